@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/weaveworks/common/logging"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/cortexproject/cortex/tools/storagemigrate"
+)
+
+func main() {
+	var (
+		configFilename string
+		tenants        flagext.StringSliceCSV
+		dryRun         bool
+		cutover        bool
+		cfg            storagemigrate.Config
+	)
+
+	logfmt, loglvl := logging.Format{}, logging.Level{}
+	logfmt.RegisterFlags(flag.CommandLine)
+	loglvl.RegisterFlags(flag.CommandLine)
+	flag.StringVar(&configFilename, "config", "", "Path to the migration config YAML, defining the source and destination buckets")
+	flag.Var(&tenants, "tenants", "Comma separated list of the tenants to migrate. If empty, all the tenants of the source storages are migrated")
+	flag.BoolVar(&dryRun, "dry-run", false, "Don't make changes; only report what needs to be done")
+	flag.BoolVar(&cutover, "cutover", false, "Run the cutover, once Cortex has been switched to the destination storage: copy the remaining blocks, propagate the deletion marks of the source blocks and regenerate the bucket index in the destination storage")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "%s is a tool to migrate the blocks, ruler and alertmanager storages from a storage backend to another.\nPlease see %s for instructions on how to run it.\n\n", os.Args[0], "https://cortexmetrics.io/docs/blocks-storage/migrate-storage-backend/")
+		fmt.Fprintf(flag.CommandLine.Output(), "Flags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	logger, err := log.NewPrometheusLogger(loglvl, logfmt)
+	if err != nil {
+		fatal("failed to create logger: %v", err)
+	}
+
+	if configFilename == "" {
+		fatal("the config file must be set")
+	}
+	buf, err := os.ReadFile(configFilename)
+	if err != nil {
+		fatal("failed to load config file from %s: %v", configFilename, err)
+	}
+	if err := yaml.UnmarshalStrict(buf, &cfg); err != nil {
+		fatal("failed to parse config file: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		fatal("config is invalid: %v", err)
+	}
+
+	ctx := context.Background()
+
+	migrator, err := storagemigrate.NewMigrator(ctx, cfg, dryRun, logger)
+	if err != nil {
+		fatal("couldn't initialize migrator: %v", err)
+	}
+
+	if len(tenants) == 0 {
+		tenants, err = migrator.Tenants(ctx)
+		if err != nil {
+			fatal("couldn't list tenants: %v", err)
+		}
+	}
+
+	failedTenants, failedBlocks, failedObjects := 0, 0, 0
+	for _, userID := range tenants {
+		res, err := migrator.Run(ctx, userID, cutover)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to migrate tenant %s: %v\n", userID, err)
+			failedTenants++
+		}
+
+		fmt.Printf("Tenant %s:\n", userID)
+		fmt.Printf("  Copied blocks %d:\n  %s\n", len(res.CopiedBlocks), strings.Join(res.CopiedBlocks, ","))
+		fmt.Printf("  Skipped blocks %d\n", len(res.SkippedBlocks))
+		fmt.Printf("  Failed blocks %d:\n  %s\n", len(res.FailedBlocks), strings.Join(res.FailedBlocks, ","))
+		if cutover {
+			fmt.Printf("  Blocks marked for deletion %d:\n  %s\n", len(res.MarkedForDeletionBlocks), strings.Join(res.MarkedForDeletionBlocks, ","))
+		}
+		fmt.Printf("  Copied objects %d\n", len(res.CopiedObjects))
+		fmt.Printf("  Failed objects %d:\n  %s\n", len(res.FailedObjects), strings.Join(res.FailedObjects, ","))
+
+		failedBlocks += len(res.FailedBlocks)
+		failedObjects += len(res.FailedObjects)
+	}
+
+	if failedTenants > 0 {
+		fatal("failed to migrate %d tenants", failedTenants)
+	}
+	if failedBlocks > 0 || failedObjects > 0 {
+		fatal("failed to copy %d blocks and %d objects", failedBlocks, failedObjects)
+	}
+}
+
+func fatal(msg string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, msg+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/weaveworks/common/logging"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	"github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/cortexproject/cortex/tools/tenantrename"
+)
+
+func main() {
+	var (
+		configFilename              string
+		sourceTenant                string
+		targetTenant                string
+		dryRun                      bool
+		markSourceBlocksForDeletion bool
+		cfg                         bucket.Config
+	)
+
+	logfmt, loglvl := logging.Format{}, logging.Level{}
+	logfmt.RegisterFlags(flag.CommandLine)
+	loglvl.RegisterFlags(flag.CommandLine)
+	cfg.RegisterFlags(flag.CommandLine)
+	flag.StringVar(&configFilename, "config", "", "Path to bucket config YAML")
+	flag.StringVar(&sourceTenant, "source-tenant", "", "Tenant whose blocks are renamed")
+	flag.StringVar(&targetTenant, "target-tenant", "", "Tenant the blocks are renamed to. If the tenant already has blocks, the blocks of the two tenants are merged")
+	flag.BoolVar(&dryRun, "dry-run", false, "Don't make changes; only report what needs to be done")
+	flag.BoolVar(&markSourceBlocksForDeletion, "mark-source-blocks-for-deletion", false, "Mark the source tenant blocks for deletion once all of them have been renamed, so that they're deleted by the compactor")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "%s is a tool to rename the tenant of the blocks in the storage.\nPlease see %s for instructions on how to run it.\n\n", os.Args[0], "https://cortexmetrics.io/docs/blocks-storage/rename-tenant/")
+		fmt.Fprintf(flag.CommandLine.Output(), "Flags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	logger, err := log.NewPrometheusLogger(loglvl, logfmt)
+	if err != nil {
+		fatal("failed to create logger: %v", err)
+	}
+
+	if configFilename != "" {
+		buf, err := os.ReadFile(configFilename)
+		if err != nil {
+			fatal("failed to load config file from %s: %v", configFilename, err)
+		}
+		err = yaml.UnmarshalStrict(buf, &cfg)
+		if err != nil {
+			fatal("failed to parse config file: %v", err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		fatal("bucket config is invalid: %v", err)
+	}
+
+	ctx := context.Background()
+
+	renamer, err := tenantrename.NewTenantRenamer(ctx, cfg, dryRun, markSourceBlocksForDeletion, logger)
+	if err != nil {
+		fatal("couldn't initialize renamer: %v", err)
+	}
+
+	results, err := renamer.Run(ctx, sourceTenant, targetTenant)
+
+	fmt.Println("Results:")
+	fmt.Printf("  Renamed %d:\n  %s\n", len(results.RenamedBlocks), strings.Join(results.RenamedBlocks, ","))
+	fmt.Printf("  Skipped %d:\n  %s\n", len(results.SkippedBlocks), strings.Join(results.SkippedBlocks, ","))
+	fmt.Printf("  Failed %d:\n  %s\n", len(results.FailedBlocks), strings.Join(results.FailedBlocks, ","))
+
+	if err != nil {
+		fatal("renamer failed: %v", err)
+	}
+	if len(results.FailedBlocks) > 0 {
+		fatal("failed to rename %d blocks", len(results.FailedBlocks))
+	}
+}
+
+func fatal(msg string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, msg+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,284 @@
+package alertmanager
+
+import (
+	"context"
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertstore"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+const (
+	alertHistoryStatusFiring   = "firing"
+	alertHistoryStatusResolved = "resolved"
+
+	alertHistoryOutcomeSuccess = "success"
+	alertHistoryOutcomeFailure = "failure"
+
+	defaultAlertHistoryFlushTimeout = 30 * time.Second
+)
+
+var (
+	errInvalidAlertHistoryFlushInterval = errors.New("invalid alertmanager alert history flush interval, must be greater than zero")
+	errInvalidAlertHistoryRetention     = errors.New("invalid alertmanager alert history retention, must be greater than zero")
+)
+
+type AlertHistoryConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+	Retention     time.Duration `yaml:"retention"`
+}
+
+func (cfg *AlertHistoryConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+".enabled", false, "[Experimental] Record the state transitions of the alerts and the outcome of their notifications to object storage, to be queried through the alert history API.")
+	f.DurationVar(&cfg.FlushInterval, prefix+".flush-interval", 5*time.Minute, "[Experimental] The interval between writing the recorded alert history to object storage. The alert history can only be queried once written.")
+	f.DurationVar(&cfg.Retention, prefix+".retention", 30*24*time.Hour, "[Experimental] How long the alert history is kept in object storage.")
+}
+
+func (cfg *AlertHistoryConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.FlushInterval <= 0 {
+		return errInvalidAlertHistoryFlushInterval
+	}
+	if cfg.Retention <= 0 {
+		return errInvalidAlertHistoryRetention
+	}
+	return nil
+}
+
+// alertHistory records the state transitions of the alerts of an user, and the outcome of their
+// notifications, and periodically writes them to persistent storage.
+//
+// Each replica of the user writes the entries it recorded, so the state transitions are recorded once
+// per replica: they are timestamped with the start or end time of the alerts to be deduplicated when
+// queried.
+type alertHistory struct {
+	services.Service
+
+	store     alertstore.AlertStore
+	userID    string
+	logger    log.Logger
+	retention time.Duration
+	timeout   time.Duration
+
+	mtx      sync.Mutex
+	entries  []alertspb.AlertHistoryEntry
+	statuses map[model.Fingerprint]string
+
+	flushTotal  prometheus.Counter
+	flushFailed prometheus.Counter
+}
+
+// newAlertHistory creates a new alert history.
+func newAlertHistory(cfg AlertHistoryConfig, userID string, store alertstore.AlertStore, l log.Logger, r prometheus.Registerer) *alertHistory {
+	h := &alertHistory{
+		store:     store,
+		userID:    userID,
+		logger:    l,
+		retention: cfg.Retention,
+		timeout:   defaultAlertHistoryFlushTimeout,
+		statuses:  map[model.Fingerprint]string{},
+		flushTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "alertmanager_alert_history_flush_total",
+			Help: "Number of times we have tried to write the alert history to remote storage.",
+		}),
+		flushFailed: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "alertmanager_alert_history_flush_failed_total",
+			Help: "Number of times we have failed to write the alert history to remote storage.",
+		}),
+	}
+
+	h.Service = services.NewTimerService(cfg.FlushInterval, nil, h.iteration, h.stopping)
+
+	return h
+}
+
+func (h *alertHistory) iteration(ctx context.Context) error {
+	if err := h.flush(ctx, time.Now()); err != nil {
+		level.Error(h.logger).Log("msg", "failed to write alert history", "user", h.userID, "err", err)
+	}
+	return nil
+}
+
+func (h *alertHistory) stopping(_ error) error {
+	// Write the entries recorded since the last flush, as the alertmanager of the user is going away.
+	if err := h.flush(context.Background(), time.Now()); err != nil {
+		level.Error(h.logger).Log("msg", "failed to write alert history", "user", h.userID, "err", err)
+	}
+	return nil
+}
+
+// flush writes the entries recorded since the last flush to the store, and deletes the entries older than the retention.
+// The entries failed to be written are discarded.
+func (h *alertHistory) flush(ctx context.Context, now time.Time) (err error) {
+	h.mtx.Lock()
+	entries := h.entries
+	h.entries = nil
+	h.mtx.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	if len(entries) > 0 {
+		h.flushTotal.Inc()
+
+		minT, maxT := entries[0].TimestampMs, entries[0].TimestampMs
+		for _, e := range entries[1:] {
+			minT, maxT = min(minT, e.TimestampMs), max(maxT, e.TimestampMs)
+		}
+
+		name := AlertHistoryBatchName(minT, maxT, ulid.MustNew(ulid.Timestamp(now), rand.Reader))
+		if err = h.store.SetAlertHistory(ctx, h.userID, name, alertspb.AlertHistoryDesc{User: h.userID, Entries: entries}); err != nil {
+			h.flushFailed.Inc()
+			return err
+		}
+	}
+
+	names, err := h.store.ListAlertHistory(ctx, h.userID)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, maxT, ok := ParseAlertHistoryBatchName(name); ok && now.Sub(maxT) > h.retention {
+			if err := h.store.DeleteAlertHistory(ctx, h.userID, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (h *alertHistory) record(e alertspb.AlertHistoryEntry) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.entries = append(h.entries, e)
+}
+
+// PreStore implements mem.AlertStoreCallback.
+func (h *alertHistory) PreStore(_ *types.Alert, _ bool) error {
+	return nil
+}
+
+// PostStore implements mem.AlertStoreCallback, recording the alerts changing state.
+func (h *alertHistory) PostStore(alert *types.Alert, _ bool) {
+	status, ts := alertHistoryStatusFiring, alert.StartsAt
+	if alert.Resolved() {
+		status, ts = alertHistoryStatusResolved, alert.EndsAt
+	}
+
+	fp := alert.Fingerprint()
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if h.statuses[fp] == status {
+		return
+	}
+	h.statuses[fp] = status
+	h.entries = append(h.entries, newAlertHistoryEntry(alert, status, ts))
+}
+
+// PostDelete implements mem.AlertStoreCallback.
+func (h *alertHistory) PostDelete(alert *types.Alert) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	delete(h.statuses, alert.Fingerprint())
+}
+
+func newAlertHistoryEntry(alert *types.Alert, status string, ts time.Time) alertspb.AlertHistoryEntry {
+	labels := make(map[string]string, len(alert.Labels))
+	for name, value := range alert.Labels {
+		labels[string(name)] = string(value)
+	}
+
+	return alertspb.AlertHistoryEntry{
+		TimestampMs: ts.UnixMilli(),
+		Fingerprint: alert.Fingerprint().String(),
+		Labels:      labels,
+		Status:      status,
+	}
+}
+
+// alertHistoryNotifier records the outcome of the notifications of an integration to the alert history.
+type alertHistoryNotifier struct {
+	upstream    notify.Notifier
+	history     *alertHistory
+	integration string
+}
+
+func newAlertHistoryNotifier(upstream notify.Notifier, history *alertHistory, integration string) *alertHistoryNotifier {
+	return &alertHistoryNotifier{
+		upstream:    upstream,
+		history:     history,
+		integration: integration,
+	}
+}
+
+func (n *alertHistoryNotifier) Notify(ctx context.Context, alerts ...*types.Alert) (bool, error) {
+	retry, err := n.upstream.Notify(ctx, alerts...)
+
+	receiver, _ := notify.ReceiverName(ctx)
+	now := time.Now()
+	for _, alert := range alerts {
+		status := alertHistoryStatusFiring
+		if alert.ResolvedAt(now) {
+			status = alertHistoryStatusResolved
+		}
+
+		e := newAlertHistoryEntry(alert, status, now)
+		e.Receiver = receiver
+		e.Integration = n.integration
+		e.Outcome = alertHistoryOutcomeSuccess
+		if err != nil {
+			e.Outcome = alertHistoryOutcomeFailure
+			e.Error = err.Error()
+		}
+		n.history.record(e)
+	}
+
+	return retry, err
+}
+
+// AlertHistoryBatchName returns the name of a batch of the alert history with entries between the given timestamps.
+func AlertHistoryBatchName(minT, maxT int64, id ulid.ULID) string {
+	return fmt.Sprintf("%d-%d-%s", minT, maxT, id.String())
+}
+
+// ParseAlertHistoryBatchName returns the time range of the entries of the batch of the alert history with the given name.
+func ParseAlertHistoryBatchName(name string) (time.Time, time.Time, bool) {
+	parts := strings.SplitN(name, "-", 3)
+	if len(parts) != 3 {
+		return time.Time{}, time.Time{}, false
+	}
+	minT, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	maxT, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	return time.UnixMilli(minT), time.UnixMilli(maxT), true
+}
@@ -0,0 +1,144 @@
+package alertmanager
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"github.com/weaveworks/common/user"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertstore/bucketclient"
+)
+
+type failingNotifier struct{}
+
+func (n *failingNotifier) Notify(_ context.Context, _ ...*types.Alert) (bool, error) {
+	return true, errors.New("connection refused")
+}
+
+func TestAlertHistory(t *testing.T) {
+	ctx := context.Background()
+	store := bucketclient.NewBucketAlertStore(objstore.NewInMemBucket(), nil, log.NewNopLogger())
+	cfg := AlertHistoryConfig{Enabled: true, FlushInterval: time.Minute, Retention: 24 * time.Hour}
+
+	now := time.Now().Truncate(time.Millisecond)
+	firing := &types.Alert{Alert: model.Alert{
+		Labels:   model.LabelSet{"alertname": "HighLatency", "service": "api"},
+		StartsAt: now.Add(-10 * time.Minute),
+		EndsAt:   now.Add(10 * time.Minute),
+	}}
+	resolved := &types.Alert{Alert: model.Alert{
+		Labels:   firing.Labels,
+		StartsAt: firing.StartsAt,
+		EndsAt:   now.Add(-time.Minute),
+	}}
+	other := &types.Alert{Alert: model.Alert{
+		Labels:   model.LabelSet{"alertname": "HighErrorRate", "service": "db"},
+		StartsAt: now.Add(-5 * time.Minute),
+		EndsAt:   now.Add(10 * time.Minute),
+	}}
+
+	// Both replicas of the user receive the alerts, and record their state transitions.
+	replicas := []*alertHistory{
+		newAlertHistory(cfg, "user-1", store, log.NewNopLogger(), nil),
+		newAlertHistory(cfg, "user-1", store, log.NewNopLogger(), nil),
+	}
+	for _, h := range replicas {
+		h.PostStore(firing, false)
+		h.PostStore(firing, true)
+		h.PostStore(other, false)
+	}
+
+	// Only one of the replicas sends the notifications.
+	notifyCtx := notify.WithReceiverName(ctx, "team-api")
+	_, err := newAlertHistoryNotifier(&mockNotifier{}, replicas[0], "webhook").Notify(notifyCtx, firing)
+	require.NoError(t, err)
+	_, err = newAlertHistoryNotifier(&failingNotifier{}, replicas[0], "email").Notify(notifyCtx, firing)
+	require.Error(t, err)
+
+	for _, h := range replicas {
+		h.PostStore(resolved, true)
+		h.PostStore(resolved, true)
+		require.NoError(t, h.flush(ctx, now))
+	}
+
+	am := &MultitenantAlertmanager{
+		cfg:    &MultitenantAlertmanagerConfig{AlertHistory: cfg},
+		store:  store,
+		logger: log.NewNopLogger(),
+	}
+	query := func(t *testing.T, params url.Values) []AlertHistoryEntry {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/alerts/history?"+params.Encode(), nil)
+		req = req.WithContext(user.InjectOrgID(req.Context(), "user-1"))
+		rec := httptest.NewRecorder()
+		am.GetUserAlertHistory(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		history := UserAlertHistory{}
+		require.NoError(t, yaml.Unmarshal(rec.Body.Bytes(), &history))
+		return history.Entries
+	}
+	type entry struct {
+		alertname, status, receiver, integration, outcome string
+	}
+	summarize := func(entries []AlertHistoryEntry) []entry {
+		res := make([]entry, 0, len(entries))
+		for _, e := range entries {
+			res = append(res, entry{e.Labels["alertname"], e.Status, e.Receiver, e.Integration, e.Outcome})
+		}
+		return res
+	}
+
+	// The state transitions recorded by both replicas are deduplicated.
+	entries := query(t, url.Values{})
+	assert.Equal(t, []entry{
+		{"HighLatency", "firing", "", "", ""},
+		{"HighErrorRate", "firing", "", "", ""},
+		{"HighLatency", "resolved", "", "", ""},
+		{"HighLatency", "firing", "team-api", "webhook", "success"},
+		{"HighLatency", "firing", "team-api", "email", "failure"},
+	}, summarize(entries))
+	assert.Equal(t, firing.StartsAt.UTC(), entries[0].Time)
+	assert.Equal(t, "connection refused", entries[4].Error)
+
+	// The entries are selected by time range, matchers, receiver and status.
+	assert.Equal(t, []entry{
+		{"HighErrorRate", "firing", "", "", ""},
+	}, summarize(query(t, url.Values{"filter": {`service="db"`}})))
+	assert.Equal(t, []entry{
+		{"HighLatency", "firing", "team-api", "webhook", "success"},
+		{"HighLatency", "firing", "team-api", "email", "failure"},
+	}, summarize(query(t, url.Values{"receiver": {"team-api"}})))
+	assert.Equal(t, []entry{
+		{"HighLatency", "resolved", "", "", ""},
+	}, summarize(query(t, url.Values{"status": {"resolved"}})))
+	assert.Equal(t, []entry{
+		{"HighLatency", "firing", "", "", ""},
+	}, summarize(query(t, url.Values{"end": {now.Add(-8 * time.Minute).Format(time.RFC3339Nano)}})))
+
+	// The entries older than the retention are deleted.
+	require.NoError(t, replicas[0].flush(ctx, now.Add(25*time.Hour)))
+	names, err := store.ListAlertHistory(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	// The alert history can't be queried when disabled.
+	am.cfg.AlertHistory.Enabled = false
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/alerts/history", nil)
+	req = req.WithContext(user.InjectOrgID(req.Context(), "user-1"))
+	rec := httptest.NewRecorder()
+	am.GetUserAlertHistory(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -53,6 +53,7 @@ import (
 	"golang.org/x/time/rate"
 
 	"github.com/cortexproject/cortex/pkg/alertmanager/alertstore"
+	"github.com/cortexproject/cortex/pkg/alertmanager/auditlog"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 	util_net "github.com/cortexproject/cortex/pkg/util/net"
 	"github.com/cortexproject/cortex/pkg/util/services"
@@ -86,8 +87,14 @@ type Config struct {
 	Replicator        Replicator
 	Store             alertstore.AlertStore
 	PersisterConfig   PersisterConfig
+	AlertHistory      AlertHistoryConfig
+	NotificationAudit auditlog.Sink
+	OperatorConfig    *OperatorConfig
 	APIConcurrency    int
 	GCInterval        time.Duration
+
+	// NotificationLogCompactionInterval is how often the notification log is compacted, 0 to disable the compaction.
+	NotificationLogCompactionInterval time.Duration
 }
 
 // An Alertmanager manages the alerts for one user.
@@ -97,10 +104,11 @@ type Alertmanager struct {
 	logger          log.Logger
 	state           State
 	persister       *statePersister
+	history         *alertHistory
 	nflog           *nflog.Log
 	silences        *silence.Silences
 	marker          types.Marker
-	alerts          *mem.Alerts
+	alerts          *alertsProvider
 	dispatcher      *dispatch.Dispatcher
 	inhibitor       *inhibit.Inhibitor
 	pipelineBuilder *notify.PipelineBuilder
@@ -122,6 +130,8 @@ type Alertmanager struct {
 	configHashMetric prometheus.Gauge
 
 	rateLimitedNotifications *prometheus.CounterVec
+	silencesLimiter          *silencesLimiter
+	nflogCompactor           *nflogCompactor
 }
 
 var (
@@ -176,6 +186,8 @@ func New(cfg *Config, reg *prometheus.Registry) (*Alertmanager, error) {
 			Help: "Number of rate-limited notifications per integration.",
 		}, []string{"integration"}), // "integration" is consistent with other alertmanager metrics.
 
+		silencesLimiter: newSilencesLimiter(reg),
+		nflogCompactor:  newNflogCompactor(reg),
 	}
 
 	am.registry = reg
@@ -218,6 +230,10 @@ func New(cfg *Config, reg *prometheus.Registry) (*Alertmanager, error) {
 		am.nflog.Maintenance(maintenancePeriod, notificationFile, am.stop, nil)
 		am.wg.Done()
 	}()
+	if cfg.NotificationLogCompactionInterval > 0 {
+		am.wg.Add(1)
+		go am.compactNotificationLogLoop(cfg.NotificationLogCompactionInterval)
+	}
 	am.marker = types.NewMarker(am.registry)
 
 	silencesFile := filepath.Join(cfg.TenantDataDir, silencesSnapshot)
@@ -260,14 +276,28 @@ func New(cfg *Config, reg *prometheus.Registry) (*Alertmanager, error) {
 		am.wg.Done()
 	}()
 
-	var callback mem.AlertStoreCallback
+	am.wg.Add(1)
+	go am.purgeSilencesLoop()
+
+	if cfg.AlertHistory.Enabled && cfg.Store != nil {
+		am.history = newAlertHistory(cfg.AlertHistory, cfg.UserID, cfg.Store, am.logger, am.registry)
+		if err := am.history.StartAsync(context.Background()); err != nil {
+			return nil, errors.Wrap(err, "failed to start alert history service")
+		}
+	}
+
+	var (
+		callbacks     alertStoreCallbacks
+		alertsLimiter *alertsLimiter
+	)
 	if am.cfg.Limits != nil {
-		callback = newAlertsLimiter(am.cfg.UserID, am.cfg.Limits, reg)
+		alertsLimiter = newAlertsLimiter(am.cfg.UserID, am.cfg.Limits, reg)
+		callbacks = append(callbacks, alertsLimiter)
 	}
-	am.alerts, err = mem.NewAlerts(context.Background(), am.marker, am.cfg.GCInterval, callback, am.logger, am.registry)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create alerts: %v", err)
+	if am.history != nil {
+		callbacks = append(callbacks, am.history)
 	}
+	am.alerts = newAlertsProvider(context.Background(), am.marker, am.cfg.GCInterval, callbacks, am.cfg.UserID, am.cfg.Limits, alertsLimiter, am.logger, am.registry)
 
 	am.api, err = api.New(api.Options{
 		Alerts:     am.alerts,
@@ -302,6 +332,9 @@ func New(cfg *Config, reg *prometheus.Registry) (*Alertmanager, error) {
 		am.mux.Handle(a, http.NotFoundHandler())
 	}
 
+	am.mux.HandleFunc(path.Join(am.cfg.ExternalURL.Path, bulkSilencesPath), am.serveBulkSilences)
+	am.registerSilencesLimitHandler()
+
 	am.dispatcherMetrics = dispatch.NewDispatcherMetrics(true, am.registry)
 
 	//TODO: From this point onward, the alertmanager _might_ receive requests - we need to make sure we've settled and are ready.
@@ -325,8 +358,8 @@ func clusterWait(position func() int, timeout time.Duration) func() time.Duratio
 	}
 }
 
-// ApplyConfig applies a new configuration to an Alertmanager.
-func (am *Alertmanager) ApplyConfig(userID string, conf *config.Config, rawCfg string) error {
+// ApplyConfig applies a new configuration to an Alertmanager, along with the generic HTTP receivers of its receivers.
+func (am *Alertmanager) ApplyConfig(userID string, conf *config.Config, httpConfigs []*HTTPReceiverConfig, rawCfg string) error {
 	templateFiles := make([]string, len(conf.Templates))
 	for i, t := range conf.Templates {
 		templateFilepath, err := safeTemplateFilepath(filepath.Join(am.cfg.TenantDataDir, templatesDir), t)
@@ -355,7 +388,12 @@ func (am *Alertmanager) ApplyConfig(userID string, conf *config.Config, rawCfg s
 		am.dispatcher.Stop()
 	}
 
-	am.inhibitor = inhibit.NewInhibitor(am.alerts, conf.InhibitRules, am.marker, log.With(am.logger, "component", "inhibitor"))
+	inhibitRules := conf.InhibitRules
+	if am.cfg.OperatorConfig != nil {
+		// The inhibition rules of the operator are evaluated along with the ones of the tenant.
+		inhibitRules = append(inhibitRules[:len(inhibitRules):len(inhibitRules)], am.cfg.OperatorConfig.InhibitRules...)
+	}
+	am.inhibitor = inhibit.NewInhibitor(am.alerts, inhibitRules, am.marker, log.With(am.logger, "component", "inhibitor"))
 
 	waitFunc := clusterWait(am.state.Position, am.cfg.PeerTimeout)
 
@@ -369,7 +407,7 @@ func (am *Alertmanager) ApplyConfig(userID string, conf *config.Config, rawCfg s
 	// Create a firewall binded to the per-tenant config.
 	firewallDialer := util_net.NewFirewallDialer(newFirewallDialerConfigProvider(userID, am.cfg.Limits))
 
-	integrationsMap, err := buildIntegrationsMap(conf.Receivers, tmpl, firewallDialer, am.logger, func(integrationName string, notifier notify.Notifier) notify.Notifier {
+	integrationsMap, err := buildIntegrationsMap(conf.Receivers, httpConfigs, tmpl, firewallDialer, am.logger, func(integrationName string, notifier notify.Notifier) notify.Notifier {
 		if am.cfg.Limits != nil {
 			rl := &tenantRateLimits{
 				tenant:      userID,
@@ -377,7 +415,13 @@ func (am *Alertmanager) ApplyConfig(userID string, conf *config.Config, rawCfg s
 				integration: integrationName,
 			}
 
-			return newRateLimitedNotifier(notifier, rl, 10*time.Second, am.rateLimitedNotifications.WithLabelValues(integrationName))
+			notifier = newRateLimitedNotifier(notifier, rl, 10*time.Second, am.rateLimitedNotifications.WithLabelValues(integrationName))
+		}
+		if am.cfg.NotificationAudit != nil {
+			notifier = newAuditedNotifier(notifier, am.cfg.NotificationAudit, userID, integrationName, am.logger)
+		}
+		if am.history != nil {
+			notifier = newAlertHistoryNotifier(notifier, am.history, integrationName)
 		}
 		return notifier
 	})
@@ -403,6 +447,13 @@ func (am *Alertmanager) ApplyConfig(userID string, conf *config.Config, rawCfg s
 		am.nflog,
 		am.state,
 	)
+	if am.cfg.OperatorConfig != nil && len(am.cfg.OperatorConfig.MuteRules) > 0 {
+		// The mute rules of the operator take precedence over the config of the tenant.
+		muteStage := newOperatorMuteStage(am.cfg.OperatorConfig.MuteRules)
+		for receiver, stage := range pipeline {
+			pipeline[receiver] = notify.MultiStage{muteStage, stage}
+		}
+	}
 	am.lastPipeline = pipeline
 	am.dispatcher = dispatch.NewDispatcher(
 		am.alerts,
@@ -436,6 +487,10 @@ func (am *Alertmanager) Stop() {
 		am.persister.StopAsync()
 	}
 
+	if am.history != nil {
+		am.history.StopAsync()
+	}
+
 	if service, ok := am.state.(services.Service); ok {
 		service.StopAsync()
 	}
@@ -444,6 +499,16 @@ func (am *Alertmanager) Stop() {
 	close(am.stop)
 }
 
+// PersistState writes the state of the tenant to the persistent storage right away, whatever the position of
+// the replica, so that the replicas taking over the tenant can read it.
+func (am *Alertmanager) PersistState(ctx context.Context) error {
+	// The state is not persisted before being settled.
+	if am.persister == nil || am.persister.State() != services.Running {
+		return nil
+	}
+	return am.persister.write(ctx)
+}
+
 func (am *Alertmanager) StopAndWait() {
 	am.Stop()
 
@@ -453,6 +518,12 @@ func (am *Alertmanager) StopAndWait() {
 		}
 	}
 
+	if am.history != nil {
+		if err := am.history.AwaitTerminated(context.Background()); err != nil {
+			level.Warn(am.logger).Log("msg", "error while stopping alert history service", "err", err)
+		}
+	}
+
 	if service, ok := am.state.(services.Service); ok {
 		if err := service.AwaitTerminated(context.Background()); err != nil {
 			level.Warn(am.logger).Log("msg", "error while stopping ring-based replication service", "err", err)
@@ -478,10 +549,10 @@ func (am *Alertmanager) getFullState() (*clusterpb.FullState, error) {
 
 // buildIntegrationsMap builds a map of name to the list of integration notifiers off of a
 // list of receiver config.
-func buildIntegrationsMap(nc []config.Receiver, tmpl *template.Template, firewallDialer *util_net.FirewallDialer, logger log.Logger, notifierWrapper func(string, notify.Notifier) notify.Notifier) (map[string][]notify.Integration, error) {
+func buildIntegrationsMap(nc []config.Receiver, httpConfigs []*HTTPReceiverConfig, tmpl *template.Template, firewallDialer *util_net.FirewallDialer, logger log.Logger, notifierWrapper func(string, notify.Notifier) notify.Notifier) (map[string][]notify.Integration, error) {
 	integrationsMap := make(map[string][]notify.Integration, len(nc))
 	for _, rcv := range nc {
-		integrations, err := buildReceiverIntegrations(rcv, tmpl, firewallDialer, logger, notifierWrapper)
+		integrations, err := buildReceiverIntegrations(rcv, receiverHTTPConfigs(httpConfigs, rcv.Name), tmpl, firewallDialer, logger, notifierWrapper)
 		if err != nil {
 			return nil, err
 		}
@@ -491,9 +562,9 @@ func buildIntegrationsMap(nc []config.Receiver, tmpl *template.Template, firewal
 }
 
 // buildReceiverIntegrations builds a list of integration notifiers off of a
-// receiver config.
+// receiver config and its generic HTTP receivers.
 // Taken from https://github.com/prometheus/alertmanager/blob/d7b4f0c7322e7151d6e3b1e31cbc15361e295d8d/cmd/alertmanager/main.go#L135-L193.
-func buildReceiverIntegrations(nc config.Receiver, tmpl *template.Template, firewallDialer *util_net.FirewallDialer, logger log.Logger, wrapper func(string, notify.Notifier) notify.Notifier) ([]notify.Integration, error) {
+func buildReceiverIntegrations(nc config.Receiver, httpConfigs []*HTTPReceiverConfig, tmpl *template.Template, firewallDialer *util_net.FirewallDialer, logger log.Logger, wrapper func(string, notify.Notifier) notify.Notifier) ([]notify.Integration, error) {
 	var (
 		errs         types.MultiError
 		integrations []notify.Integration
@@ -552,6 +623,9 @@ func buildReceiverIntegrations(nc config.Receiver, tmpl *template.Template, fire
 	for i, c := range nc.MSTeamsConfigs {
 		add("msteams", i, c, func(l log.Logger) (notify.Notifier, error) { return msteams.New(c, tmpl, l) })
 	}
+	for i, c := range httpConfigs {
+		add(httpReceiverIntegration, i, c, func(l log.Logger) (notify.Notifier, error) { return newHTTPReceiverNotifier(c, tmpl, l, httpOps...) })
+	}
 	// If we add support for more integrations, we need to add them to validation as well. See validation.allowedIntegrationNames field.
 	if errs.Len() > 0 {
 		return nil, &errs
@@ -605,6 +679,14 @@ func (p firewallDialerConfigProvider) BlockPrivateAddresses() bool {
 	return p.limits.AlertmanagerReceiversBlockPrivateAddresses(p.userID)
 }
 
+func (p firewallDialerConfigProvider) AllowedHosts() []string {
+	return p.limits.AlertmanagerReceiversAllowedHosts(p.userID)
+}
+
+func (p firewallDialerConfigProvider) BlockedHosts() []string {
+	return p.limits.AlertmanagerReceiversBlockedHosts(p.userID)
+}
+
 type tenantRateLimits struct {
 	tenant      string
 	integration string
@@ -679,6 +761,15 @@ func newAlertsLimiter(tenant string, limits Limits, reg prometheus.Registerer) *
 }
 
 func (a *alertsLimiter) PreStore(alert *types.Alert, existing bool) error {
+	if err := a.checkLimits(alert, existing); err != nil {
+		a.failureCounter.Inc()
+		return err
+	}
+	return nil
+}
+
+// checkLimits returns an error if storing the alert would exceed the alerts limits of the tenant.
+func (a *alertsLimiter) checkLimits(alert *types.Alert, existing bool) error {
 	if alert == nil {
 		return nil
 	}
@@ -694,7 +785,6 @@ func (a *alertsLimiter) PreStore(alert *types.Alert, existing bool) error {
 	defer a.mx.Unlock()
 
 	if !existing && countLimit > 0 && (a.count+1) > countLimit {
-		a.failureCounter.Inc()
 		return fmt.Errorf(errTooManyAlerts, countLimit, alert.Name())
 	}
 
@@ -703,7 +793,6 @@ func (a *alertsLimiter) PreStore(alert *types.Alert, existing bool) error {
 	}
 
 	if sizeLimit > 0 && (a.totalSize+sizeDiff) > sizeLimit {
-		a.failureCounter.Inc()
 		return fmt.Errorf(errAlertsTooBig, sizeLimit)
 	}
 
@@ -752,6 +841,30 @@ func (a *alertsLimiter) currentStats() (count, totalSize int) {
 	return a.count, a.totalSize
 }
 
+// alertStoreCallbacks calls each of the callbacks of the alerts store in order.
+type alertStoreCallbacks []mem.AlertStoreCallback
+
+func (c alertStoreCallbacks) PreStore(alert *types.Alert, existing bool) error {
+	for _, cb := range c {
+		if err := cb.PreStore(alert, existing); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c alertStoreCallbacks) PostStore(alert *types.Alert, existing bool) {
+	for _, cb := range c {
+		cb.PostStore(alert, existing)
+	}
+}
+
+func (c alertStoreCallbacks) PostDelete(alert *types.Alert) {
+	for _, cb := range c {
+		cb.PostDelete(alert)
+	}
+}
+
 func alertSize(alert model.Alert) int {
 	size := 0
 	for l, v := range alert.Labels {
@@ -110,3 +110,30 @@ func (s StatusHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
+
+// PrepareShutdownHandler prepares the alertmanager to persist the state of its tenants when it shuts down.
+func (am *MultitenantAlertmanager) PrepareShutdownHandler(w http.ResponseWriter, req *http.Request) {
+	if am.checkPreparationAvailable(w) {
+		am.preparation.PrepareShutdownHandler(w, req)
+	}
+}
+
+// PrepareDownscaleHandler switches the alertmanager to LEAVING, so that its tenants are taken over by other
+// replicas, persists their state and reports when they have been stopped.
+func (am *MultitenantAlertmanager) PrepareDownscaleHandler(w http.ResponseWriter, req *http.Request) {
+	if am.checkPreparationAvailable(w) {
+		am.preparation.PrepareDownscaleHandler(w, req)
+	}
+}
+
+func (am *MultitenantAlertmanager) checkPreparationAvailable(w http.ResponseWriter) bool {
+	if !am.cfg.ShardingEnabled {
+		http.Error(w, "Alertmanager has no ring because sharding is disabled.", http.StatusNotFound)
+		return false
+	}
+	if am.State() != services.Running {
+		http.Error(w, "Alertmanager is not running yet.", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
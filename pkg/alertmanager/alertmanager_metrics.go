@@ -47,17 +47,23 @@ type alertmanagerMetrics struct {
 	// The alertmanager config hash.
 	configHashValue *prometheus.Desc
 
-	partialMerges           *prometheus.Desc
-	partialMergesFailed     *prometheus.Desc
-	replicationTotal        *prometheus.Desc
-	replicationFailed       *prometheus.Desc
-	fetchReplicaStateTotal  *prometheus.Desc
-	fetchReplicaStateFailed *prometheus.Desc
-	initialSyncTotal        *prometheus.Desc
-	initialSyncCompleted    *prometheus.Desc
-	initialSyncDuration     *prometheus.Desc
-	persistTotal            *prometheus.Desc
-	persistFailed           *prometheus.Desc
+	partialMerges             *prometheus.Desc
+	partialMergesFailed       *prometheus.Desc
+	replicationTotal          *prometheus.Desc
+	replicationFailed         *prometheus.Desc
+	fetchReplicaStateTotal    *prometheus.Desc
+	fetchReplicaStateFailed   *prometheus.Desc
+	initialSyncTotal          *prometheus.Desc
+	initialSyncCompleted      *prometheus.Desc
+	initialSyncDuration       *prometheus.Desc
+	persistTotal              *prometheus.Desc
+	persistFailed             *prometheus.Desc
+	snapshotTotal             *prometheus.Desc
+	snapshotFailed            *prometheus.Desc
+	fallbackReplicationTotal  *prometheus.Desc
+	fallbackReplicationFailed *prometheus.Desc
+	alertHistoryFlushTotal    *prometheus.Desc
+	alertHistoryFlushFailed   *prometheus.Desc
 
 	notificationRateLimited                 *prometheus.Desc
 	dispatcherAggregationGroups             *prometheus.Desc
@@ -66,6 +72,11 @@ type alertmanagerMetrics struct {
 	insertAlertFailures                     *prometheus.Desc
 	alertsLimiterAlertsCount                *prometheus.Desc
 	alertsLimiterAlertsSize                 *prometheus.Desc
+	alertsEvicted                           *prometheus.Desc
+	silencesInsertLimited                   *prometheus.Desc
+	silencesPurged                          *prometheus.Desc
+	notificationLogSize                     *prometheus.Desc
+	notificationLogEvicted                  *prometheus.Desc
 }
 
 func newAlertmanagerMetrics() *alertmanagerMetrics {
@@ -211,6 +222,30 @@ func newAlertmanagerMetrics() *alertmanagerMetrics {
 			"cortex_alertmanager_state_persist_failed_total",
 			"Number of times we have failed to persist the running state to storage.",
 			nil, nil),
+		snapshotTotal: prometheus.NewDesc(
+			"cortex_alertmanager_state_snapshot_total",
+			"Number of times we have tried to snapshot the running state to storage.",
+			nil, nil),
+		snapshotFailed: prometheus.NewDesc(
+			"cortex_alertmanager_state_snapshot_failed_total",
+			"Number of times we have failed to snapshot the running state to storage.",
+			nil, nil),
+		fallbackReplicationTotal: prometheus.NewDesc(
+			"cortex_alertmanager_state_fallback_replication_total",
+			"Number of times we have tried to merge the state persisted in storage with the running state.",
+			nil, nil),
+		fallbackReplicationFailed: prometheus.NewDesc(
+			"cortex_alertmanager_state_fallback_replication_failed_total",
+			"Number of times we have failed to merge the state persisted in storage with the running state.",
+			nil, nil),
+		alertHistoryFlushTotal: prometheus.NewDesc(
+			"cortex_alertmanager_alert_history_flush_total",
+			"Number of times we have tried to write the alert history to storage.",
+			nil, nil),
+		alertHistoryFlushFailed: prometheus.NewDesc(
+			"cortex_alertmanager_alert_history_flush_failed_total",
+			"Number of times we have failed to write the alert history to storage.",
+			nil, nil),
 		notificationRateLimited: prometheus.NewDesc(
 			"cortex_alertmanager_notification_rate_limited_total",
 			"Total number of rate-limited notifications per integration.",
@@ -239,6 +274,26 @@ func newAlertmanagerMetrics() *alertmanagerMetrics {
 			"cortex_alertmanager_alerts_limiter_current_alerts_size_bytes",
 			"Total size of alerts tracked by alerts limiter.",
 			[]string{"user"}, nil),
+		alertsEvicted: prometheus.NewDesc(
+			"cortex_alertmanager_alerts_evicted_total",
+			"Total number of resolved alerts evicted from the in-memory alert store to make room for new alerts.",
+			[]string{"user"}, nil),
+		silencesInsertLimited: prometheus.NewDesc(
+			"cortex_alertmanager_silences_insert_limited_total",
+			"Total number of silences that were not created because the silences limit of the tenant was reached.",
+			[]string{"user"}, nil),
+		silencesPurged: prometheus.NewDesc(
+			"cortex_alertmanager_silences_purged_total",
+			"Total number of expired silences purged by the silences retention policies of the tenant.",
+			[]string{"user"}, nil),
+		notificationLogSize: prometheus.NewDesc(
+			"cortex_alertmanager_notification_log_size_bytes",
+			"Size of the notification log, as of its last compaction.",
+			[]string{"user"}, nil),
+		notificationLogEvicted: prometheus.NewDesc(
+			"cortex_alertmanager_notification_log_evicted_total",
+			"Total number of entries evicted from the notification log because it exceeded its size limit.",
+			[]string{"user"}, nil),
 	}
 }
 
@@ -288,6 +343,12 @@ func (m *alertmanagerMetrics) Describe(out chan<- *prometheus.Desc) {
 	out <- m.initialSyncDuration
 	out <- m.persistTotal
 	out <- m.persistFailed
+	out <- m.snapshotTotal
+	out <- m.snapshotFailed
+	out <- m.fallbackReplicationTotal
+	out <- m.fallbackReplicationFailed
+	out <- m.alertHistoryFlushTotal
+	out <- m.alertHistoryFlushFailed
 	out <- m.notificationRateLimited
 	out <- m.dispatcherAggregationGroups
 	out <- m.dispatcherProcessingDuration
@@ -295,6 +356,11 @@ func (m *alertmanagerMetrics) Describe(out chan<- *prometheus.Desc) {
 	out <- m.insertAlertFailures
 	out <- m.alertsLimiterAlertsCount
 	out <- m.alertsLimiterAlertsSize
+	out <- m.alertsEvicted
+	out <- m.silencesInsertLimited
+	out <- m.silencesPurged
+	out <- m.notificationLogSize
+	out <- m.notificationLogEvicted
 }
 
 func (m *alertmanagerMetrics) Collect(out chan<- prometheus.Metric) {
@@ -340,6 +406,12 @@ func (m *alertmanagerMetrics) Collect(out chan<- prometheus.Metric) {
 	data.SendSumOfHistograms(out, m.initialSyncDuration, "alertmanager_state_initial_sync_duration_seconds")
 	data.SendSumOfCounters(out, m.persistTotal, "alertmanager_state_persist_total")
 	data.SendSumOfCounters(out, m.persistFailed, "alertmanager_state_persist_failed_total")
+	data.SendSumOfCounters(out, m.snapshotTotal, "alertmanager_state_snapshot_total")
+	data.SendSumOfCounters(out, m.snapshotFailed, "alertmanager_state_snapshot_failed_total")
+	data.SendSumOfCounters(out, m.fallbackReplicationTotal, "alertmanager_state_fallback_replication_total")
+	data.SendSumOfCounters(out, m.fallbackReplicationFailed, "alertmanager_state_fallback_replication_failed_total")
+	data.SendSumOfCounters(out, m.alertHistoryFlushTotal, "alertmanager_alert_history_flush_total")
+	data.SendSumOfCounters(out, m.alertHistoryFlushFailed, "alertmanager_alert_history_flush_failed_total")
 
 	data.SendSumOfCountersPerUserWithLabels(out, m.notificationRateLimited, "alertmanager_notification_rate_limited_total", "integration")
 	data.SendSumOfGaugesPerUser(out, m.dispatcherAggregationGroups, "alertmanager_dispatcher_aggregation_groups")
@@ -348,4 +420,9 @@ func (m *alertmanagerMetrics) Collect(out chan<- prometheus.Metric) {
 	data.SendSumOfCountersPerUser(out, m.insertAlertFailures, "alertmanager_alerts_insert_limited_total")
 	data.SendSumOfGaugesPerUser(out, m.alertsLimiterAlertsCount, "alertmanager_alerts_limiter_current_alerts")
 	data.SendSumOfGaugesPerUser(out, m.alertsLimiterAlertsSize, "alertmanager_alerts_limiter_current_alerts_size_bytes")
+	data.SendSumOfCountersPerUser(out, m.alertsEvicted, "alertmanager_alerts_evicted_total")
+	data.SendSumOfCountersPerUser(out, m.silencesInsertLimited, "alertmanager_silences_insert_limited_total")
+	data.SendSumOfCountersPerUser(out, m.silencesPurged, "alertmanager_silences_purged_total")
+	data.SendSumOfGaugesPerUser(out, m.notificationLogSize, "alertmanager_notification_log_size_bytes")
+	data.SendSumOfCountersPerUser(out, m.notificationLogEvicted, "alertmanager_notification_log_evicted_total")
 }
@@ -297,6 +297,24 @@ func TestAlertmanagerMetricsStore(t *testing.T) {
 		# HELP cortex_alertmanager_state_persist_total Number of times we have tried to persist the running state to storage.
 		# TYPE cortex_alertmanager_state_persist_total counter
 		cortex_alertmanager_state_persist_total 0
+		# HELP cortex_alertmanager_state_snapshot_failed_total Number of times we have failed to snapshot the running state to storage.
+		# TYPE cortex_alertmanager_state_snapshot_failed_total counter
+		cortex_alertmanager_state_snapshot_failed_total 0
+		# HELP cortex_alertmanager_state_snapshot_total Number of times we have tried to snapshot the running state to storage.
+		# TYPE cortex_alertmanager_state_snapshot_total counter
+		cortex_alertmanager_state_snapshot_total 0
+		# HELP cortex_alertmanager_state_fallback_replication_failed_total Number of times we have failed to merge the state persisted in storage with the running state.
+		# TYPE cortex_alertmanager_state_fallback_replication_failed_total counter
+		cortex_alertmanager_state_fallback_replication_failed_total 0
+		# HELP cortex_alertmanager_state_fallback_replication_total Number of times we have tried to merge the state persisted in storage with the running state.
+		# TYPE cortex_alertmanager_state_fallback_replication_total counter
+		cortex_alertmanager_state_fallback_replication_total 0
+		# HELP cortex_alertmanager_alert_history_flush_failed_total Number of times we have failed to write the alert history to storage.
+		# TYPE cortex_alertmanager_alert_history_flush_failed_total counter
+		cortex_alertmanager_alert_history_flush_failed_total 0
+		# HELP cortex_alertmanager_alert_history_flush_total Number of times we have tried to write the alert history to storage.
+		# TYPE cortex_alertmanager_alert_history_flush_total counter
+		cortex_alertmanager_alert_history_flush_total 0
 
 		# HELP cortex_alertmanager_alerts_limiter_current_alerts Number of alerts tracked by alerts limiter.
 		# TYPE cortex_alertmanager_alerts_limiter_current_alerts gauge
@@ -308,6 +326,31 @@ func TestAlertmanagerMetricsStore(t *testing.T) {
 		cortex_alertmanager_alerts_limiter_current_alerts_size_bytes{user="user1"} 100
 		cortex_alertmanager_alerts_limiter_current_alerts_size_bytes{user="user2"} 1000
 		cortex_alertmanager_alerts_limiter_current_alerts_size_bytes{user="user3"} 10000
+		# HELP cortex_alertmanager_alerts_evicted_total Total number of resolved alerts evicted from the in-memory alert store to make room for new alerts.
+		# TYPE cortex_alertmanager_alerts_evicted_total counter
+		cortex_alertmanager_alerts_evicted_total{user="user1"} 3
+		cortex_alertmanager_alerts_evicted_total{user="user2"} 30
+		cortex_alertmanager_alerts_evicted_total{user="user3"} 300
+		# HELP cortex_alertmanager_silences_insert_limited_total Total number of silences that were not created because the silences limit of the tenant was reached.
+		# TYPE cortex_alertmanager_silences_insert_limited_total counter
+		cortex_alertmanager_silences_insert_limited_total{user="user1"} 4
+		cortex_alertmanager_silences_insert_limited_total{user="user2"} 40
+		cortex_alertmanager_silences_insert_limited_total{user="user3"} 400
+		# HELP cortex_alertmanager_silences_purged_total Total number of expired silences purged by the silences retention policies of the tenant.
+		# TYPE cortex_alertmanager_silences_purged_total counter
+		cortex_alertmanager_silences_purged_total{user="user1"} 5
+		cortex_alertmanager_silences_purged_total{user="user2"} 50
+		cortex_alertmanager_silences_purged_total{user="user3"} 500
+		# HELP cortex_alertmanager_notification_log_size_bytes Size of the notification log, as of its last compaction.
+		# TYPE cortex_alertmanager_notification_log_size_bytes gauge
+		cortex_alertmanager_notification_log_size_bytes{user="user1"} 200
+		cortex_alertmanager_notification_log_size_bytes{user="user2"} 2000
+		cortex_alertmanager_notification_log_size_bytes{user="user3"} 20000
+		# HELP cortex_alertmanager_notification_log_evicted_total Total number of entries evicted from the notification log because it exceeded its size limit.
+		# TYPE cortex_alertmanager_notification_log_evicted_total counter
+		cortex_alertmanager_notification_log_evicted_total{user="user1"} 6
+		cortex_alertmanager_notification_log_evicted_total{user="user2"} 60
+		cortex_alertmanager_notification_log_evicted_total{user="user3"} 600
 		# HELP cortex_alertmanager_alerts_insert_limited_total Total number of failures to store alert due to hitting alertmanager limits.
 		# TYPE cortex_alertmanager_alerts_insert_limited_total counter
 		cortex_alertmanager_alerts_insert_limited_total{user="user1"} 7
@@ -618,6 +661,24 @@ func TestAlertmanagerMetricsRemoval(t *testing.T) {
 						# HELP cortex_alertmanager_state_persist_total Number of times we have tried to persist the running state to storage.
 						# TYPE cortex_alertmanager_state_persist_total counter
 						cortex_alertmanager_state_persist_total 0
+						# HELP cortex_alertmanager_state_snapshot_failed_total Number of times we have failed to snapshot the running state to storage.
+						# TYPE cortex_alertmanager_state_snapshot_failed_total counter
+						cortex_alertmanager_state_snapshot_failed_total 0
+						# HELP cortex_alertmanager_state_snapshot_total Number of times we have tried to snapshot the running state to storage.
+						# TYPE cortex_alertmanager_state_snapshot_total counter
+						cortex_alertmanager_state_snapshot_total 0
+						# HELP cortex_alertmanager_state_fallback_replication_failed_total Number of times we have failed to merge the state persisted in storage with the running state.
+						# TYPE cortex_alertmanager_state_fallback_replication_failed_total counter
+						cortex_alertmanager_state_fallback_replication_failed_total 0
+						# HELP cortex_alertmanager_state_fallback_replication_total Number of times we have tried to merge the state persisted in storage with the running state.
+						# TYPE cortex_alertmanager_state_fallback_replication_total counter
+						cortex_alertmanager_state_fallback_replication_total 0
+						# HELP cortex_alertmanager_alert_history_flush_failed_total Number of times we have failed to write the alert history to storage.
+						# TYPE cortex_alertmanager_alert_history_flush_failed_total counter
+						cortex_alertmanager_alert_history_flush_failed_total 0
+						# HELP cortex_alertmanager_alert_history_flush_total Number of times we have tried to write the alert history to storage.
+						# TYPE cortex_alertmanager_alert_history_flush_total counter
+						cortex_alertmanager_alert_history_flush_total 0
 
 						# HELP cortex_alertmanager_alerts_limiter_current_alerts Number of alerts tracked by alerts limiter.
 						# TYPE cortex_alertmanager_alerts_limiter_current_alerts gauge
@@ -629,6 +690,31 @@ func TestAlertmanagerMetricsRemoval(t *testing.T) {
 						cortex_alertmanager_alerts_limiter_current_alerts_size_bytes{user="user1"} 100
 						cortex_alertmanager_alerts_limiter_current_alerts_size_bytes{user="user2"} 1000
 						cortex_alertmanager_alerts_limiter_current_alerts_size_bytes{user="user3"} 10000
+						# HELP cortex_alertmanager_alerts_evicted_total Total number of resolved alerts evicted from the in-memory alert store to make room for new alerts.
+						# TYPE cortex_alertmanager_alerts_evicted_total counter
+						cortex_alertmanager_alerts_evicted_total{user="user1"} 3
+						cortex_alertmanager_alerts_evicted_total{user="user2"} 30
+						cortex_alertmanager_alerts_evicted_total{user="user3"} 300
+						# HELP cortex_alertmanager_silences_insert_limited_total Total number of silences that were not created because the silences limit of the tenant was reached.
+						# TYPE cortex_alertmanager_silences_insert_limited_total counter
+						cortex_alertmanager_silences_insert_limited_total{user="user1"} 4
+						cortex_alertmanager_silences_insert_limited_total{user="user2"} 40
+						cortex_alertmanager_silences_insert_limited_total{user="user3"} 400
+						# HELP cortex_alertmanager_silences_purged_total Total number of expired silences purged by the silences retention policies of the tenant.
+						# TYPE cortex_alertmanager_silences_purged_total counter
+						cortex_alertmanager_silences_purged_total{user="user1"} 5
+						cortex_alertmanager_silences_purged_total{user="user2"} 50
+						cortex_alertmanager_silences_purged_total{user="user3"} 500
+						# HELP cortex_alertmanager_notification_log_size_bytes Size of the notification log, as of its last compaction.
+						# TYPE cortex_alertmanager_notification_log_size_bytes gauge
+						cortex_alertmanager_notification_log_size_bytes{user="user1"} 200
+						cortex_alertmanager_notification_log_size_bytes{user="user2"} 2000
+						cortex_alertmanager_notification_log_size_bytes{user="user3"} 20000
+						# HELP cortex_alertmanager_notification_log_evicted_total Total number of entries evicted from the notification log because it exceeded its size limit.
+						# TYPE cortex_alertmanager_notification_log_evicted_total counter
+						cortex_alertmanager_notification_log_evicted_total{user="user1"} 6
+						cortex_alertmanager_notification_log_evicted_total{user="user2"} 60
+						cortex_alertmanager_notification_log_evicted_total{user="user3"} 600
 						# HELP cortex_alertmanager_alerts_insert_limited_total Total number of failures to store alert due to hitting alertmanager limits.
 						# TYPE cortex_alertmanager_alerts_insert_limited_total counter
 						cortex_alertmanager_alerts_insert_limited_total{user="user1"} 7
@@ -883,6 +969,24 @@ func TestAlertmanagerMetricsRemoval(t *testing.T) {
 			# HELP cortex_alertmanager_state_persist_total Number of times we have tried to persist the running state to storage.
 			# TYPE cortex_alertmanager_state_persist_total counter
 			cortex_alertmanager_state_persist_total 0
+			# HELP cortex_alertmanager_state_snapshot_failed_total Number of times we have failed to snapshot the running state to storage.
+			# TYPE cortex_alertmanager_state_snapshot_failed_total counter
+			cortex_alertmanager_state_snapshot_failed_total 0
+			# HELP cortex_alertmanager_state_snapshot_total Number of times we have tried to snapshot the running state to storage.
+			# TYPE cortex_alertmanager_state_snapshot_total counter
+			cortex_alertmanager_state_snapshot_total 0
+			# HELP cortex_alertmanager_state_fallback_replication_failed_total Number of times we have failed to merge the state persisted in storage with the running state.
+			# TYPE cortex_alertmanager_state_fallback_replication_failed_total counter
+			cortex_alertmanager_state_fallback_replication_failed_total 0
+			# HELP cortex_alertmanager_state_fallback_replication_total Number of times we have tried to merge the state persisted in storage with the running state.
+			# TYPE cortex_alertmanager_state_fallback_replication_total counter
+			cortex_alertmanager_state_fallback_replication_total 0
+			# HELP cortex_alertmanager_alert_history_flush_failed_total Number of times we have failed to write the alert history to storage.
+			# TYPE cortex_alertmanager_alert_history_flush_failed_total counter
+			cortex_alertmanager_alert_history_flush_failed_total 0
+			# HELP cortex_alertmanager_alert_history_flush_total Number of times we have tried to write the alert history to storage.
+			# TYPE cortex_alertmanager_alert_history_flush_total counter
+			cortex_alertmanager_alert_history_flush_total 0
 
 			# HELP cortex_alertmanager_alerts_limiter_current_alerts Number of alerts tracked by alerts limiter.
 			# TYPE cortex_alertmanager_alerts_limiter_current_alerts gauge
@@ -892,6 +996,26 @@ func TestAlertmanagerMetricsRemoval(t *testing.T) {
 			# TYPE cortex_alertmanager_alerts_limiter_current_alerts_size_bytes gauge
 			cortex_alertmanager_alerts_limiter_current_alerts_size_bytes{user="user1"} 100
 			cortex_alertmanager_alerts_limiter_current_alerts_size_bytes{user="user2"} 1000
+			# HELP cortex_alertmanager_alerts_evicted_total Total number of resolved alerts evicted from the in-memory alert store to make room for new alerts.
+			# TYPE cortex_alertmanager_alerts_evicted_total counter
+			cortex_alertmanager_alerts_evicted_total{user="user1"} 3
+			cortex_alertmanager_alerts_evicted_total{user="user2"} 30
+			# HELP cortex_alertmanager_silences_insert_limited_total Total number of silences that were not created because the silences limit of the tenant was reached.
+			# TYPE cortex_alertmanager_silences_insert_limited_total counter
+			cortex_alertmanager_silences_insert_limited_total{user="user1"} 4
+			cortex_alertmanager_silences_insert_limited_total{user="user2"} 40
+			# HELP cortex_alertmanager_silences_purged_total Total number of expired silences purged by the silences retention policies of the tenant.
+			# TYPE cortex_alertmanager_silences_purged_total counter
+			cortex_alertmanager_silences_purged_total{user="user1"} 5
+			cortex_alertmanager_silences_purged_total{user="user2"} 50
+			# HELP cortex_alertmanager_notification_log_size_bytes Size of the notification log, as of its last compaction.
+			# TYPE cortex_alertmanager_notification_log_size_bytes gauge
+			cortex_alertmanager_notification_log_size_bytes{user="user1"} 200
+			cortex_alertmanager_notification_log_size_bytes{user="user2"} 2000
+			# HELP cortex_alertmanager_notification_log_evicted_total Total number of entries evicted from the notification log because it exceeded its size limit.
+			# TYPE cortex_alertmanager_notification_log_evicted_total counter
+			cortex_alertmanager_notification_log_evicted_total{user="user1"} 6
+			cortex_alertmanager_notification_log_evicted_total{user="user2"} 60
 			# HELP cortex_alertmanager_alerts_insert_limited_total Total number of failures to store alert due to hitting alertmanager limits.
 			# TYPE cortex_alertmanager_alerts_insert_limited_total counter
 			cortex_alertmanager_alerts_insert_limited_total{user="user1"} 7
@@ -950,6 +1074,15 @@ func populateAlertmanager(base float64) *prometheus.Registry {
 	lm.count.Set(10 * base)
 	lm.size.Set(100 * base)
 	lm.insertFailures.Add(7 * base)
+	lm.evicted.Add(3 * base)
+
+	slm := newSilencesLimiter(reg)
+	slm.insertLimited.Add(4 * base)
+	slm.purged.Add(5 * base)
+
+	nc := newNflogCompactor(reg)
+	nc.size.Set(200 * base)
+	nc.evicted.Add(6 * base)
 
 	sr := newStateReplicationMetrics(reg)
 	sr.partialStateMergesFailed.WithLabelValues("nfl").Add(base * 2)
@@ -1163,6 +1296,7 @@ type limiterMetrics struct {
 	count          prometheus.Gauge
 	size           prometheus.Gauge
 	insertFailures prometheus.Counter
+	evicted        prometheus.Counter
 }
 
 func newLimiterMetrics(r prometheus.Registerer) *limiterMetrics {
@@ -1181,10 +1315,16 @@ func newLimiterMetrics(r prometheus.Registerer) *limiterMetrics {
 		Help: "Number of failures to insert new alerts to in-memory alert store.",
 	})
 
+	evicted := promauto.With(r).NewCounter(prometheus.CounterOpts{
+		Name: "alertmanager_alerts_evicted_total",
+		Help: "Number of resolved alerts evicted from the in-memory alert store to make room for new alerts.",
+	})
+
 	return &limiterMetrics{
 		count:          count,
 		size:           size,
 		insertFailures: insertAlertFailures,
+		evicted:        evicted,
 	}
 }
 
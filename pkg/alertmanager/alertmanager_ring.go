@@ -45,6 +45,7 @@ var SyncRingOp = ring.NewOp([]ring.InstanceState{ring.ACTIVE, ring.JOINING}, fun
 type RingConfig struct {
 	KVStore              kv.Config     `yaml:"kvstore" doc:"description=The key-value store used to share the hash ring across multiple instances."`
 	HeartbeatPeriod      time.Duration `yaml:"heartbeat_period"`
+	CASBatchPeriod       time.Duration `yaml:"cas_batch_period"`
 	HeartbeatTimeout     time.Duration `yaml:"heartbeat_timeout"`
 	ReplicationFactor    int           `yaml:"replication_factor"`
 	ZoneAwarenessEnabled bool          `yaml:"zone_awareness_enabled"`
@@ -59,6 +60,9 @@ type RingConfig struct {
 	InstanceAddr           string   `yaml:"instance_addr" doc:"hidden"`
 	InstanceZone           string   `yaml:"instance_availability_zone"`
 
+	TokensGeneratorStrategy string `yaml:"tokens_generator_strategy"`
+	TokensStaticFile        string `yaml:"tokens_static_file"`
+
 	// Injected internally
 	ListenPort      int           `yaml:"-"`
 	RingCheckPeriod time.Duration `yaml:"-"`
@@ -81,6 +85,7 @@ func (cfg *RingConfig) RegisterFlags(f *flag.FlagSet) {
 	// Ring flags
 	cfg.KVStore.RegisterFlagsWithPrefix(rfprefix, "alertmanagers/", f)
 	f.DurationVar(&cfg.HeartbeatPeriod, rfprefix+"heartbeat-period", 15*time.Second, "Period at which to heartbeat to the ring. 0 = disabled.")
+	f.DurationVar(&cfg.CASBatchPeriod, rfprefix+"cas-batch-period", 0, "EXPERIMENTAL: Period within which the ring updates of the alertmanager, including the heartbeats, are batched with the ring updates of the other components of the same process into a single KV store transaction. When enabled, the heartbeats are aligned to the heartbeat period, so that the heartbeats of the components with the same heartbeat period, or a multiple of it, are batched together. Supported by the consul, etcd and memberlist KV stores. 0 = disabled.")
 	f.DurationVar(&cfg.HeartbeatTimeout, rfprefix+"heartbeat-timeout", time.Minute, "The heartbeat timeout after which alertmanagers are considered unhealthy within the ring. 0 = never (timeout disabled).")
 	f.DurationVar(&cfg.FinalSleep, rfprefix+"final-sleep", 0*time.Second, "The sleep seconds when alertmanager is shutting down. Need to be close to or larger than KV Store information propagation delay")
 	f.IntVar(&cfg.ReplicationFactor, rfprefix+"replication-factor", 3, "The replication factor to use when sharding the alertmanager.")
@@ -93,6 +98,8 @@ func (cfg *RingConfig) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.InstancePort, rfprefix+"instance-port", 0, "Port to advertise in the ring (defaults to server.grpc-listen-port).")
 	f.StringVar(&cfg.InstanceID, rfprefix+"instance-id", hostname, "Instance ID to register in the ring.")
 	f.StringVar(&cfg.InstanceZone, rfprefix+"instance-availability-zone", "", "The availability zone where this instance is running. Required if zone-awareness is enabled.")
+	f.StringVar(&cfg.TokensGeneratorStrategy, rfprefix+"tokens-generator-strategy", "random", ring.TokensGeneratorStrategyFlagDescription)
+	f.StringVar(&cfg.TokensStaticFile, rfprefix+"tokens-static-file", "", "EXPERIMENTAL: Path of the YAML file mapping the instance IDs to their tokens, used by the static token generator strategy.")
 
 	cfg.RingCheckPeriod = 5 * time.Second
 
@@ -111,13 +118,16 @@ func (cfg *RingConfig) ToLifecyclerConfig(logger log.Logger) (ring.BasicLifecycl
 	instancePort := ring.GetInstancePort(cfg.InstancePort, cfg.ListenPort)
 
 	return ring.BasicLifecyclerConfig{
-		ID:                  cfg.InstanceID,
-		Addr:                fmt.Sprintf("%s:%d", instanceAddr, instancePort),
-		HeartbeatPeriod:     cfg.HeartbeatPeriod,
-		TokensObservePeriod: 0,
-		Zone:                cfg.InstanceZone,
-		NumTokens:           RingNumTokens,
-		FinalSleep:          cfg.FinalSleep,
+		ID:                      cfg.InstanceID,
+		Addr:                    fmt.Sprintf("%s:%d", instanceAddr, instancePort),
+		HeartbeatPeriod:         cfg.HeartbeatPeriod,
+		AlignHeartbeats:         cfg.CASBatchPeriod > 0,
+		TokensObservePeriod:     0,
+		Zone:                    cfg.InstanceZone,
+		NumTokens:               RingNumTokens,
+		TokensGeneratorStrategy: cfg.TokensGeneratorStrategy,
+		TokensStaticFile:        cfg.TokensStaticFile,
+		FinalSleep:              cfg.FinalSleep,
 	}, nil
 }
 
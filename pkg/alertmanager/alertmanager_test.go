@@ -62,7 +62,7 @@ route:
 
 	cfg, err := config.Load(cfgRaw)
 	require.NoError(t, err)
-	require.NoError(t, am.ApplyConfig(user, cfg, cfgRaw))
+	require.NoError(t, am.ApplyConfig(user, cfg, nil, cfgRaw))
 
 	now := time.Now()
 
@@ -0,0 +1,201 @@
+package alertmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/log/level"
+	open_api_models "github.com/prometheus/alertmanager/api/v2/models"
+	amconfig "github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/common/model"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+)
+
+const defaultAlertsMirroringTenantLabel = "tenant"
+
+// AlertsMirroringConfig mirrors the alerts of the tenants selected by its rules to an operations tenant, so that they
+// are routed and notified by the Alertmanager config of the operations tenant as well. The mirrored alerts are copies
+// labelled with their tenant: the operations tenant can silence or inhibit them without affecting the alerts of the
+// tenants, and the alerts of the operations tenant are never mirrored.
+type AlertsMirroringConfig struct {
+	Tenant string `yaml:"tenant"`
+	// TenantLabel is the label set to the tenant of the mirrored alerts, overriding the label of the alerts if any.
+	TenantLabel string                `yaml:"tenant_label,omitempty"`
+	Rules       []AlertsMirroringRule `yaml:"rules"`
+}
+
+// AlertsMirroringRule selects the alerts matching its matchers, or all the alerts without matchers, of its tenants,
+// or of all the tenants without tenants.
+type AlertsMirroringRule struct {
+	Tenants  []string          `yaml:"tenants,omitempty"`
+	Matchers amconfig.Matchers `yaml:"matchers,omitempty"`
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (c *AlertsMirroringConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = AlertsMirroringConfig{TenantLabel: defaultAlertsMirroringTenantLabel}
+	type plain AlertsMirroringConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if c.Tenant == "" {
+		return errors.New("missing tenant in alerts mirroring config")
+	}
+	if err := tenant.ValidTenantID(c.Tenant); err != nil {
+		return fmt.Errorf("invalid tenant in alerts mirroring config: %w", err)
+	}
+	if !model.LabelName(c.TenantLabel).IsValid() {
+		return fmt.Errorf("invalid tenant label %q in alerts mirroring config", c.TenantLabel)
+	}
+	if len(c.Rules) == 0 {
+		return errors.New("missing rules in alerts mirroring config")
+	}
+	return nil
+}
+
+// mirrors returns whether the alert of the tenant, with the given labels, is mirrored.
+func (c *AlertsMirroringConfig) mirrors(userID string, ls model.LabelSet) bool {
+	if userID == c.Tenant {
+		return false
+	}
+
+	for _, r := range c.Rules {
+		if len(r.Tenants) > 0 && !util.StringsContain(r.Tenants, userID) {
+			continue
+		}
+		if labels.Matchers(r.Matchers).Matches(ls) {
+			return true
+		}
+	}
+	return false
+}
+
+// alertsMirroring returns the alerts mirroring config, nil if the alerts aren't mirrored.
+func (am *MultitenantAlertmanager) alertsMirroring() *AlertsMirroringConfig {
+	if am.operatorConfig == nil {
+		return nil
+	}
+	return am.operatorConfig.AlertsMirroring
+}
+
+// isMirroredAlertsRequest returns whether the alerts of the request may be mirrored to the operations tenant.
+func (am *MultitenantAlertmanager) isMirroredAlertsRequest(req *http.Request) bool {
+	mirroring := am.alertsMirroring()
+	if mirroring == nil || req.Method != http.MethodPost || !strings.HasSuffix(req.URL.Path, "/v2/alerts") {
+		return false
+	}
+
+	userID, err := tenant.TenantID(req.Context())
+	return err == nil && userID != mirroring.Tenant
+}
+
+// serveMirroredAlerts serves the request posting the alerts of a tenant and, if they're accepted, mirrors the alerts
+// selected by the alerts mirroring config to the operations tenant.
+func (am *MultitenantAlertmanager) serveMirroredAlerts(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	am.dispatchRequest(rec, req)
+	if rec.status/100 != 2 {
+		return
+	}
+
+	// The tenant was validated by isMirroredAlertsRequest.
+	userID, _ := tenant.TenantID(req.Context())
+	am.mirrorAlerts(req, userID, body)
+}
+
+// mirrorAlerts posts the alerts of the tenant selected by the alerts mirroring config to the operations tenant.
+// The failures are logged, they don't fail the request of the tenant.
+func (am *MultitenantAlertmanager) mirrorAlerts(req *http.Request, userID string, body []byte) {
+	mirroring := am.alertsMirroring()
+	logger := util_log.WithContext(req.Context(), am.logger)
+
+	var alerts open_api_models.PostableAlerts
+	if err := json.Unmarshal(body, &alerts); err != nil {
+		return
+	}
+
+	mirrored := make(open_api_models.PostableAlerts, 0, len(alerts))
+	for _, a := range alerts {
+		if a == nil {
+			continue
+		}
+
+		ls := make(model.LabelSet, len(a.Labels)+1)
+		for name, value := range a.Labels {
+			ls[model.LabelName(name)] = model.LabelValue(value)
+		}
+		if !mirroring.mirrors(userID, ls) {
+			continue
+		}
+
+		m := *a
+		m.Labels = make(open_api_models.LabelSet, len(a.Labels)+1)
+		for name, value := range a.Labels {
+			m.Labels[name] = value
+		}
+		m.Labels[mirroring.TenantLabel] = userID
+		mirrored = append(mirrored, &m)
+	}
+	if len(mirrored) == 0 {
+		return
+	}
+
+	mirroredBody, err := json.Marshal(mirrored)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to encode the mirrored alerts", "user", userID, "err", err)
+		am.multitenantMetrics.alertsMirroringFailed.WithLabelValues(userID).Add(float64(len(mirrored)))
+		return
+	}
+
+	mirrorReq := req.Clone(user.InjectOrgID(req.Context(), mirroring.Tenant))
+	mirrorReq.Header.Set(user.OrgIDHeaderName, mirroring.Tenant)
+	mirrorReq.Body = io.NopCloser(bytes.NewReader(mirroredBody))
+	mirrorReq.ContentLength = int64(len(mirroredBody))
+
+	rec := &statusRecorder{ResponseWriter: discardResponseWriter{header: http.Header{}}, status: http.StatusOK}
+	am.dispatchRequest(rec, mirrorReq)
+	if rec.status/100 != 2 {
+		level.Warn(logger).Log("msg", "failed to mirror the alerts to the operations tenant", "user", userID, "tenant", mirroring.Tenant, "status", rec.status)
+		am.multitenantMetrics.alertsMirroringFailed.WithLabelValues(userID).Add(float64(len(mirrored)))
+		return
+	}
+	am.multitenantMetrics.alertsMirrored.WithLabelValues(userID).Add(float64(len(mirrored)))
+}
+
+// statusRecorder records the status code of the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// discardResponseWriter discards the response.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w discardResponseWriter) Header() http.Header         { return w.header }
+func (w discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w discardResponseWriter) WriteHeader(int)             {}
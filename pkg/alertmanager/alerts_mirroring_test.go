@@ -0,0 +1,101 @@
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+func TestMultitenantAlertmanager_AlertsMirroring(t *testing.T) {
+	ctx := context.Background()
+
+	config := `route:
+  receiver: 'default'
+
+receivers:
+- name: 'default'
+`
+
+	store := prepareInMemoryAlertStore()
+	for _, userID := range []string{"tenant-a", "tenant-b", "operations"} {
+		require.NoError(t, store.SetAlertConfig(ctx, alertspb.AlertConfigDesc{
+			User:      userID,
+			RawConfig: config,
+			Templates: []*alertspb.TemplateDesc{},
+		}))
+	}
+
+	cfg := mockAlertmanagerConfig(t)
+	cfg.OperatorConfigFile = filepath.Join(t.TempDir(), "operator.yaml")
+	require.NoError(t, os.WriteFile(cfg.OperatorConfigFile, []byte(`
+alerts_mirroring:
+  tenant: operations
+  rules:
+  - tenants: ['tenant-a']
+    matchers: ['severity="critical"']
+`), 0644))
+
+	reg := prometheus.NewPedanticRegistry()
+	am, err := createMultitenantAlertmanager(cfg, nil, nil, store, nil, &mockAlertManagerLimits{}, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(ctx, am))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(ctx, am))
+	})
+
+	postAlerts := func(t *testing.T, userID, body string) {
+		req := httptest.NewRequest(http.MethodPost, cfg.ExternalURL.String()+"/api/v2/alerts", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		am.ServeHTTP(w, req.WithContext(user.InjectOrgID(req.Context(), userID)))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+	alerts := func(t *testing.T, userID string) []model.LabelSet {
+		am.alertmanagersMtx.Lock()
+		uam := am.alertmanagers[userID]
+		am.alertmanagersMtx.Unlock()
+		require.NotNil(t, uam)
+
+		var ls []model.LabelSet
+		for _, a := range uam.alerts.alerts.List() {
+			ls = append(ls, a.Labels)
+		}
+		sort.Slice(ls, func(i, j int) bool { return ls[i].String() < ls[j].String() })
+		return ls
+	}
+
+	// Only the critical alerts of tenant-a are mirrored, labelled with their tenant.
+	postAlerts(t, "tenant-a", `[{"labels":{"alertname":"HighErrorRate","severity":"critical","tenant":"spoofed"}},{"labels":{"alertname":"HighLatency","severity":"warning"}}]`)
+	postAlerts(t, "tenant-b", `[{"labels":{"alertname":"HighErrorRate","severity":"critical"}}]`)
+	postAlerts(t, "operations", `[{"labels":{"alertname":"PlatformDown","severity":"critical"}}]`)
+
+	assert.Len(t, alerts(t, "tenant-a"), 2)
+	assert.Len(t, alerts(t, "tenant-b"), 1)
+	assert.Equal(t, []model.LabelSet{
+		{"alertname": "HighErrorRate", "severity": "critical", "tenant": "tenant-a"},
+		{"alertname": "PlatformDown", "severity": "critical"},
+	}, alerts(t, "operations"))
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, bytes.NewBufferString(`
+		# HELP cortex_alertmanager_alerts_mirrored_total Total number of alerts of the user mirrored to the operations tenant.
+		# TYPE cortex_alertmanager_alerts_mirrored_total counter
+		cortex_alertmanager_alerts_mirrored_total{user="tenant-a"} 1
+	`), "cortex_alertmanager_alerts_mirrored_total", "cortex_alertmanager_alerts_mirroring_failed_total"))
+}
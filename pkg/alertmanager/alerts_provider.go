@@ -0,0 +1,290 @@
+package alertmanager
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/alertmanager/provider"
+	"github.com/prometheus/alertmanager/provider/mem"
+	"github.com/prometheus/alertmanager/store"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+)
+
+const alertChannelLength = 200
+
+// alertsProvider is an in-memory alerts provider which, when enabled for the tenant, evicts its resolved alerts,
+// oldest first, to make room for the new alerts when the alerts limits of the tenant are reached. Without the
+// eviction, the new alerts are rejected until the resolved alerts are garbage collected.
+// Taken from https://github.com/prometheus/alertmanager/blob/v0.27.0/provider/mem/mem.go, adding the eviction.
+type alertsProvider struct {
+	cancel context.CancelFunc
+
+	alerts *store.Alerts
+	marker types.Marker
+
+	mtx       sync.Mutex
+	listeners map[int]listeningAlerts
+	next      int
+
+	callback mem.AlertStoreCallback
+
+	tenant         string
+	limits         Limits
+	limiter        *alertsLimiter
+	evictedCounter prometheus.Counter
+
+	logger log.Logger
+}
+
+type listeningAlerts struct {
+	alerts chan *types.Alert
+	done   chan struct{}
+}
+
+// newAlertsProvider returns a new alerts provider. The resolved alerts are only evicted if the limits and
+// the limiter are not nil.
+func newAlertsProvider(ctx context.Context, m types.Marker, intervalGC time.Duration, callback mem.AlertStoreCallback, tenant string, limits Limits, limiter *alertsLimiter, l log.Logger, r prometheus.Registerer) *alertsProvider {
+	if callback == nil {
+		callback = alertStoreCallbacks{}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	a := &alertsProvider{
+		marker:    m,
+		alerts:    store.NewAlerts(),
+		cancel:    cancel,
+		listeners: map[int]listeningAlerts{},
+		next:      0,
+		callback:  callback,
+		tenant:    tenant,
+		limits:    limits,
+		limiter:   limiter,
+		logger:    log.With(l, "component", "provider"),
+		evictedCounter: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "alertmanager_alerts_evicted_total",
+			Help: "Number of resolved alerts evicted from the in-memory alert store to make room for new alerts.",
+		}),
+	}
+	a.alerts.SetGCCallback(func(alerts []*types.Alert) {
+		for _, alert := range alerts {
+			// As we don't persist alerts, we no longer consider them after
+			// they are resolved. Alerts waiting for resolved notifications are
+			// held in memory in aggregation groups redundantly.
+			m.Delete(alert.Fingerprint())
+			a.callback.PostDelete(alert)
+		}
+
+		a.mtx.Lock()
+		for i, l := range a.listeners {
+			select {
+			case <-l.done:
+				delete(a.listeners, i)
+				close(l.alerts)
+			default:
+				// listener is not closed yet, hence proceed.
+			}
+		}
+		a.mtx.Unlock()
+	})
+
+	if r != nil {
+		a.registerMetrics(r)
+	}
+
+	go a.alerts.Run(ctx, intervalGC)
+
+	return a
+}
+
+func (a *alertsProvider) registerMetrics(r prometheus.Registerer) {
+	newMemAlertByStatus := func(s types.AlertState) prometheus.GaugeFunc {
+		return prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name:        "alertmanager_alerts",
+				Help:        "How many alerts by state.",
+				ConstLabels: prometheus.Labels{"state": string(s)},
+			},
+			func() float64 {
+				return float64(a.count(s))
+			},
+		)
+	}
+
+	r.MustRegister(newMemAlertByStatus(types.AlertStateActive))
+	r.MustRegister(newMemAlertByStatus(types.AlertStateSuppressed))
+	r.MustRegister(newMemAlertByStatus(types.AlertStateUnprocessed))
+}
+
+// Close the alert provider.
+func (a *alertsProvider) Close() {
+	if a.cancel != nil {
+		a.cancel()
+	}
+}
+
+// Subscribe returns an iterator over active alerts that have not been
+// resolved and successfully notified about.
+// They are not guaranteed to be in chronological order.
+func (a *alertsProvider) Subscribe() provider.AlertIterator {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	var (
+		done   = make(chan struct{})
+		alerts = a.alerts.List()
+		ch     = make(chan *types.Alert, max(len(alerts), alertChannelLength))
+	)
+
+	for _, a := range alerts {
+		ch <- a
+	}
+
+	a.listeners[a.next] = listeningAlerts{alerts: ch, done: done}
+	a.next++
+
+	return provider.NewAlertIterator(ch, done, nil)
+}
+
+// GetPending returns an iterator over all the alerts that have
+// pending notifications.
+func (a *alertsProvider) GetPending() provider.AlertIterator {
+	var (
+		ch   = make(chan *types.Alert, alertChannelLength)
+		done = make(chan struct{})
+	)
+
+	go func() {
+		defer close(ch)
+
+		for _, a := range a.alerts.List() {
+			select {
+			case ch <- a:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return provider.NewAlertIterator(ch, done, nil)
+}
+
+// Get returns the alert for a given fingerprint.
+func (a *alertsProvider) Get(fp model.Fingerprint) (*types.Alert, error) {
+	return a.alerts.Get(fp)
+}
+
+// Put adds the given alert to the set.
+func (a *alertsProvider) Put(alerts ...*types.Alert) error {
+	for _, alert := range alerts {
+		fp := alert.Fingerprint()
+
+		existing := false
+
+		// Check that there's an alert existing within the store before
+		// trying to merge.
+		if old, err := a.alerts.Get(fp); err == nil {
+			existing = true
+
+			// Merge alerts if there is an overlap in activity range.
+			if (alert.EndsAt.After(old.StartsAt) && alert.EndsAt.Before(old.EndsAt)) ||
+				(alert.StartsAt.After(old.StartsAt) && alert.StartsAt.Before(old.EndsAt)) {
+				alert = old.Merge(alert)
+			}
+		}
+
+		if a.evictionEnabled() {
+			a.evictResolvedAlerts(alert, existing)
+		}
+
+		if err := a.callback.PreStore(alert, existing); err != nil {
+			level.Error(a.logger).Log("msg", "pre-store callback returned error on set alert", "err", err)
+			continue
+		}
+
+		if err := a.alerts.Set(alert); err != nil {
+			level.Error(a.logger).Log("msg", "error on set alert", "err", err)
+			continue
+		}
+
+		a.callback.PostStore(alert, existing)
+
+		a.mtx.Lock()
+		for _, l := range a.listeners {
+			select {
+			case l.alerts <- alert:
+			case <-l.done:
+			}
+		}
+		a.mtx.Unlock()
+	}
+
+	return nil
+}
+
+func (a *alertsProvider) evictionEnabled() bool {
+	return a.limits != nil && a.limiter != nil && a.limits.AlertmanagerEvictResolvedAlerts(a.tenant)
+}
+
+// evictResolvedAlerts evicts the resolved alerts, oldest first, until the alert fits in the alerts limits or there's
+// no resolved alert left. The resolved alerts waiting for their notification are still held in their aggregation group.
+func (a *alertsProvider) evictResolvedAlerts(alert *types.Alert, existing bool) {
+	if a.limiter.checkLimits(alert, existing) == nil {
+		return
+	}
+
+	var resolved []*types.Alert
+	for _, r := range a.alerts.List() {
+		if r.Resolved() && r.Fingerprint() != alert.Fingerprint() {
+			resolved = append(resolved, r)
+		}
+	}
+	sort.Slice(resolved, func(i, j int) bool {
+		return resolved[i].EndsAt.Before(resolved[j].EndsAt)
+	})
+
+	evicted := 0
+	for _, r := range resolved {
+		if a.limiter.checkLimits(alert, existing) == nil {
+			break
+		}
+
+		fp := r.Fingerprint()
+		if err := a.alerts.Delete(fp); err != nil {
+			continue
+		}
+		a.marker.Delete(fp)
+		a.callback.PostDelete(r)
+		evicted++
+	}
+
+	if evicted > 0 {
+		a.evictedCounter.Add(float64(evicted))
+		level.Debug(a.logger).Log("msg", "evicted resolved alerts to make room for a new alert", "evicted", evicted, "alert", alert.Name())
+	}
+}
+
+// count returns the number of non-resolved alerts we currently have stored filtered by the provided state.
+func (a *alertsProvider) count(state types.AlertState) int {
+	var count int
+	for _, alert := range a.alerts.List() {
+		if alert.Resolved() {
+			continue
+		}
+
+		status := a.marker.Status(alert.Fingerprint())
+		if status.State != state {
+			continue
+		}
+
+		count++
+	}
+
+	return count
+}
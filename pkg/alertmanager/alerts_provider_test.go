@@ -0,0 +1,79 @@
+package alertmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertsProvider_EvictResolvedAlerts(t *testing.T) {
+	now := time.Now()
+	newAlert := func(name string, endsAt time.Time) *types.Alert {
+		return &types.Alert{Alert: model.Alert{
+			Labels:   model.LabelSet{model.AlertNameLabel: model.LabelValue(name)},
+			StartsAt: now.Add(-time.Hour),
+			EndsAt:   endsAt,
+		}, UpdatedAt: now}
+	}
+
+	for name, tc := range map[string]struct {
+		evict            bool
+		expectedEvicted  []string
+		expectedRejected []string
+	}{
+		"eviction disabled": {
+			evict:            false,
+			expectedRejected: []string{"new-1", "new-2", "new-3"},
+		},
+		"eviction enabled": {
+			evict:            true,
+			expectedEvicted:  []string{"resolved-old", "resolved-recent"},
+			expectedRejected: []string{"new-3"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			limits := &mockAlertManagerLimits{maxAlertsCount: 3, evictResolvedAlerts: tc.evict}
+			reg := prometheus.NewPedanticRegistry()
+			limiter := newAlertsLimiter("user-1", limits, reg)
+			alerts := newAlertsProvider(context.Background(), types.NewMarker(reg), time.Hour, alertStoreCallbacks{limiter}, "user-1", limits, limiter, log.NewNopLogger(), reg)
+			defer alerts.Close()
+
+			require.NoError(t, alerts.Put(
+				newAlert("resolved-recent", now.Add(-time.Minute)),
+				newAlert("resolved-old", now.Add(-10*time.Minute)),
+				newAlert("firing", now.Add(time.Hour)),
+			))
+
+			// The oldest resolved alerts are evicted first, but the firing alerts are never evicted.
+			require.NoError(t, alerts.Put(newAlert("new-1", now.Add(time.Hour)), newAlert("new-2", now.Add(time.Hour)), newAlert("new-3", now.Add(time.Hour))))
+
+			var evicted, rejected []string
+			for _, a := range []*types.Alert{newAlert("resolved-old", now), newAlert("resolved-recent", now)} {
+				if _, err := alerts.Get(a.Fingerprint()); err != nil {
+					evicted = append(evicted, a.Name())
+				}
+			}
+			for _, a := range []*types.Alert{newAlert("new-1", now), newAlert("new-2", now), newAlert("new-3", now)} {
+				if _, err := alerts.Get(a.Fingerprint()); err != nil {
+					rejected = append(rejected, a.Name())
+				}
+			}
+
+			assert.Equal(t, tc.expectedEvicted, evicted)
+			assert.Equal(t, tc.expectedRejected, rejected)
+			assert.Equal(t, float64(len(tc.expectedEvicted)), testutil.ToFloat64(alerts.evictedCounter))
+			assert.Equal(t, float64(len(tc.expectedRejected)), testutil.ToFloat64(limiter.failureCounter))
+
+			count, _ := limiter.currentStats()
+			assert.Equal(t, 3, count)
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	fmt "fmt"
 	_ "github.com/gogo/protobuf/gogoproto"
 	proto "github.com/gogo/protobuf/proto"
+	github_com_gogo_protobuf_sortkeys "github.com/gogo/protobuf/sortkeys"
 	clusterpb "github.com/prometheus/alertmanager/cluster/clusterpb"
 	io "io"
 	math "math"
@@ -136,6 +137,58 @@ func (m *TemplateDesc) GetBody() string {
 	return ""
 }
 
+// TemplatesDesc are the template files of an user, stored apart from the alertmanager configuration.
+type TemplatesDesc struct {
+	User      string          `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
+	Templates []*TemplateDesc `protobuf:"bytes,2,rep,name=templates,proto3" json:"templates,omitempty"`
+}
+
+func (m *TemplatesDesc) Reset()      { *m = TemplatesDesc{} }
+func (*TemplatesDesc) ProtoMessage() {}
+func (*TemplatesDesc) Descriptor() ([]byte, []int) {
+	return fileDescriptor_20493709c38b81dc, []int{2}
+}
+func (m *TemplatesDesc) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TemplatesDesc) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TemplatesDesc.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *TemplatesDesc) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TemplatesDesc.Merge(m, src)
+}
+func (m *TemplatesDesc) XXX_Size() int {
+	return m.Size()
+}
+func (m *TemplatesDesc) XXX_DiscardUnknown() {
+	xxx_messageInfo_TemplatesDesc.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TemplatesDesc proto.InternalMessageInfo
+
+func (m *TemplatesDesc) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *TemplatesDesc) GetTemplates() []*TemplateDesc {
+	if m != nil {
+		return m.Templates
+	}
+	return nil
+}
+
 type FullStateDesc struct {
 	State *clusterpb.FullState `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
 }
@@ -143,7 +196,7 @@ type FullStateDesc struct {
 func (m *FullStateDesc) Reset()      { *m = FullStateDesc{} }
 func (*FullStateDesc) ProtoMessage() {}
 func (*FullStateDesc) Descriptor() ([]byte, []int) {
-	return fileDescriptor_20493709c38b81dc, []int{2}
+	return fileDescriptor_20493709c38b81dc, []int{3}
 }
 func (m *FullStateDesc) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -179,36 +232,209 @@ func (m *FullStateDesc) GetState() *clusterpb.FullState {
 	return nil
 }
 
+// AlertHistoryDesc is a batch of entries of the alert history of an user.
+type AlertHistoryDesc struct {
+	User    string              `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
+	Entries []AlertHistoryEntry `protobuf:"bytes,2,rep,name=entries,proto3" json:"entries"`
+}
+
+func (m *AlertHistoryDesc) Reset()      { *m = AlertHistoryDesc{} }
+func (*AlertHistoryDesc) ProtoMessage() {}
+func (*AlertHistoryDesc) Descriptor() ([]byte, []int) {
+	return fileDescriptor_20493709c38b81dc, []int{4}
+}
+func (m *AlertHistoryDesc) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *AlertHistoryDesc) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_AlertHistoryDesc.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *AlertHistoryDesc) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AlertHistoryDesc.Merge(m, src)
+}
+func (m *AlertHistoryDesc) XXX_Size() int {
+	return m.Size()
+}
+func (m *AlertHistoryDesc) XXX_DiscardUnknown() {
+	xxx_messageInfo_AlertHistoryDesc.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AlertHistoryDesc proto.InternalMessageInfo
+
+func (m *AlertHistoryDesc) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *AlertHistoryDesc) GetEntries() []AlertHistoryEntry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+// AlertHistoryEntry records a state transition of an alert, or the outcome of a notification of the alert.
+type AlertHistoryEntry struct {
+	TimestampMs int64             `protobuf:"varint,1,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"`
+	Fingerprint string            `protobuf:"bytes,2,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	Labels      map[string]string `protobuf:"bytes,3,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Status of the alert, firing or resolved.
+	Status string `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+	// Receiver and integration of the notification, empty for the state transitions.
+	Receiver    string `protobuf:"bytes,5,opt,name=receiver,proto3" json:"receiver,omitempty"`
+	Integration string `protobuf:"bytes,6,opt,name=integration,proto3" json:"integration,omitempty"`
+	// Outcome of the notification, success or failure.
+	Outcome string `protobuf:"bytes,7,opt,name=outcome,proto3" json:"outcome,omitempty"`
+	Error   string `protobuf:"bytes,8,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (m *AlertHistoryEntry) Reset()      { *m = AlertHistoryEntry{} }
+func (*AlertHistoryEntry) ProtoMessage() {}
+func (*AlertHistoryEntry) Descriptor() ([]byte, []int) {
+	return fileDescriptor_20493709c38b81dc, []int{5}
+}
+func (m *AlertHistoryEntry) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *AlertHistoryEntry) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_AlertHistoryEntry.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *AlertHistoryEntry) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AlertHistoryEntry.Merge(m, src)
+}
+func (m *AlertHistoryEntry) XXX_Size() int {
+	return m.Size()
+}
+func (m *AlertHistoryEntry) XXX_DiscardUnknown() {
+	xxx_messageInfo_AlertHistoryEntry.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AlertHistoryEntry proto.InternalMessageInfo
+
+func (m *AlertHistoryEntry) GetTimestampMs() int64 {
+	if m != nil {
+		return m.TimestampMs
+	}
+	return 0
+}
+
+func (m *AlertHistoryEntry) GetFingerprint() string {
+	if m != nil {
+		return m.Fingerprint
+	}
+	return ""
+}
+
+func (m *AlertHistoryEntry) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *AlertHistoryEntry) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *AlertHistoryEntry) GetReceiver() string {
+	if m != nil {
+		return m.Receiver
+	}
+	return ""
+}
+
+func (m *AlertHistoryEntry) GetIntegration() string {
+	if m != nil {
+		return m.Integration
+	}
+	return ""
+}
+
+func (m *AlertHistoryEntry) GetOutcome() string {
+	if m != nil {
+		return m.Outcome
+	}
+	return ""
+}
+
+func (m *AlertHistoryEntry) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*AlertConfigDesc)(nil), "alerts.AlertConfigDesc")
 	proto.RegisterType((*TemplateDesc)(nil), "alerts.TemplateDesc")
+	proto.RegisterType((*TemplatesDesc)(nil), "alerts.TemplatesDesc")
 	proto.RegisterType((*FullStateDesc)(nil), "alerts.FullStateDesc")
+	proto.RegisterType((*AlertHistoryDesc)(nil), "alerts.AlertHistoryDesc")
+	proto.RegisterType((*AlertHistoryEntry)(nil), "alerts.AlertHistoryEntry")
+	proto.RegisterMapType((map[string]string)(nil), "alerts.AlertHistoryEntry.LabelsEntry")
 }
 
 func init() { proto.RegisterFile("alerts.proto", fileDescriptor_20493709c38b81dc) }
 
 var fileDescriptor_20493709c38b81dc = []byte{
-	// 320 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x4c, 0x50, 0xbf, 0x4e, 0xc2, 0x40,
-	0x18, 0xbf, 0x13, 0x24, 0x70, 0x62, 0x4c, 0x1a, 0x06, 0x42, 0xe2, 0x27, 0x61, 0x22, 0x0e, 0x6d,
-	0x82, 0x9b, 0x03, 0x09, 0x68, 0x7c, 0x00, 0x74, 0x72, 0x31, 0xd7, 0x7a, 0x14, 0x92, 0x96, 0x6b,
-	0xee, 0xae, 0x41, 0x37, 0x1f, 0xc1, 0x47, 0x70, 0xf4, 0x51, 0x1c, 0x19, 0x19, 0xe5, 0x58, 0x18,
-	0x79, 0x04, 0x73, 0x77, 0xa5, 0x38, 0xf5, 0xf7, 0xf5, 0xf7, 0xe7, 0xfb, 0x7e, 0x47, 0x9a, 0x34,
-	0x61, 0x42, 0x49, 0x3f, 0x13, 0x5c, 0x71, 0xaf, 0xe6, 0xa6, 0x4e, 0x2b, 0xe6, 0x31, 0xb7, 0xbf,
-	0x02, 0x83, 0x1c, 0xdb, 0x19, 0xc7, 0x73, 0x35, 0xcb, 0x43, 0x3f, 0xe2, 0x69, 0x90, 0x09, 0x9e,
-	0x32, 0x35, 0x63, 0xb9, 0x0c, 0xac, 0x27, 0xa5, 0x0b, 0x1a, 0x33, 0x11, 0x44, 0x49, 0x2e, 0xd5,
-	0xf1, 0x9b, 0x85, 0x07, 0xe4, 0x32, 0x7a, 0x6f, 0xe4, 0x62, 0x64, 0xf4, 0x77, 0x7c, 0x31, 0x9d,
-	0xc7, 0xf7, 0x4c, 0x46, 0x9e, 0x47, 0xaa, 0xb9, 0x64, 0xa2, 0x8d, 0xbb, 0xb8, 0xdf, 0x98, 0x58,
-	0xec, 0x5d, 0x12, 0x22, 0xe8, 0xf2, 0x25, 0xb2, 0xaa, 0xf6, 0x89, 0x65, 0x1a, 0x82, 0x2e, 0x9d,
-	0xcd, 0x1b, 0x90, 0x86, 0x62, 0x69, 0x96, 0x50, 0xc5, 0x64, 0xbb, 0xd2, 0xad, 0xf4, 0xcf, 0x06,
-	0x2d, 0xbf, 0x68, 0xf2, 0x54, 0x10, 0x26, 0x7b, 0x72, 0x94, 0xf5, 0x86, 0xa4, 0xf9, 0x9f, 0xf2,
-	0x3a, 0xa4, 0x3e, 0x9d, 0x27, 0x6c, 0x41, 0x53, 0x56, 0xac, 0x2e, 0x67, 0x73, 0x52, 0xc8, 0x5f,
-	0xdf, 0x8b, 0xc5, 0x16, 0xf7, 0x46, 0xe4, 0xfc, 0x21, 0x4f, 0x92, 0x47, 0x75, 0x08, 0xb8, 0x26,
-	0xa7, 0xd2, 0x0c, 0xd6, 0x6d, 0x0e, 0x28, 0x3b, 0xfb, 0xa5, 0x70, 0xe2, 0x24, 0xb7, 0xd5, 0xdd,
-	0xd7, 0x15, 0x1a, 0x0f, 0x57, 0x1b, 0x40, 0xeb, 0x0d, 0xa0, 0xfd, 0x06, 0xf0, 0x87, 0x06, 0xfc,
-	0xad, 0x01, 0xff, 0x68, 0xc0, 0x2b, 0x0d, 0xf8, 0x57, 0x03, 0xde, 0x69, 0x40, 0x7b, 0x0d, 0xf8,
-	0x73, 0x0b, 0x68, 0xb5, 0x05, 0xb4, 0xde, 0x02, 0x7a, 0xae, 0xbb, 0x62, 0x59, 0x18, 0xd6, 0xec,
-	0x1b, 0xde, 0xfc, 0x05, 0x00, 0x00, 0xff, 0xff, 0x3d, 0xbd, 0xc1, 0x50, 0xb5, 0x01, 0x00, 0x00,
+	// 537 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x53, 0x4f, 0x6b, 0xdb, 0x4e,
+	0x10, 0x95, 0x6c, 0xc7, 0xb1, 0xc7, 0x0e, 0xbf, 0xfc, 0x16, 0x53, 0x54, 0x43, 0x37, 0xae, 0xa1,
+	0x10, 0x7a, 0xb0, 0x21, 0xbd, 0x34, 0x81, 0x06, 0xe2, 0xfe, 0xa1, 0x87, 0xf6, 0xa2, 0x16, 0x0a,
+	0xbd, 0x84, 0x95, 0x3a, 0x56, 0x44, 0x25, 0xad, 0xd8, 0x5d, 0x25, 0xf5, 0xad, 0x1f, 0xa1, 0x1f,
+	0xa1, 0xc7, 0x7e, 0x94, 0x1c, 0x7d, 0xf4, 0xa9, 0xd4, 0xf2, 0x25, 0xc7, 0x7c, 0x84, 0xb2, 0x2b,
+	0xc9, 0x71, 0x29, 0x86, 0x9e, 0x3c, 0x6f, 0xe6, 0xbd, 0xd9, 0xe7, 0x37, 0x08, 0xba, 0x2c, 0x42,
+	0xa1, 0xe4, 0x28, 0x15, 0x5c, 0x71, 0xd2, 0x2c, 0x50, 0xbf, 0x17, 0xf0, 0x80, 0x9b, 0xd6, 0x58,
+	0x57, 0xc5, 0xb4, 0x3f, 0x09, 0x42, 0x75, 0x91, 0x79, 0x23, 0x9f, 0xc7, 0xe3, 0x54, 0xf0, 0x18,
+	0xd5, 0x05, 0x66, 0x72, 0x6c, 0x34, 0x31, 0x4b, 0x58, 0x80, 0x62, 0xec, 0x47, 0x99, 0x54, 0x77,
+	0xbf, 0xa9, 0x57, 0x55, 0xc5, 0x8e, 0xe1, 0x17, 0xf8, 0xef, 0x4c, 0xf3, 0x9f, 0xf3, 0x64, 0x1a,
+	0x06, 0x2f, 0x50, 0xfa, 0x84, 0x40, 0x23, 0x93, 0x28, 0x1c, 0x7b, 0x60, 0x1f, 0xb6, 0x5d, 0x53,
+	0x93, 0x07, 0x00, 0x82, 0x5d, 0x9d, 0xfb, 0x86, 0xe5, 0xd4, 0xcc, 0xa4, 0x2d, 0xd8, 0x55, 0x21,
+	0x23, 0x47, 0xd0, 0x56, 0x18, 0xa7, 0x11, 0x53, 0x28, 0x9d, 0xfa, 0xa0, 0x7e, 0xd8, 0x39, 0xea,
+	0x8d, 0xca, 0x7f, 0xf2, 0xbe, 0x1c, 0xe8, 0xdd, 0xee, 0x1d, 0x6d, 0x78, 0x0a, 0xdd, 0xcd, 0x11,
+	0xe9, 0x43, 0x6b, 0x1a, 0x46, 0x98, 0xb0, 0x18, 0xcb, 0xa7, 0xd7, 0x58, 0x5b, 0xf2, 0xf8, 0xa7,
+	0x59, 0xf9, 0xb0, 0xa9, 0x87, 0x1f, 0x60, 0xaf, 0xd2, 0xcb, 0xad, 0xbe, 0xff, 0x30, 0x56, 0xfb,
+	0x37, 0x63, 0x67, 0xb0, 0xf7, 0x2a, 0x8b, 0xa2, 0x77, 0xaa, 0x72, 0xf6, 0x18, 0x76, 0xa4, 0x06,
+	0x66, 0xb3, 0x5e, 0xb0, 0x0e, 0x73, 0xb4, 0x26, 0xba, 0x05, 0xe5, 0xa4, 0x71, 0xf3, 0xfd, 0xc0,
+	0x1a, 0x32, 0xd8, 0x37, 0xa9, 0xbe, 0x0e, 0xa5, 0xe2, 0x62, 0xb6, 0xd5, 0xde, 0x31, 0xec, 0x62,
+	0xa2, 0x44, 0xb8, 0x36, 0x77, 0xbf, 0x32, 0xb7, 0x29, 0x7f, 0x99, 0x28, 0x31, 0x9b, 0x34, 0xae,
+	0x7f, 0x1e, 0x58, 0x6e, 0xc5, 0x1f, 0x2e, 0x6a, 0xf0, 0xff, 0x5f, 0x24, 0xf2, 0x10, 0xba, 0x2a,
+	0x8c, 0x51, 0x2a, 0x16, 0xa7, 0xe7, 0xb1, 0x34, 0x8f, 0xd5, 0xdd, 0xce, 0xba, 0xf7, 0x56, 0x92,
+	0x01, 0x74, 0xa6, 0x61, 0x12, 0xa0, 0x48, 0x45, 0x98, 0xa8, 0x32, 0xd2, 0xcd, 0x16, 0x79, 0x06,
+	0xcd, 0x88, 0x79, 0x18, 0x55, 0xa7, 0x7c, 0xb4, 0xd5, 0xd4, 0xe8, 0x8d, 0xe1, 0x99, 0xda, 0x2d,
+	0x45, 0xe4, 0x1e, 0x34, 0x75, 0x16, 0x99, 0x74, 0x1a, 0x66, 0x77, 0x89, 0xf4, 0x81, 0x05, 0xfa,
+	0x18, 0x5e, 0xa2, 0x70, 0x76, 0x8a, 0x03, 0x57, 0x58, 0x9b, 0x0a, 0x13, 0x85, 0x81, 0x60, 0x2a,
+	0xe4, 0x89, 0xd3, 0x2c, 0x4c, 0x6d, 0xb4, 0x88, 0x03, 0xbb, 0x3c, 0x53, 0x3e, 0x8f, 0xd1, 0xd9,
+	0x35, 0xd3, 0x0a, 0x92, 0x1e, 0xec, 0xa0, 0x10, 0x5c, 0x38, 0x2d, 0xd3, 0x2f, 0x40, 0xff, 0x18,
+	0x3a, 0x1b, 0xe6, 0xc8, 0x3e, 0xd4, 0x3f, 0xe3, 0xac, 0x0c, 0x5f, 0x97, 0x5a, 0x76, 0xc9, 0xa2,
+	0x0c, 0xcb, 0x04, 0x0a, 0x70, 0x52, 0x7b, 0x6a, 0x4f, 0x4e, 0xe7, 0x4b, 0x6a, 0x2d, 0x96, 0xd4,
+	0xba, 0x5d, 0x52, 0xfb, 0x6b, 0x4e, 0xed, 0x1f, 0x39, 0xb5, 0xaf, 0x73, 0x6a, 0xcf, 0x73, 0x6a,
+	0xff, 0xca, 0xa9, 0x7d, 0x93, 0x53, 0xeb, 0x36, 0xa7, 0xf6, 0xb7, 0x15, 0xb5, 0xe6, 0x2b, 0x6a,
+	0x2d, 0x56, 0xd4, 0xfa, 0xd8, 0x2a, 0x42, 0x4a, 0x3d, 0xaf, 0x69, 0x3e, 0xad, 0x27, 0xbf, 0x07,
+	0x00, 0xb1, 0xab, 0x13, 0x18, 0xcc, 0x03, 0x00, 0x00,
 }
 
 func (this *AlertConfigDesc) Equal(that interface{}) bool {
@@ -273,6 +499,120 @@ func (this *TemplateDesc) Equal(that interface{}) bool {
 	}
 	return true
 }
+func (this *TemplatesDesc) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*TemplatesDesc)
+	if !ok {
+		that2, ok := that.(TemplatesDesc)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.User != that1.User {
+		return false
+	}
+	if len(this.Templates) != len(that1.Templates) {
+		return false
+	}
+	for i := range this.Templates {
+		if !this.Templates[i].Equal(that1.Templates[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *AlertHistoryDesc) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*AlertHistoryDesc)
+	if !ok {
+		that2, ok := that.(AlertHistoryDesc)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.User != that1.User {
+		return false
+	}
+	if len(this.Entries) != len(that1.Entries) {
+		return false
+	}
+	for i := range this.Entries {
+		if !this.Entries[i].Equal(&that1.Entries[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *AlertHistoryEntry) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*AlertHistoryEntry)
+	if !ok {
+		that2, ok := that.(AlertHistoryEntry)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.TimestampMs != that1.TimestampMs {
+		return false
+	}
+	if this.Fingerprint != that1.Fingerprint {
+		return false
+	}
+	if len(this.Labels) != len(that1.Labels) {
+		return false
+	}
+	for i := range this.Labels {
+		if this.Labels[i] != that1.Labels[i] {
+			return false
+		}
+	}
+	if this.Status != that1.Status {
+		return false
+	}
+	if this.Receiver != that1.Receiver {
+		return false
+	}
+	if this.Integration != that1.Integration {
+		return false
+	}
+	if this.Outcome != that1.Outcome {
+		return false
+	}
+	if this.Error != that1.Error {
+		return false
+	}
+	return true
+}
 func (this *AlertConfigDesc) GoString() string {
 	if this == nil {
 		return "nil"
@@ -298,6 +638,19 @@ func (this *TemplateDesc) GoString() string {
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
+func (this *TemplatesDesc) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&alertspb.TemplatesDesc{")
+	s = append(s, "User: "+fmt.Sprintf("%#v", this.User)+",\n")
+	if this.Templates != nil {
+		s = append(s, "Templates: "+fmt.Sprintf("%#v", this.Templates)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
 func (this *FullStateDesc) GoString() string {
 	if this == nil {
 		return "nil"
@@ -310,6 +663,52 @@ func (this *FullStateDesc) GoString() string {
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
+func (this *AlertHistoryDesc) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&alertspb.AlertHistoryDesc{")
+	s = append(s, "User: "+fmt.Sprintf("%#v", this.User)+",\n")
+	if this.Entries != nil {
+		vs := make([]AlertHistoryEntry, len(this.Entries))
+		for i := range vs {
+			vs[i] = this.Entries[i]
+		}
+		s = append(s, "Entries: "+fmt.Sprintf("%#v", vs)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *AlertHistoryEntry) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 12)
+	s = append(s, "&alertspb.AlertHistoryEntry{")
+	s = append(s, "TimestampMs: "+fmt.Sprintf("%#v", this.TimestampMs)+",\n")
+	s = append(s, "Fingerprint: "+fmt.Sprintf("%#v", this.Fingerprint)+",\n")
+	keysForLabels := make([]string, 0, len(this.Labels))
+	for k, _ := range this.Labels {
+		keysForLabels = append(keysForLabels, k)
+	}
+	github_com_gogo_protobuf_sortkeys.Strings(keysForLabels)
+	mapStringForLabels := "map[string]string{"
+	for _, k := range keysForLabels {
+		mapStringForLabels += fmt.Sprintf("%#v: %#v,", k, this.Labels[k])
+	}
+	mapStringForLabels += "}"
+	if this.Labels != nil {
+		s = append(s, "Labels: "+mapStringForLabels+",\n")
+	}
+	s = append(s, "Status: "+fmt.Sprintf("%#v", this.Status)+",\n")
+	s = append(s, "Receiver: "+fmt.Sprintf("%#v", this.Receiver)+",\n")
+	s = append(s, "Integration: "+fmt.Sprintf("%#v", this.Integration)+",\n")
+	s = append(s, "Outcome: "+fmt.Sprintf("%#v", this.Outcome)+",\n")
+	s = append(s, "Error: "+fmt.Sprintf("%#v", this.Error)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
 func valueToGoStringAlerts(v interface{}, typ string) string {
 	rv := reflect.ValueOf(v)
 	if rv.IsNil() {
@@ -406,7 +805,7 @@ func (m *TemplateDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
-func (m *FullStateDesc) Marshal() (dAtA []byte, err error) {
+func (m *TemplatesDesc) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalToSizedBuffer(dAtA[:size])
@@ -416,19 +815,63 @@ func (m *FullStateDesc) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *FullStateDesc) MarshalTo(dAtA []byte) (int, error) {
+func (m *TemplatesDesc) MarshalTo(dAtA []byte) (int, error) {
 	size := m.Size()
 	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *FullStateDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+func (m *TemplatesDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	i := len(dAtA)
 	_ = i
 	var l int
 	_ = l
-	if m.State != nil {
-		{
-			size, err := m.State.MarshalToSizedBuffer(dAtA[:i])
+	if len(m.Templates) > 0 {
+		for iNdEx := len(m.Templates) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Templates[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintAlerts(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x12
+		}
+	}
+	if len(m.User) > 0 {
+		i -= len(m.User)
+		copy(dAtA[i:], m.User)
+		i = encodeVarintAlerts(dAtA, i, uint64(len(m.User)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *FullStateDesc) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *FullStateDesc) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *FullStateDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.State != nil {
+		{
+			size, err := m.State.MarshalToSizedBuffer(dAtA[:i])
 			if err != nil {
 				return 0, err
 			}
@@ -441,6 +884,139 @@ func (m *FullStateDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *AlertHistoryDesc) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *AlertHistoryDesc) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *AlertHistoryDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Entries) > 0 {
+		for iNdEx := len(m.Entries) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Entries[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintAlerts(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x12
+		}
+	}
+	if len(m.User) > 0 {
+		i -= len(m.User)
+		copy(dAtA[i:], m.User)
+		i = encodeVarintAlerts(dAtA, i, uint64(len(m.User)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *AlertHistoryEntry) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *AlertHistoryEntry) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *AlertHistoryEntry) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Error) > 0 {
+		i -= len(m.Error)
+		copy(dAtA[i:], m.Error)
+		i = encodeVarintAlerts(dAtA, i, uint64(len(m.Error)))
+		i--
+		dAtA[i] = 0x42
+	}
+	if len(m.Outcome) > 0 {
+		i -= len(m.Outcome)
+		copy(dAtA[i:], m.Outcome)
+		i = encodeVarintAlerts(dAtA, i, uint64(len(m.Outcome)))
+		i--
+		dAtA[i] = 0x3a
+	}
+	if len(m.Integration) > 0 {
+		i -= len(m.Integration)
+		copy(dAtA[i:], m.Integration)
+		i = encodeVarintAlerts(dAtA, i, uint64(len(m.Integration)))
+		i--
+		dAtA[i] = 0x32
+	}
+	if len(m.Receiver) > 0 {
+		i -= len(m.Receiver)
+		copy(dAtA[i:], m.Receiver)
+		i = encodeVarintAlerts(dAtA, i, uint64(len(m.Receiver)))
+		i--
+		dAtA[i] = 0x2a
+	}
+	if len(m.Status) > 0 {
+		i -= len(m.Status)
+		copy(dAtA[i:], m.Status)
+		i = encodeVarintAlerts(dAtA, i, uint64(len(m.Status)))
+		i--
+		dAtA[i] = 0x22
+	}
+	if len(m.Labels) > 0 {
+		for k := range m.Labels {
+			v := m.Labels[k]
+			baseI := i
+			i -= len(v)
+			copy(dAtA[i:], v)
+			i = encodeVarintAlerts(dAtA, i, uint64(len(v)))
+			i--
+			dAtA[i] = 0x12
+			i -= len(k)
+			copy(dAtA[i:], k)
+			i = encodeVarintAlerts(dAtA, i, uint64(len(k)))
+			i--
+			dAtA[i] = 0xa
+			i = encodeVarintAlerts(dAtA, i, uint64(baseI-i))
+			i--
+			dAtA[i] = 0x1a
+		}
+	}
+	if len(m.Fingerprint) > 0 {
+		i -= len(m.Fingerprint)
+		copy(dAtA[i:], m.Fingerprint)
+		i = encodeVarintAlerts(dAtA, i, uint64(len(m.Fingerprint)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.TimestampMs != 0 {
+		i = encodeVarintAlerts(dAtA, i, uint64(m.TimestampMs))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
 func encodeVarintAlerts(dAtA []byte, offset int, v uint64) int {
 	offset -= sovAlerts(v)
 	base := offset
@@ -492,6 +1068,25 @@ func (m *TemplateDesc) Size() (n int) {
 	return n
 }
 
+func (m *TemplatesDesc) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.User)
+	if l > 0 {
+		n += 1 + l + sovAlerts(uint64(l))
+	}
+	if len(m.Templates) > 0 {
+		for _, e := range m.Templates {
+			l = e.Size()
+			n += 1 + l + sovAlerts(uint64(l))
+		}
+	}
+	return n
+}
+
 func (m *FullStateDesc) Size() (n int) {
 	if m == nil {
 		return 0
@@ -505,6 +1100,69 @@ func (m *FullStateDesc) Size() (n int) {
 	return n
 }
 
+func (m *AlertHistoryDesc) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.User)
+	if l > 0 {
+		n += 1 + l + sovAlerts(uint64(l))
+	}
+	if len(m.Entries) > 0 {
+		for _, e := range m.Entries {
+			l = e.Size()
+			n += 1 + l + sovAlerts(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *AlertHistoryEntry) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.TimestampMs != 0 {
+		n += 1 + sovAlerts(uint64(m.TimestampMs))
+	}
+	l = len(m.Fingerprint)
+	if l > 0 {
+		n += 1 + l + sovAlerts(uint64(l))
+	}
+	if len(m.Labels) > 0 {
+		for k, v := range m.Labels {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovAlerts(uint64(len(k))) + 1 + len(v) + sovAlerts(uint64(len(v)))
+			n += mapEntrySize + 1 + sovAlerts(uint64(mapEntrySize))
+		}
+	}
+	l = len(m.Status)
+	if l > 0 {
+		n += 1 + l + sovAlerts(uint64(l))
+	}
+	l = len(m.Receiver)
+	if l > 0 {
+		n += 1 + l + sovAlerts(uint64(l))
+	}
+	l = len(m.Integration)
+	if l > 0 {
+		n += 1 + l + sovAlerts(uint64(l))
+	}
+	l = len(m.Outcome)
+	if l > 0 {
+		n += 1 + l + sovAlerts(uint64(l))
+	}
+	l = len(m.Error)
+	if l > 0 {
+		n += 1 + l + sovAlerts(uint64(l))
+	}
+	return n
+}
+
 func sovAlerts(x uint64) (n int) {
 	return (math_bits.Len64(x|1) + 6) / 7
 }
@@ -539,6 +1197,22 @@ func (this *TemplateDesc) String() string {
 	}, "")
 	return s
 }
+func (this *TemplatesDesc) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForTemplates := "[]*TemplateDesc{"
+	for _, f := range this.Templates {
+		repeatedStringForTemplates += strings.Replace(f.String(), "TemplateDesc", "TemplateDesc", 1) + ","
+	}
+	repeatedStringForTemplates += "}"
+	s := strings.Join([]string{`&TemplatesDesc{`,
+		`User:` + fmt.Sprintf("%v", this.User) + `,`,
+		`Templates:` + repeatedStringForTemplates + `,`,
+		`}`,
+	}, "")
+	return s
+}
 func (this *FullStateDesc) String() string {
 	if this == nil {
 		return "nil"
@@ -549,6 +1223,49 @@ func (this *FullStateDesc) String() string {
 	}, "")
 	return s
 }
+func (this *AlertHistoryDesc) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForEntries := "[]AlertHistoryEntry{"
+	for _, f := range this.Entries {
+		repeatedStringForEntries += strings.Replace(strings.Replace(f.String(), "AlertHistoryEntry", "AlertHistoryEntry", 1), `&`, ``, 1) + ","
+	}
+	repeatedStringForEntries += "}"
+	s := strings.Join([]string{`&AlertHistoryDesc{`,
+		`User:` + fmt.Sprintf("%v", this.User) + `,`,
+		`Entries:` + repeatedStringForEntries + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *AlertHistoryEntry) String() string {
+	if this == nil {
+		return "nil"
+	}
+	keysForLabels := make([]string, 0, len(this.Labels))
+	for k, _ := range this.Labels {
+		keysForLabels = append(keysForLabels, k)
+	}
+	github_com_gogo_protobuf_sortkeys.Strings(keysForLabels)
+	mapStringForLabels := "map[string]string{"
+	for _, k := range keysForLabels {
+		mapStringForLabels += fmt.Sprintf("%v: %v,", k, this.Labels[k])
+	}
+	mapStringForLabels += "}"
+	s := strings.Join([]string{`&AlertHistoryEntry{`,
+		`TimestampMs:` + fmt.Sprintf("%v", this.TimestampMs) + `,`,
+		`Fingerprint:` + fmt.Sprintf("%v", this.Fingerprint) + `,`,
+		`Labels:` + mapStringForLabels + `,`,
+		`Status:` + fmt.Sprintf("%v", this.Status) + `,`,
+		`Receiver:` + fmt.Sprintf("%v", this.Receiver) + `,`,
+		`Integration:` + fmt.Sprintf("%v", this.Integration) + `,`,
+		`Outcome:` + fmt.Sprintf("%v", this.Outcome) + `,`,
+		`Error:` + fmt.Sprintf("%v", this.Error) + `,`,
+		`}`,
+	}, "")
+	return s
+}
 func valueToStringAlerts(v interface{}) string {
 	rv := reflect.ValueOf(v)
 	if rv.IsNil() {
@@ -620,7 +1337,439 @@ func (m *AlertConfigDesc) Unmarshal(dAtA []byte) error {
 			iNdEx = postIndex
 		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field RawConfig", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field RawConfig", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAlerts
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.RawConfig = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Templates", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAlerts
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Templates = append(m.Templates, &TemplateDesc{})
+			if err := m.Templates[len(m.Templates)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipAlerts(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TemplateDesc) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowAlerts
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TemplateDesc: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TemplateDesc: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Filename", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAlerts
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Filename = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Body", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAlerts
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Body = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipAlerts(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TemplatesDesc) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowAlerts
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TemplatesDesc: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TemplatesDesc: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field User", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAlerts
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.User = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Templates", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAlerts
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Templates = append(m.Templates, &TemplateDesc{})
+			if err := m.Templates[len(m.Templates)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipAlerts(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *FullStateDesc) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowAlerts
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: FullStateDesc: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: FullStateDesc: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field State", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAlerts
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.State == nil {
+				m.State = &clusterpb.FullState{}
+			}
+			if err := m.State.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipAlerts(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *AlertHistoryDesc) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowAlerts
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: AlertHistoryDesc: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: AlertHistoryDesc: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field User", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -648,11 +1797,11 @@ func (m *AlertConfigDesc) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.RawConfig = string(dAtA[iNdEx:postIndex])
+			m.User = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 3:
+		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Templates", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Entries", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -679,8 +1828,8 @@ func (m *AlertConfigDesc) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Templates = append(m.Templates, &TemplateDesc{})
-			if err := m.Templates[len(m.Templates)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			m.Entries = append(m.Entries, AlertHistoryEntry{})
+			if err := m.Entries[len(m.Entries)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
@@ -690,10 +1839,7 @@ func (m *AlertConfigDesc) Unmarshal(dAtA []byte) error {
 			if err != nil {
 				return err
 			}
-			if skippy < 0 {
-				return ErrInvalidLengthAlerts
-			}
-			if (iNdEx + skippy) < 0 {
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthAlerts
 			}
 			if (iNdEx + skippy) > l {
@@ -708,7 +1854,7 @@ func (m *AlertConfigDesc) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *TemplateDesc) Unmarshal(dAtA []byte) error {
+func (m *AlertHistoryEntry) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -731,15 +1877,34 @@ func (m *TemplateDesc) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: TemplateDesc: wiretype end group for non-group")
+			return fmt.Errorf("proto: AlertHistoryEntry: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: TemplateDesc: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: AlertHistoryEntry: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TimestampMs", wireType)
+			}
+			m.TimestampMs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAlerts
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TimestampMs |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Filename", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Fingerprint", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -767,11 +1932,138 @@ func (m *TemplateDesc) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Filename = string(dAtA[iNdEx:postIndex])
+			m.Fingerprint = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 2:
+		case 3:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Body", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Labels", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAlerts
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Labels == nil {
+				m.Labels = make(map[string]string)
+			}
+			var mapkey string
+			var mapvalue string
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowAlerts
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowAlerts
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthAlerts
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey < 0 {
+						return ErrInvalidLengthAlerts
+					}
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var stringLenmapvalue uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowAlerts
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapvalue |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapvalue := int(stringLenmapvalue)
+					if intStringLenmapvalue < 0 {
+						return ErrInvalidLengthAlerts
+					}
+					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
+					if postStringIndexmapvalue < 0 {
+						return ErrInvalidLengthAlerts
+					}
+					if postStringIndexmapvalue > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
+					iNdEx = postStringIndexmapvalue
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipAlerts(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if (skippy < 0) || (iNdEx+skippy) < 0 {
+						return ErrInvalidLengthAlerts
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.Labels[mapkey] = mapvalue
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Status", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -799,66 +2091,77 @@ func (m *TemplateDesc) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Body = string(dAtA[iNdEx:postIndex])
+			m.Status = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipAlerts(dAtA[iNdEx:])
-			if err != nil {
-				return err
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Receiver", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAlerts
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			if skippy < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthAlerts
 			}
-			if (iNdEx + skippy) < 0 {
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
 				return ErrInvalidLengthAlerts
 			}
-			if (iNdEx + skippy) > l {
+			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *FullStateDesc) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowAlerts
+			m.Receiver = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Integration", wireType)
 			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAlerts
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAlerts
 			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: FullStateDesc: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: FullStateDesc: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Integration = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 7:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field State", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Outcome", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowAlerts
@@ -868,27 +2171,55 @@ func (m *FullStateDesc) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthAlerts
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthAlerts
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.State == nil {
-				m.State = &clusterpb.FullState{}
+			m.Outcome = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Error", wireType)
 			}
-			if err := m.State.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAlerts
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthAlerts
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
 			}
+			m.Error = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -896,10 +2227,7 @@ func (m *FullStateDesc) Unmarshal(dAtA []byte) error {
 			if err != nil {
 				return err
 			}
-			if skippy < 0 {
-				return ErrInvalidLengthAlerts
-			}
-			if (iNdEx + skippy) < 0 {
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthAlerts
 			}
 			if (iNdEx + skippy) > l {
@@ -917,6 +2245,7 @@ func (m *FullStateDesc) Unmarshal(dAtA []byte) error {
 func skipAlerts(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
+	depth := 0
 	for iNdEx < l {
 		var wire uint64
 		for shift := uint(0); ; shift += 7 {
@@ -948,10 +2277,8 @@ func skipAlerts(dAtA []byte) (n int, err error) {
 					break
 				}
 			}
-			return iNdEx, nil
 		case 1:
 			iNdEx += 8
-			return iNdEx, nil
 		case 2:
 			var length int
 			for shift := uint(0); ; shift += 7 {
@@ -972,55 +2299,30 @@ func skipAlerts(dAtA []byte) (n int, err error) {
 				return 0, ErrInvalidLengthAlerts
 			}
 			iNdEx += length
-			if iNdEx < 0 {
-				return 0, ErrInvalidLengthAlerts
-			}
-			return iNdEx, nil
 		case 3:
-			for {
-				var innerWire uint64
-				var start int = iNdEx
-				for shift := uint(0); ; shift += 7 {
-					if shift >= 64 {
-						return 0, ErrIntOverflowAlerts
-					}
-					if iNdEx >= l {
-						return 0, io.ErrUnexpectedEOF
-					}
-					b := dAtA[iNdEx]
-					iNdEx++
-					innerWire |= (uint64(b) & 0x7F) << shift
-					if b < 0x80 {
-						break
-					}
-				}
-				innerWireType := int(innerWire & 0x7)
-				if innerWireType == 4 {
-					break
-				}
-				next, err := skipAlerts(dAtA[start:])
-				if err != nil {
-					return 0, err
-				}
-				iNdEx = start + next
-				if iNdEx < 0 {
-					return 0, ErrInvalidLengthAlerts
-				}
-			}
-			return iNdEx, nil
+			depth++
 		case 4:
-			return iNdEx, nil
+			if depth == 0 {
+				return 0, ErrUnexpectedEndOfGroupAlerts
+			}
+			depth--
 		case 5:
 			iNdEx += 4
-			return iNdEx, nil
 		default:
 			return 0, fmt.Errorf("proto: illegal wireType %d", wireType)
 		}
+		if iNdEx < 0 {
+			return 0, ErrInvalidLengthAlerts
+		}
+		if depth == 0 {
+			return iNdEx, nil
+		}
 	}
-	panic("unreachable")
+	return 0, io.ErrUnexpectedEOF
 }
 
 var (
-	ErrInvalidLengthAlerts = fmt.Errorf("proto: negative length found during unmarshaling")
-	ErrIntOverflowAlerts   = fmt.Errorf("proto: integer overflow")
+	ErrInvalidLengthAlerts        = fmt.Errorf("proto: negative length found during unmarshaling")
+	ErrIntOverflowAlerts          = fmt.Errorf("proto: integer overflow")
+	ErrUnexpectedEndOfGroupAlerts = fmt.Errorf("proto: unexpected end of group")
 )
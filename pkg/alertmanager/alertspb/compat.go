@@ -31,3 +31,21 @@ func ParseTemplates(cfg AlertConfigDesc) map[string]string {
 	}
 	return templates
 }
+
+// WithTemplates returns the alertmanager config including the template files stored apart from it.
+// The template files of the config take precedence over the stored ones with the same name.
+func WithTemplates(cfg AlertConfigDesc, templates TemplatesDesc) AlertConfigDesc {
+	merged := make([]*TemplateDesc, 0, len(cfg.Templates)+len(templates.Templates))
+	names := make(map[string]struct{}, len(cfg.Templates))
+	for _, t := range cfg.Templates {
+		merged = append(merged, t)
+		names[t.Filename] = struct{}{}
+	}
+	for _, t := range templates.Templates {
+		if _, ok := names[t.Filename]; !ok {
+			merged = append(merged, t)
+		}
+	}
+	cfg.Templates = merged
+	return cfg
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"path"
 	"strings"
 	"sync"
 
@@ -26,6 +27,11 @@ const (
 	//     alerts/<user-id>
 	alertsPrefix = "alerts"
 
+	// The bucket prefix under which the template files stored apart from the alertmanager configs are stored.
+	// Note that objects stored under this prefix follow the pattern:
+	//     alertmanager-templates/<user-id>
+	templatesPrefix = "alertmanager-templates"
+
 	// The bucket prefix under which other alertmanager state is stored.
 	// Note that objects stored under this prefix follow the pattern:
 	//     alertmanager/<user-id>/<object>
@@ -34,6 +40,16 @@ const (
 	// The name of alertmanager full state objects (notification log + silences).
 	fullStateName = "fullstate"
 
+	// The prefix, within the alertmanager objects of an user, under which the snapshots of the
+	// alertmanager full state are stored. Note that they follow the pattern:
+	//     alertmanager/<user-id>/snapshots/<snapshot-name>
+	fullStateSnapshotsPrefix = "snapshots"
+
+	// The prefix, within the alertmanager objects of an user, under which the batches of the
+	// alert history are stored. Note that they follow the pattern:
+	//     alertmanager/<user-id>/history/<batch-name>
+	alertHistoryPrefix = "history"
+
 	// How many users to load concurrently.
 	fetchConcurrency = 16
 )
@@ -41,18 +57,20 @@ const (
 // BucketAlertStore is used to support the AlertStore interface against an object storage backend. It is implemented
 // using the Thanos objstore.Bucket interface
 type BucketAlertStore struct {
-	alertsBucket objstore.Bucket
-	amBucket     objstore.Bucket
-	cfgProvider  bucket.TenantConfigProvider
-	logger       log.Logger
+	alertsBucket    objstore.Bucket
+	templatesBucket objstore.Bucket
+	amBucket        objstore.Bucket
+	cfgProvider     bucket.TenantConfigProvider
+	logger          log.Logger
 }
 
 func NewBucketAlertStore(bkt objstore.Bucket, cfgProvider bucket.TenantConfigProvider, logger log.Logger) *BucketAlertStore {
 	return &BucketAlertStore{
-		alertsBucket: bucket.NewPrefixedBucketClient(bkt, alertsPrefix),
-		amBucket:     bucket.NewPrefixedBucketClient(bkt, alertmanagerPrefix),
-		cfgProvider:  cfgProvider,
-		logger:       logger,
+		alertsBucket:    bucket.NewPrefixedBucketClient(bkt, alertsPrefix),
+		templatesBucket: bucket.NewPrefixedBucketClient(bkt, templatesPrefix),
+		amBucket:        bucket.NewPrefixedBucketClient(bkt, alertmanagerPrefix),
+		cfgProvider:     cfgProvider,
+		logger:          logger,
 	}
 }
 
@@ -182,6 +200,148 @@ func (s *BucketAlertStore) DeleteFullState(ctx context.Context, userID string) e
 	return err
 }
 
+// ListFullStateSnapshots implements alertstore.AlertStore.
+func (s *BucketAlertStore) ListFullStateSnapshots(ctx context.Context, userID string) ([]string, error) {
+	var names []string
+
+	err := s.getAlertmanagerUserBucket(userID).Iter(ctx, fullStateSnapshotsPrefix, func(key string) error {
+		names = append(names, strings.TrimPrefix(key, fullStateSnapshotsPrefix+objstore.DirDelim))
+		return nil
+	})
+
+	return names, err
+}
+
+// GetFullStateSnapshot implements alertstore.AlertStore.
+func (s *BucketAlertStore) GetFullStateSnapshot(ctx context.Context, userID, name string) (alertspb.FullStateDesc, error) {
+	bkt := s.getAlertmanagerUserBucket(userID)
+	fs := alertspb.FullStateDesc{}
+
+	err := s.get(ctx, bkt, path.Join(fullStateSnapshotsPrefix, name), &fs)
+	if bkt.IsObjNotFoundErr(err) {
+		return fs, alertspb.ErrNotFound
+	}
+
+	if bkt.IsAccessDeniedErr(err) {
+		return fs, alertspb.ErrAccessDenied
+	}
+
+	return fs, err
+}
+
+// SetFullStateSnapshot implements alertstore.AlertStore.
+func (s *BucketAlertStore) SetFullStateSnapshot(ctx context.Context, userID, name string, fs alertspb.FullStateDesc) error {
+	bkt := s.getAlertmanagerUserBucket(userID)
+
+	fsBytes, err := fs.Marshal()
+	if err != nil {
+		return err
+	}
+
+	return bkt.Upload(ctx, path.Join(fullStateSnapshotsPrefix, name), bytes.NewReader(fsBytes))
+}
+
+// DeleteFullStateSnapshot implements alertstore.AlertStore.
+func (s *BucketAlertStore) DeleteFullStateSnapshot(ctx context.Context, userID, name string) error {
+	userBkt := s.getAlertmanagerUserBucket(userID)
+
+	err := userBkt.Delete(ctx, path.Join(fullStateSnapshotsPrefix, name))
+	if userBkt.IsObjNotFoundErr(err) {
+		return nil
+	}
+	return err
+}
+
+// ListAlertHistory implements alertstore.AlertStore.
+func (s *BucketAlertStore) ListAlertHistory(ctx context.Context, userID string) ([]string, error) {
+	var names []string
+
+	err := s.getAlertmanagerUserBucket(userID).Iter(ctx, alertHistoryPrefix, func(key string) error {
+		names = append(names, strings.TrimPrefix(key, alertHistoryPrefix+objstore.DirDelim))
+		return nil
+	})
+
+	return names, err
+}
+
+// GetAlertHistory implements alertstore.AlertStore.
+func (s *BucketAlertStore) GetAlertHistory(ctx context.Context, userID, name string) (alertspb.AlertHistoryDesc, error) {
+	bkt := s.getAlertmanagerUserBucket(userID)
+	history := alertspb.AlertHistoryDesc{}
+
+	err := s.get(ctx, bkt, path.Join(alertHistoryPrefix, name), &history)
+	if bkt.IsObjNotFoundErr(err) {
+		return history, alertspb.ErrNotFound
+	}
+
+	if bkt.IsAccessDeniedErr(err) {
+		return history, alertspb.ErrAccessDenied
+	}
+
+	return history, err
+}
+
+// SetAlertHistory implements alertstore.AlertStore.
+func (s *BucketAlertStore) SetAlertHistory(ctx context.Context, userID, name string, history alertspb.AlertHistoryDesc) error {
+	bkt := s.getAlertmanagerUserBucket(userID)
+
+	historyBytes, err := history.Marshal()
+	if err != nil {
+		return err
+	}
+
+	return bkt.Upload(ctx, path.Join(alertHistoryPrefix, name), bytes.NewReader(historyBytes))
+}
+
+// DeleteAlertHistory implements alertstore.AlertStore.
+func (s *BucketAlertStore) DeleteAlertHistory(ctx context.Context, userID, name string) error {
+	userBkt := s.getAlertmanagerUserBucket(userID)
+
+	err := userBkt.Delete(ctx, path.Join(alertHistoryPrefix, name))
+	if userBkt.IsObjNotFoundErr(err) {
+		return nil
+	}
+	return err
+}
+
+// GetTemplates implements alertstore.AlertStore.
+func (s *BucketAlertStore) GetTemplates(ctx context.Context, userID string) (alertspb.TemplatesDesc, error) {
+	templates := alertspb.TemplatesDesc{}
+	userBkt := s.getUserTemplatesBucket(userID)
+
+	err := s.get(ctx, userBkt, userID, &templates)
+	if userBkt.IsObjNotFoundErr(err) {
+		return templates, alertspb.ErrNotFound
+	}
+
+	if userBkt.IsAccessDeniedErr(err) {
+		return templates, alertspb.ErrAccessDenied
+	}
+
+	return templates, err
+}
+
+// SetTemplates implements alertstore.AlertStore.
+func (s *BucketAlertStore) SetTemplates(ctx context.Context, templates alertspb.TemplatesDesc) error {
+	templatesBytes, err := templates.Marshal()
+	if err != nil {
+		return err
+	}
+
+	return s.getUserTemplatesBucket(templates.User).Upload(ctx, templates.User, bytes.NewReader(templatesBytes))
+}
+
+// DeleteTemplates implements alertstore.AlertStore.
+func (s *BucketAlertStore) DeleteTemplates(ctx context.Context, userID string) error {
+	userBkt := s.getUserTemplatesBucket(userID)
+
+	err := userBkt.Delete(ctx, userID)
+	if userBkt.IsObjNotFoundErr(err) {
+		return nil
+	}
+	return err
+}
+
 func (s *BucketAlertStore) getAlertConfig(ctx context.Context, userID string) (alertspb.AlertConfigDesc, objstore.Bucket, error) {
 	config := alertspb.AlertConfigDesc{}
 	userBkt := s.getUserBucket(userID)
@@ -215,6 +375,11 @@ func (s *BucketAlertStore) getUserBucket(userID string) objstore.Bucket {
 	return bucket.NewSSEBucketClient(userID, s.alertsBucket, s.cfgProvider)
 }
 
+func (s *BucketAlertStore) getUserTemplatesBucket(userID string) objstore.Bucket {
+	// Inject server-side encryption based on the tenant config.
+	return bucket.NewSSEBucketClient(userID, s.templatesBucket, s.cfgProvider)
+}
+
 func (s *BucketAlertStore) getAlertmanagerUserBucket(userID string) objstore.Bucket {
 	uBucket := bucket.NewUserBucketClient(userID, s.amBucket, s.cfgProvider)
 	return uBucket.WithExpectedErrs(tsdb.IsOneOfTheExpectedErrors(uBucket.IsAccessDeniedErr, uBucket.IsObjNotFoundErr))
@@ -113,6 +113,61 @@ func (c *Store) DeleteFullState(ctx context.Context, user string) error {
 	return errState
 }
 
+// ListFullStateSnapshots implements alertstore.AlertStore.
+func (c *Store) ListFullStateSnapshots(_ context.Context, _ string) ([]string, error) {
+	return nil, errState
+}
+
+// GetFullStateSnapshot implements alertstore.AlertStore.
+func (c *Store) GetFullStateSnapshot(_ context.Context, _, _ string) (alertspb.FullStateDesc, error) {
+	return alertspb.FullStateDesc{}, errState
+}
+
+// SetFullStateSnapshot implements alertstore.AlertStore.
+func (c *Store) SetFullStateSnapshot(_ context.Context, _, _ string, _ alertspb.FullStateDesc) error {
+	return errState
+}
+
+// DeleteFullStateSnapshot implements alertstore.AlertStore.
+func (c *Store) DeleteFullStateSnapshot(_ context.Context, _, _ string) error {
+	return errState
+}
+
+// ListAlertHistory implements alertstore.AlertStore.
+func (c *Store) ListAlertHistory(_ context.Context, _ string) ([]string, error) {
+	return nil, errState
+}
+
+// GetAlertHistory implements alertstore.AlertStore.
+func (c *Store) GetAlertHistory(_ context.Context, _, _ string) (alertspb.AlertHistoryDesc, error) {
+	return alertspb.AlertHistoryDesc{}, errState
+}
+
+// SetAlertHistory implements alertstore.AlertStore.
+func (c *Store) SetAlertHistory(_ context.Context, _, _ string, _ alertspb.AlertHistoryDesc) error {
+	return errState
+}
+
+// DeleteAlertHistory implements alertstore.AlertStore.
+func (c *Store) DeleteAlertHistory(_ context.Context, _, _ string) error {
+	return errState
+}
+
+// GetTemplates implements alertstore.AlertStore.
+func (c *Store) GetTemplates(_ context.Context, _ string) (alertspb.TemplatesDesc, error) {
+	return alertspb.TemplatesDesc{}, alertspb.ErrNotFound
+}
+
+// SetTemplates implements alertstore.AlertStore.
+func (c *Store) SetTemplates(_ context.Context, _ alertspb.TemplatesDesc) error {
+	return errReadOnly
+}
+
+// DeleteTemplates implements alertstore.AlertStore.
+func (c *Store) DeleteTemplates(_ context.Context, _ string) error {
+	return errReadOnly
+}
+
 func (c *Store) reloadConfigs(ctx context.Context) (map[string]alertspb.AlertConfigDesc, error) {
 	configs, err := c.configClient.GetAlerts(ctx, c.since)
 	if err != nil {
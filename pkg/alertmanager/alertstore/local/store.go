@@ -121,6 +121,61 @@ func (f *Store) DeleteFullState(ctx context.Context, user string) error {
 	return errState
 }
 
+// ListFullStateSnapshots implements alertstore.AlertStore.
+func (f *Store) ListFullStateSnapshots(_ context.Context, _ string) ([]string, error) {
+	return nil, errState
+}
+
+// GetFullStateSnapshot implements alertstore.AlertStore.
+func (f *Store) GetFullStateSnapshot(_ context.Context, _, _ string) (alertspb.FullStateDesc, error) {
+	return alertspb.FullStateDesc{}, errState
+}
+
+// SetFullStateSnapshot implements alertstore.AlertStore.
+func (f *Store) SetFullStateSnapshot(_ context.Context, _, _ string, _ alertspb.FullStateDesc) error {
+	return errState
+}
+
+// DeleteFullStateSnapshot implements alertstore.AlertStore.
+func (f *Store) DeleteFullStateSnapshot(_ context.Context, _, _ string) error {
+	return errState
+}
+
+// ListAlertHistory implements alertstore.AlertStore.
+func (f *Store) ListAlertHistory(_ context.Context, _ string) ([]string, error) {
+	return nil, errState
+}
+
+// GetAlertHistory implements alertstore.AlertStore.
+func (f *Store) GetAlertHistory(_ context.Context, _, _ string) (alertspb.AlertHistoryDesc, error) {
+	return alertspb.AlertHistoryDesc{}, errState
+}
+
+// SetAlertHistory implements alertstore.AlertStore.
+func (f *Store) SetAlertHistory(_ context.Context, _, _ string, _ alertspb.AlertHistoryDesc) error {
+	return errState
+}
+
+// DeleteAlertHistory implements alertstore.AlertStore.
+func (f *Store) DeleteAlertHistory(_ context.Context, _, _ string) error {
+	return errState
+}
+
+// GetTemplates implements alertstore.AlertStore.
+func (f *Store) GetTemplates(_ context.Context, _ string) (alertspb.TemplatesDesc, error) {
+	return alertspb.TemplatesDesc{}, alertspb.ErrNotFound
+}
+
+// SetTemplates implements alertstore.AlertStore.
+func (f *Store) SetTemplates(_ context.Context, _ alertspb.TemplatesDesc) error {
+	return errReadOnly
+}
+
+// DeleteTemplates implements alertstore.AlertStore.
+func (f *Store) DeleteTemplates(_ context.Context, _ string) error {
+	return errReadOnly
+}
+
 func (f *Store) reloadConfigs() (map[string]alertspb.AlertConfigDesc, error) {
 	configs := map[string]alertspb.AlertConfigDesc{}
 	err := filepath.Walk(f.cfg.Path, func(path string, info os.FileInfo, err error) error {
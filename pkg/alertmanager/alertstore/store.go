@@ -46,6 +46,42 @@ type AlertStore interface {
 	// DeleteFullState deletes the alertmanager state for an user.
 	// If state for the user doesn't exist, no error is reported.
 	DeleteFullState(ctx context.Context, user string) error
+
+	// ListFullStateSnapshots returns the names of the snapshots of the alertmanager state for the given user.
+	ListFullStateSnapshots(ctx context.Context, user string) ([]string, error)
+
+	// GetFullStateSnapshot loads and returns a snapshot of the alertmanager state for the given user.
+	GetFullStateSnapshot(ctx context.Context, user, name string) (alertspb.FullStateDesc, error)
+
+	// SetFullStateSnapshot stores a snapshot of the alertmanager state for the given user.
+	SetFullStateSnapshot(ctx context.Context, user, name string, fs alertspb.FullStateDesc) error
+
+	// DeleteFullStateSnapshot deletes a snapshot of the alertmanager state for an user.
+	// If the snapshot doesn't exist, no error is reported.
+	DeleteFullStateSnapshot(ctx context.Context, user, name string) error
+
+	// GetTemplates loads and returns the template files stored apart from the alertmanager configuration for the given user.
+	GetTemplates(ctx context.Context, user string) (alertspb.TemplatesDesc, error)
+
+	// SetTemplates stores the template files for an user, apart from the alertmanager configuration.
+	SetTemplates(ctx context.Context, templates alertspb.TemplatesDesc) error
+
+	// DeleteTemplates deletes the template files stored apart from the alertmanager configuration for an user.
+	// If template files for the user don't exist, no error is reported.
+	DeleteTemplates(ctx context.Context, user string) error
+
+	// ListAlertHistory returns the names of the batches of the alert history for the given user.
+	ListAlertHistory(ctx context.Context, user string) ([]string, error)
+
+	// GetAlertHistory loads and returns a batch of the alert history for the given user.
+	GetAlertHistory(ctx context.Context, user, name string) (alertspb.AlertHistoryDesc, error)
+
+	// SetAlertHistory stores a batch of the alert history for the given user.
+	SetAlertHistory(ctx context.Context, user, name string, history alertspb.AlertHistoryDesc) error
+
+	// DeleteAlertHistory deletes a batch of the alert history for an user.
+	// If the batch doesn't exist, no error is reported.
+	DeleteAlertHistory(ctx context.Context, user, name string) error
 }
 
 // NewAlertStore returns a alertmanager store backend client based on the provided cfg.
@@ -292,6 +292,109 @@ func TestBucketAlertStore_GetSetDeleteFullState(t *testing.T) {
 	}
 }
 
+func TestBucketAlertStore_GetSetDeleteFullStateSnapshots(t *testing.T) {
+	bucket := objstore.NewInMemBucket()
+	mBucketClient := &mockBucket{Bucket: bucket}
+	store := bucketclient.NewBucketAlertStore(mBucketClient, nil, log.NewNopLogger())
+	ctx := context.Background()
+
+	state1 := makeTestFullState("one")
+	state2 := makeTestFullState("two")
+
+	// The storage is empty.
+	_, err := store.GetFullStateSnapshot(ctx, "user-1", "100")
+	assert.Equal(t, alertspb.ErrNotFound, err)
+
+	names, err := store.ListFullStateSnapshots(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	// Test Access Denied
+	mBucketClient.err = errAccessDenied
+	_, err = store.GetFullStateSnapshot(ctx, "user-1", "100")
+	assert.Equal(t, alertspb.ErrAccessDenied, err)
+	mBucketClient.err = nil
+
+	// The storage contains the snapshots of the user, apart from its current state.
+	require.NoError(t, store.SetFullState(ctx, "user-1", state2))
+	require.NoError(t, store.SetFullStateSnapshot(ctx, "user-1", "100", state1))
+	require.NoError(t, store.SetFullStateSnapshot(ctx, "user-1", "200", state2))
+
+	res, err := store.GetFullStateSnapshot(ctx, "user-1", "100")
+	require.NoError(t, err)
+	assert.Equal(t, state1, res)
+
+	exists, err := bucket.Exists(ctx, "alertmanager/user-1/snapshots/100")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	names, err = store.ListFullStateSnapshots(ctx, "user-1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"100", "200"}, names)
+
+	users, err := store.ListUsersWithFullState(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"user-1"}, users)
+
+	// A snapshot of the user is deleted.
+	require.NoError(t, store.DeleteFullStateSnapshot(ctx, "user-1", "100"))
+	_, err = store.GetFullStateSnapshot(ctx, "user-1", "100")
+	assert.Equal(t, alertspb.ErrNotFound, err)
+
+	names, err = store.ListFullStateSnapshots(ctx, "user-1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"200"}, names)
+
+	res, err = store.GetFullState(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, state2, res)
+
+	// Delete again (should be idempotent).
+	require.NoError(t, store.DeleteFullStateSnapshot(ctx, "user-1", "100"))
+}
+
+func TestBucketAlertStore_GetSetDeleteTemplates(t *testing.T) {
+	bucket := objstore.NewInMemBucket()
+	mBucketClient := &mockBucket{Bucket: bucket}
+	store := bucketclient.NewBucketAlertStore(mBucketClient, nil, log.NewNopLogger())
+	ctx := context.Background()
+
+	templates := alertspb.TemplatesDesc{
+		User:      "user-1",
+		Templates: []*alertspb.TemplateDesc{{Filename: "slack.tmpl", Body: `{{ define "slack.title" }}{{ .Status }}{{ end }}`}},
+	}
+
+	// The storage is empty.
+	_, err := store.GetTemplates(ctx, "user-1")
+	assert.Equal(t, alertspb.ErrNotFound, err)
+
+	// Test Access Denied
+	mBucketClient.err = errAccessDenied
+	_, err = store.GetTemplates(ctx, "user-1")
+	assert.Equal(t, alertspb.ErrAccessDenied, err)
+	mBucketClient.err = nil
+
+	// The storage contains the templates of the user.
+	require.NoError(t, store.SetTemplates(ctx, templates))
+
+	res, err := store.GetTemplates(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, templates, res)
+
+	// Ensure the templates are stored at the expected location.
+	exists, err := bucket.Exists(ctx, "alertmanager-templates/user-1")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// The templates of the user are deleted.
+	require.NoError(t, store.DeleteTemplates(ctx, "user-1"))
+	_, err = store.GetTemplates(ctx, "user-1")
+	assert.Equal(t, alertspb.ErrNotFound, err)
+
+	// Delete again (should be idempotent).
+	require.NoError(t, store.DeleteTemplates(ctx, "user-1"))
+}
+
 type mockBucket struct {
 	objstore.Bucket
 	err error
@@ -307,3 +410,60 @@ func (m *mockBucket) Get(ctx context.Context, name string) (io.ReadCloser, error
 func (m *mockBucket) IsAccessDeniedErr(err error) bool {
 	return err == errAccessDenied
 }
+
+func TestBucketAlertStore_GetSetDeleteAlertHistory(t *testing.T) {
+	bucket := objstore.NewInMemBucket()
+	mBucketClient := &mockBucket{Bucket: bucket}
+	store := bucketclient.NewBucketAlertStore(mBucketClient, nil, log.NewNopLogger())
+	ctx := context.Background()
+
+	history1 := alertspb.AlertHistoryDesc{User: "user-1", Entries: []alertspb.AlertHistoryEntry{
+		{TimestampMs: 100, Fingerprint: "a", Labels: map[string]string{"alertname": "one"}, Status: "firing"},
+	}}
+	history2 := alertspb.AlertHistoryDesc{User: "user-1", Entries: []alertspb.AlertHistoryEntry{
+		{TimestampMs: 200, Fingerprint: "a", Labels: map[string]string{"alertname": "one"}, Status: "firing", Receiver: "default", Integration: "webhook", Outcome: "success"},
+		{TimestampMs: 300, Fingerprint: "a", Labels: map[string]string{"alertname": "one"}, Status: "resolved"},
+	}}
+
+	// The storage is empty.
+	_, err := store.GetAlertHistory(ctx, "user-1", "100-100")
+	assert.Equal(t, alertspb.ErrNotFound, err)
+
+	names, err := store.ListAlertHistory(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	// Test Access Denied
+	mBucketClient.err = errAccessDenied
+	_, err = store.GetAlertHistory(ctx, "user-1", "100-100")
+	assert.Equal(t, alertspb.ErrAccessDenied, err)
+	mBucketClient.err = nil
+
+	// The storage contains the alert history of the user.
+	require.NoError(t, store.SetAlertHistory(ctx, "user-1", "100-100", history1))
+	require.NoError(t, store.SetAlertHistory(ctx, "user-1", "200-300", history2))
+
+	res, err := store.GetAlertHistory(ctx, "user-1", "200-300")
+	require.NoError(t, err)
+	assert.Equal(t, history2, res)
+
+	exists, err := bucket.Exists(ctx, "alertmanager/user-1/history/100-100")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	names, err = store.ListAlertHistory(ctx, "user-1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"100-100", "200-300"}, names)
+
+	// A batch of the alert history of the user is deleted.
+	require.NoError(t, store.DeleteAlertHistory(ctx, "user-1", "100-100"))
+	_, err = store.GetAlertHistory(ctx, "user-1", "100-100")
+	assert.Equal(t, alertspb.ErrNotFound, err)
+
+	names, err = store.ListAlertHistory(ctx, "user-1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"200-300"}, names)
+
+	// Delete again (should be idempotent).
+	require.NoError(t, store.DeleteAlertHistory(ctx, "user-1", "100-100"))
+}
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strconv"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -22,6 +24,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/concurrency"
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	util_net "github.com/cortexproject/cortex/pkg/util/net"
 )
 
 const (
@@ -35,6 +38,8 @@ const (
 	errConfigurationTooBig   = "Alertmanager configuration is too big, limit: %d bytes"
 	errTooManyTemplates      = "too many templates in the configuration: %d (limit: %d)"
 	errTemplateTooBig        = "template %s is too big: %d bytes (limit: %d bytes)"
+	errReceiverHostBlocked   = "the destination host %s of the receiver %s is not allowed"
+	errInvalidValidateOnly   = "invalid validate_only parameter %q"
 
 	fetchConcurrency = 16
 )
@@ -110,6 +115,14 @@ func (am *MultitenantAlertmanager) SetUserConfig(w http.ResponseWriter, r *http.
 		return
 	}
 
+	validateOnly := false
+	if v := r.URL.Query().Get("validate_only"); v != "" {
+		if validateOnly, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, fmt.Sprintf(errInvalidValidateOnly, v), http.StatusBadRequest)
+			return
+		}
+	}
+
 	var input io.Reader
 	maxConfigSize := am.limits.AlertmanagerMaxConfigSize(userID)
 	if maxConfigSize > 0 {
@@ -142,10 +155,29 @@ func (am *MultitenantAlertmanager) SetUserConfig(w http.ResponseWriter, r *http.
 		return
 	}
 
+	// The config is validated along with the template files stored apart from it.
+	templates, err := am.store.GetTemplates(r.Context(), userID)
+	if err != nil && !errors.Is(err, alertspb.ErrNotFound) {
+		level.Error(logger).Log("msg", errReadingTemplates, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errReadingTemplates, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
 	cfgDesc := alertspb.ToProto(cfg.AlertmanagerConfig, cfg.TemplateFiles, userID)
-	if err := validateUserConfig(logger, cfgDesc, am.limits, userID); err != nil {
-		level.Warn(logger).Log("msg", errValidatingConfig, "err", err.Error())
-		http.Error(w, fmt.Sprintf("%s: %s", errValidatingConfig, err.Error()), http.StatusBadRequest)
+	validationErr := validateUserConfig(logger, alertspb.WithTemplates(cfgDesc, templates), am.limits, userID)
+	if validationErr == nil {
+		// The secrets referenced by the receivers must exist, to not store a config which can't be applied.
+		validationErr = am.validateReceiverSecrets(r.Context(), userID, cfgDesc.RawConfig)
+	}
+
+	if validateOnly {
+		writeUserConfigValidation(w, logger, userID, cfgDesc.RawConfig, validationErr)
+		return
+	}
+
+	if validationErr != nil {
+		level.Warn(logger).Log("msg", errValidatingConfig, "err", validationErr.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errValidatingConfig, validationErr.Error()), http.StatusBadRequest)
 		return
 	}
 
@@ -159,7 +191,42 @@ func (am *MultitenantAlertmanager) SetUserConfig(w http.ResponseWriter, r *http.
 	w.WriteHeader(http.StatusCreated)
 }
 
+// UserConfigValidation is the result of the validation of an user alertmanager config, without storing it.
+type UserConfigValidation struct {
+	Valid    bool            `yaml:"valid"`
+	Error    string          `yaml:"error,omitempty"`
+	Warnings []ConfigWarning `yaml:"warnings"`
+}
+
+// writeUserConfigValidation writes the result of the validation of the config, along with its warnings.
+// It responds with 400 if the config is invalid.
+func writeUserConfigValidation(w http.ResponseWriter, logger log.Logger, userID, rawConfig string, validationErr error) {
+	res := UserConfigValidation{Valid: validationErr == nil, Warnings: []ConfigWarning{}}
+	if validationErr != nil {
+		res.Error = validationErr.Error()
+	}
+	if amCfg, _, err := loadAlertmanagerConfig(rawConfig); err == nil {
+		res.Warnings = append(res.Warnings, lintAlertmanagerConfig(amCfg)...)
+	}
+
+	d, err := yaml.Marshal(&res)
+	if err != nil {
+		level.Error(logger).Log("msg", errMarshallingYAML, "err", err, "user", userID)
+		http.Error(w, fmt.Sprintf("%s: %s", errMarshallingYAML, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	if !res.Valid {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	if _, err := w.Write(d); err != nil {
+		level.Warn(logger).Log("msg", "failed to write the config validation response", "err", err)
+	}
+}
+
 // DeleteUserConfig is exposed via user-visible API (if enabled, uses DELETE method), but also as an internal endpoint using POST method.
+// The template files stored apart from the config are deleted along with it.
 // Note that if no config exists for a user, StatusOK is returned.
 func (am *MultitenantAlertmanager) DeleteUserConfig(w http.ResponseWriter, r *http.Request) {
 	logger := util_log.WithContext(r.Context(), am.logger)
@@ -177,6 +244,13 @@ func (am *MultitenantAlertmanager) DeleteUserConfig(w http.ResponseWriter, r *ht
 		return
 	}
 
+	err = am.store.DeleteTemplates(r.Context(), userID)
+	if err != nil {
+		level.Error(logger).Log("msg", errDeletingTemplates, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errDeletingTemplates, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -190,15 +264,36 @@ func validateUserConfig(logger log.Logger, cfg alertspb.AlertConfigDesc, limits
 		return fmt.Errorf("configuration provided is empty, if you'd like to remove your configuration please use the delete configuration endpoint")
 	}
 
-	amCfg, err := config.Load(cfg.RawConfig)
+	amCfg, httpConfigs, err := loadAlertmanagerConfig(cfg.RawConfig)
 	if err != nil {
 		return err
 	}
 
-	// Validate the config recursively scanning it.
+	// Validate the config and its generic HTTP receivers recursively scanning them.
 	if err := validateAlertmanagerConfig(amCfg); err != nil {
 		return err
 	}
+	if err := validateAlertmanagerConfig(httpConfigs); err != nil {
+		return err
+	}
+
+	// Validate the destination hosts of the receivers.
+	if allowed, blocked := limits.AlertmanagerReceiversAllowedHosts(user), limits.AlertmanagerReceiversBlockedHosts(user); len(allowed) > 0 || len(blocked) > 0 {
+		for _, rcv := range amCfg.Receivers {
+			for _, host := range receiverHosts(rcv, nil) {
+				if !util_net.IsHostAllowed(host, allowed, blocked) {
+					return fmt.Errorf(errReceiverHostBlocked, host, rcv.Name)
+				}
+			}
+		}
+		for _, c := range httpConfigs {
+			for _, host := range receiverHosts(c, nil) {
+				if !util_net.IsHostAllowed(host, allowed, blocked) {
+					return fmt.Errorf(errReceiverHostBlocked, host, c.Receiver)
+				}
+			}
+		}
+	}
 
 	// Validate templates referenced in the alertmanager config.
 	for _, name := range amCfg.Templates {
@@ -540,3 +635,48 @@ func validateTelegramConfig(cfg config.TelegramConfig) error {
 	}
 	return nil
 }
+
+// receiverHosts returns the destination hosts of the receiver config, recursively scanning it
+// for the URLs and the host-port pairs of its integrations.
+func receiverHosts(cfg interface{}, hosts []string) []string {
+	v := reflect.ValueOf(cfg)
+
+	// Skip invalid, the zero value or a nil pointer (checked by zero value).
+	if !v.IsValid() || v.IsZero() {
+		return hosts
+	}
+
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	switch c := v.Interface().(type) {
+	case url.URL:
+		if host := c.Hostname(); host != "" {
+			hosts = append(hosts, host)
+		}
+		return hosts
+	case config.HostPort:
+		if c.Host != "" {
+			hosts = append(hosts, c.Host)
+		}
+		return hosts
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if fieldValue := v.Field(i); fieldValue.CanInterface() {
+				hosts = receiverHosts(fieldValue.Interface(), hosts)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if fieldValue := v.Index(i); fieldValue.CanInterface() {
+				hosts = receiverHosts(fieldValue.Interface(), hosts)
+			}
+		}
+	}
+
+	return hosts
+}
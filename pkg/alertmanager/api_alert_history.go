@@ -0,0 +1,259 @@
+package alertmanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/alertmanager/matchers/compat"
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/concurrency"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+)
+
+const (
+	errReadingAlertHistory  = "unable to read the alert history"
+	errAlertHistoryDisabled = "the alert history is not enabled"
+
+	// defaultAlertHistoryQueryRange is the time range of the alert history queries without start time.
+	defaultAlertHistoryQueryRange = 24 * time.Hour
+)
+
+// AlertHistoryEntry is a state transition of an alert, or the outcome of a notification of the alert.
+type AlertHistoryEntry struct {
+	Time        time.Time         `yaml:"time"`
+	Fingerprint string            `yaml:"fingerprint"`
+	Labels      map[string]string `yaml:"labels"`
+	Status      string            `yaml:"status"`
+	Receiver    string            `yaml:"receiver,omitempty"`
+	Integration string            `yaml:"integration,omitempty"`
+	Outcome     string            `yaml:"outcome,omitempty"`
+	Error       string            `yaml:"error,omitempty"`
+}
+
+// UserAlertHistory is used to communicate the alert history of an user.
+type UserAlertHistory struct {
+	Entries []AlertHistoryEntry `yaml:"entries"`
+}
+
+// alertHistoryQuery selects the entries of the alert history.
+type alertHistoryQuery struct {
+	start    time.Time
+	end      time.Time
+	matchers []*labels.Matcher
+	receiver string
+	status   string
+}
+
+// parseAlertHistoryQuery parses the alert history query from the request parameters:
+//   - start and end: time range of the entries, as a RFC3339 or Unix timestamp. Defaults to the last 24 hours.
+//   - filter: label matchers the labels of the alerts must match.
+//   - receiver: receiver of the notifications. The state transitions are not returned when set.
+//   - status: status of the alerts, firing or resolved.
+func parseAlertHistoryQuery(r *http.Request, now time.Time) (alertHistoryQuery, error) {
+	if err := r.ParseForm(); err != nil {
+		return alertHistoryQuery{}, err
+	}
+
+	q := alertHistoryQuery{
+		end:      now,
+		receiver: r.Form.Get("receiver"),
+		status:   r.Form.Get("status"),
+	}
+	if s := r.Form.Get("end"); s != "" {
+		ms, err := util.ParseTime(s)
+		if err != nil {
+			return alertHistoryQuery{}, fmt.Errorf("invalid end %q: %w", s, err)
+		}
+		q.end = util.TimeFromMillis(ms)
+	}
+	q.start = q.end.Add(-defaultAlertHistoryQueryRange)
+	if s := r.Form.Get("start"); s != "" {
+		ms, err := util.ParseTime(s)
+		if err != nil {
+			return alertHistoryQuery{}, fmt.Errorf("invalid start %q: %w", s, err)
+		}
+		q.start = util.TimeFromMillis(ms)
+	}
+	if q.end.Before(q.start) {
+		return alertHistoryQuery{}, fmt.Errorf("the end %s is before the start %s", q.end, q.start)
+	}
+
+	if q.status != "" && q.status != alertHistoryStatusFiring && q.status != alertHistoryStatusResolved {
+		return alertHistoryQuery{}, fmt.Errorf("invalid status %q", q.status)
+	}
+
+	for _, s := range r.Form["filter"] {
+		m, err := compat.Matcher(s, "api")
+		if err != nil {
+			return alertHistoryQuery{}, fmt.Errorf("invalid filter %q: %w", s, err)
+		}
+		q.matchers = append(q.matchers, m)
+	}
+
+	return q, nil
+}
+
+func (q alertHistoryQuery) overlaps(minT, maxT time.Time) bool {
+	return !maxT.Before(q.start) && !minT.After(q.end)
+}
+
+func (q alertHistoryQuery) matches(e alertspb.AlertHistoryEntry) bool {
+	ts := time.UnixMilli(e.TimestampMs)
+	if ts.Before(q.start) || ts.After(q.end) {
+		return false
+	}
+	if q.receiver != "" && e.Receiver != q.receiver {
+		return false
+	}
+	if q.status != "" && e.Status != q.status {
+		return false
+	}
+
+	for _, m := range q.matchers {
+		if !m.Matches(e.Labels[m.Name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// alertHistoryEntryKey identifies an entry of the alert history recorded by several replicas.
+type alertHistoryEntryKey struct {
+	timestampMs int64
+	fingerprint string
+	status      string
+	receiver    string
+	integration string
+	outcome     string
+	error       string
+}
+
+// GetUserAlertHistory returns the entries of the alert history of the user selected by the request, oldest first.
+func (am *MultitenantAlertmanager) GetUserAlertHistory(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), am.logger)
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", errNoOrgID, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errNoOrgID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	if !am.cfg.AlertHistory.Enabled {
+		http.Error(w, errAlertHistoryDisabled, http.StatusBadRequest)
+		return
+	}
+
+	q, err := parseAlertHistoryQuery(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := am.queryAlertHistory(r.Context(), userID, q)
+	if err != nil {
+		level.Error(logger).Log("msg", errReadingAlertHistory, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errReadingAlertHistory, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	d, err := yaml.Marshal(&UserAlertHistory{Entries: entries})
+	if err != nil {
+		level.Error(logger).Log("msg", errMarshallingYAML, "err", err, "user", userID)
+		http.Error(w, fmt.Sprintf("%s: %s", errMarshallingYAML, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	if _, err := w.Write(d); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// queryAlertHistory reads the batches of the alert history of the user overlapping the time range of the query, and
+// returns their entries selected by the query, deduplicated and sorted by time.
+func (am *MultitenantAlertmanager) queryAlertHistory(ctx context.Context, userID string, q alertHistoryQuery) ([]AlertHistoryEntry, error) {
+	names, err := am.store.ListAlertHistory(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []string
+	for _, name := range names {
+		if minT, maxT, ok := ParseAlertHistoryBatchName(name); ok && q.overlaps(minT, maxT) {
+			selected = append(selected, name)
+		}
+	}
+
+	var (
+		mtx     sync.Mutex
+		seen    = map[alertHistoryEntryKey]struct{}{}
+		entries = []AlertHistoryEntry{}
+	)
+	err = concurrency.ForEach(ctx, concurrency.CreateJobsFromStrings(selected), fetchConcurrency, func(ctx context.Context, job interface{}) error {
+		history, err := am.store.GetAlertHistory(ctx, userID, job.(string))
+		if err == alertspb.ErrNotFound {
+			// The batch has been deleted by the retention in the meantime.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		for _, e := range history.Entries {
+			if !q.matches(e) {
+				continue
+			}
+			key := alertHistoryEntryKey{
+				timestampMs: e.TimestampMs,
+				fingerprint: e.Fingerprint,
+				status:      e.Status,
+				receiver:    e.Receiver,
+				integration: e.Integration,
+				outcome:     e.Outcome,
+				error:       e.Error,
+			}
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+
+			entries = append(entries, AlertHistoryEntry{
+				Time:        time.UnixMilli(e.TimestampMs).UTC(),
+				Fingerprint: e.Fingerprint,
+				Labels:      e.Labels,
+				Status:      e.Status,
+				Receiver:    e.Receiver,
+				Integration: e.Integration,
+				Outcome:     e.Outcome,
+				Error:       e.Error,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].Time.Equal(entries[j].Time) {
+			return entries[i].Time.Before(entries[j].Time)
+		}
+		return entries[i].Fingerprint < entries[j].Fingerprint
+	})
+
+	return entries, nil
+}
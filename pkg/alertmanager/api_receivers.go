@@ -0,0 +1,228 @@
+package alertmanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
+	"github.com/cortexproject/cortex/pkg/alertmanager/auditlog"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+	util_net "github.com/cortexproject/cortex/pkg/util/net"
+)
+
+const (
+	errReceiverNotFound      = "receiver %s not found in the Alertmanager config"
+	errReceiverNoIntegration = "receiver %s has no integration"
+	errPreparingReceiver     = "unable to prepare the receiver"
+
+	testReceiverAlertName = "TestAlert"
+	testReceiverTimeout   = 30 * time.Second
+)
+
+// UserReceiverTestResult is the result of sending a test notification through a receiver of an user.
+type UserReceiverTestResult struct {
+	Receiver     string                        `yaml:"receiver"`
+	Integrations []UserReceiverIntegrationTest `yaml:"integrations"`
+}
+
+// UserReceiverIntegrationTest is the result of sending a test notification through an integration of a receiver.
+type UserReceiverIntegrationTest struct {
+	Integration    string  `yaml:"integration"`
+	Index          int     `yaml:"index"`
+	Outcome        string  `yaml:"outcome"`
+	StatusCode     int     `yaml:"status_code,omitempty"`
+	Error          string  `yaml:"error,omitempty"`
+	LatencySeconds float64 `yaml:"latency_seconds"`
+}
+
+// TestUserReceiver sends a test notification through every integration of a receiver of the stored Alertmanager
+// config of the user, with its templates, and returns the result of the delivery. The notification isn't retried.
+func (am *MultitenantAlertmanager) TestUserReceiver(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), am.logger)
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", errNoOrgID, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errNoOrgID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+	receiver := mux.Vars(r)["receiver"]
+
+	cfgDesc, err := am.store.GetAlertConfig(r.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, alertspb.ErrNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, alertspb.ErrAccessDenied):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	templates, err := am.store.GetTemplates(r.Context(), userID)
+	if err != nil && !errors.Is(err, alertspb.ErrNotFound) {
+		level.Error(logger).Log("msg", errReadingTemplates, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errReadingTemplates, err.Error()), http.StatusInternalServerError)
+		return
+	}
+	cfgDesc = alertspb.WithTemplates(cfgDesc, templates)
+
+	amCfg, httpConfigs, err := loadAlertmanagerConfig(cfgDesc.RawConfig)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("%s: %s", errValidatingConfig, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	var rcv *config.Receiver
+	for i := range amCfg.Receivers {
+		if amCfg.Receivers[i].Name == receiver {
+			rcv = &amCfg.Receivers[i]
+			break
+		}
+	}
+	if rcv == nil {
+		http.Error(w, fmt.Sprintf(errReceiverNotFound, receiver), http.StatusNotFound)
+		return
+	}
+
+	// The receiver is prepared like when the config is applied.
+	if err := am.transformConfig(userID, amCfg); err != nil {
+		http.Error(w, fmt.Sprintf("%s: %s", errPreparingReceiver, err.Error()), http.StatusInternalServerError)
+		return
+	}
+	if _, err := resolveReceiverSecrets(r.Context(), am.secretStore, userID, amCfg, httpConfigs); err != nil {
+		http.Error(w, fmt.Sprintf("%s: %s", errPreparingReceiver, err.Error()), http.StatusBadRequest)
+		return
+	}
+	applyReceiversTLSConfigs(amCfg, am.limits.AlertmanagerReceiversTLSConfigs(userID))
+
+	tmplDir, err := os.MkdirTemp("", "test-receiver-"+userID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("%s: %s", errPreparingReceiver, err.Error()), http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(tmplDir)
+
+	tmpl, err := loadUserTemplates(tmplDir, cfgDesc, amCfg)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("%s: %s", errPreparingReceiver, err.Error()), http.StatusBadRequest)
+		return
+	}
+	tmpl.ExternalURL = am.cfg.ExternalURL.URL
+
+	firewallDialer := util_net.NewFirewallDialer(newFirewallDialerConfigProvider(userID, am.limits))
+	integrations, err := buildReceiverIntegrations(*rcv, receiverHTTPConfigs(httpConfigs, receiver), tmpl, firewallDialer, logger, func(integrationName string, notifier notify.Notifier) notify.Notifier {
+		if am.notificationAudit != nil {
+			notifier = newAuditedNotifier(notifier, am.notificationAudit, userID, integrationName, logger)
+		}
+		return notifier
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("%s: %s", errPreparingReceiver, err.Error()), http.StatusBadRequest)
+		return
+	}
+	if len(integrations) == 0 {
+		http.Error(w, fmt.Sprintf(errReceiverNoIntegration, receiver), http.StatusBadRequest)
+		return
+	}
+
+	res := UserReceiverTestResult{Receiver: receiver, Integrations: testReceiverIntegrations(r.Context(), receiver, integrations)}
+	d, err := yaml.Marshal(&res)
+	if err != nil {
+		level.Error(logger).Log("msg", errMarshallingYAML, "err", err, "user", userID)
+		http.Error(w, fmt.Sprintf("%s: %s", errMarshallingYAML, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	if _, err := w.Write(d); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// loadUserTemplates writes the template files of the user to the directory and returns the template of the config.
+func loadUserTemplates(dir string, cfgDesc alertspb.AlertConfigDesc, amCfg *config.Config) (*template.Template, error) {
+	for _, tmpl := range cfgDesc.Templates {
+		templateFilepath, err := safeTemplateFilepath(dir, tmpl.Filename)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := storeTemplateFile(templateFilepath, tmpl.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	templateFiles := make([]string, 0, len(amCfg.Templates))
+	for _, t := range amCfg.Templates {
+		templateFilepath, err := safeTemplateFilepath(dir, t)
+		if err != nil {
+			return nil, err
+		}
+		templateFiles = append(templateFiles, templateFilepath)
+	}
+	return template.FromGlobs(templateFiles)
+}
+
+// testReceiverIntegrations sends a test alert through each integration, once.
+func testReceiverIntegrations(ctx context.Context, receiver string, integrations []notify.Integration) []UserReceiverIntegrationTest {
+	now := time.Now()
+	alert := &types.Alert{
+		Alert: model.Alert{
+			Labels: model.LabelSet{
+				model.AlertNameLabel: testReceiverAlertName,
+				"receiver":           model.LabelValue(receiver),
+			},
+			Annotations: model.LabelSet{
+				"summary":     "Notification test",
+				"description": "This is a test notification sent by the Cortex Alertmanager to verify the receiver " + model.LabelValue(receiver) + ".",
+			},
+			StartsAt: now,
+		},
+		UpdatedAt: now,
+	}
+	groupLabels := model.LabelSet{model.AlertNameLabel: testReceiverAlertName}
+
+	ctx = notify.WithGroupKey(ctx, fmt.Sprintf("{}/test:%s", groupLabels))
+	ctx = notify.WithGroupLabels(ctx, groupLabels)
+	ctx = notify.WithReceiverName(ctx, receiver)
+	ctx = notify.WithNow(ctx, now)
+	ctx = notify.WithRepeatInterval(ctx, time.Hour)
+
+	results := make([]UserReceiverIntegrationTest, 0, len(integrations))
+	for _, integration := range integrations {
+		res := UserReceiverIntegrationTest{
+			Integration: integration.Name(),
+			Index:       integration.Index(),
+			Outcome:     auditlog.OutcomeSuccess,
+		}
+
+		notifyCtx, cancel := context.WithTimeout(ctx, testReceiverTimeout)
+		start := time.Now()
+		_, err := integration.Notify(notifyCtx, alert)
+		res.LatencySeconds = time.Since(start).Seconds()
+		cancel()
+
+		if err != nil {
+			res.Outcome = auditlog.OutcomeFailure
+			res.Error = err.Error()
+			res.StatusCode = notificationStatusCode(err)
+		}
+		results = append(results, res)
+	}
+	return results
+}
@@ -0,0 +1,106 @@
+package alertmanager
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
+	"github.com/cortexproject/cortex/pkg/alertmanager/auditlog"
+)
+
+func TestMultitenantAlertmanager_TestUserReceiver(t *testing.T) {
+	ctx := context.Background()
+
+	slackTexts := make(chan string, 1)
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			Attachments []struct {
+				Text string `json:"text"`
+			} `json:"attachments"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+		slackTexts <- msg.Attachments[0].Text
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer slack.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	store := prepareInMemoryAlertStore()
+	require.NoError(t, store.SetAlertConfig(ctx, alertspb.AlertConfigDesc{
+		User: "user-1",
+		RawConfig: `
+route:
+  receiver: team-a
+receivers:
+  - name: team-a
+    slack_configs:
+      - api_url: ` + slack.URL + `
+        channel: alerts
+        text: '{{ template "test.text" . }}'
+    webhook_configs:
+      - url: ` + failing.URL + `
+  - name: empty
+templates: [test.tmpl]
+`,
+	}))
+	require.NoError(t, store.SetTemplates(ctx, alertspb.TemplatesDesc{
+		User:      "user-1",
+		Templates: []*alertspb.TemplateDesc{{Filename: "test.tmpl", Body: `{{ define "test.text" }}test of {{ .Receiver }}: {{ .CommonAnnotations.summary }}{{ end }}`}},
+	}))
+
+	audit := &recordingAuditSink{}
+	am, err := createMultitenantAlertmanager(mockAlertmanagerConfig(t), nil, nil, store, nil, &mockAlertManagerLimits{}, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+	am.notificationAudit = audit
+
+	post := func(userID, receiver string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/alerts/receivers/"+receiver+"/test", nil)
+		req = mux.SetURLVars(req.WithContext(user.InjectOrgID(req.Context(), userID)), map[string]string{"receiver": receiver})
+		rec := httptest.NewRecorder()
+		am.TestUserReceiver(rec, req)
+		return rec
+	}
+
+	rec := post("user-1", "team-a")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var res UserReceiverTestResult
+	require.NoError(t, yaml.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "team-a", res.Receiver)
+	require.Len(t, res.Integrations, 2)
+
+	// The test notification is rendered with the templates of the user.
+	assert.Equal(t, "test of team-a: Notification test", <-slackTexts)
+
+	assert.Equal(t, "webhook", res.Integrations[0].Integration)
+	assert.Equal(t, auditlog.OutcomeFailure, res.Integrations[0].Outcome)
+	assert.Equal(t, http.StatusServiceUnavailable, res.Integrations[0].StatusCode)
+	assert.Contains(t, res.Integrations[0].Error, "unexpected status code 503")
+
+	assert.Equal(t, "slack", res.Integrations[1].Integration)
+	assert.Equal(t, auditlog.OutcomeSuccess, res.Integrations[1].Outcome)
+	assert.Empty(t, res.Integrations[1].Error)
+
+	// The test notifications are recorded to the notification audit log.
+	require.Len(t, audit.entries, 2)
+	assert.Equal(t, "team-a", audit.entries[0].Receiver)
+
+	assert.Equal(t, http.StatusNotFound, post("user-1", "missing").Code)
+	assert.Equal(t, http.StatusBadRequest, post("user-1", "empty").Code)
+	assert.Equal(t, http.StatusNotFound, post("user-2", "team-a").Code)
+}
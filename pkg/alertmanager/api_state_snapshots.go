@@ -0,0 +1,204 @@
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/matttproud/golang_protobuf_extensions/pbutil"
+	"github.com/pkg/errors"
+	"github.com/prometheus/alertmanager/cluster/clusterpb"
+	"github.com/prometheus/alertmanager/silence/silencepb"
+	"github.com/weaveworks/common/user"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertmanagerpb"
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+)
+
+const (
+	errListingSnapshots     = "unable to list the Alertmanager state snapshots"
+	errRestoringSnapshot    = "unable to restore the Alertmanager state snapshot"
+	errSnapshotNotFound     = "state snapshot %s not found"
+	errSnapshotsNotSharding = "Alertmanager state snapshots are only available when sharding is enabled"
+
+	silencesStateKeyPrefix = "sil:"
+)
+
+// StateSnapshot describes a snapshot of the alertmanager state of an user.
+type StateSnapshot struct {
+	Name string    `yaml:"name"`
+	Time time.Time `yaml:"time"`
+}
+
+// UserStateSnapshots is used to communicate the snapshots of the alertmanager state of an user.
+type UserStateSnapshots struct {
+	Snapshots []StateSnapshot `yaml:"snapshots"`
+}
+
+// ListUserStateSnapshots returns the snapshots of the alertmanager state of the user, oldest first.
+func (am *MultitenantAlertmanager) ListUserStateSnapshots(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), am.logger)
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", errNoOrgID, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errNoOrgID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	if !am.cfg.ShardingEnabled {
+		http.Error(w, errSnapshotsNotSharding, http.StatusBadRequest)
+		return
+	}
+
+	names, err := am.store.ListFullStateSnapshots(r.Context(), userID)
+	if err != nil {
+		level.Error(logger).Log("msg", errListingSnapshots, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errListingSnapshots, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	snapshots := UserStateSnapshots{Snapshots: make([]StateSnapshot, 0, len(names))}
+	for _, name := range names {
+		if ts, ok := ParseStateSnapshotName(name); ok {
+			snapshots.Snapshots = append(snapshots.Snapshots, StateSnapshot{Name: name, Time: ts.UTC()})
+		}
+	}
+	sort.Slice(snapshots.Snapshots, func(i, j int) bool {
+		return snapshots.Snapshots[i].Time.Before(snapshots.Snapshots[j].Time)
+	})
+
+	d, err := yaml.Marshal(&snapshots)
+	if err != nil {
+		level.Error(logger).Log("msg", errMarshallingYAML, "err", err, "user", userID)
+		http.Error(w, fmt.Sprintf("%s: %s", errMarshallingYAML, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	if _, err := w.Write(d); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// RestoreUserStateSnapshot restores the alertmanager state of the user to the snapshot given by the
+// "snapshot" parameter. The silences of the snapshot replace their current version, while the silences
+// created after the snapshot and the notification log entries newer than the snapshot are kept.
+func (am *MultitenantAlertmanager) RestoreUserStateSnapshot(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), am.logger)
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", errNoOrgID, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errNoOrgID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	if !am.cfg.ShardingEnabled {
+		http.Error(w, errSnapshotsNotSharding, http.StatusBadRequest)
+		return
+	}
+
+	name := r.FormValue("snapshot")
+	snapshot, err := am.store.GetFullStateSnapshot(r.Context(), userID, name)
+	switch {
+	case errors.Is(err, alertspb.ErrNotFound):
+		http.Error(w, fmt.Sprintf(errSnapshotNotFound, name), http.StatusNotFound)
+		return
+	case errors.Is(err, alertspb.ErrAccessDenied):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case err != nil:
+		level.Error(logger).Log("msg", errRestoringSnapshot, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errRestoringSnapshot, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	if err := am.restoreStateSnapshot(r.Context(), userID, snapshot.State, time.Now()); err != nil {
+		level.Error(logger).Log("msg", errRestoringSnapshot, "snapshot", name, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errRestoringSnapshot, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	level.Info(logger).Log("msg", "restored alertmanager state snapshot", "user", userID, "snapshot", name)
+	w.WriteHeader(http.StatusOK)
+}
+
+// restoreStateSnapshot merges the snapshot of the alertmanager state of the user into the state of its replicas.
+func (am *MultitenantAlertmanager) restoreStateSnapshot(ctx context.Context, userID string, fs *clusterpb.FullState, now time.Time) error {
+	if fs == nil {
+		return nil
+	}
+
+	parts := make([]clusterpb.Part, 0, len(fs.Parts))
+	for _, part := range fs.Parts {
+		if strings.HasPrefix(part.Key, silencesStateKeyPrefix) {
+			data, err := touchSilences(part.Data, now)
+			if err != nil {
+				return errors.Wrap(err, "failed to decode the silences of the snapshot")
+			}
+			part.Data = data
+		}
+		parts = append(parts, part)
+	}
+
+	ctx = user.InjectOrgID(ctx, userID)
+	selfAddress := am.ringLifecycler.GetInstanceAddr()
+	return ring.DoBatch(ctx, RingOp, am.ring, []uint32{shardByUser(userID)}, func(desc ring.InstanceDesc, _ []int) error {
+		for i := range parts {
+			var (
+				resp *alertmanagerpb.UpdateStateResponse
+				err  error
+			)
+			if desc.GetAddr() == selfAddress {
+				resp, err = am.UpdateState(ctx, &parts[i])
+			} else {
+				var c Client
+				if c, err = am.alertmanagerClientsPool.GetClientFor(desc.GetAddr()); err == nil {
+					resp, err = c.UpdateState(ctx, &parts[i])
+				}
+			}
+			if err != nil {
+				return err
+			}
+			if resp.Status != alertmanagerpb.OK {
+				return fmt.Errorf("failed to merge the state %s into the replica %s: %s", parts[i].Key, desc.GetAddr(), resp.Error)
+			}
+		}
+		return nil
+	}, func() {})
+}
+
+// touchSilences sets the update time of the encoded silences, for them to take precedence over their
+// current version when merged into the alertmanager state.
+func touchSilences(data []byte, now time.Time) ([]byte, error) {
+	var (
+		r   = bytes.NewReader(data)
+		buf bytes.Buffer
+	)
+	for {
+		var s silencepb.MeshSilence
+		if _, err := pbutil.ReadDelimited(r, &s); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		if s.Silence != nil {
+			s.Silence.UpdatedAt = now.UTC()
+		}
+		if _, err := pbutil.WriteDelimited(&buf, &s); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,278 @@
+package alertmanager
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/prometheus/alertmanager/template"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+)
+
+const (
+	errReadingTemplates  = "unable to read the Alertmanager template files"
+	errReadingTemplate   = "unable to read the Alertmanager template file"
+	errStoringTemplates  = "unable to store the Alertmanager template files"
+	errDeletingTemplates = "unable to delete the Alertmanager template files"
+	errValidatingTmpl    = "error validating Alertmanager template file"
+	errTemplateNotFound  = "template %s not found"
+	errTemplateInConfig  = "template %s is already a template file of the Alertmanager config"
+)
+
+// UserTemplates is used to communicate the template files of an user stored apart from its alertmanager config.
+type UserTemplates struct {
+	TemplateFiles map[string]string `yaml:"template_files"`
+}
+
+// ListUserTemplates returns the template files of the user stored apart from its alertmanager config.
+func (am *MultitenantAlertmanager) ListUserTemplates(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), am.logger)
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", errNoOrgID, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errNoOrgID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	templates, ok := am.getStoredTemplates(w, r, userID)
+	if !ok {
+		return
+	}
+
+	d, err := yaml.Marshal(&UserTemplates{
+		TemplateFiles: alertspb.ParseTemplates(alertspb.AlertConfigDesc{Templates: templates.Templates}),
+	})
+	if err != nil {
+		level.Error(logger).Log("msg", errMarshallingYAML, "err", err, "user", userID)
+		http.Error(w, fmt.Sprintf("%s: %s", errMarshallingYAML, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	if _, err := w.Write(d); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetUserTemplate returns a template file of the user stored apart from its alertmanager config.
+func (am *MultitenantAlertmanager) GetUserTemplate(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), am.logger)
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", errNoOrgID, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errNoOrgID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	templates, ok := am.getStoredTemplates(w, r, userID)
+	if !ok {
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	for _, tmpl := range templates.Templates {
+		if tmpl.Filename == name {
+			w.Header().Set("Content-Type", "text/plain")
+			if _, err := w.Write([]byte(tmpl.Body)); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+	}
+	http.Error(w, fmt.Sprintf(errTemplateNotFound, name), http.StatusNotFound)
+}
+
+// SetUserTemplate validates and stores a template file of the user apart from its alertmanager config,
+// replacing the template file with the same name if any.
+func (am *MultitenantAlertmanager) SetUserTemplate(w http.ResponseWriter, r *http.Request) {
+	am.setUserTemplate(w, r, false)
+}
+
+// ValidateUserTemplate validates a template file of the user, as it would be stored, without storing it.
+func (am *MultitenantAlertmanager) ValidateUserTemplate(w http.ResponseWriter, r *http.Request) {
+	am.setUserTemplate(w, r, true)
+}
+
+func (am *MultitenantAlertmanager) setUserTemplate(w http.ResponseWriter, r *http.Request, validateOnly bool) {
+	logger := util_log.WithContext(r.Context(), am.logger)
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", errNoOrgID, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errNoOrgID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	var input io.Reader = r.Body
+	maxSize := am.limits.AlertmanagerMaxTemplateSize(userID)
+	if maxSize > 0 {
+		// Allow one extra byte, to check if the template is too big.
+		input = io.LimitReader(r.Body, int64(maxSize)+1)
+	}
+
+	body, err := io.ReadAll(input)
+	if err != nil {
+		level.Error(logger).Log("msg", errReadingTemplate, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errReadingTemplate, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	templates, ok := am.getStoredTemplates(w, r, userID)
+	if !ok {
+		return
+	}
+	templates.User = userID
+	templates.Templates = withTemplate(templates.Templates, &alertspb.TemplateDesc{Filename: mux.Vars(r)["name"], Body: string(body)})
+
+	// The template files are validated along with the ones of the alertmanager config.
+	cfg, err := am.store.GetAlertConfig(r.Context(), userID)
+	if err != nil && !errors.Is(err, alertspb.ErrNotFound) {
+		level.Error(logger).Log("msg", errReadingConfiguration, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errReadingConfiguration, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	if err := validateUserTemplate(cfg, templates, mux.Vars(r)["name"], am.limits, userID); err != nil {
+		level.Warn(logger).Log("msg", errValidatingTmpl, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errValidatingTmpl, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	if validateOnly {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if err := am.store.SetTemplates(r.Context(), templates); err != nil {
+		level.Error(logger).Log("msg", errStoringTemplates, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errStoringTemplates, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// DeleteUserTemplate deletes a template file of the user stored apart from its alertmanager config.
+// Note that if the template file doesn't exist, StatusOK is returned.
+func (am *MultitenantAlertmanager) DeleteUserTemplate(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), am.logger)
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", errNoOrgID, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errNoOrgID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	templates, ok := am.getStoredTemplates(w, r, userID)
+	if !ok {
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	remaining := make([]*alertspb.TemplateDesc, 0, len(templates.Templates))
+	for _, tmpl := range templates.Templates {
+		if tmpl.Filename != name {
+			remaining = append(remaining, tmpl)
+		}
+	}
+	if len(remaining) == len(templates.Templates) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if len(remaining) == 0 {
+		err = am.store.DeleteTemplates(r.Context(), userID)
+	} else {
+		err = am.store.SetTemplates(r.Context(), alertspb.TemplatesDesc{User: userID, Templates: remaining})
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", errDeletingTemplates, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errDeletingTemplates, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// getStoredTemplates returns the template files of the user stored apart from its alertmanager config,
+// or writes the error response and returns false.
+func (am *MultitenantAlertmanager) getStoredTemplates(w http.ResponseWriter, r *http.Request, userID string) (alertspb.TemplatesDesc, bool) {
+	templates, err := am.store.GetTemplates(r.Context(), userID)
+	switch {
+	case err == nil, errors.Is(err, alertspb.ErrNotFound):
+		return templates, true
+	case errors.Is(err, alertspb.ErrAccessDenied):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		level.Error(util_log.WithContext(r.Context(), am.logger)).Log("msg", errReadingTemplates, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errReadingTemplates, err.Error()), http.StatusInternalServerError)
+	}
+	return templates, false
+}
+
+// withTemplate returns the template files with the given one, replacing the template file with the same name if any.
+func withTemplate(templates []*alertspb.TemplateDesc, tmpl *alertspb.TemplateDesc) []*alertspb.TemplateDesc {
+	result := make([]*alertspb.TemplateDesc, 0, len(templates)+1)
+	for _, t := range templates {
+		if t.Filename != tmpl.Filename {
+			result = append(result, t)
+		}
+	}
+	return append(result, tmpl)
+}
+
+// validateUserTemplate validates a template file stored apart from the alertmanager config of the user,
+// given the alertmanager config and all the stored template files of the user.
+func validateUserTemplate(cfg alertspb.AlertConfigDesc, templates alertspb.TemplatesDesc, name string, limits Limits, user string) error {
+	if err := validateTemplateFilename(name); err != nil {
+		return err
+	}
+
+	// The template files of the alertmanager config would take precedence over the stored one.
+	for _, tmpl := range cfg.Templates {
+		if tmpl.Filename == name {
+			return fmt.Errorf(errTemplateInConfig, name)
+		}
+	}
+
+	// The template files of the alertmanager config count towards the limit of the templates of the user.
+	if l, count := limits.AlertmanagerMaxTemplatesCount(user), len(alertspb.WithTemplates(cfg, templates).Templates); l > 0 && count > l {
+		return fmt.Errorf(errTooManyTemplates, count, l)
+	}
+
+	var body string
+	for _, tmpl := range templates.Templates {
+		if tmpl.Filename == name {
+			body = tmpl.Body
+		}
+	}
+	if maxSize := limits.AlertmanagerMaxTemplateSize(user); maxSize > 0 && len(body) > maxSize {
+		return fmt.Errorf(errTemplateTooBig, name, len(body), maxSize)
+	}
+
+	// Parse the template file from a temporary directory, like the templates of the alertmanager configs.
+	userTempDir, err := os.MkdirTemp("", "validate-template-"+user)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(userTempDir)
+
+	templateFilepath, err := safeTemplateFilepath(userTempDir, name)
+	if err != nil {
+		return err
+	}
+	if _, err := storeTemplateFile(templateFilepath, body); err != nil {
+		return fmt.Errorf("unable to store template file '%s'", name)
+	}
+
+	_, err = template.FromGlobs([]string{templateFilepath})
+	return err
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/go-kit/log"
@@ -35,6 +36,8 @@ func TestAMConfigValidationAPI(t *testing.T) {
 		maxConfigSize   int
 		maxTemplates    int
 		maxTemplateSize int
+		allowedHosts    []string
+		blockedHosts    []string
 
 		response string
 		err      error
@@ -649,6 +652,100 @@ alertmanager_config: |
 `,
 			err: errors.Wrap(errTelegramBotTokenFileNotAllowed, "error validating Alertmanager config"),
 		},
+		{
+			name: "Should pass if the receivers destination hosts are allowed",
+			cfg: `
+alertmanager_config: |
+  receivers:
+    - name: default-receiver
+      webhook_configs:
+        - url: http://hooks.example.com/alerts
+      email_configs:
+        - to: user@example.com
+          from: alertmanager@example.com
+          smarthost: smtp.example.com:587
+  route:
+    receiver: 'default-receiver'
+`,
+			allowedHosts: []string{"example.com"},
+			blockedHosts: []string{"internal.example.com"},
+		},
+		{
+			name: "Should return error if a receiver destination host is not allowed",
+			cfg: `
+alertmanager_config: |
+  receivers:
+    - name: default-receiver
+      webhook_configs:
+        - url: http://hooks.example.com/alerts
+    - name: other-receiver
+      webhook_configs:
+        - url: http://169.254.169.254/latest/meta-data
+  route:
+    receiver: 'default-receiver'
+`,
+			allowedHosts: []string{"example.com"},
+			err:          fmt.Errorf("error validating Alertmanager config: the destination host 169.254.169.254 of the receiver other-receiver is not allowed"),
+		},
+		{
+			name: "Should return error if a receiver destination host is blocked",
+			cfg: `
+alertmanager_config: |
+  receivers:
+    - name: default-receiver
+      slack_configs:
+        - api_url: http://slack.internal.example.com/hooks
+          channel: alerts
+  route:
+    receiver: 'default-receiver'
+`,
+			blockedHosts: []string{"internal.example.com"},
+			err:          fmt.Errorf("error validating Alertmanager config: the destination host slack.internal.example.com of the receiver default-receiver is not allowed"),
+		},
+		{
+			name: "Should pass if the generic HTTP receivers are valid",
+			cfg: `
+alertmanager_config: |
+  receivers:
+    - name: default-receiver
+      http_configs:
+        - url: https://tickets.example.com/api/issues
+          body: '{"title": "{{ .CommonLabels.alertname }}"}'
+          hmac:
+            secret: hmac-secret
+  route:
+    receiver: 'default-receiver'
+`,
+		},
+		{
+			name: "Should return error if a generic HTTP receiver has a bearer_token_file",
+			cfg: `
+alertmanager_config: |
+  receivers:
+    - name: default-receiver
+      http_configs:
+        - url: https://tickets.example.com/api/issues
+          http_config:
+            bearer_token_file: /secrets
+  route:
+    receiver: 'default-receiver'
+`,
+			err: errors.Wrap(errPasswordFileNotAllowed, "error validating Alertmanager config"),
+		},
+		{
+			name: "Should return error if a generic HTTP receiver destination host is blocked",
+			cfg: `
+alertmanager_config: |
+  receivers:
+    - name: default-receiver
+      http_configs:
+        - url: https://tickets.internal.example.com/api/issues
+  route:
+    receiver: 'default-receiver'
+`,
+			blockedHosts: []string{"internal.example.com"},
+			err:          fmt.Errorf("error validating Alertmanager config: the destination host tickets.internal.example.com of the receiver default-receiver is not allowed"),
+		},
 	}
 
 	limits := &mockAlertManagerLimits{}
@@ -662,6 +759,8 @@ alertmanager_config: |
 			limits.maxConfigSize = tc.maxConfigSize
 			limits.maxTemplatesCount = tc.maxTemplates
 			limits.maxSizeOfTemplate = tc.maxTemplateSize
+			limits.receiversAllowedHosts = tc.allowedHosts
+			limits.receiversBlockedHosts = tc.blockedHosts
 
 			req := httptest.NewRequest(http.MethodPost, "http://alertmanager/api/v1/alerts", bytes.NewReader([]byte(tc.cfg)))
 			ctx := user.InjectOrgID(req.Context(), "testing")
@@ -683,6 +782,85 @@ alertmanager_config: |
 	}
 }
 
+func TestAMConfigValidationAPI_ValidateOnly(t *testing.T) {
+	am := &MultitenantAlertmanager{
+		store:  prepareInMemoryAlertStore(),
+		logger: util_log.Logger,
+		limits: &mockAlertManagerLimits{},
+	}
+
+	post := func(target, cfg string) (int, string) {
+		req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader([]byte(cfg)))
+		w := httptest.NewRecorder()
+		am.SetUserConfig(w, req.WithContext(user.InjectOrgID(req.Context(), "testing")))
+		return w.Code, w.Body.String()
+	}
+
+	// The warnings of a valid config are returned, and the config isn't stored.
+	code, body := post("http://alertmanager/api/v1/alerts?validate_only=true", `
+alertmanager_config: |
+  route:
+    receiver: default
+    routes:
+      - receiver: default
+        match:
+          team: a
+  receivers:
+    - name: default
+    - name: unused
+`)
+	require.Equal(t, http.StatusOK, code)
+	require.YAMLEq(t, `
+valid: true
+warnings:
+  - type: deprecated-field
+    location: route.routes[0]
+    message: the field match is deprecated, use matchers instead
+  - type: unused-receiver
+    location: receivers[1]
+    message: the receiver "unused" is not referenced by any route
+`, body)
+	_, err := am.store.GetAlertConfig(context.Background(), "testing")
+	require.ErrorIs(t, err, alertspb.ErrNotFound)
+
+	// The error of an invalid config is returned along with its warnings.
+	code, body = post("http://alertmanager/api/v1/alerts?validate_only=true", `
+alertmanager_config: |
+  route:
+    receiver: default
+  receivers:
+    - name: default
+      webhook_configs:
+        - url_file: /secrets/url
+    - name: unused
+`)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.YAMLEq(t, `
+valid: false
+error: setting Webhook url_file is not allowed
+warnings:
+  - type: unused-receiver
+    location: receivers[1]
+    message: the receiver "unused" is not referenced by any route
+`, body)
+
+	code, body = post("http://alertmanager/api/v1/alerts?validate_only=true", `
+alertmanager_config: |
+  route:
+    receiver: missing
+`)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.YAMLEq(t, `
+valid: false
+error: undefined receiver "missing" used in route
+warnings: []
+`, body)
+
+	code, body = post("http://alertmanager/api/v1/alerts?validate_only=maybe", "")
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, "invalid validate_only parameter \"maybe\"\n", body)
+}
+
 func TestMultitenantAlertmanager_DeleteUserConfig(t *testing.T) {
 	storage := objstore.NewInMemBucket()
 	alertStore := bucketclient.NewBucketAlertStore(storage, nil, log.NewNopLogger())
@@ -728,6 +906,90 @@ func TestMultitenantAlertmanager_DeleteUserConfig(t *testing.T) {
 	}
 }
 
+func TestMultitenantAlertmanager_UserTemplates(t *testing.T) {
+	storage := objstore.NewInMemBucket()
+	alertStore := bucketclient.NewBucketAlertStore(storage, nil, log.NewNopLogger())
+
+	am := &MultitenantAlertmanager{
+		store:  alertStore,
+		logger: util_log.Logger,
+		limits: &mockAlertManagerLimits{maxTemplatesCount: 2, maxSizeOfTemplate: 100},
+	}
+
+	ctx := user.InjectOrgID(context.Background(), "test_user")
+	require.NoError(t, alertStore.SetAlertConfig(ctx, alertspb.AlertConfigDesc{
+		User:      "test_user",
+		RawConfig: "config",
+		Templates: []*alertspb.TemplateDesc{{Filename: "inline.tmpl", Body: "inline"}},
+	}))
+
+	do := func(handler http.HandlerFunc, method, name, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/alerts/templates/"+name, bytes.NewBufferString(body)).WithContext(ctx)
+		req = mux.SetURLVars(req, map[string]string{"name": name})
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	// Invalid template files are rejected, and aren't stored.
+	for name, body := range map[string]string{
+		"invalid.tmpl":    `{{ define "slack.title" }}`,
+		"../path.tmpl":    `{{ define "slack.title" }}{{ end }}`,
+		"inline.tmpl":     `{{ define "slack.title" }}{{ end }}`,
+		"too-big.tmpl":    strings.Repeat("a", 101),
+		"validating.tmpl": `{{ .Unterminated `,
+	} {
+		rec := do(am.SetUserTemplate, http.MethodPost, name, body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, name)
+	}
+	_, err := alertStore.GetTemplates(ctx, "test_user")
+	require.Equal(t, alertspb.ErrNotFound, err)
+
+	// Valid template files are validated without being stored.
+	rec := do(am.ValidateUserTemplate, http.MethodPost, "slack.tmpl", `{{ define "slack.title" }}{{ .Status }}{{ end }}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	_, err = alertStore.GetTemplates(ctx, "test_user")
+	require.Equal(t, alertspb.ErrNotFound, err)
+
+	// Valid template files are stored.
+	rec = do(am.SetUserTemplate, http.MethodPost, "slack.tmpl", `{{ define "slack.title" }}{{ .Status }}{{ end }}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = do(am.GetUserTemplate, http.MethodGet, "slack.tmpl", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{{ define "slack.title" }}{{ .Status }}{{ end }}`, rec.Body.String())
+
+	rec = do(am.GetUserTemplate, http.MethodGet, "missing.tmpl", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	// The template files of the config count towards the limit of the templates.
+	rec = do(am.SetUserTemplate, http.MethodPost, "email.tmpl", `{{ define "email.subject" }}{{ .Status }}{{ end }}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "too many templates in the configuration: 3 (limit: 2)")
+
+	// Replacing a stored template file doesn't count towards the limit.
+	rec = do(am.SetUserTemplate, http.MethodPost, "slack.tmpl", `{{ define "slack.title" }}{{ .CommonLabels.alertname }}{{ end }}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/alerts/templates", nil).WithContext(ctx)
+	rec = httptest.NewRecorder()
+	am.ListUserTemplates(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	templates := UserTemplates{}
+	require.NoError(t, yaml.Unmarshal(rec.Body.Bytes(), &templates))
+	assert.Equal(t, map[string]string{"slack.tmpl": `{{ define "slack.title" }}{{ .CommonLabels.alertname }}{{ end }}`}, templates.TemplateFiles)
+
+	// The deleted template files aren't stored anymore.
+	rec = do(am.DeleteUserTemplate, http.MethodDelete, "slack.tmpl", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	_, err = alertStore.GetTemplates(ctx, "test_user")
+	require.Equal(t, alertspb.ErrNotFound, err)
+
+	// Repeating the request still reports 200
+	rec = do(am.DeleteUserTemplate, http.MethodDelete, "slack.tmpl", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
 func TestAMConfigListUserConfig(t *testing.T) {
 	testCases := map[string]*UserConfig{
 		"user1": {
@@ -0,0 +1,65 @@
+package auditlog
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+const (
+	// LogSink writes the entries to the log of the Alertmanager.
+	LogSink = "log"
+
+	// FileSink appends the entries to a file, one JSON object per line.
+	FileSink = "file"
+)
+
+var (
+	supportedSinks = []string{LogSink, FileSink}
+
+	errUnsupportedSink = errors.New("unsupported notification audit log sink")
+	errMissingFilePath = errors.New("the file notification audit log sink requires a path")
+)
+
+// Config configures the audit log of the notification attempts.
+type Config struct {
+	Sinks flagext.StringSliceCSV `yaml:"sinks"`
+
+	File FileConfig `yaml:"file"`
+}
+
+// FileConfig configures the file sink.
+type FileConfig struct {
+	Path string `yaml:"path"`
+}
+
+// RegisterFlagsWithPrefix registers the flags of the notification audit log with the given prefix.
+func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.Var(&cfg.Sinks, prefix+".sinks", fmt.Sprintf("[Experimental] Comma separated list of sinks of the audit log recording every notification attempt of the tenants. Supported values are: %s. The audit log is disabled when empty.", strings.Join(supportedSinks, ", ")))
+	f.StringVar(&cfg.File.Path, prefix+".file.path", "", "[Experimental] Path of the file the entries of the audit log are appended to, one JSON object per line.")
+}
+
+// Enabled returns whether at least a sink is configured.
+func (cfg *Config) Enabled() bool {
+	return len(cfg.Sinks) > 0
+}
+
+// Validate the config.
+func (cfg *Config) Validate() error {
+	for _, sink := range cfg.Sinks {
+		switch sink {
+		case LogSink:
+		case FileSink:
+			if cfg.File.Path == "" {
+				return errMissingFilePath
+			}
+		default:
+			return errors.Wrapf(errUnsupportedSink, "%q", sink)
+		}
+	}
+	return nil
+}
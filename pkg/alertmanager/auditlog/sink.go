@@ -0,0 +1,182 @@
+package auditlog
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/cortexproject/cortex/pkg/util/multierror"
+)
+
+const (
+	// OutcomeSuccess is the outcome of a notification attempt which succeeded.
+	OutcomeSuccess = "success"
+
+	// OutcomeFailure is the outcome of a notification attempt which failed.
+	OutcomeFailure = "failure"
+)
+
+// Entry records a notification attempt.
+type Entry struct {
+	Timestamp        time.Time `json:"timestamp"`
+	Tenant           string    `json:"tenant"`
+	Receiver         string    `json:"receiver"`
+	Integration      string    `json:"integration"`
+	GroupKey         string    `json:"group_key"`
+	GroupFingerprint string    `json:"group_fingerprint"`
+	FiringAlerts     int       `json:"firing_alerts"`
+	ResolvedAlerts   int       `json:"resolved_alerts"`
+	Outcome          string    `json:"outcome"`
+	StatusCode       int       `json:"status_code,omitempty"`
+	Error            string    `json:"error,omitempty"`
+	LatencySeconds   float64   `json:"latency_seconds"`
+	Retries          int       `json:"retries"`
+	WillRetry        bool      `json:"will_retry"`
+}
+
+// Sink records the entries of the audit log.
+type Sink interface {
+	// Write records the entry.
+	Write(e Entry) error
+
+	// Close releases the resources of the sink.
+	Close() error
+}
+
+// NewSink returns a sink writing the entries to all the configured sinks, or nil if no sink is configured.
+func NewSink(cfg Config, logger log.Logger, reg prometheus.Registerer) (Sink, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+
+	m := &multiSink{
+		writeFailures: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_alertmanager_notification_audit_log_write_failures_total",
+			Help: "Number of entries of the notification audit log failed to be written, per sink.",
+		}, []string{"sink"}),
+	}
+
+	for _, name := range cfg.Sinks {
+		var sink Sink
+		switch name {
+		case LogSink:
+			sink = NewLogSink(logger)
+		case FileSink:
+			f, err := NewFileSink(cfg.File.Path)
+			if err != nil {
+				_ = m.Close()
+				return nil, err
+			}
+			sink = f
+		default:
+			_ = m.Close()
+			return nil, errUnsupportedSink
+		}
+
+		m.names = append(m.names, name)
+		m.sinks = append(m.sinks, sink)
+		m.writeFailures.WithLabelValues(name)
+	}
+
+	return m, nil
+}
+
+type multiSink struct {
+	names []string
+	sinks []Sink
+
+	writeFailures *prometheus.CounterVec
+}
+
+func (m *multiSink) Write(e Entry) error {
+	errs := multierror.New()
+	for i, sink := range m.sinks {
+		if err := sink.Write(e); err != nil {
+			m.writeFailures.WithLabelValues(m.names[i]).Inc()
+			errs.Add(err)
+		}
+	}
+	return errs.Err()
+}
+
+func (m *multiSink) Close() error {
+	errs := multierror.New()
+	for _, sink := range m.sinks {
+		errs.Add(sink.Close())
+	}
+	return errs.Err()
+}
+
+// logSink writes the entries to a logger, to be queried with the logs of the Alertmanager.
+type logSink struct {
+	logger log.Logger
+}
+
+// NewLogSink returns a sink writing the entries to the logger.
+func NewLogSink(logger log.Logger) Sink {
+	return &logSink{logger: log.With(logger, "component", "notification-audit-log")}
+}
+
+func (s *logSink) Write(e Entry) error {
+	keyvals := []interface{}{
+		"msg", "notification attempt",
+		"tenant", e.Tenant,
+		"receiver", e.Receiver,
+		"integration", e.Integration,
+		"group_key", e.GroupKey,
+		"group_fingerprint", e.GroupFingerprint,
+		"firing_alerts", e.FiringAlerts,
+		"resolved_alerts", e.ResolvedAlerts,
+		"outcome", e.Outcome,
+		"latency", time.Duration(e.LatencySeconds * float64(time.Second)),
+		"retries", e.Retries,
+		"will_retry", e.WillRetry,
+	}
+	if e.StatusCode != 0 {
+		keyvals = append(keyvals, "status_code", e.StatusCode)
+	}
+	if e.Error != "" {
+		keyvals = append(keyvals, "err", e.Error)
+	}
+	return level.Info(s.logger).Log(keyvals...)
+}
+
+func (s *logSink) Close() error {
+	return nil
+}
+
+// fileSink appends the entries to a file, one JSON object per line, to be shipped to a log store.
+type fileSink struct {
+	mtx  sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewFileSink returns a sink appending the entries to the file at the given path, created if it doesn't exist.
+func NewFileSink(path string) (Sink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+func (s *fileSink) Write(e Entry) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.enc.Encode(e)
+}
+
+func (s *fileSink) Close() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.file.Close()
+}
@@ -0,0 +1,120 @@
+package auditlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		cfg Config
+		err error
+	}{
+		"disabled": {
+			cfg: Config{},
+		},
+		"log sink": {
+			cfg: Config{Sinks: []string{LogSink}},
+		},
+		"file sink": {
+			cfg: Config{Sinks: []string{LogSink, FileSink}, File: FileConfig{Path: "audit.log"}},
+		},
+		"file sink without path": {
+			cfg: Config{Sinks: []string{FileSink}},
+			err: errMissingFilePath,
+		},
+		"unsupported sink": {
+			cfg: Config{Sinks: []string{"unknown"}},
+			err: errUnsupportedSink,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.ErrorIs(t, tc.cfg.Validate(), tc.err)
+		})
+	}
+}
+
+func TestNewSink(t *testing.T) {
+	sink, err := NewSink(Config{}, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	assert.Nil(t, sink)
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	logs := &bytes.Buffer{}
+	reg := prometheus.NewPedanticRegistry()
+	sink, err = NewSink(Config{Sinks: []string{LogSink, FileSink}, File: FileConfig{Path: path}}, log.NewLogfmtLogger(logs), reg)
+	require.NoError(t, err)
+
+	entries := []Entry{{
+		Timestamp:        time.Unix(1700000000, 0).UTC(),
+		Tenant:           "user-1",
+		Receiver:         "team-a",
+		Integration:      "webhook",
+		GroupKey:         "{}:{alertname=\"test\"}",
+		GroupFingerprint: "fp",
+		FiringAlerts:     1,
+		Outcome:          OutcomeFailure,
+		StatusCode:       503,
+		Error:            "unexpected status code 503",
+		LatencySeconds:   0.25,
+		WillRetry:        true,
+	}, {
+		Timestamp:        time.Unix(1700000010, 0).UTC(),
+		Tenant:           "user-1",
+		Receiver:         "team-a",
+		Integration:      "webhook",
+		GroupKey:         "{}:{alertname=\"test\"}",
+		GroupFingerprint: "fp",
+		FiringAlerts:     1,
+		Outcome:          OutcomeSuccess,
+		LatencySeconds:   0.1,
+		Retries:          1,
+	}}
+	for _, e := range entries {
+		require.NoError(t, sink.Write(e))
+	}
+	require.NoError(t, sink.Close())
+
+	// The file has an entry per line.
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var written []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		written = append(written, e)
+	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, entries, written)
+
+	// The log has an entry per line too.
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `msg="notification attempt" tenant=user-1 receiver=team-a integration=webhook`)
+	assert.Contains(t, lines[0], "outcome=failure latency=250ms retries=0 will_retry=true status_code=503")
+	assert.Contains(t, lines[1], "outcome=success latency=100ms retries=1 will_retry=false")
+
+	// The failures to write the entries are counted per sink.
+	require.Error(t, sink.Write(entries[0]))
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_alertmanager_notification_audit_log_write_failures_total Number of entries of the notification audit log failed to be written, per sink.
+		# TYPE cortex_alertmanager_notification_audit_log_write_failures_total counter
+		cortex_alertmanager_notification_audit_log_write_failures_total{sink="file"} 1
+		cortex_alertmanager_notification_audit_log_write_failures_total{sink="log"} 0
+	`)))
+}
@@ -0,0 +1,185 @@
+package alertmanager
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/dispatch"
+	"github.com/prometheus/alertmanager/inhibit"
+	"github.com/prometheus/alertmanager/pkg/labels"
+)
+
+const (
+	configWarningUnreachableRoute = "unreachable-route"
+	configWarningNeverMatches     = "never-matching-matchers"
+	configWarningDeprecatedField  = "deprecated-field"
+	configWarningUnusedReceiver   = "unused-receiver"
+)
+
+// ConfigWarning is a mistake found in an Alertmanager config which doesn't prevent it from being applied.
+type ConfigWarning struct {
+	Type     string `yaml:"type"`
+	Location string `yaml:"location"`
+	Message  string `yaml:"message"`
+}
+
+// lintAlertmanagerConfig returns the warnings of a valid Alertmanager config.
+func lintAlertmanagerConfig(cfg *config.Config) []ConfigWarning {
+	var warnings []ConfigWarning
+	if cfg.Route == nil {
+		return warnings
+	}
+
+	warnings = lintRoute(warnings, cfg.Route, dispatch.NewRoute(cfg.Route, nil), "route", nil)
+
+	for i, rule := range cfg.InhibitRules {
+		location := fmt.Sprintf("inhibit_rules[%d]", i)
+		for _, field := range []struct {
+			name, replacement string
+			set               bool
+		}{
+			{name: "source_match", replacement: "source_matchers", set: len(rule.SourceMatch) > 0},
+			{name: "source_match_re", replacement: "source_matchers", set: len(rule.SourceMatchRE) > 0},
+			{name: "target_match", replacement: "target_matchers", set: len(rule.TargetMatch) > 0},
+			{name: "target_match_re", replacement: "target_matchers", set: len(rule.TargetMatchRE) > 0},
+		} {
+			if field.set {
+				warnings = append(warnings, deprecatedFieldWarning(location, field.name, field.replacement))
+			}
+		}
+
+		r := inhibit.NewInhibitRule(rule)
+		if m := neverMatchingMatchers(r.SourceMatchers); m != "" {
+			warnings = append(warnings, ConfigWarning{
+				Type:     configWarningNeverMatches,
+				Location: location,
+				Message:  fmt.Sprintf("the source matchers never match an alert: %s, so the rule never inhibits any alert", m),
+			})
+		}
+		if m := neverMatchingMatchers(r.TargetMatchers); m != "" {
+			warnings = append(warnings, ConfigWarning{
+				Type:     configWarningNeverMatches,
+				Location: location,
+				Message:  fmt.Sprintf("the target matchers never match an alert: %s, so the rule never inhibits any alert", m),
+			})
+		}
+	}
+
+	if len(cfg.MuteTimeIntervals) > 0 {
+		warnings = append(warnings, deprecatedFieldWarning("mute_time_intervals", "mute_time_intervals", "time_intervals"))
+	}
+
+	used := map[string]struct{}{}
+	collectRouteReceivers(cfg.Route, used)
+	for i, rcv := range cfg.Receivers {
+		if _, ok := used[rcv.Name]; !ok {
+			warnings = append(warnings, ConfigWarning{
+				Type:     configWarningUnusedReceiver,
+				Location: fmt.Sprintf("receivers[%d]", i),
+				Message:  fmt.Sprintf("the receiver %q is not referenced by any route", rcv.Name),
+			})
+		}
+	}
+
+	return warnings
+}
+
+// lintRoute appends the warnings of the route and its sub-routes, given the matchers of its parent routes
+// which the alerts reaching it match.
+func lintRoute(warnings []ConfigWarning, cr *config.Route, r *dispatch.Route, location string, parentMatchers labels.Matchers) []ConfigWarning {
+	if len(cr.Match) > 0 {
+		warnings = append(warnings, deprecatedFieldWarning(location, "match", "matchers"))
+	}
+	if len(cr.MatchRE) > 0 {
+		warnings = append(warnings, deprecatedFieldWarning(location, "match_re", "matchers"))
+	}
+
+	matchers := append(append(labels.Matchers{}, parentMatchers...), r.Matchers...)
+	if m := neverMatchingMatchers(matchers); m != "" {
+		// The sub-routes of the route are unreachable too, they aren't reported.
+		return append(warnings, ConfigWarning{
+			Type:     configWarningNeverMatches,
+			Location: location,
+			Message:  fmt.Sprintf("the matchers of the route and its parent routes never match an alert: %s", m),
+		})
+	}
+
+	for i, child := range r.Routes {
+		childLocation := fmt.Sprintf("%s.routes[%d]", location, i)
+
+		if j := shadowingRoute(r.Routes, i); j >= 0 {
+			warnings = append(warnings, ConfigWarning{
+				Type:     configWarningUnreachableRoute,
+				Location: childLocation,
+				Message:  fmt.Sprintf("the route is unreachable, because the alerts it matches are matched by the route %s.routes[%d] before, which doesn't continue", location, j),
+			})
+			continue
+		}
+
+		warnings = lintRoute(warnings, cr.Routes[i], child, childLocation, matchers)
+	}
+
+	return warnings
+}
+
+// shadowingRoute returns the index of the first sibling before the i-th route matching all the alerts it
+// matches and not continuing to the next siblings, or -1 if none.
+func shadowingRoute(routes []*dispatch.Route, i int) int {
+	for j := 0; j < i; j++ {
+		if !routes[j].Continue && matchersSubset(routes[j].Matchers, routes[i].Matchers) {
+			return j
+		}
+	}
+	return -1
+}
+
+// matchersSubset returns whether every matcher of a is one of b, in which case the alerts matching b match a.
+func matchersSubset(a, b labels.Matchers) bool {
+	for _, ma := range a {
+		found := false
+		for _, mb := range b {
+			if ma.String() == mb.String() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// neverMatchingMatchers returns the matchers which can't all match an alert, or an empty string if none:
+// the matchers of a label requiring a value which another matcher of the same label rejects.
+func neverMatchingMatchers(matchers labels.Matchers) string {
+	for _, eq := range matchers {
+		if eq.Type != labels.MatchEqual {
+			continue
+		}
+		for _, m := range matchers {
+			if m.Name == eq.Name && !m.Matches(eq.Value) {
+				return strings.Join([]string{eq.String(), m.String()}, ", ")
+			}
+		}
+	}
+	return ""
+}
+
+func collectRouteReceivers(r *config.Route, receivers map[string]struct{}) {
+	if r.Receiver != "" {
+		receivers[r.Receiver] = struct{}{}
+	}
+	for _, child := range r.Routes {
+		collectRouteReceivers(child, receivers)
+	}
+}
+
+func deprecatedFieldWarning(location, field, replacement string) ConfigWarning {
+	return ConfigWarning{
+		Type:     configWarningDeprecatedField,
+		Location: location,
+		Message:  fmt.Sprintf("the field %s is deprecated, use %s instead", field, replacement),
+	}
+}
@@ -0,0 +1,142 @@
+package alertmanager
+
+import (
+	"testing"
+
+	"github.com/prometheus/alertmanager/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintAlertmanagerConfig(t *testing.T) {
+	for name, tc := range map[string]struct {
+		cfg      string
+		expected []ConfigWarning
+	}{
+		"no warnings": {
+			cfg: `
+route:
+  receiver: default
+  routes:
+    - receiver: team-a
+      matchers: [team="a"]
+    - receiver: team-b
+      matchers: [team="b"]
+receivers:
+  - name: default
+  - name: team-a
+  - name: team-b
+`,
+		},
+		"unreachable routes": {
+			cfg: `
+route:
+  receiver: default
+  routes:
+    - receiver: team-a
+      matchers: [team="a"]
+    - receiver: team-a
+      matchers: [team="a", severity="critical"]
+    - receiver: team-a
+      matchers: [team="a"]
+      continue: true
+    - receiver: default
+    - receiver: team-b
+      matchers: [team="b"]
+receivers:
+  - name: default
+  - name: team-a
+  - name: team-b
+`,
+			expected: []ConfigWarning{
+				{Type: configWarningUnreachableRoute, Location: "route.routes[1]", Message: "the route is unreachable, because the alerts it matches are matched by the route route.routes[0] before, which doesn't continue"},
+				{Type: configWarningUnreachableRoute, Location: "route.routes[2]", Message: "the route is unreachable, because the alerts it matches are matched by the route route.routes[0] before, which doesn't continue"},
+				{Type: configWarningUnreachableRoute, Location: "route.routes[4]", Message: "the route is unreachable, because the alerts it matches are matched by the route route.routes[3] before, which doesn't continue"},
+			},
+		},
+		"never matching matchers": {
+			cfg: `
+route:
+  receiver: default
+  routes:
+    - receiver: default
+      matchers: [team="a"]
+      routes:
+        - receiver: default
+          matchers: [team="b"]
+          routes:
+            - receiver: default
+        - receiver: default
+          matchers: [team=~"a|b", severity="critical", severity!="critical"]
+    - receiver: default
+      matchers: [env="prod", env=~"dev.*"]
+inhibit_rules:
+  - source_matchers: [severity="critical", severity="warning"]
+    target_matchers: [severity="warning"]
+receivers:
+  - name: default
+`,
+			expected: []ConfigWarning{
+				{Type: configWarningNeverMatches, Location: "route.routes[0].routes[0]", Message: `the matchers of the route and its parent routes never match an alert: team="a", team="b"`},
+				{Type: configWarningNeverMatches, Location: "route.routes[0].routes[1]", Message: `the matchers of the route and its parent routes never match an alert: severity="critical", severity!="critical"`},
+				{Type: configWarningNeverMatches, Location: "route.routes[1]", Message: `the matchers of the route and its parent routes never match an alert: env="prod", env=~"dev.*"`},
+				{Type: configWarningNeverMatches, Location: "inhibit_rules[0]", Message: `the source matchers never match an alert: severity="critical", severity="warning", so the rule never inhibits any alert`},
+			},
+		},
+		"deprecated fields": {
+			cfg: `
+route:
+  receiver: default
+  routes:
+    - receiver: default
+      match:
+        team: a
+      match_re:
+        env: prod.*
+      mute_time_intervals: [weekends]
+inhibit_rules:
+  - source_match:
+      severity: critical
+    target_match_re:
+      severity: warning|info
+mute_time_intervals:
+  - name: weekends
+    time_intervals:
+      - weekdays: [saturday, sunday]
+receivers:
+  - name: default
+`,
+			expected: []ConfigWarning{
+				{Type: configWarningDeprecatedField, Location: "route.routes[0]", Message: "the field match is deprecated, use matchers instead"},
+				{Type: configWarningDeprecatedField, Location: "route.routes[0]", Message: "the field match_re is deprecated, use matchers instead"},
+				{Type: configWarningDeprecatedField, Location: "inhibit_rules[0]", Message: "the field source_match is deprecated, use source_matchers instead"},
+				{Type: configWarningDeprecatedField, Location: "inhibit_rules[0]", Message: "the field target_match_re is deprecated, use target_matchers instead"},
+				{Type: configWarningDeprecatedField, Location: "mute_time_intervals", Message: "the field mute_time_intervals is deprecated, use time_intervals instead"},
+			},
+		},
+		"unused receivers": {
+			cfg: `
+route:
+  receiver: default
+  routes:
+    - matchers: [team="a"]
+      routes:
+        - receiver: team-a
+          matchers: [severity="critical"]
+receivers:
+  - name: default
+  - name: team-a
+  - name: team-b
+`,
+			expected: []ConfigWarning{
+				{Type: configWarningUnusedReceiver, Location: "receivers[2]", Message: `the receiver "team-b" is not referenced by any route`},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := config.Load(tc.cfg)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, lintAlertmanagerConfig(cfg))
+		})
+	}
+}
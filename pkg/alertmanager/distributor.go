@@ -70,11 +70,11 @@ func (d *Distributor) isQuorumWritePath(p string) bool {
 }
 
 func (d *Distributor) isUnaryWritePath(p string) bool {
-	return strings.HasSuffix(p, "/silences")
+	return strings.HasSuffix(p, "/silences") || strings.HasSuffix(p, "/silences/bulk")
 }
 
 func (d *Distributor) isUnaryDeletePath(p string) bool {
-	return strings.HasSuffix(path.Dir(p), "/silence")
+	return strings.HasSuffix(path.Dir(p), "/silence") || strings.HasSuffix(p, "/silences/bulk")
 }
 
 func (d *Distributor) isQuorumReadPath(p string) (bool, merger.Merger) {
@@ -84,7 +84,7 @@ func (d *Distributor) isQuorumReadPath(p string) (bool, merger.Merger) {
 	if strings.HasSuffix(p, "/v2/alerts/groups") {
 		return true, merger.V2AlertGroups{}
 	}
-	if strings.HasSuffix(p, "/v2/silences") {
+	if strings.HasSuffix(p, "/v2/silences") || strings.HasSuffix(p, "/v2/silences/bulk") {
 		return true, merger.V2Silences{}
 	}
 	if strings.HasSuffix(path.Dir(p), "/v2/silence") {
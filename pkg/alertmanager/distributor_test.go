@@ -141,6 +141,33 @@ func TestDistributor_DistributeRequest(t *testing.T) {
 			expStatusCode:      http.StatusOK,
 			expectedTotalCalls: 1,
 			route:              "/silences",
+		}, {
+			name:               "Read /v2/silences/bulk is sent to 3 AMs",
+			numAM:              5,
+			numHappyAM:         5,
+			replicationFactor:  3,
+			isRead:             true,
+			expStatusCode:      http.StatusOK,
+			expectedTotalCalls: 3,
+			route:              "/v2/silences/bulk",
+			responseBody:       []byte(`[]`),
+		}, {
+			name:               "Write /v2/silences/bulk is sent to only 1 AM",
+			numAM:              5,
+			numHappyAM:         5,
+			replicationFactor:  3,
+			expStatusCode:      http.StatusOK,
+			expectedTotalCalls: 1,
+			route:              "/v2/silences/bulk",
+		}, {
+			name:               "Delete /v2/silences/bulk is sent to only 1 AM",
+			numAM:              5,
+			numHappyAM:         5,
+			replicationFactor:  3,
+			isDelete:           true,
+			expStatusCode:      http.StatusOK,
+			expectedTotalCalls: 1,
+			route:              "/v2/silences/bulk",
 		}, {
 			name:               "Read /v2/silence/id is sent to 3 AMs",
 			numAM:              5,
@@ -0,0 +1,57 @@
+package alertmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-kit/log/level"
+)
+
+// loadFallbackConfigs loads the named fallback configs of the directory, from the files <name>.yaml or <name>.yml.
+func loadFallbackConfigs(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := map[string]string{}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), ext)
+		if _, ok := configs[name]; ok {
+			return nil, fmt.Errorf("duplicate fallback config %q", name)
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, _, err := loadAlertmanagerConfig(string(content)); err != nil {
+			return nil, fmt.Errorf("invalid fallback config %q: %s", path, err)
+		}
+		configs[name] = string(content)
+	}
+	return configs, nil
+}
+
+// fallbackConfigFor returns the name and the content of the fallback config of the user: the named fallback config
+// selected by its limits, or the default fallback config with an empty name. The content is empty if the user has
+// no fallback config.
+func (am *MultitenantAlertmanager) fallbackConfigFor(userID string) (string, string) {
+	if am.limits != nil {
+		if name := am.limits.AlertmanagerFallbackConfigName(userID); name != "" {
+			if cfg, ok := am.fallbackConfigs[name]; ok {
+				return name, cfg
+			}
+			level.Warn(am.logger).Log("msg", "the fallback config of the user doesn't exist, using the default fallback config", "user", userID, "fallback_config", name)
+		}
+	}
+	return "", am.fallbackConfig
+}
@@ -0,0 +1,326 @@
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	tmpltext "text/template"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	amconfig "github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/notify/webhook"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/alertmanager/types"
+	commoncfg "github.com/prometheus/common/config"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// httpConfigsField is the field of the receivers of an Alertmanager configuration configuring generic HTTP
+	// receivers, which is a Cortex extension of the Alertmanager configuration.
+	httpConfigsField = "http_configs"
+
+	httpReceiverIntegration = "http"
+
+	defaultHTTPReceiverSignatureHeader = "X-Signature"
+)
+
+// HTTPReceiverConfig configures a generic HTTP receiver, sending the notifications with a templated request.
+type HTTPReceiverConfig struct {
+	amconfig.NotifierConfig `yaml:",inline"`
+
+	// Receiver is the name of the receiver of the config, set when the configuration is loaded.
+	Receiver string `yaml:"-"`
+
+	HTTPConfig *commoncfg.HTTPClientConfig `yaml:"http_config,omitempty"`
+
+	URL    *amconfig.SecretURL `yaml:"url"`
+	Method string              `yaml:"method,omitempty"`
+	// Headers and Body are templates. The body defaults to the JSON message of the webhook receiver.
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Body    string            `yaml:"body,omitempty"`
+
+	HMAC *HTTPReceiverHMACConfig `yaml:"hmac,omitempty"`
+}
+
+// HTTPReceiverHMACConfig configures the HMAC signature of the requests of a generic HTTP receiver.
+type HTTPReceiverHMACConfig struct {
+	Secret    amconfig.Secret `yaml:"secret"`
+	Algorithm string          `yaml:"algorithm,omitempty"`
+	Header    string          `yaml:"header,omitempty"`
+	// TimestampHeader is the header of the timestamp of the request, signed along with the body as <timestamp>.<body>
+	// to prevent the replay of the requests. The timestamp isn't sent if empty.
+	TimestampHeader string `yaml:"timestamp_header,omitempty"`
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (c *HTTPReceiverConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = HTTPReceiverConfig{NotifierConfig: amconfig.NotifierConfig{VSendResolved: true}, Method: http.MethodPost}
+	type plain HTTPReceiverConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if c.URL == nil {
+		return fmt.Errorf("missing url in http config")
+	}
+	switch c.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return fmt.Errorf("unsupported method %q in http config, supported methods are POST, PUT and PATCH", c.Method)
+	}
+	if err := validateHTTPReceiverTemplate(c.Body); err != nil {
+		return fmt.Errorf("invalid body template in http config: %w", err)
+	}
+	for name, value := range c.Headers {
+		if err := validateHTTPReceiverTemplate(value); err != nil {
+			return fmt.Errorf("invalid template of the header %q in http config: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// validateHTTPReceiverTemplate checks that the template can be parsed. The templates it references are only
+// resolved when it's executed.
+func validateHTTPReceiverTemplate(text string) error {
+	_, err := tmpltext.New("").Funcs(tmpltext.FuncMap(template.DefaultFuncs)).Parse(text)
+	return err
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (c *HTTPReceiverHMACConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = HTTPReceiverHMACConfig{Algorithm: "sha256", Header: defaultHTTPReceiverSignatureHeader}
+	type plain HTTPReceiverHMACConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if c.Secret == "" {
+		return fmt.Errorf("missing secret in hmac config")
+	}
+	if _, err := hmacHashFunc(c.Algorithm); err != nil {
+		return err
+	}
+	return nil
+}
+
+// hmacHashFunc returns the hash function of the HMAC algorithm.
+func hmacHashFunc(algorithm string) (func() hash.Hash, error) {
+	switch algorithm {
+	case "sha1":
+		return sha1.New, nil
+	case "sha256":
+		return sha256.New, nil
+	case "sha512":
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported hmac algorithm %q, supported algorithms are sha1, sha256 and sha512", algorithm)
+	}
+}
+
+// loadAlertmanagerConfig loads an Alertmanager configuration along with the generic HTTP receivers configured in the
+// http_configs of its receivers, which are removed from the configuration before it's loaded by the Alertmanager.
+func loadAlertmanagerConfig(rawCfg string) (*amconfig.Config, []*HTTPReceiverConfig, error) {
+	if !strings.Contains(rawCfg, httpConfigsField) {
+		cfg, err := amconfig.Load(rawCfg)
+		return cfg, nil, err
+	}
+
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal([]byte(rawCfg), &doc); err != nil {
+		return nil, nil, err
+	}
+
+	var httpConfigs []*HTTPReceiverConfig
+	for _, item := range doc {
+		// The invalid receivers are reported when the configuration is loaded.
+		receivers, ok := item.Value.([]interface{})
+		if item.Key != "receivers" || !ok {
+			continue
+		}
+
+		for i, r := range receivers {
+			receiver, ok := r.(yaml.MapSlice)
+			if !ok {
+				continue
+			}
+
+			var name string
+			for _, field := range receiver {
+				if field.Key == "name" {
+					name, _ = field.Value.(string)
+				}
+			}
+
+			fields := make(yaml.MapSlice, 0, len(receiver))
+			for _, field := range receiver {
+				if field.Key != httpConfigsField {
+					fields = append(fields, field)
+					continue
+				}
+
+				out, err := yaml.Marshal(field.Value)
+				if err != nil {
+					return nil, nil, err
+				}
+				var cfgs []*HTTPReceiverConfig
+				if err := yaml.UnmarshalStrict(out, &cfgs); err != nil {
+					return nil, nil, fmt.Errorf("invalid %s of the receiver %q: %w", httpConfigsField, name, err)
+				}
+				for _, c := range cfgs {
+					if c == nil {
+						return nil, nil, fmt.Errorf("invalid %s of the receiver %q: empty http config", httpConfigsField, name)
+					}
+					c.Receiver = name
+				}
+				httpConfigs = append(httpConfigs, cfgs...)
+			}
+			receivers[i] = fields
+		}
+	}
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg, err := amconfig.Load(string(out))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, c := range httpConfigs {
+		if c.HTTPConfig == nil {
+			c.HTTPConfig = cfg.Global.HTTPConfig
+		}
+	}
+	return cfg, httpConfigs, nil
+}
+
+// receiverHTTPConfigs returns the generic HTTP receivers of the receiver.
+func receiverHTTPConfigs(httpConfigs []*HTTPReceiverConfig, receiver string) []*HTTPReceiverConfig {
+	var cfgs []*HTTPReceiverConfig
+	for _, c := range httpConfigs {
+		if c.Receiver == receiver {
+			cfgs = append(cfgs, c)
+		}
+	}
+	return cfgs
+}
+
+// httpReceiverNotifier sends the notifications of a generic HTTP receiver.
+type httpReceiverNotifier struct {
+	conf    *HTTPReceiverConfig
+	tmpl    *template.Template
+	logger  log.Logger
+	client  *http.Client
+	retrier *notify.Retrier
+}
+
+func newHTTPReceiverNotifier(conf *HTTPReceiverConfig, tmpl *template.Template, logger log.Logger, httpOpts ...commoncfg.HTTPClientOption) (*httpReceiverNotifier, error) {
+	httpConfig := commoncfg.DefaultHTTPClientConfig
+	if conf.HTTPConfig != nil {
+		httpConfig = *conf.HTTPConfig
+	}
+
+	// The OAuth2 client credentials flow is handled by the client.
+	client, err := commoncfg.NewClientFromConfig(httpConfig, httpReceiverIntegration, httpOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &httpReceiverNotifier{
+		conf:   conf,
+		tmpl:   tmpl,
+		logger: logger,
+		client: client,
+		// Like the webhooks, the 5xx response codes are assumed to be recoverable.
+		retrier: &notify.Retrier{},
+	}, nil
+}
+
+// Notify implements notify.Notifier.
+func (n *httpReceiverNotifier) Notify(ctx context.Context, alerts ...*types.Alert) (bool, error) {
+	data := notify.GetTemplateData(ctx, n.tmpl, alerts, n.logger)
+
+	groupKey, err := notify.ExtractGroupKey(ctx)
+	if err != nil {
+		level.Error(n.logger).Log("err", err)
+	}
+
+	var body []byte
+	if n.conf.Body == "" {
+		body, err = json.Marshal(&webhook.Message{Version: "4", Data: data, GroupKey: groupKey.String()})
+	} else {
+		var s string
+		s, err = n.tmpl.ExecuteTextString(n.conf.Body, data)
+		body = []byte(s)
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to render the body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, n.conf.Method, n.conf.URL.String(), bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", notify.UserAgentHeader)
+	for name, value := range n.conf.Headers {
+		v, err := n.tmpl.ExecuteTextString(value, data)
+		if err != nil {
+			return false, fmt.Errorf("unable to render the header %q: %w", name, err)
+		}
+		req.Header.Set(name, v)
+	}
+
+	if n.conf.HMAC != nil {
+		if err := signHTTPReceiverRequest(req, body, n.conf.HMAC, time.Now()); err != nil {
+			return false, err
+		}
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, notify.RedactURL(err)
+	}
+	defer notify.Drain(resp)
+
+	shouldRetry, err := n.retrier.Check(resp.StatusCode, resp.Body)
+	if err != nil {
+		return shouldRetry, notify.NewErrorWithReason(notify.GetFailureReasonFromStatusCode(resp.StatusCode), err)
+	}
+	return shouldRetry, nil
+}
+
+// signHTTPReceiverRequest sets the HMAC signature of the body of the request, as <algorithm>=<hex signature>.
+func signHTTPReceiverRequest(req *http.Request, body []byte, cfg *HTTPReceiverHMACConfig, now time.Time) error {
+	hashFunc, err := hmacHashFunc(cfg.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	mac := hmac.New(hashFunc, []byte(cfg.Secret))
+	if cfg.TimestampHeader != "" {
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		req.Header.Set(cfg.TimestampHeader, timestamp)
+		_, _ = io.WriteString(mac, timestamp+".")
+	}
+	_, _ = mac.Write(body)
+
+	req.Header.Set(cfg.Header, cfg.Algorithm+"="+hex.EncodeToString(mac.Sum(nil)))
+	return nil
+}
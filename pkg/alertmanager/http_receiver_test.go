@@ -0,0 +1,238 @@
+package alertmanager
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadAlertmanagerConfig_HTTPConfigs(t *testing.T) {
+	cfg, httpConfigs, err := loadAlertmanagerConfig(`
+route:
+  receiver: tickets
+receivers:
+  - name: tickets
+    webhook_configs:
+      - url: http://example.com/webhook
+    http_configs:
+      - url: https://tickets.example.com/api/issues
+        method: PUT
+        headers:
+          X-Project: '{{ .CommonLabels.project }}'
+        body: '{"title": "{{ .CommonLabels.alertname }}"}'
+        hmac:
+          secret: hmac-secret
+  - name: default
+    http_configs:
+      - url: https://example.com/alerts
+        send_resolved: false
+`)
+	require.NoError(t, err)
+
+	// The generic HTTP receivers are removed from the configuration.
+	require.Len(t, cfg.Receivers, 2)
+	assert.Len(t, cfg.Receivers[0].WebhookConfigs, 1)
+	assert.NotContains(t, cfg.String(), httpConfigsField)
+
+	require.Len(t, httpConfigs, 2)
+	assert.Equal(t, "tickets", httpConfigs[0].Receiver)
+	assert.Equal(t, http.MethodPut, httpConfigs[0].Method)
+	assert.Equal(t, map[string]string{"X-Project": "{{ .CommonLabels.project }}"}, httpConfigs[0].Headers)
+	assert.True(t, httpConfigs[0].SendResolved())
+	assert.Equal(t, "sha256", httpConfigs[0].HMAC.Algorithm)
+	assert.Equal(t, defaultHTTPReceiverSignatureHeader, httpConfigs[0].HMAC.Header)
+	assert.Same(t, cfg.Global.HTTPConfig, httpConfigs[0].HTTPConfig)
+
+	assert.Equal(t, "default", httpConfigs[1].Receiver)
+	assert.Equal(t, http.MethodPost, httpConfigs[1].Method)
+	assert.False(t, httpConfigs[1].SendResolved())
+
+	assert.Equal(t, httpConfigs[:1], receiverHTTPConfigs(httpConfigs, "tickets"))
+}
+
+func TestLoadAlertmanagerConfig_InvalidHTTPConfigs(t *testing.T) {
+	for name, tc := range map[string]struct {
+		httpConfig string
+		expected   string
+	}{
+		"missing url": {
+			httpConfig: `method: POST`,
+			expected:   "missing url in http config",
+		},
+		"unsupported scheme": {
+			httpConfig: `url: ftp://example.com`,
+			expected:   `unsupported scheme "ftp" for URL`,
+		},
+		"unsupported method": {
+			httpConfig: "url: http://example.com\n        method: GET",
+			expected:   `unsupported method "GET" in http config`,
+		},
+		"invalid body template": {
+			httpConfig: "url: http://example.com\n        body: '{{ .Status'",
+			expected:   "invalid body template in http config",
+		},
+		"invalid header template": {
+			httpConfig: "url: http://example.com\n        headers:\n          X-Status: '{{ .Status'",
+			expected:   `invalid template of the header "X-Status" in http config`,
+		},
+		"missing hmac secret": {
+			httpConfig: "url: http://example.com\n        hmac:\n          algorithm: sha512",
+			expected:   "missing secret in hmac config",
+		},
+		"unsupported hmac algorithm": {
+			httpConfig: "url: http://example.com\n        hmac:\n          secret: s\n          algorithm: md5",
+			expected:   `unsupported hmac algorithm "md5"`,
+		},
+		"unknown field": {
+			httpConfig: "url: http://example.com\n        unknown: true",
+			expected:   "field unknown not found",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := loadAlertmanagerConfig(`
+route:
+  receiver: tickets
+receivers:
+  - name: tickets
+    http_configs:
+      - ` + tc.httpConfig + `
+`)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), `invalid http_configs of the receiver "tickets"`)
+			assert.Contains(t, err.Error(), tc.expected)
+		})
+	}
+}
+
+func TestHTTPReceiverNotifier(t *testing.T) {
+	type request struct {
+		method  string
+		path    string
+		headers http.Header
+		body    string
+	}
+
+	requests := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth2/token":
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token":"oauth2-token","token_type":"Bearer","expires_in":3600}`))
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			body, _ := io.ReadAll(r.Body)
+			requests <- request{method: r.Method, path: r.URL.Path, headers: r.Header, body: string(body)}
+		}
+	}))
+	defer server.Close()
+
+	_, httpConfigs, err := loadAlertmanagerConfig(`
+route:
+  receiver: tickets
+receivers:
+  - name: tickets
+    http_configs:
+      - url: ` + server.URL + `/api/issues
+        method: PUT
+        headers:
+          X-Project: '{{ .CommonLabels.project }}'
+        body: '{"title": "{{ .CommonLabels.alertname }}", "status": "{{ .Status }}"}'
+        http_config:
+          oauth2:
+            client_id: client
+            client_secret: secret
+            token_url: ` + server.URL + `/oauth2/token
+        hmac:
+          secret: hmac-secret
+          timestamp_header: X-Timestamp
+      - url: ` + server.URL + `/webhook
+      - url: ` + server.URL + `/unavailable
+`)
+	require.NoError(t, err)
+	require.Len(t, httpConfigs, 3)
+
+	tmpl, err := template.FromGlobs(nil)
+	require.NoError(t, err)
+	tmpl.ExternalURL, _ = url.Parse("http://localhost/alertmanager")
+
+	ctx := notify.WithGroupKey(context.Background(), "{}:{alertname=\"HighErrorRate\"}")
+	ctx = notify.WithReceiverName(ctx, "tickets")
+	ctx = notify.WithGroupLabels(ctx, model.LabelSet{"alertname": "HighErrorRate"})
+	alert := &types.Alert{Alert: model.Alert{
+		Labels:   model.LabelSet{"alertname": "HighErrorRate", "project": "API"},
+		StartsAt: time.Now(),
+	}}
+
+	t.Run("templated request", func(t *testing.T) {
+		n, err := newHTTPReceiverNotifier(httpConfigs[0], tmpl, log.NewNopLogger())
+		require.NoError(t, err)
+
+		retry, err := n.Notify(ctx, alert)
+		require.NoError(t, err)
+		assert.False(t, retry)
+
+		req := <-requests
+		assert.Equal(t, http.MethodPut, req.method)
+		assert.Equal(t, "/api/issues", req.path)
+		assert.Equal(t, `{"title": "HighErrorRate", "status": "firing"}`, req.body)
+		assert.Equal(t, "API", req.headers.Get("X-Project"))
+		assert.Equal(t, "application/json", req.headers.Get("Content-Type"))
+		assert.Equal(t, "Bearer oauth2-token", req.headers.Get("Authorization"))
+
+		// The timestamp is signed along with the body.
+		mac := hmac.New(sha256.New, []byte("hmac-secret"))
+		_, _ = mac.Write([]byte(req.headers.Get("X-Timestamp") + "." + req.body))
+		assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), req.headers.Get(defaultHTTPReceiverSignatureHeader))
+	})
+
+	t.Run("default body", func(t *testing.T) {
+		n, err := newHTTPReceiverNotifier(httpConfigs[1], tmpl, log.NewNopLogger())
+		require.NoError(t, err)
+
+		_, err = n.Notify(ctx, alert)
+		require.NoError(t, err)
+
+		req := <-requests
+		assert.Equal(t, http.MethodPost, req.method)
+		assert.Empty(t, req.headers.Get(defaultHTTPReceiverSignatureHeader))
+
+		var msg struct {
+			Version  string `json:"version"`
+			GroupKey string `json:"groupKey"`
+			Receiver string `json:"receiver"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(req.body), &msg))
+		assert.Equal(t, "4", msg.Version)
+		assert.Equal(t, "{}:{alertname=\"HighErrorRate\"}", msg.GroupKey)
+		assert.Equal(t, "tickets", msg.Receiver)
+	})
+
+	t.Run("recoverable failure", func(t *testing.T) {
+		n, err := newHTTPReceiverNotifier(httpConfigs[2], tmpl, log.NewNopLogger())
+		require.NoError(t, err)
+
+		retry, err := n.Notify(ctx, alert)
+		require.Error(t, err)
+		assert.True(t, retry)
+		assert.Equal(t, http.StatusServiceUnavailable, notificationStatusCode(err))
+	})
+}
@@ -25,11 +25,14 @@ import (
 	"github.com/weaveworks/common/httpgrpc"
 	"github.com/weaveworks/common/httpgrpc/server"
 	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
 	"golang.org/x/time/rate"
 
 	"github.com/cortexproject/cortex/pkg/alertmanager/alertmanagerpb"
 	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
 	"github.com/cortexproject/cortex/pkg/alertmanager/alertstore"
+	"github.com/cortexproject/cortex/pkg/alertmanager/auditlog"
+	"github.com/cortexproject/cortex/pkg/alertmanager/secretstore"
 	"github.com/cortexproject/cortex/pkg/ring"
 	"github.com/cortexproject/cortex/pkg/ring/client"
 	"github.com/cortexproject/cortex/pkg/ring/kv"
@@ -39,6 +42,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
 	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/cortexproject/cortex/pkg/util/validation"
 )
 
 const (
@@ -59,6 +63,7 @@ const (
 var (
 	errInvalidExternalURL                  = errors.New("the configured external URL is invalid: should not end with /")
 	errShardingUnsupportedStorage          = errors.New("the configured alertmanager storage backend is not supported when sharding is enabled")
+	errAlertHistoryUnsupportedStorage      = errors.New("the configured alertmanager storage backend is not supported when the alert history is enabled")
 	errZoneAwarenessEnabledWithoutZoneInfo = errors.New("the configured alertmanager has zone awareness enabled but zone is not set")
 )
 
@@ -74,21 +79,32 @@ type MultitenantAlertmanagerConfig struct {
 	ShardingEnabled bool       `yaml:"sharding_enabled"`
 	ShardingRing    RingConfig `yaml:"sharding_ring"`
 
-	FallbackConfigFile string `yaml:"fallback_config_file"`
-	AutoWebhookRoot    string `yaml:"auto_webhook_root"`
+	FallbackConfigFile      string `yaml:"fallback_config_file"`
+	FallbackConfigDirectory string `yaml:"fallback_config_directory"`
+	OperatorConfigFile      string `yaml:"operator_config_file"`
+	AutoWebhookRoot         string `yaml:"auto_webhook_root"`
 
 	Cluster ClusterConfig `yaml:"cluster"`
 
 	EnableAPI      bool          `yaml:"enable_api"`
+	EnableUIProxy  bool          `yaml:"enable_ui_proxy"`
 	APIConcurrency int           `yaml:"api_concurrency"`
 	GCInterval     time.Duration `yaml:"gc_interval"`
 
+	NotificationLogCompactionInterval time.Duration `yaml:"notification_log_compaction_interval"`
+
 	// For distributor.
 	AlertmanagerClient ClientConfig `yaml:"alertmanager_client"`
 
 	// For the state persister.
 	Persister PersisterConfig `yaml:",inline"`
 
+	AlertHistory AlertHistoryConfig `yaml:"alert_history"`
+
+	ReceiversSecrets secretstore.Config `yaml:"receivers_secrets"`
+
+	NotificationAuditLog auditlog.Config `yaml:"notification_audit_log"`
+
 	EnabledTenants  flagext.StringSliceCSV `yaml:"enabled_tenants"`
 	DisabledTenants flagext.StringSliceCSV `yaml:"disabled_tenants"`
 }
@@ -116,18 +132,25 @@ func (cfg *MultitenantAlertmanagerConfig) RegisterFlags(f *flag.FlagSet) {
 	f.Var(&cfg.ExternalURL, "alertmanager.web.external-url", "The URL under which Alertmanager is externally reachable (for example, if Alertmanager is served via a reverse proxy). Used for generating relative and absolute links back to Alertmanager itself. If the URL has a path portion, it will be used to prefix all HTTP endpoints served by Alertmanager. If omitted, relevant URL components will be derived automatically.")
 
 	f.StringVar(&cfg.FallbackConfigFile, "alertmanager.configs.fallback", "", "Filename of fallback config to use if none specified for instance.")
+	f.StringVar(&cfg.FallbackConfigDirectory, "alertmanager.configs.fallback-directory", "", "[Experimental] Directory of named fallback configs, read from the files <name>.yaml, used instead of the -alertmanager.configs.fallback config by the tenants whose alertmanager_fallback_config_name limit is set to <name>. The files are read at startup.")
+	f.StringVar(&cfg.OperatorConfigFile, "alertmanager.configs.operator", "", "[Experimental] Filename of the operator config, defining inhibition rules and mute rules applied to the alerts of every tenant in addition to their Alertmanager config, and the alerts of the tenants mirrored to an operations tenant. The file is read at startup.")
 	f.StringVar(&cfg.AutoWebhookRoot, "alertmanager.configs.auto-webhook-root", "", "Root of URL to generate if config is "+autoWebhookURL)
 	f.DurationVar(&cfg.PollInterval, "alertmanager.configs.poll-interval", 15*time.Second, "How frequently to poll Cortex configs")
 
 	f.BoolVar(&cfg.EnableAPI, "experimental.alertmanager.enable-api", false, "Enable the experimental alertmanager config api.")
+	f.BoolVar(&cfg.EnableUIProxy, "alertmanager.ui-proxy.enabled", false, "[Experimental] Serve the Alertmanager UI and API of each tenant under <alertmanager-http-prefix>/tenants/<tenant>/, for browsers which can't set the tenant header. The requests must be authenticated for the tenant, which must be one of the tenants of their X-Scope-OrgID header (for example tenant-1|tenant-2).")
 	f.IntVar(&cfg.APIConcurrency, "alertmanager.api-concurrency", 0, "Maximum number of concurrent GET API requests before returning an error.")
 	f.DurationVar(&cfg.GCInterval, "alertmanager.alerts-gc-interval", 30*time.Minute, "Alertmanager alerts Garbage collection interval.")
+	f.DurationVar(&cfg.NotificationLogCompactionInterval, "alertmanager.notification-log.compaction-interval", time.Minute, "[Experimental] How often the notification log of each tenant is compacted, garbage collecting its expired entries and evicting its oldest entries while it exceeds the -alertmanager.notification-log-max-size-bytes limit of the tenant. 0 to disable the compaction, the expired entries are still garbage collected every 15 minutes.")
 	f.BoolVar(&cfg.ShardingEnabled, "alertmanager.sharding-enabled", false, "Shard tenants across multiple alertmanager instances.")
 	f.Var(&cfg.EnabledTenants, "alertmanager.enabled-tenants", "Comma separated list of tenants whose alerts this alertmanager can process. If specified, only these tenants will be handled by alertmanager, otherwise this alertmanager can process alerts from all tenants.")
 	f.Var(&cfg.DisabledTenants, "alertmanager.disabled-tenants", "Comma separated list of tenants whose alerts this alertmanager cannot process. If specified, a alertmanager that would normally pick the specified tenant(s) for processing will ignore them instead.")
 
 	cfg.AlertmanagerClient.RegisterFlagsWithPrefix("alertmanager.alertmanager-client", f)
 	cfg.Persister.RegisterFlagsWithPrefix("alertmanager", f)
+	cfg.AlertHistory.RegisterFlagsWithPrefix("alertmanager.alert-history", f)
+	cfg.ReceiversSecrets.RegisterFlagsWithPrefix("alertmanager.receivers-secrets", f)
+	cfg.NotificationAuditLog.RegisterFlagsWithPrefix("alertmanager.notification-audit-log", f)
 	cfg.ShardingRing.RegisterFlags(f)
 	cfg.Cluster.RegisterFlags(f)
 }
@@ -152,6 +175,21 @@ func (cfg *MultitenantAlertmanagerConfig) Validate(storageCfg alertstore.Config)
 		return err
 	}
 
+	if err := cfg.AlertHistory.Validate(); err != nil {
+		return err
+	}
+	if cfg.AlertHistory.Enabled && !storageCfg.IsFullStateSupported() {
+		return errAlertHistoryUnsupportedStorage
+	}
+
+	if err := cfg.ReceiversSecrets.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.NotificationAuditLog.Validate(); err != nil {
+		return err
+	}
+
 	if cfg.ShardingEnabled {
 		if !storageCfg.IsFullStateSupported() {
 			return errShardingUnsupportedStorage
@@ -167,6 +205,8 @@ func (cfg *MultitenantAlertmanagerConfig) Validate(storageCfg alertstore.Config)
 type multitenantAlertmanagerMetrics struct {
 	lastReloadSuccessful          *prometheus.GaugeVec
 	lastReloadSuccessfulTimestamp *prometheus.GaugeVec
+	alertsMirrored                *prometheus.CounterVec
+	alertsMirroringFailed         *prometheus.CounterVec
 }
 
 func newMultitenantAlertmanagerMetrics(reg prometheus.Registerer) *multitenantAlertmanagerMetrics {
@@ -184,6 +224,18 @@ func newMultitenantAlertmanagerMetrics(reg prometheus.Registerer) *multitenantAl
 		Help:      "Timestamp of the last successful configuration reload.",
 	}, []string{"user"})
 
+	m.alertsMirrored = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cortex",
+		Name:      "alertmanager_alerts_mirrored_total",
+		Help:      "Total number of alerts of the user mirrored to the operations tenant.",
+	}, []string{"user"})
+
+	m.alertsMirroringFailed = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cortex",
+		Name:      "alertmanager_alerts_mirroring_failed_total",
+		Help:      "Total number of alerts of the user that failed to be mirrored to the operations tenant.",
+	}, []string{"user"})
+
 	return m
 }
 
@@ -197,6 +249,18 @@ type Limits interface {
 	// in the Alertmanager receivers for the given user.
 	AlertmanagerReceiversBlockPrivateAddresses(user string) bool
 
+	// AlertmanagerReceiversAllowedHosts returns the list of hosts allowed as destination of the
+	// Alertmanager receivers for the given user. If empty, all hosts are allowed.
+	AlertmanagerReceiversAllowedHosts(user string) []string
+
+	// AlertmanagerReceiversBlockedHosts returns the list of hosts blocked as destination of the
+	// Alertmanager receivers for the given user.
+	AlertmanagerReceiversBlockedHosts(user string) []string
+
+	// AlertmanagerReceiversTLSConfigs returns the TLS configs of the Alertmanager HTTP receivers
+	// for the given user, applied according to the destination hosts of the receivers.
+	AlertmanagerReceiversTLSConfigs(user string) []validation.AlertmanagerReceiverTLSConfig
+
 	// NotificationRateLimit methods return limit used by rate-limiter for given integration.
 	// If set to 0, no notifications are allowed.
 	// rate.Inf = all notifications are allowed.
@@ -228,6 +292,28 @@ type Limits interface {
 	// AlertmanagerMaxAlertsSizeBytes returns total max size of alerts that tenant can have active at the same time. 0 = no limit.
 	// Size of the alert is computed from alert labels, annotations and generator URL.
 	AlertmanagerMaxAlertsSizeBytes(tenant string) int
+
+	// AlertmanagerEvictResolvedAlerts returns whether the resolved alerts of the tenant are evicted, oldest first,
+	// to make room for the new alerts when its alerts limits are reached.
+	AlertmanagerEvictResolvedAlerts(tenant string) bool
+
+	// AlertmanagerMaxSilencesCount returns the max number of active and pending silences of the tenant. 0 = no limit.
+	AlertmanagerMaxSilencesCount(tenant string) int
+
+	// AlertmanagerMaxTotalSilences returns the max number of silences of the tenant, including the expired ones,
+	// above which its oldest expired silences are garbage collected. 0 = no limit.
+	AlertmanagerMaxTotalSilences(tenant string) int
+
+	// AlertmanagerExpiredSilencesMaxAge returns the max time the expired silences of the tenant are kept. 0 = no limit.
+	AlertmanagerExpiredSilencesMaxAge(tenant string) time.Duration
+
+	// AlertmanagerNotificationLogMaxSizeBytes returns the max size of the notification log of the tenant, above which
+	// its oldest entries are evicted. 0 = no limit.
+	AlertmanagerNotificationLogMaxSizeBytes(tenant string) int
+
+	// AlertmanagerFallbackConfigName returns the name of the fallback config used by the Alertmanager of a tenant
+	// without its own configuration. Empty to use the default fallback config.
+	AlertmanagerFallbackConfigName(tenant string) string
 }
 
 // A MultitenantAlertmanager manages Alertmanager instances for multiple
@@ -262,12 +348,37 @@ type MultitenantAlertmanager struct {
 	// because we mutate the parsed results and don't want those changes to take
 	// effect here.
 	fallbackConfig string
+	// The named fallback configs selected per tenant, stored as strings as well.
+	fallbackConfigs map[string]string
+
+	// The operator config applied to the alerts of every tenant, nil if not configured.
+	operatorConfig *OperatorConfig
+
+	// The store of the secrets referenced by the receivers, nil if not configured.
+	secretStore secretstore.SecretStore
+
+	// The sink of the notification audit log, nil if not configured.
+	notificationAudit auditlog.Sink
+
+	// Prepares the instance for the termination, nil if sharding is disabled.
+	preparation *ring.InstancePreparation
+	// Whether to persist the state of the tenants when stopping, set when the shutdown is prepared.
+	persistStateOnShutdown atomic.Bool
 
 	alertmanagersMtx sync.Mutex
 	alertmanagers    map[string]*Alertmanager
 	// Stores the current set of configurations we're running in each tenant's Alertmanager.
 	// Used for comparing configurations as we synchronize them.
 	cfgs map[string]alertspb.AlertConfigDesc
+	// Stores the hash of the values of the secrets referenced by the configuration running in each tenant's
+	// Alertmanager, to apply the configuration again when they change.
+	cfgSecrets map[string]string
+	// Stores the hash of the TLS configs applied to the receivers of the configuration running in each tenant's
+	// Alertmanager, to apply the configuration again when they change.
+	cfgReceiversTLS map[string]string
+	// Stores the name of the fallback config running in each tenant's Alertmanager without its own configuration,
+	// to apply the fallback config again when the one selected for the tenant changes.
+	cfgFallbacks map[string]string
 
 	logger              log.Logger
 	alertmanagerMetrics *alertmanagerMetrics
@@ -305,7 +416,7 @@ func NewMultitenantAlertmanager(cfg *MultitenantAlertmanagerConfig, store alerts
 		if err != nil {
 			return nil, fmt.Errorf("unable to read fallback config %q: %s", cfg.FallbackConfigFile, err)
 		}
-		_, err = amconfig.LoadFile(cfg.FallbackConfigFile)
+		_, _, err = loadAlertmanagerConfig(string(fallbackConfig))
 		if err != nil {
 			return nil, fmt.Errorf("unable to load fallback config %q: %s", cfg.FallbackConfigFile, err)
 		}
@@ -344,8 +455,10 @@ func NewMultitenantAlertmanager(cfg *MultitenantAlertmanagerConfig, store alerts
 	if cfg.ShardingEnabled {
 		util_log.WarnExperimentalUse("Alertmanager sharding")
 
+		kvCfg := cfg.ShardingRing.KVStore
+		kvCfg.CASBatchPeriod = cfg.ShardingRing.CASBatchPeriod
 		ringStore, err = kv.NewClient(
-			cfg.ShardingRing.KVStore,
+			kvCfg,
 			ring.GetCodec(),
 			kv.RegistererWithKVName(prometheus.WrapRegistererWithPrefix("cortex_", registerer), "alertmanager"),
 			logger,
@@ -359,10 +472,45 @@ func NewMultitenantAlertmanager(cfg *MultitenantAlertmanagerConfig, store alerts
 }
 
 func createMultitenantAlertmanager(cfg *MultitenantAlertmanagerConfig, fallbackConfig []byte, peer *cluster.Peer, store alertstore.AlertStore, ringStore kv.Client, limits Limits, logger log.Logger, registerer prometheus.Registerer) (*MultitenantAlertmanager, error) {
+	var operatorConfig *OperatorConfig
+	if cfg.OperatorConfigFile != "" {
+		var err error
+		operatorConfig, err = LoadOperatorConfigFile(cfg.OperatorConfigFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load operator config %q: %s", cfg.OperatorConfigFile, err)
+		}
+	}
+
+	var fallbackConfigs map[string]string
+	if cfg.FallbackConfigDirectory != "" {
+		var err error
+		fallbackConfigs, err = loadFallbackConfigs(cfg.FallbackConfigDirectory)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load fallback configs of %q: %s", cfg.FallbackConfigDirectory, err)
+		}
+	}
+
+	secretStore, err := secretstore.NewSecretStore(cfg.ReceiversSecrets)
+	if err != nil {
+		return nil, errors.Wrap(err, "create receivers secrets store")
+	}
+
+	notificationAudit, err := auditlog.NewSink(cfg.NotificationAuditLog, logger, registerer)
+	if err != nil {
+		return nil, errors.Wrap(err, "create notification audit log")
+	}
+
 	am := &MultitenantAlertmanager{
 		cfg:                 cfg,
 		fallbackConfig:      string(fallbackConfig),
+		fallbackConfigs:     fallbackConfigs,
+		operatorConfig:      operatorConfig,
+		secretStore:         secretStore,
+		notificationAudit:   notificationAudit,
 		cfgs:                map[string]alertspb.AlertConfigDesc{},
+		cfgSecrets:          map[string]string{},
+		cfgReceiversTLS:     map[string]string{},
+		cfgFallbacks:        map[string]string{},
 		alertmanagers:       map[string]*Alertmanager{},
 		alertmanagerMetrics: newAlertmanagerMetrics(),
 		multitenantMetrics:  newMultitenantAlertmanagerMetrics(registerer),
@@ -417,6 +565,13 @@ func createMultitenantAlertmanager(cfg *MultitenantAlertmanagerConfig, fallbackC
 			return nil, errors.Wrap(err, "failed to initialize Alertmanager's lifecycler")
 		}
 
+		am.preparation = ring.NewInstancePreparation(ring.PreparationHooks{
+			SetHandOffOnShutdown: am.persistStateOnShutdown.Store,
+			ChangeState:          am.ringLifecycler.ChangeState,
+			HandOff:              am.handOffTenants,
+			ReadyForTermination:  am.checkNoTenants,
+		}, am.logger)
+
 		am.ring, err = ring.NewWithStoreClientAndStrategy(am.cfg.ShardingRing.ToRingConfig(), RingNameForServer, RingKey, ringStore, ring.NewIgnoreUnhealthyInstancesReplicationStrategy(), prometheus.WrapRegistererWithPrefix("cortex_", am.registry), am.logger)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to initialize Alertmanager's ring")
@@ -699,8 +854,8 @@ func (am *MultitenantAlertmanager) loadAndSyncConfigs(ctx context.Context, syncR
 	am.syncConfigs(cfgs)
 	am.deleteUnusedLocalUserState()
 
-	// Currently, remote state persistence is only used when sharding is enabled.
-	if am.cfg.ShardingEnabled {
+	// Currently, remote state persistence is only used when sharding or the alert history is enabled.
+	if am.cfg.ShardingEnabled || am.cfg.AlertHistory.Enabled {
 		// Note when cleaning up remote state, remember that the user may not necessarily be configured
 		// in this instance. Therefore, pass the list of _all_ configured users to filter by.
 		am.deleteUnusedRemoteUserState(ctx, allUsers)
@@ -728,6 +883,10 @@ func (am *MultitenantAlertmanager) waitInitialStateSync(ctx context.Context) err
 
 // stopping runs when MultitenantAlertmanager transitions to Stopping state.
 func (am *MultitenantAlertmanager) stopping(_ error) error {
+	if am.persistStateOnShutdown.Load() {
+		am.persistTenantsState(context.Background())
+	}
+
 	am.alertmanagersMtx.Lock()
 	for _, am := range am.alertmanagers {
 		am.StopAndWait()
@@ -744,6 +903,51 @@ func (am *MultitenantAlertmanager) stopping(_ error) error {
 		// subservices manages ring and lifecycler, if sharding was enabled.
 		_ = services.StopManagerAndAwaitStopped(context.Background(), am.subservices)
 	}
+
+	if am.notificationAudit != nil {
+		if err := am.notificationAudit.Close(); err != nil {
+			level.Warn(am.logger).Log("msg", "failed to close the notification audit log", "err", err)
+		}
+	}
+	return nil
+}
+
+// handOffTenants waits until the instance is LEAVING in the ring, and persists the state of its tenants, so that
+// the replicas taking over the tenants can read it. The tenants are then stopped by the next sync of the ring.
+func (am *MultitenantAlertmanager) handOffTenants(ctx context.Context) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, am.cfg.ShardingRing.WaitInstanceStateTimeout)
+	defer cancel()
+	if err := ring.WaitInstanceState(ctxWithTimeout, am.ring, am.ringLifecycler.GetInstanceID(), ring.LEAVING); err != nil {
+		return errors.Wrap(err, "wait until the alertmanager is LEAVING in the ring")
+	}
+
+	am.persistTenantsState(ctx)
+	return nil
+}
+
+func (am *MultitenantAlertmanager) persistTenantsState(ctx context.Context) {
+	am.alertmanagersMtx.Lock()
+	userAMs := make(map[string]*Alertmanager, len(am.alertmanagers))
+	for userID, userAM := range am.alertmanagers {
+		userAMs[userID] = userAM
+	}
+	am.alertmanagersMtx.Unlock()
+
+	for userID, userAM := range userAMs {
+		if err := userAM.PersistState(ctx); err != nil {
+			level.Warn(am.logger).Log("msg", "failed to persist the alertmanager state", "user", userID, "err", err)
+		}
+	}
+}
+
+// checkNoTenants returns an error if the instance still runs the alertmanager of some tenants.
+func (am *MultitenantAlertmanager) checkNoTenants() error {
+	am.alertmanagersMtx.Lock()
+	defer am.alertmanagersMtx.Unlock()
+
+	if len(am.alertmanagers) > 0 {
+		return fmt.Errorf("the alertmanager still runs %d tenants", len(am.alertmanagers))
+	}
 	return nil
 }
 
@@ -777,11 +981,41 @@ func (am *MultitenantAlertmanager) loadAlertmanagerConfigs(ctx context.Context)
 		return nil, nil, errors.Wrapf(err, "failed to load alertmanager configurations for owned users")
 	}
 
+	// The template files uploaded through the API are stored apart from the configurations.
+	if am.cfg.EnableAPI {
+		if err := am.loadStoredTemplates(ctx, configs); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to load alertmanager template files for owned users")
+		}
+	}
+
 	am.tenantsDiscovered.Set(float64(numUsersDiscovered))
 	am.tenantsOwned.Set(float64(numUsersOwned))
 	return allUserIDs, configs, nil
 }
 
+// loadStoredTemplates adds the template files stored apart from the configurations to the configurations.
+func (am *MultitenantAlertmanager) loadStoredTemplates(ctx context.Context, configs map[string]alertspb.AlertConfigDesc) error {
+	userIDs := make([]string, 0, len(configs))
+	for userID := range configs {
+		userIDs = append(userIDs, userID)
+	}
+
+	var mtx sync.Mutex
+	return concurrency.ForEachUser(ctx, userIDs, fetchConcurrency, func(ctx context.Context, userID string) error {
+		templates, err := am.store.GetTemplates(ctx, userID)
+		if errors.Is(err, alertspb.ErrNotFound) || errors.Is(err, alertspb.ErrAccessDenied) {
+			return nil
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to fetch alertmanager template files for user %s", userID)
+		}
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		configs[userID] = alertspb.WithTemplates(configs[userID], templates)
+		return nil
+	})
+}
+
 func (am *MultitenantAlertmanager) isUserOwned(userID string) bool {
 	// If sharding is disabled, any alertmanager instance owns all users.
 	if !am.cfg.ShardingEnabled {
@@ -820,6 +1054,9 @@ func (am *MultitenantAlertmanager) syncConfigs(cfgs map[string]alertspb.AlertCon
 			userAlertmanagersToStop[userID] = userAM
 			delete(am.alertmanagers, userID)
 			delete(am.cfgs, userID)
+			delete(am.cfgSecrets, userID)
+			delete(am.cfgReceiversTLS, userID)
+			delete(am.cfgFallbacks, userID)
 			am.multitenantMetrics.lastReloadSuccessful.DeleteLabelValues(userID)
 			am.multitenantMetrics.lastReloadSuccessfulTimestamp.DeleteLabelValues(userID)
 			am.alertmanagerMetrics.removeUserRegistry(userID)
@@ -837,8 +1074,30 @@ func (am *MultitenantAlertmanager) syncConfigs(cfgs map[string]alertspb.AlertCon
 
 // setConfig applies the given configuration to the alertmanager for `userID`,
 // creating an alertmanager if it doesn't already exist.
+// transformConfig transforms the webhook configs URLs to the per tenant monitor.
+func (am *MultitenantAlertmanager) transformConfig(userID string, cfg *amconfig.Config) error {
+	if am.cfg.AutoWebhookRoot == "" {
+		return nil
+	}
+
+	for i, r := range cfg.Receivers {
+		for j, w := range r.WebhookConfigs {
+			if w.URL.String() == autoWebhookURL {
+				u, err := url.Parse(am.cfg.AutoWebhookRoot + "/" + userID + "/monitor")
+				if err != nil {
+					return err
+				}
+
+				cfg.Receivers[i].WebhookConfigs[j].URL = &amconfig.SecretURL{URL: u}
+			}
+		}
+	}
+	return nil
+}
+
 func (am *MultitenantAlertmanager) setConfig(cfg alertspb.AlertConfigDesc) error {
 	var userAmConfig *amconfig.Config
+	var httpConfigs []*HTTPReceiverConfig
 	var err error
 	var hasTemplateChanges bool
 	var userTemplateDir = filepath.Join(am.getTenantDirectory(cfg.User), templatesDir)
@@ -884,18 +1143,21 @@ func (am *MultitenantAlertmanager) setConfig(cfg alertspb.AlertConfigDesc) error
 	existing, hasExisting := am.alertmanagers[cfg.User]
 
 	rawCfg := cfg.RawConfig
+	fallbackName := ""
 	if cfg.RawConfig == "" {
-		if am.fallbackConfig == "" {
+		var fallbackConfig string
+		fallbackName, fallbackConfig = am.fallbackConfigFor(cfg.User)
+		if fallbackConfig == "" {
 			return fmt.Errorf("blank Alertmanager configuration for %v", cfg.User)
 		}
-		level.Debug(am.logger).Log("msg", "blank Alertmanager configuration; using fallback", "user", cfg.User)
-		userAmConfig, err = amconfig.Load(am.fallbackConfig)
+		level.Debug(am.logger).Log("msg", "blank Alertmanager configuration; using fallback", "user", cfg.User, "fallback_config", fallbackName)
+		userAmConfig, httpConfigs, err = loadAlertmanagerConfig(fallbackConfig)
 		if err != nil {
 			return fmt.Errorf("unable to load fallback configuration for %v: %v", cfg.User, err)
 		}
-		rawCfg = am.fallbackConfig
+		rawCfg = fallbackConfig
 	} else {
-		userAmConfig, err = amconfig.Load(cfg.RawConfig)
+		userAmConfig, httpConfigs, err = loadAlertmanagerConfig(cfg.RawConfig)
 		if err != nil && hasExisting {
 			// This means that if a user has a working config and
 			// they submit a broken one, the Manager will keep running the last known
@@ -912,40 +1174,44 @@ func (am *MultitenantAlertmanager) setConfig(cfg alertspb.AlertConfigDesc) error
 		return fmt.Errorf("no usable Alertmanager configuration for %v", cfg.User)
 	}
 
-	// Transform webhook configs URLs to the per tenant monitor
-	if am.cfg.AutoWebhookRoot != "" {
-		for i, r := range userAmConfig.Receivers {
-			for j, w := range r.WebhookConfigs {
-				if w.URL.String() == autoWebhookURL {
-					u, err := url.Parse(am.cfg.AutoWebhookRoot + "/" + cfg.User + "/monitor")
-					if err != nil {
-						return err
-					}
+	if err := am.transformConfig(cfg.User, userAmConfig); err != nil {
+		return err
+	}
 
-					userAmConfig.Receivers[i].WebhookConfigs[j].URL = &amconfig.SecretURL{URL: u}
-				}
-			}
-		}
+	// Resolve the secrets referenced by the receivers. If they can't be resolved, the user keeps
+	// running the last working configuration.
+	secretsHash, err := resolveReceiverSecrets(context.Background(), am.secretStore, cfg.User, userAmConfig, httpConfigs)
+	if err != nil {
+		return fmt.Errorf("unable to resolve the receivers secrets for %v: %v", cfg.User, err)
+	}
+
+	// Apply the per-tenant TLS configs of the receivers.
+	var receiversTLSHash string
+	if am.limits != nil {
+		receiversTLSHash = applyReceiversTLSConfigs(userAmConfig, am.limits.AlertmanagerReceiversTLSConfigs(cfg.User))
 	}
 
 	// If no Alertmanager instance exists for this user yet, start one.
 	if !hasExisting {
 		level.Debug(am.logger).Log("msg", "initializing new per-tenant alertmanager", "user", cfg.User)
-		newAM, err := am.newAlertmanager(cfg.User, userAmConfig, rawCfg)
+		newAM, err := am.newAlertmanager(cfg.User, userAmConfig, httpConfigs, rawCfg)
 		if err != nil {
 			return err
 		}
 		am.alertmanagers[cfg.User] = newAM
-	} else if am.cfgs[cfg.User].RawConfig != cfg.RawConfig || hasTemplateChanges {
+	} else if am.cfgs[cfg.User].RawConfig != cfg.RawConfig || hasTemplateChanges || am.cfgSecrets[cfg.User] != secretsHash || am.cfgReceiversTLS[cfg.User] != receiversTLSHash || am.cfgFallbacks[cfg.User] != fallbackName {
 		level.Info(am.logger).Log("msg", "updating new per-tenant alertmanager", "user", cfg.User)
 		// If the config changed, apply the new one.
-		err := existing.ApplyConfig(cfg.User, userAmConfig, rawCfg)
+		err := existing.ApplyConfig(cfg.User, userAmConfig, httpConfigs, rawCfg)
 		if err != nil {
 			return fmt.Errorf("unable to apply Alertmanager config for user %v: %v", cfg.User, err)
 		}
 	}
 
 	am.cfgs[cfg.User] = cfg
+	am.cfgSecrets[cfg.User] = secretsHash
+	am.cfgReceiversTLS[cfg.User] = receiversTLSHash
+	am.cfgFallbacks[cfg.User] = fallbackName
 	return nil
 }
 
@@ -953,7 +1219,7 @@ func (am *MultitenantAlertmanager) getTenantDirectory(userID string) string {
 	return filepath.Join(am.cfg.DataDir, userID)
 }
 
-func (am *MultitenantAlertmanager) newAlertmanager(userID string, amConfig *amconfig.Config, rawCfg string) (*Alertmanager, error) {
+func (am *MultitenantAlertmanager) newAlertmanager(userID string, amConfig *amconfig.Config, httpConfigs []*HTTPReceiverConfig, rawCfg string) (*Alertmanager, error) {
 	reg := prometheus.NewRegistry()
 
 	tenantDir := am.getTenantDirectory(userID)
@@ -975,15 +1241,20 @@ func (am *MultitenantAlertmanager) newAlertmanager(userID string, amConfig *amco
 		ReplicationFactor: am.cfg.ShardingRing.ReplicationFactor,
 		Store:             am.store,
 		PersisterConfig:   am.cfg.Persister,
+		AlertHistory:      am.cfg.AlertHistory,
+		NotificationAudit: am.notificationAudit,
+		OperatorConfig:    am.operatorConfig,
 		Limits:            am.limits,
 		APIConcurrency:    am.cfg.APIConcurrency,
 		GCInterval:        am.cfg.GCInterval,
+
+		NotificationLogCompactionInterval: am.cfg.NotificationLogCompactionInterval,
 	}, reg)
 	if err != nil {
 		return nil, fmt.Errorf("unable to start Alertmanager for user %v: %v", userID, err)
 	}
 
-	if err := newAM.ApplyConfig(userID, amConfig, rawCfg); err != nil {
+	if err := newAM.ApplyConfig(userID, amConfig, httpConfigs, rawCfg); err != nil {
 		return nil, fmt.Errorf("unable to apply initial config for user %v: %v", userID, err)
 	}
 
@@ -1024,6 +1295,16 @@ func (am *MultitenantAlertmanager) ServeHTTP(w http.ResponseWriter, req *http.Re
 		return
 	}
 
+	if am.isMirroredAlertsRequest(req) {
+		am.serveMirroredAlerts(w, req)
+		return
+	}
+
+	am.dispatchRequest(w, req)
+}
+
+// dispatchRequest serves the request through the distributor, if sharding is enabled, or by this instance.
+func (am *MultitenantAlertmanager) dispatchRequest(w http.ResponseWriter, req *http.Request) {
 	if am.cfg.ShardingEnabled {
 		am.distributor.DistributeRequest(w, req, am.allowedTenants)
 		return
@@ -1059,7 +1340,7 @@ func (am *MultitenantAlertmanager) serveRequest(w http.ResponseWriter, req *http
 		return
 	}
 
-	if am.fallbackConfig != "" {
+	if _, fallbackConfig := am.fallbackConfigFor(userID); fallbackConfig != "" {
 		userAM, err = am.alertmanagerFromFallbackConfig(userID)
 		if err != nil {
 			level.Error(am.logger).Log("msg", "unable to initialize the Alertmanager with a fallback configuration", "user", userID, "err", err)
@@ -1247,6 +1528,49 @@ func (am *MultitenantAlertmanager) deleteUnusedRemoteUserState(ctx context.Conte
 		} else {
 			level.Info(am.logger).Log("msg", "deleted remote state for user", "user", userID)
 		}
+
+		am.deleteRemoteUserStateSnapshots(ctx, userID)
+		am.deleteRemoteUserAlertHistory(ctx, userID)
+	}
+}
+
+// deleteRemoteUserStateSnapshots deletes the snapshots of the state in remote storage of an user no longer configured.
+func (am *MultitenantAlertmanager) deleteRemoteUserStateSnapshots(ctx context.Context, userID string) {
+	names, err := am.store.ListFullStateSnapshots(ctx, userID)
+	if err != nil {
+		level.Warn(am.logger).Log("msg", "failed to list remote state snapshots for user", "user", userID, "err", err)
+		return
+	}
+
+	for _, name := range names {
+		if err := am.store.DeleteFullStateSnapshot(ctx, userID, name); err != nil {
+			level.Warn(am.logger).Log("msg", "failed to delete remote state snapshot for user", "user", userID, "snapshot", name, "err", err)
+			return
+		}
+	}
+
+	if len(names) > 0 {
+		level.Info(am.logger).Log("msg", "deleted remote state snapshots for user", "user", userID, "snapshots", len(names))
+	}
+}
+
+// deleteRemoteUserAlertHistory deletes the alert history in remote storage of an user no longer configured.
+func (am *MultitenantAlertmanager) deleteRemoteUserAlertHistory(ctx context.Context, userID string) {
+	names, err := am.store.ListAlertHistory(ctx, userID)
+	if err != nil {
+		level.Warn(am.logger).Log("msg", "failed to list remote alert history for user", "user", userID, "err", err)
+		return
+	}
+
+	for _, name := range names {
+		if err := am.store.DeleteAlertHistory(ctx, userID, name); err != nil {
+			level.Warn(am.logger).Log("msg", "failed to delete remote alert history for user", "user", userID, "batch", name, "err", err)
+			return
+		}
+	}
+
+	if len(names) > 0 {
+		level.Info(am.logger).Log("msg", "deleted remote alert history for user", "user", userID, "batches", len(names))
 	}
 }
 
@@ -24,6 +24,8 @@ import (
 	"github.com/prometheus/alertmanager/cluster/clusterpb"
 	"github.com/prometheus/alertmanager/notify"
 	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/alertmanager/silence"
+	"github.com/prometheus/alertmanager/silence/silencepb"
 	"github.com/prometheus/alertmanager/types"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
@@ -369,6 +371,48 @@ templates:
 	require.False(t, fileExists(t, filepath.Join(user3Dir, templatesDir, "second.tpl")))
 }
 
+func TestMultitenantAlertmanager_loadAndSyncConfigsWithStoredTemplates(t *testing.T) {
+	ctx := context.Background()
+
+	store := prepareInMemoryAlertStore()
+	require.NoError(t, store.SetAlertConfig(ctx, alertspb.AlertConfigDesc{
+		User: "user1",
+		RawConfig: simpleConfigOne + `
+templates:
+- '*.tpl'
+`,
+		Templates: []*alertspb.TemplateDesc{{Filename: "first.tpl", Body: `{{ define "t1" }}Template 1{{ end }}`}},
+	}))
+	require.NoError(t, store.SetTemplates(ctx, alertspb.TemplatesDesc{
+		User: "user1",
+		Templates: []*alertspb.TemplateDesc{
+			{Filename: "first.tpl", Body: `{{ define "t1" }}Stored template 1{{ end }}`},
+			{Filename: "second.tpl", Body: `{{ define "t2" }}Template 2{{ end }}`},
+		},
+	}))
+
+	cfg := mockAlertmanagerConfig(t)
+	cfg.EnableAPI = true
+	am, err := createMultitenantAlertmanager(cfg, nil, nil, store, nil, nil, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+
+	require.NoError(t, am.loadAndSyncConfigs(ctx, reasonPeriodic))
+	require.Len(t, am.alertmanagers, 1)
+
+	// The stored template files are written along with the ones of the config, which take precedence.
+	templateDir := filepath.Join(am.getPerUserDirectories()["user1"], templatesDir)
+	first, err := os.ReadFile(filepath.Join(templateDir, "first.tpl"))
+	require.NoError(t, err)
+	assert.Equal(t, `{{ define "t1" }}Template 1{{ end }}`, string(first))
+	require.True(t, fileExists(t, filepath.Join(templateDir, "second.tpl")))
+
+	// The deleted template files are removed.
+	require.NoError(t, store.DeleteTemplates(ctx, "user1"))
+	require.NoError(t, am.loadAndSyncConfigs(ctx, reasonPeriodic))
+	require.True(t, fileExists(t, filepath.Join(templateDir, "first.tpl")))
+	require.False(t, fileExists(t, filepath.Join(templateDir, "second.tpl")))
+}
+
 func TestMultitenantAlertmanager_FirewallShouldBlockHTTPBasedReceiversWhenEnabled(t *testing.T) {
 	tests := map[string]struct {
 		getAlertmanagerConfig func(backendURL string) string
@@ -824,6 +868,94 @@ func TestMultitenantAlertmanager_deleteUnusedRemoteUserState(t *testing.T) {
 	}
 }
 
+func TestMultitenantAlertmanager_StateSnapshots(t *testing.T) {
+	ctx := context.Background()
+	alertStore := prepareInMemoryAlertStore()
+	ringStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	cfg := mockAlertmanagerConfig(t)
+	cfg.ShardingRing.ReplicationFactor = 1
+	cfg.ShardingRing.InstanceID = "instance-1"
+	cfg.ShardingRing.InstanceAddr = "127.0.0.1"
+	cfg.ShardingEnabled = true
+	// Snapshot the state each time it's persisted, making the test faster.
+	cfg.Persister.Interval = 500 * time.Millisecond
+	cfg.Persister.SnapshotInterval = time.Millisecond
+	cfg.Persister.SnapshotRetention = time.Hour
+
+	am, err := createMultitenantAlertmanager(cfg, nil, nil, alertStore, ringStore, nil, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(ctx, am))
+	})
+	require.NoError(t, services.StartAndAwaitRunning(ctx, am))
+
+	require.NoError(t, alertStore.SetAlertConfig(ctx, alertspb.AlertConfigDesc{
+		User:      "user-1",
+		RawConfig: simpleConfigOne,
+		Templates: []*alertspb.TemplateDesc{},
+	}))
+	require.NoError(t, am.loadAndSyncConfigs(ctx, reasonPeriodic))
+
+	am.alertmanagersMtx.Lock()
+	silences := am.alertmanagers["user-1"].silences
+	am.alertmanagersMtx.Unlock()
+
+	// Create a silence, and wait for it to be snapshotted.
+	silenceID, err := silences.Set(&silencepb.Silence{
+		Matchers: []*silencepb.Matcher{{Type: silencepb.Matcher_EQUAL, Name: "alertname", Pattern: "test"}},
+		StartsAt: time.Now(),
+		EndsAt:   time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	var snapshot string
+	require.Eventually(t, func() bool {
+		names, err := alertStore.ListFullStateSnapshots(ctx, "user-1")
+		if err != nil || len(names) == 0 {
+			return false
+		}
+		snapshot = names[0]
+		return true
+	}, 5*time.Second, 100*time.Millisecond, "timed out waiting for the state to be snapshotted")
+
+	// The snapshots are listed.
+	req := httptest.NewRequest(http.MethodGet, "/multitenant_alertmanager/state_snapshots", nil).WithContext(user.InjectOrgID(ctx, "user-1"))
+	w := httptest.NewRecorder()
+	am.ListUserStateSnapshots(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "name: \""+snapshot+"\"")
+
+	// Expire the silence, as if deleted by mistake, and restore the snapshot.
+	require.NoError(t, silences.Expire(silenceID))
+	sil, err := silences.QueryOne(silence.QIDs(silenceID))
+	require.NoError(t, err)
+	require.Equal(t, types.SilenceStateExpired, types.CalcSilenceState(sil.StartsAt, sil.EndsAt))
+
+	restore := func(name string) int {
+		req := httptest.NewRequest(http.MethodPost, "/multitenant_alertmanager/restore_state_snapshot?snapshot="+name, nil).WithContext(user.InjectOrgID(ctx, "user-1"))
+		w := httptest.NewRecorder()
+		am.RestoreUserStateSnapshot(w, req)
+		return w.Code
+	}
+	require.Equal(t, http.StatusNotFound, restore("1"))
+	require.Equal(t, http.StatusOK, restore(snapshot))
+
+	sil, err = silences.QueryOne(silence.QIDs(silenceID))
+	require.NoError(t, err)
+	assert.Equal(t, types.SilenceStateActive, types.CalcSilenceState(sil.StartsAt, sil.EndsAt))
+
+	// The snapshots are deleted along with the state of the users no longer configured.
+	require.NoError(t, alertStore.DeleteAlertConfig(ctx, "user-1"))
+	require.NoError(t, am.loadAndSyncConfigs(ctx, reasonPeriodic))
+	require.Eventually(t, func() bool {
+		am.deleteUnusedRemoteUserState(ctx, nil)
+		names, err := alertStore.ListFullStateSnapshots(ctx, "user-1")
+		return err == nil && len(names) == 0
+	}, 5*time.Second, 100*time.Millisecond, "timed out waiting for the snapshots to be deleted")
+}
+
 func createFile(t *testing.T, path string) string {
 	dir := filepath.Dir(path)
 	require.NoError(t, os.MkdirAll(dir, 0777))
@@ -1014,6 +1146,60 @@ receivers:
 	require.Equal(t, http.StatusOK, resp.StatusCode)
 }
 
+func TestMultitenantAlertmanager_NamedFallbackConfigs(t *testing.T) {
+	ctx := context.Background()
+
+	fallbackDir := t.TempDir()
+	for name, receiver := range map[string]string{"tier-1": "tier-1-receiver", "tier-2": "tier-2-receiver"} {
+		cfg := fmt.Sprintf("route:\n  receiver: %[1]s\nreceivers:\n  - name: %[1]s\n", receiver)
+		require.NoError(t, os.WriteFile(filepath.Join(fallbackDir, name+".yaml"), []byte(cfg), 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(fallbackDir, "README.md"), []byte("not a config"), 0644))
+
+	amConfig := mockAlertmanagerConfig(t)
+	amConfig.FallbackConfigDirectory = fallbackDir
+
+	limits := &mockAlertManagerLimits{fallbackConfigNames: map[string]string{"user-1": "tier-1", "user-2": "unknown"}}
+	store := prepareInMemoryAlertStore()
+	am, err := createMultitenantAlertmanager(amConfig, []byte(simpleConfigOne), nil, store, nil, limits, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	require.Len(t, am.fallbackConfigs, 2)
+
+	require.NoError(t, services.StartAndAwaitRunning(ctx, am))
+	defer services.StopAndAwaitTerminated(ctx, am) //nolint:errcheck
+
+	statusOf := func(userID string) string {
+		req := httptest.NewRequest("GET", amConfig.ExternalURL.String()+"/api/v2/status", nil)
+		w := httptest.NewRecorder()
+		am.ServeHTTP(w, req.WithContext(user.InjectOrgID(req.Context(), userID)))
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	// The user runs the fallback config selected by its limits.
+	assert.Contains(t, statusOf("user-1"), "tier-1-receiver")
+	assert.Equal(t, "tier-1", am.cfgFallbacks["user-1"])
+
+	// The user whose fallback config doesn't exist runs the default fallback config.
+	assert.Contains(t, statusOf("user-2"), "dummy")
+	assert.Equal(t, "", am.cfgFallbacks["user-2"])
+
+	// The fallback config is applied again when the one selected for the user changes.
+	limits.fallbackConfigNames["user-1"] = "tier-2"
+	require.NoError(t, am.setConfig(alertspb.ToProto("", nil, "user-1")))
+	assert.Contains(t, statusOf("user-1"), "tier-2-receiver")
+	assert.Equal(t, "tier-2", am.cfgFallbacks["user-1"])
+}
+
+func TestLoadFallbackConfigs_InvalidConfig(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.yaml"), []byte("route: {}"), 0644))
+
+	_, err := loadFallbackConfigs(dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid fallback config")
+}
+
 func TestMultitenantAlertmanager_InitialSyncWithSharding(t *testing.T) {
 	tg := ring.NewRandomTokenGenerator()
 	tc := []struct {
@@ -2219,6 +2405,15 @@ type mockAlertManagerLimits struct {
 	maxDispatcherAggregationGroups int
 	maxAlertsCount                 int
 	maxAlertsSizeBytes             int
+	evictResolvedAlerts            bool
+	maxSilencesCount               int
+	maxTotalSilences               int
+	expiredSilencesMaxAge          time.Duration
+	notificationLogMaxSizeBytes    int
+	receiversAllowedHosts          []string
+	receiversBlockedHosts          []string
+	receiversTLSConfigs            []validation.AlertmanagerReceiverTLSConfig
+	fallbackConfigNames            map[string]string
 }
 
 func (m *mockAlertManagerLimits) AlertmanagerMaxConfigSize(tenant string) int {
@@ -2234,11 +2429,23 @@ func (m *mockAlertManagerLimits) AlertmanagerMaxTemplateSize(tenant string) int
 }
 
 func (m *mockAlertManagerLimits) AlertmanagerReceiversBlockCIDRNetworks(user string) []flagext.CIDR {
-	panic("implement me")
+	return nil
 }
 
 func (m *mockAlertManagerLimits) AlertmanagerReceiversBlockPrivateAddresses(user string) bool {
-	panic("implement me")
+	return false
+}
+
+func (m *mockAlertManagerLimits) AlertmanagerReceiversAllowedHosts(_ string) []string {
+	return m.receiversAllowedHosts
+}
+
+func (m *mockAlertManagerLimits) AlertmanagerReceiversBlockedHosts(_ string) []string {
+	return m.receiversBlockedHosts
+}
+
+func (m *mockAlertManagerLimits) AlertmanagerReceiversTLSConfigs(_ string) []validation.AlertmanagerReceiverTLSConfig {
+	return m.receiversTLSConfigs
 }
 
 func (m *mockAlertManagerLimits) NotificationRateLimit(_ string, integration string) rate.Limit {
@@ -2260,3 +2467,27 @@ func (m *mockAlertManagerLimits) AlertmanagerMaxAlertsCount(_ string) int {
 func (m *mockAlertManagerLimits) AlertmanagerMaxAlertsSizeBytes(_ string) int {
 	return m.maxAlertsSizeBytes
 }
+
+func (m *mockAlertManagerLimits) AlertmanagerEvictResolvedAlerts(_ string) bool {
+	return m.evictResolvedAlerts
+}
+
+func (m *mockAlertManagerLimits) AlertmanagerMaxSilencesCount(_ string) int {
+	return m.maxSilencesCount
+}
+
+func (m *mockAlertManagerLimits) AlertmanagerMaxTotalSilences(_ string) int {
+	return m.maxTotalSilences
+}
+
+func (m *mockAlertManagerLimits) AlertmanagerExpiredSilencesMaxAge(_ string) time.Duration {
+	return m.expiredSilencesMaxAge
+}
+
+func (m *mockAlertManagerLimits) AlertmanagerNotificationLogMaxSizeBytes(_ string) int {
+	return m.notificationLogMaxSizeBytes
+}
+
+func (m *mockAlertManagerLimits) AlertmanagerFallbackConfigName(tenant string) string {
+	return m.fallbackConfigNames[tenant]
+}
@@ -0,0 +1,125 @@
+package alertmanager
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/matttproud/golang_protobuf_extensions/pbutil"
+	"github.com/prometheus/alertmanager/nflog/nflogpb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// nflogEvictionDelay is how long the evicted entries of the notification log are kept, in the state of the
+// replicas, before being garbage collected.
+const nflogEvictionDelay = time.Second
+
+// nflogCompactor bounds the size of the notification log of a tenant.
+type nflogCompactor struct {
+	size    prometheus.Gauge
+	evicted prometheus.Counter
+}
+
+func newNflogCompactor(reg prometheus.Registerer) *nflogCompactor {
+	return &nflogCompactor{
+		size: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "alertmanager_notification_log_size_bytes",
+			Help: "Size of the notification log, as of its last compaction.",
+		}),
+		evicted: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "alertmanager_notification_log_evicted_total",
+			Help: "Number of entries evicted from the notification log because it exceeded its size limit.",
+		}),
+	}
+}
+
+// compactNotificationLogLoop periodically compacts the notification log of the tenant.
+func (am *Alertmanager) compactNotificationLogLoop(interval time.Duration) {
+	defer am.wg.Done()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-am.stop:
+			return
+		case <-t.C:
+			if err := am.compactNotificationLog(time.Now()); err != nil {
+				level.Warn(am.logger).Log("msg", "failed to compact the notification log", "err", err)
+			}
+		}
+	}
+}
+
+// compactNotificationLog garbage collects the expired entries of the notification log of the tenant and, while it
+// exceeds the notification log size limit of the tenant, evicts its oldest entries. The evicted entries are marked to
+// expire from the state shortly, and the change is replicated to the other replicas of the tenant. The groups of the
+// evicted entries are notified again at their next flush, as if they had never been notified.
+func (am *Alertmanager) compactNotificationLog(now time.Time) error {
+	if _, err := am.nflog.GC(); err != nil {
+		return err
+	}
+
+	state, err := am.nflog.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	am.nflogCompactor.size.Set(float64(len(state)))
+
+	if am.cfg.Limits == nil {
+		return nil
+	}
+	limit := am.cfg.Limits.AlertmanagerNotificationLogMaxSizeBytes(am.cfg.UserID)
+	if limit <= 0 || len(state) <= limit {
+		return nil
+	}
+
+	var entries []*nflogpb.MeshEntry
+	r := bytes.NewReader(state)
+	for {
+		var e nflogpb.MeshEntry
+		if _, err := pbutil.ReadDelimited(r, &e); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		entries = append(entries, &e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Entry.Timestamp.Before(entries[j].Entry.Timestamp)
+	})
+
+	var buf bytes.Buffer
+	size, evicted := len(state), 0
+	for _, e := range entries {
+		if size <= limit {
+			break
+		}
+
+		n, err := pbutil.WriteDelimited(io.Discard, e)
+		if err != nil {
+			return err
+		}
+		size -= n
+
+		// The entry must be newer than the entry of the state to be merged.
+		entry := *e.Entry
+		entry.Timestamp = entry.Timestamp.Add(time.Nanosecond)
+		if _, err := pbutil.WriteDelimited(&buf, &nflogpb.MeshEntry{Entry: &entry, ExpiresAt: now.Add(nflogEvictionDelay)}); err != nil {
+			return err
+		}
+		evicted++
+	}
+
+	if err := am.nflog.Merge(buf.Bytes()); err != nil {
+		return err
+	}
+	am.nflogCompactor.evicted.Add(float64(evicted))
+	level.Debug(am.logger).Log("msg", "evicted the oldest entries of the notification log", "evicted", evicted, "size", len(state), "limit", limit)
+	return nil
+}
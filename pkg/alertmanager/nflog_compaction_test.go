@@ -0,0 +1,71 @@
+package alertmanager
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/nflog"
+	"github.com/prometheus/alertmanager/nflog/nflogpb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertmanager_CompactNotificationLog(t *testing.T) {
+	limits := &mockAlertManagerLimits{}
+	am, err := New(&Config{
+		UserID:        "user-1",
+		Logger:        log.NewNopLogger(),
+		Limits:        limits,
+		Retention:     time.Hour,
+		TenantDataDir: t.TempDir(),
+		ExternalURL:   &url.URL{Path: "/am"},
+		GCInterval:    30 * time.Minute,
+	}, prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+	defer am.StopAndWait()
+
+	receiver := &nflogpb.Receiver{GroupName: "default", Integration: "webhook", Idx: 0}
+	groupKeys := []string{"group-1", "group-2", "group-3", "group-4"}
+	for _, gk := range groupKeys {
+		require.NoError(t, am.nflog.Log(receiver, gk, []uint64{1}, nil, time.Hour))
+	}
+	notified := func() []string {
+		var keys []string
+		for _, gk := range groupKeys {
+			if _, err := am.nflog.Query(nflog.QReceiver(receiver), nflog.QGroupKey(gk)); err == nil {
+				keys = append(keys, gk)
+			}
+		}
+		return keys
+	}
+
+	state, err := am.nflog.MarshalBinary()
+	require.NoError(t, err)
+	entrySize := len(state) / len(groupKeys)
+
+	// Without limit, the notification log is only measured.
+	require.NoError(t, am.compactNotificationLog(time.Now()))
+	assert.Equal(t, float64(len(state)), testutil.ToFloat64(am.nflogCompactor.size))
+	assert.Equal(t, groupKeys, notified())
+
+	// The oldest entries are evicted while the notification log exceeds its limit.
+	limits.notificationLogMaxSizeBytes = 2*entrySize + entrySize/2
+	require.NoError(t, am.compactNotificationLog(time.Now()))
+	assert.Equal(t, float64(2), testutil.ToFloat64(am.nflogCompactor.evicted))
+
+	require.Eventually(t, func() bool {
+		// The evicted entries are garbage collected once their eviction delay has elapsed.
+		_, err := am.nflog.GC()
+		require.NoError(t, err)
+		return len(notified()) == 2
+	}, 5*time.Second, 100*time.Millisecond)
+	assert.Equal(t, []string{"group-3", "group-4"}, notified())
+
+	require.NoError(t, am.compactNotificationLog(time.Now()))
+	assert.Equal(t, float64(2), testutil.ToFloat64(am.nflogCompactor.evicted))
+	assert.LessOrEqual(t, testutil.ToFloat64(am.nflogCompactor.size), float64(limits.notificationLogMaxSizeBytes))
+}
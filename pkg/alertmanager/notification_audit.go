@@ -0,0 +1,123 @@
+package alertmanager
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/auditlog"
+)
+
+// The HTTP integrations report the unexpected status codes of the receivers in their errors.
+var notificationStatusCodeRegexp = regexp.MustCompile(`unexpected status code (\d{3})`)
+
+// auditedNotifier records every notification attempt of an integration to the notification audit log.
+type auditedNotifier struct {
+	upstream    notify.Notifier
+	sink        auditlog.Sink
+	userID      string
+	integration string
+	logger      log.Logger
+
+	// The attempts of the notifications being retried, by group key. The retries of a notification
+	// share the time of the flush of the alert group.
+	mtx      sync.Mutex
+	attempts map[string]notificationAttempts
+}
+
+type notificationAttempts struct {
+	flushedAt time.Time
+	count     int
+}
+
+func newAuditedNotifier(upstream notify.Notifier, sink auditlog.Sink, userID, integration string, logger log.Logger) *auditedNotifier {
+	return &auditedNotifier{
+		upstream:    upstream,
+		sink:        sink,
+		userID:      userID,
+		integration: integration,
+		logger:      logger,
+		attempts:    map[string]notificationAttempts{},
+	}
+}
+
+func (n *auditedNotifier) Notify(ctx context.Context, alerts ...*types.Alert) (bool, error) {
+	start := time.Now()
+	retry, err := n.upstream.Notify(ctx, alerts...)
+	now := time.Now()
+
+	groupKey, _ := notify.GroupKey(ctx)
+	receiver, _ := notify.ReceiverName(ctx)
+	flushedAt, ok := notify.Now(ctx)
+	if !ok {
+		flushedAt = start
+	}
+
+	e := auditlog.Entry{
+		Timestamp:        now,
+		Tenant:           n.userID,
+		Receiver:         receiver,
+		Integration:      n.integration,
+		GroupKey:         groupKey,
+		GroupFingerprint: notify.Key(groupKey).Hash(),
+		Outcome:          auditlog.OutcomeSuccess,
+		LatencySeconds:   now.Sub(start).Seconds(),
+		Retries:          n.retries(groupKey, flushedAt, err != nil && retry),
+	}
+	for _, alert := range alerts {
+		if alert.ResolvedAt(flushedAt) {
+			e.ResolvedAlerts++
+		} else {
+			e.FiringAlerts++
+		}
+	}
+	if err != nil {
+		e.Outcome = auditlog.OutcomeFailure
+		e.Error = err.Error()
+		e.StatusCode = notificationStatusCode(err)
+		e.WillRetry = retry
+	}
+
+	if werr := n.sink.Write(e); werr != nil {
+		level.Warn(n.logger).Log("msg", "failed to write the notification audit log", "user", n.userID, "err", werr)
+	}
+
+	return retry, err
+}
+
+// retries returns the number of previous attempts of the notification of the alert group flushed at the given time.
+func (n *auditedNotifier) retries(groupKey string, flushedAt time.Time, willRetry bool) int {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	a, ok := n.attempts[groupKey]
+	if !ok || !a.flushedAt.Equal(flushedAt) {
+		a = notificationAttempts{flushedAt: flushedAt}
+	}
+	retries := a.count
+
+	if willRetry {
+		a.count++
+		n.attempts[groupKey] = a
+	} else {
+		delete(n.attempts, groupKey)
+	}
+	return retries
+}
+
+// notificationStatusCode returns the HTTP status code reported in the error of a notification, or 0 if none.
+func notificationStatusCode(err error) int {
+	m := notificationStatusCodeRegexp.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0
+	}
+	code, _ := strconv.Atoi(m[1])
+	return code
+}
@@ -0,0 +1,105 @@
+package alertmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/auditlog"
+)
+
+type recordingAuditSink struct {
+	entries []auditlog.Entry
+}
+
+func (s *recordingAuditSink) Write(e auditlog.Entry) error {
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+func (s *recordingAuditSink) Close() error {
+	return nil
+}
+
+type resultsNotifier struct {
+	results []error
+}
+
+func (n *resultsNotifier) Notify(_ context.Context, _ ...*types.Alert) (bool, error) {
+	err := n.results[0]
+	n.results = n.results[1:]
+	return err != nil && !errors.Is(err, errRateLimited), err
+}
+
+func TestAuditedNotifier(t *testing.T) {
+	sink := &recordingAuditSink{}
+	upstream := &resultsNotifier{results: []error{
+		fmt.Errorf("unexpected status code 503: unavailable"),
+		fmt.Errorf("unexpected status code 502"),
+		nil,
+		errRateLimited,
+	}}
+	notifier := newAuditedNotifier(upstream, sink, "user-1", "webhook", log.NewNopLogger())
+
+	flushedAt := time.Now()
+	ctx := notify.WithGroupKey(context.Background(), "{}:{alertname=\"test\"}")
+	ctx = notify.WithReceiverName(ctx, "team-a")
+	ctx = notify.WithNow(ctx, flushedAt)
+
+	alerts := []*types.Alert{
+		{Alert: model.Alert{Labels: model.LabelSet{"alertname": "test", "instance": "a"}, StartsAt: flushedAt.Add(-time.Hour)}},
+		{Alert: model.Alert{Labels: model.LabelSet{"alertname": "test", "instance": "b"}, StartsAt: flushedAt.Add(-time.Hour), EndsAt: flushedAt.Add(-time.Minute)}},
+	}
+
+	// The notification is retried twice before succeeding.
+	for i := 0; i < 3; i++ {
+		_, _ = notifier.Notify(ctx, alerts...)
+	}
+	// The next flush of the alert group is rate limited.
+	retry, err := notifier.Notify(notify.WithNow(ctx, flushedAt.Add(time.Minute)), alerts...)
+	require.ErrorIs(t, err, errRateLimited)
+	assert.False(t, retry)
+
+	require.Len(t, sink.entries, 4)
+	for i, e := range sink.entries {
+		assert.Equal(t, "user-1", e.Tenant)
+		assert.Equal(t, "team-a", e.Receiver)
+		assert.Equal(t, "webhook", e.Integration)
+		assert.Equal(t, notify.Key("{}:{alertname=\"test\"}").Hash(), e.GroupFingerprint)
+		assert.Equal(t, 1, e.FiringAlerts)
+		assert.Equal(t, 1, e.ResolvedAlerts)
+		assert.GreaterOrEqual(t, e.LatencySeconds, 0.0)
+		if i < 3 {
+			assert.Equal(t, i, e.Retries)
+		}
+	}
+
+	assert.Equal(t, auditlog.OutcomeFailure, sink.entries[0].Outcome)
+	assert.Equal(t, 503, sink.entries[0].StatusCode)
+	assert.Equal(t, "unexpected status code 503: unavailable", sink.entries[0].Error)
+	assert.True(t, sink.entries[0].WillRetry)
+
+	assert.Equal(t, 502, sink.entries[1].StatusCode)
+
+	assert.Equal(t, auditlog.OutcomeSuccess, sink.entries[2].Outcome)
+	assert.Zero(t, sink.entries[2].StatusCode)
+	assert.Empty(t, sink.entries[2].Error)
+
+	assert.Equal(t, auditlog.OutcomeFailure, sink.entries[3].Outcome)
+	assert.Equal(t, 0, sink.entries[3].Retries)
+	assert.Zero(t, sink.entries[3].StatusCode)
+	assert.Equal(t, errRateLimited.Error(), sink.entries[3].Error)
+	assert.False(t, sink.entries[3].WillRetry)
+
+	// The attempts of the notifications no longer retried are forgotten.
+	assert.Empty(t, notifier.attempts)
+}
@@ -0,0 +1,122 @@
+package alertmanager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	amconfig "github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/alertmanager/timeinterval"
+	"github.com/prometheus/alertmanager/types"
+	"gopkg.in/yaml.v2"
+)
+
+// OperatorConfig is the configuration defined by the operator of the cluster, applied to the alerts of every
+// tenant in addition to their Alertmanager config. The tenants can't override it:
+//   - The inhibition rules are evaluated along with the inhibition rules of the tenant.
+//   - The alerts matched by an active mute rule are not notified, regardless of the routes, time intervals and
+//     silences of the tenant. They are still shown as active, and can still inhibit other alerts.
+//   - The alerts selected by the alerts mirroring config are mirrored to the operations tenant.
+type OperatorConfig struct {
+	InhibitRules    []amconfig.InhibitRule `yaml:"inhibit_rules,omitempty"`
+	MuteRules       []OperatorMuteRule     `yaml:"mute_rules,omitempty"`
+	AlertsMirroring *AlertsMirroringConfig `yaml:"alerts_mirroring,omitempty"`
+}
+
+// OperatorMuteRule mutes the notifications of the alerts matching its matchers, or all the alerts without
+// matchers, during its time intervals (for example a planned platform maintenance).
+type OperatorMuteRule struct {
+	Name          string                      `yaml:"name"`
+	Matchers      amconfig.Matchers           `yaml:"matchers,omitempty"`
+	TimeIntervals []timeinterval.TimeInterval `yaml:"time_intervals"`
+}
+
+// LoadOperatorConfigFile loads and validates the operator config from the given file.
+func LoadOperatorConfigFile(filename string) (*OperatorConfig, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return LoadOperatorConfig(content)
+}
+
+// LoadOperatorConfig parses and validates the operator config.
+func LoadOperatorConfig(content []byte) (*OperatorConfig, error) {
+	cfg := &OperatorConfig{}
+	if err := yaml.UnmarshalStrict(content, cfg); err != nil {
+		return nil, err
+	}
+
+	names := map[string]struct{}{}
+	for _, r := range cfg.MuteRules {
+		if r.Name == "" {
+			return nil, errors.New("missing name in mute rule")
+		}
+		if _, ok := names[r.Name]; ok {
+			return nil, fmt.Errorf("mute rule %q is not unique", r.Name)
+		}
+		names[r.Name] = struct{}{}
+
+		if len(r.TimeIntervals) == 0 {
+			return nil, fmt.Errorf("mute rule %q has no time intervals", r.Name)
+		}
+	}
+
+	return cfg, nil
+}
+
+// operatorMuteStage filters out the alerts muted by the active mute rules of the operator config.
+type operatorMuteStage struct {
+	rules []OperatorMuteRule
+}
+
+func newOperatorMuteStage(rules []OperatorMuteRule) *operatorMuteStage {
+	return &operatorMuteStage{rules: rules}
+}
+
+// Exec implements notify.Stage.
+func (s *operatorMuteStage) Exec(ctx context.Context, l log.Logger, alerts ...*types.Alert) (context.Context, []*types.Alert, error) {
+	now, ok := notify.Now(ctx)
+	if !ok {
+		now = time.Now()
+	}
+
+	var active []labels.Matchers
+	for _, r := range s.rules {
+		for _, ti := range r.TimeIntervals {
+			if ti.ContainsTime(now) {
+				active = append(active, labels.Matchers(r.Matchers))
+				break
+			}
+		}
+	}
+	if len(active) == 0 {
+		return ctx, alerts, nil
+	}
+
+	filtered := make([]*types.Alert, 0, len(alerts))
+	for _, a := range alerts {
+		muted := false
+		for _, ms := range active {
+			if ms.Matches(a.Labels) {
+				muted = true
+				break
+			}
+		}
+		if !muted {
+			filtered = append(filtered, a)
+		}
+	}
+
+	if len(filtered) < len(alerts) {
+		level.Debug(l).Log("msg", "notifications muted by the operator config", "muted", len(alerts)-len(filtered))
+	}
+
+	return ctx, filtered, nil
+}
@@ -0,0 +1,221 @@
+package alertmanager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
+)
+
+func TestLoadOperatorConfig(t *testing.T) {
+	for name, tc := range map[string]struct {
+		config string
+		err    string
+	}{
+		"valid config": {
+			config: `
+inhibit_rules:
+- source_matchers: ['alertname="PlatformDown"']
+  target_matchers: ['severity="warning"']
+mute_rules:
+- name: maintenance
+  matchers: ['cluster="eu-1"']
+  time_intervals:
+  - weekdays: ['saturday']
+    times:
+    - start_time: '02:00'
+      end_time: '04:00'
+`,
+		},
+		"empty config": {
+			config: ``,
+		},
+		"mute rule without name": {
+			config: `
+mute_rules:
+- time_intervals:
+  - weekdays: ['saturday']
+`,
+			err: "missing name in mute rule",
+		},
+		"duplicated mute rule": {
+			config: `
+mute_rules:
+- name: maintenance
+  time_intervals:
+  - weekdays: ['saturday']
+- name: maintenance
+  time_intervals:
+  - weekdays: ['sunday']
+`,
+			err: `mute rule "maintenance" is not unique`,
+		},
+		"mute rule without time intervals": {
+			config: `
+mute_rules:
+- name: maintenance
+`,
+			err: `mute rule "maintenance" has no time intervals`,
+		},
+		"alerts mirroring": {
+			config: `
+alerts_mirroring:
+  tenant: operations
+  rules:
+  - tenants: ['tenant-a']
+    matchers: ['severity="critical"']
+`,
+		},
+		"alerts mirroring without tenant": {
+			config: `
+alerts_mirroring:
+  rules:
+  - matchers: ['severity="critical"']
+`,
+			err: "missing tenant in alerts mirroring config",
+		},
+		"alerts mirroring with invalid tenant label": {
+			config: `
+alerts_mirroring:
+  tenant: operations
+  tenant_label: 'source-tenant'
+  rules:
+  - matchers: ['severity="critical"']
+`,
+			err: `invalid tenant label "source-tenant" in alerts mirroring config`,
+		},
+		"alerts mirroring without rules": {
+			config: `
+alerts_mirroring:
+  tenant: operations
+`,
+			err: "missing rules in alerts mirroring config",
+		},
+		"unknown field": {
+			config: `
+silences: []
+`,
+			err: "field silences not found",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadOperatorConfig([]byte(tc.config))
+			if tc.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
+
+func TestOperatorMuteStage(t *testing.T) {
+	cfg, err := LoadOperatorConfig([]byte(`
+mute_rules:
+- name: eu-maintenance
+  matchers: ['cluster="eu-1"']
+  time_intervals:
+  - weekdays: ['monday']
+- name: us-maintenance
+  matchers: ['cluster="us-1"']
+  time_intervals:
+  - weekdays: ['tuesday']
+`))
+	require.NoError(t, err)
+
+	euAlert := &types.Alert{Alert: model.Alert{Labels: model.LabelSet{"alertname": "a", "cluster": "eu-1"}}}
+	usAlert := &types.Alert{Alert: model.Alert{Labels: model.LabelSet{"alertname": "b", "cluster": "us-1"}}}
+
+	stage := newOperatorMuteStage(cfg.MuteRules)
+	exec := func(now time.Time) []*types.Alert {
+		ctx := notify.WithNow(context.Background(), now)
+		_, alerts, err := stage.Exec(ctx, log.NewNopLogger(), euAlert, usAlert)
+		require.NoError(t, err)
+		return alerts
+	}
+
+	monday := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, []*types.Alert{usAlert}, exec(monday))
+	assert.Equal(t, []*types.Alert{euAlert}, exec(monday.Add(24*time.Hour)))
+	assert.Equal(t, []*types.Alert{euAlert, usAlert}, exec(monday.Add(48*time.Hour)))
+}
+
+func TestMultitenantAlertmanager_OperatorConfig(t *testing.T) {
+	ctx := context.Background()
+
+	config := `route:
+  receiver: 'email'
+
+receivers:
+- name: 'email'
+  email_configs:
+  - to: test@example.com
+    from: test@example.com
+    smarthost: smtp:2525
+`
+
+	store := prepareInMemoryAlertStore()
+	require.NoError(t, store.SetAlertConfig(ctx, alertspb.AlertConfigDesc{
+		User:      "user",
+		RawConfig: config,
+		Templates: []*alertspb.TemplateDesc{},
+	}))
+
+	// The email notifications are rate-limited, to fail if not muted.
+	limits := mockAlertManagerLimits{
+		emailNotificationRateLimit: 0,
+		emailNotificationBurst:     0,
+	}
+
+	cfg := mockAlertmanagerConfig(t)
+	cfg.OperatorConfigFile = filepath.Join(t.TempDir(), "operator.yaml")
+	require.NoError(t, os.WriteFile(cfg.OperatorConfigFile, []byte(`
+mute_rules:
+- name: maintenance
+  matchers: ['cluster="eu-1"']
+  time_intervals:
+  - times:
+    - start_time: '00:00'
+      end_time: '24:00'
+`), 0644))
+
+	am, err := createMultitenantAlertmanager(cfg, nil, nil, store, nil, &limits, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+
+	require.NoError(t, am.loadAndSyncConfigs(ctx, reasonPeriodic))
+	am.alertmanagersMtx.Lock()
+	uam := am.alertmanagers["user"]
+	am.alertmanagersMtx.Unlock()
+	require.NotNil(t, uam)
+
+	ctx = notify.WithReceiverName(ctx, "email")
+	ctx = notify.WithGroupKey(ctx, "key")
+	ctx = notify.WithRepeatInterval(ctx, time.Minute)
+
+	// The alerts muted by the operator are not notified.
+	_, alerts, err := uam.lastPipeline.Exec(ctx, log.NewNopLogger(), &types.Alert{Alert: model.Alert{Labels: model.LabelSet{"cluster": "eu-1"}}})
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+
+	// The other alerts are notified.
+	_, _, err = uam.lastPipeline.Exec(ctx, log.NewNopLogger(), &types.Alert{Alert: model.Alert{Labels: model.LabelSet{"cluster": "us-1"}}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), errRateLimited.Error())
+
+	// An invalid operator config is rejected at startup.
+	require.NoError(t, os.WriteFile(cfg.OperatorConfigFile, []byte(`mute_rules: [{name: maintenance}]`), 0644))
+	_, err = createMultitenantAlertmanager(cfg, nil, nil, store, nil, &limits, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.Error(t, err)
+}
@@ -0,0 +1,141 @@
+package alertmanager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	amconfig "github.com/prometheus/alertmanager/config"
+	commoncfg "github.com/prometheus/common/config"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/secretstore"
+)
+
+// secretReferencePrefix is the prefix of the values of the secret fields of an Alertmanager configuration referencing
+// a secret of the external secret store, as secret://<name> or secret://<name>#<key>.
+const secretReferencePrefix = "secret://"
+
+var errSecretStoreDisabled = errors.New("the configuration references external secrets, but no receivers secrets backend is configured")
+
+// parseSecretReference returns the name and the optional key of the secret referenced by the given value.
+func parseSecretReference(value string) (name, key string, ok bool) {
+	if !strings.HasPrefix(value, secretReferencePrefix) {
+		return "", "", false
+	}
+	name, key, _ = strings.Cut(strings.TrimPrefix(value, secretReferencePrefix), "#")
+	return name, key, true
+}
+
+// resolveReceiverSecrets replaces the references to external secrets in the secret fields of the Alertmanager
+// configuration and its generic HTTP receivers (API keys, passwords, tokens, ...) with the values of the secrets of
+// the user, and returns a hash of these values, empty if the configuration references no secret.
+//
+// When the reference has a key, the value of the secret must be a JSON object and the value of the key is used.
+func resolveReceiverSecrets(ctx context.Context, store secretstore.SecretStore, userID string, cfg *amconfig.Config, httpConfigs []*HTTPReceiverConfig) (string, error) {
+	var (
+		hash     = sha256.New()
+		resolved = false
+	)
+
+	resolve := func(v reflect.Value) error {
+		name, key, ok := parseSecretReference(v.String())
+		if !ok {
+			return nil
+		}
+		if store == nil {
+			return errSecretStoreDisabled
+		}
+		if err := secretstore.ValidateName(name); err != nil {
+			return err
+		}
+
+		value, err := store.GetSecret(ctx, userID, name)
+		if err != nil {
+			return fmt.Errorf("unable to read the secret %q: %w", name, err)
+		}
+
+		if key != "" {
+			fields := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(value), &fields); err != nil {
+				return fmt.Errorf("the secret %q is not a JSON object: %w", name, err)
+			}
+			s, ok := fields[key].(string)
+			if !ok {
+				return fmt.Errorf("the secret %q has no string key %q", name, key)
+			}
+			value = s
+		}
+
+		v.SetString(value)
+		_, _ = hash.Write([]byte(name + "#" + key + "\xff" + value + "\xff"))
+		resolved = true
+		return nil
+	}
+
+	err := walkSecretFields(reflect.ValueOf(cfg), resolve)
+	if err == nil {
+		err = walkSecretFields(reflect.ValueOf(httpConfigs), resolve)
+	}
+	if err != nil || !resolved {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// validateReceiverSecrets checks that the secrets referenced by the receivers of the given Alertmanager
+// configuration can be resolved.
+func (am *MultitenantAlertmanager) validateReceiverSecrets(ctx context.Context, userID, rawCfg string) error {
+	cfg, httpConfigs, err := loadAlertmanagerConfig(rawCfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = resolveReceiverSecrets(ctx, am.secretStore, userID, cfg, httpConfigs)
+	return err
+}
+
+var (
+	secretType       = reflect.TypeOf(amconfig.Secret(""))
+	commonSecretType = reflect.TypeOf(commoncfg.Secret(""))
+)
+
+// walkSecretFields recursively calls fn with the settable secret fields of the given value. The secrets
+// in the values of maps are not walked.
+func walkSecretFields(v reflect.Value, fn func(v reflect.Value) error) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return walkSecretFields(v.Elem(), fn)
+
+	case reflect.String:
+		if (v.Type() == secretType || v.Type() == commonSecretType) && v.CanSet() {
+			return fn(v)
+		}
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Field(i); f.CanSet() {
+				if err := walkSecretFields(f, fn); err != nil {
+					return err
+				}
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := walkSecretFields(v.Index(i), fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package alertmanager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	amconfig "github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
+	"github.com/cortexproject/cortex/pkg/alertmanager/secretstore"
+)
+
+const receiverSecretsConfig = `route:
+  receiver: 'default'
+
+receivers:
+- name: 'default'
+  opsgenie_configs:
+  - api_key: secret://opsgenie#api_key
+  webhook_configs:
+  - url: http://webhook.example.com/hook
+    http_config:
+      authorization:
+        credentials: secret://webhook/token
+  pagerduty_configs:
+  - routing_key: plaintext
+`
+
+type mockSecretStore map[string]string
+
+func (m mockSecretStore) GetSecret(_ context.Context, userID, name string) (string, error) {
+	value, ok := m[userID+"/"+name]
+	if !ok {
+		return "", secretstore.ErrNotFound
+	}
+	return value, nil
+}
+
+func TestResolveReceiverSecrets(t *testing.T) {
+	ctx := context.Background()
+	store := mockSecretStore{
+		"user-1/opsgenie":      `{"api_key": "og-key"}`,
+		"user-1/webhook/token": "wh-token",
+	}
+
+	cfg, err := amconfig.Load(receiverSecretsConfig)
+	require.NoError(t, err)
+	hash, err := resolveReceiverSecrets(ctx, store, "user-1", cfg, nil)
+	require.NoError(t, err)
+	assert.NotEmpty(t, hash)
+
+	rcv := cfg.Receivers[0]
+	assert.Equal(t, amconfig.Secret("og-key"), rcv.OpsGenieConfigs[0].APIKey)
+	assert.Equal(t, "wh-token", string(rcv.WebhookConfigs[0].HTTPConfig.Authorization.Credentials))
+	assert.Equal(t, amconfig.Secret("plaintext"), rcv.PagerdutyConfigs[0].RoutingKey)
+
+	// The hash changes with the values of the secrets.
+	store["user-1/webhook/token"] = "wh-token-2"
+	cfg, err = amconfig.Load(receiverSecretsConfig)
+	require.NoError(t, err)
+	newHash, err := resolveReceiverSecrets(ctx, store, "user-1", cfg, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, newHash)
+
+	for name, tc := range map[string]struct {
+		store secretstore.SecretStore
+		user  string
+		err   string
+	}{
+		"secret of another tenant": {
+			store: store,
+			user:  "user-2",
+			err:   "secret not found",
+		},
+		"missing key": {
+			store: mockSecretStore{"user-1/opsgenie": `{"key": "og-key"}`, "user-1/webhook/token": "wh-token"},
+			user:  "user-1",
+			err:   `the secret "opsgenie" has no string key "api_key"`,
+		},
+		"not a JSON object": {
+			store: mockSecretStore{"user-1/opsgenie": "og-key", "user-1/webhook/token": "wh-token"},
+			user:  "user-1",
+			err:   `the secret "opsgenie" is not a JSON object`,
+		},
+		"no secret store": {
+			user: "user-1",
+			err:  errSecretStoreDisabled.Error(),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := amconfig.Load(receiverSecretsConfig)
+			require.NoError(t, err)
+			_, err = resolveReceiverSecrets(ctx, tc.store, tc.user, cfg, nil)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+
+	// The configurations without secret references don't need a secret store.
+	cfg, err = amconfig.Load(simpleConfigOne)
+	require.NoError(t, err)
+	hash, err = resolveReceiverSecrets(ctx, nil, "user-1", cfg, nil)
+	require.NoError(t, err)
+	assert.Empty(t, hash)
+}
+
+func TestResolveReceiverSecrets_HTTPConfigs(t *testing.T) {
+	ctx := context.Background()
+	store := mockSecretStore{
+		"user-1/tickets": `{"client_secret": "oauth2-secret", "hmac": "hmac-secret"}`,
+	}
+
+	cfg, httpConfigs, err := loadAlertmanagerConfig(`
+route:
+  receiver: tickets
+receivers:
+  - name: tickets
+    http_configs:
+      - url: https://tickets.example.com/api/issues
+        http_config:
+          oauth2:
+            client_id: alertmanager
+            client_secret: secret://tickets#client_secret
+            token_url: https://tickets.example.com/oauth2/token
+        hmac:
+          secret: secret://tickets#hmac
+`)
+	require.NoError(t, err)
+
+	hash, err := resolveReceiverSecrets(ctx, store, "user-1", cfg, httpConfigs)
+	require.NoError(t, err)
+	assert.NotEmpty(t, hash)
+	assert.Equal(t, "oauth2-secret", string(httpConfigs[0].HTTPConfig.OAuth2.ClientSecret))
+	assert.Equal(t, amconfig.Secret("hmac-secret"), httpConfigs[0].HMAC.Secret)
+}
+
+func TestMultitenantAlertmanager_ReceiverSecrets(t *testing.T) {
+	ctx := context.Background()
+
+	store := prepareInMemoryAlertStore()
+	require.NoError(t, store.SetAlertConfig(ctx, alertspb.AlertConfigDesc{
+		User:      "user-1",
+		RawConfig: receiverSecretsConfig,
+		Templates: []*alertspb.TemplateDesc{},
+	}))
+
+	dir := t.TempDir()
+	writeSecret := func(name, value string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, "user-1", name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "user-1", name), []byte(value), 0644))
+	}
+	writeSecret("opsgenie", `{"api_key": "og-key"}`)
+	writeSecret("webhook/token", "wh-token")
+
+	cfg := mockAlertmanagerConfig(t)
+	cfg.ReceiversSecrets = secretstore.Config{
+		Backend:    secretstore.FilesystemBackend,
+		Filesystem: secretstore.FilesystemConfig{Directory: dir},
+	}
+	am, err := createMultitenantAlertmanager(cfg, nil, nil, store, nil, nil, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+
+	require.NoError(t, am.loadAndSyncConfigs(ctx, reasonPeriodic))
+	require.Contains(t, am.alertmanagers, "user-1")
+	hash := am.cfgSecrets["user-1"]
+	require.NotEmpty(t, hash)
+
+	// The config is applied again when the value of a secret changes.
+	writeSecret("webhook/token", "wh-token-2")
+	require.NoError(t, am.loadAndSyncConfigs(ctx, reasonPeriodic))
+	assert.NotEqual(t, hash, am.cfgSecrets["user-1"])
+	hash = am.cfgSecrets["user-1"]
+
+	// The last working config keeps running when a secret can't be read.
+	require.NoError(t, os.Remove(filepath.Join(dir, "user-1", "opsgenie")))
+	require.NoError(t, am.loadAndSyncConfigs(ctx, reasonPeriodic))
+	require.Contains(t, am.alertmanagers, "user-1")
+	assert.Equal(t, hash, am.cfgSecrets["user-1"])
+
+	// The configs referencing missing secrets are rejected by the API.
+	err = am.validateReceiverSecrets(ctx, "user-1", receiverSecretsConfig)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), secretstore.ErrNotFound.Error())
+	require.NoError(t, am.validateReceiverSecrets(ctx, "user-2", simpleConfigOne))
+}
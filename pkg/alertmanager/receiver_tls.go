@@ -0,0 +1,98 @@
+package alertmanager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+
+	amconfig "github.com/prometheus/alertmanager/config"
+	commoncfg "github.com/prometheus/common/config"
+
+	util_net "github.com/cortexproject/cortex/pkg/util/net"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+var httpClientConfigType = reflect.TypeOf(&commoncfg.HTTPClientConfig{})
+
+// applyReceiversTLSConfigs sets the TLS config of the HTTP receivers of the Alertmanager configuration sending
+// notifications to the hosts of one of the given TLS configs, unless they have their own CA or client certificate.
+// The first matching TLS config is applied. It returns a hash of the applied TLS configs, empty if none is applied.
+func applyReceiversTLSConfigs(cfg *amconfig.Config, tlsConfigs []validation.AlertmanagerReceiverTLSConfig) string {
+	if len(tlsConfigs) == 0 {
+		return ""
+	}
+
+	var (
+		hash    = sha256.New()
+		applied = false
+	)
+
+	for k := range cfg.Receivers {
+		rcv := &cfg.Receivers[k]
+		v := reflect.ValueOf(rcv).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			integrations := v.Field(i)
+			if integrations.Kind() != reflect.Slice {
+				continue
+			}
+
+			for j := 0; j < integrations.Len(); j++ {
+				integration := reflect.Indirect(integrations.Index(j))
+				if integration.Kind() != reflect.Struct {
+					continue
+				}
+				field := integration.FieldByName("HTTPConfig")
+				if !field.IsValid() || field.Type() != httpClientConfigType || !field.CanSet() {
+					continue
+				}
+
+				httpConfig, _ := field.Interface().(*commoncfg.HTTPClientConfig)
+				if httpConfig != nil && hasOwnTLSConfig(httpConfig.TLSConfig) {
+					continue
+				}
+
+				tlsConfig, ok := matchReceiverTLSConfig(receiverHosts(integration.Interface(), nil), tlsConfigs)
+				if !ok {
+					continue
+				}
+
+				// The HTTP config may be shared with the defaults of the Alertmanager config, so it's copied.
+				updated := commoncfg.DefaultHTTPClientConfig
+				if httpConfig != nil {
+					updated = *httpConfig
+				}
+				updated.TLSConfig = tlsConfig.TLSConfig
+				field.Set(reflect.ValueOf(&updated))
+
+				_, _ = fmt.Fprintf(hash, "%s/%d/%d:%+v\xff", rcv.Name, i, j, tlsConfig)
+				applied = true
+			}
+		}
+	}
+
+	if !applied {
+		return ""
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// hasOwnTLSConfig returns whether the TLS config has a CA or a client certificate.
+func hasOwnTLSConfig(cfg commoncfg.TLSConfig) bool {
+	return cfg.CA != "" || cfg.CAFile != "" || cfg.Cert != "" || cfg.CertFile != "" || cfg.Key != "" || cfg.KeyFile != ""
+}
+
+// matchReceiverTLSConfig returns the first TLS config matching one of the hosts.
+func matchReceiverTLSConfig(hosts []string, tlsConfigs []validation.AlertmanagerReceiverTLSConfig) (validation.AlertmanagerReceiverTLSConfig, bool) {
+	for _, c := range tlsConfigs {
+		if len(c.Hosts) == 0 {
+			continue
+		}
+		for _, host := range hosts {
+			if util_net.IsHostAllowed(host, c.Hosts, nil) {
+				return c, true
+			}
+		}
+	}
+	return validation.AlertmanagerReceiverTLSConfig{}, false
+}
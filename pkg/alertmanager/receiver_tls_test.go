@@ -0,0 +1,152 @@
+package alertmanager
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	amconfig "github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/notify/webhook"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/alertmanager/types"
+	commoncfg "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/integration/ca"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+func TestApplyReceiversTLSConfigs(t *testing.T) {
+	const config = `route:
+  receiver: 'default'
+
+receivers:
+- name: 'default'
+  webhook_configs:
+  - url: https://alerts.internal.example.com/hook
+  - url: https://other.internal.example.com/hook
+    http_config:
+      tls_config:
+        ca: own-ca
+  - url: https://hooks.example.com/hook
+  pagerduty_configs:
+  - routing_key: key
+`
+	tlsConfigs := []validation.AlertmanagerReceiverTLSConfig{{
+		Hosts:     []string{"internal.example.com"},
+		TLSConfig: commoncfg.TLSConfig{CAFile: "/certs/ca.crt", CertFile: "/certs/client.crt", KeyFile: "/certs/client.key"},
+	}}
+	defaultTLSConfig := commoncfg.DefaultHTTPClientConfig.TLSConfig
+
+	cfg, err := amconfig.Load(config)
+	require.NoError(t, err)
+	hash := applyReceiversTLSConfigs(cfg, tlsConfigs)
+	assert.NotEmpty(t, hash)
+
+	webhooks := cfg.Receivers[0].WebhookConfigs
+	assert.Equal(t, tlsConfigs[0].TLSConfig, webhooks[0].HTTPConfig.TLSConfig)
+	assert.Equal(t, commoncfg.TLSConfig{CA: "own-ca"}, webhooks[1].HTTPConfig.TLSConfig)
+	assert.Equal(t, defaultTLSConfig, webhooks[2].HTTPConfig.TLSConfig)
+	assert.Equal(t, defaultTLSConfig, cfg.Receivers[0].PagerdutyConfigs[0].HTTPConfig.TLSConfig)
+
+	// The defaults of the Alertmanager config are not modified.
+	assert.Equal(t, defaultTLSConfig, commoncfg.DefaultHTTPClientConfig.TLSConfig)
+
+	// The hash changes with the applied TLS configs.
+	tlsConfigs[0].TLSConfig.ServerName = "alerts"
+	cfg, err = amconfig.Load(config)
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, applyReceiversTLSConfigs(cfg, tlsConfigs))
+
+	// The hash is empty when no TLS config is applied.
+	cfg, err = amconfig.Load(config)
+	require.NoError(t, err)
+	assert.Empty(t, applyReceiversTLSConfigs(cfg, nil))
+	assert.Empty(t, applyReceiversTLSConfigs(cfg, []validation.AlertmanagerReceiverTLSConfig{{Hosts: []string{"example.org"}}}))
+}
+
+func TestApplyReceiversTLSConfigs_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	serverCA, clientCA := ca.New("Server CA"), ca.New("Client CA")
+	files := map[string]string{}
+	for _, name := range []string{"server-ca.crt", "client-ca.crt", "server.crt", "server.key", "client.crt", "client.key"} {
+		files[name] = filepath.Join(dir, name)
+	}
+	require.NoError(t, serverCA.WriteCACertificate(files["server-ca.crt"]))
+	require.NoError(t, clientCA.WriteCACertificate(files["client-ca.crt"]))
+	require.NoError(t, serverCA.WriteCertificate(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "server"},
+		DNSNames:    []string{"localhost"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, files["server.crt"], files["server.key"]))
+	require.NoError(t, clientCA.WriteCertificate(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, files["client.crt"], files["client.key"]))
+
+	// The endpoint requires a client certificate issued by the client CA.
+	serverCert, err := tls.LoadX509KeyPair(files["server.crt"], files["server.key"])
+	require.NoError(t, err)
+	clientCAPEM, err := os.ReadFile(files["client-ca.crt"])
+	require.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	require.True(t, clientCAs.AppendCertsFromPEM(clientCAPEM))
+
+	received := make(chan string, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.TLS.PeerCertificates[0].Subject.CommonName
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	cfg, err := amconfig.Load(`route:
+  receiver: 'default'
+receivers:
+- name: 'default'
+  webhook_configs:
+  - url: https://localhost:` + serverURL.Port() + `/hook
+`)
+	require.NoError(t, err)
+
+	tmpl, err := template.FromGlobs(nil)
+	require.NoError(t, err)
+	tmpl.ExternalURL = &url.URL{}
+
+	notifyCtx := notify.WithGroupKey(context.Background(), "key")
+	alert := &types.Alert{Alert: model.Alert{Labels: model.LabelSet{"alertname": "test"}}}
+
+	// The notifications fail without the TLS config of the endpoint.
+	notifier, err := webhook.New(cfg.Receivers[0].WebhookConfigs[0], tmpl, log.NewNopLogger())
+	require.NoError(t, err)
+	_, err = notifier.Notify(notifyCtx, alert)
+	require.Error(t, err)
+
+	applyReceiversTLSConfigs(cfg, []validation.AlertmanagerReceiverTLSConfig{{
+		Hosts:     []string{"localhost"},
+		TLSConfig: commoncfg.TLSConfig{CAFile: files["server-ca.crt"], CertFile: files["client.crt"], KeyFile: files["client.key"]},
+	}})
+	notifier, err = webhook.New(cfg.Receivers[0].WebhookConfigs[0], tmpl, log.NewNopLogger())
+	require.NoError(t, err)
+	_, err = notifier.Notify(notifyCtx, alert)
+	require.NoError(t, err)
+	assert.Equal(t, "client", <-received)
+}
@@ -0,0 +1,62 @@
+package secretstore
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/pkg/errors"
+)
+
+// secretsManagerAPI is the subset of the AWS Secrets Manager API used by the store.
+type secretsManagerAPI interface {
+	GetSecretValueWithContext(ctx aws.Context, input *secretsmanager.GetSecretValueInput, opts ...request.Option) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// awsSecretsManagerStore reads the secrets from AWS Secrets Manager.
+type awsSecretsManagerStore struct {
+	cfg    AWSSecretsManagerConfig
+	client secretsManagerAPI
+}
+
+func newAWSSecretsManagerStore(cfg AWSSecretsManagerConfig) (*awsSecretsManagerStore, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+
+	awsCfg := aws.NewConfig()
+	if len(cfg.Region) > 0 {
+		awsCfg = awsCfg.WithRegion(cfg.Region)
+	}
+
+	return &awsSecretsManagerStore{
+		cfg:    cfg,
+		client: secretsmanager.New(sess, awsCfg),
+	}, nil
+}
+
+// GetSecret implements SecretStore. The value of a secret is the string of its current version.
+func (s *awsSecretsManagerStore) GetSecret(ctx context.Context, userID, name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+
+	out, err := s.client.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(s.cfg.NamePrefix + userID + "/" + name),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == secretsmanager.ErrCodeResourceNotFoundException {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if out.SecretString == nil {
+		return "", errors.New("binary secrets are not supported")
+	}
+
+	return aws.StringValue(out.SecretString), nil
+}
@@ -0,0 +1,108 @@
+package secretstore
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+const (
+	// FilesystemBackend reads the secrets from a directory, for example the Kubernetes secrets mounted as volumes.
+	FilesystemBackend = "filesystem"
+
+	// VaultBackend reads the secrets from a KV version 2 secrets engine of Vault.
+	VaultBackend = "vault"
+
+	// AWSSecretsManagerBackend reads the secrets from AWS Secrets Manager.
+	AWSSecretsManagerBackend = "aws-secrets-manager"
+)
+
+var (
+	supportedBackends = []string{FilesystemBackend, VaultBackend, AWSSecretsManagerBackend}
+
+	errUnsupportedBackend    = errors.New("unsupported receivers secrets backend")
+	errMissingDirectory      = errors.New("the filesystem receivers secrets backend requires a directory")
+	errMissingVaultAddress   = errors.New("the vault receivers secrets backend requires an address")
+	errInvalidCacheTTL       = errors.New("invalid receivers secrets cache TTL, must not be negative")
+	errMissingVaultMountPath = errors.New("the vault receivers secrets backend requires a mount path")
+)
+
+// Config configures the external store of the secrets referenced by the receivers of the Alertmanager configurations.
+type Config struct {
+	Backend  string        `yaml:"backend"`
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+
+	Filesystem        FilesystemConfig        `yaml:"filesystem"`
+	Vault             VaultConfig             `yaml:"vault"`
+	AWSSecretsManager AWSSecretsManagerConfig `yaml:"aws_secrets_manager"`
+}
+
+// FilesystemConfig configures the filesystem secrets backend.
+type FilesystemConfig struct {
+	Directory string `yaml:"directory"`
+}
+
+// VaultConfig configures the Vault secrets backend.
+type VaultConfig struct {
+	Address    string         `yaml:"address"`
+	Token      flagext.Secret `yaml:"token"`
+	MountPath  string         `yaml:"mount_path"`
+	PathPrefix string         `yaml:"path_prefix"`
+}
+
+// AWSSecretsManagerConfig configures the AWS Secrets Manager backend.
+type AWSSecretsManagerConfig struct {
+	Region     string `yaml:"region"`
+	NamePrefix string `yaml:"name_prefix"`
+}
+
+// RegisterFlagsWithPrefix registers the flags of the receivers secrets store with the given prefix.
+func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Backend, prefix+".backend", "", fmt.Sprintf("[Experimental] Backend of the secrets referenced by the receivers of the Alertmanager configurations, as secret://<name>. Supported values are: %s. The secret references are rejected when empty.", strings.Join(supportedBackends, ", ")))
+	f.DurationVar(&cfg.CacheTTL, prefix+".cache-ttl", 5*time.Minute, "[Experimental] How long the values of the secrets are cached. The Alertmanager configuration of a tenant is applied again when the value of one of its secrets changes. 0 to disable the cache.")
+
+	f.StringVar(&cfg.Filesystem.Directory, prefix+".filesystem.directory", "", "[Experimental] Directory of the secrets. The secret <name> of a tenant is read from the file <directory>/<tenant>/<name>.")
+
+	f.StringVar(&cfg.Vault.Address, prefix+".vault.address", "", "[Experimental] Address of the Vault server.")
+	f.Var(&cfg.Vault.Token, prefix+".vault.token", "[Experimental] Token used to authenticate to the Vault server.")
+	f.StringVar(&cfg.Vault.MountPath, prefix+".vault.mount-path", "secret", "[Experimental] Mount path of the KV version 2 secrets engine.")
+	f.StringVar(&cfg.Vault.PathPrefix, prefix+".vault.path-prefix", "", "[Experimental] Prefix of the path of the secrets in the secrets engine. The secret <name> of a tenant is read from the path <prefix>/<tenant>/<name>.")
+
+	f.StringVar(&cfg.AWSSecretsManager.Region, prefix+".aws-secrets-manager.region", "", "[Experimental] AWS region of the secrets. Defaults to the region of the AWS SDK configuration.")
+	f.StringVar(&cfg.AWSSecretsManager.NamePrefix, prefix+".aws-secrets-manager.name-prefix", "", "[Experimental] Prefix of the name of the secrets. The secret <name> of a tenant is read from the secret <prefix><tenant>/<name>.")
+}
+
+// Enabled returns whether an external secrets store is configured.
+func (cfg *Config) Enabled() bool {
+	return cfg.Backend != ""
+}
+
+// Validate the config.
+func (cfg *Config) Validate() error {
+	if cfg.CacheTTL < 0 {
+		return errInvalidCacheTTL
+	}
+
+	switch cfg.Backend {
+	case "", AWSSecretsManagerBackend:
+	case FilesystemBackend:
+		if cfg.Filesystem.Directory == "" {
+			return errMissingDirectory
+		}
+	case VaultBackend:
+		if cfg.Vault.Address == "" {
+			return errMissingVaultAddress
+		}
+		if cfg.Vault.MountPath == "" {
+			return errMissingVaultMountPath
+		}
+	default:
+		return errUnsupportedBackend
+	}
+	return nil
+}
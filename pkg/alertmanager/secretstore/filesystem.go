@@ -0,0 +1,34 @@
+package secretstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// filesystemStore reads the secrets from the files of a directory, one sub-directory per tenant.
+type filesystemStore struct {
+	cfg FilesystemConfig
+}
+
+func newFilesystemStore(cfg FilesystemConfig) *filesystemStore {
+	return &filesystemStore{cfg: cfg}
+}
+
+// GetSecret implements SecretStore. The trailing newline of the file is trimmed.
+func (s *filesystemStore) GetSecret(_ context.Context, userID, name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+
+	content, err := os.ReadFile(filepath.Join(s.cfg.Directory, userID, filepath.FromSlash(name)))
+	if os.IsNotExist(err) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(strings.TrimSuffix(string(content), "\n"), "\r"), nil
+}
@@ -0,0 +1,119 @@
+package secretstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is returned when the secret doesn't exist.
+	ErrNotFound = errors.New("secret not found")
+)
+
+// SecretStore reads the secrets referenced by the receivers of the Alertmanager configurations.
+// The secrets are scoped by tenant: a tenant can only read its own secrets.
+type SecretStore interface {
+	// GetSecret returns the value of the secret of the user with the given name.
+	GetSecret(ctx context.Context, userID, name string) (string, error)
+}
+
+// NewSecretStore returns the secret store of the configured backend, or nil if no backend is configured.
+func NewSecretStore(cfg Config) (SecretStore, error) {
+	var (
+		store SecretStore
+		err   error
+	)
+
+	switch cfg.Backend {
+	case "":
+		return nil, nil
+	case FilesystemBackend:
+		store = newFilesystemStore(cfg.Filesystem)
+	case VaultBackend:
+		store = newVaultStore(cfg.Vault)
+	case AWSSecretsManagerBackend:
+		store, err = newAWSSecretsManagerStore(cfg.AWSSecretsManager)
+	default:
+		return nil, errUnsupportedBackend
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.CacheTTL > 0 {
+		store = newCachingStore(store, cfg.CacheTTL)
+	}
+	return store, nil
+}
+
+// ValidateName validates the name of a secret, which is a relative path of one or more segments.
+func ValidateName(name string) error {
+	if name == "" {
+		return errors.New("empty secret name")
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("invalid secret name %q", name)
+		}
+	}
+	return nil
+}
+
+type cacheEntry struct {
+	value   string
+	expires time.Time
+}
+
+// cachingStore caches the values of the secrets read from the upstream store, to not read them every time the
+// Alertmanager configurations are synced. The errors are not cached.
+type cachingStore struct {
+	upstream SecretStore
+	ttl      time.Duration
+
+	mtx     sync.Mutex
+	entries map[string]cacheEntry
+}
+
+func newCachingStore(upstream SecretStore, ttl time.Duration) *cachingStore {
+	return &cachingStore{
+		upstream: upstream,
+		ttl:      ttl,
+		entries:  map[string]cacheEntry{},
+	}
+}
+
+// GetSecret implements SecretStore.
+func (s *cachingStore) GetSecret(ctx context.Context, userID, name string) (string, error) {
+	key := userID + "/" + name
+	now := time.Now()
+
+	s.mtx.Lock()
+	e, ok := s.entries[key]
+	s.mtx.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.value, nil
+	}
+
+	value, err := s.upstream.GetSecret(ctx, userID, name)
+	if err != nil {
+		return "", err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	// Remove the expired entries, so that the secrets no longer referenced don't stay in memory.
+	for k, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+	s.entries[key] = cacheEntry{value: value, expires: now.Add(s.ttl)}
+
+	return value, nil
+}
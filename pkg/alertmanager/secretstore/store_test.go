@@ -0,0 +1,187 @@
+package secretstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+func TestValidateName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"token":        true,
+		"slack/token":  true,
+		"":             false,
+		"/token":       false,
+		"slack/":       false,
+		"../token":     false,
+		"slack/./name": false,
+	} {
+		assert.Equal(t, valid, ValidateName(name) == nil, name)
+	}
+}
+
+func TestFilesystemStore(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "user-1", "slack"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "user-1", "slack", "token"), []byte("xoxb-1\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "user-2-token"), []byte("xoxb-2"), 0644))
+
+	store, err := NewSecretStore(Config{Backend: FilesystemBackend, Filesystem: FilesystemConfig{Directory: dir}})
+	require.NoError(t, err)
+
+	value, err := store.GetSecret(context.Background(), "user-1", "slack/token")
+	require.NoError(t, err)
+	assert.Equal(t, "xoxb-1", value)
+
+	_, err = store.GetSecret(context.Background(), "user-2", "slack/token")
+	assert.Equal(t, ErrNotFound, err)
+
+	// A tenant can't read the secrets outside of its directory.
+	_, err = store.GetSecret(context.Background(), "user-2", "../user-2-token")
+	assert.Error(t, err)
+	assert.NotEqual(t, ErrNotFound, err)
+}
+
+func TestVaultStore(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv/data/cortex/user-1/pagerduty":
+			_, _ = w.Write([]byte(`{"data": {"data": {"routing_key": "abc"}, "metadata": {"version": 2}}}`))
+		case "/v1/kv/data/cortex/user-1/deleted":
+			_, _ = w.Write([]byte(`{"data": {"data": null, "metadata": {"version": 3}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cfg := Config{Backend: VaultBackend, Vault: VaultConfig{
+		Address:    server.URL,
+		Token:      flagext.Secret{Value: "root"},
+		MountPath:  "kv",
+		PathPrefix: "cortex",
+	}}
+	store, err := NewSecretStore(cfg)
+	require.NoError(t, err)
+
+	value, err := store.GetSecret(context.Background(), "user-1", "pagerduty")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"routing_key": "abc"}`, value)
+
+	_, err = store.GetSecret(context.Background(), "user-1", "deleted")
+	assert.Equal(t, ErrNotFound, err)
+	_, err = store.GetSecret(context.Background(), "user-2", "pagerduty")
+	assert.Equal(t, ErrNotFound, err)
+
+	cfg.Vault.Token = flagext.Secret{Value: "invalid"}
+	store, err = NewSecretStore(cfg)
+	require.NoError(t, err)
+	_, err = store.GetSecret(context.Background(), "user-1", "pagerduty")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unexpected status code 403")
+}
+
+type mockSecretsManager struct {
+	secrets map[string]string
+}
+
+func (m *mockSecretsManager) GetSecretValueWithContext(_ aws.Context, input *secretsmanager.GetSecretValueInput, _ ...request.Option) (*secretsmanager.GetSecretValueOutput, error) {
+	value, ok := m.secrets[aws.StringValue(input.SecretId)]
+	if !ok {
+		return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(value)}, nil
+}
+
+func TestAWSSecretsManagerStore(t *testing.T) {
+	store := &awsSecretsManagerStore{
+		cfg:    AWSSecretsManagerConfig{NamePrefix: "cortex/"},
+		client: &mockSecretsManager{secrets: map[string]string{"cortex/user-1/opsgenie": "key"}},
+	}
+
+	value, err := store.GetSecret(context.Background(), "user-1", "opsgenie")
+	require.NoError(t, err)
+	assert.Equal(t, "key", value)
+
+	_, err = store.GetSecret(context.Background(), "user-2", "opsgenie")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+type countingStore struct {
+	values map[string]string
+	calls  int
+}
+
+func (s *countingStore) GetSecret(_ context.Context, userID, name string) (string, error) {
+	s.calls++
+	value, ok := s.values[userID+"/"+name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func TestCachingStore(t *testing.T) {
+	upstream := &countingStore{values: map[string]string{"user-1/token": "v1"}}
+	store := newCachingStore(upstream, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		value, err := store.GetSecret(context.Background(), "user-1", "token")
+		require.NoError(t, err)
+		assert.Equal(t, "v1", value)
+	}
+	assert.Equal(t, 1, upstream.calls)
+
+	// The errors are not cached.
+	for i := 0; i < 2; i++ {
+		_, err := store.GetSecret(context.Background(), "user-2", "token")
+		assert.Equal(t, ErrNotFound, err)
+	}
+	assert.Equal(t, 3, upstream.calls)
+
+	// The expired values are read again.
+	upstream.values["user-1/token"] = "v2"
+	store.ttl = 0
+	store.entries["user-1/token"] = cacheEntry{value: "v1", expires: time.Now().Add(-time.Second)}
+	value, err := store.GetSecret(context.Background(), "user-1", "token")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", value)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		cfg      Config
+		expected error
+	}{
+		"disabled":             {cfg: Config{}},
+		"aws secrets manager":  {cfg: Config{Backend: AWSSecretsManagerBackend}},
+		"unsupported backend":  {cfg: Config{Backend: "consul"}, expected: errUnsupportedBackend},
+		"negative cache TTL":   {cfg: Config{CacheTTL: -time.Second}, expected: errInvalidCacheTTL},
+		"filesystem":           {cfg: Config{Backend: FilesystemBackend, Filesystem: FilesystemConfig{Directory: "/secrets"}}},
+		"filesystem directory": {cfg: Config{Backend: FilesystemBackend}, expected: errMissingDirectory},
+		"vault":                {cfg: Config{Backend: VaultBackend, Vault: VaultConfig{Address: "http://vault:8200", MountPath: "secret"}}},
+		"vault address":        {cfg: Config{Backend: VaultBackend, Vault: VaultConfig{MountPath: "secret"}}, expected: errMissingVaultAddress},
+		"vault mount path":     {cfg: Config{Backend: VaultBackend, Vault: VaultConfig{Address: "http://vault:8200"}}, expected: errMissingVaultMountPath},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.cfg.Validate())
+		})
+	}
+}
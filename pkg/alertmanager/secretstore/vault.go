@@ -0,0 +1,71 @@
+package secretstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const vaultRequestTimeout = 10 * time.Second
+
+// vaultStore reads the secrets from a KV version 2 secrets engine of Vault, through its HTTP API.
+type vaultStore struct {
+	cfg    VaultConfig
+	client *http.Client
+}
+
+func newVaultStore(cfg VaultConfig) *vaultStore {
+	return &vaultStore{
+		cfg:    cfg,
+		client: &http.Client{Timeout: vaultRequestTimeout},
+	}
+}
+
+// GetSecret implements SecretStore. The value of a secret is the JSON object of the data of its latest version.
+func (s *vaultStore) GetSecret(ctx context.Context, userID, name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+
+	p := path.Join("/v1", s.cfg.MountPath, "data", s.cfg.PathPrefix, userID, name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(s.cfg.Address, "/")+(&url.URL{Path: p}).EscapedPath(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", s.cfg.Token.Value)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("unexpected status code %d reading the secret from vault: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var secret struct {
+		Data struct {
+			Data json.RawMessage `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", fmt.Errorf("unable to decode the secret read from vault: %w", err)
+	}
+	if len(secret.Data.Data) == 0 || string(secret.Data.Data) == "null" {
+		// The latest version of the secret has been deleted.
+		return "", ErrNotFound
+	}
+
+	return string(secret.Data.Data), nil
+}
@@ -0,0 +1,260 @@
+package alertmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/go-openapi/strfmt"
+	v2 "github.com/prometheus/alertmanager/api/v2"
+	open_api_models "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/alertmanager/matchers/compat"
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/alertmanager/silence/silencepb"
+	"github.com/prometheus/alertmanager/types"
+
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+const (
+	// bulkSilencesPath is the path, relative to the alertmanager API, of the bulk silences API.
+	bulkSilencesPath = "/api/v2/silences/bulk"
+
+	errBulkSilencesNoFilter = "at least one of the filter, created_by, start and end parameters is required to expire silences in bulk"
+)
+
+// BulkSilencesResponse is the response of the bulk silences API creating or expiring silences.
+type BulkSilencesResponse struct {
+	SilenceIDs []string `json:"silenceIDs"`
+}
+
+// silencesFilter selects the silences of the bulk silences API.
+type silencesFilter struct {
+	matchers  []*labels.Matcher
+	createdBy string
+	start     time.Time
+	end       time.Time
+	states    map[types.SilenceState]struct{}
+}
+
+// parseSilencesFilter parses the silences filter from the request parameters:
+//   - filter: label matchers the matchers of the silences must match, as in the silences API.
+//   - created_by: creator of the silences.
+//   - start and end: time window the silences must be active in, as a RFC3339 or Unix timestamp.
+//   - state: states of the silences (active, pending or expired).
+func parseSilencesFilter(r *http.Request) (silencesFilter, error) {
+	if err := r.ParseForm(); err != nil {
+		return silencesFilter{}, err
+	}
+
+	f := silencesFilter{createdBy: r.Form.Get("created_by")}
+	for _, s := range r.Form["filter"] {
+		m, err := compat.Matcher(s, "api")
+		if err != nil {
+			return silencesFilter{}, fmt.Errorf("invalid filter %q: %w", s, err)
+		}
+		f.matchers = append(f.matchers, m)
+	}
+
+	for param, t := range map[string]*time.Time{"start": &f.start, "end": &f.end} {
+		if s := r.Form.Get(param); s != "" {
+			ms, err := util.ParseTime(s)
+			if err != nil {
+				return silencesFilter{}, fmt.Errorf("invalid %s %q: %w", param, s, err)
+			}
+			*t = util.TimeFromMillis(ms)
+		}
+	}
+	if !f.start.IsZero() && !f.end.IsZero() && f.end.Before(f.start) {
+		return silencesFilter{}, fmt.Errorf("the end %s is before the start %s", f.end, f.start)
+	}
+
+	for _, s := range r.Form["state"] {
+		state := types.SilenceState(s)
+		if state != types.SilenceStateActive && state != types.SilenceStatePending && state != types.SilenceStateExpired {
+			return silencesFilter{}, fmt.Errorf("invalid state %q", s)
+		}
+		if f.states == nil {
+			f.states = map[types.SilenceState]struct{}{}
+		}
+		f.states[state] = struct{}{}
+	}
+
+	return f, nil
+}
+
+// empty returns whether the filter selects the silences regardless of their matchers, creator and time window.
+func (f silencesFilter) empty() bool {
+	return len(f.matchers) == 0 && f.createdBy == "" && f.start.IsZero() && f.end.IsZero()
+}
+
+func (f silencesFilter) matches(s *silencepb.Silence) bool {
+	if f.createdBy != "" && s.CreatedBy != f.createdBy {
+		return false
+	}
+	if !f.start.IsZero() && s.EndsAt.Before(f.start) {
+		return false
+	}
+	if !f.end.IsZero() && s.StartsAt.After(f.end) {
+		return false
+	}
+	if f.states != nil {
+		if _, ok := f.states[types.CalcSilenceState(s.StartsAt, s.EndsAt)]; !ok {
+			return false
+		}
+	}
+	return v2.CheckSilenceMatchesFilterLabels(s, f.matchers)
+}
+
+// serveBulkSilences serves the bulk silences API, to query, create and expire many silences of the tenant at once.
+func (am *Alertmanager) serveBulkSilences(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		am.getSilencesBulk(w, r)
+	case http.MethodPost:
+		am.createSilencesBulk(w, r)
+	case http.MethodDelete:
+		am.expireSilencesBulk(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getSilencesBulk returns the silences selected by the filter of the request, in the format of the silences API.
+func (am *Alertmanager) getSilencesBulk(w http.ResponseWriter, r *http.Request) {
+	f, err := parseSilencesFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	all, _, err := am.silences.Query()
+	if err != nil {
+		level.Error(am.logger).Log("msg", "failed to query silences", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sils := open_api_models.GettableSilences{}
+	for _, s := range all {
+		if !f.matches(s) {
+			continue
+		}
+		sil, err := v2.GettableSilenceFromProto(s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sils = append(sils, &sil)
+	}
+	v2.SortSilences(sils)
+
+	writeBulkSilencesJSON(w, sils)
+}
+
+// createSilencesBulk creates or updates the silences of the request body, a JSON list of silences in the
+// format of the silences API. The silences are all validated before any of them is created.
+func (am *Alertmanager) createSilencesBulk(w http.ResponseWriter, r *http.Request) {
+	var postable []*open_api_models.PostableSilence
+	if err := json.NewDecoder(r.Body).Decode(&postable); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode the silences: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	sils := make([]*silencepb.Silence, 0, len(postable))
+	for i, p := range postable {
+		if p == nil {
+			http.Error(w, fmt.Sprintf("silence %d: empty silence", i), http.StatusBadRequest)
+			return
+		}
+		if err := p.Validate(strfmt.Default); err != nil {
+			http.Error(w, fmt.Sprintf("silence %d: %s", i, err.Error()), http.StatusBadRequest)
+			return
+		}
+		sil, err := v2.PostableSilenceToProto(p)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("silence %d: %s", i, err.Error()), http.StatusBadRequest)
+			return
+		}
+		if !sil.StartsAt.Before(sil.EndsAt) {
+			http.Error(w, fmt.Sprintf("silence %d: start time must be before end time", i), http.StatusBadRequest)
+			return
+		}
+		if sil.EndsAt.Before(now) {
+			http.Error(w, fmt.Sprintf("silence %d: end time can't be in the past", i), http.StatusBadRequest)
+			return
+		}
+		sils = append(sils, sil)
+	}
+
+	newSilences := 0
+	for _, sil := range sils {
+		if am.isNewSilence(sil.Id) {
+			newSilences++
+		}
+	}
+	if err := am.checkSilencesLimit(newSilences); err != nil {
+		level.Warn(am.logger).Log("msg", "failed to create silences in bulk", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := BulkSilencesResponse{SilenceIDs: make([]string, 0, len(sils))}
+	for i, sil := range sils {
+		id, err := am.silences.Set(sil)
+		if err != nil {
+			level.Warn(am.logger).Log("msg", "failed to create silence in bulk", "created", len(resp.SilenceIDs), "err", err)
+			http.Error(w, fmt.Sprintf("silence %d: failed to create the silence, %d previous silences created: %s", i, len(resp.SilenceIDs), err.Error()), http.StatusBadRequest)
+			return
+		}
+		resp.SilenceIDs = append(resp.SilenceIDs, id)
+	}
+
+	writeBulkSilencesJSON(w, resp)
+}
+
+// expireSilencesBulk expires the silences, not expired yet, selected by the filter of the request.
+func (am *Alertmanager) expireSilencesBulk(w http.ResponseWriter, r *http.Request) {
+	f, err := parseSilencesFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Guard against expiring all the silences of the tenant by mistake.
+	if f.empty() {
+		http.Error(w, errBulkSilencesNoFilter, http.StatusBadRequest)
+		return
+	}
+
+	all, _, err := am.silences.Query()
+	if err != nil {
+		level.Error(am.logger).Log("msg", "failed to query silences", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := BulkSilencesResponse{SilenceIDs: []string{}}
+	for _, s := range all {
+		if types.CalcSilenceState(s.StartsAt, s.EndsAt) == types.SilenceStateExpired || !f.matches(s) {
+			continue
+		}
+		if err := am.silences.Expire(s.Id); err != nil {
+			level.Warn(am.logger).Log("msg", "failed to expire silence in bulk", "expired", len(resp.SilenceIDs), "err", err)
+			http.Error(w, fmt.Sprintf("failed to expire the silence %s, %d previous silences expired: %s", s.Id, len(resp.SilenceIDs), err.Error()), http.StatusInternalServerError)
+			return
+		}
+		resp.SilenceIDs = append(resp.SilenceIDs, s.Id)
+	}
+
+	writeBulkSilencesJSON(w, resp)
+}
+
+func writeBulkSilencesJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
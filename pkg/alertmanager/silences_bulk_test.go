@@ -0,0 +1,107 @@
+package alertmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	open_api_models "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/alertmanager/silence"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertmanager_BulkSilences(t *testing.T) {
+	am, err := New(&Config{
+		UserID:        "user-1",
+		Logger:        log.NewNopLogger(),
+		Limits:        &mockAlertManagerLimits{},
+		TenantDataDir: t.TempDir(),
+		ExternalURL:   &url.URL{Path: "/am"},
+		GCInterval:    30 * time.Minute,
+	}, prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+	defer am.StopAndWait()
+
+	do := func(t *testing.T, method, query, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/am/api/v2/silences/bulk"+query, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		am.mux.ServeHTTP(w, req)
+		return w
+	}
+	silenceIDs := func(t *testing.T, w *httptest.ResponseRecorder) []string {
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		resp := BulkSilencesResponse{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.SilenceIDs
+	}
+
+	now := time.Now().UTC()
+	postable := func(service, createdBy string, startsAt time.Time) string {
+		return fmt.Sprintf(`{"matchers":[{"name":"service","value":%q,"isRegex":false}],"startsAt":%q,"endsAt":%q,"createdBy":%q,"comment":"incident"}`,
+			service, startsAt.Format(time.RFC3339), startsAt.Add(time.Hour).Format(time.RFC3339), createdBy)
+	}
+
+	// The silences are created in bulk.
+	created := silenceIDs(t, do(t, http.MethodPost, "", "["+strings.Join([]string{
+		postable("api", "alice", now),
+		postable("db", "alice", now),
+		postable("api", "bob", now.Add(2*time.Hour)),
+	}, ",")+"]"))
+	require.Len(t, created, 3)
+
+	// None of the silences is created if any of them is invalid.
+	w := do(t, http.MethodPost, "", "["+postable("api", "alice", now)+`,{"matchers":[],"startsAt":"2020-01-01T00:00:00Z","endsAt":"2020-01-01T01:00:00Z","createdBy":"alice","comment":"x"}]`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	_, count, err := am.silences.Query()
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+
+	query := func(t *testing.T, params string) []string {
+		w := do(t, http.MethodGet, params, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		sils := open_api_models.GettableSilences{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sils))
+		ids := make([]string, 0, len(sils))
+		for _, s := range sils {
+			ids = append(ids, *s.ID)
+		}
+		return ids
+	}
+
+	// The silences are queried by matcher, creator and time window.
+	assert.ElementsMatch(t, created, query(t, ""))
+	assert.ElementsMatch(t, []string{created[0], created[2]}, query(t, "?filter="+url.QueryEscape(`service="api"`)))
+	assert.ElementsMatch(t, []string{created[0], created[1]}, query(t, "?created_by=alice"))
+	assert.ElementsMatch(t, []string{created[2]}, query(t, "?start="+url.QueryEscape(now.Add(90*time.Minute).Format(time.RFC3339))))
+	assert.ElementsMatch(t, []string{created[2]}, query(t, "?state=pending"))
+	assert.Equal(t, http.StatusBadRequest, do(t, http.MethodGet, "?state=unknown", "").Code)
+
+	// The silences aren't expired in bulk without any filter.
+	assert.Equal(t, http.StatusBadRequest, do(t, http.MethodDelete, "", "").Code)
+
+	// The silences are expired in bulk.
+	expired := silenceIDs(t, do(t, http.MethodDelete, "?created_by=alice", ""))
+	assert.ElementsMatch(t, []string{created[0], created[1]}, expired)
+
+	for i, id := range created {
+		sil, err := am.silences.QueryOne(silence.QIDs(id))
+		require.NoError(t, err)
+		if i < 2 {
+			assert.Equal(t, types.SilenceStateExpired, types.CalcSilenceState(sil.StartsAt, sil.EndsAt))
+		} else {
+			assert.Equal(t, types.SilenceStatePending, types.CalcSilenceState(sil.StartsAt, sil.EndsAt))
+		}
+	}
+
+	// The silences already expired aren't expired again.
+	assert.Empty(t, silenceIDs(t, do(t, http.MethodDelete, "?created_by=alice", "")))
+}
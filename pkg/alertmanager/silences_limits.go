@@ -0,0 +1,205 @@
+package alertmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/matttproud/golang_protobuf_extensions/pbutil"
+	open_api_models "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/alertmanager/silence"
+	"github.com/prometheus/alertmanager/silence/silencepb"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// silencesPath is the path, relative to the alertmanager API, of the silences API.
+	silencesPath = "/api/v2/silences"
+
+	// silencesPurgePeriod is how often the expired silences exceeding the retention policies of the tenant are purged.
+	silencesPurgePeriod = time.Minute
+
+	// silencePurgeDelay is how long the purged silences are kept, in the state of the replicas, before being
+	// garbage collected.
+	silencePurgeDelay = time.Second
+
+	errTooManySilences = "too many silences, limit: %d"
+)
+
+// silencesLimiter enforces the silences quota and retention policies of a tenant.
+type silencesLimiter struct {
+	insertLimited prometheus.Counter
+	purged        prometheus.Counter
+}
+
+func newSilencesLimiter(reg prometheus.Registerer) *silencesLimiter {
+	return &silencesLimiter{
+		insertLimited: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "alertmanager_silences_insert_limited_total",
+			Help: "Number of silences that were not created because the silences limit of the tenant was reached.",
+		}),
+		purged: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "alertmanager_silences_purged_total",
+			Help: "Number of expired silences purged by the silences retention policies of the tenant.",
+		}),
+	}
+}
+
+// checkSilencesLimit returns an error if creating the new silences would exceed the limit of active and pending
+// silences of the tenant.
+func (am *Alertmanager) checkSilencesLimit(newSilences int) error {
+	if am.cfg.Limits == nil || newSilences == 0 {
+		return nil
+	}
+	limit := am.cfg.Limits.AlertmanagerMaxSilencesCount(am.cfg.UserID)
+	if limit <= 0 {
+		return nil
+	}
+
+	count, err := am.silences.CountState(types.SilenceStateActive, types.SilenceStatePending)
+	if err != nil {
+		return err
+	}
+	if count+newSilences > limit {
+		am.silencesLimiter.insertLimited.Add(float64(newSilences))
+		return fmt.Errorf(errTooManySilences, limit)
+	}
+	return nil
+}
+
+// isNewSilence returns whether the silence of the ID would be created, rather than updated.
+func (am *Alertmanager) isNewSilence(id string) bool {
+	if id == "" {
+		return true
+	}
+	_, err := am.silences.QueryOne(silence.QIDs(id))
+	return err != nil
+}
+
+// silencesLimitHandler wraps the handler of the silences API to enforce the silences limit of the tenant when
+// silences are created.
+func (am *Alertmanager) silencesLimitHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// The invalid silences are reported by the silences API.
+		var sil open_api_models.PostableSilence
+		if err := json.Unmarshal(body, &sil); err == nil && am.isNewSilence(sil.ID) {
+			if err := am.checkSilencesLimit(1); err != nil {
+				level.Warn(am.logger).Log("msg", "failed to create silence", "err", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// registerSilencesLimitHandler wraps the silences API of the mux with the silences limit.
+func (am *Alertmanager) registerSilencesLimitHandler() {
+	p := path.Join(am.cfg.ExternalURL.Path, silencesPath)
+	next, _ := am.mux.Handler(&http.Request{Method: http.MethodPost, URL: &url.URL{Path: p}})
+	am.mux.Handle(p, am.silencesLimitHandler(next))
+}
+
+// purgeSilencesLoop periodically purges the expired silences exceeding the retention policies of the tenant.
+func (am *Alertmanager) purgeSilencesLoop() {
+	defer am.wg.Done()
+
+	t := time.NewTicker(silencesPurgePeriod)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-am.stop:
+			return
+		case <-t.C:
+			// Garbage collect the silences purged during the previous run.
+			if _, err := am.silences.GC(); err != nil {
+				level.Warn(am.logger).Log("msg", "failed to garbage collect silences", "err", err)
+			}
+			if err := am.purgeSilences(time.Now()); err != nil {
+				level.Warn(am.logger).Log("msg", "failed to purge expired silences", "err", err)
+			}
+		}
+	}
+}
+
+// purgeSilences purges the expired silences older than the max age of the expired silences of the tenant and, while
+// the tenant has more silences than its max total silences, the oldest expired silences. The purged silences are
+// marked to expire from the state shortly, and the change is replicated to the other replicas of the tenant.
+func (am *Alertmanager) purgeSilences(now time.Time) error {
+	if am.cfg.Limits == nil {
+		return nil
+	}
+	maxAge := am.cfg.Limits.AlertmanagerExpiredSilencesMaxAge(am.cfg.UserID)
+	maxTotal := am.cfg.Limits.AlertmanagerMaxTotalSilences(am.cfg.UserID)
+	if maxAge <= 0 && maxTotal <= 0 {
+		return nil
+	}
+
+	all, _, err := am.silences.Query()
+	if err != nil {
+		return err
+	}
+
+	var expired []*silencepb.Silence
+	for _, s := range all {
+		if types.CalcSilenceState(s.StartsAt, s.EndsAt) == types.SilenceStateExpired {
+			expired = append(expired, s)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].EndsAt.Before(expired[j].EndsAt)
+	})
+
+	excess := 0
+	if maxTotal > 0 && len(all) > maxTotal {
+		excess = len(all) - maxTotal
+	}
+
+	var buf bytes.Buffer
+	purged := 0
+	for i, s := range expired {
+		if i >= excess && (maxAge <= 0 || now.Sub(s.EndsAt) <= maxAge) {
+			break
+		}
+
+		sil := *s
+		sil.UpdatedAt = now
+		if _, err := pbutil.WriteDelimited(&buf, &silencepb.MeshSilence{Silence: &sil, ExpiresAt: now.Add(silencePurgeDelay)}); err != nil {
+			return err
+		}
+		purged++
+	}
+	if purged == 0 {
+		return nil
+	}
+
+	if err := am.silences.Merge(buf.Bytes()); err != nil {
+		return err
+	}
+	am.silencesLimiter.purged.Add(float64(purged))
+	level.Debug(am.logger).Log("msg", "purged expired silences", "purged", purged)
+	return nil
+}
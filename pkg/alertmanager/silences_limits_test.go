@@ -0,0 +1,163 @@
+package alertmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/matttproud/golang_protobuf_extensions/pbutil"
+	v2_operations "github.com/prometheus/alertmanager/api/v2/restapi/operations/silence"
+	"github.com/prometheus/alertmanager/silence"
+	"github.com/prometheus/alertmanager/silence/silencepb"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertmanager_SilencesLimit(t *testing.T) {
+	limits := &mockAlertManagerLimits{maxSilencesCount: 2}
+	am, err := New(&Config{
+		UserID:        "user-1",
+		Logger:        log.NewNopLogger(),
+		Limits:        limits,
+		TenantDataDir: t.TempDir(),
+		ExternalURL:   &url.URL{Path: "/am"},
+		GCInterval:    30 * time.Minute,
+	}, prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+	defer am.StopAndWait()
+
+	do := func(t *testing.T, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/am"+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		am.mux.ServeHTTP(w, req)
+		return w
+	}
+
+	now := time.Now().UTC()
+	postable := func(id, service string) string {
+		return fmt.Sprintf(`{"id":%q,"matchers":[{"name":"service","value":%q,"isRegex":false}],"startsAt":%q,"endsAt":%q,"createdBy":"alice","comment":"incident"}`,
+			id, service, now.Format(time.RFC3339), now.Add(time.Hour).Format(time.RFC3339))
+	}
+
+	// The silences are created up to the limit.
+	w := do(t, silencesPath, postable("", "api"))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	created := v2_operations.PostSilencesOKBody{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	w = do(t, silencesPath, postable("", "db"))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = do(t, silencesPath, postable("", "web"))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "too many silences, limit: 2")
+
+	w = do(t, bulkSilencesPath, "["+postable("", "web")+"]")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "too many silences, limit: 2")
+
+	// The existing silences can still be updated.
+	w = do(t, silencesPath, postable(created.SilenceID, "api-v2"))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	count, err := am.silences.CountState(types.SilenceStateActive, types.SilenceStatePending)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, float64(2), testutil.ToFloat64(am.silencesLimiter.insertLimited))
+
+	// The silences can be created again once the limit is raised.
+	limits.maxSilencesCount = 0
+	w = do(t, silencesPath, postable("", "web"))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestAlertmanager_PurgeSilences(t *testing.T) {
+	for name, tc := range map[string]struct {
+		maxAge         time.Duration
+		maxTotal       int
+		expectedPurged []string
+	}{
+		"no retention policy": {},
+		"max age of the expired silences": {
+			maxAge:         time.Hour,
+			expectedPurged: []string{"expired-old", "expired-older"},
+		},
+		"max total silences": {
+			maxTotal:       3,
+			expectedPurged: []string{"expired-older"},
+		},
+		"max total silences below the active silences": {
+			maxTotal:       1,
+			expectedPurged: []string{"expired-old", "expired-older", "expired-recent"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			limits := &mockAlertManagerLimits{expiredSilencesMaxAge: tc.maxAge, maxTotalSilences: tc.maxTotal}
+			am, err := New(&Config{
+				UserID:        "user-1",
+				Logger:        log.NewNopLogger(),
+				Limits:        limits,
+				TenantDataDir: t.TempDir(),
+				ExternalURL:   &url.URL{Path: "/am"},
+				GCInterval:    30 * time.Minute,
+			}, prometheus.NewPedanticRegistry())
+			require.NoError(t, err)
+			defer am.StopAndWait()
+
+			// The expired silences are merged as the Alertmanager doesn't create silences ending in the past.
+			now := time.Now()
+			var buf bytes.Buffer
+			for name, endsAt := range map[string]time.Time{
+				"expired-older":  now.Add(-3 * time.Hour),
+				"expired-old":    now.Add(-2 * time.Hour),
+				"expired-recent": now.Add(-time.Minute),
+				"active":         now.Add(time.Hour),
+			} {
+				_, err := pbutil.WriteDelimited(&buf, &silencepb.MeshSilence{
+					Silence: &silencepb.Silence{
+						Id:        name,
+						Matchers:  []*silencepb.Matcher{{Name: "name", Pattern: name}},
+						StartsAt:  now.Add(-4 * time.Hour),
+						EndsAt:    endsAt,
+						UpdatedAt: now.Add(-4 * time.Hour),
+						CreatedBy: "alice",
+						Comment:   name,
+					},
+					ExpiresAt: endsAt.Add(24 * time.Hour),
+				})
+				require.NoError(t, err)
+			}
+			require.NoError(t, am.silences.Merge(buf.Bytes()))
+
+			require.NoError(t, am.purgeSilences(now))
+
+			var purged []string
+			require.Eventually(t, func() bool {
+				// The purged silences are garbage collected once their purge delay has elapsed.
+				_, err := am.silences.GC()
+				require.NoError(t, err)
+
+				purged = nil
+				for _, name := range []string{"expired-older", "expired-old", "expired-recent", "active"} {
+					if _, err := am.silences.QueryOne(silence.QIDs(name)); err != nil {
+						purged = append(purged, name)
+					}
+				}
+				return len(purged) == len(tc.expectedPurged)
+			}, 5*time.Second, 100*time.Millisecond)
+			assert.ElementsMatch(t, tc.expectedPurged, purged)
+			assert.Equal(t, float64(len(tc.expectedPurged)), testutil.ToFloat64(am.silencesLimiter.purged))
+		})
+	}
+}
@@ -3,6 +3,7 @@ package alertmanager
 import (
 	"context"
 	"flag"
+	"strconv"
 	"time"
 
 	"github.com/go-kit/log"
@@ -22,21 +23,35 @@ const (
 )
 
 var (
-	errInvalidPersistInterval = errors.New("invalid alertmanager persist interval, must be greater than zero")
+	errInvalidPersistInterval   = errors.New("invalid alertmanager persist interval, must be greater than zero")
+	errInvalidSnapshotRetention = errors.New("invalid alertmanager state snapshot retention, must be greater than zero when the state snapshots are enabled")
+	errInvalidSnapshotInterval  = errors.New("invalid alertmanager state snapshot interval, must be greater than or equal to zero")
 )
 
 type PersisterConfig struct {
-	Interval time.Duration `yaml:"persist_interval"`
+	Interval            time.Duration `yaml:"persist_interval"`
+	SnapshotInterval    time.Duration `yaml:"state_snapshot_interval"`
+	SnapshotRetention   time.Duration `yaml:"state_snapshot_retention"`
+	FallbackReplication bool          `yaml:"state_fallback_replication_enabled"`
 }
 
 func (cfg *PersisterConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	f.DurationVar(&cfg.Interval, prefix+".persist-interval", 15*time.Minute, "The interval between persisting the current alertmanager state (notification log and silences) to object storage. This is only used when sharding is enabled. This state is read when all replicas for a shard can not be contacted. In this scenario, having persisted the state more frequently will result in potentially fewer lost silences, and fewer duplicate notifications.")
+	f.DurationVar(&cfg.SnapshotInterval, prefix+".state-snapshot-interval", 0, "[Experimental] The interval between snapshots of the alertmanager state (notification log and silences) kept in object storage, which can be restored through the state snapshots API. The snapshots are taken while persisting the state, so the interval is rounded up to a multiple of the persist interval. This is only used when sharding is enabled. 0 to disable.")
+	f.DurationVar(&cfg.SnapshotRetention, prefix+".state-snapshot-retention", 7*24*time.Hour, "[Experimental] How long the snapshots of the alertmanager state are kept in object storage.")
+	f.BoolVar(&cfg.FallbackReplication, prefix+".state-fallback-replication-enabled", false, "[Experimental] Replicate the alertmanager state (notification log and silences) through object storage when the replication to the other replicas fails, for the state of the replicas to converge after a network partition. Every replica merges the persisted state into its own state at each persist interval, and the replicas which failed to replicate their state persist it as well. This is only used when sharding is enabled.")
 }
 
 func (cfg *PersisterConfig) Validate() error {
 	if cfg.Interval <= 0 {
 		return errInvalidPersistInterval
 	}
+	if cfg.SnapshotInterval < 0 {
+		return errInvalidSnapshotInterval
+	}
+	if cfg.SnapshotInterval > 0 && cfg.SnapshotRetention <= 0 {
+		return errInvalidSnapshotRetention
+	}
 	return nil
 }
 
@@ -45,6 +60,15 @@ type PersistableState interface {
 	GetFullState() (*clusterpb.FullState, error)
 }
 
+// fallbackReplicatedState is a state which can be replicated through the persistent storage, when
+// replicating it to the other replicas fails.
+type fallbackReplicatedState interface {
+	// MergeFullState merges the persisted full state with the state.
+	MergeFullState(*clusterpb.FullState) error
+	// ReplicationFailed returns whether replicating the state to the other replicas failed since the last call.
+	ReplicationFailed() bool
+}
+
 // statePersister periodically writes the alertmanager state to persistent storage.
 type statePersister struct {
 	services.Service
@@ -56,19 +80,34 @@ type statePersister struct {
 
 	timeout time.Duration
 
-	persistTotal  prometheus.Counter
-	persistFailed prometheus.Counter
+	snapshotInterval  time.Duration
+	snapshotRetention time.Duration
+	lastSnapshot      time.Time
+	lastSnapshotKnown bool
+
+	fallbackReplication bool
+
+	persistTotal              prometheus.Counter
+	persistFailed             prometheus.Counter
+	snapshotTotal             prometheus.Counter
+	snapshotFailed            prometheus.Counter
+	fallbackReplicationTotal  prometheus.Counter
+	fallbackReplicationFailed prometheus.Counter
 }
 
 // newStatePersister creates a new state persister.
 func newStatePersister(cfg PersisterConfig, userID string, state PersistableState, store alertstore.AlertStore, l log.Logger, r prometheus.Registerer) *statePersister {
 
 	s := &statePersister{
-		state:   state,
-		store:   store,
-		userID:  userID,
-		logger:  l,
-		timeout: defaultPersistTimeout,
+		state:             state,
+		store:             store,
+		userID:            userID,
+		logger:            l,
+		timeout:           defaultPersistTimeout,
+		snapshotInterval:  cfg.SnapshotInterval,
+		snapshotRetention: cfg.SnapshotRetention,
+
+		fallbackReplication: cfg.FallbackReplication,
 		persistTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
 			Name: "alertmanager_state_persist_total",
 			Help: "Number of times we have tried to persist the running state to remote storage.",
@@ -77,6 +116,22 @@ func newStatePersister(cfg PersisterConfig, userID string, state PersistableStat
 			Name: "alertmanager_state_persist_failed_total",
 			Help: "Number of times we have failed to persist the running state to remote storage.",
 		}),
+		snapshotTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "alertmanager_state_snapshot_total",
+			Help: "Number of times we have tried to snapshot the running state to remote storage.",
+		}),
+		snapshotFailed: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "alertmanager_state_snapshot_failed_total",
+			Help: "Number of times we have failed to snapshot the running state to remote storage.",
+		}),
+		fallbackReplicationTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "alertmanager_state_fallback_replication_total",
+			Help: "Number of times we have tried to merge the state persisted in remote storage with the running state.",
+		}),
+		fallbackReplicationFailed: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "alertmanager_state_fallback_replication_failed_total",
+			Help: "Number of times we have failed to merge the state persisted in remote storage with the running state.",
+		}),
 	}
 
 	s.Service = services.NewTimerService(cfg.Interval, s.starting, s.iteration, nil)
@@ -98,11 +153,25 @@ func (s *statePersister) iteration(ctx context.Context) error {
 }
 
 func (s *statePersister) persist(ctx context.Context) (err error) {
-	// Only the replica at position zero should write the state.
-	if s.state.Position() != 0 {
+	replicationFailed := false
+	if fs, ok := s.state.(fallbackReplicatedState); ok && s.fallbackReplication {
+		replicationFailed = fs.ReplicationFailed()
+		if err := s.mergePersistedState(ctx, fs); err != nil {
+			level.Error(s.logger).Log("msg", "failed to merge persisted state", "user", s.userID, "err", err)
+		}
+	}
+
+	// Only the replica at position zero should write the state, unless the replica failed to replicate
+	// its state to the other replicas.
+	if s.state.Position() != 0 && !replicationFailed {
 		return nil
 	}
 
+	return s.write(ctx)
+}
+
+// write writes the full state to the persistent storage, and snapshots it.
+func (s *statePersister) write(ctx context.Context) (err error) {
 	s.persistTotal.Inc()
 	defer func() {
 		if err != nil {
@@ -126,5 +195,100 @@ func (s *statePersister) persist(ctx context.Context) (err error) {
 		return err
 	}
 
+	if err := s.snapshot(ctx, desc, time.Now()); err != nil {
+		level.Error(s.logger).Log("msg", "failed to snapshot state", "user", s.userID, "err", err)
+	}
+
 	return nil
 }
+
+// mergePersistedState merges the state persisted by the other replicas with the running state, for the state
+// of the replicas to converge when the replication between them fails.
+func (s *statePersister) mergePersistedState(ctx context.Context, state fallbackReplicatedState) (err error) {
+	s.fallbackReplicationTotal.Inc()
+	defer func() {
+		if err != nil {
+			s.fallbackReplicationFailed.Inc()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var desc alertspb.FullStateDesc
+	desc, err = s.store.GetFullState(ctx, s.userID)
+	if errors.Is(err, alertspb.ErrNotFound) {
+		return nil
+	}
+	if err != nil || desc.State == nil {
+		return err
+	}
+
+	return state.MergeFullState(desc.State)
+}
+
+// snapshot stores a snapshot of the persisted state, if the snapshot interval elapsed since the
+// last one, and deletes the snapshots older than the retention.
+func (s *statePersister) snapshot(ctx context.Context, desc alertspb.FullStateDesc, now time.Time) (err error) {
+	if s.snapshotInterval <= 0 {
+		return nil
+	}
+
+	// The last snapshot may have been taken by another replica, or before a restart.
+	if !s.lastSnapshotKnown {
+		names, err := s.store.ListFullStateSnapshots(ctx, s.userID)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if ts, ok := ParseStateSnapshotName(name); ok && ts.After(s.lastSnapshot) {
+				s.lastSnapshot = ts
+			}
+		}
+		s.lastSnapshotKnown = true
+	}
+
+	if now.Sub(s.lastSnapshot) < s.snapshotInterval {
+		return nil
+	}
+
+	s.snapshotTotal.Inc()
+	defer func() {
+		if err != nil {
+			s.snapshotFailed.Inc()
+		}
+	}()
+
+	if err = s.store.SetFullStateSnapshot(ctx, s.userID, StateSnapshotName(now), desc); err != nil {
+		return err
+	}
+	s.lastSnapshot = now
+
+	names, err := s.store.ListFullStateSnapshots(ctx, s.userID)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if ts, ok := ParseStateSnapshotName(name); ok && now.Sub(ts) > s.snapshotRetention {
+			if err = s.store.DeleteFullStateSnapshot(ctx, s.userID, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// StateSnapshotName returns the name of the snapshot of the alertmanager state taken at the given time.
+func StateSnapshotName(ts time.Time) string {
+	return strconv.FormatInt(ts.Unix(), 10)
+}
+
+// ParseStateSnapshotName returns the time at which the snapshot of the alertmanager state with the given name was taken.
+func ParseStateSnapshotName(name string) (time.Time, bool) {
+	secs, err := strconv.ParseInt(name, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(secs, 0), true
+}
@@ -10,9 +10,11 @@ import (
 	"github.com/prometheus/alertmanager/cluster/clusterpb"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
 
 	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
 	"github.com/cortexproject/cortex/pkg/alertmanager/alertstore"
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertstore/bucketclient"
 	"github.com/cortexproject/cortex/pkg/util/services"
 )
 
@@ -155,3 +157,100 @@ func TestStatePersister_Position1ShouldNotWrite(t *testing.T) {
 		assert.Equal(t, 0, len(store.getWrites()))
 	}
 }
+
+func TestStatePersister_Snapshot(t *testing.T) {
+	ctx := context.Background()
+	store := bucketclient.NewBucketAlertStore(objstore.NewInMemBucket(), nil, log.NewNopLogger())
+	cfg := PersisterConfig{Interval: time.Minute, SnapshotInterval: time.Hour, SnapshotRetention: 3 * time.Hour}
+	desc := alertspb.FullStateDesc{State: makeTestFullState()}
+
+	listSnapshots := func(t *testing.T) []string {
+		names, err := store.ListFullStateSnapshots(ctx, "user-1")
+		require.NoError(t, err)
+		return names
+	}
+
+	// A snapshot taken by another replica is taken into account.
+	start := time.Unix(10000, 0)
+	require.NoError(t, store.SetFullStateSnapshot(ctx, "user-1", StateSnapshotName(start), desc))
+
+	s := newStatePersister(cfg, "user-1", newFakePersistableState(), store, log.NewNopLogger(), nil)
+	require.NoError(t, s.snapshot(ctx, desc, start.Add(30*time.Minute)))
+	assert.Equal(t, []string{"10000"}, listSnapshots(t))
+
+	// A snapshot is taken once the interval elapsed since the last one.
+	for i := 1; i <= 4; i++ {
+		require.NoError(t, s.snapshot(ctx, desc, start.Add(time.Duration(i)*time.Hour)))
+	}
+	res, err := store.GetFullStateSnapshot(ctx, "user-1", "13600")
+	require.NoError(t, err)
+	assert.Equal(t, desc, res)
+
+	// The snapshots older than the retention are deleted.
+	assert.ElementsMatch(t, []string{"13600", "17200", "20800", "24400"}, listSnapshots(t))
+
+	// The snapshots are disabled by default.
+	s = newStatePersister(PersisterConfig{Interval: time.Minute}, "user-2", newFakePersistableState(), store, log.NewNopLogger(), nil)
+	require.NoError(t, s.snapshot(ctx, desc, start))
+	names, err := store.ListFullStateSnapshots(ctx, "user-2")
+	require.NoError(t, err)
+	assert.Empty(t, names)
+}
+
+type fakeFallbackReplicatedState struct {
+	*fakePersistableState
+
+	merged            []*clusterpb.FullState
+	replicationFailed bool
+}
+
+func (f *fakeFallbackReplicatedState) MergeFullState(fs *clusterpb.FullState) error {
+	f.merged = append(f.merged, fs)
+	return nil
+}
+
+func (f *fakeFallbackReplicatedState) ReplicationFailed() bool {
+	failed := f.replicationFailed
+	f.replicationFailed = false
+	return failed
+}
+
+func TestStatePersister_FallbackReplication(t *testing.T) {
+	ctx := context.Background()
+	store := bucketclient.NewBucketAlertStore(objstore.NewInMemBucket(), nil, log.NewNopLogger())
+
+	persisted := &clusterpb.FullState{Parts: []clusterpb.Part{{Key: "key", Data: []byte("persisted")}}}
+	running := &clusterpb.FullState{Parts: []clusterpb.Part{{Key: "key", Data: []byte("running")}}}
+	require.NoError(t, store.SetFullState(ctx, "user-1", alertspb.FullStateDesc{State: persisted}))
+
+	state := &fakeFallbackReplicatedState{fakePersistableState: newFakePersistableState()}
+	state.position = 1
+	state.getResult = running
+
+	getPersisted := func(t *testing.T) *clusterpb.FullState {
+		desc, err := store.GetFullState(ctx, "user-1")
+		require.NoError(t, err)
+		return desc.State
+	}
+
+	// The persisted state isn't merged when the fallback replication is disabled.
+	s := newStatePersister(PersisterConfig{Interval: time.Minute}, "user-1", state, store, log.NewNopLogger(), nil)
+	state.replicationFailed = true
+	require.NoError(t, s.persist(ctx))
+	assert.Empty(t, state.merged)
+	assert.Equal(t, persisted, getPersisted(t))
+
+	// The persisted state is merged by the replicas not at position zero, which don't write their state
+	// if the replication succeeded.
+	s = newStatePersister(PersisterConfig{Interval: time.Minute, FallbackReplication: true}, "user-1", state, store, log.NewNopLogger(), nil)
+	state.replicationFailed = false
+	require.NoError(t, s.persist(ctx))
+	assert.Equal(t, []*clusterpb.FullState{persisted}, state.merged)
+	assert.Equal(t, persisted, getPersisted(t))
+
+	// The replicas failing to replicate their state write it.
+	state.replicationFailed = true
+	require.NoError(t, s.persist(ctx))
+	assert.Equal(t, []*clusterpb.FullState{persisted, persisted}, state.merged)
+	assert.Equal(t, running, getPersisted(t))
+}
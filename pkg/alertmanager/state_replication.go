@@ -15,6 +15,7 @@ import (
 	"github.com/prometheus/alertmanager/cluster"
 	"github.com/prometheus/alertmanager/cluster/clusterpb"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/atomic"
 
 	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
 	"github.com/cortexproject/cortex/pkg/alertmanager/alertstore"
@@ -51,6 +52,9 @@ type state struct {
 	replicator        Replicator
 	store             alertstore.AlertStore
 
+	// Whether replicating the state to the other replicas failed since the last check.
+	replicationFailed atomic.Bool
+
 	partialStateMergesTotal  *prometheus.CounterVec
 	partialStateMergesFailed *prometheus.CounterVec
 	stateReplicationTotal    *prometheus.CounterVec
@@ -191,6 +195,16 @@ func (s *state) GetFullState() (*clusterpb.FullState, error) {
 	return all, nil
 }
 
+// MergeFullState merges a full state, read from the persistent storage, with the internal state.
+func (s *state) MergeFullState(fs *clusterpb.FullState) error {
+	return s.mergeFullStates([]*clusterpb.FullState{fs})
+}
+
+// ReplicationFailed returns whether replicating the state to the other replicas failed since the last call.
+func (s *state) ReplicationFailed() bool {
+	return s.replicationFailed.Swap(false)
+}
+
 // starting waits until the alertmanagers are ready (and sets the appropriate internal state when it is).
 // The idea is that we don't want to start working" before we get a chance to know most of the notifications and/or silences.
 func (s *state) starting(ctx context.Context) error {
@@ -298,6 +312,7 @@ func (s *state) running(ctx context.Context) error {
 			s.stateReplicationTotal.WithLabelValues(stateType).Inc()
 			if err := s.replicator.ReplicateStateForUser(ctx, s.userID, p); err != nil {
 				s.stateReplicationFailed.WithLabelValues(stateType).Inc()
+				s.replicationFailed.Store(true)
 				level.Error(s.logger).Log("msg", "failed to replicate state to other alertmanagers", "user", s.userID, "key", p.Key, "err", err)
 			}
 		case <-ctx.Done():
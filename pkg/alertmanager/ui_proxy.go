@@ -0,0 +1,78 @@
+package alertmanager
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/go-kit/log/level"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+)
+
+// uiProxy serves the Alertmanager UI and API of the tenant given in the URL path, as <prefix>/<tenant>/, for browsers
+// which can't set the tenant header of the requests. The request must be authenticated for the tenant: the tenant
+// must be one of the tenants of the request (for example set by an authenticating proxy as tenant-1|tenant-2),
+// so that a single ingress can serve the UI of every tenant.
+type uiProxy struct {
+	am     *MultitenantAlertmanager
+	prefix string
+}
+
+// UIProxyHandler returns the handler of the Alertmanager UI proxy served under the given path prefix.
+func (am *MultitenantAlertmanager) UIProxyHandler(prefix string) http.Handler {
+	return &uiProxy{am: am, prefix: strings.TrimSuffix(prefix, "/")}
+}
+
+func (p *uiProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), p.am.logger)
+
+	rest, ok := strings.CutPrefix(r.URL.Path, p.prefix+"/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	tenantID, subPath, hasSubPath := strings.Cut(rest, "/")
+	if tenantID == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if !hasSubPath {
+		// The UI uses relative URLs, which require the trailing slash.
+		http.Redirect(w, r, p.prefix+"/"+tenantID+"/", http.StatusMovedPermanently)
+		return
+	}
+
+	// The tenants of the request are split regardless of the tenant federation.
+	authenticated, err := tenant.NewMultiResolver().TenantIDs(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	allowed := false
+	for _, id := range authenticated {
+		if id == tenantID {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		level.Warn(logger).Log("msg", "the request is not authenticated for the tenant of the alertmanager UI proxy", "tenant", tenantID)
+		http.Error(w, "the request is not authenticated for the tenant "+tenantID, http.StatusForbidden)
+		return
+	}
+
+	// Serve the request as if it was sent to the Alertmanager of the tenant.
+	req := r.Clone(user.InjectOrgID(r.Context(), tenantID))
+	req.Header.Set(user.OrgIDHeaderName, tenantID)
+	req.URL.Path = path.Join("/", p.am.cfg.ExternalURL.Path, subPath)
+	if (subPath == "" || strings.HasSuffix(subPath, "/")) && !strings.HasSuffix(req.URL.Path, "/") {
+		req.URL.Path += "/"
+	}
+	req.URL.RawPath = ""
+	req.RequestURI = req.URL.RequestURI()
+
+	p.am.ServeHTTP(w, req)
+}
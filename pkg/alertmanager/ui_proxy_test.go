@@ -0,0 +1,72 @@
+package alertmanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+func TestMultitenantAlertmanager_UIProxy(t *testing.T) {
+	ctx := context.Background()
+	store := prepareInMemoryAlertStore()
+	for _, userID := range []string{"user-1", "user-2"} {
+		require.NoError(t, store.SetAlertConfig(ctx, alertspb.AlertConfigDesc{
+			User:      userID,
+			RawConfig: fmt.Sprintf("route:\n  receiver: receiver-%s\nreceivers:\n  - name: receiver-%s", userID, userID),
+			Templates: []*alertspb.TemplateDesc{},
+		}))
+	}
+
+	cfg := mockAlertmanagerConfig(t)
+	cfg.ExternalURL = flagext.URLValue{}
+	require.NoError(t, cfg.ExternalURL.Set("http://localhost:8080/alertmanager"))
+
+	am, err := createMultitenantAlertmanager(cfg, nil, nil, store, nil, nil, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(ctx, am))
+	defer services.StopAndAwaitTerminated(ctx, am) //nolint:errcheck
+
+	proxy := am.UIProxyHandler("/alertmanager/tenants/")
+	serve := func(orgID, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if orgID != "" {
+			req = req.WithContext(user.InjectOrgID(req.Context(), orgID))
+		}
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The UI of the tenant is served under its path.
+	rec := serve("user-1|user-2", "/alertmanager/tenants/user-1")
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(t, "/alertmanager/tenants/user-1/", rec.Header().Get("Location"))
+
+	rec = serve("user-1|user-2", "/alertmanager/tenants/user-1/")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "<html")
+
+	// The API requests of the UI are served by the Alertmanager of the tenant.
+	for _, userID := range []string{"user-1", "user-2"} {
+		rec = serve("user-1|user-2", "/alertmanager/tenants/"+userID+"/api/v2/status")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Contains(t, rec.Body.String(), "receiver-"+userID)
+	}
+
+	// The requests not authenticated for the tenant are rejected.
+	assert.Equal(t, http.StatusForbidden, serve("user-1", "/alertmanager/tenants/user-2/api/v2/status").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve("", "/alertmanager/tenants/user-1/api/v2/status").Code)
+	assert.Equal(t, http.StatusNotFound, serve("user-1", "/alertmanager/tenants/").Code)
+}
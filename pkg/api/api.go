@@ -35,6 +35,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/ruler"
 	"github.com/cortexproject/cortex/pkg/scheduler"
 	"github.com/cortexproject/cortex/pkg/scheduler/schedulerpb"
+	"github.com/cortexproject/cortex/pkg/storage/tsdb"
 	"github.com/cortexproject/cortex/pkg/storegateway"
 	"github.com/cortexproject/cortex/pkg/storegateway/storegatewaypb"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
@@ -206,7 +207,7 @@ func (a *API) RegisterRoutesWithPrefix(prefix string, handler http.Handler, auth
 
 // RegisterAlertmanager registers endpoints associated with the alertmanager. It will only
 // serve endpoints using the legacy http-prefix if it is not run as a single binary.
-func (a *API) RegisterAlertmanager(am *alertmanager.MultitenantAlertmanager, target, apiEnabled bool) {
+func (a *API) RegisterAlertmanager(am *alertmanager.MultitenantAlertmanager, target, apiEnabled, uiProxyEnabled bool) {
 	alertmanagerpb.RegisterAlertmanagerServer(a.server.GRPC, am)
 
 	a.indexPage.AddLink(SectionAdminEndpoints, "/multitenant_alertmanager/status", "Alertmanager Status")
@@ -215,7 +216,17 @@ func (a *API) RegisterAlertmanager(am *alertmanager.MultitenantAlertmanager, tar
 	a.RegisterRoute("/multitenant_alertmanager/status", am.GetStatusHandler(), false, "GET")
 	a.RegisterRoute("/multitenant_alertmanager/configs", http.HandlerFunc(am.ListAllConfigs), false, "GET")
 	a.RegisterRoute("/multitenant_alertmanager/ring", http.HandlerFunc(am.RingHandler), false, "GET", "POST")
+	a.RegisterRoute("/multitenant_alertmanager/prepare-shutdown", http.HandlerFunc(am.PrepareShutdownHandler), false, "GET", "POST", "DELETE")
+	a.RegisterRoute("/multitenant_alertmanager/prepare-downscale", http.HandlerFunc(am.PrepareDownscaleHandler), false, "GET", "POST", "DELETE")
 	a.RegisterRoute("/multitenant_alertmanager/delete_tenant_config", http.HandlerFunc(am.DeleteUserConfig), true, "POST")
+	a.RegisterRoute("/multitenant_alertmanager/state_snapshots", http.HandlerFunc(am.ListUserStateSnapshots), true, "GET")
+	a.RegisterRoute("/multitenant_alertmanager/restore_state_snapshot", http.HandlerFunc(am.RestoreUserStateSnapshot), true, "POST")
+
+	// The UI proxy is served under the same path prefix, so it must be registered first.
+	if uiProxyEnabled {
+		uiProxyPrefix := a.cfg.AlertmanagerHTTPPrefix + "/tenants/"
+		a.RegisterRoutesWithPrefix(uiProxyPrefix, am.UIProxyHandler(uiProxyPrefix), true)
+	}
 
 	// UI components lead to a large number of routes to support, utilize a path prefix instead
 	a.RegisterRoutesWithPrefix(a.cfg.AlertmanagerHTTPPrefix, am, true)
@@ -226,6 +237,13 @@ func (a *API) RegisterAlertmanager(am *alertmanager.MultitenantAlertmanager, tar
 		a.RegisterRoute("/api/v1/alerts", http.HandlerFunc(am.GetUserConfig), true, "GET")
 		a.RegisterRoute("/api/v1/alerts", http.HandlerFunc(am.SetUserConfig), true, "POST")
 		a.RegisterRoute("/api/v1/alerts", http.HandlerFunc(am.DeleteUserConfig), true, "DELETE")
+		a.RegisterRoute("/api/v1/alerts/history", http.HandlerFunc(am.GetUserAlertHistory), true, "GET")
+		a.RegisterRoute("/api/v1/alerts/receivers/{receiver}/test", http.HandlerFunc(am.TestUserReceiver), true, "POST")
+		a.RegisterRoute("/api/v1/alerts/templates", http.HandlerFunc(am.ListUserTemplates), true, "GET")
+		a.RegisterRoute("/api/v1/alerts/templates/{name}", http.HandlerFunc(am.GetUserTemplate), true, "GET")
+		a.RegisterRoute("/api/v1/alerts/templates/{name}", http.HandlerFunc(am.SetUserTemplate), true, "POST")
+		a.RegisterRoute("/api/v1/alerts/templates/{name}", http.HandlerFunc(am.DeleteUserTemplate), true, "DELETE")
+		a.RegisterRoute("/api/v1/alerts/templates/{name}/validate", http.HandlerFunc(am.ValidateUserTemplate), true, "POST")
 	}
 
 	// If the target is Alertmanager, enable the legacy behaviour. Otherwise only enable
@@ -283,6 +301,8 @@ type Ingester interface {
 	client.IngesterServer
 	FlushHandler(http.ResponseWriter, *http.Request)
 	ShutdownHandler(http.ResponseWriter, *http.Request)
+	PrepareShutdownHandler(http.ResponseWriter, *http.Request)
+	PrepareDownscaleHandler(http.ResponseWriter, *http.Request)
 	Push(context.Context, *cortexpb.WriteRequest) (*cortexpb.WriteResponse, error)
 }
 
@@ -294,6 +314,8 @@ func (a *API) RegisterIngester(i Ingester, pushConfig distributor.Config) {
 	a.indexPage.AddLink(SectionDangerous, "/ingester/shutdown", "Trigger Ingester Shutdown (Dangerous)")
 	a.RegisterRoute("/ingester/flush", http.HandlerFunc(i.FlushHandler), false, "GET", "POST")
 	a.RegisterRoute("/ingester/shutdown", http.HandlerFunc(i.ShutdownHandler), false, "GET", "POST")
+	a.RegisterRoute("/ingester/prepare-shutdown", http.HandlerFunc(i.PrepareShutdownHandler), false, "GET", "POST", "DELETE")
+	a.RegisterRoute("/ingester/prepare-downscale", http.HandlerFunc(i.PrepareDownscaleHandler), false, "GET", "POST", "DELETE")
 	a.RegisterRoute("/ingester/push", push.Handler(pushConfig.MaxRecvMsgSize, a.sourceIPs, i.Push), true, "POST") // For testing and debugging.
 
 	// Legacy Routes
@@ -307,10 +329,29 @@ func (a *API) RegisterTenantDeletion(api *purger.TenantDeletionAPI) {
 	a.RegisterRoute("/purger/delete_tenant_status", http.HandlerFunc(api.DeleteTenantStatus), true, "GET")
 }
 
+func (a *API) RegisterBlocksPurger(api *purger.BlocksPurgerAPI) {
+	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/admin/tsdb/delete_series"), http.HandlerFunc(api.AddDeleteRequestHandler), true, "PUT", "POST")
+	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/admin/tsdb/delete_series"), http.HandlerFunc(api.GetAllDeleteRequestsHandler), true, "GET")
+	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/admin/tsdb/cancel_delete_request"), http.HandlerFunc(api.CancelDeleteRequestHandler), true, "PUT", "POST")
+
+	// Legacy Routes
+	a.RegisterRoute(path.Join(a.cfg.LegacyHTTPPrefix, "/api/v1/admin/tsdb/delete_series"), http.HandlerFunc(api.AddDeleteRequestHandler), true, "PUT", "POST")
+	a.RegisterRoute(path.Join(a.cfg.LegacyHTTPPrefix, "/api/v1/admin/tsdb/delete_series"), http.HandlerFunc(api.GetAllDeleteRequestsHandler), true, "GET")
+	a.RegisterRoute(path.Join(a.cfg.LegacyHTTPPrefix, "/api/v1/admin/tsdb/cancel_delete_request"), http.HandlerFunc(api.CancelDeleteRequestHandler), true, "PUT", "POST")
+}
+
+// RegisterCacheGeneration registers the routes of the cache generation API, bumping the cache generation of the tenants.
+func (a *API) RegisterCacheGeneration(api *purger.CacheGenerationAPI) {
+	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/admin/cache/generation"), http.HandlerFunc(api.BumpCacheGeneration), true, "POST")
+	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/admin/cache/generation"), http.HandlerFunc(api.CacheGeneration), true, "GET")
+}
+
 // RegisterRuler registers routes associated with the Ruler service.
 func (a *API) RegisterRuler(r *ruler.Ruler) {
 	a.indexPage.AddLink(SectionAdminEndpoints, "/ruler/ring", "Ruler Ring Status")
 	a.RegisterRoute("/ruler/ring", r, false, "GET", "POST")
+	a.RegisterRoute("/ruler/prepare-shutdown", http.HandlerFunc(r.PrepareShutdownHandler), false, "GET", "POST", "DELETE")
+	a.RegisterRoute("/ruler/prepare-downscale", http.HandlerFunc(r.PrepareDownscaleHandler), false, "GET", "POST", "DELETE")
 
 	// Administrative API, uses authentication to inform which user's configuration to delete.
 	a.RegisterRoute("/ruler/delete_tenant_config", http.HandlerFunc(r.DeleteTenantConfiguration), true, "POST")
@@ -324,6 +365,11 @@ func (a *API) RegisterRuler(r *ruler.Ruler) {
 	ruler.RegisterRulerServer(a.server.GRPC, r)
 }
 
+// RegisterRulerSyncNotifications registers the route receiving the notifications of changes to the rules bucket.
+func (a *API) RegisterRulerSyncNotifications(r *ruler.Ruler) {
+	a.RegisterRoute("/ruler/sync_notification", http.HandlerFunc(r.SyncNotification), false, "POST")
+}
+
 // RegisterRulerAPI registers routes associated with the Ruler API
 func (a *API) RegisterRulerAPI(r *ruler.API) {
 	// Prometheus Rule API Routes
@@ -337,6 +383,7 @@ func (a *API) RegisterRulerAPI(r *ruler.API) {
 	a.RegisterRoute("/api/v1/rules/{namespace}", http.HandlerFunc(r.CreateRuleGroup), true, "POST")
 	a.RegisterRoute("/api/v1/rules/{namespace}/{groupName}", http.HandlerFunc(r.DeleteRuleGroup), true, "DELETE")
 	a.RegisterRoute("/api/v1/rules/{namespace}", http.HandlerFunc(r.DeleteNamespace), true, "DELETE")
+	a.RegisterRoute("/api/v1/rules_import/{namespace}", http.HandlerFunc(r.ImportRules), true, "POST")
 
 	// Legacy Prometheus Rule API Routes
 	a.RegisterRoute(path.Join(a.cfg.LegacyHTTPPrefix, "/api/v1/rules"), http.HandlerFunc(r.PrometheusRules), true, "GET")
@@ -351,6 +398,29 @@ func (a *API) RegisterRulerAPI(r *ruler.API) {
 	a.RegisterRoute(path.Join(a.cfg.LegacyHTTPPrefix, "/rules/{namespace}"), http.HandlerFunc(r.DeleteNamespace), true, "DELETE")
 }
 
+// RegisterRulerBacktester registers the route of the rules backtesting API.
+func (a *API) RegisterRulerBacktester(b *ruler.Backtester) {
+	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/rules/backtest"), http.HandlerFunc(b.BacktestRule), true, "POST")
+}
+
+// RegisterRulerDryRun registers the route of the rule groups dry-run API.
+func (a *API) RegisterRulerDryRun(b *ruler.Backtester) {
+	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/rules/dry_run"), http.HandlerFunc(b.DryRunRuleGroup), true, "POST")
+}
+
+// RegisterRulerRuleTest registers the route of the rule unit tests API.
+func (a *API) RegisterRulerRuleTest(b *ruler.Backtester) {
+	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/rules/test"), http.HandlerFunc(b.TestRules), true, "POST")
+}
+
+// RegisterRulerVersionsAPI registers the routes of the rules versioning API.
+func (a *API) RegisterRulerVersionsAPI(v *ruler.RulesVersionsAPI) {
+	a.RegisterRoute("/api/v1/rules_versions/{namespace}", http.HandlerFunc(v.ListVersions), true, "GET")
+	a.RegisterRoute("/api/v1/rules_versions/{namespace}/{version}", http.HandlerFunc(v.GetVersion), true, "GET")
+	a.RegisterRoute("/api/v1/rules_versions/{namespace}/{version}/diff", http.HandlerFunc(v.DiffVersion), true, "GET")
+	a.RegisterRoute("/api/v1/rules_versions/{namespace}/{version}/rollback", http.HandlerFunc(v.RollbackVersion), true, "POST")
+}
+
 // RegisterRing registers the ring UI page associated with the distributor for writes.
 func (a *API) RegisterRing(r *ring.Ring) {
 	a.indexPage.AddLink(SectionAdminEndpoints, "/ingester/ring", "Ingester Ring Status")
@@ -366,12 +436,17 @@ func (a *API) RegisterStoreGateway(s *storegateway.StoreGateway) {
 
 	a.indexPage.AddLink(SectionAdminEndpoints, "/store-gateway/ring", "Store Gateway Ring")
 	a.RegisterRoute("/store-gateway/ring", http.HandlerFunc(s.RingHandler), false, "GET", "POST")
+	a.RegisterRoute("/store-gateway/prepare-shutdown", http.HandlerFunc(s.PrepareShutdownHandler), false, "GET", "POST", "DELETE")
+	a.RegisterRoute("/store-gateway/prepare-downscale", http.HandlerFunc(s.PrepareDownscaleHandler), false, "GET", "POST", "DELETE")
 }
 
 // RegisterCompactor registers the ring UI page associated with the compactor.
 func (a *API) RegisterCompactor(c *compactor.Compactor) {
 	a.indexPage.AddLink(SectionAdminEndpoints, "/compactor/ring", "Compactor Ring Status")
 	a.RegisterRoute("/compactor/ring", http.HandlerFunc(c.RingHandler), false, "GET", "POST")
+	a.RegisterRoute("/compactor/block_repair_report", http.HandlerFunc(c.BlockRepairReportHandler), true, "GET")
+	a.RegisterRoute("/compactor/block_transfers", http.HandlerFunc(c.BlockTransfersHandler), true, "GET", "POST")
+	a.RegisterRoute("/compactor/compaction_plan", http.HandlerFunc(c.CompactionPlanHandler), true, "GET")
 }
 
 type Distributor interface {
@@ -442,6 +517,8 @@ func (a *API) RegisterQueryFrontend2(f *frontendv2.Frontend) {
 func (a *API) RegisterQueryScheduler(f *scheduler.Scheduler) {
 	schedulerpb.RegisterSchedulerForFrontendServer(a.server.GRPC, f)
 	schedulerpb.RegisterSchedulerForQuerierServer(a.server.GRPC, f)
+
+	a.RegisterRoute("/scheduler/drain_querier", http.HandlerFunc(f.DrainQuerierHandler), false, "GET", "POST")
 }
 
 // RegisterServiceMapHandler registers the Cortex structs service handler
@@ -452,7 +529,25 @@ func (a *API) RegisterServiceMapHandler(handler http.Handler) {
 	a.RegisterRoute("/services", handler, false, "GET")
 }
 
+// RegisterGroupcache registers the endpoints of the requests between the groupcache peers.
+func (a *API) RegisterGroupcache(handler http.Handler) {
+	a.RegisterRoutesWithPrefix(tsdb.GroupcacheBasePath, handler, false, "GET")
+}
+
 func (a *API) RegisterMemberlistKV(handler http.Handler) {
 	a.indexPage.AddLink(SectionAdminEndpoints, "/memberlist", "Memberlist Status")
 	a.RegisterRoute("/memberlist", handler, false, "GET")
 }
+
+// RegisterMultiKV registers the endpoint driving the migration of the rings between the KV stores of the multi KV
+// store.
+func (a *API) RegisterMultiKV(handler http.Handler) {
+	a.indexPage.AddLink(SectionAdminEndpoints, "/multikv", "Multi KV Migration Status")
+	a.RegisterRoute("/multikv", handler, false, "GET", "POST")
+}
+
+// RegisterRingSnapshots registers the endpoint dumping the state of the rings to a snapshot, and restoring it.
+func (a *API) RegisterRingSnapshots(handler http.Handler) {
+	a.indexPage.AddLink(SectionAdminEndpoints, "/ring/snapshot", "Ring Snapshot")
+	a.RegisterRoute("/ring/snapshot", handler, false, "GET", "POST")
+}
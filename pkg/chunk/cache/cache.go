@@ -23,11 +23,14 @@ type Cache interface {
 	Stop()
 }
 
+var errTTLPolicyWithoutDefaultValidity = errors.New("the TTL jitter and stale-while-revalidate require a default validity")
+
 // Config for building Caches.
 type Config struct {
 	EnableFifoCache bool `yaml:"enable_fifocache"`
 
-	DefaultValidity time.Duration `yaml:"default_validity"`
+	DefaultValidity time.Duration   `yaml:"default_validity"`
+	TTLPolicy       TTLPolicyConfig `yaml:",inline"`
 
 	Background     BackgroundConfig      `yaml:"background"`
 	Memcache       MemcachedConfig       `yaml:"memcached"`
@@ -52,11 +55,24 @@ func (cfg *Config) RegisterFlagsWithPrefix(prefix string, description string, f
 
 	f.BoolVar(&cfg.EnableFifoCache, prefix+"cache.enable-fifocache", false, description+"Enable in-memory cache.")
 	f.DurationVar(&cfg.DefaultValidity, prefix+"default-validity", 0, description+"The default validity of entries for caches unless overridden.")
+	cfg.TTLPolicy.RegisterFlagsWithPrefix(prefix, description, f)
 
 	cfg.Prefix = prefix
 }
 
 func (cfg *Config) Validate() error {
+	if err := cfg.TTLPolicy.Validate(); err != nil {
+		return err
+	}
+	if cfg.TTLPolicy.Enabled() && cfg.DefaultValidity <= 0 {
+		return errTTLPolicyWithoutDefaultValidity
+	}
+	if err := cfg.MemcacheClient.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.Redis.Validate(); err != nil {
+		return err
+	}
 	return cfg.Fifocache.Validate()
 }
 
@@ -66,6 +82,13 @@ func New(cfg Config, reg prometheus.Registerer, logger log.Logger) (Cache, error
 		return cfg.Cache, nil
 	}
 
+	// The entries are kept by the caches until the end of their stale-while-revalidate period, while the TTL policy
+	// expires them at the end of their validity.
+	validity := cfg.DefaultValidity
+	if cfg.TTLPolicy.Enabled() {
+		cfg.DefaultValidity += cfg.TTLPolicy.StaleWhileRevalidate
+	}
+
 	caches := []Cache{}
 
 	if cfg.EnableFifoCache {
@@ -78,11 +101,11 @@ func New(cfg Config, reg prometheus.Registerer, logger log.Logger) (Cache, error
 		}
 	}
 
-	if (cfg.MemcacheClient.Host != "" || cfg.MemcacheClient.Addresses != "") && cfg.Redis.Endpoint != "" {
+	if cfg.MemcacheClient.Enabled() && cfg.Redis.Endpoint != "" {
 		return nil, errors.New("use of multiple cache storage systems is not supported")
 	}
 
-	if cfg.MemcacheClient.Host != "" || cfg.MemcacheClient.Addresses != "" {
+	if cfg.MemcacheClient.Enabled() {
 		if cfg.Memcache.Expiration == 0 && cfg.DefaultValidity != 0 {
 			cfg.Memcache.Expiration = cfg.DefaultValidity
 		}
@@ -98,8 +121,12 @@ func New(cfg Config, reg prometheus.Registerer, logger log.Logger) (Cache, error
 		if cfg.Redis.Expiration == 0 && cfg.DefaultValidity != 0 {
 			cfg.Redis.Expiration = cfg.DefaultValidity
 		}
+		client, err := NewRedisClient(&cfg.Redis)
+		if err != nil {
+			return nil, err
+		}
 		cacheName := cfg.Prefix + "redis"
-		cache := NewRedisCache(cacheName, NewRedisClient(&cfg.Redis), reg, logger)
+		cache := NewRedisCache(cacheName, client, reg, logger)
 		caches = append(caches, NewBackground(cacheName, cfg.Background, Instrument(cacheName, cache, reg), reg))
 	}
 
@@ -107,5 +134,8 @@ func New(cfg Config, reg prometheus.Registerer, logger log.Logger) (Cache, error
 	if len(caches) > 1 {
 		cache = Instrument(cfg.Prefix+"tiered", cache, reg)
 	}
+	if cfg.TTLPolicy.Enabled() {
+		cache = NewTTLPolicyCache(cache, NewTTLPolicy(cfg.Prefix+"cache", cfg.TTLPolicy, reg), validity)
+	}
 	return cache, nil
 }
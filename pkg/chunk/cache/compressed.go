@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/cortexproject/cortex/pkg/util/compression"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+)
+
+type compressedCache struct {
+	next       Cache
+	compressor *compression.Compressor
+	logger     log.Logger
+}
+
+// NewCompressed makes a new cache wrapper compressing the entries with the compressor.
+func NewCompressed(next Cache, compressor *compression.Compressor, logger log.Logger) Cache {
+	return &compressedCache{
+		next:       next,
+		compressor: compressor,
+		logger:     logger,
+	}
+}
+
+func (c *compressedCache) Store(ctx context.Context, keys []string, bufs [][]byte) {
+	cs := make([][]byte, 0, len(bufs))
+	for _, buf := range bufs {
+		cs = append(cs, c.compressor.Compress(buf))
+	}
+	c.next.Store(ctx, keys, cs)
+}
+
+func (c *compressedCache) Fetch(ctx context.Context, keys []string) ([]string, [][]byte, []string) {
+	found, bufs, missing := c.next.Fetch(ctx, keys)
+	ds := make([][]byte, 0, len(bufs))
+	for _, buf := range bufs {
+		d, err := compression.Decompress(buf)
+		if err != nil {
+			level.Error(util_log.WithContext(ctx, c.logger)).Log("msg", "failed to decompress cache entry", "err", err)
+			return nil, nil, keys
+		}
+		ds = append(ds, d)
+	}
+	return found, ds, missing
+}
+
+func (c *compressedCache) Stop() {
+	c.next.Stop()
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 
+	"github.com/cortexproject/cortex/pkg/tenant"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
 )
@@ -36,6 +37,10 @@ type FifoCacheConfig struct {
 	Validity     time.Duration `yaml:"validity"`
 
 	DeprecatedSize int `yaml:"size"`
+
+	// Injected at runtime to cap the size of the entries of each tenant, evicting the least recently used entries of
+	// the tenant instead of the entries of the other tenants. It returns 0 when the tenant isn't capped.
+	TenantMaxSizeBytes func(userID string) int64 `yaml:"-"`
 }
 
 // RegisterFlagsWithPrefix adds the flags required to config this to the given FlagSet
@@ -75,6 +80,10 @@ type FifoCache struct {
 	entries map[string]*list.Element
 	lru     *list.List
 
+	// The entries of each tenant, in least recently used order, when the tenants are capped.
+	tenantMaxSizeBytes func(userID string) int64
+	tenants            map[string]*tenantEntries
+
 	entriesAdded    prometheus.Counter
 	entriesAddedNew prometheus.Counter
 	entriesEvicted  prometheus.Counter
@@ -83,12 +92,24 @@ type FifoCache struct {
 	totalMisses     prometheus.Counter
 	staleGets       prometheus.Counter
 	memoryBytes     prometheus.Gauge
+
+	tenantMemoryBytes    *prometheus.GaugeVec
+	tenantAddedBytes     *prometheus.CounterVec
+	tenantEntriesEvicted *prometheus.CounterVec
 }
 
 type cacheEntry struct {
 	updated time.Time
 	key     string
 	value   []byte
+
+	tenant        string
+	tenantElement *list.Element
+}
+
+type tenantEntries struct {
+	lru       *list.List
+	sizeBytes uint64
 }
 
 // NewFifoCache returns a new initialised FifoCache of size.
@@ -114,6 +135,9 @@ func NewFifoCache(name string, cfg FifoCacheConfig, reg prometheus.Registerer, l
 		entries:      make(map[string]*list.Element),
 		lru:          list.New(),
 
+		tenantMaxSizeBytes: cfg.TenantMaxSizeBytes,
+		tenants:            map[string]*tenantEntries{},
+
 		entriesAdded: promauto.With(reg).NewCounter(prometheus.CounterOpts{
 			Namespace:   "querier",
 			Subsystem:   "cache",
@@ -177,6 +201,30 @@ func NewFifoCache(name string, cfg FifoCacheConfig, reg prometheus.Registerer, l
 			Help:        "The current cache size in bytes",
 			ConstLabels: prometheus.Labels{"cache": name},
 		}),
+
+		tenantMemoryBytes: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   "querier",
+			Subsystem:   "cache",
+			Name:        "tenant_memory_bytes",
+			Help:        "The current size in bytes of the entries of the tenant",
+			ConstLabels: prometheus.Labels{"cache": name},
+		}, []string{"user"}),
+
+		tenantAddedBytes: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "querier",
+			Subsystem:   "cache",
+			Name:        "tenant_added_bytes_total",
+			Help:        "The total size in bytes of the entries added to the cache by the tenant",
+			ConstLabels: prometheus.Labels{"cache": name},
+		}, []string{"user"}),
+
+		tenantEntriesEvicted: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "querier",
+			Subsystem:   "cache",
+			Name:        "tenant_evicted_total",
+			Help:        "The total number of entries of the tenant evicted because the tenant exceeded its max size",
+			ConstLabels: prometheus.Labels{"cache": name},
+		}, []string{"user"}),
 	}
 }
 
@@ -199,12 +247,13 @@ func (c *FifoCache) Fetch(ctx context.Context, keys []string) (found []string, b
 // Store implements Cache.
 func (c *FifoCache) Store(ctx context.Context, keys []string, values [][]byte) {
 	c.entriesAdded.Inc()
+	userID := c.tenantOf(ctx)
 
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
 	for i := range keys {
-		c.put(keys[i], values[i])
+		c.put(userID, keys[i], values[i])
 	}
 }
 
@@ -218,30 +267,52 @@ func (c *FifoCache) Stop() {
 	c.entries = make(map[string]*list.Element)
 	c.lru.Init()
 	c.currSizeBytes = 0
+	for userID := range c.tenants {
+		c.deleteTenantMetrics(userID)
+	}
+	c.tenants = map[string]*tenantEntries{}
 
 	c.entriesCurrent.Set(float64(0))
 	c.memoryBytes.Set(float64(0))
 }
 
-func (c *FifoCache) put(key string, value []byte) {
+// tenantOf returns the tenant whose entries are capped, empty if the tenants aren't capped.
+func (c *FifoCache) tenantOf(ctx context.Context) string {
+	if c.tenantMaxSizeBytes == nil {
+		return ""
+	}
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return ""
+	}
+	return tenant.JoinTenantIDs(tenantIDs)
+}
+
+func (c *FifoCache) put(userID, key string, value []byte) {
 	// See if we already have the item in the cache.
 	element, ok := c.entries[key]
 	if ok {
 		// Remove the item from the cache.
-		entry := c.lru.Remove(element).(*cacheEntry)
-		delete(c.entries, key)
-		c.currSizeBytes -= sizeOf(entry)
-		c.entriesCurrent.Dec()
+		c.remove(element)
 	}
 
 	entry := &cacheEntry{
 		updated: time.Now(),
 		key:     key,
 		value:   value,
+		tenant:  userID,
 	}
 	entrySz := sizeOf(entry)
 
-	if c.maxSizeBytes > 0 && entrySz > c.maxSizeBytes {
+	var tenantMaxSizeBytes uint64
+	if userID != "" {
+		if maxSizeBytes := c.tenantMaxSizeBytes(userID); maxSizeBytes > 0 {
+			tenantMaxSizeBytes = uint64(maxSizeBytes)
+		}
+		c.tenantAddedBytes.WithLabelValues(userID).Add(float64(entrySz))
+	}
+
+	if (c.maxSizeBytes > 0 && entrySz > c.maxSizeBytes) || (tenantMaxSizeBytes > 0 && entrySz > tenantMaxSizeBytes) {
 		// Cannot keep this item in the cache.
 		if ok {
 			// We do not replace this item.
@@ -251,22 +322,38 @@ func (c *FifoCache) put(key string, value []byte) {
 		return
 	}
 
-	// Otherwise, see if we need to evict item(s).
+	// Otherwise, see if we need to evict the least recently used item(s) of the tenant, and then any item(s).
+	if tenantMaxSizeBytes > 0 {
+		for entries := c.tenants[userID]; entries != nil && entries.sizeBytes+entrySz > tenantMaxSizeBytes; {
+			c.remove(entries.lru.Back().Value.(*list.Element))
+			c.entriesEvicted.Inc()
+			c.tenantEntriesEvicted.WithLabelValues(userID).Inc()
+			entries = c.tenants[userID]
+		}
+	}
 	for (c.maxSizeBytes > 0 && c.currSizeBytes+entrySz > c.maxSizeBytes) || (c.maxSizeItems > 0 && len(c.entries) >= c.maxSizeItems) {
 		lastElement := c.lru.Back()
 		if lastElement == nil {
 			break
 		}
-		evicted := c.lru.Remove(lastElement).(*cacheEntry)
-		delete(c.entries, evicted.key)
-		c.currSizeBytes -= sizeOf(evicted)
-		c.entriesCurrent.Dec()
+		c.remove(lastElement)
 		c.entriesEvicted.Inc()
 	}
 
 	// Finally, we have space to add the item.
-	c.entries[key] = c.lru.PushFront(entry)
+	element = c.lru.PushFront(entry)
+	c.entries[key] = element
 	c.currSizeBytes += entrySz
+	if userID != "" {
+		entries := c.tenants[userID]
+		if entries == nil {
+			entries = &tenantEntries{lru: list.New()}
+			c.tenants[userID] = entries
+		}
+		entry.tenantElement = entries.lru.PushFront(element)
+		entries.sizeBytes += entrySz
+		c.tenantMemoryBytes.WithLabelValues(userID).Set(float64(entries.sizeBytes))
+	}
 	if !ok {
 		c.entriesAddedNew.Inc()
 	}
@@ -274,17 +361,54 @@ func (c *FifoCache) put(key string, value []byte) {
 	c.memoryBytes.Set(float64(c.currSizeBytes))
 }
 
+// remove removes the element from the cache, and from the entries of its tenant.
+func (c *FifoCache) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*cacheEntry)
+	delete(c.entries, entry.key)
+	entrySz := sizeOf(entry)
+	c.currSizeBytes -= entrySz
+	c.entriesCurrent.Dec()
+
+	if entry.tenantElement == nil {
+		return
+	}
+	entries := c.tenants[entry.tenant]
+	entries.lru.Remove(entry.tenantElement)
+	entries.sizeBytes -= entrySz
+	if entries.lru.Len() == 0 {
+		delete(c.tenants, entry.tenant)
+		c.deleteTenantMetrics(entry.tenant)
+		return
+	}
+	c.tenantMemoryBytes.WithLabelValues(entry.tenant).Set(float64(entries.sizeBytes))
+}
+
+func (c *FifoCache) deleteTenantMetrics(userID string) {
+	c.tenantMemoryBytes.DeleteLabelValues(userID)
+	c.tenantAddedBytes.DeleteLabelValues(userID)
+	c.tenantEntriesEvicted.DeleteLabelValues(userID)
+}
+
 // Get returns the stored value against the key and when the key was last updated.
 func (c *FifoCache) Get(ctx context.Context, key string) ([]byte, bool) {
 	c.totalGets.Inc()
 
-	c.lock.RLock()
-	defer c.lock.RUnlock()
+	// The entries of the capped tenants are moved to the front of the entries of their tenant when read.
+	if c.tenantMaxSizeBytes != nil {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+	} else {
+		c.lock.RLock()
+		defer c.lock.RUnlock()
+	}
 
 	element, ok := c.entries[key]
 	if ok {
 		entry := element.Value.(*cacheEntry)
 		if c.validity == 0 || time.Since(entry.updated) < c.validity {
+			if entry.tenantElement != nil {
+				c.tenants[entry.tenant].lru.MoveToFront(entry.tenantElement)
+			}
 			return entry.value, true
 		}
 
@@ -11,6 +11,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
 )
 
 func TestFifoCacheEviction(t *testing.T) {
@@ -231,6 +232,47 @@ func TestFifoCacheExpiry(t *testing.T) {
 	}
 }
 
+func TestFifoCacheTenantMaxSize(t *testing.T) {
+	value := genBytes(16)
+	entrySz := sizeOf(&cacheEntry{key: "a1", value: value, tenant: "user-1"})
+
+	c := NewFifoCache("test", FifoCacheConfig{
+		MaxSizeItems: 10,
+		TenantMaxSizeBytes: func(userID string) int64 {
+			if userID == "user-1" {
+				return int64(2 * entrySz)
+			}
+			return 0
+		},
+	}, nil, log.NewNopLogger())
+	ctx1 := user.InjectOrgID(context.Background(), "user-1")
+	ctx2 := user.InjectOrgID(context.Background(), "user-2")
+
+	c.Store(ctx2, []string{"b1", "b2", "b3"}, [][]byte{value, value, value})
+	c.Store(ctx1, []string{"a1", "a2"}, [][]byte{value, value})
+
+	// The least recently used entry of the tenant is evicted, instead of the entries of the other tenants.
+	_, ok := c.Get(ctx1, "a1")
+	require.True(t, ok)
+	c.Store(ctx1, []string{"a3"}, [][]byte{value})
+
+	for key, expected := range map[string]bool{"a1": true, "a2": false, "a3": true, "b1": true, "b2": true, "b3": true} {
+		_, ok := c.Get(ctx1, key)
+		assert.Equal(t, expected, ok, key)
+	}
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.tenantEntriesEvicted.WithLabelValues("user-1")))
+	assert.Equal(t, float64(2*entrySz), testutil.ToFloat64(c.tenantMemoryBytes.WithLabelValues("user-1")))
+	assert.Equal(t, float64(3*entrySz), testutil.ToFloat64(c.tenantAddedBytes.WithLabelValues("user-1")))
+	assert.Equal(t, float64(3*entrySz), testutil.ToFloat64(c.tenantMemoryBytes.WithLabelValues("user-2")))
+
+	// The entries bigger than the max size of the tenant aren't stored.
+	c.Store(ctx1, []string{"a4"}, [][]byte{genBytes(255)})
+	_, ok = c.Get(ctx1, "a4")
+	assert.False(t, ok)
+
+	c.Stop()
+}
+
 func genBytes(n uint8) []byte {
 	arr := make([]byte, n)
 	for i := range arr {
@@ -18,10 +18,16 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/sony/gobreaker"
 	"github.com/thanos-io/thanos/pkg/discovery/dns"
+	memcachediscovery "github.com/thanos-io/thanos/pkg/discovery/memcache"
 
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
 )
 
+var (
+	errMemcachedAutoDiscoveryWithoutAddresses = errors.New("the memcached auto-discovery requires the addresses of the configuration endpoints")
+	errMemcachedKubernetesWithAddresses       = errors.New("the memcached Kubernetes service and addresses can't be both configured")
+)
+
 // MemcachedClient interface exists for mocking memcacheClient.
 type MemcachedClient interface {
 	GetMulti(keys []string) (map[string]*memcache.Item, error)
@@ -44,8 +50,10 @@ type memcachedClient struct {
 	hostname string
 	service  string
 
-	addresses []string
-	provider  *dns.Provider
+	addresses     []string
+	provider      *dns.Provider
+	autoDiscovery *memcachediscovery.Provider
+	kubernetes    *kubernetesDiscovery
 
 	cbs        map[ /*address*/ string]*gobreaker.CircuitBreaker
 	cbFailures uint
@@ -54,8 +62,9 @@ type memcachedClient struct {
 
 	maxItemSize int
 
-	quit chan struct{}
-	wait sync.WaitGroup
+	quit   chan struct{}
+	cancel context.CancelFunc
+	wait   sync.WaitGroup
 
 	numServers prometheus.Gauge
 	skipped    prometheus.Counter
@@ -68,6 +77,8 @@ type MemcachedClientConfig struct {
 	Host           string        `yaml:"host"`
 	Service        string        `yaml:"service"`
 	Addresses      string        `yaml:"addresses"` // EXPERIMENTAL.
+	AutoDiscovery  bool          `yaml:"auto_discovery"`
+	Kubernetes     string        `yaml:"kubernetes_service"`
 	Timeout        time.Duration `yaml:"timeout"`
 	MaxIdleConns   int           `yaml:"max_idle_conns"`
 	MaxItemSize    int           `yaml:"max_item_size"`
@@ -83,6 +94,8 @@ func (cfg *MemcachedClientConfig) RegisterFlagsWithPrefix(prefix, description st
 	f.StringVar(&cfg.Host, prefix+"memcached.hostname", "", description+"Hostname for memcached service to use. If empty and if addresses is unset, no memcached will be used.")
 	f.StringVar(&cfg.Service, prefix+"memcached.service", "memcached", description+"SRV service used to discover memcache servers.")
 	f.StringVar(&cfg.Addresses, prefix+"memcached.addresses", "", description+"EXPERIMENTAL: Comma separated addresses list in DNS Service Discovery format: https://cortexmetrics.io/docs/configuration/arguments/#dns-service-discovery")
+	f.BoolVar(&cfg.AutoDiscovery, prefix+"memcached.auto-discovery", false, description+"[Experimental] Use the memcached auto-discovery mechanism of AWS ElastiCache and GCP Memorystore to discover the memcached servers, from the configuration endpoints of the clusters set in the addresses.")
+	f.StringVar(&cfg.Kubernetes, prefix+"memcached.kubernetes-service", "", description+"[Experimental] Kubernetes service, in the <service>[.<namespace>]:<port> format, whose endpoints are watched to discover the memcached servers. The port is the name or the number of the port of the endpoints, and the namespace defaults to the namespace of the pod. The endpoints with a hostname, such as the pods of a StatefulSet behind a headless service, are addressed by their DNS name, so that the keys aren't rehashed when the pods are rescheduled. Requires to run in Kubernetes with the permission to get, list and watch the endpoints.")
 	f.IntVar(&cfg.MaxIdleConns, prefix+"memcached.max-idle-conns", 16, description+"Maximum number of idle connections in pool.")
 	f.DurationVar(&cfg.Timeout, prefix+"memcached.timeout", 100*time.Millisecond, description+"Maximum time to wait before giving up on memcached requests.")
 	f.DurationVar(&cfg.UpdateInterval, prefix+"memcached.update-interval", 1*time.Minute, description+"Period with which to poll DNS for memcache servers.")
@@ -93,6 +106,27 @@ func (cfg *MemcachedClientConfig) RegisterFlagsWithPrefix(prefix, description st
 	f.IntVar(&cfg.MaxItemSize, prefix+"memcached.max-item-size", 0, description+"The maximum size of an item stored in memcached. Bigger items are not stored. If set to 0, no maximum size is enforced.")
 }
 
+// Enabled returns whether the memcached servers are configured.
+func (cfg *MemcachedClientConfig) Enabled() bool {
+	return cfg.Host != "" || cfg.Addresses != "" || cfg.Kubernetes != ""
+}
+
+// Validate the config.
+func (cfg *MemcachedClientConfig) Validate() error {
+	if cfg.AutoDiscovery && cfg.Addresses == "" {
+		return errMemcachedAutoDiscoveryWithoutAddresses
+	}
+	if cfg.Kubernetes != "" {
+		if cfg.Addresses != "" {
+			return errMemcachedKubernetesWithAddresses
+		}
+		if _, err := parseKubernetesService(cfg.Kubernetes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // NewMemcachedClient creates a new MemcacheClient that gets its server list
 // from SRV and updates the server list on a regular basis.
 func NewMemcachedClient(cfg MemcachedClientConfig, name string, r prometheus.Registerer, logger log.Logger) MemcachedClient {
@@ -149,11 +183,49 @@ func NewMemcachedClient(cfg MemcachedClientConfig, name string, r prometheus.Reg
 		newClient.addresses = strings.Split(cfg.Addresses, ",")
 	}
 
+	if cfg.AutoDiscovery {
+		util_log.WarnExperimentalUse("memcached auto-discovery")
+		newClient.autoDiscovery = memcachediscovery.NewProvider(logger, dnsProviderRegisterer, cfg.Timeout)
+	}
+
+	var resourceVersion string
+	if cfg.Kubernetes != "" {
+		util_log.WarnExperimentalUse("Kubernetes memcached service discovery")
+		watchFailures := promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace:   "cortex",
+			Name:        "memcache_client_kubernetes_watch_failures_total",
+			Help:        "Total number of failures to list or watch the Kubernetes endpoints of the memcache servers.",
+			ConstLabels: prometheus.Labels{"name": name},
+		})
+		discovery, err := newKubernetesDiscovery(cfg.Kubernetes, watchFailures, logger)
+		if err != nil {
+			level.Error(logger).Log("msg", "error creating the Kubernetes memcache servers discovery", "service", cfg.Kubernetes, "err", err)
+		} else {
+			newClient.kubernetes = discovery
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if resourceVersion, err = discovery.Resolve(ctx); err != nil {
+				watchFailures.Inc()
+				level.Error(logger).Log("msg", "error listing the Kubernetes endpoints of the memcache servers", "service", cfg.Kubernetes, "err", err)
+			}
+			cancel()
+		}
+	}
+
 	err := newClient.updateMemcacheServers()
 	if err != nil {
 		level.Error(logger).Log("msg", "error setting memcache servers to host", "host", cfg.Host, "err", err)
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	newClient.cancel = cancel
+	if newClient.kubernetes != nil {
+		newClient.wait.Add(1)
+		go func() {
+			defer newClient.wait.Done()
+			newClient.kubernetes.watch(ctx, resourceVersion)
+		}()
+	}
+
 	newClient.wait.Add(1)
 	go newClient.updateLoop(cfg.UpdateInterval)
 	return newClient
@@ -192,6 +264,7 @@ func (c *memcachedClient) dialViaCircuitBreaker(_ context.Context, network, addr
 // Stop the memcache client.
 func (c *memcachedClient) Stop() {
 	close(c.quit)
+	c.cancel()
 	c.wait.Wait()
 }
 
@@ -220,6 +293,13 @@ func (c *memcachedClient) Set(item *memcache.Item) error {
 func (c *memcachedClient) updateLoop(updateInterval time.Duration) {
 	defer c.wait.Done()
 	ticker := time.NewTicker(updateInterval)
+
+	// The servers discovered from the Kubernetes endpoints are updated as soon as they change.
+	var changes <-chan struct{}
+	if c.kubernetes != nil {
+		changes = c.kubernetes.changes
+	}
+
 	for {
 		select {
 		case <-ticker.C:
@@ -227,6 +307,11 @@ func (c *memcachedClient) updateLoop(updateInterval time.Duration) {
 			if err != nil {
 				level.Warn(c.logger).Log("msg", "error updating memcache servers", "err", err)
 			}
+		case <-changes:
+			err := c.updateMemcacheServers()
+			if err != nil {
+				level.Warn(c.logger).Log("msg", "error updating memcache servers", "err", err)
+			}
 		case <-c.quit:
 			ticker.Stop()
 			return
@@ -234,12 +319,23 @@ func (c *memcachedClient) updateLoop(updateInterval time.Duration) {
 	}
 }
 
-// updateMemcacheServers sets a memcache server list from SRV records. SRV
-// priority & weight are ignored.
+// updateMemcacheServers sets a memcache server list from the Kubernetes endpoints,
+// the auto-discovery, the DNS service discovery or SRV records. SRV priority &
+// weight are ignored.
 func (c *memcachedClient) updateMemcacheServers() error {
 	var servers []string
 
-	if len(c.addresses) > 0 {
+	if c.kubernetes != nil {
+		servers = c.kubernetes.Addresses()
+	} else if c.autoDiscovery != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := c.autoDiscovery.Resolve(ctx, c.addresses); err != nil {
+			return err
+		}
+		servers = c.autoDiscovery.Addresses()
+	} else if len(c.addresses) > 0 {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
@@ -0,0 +1,265 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sercand/kuberesolver/v4"
+	"golang.org/x/exp/slices"
+
+	"github.com/cortexproject/cortex/pkg/util/backoff"
+)
+
+const kubernetesNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+var errInvalidKubernetesService = errors.New("invalid memcached Kubernetes service, the format is <service>[.<namespace>]:<port>")
+
+// kubernetesEndpoints is the subset of the Kubernetes Endpoints object used to discover the memcached servers.
+type kubernetesEndpoints struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Subsets []struct {
+		Addresses []struct {
+			IP       string `json:"ip"`
+			Hostname string `json:"hostname"`
+		} `json:"addresses"`
+		Ports []struct {
+			Name string `json:"name"`
+			Port int    `json:"port"`
+		} `json:"ports"`
+	} `json:"subsets"`
+}
+
+type kubernetesEndpointsEvent struct {
+	Type   string              `json:"type"`
+	Object kubernetesEndpoints `json:"object"`
+}
+
+// kubernetesService is a Kubernetes service in the <service>[.<namespace>]:<port> format, where the port is the
+// name or the number of the port of the endpoints.
+type kubernetesService struct {
+	name, namespace, port string
+}
+
+func parseKubernetesService(s string) (kubernetesService, error) {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil || host == "" || port == "" {
+		return kubernetesService{}, errInvalidKubernetesService
+	}
+	name, namespace, _ := strings.Cut(host, ".")
+	if strings.Contains(namespace, ".") {
+		return kubernetesService{}, errInvalidKubernetesService
+	}
+	return kubernetesService{name: name, namespace: namespace, port: port}, nil
+}
+
+// kubernetesDiscovery discovers the memcached servers from the endpoints of a Kubernetes service, and watches the
+// endpoints so that the servers are updated as soon as the memcached pods are scaled or rescheduled.
+type kubernetesDiscovery struct {
+	client  kuberesolver.K8sClient
+	service kubernetesService
+	logger  log.Logger
+
+	mtx       sync.Mutex
+	addresses []string
+
+	// changes is notified when the addresses change.
+	changes chan struct{}
+
+	watchFailures prometheus.Counter
+}
+
+func newKubernetesDiscovery(service string, watchFailures prometheus.Counter, logger log.Logger) (*kubernetesDiscovery, error) {
+	svc, err := parseKubernetesService(service)
+	if err != nil {
+		return nil, err
+	}
+	if svc.namespace == "" {
+		svc.namespace = currentKubernetesNamespace()
+	}
+
+	client, err := kuberesolver.NewInClusterK8sClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the Kubernetes client")
+	}
+
+	return &kubernetesDiscovery{
+		client:        client,
+		service:       svc,
+		logger:        log.With(logger, "service", svc.name, "namespace", svc.namespace),
+		changes:       make(chan struct{}, 1),
+		watchFailures: watchFailures,
+	}, nil
+}
+
+// Addresses returns the latest addresses of the memcached servers.
+func (d *kubernetesDiscovery) Addresses() []string {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.addresses
+}
+
+// Resolve lists the endpoints of the service, and returns their resource version to watch the changes from.
+func (d *kubernetesDiscovery) Resolve(ctx context.Context) (string, error) {
+	endpoints := kubernetesEndpoints{}
+	path := fmt.Sprintf("/api/v1/namespaces/%s/endpoints/%s", url.PathEscape(d.service.namespace), url.PathEscape(d.service.name))
+	resp, err := d.get(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&endpoints); err != nil {
+		return "", errors.Wrap(err, "failed to decode the Kubernetes endpoints")
+	}
+	d.update(endpoints)
+	return endpoints.Metadata.ResourceVersion, nil
+}
+
+// watch watches the changes of the endpoints of the service until the context is canceled. The endpoints are listed
+// again whenever the watch ends.
+func (d *kubernetesDiscovery) watch(ctx context.Context, resourceVersion string) {
+	boff := backoff.New(ctx, backoff.Config{MinBackoff: time.Second, MaxBackoff: time.Minute})
+	for boff.Ongoing() {
+		if resourceVersion == "" {
+			var err error
+			if resourceVersion, err = d.Resolve(ctx); err != nil {
+				d.watchFailed(err)
+				boff.Wait()
+				continue
+			}
+		}
+
+		if err := d.watchFrom(ctx, resourceVersion); err != nil {
+			d.watchFailed(err)
+			boff.Wait()
+		} else {
+			boff.Reset()
+		}
+		resourceVersion = ""
+	}
+}
+
+func (d *kubernetesDiscovery) watchFailed(err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	d.watchFailures.Inc()
+	level.Warn(d.logger).Log("msg", "failed to watch the memcached Kubernetes endpoints", "err", err)
+}
+
+func (d *kubernetesDiscovery) watchFrom(ctx context.Context, resourceVersion string) error {
+	path := fmt.Sprintf("/api/v1/namespaces/%s/endpoints?watch=true&fieldSelector=%s&resourceVersion=%s",
+		url.PathEscape(d.service.namespace), url.QueryEscape("metadata.name="+d.service.name), url.QueryEscape(resourceVersion))
+	resp, err := d.get(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		event := kubernetesEndpointsEvent{}
+		if err := decoder.Decode(&event); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// The API server ends the watches periodically.
+			return nil
+		}
+
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			d.update(event.Object)
+		case "DELETED":
+			d.update(kubernetesEndpoints{})
+		case "ERROR":
+			// The resource version is too old, so the endpoints are listed again.
+			return nil
+		}
+	}
+}
+
+func (d *kubernetesDiscovery) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := d.client.GetRequest(d.client.Host() + path)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status code %d from the Kubernetes API", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+func (d *kubernetesDiscovery) update(endpoints kubernetesEndpoints) {
+	addresses := endpointsAddresses(endpoints, d.service)
+
+	d.mtx.Lock()
+	changed := !slices.Equal(d.addresses, addresses)
+	d.addresses = addresses
+	d.mtx.Unlock()
+
+	if changed {
+		select {
+		case d.changes <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// endpointsAddresses returns the sorted addresses of the endpoints of the service. The endpoints with a hostname,
+// such as the pods of a StatefulSet behind a headless service, are addressed by their stable DNS name instead of their
+// IP, so that the consistent hashing of the keys doesn't change when the pods are rescheduled.
+func endpointsAddresses(endpoints kubernetesEndpoints, service kubernetesService) []string {
+	var addresses []string
+	for _, subset := range endpoints.Subsets {
+		port := 0
+		for _, p := range subset.Ports {
+			if p.Name == service.port || strconv.Itoa(p.Port) == service.port {
+				port = p.Port
+				break
+			}
+		}
+		if port == 0 {
+			continue
+		}
+
+		for _, addr := range subset.Addresses {
+			host := addr.IP
+			if addr.Hostname != "" {
+				host = fmt.Sprintf("%s.%s.%s.svc", addr.Hostname, service.name, service.namespace)
+			}
+			addresses = append(addresses, net.JoinHostPort(host, strconv.Itoa(port)))
+		}
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+func currentKubernetesNamespace() string {
+	ns, err := os.ReadFile(kubernetesNamespaceFile)
+	if err != nil {
+		return "default"
+	}
+	return strings.TrimSpace(string(ns))
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sercand/kuberesolver/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKubernetesService(t *testing.T) {
+	tests := map[string]struct {
+		service  string
+		expected kubernetesService
+		err      bool
+	}{
+		"service and port":              {service: "memcached:11211", expected: kubernetesService{name: "memcached", port: "11211"}},
+		"service, namespace and port":   {service: "memcached.cortex:client", expected: kubernetesService{name: "memcached", namespace: "cortex", port: "client"}},
+		"missing port":                  {service: "memcached.cortex", err: true},
+		"fully qualified service names": {service: "memcached.cortex.svc:11211", err: true},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			actual, err := parseKubernetesService(testData.service)
+			if testData.err {
+				assert.ErrorIs(t, err, errInvalidKubernetesService)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, testData.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMemcachedClientConfig_Validate(t *testing.T) {
+	cfg := MemcachedClientConfig{AutoDiscovery: true}
+	assert.ErrorIs(t, cfg.Validate(), errMemcachedAutoDiscoveryWithoutAddresses)
+
+	cfg = MemcachedClientConfig{AutoDiscovery: true, Addresses: "cluster.cfg.cache.amazonaws.com:11211"}
+	assert.NoError(t, cfg.Validate())
+
+	cfg = MemcachedClientConfig{Kubernetes: "memcached:11211", Addresses: "dns+memcached:11211"}
+	assert.ErrorIs(t, cfg.Validate(), errMemcachedKubernetesWithAddresses)
+
+	cfg = MemcachedClientConfig{Kubernetes: "memcached"}
+	assert.ErrorIs(t, cfg.Validate(), errInvalidKubernetesService)
+
+	cfg = MemcachedClientConfig{Kubernetes: "memcached.cortex:client"}
+	assert.NoError(t, cfg.Validate())
+	assert.True(t, cfg.Enabled())
+}
+
+const testEndpoints = `{
+	"metadata": {"resourceVersion": "%s"},
+	"subsets": [{
+		"addresses": [%s],
+		"ports": [{"name": "client", "port": 11211}, {"name": "metrics", "port": 9150}]
+	}]
+}`
+
+func TestKubernetesDiscovery_ShouldWatchTheEndpoints(t *testing.T) {
+	watchEvents := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v1/namespaces/cortex/endpoints/memcached":
+			fmt.Fprintf(w, testEndpoints, "1", `{"ip": "10.0.0.2", "hostname": "memcached-1"}, {"ip": "10.0.0.1", "hostname": "memcached-0"}`)
+		case r.URL.Path == "/api/v1/namespaces/cortex/endpoints" && r.URL.Query().Get("watch") == "true":
+			assert.Equal(t, "metadata.name=memcached", r.URL.Query().Get("fieldSelector"))
+			assert.Equal(t, "1", r.URL.Query().Get("resourceVersion"))
+			w.(http.Flusher).Flush()
+			for {
+				select {
+				case event := <-watchEvents:
+					fmt.Fprintln(w, event)
+					w.(http.Flusher).Flush()
+				case <-r.Context().Done():
+					return
+				}
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	d := &kubernetesDiscovery{
+		client:        kuberesolver.NewInsecureK8sClient(server.URL),
+		service:       kubernetesService{name: "memcached", namespace: "cortex", port: "client"},
+		logger:        log.NewNopLogger(),
+		changes:       make(chan struct{}, 1),
+		watchFailures: prometheus.NewCounter(prometheus.CounterOpts{}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resourceVersion, err := d.Resolve(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "1", resourceVersion)
+	assert.Equal(t, []string{"memcached-0.memcached.cortex.svc:11211", "memcached-1.memcached.cortex.svc:11211"}, d.Addresses())
+	<-d.changes
+
+	go d.watch(ctx, resourceVersion)
+
+	// The endpoints without a hostname are addressed by their IP.
+	watchEvents <- fmt.Sprintf(`{"type": "MODIFIED", "object": %s}`, fmt.Sprintf(testEndpoints, "2", `{"ip": "10.0.0.3"}`))
+	select {
+	case <-d.changes:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "the endpoints change hasn't been notified")
+	}
+	assert.Equal(t, []string{"10.0.0.3:11211"}, d.Addresses())
+
+	watchEvents <- `{"type": "DELETED", "object": {}}`
+	select {
+	case <-d.changes:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "the endpoints deletion hasn't been notified")
+	}
+	assert.Empty(t, d.Addresses())
+}
@@ -2,7 +2,6 @@ package cache
 
 import (
 	"context"
-	"crypto/tls"
 	"flag"
 	"fmt"
 	"strings"
@@ -10,21 +9,33 @@ import (
 	"unsafe"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
 
 	"github.com/cortexproject/cortex/pkg/util/flagext"
+	cortex_tls "github.com/cortexproject/cortex/pkg/util/tls"
 )
 
+var errRedisClusterWithMasterName = errors.New("the Redis Cluster mode can't be enabled with a Redis Sentinel master name")
+
 // RedisConfig defines how a RedisCache should be constructed.
 type RedisConfig struct {
 	Endpoint           string         `yaml:"endpoint"`
 	MasterName         string         `yaml:"master_name"`
+	ClusterEnabled     bool           `yaml:"cluster_enabled"`
 	Timeout            time.Duration  `yaml:"timeout"`
 	Expiration         time.Duration  `yaml:"expiration"`
 	DB                 int            `yaml:"db"`
 	PoolSize           int            `yaml:"pool_size"`
+	Username           string         `yaml:"username"`
 	Password           flagext.Secret `yaml:"password"`
+	SentinelUsername   string         `yaml:"sentinel_username"`
+	SentinelPassword   flagext.Secret `yaml:"sentinel_password"`
 	EnableTLS          bool           `yaml:"tls_enabled"`
 	InsecureSkipVerify bool           `yaml:"tls_insecure_skip_verify"`
+	TLSCertPath        string         `yaml:"tls_cert_path"`
+	TLSKeyPath         string         `yaml:"tls_key_path"`
+	TLSCAPath          string         `yaml:"tls_ca_path"`
+	TLSServerName      string         `yaml:"tls_server_name"`
 	IdleTimeout        time.Duration  `yaml:"idle_timeout"`
 	MaxConnAge         time.Duration  `yaml:"max_connection_age"`
 }
@@ -33,13 +44,21 @@ type RedisConfig struct {
 func (cfg *RedisConfig) RegisterFlagsWithPrefix(prefix, description string, f *flag.FlagSet) {
 	f.StringVar(&cfg.Endpoint, prefix+"redis.endpoint", "", description+"Redis Server endpoint to use for caching. A comma-separated list of endpoints for Redis Cluster or Redis Sentinel. If empty, no redis will be used.")
 	f.StringVar(&cfg.MasterName, prefix+"redis.master-name", "", description+"Redis Sentinel master name. An empty string for Redis Server or Redis Cluster.")
+	f.BoolVar(&cfg.ClusterEnabled, prefix+"redis.cluster-enabled", false, description+"Connect to a Redis Cluster even if a single endpoint is configured, such as the configuration endpoint of a managed Redis Cluster. A Redis Cluster is always used when several endpoints are configured without a Sentinel master name.")
 	f.DurationVar(&cfg.Timeout, prefix+"redis.timeout", 500*time.Millisecond, description+"Maximum time to wait before giving up on redis requests.")
 	f.DurationVar(&cfg.Expiration, prefix+"redis.expiration", 0, description+"How long keys stay in the redis.")
 	f.IntVar(&cfg.DB, prefix+"redis.db", 0, description+"Database index.")
 	f.IntVar(&cfg.PoolSize, prefix+"redis.pool-size", 0, description+"Maximum number of connections in the pool.")
+	f.StringVar(&cfg.Username, prefix+"redis.username", "", description+"Username to use when connecting to redis, for the Redis ACL users.")
 	f.Var(&cfg.Password, prefix+"redis.password", description+"Password to use when connecting to redis.")
+	f.StringVar(&cfg.SentinelUsername, prefix+"redis.sentinel-username", "", description+"Username to use when connecting to the Redis Sentinels, if different from the username of the redis servers.")
+	f.Var(&cfg.SentinelPassword, prefix+"redis.sentinel-password", description+"Password to use when connecting to the Redis Sentinels, if they require a password.")
 	f.BoolVar(&cfg.EnableTLS, prefix+"redis.tls-enabled", false, description+"Enable connecting to redis with TLS.")
 	f.BoolVar(&cfg.InsecureSkipVerify, prefix+"redis.tls-insecure-skip-verify", false, description+"Skip validating server certificate.")
+	f.StringVar(&cfg.TLSCertPath, prefix+"redis.tls-cert-path", "", description+"Path to the client certificate file, which will be used for authenticating with the server. Also requires the key path to be configured.")
+	f.StringVar(&cfg.TLSKeyPath, prefix+"redis.tls-key-path", "", description+"Path to the key file for the client certificate. Also requires the client certificate to be configured.")
+	f.StringVar(&cfg.TLSCAPath, prefix+"redis.tls-ca-path", "", description+"Path to the CA certificates file to validate server certificate against. If not set, the host's root CA certificates are used.")
+	f.StringVar(&cfg.TLSServerName, prefix+"redis.tls-server-name", "", description+"Override the expected name on the server certificate.")
 	f.DurationVar(&cfg.IdleTimeout, prefix+"redis.idle-timeout", 0, description+"Close connections after remaining idle for this duration. If the value is zero, then idle connections are not closed.")
 	f.DurationVar(&cfg.MaxConnAge, prefix+"redis.max-connection-age", 0, description+"Close connections older than this duration. If the value is zero, then the pool does not close connections based on age.")
 }
@@ -50,25 +69,55 @@ type RedisClient struct {
 	rdb        redis.UniversalClient
 }
 
+// Validate the config.
+func (cfg *RedisConfig) Validate() error {
+	if cfg.ClusterEnabled && cfg.MasterName != "" {
+		return errRedisClusterWithMasterName
+	}
+	return nil
+}
+
 // NewRedisClient creates Redis client
-func NewRedisClient(cfg *RedisConfig) *RedisClient {
+func NewRedisClient(cfg *RedisConfig) (*RedisClient, error) {
 	opt := &redis.UniversalOptions{
-		Addrs:       strings.Split(cfg.Endpoint, ","),
-		MasterName:  cfg.MasterName,
-		Password:    cfg.Password.Value,
-		DB:          cfg.DB,
-		PoolSize:    cfg.PoolSize,
-		IdleTimeout: cfg.IdleTimeout,
-		MaxConnAge:  cfg.MaxConnAge,
+		Addrs:            strings.Split(cfg.Endpoint, ","),
+		MasterName:       cfg.MasterName,
+		Username:         cfg.Username,
+		Password:         cfg.Password.Value,
+		SentinelUsername: cfg.SentinelUsername,
+		SentinelPassword: cfg.SentinelPassword.Value,
+		DB:               cfg.DB,
+		PoolSize:         cfg.PoolSize,
+		IdleTimeout:      cfg.IdleTimeout,
+		MaxConnAge:       cfg.MaxConnAge,
 	}
 	if cfg.EnableTLS {
-		opt.TLSConfig = &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+		tlsCfg := cortex_tls.ClientConfig{
+			CertPath:           cfg.TLSCertPath,
+			KeyPath:            cfg.TLSKeyPath,
+			CAPath:             cfg.TLSCAPath,
+			ServerName:         cfg.TLSServerName,
+			InsecureSkipVerify: cfg.InsecureSkipVerify,
+		}
+		var err error
+		if opt.TLSConfig, err = tlsCfg.GetTLSConfig(); err != nil {
+			return nil, errors.Wrap(err, "redis TLS config")
+		}
+	}
+
+	// The universal client connects to a Redis Cluster only if several endpoints are configured.
+	var rdb redis.UniversalClient
+	if cfg.ClusterEnabled {
+		rdb = redis.NewClusterClient(opt.Cluster())
+	} else {
+		rdb = redis.NewUniversalClient(opt)
 	}
+
 	return &RedisClient{
 		expiration: cfg.Expiration,
 		timeout:    cfg.Timeout,
-		rdb:        redis.NewUniversalClient(opt),
-	}
+		rdb:        rdb,
+	}, nil
 }
 
 func (c *RedisClient) Ping(ctx context.Context) error {
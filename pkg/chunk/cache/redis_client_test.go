@@ -7,7 +7,10 @@ import (
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
 )
 
 func TestRedisClient(t *testing.T) {
@@ -64,6 +67,59 @@ func TestRedisClient(t *testing.T) {
 	}
 }
 
+func TestNewRedisClient(t *testing.T) {
+	redisServer, err := miniredis.Run()
+	require.NoError(t, err)
+	defer redisServer.Close()
+	redisServer.RequireUserAuth("cortex", "secret")
+
+	ctx := context.Background()
+
+	tests := map[string]struct {
+		cfg             RedisConfig
+		expectedCluster bool
+	}{
+		"single endpoint": {
+			cfg: RedisConfig{Endpoint: redisServer.Addr()},
+		},
+		"single endpoint of a Redis Cluster": {
+			cfg:             RedisConfig{Endpoint: redisServer.Addr(), ClusterEnabled: true},
+			expectedCluster: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			testData.cfg.Timeout = time.Second
+			testData.cfg.Username = "cortex"
+			testData.cfg.Password = flagext.Secret{Value: "secret"}
+
+			client, err := NewRedisClient(&testData.cfg)
+			require.NoError(t, err)
+			defer client.Close()
+
+			_, isCluster := client.rdb.(*redis.ClusterClient)
+			assert.Equal(t, testData.expectedCluster, isCluster)
+
+			require.NoError(t, client.MSet(ctx, []string{"key"}, [][]byte{[]byte("value")}))
+			values, err := client.MGet(ctx, []string{"key"})
+			require.NoError(t, err)
+			assert.Equal(t, [][]byte{[]byte("value")}, values)
+		})
+	}
+}
+
+func TestNewRedisClient_ShouldFailOnInvalidTLSConfig(t *testing.T) {
+	_, err := NewRedisClient(&RedisConfig{Endpoint: "localhost:6379", EnableTLS: true, TLSCAPath: "/non-existent/ca.pem"})
+	assert.ErrorContains(t, err, "redis TLS config")
+}
+
+func TestRedisConfig_Validate(t *testing.T) {
+	assert.NoError(t, (&RedisConfig{MasterName: "mymaster"}).Validate())
+	assert.NoError(t, (&RedisConfig{ClusterEnabled: true}).Validate())
+	assert.Equal(t, errRedisClusterWithMasterName, (&RedisConfig{MasterName: "mymaster", ClusterEnabled: true}).Validate())
+}
+
 func mockRedisClientSingle() (*RedisClient, error) {
 	redisServer, err := miniredis.Run()
 	if err != nil {
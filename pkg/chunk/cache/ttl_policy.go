@@ -0,0 +1,211 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// TTLPolicyKeyPrefix prefixes the keys of the entries stored with a TTL policy, since their values are wrapped
+	// with their freshness: disabling the policy misses them instead of returning the wrapped values.
+	TTLPolicyKeyPrefix = "ttl:"
+
+	// How long the entries being revalidated by a request keep being served stale to the other requests, before
+	// another request revalidates them, eg. when the revalidation failed.
+	revalidationTimeout = time.Minute
+
+	ttlPolicyHeaderSize = 8
+)
+
+var (
+	errInvalidTTLJitter            = errors.New("the TTL jitter must be between 0 and 1")
+	errInvalidStaleWhileRevalidate = errors.New("the stale-while-revalidate period must not be negative")
+)
+
+// TTLPolicyConfig configures how the entries of a cache expire.
+type TTLPolicyConfig struct {
+	TTLJitter            float64       `yaml:"ttl_jitter"`
+	StaleWhileRevalidate time.Duration `yaml:"stale_while_revalidate"`
+}
+
+func (cfg *TTLPolicyConfig) RegisterFlagsWithPrefix(prefix, description string, f *flag.FlagSet) {
+	f.Float64Var(&cfg.TTLJitter, prefix+"ttl-jitter", 0, "[Experimental] "+description+"Fraction of the TTL of each entry randomly cut off when storing it, between 0 and 1, so that the entries stored together don't expire together. 0 to disable the jitter.")
+	f.DurationVar(&cfg.StaleWhileRevalidate, prefix+"stale-while-revalidate", 0, "[Experimental] "+description+"How long the entries are still served after their TTL, while the first request reading them refreshes them. 0 to disable the stale-while-revalidate.")
+}
+
+// Validate the config.
+func (cfg *TTLPolicyConfig) Validate() error {
+	if cfg.TTLJitter < 0 || cfg.TTLJitter > 1 {
+		return errInvalidTTLJitter
+	}
+	if cfg.StaleWhileRevalidate < 0 {
+		return errInvalidStaleWhileRevalidate
+	}
+	return nil
+}
+
+// Enabled returns whether the entries expire with the TTL policy, instead of with the TTL of the cache.
+func (cfg *TTLPolicyConfig) Enabled() bool {
+	return cfg.TTLJitter > 0 || cfg.StaleWhileRevalidate > 0
+}
+
+// TTLPolicy wraps the cached values with the time until which they're fresh, jittering their TTL, and decides
+// whether each value read from the cache is fresh, stale but still served, or must be refreshed.
+type TTLPolicy struct {
+	cfg TTLPolicyConfig
+
+	mtx          sync.Mutex
+	revalidating map[string]time.Time
+	lastSweep    time.Time
+
+	staleHits     prometheus.Counter
+	revalidations prometheus.Counter
+}
+
+func NewTTLPolicy(name string, cfg TTLPolicyConfig, reg prometheus.Registerer) *TTLPolicy {
+	return &TTLPolicy{
+		cfg:          cfg,
+		revalidating: map[string]time.Time{},
+		staleHits: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "cortex_cache_stale_hits_total",
+			Help:        "Total number of stale entries served while being revalidated.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}),
+		revalidations: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "cortex_cache_revalidations_total",
+			Help:        "Total number of stale entries missed once to be revalidated.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}),
+	}
+}
+
+// Wrap returns the value wrapped with the end of its jittered TTL, and the TTL to store it with, extended with the
+// stale-while-revalidate period. A TTL of 0 never expires.
+func (p *TTLPolicy) Wrap(key string, value []byte, ttl time.Duration, now time.Time) ([]byte, time.Duration) {
+	p.mtx.Lock()
+	delete(p.revalidating, key)
+	p.mtx.Unlock()
+
+	freshUntil := int64(math.MaxInt64)
+	if ttl > 0 {
+		jitter := time.Duration(rand.Float64() * p.cfg.TTLJitter * float64(ttl))
+		freshUntil = now.Add(ttl - jitter).UnixMilli()
+		ttl += p.cfg.StaleWhileRevalidate
+	}
+
+	wrapped := make([]byte, ttlPolicyHeaderSize+len(value))
+	binary.BigEndian.PutUint64(wrapped, uint64(freshUntil))
+	copy(wrapped[ttlPolicyHeaderSize:], value)
+	return wrapped, ttl
+}
+
+// Unwrap returns the value unwrapped, and whether it must be served. The stale values are served in their
+// stale-while-revalidate period, except to the first request reading them, which misses them to refresh them.
+func (p *TTLPolicy) Unwrap(key string, wrapped []byte, now time.Time) ([]byte, bool) {
+	if len(wrapped) < ttlPolicyHeaderSize {
+		return nil, false
+	}
+	freshUntil := int64(binary.BigEndian.Uint64(wrapped))
+	value := wrapped[ttlPolicyHeaderSize:]
+
+	nowMillis := now.UnixMilli()
+	if nowMillis < freshUntil {
+		return value, true
+	}
+	if nowMillis >= freshUntil+p.cfg.StaleWhileRevalidate.Milliseconds() {
+		return nil, false
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if deadline, ok := p.revalidating[key]; ok && now.Before(deadline) {
+		p.staleHits.Inc()
+		return value, true
+	}
+	p.sweep(now)
+	p.revalidating[key] = now.Add(revalidationTimeout)
+	p.revalidations.Inc()
+	return nil, false
+}
+
+// sweep removes the revalidations which timed out, at most once per revalidation timeout. Must be called with the
+// mutex held.
+func (p *TTLPolicy) sweep(now time.Time) {
+	if now.Sub(p.lastSweep) < revalidationTimeout {
+		return
+	}
+	p.lastSweep = now
+	for key, deadline := range p.revalidating {
+		if !now.Before(deadline) {
+			delete(p.revalidating, key)
+		}
+	}
+}
+
+type ttlPolicyCache struct {
+	next   Cache
+	policy *TTLPolicy
+	ttl    time.Duration
+}
+
+// NewTTLPolicyCache makes a new cache wrapper expiring the entries with the TTL policy, where the TTL is the validity
+// of the entries. The cache must keep the entries for the TTL and the stale-while-revalidate period.
+func NewTTLPolicyCache(next Cache, policy *TTLPolicy, ttl time.Duration) Cache {
+	return &ttlPolicyCache{
+		next:   next,
+		policy: policy,
+		ttl:    ttl,
+	}
+}
+
+func (c *ttlPolicyCache) Store(ctx context.Context, keys []string, bufs [][]byte) {
+	now := time.Now()
+	prefixedKeys := make([]string, 0, len(keys))
+	wrapped := make([][]byte, 0, len(bufs))
+	for i, key := range keys {
+		buf, _ := c.policy.Wrap(key, bufs[i], c.ttl, now)
+		prefixedKeys = append(prefixedKeys, TTLPolicyKeyPrefix+key)
+		wrapped = append(wrapped, buf)
+	}
+	c.next.Store(ctx, prefixedKeys, wrapped)
+}
+
+func (c *ttlPolicyCache) Fetch(ctx context.Context, keys []string) (found []string, bufs [][]byte, missing []string) {
+	prefixedKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		prefixedKeys = append(prefixedKeys, TTLPolicyKeyPrefix+key)
+	}
+
+	now := time.Now()
+	hits := map[string][]byte{}
+	prefixedFound, prefixedBufs, _ := c.next.Fetch(ctx, prefixedKeys)
+	for i, prefixedKey := range prefixedFound {
+		key := prefixedKey[len(TTLPolicyKeyPrefix):]
+		if buf, ok := c.policy.Unwrap(key, prefixedBufs[i], now); ok {
+			hits[key] = buf
+		}
+	}
+
+	for _, key := range keys {
+		if buf, ok := hits[key]; ok {
+			found = append(found, key)
+			bufs = append(bufs, buf)
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	return
+}
+
+func (c *ttlPolicyCache) Stop() {
+	c.next.Stop()
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTTLPolicyConfig_Validate(t *testing.T) {
+	assert.NoError(t, (&TTLPolicyConfig{TTLJitter: 0.1, StaleWhileRevalidate: time.Minute}).Validate())
+	assert.ErrorIs(t, (&TTLPolicyConfig{TTLJitter: 1.5}).Validate(), errInvalidTTLJitter)
+	assert.ErrorIs(t, (&TTLPolicyConfig{StaleWhileRevalidate: -time.Minute}).Validate(), errInvalidStaleWhileRevalidate)
+
+	cfg := Config{TTLPolicy: TTLPolicyConfig{TTLJitter: 0.1}}
+	assert.ErrorIs(t, cfg.Validate(), errTTLPolicyWithoutDefaultValidity)
+}
+
+func TestTTLPolicy_ShouldServeTheStaleValuesWhileTheFirstRequestRevalidatesThem(t *testing.T) {
+	policy := NewTTLPolicy("test", TTLPolicyConfig{StaleWhileRevalidate: 5 * time.Minute}, prometheus.NewPedanticRegistry())
+	now := time.Now()
+
+	wrapped, ttl := policy.Wrap("key", []byte("value"), time.Hour, now)
+	assert.Equal(t, time.Hour+5*time.Minute, ttl)
+
+	value, ok := policy.Unwrap("key", wrapped, now.Add(59*time.Minute))
+	require.True(t, ok)
+	assert.Equal(t, []byte("value"), value)
+
+	// The first request reading the stale value misses it, while the next ones are served the stale value.
+	_, ok = policy.Unwrap("key", wrapped, now.Add(time.Hour))
+	assert.False(t, ok)
+	value, ok = policy.Unwrap("key", wrapped, now.Add(time.Hour+time.Second))
+	require.True(t, ok)
+	assert.Equal(t, []byte("value"), value)
+
+	// Another request revalidates the value if it's not refreshed in time.
+	_, ok = policy.Unwrap("key", wrapped, now.Add(time.Hour+revalidationTimeout))
+	assert.False(t, ok)
+
+	value, ok = policy.Unwrap("key", wrapped, now.Add(time.Hour+revalidationTimeout+time.Second))
+	require.True(t, ok)
+	assert.Equal(t, []byte("value"), value)
+
+	// The value isn't served anymore after the stale-while-revalidate period.
+	_, ok = policy.Unwrap("key", wrapped, now.Add(time.Hour+5*time.Minute))
+	assert.False(t, ok)
+
+	// A TTL of 0 never expires.
+	wrapped, ttl = policy.Wrap("key", []byte("value"), 0, now)
+	assert.Equal(t, time.Duration(0), ttl)
+	_, ok = policy.Unwrap("key", wrapped, now.Add(24*365*time.Hour))
+	assert.True(t, ok)
+}
+
+func TestTTLPolicy_ShouldJitterTheTTL(t *testing.T) {
+	policy := NewTTLPolicy("test", TTLPolicyConfig{TTLJitter: 0.5}, prometheus.NewPedanticRegistry())
+	now := time.Now()
+
+	expired := 0
+	for i := 0; i < 100; i++ {
+		wrapped, ttl := policy.Wrap("key", []byte("value"), time.Hour, now)
+		assert.Equal(t, time.Hour, ttl)
+
+		_, ok := policy.Unwrap("key", wrapped, now.Add(30*time.Minute))
+		require.True(t, ok)
+		if _, ok := policy.Unwrap("key", wrapped, now.Add(45*time.Minute)); !ok {
+			expired++
+		}
+	}
+	assert.Greater(t, expired, 0)
+	assert.Less(t, expired, 100)
+}
+
+func TestTTLPolicyCache(t *testing.T) {
+	next := NewMockCache()
+	c := NewTTLPolicyCache(next, NewTTLPolicy("test", TTLPolicyConfig{StaleWhileRevalidate: time.Minute}, prometheus.NewPedanticRegistry()), time.Hour)
+
+	ctx := context.Background()
+	c.Store(ctx, []string{"key1", "key2"}, [][]byte{[]byte("value1"), []byte("value2")})
+	found, bufs, missing := c.Fetch(ctx, []string{"key1", "key2", "key3"})
+	assert.Equal(t, []string{"key1", "key2"}, found)
+	assert.Equal(t, [][]byte{[]byte("value1"), []byte("value2")}, bufs)
+	assert.Equal(t, []string{"key3"}, missing)
+
+	// The entries are stored with their freshness, under prefixed keys.
+	found, _, _ = next.Fetch(ctx, []string{"key1", TTLPolicyKeyPrefix + "key1"})
+	assert.Equal(t, []string{TTLPolicyKeyPrefix + "key1"}, found)
+}
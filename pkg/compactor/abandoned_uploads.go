@@ -0,0 +1,139 @@
+package compactor
+
+import (
+	"context"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+
+	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
+	"github.com/cortexproject/cortex/pkg/util/concurrency"
+)
+
+const (
+	abandonedUploadPartialBlock = "partial-block"
+	abandonedUploadDeletionMark = "deletion-mark"
+)
+
+// cleanUserAbandonedDeletionMarks deletes the global deletion marks of the blocks which don't exist
+// in the storage anymore, once the deletion delay and the grace period have elapsed. This happens when
+// the block deletion has been interrupted after deleting the block local deletion mark: the global one
+// is left behind and, given the bucket index updater reads the local one, it's never cleaned up. The
+// provided index is updated accordingly.
+func (c *BlocksCleaner) cleanUserAbandonedDeletionMarks(ctx context.Context, idx *bucketindex.Index, partials map[ulid.ULID]error, userBucket objstore.InstrumentedBucket, userLogger log.Logger) {
+	// The updater lists all the block directories, so a deletion mark whose block is neither
+	// in the index nor a partial block has no block directory in the storage.
+	existing := make(map[ulid.ULID]struct{}, len(idx.Blocks)+len(partials))
+	for _, b := range idx.Blocks {
+		existing[b.ID] = struct{}{}
+	}
+	for id := range partials {
+		existing[id] = struct{}{}
+	}
+
+	threshold := time.Now().Add(-c.cfg.DeletionDelay - c.cfg.AbandonedUploadsCleanupGracePeriod)
+
+	var abandoned []ulid.ULID
+	err := userBucket.Iter(ctx, bucketindex.MarkersPathname+"/", func(name string) error {
+		blockID, ok := bucketindex.IsBlockDeletionMarkFilename(path.Base(name))
+		if !ok {
+			return nil
+		}
+		if _, ok := existing[blockID]; ok {
+			return nil
+		}
+
+		attrs, err := userBucket.Attributes(ctx, name)
+		if userBucket.IsObjNotFoundErr(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if attrs.LastModified.Before(threshold) {
+			abandoned = append(abandoned, blockID)
+		}
+		return nil
+	})
+	if err != nil {
+		level.Warn(userLogger).Log("msg", "failed to list block deletion marks", "err", err)
+		return
+	}
+
+	for _, blockID := range abandoned {
+		if err := userBucket.Delete(ctx, bucketindex.BlockDeletionMarkFilepath(blockID)); err != nil && !userBucket.IsObjNotFoundErr(err) {
+			level.Warn(userLogger).Log("msg", "failed to delete abandoned block deletion mark", "block", blockID, "err", err)
+			continue
+		}
+
+		idx.RemoveBlock(blockID)
+		c.abandonedUploadsCleaned.WithLabelValues(abandonedUploadDeletionMark).Inc()
+		level.Info(userLogger).Log("msg", "deleted abandoned block deletion mark", "block", blockID)
+	}
+}
+
+// cleanUserAbandonedPartialBlocks deletes the partial blocks, with a missing meta.json, whose objects
+// have not been modified for longer than the grace period: their upload has been abandoned and they
+// would never be completed. The provided partials map is updated accordingly.
+func (c *BlocksCleaner) cleanUserAbandonedPartialBlocks(ctx context.Context, userID string, partials map[ulid.ULID]error, userBucket objstore.InstrumentedBucket, userLogger log.Logger) {
+	blocks := make([]interface{}, 0, len(partials))
+	for blockID, blockErr := range partials {
+		if errors.Is(blockErr, bucketindex.ErrBlockMetaNotFound) {
+			blocks = append(blocks, blockID)
+		}
+	}
+
+	threshold := time.Now().Add(-c.cfg.AbandonedUploadsCleanupGracePeriod)
+	var mux sync.Mutex
+
+	_ = concurrency.ForEach(ctx, blocks, defaultDeleteBlocksConcurrency, func(ctx context.Context, job interface{}) error {
+		blockID := job.(ulid.ULID)
+
+		size, lastModified, err := blockObjectsStats(ctx, userBucket, blockID)
+		if err != nil {
+			level.Warn(userLogger).Log("msg", "failed to read partial block objects", "block", blockID, "err", err)
+			return nil
+		}
+		if !lastModified.Before(threshold) {
+			return nil
+		}
+
+		if err := block.Delete(ctx, userLogger, userBucket, blockID); err != nil {
+			c.blockDeletionFailed(userLogger, blockID, "failed to delete abandoned partial block", err)
+			return nil
+		}
+		c.tenantReclaimedBytes.WithLabelValues(userID).Add(float64(size))
+
+		mux.Lock()
+		delete(partials, blockID)
+		mux.Unlock()
+
+		c.blocksCleanedTotal.Inc()
+		c.abandonedUploadsCleaned.WithLabelValues(abandonedUploadPartialBlock).Inc()
+		level.Info(userLogger).Log("msg", "deleted abandoned partial block", "block", blockID, "last_modified", lastModified, "reclaimed_bytes", size)
+		return nil
+	})
+}
+
+// blockObjectsStats returns the total size and the most recent modification time of the objects of a block.
+func blockObjectsStats(ctx context.Context, userBucket objstore.Bucket, blockID ulid.ULID) (size int64, lastModified time.Time, _ error) {
+	err := userBucket.Iter(ctx, blockID.String(), func(name string) error {
+		attrs, err := userBucket.Attributes(ctx, name)
+		if err != nil {
+			return err
+		}
+		size += attrs.Size
+		if attrs.LastModified.After(lastModified) {
+			lastModified = attrs.LastModified
+		}
+		return nil
+	}, objstore.WithRecursiveIter)
+	return size, lastModified, err
+}
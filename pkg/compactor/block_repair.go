@@ -0,0 +1,667 @@
+package compactor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/index"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact"
+	"github.com/thanos-io/thanos/pkg/runutil"
+
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+)
+
+const (
+	// blockRepairReportFilename is the name of the per-tenant file, stored in the tenant
+	// directory, holding the outcome of the blocks repair.
+	blockRepairReportFilename = "block-repair-report.json"
+
+	// maxBlockRepairReportEntries is the max number of repairs kept in the report.
+	maxBlockRepairReportEntries = 100
+
+	// blockRepairFailedNoCompactReason is the reason of the no-compact mark added to the
+	// blocks which can't be repaired, so that they don't block the compaction of the tenant.
+	blockRepairFailedNoCompactReason metadata.NoCompactReason = "block-repair-failed"
+
+	blockRepairActionRepaired        = "repaired"
+	blockRepairActionMetaRestored    = "meta-restored"
+	blockRepairActionMarkedNoCompact = "marked-no-compact"
+)
+
+// blockRepairReport is the outcome of the blocks repair of a tenant.
+type blockRepairReport struct {
+	// CheckedBlocks holds the IDs of the blocks which have been checked, and found healthy
+	// or repaired, so that they're not checked again.
+	CheckedBlocks []ulid.ULID `json:"checked_blocks"`
+
+	// Repairs holds the most recent repairs, sorted by time.
+	Repairs []blockRepair `json:"repairs"`
+
+	// UpdatedAt is a unix timestamp (seconds precision) of when the report has been updated.
+	UpdatedAt int64 `json:"updated_at"`
+}
+
+// blockRepair is the outcome of the repair of a block.
+type blockRepair struct {
+	BlockID ulid.ULID `json:"block_id"`
+	Issue   string    `json:"issue"`
+	Action  string    `json:"action"`
+
+	// NewBlockID is the ID of the block uploaded by the repair, if any.
+	NewBlockID *ulid.ULID `json:"new_block_id,omitempty"`
+
+	// Error is the reason why the block couldn't be repaired, if any.
+	Error string `json:"error,omitempty"`
+
+	// Time is a unix timestamp (seconds precision) of when the repair has been done.
+	Time int64 `json:"time"`
+}
+
+// repairUserBlocks checks the health of the blocks of a user which could prevent their compaction,
+// repairing the corrupted ones. Only the blocks uploaded by the ingesters (compaction level 1) and the
+// ones excluded from compaction because of out-of-order chunks are checked, given the blocks generated
+// by the compactor are verified before being uploaded. Each block is checked once, and the outcome of
+// the repairs is tracked in the tenant's block repair report.
+func (c *Compactor) repairUserBlocks(ctx context.Context, logger log.Logger, userID string, bkt objstore.Bucket, fetcher block.MetadataFetcher, noCompactMarkFilter *compact.GatherNoCompactionMarkFilter, dir string) error {
+	metas, partials, err := fetcher.Fetch(ctx)
+	if err != nil {
+		return errors.Wrap(err, "fetch blocks metadata")
+	}
+
+	// The no-compact marks are gathered while fetching the metas.
+	noCompactMarks := noCompactMarkFilter.NoCompactMarkedBlocks()
+
+	report, err := readBlockRepairReport(ctx, logger, bkt)
+	if err != nil {
+		return errors.Wrap(err, "read block repair report")
+	}
+
+	checked := make(map[ulid.ULID]struct{}, len(report.CheckedBlocks))
+	for _, id := range report.CheckedBlocks {
+		checked[id] = struct{}{}
+	}
+
+	var repairs []blockRepair
+
+	for _, meta := range blocksToCheckForRepair(metas, noCompactMarks, checked) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		c.blocksCheckedForRepair.Inc()
+		checked[meta.ULID] = struct{}{}
+
+		issue, err := checkBlockHealth(ctx, logger, bkt, meta, dir)
+		if err != nil {
+			return errors.Wrapf(err, "check block %s", meta.ULID)
+		}
+		if issue == "" {
+			continue
+		}
+
+		level.Warn(logger).Log("msg", "found corrupted block, repairing it", "block", meta.ULID, "issue", issue)
+		repair := blockRepair{BlockID: meta.ULID, Issue: issue, Time: time.Now().Unix()}
+
+		newID, repairErr := repairBlock(ctx, logger, bkt, meta, dir)
+		if repairErr == nil {
+			c.blocksRepaired.Inc()
+			repair.Action = blockRepairActionRepaired
+			repair.NewBlockID = &newID
+			checked[newID] = struct{}{}
+
+			if err := block.MarkForDeletion(ctx, logger, bkt, meta.ULID, "block repaired", c.blocksMarkedForRepair); err != nil {
+				return errors.Wrapf(err, "mark block %s for deletion", meta.ULID)
+			}
+		} else {
+			// The repair of a block may legitimately fail, for example if the index can't be read
+			// at all, so we exclude the block from compaction instead of failing the compaction.
+			c.blockRepairFailures.Inc()
+			level.Error(logger).Log("msg", "failed to repair block, marking it for no compaction", "block", meta.ULID, "err", repairErr)
+			repair.Action = blockRepairActionMarkedNoCompact
+			repair.Error = repairErr.Error()
+
+			if _, ok := noCompactMarks[meta.ULID]; !ok {
+				if err := block.MarkForNoCompact(ctx, logger, bkt, meta.ULID, blockRepairFailedNoCompactReason, repairErr.Error(), c.blocksMarkedForNoCompaction); err != nil {
+					return errors.Wrapf(err, "mark block %s for no compaction", meta.ULID)
+				}
+			}
+		}
+
+		repairs = append(repairs, repair)
+	}
+
+	for id, partialErr := range partials {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !errors.Is(partialErr, block.ErrorSyncMetaNotFound) {
+			continue
+		}
+
+		restored, err := restoreBlockMeta(ctx, logger, bkt, id, userID, compact.PartialUploadThresholdAge, dir)
+		if err != nil {
+			// The block is left partial, so it will be tried again on the next run.
+			level.Warn(logger).Log("msg", "failed to restore the meta.json of partial block", "block", id, "err", err)
+			continue
+		}
+		if restored {
+			c.blocksRepaired.Inc()
+			checked[id] = struct{}{}
+			repairs = append(repairs, blockRepair{BlockID: id, Issue: "missing meta.json", Action: blockRepairActionMetaRestored, Time: time.Now().Unix()})
+		}
+	}
+
+	// Keep track only of the blocks still existing, to keep the report small.
+	checkedBlocks := make([]ulid.ULID, 0, len(checked))
+	for id := range checked {
+		if _, ok := metas[id]; ok || containsRepairedBlock(repairs, id) {
+			checkedBlocks = append(checkedBlocks, id)
+		}
+	}
+	sort.Slice(checkedBlocks, func(i, j int) bool {
+		return checkedBlocks[i].Compare(checkedBlocks[j]) < 0
+	})
+
+	if len(repairs) == 0 && equalULIDs(report.CheckedBlocks, checkedBlocks) {
+		return nil
+	}
+	report.CheckedBlocks = checkedBlocks
+
+	report.Repairs = append(report.Repairs, repairs...)
+	if len(report.Repairs) > maxBlockRepairReportEntries {
+		report.Repairs = report.Repairs[len(report.Repairs)-maxBlockRepairReportEntries:]
+	}
+	report.UpdatedAt = time.Now().Unix()
+
+	return errors.Wrap(writeBlockRepairReport(ctx, bkt, report), "write block repair report")
+}
+
+// blocksToCheckForRepair returns the raw blocks uploaded by the ingesters and the blocks marked for
+// no compaction because of out-of-order chunks, which have not been checked yet.
+func blocksToCheckForRepair(metas map[ulid.ULID]*metadata.Meta, noCompactMarks map[ulid.ULID]*metadata.NoCompactMark, checked map[ulid.ULID]struct{}) []*metadata.Meta {
+	var result []*metadata.Meta
+	for id, m := range metas {
+		if _, ok := checked[id]; ok || m.Thanos.Downsample.Resolution != 0 {
+			continue
+		}
+
+		mark, marked := noCompactMarks[id]
+		if marked && mark.Reason != metadata.OutOfOrderChunksNoCompactReason {
+			continue
+		}
+		if !marked && m.Compaction.Level != 1 {
+			continue
+		}
+
+		result = append(result, m)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].MinTime < result[j].MinTime
+	})
+
+	return result
+}
+
+func equalULIDs(a, b []ulid.ULID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsRepairedBlock(repairs []blockRepair, id ulid.ULID) bool {
+	for _, r := range repairs {
+		if r.BlockID == id || (r.NewBlockID != nil && *r.NewBlockID == id) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkBlockHealth downloads the index of the block and checks its health, returning the issue
+// found or an empty string if the block is healthy.
+func checkBlockHealth(ctx context.Context, logger log.Logger, bkt objstore.Bucket, meta *metadata.Meta, dir string) (string, error) {
+	blockDir := filepath.Join(dir, meta.ULID.String())
+	defer func() {
+		if err := os.RemoveAll(blockDir); err != nil {
+			level.Warn(logger).Log("msg", "failed to remove downloaded block", "block", meta.ULID, "err", err)
+		}
+	}()
+
+	if err := os.MkdirAll(blockDir, 0750); err != nil {
+		return "", err
+	}
+
+	indexPath := filepath.Join(blockDir, block.IndexFilename)
+	if err := objstore.DownloadFile(ctx, logger, bkt, path.Join(meta.ULID.String(), block.IndexFilename), indexPath); err != nil {
+		return "", errors.Wrap(err, "download index")
+	}
+
+	stats, err := block.GatherIndexHealthStats(ctx, logger, indexPath, meta.MinTime, meta.MaxTime)
+	if err != nil {
+		return "broken index: " + err.Error(), nil
+	}
+	if err := stats.AnyErr(); err != nil {
+		return err.Error(), nil
+	}
+	return "", nil
+}
+
+// repairBlock downloads the block, rewrites it and uploads the new block. The series are read
+// following the postings of all series, so that the index is rebuilt from scratch. Their chunks
+// are sorted and merged, deduplicating the samples of overlapping chunks, and the samples outside
+// of the block time range are dropped. Series with the same labels are merged too. The series are
+// rewritten one by one, so that only the samples of the series being rewritten are held in memory.
+func repairBlock(ctx context.Context, logger log.Logger, bkt objstore.Bucket, meta *metadata.Meta, dir string) (ulid.ULID, error) {
+	blockDir := filepath.Join(dir, meta.ULID.String())
+	defer func() {
+		if err := os.RemoveAll(blockDir); err != nil {
+			level.Warn(logger).Log("msg", "failed to remove downloaded block", "block", meta.ULID, "err", err)
+		}
+	}()
+
+	if err := block.Download(ctx, logger, bkt, meta.ULID, blockDir); err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "download block")
+	}
+
+	b, err := tsdb.OpenBlock(logger, blockDir, chunkenc.NewPool())
+	if err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "open block")
+	}
+	defer runutil.CloseWithLogOnErr(logger, b, "repaired block reader")
+
+	indexr, err := b.Index()
+	if err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "open index")
+	}
+	defer runutil.CloseWithLogOnErr(logger, indexr, "repaired block index reader")
+
+	chunkr, err := b.Chunks()
+	if err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "open chunks")
+	}
+	defer runutil.CloseWithLogOnErr(logger, chunkr, "repaired block chunks reader")
+
+	series, symbols, err := readBlockSeriesForRepair(ctx, indexr)
+	if err != nil {
+		return ulid.ULID{}, err
+	}
+	if len(series) == 0 {
+		return ulid.ULID{}, errors.New("no series found in the block")
+	}
+
+	w, err := newBlockSeriesWriter(ctx, logger, dir, symbols)
+	if err != nil {
+		return ulid.ULID{}, err
+	}
+	defer w.close()
+
+	var (
+		builder labels.ScratchBuilder
+		chks    []chunks.Meta
+		it      chunkenc.Iterator
+	)
+
+	for _, s := range series {
+		if err := ctx.Err(); err != nil {
+			return ulid.ULID{}, err
+		}
+
+		var sources []storage.Series
+		for _, ref := range s.refs {
+			if err := indexr.Series(ref, &builder, &chks); err != nil {
+				return ulid.ULID{}, errors.Wrapf(err, "read series %s", s.labels)
+			}
+			for _, chk := range chks {
+				c, _, err := chunkr.ChunkOrIterable(chk)
+				if err != nil {
+					return ulid.ULID{}, errors.Wrapf(err, "read chunk of series %s", s.labels)
+				}
+				sources = append(sources, &chunkSeries{labels: s.labels, chunk: c})
+			}
+		}
+
+		it = storage.ChainedSeriesMerge(sources...).Iterator(it)
+		samples, err := readSamplesInRange(it, meta.MinTime, meta.MaxTime)
+		if err != nil {
+			return ulid.ULID{}, errors.Wrapf(err, "iterate chunks of series %s", s.labels)
+		}
+		if err := w.writeSeries(storage.NewListSeries(s.labels, samples)); err != nil {
+			return ulid.ULID{}, err
+		}
+	}
+
+	thanosMeta := meta.Thanos
+	thanosMeta.Source = metadata.BucketRepairSource
+	thanosMeta.Rewrites = append(thanosMeta.Rewrites, metadata.Rewrite{Sources: meta.Compaction.Sources})
+
+	// Keep the time range and compaction level of the original block, so that the repaired block
+	// is compacted like the original one would have been.
+	newMeta, err := w.flush(metadata.Meta{
+		BlockMeta: tsdb.BlockMeta{
+			MinTime:    meta.MinTime,
+			MaxTime:    meta.MaxTime,
+			Compaction: tsdb.BlockMetaCompaction{Level: meta.Compaction.Level, Sources: []ulid.ULID{w.id}},
+		},
+		Thanos: thanosMeta,
+	})
+	if err != nil {
+		return ulid.ULID{}, err
+	}
+	if newMeta == nil {
+		return ulid.ULID{}, errors.New("no samples found within the block time range")
+	}
+
+	// Ensure the repaired block is healthy before uploading it.
+	stats, err := block.GatherIndexHealthStats(ctx, logger, filepath.Join(w.dir, block.IndexFilename), newMeta.MinTime, newMeta.MaxTime)
+	if err == nil {
+		err = stats.AnyErr()
+	}
+	if err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "repaired block is not healthy")
+	}
+
+	if err := block.Upload(ctx, logger, bkt, w.dir, metadata.NoneFunc); err != nil {
+		return ulid.ULID{}, errors.Wrapf(err, "upload repaired block %s", newMeta.ULID)
+	}
+
+	level.Info(logger).Log("msg", "repaired block", "source", meta.ULID, "block", newMeta.ULID, "series", newMeta.Stats.NumSeries)
+	return newMeta.ULID, nil
+}
+
+// repairedSeries holds the references of the series of a block being repaired which have the same labels.
+type repairedSeries struct {
+	labels labels.Labels
+	refs   []storage.SeriesRef
+}
+
+// readBlockSeriesForRepair reads the labels of all the series of the index, fixing the labels out of
+// order, and grouping the series with the same labels. It returns the series sorted by labels, and the
+// symbols of their labels.
+func readBlockSeriesForRepair(ctx context.Context, indexr tsdb.IndexReader) ([]*repairedSeries, map[string]struct{}, error) {
+	key, value := index.AllPostingsKey()
+	postings, err := indexr.Postings(ctx, key, value)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "read postings")
+	}
+
+	var (
+		builder labels.ScratchBuilder
+		chks    []chunks.Meta
+		groups  = map[string]*repairedSeries{}
+		symbols = map[string]struct{}{}
+	)
+
+	for postings.Next() {
+		if err := indexr.Series(postings.At(), &builder, &chks); err != nil {
+			return nil, nil, errors.Wrap(err, "read series")
+		}
+		if len(chks) == 0 {
+			continue
+		}
+
+		// Fix the labels out of order, if any.
+		builder.Sort()
+		lbls := builder.Labels()
+
+		key := lbls.String()
+		if g, ok := groups[key]; ok {
+			g.refs = append(g.refs, postings.At())
+			continue
+		}
+
+		groups[key] = &repairedSeries{labels: lbls, refs: []storage.SeriesRef{postings.At()}}
+		lbls.Range(func(l labels.Label) {
+			symbols[l.Name] = struct{}{}
+			symbols[l.Value] = struct{}{}
+		})
+	}
+	if err := postings.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "iterate postings")
+	}
+
+	result := make([]*repairedSeries, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return labels.Compare(result[i].labels, result[j].labels) < 0
+	})
+
+	return result, symbols, nil
+}
+
+// chunkSeries is a series made of a single chunk.
+type chunkSeries struct {
+	labels labels.Labels
+	chunk  chunkenc.Chunk
+}
+
+func (s *chunkSeries) Labels() labels.Labels {
+	return s.labels
+}
+
+func (s *chunkSeries) Iterator(it chunkenc.Iterator) chunkenc.Iterator {
+	return s.chunk.Iterator(it)
+}
+
+// readSamplesInRange returns the samples of the sorted iterator within the [minT, maxT) time range.
+func readSamplesInRange(it chunkenc.Iterator, minT, maxT int64) ([]chunks.Sample, error) {
+	var samples []chunks.Sample
+	for typ := it.Next(); typ != chunkenc.ValNone; typ = it.Next() {
+		if t := it.AtT(); t < minT {
+			continue
+		} else if t >= maxT {
+			break
+		}
+
+		switch typ {
+		case chunkenc.ValFloat:
+			t, f := it.At()
+			samples = append(samples, repairedSample{t: t, f: f})
+		case chunkenc.ValHistogram:
+			t, h := it.AtHistogram(nil)
+			samples = append(samples, repairedSample{t: t, h: h})
+		case chunkenc.ValFloatHistogram:
+			t, fh := it.AtFloatHistogram(nil)
+			samples = append(samples, repairedSample{t: t, fh: fh})
+		}
+	}
+	return samples, it.Err()
+}
+
+// repairedSample is a float, histogram or float histogram sample.
+type repairedSample struct {
+	t  int64
+	f  float64
+	h  *histogram.Histogram
+	fh *histogram.FloatHistogram
+}
+
+func (s repairedSample) T() int64                      { return s.t }
+func (s repairedSample) F() float64                    { return s.f }
+func (s repairedSample) H() *histogram.Histogram       { return s.h }
+func (s repairedSample) FH() *histogram.FloatHistogram { return s.fh }
+
+func (s repairedSample) Type() chunkenc.ValueType {
+	switch {
+	case s.h != nil:
+		return chunkenc.ValHistogram
+	case s.fh != nil:
+		return chunkenc.ValFloatHistogram
+	default:
+		return chunkenc.ValFloat
+	}
+}
+
+// restoreBlockMeta rebuilds and uploads the meta.json of a block whose upload has been interrupted
+// after the index was uploaded, which means all its chunks have been uploaded too. The block is
+// restored only if its index was uploaded longer than minAge ago, to not interfere with an upload
+// in progress. It returns whether the meta.json has been restored.
+func restoreBlockMeta(ctx context.Context, logger log.Logger, bkt objstore.Bucket, id ulid.ULID, userID string, minAge time.Duration, dir string) (bool, error) {
+	attrs, err := bkt.Attributes(ctx, path.Join(id.String(), block.IndexFilename))
+	if err != nil {
+		if bkt.IsObjNotFoundErr(err) {
+			// Nothing to restore, the block upload didn't even complete the index.
+			return false, nil
+		}
+		return false, errors.Wrap(err, "read index attributes")
+	}
+	if time.Since(attrs.LastModified) < minAge {
+		return false, nil
+	}
+
+	// Blocks being deleted have their meta.json deleted first and the deletion mark last,
+	// so we must not restore a block with a deletion mark.
+	if marked, err := bkt.Exists(ctx, path.Join(id.String(), metadata.DeletionMarkFilename)); err != nil {
+		return false, errors.Wrap(err, "check deletion mark")
+	} else if marked {
+		return false, nil
+	}
+
+	blockDir := filepath.Join(dir, id.String())
+	defer func() {
+		if err := os.RemoveAll(blockDir); err != nil {
+			level.Warn(logger).Log("msg", "failed to remove downloaded block", "block", id, "err", err)
+		}
+	}()
+
+	if err := objstore.DownloadDir(ctx, logger, bkt, id.String(), id.String(), blockDir); err != nil {
+		return false, errors.Wrap(err, "download block")
+	}
+
+	meta, err := buildBlockMeta(ctx, logger, id, blockDir)
+	if err != nil {
+		return false, err
+	}
+
+	meta.Thanos = metadata.Thanos{
+		Version: metadata.ThanosVersion1,
+		Labels:  map[string]string{cortex_tsdb.TenantIDExternalLabel: userID},
+		Source:  metadata.BucketRepairSource,
+		Files:   []metadata.File{},
+	}
+
+	data, err := json.MarshalIndent(meta, "", "\t")
+	if err != nil {
+		return false, errors.Wrap(err, "encode meta.json")
+	}
+	if err := bkt.Upload(ctx, path.Join(id.String(), block.MetaFilename), bytes.NewReader(data)); err != nil {
+		return false, errors.Wrap(err, "upload meta.json")
+	}
+
+	level.Info(logger).Log("msg", "restored meta.json of partial block", "block", id, "min_time", meta.MinTime, "max_time", meta.MaxTime)
+	return true, nil
+}
+
+// buildBlockMeta builds the TSDB meta of the block in the input directory reading its index and chunks.
+func buildBlockMeta(ctx context.Context, logger log.Logger, id ulid.ULID, blockDir string) (*metadata.Meta, error) {
+	indexr, err := index.NewFileReader(filepath.Join(blockDir, block.IndexFilename))
+	if err != nil {
+		return nil, errors.Wrap(err, "open index")
+	}
+	defer runutil.CloseWithLogOnErr(logger, indexr, "close index reader")
+
+	chunkr, err := chunks.NewDirReader(filepath.Join(blockDir, block.ChunksDirname), chunkenc.NewPool())
+	if err != nil {
+		return nil, errors.Wrap(err, "open chunks")
+	}
+	defer runutil.CloseWithLogOnErr(logger, chunkr, "close chunks reader")
+
+	key, value := index.AllPostingsKey()
+	postings, err := indexr.Postings(ctx, key, value)
+	if err != nil {
+		return nil, errors.Wrap(err, "read postings")
+	}
+
+	meta := &metadata.Meta{}
+	meta.ULID = id
+	meta.Version = metadata.TSDBVersion1
+	meta.MinTime = math.MaxInt64
+	meta.MaxTime = math.MinInt64
+	meta.Compaction = tsdb.BlockMetaCompaction{Level: 1, Sources: []ulid.ULID{id}}
+
+	var (
+		builder labels.ScratchBuilder
+		chks    []chunks.Meta
+	)
+
+	for postings.Next() {
+		if err := indexr.Series(postings.At(), &builder, &chks); err != nil {
+			return nil, errors.Wrap(err, "read series")
+		}
+
+		meta.Stats.NumSeries++
+		for _, chk := range chks {
+			c, _, err := chunkr.ChunkOrIterable(chk)
+			if err != nil {
+				return nil, errors.Wrap(err, "read chunk")
+			}
+
+			meta.Stats.NumChunks++
+			meta.Stats.NumSamples += uint64(c.NumSamples())
+			meta.MinTime = min(meta.MinTime, chk.MinTime)
+			meta.MaxTime = max(meta.MaxTime, chk.MaxTime+1)
+		}
+	}
+	if err := postings.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate postings")
+	}
+	if meta.Stats.NumSamples == 0 {
+		return nil, errors.New("no samples found in the block")
+	}
+
+	return meta, nil
+}
+
+func readBlockRepairReport(ctx context.Context, logger log.Logger, bkt objstore.Bucket) (*blockRepairReport, error) {
+	r, err := bkt.Get(ctx, blockRepairReportFilename)
+	if err != nil {
+		if bkt.IsObjNotFoundErr(err) {
+			return &blockRepairReport{}, nil
+		}
+		return nil, err
+	}
+	defer runutil.CloseWithLogOnErr(logger, r, "close block repair report reader")
+
+	report := &blockRepairReport{}
+	if err := json.NewDecoder(r).Decode(report); err != nil {
+		return nil, errors.Wrap(err, "decode block repair report")
+	}
+	return report, nil
+}
+
+func writeBlockRepairReport(ctx context.Context, bkt objstore.Bucket, report *blockRepairReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	return bkt.Upload(ctx, blockRepairReportFilename, bytes.NewReader(data))
+}
@@ -0,0 +1,212 @@
+package compactor
+
+import (
+	"context"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	cortex_testutil "github.com/cortexproject/cortex/pkg/storage/tsdb/testutil"
+)
+
+// createBlockWithChunksOutsideOfItsRange creates a block whose meta.json time range doesn't
+// include the last sample of the block, which is a corruption preventing its compaction.
+func createBlockWithChunksOutsideOfItsRange(t *testing.T, bkt objstore.Bucket, userBucket objstore.Bucket, userID string) *metadata.Meta {
+	const blockRange = int64(2 * time.Hour / time.Millisecond)
+
+	id := createTSDBBlock(t, bkt, userID, 0, blockRange, map[string]string{cortex_tsdb.TenantIDExternalLabel: userID})
+
+	meta, err := block.DownloadMeta(context.Background(), log.NewNopLogger(), userBucket, id)
+	require.NoError(t, err)
+
+	meta.MaxTime = blockRange / 2
+	meta.Compaction.Level = 1
+	require.NoError(t, userBucket.Delete(context.Background(), path.Join(id.String(), block.MetaFilename)))
+	dir := t.TempDir()
+	require.NoError(t, meta.WriteToDir(log.NewNopLogger(), dir))
+	require.NoError(t, objstore.UploadFile(context.Background(), log.NewNopLogger(), userBucket, filepath.Join(dir, block.MetaFilename), path.Join(id.String(), block.MetaFilename)))
+
+	return &meta
+}
+
+func TestCheckBlockHealthAndRepairBlock(t *testing.T) {
+	bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	userBucket := bucket.NewUserBucketClient("user-1", bucketClient, nil)
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+
+	// A healthy block.
+	healthyID := createTSDBBlock(t, bucketClient, "user-1", 0, int64(2*time.Hour/time.Millisecond), map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"})
+	healthy, err := block.DownloadMeta(ctx, logger, userBucket, healthyID)
+	require.NoError(t, err)
+
+	issue, err := checkBlockHealth(ctx, logger, userBucket, &healthy, t.TempDir())
+	require.NoError(t, err)
+	assert.Empty(t, issue)
+
+	// A corrupted block.
+	corrupted := createBlockWithChunksOutsideOfItsRange(t, bucketClient, userBucket, "user-1")
+
+	issue, err = checkBlockHealth(ctx, logger, userBucket, corrupted, t.TempDir())
+	require.NoError(t, err)
+	assert.Contains(t, issue, "chunks completely outside the block time range")
+
+	newID, err := repairBlock(ctx, logger, userBucket, corrupted, t.TempDir())
+	require.NoError(t, err)
+
+	repaired, err := block.DownloadMeta(ctx, logger, userBucket, newID)
+	require.NoError(t, err)
+
+	// The sample outside of the block time range should have been dropped.
+	assert.Equal(t, uint64(1), repaired.Stats.NumSeries)
+	assert.Equal(t, uint64(1), repaired.Stats.NumSamples)
+	assert.Equal(t, corrupted.MinTime, repaired.MinTime)
+	assert.Equal(t, corrupted.MaxTime, repaired.MaxTime)
+	assert.Equal(t, corrupted.Compaction.Level, repaired.Compaction.Level)
+	assert.Equal(t, corrupted.Thanos.Labels, repaired.Thanos.Labels)
+	assert.Equal(t, metadata.BucketRepairSource, repaired.Thanos.Source)
+
+	issue, err = checkBlockHealth(ctx, logger, userBucket, &repaired, t.TempDir())
+	require.NoError(t, err)
+	assert.Empty(t, issue)
+}
+
+func TestReadSamplesInRange_ShouldMergeOverlappingChunks(t *testing.T) {
+	lbls := labels.FromStrings(labels.MetricName, "series_1")
+
+	newChunk := func(minT, maxT int64) chunkenc.Chunk {
+		c := chunkenc.NewXORChunk()
+		app, err := c.Appender()
+		require.NoError(t, err)
+		for ts := minT; ts <= maxT; ts++ {
+			app.Append(ts, float64(ts))
+		}
+		return c
+	}
+
+	// Out of order and overlapping chunks, plus a duplicated one.
+	var sources []storage.Series
+	for _, c := range []chunkenc.Chunk{newChunk(15, 24), newChunk(10, 19), newChunk(10, 19), newChunk(30, 40)} {
+		sources = append(sources, &chunkSeries{labels: lbls, chunk: c})
+	}
+
+	// The samples outside of the time range should be dropped.
+	samples, err := readSamplesInRange(storage.ChainedSeriesMerge(sources...).Iterator(nil), 12, 35)
+	require.NoError(t, err)
+
+	var timestamps []int64
+	for _, s := range samples {
+		timestamps = append(timestamps, s.T())
+	}
+	assert.Equal(t, []int64{12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 30, 31, 32, 33, 34}, timestamps)
+}
+
+func TestRestoreBlockMeta(t *testing.T) {
+	bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	userBucket := bucket.NewUserBucketClient("user-1", bucketClient, nil)
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+
+	const blockRange = int64(2 * time.Hour / time.Millisecond)
+
+	id := createTSDBBlock(t, bucketClient, "user-1", 0, blockRange, map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"})
+	require.NoError(t, userBucket.Delete(ctx, path.Join(id.String(), block.MetaFilename)))
+
+	// The block may be still being uploaded.
+	restored, err := restoreBlockMeta(ctx, logger, userBucket, id, "user-1", time.Hour, t.TempDir())
+	require.NoError(t, err)
+	assert.False(t, restored)
+
+	restored, err = restoreBlockMeta(ctx, logger, userBucket, id, "user-1", 0, t.TempDir())
+	require.NoError(t, err)
+	assert.True(t, restored)
+
+	meta, err := block.DownloadMeta(ctx, logger, userBucket, id)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), meta.MinTime)
+	assert.Equal(t, blockRange, meta.MaxTime)
+	assert.Equal(t, uint64(2), meta.Stats.NumSeries)
+	assert.Equal(t, uint64(2), meta.Stats.NumSamples)
+	assert.Equal(t, 1, meta.Compaction.Level)
+	assert.Equal(t, map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"}, meta.Thanos.Labels)
+
+	// A block being deleted should not be restored.
+	require.NoError(t, userBucket.Delete(ctx, path.Join(id.String(), block.MetaFilename)))
+	require.NoError(t, block.MarkForDeletion(ctx, logger, userBucket, id, "test", prometheus.NewCounter(prometheus.CounterOpts{})))
+
+	restored, err = restoreBlockMeta(ctx, logger, userBucket, id, "user-1", 0, t.TempDir())
+	require.NoError(t, err)
+	assert.False(t, restored)
+}
+
+func TestCompactor_RepairUserBlocks(t *testing.T) {
+	bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	userBucket := bucket.NewUserBucketClient("user-1", bucketClient, nil)
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+
+	healthyID := createTSDBBlock(t, bucketClient, "user-1", 0, int64(2*time.Hour/time.Millisecond), map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"})
+	corrupted := createBlockWithChunksOutsideOfItsRange(t, bucketClient, userBucket, "user-1")
+
+	c := &Compactor{
+		blocksCheckedForRepair:      prometheus.NewCounter(prometheus.CounterOpts{}),
+		blocksRepaired:              prometheus.NewCounter(prometheus.CounterOpts{}),
+		blockRepairFailures:         prometheus.NewCounter(prometheus.CounterOpts{}),
+		blocksMarkedForRepair:       prometheus.NewCounter(prometheus.CounterOpts{}),
+		blocksMarkedForNoCompaction: prometheus.NewCounter(prometheus.CounterOpts{}),
+	}
+
+	runRepair := func() {
+		noCompactMarkFilter := compact.NewGatherNoCompactionMarkFilter(logger, userBucket, 1)
+		fetcher, err := block.NewMetaFetcher(logger, 1, userBucket, block.NewConcurrentLister(logger, userBucket), t.TempDir(), nil, []block.MetadataFilter{
+			block.NewIgnoreDeletionMarkFilter(logger, userBucket, 0, 1),
+			noCompactMarkFilter,
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, c.repairUserBlocks(ctx, logger, "user-1", userBucket, fetcher, noCompactMarkFilter, t.TempDir()))
+	}
+
+	runRepair()
+
+	report, err := readBlockRepairReport(ctx, logger, userBucket)
+	require.NoError(t, err)
+	require.Len(t, report.Repairs, 1)
+
+	repair := report.Repairs[0]
+	assert.Equal(t, corrupted.ULID, repair.BlockID)
+	assert.Equal(t, blockRepairActionRepaired, repair.Action)
+	require.NotNil(t, repair.NewBlockID)
+	assert.ElementsMatch(t, []ulid.ULID{healthyID, corrupted.ULID, *repair.NewBlockID}, report.CheckedBlocks)
+
+	exists, err := userBucket.Exists(ctx, path.Join(corrupted.ULID.String(), metadata.DeletionMarkFilename))
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	assert.Equal(t, float64(2), prom_testutil.ToFloat64(c.blocksCheckedForRepair))
+	assert.Equal(t, float64(1), prom_testutil.ToFloat64(c.blocksRepaired))
+	assert.Equal(t, float64(1), prom_testutil.ToFloat64(c.blocksMarkedForRepair))
+
+	// The blocks already checked should not be checked again.
+	runRepair()
+
+	assert.Equal(t, float64(2), prom_testutil.ToFloat64(c.blocksCheckedForRepair))
+	assert.Equal(t, float64(1), prom_testutil.ToFloat64(c.blocksRepaired))
+}
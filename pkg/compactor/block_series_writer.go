@@ -0,0 +1,144 @@
+package compactor
+
+import (
+	"context"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+)
+
+// blockSeriesWriter writes a new block streaming its series through the TSDB index and chunks writers,
+// so that only the series being written is held in memory, unlike the head based tsdb.BlockWriter. The
+// symbols of the block must be known upfront, and the series must be written sorted by labels.
+type blockSeriesWriter struct {
+	logger log.Logger
+	id     ulid.ULID
+	dir    string
+	w      *block.DiskWriter
+
+	ref     storage.SeriesRef
+	flushed bool
+}
+
+// newBlockSeriesWriter creates the directory of a new block in parentDir, and adds the input symbols
+// to its index.
+func newBlockSeriesWriter(ctx context.Context, logger log.Logger, parentDir string, symbols map[string]struct{}) (*blockSeriesWriter, error) {
+	id := ulid.MustNew(ulid.Now(), rand.Reader)
+	dir := filepath.Join(parentDir, id.String())
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, errors.Wrap(err, "create block dir")
+	}
+
+	w, err := block.NewDiskWriter(ctx, logger, dir)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, errors.Wrap(err, "create block writer")
+	}
+
+	bw := &blockSeriesWriter{logger: logger, id: id, dir: dir, w: w}
+
+	sorted := make([]string, 0, len(symbols))
+	for s := range symbols {
+		sorted = append(sorted, s)
+	}
+	sort.Strings(sorted)
+
+	for _, s := range sorted {
+		if err := w.AddSymbol(s); err != nil {
+			bw.close()
+			return nil, errors.Wrap(err, "add symbol")
+		}
+	}
+
+	return bw, nil
+}
+
+// writeChunks writes a series made of the input chunks, which must be sorted by time and not overlap.
+// Series without chunks are skipped.
+func (w *blockSeriesWriter) writeChunks(lbls labels.Labels, chks []chunks.Meta) error {
+	if len(chks) == 0 {
+		return nil
+	}
+
+	// The chunks writer sets the reference of the chunks added to the series.
+	if err := w.w.WriteChunks(chks...); err != nil {
+		return errors.Wrapf(err, "write chunks of series %s", lbls)
+	}
+	if err := w.w.AddSeries(w.ref, lbls, chks...); err != nil {
+		return errors.Wrapf(err, "add series %s", lbls)
+	}
+
+	w.ref++
+	return nil
+}
+
+// writeSeries encodes the samples of the series into chunks and writes them.
+func (w *blockSeriesWriter) writeSeries(s storage.Series) error {
+	chks, err := encodeSeriesChunks(s)
+	if err != nil {
+		return err
+	}
+
+	return w.writeChunks(s.Labels(), chks)
+}
+
+// encodeSeriesChunks encodes the samples of the series into chunks.
+func encodeSeriesChunks(s storage.Series) ([]chunks.Meta, error) {
+	var chks []chunks.Meta
+
+	it := storage.NewSeriesToChunkEncoder(s).Iterator(nil)
+	for it.Next() {
+		chks = append(chks, it.At())
+	}
+	if err := it.Err(); err != nil {
+		return nil, errors.Wrapf(err, "encode chunks of series %s", s.Labels())
+	}
+
+	return chks, nil
+}
+
+// flush completes the block and writes its meta.json, made of the input meta with the ID, version and
+// stats of the written block. It returns nil if no series has been written, in which case the block
+// directory is removed.
+func (w *blockSeriesWriter) flush(meta metadata.Meta) (*metadata.Meta, error) {
+	w.flushed = true
+
+	stats, err := w.w.Flush()
+	if err != nil {
+		return nil, errors.Wrap(err, "flush block")
+	}
+	if stats.NumSeries == 0 {
+		w.close()
+		return nil, nil
+	}
+
+	meta.ULID = w.id
+	meta.Version = metadata.TSDBVersion1
+	meta.Thanos.Version = metadata.ThanosVersion1
+	meta.Stats = stats
+
+	if err := meta.WriteToDir(w.logger, w.dir); err != nil {
+		return nil, errors.Wrap(err, "write block meta")
+	}
+
+	return &meta, nil
+}
+
+// close removes the block directory, aborting the block if it has not been flushed yet.
+func (w *blockSeriesWriter) close() {
+	if !w.flushed {
+		_ = w.w.Close()
+	}
+	_ = os.RemoveAll(w.dir + ".tmp-for-creation")
+	_ = os.RemoveAll(w.dir)
+}
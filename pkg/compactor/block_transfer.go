@@ -0,0 +1,341 @@
+package compactor
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/runutil"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+)
+
+const (
+	// blockTransfersPrefix is the prefix, within the tenant directory, of the block transfer requests.
+	blockTransfersPrefix = "block-transfers"
+
+	blockTransferTypeExport = "export"
+	blockTransferTypeImport = "import"
+
+	blockTransferStatePending = "pending"
+	blockTransferStateDone    = "done"
+	blockTransferStateFailed  = "failed"
+
+	// blockImportSource is the source set in the meta.json of the imported blocks.
+	blockImportSource metadata.SourceType = "compactor.import"
+)
+
+// blockTransfer is a request to export the blocks of a tenant to the block transfer storage,
+// or to import blocks from it, processed asynchronously by the compactor owning the tenant.
+type blockTransfer struct {
+	ID   ulid.ULID `json:"id"`
+	Type string    `json:"type"`
+
+	// Prefix is the location of the blocks in the block transfer storage, relative to the
+	// location of the tenant, so that a tenant can't access the blocks transferred by the others.
+	Prefix string `json:"prefix"`
+
+	// MinTime and MaxTime are the time range, in milliseconds, of the blocks to transfer. The
+	// blocks overlapping the time range are transferred as a whole.
+	MinTime int64 `json:"min_time"`
+	MaxTime int64 `json:"max_time"`
+
+	State string `json:"state"`
+
+	// Blocks holds the IDs of the transferred blocks, once the request has been processed.
+	Blocks []ulid.ULID `json:"blocks,omitempty"`
+
+	// Error is the reason why the request failed, if any.
+	Error string `json:"error,omitempty"`
+
+	// CreatedAt and FinishedAt are unix timestamps (seconds precision) of when the request has
+	// been created and processed.
+	CreatedAt  int64 `json:"created_at"`
+	FinishedAt int64 `json:"finished_at,omitempty"`
+}
+
+func newBlockTransfer(typ, prefix string, minT, maxT int64, now time.Time) (*blockTransfer, error) {
+	if typ != blockTransferTypeExport && typ != blockTransferTypeImport {
+		return nil, fmt.Errorf("invalid block transfer type %q, supported values are: %s, %s", typ, blockTransferTypeExport, blockTransferTypeImport)
+	}
+
+	if strings.HasPrefix(prefix, "/") {
+		return nil, fmt.Errorf("invalid block transfer prefix %q, it must be relative to the tenant location", prefix)
+	}
+
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return nil, errors.New("block transfer prefix not set")
+	}
+	for _, p := range strings.Split(prefix, "/") {
+		if p == "" || p == "." || p == ".." {
+			return nil, fmt.Errorf("invalid block transfer prefix %q", prefix)
+		}
+	}
+
+	if minT > maxT {
+		return nil, errors.New("start time can't be greater than end time")
+	}
+
+	return &blockTransfer{
+		ID:        ulid.MustNew(ulid.Timestamp(now), rand.Reader),
+		Type:      typ,
+		Prefix:    prefix,
+		MinTime:   minT,
+		MaxTime:   maxT,
+		State:     blockTransferStatePending,
+		CreatedAt: now.Unix(),
+	}, nil
+}
+
+// blockTransferLocation returns the location of the blocks of a transfer in the block transfer storage.
+// The prefix is scoped under the tenant, given it's provided by the tenant itself.
+func blockTransferLocation(userID, prefix string) string {
+	return path.Join(userID, prefix)
+}
+
+// includes returns whether the block overlaps the time range of the transfer.
+func (t *blockTransfer) includes(m *metadata.Meta) bool {
+	return m.MinTime < t.MaxTime && m.MaxTime > t.MinTime
+}
+
+// transferUserBlocks processes the pending block transfer requests of a user. A request failing is
+// marked as failed and not retried, given it can be safely submitted again: the blocks already
+// transferred are skipped.
+func (c *Compactor) transferUserBlocks(ctx context.Context, logger log.Logger, userID string, bkt objstore.Bucket, fetcher block.MetadataFetcher) error {
+	transfers, err := readBlockTransfers(ctx, logger, bkt)
+	if err != nil {
+		return errors.Wrap(err, "read block transfers")
+	}
+
+	for _, t := range transfers {
+		if t.State != blockTransferStatePending {
+			continue
+		}
+
+		transferBkt := bucket.NewPrefixedBucketClient(c.blockTransferBucket, blockTransferLocation(userID, t.Prefix))
+
+		var transferErr error
+		if t.Type == blockTransferTypeExport {
+			t.Blocks, transferErr = c.exportBlocks(ctx, logger, t, bkt, transferBkt, fetcher)
+		} else {
+			t.Blocks, transferErr = c.importBlocks(ctx, logger, userID, t, transferBkt, bkt)
+		}
+
+		// Keep the request pending if the compactor is shutting down.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		t.State = blockTransferStateDone
+		if transferErr != nil {
+			c.blockTransferFailures.WithLabelValues(t.Type).Inc()
+			level.Error(logger).Log("msg", "block transfer failed", "id", t.ID, "type", t.Type, "prefix", t.Prefix, "err", transferErr)
+			t.State = blockTransferStateFailed
+			t.Error = transferErr.Error()
+		} else {
+			level.Info(logger).Log("msg", "block transfer completed", "id", t.ID, "type", t.Type, "prefix", t.Prefix, "blocks", len(t.Blocks))
+		}
+		t.FinishedAt = time.Now().Unix()
+
+		if err := writeBlockTransfer(ctx, bkt, t); err != nil {
+			return errors.Wrapf(err, "write block transfer %s", t.ID)
+		}
+	}
+
+	return nil
+}
+
+// exportBlocks copies the blocks of the user within the time range of the transfer to the block
+// transfer storage. The blocks marked for deletion are not exported.
+func (c *Compactor) exportBlocks(ctx context.Context, logger log.Logger, t *blockTransfer, userBkt, transferBkt objstore.Bucket, fetcher block.MetadataFetcher) ([]ulid.ULID, error) {
+	metas, _, err := fetcher.Fetch(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetch blocks metadata")
+	}
+
+	var exported []ulid.ULID
+	for _, m := range sortedMetas(metas) {
+		if !t.includes(m) {
+			continue
+		}
+
+		copied, err := copyBlock(ctx, logger, userBkt, transferBkt, m.ULID, nil)
+		if err != nil {
+			return exported, errors.Wrapf(err, "export block %s", m.ULID)
+		}
+		if copied {
+			c.blocksTransferred.WithLabelValues(blockTransferTypeExport).Inc()
+		}
+		exported = append(exported, m.ULID)
+	}
+
+	return exported, nil
+}
+
+// importBlocks copies the blocks within the time range of the transfer from the block transfer
+// storage to the user bucket. The tenant external label of the imported blocks is rewritten to
+// the importing user.
+func (c *Compactor) importBlocks(ctx context.Context, logger log.Logger, userID string, t *blockTransfer, transferBkt, userBkt objstore.Bucket) ([]ulid.ULID, error) {
+	var ids []ulid.ULID
+	if err := transferBkt.Iter(ctx, "", func(name string) error {
+		if id, ok := block.IsBlockDir(name); ok {
+			ids = append(ids, id)
+		}
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "list blocks to import")
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Compare(ids[j]) < 0
+	})
+
+	var imported []ulid.ULID
+	for _, id := range ids {
+		meta, err := block.DownloadMeta(ctx, logger, transferBkt, id)
+		if err != nil {
+			return imported, errors.Wrapf(err, "read meta of block %s to import", id)
+		}
+		if !t.includes(&meta) {
+			continue
+		}
+
+		copied, err := copyBlock(ctx, logger, transferBkt, userBkt, id, func(m *metadata.Meta) {
+			if m.Thanos.Labels == nil {
+				m.Thanos.Labels = map[string]string{}
+			}
+			m.Thanos.Labels[cortex_tsdb.TenantIDExternalLabel] = userID
+			m.Thanos.Source = blockImportSource
+		})
+		if err != nil {
+			return imported, errors.Wrapf(err, "import block %s", id)
+		}
+		if copied {
+			c.blocksTransferred.WithLabelValues(blockTransferTypeImport).Inc()
+		}
+		imported = append(imported, id)
+	}
+
+	return imported, nil
+}
+
+// copyBlock copies the index, chunks and meta.json of a block, optionally rewriting its meta.json.
+// The meta.json is copied last, so that the block is complete once visible. It returns false if the
+// block already exists in the destination bucket.
+func copyBlock(ctx context.Context, logger log.Logger, src, dst objstore.Bucket, id ulid.ULID, rewriteMeta func(*metadata.Meta)) (bool, error) {
+	metaFile := path.Join(id.String(), block.MetaFilename)
+	if exists, err := dst.Exists(ctx, metaFile); err != nil {
+		return false, err
+	} else if exists {
+		return false, nil
+	}
+
+	meta, err := block.DownloadMeta(ctx, logger, src, id)
+	if err != nil {
+		return false, err
+	}
+	if rewriteMeta != nil {
+		rewriteMeta(&meta)
+	}
+
+	var files []string
+	if err := src.Iter(ctx, path.Join(id.String(), block.ChunksDirname), func(name string) error {
+		files = append(files, name)
+		return nil
+	}); err != nil {
+		return false, errors.Wrap(err, "list chunks")
+	}
+	files = append(files, path.Join(id.String(), block.IndexFilename))
+
+	for _, name := range files {
+		if err := copyObject(ctx, logger, src, dst, name); err != nil {
+			return false, errors.Wrapf(err, "copy %s", name)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := meta.Write(&buf); err != nil {
+		return false, errors.Wrap(err, "encode meta.json")
+	}
+	return true, errors.Wrap(dst.Upload(ctx, metaFile, &buf), "upload meta.json")
+}
+
+func copyObject(ctx context.Context, logger log.Logger, src, dst objstore.Bucket, name string) error {
+	r, err := src.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer runutil.CloseWithLogOnErr(logger, r, "close block transfer object reader")
+
+	return dst.Upload(ctx, name, r)
+}
+
+// sortedMetas returns the metas sorted by min time, then by ID.
+func sortedMetas(metas map[ulid.ULID]*metadata.Meta) []*metadata.Meta {
+	result := make([]*metadata.Meta, 0, len(metas))
+	for _, m := range metas {
+		result = append(result, m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].MinTime != result[j].MinTime {
+			return result[i].MinTime < result[j].MinTime
+		}
+		return result[i].ULID.Compare(result[j].ULID) < 0
+	})
+	return result
+}
+
+// readBlockTransfers returns the block transfer requests of the user, sorted by creation time.
+func readBlockTransfers(ctx context.Context, logger log.Logger, bkt objstore.Bucket) ([]*blockTransfer, error) {
+	var transfers []*blockTransfer
+
+	err := bkt.Iter(ctx, blockTransfersPrefix+"/", func(name string) error {
+		if !strings.HasSuffix(name, ".json") {
+			return nil
+		}
+
+		r, err := bkt.Get(ctx, name)
+		if err != nil {
+			return err
+		}
+		defer runutil.CloseWithLogOnErr(logger, r, "close block transfer reader")
+
+		t := &blockTransfer{}
+		if err := json.NewDecoder(r).Decode(t); err != nil {
+			return errors.Wrapf(err, "decode block transfer %s", name)
+		}
+		transfers = append(transfers, t)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].ID.Compare(transfers[j].ID) < 0
+	})
+	return transfers, nil
+}
+
+func writeBlockTransfer(ctx context.Context, bkt objstore.Bucket, t *blockTransfer) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	return bkt.Upload(ctx, path.Join(blockTransfersPrefix, t.ID.String()+".json"), bytes.NewReader(data))
+}
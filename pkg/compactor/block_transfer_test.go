@@ -0,0 +1,205 @@
+package compactor
+
+import (
+	"context"
+	"math"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	cortex_testutil "github.com/cortexproject/cortex/pkg/storage/tsdb/testutil"
+)
+
+func TestNewBlockTransfer(t *testing.T) {
+	now := time.Now()
+
+	tests := map[string]struct {
+		typ         string
+		prefix      string
+		minT, maxT  int64
+		expectedErr string
+	}{
+		"valid export": {
+			typ:    blockTransferTypeExport,
+			prefix: "migrations/user-1/",
+			maxT:   math.MaxInt64,
+		},
+		"valid import": {
+			typ:    blockTransferTypeImport,
+			prefix: "migrations/user-1",
+			minT:   10,
+			maxT:   20,
+		},
+		"invalid type": {
+			typ:         "copy",
+			prefix:      "migrations",
+			expectedErr: `invalid block transfer type "copy"`,
+		},
+		"missing prefix": {
+			typ:         blockTransferTypeExport,
+			prefix:      "",
+			expectedErr: "block transfer prefix not set",
+		},
+		"absolute prefix": {
+			typ:         blockTransferTypeExport,
+			prefix:      "/user-2/migrations",
+			expectedErr: `invalid block transfer prefix "/user-2/migrations"`,
+		},
+		"prefix with relative path": {
+			typ:         blockTransferTypeExport,
+			prefix:      "migrations/../user-2",
+			expectedErr: `invalid block transfer prefix "migrations/../user-2"`,
+		},
+		"prefix with empty path segment": {
+			typ:         blockTransferTypeExport,
+			prefix:      "migrations//user-1",
+			expectedErr: `invalid block transfer prefix "migrations//user-1"`,
+		},
+		"start greater than end": {
+			typ:         blockTransferTypeExport,
+			prefix:      "migrations",
+			minT:        20,
+			maxT:        10,
+			expectedErr: "start time can't be greater than end time",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			transfer, err := newBlockTransfer(testData.typ, testData.prefix, testData.minT, testData.maxT, now)
+			if testData.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testData.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "migrations/user-1", transfer.Prefix)
+			assert.Equal(t, blockTransferStatePending, transfer.State)
+			assert.Equal(t, ulid.Timestamp(now), transfer.ID.Time())
+		})
+	}
+}
+
+func TestCompactor_TransferUserBlocks(t *testing.T) {
+	bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	transferBucket, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+
+	const blockRange = int64(2 * time.Hour / time.Millisecond)
+
+	block1 := createTSDBBlock(t, bucketClient, "user-1", 0, blockRange, map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"})
+	block2 := createTSDBBlock(t, bucketClient, "user-1", blockRange, 2*blockRange, map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"})
+	block3 := createTSDBBlock(t, bucketClient, "user-1", 0, blockRange, map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"})
+	createDeletionMark(t, bucketClient, "user-1", block3, time.Now())
+
+	c := &Compactor{
+		blockTransferBucket:   transferBucket,
+		blocksTransferred:     prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"type"}),
+		blockTransferFailures: prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"type"}),
+	}
+
+	runTransfers := func(userID string) []*blockTransfer {
+		userBucket := bucket.NewUserBucketClient(userID, bucketClient, nil)
+		fetcher, err := block.NewMetaFetcher(logger, 1, userBucket, block.NewConcurrentLister(logger, userBucket), t.TempDir(), nil, []block.MetadataFilter{
+			block.NewIgnoreDeletionMarkFilter(logger, userBucket, 0, 1),
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, c.transferUserBlocks(ctx, logger, userID, userBucket, fetcher))
+
+		transfers, err := readBlockTransfers(ctx, logger, userBucket)
+		require.NoError(t, err)
+		return transfers
+	}
+
+	// Export the blocks of the first 2 hours, except the ones marked for deletion.
+	export, err := newBlockTransfer(blockTransferTypeExport, "migration", 0, blockRange, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, writeBlockTransfer(ctx, bucket.NewUserBucketClient("user-1", bucketClient, nil), export))
+
+	transfers := runTransfers("user-1")
+	require.Len(t, transfers, 1)
+	assert.Equal(t, blockTransferStateDone, transfers[0].State)
+	assert.Equal(t, []ulid.ULID{block1}, transfers[0].Blocks)
+	assert.Empty(t, transfers[0].Error)
+
+	for _, id := range []ulid.ULID{block1, block2, block3} {
+		exists, err := transferBucket.Exists(ctx, path.Join("user-1", "migration", id.String(), block.MetaFilename))
+		require.NoError(t, err)
+		assert.Equal(t, id == block1, exists)
+	}
+	assert.Equal(t, float64(1), prom_testutil.ToFloat64(c.blocksTransferred.WithLabelValues(blockTransferTypeExport)))
+
+	// Another tenant can't import the blocks exported by the first one.
+	userBucket := bucket.NewUserBucketClient("user-2", bucketClient, nil)
+	imp, err := newBlockTransfer(blockTransferTypeImport, "migration", 0, math.MaxInt64, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, writeBlockTransfer(ctx, userBucket, imp))
+
+	transfers = runTransfers("user-2")
+	require.Len(t, transfers, 1)
+	assert.Equal(t, blockTransferStateDone, transfers[0].State)
+	assert.Empty(t, transfers[0].Blocks)
+
+	// Import the exported blocks, once handed over to the other tenant.
+	exportBucket := bucket.NewPrefixedBucketClient(transferBucket, "user-1")
+	require.NoError(t, exportBucket.Iter(ctx, "migration/", func(name string) error {
+		return copyObject(ctx, logger, exportBucket, bucket.NewPrefixedBucketClient(transferBucket, "user-2"), name)
+	}, objstore.WithRecursiveIter))
+	imp, err = newBlockTransfer(blockTransferTypeImport, "migration", 0, math.MaxInt64, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	require.NoError(t, writeBlockTransfer(ctx, userBucket, imp))
+
+	transfers = runTransfers("user-2")
+	require.Len(t, transfers, 2)
+	assert.Equal(t, blockTransferStateDone, transfers[1].State)
+	assert.Equal(t, []ulid.ULID{block1}, transfers[1].Blocks)
+
+	meta, err := block.DownloadMeta(ctx, logger, userBucket, block1)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-2"}, meta.Thanos.Labels)
+	assert.Equal(t, blockImportSource, meta.Thanos.Source)
+	assert.Equal(t, uint64(2), meta.Stats.NumSamples)
+
+	exists, err := userBucket.Exists(ctx, path.Join(block1.String(), block.IndexFilename))
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, float64(1), prom_testutil.ToFloat64(c.blocksTransferred.WithLabelValues(blockTransferTypeImport)))
+
+	// Importing the blocks again should skip the blocks already imported.
+	imp, err = newBlockTransfer(blockTransferTypeImport, "migration", 0, math.MaxInt64, time.Now().Add(2*time.Second))
+	require.NoError(t, err)
+	require.NoError(t, writeBlockTransfer(ctx, userBucket, imp))
+
+	transfers = runTransfers("user-2")
+	require.Len(t, transfers, 3)
+	assert.Equal(t, blockTransferStateDone, transfers[2].State)
+	assert.Equal(t, []ulid.ULID{block1}, transfers[2].Blocks)
+	assert.Equal(t, float64(1), prom_testutil.ToFloat64(c.blocksTransferred.WithLabelValues(blockTransferTypeImport)))
+
+	// A transfer failing should be marked as failed.
+	require.NoError(t, transferBucket.Upload(ctx, path.Join("user-2", "partial", block2.String(), block.IndexFilename), strings.NewReader("index")))
+	imp, err = newBlockTransfer(blockTransferTypeImport, "partial", 0, math.MaxInt64, time.Now().Add(3*time.Second))
+	require.NoError(t, err)
+	require.NoError(t, writeBlockTransfer(ctx, userBucket, imp))
+
+	transfers = runTransfers("user-2")
+	require.Len(t, transfers, 4)
+	assert.Equal(t, blockTransferStateFailed, transfers[3].State)
+	assert.Contains(t, transfers[3].Error, block2.String())
+	assert.Equal(t, float64(1), prom_testutil.ToFloat64(c.blockTransferFailures.WithLabelValues(blockTransferTypeImport)))
+}
@@ -3,6 +3,7 @@ package compactor
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -15,8 +16,10 @@ import (
 	"github.com/thanos-io/objstore"
 	"github.com/thanos-io/thanos/pkg/block"
 	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact/downsample"
 
 	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	cortex_s3 "github.com/cortexproject/cortex/pkg/storage/bucket/s3"
 	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
 	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
 	"github.com/cortexproject/cortex/pkg/util"
@@ -35,6 +38,12 @@ type BlocksCleanerConfig struct {
 	CleanupConcurrency                 int
 	BlockDeletionMarksMigrationEnabled bool          // TODO Discuss whether we should remove it in Cortex 1.8.0 and document that upgrading to 1.7.0 before 1.8.0 is required.
 	TenantCleanupDelay                 time.Duration // Delay before removing tenant deletion mark and "debug".
+	SeriesDeletionEnabled              bool          // Whether series deletion tombstones are processed.
+	SeriesDeletionDir                  string        // Directory used to rewrite blocks affected by series deletion.
+	MaxBlockRange                      time.Duration // Largest compaction block range.
+	AbandonedUploadsCleanupGracePeriod time.Duration // Grace period before partial blocks and abandoned deletion marks are deleted, 0 to disable.
+	BlockStatsEnabled                  bool          // Whether the query planning statistics of the blocks are tracked in the bucket index.
+	BlockStatsKeyLabels                []string      // Labels whose values range is tracked in the block stats.
 }
 
 type BlocksCleaner struct {
@@ -56,12 +65,17 @@ type BlocksCleaner struct {
 	runsLastSuccess                   prometheus.Gauge
 	blocksCleanedTotal                prometheus.Counter
 	blocksFailedTotal                 prometheus.Counter
+	blocksLockedTotal                 prometheus.Counter
 	blocksMarkedForDeletion           prometheus.Counter
 	tenantBlocks                      *prometheus.GaugeVec
 	tenantBlocksMarkedForDelete       *prometheus.GaugeVec
 	tenantBlocksMarkedForNoCompaction *prometheus.GaugeVec
 	tenantPartialBlocks               *prometheus.GaugeVec
 	tenantBucketIndexLastUpdate       *prometheus.GaugeVec
+	tenantReclaimedBytes              *prometheus.CounterVec
+	blocksMarkedForSeriesDeletion     prometheus.Counter
+	blocksRewrittenForSeriesDeletion  prometheus.Counter
+	abandonedUploadsCleaned           *prometheus.CounterVec
 }
 
 func NewBlocksCleaner(cfg BlocksCleanerConfig, bucketClient objstore.InstrumentedBucket, usersScanner *cortex_tsdb.UsersScanner, cfgProvider ConfigProvider, logger log.Logger, reg prometheus.Registerer) *BlocksCleaner {
@@ -95,11 +109,28 @@ func NewBlocksCleaner(cfg BlocksCleanerConfig, bucketClient objstore.Instrumente
 			Name: "cortex_compactor_block_cleanup_failures_total",
 			Help: "Total number of blocks failed to be deleted.",
 		}),
+		blocksLockedTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_block_cleanup_skipped_locked_total",
+			Help: "Total number of blocks whose deletion was skipped because their objects are locked by the S3 Object Lock retention.",
+		}),
 		blocksMarkedForDeletion: promauto.With(reg).NewCounter(prometheus.CounterOpts{
 			Name:        blocksMarkedForDeletionName,
 			Help:        blocksMarkedForDeletionHelp,
 			ConstLabels: prometheus.Labels{"reason": "retention"},
 		}),
+		blocksMarkedForSeriesDeletion: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        blocksMarkedForDeletionName,
+			Help:        blocksMarkedForDeletionHelp,
+			ConstLabels: prometheus.Labels{"reason": "series-deletion"},
+		}),
+		blocksRewrittenForSeriesDeletion: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_blocks_rewritten_for_series_deletion_total",
+			Help: "Total number of blocks rewritten to apply series deletion requests.",
+		}),
+		abandonedUploadsCleaned: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_compactor_abandoned_uploads_cleaned_total",
+			Help: "Total number of abandoned partial blocks and block deletion marks deleted.",
+		}, []string{"type"}),
 
 		// The following metrics don't have the "cortex_compactor" prefix because not strictly related to
 		// the compactor. They're just tracked by the compactor because it's the most logical place where these
@@ -124,6 +155,10 @@ func NewBlocksCleaner(cfg BlocksCleanerConfig, bucketClient objstore.Instrumente
 			Name: "cortex_bucket_index_last_successful_update_timestamp_seconds",
 			Help: "Timestamp of the last successful update of a tenant's bucket index.",
 		}, []string{"user"}),
+		tenantReclaimedBytes: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_compactor_blocks_reclaimed_bytes_total",
+			Help: "Total number of bytes reclaimed in the bucket by deleting blocks, including partial blocks.",
+		}, []string{"user"}),
 	}
 
 	c.Service = services.NewTimerService(cfg.CleanupInterval, c.starting, c.ticker, nil)
@@ -182,6 +217,7 @@ func (c *BlocksCleaner) cleanUsers(ctx context.Context, firstRun bool) error {
 			c.tenantBlocksMarkedForNoCompaction.DeleteLabelValues(userID)
 			c.tenantPartialBlocks.DeleteLabelValues(userID)
 			c.tenantBucketIndexLastUpdate.DeleteLabelValues(userID)
+			c.tenantReclaimedBytes.DeleteLabelValues(userID)
 		}
 	}
 	c.lastOwnedUsers = allUsers
@@ -227,8 +263,7 @@ func (c *BlocksCleaner) deleteUserMarkedForDeletion(ctx context.Context, userID
 		err := block.Delete(ctx, userLogger, userBucket, id)
 		if err != nil {
 			failed++
-			c.blocksFailedTotal.Inc()
-			level.Warn(userLogger).Log("msg", "failed to delete block", "block", id, "err", err)
+			c.blockDeletionFailed(userLogger, id, "failed to delete block", err)
 			return nil // Continue with other blocks.
 		}
 
@@ -258,6 +293,7 @@ func (c *BlocksCleaner) deleteUserMarkedForDeletion(ctx context.Context, userID
 	c.tenantBlocksMarkedForDelete.DeleteLabelValues(userID)
 	c.tenantBlocksMarkedForNoCompaction.DeleteLabelValues(userID)
 	c.tenantPartialBlocks.DeleteLabelValues(userID)
+	c.tenantReclaimedBytes.DeleteLabelValues(userID)
 
 	if deletedBlocks > 0 {
 		level.Info(userLogger).Log("msg", "deleted blocks for tenant marked for deletion", "deletedBlocks", deletedBlocks)
@@ -299,6 +335,12 @@ func (c *BlocksCleaner) deleteUserMarkedForDeletion(ctx context.Context, userID
 		level.Info(userLogger).Log("msg", "deleted marker files for tenant marked for deletion", "count", deleted)
 	}
 
+	if deleted, err := bucket.DeletePrefix(ctx, userBucket, cortex_tsdb.TombstonesPath, userLogger); err != nil {
+		return errors.Wrap(err, "failed to delete series deletion tombstones")
+	} else if deleted > 0 {
+		level.Info(userLogger).Log("msg", "deleted series deletion tombstones for tenant marked for deletion", "count", deleted)
+	}
+
 	if err := cortex_tsdb.DeleteTenantDeletionMark(ctx, c.bucketClient, userID); err != nil {
 		return errors.Wrap(err, "failed to delete tenant deletion mark")
 	}
@@ -370,21 +412,48 @@ func (c *BlocksCleaner) cleanUser(ctx context.Context, userID string, firstRun b
 	// Note doing this before UpdateIndex, so it reads in the deletion marks.
 	// The trade-off being that retention is not applied if the index has to be
 	// built, but this is rare.
+	retention := c.cfgProvider.CompactorBlocksRetentionPeriod(userID)
+
+	// Downsampled blocks fallback to the raw blocks retention if a specific one is not configured.
+	retention5m, retention1h := c.cfgProvider.CompactorBlocksRetentionPeriod5m(userID), c.cfgProvider.CompactorBlocksRetentionPeriod1h(userID)
+	if retention5m <= 0 {
+		retention5m = retention
+	}
+	if retention1h <= 0 {
+		retention1h = retention
+	}
+
 	if idx != nil {
 		// We do not want to stop the remaining work in the cleaner if an
 		// error occurs here. Errors are logged in the function.
-		retention := c.cfgProvider.CompactorBlocksRetentionPeriod(userID)
-		c.applyUserRetentionPeriod(ctx, idx, retention, userBucket, userLogger)
+		c.applyUserRetentionPeriod(ctx, idx, downsample.ResLevel0, retention, userBucket, userLogger)
+		c.applyUserRetentionPeriod(ctx, idx, downsample.ResLevel1, retention5m, userBucket, userLogger)
+		c.applyUserRetentionPeriod(ctx, idx, downsample.ResLevel2, retention1h, userBucket, userLogger)
+
+		// Series deletion is applied before UpdateIndex too, so that the rewritten blocks, their
+		// deletion marks and the tombstones state changes are picked up by the updated index.
+		if c.cfg.SeriesDeletionEnabled {
+			c.applyUserSeriesDeletion(ctx, userID, idx, userBucket, userLogger)
+		}
 	}
 
 	// Generate an updated in-memory version of the bucket index.
 	w := bucketindex.NewUpdater(c.bucketClient, userID, c.cfgProvider, c.logger)
+	if c.cfg.BlockStatsEnabled {
+		w.EnableBlockStats(c.cfg.BlockStatsKeyLabels)
+	}
 	idx, partials, totalBlocksBlocksMarkedForNoCompaction, err := w.UpdateIndex(ctx, idx)
 	if err != nil {
 		idxs.Status = bucketindex.GenericError
 		return err
 	}
 
+	// Deletion marks left behind by interrupted block deletions are deleted before processing
+	// the deletion marks, given there's no block to delete.
+	if c.cfg.AbandonedUploadsCleanupGracePeriod > 0 {
+		c.cleanUserAbandonedDeletionMarks(ctx, idx, partials, userBucket, userLogger)
+	}
+
 	// Delete blocks marked for deletion. We iterate over a copy of deletion marks because
 	// we'll need to manipulate the index (removing blocks which get deleted).
 	blocksToDelete := make([]interface{}, 0, len(idx.BlockDeletionMarks))
@@ -400,11 +469,12 @@ func (c *BlocksCleaner) cleanUser(ctx context.Context, userID string, firstRun b
 	_ = concurrency.ForEach(ctx, blocksToDelete, defaultDeleteBlocksConcurrency, func(ctx context.Context, job interface{}) error {
 		blockID := job.(ulid.ULID)
 
+		size := c.blockSizeBytes(ctx, userBucket, blockID, userLogger)
 		if err := block.Delete(ctx, userLogger, userBucket, blockID); err != nil {
-			c.blocksFailedTotal.Inc()
-			level.Warn(userLogger).Log("msg", "failed to delete block marked for deletion", "block", blockID, "err", err)
+			c.blockDeletionFailed(userLogger, blockID, "failed to delete block marked for deletion", err)
 			return nil
 		}
+		c.tenantReclaimedBytes.WithLabelValues(userID).Add(float64(size))
 
 		// Remove the block from the bucket index too.
 		mux.Lock()
@@ -419,7 +489,14 @@ func (c *BlocksCleaner) cleanUser(ctx context.Context, userID string, firstRun b
 	// Partial blocks with a deletion mark can be cleaned up. This is a best effort, so we don't return
 	// error if the cleanup of partial blocks fail.
 	if len(partials) > 0 {
-		c.cleanUserPartialBlocks(ctx, partials, idx, userBucket, userLogger)
+		// Partial blocks are not tracked in the bucket index, so the retention has not been applied
+		// to them yet. Given the resolution of a partial block is unknown, the longest retention is used.
+		c.applyUserRetentionPeriodToPartialBlocks(ctx, partials, max(retention, retention5m, retention1h), userBucket, userLogger)
+		c.cleanUserPartialBlocks(ctx, userID, partials, idx, userBucket, userLogger)
+
+		if c.cfg.AbandonedUploadsCleanupGracePeriod > 0 {
+			c.cleanUserAbandonedPartialBlocks(ctx, userID, partials, userBucket, userLogger)
+		}
 	}
 
 	// Upload the updated index to the storage.
@@ -437,7 +514,7 @@ func (c *BlocksCleaner) cleanUser(ctx context.Context, userID string, firstRun b
 
 // cleanUserPartialBlocks delete partial blocks which are safe to be deleted. The provided partials map
 // and index are updated accordingly.
-func (c *BlocksCleaner) cleanUserPartialBlocks(ctx context.Context, partials map[ulid.ULID]error, idx *bucketindex.Index, userBucket objstore.InstrumentedBucket, userLogger log.Logger) {
+func (c *BlocksCleaner) cleanUserPartialBlocks(ctx context.Context, userID string, partials map[ulid.ULID]error, idx *bucketindex.Index, userBucket objstore.InstrumentedBucket, userLogger log.Logger) {
 	// Collect all blocks with missing meta.json into buffered channel.
 	blocks := make([]interface{}, 0, len(partials))
 
@@ -479,11 +556,12 @@ func (c *BlocksCleaner) cleanUserPartialBlocks(ctx context.Context, partials map
 
 		// Hard-delete partial blocks having a deletion mark, even if the deletion threshold has not
 		// been reached yet.
+		size := c.blockSizeBytes(ctx, userBucket, blockID, userLogger)
 		if err := block.Delete(ctx, userLogger, userBucket, blockID); err != nil {
-			c.blocksFailedTotal.Inc()
-			level.Warn(userLogger).Log("msg", "error deleting partial block marked for deletion", "block", blockID, "err", err)
+			c.blockDeletionFailed(userLogger, blockID, "error deleting partial block marked for deletion", err)
 			return nil
 		}
+		c.tenantReclaimedBytes.WithLabelValues(userID).Add(float64(size))
 
 		// Remove the block from the bucket index too.
 		mux.Lock()
@@ -497,15 +575,29 @@ func (c *BlocksCleaner) cleanUserPartialBlocks(ctx context.Context, partials map
 	})
 }
 
-// applyUserRetentionPeriod marks blocks for deletion which have aged past the retention period.
-func (c *BlocksCleaner) applyUserRetentionPeriod(ctx context.Context, idx *bucketindex.Index, retention time.Duration, userBucket objstore.Bucket, userLogger log.Logger) {
+// blockDeletionFailed tracks the failed deletion of a block. The deletion of the blocks whose objects are locked by
+// the S3 Object Lock retention is skipped and reported, rather than failed, given it's retried by the next cleanups
+// until the retention expires.
+func (c *BlocksCleaner) blockDeletionFailed(logger log.Logger, blockID ulid.ULID, msg string, err error) {
+	if cortex_s3.IsObjectLockedErr(err) {
+		c.blocksLockedTotal.Inc()
+		level.Info(logger).Log("msg", "skipped deleting block whose objects are locked by the object lock retention", "block", blockID, "err", err)
+		return
+	}
+
+	c.blocksFailedTotal.Inc()
+	level.Warn(logger).Log("msg", msg, "block", blockID, "err", err)
+}
+
+// applyUserRetentionPeriod marks blocks of the given resolution for deletion which have aged past the retention period.
+func (c *BlocksCleaner) applyUserRetentionPeriod(ctx context.Context, idx *bucketindex.Index, resolution int64, retention time.Duration, userBucket objstore.Bucket, userLogger log.Logger) {
 	// The retention period of zero is a special value indicating to never delete.
 	if retention <= 0 {
 		return
 	}
 
-	level.Debug(userLogger).Log("msg", "applying retention", "retention", retention.String())
-	blocks := listBlocksOutsideRetentionPeriod(idx, time.Now().Add(-retention))
+	level.Debug(userLogger).Log("msg", "applying retention", "retention", retention.String(), "resolution", resolution)
+	blocks := listBlocksOutsideRetentionPeriod(idx, resolution, time.Now().Add(-retention))
 
 	// Attempt to mark all blocks. It is not critical if a marking fails, as
 	// the cleaner will retry applying the retention in its next cycle.
@@ -517,9 +609,81 @@ func (c *BlocksCleaner) applyUserRetentionPeriod(ctx context.Context, idx *bucke
 	}
 }
 
-// listBlocksOutsideRetentionPeriod determines the blocks which have aged past
-// the specified retention period, and are not already marked for deletion.
-func listBlocksOutsideRetentionPeriod(idx *bucketindex.Index, threshold time.Time) (result bucketindex.Blocks) {
+// applyUserRetentionPeriodToPartialBlocks marks partial blocks for deletion which have aged past the retention
+// period. The max time of a partial block is unknown because its meta.json is missing, so the block creation
+// time (encoded in its ULID) is used instead: a block can't contain samples newer than its creation time.
+func (c *BlocksCleaner) applyUserRetentionPeriodToPartialBlocks(ctx context.Context, partials map[ulid.ULID]error, retention time.Duration, userBucket objstore.InstrumentedBucket, userLogger log.Logger) {
+	// The retention period of zero is a special value indicating to never delete.
+	if retention <= 0 {
+		return
+	}
+
+	for _, blockID := range listPartialBlocksOutsideRetentionPeriod(partials, time.Now().Add(-retention)) {
+		// Skip partial blocks already marked for deletion.
+		err := metadata.ReadMarker(ctx, userLogger, userBucket, blockID.String(), &metadata.DeletionMark{})
+		if err == nil {
+			continue
+		} else if !errors.Is(err, metadata.ErrorMarkerNotFound) {
+			level.Warn(userLogger).Log("msg", "error reading partial block deletion mark", "block", blockID, "err", err)
+			continue
+		}
+
+		level.Info(userLogger).Log("msg", "applied retention: marking partial block for deletion", "block", blockID)
+		if err := block.MarkForDeletion(ctx, userLogger, userBucket, blockID, fmt.Sprintf("partial block exceeding retention of %v", retention), c.blocksMarkedForDeletion); err != nil {
+			level.Warn(userLogger).Log("msg", "failed to mark partial block for deletion", "block", blockID, "err", err)
+		}
+	}
+}
+
+// listPartialBlocksOutsideRetentionPeriod determines the partial blocks, with a missing meta.json,
+// which have been created before the specified retention threshold.
+func listPartialBlocksOutsideRetentionPeriod(partials map[ulid.ULID]error, threshold time.Time) (result []ulid.ULID) {
+	for blockID, blockErr := range partials {
+		if !errors.Is(blockErr, bucketindex.ErrBlockMetaNotFound) {
+			continue
+		}
+
+		if ulid.Time(blockID.Time()).Before(threshold) {
+			result = append(result, blockID)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Compare(result[j]) < 0
+	})
+	return
+}
+
+// blockSizeBytes returns the total size of the files of a block, as listed in its meta.json. The size of
+// the blocks whose meta.json is missing or doesn't list the files is computed from the attributes of their
+// objects in the storage instead. This is a best effort, so errors are logged and the size of the files
+// found so far is returned.
+func (c *BlocksCleaner) blockSizeBytes(ctx context.Context, userBucket objstore.Bucket, blockID ulid.ULID, userLogger log.Logger) (size int64) {
+	meta, err := block.DownloadMeta(ctx, userLogger, userBucket, blockID)
+	if err == nil && len(meta.Thanos.Files) > 0 {
+		for _, f := range meta.Thanos.Files {
+			size += f.SizeBytes
+		}
+		return size
+	}
+
+	err = userBucket.Iter(ctx, blockID.String(), func(name string) error {
+		attrs, err := userBucket.Attributes(ctx, name)
+		if err != nil {
+			return err
+		}
+		size += attrs.Size
+		return nil
+	}, objstore.WithRecursiveIter)
+	if err != nil {
+		level.Warn(userLogger).Log("msg", "failed to compute the size of block", "block", blockID, "err", err)
+	}
+	return size
+}
+
+// listBlocksOutsideRetentionPeriod determines the blocks of the given resolution which have
+// aged past the specified retention period, and are not already marked for deletion.
+func listBlocksOutsideRetentionPeriod(idx *bucketindex.Index, resolution int64, threshold time.Time) (result bucketindex.Blocks) {
 	// Whilst re-marking a block is not harmful, it is wasteful and generates
 	// a warning log message. Use the block deletion marks already in-memory
 	// to prevent marking blocks already marked for deletion.
@@ -529,6 +693,10 @@ func listBlocksOutsideRetentionPeriod(idx *bucketindex.Index, threshold time.Tim
 	}
 
 	for _, b := range idx.Blocks {
+		if b.Resolution != resolution {
+			continue
+		}
+
 		maxTime := time.Unix(b.MaxTime/1000, 0)
 		if maxTime.Before(threshold) {
 			if _, isMarked := marked[b.ID]; !isMarked {
@@ -1,24 +1,31 @@
 package compactor
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"fmt"
+	"os"
 	"path"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/go-kit/log"
+	"github.com/minio/minio-go/v7"
 	"github.com/oklog/ulid"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
 	"github.com/thanos-io/thanos/pkg/block"
 	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact/downsample"
 
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
 	"github.com/cortexproject/cortex/pkg/storage/tsdb"
 	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
 	cortex_testutil "github.com/cortexproject/cortex/pkg/storage/tsdb/testutil"
@@ -365,6 +372,64 @@ func TestBlocksCleaner_ShouldContinueOnBlockDeletionFailure(t *testing.T) {
 	assert.ElementsMatch(t, []ulid.ULID{block3}, idx.BlockDeletionMarks.GetULIDs())
 }
 
+func TestBlocksCleaner_ShouldSkipTheDeletionOfLockedBlocks(t *testing.T) {
+	const userID = "user-1"
+
+	bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	bucketClient = bucketindex.BucketWithGlobalMarkers(bucketClient)
+
+	// Create blocks.
+	ctx := context.Background()
+	now := time.Now()
+	deletionDelay := 12 * time.Hour
+	block1 := createTSDBBlock(t, bucketClient, userID, 10, 20, nil)
+	block2 := createTSDBBlock(t, bucketClient, userID, 20, 30, nil)
+	createDeletionMark(t, bucketClient, userID, block1, now.Add(-deletionDelay).Add(-time.Hour))
+	createDeletionMark(t, bucketClient, userID, block2, now.Add(-deletionDelay).Add(-time.Hour))
+
+	// To emulate the object lock retention of a block, we wrap the bucket client in a mocked one.
+	bucketClient = &lockedObjectsBucket{InstrumentedBucket: bucketClient, lockedPrefix: path.Join(userID, block2.String())}
+
+	cfg := BlocksCleanerConfig{
+		DeletionDelay:      deletionDelay,
+		CleanupInterval:    time.Minute,
+		CleanupConcurrency: 1,
+	}
+
+	logger := log.NewNopLogger()
+	scanner := tsdb.NewUsersScanner(bucketClient, tsdb.AllUsers, logger)
+	cfgProvider := newMockConfigProvider()
+
+	cleaner := NewBlocksCleaner(cfg, bucketClient, scanner, cfgProvider, logger, nil)
+	require.NoError(t, services.StartAndAwaitRunning(ctx, cleaner))
+	defer services.StopAndAwaitTerminated(ctx, cleaner) //nolint:errcheck
+
+	// The locked block is skipped, rather than failed, and kept marked for deletion.
+	assert.Equal(t, float64(1), testutil.ToFloat64(cleaner.runsCompleted))
+	assert.Equal(t, float64(1), testutil.ToFloat64(cleaner.blocksCleanedTotal))
+	assert.Equal(t, float64(0), testutil.ToFloat64(cleaner.blocksFailedTotal))
+	assert.Equal(t, float64(1), testutil.ToFloat64(cleaner.blocksLockedTotal))
+
+	idx, err := bucketindex.ReadIndex(ctx, bucketClient, userID, nil, logger)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []ulid.ULID{block2}, idx.Blocks.GetULIDs())
+	assert.ElementsMatch(t, []ulid.ULID{block2}, idx.BlockDeletionMarks.GetULIDs())
+}
+
+// lockedObjectsBucket is a bucket client failing the deletion of the objects locked by the object lock retention,
+// like S3.
+type lockedObjectsBucket struct {
+	objstore.InstrumentedBucket
+	lockedPrefix string
+}
+
+func (b *lockedObjectsBucket) Delete(ctx context.Context, name string) error {
+	if strings.HasPrefix(name, b.lockedPrefix) {
+		return minio.ErrorResponse{Code: "AccessDenied", Message: "Access Denied because object protected by object lock."}
+	}
+	return b.InstrumentedBucket.Delete(ctx, name)
+}
+
 func TestBlocksCleaner_ShouldRebuildBucketIndexOnCorruptedOne(t *testing.T) {
 	const userID = "user-1"
 
@@ -513,20 +578,20 @@ func TestBlocksCleaner_ListBlocksOutsideRetentionPeriod(t *testing.T) {
 	assert.ElementsMatch(t, []ulid.ULID{id1, id2, id3}, idx.Blocks.GetULIDs())
 
 	// Excessive retention period (wrapping epoch)
-	result := listBlocksOutsideRetentionPeriod(idx, time.Unix(10, 0).Add(-time.Hour))
+	result := listBlocksOutsideRetentionPeriod(idx, downsample.ResLevel0, time.Unix(10, 0).Add(-time.Hour))
 	assert.ElementsMatch(t, []ulid.ULID{}, result.GetULIDs())
 
 	// Normal operation - varying retention period.
-	result = listBlocksOutsideRetentionPeriod(idx, time.Unix(6, 0))
+	result = listBlocksOutsideRetentionPeriod(idx, downsample.ResLevel0, time.Unix(6, 0))
 	assert.ElementsMatch(t, []ulid.ULID{}, result.GetULIDs())
 
-	result = listBlocksOutsideRetentionPeriod(idx, time.Unix(7, 0))
+	result = listBlocksOutsideRetentionPeriod(idx, downsample.ResLevel0, time.Unix(7, 0))
 	assert.ElementsMatch(t, []ulid.ULID{id1}, result.GetULIDs())
 
-	result = listBlocksOutsideRetentionPeriod(idx, time.Unix(8, 0))
+	result = listBlocksOutsideRetentionPeriod(idx, downsample.ResLevel0, time.Unix(8, 0))
 	assert.ElementsMatch(t, []ulid.ULID{id1, id2}, result.GetULIDs())
 
-	result = listBlocksOutsideRetentionPeriod(idx, time.Unix(9, 0))
+	result = listBlocksOutsideRetentionPeriod(idx, downsample.ResLevel0, time.Unix(9, 0))
 	assert.ElementsMatch(t, []ulid.ULID{id1, id2, id3}, result.GetULIDs())
 
 	// Avoiding redundant marking - blocks already marked for deletion.
@@ -536,22 +601,39 @@ func TestBlocksCleaner_ListBlocksOutsideRetentionPeriod(t *testing.T) {
 
 	idx.BlockDeletionMarks = bucketindex.BlockDeletionMarks{mark1}
 
-	result = listBlocksOutsideRetentionPeriod(idx, time.Unix(7, 0))
+	result = listBlocksOutsideRetentionPeriod(idx, downsample.ResLevel0, time.Unix(7, 0))
 	assert.ElementsMatch(t, []ulid.ULID{}, result.GetULIDs())
 
-	result = listBlocksOutsideRetentionPeriod(idx, time.Unix(8, 0))
+	result = listBlocksOutsideRetentionPeriod(idx, downsample.ResLevel0, time.Unix(8, 0))
 	assert.ElementsMatch(t, []ulid.ULID{id2}, result.GetULIDs())
 
 	idx.BlockDeletionMarks = bucketindex.BlockDeletionMarks{mark1, mark2}
 
-	result = listBlocksOutsideRetentionPeriod(idx, time.Unix(7, 0))
+	result = listBlocksOutsideRetentionPeriod(idx, downsample.ResLevel0, time.Unix(7, 0))
 	assert.ElementsMatch(t, []ulid.ULID{}, result.GetULIDs())
 
-	result = listBlocksOutsideRetentionPeriod(idx, time.Unix(8, 0))
+	result = listBlocksOutsideRetentionPeriod(idx, downsample.ResLevel0, time.Unix(8, 0))
 	assert.ElementsMatch(t, []ulid.ULID{}, result.GetULIDs())
 
-	result = listBlocksOutsideRetentionPeriod(idx, time.Unix(9, 0))
+	result = listBlocksOutsideRetentionPeriod(idx, downsample.ResLevel0, time.Unix(9, 0))
 	assert.ElementsMatch(t, []ulid.ULID{id3}, result.GetULIDs())
+
+	// Only blocks of the requested resolution are considered.
+	idx.BlockDeletionMarks = nil
+	for _, b := range idx.Blocks {
+		if b.ID == id1 {
+			b.Resolution = downsample.ResLevel1
+		}
+	}
+
+	result = listBlocksOutsideRetentionPeriod(idx, downsample.ResLevel0, time.Unix(9, 0))
+	assert.ElementsMatch(t, []ulid.ULID{id2, id3}, result.GetULIDs())
+
+	result = listBlocksOutsideRetentionPeriod(idx, downsample.ResLevel1, time.Unix(9, 0))
+	assert.ElementsMatch(t, []ulid.ULID{id1}, result.GetULIDs())
+
+	result = listBlocksOutsideRetentionPeriod(idx, downsample.ResLevel2, time.Unix(9, 0))
+	assert.ElementsMatch(t, []ulid.ULID{}, result.GetULIDs())
 }
 
 func TestBlocksCleaner_ShouldRemoveBlocksOutsideRetentionPeriod(t *testing.T) {
@@ -610,6 +692,7 @@ func TestBlocksCleaner_ShouldRemoveBlocksOutsideRetentionPeriod(t *testing.T) {
 			# HELP cortex_compactor_blocks_marked_for_deletion_total Total number of blocks marked for deletion in compactor.
 			# TYPE cortex_compactor_blocks_marked_for_deletion_total counter
 			cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 0
+			cortex_compactor_blocks_marked_for_deletion_total{reason="series-deletion"} 0
 			`),
 			"cortex_bucket_blocks_count",
 			"cortex_bucket_blocks_marked_for_deletion_count",
@@ -651,6 +734,7 @@ func TestBlocksCleaner_ShouldRemoveBlocksOutsideRetentionPeriod(t *testing.T) {
 			# HELP cortex_compactor_blocks_marked_for_deletion_total Total number of blocks marked for deletion in compactor.
 			# TYPE cortex_compactor_blocks_marked_for_deletion_total counter
 			cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 1
+			cortex_compactor_blocks_marked_for_deletion_total{reason="series-deletion"} 0
 			`),
 			"cortex_bucket_blocks_count",
 			"cortex_bucket_blocks_marked_for_deletion_count",
@@ -689,6 +773,7 @@ func TestBlocksCleaner_ShouldRemoveBlocksOutsideRetentionPeriod(t *testing.T) {
 			# HELP cortex_compactor_blocks_marked_for_deletion_total Total number of blocks marked for deletion in compactor.
 			# TYPE cortex_compactor_blocks_marked_for_deletion_total counter
 			cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 1
+			cortex_compactor_blocks_marked_for_deletion_total{reason="series-deletion"} 0
 			`),
 			"cortex_bucket_blocks_count",
 			"cortex_bucket_blocks_marked_for_deletion_count",
@@ -718,6 +803,7 @@ func TestBlocksCleaner_ShouldRemoveBlocksOutsideRetentionPeriod(t *testing.T) {
 			# HELP cortex_compactor_blocks_marked_for_deletion_total Total number of blocks marked for deletion in compactor.
 			# TYPE cortex_compactor_blocks_marked_for_deletion_total counter
 			cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 3
+			cortex_compactor_blocks_marked_for_deletion_total{reason="series-deletion"} 0
 			`),
 			"cortex_bucket_blocks_count",
 			"cortex_bucket_blocks_marked_for_deletion_count",
@@ -726,13 +812,251 @@ func TestBlocksCleaner_ShouldRemoveBlocksOutsideRetentionPeriod(t *testing.T) {
 	}
 }
 
+func TestBlocksCleaner_ShouldRemovePartialBlocksOutsideRetentionPeriod(t *testing.T) {
+	bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	bucketClient = bucketindex.BucketWithGlobalMarkers(bucketClient)
+
+	ctx := context.Background()
+	now := time.Now()
+
+	// Partial blocks are blocks without the meta.json, so we just upload some chunks.
+	oldPartial := ulid.MustNew(ulid.Timestamp(now.Add(-10*time.Hour)), rand.Reader)
+	newPartial := ulid.MustNew(ulid.Timestamp(now.Add(-2*time.Hour)), rand.Reader)
+	require.NoError(t, bucketClient.Upload(ctx, path.Join("user-1", oldPartial.String(), "chunks", "000001"), strings.NewReader("old-partial")))
+	require.NoError(t, bucketClient.Upload(ctx, path.Join("user-1", newPartial.String(), "chunks", "000001"), strings.NewReader("new-partial")))
+
+	cfg := BlocksCleanerConfig{
+		DeletionDelay:      time.Hour,
+		CleanupInterval:    time.Minute,
+		CleanupConcurrency: 1,
+	}
+
+	logger := log.NewNopLogger()
+	reg := prometheus.NewPedanticRegistry()
+	scanner := tsdb.NewUsersScanner(bucketClient, tsdb.AllUsers, logger)
+	cfgProvider := newMockConfigProvider()
+
+	cleaner := NewBlocksCleaner(cfg, bucketClient, scanner, cfgProvider, logger, reg)
+
+	assertPartialBlockExists := func(block ulid.ULID, expectExists bool) {
+		exists, err := bucketClient.Exists(ctx, path.Join("user-1", block.String(), "chunks", "000001"))
+		require.NoError(t, err)
+		assert.Equal(t, expectExists, exists)
+	}
+
+	// Retention period disabled.
+	{
+		require.NoError(t, cleaner.cleanUsers(ctx, true))
+		assertPartialBlockExists(oldPartial, true)
+		assertPartialBlockExists(newPartial, true)
+	}
+
+	// Retention enabled. The partial block created before the retention threshold
+	// is marked for deletion and then hard-deleted, regardless of the deletion delay.
+	{
+		cfgProvider.userRetentionPeriods["user-1"] = 5 * time.Hour
+
+		require.NoError(t, cleaner.cleanUsers(ctx, false))
+		assertPartialBlockExists(oldPartial, false)
+		assertPartialBlockExists(newPartial, true)
+
+		assert.NoError(t, prom_testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_bucket_blocks_partials_count Total number of partial blocks.
+			# TYPE cortex_bucket_blocks_partials_count gauge
+			cortex_bucket_blocks_partials_count{user="user-1"} 1
+			# HELP cortex_compactor_blocks_marked_for_deletion_total Total number of blocks marked for deletion in compactor.
+			# TYPE cortex_compactor_blocks_marked_for_deletion_total counter
+			cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 1
+			cortex_compactor_blocks_marked_for_deletion_total{reason="series-deletion"} 0
+			`),
+			"cortex_bucket_blocks_partials_count",
+			"cortex_compactor_blocks_marked_for_deletion_total",
+		))
+
+		// The reclaimed bytes include the chunks and the deletion mark.
+		reclaimed := prom_testutil.ToFloat64(cleaner.tenantReclaimedBytes.WithLabelValues("user-1"))
+		assert.Greater(t, reclaimed, float64(len("old-partial")))
+	}
+}
+
+func TestBlocksCleaner_ShouldRemoveAbandonedUploads(t *testing.T) {
+	storageBucket, storageDir := cortex_testutil.PrepareFilesystemBucket(t)
+	bucketClient := bucketindex.BucketWithGlobalMarkers(storageBucket)
+
+	ctx := context.Background()
+	now := time.Now()
+
+	// Partial blocks are blocks without the meta.json, so we just upload some chunks. The
+	// abandoned one has not been modified for longer than the grace period.
+	abandonedPartial := ulid.MustNew(ulid.Timestamp(now.Add(-10*time.Hour)), rand.Reader)
+	inProgressPartial := ulid.MustNew(ulid.Timestamp(now.Add(-10*time.Hour)), rand.Reader)
+	require.NoError(t, bucketClient.Upload(ctx, path.Join("user-1", abandonedPartial.String(), "chunks", "000001"), strings.NewReader("abandoned")))
+	require.NoError(t, bucketClient.Upload(ctx, path.Join("user-1", inProgressPartial.String(), "chunks", "000001"), strings.NewReader("in-progress")))
+	require.NoError(t, os.Chtimes(filepath.Join(storageDir, "user-1", abandonedPartial.String(), "chunks", "000001"), now.Add(-10*time.Hour), now.Add(-10*time.Hour)))
+
+	// Global deletion marks whose block doesn't exist anymore, because the block deletion has been
+	// interrupted after deleting the block local deletion mark.
+	abandonedMark := ulid.MustNew(ulid.Timestamp(now.Add(-20*time.Hour)), rand.Reader)
+	recentMark := ulid.MustNew(ulid.Timestamp(now.Add(-20*time.Hour)), rand.Reader)
+	createDeletionMark(t, bucketClient, "user-1", abandonedMark, now.Add(-10*time.Hour))
+	createDeletionMark(t, bucketClient, "user-1", recentMark, now.Add(-2*time.Hour))
+	require.NoError(t, storageBucket.Delete(ctx, path.Join("user-1", abandonedMark.String(), metadata.DeletionMarkFilename)))
+	require.NoError(t, storageBucket.Delete(ctx, path.Join("user-1", recentMark.String(), metadata.DeletionMarkFilename)))
+	require.NoError(t, os.Chtimes(filepath.Join(storageDir, "user-1", bucketindex.BlockDeletionMarkFilepath(abandonedMark)), now.Add(-10*time.Hour), now.Add(-10*time.Hour)))
+	require.NoError(t, os.Chtimes(filepath.Join(storageDir, "user-1", bucketindex.BlockDeletionMarkFilepath(recentMark)), now.Add(-2*time.Hour), now.Add(-2*time.Hour)))
+
+	// A block marked for deletion which still exists.
+	block1 := createTSDBBlock(t, bucketClient, "user-1", 10, 20, nil)
+	createDeletionMark(t, bucketClient, "user-1", block1, now.Add(-30*time.Minute))
+
+	cfg := BlocksCleanerConfig{
+		DeletionDelay:                      time.Hour,
+		CleanupInterval:                    time.Minute,
+		CleanupConcurrency:                 1,
+		AbandonedUploadsCleanupGracePeriod: 5 * time.Hour,
+	}
+
+	logger := log.NewNopLogger()
+	reg := prometheus.NewPedanticRegistry()
+	scanner := tsdb.NewUsersScanner(bucketClient, tsdb.AllUsers, logger)
+	cleaner := NewBlocksCleaner(cfg, bucketClient, scanner, newMockConfigProvider(), logger, reg)
+
+	require.NoError(t, cleaner.cleanUsers(ctx, true))
+
+	for name, expectExists := range map[string]bool{
+		path.Join("user-1", abandonedPartial.String(), "chunks", "000001"):        false,
+		path.Join("user-1", inProgressPartial.String(), "chunks", "000001"):       true,
+		path.Join("user-1", bucketindex.BlockDeletionMarkFilepath(abandonedMark)): false,
+		path.Join("user-1", bucketindex.BlockDeletionMarkFilepath(recentMark)):    true,
+		path.Join("user-1", bucketindex.BlockDeletionMarkFilepath(block1)):        true,
+	} {
+		exists, err := bucketClient.Exists(ctx, name)
+		require.NoError(t, err)
+		assert.Equal(t, expectExists, exists, name)
+	}
+
+	idx, err := bucketindex.ReadIndex(ctx, bucketClient, "user-1", nil, logger)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []ulid.ULID{block1}, idx.Blocks.GetULIDs())
+	assert.ElementsMatch(t, []ulid.ULID{block1}, idx.BlockDeletionMarks.GetULIDs())
+
+	assert.NoError(t, prom_testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_bucket_blocks_partials_count Total number of partial blocks.
+		# TYPE cortex_bucket_blocks_partials_count gauge
+		cortex_bucket_blocks_partials_count{user="user-1"} 1
+		# HELP cortex_compactor_abandoned_uploads_cleaned_total Total number of abandoned partial blocks and block deletion marks deleted.
+		# TYPE cortex_compactor_abandoned_uploads_cleaned_total counter
+		cortex_compactor_abandoned_uploads_cleaned_total{type="deletion-mark"} 1
+		cortex_compactor_abandoned_uploads_cleaned_total{type="partial-block"} 1
+		# HELP cortex_compactor_blocks_reclaimed_bytes_total Total number of bytes reclaimed in the bucket by deleting blocks, including partial blocks.
+		# TYPE cortex_compactor_blocks_reclaimed_bytes_total counter
+		cortex_compactor_blocks_reclaimed_bytes_total{user="user-1"} 9
+		`),
+		"cortex_bucket_blocks_partials_count",
+		"cortex_compactor_abandoned_uploads_cleaned_total",
+		"cortex_compactor_blocks_reclaimed_bytes_total",
+	))
+}
+
+func TestBlocksCleaner_ShouldTrackReclaimedBytesPerTenant(t *testing.T) {
+	bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	bucketClient = bucketindex.BucketWithGlobalMarkers(bucketClient)
+
+	ctx := context.Background()
+	block1 := createTSDBBlock(t, bucketClient, "user-1", 10, 20, nil)
+	block2 := createTSDBBlock(t, bucketClient, "user-1", 20, 30, nil)
+	createTSDBBlock(t, bucketClient, "user-2", 10, 20, nil)
+	createDeletionMark(t, bucketClient, "user-1", block1, time.Now().Add(-2*time.Hour))
+
+	// Compute the expected size of the block marked for deletion, deletion mark included.
+	expectedSize := int64(0)
+	require.NoError(t, bucketClient.Iter(ctx, path.Join("user-1", block1.String()), func(name string) error {
+		attrs, err := bucketClient.Attributes(ctx, name)
+		if err != nil {
+			return err
+		}
+		expectedSize += attrs.Size
+		return nil
+	}, objstore.WithRecursiveIter))
+
+	cfg := BlocksCleanerConfig{
+		DeletionDelay:      time.Hour,
+		CleanupInterval:    time.Minute,
+		CleanupConcurrency: 1,
+	}
+
+	logger := log.NewNopLogger()
+	reg := prometheus.NewPedanticRegistry()
+	scanner := tsdb.NewUsersScanner(bucketClient, tsdb.AllUsers, logger)
+	cleaner := NewBlocksCleaner(cfg, bucketClient, scanner, newMockConfigProvider(), logger, reg)
+
+	require.NoError(t, cleaner.cleanUsers(ctx, true))
+
+	exists, err := bucketClient.Exists(ctx, path.Join("user-1", block1.String(), metadata.MetaFilename))
+	require.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = bucketClient.Exists(ctx, path.Join("user-1", block2.String(), metadata.MetaFilename))
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	assert.NoError(t, prom_testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+		# HELP cortex_compactor_blocks_reclaimed_bytes_total Total number of bytes reclaimed in the bucket by deleting blocks, including partial blocks.
+		# TYPE cortex_compactor_blocks_reclaimed_bytes_total counter
+		cortex_compactor_blocks_reclaimed_bytes_total{user="user-1"} %d
+		`, expectedSize)),
+		"cortex_compactor_blocks_reclaimed_bytes_total",
+	))
+}
+
+func TestBlocksCleaner_blockSizeBytes(t *testing.T) {
+	bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+	userBucket := bucket.NewUserBucketClient("user-1", bucketClient, nil)
+	cleaner := NewBlocksCleaner(BlocksCleanerConfig{}, bucketClient, nil, newMockConfigProvider(), logger, nil)
+
+	withFiles := createTSDBBlock(t, bucketClient, "user-1", 10, 20, nil)
+	withoutFiles := createTSDBBlock(t, bucketClient, "user-1", 20, 30, nil)
+
+	// The files listed in the meta.json are used when available.
+	meta, err := block.DownloadMeta(ctx, logger, userBucket, withFiles)
+	require.NoError(t, err)
+	meta.Thanos.Files = []metadata.File{{RelPath: block.IndexFilename, SizeBytes: 100}, {RelPath: "chunks/000001", SizeBytes: 200}, {RelPath: metadata.MetaFilename}}
+
+	var buf bytes.Buffer
+	require.NoError(t, meta.Write(&buf))
+	require.NoError(t, userBucket.Upload(ctx, path.Join(withFiles.String(), metadata.MetaFilename), &buf))
+	assert.Equal(t, int64(300), cleaner.blockSizeBytes(ctx, userBucket, withFiles, logger))
+
+	// Otherwise, the objects of the block are listed.
+	expectedSize := int64(0)
+	require.NoError(t, userBucket.Iter(ctx, withoutFiles.String(), func(name string) error {
+		attrs, err := userBucket.Attributes(ctx, name)
+		if err != nil {
+			return err
+		}
+		expectedSize += attrs.Size
+		return nil
+	}, objstore.WithRecursiveIter))
+	assert.Greater(t, expectedSize, int64(0))
+	assert.Equal(t, expectedSize, cleaner.blockSizeBytes(ctx, userBucket, withoutFiles, logger))
+}
+
 type mockConfigProvider struct {
-	userRetentionPeriods map[string]time.Duration
+	userRetentionPeriods    map[string]time.Duration
+	userRetentionPeriods5m  map[string]time.Duration
+	userRetentionPeriods1h  map[string]time.Duration
+	userDownsamplingEnabled map[string]struct{}
+	userDeleteCancelPeriods map[string]time.Duration
 }
 
 func newMockConfigProvider() *mockConfigProvider {
 	return &mockConfigProvider{
-		userRetentionPeriods: make(map[string]time.Duration),
+		userRetentionPeriods:    make(map[string]time.Duration),
+		userRetentionPeriods5m:  make(map[string]time.Duration),
+		userRetentionPeriods1h:  make(map[string]time.Duration),
+		userDownsamplingEnabled: make(map[string]struct{}),
+		userDeleteCancelPeriods: make(map[string]time.Duration),
 	}
 }
 
@@ -743,6 +1067,32 @@ func (m *mockConfigProvider) CompactorBlocksRetentionPeriod(user string) time.Du
 	return 0
 }
 
+func (m *mockConfigProvider) CompactorBlocksRetentionPeriod5m(user string) time.Duration {
+	if result, ok := m.userRetentionPeriods5m[user]; ok {
+		return result
+	}
+	return 0
+}
+
+func (m *mockConfigProvider) CompactorBlocksRetentionPeriod1h(user string) time.Duration {
+	if result, ok := m.userRetentionPeriods1h[user]; ok {
+		return result
+	}
+	return 0
+}
+
+func (m *mockConfigProvider) CompactorDownsamplingEnabled(user string) bool {
+	_, ok := m.userDownsamplingEnabled[user]
+	return ok
+}
+
+func (m *mockConfigProvider) DeleteRequestCancelPeriod(user string) time.Duration {
+	if result, ok := m.userDeleteCancelPeriods[user]; ok {
+		return result
+	}
+	return 0
+}
+
 func (m *mockConfigProvider) S3SSEType(user string) string {
 	return ""
 }
@@ -754,3 +1104,7 @@ func (m *mockConfigProvider) S3SSEKMSKeyID(userID string) string {
 func (m *mockConfigProvider) S3SSEKMSEncryptionContext(userID string) string {
 	return ""
 }
+
+func (m *mockConfigProvider) GCSKMSKeyName(userID string) string {
+	return ""
+}
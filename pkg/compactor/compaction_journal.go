@@ -0,0 +1,418 @@
+package compactor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/tombstones"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact"
+)
+
+const compactionJournalFilename = "compaction-journal.json"
+
+// compactionJournal keeps track of the progress of the compactions of a tenant, so that a
+// compactor restarted in the middle of a compaction can resume it instead of starting over.
+type compactionJournal struct {
+	// Hashes of the block files fully downloaded, by block ID and file relative path.
+	Blocks map[ulid.ULID]map[string]metadata.ObjectHash `json:"blocks"`
+	// Output blocks written by the compactions, by compaction group directory.
+	Outputs map[string]compactionJournalOutput `json:"outputs"`
+	// Completed is set once all the compactions of the tenant have completed, so that the local
+	// compaction directory can be deleted if the compactor failed to delete it.
+	Completed bool `json:"completed,omitempty"`
+}
+
+type compactionJournalOutput struct {
+	Sources []ulid.ULID `json:"sources"`
+	Block   ulid.ULID   `json:"block"`
+}
+
+// compactionJournalFile is a compaction journal stored in the local compaction directory
+// of a tenant. It's safe for concurrent use.
+type compactionJournalFile struct {
+	logger log.Logger
+	path   string
+
+	mtx     sync.Mutex
+	journal compactionJournal
+}
+
+// openCompactionJournal opens the compaction journal stored in dir, creating an empty
+// one if it doesn't exist or can't be read.
+func openCompactionJournal(logger log.Logger, dir string) (*compactionJournalFile, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, errors.Wrap(err, "create compaction dir")
+	}
+
+	j := &compactionJournalFile{
+		logger: logger,
+		path:   filepath.Join(dir, compactionJournalFilename),
+	}
+
+	data, err := os.ReadFile(j.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "read compaction journal")
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &j.journal); err != nil {
+			// The journal is only an optimization, so we just start over.
+			level.Warn(logger).Log("msg", "failed to decode compaction journal, starting from an empty one", "path", j.path, "err", err)
+			j.journal = compactionJournal{}
+		}
+	}
+
+	// There's nothing to resume from a journal whose compactions have completed.
+	if j.journal.Completed {
+		j.journal = compactionJournal{}
+	}
+
+	if j.journal.Blocks == nil {
+		j.journal.Blocks = map[ulid.ULID]map[string]metadata.ObjectHash{}
+	}
+	if j.journal.Outputs == nil {
+		j.journal.Outputs = map[string]compactionJournalOutput{}
+	}
+
+	return j, nil
+}
+
+// recordFile records a block file has been fully downloaded.
+func (j *compactionJournalFile) recordFile(id ulid.ULID, relPath string, hash metadata.ObjectHash) error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	if j.journal.Blocks[id] == nil {
+		j.journal.Blocks[id] = map[string]metadata.ObjectHash{}
+	}
+	j.journal.Blocks[id][relPath] = hash
+
+	return j.save()
+}
+
+// fileHashes returns the hashes of the downloaded files of a block.
+func (j *compactionJournalFile) fileHashes(id ulid.ULID) map[string]metadata.ObjectHash {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	hashes := make(map[string]metadata.ObjectHash, len(j.journal.Blocks[id]))
+	for relPath, hash := range j.journal.Blocks[id] {
+		hashes[relPath] = hash
+	}
+	return hashes
+}
+
+// recordOutput records the output block written in dir by the compaction of the sources blocks.
+func (j *compactionJournalFile) recordOutput(dir string, sources []ulid.ULID, id ulid.ULID) error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	j.journal.Outputs[dir] = compactionJournalOutput{Sources: sources, Block: id}
+
+	return j.save()
+}
+
+// output returns the output block written in dir by a previous compaction of the same sources blocks.
+func (j *compactionJournalFile) output(dir string, sources []ulid.ULID) (ulid.ULID, bool) {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	out, ok := j.journal.Outputs[dir]
+	if !ok || len(out.Sources) != len(sources) {
+		return ulid.ULID{}, false
+	}
+	for i := range sources {
+		if out.Sources[i] != sources[i] {
+			return ulid.ULID{}, false
+		}
+	}
+	return out.Block, true
+}
+
+// markCompleted records all the compactions of the tenant have completed.
+func (j *compactionJournalFile) markCompleted() error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	j.journal.Completed = true
+
+	return j.save()
+}
+
+// readCompactionJournal reads the compaction journal stored in dir.
+func readCompactionJournal(dir string) (compactionJournal, error) {
+	var journal compactionJournal
+
+	data, err := os.ReadFile(filepath.Join(dir, compactionJournalFilename))
+	if err != nil {
+		return journal, err
+	}
+	return journal, json.Unmarshal(data, &journal)
+}
+
+// save atomically writes the journal to disk. It must be called with the lock held.
+func (j *compactionJournalFile) save() error {
+	data, err := json.Marshal(j.journal)
+	if err != nil {
+		return errors.Wrap(err, "encode compaction journal")
+	}
+
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0640); err != nil {
+		return errors.Wrap(err, "write compaction journal")
+	}
+	return errors.Wrap(os.Rename(tmp, j.path), "rename compaction journal")
+}
+
+// journaledBucket is a bucket recording the hashes of the block files read through it in a
+// compaction journal, and injecting them into the meta.json files read later on. This way the
+// blocks download skips the files which have already been downloaded by a previous attempt.
+type journaledBucket struct {
+	objstore.InstrumentedBucket
+
+	logger  log.Logger
+	journal *compactionJournalFile
+}
+
+func newJournaledBucket(bkt objstore.InstrumentedBucket, journal *compactionJournalFile, logger log.Logger) *journaledBucket {
+	return &journaledBucket{
+		InstrumentedBucket: bkt,
+		logger:             logger,
+		journal:            journal,
+	}
+}
+
+func (b *journaledBucket) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	id, relPath, ok := parseBlockFilePath(name)
+	if !ok {
+		return b.InstrumentedBucket.Get(ctx, name)
+	}
+
+	switch {
+	case relPath == block.MetaFilename:
+		return b.getMeta(ctx, name, id)
+	case relPath == block.IndexFilename, strings.HasPrefix(relPath, block.ChunksDirname+"/"):
+		r, err := b.InstrumentedBucket.Get(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		return &journaledReader{ReadCloser: r, hash: sha256.New(), logger: b.logger, journal: b.journal, id: id, relPath: relPath}, nil
+	default:
+		return b.InstrumentedBucket.Get(ctx, name)
+	}
+}
+
+// getMeta returns the meta.json of the block, injecting the hashes of the files already downloaded.
+func (b *journaledBucket) getMeta(ctx context.Context, name string, id ulid.ULID) (io.ReadCloser, error) {
+	r, err := b.InstrumentedBucket.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := b.journal.fileHashes(id)
+	if len(hashes) == 0 {
+		return r, nil
+	}
+
+	data, err := io.ReadAll(r)
+	if closeErr := r.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var meta metadata.Meta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		// Let the caller deal with the malformed meta.json.
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	for i, f := range meta.Thanos.Files {
+		if h, ok := hashes[f.RelPath]; ok && (f.Hash == nil || f.Hash.Func == metadata.NoneFunc) {
+			meta.Thanos.Files[i].Hash = &h
+		}
+		delete(hashes, f.RelPath)
+	}
+	// Blocks uploaded by older versions may not list their files.
+	for relPath, h := range hashes {
+		h := h
+		meta.Thanos.Files = append(meta.Thanos.Files, metadata.File{RelPath: relPath, Hash: &h})
+	}
+
+	data, err = json.Marshal(meta)
+	if err != nil {
+		return nil, errors.Wrapf(err, "encode meta.json of block %s", id.String())
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// journaledReader hashes the block file while it's read, recording it in the
+// compaction journal once fully read.
+type journaledReader struct {
+	io.ReadCloser
+
+	hash    hash.Hash
+	logger  log.Logger
+	journal *compactionJournalFile
+	id      ulid.ULID
+	relPath string
+}
+
+func (r *journaledReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+
+	if err == io.EOF {
+		h := metadata.ObjectHash{Func: metadata.SHA256Func, Value: hex.EncodeToString(r.hash.Sum(nil))}
+		if recordErr := r.journal.recordFile(r.id, r.relPath, h); recordErr != nil {
+			level.Warn(r.logger).Log("msg", "failed to record downloaded block file in the compaction journal", "block", r.id.String(), "file", r.relPath, "err", recordErr)
+		}
+	}
+	return n, err
+}
+
+// parseBlockFilePath splits the object name of a block file into the block ID and the file relative path.
+func parseBlockFilePath(name string) (ulid.ULID, string, bool) {
+	dir, relPath, ok := strings.Cut(name, "/")
+	if !ok {
+		return ulid.ULID{}, "", false
+	}
+
+	id, err := ulid.Parse(dir)
+	if err != nil {
+		return ulid.ULID{}, "", false
+	}
+	return id, relPath, true
+}
+
+// journaledCompactor is a compactor recording the output blocks in a compaction journal,
+// returning the output block of a previous attempt when compacting the same blocks again.
+type journaledCompactor struct {
+	compact.Compactor
+
+	logger  log.Logger
+	journal *compactionJournalFile
+	resumed prometheus.Counter
+}
+
+func newJournaledCompactor(c compact.Compactor, journal *compactionJournalFile, resumed prometheus.Counter, logger log.Logger) *journaledCompactor {
+	return &journaledCompactor{
+		Compactor: c,
+		logger:    logger,
+		journal:   journal,
+		resumed:   resumed,
+	}
+}
+
+func (c *journaledCompactor) CompactWithBlockPopulator(dest string, dirs []string, open []*tsdb.Block, blockPopulator tsdb.BlockPopulator) (ulid.ULID, error) {
+	sources := make([]ulid.ULID, 0, len(dirs))
+	for _, dir := range dirs {
+		id, err := ulid.Parse(filepath.Base(dir))
+		if err != nil {
+			// Not a block we can keep track of.
+			return c.Compactor.CompactWithBlockPopulator(dest, dirs, open, blockPopulator)
+		}
+		sources = append(sources, id)
+	}
+
+	if id, ok := c.journal.output(dest, sources); ok {
+		err := prepareJournaledOutputBlock(c.logger, filepath.Join(dest, id.String()))
+		if err == nil {
+			level.Info(c.logger).Log("msg", "resuming compaction from the output block found in the compaction journal", "block", id.String(), "dir", dest)
+			c.resumed.Inc()
+			return id, nil
+		}
+		level.Warn(c.logger).Log("msg", "output block found in the compaction journal can't be reused, compacting blocks again", "block", id.String(), "err", err)
+	}
+
+	id, err := c.Compactor.CompactWithBlockPopulator(dest, dirs, open, blockPopulator)
+	if err != nil || id == (ulid.ULID{}) {
+		return id, err
+	}
+
+	if err := c.journal.recordOutput(dest, sources, id); err != nil {
+		level.Warn(c.logger).Log("msg", "failed to record compacted block in the compaction journal", "block", id.String(), "err", err)
+	}
+	return id, nil
+}
+
+// prepareJournaledOutputBlock checks the output block of a previous compaction is complete, and
+// restores the tombstones file removed once the compaction has completed, which is expected
+// to be found in the output block.
+func prepareJournaledOutputBlock(logger log.Logger, dir string) error {
+	if _, err := metadata.ReadFromDir(dir); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(dir, block.IndexFilename)); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, tombstones.TombstonesFilename)); os.IsNotExist(err) {
+		_, err := tombstones.WriteFile(logger, dir, tombstones.NewMemTombstones())
+		return err
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// cleanupCompactionDirs deletes the local compaction directories of the tenants not owned
+// anymore, and the ones whose compaction journal has completed or has not been updated for
+// longer than maxAge. The directories of the owned tenants without a compaction journal are
+// kept, because a compaction may be in progress.
+func (c *Compactor) cleanupCompactionDirs(ownedUsers map[string]struct{}, maxAge time.Duration) {
+	entries, err := os.ReadDir(c.compactRootDir())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			level.Warn(c.logger).Log("msg", "failed to list local compaction directories", "dir", c.compactRootDir(), "err", err)
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		userID := entry.Name()
+		dir := c.compactDirForUser(userID)
+
+		reason := ""
+		if _, owned := ownedUsers[userID]; !owned {
+			reason = "user not owned by this shard"
+		} else if s, err := os.Stat(filepath.Join(dir, compactionJournalFilename)); err != nil {
+			continue
+		} else if time.Since(s.ModTime()) > maxAge {
+			reason = "stale compaction journal"
+		} else if journal, err := readCompactionJournal(dir); err == nil && journal.Completed {
+			reason = "completed compaction journal"
+		} else {
+			continue
+		}
+
+		if err := os.RemoveAll(dir); err == nil {
+			level.Info(c.logger).Log("msg", "deleted local compaction directory", "dir", dir, "reason", reason)
+		} else {
+			level.Warn(c.logger).Log("msg", "failed to delete local compaction directory", "dir", dir, "reason", reason, "err", err)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package compactor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/tsdb/tombstones"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	cortex_testutil "github.com/cortexproject/cortex/pkg/storage/tsdb/testutil"
+)
+
+func TestJournaledBucket_ShouldSkipDownloadOfJournaledFiles(t *testing.T) {
+	bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+
+	blockID := createTSDBBlock(t, bucketClient, "user-1", 0, int64(2*time.Hour/time.Millisecond), map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"})
+	userBucket := bucket.NewUserBucketClient("user-1", bucketClient, nil)
+
+	compactDir := t.TempDir()
+	blockDir := filepath.Join(compactDir, "group", blockID.String())
+	indexPath := filepath.Join(blockDir, block.IndexFilename)
+	chunksPath := filepath.Join(blockDir, block.ChunksDirname, "000001")
+
+	journal, err := openCompactionJournal(logger, compactDir)
+	require.NoError(t, err)
+	require.NoError(t, block.Download(ctx, logger, newJournaledBucket(userBucket, journal, logger), blockID, blockDir))
+
+	originalIndex, err := os.ReadFile(indexPath)
+	require.NoError(t, err)
+	originalChunks, err := os.ReadFile(chunksPath)
+	require.NoError(t, err)
+
+	// Both the index and chunks files should have been recorded in the journal.
+	assert.Len(t, journal.fileHashes(blockID), 2)
+
+	// Change the files in the bucket, so that we can detect whether they're downloaded again.
+	require.NoError(t, userBucket.Upload(ctx, path.Join(blockID.String(), block.IndexFilename), bytes.NewReader([]byte("changed"))))
+	require.NoError(t, userBucket.Upload(ctx, path.Join(blockID.String(), block.ChunksDirname, "000001"), bytes.NewReader([]byte("changed"))))
+
+	// Corrupt the local chunks file, as if its download was interrupted.
+	require.NoError(t, os.WriteFile(chunksPath, originalChunks[:len(originalChunks)/2], 0640))
+
+	// Reopen the journal, as if the compactor was restarted.
+	journal, err = openCompactionJournal(logger, compactDir)
+	require.NoError(t, err)
+	require.NoError(t, block.Download(ctx, logger, newJournaledBucket(userBucket, journal, logger), blockID, blockDir))
+
+	// The index should have been kept, while the corrupted chunks file downloaded again.
+	actualIndex, err := os.ReadFile(indexPath)
+	require.NoError(t, err)
+	assert.Equal(t, originalIndex, actualIndex)
+
+	actualChunks, err := os.ReadFile(chunksPath)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("changed"), actualChunks)
+}
+
+func TestJournaledCompactor_ShouldResumeFromJournaledOutputBlock(t *testing.T) {
+	bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+
+	outputID := createTSDBBlock(t, bucketClient, "user-1", 0, int64(2*time.Hour/time.Millisecond), map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"})
+	userBucket := bucket.NewUserBucketClient("user-1", bucketClient, nil)
+
+	compactDir := t.TempDir()
+	dest := filepath.Join(compactDir, "group")
+	sourceDirs := []string{filepath.Join(dest, ulid.MustNew(1, nil).String()), filepath.Join(dest, ulid.MustNew(2, nil).String())}
+	otherSourceDirs := []string{filepath.Join(dest, ulid.MustNew(3, nil).String())}
+
+	tsdbCompactor := &tsdbCompactorMock{}
+	tsdbCompactor.On("CompactWithBlockPopulator", dest, sourceDirs, mock.Anything, mock.Anything).Return(outputID, nil).Once()
+	tsdbCompactor.On("CompactWithBlockPopulator", dest, otherSourceDirs, mock.Anything, mock.Anything).Return(ulid.ULID{}, nil).Once()
+
+	resumed := prometheus.NewCounter(prometheus.CounterOpts{})
+
+	journal, err := openCompactionJournal(logger, compactDir)
+	require.NoError(t, err)
+	id, err := newJournaledCompactor(tsdbCompactor, journal, resumed, logger).CompactWithBlockPopulator(dest, sourceDirs, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, outputID, id)
+
+	// Simulate the output block was written, and its tombstones removed once the compaction completed.
+	require.NoError(t, block.Download(ctx, logger, userBucket, outputID, filepath.Join(dest, outputID.String())))
+
+	// Reopen the journal, as if the compactor was restarted, and compact the same blocks again.
+	journal, err = openCompactionJournal(logger, compactDir)
+	require.NoError(t, err)
+	journaledCompactor := newJournaledCompactor(tsdbCompactor, journal, resumed, logger)
+
+	id, err = journaledCompactor.CompactWithBlockPopulator(dest, sourceDirs, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, outputID, id)
+	assert.FileExists(t, filepath.Join(dest, outputID.String(), tombstones.TombstonesFilename))
+	assert.Equal(t, float64(1), prom_testutil.ToFloat64(resumed))
+
+	// Compacting different blocks should not resume from the journaled output block.
+	id, err = journaledCompactor.CompactWithBlockPopulator(dest, otherSourceDirs, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, ulid.ULID{}, id)
+	assert.Equal(t, float64(1), prom_testutil.ToFloat64(resumed))
+
+	tsdbCompactor.AssertExpectations(t)
+}
+
+func TestCompactor_CleanupCompactionDirs(t *testing.T) {
+	c := &Compactor{
+		compactorCfg: Config{DataDir: t.TempDir()},
+		logger:       log.NewNopLogger(),
+	}
+
+	for _, userID := range []string{"not-owned", "not-owned-no-journal", "no-journal", "stale-journal", "fresh-journal", "completed-journal"} {
+		require.NoError(t, os.MkdirAll(c.compactDirForUser(userID), 0750))
+	}
+	for _, userID := range []string{"not-owned", "stale-journal", "fresh-journal"} {
+		require.NoError(t, os.WriteFile(filepath.Join(c.compactDirForUser(userID), compactionJournalFilename), []byte("{}"), 0640))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(c.compactDirForUser("completed-journal"), compactionJournalFilename), []byte(`{"completed":true}`), 0640))
+
+	staleTime := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(c.compactDirForUser("stale-journal"), compactionJournalFilename), staleTime, staleTime))
+
+	c.cleanupCompactionDirs(map[string]struct{}{"no-journal": {}, "stale-journal": {}, "fresh-journal": {}, "completed-journal": {}}, time.Hour)
+
+	// The directory of an owned user without a journal is kept, because a compaction may be in progress.
+	entries, err := os.ReadDir(c.compactRootDir())
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "fresh-journal", entries[0].Name())
+	assert.Equal(t, "no-journal", entries[1].Name())
+}
+
+func TestOpenCompactionJournal_ShouldStartOverIfCompleted(t *testing.T) {
+	dir := t.TempDir()
+	logger := log.NewNopLogger()
+	blockID := ulid.MustNew(1, nil)
+
+	journal, err := openCompactionJournal(logger, dir)
+	require.NoError(t, err)
+	require.NoError(t, journal.recordFile(blockID, block.IndexFilename, metadata.ObjectHash{Func: metadata.SHA256Func, Value: "hash"}))
+	require.NoError(t, journal.markCompleted())
+
+	stored, err := readCompactionJournal(dir)
+	require.NoError(t, err)
+	assert.True(t, stored.Completed)
+
+	journal, err = openCompactionJournal(logger, dir)
+	require.NoError(t, err)
+	assert.Empty(t, journal.fileHashes(blockID))
+	assert.False(t, journal.journal.Completed)
+}
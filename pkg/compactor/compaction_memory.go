@@ -0,0 +1,112 @@
+package compactor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/compact"
+	"golang.org/x/sync/semaphore"
+)
+
+// compactionMemoryLimiter limits the compactions running concurrently in the compactor, across
+// all tenants, so that their estimated memory fits the configured memory budget.
+type compactionMemoryLimiter struct {
+	budget int64
+	sem    *semaphore.Weighted
+
+	inUse     prometheus.Gauge
+	throttled prometheus.Counter
+}
+
+func newCompactionMemoryLimiter(budget uint64, reg prometheus.Registerer) *compactionMemoryLimiter {
+	return &compactionMemoryLimiter{
+		budget: int64(budget),
+		sem:    semaphore.NewWeighted(int64(budget)),
+		inUse: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_compactor_compaction_memory_in_use_bytes",
+			Help: "Estimated memory in bytes of the compactions currently running, reserved from the compaction memory budget.",
+		}),
+		throttled: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_compactions_memory_throttled_total",
+			Help: "Total number of compactions which had to wait for other compactions to complete, to fit the compaction memory budget.",
+		}),
+	}
+}
+
+// acquire reserves the estimated memory of a compaction from the budget, waiting for the other
+// compactions to complete if it doesn't fit. A compaction estimated to be larger than the whole
+// budget reserves the whole budget, so that it runs alone.
+func (l *compactionMemoryLimiter) acquire(ctx context.Context, logger log.Logger, estimated int64) (int64, error) {
+	if estimated > l.budget {
+		estimated = l.budget
+	}
+
+	if !l.sem.TryAcquire(estimated) {
+		l.throttled.Inc()
+		level.Info(logger).Log("msg", "waiting for running compactions to complete to fit the compaction memory budget", "estimated_bytes", estimated, "budget_bytes", l.budget)
+
+		if err := l.sem.Acquire(ctx, estimated); err != nil {
+			return 0, err
+		}
+	}
+
+	l.inUse.Add(float64(estimated))
+	return estimated, nil
+}
+
+func (l *compactionMemoryLimiter) release(reserved int64) {
+	l.inUse.Sub(float64(reserved))
+	l.sem.Release(reserved)
+}
+
+// memoryLimitedCompactor is a compactor waiting for the estimated memory of each compaction
+// to be available in the compaction memory budget before running it.
+type memoryLimitedCompactor struct {
+	compact.Compactor
+
+	// The context of the tenant compaction, given the compactor interface doesn't take one.
+	ctx     context.Context
+	logger  log.Logger
+	limiter *compactionMemoryLimiter
+}
+
+func newMemoryLimitedCompactor(ctx context.Context, c compact.Compactor, limiter *compactionMemoryLimiter, logger log.Logger) *memoryLimitedCompactor {
+	return &memoryLimitedCompactor{
+		Compactor: c,
+		ctx:       ctx,
+		logger:    logger,
+		limiter:   limiter,
+	}
+}
+
+func (c *memoryLimitedCompactor) CompactWithBlockPopulator(dest string, dirs []string, open []*tsdb.Block, blockPopulator tsdb.BlockPopulator) (ulid.ULID, error) {
+	reserved, err := c.limiter.acquire(c.ctx, c.logger, estimateCompactionMemory(dirs))
+	if err != nil {
+		return ulid.ULID{}, err
+	}
+	defer c.limiter.release(reserved)
+
+	return c.Compactor.CompactWithBlockPopulator(dest, dirs, open, blockPopulator)
+}
+
+// estimateCompactionMemory estimates the memory required to compact the blocks in the input
+// directories from the size of their index, given the compaction memory is dominated by the
+// symbols and postings loaded from the input blocks index. The estimate is at least 1 byte,
+// so that each compaction reserves part of the budget.
+func estimateCompactionMemory(dirs []string) int64 {
+	estimated := int64(1)
+	for _, dir := range dirs {
+		if info, err := os.Stat(filepath.Join(dir, block.IndexFilename)); err == nil {
+			estimated += info.Size()
+		}
+	}
+	return estimated
+}
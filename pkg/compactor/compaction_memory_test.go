@@ -0,0 +1,96 @@
+package compactor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block"
+)
+
+func TestMemoryLimitedCompactor_ShouldLimitConcurrentCompactionsToMemoryBudget(t *testing.T) {
+	ctx := context.Background()
+	limiter := newCompactionMemoryLimiter(100, prometheus.NewPedanticRegistry())
+
+	// Each compaction is estimated to 60 bytes, so only one of them fits the budget at a time.
+	createBlockDirWithIndex := func(size int) string {
+		dir := filepath.Join(t.TempDir(), ulid.MustNew(ulid.Now(), nil).String())
+		require.NoError(t, os.MkdirAll(dir, os.ModePerm))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, block.IndexFilename), []byte(strings.Repeat("x", size)), os.ModePerm))
+		return dir
+	}
+	first := []string{createBlockDirWithIndex(29), createBlockDirWithIndex(30)}
+	second := []string{createBlockDirWithIndex(59)}
+	assert.Equal(t, int64(60), estimateCompactionMemory(first))
+	assert.Equal(t, int64(60), estimateCompactionMemory(second))
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	mockCompactor := &tsdbCompactorMock{}
+	mockCompactor.On("CompactWithBlockPopulator", "dest", first, mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		close(started)
+		<-unblock
+	}).Return(ulid.MustNew(1, nil), nil)
+	mockCompactor.On("CompactWithBlockPopulator", "dest", second, mock.Anything, mock.Anything).Return(ulid.MustNew(2, nil), nil)
+
+	compactor := newMemoryLimitedCompactor(ctx, mockCompactor, limiter, log.NewNopLogger())
+
+	firstDone := make(chan struct{})
+	go func() {
+		defer close(firstDone)
+		id, err := compactor.CompactWithBlockPopulator("dest", first, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, ulid.MustNew(1, nil), id)
+	}()
+	<-started
+	assert.Equal(t, float64(60), prom_testutil.ToFloat64(limiter.inUse))
+
+	secondDone := make(chan struct{})
+	go func() {
+		defer close(secondDone)
+		id, err := compactor.CompactWithBlockPopulator("dest", second, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, ulid.MustNew(2, nil), id)
+	}()
+
+	// The second compaction waits for the first one to complete.
+	require.Eventually(t, func() bool {
+		return prom_testutil.ToFloat64(limiter.throttled) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	mockCompactor.AssertNumberOfCalls(t, "CompactWithBlockPopulator", 1)
+
+	close(unblock)
+	<-firstDone
+	<-secondDone
+
+	mockCompactor.AssertNumberOfCalls(t, "CompactWithBlockPopulator", 2)
+	assert.Equal(t, float64(0), prom_testutil.ToFloat64(limiter.inUse))
+}
+
+func TestMemoryLimitedCompactor_ShouldRunCompactionLargerThanBudgetAlone(t *testing.T) {
+	limiter := newCompactionMemoryLimiter(100, prometheus.NewPedanticRegistry())
+
+	reserved, err := limiter.acquire(context.Background(), log.NewNopLogger(), 1000)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), reserved)
+
+	// Other compactions wait until the context is canceled.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = newMemoryLimitedCompactor(ctx, &tsdbCompactorMock{}, limiter, log.NewNopLogger()).CompactWithBlockPopulator("dest", nil, nil, nil)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, float64(1), prom_testutil.ToFloat64(limiter.throttled))
+
+	limiter.release(reserved)
+	assert.Equal(t, float64(0), prom_testutil.ToFloat64(limiter.inUse))
+}
@@ -0,0 +1,145 @@
+package compactor
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact"
+
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
+)
+
+// compactionPlan is the compaction plan of a tenant, computed from the blocks currently in the
+// storage. The groups are sorted in the order they're compacted.
+type compactionPlan struct {
+	Groups []compactionPlanGroup `json:"groups"`
+
+	// ExcludedBlocks are the blocks marked for no compaction, which are not compacted.
+	ExcludedBlocks []compactionPlanExcludedBlock `json:"excluded_blocks"`
+}
+
+type compactionPlanGroup struct {
+	Key        string            `json:"key"`
+	Labels     map[string]string `json:"labels"`
+	Resolution int64             `json:"resolution"`
+
+	// RangeStart and RangeEnd are the compactable time range of the group, in milliseconds.
+	RangeStart int64 `json:"range_start"`
+	RangeEnd   int64 `json:"range_end"`
+
+	// OutputMinTime and OutputMaxTime are the expected time range of the compacted block, in milliseconds.
+	OutputMinTime int64 `json:"output_min_time"`
+	OutputMaxTime int64 `json:"output_max_time"`
+
+	// EstimatedBytes is the size of the input blocks, as tracked in their meta.json.
+	EstimatedBytes int64 `json:"estimated_bytes"`
+
+	Blocks []compactionPlanBlock `json:"blocks"`
+}
+
+type compactionPlanBlock struct {
+	ID      ulid.ULID `json:"block_id"`
+	MinTime int64     `json:"min_time"`
+	MaxTime int64     `json:"max_time"`
+	Level   int       `json:"compaction_level"`
+	Bytes   int64     `json:"bytes"`
+}
+
+type compactionPlanExcludedBlock struct {
+	ID      ulid.ULID                `json:"block_id"`
+	Reason  metadata.NoCompactReason `json:"reason"`
+	Details string                   `json:"details,omitempty"`
+}
+
+// planUserCompaction computes the compaction plan of the user, without executing it. The blocks are
+// fetched with the same filters used by the compaction, but the blocks visit markers are neither read
+// nor written, so the plan includes the groups being compacted by other compactors too.
+func (c *Compactor) planUserCompaction(ctx context.Context, logger log.Logger, userID string, bkt objstore.InstrumentedBucket) (*compactionPlan, error) {
+	blockLister, err := c.blockListerForUser(logger, userID, bkt)
+	if err != nil {
+		return nil, err
+	}
+
+	noCompactMarkerFilter := compact.NewGatherNoCompactionMarkFilter(logger, bkt, c.compactorCfg.MetaSyncConcurrency)
+
+	// The fetcher doesn't use the local cache, which is reserved to the compaction.
+	fetcher, err := block.NewMetaFetcher(logger, c.compactorCfg.MetaSyncConcurrency, bkt, blockLister, "", nil, []block.MetadataFilter{
+		NewLabelRemoverFilter(append([]string{cortex_tsdb.IngesterIDExternalLabel}, c.limits.CompactorDeduplicationReplicaLabels(userID)...)),
+		block.NewConsistencyDelayMetaFilterWithoutMetrics(logger, c.compactorCfg.ConsistencyDelay),
+		block.NewIgnoreDeletionMarkFilter(logger, bkt, 0, c.compactorCfg.MetaSyncConcurrency),
+		block.NewDeduplicateFilter(c.compactorCfg.BlockSyncConcurrency),
+		noCompactMarkerFilter,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	metas, _, err := fetcher.Fetch(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetch blocks metadata")
+	}
+
+	return newCompactionPlan(userID, c.compactorCfg, metas, noCompactMarkerFilter.NoCompactMarkedBlocks(), time.Now()), nil
+}
+
+func newCompactionPlan(userID string, cfg Config, metas map[ulid.ULID]*metadata.Meta, noCompactMarked map[ulid.ULID]*metadata.NoCompactMark, now time.Time) *compactionPlan {
+	plan := &compactionPlan{
+		Groups:         []compactionPlanGroup{},
+		ExcludedBlocks: []compactionPlanExcludedBlock{},
+	}
+
+	for _, group := range groupBlocksForCompaction(userID, cfg, metas, noCompactMarked, now) {
+		// Nothing to do if we don't have at least 2 blocks.
+		if len(group.blocks) < 2 {
+			continue
+		}
+
+		out := compactionPlanGroup{
+			Key:           createGroupKey(hashGroup(userID, group.rangeStart, group.rangeEnd), group),
+			Labels:        group.blocks[0].Thanos.Labels,
+			Resolution:    group.blocks[0].Thanos.Downsample.Resolution,
+			RangeStart:    group.rangeStart,
+			RangeEnd:      group.rangeEnd,
+			OutputMinTime: group.minTime(),
+			OutputMaxTime: group.maxTime(),
+		}
+
+		for _, m := range group.blocks {
+			size := bucketindex.BlockFromThanosMeta(*m).Size
+			out.EstimatedBytes += size
+			out.Blocks = append(out.Blocks, compactionPlanBlock{
+				ID:      m.ULID,
+				MinTime: m.MinTime,
+				MaxTime: m.MaxTime,
+				Level:   m.Compaction.Level,
+				Bytes:   size,
+			})
+		}
+
+		plan.Groups = append(plan.Groups, out)
+	}
+
+	for id, mark := range noCompactMarked {
+		if _, ok := metas[id]; !ok {
+			continue
+		}
+		plan.ExcludedBlocks = append(plan.ExcludedBlocks, compactionPlanExcludedBlock{
+			ID:      id,
+			Reason:  mark.Reason,
+			Details: mark.Details,
+		})
+	}
+	sort.Slice(plan.ExcludedBlocks, func(i, j int) bool {
+		return plan.ExcludedBlocks[i].ID.Compare(plan.ExcludedBlocks[j].ID) < 0
+	})
+
+	return plan
+}
@@ -0,0 +1,69 @@
+package compactor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+)
+
+func TestNewCompactionPlan(t *testing.T) {
+	cfg := Config{BlockRanges: cortex_tsdb.DurationList{2 * time.Hour, 12 * time.Hour, 24 * time.Hour}}
+	now := time.UnixMilli(48 * time.Hour.Milliseconds())
+
+	newMeta := func(id uint64, minHour, maxHour int64, size int64) *metadata.Meta {
+		return &metadata.Meta{
+			BlockMeta: tsdb.BlockMeta{
+				ULID:       ulid.MustNew(id, nil),
+				MinTime:    minHour * time.Hour.Milliseconds(),
+				MaxTime:    maxHour * time.Hour.Milliseconds(),
+				Compaction: tsdb.BlockMetaCompaction{Level: 1},
+			},
+			Thanos: metadata.Thanos{
+				Labels: map[string]string{"__org_id__": "user-1"},
+				Files:  []metadata.File{{RelPath: "index", SizeBytes: size}},
+			},
+		}
+	}
+
+	metas := map[ulid.ULID]*metadata.Meta{}
+	for _, m := range []*metadata.Meta{
+		newMeta(1, 0, 2, 10),
+		newMeta(2, 2, 4, 20),
+		newMeta(3, 4, 6, 30),
+		newMeta(4, 24, 26, 40),
+	} {
+		metas[m.ULID] = m
+	}
+	noCompactMarked := map[ulid.ULID]*metadata.NoCompactMark{
+		ulid.MustNew(3, nil): {ID: ulid.MustNew(3, nil), Reason: metadata.OutOfOrderChunksNoCompactReason, Details: "out of order chunks"},
+		ulid.MustNew(5, nil): {ID: ulid.MustNew(5, nil), Reason: metadata.ManualNoCompactReason},
+	}
+
+	plan := newCompactionPlan("user-1", cfg, metas, noCompactMarked, now)
+
+	// The block marked for no compaction is excluded, while the block alone in its range has nothing to compact.
+	require.Len(t, plan.Groups, 1)
+	group := plan.Groups[0]
+	assert.Equal(t, map[string]string{"__org_id__": "user-1"}, group.Labels)
+	assert.Equal(t, int64(0), group.RangeStart)
+	assert.Equal(t, 12*time.Hour.Milliseconds(), group.RangeEnd)
+	assert.Equal(t, int64(0), group.OutputMinTime)
+	assert.Equal(t, 4*time.Hour.Milliseconds(), group.OutputMaxTime)
+	assert.Equal(t, int64(30), group.EstimatedBytes)
+	assert.Equal(t, []compactionPlanBlock{
+		{ID: ulid.MustNew(1, nil), MinTime: 0, MaxTime: 2 * time.Hour.Milliseconds(), Level: 1, Bytes: 10},
+		{ID: ulid.MustNew(2, nil), MinTime: 2 * time.Hour.Milliseconds(), MaxTime: 4 * time.Hour.Milliseconds(), Level: 1, Bytes: 20},
+	}, group.Blocks)
+
+	// Only the no compaction marks of the fetched blocks are reported.
+	assert.Equal(t, []compactionPlanExcludedBlock{
+		{ID: ulid.MustNew(3, nil), Reason: metadata.OutOfOrderChunksNoCompactReason, Details: "out of order chunks"},
+	}, plan.ExcludedBlocks)
+}
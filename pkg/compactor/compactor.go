@@ -8,6 +8,7 @@ import (
 	"math/rand"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -16,6 +17,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/promql"
 	"github.com/prometheus/prometheus/tsdb"
 	"github.com/thanos-io/objstore"
 	"github.com/thanos-io/thanos/pkg/block"
@@ -40,8 +42,8 @@ import (
 )
 
 const (
-	// ringKey is the key under which we store the compactors ring in the KVStore.
-	ringKey = "compactor"
+	// RingKey is the key under which we store the compactors ring in the KVStore.
+	RingKey = "compactor"
 
 	blocksMarkedForDeletionName = "cortex_compactor_blocks_marked_for_deletion_total"
 	blocksMarkedForDeletionHelp = "Total number of blocks marked for deletion in compactor."
@@ -105,7 +107,11 @@ var (
 		}
 
 		plannerFactory := func(ctx context.Context, bkt objstore.InstrumentedBucket, logger log.Logger, cfg Config, noCompactionMarkFilter *compact.GatherNoCompactionMarkFilter, ringLifecycle *ring.Lifecycler, _ prometheus.Counter, _ prometheus.Counter) compact.Planner {
-			return compact.NewPlanner(logger, cfg.BlockRanges.ToMilliseconds(), noCompactionMarkFilter)
+			planner := compact.Planner(compact.NewPlanner(logger, cfg.BlockRanges.ToMilliseconds(), noCompactionMarkFilter))
+			if cfg.RecentRangesPriorityPeriod > 0 {
+				planner = NewRecentRangesPriorityPlanner(planner, cfg.BlockRanges.ToMilliseconds(), cfg.RecentRangesPriorityPeriod, noCompactionMarkFilter.NoCompactMarkedBlocks)
+			}
+			return planner
 		}
 
 		return compactor, plannerFactory, nil
@@ -198,6 +204,10 @@ type Config struct {
 	ShardingStrategy string     `yaml:"sharding_strategy"`
 	ShardingRing     RingConfig `yaml:"sharding_ring"`
 
+	ShardingWorkloadAwareEnabled bool `yaml:"sharding_workload_aware_enabled"`
+
+	RecentRangesPriorityPeriod time.Duration `yaml:"recent_ranges_priority_period"`
+
 	// No need to add options to customize the retry backoff,
 	// given the defaults should be fine, but allow to override
 	// it in tests.
@@ -214,6 +224,28 @@ type Config struct {
 
 	AcceptMalformedIndex bool `yaml:"accept_malformed_index"`
 	CachingBucketEnabled bool `yaml:"caching_bucket_enabled"`
+
+	SeriesDeletionEnabled bool `yaml:"series_deletion_enabled"`
+
+	BlockRepairEnabled bool `yaml:"block_repair_enabled"`
+
+	BlockTransferEnabled bool          `yaml:"block_transfer_enabled"`
+	BlockTransferStorage bucket.Config `yaml:"block_transfer_storage"`
+
+	CompactionJournalEnabled bool          `yaml:"compaction_journal_enabled"`
+	CompactionJournalMaxAge  time.Duration `yaml:"compaction_journal_max_age"`
+
+	AbandonedUploadsCleanupGracePeriod time.Duration `yaml:"abandoned_uploads_cleanup_grace_period"`
+
+	CompactionMemoryBudgetBytes uint64 `yaml:"compaction_memory_budget_bytes"`
+
+	MaxCompactionOutputBlockSizeBytes uint64 `yaml:"max_compaction_output_block_size_bytes"`
+	MaxCompactionOutputBlockSeries    uint64 `yaml:"max_compaction_output_block_series"`
+
+	BucketIndexBlockStatsEnabled   bool                   `yaml:"bucket_index_block_stats_enabled"`
+	BucketIndexBlockStatsKeyLabels flagext.StringSliceCSV `yaml:"bucket_index_block_stats_key_labels"`
+
+	BucketRateLimit bucket.RateLimitConfig `yaml:"bucket_rate_limit"`
 }
 
 // RegisterFlags registers the Compactor flags.
@@ -236,6 +268,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.CleanupConcurrency, "compactor.cleanup-concurrency", 20, "Max number of tenants for which blocks cleanup and maintenance should run concurrently.")
 	f.BoolVar(&cfg.ShardingEnabled, "compactor.sharding-enabled", false, "Shard tenants across multiple compactor instances. Sharding is required if you run multiple compactor instances, in order to coordinate compactions and avoid race conditions leading to the same tenant blocks simultaneously compacted by different instances.")
 	f.StringVar(&cfg.ShardingStrategy, "compactor.sharding-strategy", util.ShardingStrategyDefault, fmt.Sprintf("The sharding strategy to use. Supported values are: %s.", strings.Join(supportedShardingStrategies, ", ")))
+	f.BoolVar(&cfg.ShardingWorkloadAwareEnabled, "compactor.sharding-workload-aware-enabled", false, "[Experimental] When enabled, the compactor reads the pending compaction bytes of each tenant from its bucket index at the beginning of each compaction run, exporting the total for the tenants it owns. If the default sharding strategy is used, tenants with pending compaction work are assigned to the compactors balancing their pending compaction bytes, according to the assignment published in the storage by the compactor with the lowest address, instead of hashing the tenant ID. Requires the bucket index to be enabled.")
+	f.DurationVar(&cfg.RecentRangesPriorityPeriod, "compactor.recent-ranges-priority-period", 0, "[Experimental] If greater than 0, the compaction of the blocks into the second block range (eg. 2h blocks into 12h blocks) is prioritized for the time ranges ending within this period, from the most recent one, before compacting the older blocks. It keeps low the number of blocks queried for recent data when the compactor is behind. 0 to disable.")
 	f.DurationVar(&cfg.DeletionDelay, "compactor.deletion-delay", 12*time.Hour, "Time before a block marked for deletion is deleted from bucket. "+
 		"If not 0, blocks will be marked for deletion and compactor component will permanently delete blocks marked for deletion from the bucket. "+
 		"If 0, blocks will be deleted straight away. Note that deleting blocks immediately can cause query failures.")
@@ -253,6 +287,19 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 
 	f.BoolVar(&cfg.AcceptMalformedIndex, "compactor.accept-malformed-index", false, "When enabled, index verification will ignore out of order label names.")
 	f.BoolVar(&cfg.CachingBucketEnabled, "compactor.caching-bucket-enabled", false, "When enabled, caching bucket will be used for compactor, except cleaner service, which serves as the source of truth for block status")
+	f.BoolVar(&cfg.SeriesDeletionEnabled, "compactor.series-deletion-enabled", false, "[Experimental] When enabled, the compactor processes the series deletion requests, rewriting the blocks affected by them once their cancellation period has expired.")
+	f.BoolVar(&cfg.BlockRepairEnabled, "compactor.block-repair-enabled", false, "[Experimental] When enabled, the compactor checks the health of the blocks uploaded by the ingesters and the blocks excluded from compaction because of out-of-order chunks, before compacting them. Corrupted blocks are repaired rewriting them, or excluded from compaction if they can't be repaired, and blocks whose upload has been interrupted before the meta.json get their meta.json restored. The outcome is tracked in a per-tenant report.")
+	f.BoolVar(&cfg.BlockTransferEnabled, "compactor.block-transfer-enabled", false, "[Experimental] When enabled, the compactor processes the requests to export the blocks of a tenant to the block transfer storage, or to import blocks from it, submitted through the block transfers API. It allows to migrate the blocks of a tenant between clusters.")
+	cfg.BlockTransferStorage.RegisterFlagsWithPrefix("compactor.block-transfer-storage.", f)
+	f.BoolVar(&cfg.CompactionJournalEnabled, "compactor.compaction-journal-enabled", false, "[Experimental] When enabled, the compactor keeps track of the progress of the compactions in a journal stored in the data directory, so that a compaction interrupted by a restart or a failure resumes from the blocks already downloaded and the output blocks already written, instead of starting over.")
+	f.DurationVar(&cfg.CompactionJournalMaxAge, "compactor.compaction-journal-max-age", 24*time.Hour, "[Experimental] Local compaction directories whose journal has not been updated for longer than this period are considered stale and deleted. Applies only when the compaction journal is enabled.")
+	f.DurationVar(&cfg.AbandonedUploadsCleanupGracePeriod, "compactor.abandoned-uploads-cleanup-grace-period", 0, "[Experimental] If greater than 0, the blocks cleaner deletes the partial blocks (blocks without the meta.json) whose objects have not been modified for longer than this period, and the block deletion marks left behind by interrupted deletions, whose block doesn't exist anymore, older than the deletion delay plus this period. If the block repair is enabled, this period should be greater than the partial upload threshold age (2 days), otherwise partial blocks may be deleted before they're repaired. 0 to disable.")
+	f.Uint64Var(&cfg.CompactionMemoryBudgetBytes, "compactor.compaction-memory-budget-bytes", 0, "[Experimental] If greater than 0, the compactor estimates the memory required by each compaction from the size of the index of the blocks to compact, and runs concurrently only the compactions fitting this memory budget, across all tenants. The max number of concurrent compactions is still limited by -compactor.compaction-concurrency. A compaction estimated to be larger than the budget runs alone. 0 to disable.")
+	f.Uint64Var(&cfg.MaxCompactionOutputBlockSizeBytes, "compactor.max-compaction-output-block-size-bytes", 0, "[Experimental] If greater than 0, the blocks of a compactable range whose compacted block, estimated summing the size of the blocks to compact, would be larger than this size are partitioned by time, compacting the contiguous blocks fitting this size together. Blocks overlapping in time are always compacted together, so the limit may be exceeded. Applies only with the shuffle-sharding strategy. 0 to disable.")
+	f.Uint64Var(&cfg.MaxCompactionOutputBlockSeries, "compactor.max-compaction-output-block-series", 0, "[Experimental] If greater than 0, the blocks of a compactable range whose compacted block, estimated summing the number of series of the blocks to compact, would have more series than this limit are partitioned by time, compacting the contiguous blocks fitting this limit together. Blocks overlapping in time are always compacted together, so the limit may be exceeded. Applies only with the shuffle-sharding strategy. 0 to disable.")
+	f.BoolVar(&cfg.BucketIndexBlockStatsEnabled, "compactor.bucket-index-block-stats-enabled", false, "[Experimental] When enabled, the compactor tracks the query planning statistics of each block in the bucket index (number of series, number of values of each label name and range of values of the key labels), reading the index-header of the blocks once. The bucket index is written with version 2.")
+	f.Var(&cfg.BucketIndexBlockStatsKeyLabels, "compactor.bucket-index-block-stats-key-labels", "[Experimental] Comma separated list of labels whose smallest and largest value in each block is tracked in the bucket index block stats. Applies only when the bucket index block stats are enabled.")
+	cfg.BucketRateLimit.RegisterFlagsWithPrefix("compactor.bucket-rate-limit.", "compactor", f)
 }
 
 func (cfg *Config) Validate(limits validation.Limits) error {
@@ -268,6 +315,12 @@ func (cfg *Config) Validate(limits validation.Limits) error {
 		}
 	}
 
+	if cfg.BlockTransferEnabled {
+		if err := cfg.BlockTransferStorage.Validate(); err != nil {
+			return errors.Wrap(err, "invalid block transfer storage config")
+		}
+	}
+
 	// Make sure a valid sharding strategy is being used
 	if !util.StringsContain(supportedShardingStrategies, cfg.ShardingStrategy) {
 		return errInvalidShardingStrategy
@@ -286,6 +339,10 @@ func (cfg *Config) Validate(limits validation.Limits) error {
 type ConfigProvider interface {
 	bucket.TenantConfigProvider
 	CompactorBlocksRetentionPeriod(user string) time.Duration
+	CompactorBlocksRetentionPeriod5m(user string) time.Duration
+	CompactorBlocksRetentionPeriod1h(user string) time.Duration
+	CompactorDownsamplingEnabled(user string) bool
+	DeleteRequestCancelPeriod(user string) time.Duration
 }
 
 // Compactor is a multi-tenant TSDB blocks compactor based on Thanos.
@@ -309,17 +366,26 @@ type Compactor struct {
 	// Users scanner, used to discover users from the bucket.
 	usersScanner *cortex_tsdb.UsersScanner
 
+	// Engine evaluating the rollup rules over the blocks.
+	rollupEngine *promql.Engine
+
 	// Blocks cleaner is responsible to hard delete blocks marked for deletion.
 	blocksCleaner *BlocksCleaner
 
 	// Underlying compactor used to compact TSDB blocks.
 	blocksCompactor compact.Compactor
 
+	// Compactor used to compact the TSDB blocks of the tenants using the penalty deduplication.
+	penaltyBlocksCompactor compact.Compactor
+
 	blocksPlannerFactory PlannerFactory
 
 	// Client used to run operations on the bucket storing blocks.
 	bucketClient objstore.InstrumentedBucket
 
+	// Client used to export and import blocks, if the block transfer is enabled.
+	blockTransferBucket objstore.Bucket
+
 	// Ring used for sharding compactions.
 	ringLifecycler         *ring.Lifecycler
 	ring                   *ring.Ring
@@ -344,6 +410,23 @@ type Compactor struct {
 	remainingPlannedCompactions    prometheus.Gauge
 	blockVisitMarkerReadFailed     prometheus.Counter
 	blockVisitMarkerWriteFailed    prometheus.Counter
+	blocksDownsampled              *prometheus.CounterVec
+	blocksRelabeled                prometheus.Counter
+	blocksMarkedForRelabeling      prometheus.Counter
+	blocksRolledUp                 prometheus.Counter
+	blocksRewrittenForRollup       prometheus.Counter
+	blocksMarkedForRollup          prometheus.Counter
+	compactionsResumed             prometheus.Counter
+	pendingCompactionBytes         prometheus.Gauge
+	blocksCheckedForRepair         prometheus.Counter
+	blocksRepaired                 prometheus.Counter
+	blockRepairFailures            prometheus.Counter
+	blocksMarkedForRepair          prometheus.Counter
+	blocksTransferred              *prometheus.CounterVec
+	blockTransferFailures          *prometheus.CounterVec
+
+	// Limits the concurrent compactions to the memory budget. Nil if disabled.
+	compactionMemoryLimiter *compactionMemoryLimiter
 
 	// TSDB syncer metrics
 	syncerMetrics *syncerMetrics
@@ -352,7 +435,12 @@ type Compactor struct {
 // NewCompactor makes a new Compactor.
 func NewCompactor(compactorCfg Config, storageCfg cortex_tsdb.BlocksStorageConfig, logger log.Logger, registerer prometheus.Registerer, limits *validation.Overrides) (*Compactor, error) {
 	bucketClientFactory := func(ctx context.Context) (objstore.InstrumentedBucket, error) {
-		return bucket.NewClient(ctx, storageCfg.Bucket, "compactor", logger, registerer)
+		bucketClient, err := cortex_tsdb.NewBucketClient(ctx, storageCfg, "compactor", logger, registerer)
+		if err != nil {
+			return nil, err
+		}
+		bucketClient = bucket.NewBandwidthGovernedBucketClient(bucketClient, storageCfg.BandwidthGovernor, false)
+		return bucket.NewRateLimitedBucketClient(bucketClient, compactorCfg.BucketRateLimit, "compactor", registerer), nil
 	}
 
 	blocksGrouperFactory := compactorCfg.BlocksGrouperFactory
@@ -475,10 +563,74 @@ func newCompactor(
 			Name: "cortex_compactor_block_visit_marker_write_failed",
 			Help: "Number of block visit marker file failed to be written.",
 		}),
+		blocksDownsampled: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_compactor_blocks_downsampled_total",
+			Help: "Total number of blocks downsampled by the compactor.",
+		}, []string{"resolution"}),
+		blocksRelabeled: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_blocks_relabeled_total",
+			Help: "Total number of blocks rewritten by the compactor applying the tenant relabel configs.",
+		}),
+		blocksMarkedForRelabeling: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name:        blocksMarkedForDeletionName,
+			Help:        blocksMarkedForDeletionHelp,
+			ConstLabels: prometheus.Labels{"reason": "relabeling"},
+		}),
+		blocksRolledUp: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_blocks_rolled_up_total",
+			Help: "Total number of blocks over which the compactor evaluated the tenant rollup rules.",
+		}),
+		blocksRewrittenForRollup: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_blocks_rewritten_for_rollup_total",
+			Help: "Total number of blocks rewritten by the compactor deleting the source series of the tenant rollup rules.",
+		}),
+		blocksMarkedForRollup: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name:        blocksMarkedForDeletionName,
+			Help:        blocksMarkedForDeletionHelp,
+			ConstLabels: prometheus.Labels{"reason": "rollup"},
+		}),
+		compactionsResumed: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_compactions_resumed_total",
+			Help: "Total number of compactions resumed from the output block found in the compaction journal.",
+		}),
+		pendingCompactionBytes: promauto.With(registerer).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_compactor_pending_compaction_bytes",
+			Help: "Total size in bytes of the blocks not compacted yet to the largest block range, for the tenants owned by this compactor. Updated at the beginning of each compaction run, only when the workload-aware sharding is enabled.",
+		}),
+		blocksCheckedForRepair: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_blocks_checked_for_repair_total",
+			Help: "Total number of blocks whose health has been checked by the compactor blocks repair.",
+		}),
+		blocksRepaired: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_blocks_repaired_total",
+			Help: "Total number of corrupted or partial blocks repaired by the compactor.",
+		}),
+		blockRepairFailures: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_block_repair_failures_total",
+			Help: "Total number of corrupted blocks the compactor failed to repair, and excluded from compaction.",
+		}),
+		blocksMarkedForRepair: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name:        blocksMarkedForDeletionName,
+			Help:        blocksMarkedForDeletionHelp,
+			ConstLabels: prometheus.Labels{"reason": "repair"},
+		}),
+		blocksTransferred: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_compactor_blocks_transferred_total",
+			Help: "Total number of blocks exported to or imported from the block transfer storage by the compactor.",
+		}, []string{"type"}),
+		blockTransferFailures: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_compactor_block_transfer_failures_total",
+			Help: "Total number of block transfer requests failed.",
+		}, []string{"type"}),
+		rollupEngine:                newRollupEngine(logger),
 		remainingPlannedCompactions: remainingPlannedCompactions,
 		limits:                      limits,
 	}
 
+	if compactorCfg.CompactionMemoryBudgetBytes > 0 {
+		c.compactionMemoryLimiter = newCompactionMemoryLimiter(compactorCfg.CompactionMemoryBudgetBytes, registerer)
+	}
+
 	if len(compactorCfg.EnabledTenants) > 0 {
 		level.Info(c.logger).Log("msg", "compactor using enabled users", "enabled", strings.Join(compactorCfg.EnabledTenants, ", "))
 	}
@@ -516,6 +668,18 @@ func (c *Compactor) starting(ctx context.Context) error {
 		return errors.Wrap(err, "failed to initialize compactor dependencies")
 	}
 
+	c.penaltyBlocksCompactor, err = newPenaltyDeduplicationCompactor(ctx, c.compactorCfg, c.logger)
+	if err != nil {
+		return errors.Wrap(err, "failed to initialize penalty deduplication compactor")
+	}
+
+	if c.compactorCfg.BlockTransferEnabled {
+		c.blockTransferBucket, err = bucket.NewClient(ctx, c.compactorCfg.BlockTransferStorage, "compactor-block-transfer", c.logger, c.registerer)
+		if err != nil {
+			return errors.Wrap(err, "failed to create block transfer bucket client")
+		}
+	}
+
 	// Wrap the bucket client to write block deletion marks in the global location too.
 	c.bucketClient = bucketindex.BucketWithGlobalMarkers(c.bucketClient)
 
@@ -529,17 +693,23 @@ func (c *Compactor) starting(ctx context.Context) error {
 		CleanupConcurrency:                 c.compactorCfg.CleanupConcurrency,
 		BlockDeletionMarksMigrationEnabled: c.compactorCfg.BlockDeletionMarksMigrationEnabled,
 		TenantCleanupDelay:                 c.compactorCfg.TenantCleanupDelay,
+		SeriesDeletionEnabled:              c.compactorCfg.SeriesDeletionEnabled,
+		SeriesDeletionDir:                  filepath.Join(c.compactorCfg.DataDir, "series-deletion"),
+		MaxBlockRange:                      c.compactorCfg.BlockRanges[len(c.compactorCfg.BlockRanges)-1],
+		AbandonedUploadsCleanupGracePeriod: c.compactorCfg.AbandonedUploadsCleanupGracePeriod,
+		BlockStatsEnabled:                  c.compactorCfg.BucketIndexBlockStatsEnabled,
+		BlockStatsKeyLabels:                c.compactorCfg.BucketIndexBlockStatsKeyLabels,
 	}, c.bucketClient, c.usersScanner, c.limits, c.parentLogger, c.registerer)
 
 	// Initialize the compactors ring if sharding is enabled.
 	if c.compactorCfg.ShardingEnabled {
 		lifecyclerCfg := c.compactorCfg.ShardingRing.ToLifecyclerConfig()
-		c.ringLifecycler, err = ring.NewLifecycler(lifecyclerCfg, ring.NewNoopFlushTransferer(), "compactor", ringKey, true, false, c.logger, prometheus.WrapRegistererWithPrefix("cortex_", c.registerer))
+		c.ringLifecycler, err = ring.NewLifecycler(lifecyclerCfg, ring.NewNoopFlushTransferer(), "compactor", RingKey, true, false, c.logger, prometheus.WrapRegistererWithPrefix("cortex_", c.registerer))
 		if err != nil {
 			return errors.Wrap(err, "unable to initialize compactor ring lifecycler")
 		}
 
-		c.ring, err = ring.New(lifecyclerCfg.RingConfig, "compactor", ringKey, c.logger, prometheus.WrapRegistererWithPrefix("cortex_", c.registerer))
+		c.ring, err = ring.New(lifecyclerCfg.RingConfig, "compactor", RingKey, c.logger, prometheus.WrapRegistererWithPrefix("cortex_", c.registerer))
 		if err != nil {
 			return errors.Wrap(err, "unable to initialize compactor ring")
 		}
@@ -688,6 +858,17 @@ func (c *Compactor) compactUsers(ctx context.Context) {
 		users[i], users[j] = users[j], users[i]
 	})
 
+	// Compact the users with a higher priority first. The sort is stable, so users with
+	// the same priority keep the shuffled order.
+	sort.SliceStable(users, func(i, j int) bool {
+		return c.limits.CompactorTenantPriority(users[i]) > c.limits.CompactorTenantPriority(users[j])
+	})
+
+	ownUser := c.ownUserForCompaction
+	if c.compactorCfg.ShardingWorkloadAwareEnabled {
+		ownUser = c.workloadAwareOwnership(ctx, users)
+	}
+
 	// Keep track of users owned by this shard, so that we can delete the local files for all other users.
 	ownedUsers := map[string]struct{}{}
 	for _, userID := range users {
@@ -699,7 +880,7 @@ func (c *Compactor) compactUsers(ctx context.Context) {
 		}
 
 		// Ensure the user ID belongs to our shard.
-		if owned, err := c.ownUserForCompaction(userID); err != nil {
+		if owned, err := ownUser(userID); err != nil {
 			c.compactionRunSkippedTenants.Inc()
 			level.Warn(c.logger).Log("msg", "unable to check if user is owned by this shard", "user", userID, "err", err)
 			continue
@@ -730,6 +911,12 @@ func (c *Compactor) compactUsers(ctx context.Context) {
 			continue
 		}
 
+		if windows := c.limits.CompactorTenantCompactionWindows(userID); !windows.Contains(time.Now()) {
+			c.compactionRunSkippedTenants.Inc()
+			level.Debug(c.logger).Log("msg", "skipping user because it is outside of its compaction windows", "user", userID, "windows", windows.String())
+			continue
+		}
+
 		level.Info(c.logger).Log("msg", "starting compaction of user blocks", "user", userID)
 
 		if err = c.compactUserWithRetries(ctx, userID); err != nil {
@@ -776,6 +963,10 @@ func (c *Compactor) compactUsers(ctx context.Context) {
 			}
 		}
 	}
+
+	if c.compactorCfg.CompactionJournalEnabled {
+		c.cleanupCompactionDirs(ownedUsers, c.compactorCfg.CompactionJournalMaxAge)
+	}
 }
 
 func (c *Compactor) compactUserWithRetries(ctx context.Context, userID string) error {
@@ -827,19 +1018,9 @@ func (c *Compactor) compactUser(ctx context.Context, userID string) error {
 	// out of order chunks or index file too big.
 	noCompactMarkerFilter := compact.NewGatherNoCompactionMarkFilter(ulogger, bucket, c.compactorCfg.MetaSyncConcurrency)
 
-	var blockLister block.Lister
-	switch cortex_tsdb.BlockDiscoveryStrategy(c.storageCfg.BucketStore.BlockDiscoveryStrategy) {
-	case cortex_tsdb.ConcurrentDiscovery:
-		blockLister = block.NewConcurrentLister(ulogger, bucket)
-	case cortex_tsdb.RecursiveDiscovery:
-		blockLister = block.NewRecursiveLister(ulogger, bucket)
-	case cortex_tsdb.BucketIndexDiscovery:
-		if !c.storageCfg.BucketStore.BucketIndex.Enabled {
-			return cortex_tsdb.ErrInvalidBucketIndexBlockDiscoveryStrategy
-		}
-		blockLister = bucketindex.NewBlockLister(ulogger, c.bucketClient, userID, c.limits)
-	default:
-		return cortex_tsdb.ErrBlockDiscoveryStrategy
+	blockLister, err := c.blockListerForUser(ulogger, userID, bucket)
+	if err != nil {
+		return err
 	}
 
 	fetcher, err := block.NewMetaFetcher(
@@ -852,8 +1033,9 @@ func (c *Compactor) compactUser(ctx context.Context, userID string) error {
 		// List of filters to apply (order matters).
 		[]block.MetadataFilter{
 			// Remove the ingester ID because we don't shard blocks anymore, while still
-			// honoring the shard ID if sharding was done in the past.
-			NewLabelRemoverFilter([]string{cortex_tsdb.IngesterIDExternalLabel}),
+			// honoring the shard ID if sharding was done in the past. The replica labels are
+			// removed too, so that the blocks of different replicas are compacted together.
+			NewLabelRemoverFilter(append([]string{cortex_tsdb.IngesterIDExternalLabel}, c.limits.CompactorDeduplicationReplicaLabels(userID)...)),
 			block.NewConsistencyDelayMetaFilter(ulogger, c.compactorCfg.ConsistencyDelay, reg),
 			ignoreDeletionMarkFilter,
 			deduplicateBlocksFilter,
@@ -878,14 +1060,31 @@ func (c *Compactor) compactUser(ctx context.Context, userID string) error {
 		return errors.Wrap(err, "failed to create syncer")
 	}
 
+	// When the compaction journal is enabled, the blocks are downloaded and compacted keeping
+	// track of the progress, so that a compaction interrupted midway can be resumed.
+	var journal *compactionJournalFile
+	grouperBucket, blocksCompactor := bucket, c.blocksCompactorForUser(userID)
+	if c.compactionMemoryLimiter != nil {
+		// Wrapped before the journal, so that the compactions resumed from the journal don't wait for the budget.
+		blocksCompactor = newMemoryLimitedCompactor(ctx, blocksCompactor, c.compactionMemoryLimiter, ulogger)
+	}
+	if c.compactorCfg.CompactionJournalEnabled {
+		journal, err = openCompactionJournal(ulogger, c.compactDirForUser(userID))
+		if err != nil {
+			return errors.Wrap(err, "failed to open compaction journal")
+		}
+		grouperBucket = newJournaledBucket(bucket, journal, ulogger)
+		blocksCompactor = newJournaledCompactor(blocksCompactor, journal, c.compactionsResumed, ulogger)
+	}
+
 	currentCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	compactor, err := compact.NewBucketCompactor(
 		ulogger,
 		syncer,
-		c.blocksGrouperFactory(currentCtx, c.compactorCfg, bucket, ulogger, reg, c.blocksMarkedForDeletion, c.blocksMarkedForNoCompaction, c.garbageCollectedBlocks, c.remainingPlannedCompactions, c.blockVisitMarkerReadFailed, c.blockVisitMarkerWriteFailed, c.ring, c.ringLifecycler, c.limits, userID, noCompactMarkerFilter),
+		c.blocksGrouperFactory(currentCtx, c.compactorCfg, grouperBucket, ulogger, reg, c.blocksMarkedForDeletion, c.blocksMarkedForNoCompaction, c.garbageCollectedBlocks, c.remainingPlannedCompactions, c.blockVisitMarkerReadFailed, c.blockVisitMarkerWriteFailed, c.ring, c.ringLifecycler, c.limits, userID, noCompactMarkerFilter),
 		c.blocksPlannerFactory(currentCtx, bucket, ulogger, c.compactorCfg, noCompactMarkerFilter, c.ringLifecycler, c.blockVisitMarkerReadFailed, c.blockVisitMarkerWriteFailed),
-		c.blocksCompactor,
+		blocksCompactor,
 		c.compactDirForUser(userID),
 		bucket,
 		c.compactorCfg.CompactionConcurrency,
@@ -895,20 +1094,76 @@ func (c *Compactor) compactUser(ctx context.Context, userID string) error {
 		return errors.Wrap(err, "failed to create bucket compactor")
 	}
 
+	if c.compactorCfg.BlockTransferEnabled {
+		if err := c.transferUserBlocks(ctx, ulogger, userID, bucket, fetcher); err != nil {
+			return errors.Wrap(err, "block transfer")
+		}
+	}
+
+	if c.compactorCfg.BlockRepairEnabled {
+		if err := c.repairUserBlocks(ctx, ulogger, userID, bucket, fetcher, noCompactMarkerFilter, c.compactDirForUser(userID)); err != nil {
+			return errors.Wrap(err, "repair")
+		}
+	}
+
 	if err := compactor.Compact(ctx); err != nil {
 		return errors.Wrap(err, "compaction")
 	}
 
+	if c.limits.CompactorDownsamplingEnabled(userID) {
+		if err := c.downsampleUserBlocks(ctx, ulogger, bucket, fetcher, c.compactDirForUser(userID)); err != nil {
+			return errors.Wrap(err, "downsampling")
+		}
+	}
+
+	if configs := c.limits.CompactorRelabelConfigs(userID); len(configs) > 0 {
+		if err := c.relabelUserBlocks(ctx, ulogger, bucket, fetcher, ignoreDeletionMarkFilter.DeletionMarkBlocks(), configs, c.compactDirForUser(userID)); err != nil {
+			return errors.Wrap(err, "relabeling")
+		}
+	}
+
+	if rules := c.limits.CompactorRollupRules(userID); len(rules) > 0 {
+		if err := c.rollupUserBlocks(ctx, ulogger, bucket, fetcher, ignoreDeletionMarkFilter, rules, c.compactDirForUser(userID)); err != nil {
+			return errors.Wrap(err, "rollup")
+		}
+	}
+
 	// Remove all files on the compact root dir
 	// We do this only if there is no error because potentially on the next run we would not have to download
-	// everything again.
-	if err := os.RemoveAll(c.compactRootDir()); err != nil {
-		level.Error(c.logger).Log("msg", "failed to remove compaction work directory", "path", c.compactRootDir(), "err", err)
+	// everything again. When the compaction journal is enabled, we keep the other users' directories, which
+	// are cleaned up once stale. The journal is marked completed first, so that the directory is cleaned
+	// up later if it can't be removed now.
+	compactDir := c.compactRootDir()
+	if journal != nil {
+		compactDir = c.compactDirForUser(userID)
+		if err := journal.markCompleted(); err != nil {
+			level.Warn(ulogger).Log("msg", "failed to mark the compaction journal completed", "err", err)
+		}
+	}
+	if err := os.RemoveAll(compactDir); err != nil {
+		level.Error(c.logger).Log("msg", "failed to remove compaction work directory", "path", compactDir, "err", err)
 	}
 
 	return nil
 }
 
+// blockListerForUser returns the lister of the user blocks, based on the configured block discovery strategy.
+func (c *Compactor) blockListerForUser(logger log.Logger, userID string, bkt objstore.InstrumentedBucket) (block.Lister, error) {
+	switch cortex_tsdb.BlockDiscoveryStrategy(c.storageCfg.BucketStore.BlockDiscoveryStrategy) {
+	case cortex_tsdb.ConcurrentDiscovery:
+		return block.NewConcurrentLister(logger, bkt), nil
+	case cortex_tsdb.RecursiveDiscovery:
+		return block.NewRecursiveLister(logger, bkt), nil
+	case cortex_tsdb.BucketIndexDiscovery:
+		if !c.storageCfg.BucketStore.BucketIndex.Enabled {
+			return nil, cortex_tsdb.ErrInvalidBucketIndexBlockDiscoveryStrategy
+		}
+		return bucketindex.NewBlockLister(logger, c.bucketClient, userID, c.limits), nil
+	default:
+		return nil, cortex_tsdb.ErrBlockDiscoveryStrategy
+	}
+}
+
 func (c *Compactor) discoverUsersWithRetries(ctx context.Context) ([]string, error) {
 	var lastErr error
 
@@ -2,10 +2,15 @@ package compactor
 
 import (
 	"html/template"
+	"math"
 	"net/http"
+	"time"
 
 	"github.com/go-kit/log/level"
 
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
 	"github.com/cortexproject/cortex/pkg/util/services"
 )
@@ -51,3 +56,127 @@ func (c *Compactor) RingHandler(w http.ResponseWriter, req *http.Request) {
 
 	c.ring.ServeHTTP(w, req)
 }
+
+// BlockRepairReportHandler returns the blocks repair report of the tenant.
+func (c *Compactor) BlockRepairReportHandler(w http.ResponseWriter, req *http.Request) {
+	if !c.compactorCfg.BlockRepairEnabled {
+		http.Error(w, "blocks repair is disabled", http.StatusNotFound)
+		return
+	}
+
+	if c.State() != services.Running {
+		// The bucket client is created while the compactor is starting.
+		http.Error(w, "compactor is not running yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	userID, err := tenant.TenantID(req.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	userBucket := bucket.NewUserBucketClient(userID, c.bucketClient, c.limits)
+	report, err := readBlockRepairReport(req.Context(), util_log.WithUserID(userID, c.logger), userBucket)
+	if err != nil {
+		level.Error(c.logger).Log("msg", "unable to read blocks repair report", "user", userID, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	util.WriteJSONResponse(w, report)
+}
+
+// BlockTransfersHandler lists the block transfer requests of the tenant on GET, and creates a new
+// block transfer request on POST.
+func (c *Compactor) BlockTransfersHandler(w http.ResponseWriter, req *http.Request) {
+	if !c.compactorCfg.BlockTransferEnabled {
+		http.Error(w, "block transfer is disabled", http.StatusNotFound)
+		return
+	}
+
+	if c.State() != services.Running {
+		// The bucket client is created while the compactor is starting.
+		http.Error(w, "compactor is not running yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	userID, err := tenant.TenantID(req.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	logger := util_log.WithUserID(userID, c.logger)
+	userBucket := bucket.NewUserBucketClient(userID, c.bucketClient, c.limits)
+
+	if req.Method == http.MethodGet {
+		transfers, err := readBlockTransfers(req.Context(), logger, userBucket)
+		if err != nil {
+			level.Error(logger).Log("msg", "unable to read block transfers", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		util.WriteJSONResponse(w, transfers)
+		return
+	}
+
+	start, err := util.ParseTimeParam(req, "start", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	end := int64(math.MaxInt64)
+	if req.FormValue("end") != "" {
+		if end, err = util.ParseTimeParam(req, "end", 0); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	transfer, err := newBlockTransfer(req.FormValue("type"), req.FormValue("prefix"), start, end, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := writeBlockTransfer(req.Context(), userBucket, transfer); err != nil {
+		level.Error(logger).Log("msg", "unable to write block transfer", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	level.Info(logger).Log("msg", "block transfer request created", "id", transfer.ID, "type", transfer.Type, "prefix", transfer.Prefix, "start", start, "end", end)
+
+	util.WriteJSONResponse(w, transfer)
+}
+
+// CompactionPlanHandler returns the compaction plan of the tenant, computed from the blocks currently
+// in the storage, without executing it.
+func (c *Compactor) CompactionPlanHandler(w http.ResponseWriter, req *http.Request) {
+	if c.State() != services.Running {
+		// The bucket client is created while the compactor is starting.
+		http.Error(w, "compactor is not running yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	userID, err := tenant.TenantID(req.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	logger := util_log.WithUserID(userID, c.logger)
+	userBucket := bucket.NewUserBucketClient(userID, c.bucketClient, c.limits)
+
+	plan, err := c.planUserCompaction(req.Context(), logger, userID, userBucket)
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to compute the compaction plan", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	util.WriteJSONResponse(w, plan)
+}
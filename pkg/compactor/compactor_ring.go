@@ -20,6 +20,7 @@ import (
 type RingConfig struct {
 	KVStore          kv.Config     `yaml:"kvstore"`
 	HeartbeatPeriod  time.Duration `yaml:"heartbeat_period"`
+	CASBatchPeriod   time.Duration `yaml:"cas_batch_period"`
 	HeartbeatTimeout time.Duration `yaml:"heartbeat_timeout"`
 
 	// Wait ring stability.
@@ -34,6 +35,9 @@ type RingConfig struct {
 	TokensFilePath         string   `yaml:"tokens_file_path"`
 	UnregisterOnShutdown   bool     `yaml:"unregister_on_shutdown"`
 
+	TokensGeneratorStrategy string `yaml:"tokens_generator_strategy"`
+	TokensStaticFile        string `yaml:"tokens_static_file"`
+
 	// Injected internally
 	ListenPort int `yaml:"-"`
 
@@ -53,6 +57,7 @@ func (cfg *RingConfig) RegisterFlags(f *flag.FlagSet) {
 	// Ring flags
 	cfg.KVStore.RegisterFlagsWithPrefix("compactor.ring.", "collectors/", f)
 	f.DurationVar(&cfg.HeartbeatPeriod, "compactor.ring.heartbeat-period", 5*time.Second, "Period at which to heartbeat to the ring. 0 = disabled.")
+	f.DurationVar(&cfg.CASBatchPeriod, "compactor.ring.cas-batch-period", 0, "EXPERIMENTAL: Period within which the ring updates of the compactor, including the heartbeats, are batched with the ring updates of the other components of the same process into a single KV store transaction. When enabled, the heartbeats are aligned to the heartbeat period, so that the heartbeats of the components with the same heartbeat period, or a multiple of it, are batched together. Supported by the consul, etcd and memberlist KV stores. 0 = disabled.")
 	f.DurationVar(&cfg.HeartbeatTimeout, "compactor.ring.heartbeat-timeout", time.Minute, "The heartbeat timeout after which compactors are considered unhealthy within the ring. 0 = never (timeout disabled).")
 
 	// Wait stability flags.
@@ -67,6 +72,8 @@ func (cfg *RingConfig) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&cfg.InstanceID, "compactor.ring.instance-id", hostname, "Instance ID to register in the ring.")
 	f.StringVar(&cfg.TokensFilePath, "compactor.ring.tokens-file-path", "", "File path where tokens are stored. If empty, tokens are not stored at shutdown and restored at startup.")
 	f.BoolVar(&cfg.UnregisterOnShutdown, "compactor.ring.unregister-on-shutdown", true, "Unregister the compactor during shutdown if true.")
+	f.StringVar(&cfg.TokensGeneratorStrategy, "compactor.ring.tokens-generator-strategy", "random", ring.TokensGeneratorStrategyFlagDescription)
+	f.StringVar(&cfg.TokensStaticFile, "compactor.ring.tokens-static-file", "", "EXPERIMENTAL: Path of the YAML file mapping the instance IDs to their tokens, used by the static token generator strategy.")
 
 	// Timeout durations
 	f.DurationVar(&cfg.WaitActiveInstanceTimeout, "compactor.ring.wait-active-instance-timeout", 10*time.Minute, "Timeout for waiting on compactor to become ACTIVE in the ring.")
@@ -98,11 +105,14 @@ func (cfg *RingConfig) ToLifecyclerConfig() ring.LifecyclerConfig {
 	lc.InfNames = cfg.InstanceInterfaceNames
 	lc.UnregisterOnShutdown = cfg.UnregisterOnShutdown
 	lc.HeartbeatPeriod = cfg.HeartbeatPeriod
+	lc.CASBatchPeriod = cfg.CASBatchPeriod
 	lc.ObservePeriod = cfg.ObservePeriod
 	lc.JoinAfter = 0
 	lc.MinReadyDuration = 0
 	lc.FinalSleep = 0
 	lc.TokensFilePath = cfg.TokensFilePath
+	lc.TokensGeneratorStrategy = cfg.TokensGeneratorStrategy
+	lc.TokensStaticFile = cfg.TokensStaticFile
 
 	// We use a safe default instead of exposing to config option to the user
 	// in order to simplify the config.
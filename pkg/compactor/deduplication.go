@@ -0,0 +1,32 @@
+package compactor
+
+import (
+	"context"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/thanos-io/thanos/pkg/compact"
+	"github.com/thanos-io/thanos/pkg/compact/downsample"
+	"github.com/thanos-io/thanos/pkg/dedup"
+
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+// newPenaltyDeduplicationCompactor creates a blocks compactor which merges the series of overlapping
+// blocks with the penalty based deduplication algorithm, picking the samples of one replica at a time.
+// Unlike the default one-to-one deduplication, it deduplicates the samples scraped by HA Prometheus
+// pairs, which have different timestamps.
+func newPenaltyDeduplicationCompactor(ctx context.Context, cfg Config, logger log.Logger) (compact.Compactor, error) {
+	// The compactor metrics are already registered by the default blocks compactor.
+	return tsdb.NewLeveledCompactor(ctx, nil, logger, cfg.BlockRanges.ToMilliseconds(), downsample.NewPool(), dedup.NewChunkSeriesMerger())
+}
+
+// blocksCompactorForUser returns the compactor to use to compact the blocks of the given user,
+// according to its deduplication function.
+func (c *Compactor) blocksCompactorForUser(userID string) compact.Compactor {
+	if c.limits.CompactorDeduplicationFunc(userID) == validation.CompactorDeduplicationFuncPenalty {
+		return c.penaltyBlocksCompactor
+	}
+
+	return c.blocksCompactor
+}
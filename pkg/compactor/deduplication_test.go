@@ -0,0 +1,90 @@
+package compactor
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact"
+
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+func TestPenaltyDeduplicationCompactor(t *testing.T) {
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+	series := labels.FromStrings(labels.MetricName, "series_1")
+
+	cfg := Config{}
+	require.NoError(t, cfg.BlockRanges.Set("2h"))
+
+	// Create the blocks of two HA replicas scraping the same series every 15s, with a 5s offset.
+	createReplicaBlock := func(dir string, offset time.Duration) string {
+		w, err := tsdb.NewBlockWriter(logger, dir, 2*time.Hour.Milliseconds())
+		require.NoError(t, err)
+
+		app := w.Appender(ctx)
+		for i := 0; i < 40; i++ {
+			ts := (time.Duration(i)*15*time.Second + offset).Milliseconds()
+			_, err := app.Append(0, series, ts, float64(i))
+			require.NoError(t, err)
+		}
+		require.NoError(t, app.Commit())
+
+		id, err := w.Flush(ctx)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		return filepath.Join(dir, id.String())
+	}
+
+	compactReplicaBlocks := func(c compact.Compactor) *metadata.Meta {
+		dir := t.TempDir()
+		id, err := c.Compact(dir, []string{createReplicaBlock(t.TempDir(), 0), createReplicaBlock(t.TempDir(), 5*time.Second)}, nil)
+		require.NoError(t, err)
+
+		meta, err := metadata.ReadFromDir(filepath.Join(dir, id.String()))
+		require.NoError(t, err)
+		return meta
+	}
+
+	// The one-to-one deduplication keeps the samples of both replicas.
+	oneToOne, err := tsdb.NewLeveledCompactor(ctx, nil, logger, cfg.BlockRanges.ToMilliseconds(), nil, nil)
+	require.NoError(t, err)
+
+	meta := compactReplicaBlocks(oneToOne)
+	assert.Equal(t, uint64(1), meta.Stats.NumSeries)
+	assert.Equal(t, uint64(80), meta.Stats.NumSamples)
+
+	// The penalty deduplication keeps the samples of one replica.
+	penalty, err := newPenaltyDeduplicationCompactor(ctx, cfg, logger)
+	require.NoError(t, err)
+
+	meta = compactReplicaBlocks(penalty)
+	assert.Equal(t, uint64(1), meta.Stats.NumSeries)
+	assert.Equal(t, uint64(40), meta.Stats.NumSamples)
+}
+
+func TestCompactor_BlocksCompactorForUser(t *testing.T) {
+	c := &Compactor{
+		blocksCompactor:        &tsdbCompactorMock{},
+		penaltyBlocksCompactor: &tsdbCompactorMock{},
+	}
+
+	for deduplicationFunc, expected := range map[string]compact.Compactor{
+		validation.CompactorDeduplicationFuncOneToOne: c.blocksCompactor,
+		validation.CompactorDeduplicationFuncPenalty:  c.penaltyBlocksCompactor,
+	} {
+		overrides, err := validation.NewOverrides(validation.Limits{CompactorDeduplicationFunc: deduplicationFunc}, nil)
+		require.NoError(t, err)
+
+		c.limits = overrides
+		assert.Same(t, expected, c.blocksCompactorForUser("user-1"))
+	}
+}
@@ -0,0 +1,159 @@
+package compactor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact/downsample"
+	"github.com/thanos-io/thanos/pkg/runutil"
+)
+
+// downsampleUserBlocks downsamples the raw blocks of a user spanning at least 40h to 5m resolution, and
+// the 5m blocks spanning at least 10d to 1h resolution, like the Thanos compactor does. A block is downsampled only once: blocks whose sources are all covered by
+// blocks of the target resolution are skipped. 5m blocks created during this run are downsampled to 1h
+// on the next run.
+func (c *Compactor) downsampleUserBlocks(ctx context.Context, logger log.Logger, bkt objstore.Bucket, fetcher block.MetadataFetcher, dir string) error {
+	metas, _, err := fetcher.Fetch(ctx)
+	if err != nil {
+		return errors.Wrap(err, "fetch blocks metadata")
+	}
+
+	for _, target := range []struct{ from, to, minBlockRange int64 }{
+		{from: downsample.ResLevel0, to: downsample.ResLevel1, minBlockRange: downsample.ResLevel1DownsampleRange},
+		{from: downsample.ResLevel1, to: downsample.ResLevel2, minBlockRange: downsample.ResLevel2DownsampleRange},
+	} {
+		for _, meta := range blocksToDownsample(metas, target.from, target.to, target.minBlockRange) {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			if err := downsampleBlock(ctx, logger, bkt, meta, target.to, dir); err != nil {
+				return errors.Wrapf(err, "downsample block %s to resolution %d", meta.ULID, target.to)
+			}
+			c.blocksDownsampled.WithLabelValues(strconv.FormatInt(target.to, 10)).Inc()
+		}
+	}
+
+	return nil
+}
+
+// blocksToDownsample returns the blocks with the given resolution spanning at least minBlockRange
+// which have not been downsampled to the target resolution yet.
+func blocksToDownsample(metas map[ulid.ULID]*metadata.Meta, from, to, minBlockRange int64) []*metadata.Meta {
+	downsampledSources := map[ulid.ULID]struct{}{}
+	for _, m := range metas {
+		if m.Thanos.Downsample.Resolution != to {
+			continue
+		}
+		for _, id := range m.Compaction.Sources {
+			downsampledSources[id] = struct{}{}
+		}
+	}
+
+	var result []*metadata.Meta
+	for _, m := range metas {
+		if m.Thanos.Downsample.Resolution != from || m.MaxTime-m.MinTime < minBlockRange {
+			continue
+		}
+
+		for _, id := range m.Compaction.Sources {
+			if _, ok := downsampledSources[id]; !ok {
+				result = append(result, m)
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+func downsampleBlock(ctx context.Context, logger log.Logger, bkt objstore.Bucket, meta *metadata.Meta, resolution int64, dir string) error {
+	blockDir := filepath.Join(dir, meta.ULID.String())
+	defer func() {
+		if rmErr := os.RemoveAll(blockDir); rmErr != nil {
+			level.Warn(logger).Log("msg", "failed to remove downloaded block", "block", meta.ULID, "err", rmErr)
+		}
+	}()
+
+	if err := block.Download(ctx, logger, bkt, meta.ULID, blockDir); err != nil {
+		return errors.Wrap(err, "download block")
+	}
+
+	b, err := tsdb.OpenBlock(logger, blockDir, downsample.NewPool())
+	if err != nil {
+		return errors.Wrap(err, "open block")
+	}
+	defer runutil.CloseWithLogOnErr(logger, b, "downsampled block reader")
+
+	id, err := downsampleBlockSeries(ctx, logger, meta, b, resolution, dir)
+	if err != nil {
+		return err
+	}
+
+	downsampledDir := filepath.Join(dir, id.String())
+	defer func() {
+		if rmErr := os.RemoveAll(downsampledDir); rmErr != nil {
+			level.Warn(logger).Log("msg", "failed to remove downsampled block", "block", id, "err", rmErr)
+		}
+	}()
+
+	if err := block.Upload(ctx, logger, bkt, downsampledDir, metadata.NoneFunc); err != nil {
+		return errors.Wrapf(err, "upload downsampled block %s", id)
+	}
+
+	level.Info(logger).Log("msg", "downsampled block", "source", meta.ULID, "block", id, "resolution", resolution)
+	return nil
+}
+
+// downsampleBlockSeries downsamples the series of the block, writing a new block into dir and returning
+// its ID. The float series are downsampled by Thanos, while the native histogram series, not supported
+// by the Thanos downsampling, are downsampled separately and merged into the same block.
+func downsampleBlockSeries(ctx context.Context, logger log.Logger, meta *metadata.Meta, b tsdb.BlockReader, resolution int64, dir string) (ulid.ULID, error) {
+	indexr, err := b.Index()
+	if err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "open index")
+	}
+	defer runutil.CloseWithLogOnErr(logger, indexr, "downsampled block index reader")
+
+	chunkr, err := b.Chunks()
+	if err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "open chunks")
+	}
+	defer runutil.CloseWithLogOnErr(logger, chunkr, "downsampled block chunks reader")
+
+	floats, histograms, err := splitSeriesByType(ctx, indexr, chunkr)
+	if err != nil {
+		return ulid.ULID{}, err
+	}
+	if len(histograms) == 0 {
+		return downsample.Downsample(ctx, logger, meta, b, dir, resolution)
+	}
+
+	histogramsID, err := downsampleHistogramSeries(ctx, logger, b, meta, histograms, resolution, dir)
+	if err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "downsample native histogram series")
+	}
+
+	floatsID, err := downsample.Downsample(ctx, logger, meta, &floatSeriesBlockReader{BlockReader: b, logger: logger, refs: floats}, dir, resolution)
+	if err != nil {
+		if rmErr := os.RemoveAll(filepath.Join(dir, histogramsID.String())); rmErr != nil {
+			level.Warn(logger).Log("msg", "failed to remove downsampled native histograms block", "block", histogramsID, "err", rmErr)
+		}
+		return ulid.ULID{}, err
+	}
+	if histogramsID == (ulid.ULID{}) {
+		return floatsID, nil
+	}
+
+	return mergeDownsampledBlocks(ctx, logger, floatsID, histogramsID, dir)
+}
@@ -0,0 +1,324 @@
+package compactor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/index"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact/downsample"
+	"github.com/thanos-io/thanos/pkg/runutil"
+)
+
+// histogramSample is a native histogram sample.
+type histogramSample struct {
+	t  int64
+	fh *histogram.FloatHistogram
+}
+
+// isHistogramEncoding returns whether the chunk encoding holds native histogram samples.
+func isHistogramEncoding(e chunkenc.Encoding) bool {
+	return e == chunkenc.EncHistogram || e == chunkenc.EncFloatHistogram
+}
+
+// splitSeriesByType returns the references of the series of the block which contain float samples,
+// and of the series which only contain native histogram samples. The Thanos downsampling only
+// supports float samples, so the native histogram series are downsampled separately.
+func splitSeriesByType(ctx context.Context, indexr tsdb.IndexReader, chunkr tsdb.ChunkReader) (floats, histograms []storage.SeriesRef, err error) {
+	key, value := index.AllPostingsKey()
+	postings, err := indexr.Postings(ctx, key, value)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "read postings")
+	}
+
+	var (
+		builder labels.ScratchBuilder
+		chks    []chunks.Meta
+	)
+
+	for postings.Next() {
+		ref := postings.At()
+		if err := indexr.Series(ref, &builder, &chks); err != nil {
+			return nil, nil, errors.Wrapf(err, "read series %d", ref)
+		}
+
+		hasFloats, hasHistograms := false, false
+		for _, chk := range chks {
+			c, _, err := chunkr.ChunkOrIterable(chk)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "read chunk of series %d", ref)
+			}
+			if isHistogramEncoding(c.Encoding()) {
+				hasHistograms = true
+			} else {
+				hasFloats = true
+			}
+		}
+
+		// Series mixing floats and native histograms are downsampled keeping only their float samples.
+		if hasHistograms && !hasFloats {
+			histograms = append(histograms, ref)
+		} else {
+			floats = append(floats, ref)
+		}
+	}
+
+	return floats, histograms, postings.Err()
+}
+
+// floatSeriesBlockReader is a tsdb.BlockReader exposing only the float series and chunks of a block.
+type floatSeriesBlockReader struct {
+	tsdb.BlockReader
+
+	logger log.Logger
+	refs   []storage.SeriesRef
+}
+
+func (r *floatSeriesBlockReader) Index() (tsdb.IndexReader, error) {
+	indexr, err := r.BlockReader.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	chunkr, err := r.BlockReader.Chunks()
+	if err != nil {
+		runutil.CloseWithLogOnErr(r.logger, indexr, "float series index reader")
+		return nil, err
+	}
+
+	return &floatSeriesIndexReader{IndexReader: indexr, logger: r.logger, chunkr: chunkr, refs: r.refs}, nil
+}
+
+type floatSeriesIndexReader struct {
+	tsdb.IndexReader
+
+	logger log.Logger
+	chunkr tsdb.ChunkReader
+	refs   []storage.SeriesRef
+}
+
+func (r *floatSeriesIndexReader) Postings(ctx context.Context, name string, values ...string) (index.Postings, error) {
+	p, err := r.IndexReader.Postings(ctx, name, values...)
+	if err != nil {
+		return nil, err
+	}
+
+	return index.Intersect(p, index.NewListPostings(r.refs)), nil
+}
+
+// Series returns the series labels and its chunks, excluding the native histogram chunks of
+// series mixing floats and native histograms.
+func (r *floatSeriesIndexReader) Series(ref storage.SeriesRef, builder *labels.ScratchBuilder, chks *[]chunks.Meta) error {
+	if err := r.IndexReader.Series(ref, builder, chks); err != nil {
+		return err
+	}
+
+	filtered := (*chks)[:0]
+	for _, chk := range *chks {
+		c, _, err := r.chunkr.ChunkOrIterable(chk)
+		if err != nil {
+			return err
+		}
+		if !isHistogramEncoding(c.Encoding()) {
+			filtered = append(filtered, chk)
+		}
+	}
+	*chks = filtered
+
+	return nil
+}
+
+func (r *floatSeriesIndexReader) Close() error {
+	runutil.CloseWithLogOnErr(r.logger, r.chunkr, "float series chunks reader")
+	return r.IndexReader.Close()
+}
+
+// downsampleHistogramSeries downsamples the input native histogram series of the block to the given
+// resolution, writing them to a new block in dir. It returns the ID of the new block.
+func downsampleHistogramSeries(ctx context.Context, logger log.Logger, b tsdb.BlockReader, meta *metadata.Meta, refs []storage.SeriesRef, resolution int64, dir string) (ulid.ULID, error) {
+	indexr, err := b.Index()
+	if err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "open index")
+	}
+	defer runutil.CloseWithLogOnErr(logger, indexr, "downsample histograms index reader")
+
+	chunkr, err := b.Chunks()
+	if err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "open chunks")
+	}
+	defer runutil.CloseWithLogOnErr(logger, chunkr, "downsample histograms chunks reader")
+
+	// The block writer head only accepts samples within half of its block size from the max
+	// appended timestamp, so we double it to be able to append the series one by one.
+	w, err := tsdb.NewBlockWriter(logger, dir, 2*(meta.MaxTime-meta.MinTime))
+	if err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "create block writer")
+	}
+	defer runutil.CloseWithLogOnErr(logger, w, "downsample histograms block writer")
+
+	var (
+		builder labels.ScratchBuilder
+		chks    []chunks.Meta
+		samples []histogramSample
+		it      chunkenc.Iterator
+	)
+
+	for _, ref := range refs {
+		if err := ctx.Err(); err != nil {
+			return ulid.ULID{}, err
+		}
+		if err := indexr.Series(ref, &builder, &chks); err != nil {
+			return ulid.ULID{}, errors.Wrapf(err, "read series %d", ref)
+		}
+		lbls := builder.Labels()
+
+		samples = samples[:0]
+		for _, chk := range chks {
+			c, _, err := chunkr.ChunkOrIterable(chk)
+			if err != nil {
+				return ulid.ULID{}, errors.Wrapf(err, "read chunk of series %s", lbls)
+			}
+
+			it = c.Iterator(it)
+			for typ := it.Next(); typ != chunkenc.ValNone; typ = it.Next() {
+				if typ != chunkenc.ValHistogram && typ != chunkenc.ValFloatHistogram {
+					continue
+				}
+
+				// The iterator converts integer histograms to float histograms.
+				t, fh := it.AtFloatHistogram(nil)
+				if value.IsStaleNaN(fh.Sum) {
+					continue
+				}
+				// Ensure the series doesn't go back in time.
+				if len(samples) > 0 && t <= samples[len(samples)-1].t {
+					continue
+				}
+				samples = append(samples, histogramSample{t: t, fh: fh})
+			}
+			if err := it.Err(); err != nil {
+				return ulid.ULID{}, errors.Wrapf(err, "iterate chunk of series %s", lbls)
+			}
+		}
+
+		app := w.Appender(ctx)
+		for _, s := range downsampleHistograms(samples, resolution) {
+			if _, err := app.AppendHistogram(0, lbls, s.t, nil, s.fh); err != nil {
+				_ = app.Rollback()
+				return ulid.ULID{}, errors.Wrapf(err, "append series %s", lbls)
+			}
+		}
+		if err := app.Commit(); err != nil {
+			return ulid.ULID{}, errors.Wrapf(err, "commit series %s", lbls)
+		}
+	}
+
+	return w.Flush(ctx)
+}
+
+// downsampleHistograms aggregates the input samples, sorted by timestamp, to one sample for each
+// resolution window. The aggregated sample is the average of the window samples: the sum of their
+// buckets, reconciling different schemas and zero thresholds, divided by the number of samples. Like
+// the Thanos downsampling, its timestamp is the end of the window, capped to the last input sample.
+func downsampleHistograms(samples []histogramSample, resolution int64) []histogramSample {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	var (
+		result    []histogramSample
+		sum       *histogram.FloatHistogram
+		count     int
+		windowEnd int64
+		lastT     = samples[len(samples)-1].t
+	)
+
+	flush := func() {
+		avg := sum.Div(float64(count)).Compact(0)
+
+		// The counter reset hints of the window samples don't apply to the aggregated sample,
+		// so we let the counter resets be detected again, unless it's a gauge histogram.
+		if avg.CounterResetHint != histogram.GaugeType {
+			avg.CounterResetHint = histogram.UnknownCounterReset
+		}
+		result = append(result, histogramSample{t: min(windowEnd, lastT), fh: avg})
+	}
+
+	for _, s := range samples {
+		if sum != nil && s.t > windowEnd {
+			flush()
+			sum = nil
+		}
+
+		if sum == nil {
+			sum = s.fh.Copy()
+			count = 1
+			windowEnd = s.t - (s.t % resolution) + resolution - 1
+			continue
+		}
+
+		sum.Add(s.fh)
+		count++
+	}
+	flush()
+
+	return result
+}
+
+// mergeDownsampledBlocks merges the Thanos downsampled block with the block holding the downsampled
+// native histogram series. The merged block gets the metadata of the Thanos downsampled block.
+func mergeDownsampledBlocks(ctx context.Context, logger log.Logger, floatsID, histogramsID ulid.ULID, dir string) (ulid.ULID, error) {
+	floatsDir := filepath.Join(dir, floatsID.String())
+	histogramsDir := filepath.Join(dir, histogramsID.String())
+	defer func() {
+		for _, d := range []string{floatsDir, histogramsDir} {
+			if err := os.RemoveAll(d); err != nil {
+				level.Warn(logger).Log("msg", "failed to remove downsampled block", "dir", d, "err", err)
+			}
+		}
+	}()
+
+	floatsMeta, err := metadata.ReadFromDir(floatsDir)
+	if err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "read downsampled block meta")
+	}
+
+	// The blocks have no series in common, so the compactor just copies the chunks of each
+	// series, including the downsampled aggregated chunks it couldn't iterate over.
+	compactor, err := tsdb.NewLeveledCompactor(ctx, nil, logger, []int64{floatsMeta.MaxTime - floatsMeta.MinTime}, downsample.NewPool(), nil)
+	if err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "create compactor")
+	}
+
+	id, err := compactor.Compact(dir, []string{floatsDir, histogramsDir}, nil)
+	if err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "merge downsampled blocks")
+	}
+
+	mergedDir := filepath.Join(dir, id.String())
+	mergedMeta, err := metadata.ReadFromDir(mergedDir)
+	if err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "read merged block meta")
+	}
+
+	newMeta := *floatsMeta
+	newMeta.ULID = id
+	newMeta.Stats = mergedMeta.Stats
+	if err := newMeta.WriteToDir(logger, mergedDir); err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "write merged block meta")
+	}
+
+	return id, nil
+}
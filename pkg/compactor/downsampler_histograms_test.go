@@ -0,0 +1,199 @@
+package compactor
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/tsdbutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact/downsample"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	cortex_testutil "github.com/cortexproject/cortex/pkg/storage/tsdb/testutil"
+)
+
+func TestDownsampleHistograms(t *testing.T) {
+	const resolution = int64(100)
+
+	// Histograms with a different schema, to check the buckets are reconciled.
+	h1 := tsdbutil.GenerateTestFloatHistogram(1)
+	h2 := tsdbutil.GenerateTestFloatHistogram(2)
+	h2.Schema = h1.Schema - 1
+	h3 := tsdbutil.GenerateTestFloatHistogram(3)
+	gauge := tsdbutil.GenerateTestGaugeFloatHistogram(4)
+
+	actual := downsampleHistograms([]histogramSample{
+		{t: 10, fh: h1},
+		{t: 50, fh: h2},
+		{t: 150, fh: h3},
+		{t: 210, fh: gauge},
+		{t: 220, fh: gauge},
+	}, resolution)
+
+	expectedAvg := h1.Copy().Add(h2).Div(2).Compact(0)
+	expectedAvg.CounterResetHint = histogram.UnknownCounterReset
+
+	expectedH3 := h3.Copy()
+	expectedH3.CounterResetHint = histogram.UnknownCounterReset
+
+	require.Len(t, actual, 3)
+	assert.Equal(t, int64(99), actual[0].t)
+	assert.Equal(t, expectedAvg, actual[0].fh)
+	assert.Equal(t, h2.Schema, actual[0].fh.Schema)
+	assert.Equal(t, int64(199), actual[1].t)
+	assert.Equal(t, expectedH3.Compact(0), actual[1].fh)
+
+	// The timestamp of the last window is capped to the last sample.
+	assert.Equal(t, int64(220), actual[2].t)
+	assert.Equal(t, gauge.Copy().Compact(0), actual[2].fh)
+	assert.Equal(t, histogram.GaugeType, actual[2].fh.CounterResetHint)
+
+	// The input histograms should not be modified.
+	assert.Equal(t, tsdbutil.GenerateTestFloatHistogram(1), h1)
+
+	assert.Empty(t, downsampleHistograms(nil, resolution))
+}
+
+func TestDownsampleBlock_NativeHistograms(t *testing.T) {
+	bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	userBucket := bucket.NewUserBucketClient("user-1", bucketClient, nil)
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+
+	const blockRange = int64(2 * time.Hour / time.Millisecond)
+
+	floatSeries := labels.FromStrings(labels.MetricName, "float_series")
+	histogramSeries := labels.FromStrings(labels.MetricName, "histogram_series")
+
+	// Create a block with a float and a native histogram series, with a sample every minute for 20 minutes.
+	blockDir := t.TempDir()
+	w, err := tsdb.NewBlockWriter(logger, blockDir, blockRange)
+	require.NoError(t, err)
+
+	app := w.Appender(ctx)
+	for i := 0; i < 20; i++ {
+		ts := int64(i) * time.Minute.Milliseconds()
+		_, err := app.Append(0, floatSeries, ts, float64(i))
+		require.NoError(t, err)
+		_, err = app.AppendHistogram(0, histogramSeries, ts, tsdbutil.GenerateTestHistogram(i), nil)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+
+	blockID, err := w.Flush(ctx)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	_, err = metadata.InjectThanos(logger, filepath.Join(blockDir, blockID.String()), metadata.Thanos{
+		Labels: map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"},
+		Source: metadata.TestSource,
+	}, nil)
+	require.NoError(t, err)
+	require.NoError(t, block.Upload(ctx, logger, userBucket, filepath.Join(blockDir, blockID.String()), metadata.NoneFunc))
+
+	// Downsample the raw block to 5m, and the 5m block to 1h.
+	meta, err := block.DownloadMeta(ctx, logger, userBucket, blockID)
+	require.NoError(t, err)
+	require.NoError(t, downsampleBlock(ctx, logger, userBucket, &meta, downsample.ResLevel1, t.TempDir()))
+
+	meta5m := findDownsampledBlock(t, userBucket, downsample.ResLevel1)
+	assert.Equal(t, meta.Compaction, meta5m.Compaction)
+	assert.Equal(t, meta.MinTime, meta5m.MinTime)
+	assert.Equal(t, meta.MaxTime, meta5m.MaxTime)
+	assert.Equal(t, meta.Thanos.Labels, meta5m.Thanos.Labels)
+	assert.Equal(t, uint64(2), meta5m.Stats.NumSeries)
+
+	encodings, numSamples := readDownsampledBlockSeries(t, userBucket, meta5m.ULID)
+	assert.Equal(t, []chunkenc.Encoding{downsample.ChunkEncAggr}, encodings[floatSeries.String()])
+	assert.Equal(t, []chunkenc.Encoding{chunkenc.EncFloatHistogram}, encodings[histogramSeries.String()])
+	assert.Equal(t, 4, numSamples[histogramSeries.String()])
+
+	require.NoError(t, downsampleBlock(ctx, logger, userBucket, &meta5m, downsample.ResLevel2, t.TempDir()))
+
+	meta1h := findDownsampledBlock(t, userBucket, downsample.ResLevel2)
+	assert.Equal(t, uint64(2), meta1h.Stats.NumSeries)
+
+	encodings, numSamples = readDownsampledBlockSeries(t, userBucket, meta1h.ULID)
+	assert.Equal(t, []chunkenc.Encoding{downsample.ChunkEncAggr}, encodings[floatSeries.String()])
+	assert.Equal(t, []chunkenc.Encoding{chunkenc.EncFloatHistogram}, encodings[histogramSeries.String()])
+	assert.Equal(t, 1, numSamples[histogramSeries.String()])
+}
+
+func findDownsampledBlock(t *testing.T, bkt objstore.Bucket, resolution int64) metadata.Meta {
+	var result []metadata.Meta
+	require.NoError(t, bkt.Iter(context.Background(), "", func(name string) error {
+		id, ok := block.IsBlockDir(name)
+		if !ok {
+			return nil
+		}
+
+		m, err := block.DownloadMeta(context.Background(), log.NewNopLogger(), bkt, id)
+		if err == nil && m.Thanos.Downsample.Resolution == resolution {
+			result = append(result, m)
+		}
+		return err
+	}))
+
+	require.Len(t, result, 1)
+	return result[0]
+}
+
+// readDownsampledBlockSeries returns the chunk encodings of each series of the block, and the number
+// of samples of its native histogram chunks.
+func readDownsampledBlockSeries(t *testing.T, bkt objstore.Bucket, id ulid.ULID) (map[string][]chunkenc.Encoding, map[string]int) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), id.String())
+	require.NoError(t, block.Download(ctx, log.NewNopLogger(), bkt, id, dir))
+
+	b, err := tsdb.OpenBlock(log.NewNopLogger(), dir, downsample.NewPool())
+	require.NoError(t, err)
+	defer func() { require.NoError(t, b.Close()) }()
+
+	indexr, err := b.Index()
+	require.NoError(t, err)
+	defer func() { require.NoError(t, indexr.Close()) }()
+
+	chunkr, err := b.Chunks()
+	require.NoError(t, err)
+	defer func() { require.NoError(t, chunkr.Close()) }()
+
+	postings, err := indexr.Postings(ctx, "", "")
+	require.NoError(t, err)
+
+	var (
+		builder    labels.ScratchBuilder
+		chks       []chunks.Meta
+		encodings  = map[string][]chunkenc.Encoding{}
+		numSamples = map[string]int{}
+	)
+	for postings.Next() {
+		require.NoError(t, indexr.Series(postings.At(), &builder, &chks))
+		key := builder.Labels().String()
+
+		for _, chk := range chks {
+			c, _, err := chunkr.ChunkOrIterable(chk)
+			require.NoError(t, err)
+			encodings[key] = append(encodings[key], c.Encoding())
+			if c.Encoding() == chunkenc.EncFloatHistogram {
+				numSamples[key] += c.NumSamples()
+			}
+		}
+	}
+	require.NoError(t, postings.Err())
+
+	return encodings, numSamples
+}
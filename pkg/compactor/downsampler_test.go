@@ -0,0 +1,125 @@
+package compactor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact/downsample"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	cortex_testutil "github.com/cortexproject/cortex/pkg/storage/tsdb/testutil"
+)
+
+func TestBlocksToDownsample(t *testing.T) {
+	const day = int64(24 * time.Hour / time.Millisecond)
+
+	newMeta := func(id uint64, resolution, minT, maxT int64, sources ...uint64) *metadata.Meta {
+		m := &metadata.Meta{
+			BlockMeta: tsdb.BlockMeta{ULID: ulid.MustNew(id, nil), MinTime: minT, MaxTime: maxT},
+			Thanos:    metadata.Thanos{Downsample: metadata.ThanosDownsample{Resolution: resolution}},
+		}
+		for _, s := range sources {
+			m.Compaction.Sources = append(m.Compaction.Sources, ulid.MustNew(s, nil))
+		}
+		return m
+	}
+
+	tests := map[string]struct {
+		metas    []*metadata.Meta
+		from, to int64
+		expected []ulid.ULID
+	}{
+		"raw block spanning the min block range should be downsampled": {
+			metas:    []*metadata.Meta{newMeta(1, downsample.ResLevel0, 0, day, 1)},
+			from:     downsample.ResLevel0,
+			to:       downsample.ResLevel1,
+			expected: []ulid.ULID{ulid.MustNew(1, nil)},
+		},
+		"raw block shorter than the min block range should not be downsampled": {
+			metas: []*metadata.Meta{newMeta(1, downsample.ResLevel0, 0, day-1, 1)},
+			from:  downsample.ResLevel0,
+			to:    downsample.ResLevel1,
+		},
+		"raw block already downsampled should not be downsampled again": {
+			metas: []*metadata.Meta{
+				newMeta(1, downsample.ResLevel0, 0, day, 1, 2),
+				newMeta(3, downsample.ResLevel1, 0, day, 1, 2),
+			},
+			from: downsample.ResLevel0,
+			to:   downsample.ResLevel1,
+		},
+		"raw block partially downsampled should be downsampled": {
+			metas: []*metadata.Meta{
+				newMeta(1, downsample.ResLevel0, 0, day, 1, 2),
+				newMeta(3, downsample.ResLevel1, 0, day, 1),
+			},
+			from:     downsample.ResLevel0,
+			to:       downsample.ResLevel1,
+			expected: []ulid.ULID{ulid.MustNew(1, nil)},
+		},
+		"5m block should be downsampled to 1h": {
+			metas: []*metadata.Meta{
+				newMeta(1, downsample.ResLevel0, 0, day, 1),
+				newMeta(2, downsample.ResLevel1, 0, day, 1),
+			},
+			from:     downsample.ResLevel1,
+			to:       downsample.ResLevel2,
+			expected: []ulid.ULID{ulid.MustNew(2, nil)},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			metas := map[ulid.ULID]*metadata.Meta{}
+			for _, m := range testData.metas {
+				metas[m.ULID] = m
+			}
+
+			var actual []ulid.ULID
+			for _, m := range blocksToDownsample(metas, testData.from, testData.to, day) {
+				actual = append(actual, m.ULID)
+			}
+			assert.ElementsMatch(t, testData.expected, actual)
+		})
+	}
+}
+
+func TestDownsampleBlock(t *testing.T) {
+	bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+
+	blockID := createTSDBBlock(t, bucketClient, "user-1", 0, int64(2*time.Hour/time.Millisecond), map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"})
+	userBucket := bucket.NewUserBucketClient("user-1", bucketClient, nil)
+
+	meta, err := block.DownloadMeta(ctx, logger, userBucket, blockID)
+	require.NoError(t, err)
+	require.NoError(t, downsampleBlock(ctx, logger, userBucket, &meta, downsample.ResLevel1, t.TempDir()))
+
+	var downsampled []metadata.Meta
+	require.NoError(t, userBucket.Iter(ctx, "", func(name string) error {
+		id, ok := block.IsBlockDir(name)
+		if !ok || id == blockID {
+			return nil
+		}
+
+		m, err := block.DownloadMeta(ctx, logger, userBucket, id)
+		downsampled = append(downsampled, m)
+		return err
+	}))
+
+	require.Len(t, downsampled, 1)
+	assert.Equal(t, downsample.ResLevel1, downsampled[0].Thanos.Downsample.Resolution)
+	assert.Equal(t, meta.Compaction.Sources, downsampled[0].Compaction.Sources)
+	assert.Equal(t, meta.MinTime, downsampled[0].MinTime)
+	assert.Equal(t, meta.MaxTime, downsampled[0].MaxTime)
+}
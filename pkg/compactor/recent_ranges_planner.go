@@ -0,0 +1,145 @@
+package compactor
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/oklog/ulid"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact"
+)
+
+// isRecentRange returns whether a compaction range ending at rangeEnd is within the
+// recent ranges priority period.
+func isRecentRange(rangeEnd int64, period time.Duration, now time.Time) bool {
+	return period > 0 && rangeEnd > now.Add(-period).UnixMilli()
+}
+
+// RecentRangesPriorityPlanner is a compact.Planner which plans the compaction of the blocks
+// of the most recent time ranges into the second block range (eg. 2h blocks into 12h blocks)
+// before falling back to the wrapped planner to process the historical backlog. Within each
+// recent range, overlapping blocks are vertically compacted first.
+type RecentRangesPriorityPlanner struct {
+	compact.Planner
+
+	ranges           []int64
+	period           time.Duration
+	noCompBlocksFunc func() map[ulid.ULID]*metadata.NoCompactMark
+	now              func() time.Time
+}
+
+func NewRecentRangesPriorityPlanner(planner compact.Planner, ranges []int64, period time.Duration, noCompBlocksFunc func() map[ulid.ULID]*metadata.NoCompactMark) *RecentRangesPriorityPlanner {
+	return &RecentRangesPriorityPlanner{
+		Planner:          planner,
+		ranges:           ranges,
+		period:           period,
+		noCompBlocksFunc: noCompBlocksFunc,
+		now:              time.Now,
+	}
+}
+
+func (p *RecentRangesPriorityPlanner) Plan(ctx context.Context, metasByMinTime []*metadata.Meta, errChan chan error, extensions any) ([]*metadata.Meta, error) {
+	if res := p.planRecentRanges(metasByMinTime); len(res) > 0 {
+		return res, nil
+	}
+
+	return p.Planner.Plan(ctx, metasByMinTime, errChan, extensions)
+}
+
+func (p *RecentRangesPriorityPlanner) planRecentRanges(metasByMinTime []*metadata.Meta) []*metadata.Meta {
+	if len(p.ranges) < 2 || len(metasByMinTime) < 2 {
+		return nil
+	}
+
+	var (
+		tr              = p.ranges[1]
+		now             = p.now()
+		noCompactMarked = p.noCompBlocksFunc()
+		highTime        = metasByMinTime[len(metasByMinTime)-1].MinTime
+		windows         = map[int64][]*metadata.Meta{}
+	)
+
+	// Group the blocks smaller than the range by the recent range they fit in.
+	for _, m := range metasByMinTime {
+		if m.MaxTime-m.MinTime >= tr {
+			continue
+		}
+		if _, excluded := noCompactMarked[m.ULID]; excluded {
+			continue
+		}
+
+		start := getRangeStart(m, tr)
+		if m.MaxTime > start+tr || !isRecentRange(start+tr, p.period, now) {
+			continue
+		}
+		windows[start] = append(windows[start], m)
+	}
+
+	starts := make([]int64, 0, len(windows))
+	for start := range windows {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		return starts[i] > starts[j]
+	})
+
+nextWindow:
+	for _, start := range starts {
+		blocks := windows[start]
+		if len(blocks) < 2 {
+			continue
+		}
+		for _, m := range blocks {
+			if m.Compaction.Failed {
+				continue nextWindow
+			}
+		}
+
+		if overlapping := overlappingMetas(blocks); len(overlapping) > 0 {
+			return overlapping
+		}
+
+		// Like the Thanos planner, we don't compact the most recent block, and we compact a range only
+		// if it's fully covered or before the most recent block, so that we don't compact the blocks
+		// prematurely when another one still fits in the range after upload.
+		if blocks[len(blocks)-1].MinTime == highTime {
+			blocks = blocks[:len(blocks)-1]
+		}
+		if len(blocks) < 2 {
+			continue
+		}
+
+		if maxt := blocks[len(blocks)-1].MaxTime; maxt-blocks[0].MinTime != tr && maxt > highTime {
+			continue
+		}
+
+		return blocks
+	}
+
+	return nil
+}
+
+// overlappingMetas returns the first set of blocks with overlapping time ranges. It expects the
+// input sorted by min time and returns the blocks in the same order.
+func overlappingMetas(metasByMinTime []*metadata.Meta) []*metadata.Meta {
+	var result []*metadata.Meta
+
+	maxt := metasByMinTime[0].MaxTime
+	for i, m := range metasByMinTime[1:] {
+		if m.MinTime < maxt {
+			if len(result) == 0 {
+				result = append(result, metasByMinTime[i])
+			}
+			result = append(result, m)
+		} else if len(result) > 0 {
+			break
+		}
+
+		if m.MaxTime > maxt {
+			maxt = m.MaxTime
+		}
+	}
+
+	return result
+}
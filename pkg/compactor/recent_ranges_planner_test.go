@@ -0,0 +1,105 @@
+package compactor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+)
+
+func TestRecentRangesPriorityPlanner_Plan(t *testing.T) {
+	ranges := []int64{
+		2 * time.Hour.Milliseconds(),
+		12 * time.Hour.Milliseconds(),
+		24 * time.Hour.Milliseconds(),
+	}
+	now := time.UnixMilli(48 * time.Hour.Milliseconds())
+
+	newMeta := func(id uint64, minHour, maxHour int64) *metadata.Meta {
+		return &metadata.Meta{BlockMeta: tsdb.BlockMeta{
+			ULID:    ulid.MustNew(id, nil),
+			MinTime: minHour * time.Hour.Milliseconds(),
+			MaxTime: maxHour * time.Hour.Milliseconds(),
+		}}
+	}
+	failed := func(m *metadata.Meta) *metadata.Meta {
+		m.Compaction.Failed = true
+		return m
+	}
+
+	tests := map[string]struct {
+		blocks        []*metadata.Meta
+		noCompact     []uint64
+		expected      []uint64
+		expectedInner bool
+	}{
+		"should fall back to the wrapped planner if there are no recent ranges to compact": {
+			blocks:        []*metadata.Meta{newMeta(1, 0, 2), newMeta(2, 2, 4), newMeta(3, 4, 6)},
+			expectedInner: true,
+		},
+		"should compact the most recent range first": {
+			blocks:   []*metadata.Meta{newMeta(1, 24, 26), newMeta(2, 26, 28), newMeta(3, 36, 38), newMeta(4, 38, 40), newMeta(5, 40, 42)},
+			expected: []uint64{3, 4},
+		},
+		"should compact the overlapping blocks of a recent range first, including the most recent block": {
+			blocks:   []*metadata.Meta{newMeta(1, 36, 38), newMeta(2, 38, 40), newMeta(3, 39, 41)},
+			expected: []uint64{2, 3},
+		},
+		"should not compact a recent range not fully covered including only the most recent block": {
+			blocks:        []*metadata.Meta{newMeta(1, 36, 38)},
+			expectedInner: true,
+		},
+		"should not compact the blocks marked for no compaction": {
+			blocks:    []*metadata.Meta{newMeta(1, 24, 26), newMeta(2, 26, 28), newMeta(3, 36, 38), newMeta(4, 38, 40), newMeta(5, 40, 42)},
+			noCompact: []uint64{3},
+			expected:  []uint64{1, 2},
+		},
+		"should skip a recent range with a failed compaction": {
+			blocks:   []*metadata.Meta{newMeta(1, 24, 26), newMeta(2, 26, 28), failed(newMeta(3, 36, 38)), newMeta(4, 38, 40), newMeta(5, 40, 42)},
+			expected: []uint64{1, 2},
+		},
+		"should not compact blocks of a range not ending within the recent ranges priority period": {
+			blocks:        []*metadata.Meta{newMeta(1, 12, 14), newMeta(2, 14, 16), newMeta(3, 36, 38)},
+			expectedInner: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			noCompactMarks := map[ulid.ULID]*metadata.NoCompactMark{}
+			for _, id := range testData.noCompact {
+				noCompactMarks[ulid.MustNew(id, nil)] = &metadata.NoCompactMark{ID: ulid.MustNew(id, nil)}
+			}
+
+			inner := &tsdbPlannerMock{}
+			inner.On("Plan", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*metadata.Meta{}, nil)
+
+			p := NewRecentRangesPriorityPlanner(inner, ranges, 24*time.Hour, func() map[ulid.ULID]*metadata.NoCompactMark {
+				return noCompactMarks
+			})
+			p.now = func() time.Time { return now }
+
+			actual, err := p.Plan(context.Background(), testData.blocks, nil, nil)
+			require.NoError(t, err)
+
+			if testData.expectedInner {
+				inner.AssertNumberOfCalls(t, "Plan", 1)
+				assert.Empty(t, actual)
+				return
+			}
+
+			inner.AssertNotCalled(t, "Plan", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			actualIDs := make([]uint64, 0, len(actual))
+			for _, m := range actual {
+				actualIDs = append(actualIDs, m.ULID.Time())
+			}
+			assert.Equal(t, testData.expected, actualIDs)
+		})
+	}
+}
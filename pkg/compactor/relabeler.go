@@ -0,0 +1,416 @@
+package compactor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/index"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/runutil"
+	"gopkg.in/yaml.v2"
+)
+
+// blockExtensions holds the Cortex specific information stored in the Thanos section of the block meta.
+type blockExtensions struct {
+	// RelabelConfigsHash is the hash of the relabel configs applied to the block by the compactor.
+	RelabelConfigsHash string `json:"relabel_configs_hash,omitempty"`
+
+	// RollupSourceBlock is the ID of the raw block the rollup block has been generated from.
+	RollupSourceBlock string `json:"rollup_source_block,omitempty"`
+}
+
+// relabelUserBlocks rewrites the fully compacted raw blocks of a user applying the tenant relabel
+// configs, and marks the original blocks for deletion. A block is rewritten only once for a given
+// set of relabel configs: if the configs change, the blocks are rewritten again applying the new ones.
+// Downsampled blocks generated from a rewritten block are marked for deletion too, so that they get
+// downsampled again from the rewritten block.
+func (c *Compactor) relabelUserBlocks(ctx context.Context, logger log.Logger, bkt objstore.Bucket, fetcher block.MetadataFetcher, deletionMarks map[ulid.ULID]*metadata.DeletionMark, configs []*relabel.Config, dir string) error {
+	metas, _, err := fetcher.Fetch(ctx)
+	if err != nil {
+		return errors.Wrap(err, "fetch blocks metadata")
+	}
+
+	hash, err := relabelConfigsHash(configs)
+	if err != nil {
+		return err
+	}
+
+	minBlockRange := c.compactorCfg.BlockRanges.ToMilliseconds()[len(c.compactorCfg.BlockRanges)-1]
+
+	for _, meta := range blocksToRelabel(metas, deletionMarks, hash, minBlockRange) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if _, err := relabelBlock(ctx, logger, bkt, meta, configs, hash, dir); err != nil {
+			return errors.Wrapf(err, "relabel block %s", meta.ULID)
+		}
+		c.blocksRelabeled.Inc()
+
+		if err := block.MarkForDeletion(ctx, logger, bkt, meta.ULID, "block rewritten applying relabel configs", c.blocksMarkedForRelabeling); err != nil {
+			return errors.Wrapf(err, "mark block %s for deletion", meta.ULID)
+		}
+
+		for _, downsampled := range downsampledBlocksOf(metas, deletionMarks, meta) {
+			if err := block.MarkForDeletion(ctx, logger, bkt, downsampled.ULID, fmt.Sprintf("downsampled block of %s rewritten applying relabel configs", meta.ULID), c.blocksMarkedForRelabeling); err != nil {
+				return errors.Wrapf(err, "mark downsampled block %s for deletion", downsampled.ULID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// blocksToRelabel returns the raw blocks spanning at least minBlockRange which have not been rewritten
+// with the relabel configs matching the input hash yet, excluding the blocks marked for deletion.
+func blocksToRelabel(metas map[ulid.ULID]*metadata.Meta, deletionMarks map[ulid.ULID]*metadata.DeletionMark, hash string, minBlockRange int64) []*metadata.Meta {
+	var result []*metadata.Meta
+	for id, m := range metas {
+		if _, ok := deletionMarks[id]; ok {
+			continue
+		}
+		if m.Thanos.Downsample.Resolution != 0 || m.MaxTime-m.MinTime < minBlockRange {
+			continue
+		}
+
+		ext := blockExtensions{}
+		if _, err := m.Thanos.ParseExtensions(&ext); err == nil && ext.RelabelConfigsHash == hash {
+			continue
+		}
+
+		result = append(result, m)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].MinTime < result[j].MinTime
+	})
+
+	return result
+}
+
+// downsampledBlocksOf returns the downsampled blocks whose sources overlap the sources of the input block.
+func downsampledBlocksOf(metas map[ulid.ULID]*metadata.Meta, deletionMarks map[ulid.ULID]*metadata.DeletionMark, raw *metadata.Meta) []*metadata.Meta {
+	sources := map[ulid.ULID]struct{}{}
+	for _, id := range raw.Compaction.Sources {
+		sources[id] = struct{}{}
+	}
+
+	var result []*metadata.Meta
+	for id, m := range metas {
+		if _, ok := deletionMarks[id]; ok || m.Thanos.Downsample.Resolution == 0 {
+			continue
+		}
+
+		for _, source := range m.Compaction.Sources {
+			if _, ok := sources[source]; ok {
+				result = append(result, m)
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+// relabelBlock downloads the block, rewrites its series applying the relabel configs and uploads the new
+// block. Series dropped by the relabel configs are removed, while series which end up having the same
+// labels are merged, summing the samples with the same timestamp. The series are streamed to the new block:
+// only their labels are kept in memory, and the chunks of the series not merged with other ones are copied
+// as they are. It returns the ID of the new block, or a zero ID if all the series have been dropped and no
+// block has been uploaded.
+func relabelBlock(ctx context.Context, logger log.Logger, bkt objstore.Bucket, meta *metadata.Meta, configs []*relabel.Config, hash, dir string) (ulid.ULID, error) {
+	blockDir := filepath.Join(dir, meta.ULID.String())
+	defer func() {
+		if err := os.RemoveAll(blockDir); err != nil {
+			level.Warn(logger).Log("msg", "failed to remove downloaded block", "block", meta.ULID, "err", err)
+		}
+	}()
+
+	if err := block.Download(ctx, logger, bkt, meta.ULID, blockDir); err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "download block")
+	}
+
+	b, err := tsdb.OpenBlock(logger, blockDir, chunkenc.NewPool())
+	if err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "open block")
+	}
+	defer runutil.CloseWithLogOnErr(logger, b, "relabeled block reader")
+
+	indexr, err := b.Index()
+	if err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "open index")
+	}
+	defer runutil.CloseWithLogOnErr(logger, indexr, "relabeled block index reader")
+
+	chunkr, err := b.Chunks()
+	if err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "open chunks")
+	}
+	defer runutil.CloseWithLogOnErr(logger, chunkr, "relabeled block chunks reader")
+
+	series, symbols, err := readRelabeledSeries(ctx, indexr, configs)
+	if err != nil {
+		return ulid.ULID{}, err
+	}
+
+	// All the series have been dropped, so there's nothing to write.
+	if len(series) == 0 {
+		return ulid.ULID{}, nil
+	}
+
+	w, err := newBlockSeriesWriter(ctx, logger, dir, symbols)
+	if err != nil {
+		return ulid.ULID{}, err
+	}
+	defer w.close()
+
+	var (
+		builder labels.ScratchBuilder
+		chks    []chunks.Meta
+		it      chunkenc.Iterator
+	)
+
+	for _, s := range series {
+		if err := ctx.Err(); err != nil {
+			return ulid.ULID{}, err
+		}
+
+		if len(s.refs) == 1 {
+			if err := indexr.Series(s.refs[0], &builder, &chks); err != nil {
+				return ulid.ULID{}, errors.Wrapf(err, "read series %s", s.labels)
+			}
+			for i := range chks {
+				if chks[i].Chunk, _, err = chunkr.ChunkOrIterable(chks[i]); err != nil {
+					return ulid.ULID{}, errors.Wrapf(err, "read chunk of series %s", s.labels)
+				}
+			}
+			if err := w.writeChunks(s.labels, chks); err != nil {
+				return ulid.ULID{}, err
+			}
+			continue
+		}
+
+		// The series merged together are aggregated sample by sample.
+		var samples []chunks.Sample
+		for _, ref := range s.refs {
+			if err := indexr.Series(ref, &builder, &chks); err != nil {
+				return ulid.ULID{}, errors.Wrapf(err, "read series %s", s.labels)
+			}
+			for _, chk := range chks {
+				c, _, err := chunkr.ChunkOrIterable(chk)
+				if err != nil {
+					return ulid.ULID{}, errors.Wrapf(err, "read chunk of series %s", s.labels)
+				}
+				if samples, err = appendChunkSamples(samples, c.Iterator(it)); err != nil {
+					return ulid.ULID{}, errors.Wrapf(err, "iterate chunk of series %s", s.labels)
+				}
+			}
+		}
+
+		if err := w.writeSeries(storage.NewListSeries(s.labels, sumSamplesByTimestamp(samples))); err != nil {
+			return ulid.ULID{}, err
+		}
+	}
+
+	// The applied relabel configs are tracked in the extensions, because they can't be safely
+	// round-tripped through the JSON meta file.
+	thanosMeta := meta.Thanos
+	thanosMeta.Source = metadata.BucketRewriteSource
+	thanosMeta.Rewrites = append(thanosMeta.Rewrites, metadata.Rewrite{Sources: meta.Compaction.Sources})
+	ext := blockExtensions{}
+	if _, err := meta.Thanos.ParseExtensions(&ext); err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "parse block extensions")
+	}
+	ext.RelabelConfigsHash = hash
+	thanosMeta.Extensions = ext
+
+	// Keep the time range and compaction level of the original block, so that the rewritten block
+	// is considered fully compacted like the original one.
+	newMeta, err := w.flush(metadata.Meta{
+		BlockMeta: tsdb.BlockMeta{
+			MinTime:    meta.MinTime,
+			MaxTime:    meta.MaxTime,
+			Compaction: tsdb.BlockMetaCompaction{Level: meta.Compaction.Level, Sources: []ulid.ULID{w.id}},
+		},
+		Thanos: thanosMeta,
+	})
+	if err != nil {
+		return ulid.ULID{}, err
+	}
+	if newMeta == nil {
+		return ulid.ULID{}, nil
+	}
+
+	if err := block.Upload(ctx, logger, bkt, w.dir, metadata.NoneFunc); err != nil {
+		return ulid.ULID{}, errors.Wrapf(err, "upload relabeled block %s", newMeta.ULID)
+	}
+
+	level.Info(logger).Log("msg", "relabeled block", "source", meta.ULID, "block", newMeta.ULID, "series", len(series))
+	return newMeta.ULID, nil
+}
+
+// relabeledSeries holds the references of the source series which have the same labels after relabeling.
+type relabeledSeries struct {
+	labels labels.Labels
+	refs   []storage.SeriesRef
+}
+
+// readRelabeledSeries reads the labels of all the series of the index, applying the relabel configs. It
+// returns the relabeled series sorted by labels, and the symbols of their labels.
+func readRelabeledSeries(ctx context.Context, indexr tsdb.IndexReader, configs []*relabel.Config) ([]*relabeledSeries, map[string]struct{}, error) {
+	key, value := index.AllPostingsKey()
+	postings, err := indexr.Postings(ctx, key, value)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "read postings")
+	}
+
+	var (
+		builder labels.ScratchBuilder
+		chks    []chunks.Meta
+		groups  = map[string]*relabeledSeries{}
+		symbols = map[string]struct{}{}
+	)
+
+	for postings.Next() {
+		if err := indexr.Series(postings.At(), &builder, &chks); err != nil {
+			return nil, nil, errors.Wrap(err, "read series")
+		}
+
+		lbls, keep := relabel.Process(builder.Labels(), configs...)
+		if !keep || lbls.IsEmpty() || len(chks) == 0 {
+			continue
+		}
+
+		key := lbls.String()
+		if g, ok := groups[key]; ok {
+			g.refs = append(g.refs, postings.At())
+			continue
+		}
+
+		groups[key] = &relabeledSeries{labels: lbls, refs: []storage.SeriesRef{postings.At()}}
+		lbls.Range(func(l labels.Label) {
+			symbols[l.Name] = struct{}{}
+			symbols[l.Value] = struct{}{}
+		})
+	}
+	if err := postings.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "iterate postings")
+	}
+
+	result := make([]*relabeledSeries, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return labels.Compare(result[i].labels, result[j].labels) < 0
+	})
+
+	return result, symbols, nil
+}
+
+// aggregatedSample is a float or float histogram sample.
+type aggregatedSample struct {
+	t  int64
+	f  float64
+	fh *histogram.FloatHistogram
+}
+
+func (s aggregatedSample) T() int64                      { return s.t }
+func (s aggregatedSample) F() float64                    { return s.f }
+func (s aggregatedSample) H() *histogram.Histogram       { return nil }
+func (s aggregatedSample) FH() *histogram.FloatHistogram { return s.fh }
+
+func (s aggregatedSample) Type() chunkenc.ValueType {
+	if s.fh != nil {
+		return chunkenc.ValFloatHistogram
+	}
+	return chunkenc.ValFloat
+}
+
+// appendChunkSamples appends the samples of the chunk iterator to the input ones. Integer histograms
+// are converted to float histograms, so that they can be summed.
+func appendChunkSamples(samples []chunks.Sample, it chunkenc.Iterator) ([]chunks.Sample, error) {
+	for typ := it.Next(); typ != chunkenc.ValNone; typ = it.Next() {
+		switch typ {
+		case chunkenc.ValFloat:
+			t, f := it.At()
+			samples = append(samples, aggregatedSample{t: t, f: f})
+		case chunkenc.ValHistogram, chunkenc.ValFloatHistogram:
+			t, fh := it.AtFloatHistogram(nil)
+			samples = append(samples, aggregatedSample{t: t, fh: fh})
+		}
+	}
+	return samples, it.Err()
+}
+
+// sumSamplesByTimestamp sorts the samples by timestamp, and sums the samples with the same timestamp.
+// Stale markers are ignored if there's any other sample with the same timestamp, and a float sample can't
+// be summed with a histogram sample, so the first one is kept.
+func sumSamplesByTimestamp(samples []chunks.Sample) []chunks.Sample {
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].T() < samples[j].T()
+	})
+
+	result := samples[:0]
+	for _, s := range samples {
+		if len(result) == 0 || result[len(result)-1].T() != s.T() {
+			result = append(result, s)
+			continue
+		}
+
+		last := result[len(result)-1].(aggregatedSample)
+		curr := s.(aggregatedSample)
+
+		switch {
+		case isStaleSample(curr):
+			// Nothing to sum.
+		case isStaleSample(last):
+			result[len(result)-1] = curr
+		case last.fh == nil && curr.fh == nil:
+			last.f += curr.f
+			result[len(result)-1] = last
+		case last.fh != nil && curr.fh != nil:
+			last.fh = last.fh.Copy().Add(curr.fh).Compact(0)
+			result[len(result)-1] = last
+		}
+	}
+
+	return result
+}
+
+func isStaleSample(s aggregatedSample) bool {
+	if s.fh != nil {
+		return value.IsStaleNaN(s.fh.Sum)
+	}
+	return value.IsStaleNaN(s.f)
+}
+
+// relabelConfigsHash returns a hash of the input relabel configs.
+func relabelConfigsHash(configs []*relabel.Config) (string, error) {
+	data, err := yaml.Marshal(configs)
+	if err != nil {
+		return "", errors.Wrap(err, "marshal relabel configs")
+	}
+
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
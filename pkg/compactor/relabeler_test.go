@@ -0,0 +1,252 @@
+package compactor
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact/downsample"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	cortex_testutil "github.com/cortexproject/cortex/pkg/storage/tsdb/testutil"
+)
+
+func TestBlocksToRelabel(t *testing.T) {
+	const (
+		hash          = "current"
+		minBlockRange = int64(100)
+	)
+
+	newMeta := func(id uint64, minT, maxT, resolution int64, relabelHash string) *metadata.Meta {
+		m := &metadata.Meta{}
+		m.ULID = ulid.MustNew(id, nil)
+		m.MinTime = minT
+		m.MaxTime = maxT
+		m.Thanos.Downsample.Resolution = resolution
+		if relabelHash != "" {
+			m.Thanos.Extensions = blockExtensions{RelabelConfigsHash: relabelHash}
+		}
+		return m
+	}
+
+	fullyCompacted := newMeta(1, 0, 100, 0, "")
+	notCompacted := newMeta(2, 100, 150, 0, "")
+	downsampled := newMeta(3, 0, 100, downsample.ResLevel1, "")
+	relabeled := newMeta(4, 200, 300, 0, hash)
+	relabeledWithPreviousConfigs := newMeta(5, 300, 400, 0, "previous")
+	markedForDeletion := newMeta(6, 400, 500, 0, "")
+
+	metas := map[ulid.ULID]*metadata.Meta{}
+	for _, m := range []*metadata.Meta{fullyCompacted, notCompacted, downsampled, relabeled, relabeledWithPreviousConfigs, markedForDeletion} {
+		metas[m.ULID] = m
+	}
+
+	deletionMarks := map[ulid.ULID]*metadata.DeletionMark{markedForDeletion.ULID: {ID: markedForDeletion.ULID}}
+
+	assert.Equal(t, []*metadata.Meta{fullyCompacted, relabeledWithPreviousConfigs}, blocksToRelabel(metas, deletionMarks, hash, minBlockRange))
+}
+
+func TestRelabelBlock(t *testing.T) {
+	for name, tc := range map[string]struct {
+		configs         string
+		expectedSeries  uint64
+		expectedSamples uint64
+		expectedDropped bool
+	}{
+		"rewrite a label value merging series": {
+			configs: `
+- source_labels: [series_id]
+  regex: "1"
+  target_label: series_id
+  replacement: "0"
+`,
+			expectedSeries:  1,
+			expectedSamples: 2,
+		},
+		"drop series": {
+			configs: `
+- source_labels: [series_id]
+  regex: "0"
+  action: drop
+`,
+			expectedSeries:  1,
+			expectedSamples: 1,
+		},
+		"drop a label merging series": {
+			configs: `
+- regex: series_id
+  action: labeldrop
+- target_label: job
+  replacement: test
+`,
+			expectedSeries:  1,
+			expectedSamples: 2,
+		},
+		"drop all series": {
+			configs: `
+- action: drop
+  regex: .*
+`,
+			expectedDropped: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+			ctx := context.Background()
+			logger := log.NewNopLogger()
+
+			var configs []*relabel.Config
+			require.NoError(t, yaml.Unmarshal([]byte(tc.configs), &configs))
+			hash, err := relabelConfigsHash(configs)
+			require.NoError(t, err)
+
+			blockID := createTSDBBlock(t, bucketClient, "user-1", 0, int64(2*time.Hour/time.Millisecond), map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"})
+			userBucket := bucket.NewUserBucketClient("user-1", bucketClient, nil)
+
+			meta, err := block.DownloadMeta(ctx, logger, userBucket, blockID)
+			require.NoError(t, err)
+
+			newID, err := relabelBlock(ctx, logger, userBucket, &meta, configs, hash, t.TempDir())
+			require.NoError(t, err)
+
+			if tc.expectedDropped {
+				assert.Equal(t, ulid.ULID{}, newID)
+				return
+			}
+
+			newMeta, err := block.DownloadMeta(ctx, logger, userBucket, newID)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedSeries, newMeta.Stats.NumSeries)
+			assert.Equal(t, tc.expectedSamples, newMeta.Stats.NumSamples)
+			assert.Equal(t, meta.MinTime, newMeta.MinTime)
+			assert.Equal(t, meta.MaxTime, newMeta.MaxTime)
+			assert.Equal(t, meta.Thanos.Labels, newMeta.Thanos.Labels)
+			assert.Equal(t, metadata.BucketRewriteSource, newMeta.Thanos.Source)
+			require.Len(t, newMeta.Thanos.Rewrites, 1)
+			assert.Equal(t, meta.Compaction.Sources, newMeta.Thanos.Rewrites[0].Sources)
+
+			// The rewritten block should not be relabeled again with the same configs.
+			metas := map[ulid.ULID]*metadata.Meta{newID: &newMeta}
+			assert.Empty(t, blocksToRelabel(metas, nil, hash, newMeta.MaxTime-newMeta.MinTime))
+		})
+	}
+}
+
+func TestRelabelBlock_ShouldSumTheSamplesOfTheMergedSeries(t *testing.T) {
+	bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	userBucket := bucket.NewUserBucketClient("user-1", bucketClient, nil)
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+
+	// Two series with samples at the same timestamps, and a sample at a timestamp of its own.
+	dir := t.TempDir()
+	w, err := tsdb.NewBlockWriter(logger, dir, 2*int64(time.Hour/time.Millisecond))
+	require.NoError(t, err)
+	app := w.Appender(ctx)
+	for ts := int64(0); ts < 10; ts++ {
+		_, err = app.Append(0, labels.FromStrings(labels.MetricName, "metric", "pod", "a"), ts, 1)
+		require.NoError(t, err)
+		_, err = app.Append(0, labels.FromStrings(labels.MetricName, "metric", "pod", "b"), ts, 2)
+		require.NoError(t, err)
+	}
+	_, err = app.Append(0, labels.FromStrings(labels.MetricName, "metric", "pod", "b"), 10, 5)
+	require.NoError(t, err)
+	_, err = app.Append(0, labels.FromStrings(labels.MetricName, "other"), 0, 7)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+
+	blockID, err := w.Flush(ctx)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	blockDir := filepath.Join(dir, blockID.String())
+	_, err = metadata.InjectThanos(logger, blockDir, metadata.Thanos{Labels: map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"}, Source: "test"}, nil)
+	require.NoError(t, err)
+	require.NoError(t, block.Upload(ctx, logger, userBucket, blockDir, metadata.NoneFunc))
+
+	meta, err := block.DownloadMeta(ctx, logger, userBucket, blockID)
+	require.NoError(t, err)
+
+	var configs []*relabel.Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+- regex: pod
+  action: labeldrop
+`), &configs))
+	hash, err := relabelConfigsHash(configs)
+	require.NoError(t, err)
+
+	newID, err := relabelBlock(ctx, logger, userBucket, &meta, configs, hash, t.TempDir())
+	require.NoError(t, err)
+
+	// Read the samples of the rewritten block.
+	newDir := filepath.Join(t.TempDir(), newID.String())
+	require.NoError(t, block.Download(ctx, logger, userBucket, newID, newDir))
+	b, err := tsdb.OpenBlock(logger, newDir, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, b.Close()) })
+
+	q, err := tsdb.NewBlockQuerier(b, meta.MinTime, meta.MaxTime)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, q.Close()) })
+
+	actual := map[string][]float64{}
+	set := q.Select(ctx, true, nil, labels.MustNewMatcher(labels.MatchRegexp, labels.MetricName, ".+"))
+	for set.Next() {
+		it := set.At().Iterator(nil)
+		for it.Next() != chunkenc.ValNone {
+			_, v := it.At()
+			actual[set.At().Labels().String()] = append(actual[set.At().Labels().String()], v)
+		}
+		require.NoError(t, it.Err())
+	}
+	require.NoError(t, set.Err())
+
+	assert.Equal(t, map[string][]float64{
+		`{__name__="metric"}`: {3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 5},
+		`{__name__="other"}`:  {7},
+	}, actual)
+}
+
+func TestSumSamplesByTimestamp(t *testing.T) {
+	fh := func(count float64) *histogram.FloatHistogram {
+		return &histogram.FloatHistogram{Count: count, Sum: count, Schema: 0}
+	}
+
+	samples := []chunks.Sample{
+		aggregatedSample{t: 2, f: 1},
+		aggregatedSample{t: 1, f: 1},
+		aggregatedSample{t: 2, f: 2},
+		aggregatedSample{t: 3, f: math.Float64frombits(value.StaleNaN)},
+		aggregatedSample{t: 3, f: 4},
+		aggregatedSample{t: 4, fh: fh(1)},
+		aggregatedSample{t: 4, fh: fh(2)},
+		aggregatedSample{t: 5, f: 1},
+		aggregatedSample{t: 5, fh: fh(1)},
+	}
+
+	assert.Equal(t, []chunks.Sample{
+		aggregatedSample{t: 1, f: 1},
+		aggregatedSample{t: 2, f: 3},
+		aggregatedSample{t: 3, f: 4},
+		aggregatedSample{t: 4, fh: fh(3)},
+		aggregatedSample{t: 5, f: 1},
+	}, sumSamplesByTimestamp(samples))
+}
@@ -0,0 +1,534 @@
+package compactor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/tombstones"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/runutil"
+
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+const (
+	// rollupMarkFilename is the name of the file uploaded to a raw block directory once
+	// the rollup rules have been evaluated over the block.
+	rollupMarkFilename = "rollup-mark.json"
+
+	// Options of the engine evaluating the rollup rules expressions over a single block.
+	rollupMaxSamples    = 50000000
+	rollupQueryTimeout  = time.Hour
+	rollupLookbackDelta = 5 * time.Minute
+)
+
+// rollupMark tracks the rollup rules applied to a raw block.
+type rollupMark struct {
+	// RulesHash is the hash of the rollup rules evaluated over the block.
+	RulesHash string `json:"rules_hash"`
+
+	// SourceSeriesDeleted holds the hashes of the rollup rules whose source series have
+	// been deleted from the block.
+	SourceSeriesDeleted []string `json:"source_series_deleted,omitempty"`
+}
+
+func newRollupEngine(logger log.Logger) *promql.Engine {
+	return promql.NewEngine(promql.EngineOpts{
+		Logger:        logger,
+		MaxSamples:    rollupMaxSamples,
+		Timeout:       rollupQueryTimeout,
+		LookbackDelta: rollupLookbackDelta,
+	})
+}
+
+// rollupUserBlocks evaluates the tenant rollup rules over the fully compacted raw blocks, uploading the
+// generated series to a new rollup block for each of them. The rollup blocks have the same time range
+// of the source blocks and an additional external label, so that they're compacted separately from the
+// raw blocks. When the rules change, the rollup blocks previously generated from a block are marked for
+// deletion once the new one has been uploaded. Once the rules have been evaluated over a block and its
+// grace period has expired, the source series selected by the rules configured to do so are deleted
+// rewriting the raw block.
+func (c *Compactor) rollupUserBlocks(ctx context.Context, logger log.Logger, bkt objstore.Bucket, fetcher block.MetadataFetcher, deletionMarkFilter *block.IgnoreDeletionMarkFilter, rules []validation.RollupRule, dir string) error {
+	metas, _, err := fetcher.Fetch(ctx)
+	if err != nil {
+		return errors.Wrap(err, "fetch blocks metadata")
+	}
+
+	// The deletion marks are read after fetching the metas, because the previous steps may have
+	// marked blocks for deletion.
+	deletionMarks := deletionMarkFilter.DeletionMarkBlocks()
+
+	hash := rollupRulesHash(rules)
+	maxBlockRange := c.compactorCfg.BlockRanges.ToMilliseconds()[len(c.compactorCfg.BlockRanges)-1]
+
+	for _, meta := range blocksToRollup(metas, deletionMarks, maxBlockRange) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		mark, err := readRollupMark(ctx, logger, bkt, meta.ULID)
+		if err != nil {
+			return errors.Wrapf(err, "read rollup mark of block %s", meta.ULID)
+		}
+
+		if mark.RulesHash != hash {
+			newID, err := rollupBlock(ctx, logger, c.rollupEngine, bkt, meta, previousBlocks(metas, deletionMarks, meta), rules, dir)
+			if err != nil {
+				return errors.Wrapf(err, "rollup block %s", meta.ULID)
+			}
+			c.blocksRolledUp.Inc()
+
+			// The series generated by the rules whose source series have been deleted can't be generated
+			// again, so the previous rollup blocks are kept and merged with the new one in that case.
+			if len(mark.SourceSeriesDeleted) == 0 {
+				for _, superseded := range supersededRollupBlocks(metas, deletionMarks, meta.ULID, newID) {
+					if err := block.MarkForDeletion(ctx, logger, bkt, superseded.ULID, fmt.Sprintf("rollup block of %s superseded by %s", meta.ULID, newID), c.blocksMarkedForRollup); err != nil {
+						return errors.Wrapf(err, "mark rollup block %s for deletion", superseded.ULID)
+					}
+
+					for _, downsampled := range downsampledBlocksOf(metas, deletionMarks, superseded) {
+						if err := block.MarkForDeletion(ctx, logger, bkt, downsampled.ULID, fmt.Sprintf("downsampled block of superseded rollup block %s", superseded.ULID), c.blocksMarkedForRollup); err != nil {
+							return errors.Wrapf(err, "mark downsampled block %s for deletion", downsampled.ULID)
+						}
+					}
+				}
+			}
+
+			mark.RulesHash = hash
+			if err := writeRollupMark(ctx, bkt, meta.ULID, mark); err != nil {
+				return errors.Wrapf(err, "write rollup mark of block %s", meta.ULID)
+			}
+		}
+
+		toDelete := rollupRulesWithSourceSeriesToDelete(rules, mark, meta, time.Now())
+		if len(toDelete) == 0 {
+			continue
+		}
+
+		newID, err := deleteRollupSourceSeries(ctx, logger, bkt, meta, toDelete, maxBlockRange, dir)
+		if err != nil {
+			return errors.Wrapf(err, "delete rollup source series from block %s", meta.ULID)
+		}
+
+		for _, r := range toDelete {
+			mark.SourceSeriesDeleted = append(mark.SourceSeriesDeleted, rollupRuleHash(r))
+		}
+
+		// The rules don't match any series in the block, so we just keep track they have been applied.
+		if newID == nil {
+			if err := writeRollupMark(ctx, bkt, meta.ULID, mark); err != nil {
+				return errors.Wrapf(err, "write rollup mark of block %s", meta.ULID)
+			}
+			continue
+		}
+
+		if *newID != (ulid.ULID{}) {
+			c.blocksRewrittenForRollup.Inc()
+			if err := writeRollupMark(ctx, bkt, *newID, mark); err != nil {
+				return errors.Wrapf(err, "write rollup mark of block %s", newID)
+			}
+		}
+
+		if err := block.MarkForDeletion(ctx, logger, bkt, meta.ULID, "block rewritten deleting the rollup rules source series", c.blocksMarkedForRollup); err != nil {
+			return errors.Wrapf(err, "mark block %s for deletion", meta.ULID)
+		}
+
+		// The downsampled blocks still contain the deleted series, so they're deleted and
+		// downsampled again from the rewritten block.
+		for _, downsampled := range downsampledBlocksOf(metas, deletionMarks, meta) {
+			if err := block.MarkForDeletion(ctx, logger, bkt, downsampled.ULID, fmt.Sprintf("downsampled block of %s rewritten deleting the rollup rules source series", meta.ULID), c.blocksMarkedForRollup); err != nil {
+				return errors.Wrapf(err, "mark downsampled block %s for deletion", downsampled.ULID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// blocksToRollup returns the raw blocks spanning at least minBlockRange, excluding the rollup blocks
+// and the blocks marked for deletion.
+func blocksToRollup(metas map[ulid.ULID]*metadata.Meta, deletionMarks map[ulid.ULID]*metadata.DeletionMark, minBlockRange int64) []*metadata.Meta {
+	var result []*metadata.Meta
+	for id, m := range metas {
+		if _, ok := deletionMarks[id]; ok {
+			continue
+		}
+		if _, ok := m.Thanos.Labels[cortex_tsdb.RollupExternalLabel]; ok {
+			continue
+		}
+		if m.Thanos.Downsample.Resolution != 0 || m.MaxTime-m.MinTime < minBlockRange {
+			continue
+		}
+
+		result = append(result, m)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].MinTime < result[j].MinTime
+	})
+
+	return result
+}
+
+// previousBlocks returns the raw blocks ending where the input block starts, excluding the rollup blocks
+// and the blocks marked for deletion.
+func previousBlocks(metas map[ulid.ULID]*metadata.Meta, deletionMarks map[ulid.ULID]*metadata.DeletionMark, meta *metadata.Meta) []*metadata.Meta {
+	var result []*metadata.Meta
+	for id, m := range metas {
+		if _, ok := deletionMarks[id]; ok {
+			continue
+		}
+		if _, ok := m.Thanos.Labels[cortex_tsdb.RollupExternalLabel]; ok {
+			continue
+		}
+		if m.Thanos.Downsample.Resolution != 0 || m.MaxTime != meta.MinTime {
+			continue
+		}
+
+		result = append(result, m)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ULID.Compare(result[j].ULID) < 0
+	})
+
+	return result
+}
+
+// supersededRollupBlocks returns the rollup blocks generated from the source block, other than the
+// current one, excluding the blocks marked for deletion.
+func supersededRollupBlocks(metas map[ulid.ULID]*metadata.Meta, deletionMarks map[ulid.ULID]*metadata.DeletionMark, source, current ulid.ULID) []*metadata.Meta {
+	var result []*metadata.Meta
+	for id, m := range metas {
+		if _, ok := deletionMarks[id]; ok || id == current {
+			continue
+		}
+		if _, ok := m.Thanos.Labels[cortex_tsdb.RollupExternalLabel]; !ok || m.Thanos.Downsample.Resolution != 0 {
+			continue
+		}
+
+		ext := blockExtensions{}
+		if _, err := m.Thanos.ParseExtensions(&ext); err == nil && ext.RollupSourceBlock == source.String() {
+			result = append(result, m)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ULID.Compare(result[j].ULID) < 0
+	})
+
+	return result
+}
+
+// rollupRulesWithSourceSeriesToDelete returns the rules whose source series should be deleted from
+// the block, because the block is older than their grace period and they've not been deleted yet.
+func rollupRulesWithSourceSeriesToDelete(rules []validation.RollupRule, mark *rollupMark, meta *metadata.Meta, now time.Time) []validation.RollupRule {
+	deleted := map[string]struct{}{}
+	for _, h := range mark.SourceSeriesDeleted {
+		deleted[h] = struct{}{}
+	}
+
+	var result []validation.RollupRule
+	for _, r := range rules {
+		if r.DeleteSourceSeriesAfter <= 0 || now.Sub(time.UnixMilli(meta.MaxTime)) < time.Duration(r.DeleteSourceSeriesAfter) {
+			continue
+		}
+		if _, ok := deleted[rollupRuleHash(r)]; ok {
+			continue
+		}
+
+		result = append(result, r)
+	}
+
+	return result
+}
+
+// rollupBlock downloads the block, evaluates the rollup rules over it and uploads the generated series
+// to a new rollup block. The previous blocks, ending where the block starts, are downloaded too, so that
+// the range selectors and the lookback delta at the beginning of the block see their samples. It returns
+// the ID of the new block, or a zero ID if the rules didn't generate any series and no block has been
+// uploaded.
+func rollupBlock(ctx context.Context, logger log.Logger, engine *promql.Engine, bkt objstore.Bucket, meta *metadata.Meta, previous []*metadata.Meta, rules []validation.RollupRule, dir string) (ulid.ULID, error) {
+	var blocks []tsdb.BlockReader
+	for _, m := range append([]*metadata.Meta{meta}, previous...) {
+		blockDir := filepath.Join(dir, m.ULID.String())
+		defer func(id ulid.ULID) {
+			if err := os.RemoveAll(blockDir); err != nil {
+				level.Warn(logger).Log("msg", "failed to remove downloaded block", "block", id, "err", err)
+			}
+		}(m.ULID)
+
+		if err := block.Download(ctx, logger, bkt, m.ULID, blockDir); err != nil {
+			return ulid.ULID{}, errors.Wrapf(err, "download block %s", m.ULID)
+		}
+
+		b, err := tsdb.OpenBlock(logger, blockDir, chunkenc.NewPool())
+		if err != nil {
+			return ulid.ULID{}, errors.Wrapf(err, "open block %s", m.ULID)
+		}
+		defer runutil.CloseWithLogOnErr(logger, b, "rollup block reader")
+
+		blocks = append(blocks, b)
+	}
+
+	queryable := storage.QueryableFunc(func(mint, maxt int64) (storage.Querier, error) {
+		queriers := make([]storage.Querier, 0, len(blocks))
+		for _, b := range blocks {
+			q, err := tsdb.NewBlockQuerier(b, mint, maxt)
+			if err != nil {
+				return nil, err
+			}
+			queriers = append(queriers, q)
+		}
+		return storage.NewMergeQuerier(queriers, nil, storage.ChainedSeriesMerge), nil
+	})
+
+	// The generated series are encoded into chunks as soon as each rule has been evaluated, so that
+	// they can be written to the block sorted by labels once all the rules have been evaluated.
+	var (
+		series  = map[string]*rollupSeries{}
+		symbols = map[string]struct{}{}
+	)
+
+	for _, r := range rules {
+		skipped, err := evaluateRollupRule(ctx, engine, queryable, r, meta.MinTime, meta.MaxTime, series, symbols)
+		if err != nil {
+			return ulid.ULID{}, errors.Wrapf(err, "evaluate rollup rule %s", r.Record)
+		}
+		if skipped > 0 {
+			level.Warn(logger).Log("msg", "rollup rule generated multiple series with the same labels, only the first one has been kept", "block", meta.ULID, "record", r.Record, "skipped", skipped)
+		}
+	}
+
+	if len(series) == 0 {
+		level.Info(logger).Log("msg", "rollup rules didn't generate any series", "source", meta.ULID)
+		return ulid.ULID{}, nil
+	}
+
+	sorted := make([]*rollupSeries, 0, len(series))
+	for _, s := range series {
+		sorted = append(sorted, s)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return labels.Compare(sorted[i].labels, sorted[j].labels) < 0
+	})
+
+	w, err := newBlockSeriesWriter(ctx, logger, dir, symbols)
+	if err != nil {
+		return ulid.ULID{}, err
+	}
+	defer w.close()
+
+	for _, s := range sorted {
+		if err := w.writeChunks(s.labels, s.chunks); err != nil {
+			return ulid.ULID{}, err
+		}
+	}
+
+	// The rollup block gets an additional external label, so that it's never compacted together
+	// with the raw blocks it has been generated from.
+	externalLabels := make(map[string]string, len(meta.Thanos.Labels)+1)
+	for name, value := range meta.Thanos.Labels {
+		externalLabels[name] = value
+	}
+	externalLabels[cortex_tsdb.RollupExternalLabel] = "true"
+
+	// Keep the time range and compaction level of the source block, so that the rollup block
+	// is considered fully compacted like the source one.
+	newMeta, err := w.flush(metadata.Meta{
+		BlockMeta: tsdb.BlockMeta{
+			MinTime:    meta.MinTime,
+			MaxTime:    meta.MaxTime,
+			Compaction: tsdb.BlockMetaCompaction{Level: meta.Compaction.Level, Sources: []ulid.ULID{w.id}},
+		},
+		Thanos: metadata.Thanos{
+			Labels:     externalLabels,
+			Source:     metadata.CompactorSource,
+			Extensions: blockExtensions{RollupSourceBlock: meta.ULID.String()},
+		},
+	})
+	if err != nil {
+		return ulid.ULID{}, err
+	}
+	if newMeta == nil {
+		level.Info(logger).Log("msg", "rollup rules didn't generate any series", "source", meta.ULID)
+		return ulid.ULID{}, nil
+	}
+
+	if err := block.Upload(ctx, logger, bkt, w.dir, metadata.NoneFunc); err != nil {
+		return ulid.ULID{}, errors.Wrapf(err, "upload rollup block %s", newMeta.ULID)
+	}
+
+	level.Info(logger).Log("msg", "rolled up block", "source", meta.ULID, "block", newMeta.ULID, "series", len(sorted))
+	return newMeta.ULID, nil
+}
+
+// rollupSeries holds the chunks of a series generated by a rollup rule.
+type rollupSeries struct {
+	labels labels.Labels
+	chunks []chunks.Meta
+}
+
+// evaluateRollupRule evaluates the rule expression as a range query over the block time range, with
+// a step equal to the rule interval, and adds the result renamed after the rule record to the input
+// series, adding their labels to the symbols. It returns the number of series skipped because they
+// had the same labels of another series once renamed.
+func evaluateRollupRule(ctx context.Context, engine *promql.Engine, queryable storage.Queryable, rule validation.RollupRule, minT, maxT int64, series map[string]*rollupSeries, symbols map[string]struct{}) (int, error) {
+	interval := time.Duration(rule.Interval)
+	step := interval.Milliseconds()
+
+	// Align the samples timestamps to the interval.
+	start := minT + (step-minT%step)%step
+	end := maxT - 1
+	if start > end {
+		return 0, nil
+	}
+
+	q, err := engine.NewRangeQuery(ctx, queryable, nil, rule.Expr, time.UnixMilli(start), time.UnixMilli(end), interval)
+	if err != nil {
+		return 0, err
+	}
+	defer q.Close()
+
+	res := q.Exec(ctx)
+	if res.Err != nil {
+		return 0, res.Err
+	}
+
+	matrix, err := res.Matrix()
+	if err != nil {
+		return 0, err
+	}
+
+	skipped := 0
+	for _, s := range matrix {
+		lbls := labels.NewBuilder(s.Metric).Set(labels.MetricName, rule.Record).Labels()
+		key := lbls.String()
+		if _, ok := series[key]; ok {
+			skipped++
+			continue
+		}
+
+		// Float and histogram samples are returned separately, so we merge them by timestamp.
+		samples := make([]chunks.Sample, 0, len(s.Floats)+len(s.Histograms))
+		floats, histograms := s.Floats, s.Histograms
+		for len(floats) > 0 || len(histograms) > 0 {
+			if len(histograms) == 0 || (len(floats) > 0 && floats[0].T < histograms[0].T) {
+				samples = append(samples, aggregatedSample{t: floats[0].T, f: floats[0].F})
+				floats = floats[1:]
+			} else {
+				samples = append(samples, aggregatedSample{t: histograms[0].T, fh: histograms[0].H})
+				histograms = histograms[1:]
+			}
+		}
+
+		chks, err := encodeSeriesChunks(storage.NewListSeries(lbls, samples))
+		if err != nil {
+			return 0, err
+		}
+
+		series[key] = &rollupSeries{labels: lbls, chunks: chks}
+		lbls.Range(func(l labels.Label) {
+			symbols[l.Name] = struct{}{}
+			symbols[l.Value] = struct{}{}
+		})
+	}
+
+	return skipped, nil
+}
+
+// deleteRollupSourceSeries rewrites the block deleting the series selected by the rules expressions.
+// It returns a nil ID if the rules don't match any series in the block, or a zero ID if all the series
+// of the block have been deleted.
+func deleteRollupSourceSeries(ctx context.Context, logger log.Logger, bkt objstore.Bucket, meta *metadata.Meta, rules []validation.RollupRule, maxBlockRange int64, dir string) (*ulid.ULID, error) {
+	var deletions []metadata.DeletionRequest
+	for _, r := range rules {
+		expr, err := parser.ParseExpr(r.Expr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse rollup rule %s expression", r.Record)
+		}
+
+		for _, matchers := range parser.ExtractSelectors(expr) {
+			deletions = append(deletions, metadata.DeletionRequest{
+				Matchers:  matchers,
+				Intervals: tombstones.Intervals{{Mint: meta.MinTime, Maxt: meta.MaxTime - 1}},
+				RequestID: "rollup:" + r.Record,
+			})
+		}
+	}
+
+	newID, err := rewriteBlockDeletingSeries(ctx, logger, bkt, meta.ULID, deletions, maxBlockRange, dir)
+	if err != nil {
+		return nil, err
+	}
+	if newID != nil && *newID != (ulid.ULID{}) {
+		level.Info(logger).Log("msg", "rewritten block deleting the rollup rules source series", "block", meta.ULID, "new_block", newID)
+	}
+
+	return newID, nil
+}
+
+func readRollupMark(ctx context.Context, logger log.Logger, bkt objstore.Bucket, blockID ulid.ULID) (*rollupMark, error) {
+	r, err := bkt.Get(ctx, path.Join(blockID.String(), rollupMarkFilename))
+	if err != nil {
+		if bkt.IsObjNotFoundErr(err) {
+			return &rollupMark{}, nil
+		}
+		return nil, err
+	}
+	defer runutil.CloseWithLogOnErr(logger, r, "close rollup mark reader")
+
+	mark := &rollupMark{}
+	if err := json.NewDecoder(r).Decode(mark); err != nil {
+		return nil, errors.Wrap(err, "decode rollup mark")
+	}
+	return mark, nil
+}
+
+func writeRollupMark(ctx context.Context, bkt objstore.Bucket, blockID ulid.ULID, mark *rollupMark) error {
+	data, err := json.Marshal(mark)
+	if err != nil {
+		return err
+	}
+
+	return bkt.Upload(ctx, path.Join(blockID.String(), rollupMarkFilename), bytes.NewReader(data))
+}
+
+// rollupRuleHash returns a hash of the rule record, expression and interval. The source series
+// deletion period is not included, because changing it doesn't change the generated series.
+func rollupRuleHash(r validation.RollupRule) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d", r.Record, r.Expr, r.Interval)))
+	return hex.EncodeToString(hash[:])
+}
+
+// rollupRulesHash returns a hash of the input rules.
+func rollupRulesHash(rules []validation.RollupRule) string {
+	h := sha256.New()
+	for _, r := range rules {
+		_, _ = h.Write([]byte(rollupRuleHash(r)))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
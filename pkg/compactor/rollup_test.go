@@ -0,0 +1,310 @@
+package compactor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact/downsample"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	cortex_testutil "github.com/cortexproject/cortex/pkg/storage/tsdb/testutil"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+func TestBlocksToRollup(t *testing.T) {
+	const minBlockRange = int64(100)
+
+	newMeta := func(id uint64, minT, maxT, resolution int64, rollup bool) *metadata.Meta {
+		m := &metadata.Meta{}
+		m.ULID = ulid.MustNew(id, nil)
+		m.MinTime = minT
+		m.MaxTime = maxT
+		m.Thanos.Downsample.Resolution = resolution
+		m.Thanos.Labels = map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"}
+		if rollup {
+			m.Thanos.Labels[cortex_tsdb.RollupExternalLabel] = "true"
+		}
+		return m
+	}
+
+	fullyCompacted1 := newMeta(1, 100, 200, 0, false)
+	fullyCompacted2 := newMeta(2, 0, 100, 0, false)
+	notCompacted := newMeta(3, 200, 250, 0, false)
+	downsampled := newMeta(4, 0, 100, downsample.ResLevel1, false)
+	rollup := newMeta(5, 0, 100, 0, true)
+	markedForDeletion := newMeta(6, 300, 400, 0, false)
+
+	metas := map[ulid.ULID]*metadata.Meta{}
+	for _, m := range []*metadata.Meta{fullyCompacted1, fullyCompacted2, notCompacted, downsampled, rollup, markedForDeletion} {
+		metas[m.ULID] = m
+	}
+
+	deletionMarks := map[ulid.ULID]*metadata.DeletionMark{markedForDeletion.ULID: {ID: markedForDeletion.ULID}}
+
+	assert.Equal(t, []*metadata.Meta{fullyCompacted2, fullyCompacted1}, blocksToRollup(metas, deletionMarks, minBlockRange))
+}
+
+func TestRollupRulesWithSourceSeriesToDelete(t *testing.T) {
+	now := time.Now()
+	meta := &metadata.Meta{}
+	meta.MaxTime = now.Add(-2 * time.Hour).UnixMilli()
+
+	keep := validation.RollupRule{Record: "keep", Expr: "sum(a)", Interval: model.Duration(time.Minute)}
+	expired := validation.RollupRule{Record: "expired", Expr: "sum(b)", Interval: model.Duration(time.Minute), DeleteSourceSeriesAfter: model.Duration(time.Hour)}
+	notExpired := validation.RollupRule{Record: "not_expired", Expr: "sum(c)", Interval: model.Duration(time.Minute), DeleteSourceSeriesAfter: model.Duration(3 * time.Hour)}
+	alreadyDeleted := validation.RollupRule{Record: "already_deleted", Expr: "sum(d)", Interval: model.Duration(time.Minute), DeleteSourceSeriesAfter: model.Duration(time.Hour)}
+
+	mark := &rollupMark{SourceSeriesDeleted: []string{rollupRuleHash(alreadyDeleted)}}
+	rules := []validation.RollupRule{keep, expired, notExpired, alreadyDeleted}
+
+	assert.Equal(t, []validation.RollupRule{expired}, rollupRulesWithSourceSeriesToDelete(rules, mark, meta, now))
+}
+
+func TestRollupBlock(t *testing.T) {
+	for name, tc := range map[string]struct {
+		rules           []validation.RollupRule
+		expectedSeries  uint64
+		expectedSamples uint64
+	}{
+		"single rule": {
+			rules: []validation.RollupRule{
+				{Record: "series:count", Expr: `count({series_id=~".+"})`, Interval: model.Duration(time.Hour)},
+			},
+			// The block has a sample at the beginning and one at the end of its range, so only
+			// the first step finds a sample within the lookback period.
+			expectedSeries:  1,
+			expectedSamples: 1,
+		},
+		"multiple rules": {
+			rules: []validation.RollupRule{
+				{Record: "series:count", Expr: `count({series_id=~".+"})`, Interval: model.Duration(time.Hour)},
+				{Record: "series:count_over_time", Expr: `count_over_time({series_id=~".+"}[3h])`, Interval: model.Duration(30 * time.Minute)},
+			},
+			// The series with the sample at the end of the block range is not selected by any step.
+			expectedSeries:  2,
+			expectedSamples: 1 + 4,
+		},
+		"no series generated": {
+			rules: []validation.RollupRule{
+				{Record: "missing:count", Expr: `count(missing)`, Interval: model.Duration(time.Hour)},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+			ctx := context.Background()
+			logger := log.NewNopLogger()
+
+			blockID := createTSDBBlock(t, bucketClient, "user-1", 0, int64(2*time.Hour/time.Millisecond), map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"})
+			userBucket := bucket.NewUserBucketClient("user-1", bucketClient, nil)
+
+			meta, err := block.DownloadMeta(ctx, logger, userBucket, blockID)
+			require.NoError(t, err)
+
+			newID, err := rollupBlock(ctx, logger, newRollupEngine(logger), userBucket, &meta, nil, tc.rules, t.TempDir())
+			require.NoError(t, err)
+
+			if tc.expectedSeries == 0 {
+				assert.Equal(t, ulid.ULID{}, newID)
+				return
+			}
+
+			newMeta, err := block.DownloadMeta(ctx, logger, userBucket, newID)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedSeries, newMeta.Stats.NumSeries)
+			assert.Equal(t, tc.expectedSamples, newMeta.Stats.NumSamples)
+			assert.Equal(t, meta.MinTime, newMeta.MinTime)
+			assert.Equal(t, meta.MaxTime, newMeta.MaxTime)
+			assert.Equal(t, map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1", cortex_tsdb.RollupExternalLabel: "true"}, newMeta.Thanos.Labels)
+
+			ext := blockExtensions{}
+			_, err = newMeta.Thanos.ParseExtensions(&ext)
+			require.NoError(t, err)
+			assert.Equal(t, blockID.String(), ext.RollupSourceBlock)
+
+			// The rollup block should not be rolled up in turn.
+			metas := map[ulid.ULID]*metadata.Meta{blockID: &meta, newID: &newMeta}
+			assert.Equal(t, []*metadata.Meta{&meta}, blocksToRollup(metas, nil, meta.MaxTime-meta.MinTime))
+		})
+	}
+}
+
+func TestRollupBlock_ShouldEvaluateRangeSelectorsOverThePreviousBlocks(t *testing.T) {
+	bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+	blockRange := 2 * time.Hour.Milliseconds()
+
+	previousID := createTSDBBlock(t, bucketClient, "user-1", 0, blockRange, map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"})
+	blockID := createTSDBBlock(t, bucketClient, "user-1", blockRange, 2*blockRange, map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"})
+	userBucket := bucket.NewUserBucketClient("user-1", bucketClient, nil)
+
+	previousMeta, err := block.DownloadMeta(ctx, logger, userBucket, previousID)
+	require.NoError(t, err)
+	meta, err := block.DownloadMeta(ctx, logger, userBucket, blockID)
+	require.NoError(t, err)
+
+	// The only sample of the series selected by the first step is the one at the end of the previous block.
+	rules := []validation.RollupRule{
+		{Record: "series:count_over_time", Expr: `count_over_time({series_id="1"}[1h])`, Interval: model.Duration(time.Hour)},
+	}
+
+	newID, err := rollupBlock(ctx, logger, newRollupEngine(logger), userBucket, &meta, nil, rules, t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, ulid.ULID{}, newID)
+
+	newID, err = rollupBlock(ctx, logger, newRollupEngine(logger), userBucket, &meta, []*metadata.Meta{&previousMeta}, rules, t.TempDir())
+	require.NoError(t, err)
+
+	newMeta, err := block.DownloadMeta(ctx, logger, userBucket, newID)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), newMeta.Stats.NumSeries)
+	assert.Equal(t, uint64(1), newMeta.Stats.NumSamples)
+	assert.Equal(t, meta.MinTime, newMeta.MinTime)
+	assert.Equal(t, meta.MaxTime, newMeta.MaxTime)
+}
+
+func TestPreviousBlocks(t *testing.T) {
+	newMeta := func(id uint64, minT, maxT, resolution int64, rollup bool) *metadata.Meta {
+		m := &metadata.Meta{}
+		m.ULID = ulid.MustNew(id, nil)
+		m.MinTime = minT
+		m.MaxTime = maxT
+		m.Thanos.Downsample.Resolution = resolution
+		m.Thanos.Labels = map[string]string{}
+		if rollup {
+			m.Thanos.Labels[cortex_tsdb.RollupExternalLabel] = "true"
+		}
+		return m
+	}
+
+	current := newMeta(1, 100, 200, 0, false)
+	previous := newMeta(2, 0, 100, 0, false)
+	overlapping := newMeta(3, 50, 150, 0, false)
+	downsampled := newMeta(4, 0, 100, downsample.ResLevel1, false)
+	rollup := newMeta(5, 0, 100, 0, true)
+	markedForDeletion := newMeta(6, 0, 100, 0, false)
+
+	metas := map[ulid.ULID]*metadata.Meta{}
+	for _, m := range []*metadata.Meta{current, previous, overlapping, downsampled, rollup, markedForDeletion} {
+		metas[m.ULID] = m
+	}
+
+	deletionMarks := map[ulid.ULID]*metadata.DeletionMark{markedForDeletion.ULID: {ID: markedForDeletion.ULID}}
+
+	assert.Equal(t, []*metadata.Meta{previous}, previousBlocks(metas, deletionMarks, current))
+}
+
+func TestSupersededRollupBlocks(t *testing.T) {
+	source := ulid.MustNew(1, nil)
+	otherSource := ulid.MustNew(2, nil)
+
+	newMeta := func(id uint64, source ulid.ULID, resolution int64) *metadata.Meta {
+		m := &metadata.Meta{}
+		m.ULID = ulid.MustNew(id, nil)
+		m.Thanos.Downsample.Resolution = resolution
+		m.Thanos.Labels = map[string]string{cortex_tsdb.RollupExternalLabel: "true"}
+		m.Thanos.Extensions = blockExtensions{RollupSourceBlock: source.String()}
+		return m
+	}
+
+	current := newMeta(3, source, 0)
+	superseded1 := newMeta(4, source, 0)
+	superseded2 := newMeta(5, source, 0)
+	otherSourceRollup := newMeta(6, otherSource, 0)
+	downsampled := newMeta(7, source, downsample.ResLevel1)
+	markedForDeletion := newMeta(8, source, 0)
+
+	metas := map[ulid.ULID]*metadata.Meta{}
+	for _, m := range []*metadata.Meta{current, superseded1, superseded2, otherSourceRollup, downsampled, markedForDeletion} {
+		metas[m.ULID] = m
+	}
+
+	deletionMarks := map[ulid.ULID]*metadata.DeletionMark{markedForDeletion.ULID: {ID: markedForDeletion.ULID}}
+
+	assert.Equal(t, []*metadata.Meta{superseded1, superseded2}, supersededRollupBlocks(metas, deletionMarks, source, current.ULID))
+}
+
+func TestDeleteRollupSourceSeries(t *testing.T) {
+	for name, tc := range map[string]struct {
+		expr            string
+		expectedNil     bool
+		expectedDropped bool
+		expectedSeries  uint64
+	}{
+		"delete some series": {
+			expr:           `count_over_time({series_id="0"}[1h])`,
+			expectedSeries: 1,
+		},
+		"delete all series": {
+			expr:            `count_over_time({series_id=~".+"}[1h])`,
+			expectedDropped: true,
+		},
+		"no series to delete": {
+			expr:        `count_over_time(missing[1h])`,
+			expectedNil: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+			ctx := context.Background()
+			logger := log.NewNopLogger()
+
+			blockID := createTSDBBlock(t, bucketClient, "user-1", 0, int64(2*time.Hour/time.Millisecond), map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"})
+			userBucket := bucket.NewUserBucketClient("user-1", bucketClient, nil)
+
+			meta, err := block.DownloadMeta(ctx, logger, userBucket, blockID)
+			require.NoError(t, err)
+
+			rules := []validation.RollupRule{{Record: "rollup", Expr: tc.expr, Interval: model.Duration(time.Hour), DeleteSourceSeriesAfter: model.Duration(time.Hour)}}
+			newID, err := deleteRollupSourceSeries(ctx, logger, userBucket, &meta, rules, 2*time.Hour.Milliseconds(), t.TempDir())
+			require.NoError(t, err)
+
+			if tc.expectedNil {
+				assert.Nil(t, newID)
+				return
+			}
+			require.NotNil(t, newID)
+			if tc.expectedDropped {
+				assert.Equal(t, ulid.ULID{}, *newID)
+				return
+			}
+
+			newMeta, err := block.DownloadMeta(ctx, logger, userBucket, *newID)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedSeries, newMeta.Stats.NumSeries)
+			assert.Equal(t, meta.Thanos.Labels, newMeta.Thanos.Labels)
+			assert.Equal(t, metadata.BucketRewriteSource, newMeta.Thanos.Source)
+		})
+	}
+}
+
+func TestRollupMark(t *testing.T) {
+	bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+	blockID := ulid.MustNew(1, nil)
+
+	// A missing mark should be returned as an empty one.
+	mark, err := readRollupMark(ctx, logger, bucketClient, blockID)
+	require.NoError(t, err)
+	assert.Equal(t, &rollupMark{}, mark)
+
+	expected := &rollupMark{RulesHash: "hash", SourceSeriesDeleted: []string{"rule"}}
+	require.NoError(t, writeRollupMark(ctx, bucketClient, blockID, expected))
+
+	mark, err = readRollupMark(ctx, logger, bucketClient, blockID)
+	require.NoError(t, err)
+	assert.Equal(t, expected, mark)
+}
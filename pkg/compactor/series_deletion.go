@@ -0,0 +1,279 @@
+package compactor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/tombstones"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/runutil"
+
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
+)
+
+// applyUserSeriesDeletion processes the series deletion tombstones of a user. Pending tombstones
+// whose cancellation period has expired are applied by rewriting the affected blocks, while
+// processed and cancelled tombstones are deleted once they're not needed anymore. Errors are logged
+// and the processing of the failed tombstone is retried on the next run.
+func (c *BlocksCleaner) applyUserSeriesDeletion(ctx context.Context, userID string, idx *bucketindex.Index, userBucket objstore.InstrumentedBucket, userLogger log.Logger) {
+	all, err := cortex_tsdb.GetAllTombstones(ctx, userBucket)
+	if err != nil {
+		level.Warn(userLogger).Log("msg", "failed to read series deletion tombstones", "err", err)
+		return
+	}
+
+	cancelPeriod := c.cfgProvider.DeleteRequestCancelPeriod(userID)
+
+	for _, t := range all {
+		if ctx.Err() != nil {
+			return
+		}
+
+		switch t.State {
+		case cortex_tsdb.StatePending:
+			if time.Since(t.GetRequestCreatedAt()) < cancelPeriod {
+				continue
+			}
+
+			if err := c.processTombstone(ctx, userID, t, idx, userBucket, userLogger); err != nil {
+				level.Warn(userLogger).Log("msg", "failed to process series deletion request", "request_id", t.RequestID, "err", err)
+			}
+
+		case cortex_tsdb.StateProcessed:
+			// The tombstone is kept until the rewritten blocks are deleted, so that queriers keep
+			// filtering out the deleted series from them in the meanwhile.
+			if time.Since(t.GetStateCreatedAt()) <= c.cfg.DeletionDelay {
+				continue
+			}
+
+			if err := cortex_tsdb.DeleteTombstone(ctx, userBucket, t.RequestID); err != nil {
+				level.Warn(userLogger).Log("msg", "failed to delete processed series deletion request", "request_id", t.RequestID, "err", err)
+			}
+
+		case cortex_tsdb.StateCancelled:
+			if time.Since(t.GetStateCreatedAt()) <= cancelPeriod {
+				continue
+			}
+
+			if err := cortex_tsdb.DeleteTombstone(ctx, userBucket, t.RequestID); err != nil {
+				level.Warn(userLogger).Log("msg", "failed to delete cancelled series deletion request", "request_id", t.RequestID, "err", err)
+			}
+		}
+	}
+}
+
+// processTombstone rewrites the raw blocks overlapping the tombstone time range, excluding the deleted
+// series, and marks the original blocks and the overlapping downsampled blocks for deletion. The
+// tombstone is processed only once all the blocks it affects have been fully compacted, otherwise
+// the compactor could merge deleted samples back into a new block.
+func (c *BlocksCleaner) processTombstone(ctx context.Context, userID string, t *cortex_tsdb.Tombstone, idx *bucketindex.Index, userBucket objstore.InstrumentedBucket, userLogger log.Logger) error {
+	selectors, err := t.GetMatchers()
+	if err != nil {
+		return errors.Wrap(err, "parse selectors")
+	}
+
+	rawBlocks, downsampledBlocks := listBlocksAffectedByTombstone(idx, t)
+	if !blocksFullyCompacted(idx, rawBlocks, c.cfg.MaxBlockRange.Milliseconds(), time.Now()) {
+		level.Debug(userLogger).Log("msg", "series deletion request is waiting for blocks to be fully compacted", "request_id", t.RequestID)
+		return nil
+	}
+
+	for _, b := range rawBlocks {
+		if err := c.rewriteBlockWithDeletion(ctx, userID, b.ID, t, selectors, userBucket, userLogger); err != nil {
+			return errors.Wrapf(err, "rewrite block %s", b.ID)
+		}
+	}
+
+	// Downsampled blocks are not rewritten. They're deleted and the downsampling of the
+	// rewritten raw blocks is left to the compactor.
+	for _, b := range downsampledBlocks {
+		if err := block.MarkForDeletion(ctx, userLogger, userBucket, b.ID, fmt.Sprintf("downsampled block affected by series deletion request %s", t.RequestID), c.blocksMarkedForSeriesDeletion); err != nil {
+			return errors.Wrapf(err, "mark downsampled block %s for deletion", b.ID)
+		}
+	}
+
+	if _, err := cortex_tsdb.UpdateTombstoneState(ctx, userBucket, t, cortex_tsdb.StateProcessed, time.Now()); err != nil {
+		return errors.Wrap(err, "update tombstone state")
+	}
+
+	level.Info(userLogger).Log("msg", "processed series deletion request", "request_id", t.RequestID, "rewritten_blocks", len(rawBlocks), "deleted_downsampled_blocks", len(downsampledBlocks))
+	return nil
+}
+
+// rewriteBlockWithDeletion rewrites the block excluding the samples deleted by the tombstone and
+// marks the original one for deletion.
+func (c *BlocksCleaner) rewriteBlockWithDeletion(ctx context.Context, userID string, blockID ulid.ULID, t *cortex_tsdb.Tombstone, selectors [][]*labels.Matcher, userBucket objstore.InstrumentedBucket, userLogger log.Logger) error {
+	deletions := make([]metadata.DeletionRequest, 0, len(selectors))
+	for _, matchers := range selectors {
+		deletions = append(deletions, metadata.DeletionRequest{
+			Matchers:  matchers,
+			Intervals: tombstones.Intervals{{Mint: t.StartTime, Maxt: t.EndTime}},
+			RequestID: t.RequestID,
+		})
+	}
+
+	newID, err := rewriteBlockDeletingSeries(ctx, userLogger, userBucket, blockID, deletions, c.cfg.MaxBlockRange.Milliseconds(), filepath.Join(c.cfg.SeriesDeletionDir, userID))
+	if err != nil {
+		return err
+	}
+	if newID == nil {
+		return nil
+	}
+	if *newID != (ulid.ULID{}) {
+		c.blocksRewrittenForSeriesDeletion.Inc()
+		level.Info(userLogger).Log("msg", "rewritten block applying series deletion request", "request_id", t.RequestID, "block", blockID, "new_block", newID)
+	}
+
+	return block.MarkForDeletion(ctx, userLogger, userBucket, blockID, fmt.Sprintf("block rewritten by series deletion request %s", t.RequestID), c.blocksMarkedForSeriesDeletion)
+}
+
+// rewriteBlockDeletingSeries downloads the block, rewrites it excluding the samples matched by the
+// deletion requests and uploads the new block. It returns a nil ID if the deletion requests don't
+// match any series in the block, or a zero ID if all the samples of the block have been deleted.
+// In both cases there's nothing to upload.
+func rewriteBlockDeletingSeries(ctx context.Context, logger log.Logger, bkt objstore.Bucket, blockID ulid.ULID, deletions []metadata.DeletionRequest, maxBlockRange int64, dir string) (*ulid.ULID, error) {
+	blockDir := filepath.Join(dir, blockID.String())
+	defer func() {
+		if err := os.RemoveAll(blockDir); err != nil {
+			level.Warn(logger).Log("msg", "failed to remove downloaded block", "block", blockID, "err", err)
+		}
+	}()
+
+	if err := block.Download(ctx, logger, bkt, blockID, blockDir); err != nil {
+		return nil, errors.Wrap(err, "download block")
+	}
+
+	meta, err := metadata.ReadFromDir(blockDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "read block meta")
+	}
+
+	b, err := tsdb.OpenBlock(logger, blockDir, chunkenc.NewPool())
+	if err != nil {
+		return nil, errors.Wrap(err, "open block")
+	}
+	defer runutil.CloseWithLogOnErr(logger, b, "series deletion block reader")
+
+	for _, d := range deletions {
+		for _, interval := range d.Intervals {
+			if err := b.Delete(ctx, interval.Mint, interval.Maxt, d.Matchers...); err != nil {
+				return nil, errors.Wrap(err, "delete series")
+			}
+		}
+	}
+
+	compactor, err := tsdb.NewLeveledCompactor(ctx, nil, logger, []int64{maxBlockRange}, nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "create compactor")
+	}
+
+	newID, _, err := b.CleanTombstones(dir, compactor)
+	if err != nil {
+		return nil, errors.Wrap(err, "clean tombstones")
+	}
+	if newID == nil || *newID == (ulid.ULID{}) {
+		return newID, nil
+	}
+
+	newDir := filepath.Join(dir, newID.String())
+	defer func() {
+		if err := os.RemoveAll(newDir); err != nil {
+			level.Warn(logger).Log("msg", "failed to remove rewritten block", "block", newID, "err", err)
+		}
+	}()
+
+	thanosMeta := meta.Thanos
+	thanosMeta.Source = metadata.BucketRewriteSource
+	thanosMeta.Rewrites = append(thanosMeta.Rewrites, metadata.Rewrite{
+		Sources:          meta.Compaction.Sources,
+		DeletionsApplied: deletions,
+	})
+
+	newMeta, err := metadata.InjectThanos(logger, newDir, thanosMeta, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "inject thanos meta")
+	}
+
+	// Keep the compaction level of the original block, so that the rewritten block is
+	// not considered for compaction with less compacted blocks.
+	newMeta.Compaction.Level = meta.Compaction.Level
+	if err := newMeta.WriteToDir(logger, newDir); err != nil {
+		return nil, errors.Wrap(err, "write block meta")
+	}
+
+	if err := block.Upload(ctx, logger, bkt, newDir, metadata.NoneFunc); err != nil {
+		return nil, errors.Wrap(err, "upload rewritten block")
+	}
+
+	return newID, nil
+}
+
+// listBlocksAffectedByTombstone returns the raw and downsampled blocks overlapping the tombstone
+// time range, excluding the blocks already marked for deletion.
+func listBlocksAffectedByTombstone(idx *bucketindex.Index, t *cortex_tsdb.Tombstone) (raw, downsampled bucketindex.Blocks) {
+	marked := map[ulid.ULID]struct{}{}
+	for _, m := range idx.BlockDeletionMarks {
+		marked[m.ID] = struct{}{}
+	}
+
+	for _, b := range idx.Blocks {
+		if _, ok := marked[b.ID]; ok || !t.IsOverlappingInterval(b.MinTime, b.MaxTime-1) {
+			continue
+		}
+
+		if b.Resolution == 0 {
+			raw = append(raw, b)
+		} else {
+			downsampled = append(downsampled, b)
+		}
+	}
+
+	return raw, downsampled
+}
+
+// blocksFullyCompacted returns whether each of the input blocks is the only raw block in its
+// largest block range window, and the window has been closed for at least one block range. Rollup
+// blocks are compacted separately from the other blocks, so they're only compared with each other.
+func blocksFullyCompacted(idx *bucketindex.Index, blocks bucketindex.Blocks, blockRange int64, now time.Time) bool {
+	if blockRange <= 0 {
+		return false
+	}
+
+	marked := map[ulid.ULID]struct{}{}
+	for _, m := range idx.BlockDeletionMarks {
+		marked[m.ID] = struct{}{}
+	}
+
+	for _, b := range blocks {
+		windowStart := b.MinTime - b.MinTime%blockRange
+		windowEnd := windowStart + blockRange
+
+		if b.MaxTime > windowEnd || windowEnd+blockRange > now.UnixMilli() {
+			return false
+		}
+
+		for _, other := range idx.Blocks {
+			if _, ok := marked[other.ID]; ok || other.ID == b.ID || other.Resolution != 0 || other.Rollup != b.Rollup {
+				continue
+			}
+			if other.MinTime < windowEnd && other.MaxTime > windowStart {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,232 @@
+package compactor
+
+import (
+	"context"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	"github.com/cortexproject/cortex/pkg/storage/tsdb"
+	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
+	cortex_testutil "github.com/cortexproject/cortex/pkg/storage/tsdb/testutil"
+)
+
+func TestBlocksCleaner_ShouldApplySeriesDeletion(t *testing.T) {
+	const userID = "user-1"
+
+	bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	bucketClient = bucketindex.BucketWithGlobalMarkers(bucketClient)
+	userBucket := bucket.NewUserBucketClient(userID, bucketClient, nil)
+
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+	now := time.Now()
+
+	// Create a block in a window closed since more than a block range.
+	externalLabels := map[string]string{tsdb.TenantIDExternalLabel: userID}
+	blockRange := 2 * time.Hour
+	windowStart := now.Add(-10 * time.Hour).Truncate(blockRange).UnixMilli()
+	windowEnd := windowStart + blockRange.Milliseconds()
+	block1 := createTSDBBlock(t, bucketClient, userID, windowStart, windowEnd, externalLabels)
+
+	// Create two blocks in another window, which is not fully compacted yet.
+	block2 := createTSDBBlock(t, bucketClient, userID, windowEnd, windowEnd+time.Hour.Milliseconds(), externalLabels)
+	block3 := createTSDBBlock(t, bucketClient, userID, windowEnd+time.Hour.Milliseconds(), windowEnd+blockRange.Milliseconds(), externalLabels)
+
+	// The first tombstone deletes one of the two series of block1 and should be processed. The second
+	// one overlaps the blocks which have not been fully compacted yet. The third one is still in its
+	// cancellation period.
+	processable := tsdb.NewTombstone(userID, now.Add(-2*time.Hour), windowStart, windowEnd-1, []string{`{series_id="0"}`})
+	notCompacted := tsdb.NewTombstone(userID, now.Add(-2*time.Hour), windowEnd, windowEnd+blockRange.Milliseconds(), []string{`{series_id="0"}`})
+	cancellable := tsdb.NewTombstone(userID, now, windowStart, windowEnd-1, []string{`{series_id="1"}`})
+	for _, ts := range []*tsdb.Tombstone{processable, notCompacted, cancellable} {
+		require.NoError(t, tsdb.WriteTombstone(ctx, userBucket, ts))
+	}
+
+	cfg := BlocksCleanerConfig{
+		DeletionDelay:         time.Hour,
+		CleanupInterval:       time.Minute,
+		CleanupConcurrency:    1,
+		SeriesDeletionEnabled: true,
+		SeriesDeletionDir:     t.TempDir(),
+		MaxBlockRange:         blockRange,
+	}
+
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.userDeleteCancelPeriods[userID] = time.Hour
+
+	scanner := tsdb.NewUsersScanner(bucketClient, tsdb.AllUsers, logger)
+	cleaner := NewBlocksCleaner(cfg, bucketClient, scanner, cfgProvider, logger, prometheus.NewPedanticRegistry())
+
+	// The first run builds the bucket index, while the second one processes the tombstones.
+	require.NoError(t, cleaner.cleanUsers(ctx, true))
+	require.NoError(t, cleaner.cleanUsers(ctx, false))
+
+	// The block affected by the processed tombstone should be marked for deletion.
+	for blockID, expectedMarked := range map[ulid.ULID]bool{block1: true, block2: false, block3: false} {
+		exists, err := bucketClient.Exists(ctx, path.Join(userID, blockID.String(), metadata.DeletionMarkFilename))
+		require.NoError(t, err)
+		assert.Equal(t, expectedMarked, exists, blockID.String())
+	}
+
+	for ts, expectedState := range map[*tsdb.Tombstone]tsdb.BlockDeleteRequestState{
+		processable:  tsdb.StateProcessed,
+		notCompacted: tsdb.StatePending,
+		cancellable:  tsdb.StatePending,
+	} {
+		actual, err := tsdb.GetTombstone(ctx, userBucket, ts.RequestID)
+		require.NoError(t, err)
+		assert.Equal(t, expectedState, actual.State, ts.RequestID)
+	}
+
+	// The rewritten block should be in the index and contain only the series not deleted.
+	idx, err := bucketindex.ReadIndex(ctx, bucketClient, userID, nil, logger)
+	require.NoError(t, err)
+	require.Len(t, idx.Blocks, 4)
+	assert.ElementsMatch(t, []ulid.ULID{block1}, idx.BlockDeletionMarks.GetULIDs())
+	assert.Len(t, idx.Tombstones, 3)
+
+	var rewritten *metadata.Meta
+	for _, b := range idx.Blocks {
+		if b.ID == block1 || b.ID == block2 || b.ID == block3 {
+			continue
+		}
+
+		meta, err := block.DownloadMeta(ctx, logger, userBucket, b.ID)
+		require.NoError(t, err)
+		rewritten = &meta
+	}
+
+	require.NotNil(t, rewritten)
+	assert.Equal(t, metadata.BucketRewriteSource, rewritten.Thanos.Source)
+	assert.Equal(t, uint64(1), rewritten.Stats.NumSeries)
+	assert.Equal(t, windowStart, rewritten.MinTime)
+	assert.Equal(t, windowEnd, rewritten.MaxTime)
+	require.Len(t, rewritten.Thanos.Rewrites, 1)
+	require.Len(t, rewritten.Thanos.Rewrites[0].DeletionsApplied, 1)
+	assert.Equal(t, processable.RequestID, rewritten.Thanos.Rewrites[0].DeletionsApplied[0].RequestID)
+}
+
+func TestBlocksCleaner_ShouldDeleteExpiredTombstones(t *testing.T) {
+	const userID = "user-1"
+
+	bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	bucketClient = bucketindex.BucketWithGlobalMarkers(bucketClient)
+	userBucket := bucket.NewUserBucketClient(userID, bucketClient, nil)
+
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+	now := time.Now()
+
+	// A block is required for the user to be discovered by the cleaner.
+	createTSDBBlock(t, bucketClient, userID, 10, 20, nil)
+
+	newTombstone := func(state tsdb.BlockDeleteRequestState, stateCreatedAt time.Time, selector string) *tsdb.Tombstone {
+		ts := tsdb.NewTombstone(userID, stateCreatedAt.Add(-time.Minute), 0, 10, []string{selector})
+		ts.State = state
+		ts.StateCreatedAt = stateCreatedAt.UnixMilli()
+		require.NoError(t, tsdb.WriteTombstone(ctx, userBucket, ts))
+		return ts
+	}
+
+	expiredProcessed := newTombstone(tsdb.StateProcessed, now.Add(-2*time.Hour), `{a="1"}`)
+	recentProcessed := newTombstone(tsdb.StateProcessed, now.Add(-30*time.Minute), `{a="2"}`)
+	expiredCancelled := newTombstone(tsdb.StateCancelled, now.Add(-3*time.Hour), `{a="3"}`)
+	recentCancelled := newTombstone(tsdb.StateCancelled, now.Add(-time.Hour), `{a="4"}`)
+
+	cfg := BlocksCleanerConfig{
+		DeletionDelay:         time.Hour,
+		CleanupInterval:       time.Minute,
+		CleanupConcurrency:    1,
+		SeriesDeletionEnabled: true,
+		SeriesDeletionDir:     t.TempDir(),
+		MaxBlockRange:         2 * time.Hour,
+	}
+
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.userDeleteCancelPeriods[userID] = 2 * time.Hour
+
+	scanner := tsdb.NewUsersScanner(bucketClient, tsdb.AllUsers, logger)
+	cleaner := NewBlocksCleaner(cfg, bucketClient, scanner, cfgProvider, logger, prometheus.NewPedanticRegistry())
+
+	require.NoError(t, cleaner.cleanUsers(ctx, true))
+	require.NoError(t, cleaner.cleanUsers(ctx, false))
+
+	for ts, expectedExists := range map[*tsdb.Tombstone]bool{
+		expiredProcessed: false,
+		recentProcessed:  true,
+		expiredCancelled: false,
+		recentCancelled:  true,
+	} {
+		_, err := tsdb.GetTombstone(ctx, userBucket, ts.RequestID)
+		if expectedExists {
+			assert.NoError(t, err, ts.Selectors)
+		} else {
+			assert.ErrorIs(t, err, tsdb.ErrTombstoneNotFound, ts.Selectors)
+		}
+	}
+}
+
+func TestBlocksFullyCompacted(t *testing.T) {
+	const blockRange = int64(100)
+
+	now := time.UnixMilli(1000)
+	block1 := &bucketindex.Block{ID: ulid.MustNew(1, nil), MinTime: 0, MaxTime: 100}
+	block2 := &bucketindex.Block{ID: ulid.MustNew(2, nil), MinTime: 100, MaxTime: 150}
+	block3 := &bucketindex.Block{ID: ulid.MustNew(3, nil), MinTime: 150, MaxTime: 200}
+	block4 := &bucketindex.Block{ID: ulid.MustNew(4, nil), MinTime: 900, MaxTime: 1000}
+	downsampled := &bucketindex.Block{ID: ulid.MustNew(5, nil), MinTime: 0, MaxTime: 100, Resolution: 300000}
+	rollup := &bucketindex.Block{ID: ulid.MustNew(6, nil), MinTime: 0, MaxTime: 100, Rollup: true}
+
+	for name, tc := range map[string]struct {
+		idx      *bucketindex.Index
+		blocks   bucketindex.Blocks
+		expected bool
+	}{
+		"no blocks": {
+			idx:      &bucketindex.Index{},
+			expected: true,
+		},
+		"single block in a closed window": {
+			idx:      &bucketindex.Index{Blocks: bucketindex.Blocks{block1, downsampled}},
+			blocks:   bucketindex.Blocks{block1},
+			expected: true,
+		},
+		"single block in a closed window with a rollup block": {
+			idx:      &bucketindex.Index{Blocks: bucketindex.Blocks{block1, rollup}},
+			blocks:   bucketindex.Blocks{block1, rollup},
+			expected: true,
+		},
+		"multiple blocks in the same window": {
+			idx:      &bucketindex.Index{Blocks: bucketindex.Blocks{block2, block3}},
+			blocks:   bucketindex.Blocks{block2},
+			expected: false,
+		},
+		"multiple blocks in the same window, but one is marked for deletion": {
+			idx: &bucketindex.Index{
+				Blocks:             bucketindex.Blocks{block2, block3},
+				BlockDeletionMarks: bucketindex.BlockDeletionMarks{{ID: block3.ID}},
+			},
+			blocks:   bucketindex.Blocks{block2},
+			expected: true,
+		},
+		"block in a window not closed yet": {
+			idx:      &bucketindex.Index{Blocks: bucketindex.Blocks{block4}},
+			blocks:   bucketindex.Blocks{block4},
+			expected: false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, blocksFullyCompacted(tc.idx, tc.blocks, blockRange, now))
+		})
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/thanos-io/thanos/pkg/compact"
 
 	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
 )
 
 type ShuffleShardingGrouper struct {
@@ -138,17 +139,6 @@ func NewShuffleShardingGrouper(
 
 // Groups function modified from https://github.com/cortexproject/cortex/pull/2616
 func (g *ShuffleShardingGrouper) Groups(blocks map[ulid.ULID]*metadata.Meta) (res []*compact.Group, err error) {
-	noCompactMarked := g.noCompBlocksFunc()
-	// First of all we have to group blocks using the Thanos default
-	// grouping (based on downsample resolution + external labels).
-	mainGroups := map[string][]*metadata.Meta{}
-	for _, b := range blocks {
-		if _, excluded := noCompactMarked[b.ULID]; !excluded {
-			key := b.Thanos.GroupKey()
-			mainGroups[key] = append(mainGroups[key], b)
-		}
-	}
-
 	// For each group, we have to further split it into set of blocks
 	// which we can parallelly compact.
 	var outGroups []*compact.Group
@@ -169,39 +159,7 @@ func (g *ShuffleShardingGrouper) Groups(blocks map[ulid.ULID]*metadata.Meta) (re
 	var remainingCompactions = 0.
 	defer func() { g.remainingPlannedCompactions.Set(remainingCompactions) }()
 
-	var groups []blocksGroup
-	for _, mainBlocks := range mainGroups {
-		groups = append(groups, groupBlocksByCompactableRanges(mainBlocks, g.compactorCfg.BlockRanges.ToMilliseconds())...)
-	}
-
-	// Ensure groups are sorted by smallest range, oldest min time first. The rationale
-	// is that we want to favor smaller ranges first (ie. to deduplicate samples sooner
-	// than later) and older ones are more likely to be "complete" (no missing block still
-	// to be uploaded).
-	sort.SliceStable(groups, func(i, j int) bool {
-		iGroup := groups[i]
-		jGroup := groups[j]
-		iMinTime := iGroup.minTime()
-		iMaxTime := iGroup.maxTime()
-		jMinTime := jGroup.minTime()
-		jMaxTime := jGroup.maxTime()
-		iLength := iMaxTime - iMinTime
-		jLength := jMaxTime - jMinTime
-
-		if iLength != jLength {
-			return iLength < jLength
-		}
-		if iMinTime != jMinTime {
-			return iMinTime < jMinTime
-		}
-
-		iGroupHash := hashGroup(g.userID, iGroup.rangeStart, iGroup.rangeEnd)
-		iGroupKey := createGroupKey(iGroupHash, iGroup)
-		jGroupHash := hashGroup(g.userID, jGroup.rangeStart, jGroup.rangeEnd)
-		jGroupKey := createGroupKey(jGroupHash, jGroup)
-		// Guarantee stable sort for tests.
-		return iGroupKey < jGroupKey
-	})
+	groups := groupBlocksForCompaction(g.userID, g.compactorCfg, blocks, g.noCompBlocksFunc(), time.Now())
 
 mainLoop:
 	for _, group := range groups {
@@ -284,6 +242,79 @@ mainLoop:
 	return outGroups, nil
 }
 
+// groupBlocksForCompaction groups the blocks by compactable ranges, excluding the blocks marked for
+// no compaction, and sorts the groups in the order they should be compacted. The returned groups
+// include the ones with a single block, which have nothing to compact.
+func groupBlocksForCompaction(userID string, cfg Config, blocks map[ulid.ULID]*metadata.Meta, noCompactMarked map[ulid.ULID]*metadata.NoCompactMark, now time.Time) []blocksGroup {
+	// First of all we have to group blocks using the Thanos default
+	// grouping (based on downsample resolution + external labels).
+	mainGroups := map[string][]*metadata.Meta{}
+	for _, b := range blocks {
+		if _, excluded := noCompactMarked[b.ULID]; !excluded {
+			key := b.Thanos.GroupKey()
+			mainGroups[key] = append(mainGroups[key], b)
+		}
+	}
+
+	var groups []blocksGroup
+	for _, mainBlocks := range mainGroups {
+		groups = append(groups, groupBlocksByCompactableRanges(mainBlocks, cfg.BlockRanges.ToMilliseconds(), newOutputBlockLimits(cfg))...)
+	}
+
+	// Ensure groups are sorted by smallest range, oldest min time first. The rationale
+	// is that we want to favor smaller ranges first (ie. to deduplicate samples sooner
+	// than later) and older ones are more likely to be "complete" (no missing block still
+	// to be uploaded). If enabled, the groups of the recent ranges not larger than the
+	// second block range are compacted before, from the most recent one.
+	sort.SliceStable(groups, func(i, j int) bool {
+		iGroup := groups[i]
+		jGroup := groups[j]
+
+		iRecent := isRecentGroup(cfg, iGroup, now)
+		jRecent := isRecentGroup(cfg, jGroup, now)
+		if iRecent != jRecent {
+			return iRecent
+		}
+		if iRecent && iGroup.rangeStart != jGroup.rangeStart {
+			return iGroup.rangeStart > jGroup.rangeStart
+		}
+
+		iMinTime := iGroup.minTime()
+		iMaxTime := iGroup.maxTime()
+		jMinTime := jGroup.minTime()
+		jMaxTime := jGroup.maxTime()
+		iLength := iMaxTime - iMinTime
+		jLength := jMaxTime - jMinTime
+
+		if iLength != jLength {
+			return iLength < jLength
+		}
+		if iMinTime != jMinTime {
+			return iMinTime < jMinTime
+		}
+
+		iGroupHash := hashGroup(userID, iGroup.rangeStart, iGroup.rangeEnd)
+		iGroupKey := createGroupKey(iGroupHash, iGroup)
+		jGroupHash := hashGroup(userID, jGroup.rangeStart, jGroup.rangeEnd)
+		jGroupKey := createGroupKey(jGroupHash, jGroup)
+		// Guarantee stable sort for tests.
+		return iGroupKey < jGroupKey
+	})
+
+	return groups
+}
+
+// isRecentGroup returns whether the group compacts blocks into a range not larger than the second
+// block range, and the range is within the recent ranges priority period.
+func isRecentGroup(cfg Config, group blocksGroup, now time.Time) bool {
+	ranges := cfg.BlockRanges.ToMilliseconds()
+	if len(ranges) < 2 || group.rangeLength() > ranges[1] {
+		return false
+	}
+
+	return isRecentRange(group.rangeEnd, cfg.RecentRangesPriorityPeriod, now)
+}
+
 func (g *ShuffleShardingGrouper) isGroupVisited(blocks []*metadata.Meta, compactorID string) (bool, error) {
 	for _, block := range blocks {
 		blockID := block.ULID.String()
@@ -328,7 +359,13 @@ func hashGroup(userID string, rangeStart int64, rangeEnd int64) uint32 {
 }
 
 func createGroupKey(groupHash uint32, group blocksGroup) string {
-	return fmt.Sprintf("%v%s", groupHash, group.blocks[0].Thanos.GroupKey())
+	key := fmt.Sprintf("%v%s", groupHash, group.blocks[0].Thanos.GroupKey())
+
+	// The partitions of the same range are compacted in different directories.
+	if group.partitioned {
+		key += fmt.Sprintf("-%d", group.minTime())
+	}
+	return key
 }
 
 // blocksGroup struct and functions copied and adjusted from https://github.com/cortexproject/cortex/pull/2616
@@ -337,6 +374,10 @@ type blocksGroup struct {
 	rangeEnd   int64 // Excluded.
 	blocks     []*metadata.Meta
 	key        string
+
+	// partitioned is true if the group is one of the time partitions of the blocks in the range,
+	// whose estimated output block exceeds the output block limits.
+	partitioned bool
 }
 
 // overlaps returns whether the group range overlaps with the input group.
@@ -399,8 +440,9 @@ func (g blocksGroup) maxTime() int64 {
 // groupBlocksByCompactableRanges groups input blocks by compactable ranges, giving preference
 // to smaller ranges. If a smaller range contains more than 1 block (and thus it should
 // be compacted), the larger range block group is not generated until each of its
-// smaller ranges have 1 block each at most.
-func groupBlocksByCompactableRanges(blocks []*metadata.Meta, ranges []int64) []blocksGroup {
+// smaller ranges have 1 block each at most. The groups exceeding the output block limits
+// are partitioned by time.
+func groupBlocksByCompactableRanges(blocks []*metadata.Meta, ranges []int64, limits outputBlockLimits) []blocksGroup {
 	if len(blocks) == 0 {
 		return nil
 	}
@@ -411,8 +453,12 @@ func groupBlocksByCompactableRanges(blocks []*metadata.Meta, ranges []int64) []b
 	var groups []blocksGroup
 
 	for _, tr := range ranges {
+		// The partitions of a range have the same range, so they're only checked against
+		// the groups of the smaller ranges.
+		smallerRangesGroups := groups
+
 	nextGroup:
-		for _, group := range groupBlocksByRange(blocks, tr) {
+		for _, group := range partitionGroupsByOutputBlockLimits(groupBlocksByRange(blocks, tr), limits) {
 
 			// Exclude groups with a single block, because no compaction is required.
 			if len(group.blocks) < 2 {
@@ -422,7 +468,7 @@ func groupBlocksByCompactableRanges(blocks []*metadata.Meta, ranges []int64) []b
 			// Ensure this group's range does not overlap with any group already scheduled
 			// for compaction by a smaller range, because we need to guarantee that smaller ranges
 			// are compacted first.
-			for _, c := range groups {
+			for _, c := range smallerRangesGroups {
 				if group.overlaps(c) {
 					continue nextGroup
 				}
@@ -467,6 +513,76 @@ func groupBlocksByCompactableRanges(blocks []*metadata.Meta, ranges []int64) []b
 	return groups
 }
 
+// outputBlockLimits are the max estimated size and number of series of the block compacted from
+// a group. Zero means unlimited.
+type outputBlockLimits struct {
+	maxBytes  uint64
+	maxSeries uint64
+}
+
+func newOutputBlockLimits(cfg Config) outputBlockLimits {
+	return outputBlockLimits{
+		maxBytes:  cfg.MaxCompactionOutputBlockSizeBytes,
+		maxSeries: cfg.MaxCompactionOutputBlockSeries,
+	}
+}
+
+// exceeded returns whether a block with the given estimated size and number of series exceeds the limits.
+func (l outputBlockLimits) exceeded(bytes, series uint64) bool {
+	return (l.maxBytes > 0 && bytes > l.maxBytes) || (l.maxSeries > 0 && series > l.maxSeries)
+}
+
+// partitionGroupsByOutputBlockLimits splits each group into groups of contiguous blocks whose output
+// block, estimated summing the size and the number of series of the input blocks, doesn't exceed the
+// limits, so that the compacted blocks are partitioned by time instead of covering the whole range.
+// Blocks overlapping in time are always kept in the same partition, given they must be compacted
+// together, so the limits may be exceeded.
+func partitionGroupsByOutputBlockLimits(groups []blocksGroup, limits outputBlockLimits) []blocksGroup {
+	if limits.maxBytes == 0 && limits.maxSeries == 0 {
+		return groups
+	}
+
+	var out []blocksGroup
+	for _, group := range groups {
+		var (
+			partitions     []blocksGroup
+			current        blocksGroup
+			currentMaxTime int64
+			currentBytes   uint64
+			currentSeries  uint64
+		)
+
+		// Blocks are expected to be sorted by MinTime.
+		for _, m := range group.blocks {
+			bytes := uint64(bucketindex.BlockFromThanosMeta(*m).Size)
+			series := m.Stats.NumSeries
+
+			if len(current.blocks) > 0 && m.MinTime >= currentMaxTime && limits.exceeded(currentBytes+bytes, currentSeries+series) {
+				partitions = append(partitions, current)
+				current, currentMaxTime, currentBytes, currentSeries = blocksGroup{}, 0, 0, 0
+			}
+
+			if len(current.blocks) == 0 {
+				current.rangeStart, current.rangeEnd = group.rangeStart, group.rangeEnd
+			}
+			current.blocks = append(current.blocks, m)
+			currentMaxTime = max(currentMaxTime, m.MaxTime)
+			currentBytes += bytes
+			currentSeries += series
+		}
+		partitions = append(partitions, current)
+
+		if len(partitions) > 1 {
+			for i := range partitions {
+				partitions[i].partitioned = true
+			}
+		}
+		out = append(out, partitions...)
+	}
+
+	return out
+}
+
 // groupBlocksByRange splits the blocks by the time range. The range sequence starts at 0.
 // Input blocks are expected to be sorted by MinTime.
 //
@@ -127,9 +127,10 @@ func TestShuffleShardingGrouper_Groups(t *testing.T) {
 			compactorID string
 			isExpired   bool
 		}
-		expected        [][]ulid.ULID
-		metrics         string
-		noCompactBlocks map[ulid.ULID]*metadata.NoCompactMark
+		expected                   [][]ulid.ULID
+		metrics                    string
+		noCompactBlocks            map[ulid.ULID]*metadata.NoCompactMark
+		recentRangesPriorityPeriod time.Duration
 	}{
 		"test basic grouping": {
 			concurrency: 3,
@@ -143,6 +144,22 @@ func TestShuffleShardingGrouper_Groups(t *testing.T) {
 			metrics: `# HELP cortex_compactor_remaining_planned_compactions Total number of plans that remain to be compacted.
         	          # TYPE cortex_compactor_remaining_planned_compactions gauge
         	          cortex_compactor_remaining_planned_compactions 3
+`,
+		},
+		"test recent ranges first": {
+			concurrency: 3,
+			ranges:      []time.Duration{2 * time.Hour, 4 * time.Hour},
+			blocks:      map[ulid.ULID]*metadata.Meta{block1hto2hExt1Ulid: blocks[block1hto2hExt1Ulid], block3hto4hExt1Ulid: blocks[block3hto4hExt1Ulid], block0hto1hExt1Ulid: blocks[block0hto1hExt1Ulid], block2hto3hExt1Ulid: blocks[block2hto3hExt1Ulid], block1hto2hExt2Ulid: blocks[block1hto2hExt2Ulid], block0hto1hExt2Ulid: blocks[block0hto1hExt2Ulid]},
+			// All the test blocks are within the period.
+			recentRangesPriorityPeriod: time.Since(time.UnixMilli(0)),
+			expected: [][]ulid.ULID{
+				{block3hto4hExt1Ulid, block2hto3hExt1Ulid},
+				{block1hto2hExt2Ulid, block0hto1hExt2Ulid},
+				{block1hto2hExt1Ulid, block0hto1hExt1Ulid},
+			},
+			metrics: `# HELP cortex_compactor_remaining_planned_compactions Total number of plans that remain to be compacted.
+        	          # TYPE cortex_compactor_remaining_planned_compactions gauge
+        	          cortex_compactor_remaining_planned_compactions 3
 `,
 		},
 		"test no compaction": {
@@ -326,7 +343,8 @@ func TestShuffleShardingGrouper_Groups(t *testing.T) {
 	for testName, testData := range tests {
 		t.Run(testName, func(t *testing.T) {
 			compactorCfg := &Config{
-				BlockRanges: testData.ranges,
+				BlockRanges:                testData.ranges,
+				RecentRangesPriorityPeriod: testData.recentRangesPriorityPeriod,
 			}
 
 			limits := &validation.Limits{}
@@ -607,7 +625,84 @@ func TestGroupBlocksByCompactableRanges(t *testing.T) {
 
 	for testName, testData := range tests {
 		t.Run(testName, func(t *testing.T) {
-			assert.Equal(t, testData.expected, groupBlocksByCompactableRanges(testData.blocks, testData.ranges))
+			assert.Equal(t, testData.expected, groupBlocksByCompactableRanges(testData.blocks, testData.ranges, outputBlockLimits{}))
+		})
+	}
+}
+
+func TestGroupBlocksByCompactableRanges_WithOutputBlockLimits(t *testing.T) {
+	newMeta := func(minTime, maxTime int64, series uint64, bytes int64) *metadata.Meta {
+		return &metadata.Meta{
+			BlockMeta: tsdb.BlockMeta{MinTime: minTime, MaxTime: maxTime, Stats: tsdb.BlockStats{NumSeries: series}},
+			Thanos:    metadata.Thanos{Files: []metadata.File{{RelPath: "index", SizeBytes: bytes}}},
+		}
+	}
+
+	tests := map[string]struct {
+		ranges   []int64
+		limits   outputBlockLimits
+		blocks   []*metadata.Meta
+		expected []blocksGroup
+	}{
+		"range exceeding the series limit is partitioned, and the larger range is not compacted until its partitions are": {
+			ranges: []int64{20, 40},
+			limits: outputBlockLimits{maxSeries: 10},
+			blocks: []*metadata.Meta{
+				newMeta(0, 10, 6, 0),
+				newMeta(10, 20, 6, 0),
+				newMeta(20, 30, 3, 0),
+				newMeta(30, 40, 3, 0),
+			},
+			expected: []blocksGroup{
+				{rangeStart: 20, rangeEnd: 40, blocks: []*metadata.Meta{
+					newMeta(20, 30, 3, 0),
+					newMeta(30, 40, 3, 0),
+				}},
+			},
+		},
+		"larger range with each block exceeding the limit with the next one is not compacted": {
+			ranges: []int64{20, 40},
+			limits: outputBlockLimits{maxSeries: 10},
+			blocks: []*metadata.Meta{
+				newMeta(0, 10, 6, 0),
+				newMeta(10, 20, 6, 0),
+				newMeta(20, 40, 6, 0),
+			},
+			expected: nil,
+		},
+		"overlapping blocks are kept in the same partition, even if exceeding the size limit": {
+			ranges: []int64{20},
+			limits: outputBlockLimits{maxBytes: 10},
+			blocks: []*metadata.Meta{
+				newMeta(0, 10, 0, 6),
+				newMeta(0, 10, 0, 6),
+				newMeta(10, 15, 0, 1),
+				newMeta(15, 20, 0, 1),
+			},
+			expected: []blocksGroup{
+				{rangeStart: 0, rangeEnd: 20, partitioned: true, blocks: []*metadata.Meta{
+					newMeta(0, 10, 0, 6),
+					newMeta(0, 10, 0, 6),
+				}},
+				{rangeStart: 0, rangeEnd: 20, partitioned: true, blocks: []*metadata.Meta{
+					newMeta(10, 15, 0, 1),
+					newMeta(15, 20, 0, 1),
+				}},
+			},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			groups := groupBlocksByCompactableRanges(testData.blocks, testData.ranges, testData.limits)
+			assert.Equal(t, testData.expected, groups)
+
+			// The partitions of the same range have different keys.
+			keys := map[string]struct{}{}
+			for _, group := range groups {
+				keys[createGroupKey(hashGroup("user-1", group.rangeStart, group.rangeEnd), group)] = struct{}{}
+			}
+			assert.Len(t, keys, len(groups))
 		})
 	}
 }
@@ -756,6 +851,11 @@ func (r *RingMock) Get(key uint32, op ring.Operation, bufDescs []ring.InstanceDe
 	return args.Get(0).(ring.ReplicationSet), args.Error(1)
 }
 
+func (r *RingMock) GetWithBoundedLoads(key uint32, op ring.Operation, loads *ring.BoundedLoads, bufDescs []ring.InstanceDesc, bufHosts []string, bufZones map[string]int) (ring.ReplicationSet, error) {
+	args := r.Called(key, op, loads, bufDescs, bufHosts, bufZones)
+	return args.Get(0).(ring.ReplicationSet), args.Error(1)
+}
+
 func (r *RingMock) GetAllHealthy(op ring.Operation) (ring.ReplicationSet, error) {
 	args := r.Called(op)
 	return args.Get(0).(ring.ReplicationSet), args.Error(1)
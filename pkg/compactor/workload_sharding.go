@@ -0,0 +1,230 @@
+package compactor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/objstore"
+
+	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/concurrency"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+)
+
+// workloadAssignmentPath is the path of the tenants assignment computed by the workload-aware sharding,
+// stored among the global markers because it's not owned by any tenant.
+var workloadAssignmentPath = path.Join(util.GlobalMarkersDir, "compactor-workload-assignment.json")
+
+// workloadAssignment is the assignment of the tenants to the compactors published by the workload-aware
+// sharding, so that all the compactors use the same one.
+type workloadAssignment struct {
+	// Instances are the sorted addresses of the healthy compactors the tenants have been assigned to.
+	Instances []string `json:"instances"`
+	// Tenants maps the users with pending compaction work to the address of the compactor owning them.
+	Tenants map[string]string `json:"tenants"`
+	// UpdatedAt is the unix timestamp of the assignment, in seconds.
+	UpdatedAt int64 `json:"updated_at"`
+}
+
+// pendingCompactionBytes returns the size of the raw blocks in the bucket index which
+// haven't been compacted yet to the largest block range.
+func pendingCompactionBytes(idx *bucketindex.Index, maxBlockRange int64) int64 {
+	deleted := make(map[ulid.ULID]struct{}, len(idx.BlockDeletionMarks))
+	for _, id := range idx.BlockDeletionMarks.GetULIDs() {
+		deleted[id] = struct{}{}
+	}
+
+	pending := int64(0)
+	for _, b := range idx.Blocks {
+		if _, ok := deleted[b.ID]; ok || b.Resolution != 0 || b.Rollup {
+			continue
+		}
+		if b.MaxTime-b.MinTime < maxBlockRange {
+			pending += b.Size
+		}
+	}
+	return pending
+}
+
+// readTenantsPendingCompactionBytes reads the pending compaction bytes of the input users from
+// their bucket index. Users whose bucket index can't be read are not included in the result.
+func (c *Compactor) readTenantsPendingCompactionBytes(ctx context.Context, userIDs []string) map[string]int64 {
+	maxBlockRange := c.compactorCfg.BlockRanges.ToMilliseconds()[len(c.compactorCfg.BlockRanges)-1]
+
+	var (
+		mtx     sync.Mutex
+		pending = make(map[string]int64, len(userIDs))
+	)
+
+	_ = concurrency.ForEachUser(ctx, userIDs, c.compactorCfg.MetaSyncConcurrency, func(ctx context.Context, userID string) error {
+		idx, err := bucketindex.ReadIndex(ctx, c.bucketClient, userID, c.limits, c.logger)
+		if err != nil {
+			level.Debug(c.logger).Log("msg", "unable to read bucket index to compute pending compaction bytes", "user", userID, "err", err)
+			return nil
+		}
+
+		mtx.Lock()
+		pending[userID] = pendingCompactionBytes(idx, maxBlockRange)
+		mtx.Unlock()
+		return nil
+	})
+
+	return pending
+}
+
+// assignTenantsByWorkload assigns the users with pending compaction work to the input instances,
+// starting from the users with the most pending bytes and picking each time the instance with
+// the least pending bytes assigned so far. Given the same input, every compactor computes the
+// same assignment. Users with no pending work are not assigned.
+func assignTenantsByWorkload(pending map[string]int64, instances []string) map[string]string {
+	if len(instances) == 0 {
+		return nil
+	}
+
+	userIDs := make([]string, 0, len(pending))
+	for userID, size := range pending {
+		if size > 0 {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	sort.Slice(userIDs, func(i, j int) bool {
+		if pending[userIDs[i]] != pending[userIDs[j]] {
+			return pending[userIDs[i]] > pending[userIDs[j]]
+		}
+		return userIDs[i] < userIDs[j]
+	})
+
+	sortedInstances := append([]string(nil), instances...)
+	sort.Strings(sortedInstances)
+
+	loads := make([]int64, len(sortedInstances))
+	assignment := make(map[string]string, len(userIDs))
+	for _, userID := range userIDs {
+		lowest := 0
+		for i := range loads {
+			if loads[i] < loads[lowest] {
+				lowest = i
+			}
+		}
+
+		assignment[userID] = sortedInstances[lowest]
+		loads[lowest] += pending[userID]
+	}
+
+	return assignment
+}
+
+// resolveWorkloadAssignment returns the tenants assignment published for the input healthy compactors,
+// or nil if there isn't any. The compactor with the lowest address then publishes the assignment computed
+// from the pending compaction bytes it has read, which is used by all the compactors in their next run:
+// this way the compactors never compute a different assignment from the slightly different pending bytes
+// each of them may have read.
+func resolveWorkloadAssignment(ctx context.Context, bkt objstore.Bucket, logger log.Logger, pending map[string]int64, instances []string, addr string) map[string]string {
+	sortedInstances := append([]string(nil), instances...)
+	sort.Strings(sortedInstances)
+
+	var assignment map[string]string
+	if published, err := readWorkloadAssignment(ctx, bkt); err != nil {
+		level.Warn(logger).Log("msg", "unable to read the published workload assignment, falling back to the hash-based sharding", "err", err)
+	} else if published != nil && slices.Equal(published.Instances, sortedInstances) {
+		assignment = published.Tenants
+	}
+
+	if len(sortedInstances) > 0 && sortedInstances[0] == addr {
+		next := workloadAssignment{
+			Instances: sortedInstances,
+			Tenants:   assignTenantsByWorkload(pending, sortedInstances),
+			UpdatedAt: time.Now().Unix(),
+		}
+		if err := writeWorkloadAssignment(ctx, bkt, next); err != nil {
+			level.Warn(logger).Log("msg", "unable to publish the workload assignment", "err", err)
+		}
+	}
+
+	return assignment
+}
+
+// readWorkloadAssignment reads the published tenants assignment, returning nil if it doesn't exist.
+func readWorkloadAssignment(ctx context.Context, bkt objstore.Bucket) (*workloadAssignment, error) {
+	r, err := bkt.Get(ctx, workloadAssignmentPath)
+	if bkt.IsObjNotFoundErr(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "get workload assignment")
+	}
+	defer r.Close()
+
+	assignment := &workloadAssignment{}
+	if err := json.NewDecoder(r).Decode(assignment); err != nil {
+		return nil, errors.Wrap(err, "decode workload assignment")
+	}
+	return assignment, nil
+}
+
+// writeWorkloadAssignment publishes the tenants assignment.
+func writeWorkloadAssignment(ctx context.Context, bkt objstore.Bucket, assignment workloadAssignment) error {
+	data, err := json.Marshal(assignment)
+	if err != nil {
+		return errors.Wrap(err, "encode workload assignment")
+	}
+	return errors.Wrap(bkt.Upload(ctx, workloadAssignmentPath, bytes.NewReader(data)), "upload workload assignment")
+}
+
+// workloadAwareOwnership computes the pending compaction bytes of the input users and, if the
+// default sharding strategy is used, assigns them to the healthy compactors using the published
+// assignment. It returns a function checking whether a user is owned by this compactor, falling
+// back to the hash-based ownership for the users not assigned.
+func (c *Compactor) workloadAwareOwnership(ctx context.Context, userIDs []string) func(userID string) (bool, error) {
+	allowed := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if c.allowedTenants.IsAllowed(userID) {
+			allowed = append(allowed, userID)
+		}
+	}
+
+	pending := c.readTenantsPendingCompactionBytes(ctx, allowed)
+
+	var assignment map[string]string
+	if c.compactorCfg.ShardingEnabled && c.compactorCfg.ShardingStrategy == util.ShardingStrategyDefault {
+		if rs, err := c.ring.GetAllHealthy(RingOp); err != nil {
+			level.Warn(c.logger).Log("msg", "unable to get healthy compactors, falling back to the hash-based sharding", "err", err)
+		} else {
+			instances := make([]string, 0, len(rs.Instances))
+			for _, instance := range rs.Instances {
+				instances = append(instances, instance.Addr)
+			}
+			assignment = resolveWorkloadAssignment(ctx, c.bucketClient, c.logger, pending, instances, c.ringLifecycler.Addr)
+		}
+	}
+
+	ownUser := func(userID string) (bool, error) {
+		if addr, ok := assignment[userID]; ok {
+			return addr == c.ringLifecycler.Addr, nil
+		}
+		return c.ownUserForCompaction(userID)
+	}
+
+	ownedPending := int64(0)
+	for userID, size := range pending {
+		if owned, err := ownUser(userID); err != nil {
+			level.Warn(util_log.WithUserID(userID, c.logger)).Log("msg", "unable to check if user is owned by this shard", "err", err)
+		} else if owned {
+			ownedPending += size
+		}
+	}
+	c.pendingCompactionBytes.Set(float64(ownedPending))
+
+	return ownUser
+}
@@ -0,0 +1,149 @@
+package compactor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/compact/downsample"
+
+	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
+	cortex_testutil "github.com/cortexproject/cortex/pkg/storage/tsdb/testutil"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+func TestPendingCompactionBytes(t *testing.T) {
+	const maxBlockRange = int64(100)
+
+	idx := &bucketindex.Index{
+		Blocks: bucketindex.Blocks{
+			{ID: ulid.MustNew(1, nil), MinTime: 0, MaxTime: 100, Size: 1},
+			{ID: ulid.MustNew(2, nil), MinTime: 100, MaxTime: 150, Size: 10},
+			{ID: ulid.MustNew(3, nil), MinTime: 150, MaxTime: 200, Size: 100},
+			{ID: ulid.MustNew(4, nil), MinTime: 150, MaxTime: 200, Size: 1000},
+			{ID: ulid.MustNew(5, nil), MinTime: 150, MaxTime: 200, Size: 10000, Resolution: downsample.ResLevel1},
+			{ID: ulid.MustNew(6, nil), MinTime: 150, MaxTime: 200, Size: 100000, Rollup: true},
+		},
+		BlockDeletionMarks: bucketindex.BlockDeletionMarks{
+			{ID: ulid.MustNew(4, nil)},
+		},
+	}
+
+	assert.Equal(t, int64(110), pendingCompactionBytes(idx, maxBlockRange))
+}
+
+func TestAssignTenantsByWorkload(t *testing.T) {
+	pending := map[string]int64{
+		"user-1": 100,
+		"user-2": 60,
+		"user-3": 50,
+		"user-4": 30,
+		"user-5": 10,
+		"user-6": 0,
+	}
+
+	expected := map[string]string{
+		"user-1": "instance-1",
+		"user-2": "instance-2",
+		"user-3": "instance-2",
+		"user-4": "instance-1",
+		"user-5": "instance-2",
+	}
+
+	assert.Equal(t, expected, assignTenantsByWorkload(pending, []string{"instance-1", "instance-2"}))
+
+	// The assignment should not depend on the order of the instances.
+	assert.Equal(t, expected, assignTenantsByWorkload(pending, []string{"instance-2", "instance-1"}))
+
+	assert.Nil(t, assignTenantsByWorkload(pending, nil))
+}
+
+func TestResolveWorkloadAssignment_ShouldNotOverlapWithDifferentPendingBytes(t *testing.T) {
+	bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+	instances := []string{"instance-2", "instance-1"}
+
+	// The two compactors read slightly different pending bytes, for example because the bucket index
+	// has been updated in the meanwhile, which would lead to different assignments if each of them
+	// computed its own.
+	pending := map[string]map[string]int64{
+		"instance-1": {"user-1": 100, "user-2": 60, "user-3": 41, "user-4": 40},
+		"instance-2": {"user-1": 100, "user-2": 60, "user-3": 39, "user-4": 40},
+	}
+	require.NotEqual(t, assignTenantsByWorkload(pending["instance-1"], instances), assignTenantsByWorkload(pending["instance-2"], instances))
+
+	// Nothing has been published on the first run, so both compactors fall back to the hash-based sharding.
+	for _, addr := range instances {
+		assert.Nil(t, resolveWorkloadAssignment(ctx, bucketClient, logger, pending[addr], instances, addr))
+	}
+
+	// On the next run both compactors use the assignment published by the compactor with the lowest address.
+	assignments := map[string]map[string]string{}
+	for _, addr := range instances {
+		assignments[addr] = resolveWorkloadAssignment(ctx, bucketClient, logger, pending[addr], instances, addr)
+	}
+	assert.Equal(t, assignTenantsByWorkload(pending["instance-1"], instances), assignments["instance-1"])
+
+	for userID := range pending["instance-1"] {
+		owners := 0
+		for addr, assignment := range assignments {
+			if assignment[userID] == addr {
+				owners++
+			}
+		}
+		assert.Equal(t, 1, owners, userID)
+	}
+
+	// The published assignment is ignored if the healthy compactors have changed.
+	assert.Nil(t, resolveWorkloadAssignment(ctx, bucketClient, logger, pending["instance-2"], []string{"instance-2", "instance-3"}, "instance-2"))
+}
+
+func TestCompactor_WorkloadAwareOwnership_ShouldExportPendingCompactionBytes(t *testing.T) {
+	bucketClient, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	ctx := context.Background()
+
+	for userID, size := range map[string]int64{"user-1": 100, "user-2": 200, "user-3": 400} {
+		idx := &bucketindex.Index{
+			Version:   bucketindex.IndexVersion1,
+			Blocks:    bucketindex.Blocks{{ID: ulid.MustNew(1, nil), MinTime: 0, MaxTime: time.Hour.Milliseconds(), Size: size}},
+			UpdatedAt: time.Now().Unix(),
+		}
+		require.NoError(t, bucketindex.WriteIndex(ctx, bucketClient, userID, nil, idx))
+	}
+
+	overrides, err := validation.NewOverrides(validation.Limits{}, nil)
+	require.NoError(t, err)
+
+	c := &Compactor{
+		compactorCfg: Config{
+			BlockRanges:         []time.Duration{2 * time.Hour},
+			MetaSyncConcurrency: 1,
+			ShardingStrategy:    util.ShardingStrategyDefault,
+		},
+		bucketClient:           bucketClient,
+		limits:                 overrides,
+		logger:                 log.NewNopLogger(),
+		allowedTenants:         util.NewAllowedTenants(nil, flagext.StringSliceCSV{"user-3"}),
+		pendingCompactionBytes: prometheus.NewGauge(prometheus.GaugeOpts{}),
+	}
+
+	ownUser := c.workloadAwareOwnership(ctx, []string{"user-1", "user-2", "user-3", "user-4"})
+
+	// Sharding is disabled, so all allowed users are owned.
+	for userID, expected := range map[string]bool{"user-1": true, "user-2": true, "user-3": false, "user-4": true} {
+		owned, err := ownUser(userID)
+		require.NoError(t, err)
+		assert.Equal(t, expected, owned, userID)
+	}
+
+	assert.Equal(t, float64(300), prom_testutil.ToFloat64(c.pendingCompactionBytes))
+}
@@ -44,6 +44,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/querier/tripperware/queryrange"
 	querier_worker "github.com/cortexproject/cortex/pkg/querier/worker"
 	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/ring/kv"
 	"github.com/cortexproject/cortex/pkg/ring/kv/memberlist"
 	"github.com/cortexproject/cortex/pkg/ruler"
 	"github.com/cortexproject/cortex/pkg/ruler/rulestore"
@@ -196,6 +197,9 @@ func (c *Config) Validate(log log.Logger) error {
 	if err := c.LimitsConfig.Validate(c.Distributor.ShardByAllLabels); err != nil {
 		return errors.Wrap(err, "invalid limits config")
 	}
+	if err := validateStorageOverrides(&c.LimitsConfig); err != nil {
+		return errors.Wrap(err, "invalid limits config")
+	}
 	if err := c.Distributor.Validate(c.LimitsConfig); err != nil {
 		return errors.Wrap(err, "invalid distributor config")
 	}
@@ -318,10 +322,20 @@ type Cortex struct {
 	Compactor    *compactor.Compactor
 	StoreGateway *storegateway.StoreGateway
 	MemberlistKV *memberlist.KVInitService
+	MultiKV      *kv.MultiClients
+	Groupcache   *tsdb.GroupcacheUniverse
+
+	// Loader of the cache generations of the tenants, versioning the keys of the results, chunks and metadata caches.
+	// It's nil if the cache generations are disabled.
+	CacheGenerations *tsdb.CacheGenerationLoader
 
 	// Queryables that the querier should use to query the long
 	// term storage. It depends on the storage engine used.
 	StoreQueryables []querier.QueryableWithFilter
+
+	// Loader of the series deletion tombstones, used by the querier to filter
+	// out deleted series. It's nil if tombstones are not supported.
+	TombstonesLoader querier.TombstonesLoader
 }
 
 // New makes a new Cortex.
@@ -42,11 +42,14 @@ import (
 	"github.com/cortexproject/cortex/pkg/querier/tripperware/queryrange"
 	querier_worker "github.com/cortexproject/cortex/pkg/querier/worker"
 	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/ring/kv"
 	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
 	"github.com/cortexproject/cortex/pkg/ring/kv/memberlist"
 	"github.com/cortexproject/cortex/pkg/ruler"
+	"github.com/cortexproject/cortex/pkg/ruler/rulestore"
 	"github.com/cortexproject/cortex/pkg/scheduler"
 	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	"github.com/cortexproject/cortex/pkg/storage/tsdb"
 	"github.com/cortexproject/cortex/pkg/storegateway"
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
 	"github.com/cortexproject/cortex/pkg/util/modules"
@@ -80,7 +83,13 @@ const (
 	Compactor                string = "compactor"
 	StoreGateway             string = "store-gateway"
 	MemberlistKV             string = "memberlist-kv"
+	MultiKV                  string = "multi-kv"
+	RingSnapshots            string = "ring-snapshots"
+	Groupcache               string = "groupcache"
+	CacheGeneration          string = "cache-generation"
+	CacheGenerationAPI       string = "cache-generation-api"
 	TenantDeletion           string = "tenant-deletion"
+	SeriesDeletion           string = "series-deletion"
 	Purger                   string = "purger"
 	QueryScheduler           string = "query-scheduler"
 	TenantFederation         string = "tenant-federation"
@@ -136,7 +145,6 @@ func (t *Cortex) initServer() (services.Service, error) {
 }
 
 func (t *Cortex) initRing() (serv services.Service, err error) {
-	t.Cfg.Ingester.LifecyclerConfig.RingConfig.KVStore.Multi.ConfigProvider = multiClientRuntimeConfigChannel(t.RuntimeConfig)
 	t.Ring, err = ring.New(t.Cfg.Ingester.LifecyclerConfig.RingConfig, "ingester", ingester.RingKey, util_log.Logger, prometheus.WrapRegistererWithPrefix("cortex_", prometheus.DefaultRegisterer))
 	if err != nil {
 		return nil, err
@@ -228,7 +236,7 @@ func (t *Cortex) initQueryable() (serv services.Service, err error) {
 	querierRegisterer := prometheus.WrapRegistererWith(prometheus.Labels{"engine": "querier"}, prometheus.DefaultRegisterer)
 
 	// Create a querier queryable and PromQL engine
-	t.QuerierQueryable, t.ExemplarQueryable, t.QuerierEngine = querier.New(t.Cfg.Querier, t.Overrides, t.Distributor, t.StoreQueryables, querierRegisterer, util_log.Logger)
+	t.QuerierQueryable, t.ExemplarQueryable, t.QuerierEngine = querier.New(t.Cfg.Querier, t.Overrides, t.Distributor, t.StoreQueryables, t.TombstonesLoader, querierRegisterer, util_log.Logger)
 
 	// Register the default endpoints that are always enabled for the querier module
 	t.API.RegisterQueryable(t.QuerierQueryable, t.Distributor)
@@ -363,6 +371,9 @@ func (t *Cortex) initStoreQueryables() (services.Service, error) {
 		return nil, fmt.Errorf("failed to initialize querier: %v", err)
 	} else {
 		t.StoreQueryables = append(t.StoreQueryables, querier.UseAlwaysQueryable(q))
+		if l, ok := q.(querier.TombstonesLoader); ok {
+			t.TombstonesLoader = l
+		}
 		if s, ok := q.(services.Service); ok {
 			servs = append(servs, s)
 		}
@@ -398,7 +409,6 @@ func (t *Cortex) tsdbIngesterConfig() {
 }
 
 func (t *Cortex) initIngesterService() (serv services.Service, err error) {
-	t.Cfg.Ingester.LifecyclerConfig.RingConfig.KVStore.Multi.ConfigProvider = multiClientRuntimeConfigChannel(t.RuntimeConfig)
 	t.Cfg.Ingester.LifecyclerConfig.ListenPort = t.Cfg.Server.GRPCListenPort
 	t.Cfg.Ingester.DistributorShardingStrategy = t.Cfg.Distributor.ShardingStrategy
 	t.Cfg.Ingester.DistributorShardByAllLabels = t.Cfg.Distributor.ShardByAllLabels
@@ -447,6 +457,9 @@ func (t *Cortex) initQueryFrontendTripperware() (serv services.Service, err erro
 	// ShardedPrometheusCodec is same as PrometheusCodec but to be used on the sharded queries (it sum up the stats)
 	shardedPrometheusCodec := queryrange.NewPrometheusCodec(true)
 
+	// Cap the entries of each tenant in the in-memory results cache.
+	t.Cfg.QueryRange.ResultsCacheConfig.CacheConfig.Fifocache.TenantMaxSizeBytes = t.Overrides.MaxCacheSizeBytes
+
 	queryRangeMiddlewares, cache, err := queryrange.Middlewares(
 		t.Cfg.QueryRange,
 		util_log.Logger,
@@ -543,6 +556,7 @@ func createActiveQueryTracker(cfg querier.Config, logger log.Logger) promql.Quer
 
 func (t *Cortex) initRuler() (serv services.Service, err error) {
 	var manager *ruler.DefaultMultiTenantManager
+	var backtester *ruler.Backtester
 	if t.RulerStorage == nil {
 		level.Info(util_log.Logger).Log("msg", "RulerStorage is nil.  Not starting the ruler.")
 		return nil, nil
@@ -552,6 +566,22 @@ func (t *Cortex) initRuler() (serv services.Service, err error) {
 	t.Cfg.Ruler.Ring.ListenPort = t.Cfg.Server.GRPCListenPort
 	metrics := ruler.NewRuleEvalMetrics(t.Cfg.Ruler, prometheus.DefaultRegisterer)
 
+	var alertStateStore *ruler.AlertStateStore
+	if t.Cfg.Ruler.AlertStatePersistenceEnabled {
+		alertStateStore, err = ruler.NewAlertStateStore(context.Background(), t.Cfg.RulerStorage, t.Overrides, util_log.Logger, prometheus.DefaultRegisterer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var lastEvaluationStore *ruler.LastEvaluationStore
+	if t.Cfg.Ruler.EvaluationCatchUpEnabled {
+		lastEvaluationStore, err = ruler.NewLastEvaluationStore(context.Background(), t.Cfg.RulerStorage, t.Overrides, util_log.Logger, prometheus.DefaultRegisterer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if t.Cfg.ExternalPusher != nil && t.Cfg.ExternalQueryable != nil {
 		rulerRegisterer := prometheus.WrapRegistererWith(prometheus.Labels{"engine": "ruler"}, prometheus.DefaultRegisterer)
 
@@ -580,14 +610,27 @@ func (t *Cortex) initRuler() (serv services.Service, err error) {
 		}
 
 		managerFactory := ruler.DefaultTenantManagerFactory(t.Cfg.Ruler, t.Cfg.ExternalPusher, t.Cfg.ExternalQueryable, queryEngine, t.Overrides, metrics, prometheus.DefaultRegisterer)
-		manager, err = ruler.NewDefaultMultiTenantManager(t.Cfg.Ruler, managerFactory, metrics, prometheus.DefaultRegisterer, util_log.Logger)
+		backtester = ruler.NewBacktester(t.Cfg.Ruler, t.Cfg.ExternalQueryable, queryEngine, t.Overrides, util_log.Logger)
+		manager, err = ruler.NewDefaultMultiTenantManager(t.Cfg.Ruler, managerFactory, alertStateStore, lastEvaluationStore, metrics, prometheus.DefaultRegisterer, util_log.Logger)
 	} else {
 		rulerRegisterer := prometheus.WrapRegistererWith(prometheus.Labels{"engine": "ruler"}, prometheus.DefaultRegisterer)
 		// TODO: Consider wrapping logger to differentiate from querier module logger
-		queryable, _, engine := querier.New(t.Cfg.Querier, t.Overrides, t.Distributor, t.StoreQueryables, rulerRegisterer, util_log.Logger)
+		queryable, _, engine := querier.New(t.Cfg.Querier, t.Overrides, t.Distributor, t.StoreQueryables, t.TombstonesLoader, rulerRegisterer, util_log.Logger)
+		if t.Cfg.TenantFederation.Enabled {
+			// The federated rule groups query across their source tenants.
+			queryable = querier.NewSampleAndChunkQueryable(tenantfederation.NewQueryable(queryable, true))
+		}
 
 		managerFactory := ruler.DefaultTenantManagerFactory(t.Cfg.Ruler, t.Distributor, queryable, engine, t.Overrides, metrics, prometheus.DefaultRegisterer)
-		manager, err = ruler.NewDefaultMultiTenantManager(t.Cfg.Ruler, managerFactory, metrics, prometheus.DefaultRegisterer, util_log.Logger)
+		backtester = ruler.NewBacktester(t.Cfg.Ruler, queryable, engine, t.Overrides, util_log.Logger)
+		if t.Cfg.Ruler.FrontendAddress != "" {
+			frontendClient, err := ruler.NewFrontendClient(t.Cfg.Ruler.FrontendAddress, t.Cfg.Ruler.FrontendTimeout)
+			if err != nil {
+				return nil, err
+			}
+			managerFactory = ruler.FrontendTenantManagerFactory(t.Cfg.Ruler, t.Distributor, queryable, frontendClient, t.Overrides, metrics)
+		}
+		manager, err = ruler.NewDefaultMultiTenantManager(t.Cfg.Ruler, managerFactory, alertStateStore, lastEvaluationStore, metrics, prometheus.DefaultRegisterer, util_log.Logger)
 	}
 
 	if err != nil {
@@ -608,10 +651,32 @@ func (t *Cortex) initRuler() (serv services.Service, err error) {
 
 	// Expose HTTP/GRPC endpoints for the Ruler service
 	t.API.RegisterRuler(t.Ruler)
+	if t.Cfg.Ruler.SyncNotificationsEnabled {
+		t.API.RegisterRulerSyncNotifications(t.Ruler)
+	}
 
 	// If the API is enabled, register the Ruler API
 	if t.Cfg.Ruler.EnableAPI {
-		t.API.RegisterRulerAPI(ruler.NewAPI(t.Ruler, t.RulerStorage, util_log.Logger))
+		var ruleStore rulestore.RuleStore = t.RulerStorage
+		if t.Cfg.Ruler.RulesVersioningEnabled {
+			versionedStore, err := ruler.NewVersionedRuleStore(context.Background(), t.Cfg.RulerStorage, t.RulerStorage, t.Cfg.Ruler.MaxRulesVersions, t.Overrides, util_log.Logger, prometheus.DefaultRegisterer)
+			if err != nil {
+				return nil, err
+			}
+			t.API.RegisterRulerVersionsAPI(ruler.NewRulesVersionsAPI(t.Ruler, versionedStore, util_log.Logger))
+			ruleStore = versionedStore
+		}
+		t.API.RegisterRulerAPI(ruler.NewAPI(t.Ruler, ruleStore, util_log.Logger))
+
+		if t.Cfg.Ruler.EnableBacktestAPI {
+			t.API.RegisterRulerBacktester(backtester)
+		}
+		if t.Cfg.Ruler.EnableDryRunAPI {
+			t.API.RegisterRulerDryRun(backtester)
+		}
+		if t.Cfg.Ruler.EnableRuleTestAPI {
+			t.API.RegisterRulerRuleTest(backtester)
+		}
 	}
 
 	return t.Ruler, nil
@@ -645,13 +710,17 @@ func (t *Cortex) initAlertManager() (serv services.Service, err error) {
 		return
 	}
 
-	t.API.RegisterAlertmanager(t.Alertmanager, t.Cfg.isModuleEnabled(AlertManager), t.Cfg.Alertmanager.EnableAPI)
+	t.API.RegisterAlertmanager(t.Alertmanager, t.Cfg.isModuleEnabled(AlertManager), t.Cfg.Alertmanager.EnableAPI, t.Cfg.Alertmanager.EnableUIProxy)
 	return t.Alertmanager, nil
 }
 
 func (t *Cortex) initCompactor() (serv services.Service, err error) {
 	t.Cfg.Compactor.ShardingRing.ListenPort = t.Cfg.Server.GRPCListenPort
 
+	if err = t.initBandwidthGovernor(); err != nil {
+		return
+	}
+
 	t.Compactor, err = compactor.NewCompactor(t.Cfg.Compactor, t.Cfg.BlocksStorage, util_log.Logger, prometheus.DefaultRegisterer, t.Overrides)
 	if err != nil {
 		return
@@ -665,6 +734,10 @@ func (t *Cortex) initCompactor() (serv services.Service, err error) {
 func (t *Cortex) initStoreGateway() (serv services.Service, err error) {
 	t.Cfg.StoreGateway.ShardingRing.ListenPort = t.Cfg.Server.GRPCListenPort
 
+	if err = t.initBandwidthGovernor(); err != nil {
+		return nil, err
+	}
+
 	t.StoreGateway, err = storegateway.NewStoreGateway(t.Cfg.StoreGateway, t.Cfg.BlocksStorage, t.Overrides, t.Cfg.Server.LogLevel, util_log.Logger, prometheus.DefaultRegisterer)
 	if err != nil {
 		return nil, err
@@ -676,6 +749,55 @@ func (t *Cortex) initStoreGateway() (serv services.Service, err error) {
 	return t.StoreGateway, nil
 }
 
+// initBandwidthGovernor creates the bandwidth governor of the background bucket traffic, shared by the compactor
+// and the store-gateway running in the same process.
+func (t *Cortex) initBandwidthGovernor() (err error) {
+	if t.Cfg.BlocksStorage.BandwidthGovernor != nil {
+		return nil
+	}
+	t.Cfg.BlocksStorage.BandwidthGovernor, err = bucket.NewBandwidthGovernor(t.Cfg.BlocksStorage.BackgroundBandwidth, prometheus.DefaultRegisterer)
+	return err
+}
+
+// initGroupcache creates the groupcache peers, shared by the chunks and metadata caches of the queriers,
+// store-gateways and compactors running in the same process.
+func (t *Cortex) initGroupcache() (services.Service, error) {
+	if !t.Cfg.BlocksStorage.BucketStore.GroupcacheEnabled() {
+		return nil, nil
+	}
+
+	t.Groupcache = tsdb.NewGroupcacheUniverse(t.Cfg.BlocksStorage.BucketStore.Groupcache, util_log.Logger, prometheus.DefaultRegisterer)
+	t.API.RegisterGroupcache(t.Groupcache.Handler())
+
+	// Update the config.
+	t.Cfg.BlocksStorage.BucketStore.ChunksCache.Groupcache.Universe = t.Groupcache
+	t.Cfg.BlocksStorage.BucketStore.MetadataCache.Groupcache.Universe = t.Groupcache
+
+	return t.Groupcache, nil
+}
+
+// initCacheGeneration creates the loader of the cache generations of the tenants, shared by the results cache of the
+// query-frontend and the chunks and metadata caches of the queriers, store-gateways and compactors running in the
+// same process.
+func (t *Cortex) initCacheGeneration() (services.Service, error) {
+	if !t.Cfg.BlocksStorage.CacheGeneration.Enabled {
+		return nil, nil
+	}
+
+	bucketClient, err := tsdb.NewBucketClient(context.Background(), t.Cfg.BlocksStorage, "cache-generation", util_log.Logger, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the bucket client of the cache generations")
+	}
+	t.CacheGenerations = tsdb.NewCacheGenerationLoader(bucketClient, t.Cfg.BlocksStorage.CacheGeneration.RefreshInterval, util_log.Logger, prometheus.DefaultRegisterer)
+
+	// Update the config.
+	t.Cfg.QueryRange.ResultsCacheConfig.CacheGenerations = t.CacheGenerations
+	t.Cfg.BlocksStorage.BucketStore.ChunksCache.CacheGenerations = t.CacheGenerations
+	t.Cfg.BlocksStorage.BucketStore.MetadataCache.CacheGenerations = t.CacheGenerations
+
+	return nil, nil
+}
+
 func (t *Cortex) initMemberlistKV() (services.Service, error) {
 	reg := prometheus.DefaultRegisterer
 	t.Cfg.MemberlistKV.MetricsRegisterer = reg
@@ -704,6 +826,45 @@ func (t *Cortex) initMemberlistKV() (services.Service, error) {
 	return t.MemberlistKV, nil
 }
 
+func (t *Cortex) initMultiKV() (services.Service, error) {
+	t.MultiKV = kv.NewMultiClients()
+	t.API.RegisterMultiKV(t.MultiKV)
+
+	// Update the config, so that the migration of all the rings is driven by the runtime config and the admin endpoint.
+	for _, cfg := range []*kv.MultiConfig{
+		&t.Cfg.Distributor.DistributorRing.KVStore.Multi,
+		&t.Cfg.Ingester.LifecyclerConfig.RingConfig.KVStore.Multi,
+		&t.Cfg.StoreGateway.ShardingRing.KVStore.Multi,
+		&t.Cfg.Compactor.ShardingRing.KVStore.Multi,
+		&t.Cfg.Ruler.Ring.KVStore.Multi,
+		&t.Cfg.Alertmanager.ShardingRing.KVStore.Multi,
+	} {
+		cfg.ConfigProvider = multiClientRuntimeConfigChannel(t.RuntimeConfig)
+		cfg.Registry = t.MultiKV
+	}
+
+	return nil, nil
+}
+
+func (t *Cortex) initRingSnapshots() (services.Service, error) {
+	targets := []ring.SnapshotTarget{
+		{Name: "ingester", Key: ingester.RingKey, KVConfig: t.Cfg.Ingester.LifecyclerConfig.RingConfig.KVStore},
+		{Name: "distributor", Key: distributor.RingKey, KVConfig: t.Cfg.Distributor.DistributorRing.KVStore},
+		{Name: "store-gateway", Key: storegateway.RingKey, KVConfig: t.Cfg.StoreGateway.ShardingRing.KVStore},
+		{Name: "compactor", Key: compactor.RingKey, KVConfig: t.Cfg.Compactor.ShardingRing.KVStore},
+		{Name: "ruler", Key: ruler.RingKey, KVConfig: t.Cfg.Ruler.Ring.KVStore},
+		{Name: "alertmanager", Key: alertmanager.RingKey, KVConfig: t.Cfg.Alertmanager.ShardingRing.KVStore},
+	}
+	for i := range targets {
+		// The multi KV clients of the snapshots are not migrated via the admin endpoint, they only follow
+		// the runtime configuration.
+		targets[i].KVConfig.Multi.Registry = nil
+	}
+
+	t.API.RegisterRingSnapshots(ring.NewSnapshotHandler(targets, util_log.Logger))
+	return nil, nil
+}
+
 func (t *Cortex) initTenantDeletionAPI() (services.Service, error) {
 	// t.RulerStorage can be nil when running in single-binary mode, and rule storage is not configured.
 	tenantDeletionAPI, err := purger.NewTenantDeletionAPI(t.Cfg.BlocksStorage, t.Overrides, util_log.Logger, prometheus.DefaultRegisterer)
@@ -715,6 +876,30 @@ func (t *Cortex) initTenantDeletionAPI() (services.Service, error) {
 	return nil, nil
 }
 
+func (t *Cortex) initSeriesDeletionAPI() (services.Service, error) {
+	blocksPurgerAPI, err := purger.NewBlocksPurgerAPI(t.Cfg.BlocksStorage, t.Overrides, util_log.Logger, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, err
+	}
+
+	t.API.RegisterBlocksPurger(blocksPurgerAPI)
+	return nil, nil
+}
+
+func (t *Cortex) initCacheGenerationAPI() (services.Service, error) {
+	if !t.Cfg.BlocksStorage.CacheGeneration.Enabled {
+		return nil, nil
+	}
+
+	cacheGenerationAPI, err := purger.NewCacheGenerationAPI(t.Cfg.BlocksStorage, util_log.Logger, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, err
+	}
+
+	t.API.RegisterCacheGeneration(cacheGenerationAPI)
+	return nil, nil
+}
+
 func (t *Cortex) initQueryScheduler() (services.Service, error) {
 	s, err := scheduler.NewScheduler(t.Cfg.QueryScheduler, t.Overrides, util_log.Logger, prometheus.DefaultRegisterer)
 	if err != nil {
@@ -734,6 +919,10 @@ func (t *Cortex) setupModuleManager() error {
 	mm.RegisterModule(API, t.initAPI, modules.UserInvisibleModule)
 	mm.RegisterModule(RuntimeConfig, t.initRuntimeConfig, modules.UserInvisibleModule)
 	mm.RegisterModule(MemberlistKV, t.initMemberlistKV, modules.UserInvisibleModule)
+	mm.RegisterModule(MultiKV, t.initMultiKV, modules.UserInvisibleModule)
+	mm.RegisterModule(RingSnapshots, t.initRingSnapshots, modules.UserInvisibleModule)
+	mm.RegisterModule(Groupcache, t.initGroupcache, modules.UserInvisibleModule)
+	mm.RegisterModule(CacheGeneration, t.initCacheGeneration, modules.UserInvisibleModule)
 	mm.RegisterModule(Ring, t.initRing, modules.UserInvisibleModule)
 	mm.RegisterModule(Overrides, t.initOverrides, modules.UserInvisibleModule)
 	mm.RegisterModule(OverridesExporter, t.initOverridesExporter)
@@ -754,6 +943,8 @@ func (t *Cortex) setupModuleManager() error {
 	mm.RegisterModule(Compactor, t.initCompactor)
 	mm.RegisterModule(StoreGateway, t.initStoreGateway)
 	mm.RegisterModule(TenantDeletion, t.initTenantDeletionAPI, modules.UserInvisibleModule)
+	mm.RegisterModule(SeriesDeletion, t.initSeriesDeletionAPI, modules.UserInvisibleModule)
+	mm.RegisterModule(CacheGenerationAPI, t.initCacheGenerationAPI, modules.UserInvisibleModule)
 	mm.RegisterModule(Purger, nil)
 	mm.RegisterModule(QueryScheduler, t.initQueryScheduler)
 	mm.RegisterModule(TenantFederation, t.initTenantFederation, modules.UserInvisibleModule)
@@ -763,29 +954,35 @@ func (t *Cortex) setupModuleManager() error {
 	deps := map[string][]string{
 		API:                      {Server},
 		MemberlistKV:             {API},
+		MultiKV:                  {API, RuntimeConfig},
+		RingSnapshots:            {API, MemberlistKV, MultiKV},
+		Groupcache:               {API},
+		CacheGeneration:          {API},
 		RuntimeConfig:            {API},
-		Ring:                     {API, RuntimeConfig, MemberlistKV},
+		Ring:                     {API, RuntimeConfig, MemberlistKV, MultiKV, RingSnapshots},
 		Overrides:                {RuntimeConfig},
 		OverridesExporter:        {RuntimeConfig},
 		Distributor:              {DistributorService, API},
 		DistributorService:       {Ring, Overrides},
 		Ingester:                 {IngesterService, Overrides, API},
-		IngesterService:          {Overrides, RuntimeConfig, MemberlistKV},
+		IngesterService:          {Overrides, RuntimeConfig, MemberlistKV, MultiKV, RingSnapshots},
 		Flusher:                  {Overrides, API},
-		Queryable:                {Overrides, DistributorService, Overrides, Ring, API, StoreQueryable, MemberlistKV},
+		Queryable:                {Overrides, DistributorService, Overrides, Ring, API, StoreQueryable, MemberlistKV, MultiKV},
 		Querier:                  {TenantFederation},
-		StoreQueryable:           {Overrides, Overrides, MemberlistKV},
-		QueryFrontendTripperware: {API, Overrides},
+		StoreQueryable:           {Overrides, Overrides, MemberlistKV, MultiKV, Groupcache, CacheGeneration},
+		QueryFrontendTripperware: {API, Overrides, CacheGeneration},
 		QueryFrontend:            {QueryFrontendTripperware},
 		QueryScheduler:           {API, Overrides},
 		Ruler:                    {DistributorService, Overrides, StoreQueryable, RulerStorage},
 		RulerStorage:             {Overrides},
 		Configs:                  {API},
-		AlertManager:             {API, MemberlistKV, Overrides},
-		Compactor:                {API, MemberlistKV, Overrides},
-		StoreGateway:             {API, Overrides, MemberlistKV},
+		AlertManager:             {API, MemberlistKV, MultiKV, RingSnapshots, Overrides},
+		Compactor:                {API, MemberlistKV, MultiKV, RingSnapshots, Overrides, Groupcache, CacheGeneration},
+		StoreGateway:             {API, Overrides, MemberlistKV, MultiKV, RingSnapshots, Groupcache, CacheGeneration},
 		TenantDeletion:           {API, Overrides},
-		Purger:                   {TenantDeletion},
+		SeriesDeletion:           {API, Overrides},
+		CacheGenerationAPI:       {API},
+		Purger:                   {TenantDeletion, SeriesDeletion, CacheGenerationAPI},
 		TenantFederation:         {Queryable},
 		All:                      {QueryFrontend, Querier, Ingester, Distributor, Purger, StoreGateway, Ruler},
 	}
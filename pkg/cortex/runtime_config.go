@@ -2,6 +2,7 @@ package cortex
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 
@@ -9,6 +10,8 @@ import (
 
 	"github.com/cortexproject/cortex/pkg/ingester"
 	"github.com/cortexproject/cortex/pkg/ring/kv"
+	"github.com/cortexproject/cortex/pkg/storage/bucket/gcs"
+	"github.com/cortexproject/cortex/pkg/storage/bucket/s3"
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/runtimeconfig"
 	"github.com/cortexproject/cortex/pkg/util/validation"
@@ -74,9 +77,30 @@ func loadRuntimeConfig(r io.Reader) (interface{}, error) {
 		return nil, errMultipleDocuments
 	}
 
+	for userID, limits := range overrides.TenantLimits {
+		if limits == nil {
+			continue
+		}
+		if err := validateStorageOverrides(limits); err != nil {
+			return nil, fmt.Errorf("invalid overrides for tenant %s: %w", userID, err)
+		}
+	}
+
 	return overrides, nil
 }
 
+// validateStorageOverrides validates the overrides of the storage backend configs, which are not
+// validated when the limits are unmarshalled to keep the validation package free of backend imports.
+func validateStorageOverrides(limits *validation.Limits) error {
+	if err := s3.ValidateSSEConfigOverride(limits.S3SSEType, limits.S3SSEKMSKeyID, limits.S3SSEKMSEncryptionContext); err != nil {
+		return fmt.Errorf("invalid S3 SSE config override: %w", err)
+	}
+	if err := gcs.ValidateKMSKeyName(limits.GCSKMSKeyName); err != nil {
+		return fmt.Errorf("invalid GCS KMS key name override: %w", err)
+	}
+	return nil
+}
+
 func multiClientRuntimeConfigChannel(manager *runtimeconfig.Manager) func() <-chan kv.MultiRuntimeConfig {
 	if manager == nil {
 		return nil
@@ -109,3 +109,73 @@ overrides:
 		assert.Nil(t, actual)
 	}
 }
+
+func TestLoadRuntimeConfig_ShouldValidateS3SSEConfigOverrides(t *testing.T) {
+	for name, tc := range map[string]struct {
+		input       string
+		expectedErr string
+	}{
+		"valid SSE-KMS override": {
+			input: `
+overrides:
+  user-1:
+    s3_sse_type: SSE-KMS
+    s3_sse_kms_key_id: tenant-key
+    s3_sse_kms_encryption_context: '{"tenant":"tenant-a"}'
+`,
+		},
+		"encryption context ignored without SSE type": {
+			input: `
+overrides:
+  user-1:
+    s3_sse_kms_encryption_context: 'invalid'
+`,
+		},
+		"unsupported SSE type": {
+			input: `
+overrides:
+  user-1:
+    s3_sse_type: SSE-C
+`,
+			expectedErr: "invalid overrides for tenant user-1: invalid S3 SSE config override: unsupported S3 SSE type",
+		},
+		"invalid encryption context": {
+			input: `
+overrides:
+  user-1:
+    s3_sse_type: SSE-KMS
+    s3_sse_kms_key_id: tenant-key
+    s3_sse_kms_encryption_context: 'invalid'
+`,
+			expectedErr: "invalid overrides for tenant user-1: invalid S3 SSE config override: invalid S3 SSE encryption context",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := loadRuntimeConfig(strings.NewReader(tc.input))
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestLoadRuntimeConfig_ShouldValidateGCSKMSKeyNameOverrides(t *testing.T) {
+	runtimeCfg, err := loadRuntimeConfig(strings.NewReader(`
+overrides:
+  user-1:
+    gcs_kms_key_name: projects/test/locations/global/keyRings/cortex/cryptoKeys/tenant
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "projects/test/locations/global/keyRings/cortex/cryptoKeys/tenant", runtimeCfg.(*RuntimeConfigValues).TenantLimits["user-1"].GCSKMSKeyName)
+
+	_, err = loadRuntimeConfig(strings.NewReader(`
+overrides:
+  user-1:
+    gcs_kms_key_name: tenant
+`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid overrides for tenant user-1: invalid GCS KMS key name override")
+}
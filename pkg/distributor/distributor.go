@@ -48,8 +48,13 @@ var (
 	supportedShardingStrategies = []string{util.ShardingStrategyDefault, util.ShardingStrategyShuffle}
 
 	// Validation errors.
-	errInvalidShardingStrategy = errors.New("invalid sharding strategy")
-	errInvalidTenantShardSize  = errors.New("invalid tenant shard size. The value must be greater than or equal to 0")
+	errInvalidShardingStrategy    = errors.New("invalid sharding strategy")
+	errInvalidTenantShardSize     = errors.New("invalid tenant shard size. The value must be greater than or equal to 0")
+	errInvalidBoundedLoadsEpsilon = errors.New("invalid bounded loads epsilon. The value must be greater than 0")
+
+	// Ingestion partitions errors.
+	errPartitionsWithShuffleSharding = errors.New("the ingestion partitions are not supported with the shuffle-sharding strategy")
+	errPartitionsWithBoundedLoads    = errors.New("the ingestion partitions are not supported with the bounded loads")
 
 	// Distributor instance limits errors.
 	errTooManyInflightPushRequests    = errors.New("too many inflight push requests in distributor")
@@ -57,8 +62,8 @@ var (
 )
 
 const (
-	// ringKey is the key under which we store the distributors ring in the KVStore.
-	ringKey = "distributor"
+	// RingKey is the key under which we store the distributors ring in the KVStore.
+	RingKey = "distributor"
 
 	typeSamples  = "samples"
 	typeMetadata = "metadata"
@@ -80,8 +85,10 @@ type Distributor struct {
 	cfg           Config
 	log           log.Logger
 	ingestersRing ring.ReadRing
-	ingesterPool  *ring_client.Pool
-	limits        *validation.Overrides
+	// Only set if the series are assigned to the ingestion partitions.
+	partitionsRing ring.PartitionReadRing
+	ingesterPool   *ring_client.Pool
+	limits         *validation.Overrides
 
 	// The global rate limiter requires a distributors ring to count
 	// the number of healthy instances
@@ -138,6 +145,9 @@ type Config struct {
 	ExtendWrites             bool   `yaml:"extend_writes"`
 	SignWriteRequestsEnabled bool   `yaml:"sign_write_requests"`
 
+	BoundedLoadsEnabled bool    `yaml:"bounded_loads_enabled"`
+	BoundedLoadsEpsilon float64 `yaml:"bounded_loads_epsilon"`
+
 	// Distributors ring
 	DistributorRing RingConfig `yaml:"ring"`
 
@@ -178,6 +188,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&cfg.SignWriteRequestsEnabled, "distributor.sign-write-requests", false, "EXPERIMENTAL: If enabled, sign the write request between distributors and ingesters.")
 	f.StringVar(&cfg.ShardingStrategy, "distributor.sharding-strategy", util.ShardingStrategyDefault, fmt.Sprintf("The sharding strategy to use. Supported values are: %s.", strings.Join(supportedShardingStrategies, ", ")))
 	f.BoolVar(&cfg.ExtendWrites, "distributor.extend-writes", true, "Try writing to an additional ingester in the presence of an ingester not in the ACTIVE state. It is useful to disable this along with -ingester.unregister-on-shutdown=false in order to not spread samples to extra ingesters during rolling restarts with consistent naming.")
+	f.BoolVar(&cfg.BoundedLoadsEnabled, "distributor.bounded-loads-enabled", false, "EXPERIMENTAL: True to assign the series of each write request to the ingesters with consistent hashing with bounded loads, so that no ingester receives more than (1 + epsilon) times the average number of series per ingester of the request. The series exceeding the capacity of their ingesters are sent to the next ingesters in the ring, which may spread the series of a hot metric across more ingesters.")
+	f.Float64Var(&cfg.BoundedLoadsEpsilon, "distributor.bounded-loads-epsilon", 0.25, "EXPERIMENTAL: Maximum load of each ingester above the average load, as a fraction of the average load, when the bounded loads are enabled.")
 	f.BoolVar(&cfg.ZoneResultsQuorumMetadata, "distributor.zone-results-quorum-metadata", false, "Experimental, this flag may change in the future. If zone awareness and this both enabled, when querying metadata APIs (labels names and values for now), only results from quorum number of zones will be included.")
 
 	f.Float64Var(&cfg.InstanceLimits.MaxIngestionRate, "distributor.instance-limits.max-ingestion-rate", 0, "Max ingestion rate (samples/sec) that this distributor will accept. This limit is per-distributor, not per-tenant. Additional push requests will be rejected. Current ingestion rate is computed as exponentially weighted moving average, updated every second. 0 = unlimited.")
@@ -194,6 +206,10 @@ func (cfg *Config) Validate(limits validation.Limits) error {
 		return errInvalidTenantShardSize
 	}
 
+	if cfg.BoundedLoadsEnabled && cfg.BoundedLoadsEpsilon <= 0 {
+		return errInvalidBoundedLoadsEpsilon
+	}
+
 	haHATrackerConfig := cfg.HATrackerConfig.ToHATrackerConfig()
 
 	return haHATrackerConfig.Validate()
@@ -215,6 +231,17 @@ func New(cfg Config, clientConfig ingester_client.Config, limits *validation.Ove
 
 	cfg.PoolConfig.RemoteTimeout = cfg.RemoteTimeout
 
+	var partitionsRing ring.PartitionReadRing
+	if r, ok := ingestersRing.(ring.PartitionReadRing); ok && r.PartitionsEnabled() {
+		if cfg.ShardingStrategy == util.ShardingStrategyShuffle {
+			return nil, errPartitionsWithShuffleSharding
+		}
+		if cfg.BoundedLoadsEnabled {
+			return nil, errPartitionsWithBoundedLoads
+		}
+		partitionsRing = r
+	}
+
 	haTrackerStatusConfig := ha.HATrackerStatusConfig{
 		Title:             "Cortex HA Tracker Status",
 		ReplicaGroupLabel: "Cluster",
@@ -237,12 +264,12 @@ func New(cfg Config, clientConfig ingester_client.Config, limits *validation.Ove
 	if !canJoinDistributorsRing {
 		ingestionRateStrategy = newInfiniteIngestionRateStrategy()
 	} else if limits.IngestionRateStrategy() == validation.GlobalIngestionRateStrategy {
-		distributorsLifeCycler, err = ring.NewLifecycler(cfg.DistributorRing.ToLifecyclerConfig(), nil, "distributor", ringKey, true, true, log, prometheus.WrapRegistererWithPrefix("cortex_", reg))
+		distributorsLifeCycler, err = ring.NewLifecycler(cfg.DistributorRing.ToLifecyclerConfig(), nil, "distributor", RingKey, true, true, log, prometheus.WrapRegistererWithPrefix("cortex_", reg))
 		if err != nil {
 			return nil, err
 		}
 
-		distributorsRing, err = ring.New(cfg.DistributorRing.ToRingConfig(), "distributor", ringKey, log, prometheus.WrapRegistererWithPrefix("cortex_", reg))
+		distributorsRing, err = ring.New(cfg.DistributorRing.ToRingConfig(), "distributor", RingKey, log, prometheus.WrapRegistererWithPrefix("cortex_", reg))
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to initialize distributors' ring client")
 		}
@@ -257,6 +284,7 @@ func New(cfg Config, clientConfig ingester_client.Config, limits *validation.Ove
 		cfg:                    cfg,
 		log:                    log,
 		ingestersRing:          ingestersRing,
+		partitionsRing:         partitionsRing,
 		ingesterPool:           NewPool(cfg.PoolConfig, ingestersRing, cfg.IngesterClientFactory, log),
 		distributorsLifeCycler: distributorsLifeCycler,
 		distributorsRing:       distributorsRing,
@@ -767,7 +795,18 @@ func (d *Distributor) doBatch(ctx context.Context, req *cortexpb.WriteRequest, s
 		op = ring.Write
 	}
 
-	return ring.DoBatch(ctx, op, subRing, keys, func(ingester ring.InstanceDesc, indexes []int) error {
+	doBatch := ring.DoBatch
+	if d.cfg.BoundedLoadsEnabled {
+		doBatch = func(ctx context.Context, op ring.Operation, r ring.ReadRing, keys []uint32, callback func(ring.InstanceDesc, []int) error, cleanup func()) error {
+			return ring.DoBatchWithBoundedLoads(ctx, op, r, keys, d.cfg.BoundedLoadsEpsilon, callback, cleanup)
+		}
+	} else if d.partitionsRing != nil {
+		doBatch = func(ctx context.Context, op ring.Operation, _ ring.ReadRing, keys []uint32, callback func(ring.InstanceDesc, []int) error, cleanup func()) error {
+			return ring.DoBatchWithPartitions(ctx, op, d.partitionsRing, keys, callback, cleanup)
+		}
+	}
+
+	return doBatch(ctx, op, subRing, keys, func(ingester ring.InstanceDesc, indexes []int) error {
 		timeseries := make([]cortexpb.PreallocTimeseries, 0, len(indexes))
 		var metadata []*cortexpb.MetricMetadata
 
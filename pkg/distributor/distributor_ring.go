@@ -20,6 +20,7 @@ import (
 type RingConfig struct {
 	KVStore          kv.Config     `yaml:"kvstore"`
 	HeartbeatPeriod  time.Duration `yaml:"heartbeat_period"`
+	CASBatchPeriod   time.Duration `yaml:"cas_batch_period"`
 	HeartbeatTimeout time.Duration `yaml:"heartbeat_timeout"`
 
 	// Instance details
@@ -43,6 +44,7 @@ func (cfg *RingConfig) RegisterFlags(f *flag.FlagSet) {
 	// Ring flags
 	cfg.KVStore.RegisterFlagsWithPrefix("distributor.ring.", "collectors/", f)
 	f.DurationVar(&cfg.HeartbeatPeriod, "distributor.ring.heartbeat-period", 5*time.Second, "Period at which to heartbeat to the ring. 0 = disabled.")
+	f.DurationVar(&cfg.CASBatchPeriod, "distributor.ring.cas-batch-period", 0, "EXPERIMENTAL: Period within which the ring updates of the distributor, including the heartbeats, are batched with the ring updates of the other components of the same process into a single KV store transaction. When enabled, the heartbeats are aligned to the heartbeat period, so that the heartbeats of the components with the same heartbeat period, or a multiple of it, are batched together. Supported by the consul, etcd and memberlist KV stores. 0 = disabled.")
 	f.DurationVar(&cfg.HeartbeatTimeout, "distributor.ring.heartbeat-timeout", time.Minute, "The heartbeat timeout after which distributors are considered unhealthy within the ring. 0 = never (timeout disabled).")
 
 	// Instance flags
@@ -78,6 +80,7 @@ func (cfg *RingConfig) ToLifecyclerConfig() ring.LifecyclerConfig {
 	lc.InfNames = cfg.InstanceInterfaceNames
 	lc.UnregisterOnShutdown = true
 	lc.HeartbeatPeriod = cfg.HeartbeatPeriod
+	lc.CASBatchPeriod = cfg.CASBatchPeriod
 	lc.ObservePeriod = 0
 	lc.NumTokens = 1
 	lc.JoinAfter = 0
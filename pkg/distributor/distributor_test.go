@@ -1599,6 +1599,92 @@ func TestDistributor_Push_ShouldGuaranteeShardingTokenConsistencyOverTheTime(t *
 	}
 }
 
+func TestDistributor_Push_ShouldSendSeriesToTheOwnersOfTheirPartition(t *testing.T) {
+	t.Parallel()
+	ctx := user.InjectOrgID(context.Background(), "user")
+
+	ds, ingesters, _, r := prepare(t, prepConfig{
+		numIngesters:      4,
+		happyIngesters:    4,
+		numDistributors:   1,
+		shardByAllLabels:  true,
+		partitionsEnabled: true,
+	})
+	test.Poll(t, time.Second, 4, func() interface{} {
+		return r.PartitionsCount()
+	})
+
+	_, err := ds[0].Push(ctx, makeWriteRequest(0, 100, 0))
+	require.NoError(t, err)
+
+	// Each partition is owned by a single ingester, so each series is sent to a single ingester.
+	total := 0
+	for i := range ingesters {
+		for key := range ingesters[i].series() {
+			partition, err := r.GetPartition(key)
+			require.NoError(t, err)
+			assert.Equal(t, int32(i), partition)
+			total++
+		}
+	}
+	assert.Equal(t, 100, total)
+}
+
+func TestDistributor_New_ShouldFailWithPartitionsAndUnsupportedConfig(t *testing.T) {
+	t.Parallel()
+
+	kvStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	ingestersRing, err := ring.New(ring.Config{
+		KVStore:           kv.Config{Mock: kvStore},
+		HeartbeatTimeout:  time.Minute,
+		ReplicationFactor: 3,
+		PartitionsEnabled: true,
+	}, ingester.RingKey, ingester.RingKey, nil, nil)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		prepareConfig func(cfg *Config)
+		expectedErr   error
+	}{
+		"default sharding strategy": {
+			prepareConfig: func(cfg *Config) {},
+		},
+		"shuffle-sharding strategy": {
+			prepareConfig: func(cfg *Config) {
+				cfg.ShardingStrategy = util.ShardingStrategyShuffle
+			},
+			expectedErr: errPartitionsWithShuffleSharding,
+		},
+		"bounded loads": {
+			prepareConfig: func(cfg *Config) {
+				cfg.BoundedLoadsEnabled = true
+			},
+			expectedErr: errPartitionsWithBoundedLoads,
+		},
+	}
+
+	for testName, testData := range tests {
+		testData := testData
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			var cfg Config
+			var clientConfig client.Config
+			limits := validation.Limits{}
+			flagext.DefaultValues(&cfg, &clientConfig, &limits)
+			testData.prepareConfig(&cfg)
+
+			overrides, err := validation.NewOverrides(limits, nil)
+			require.NoError(t, err)
+
+			_, err = New(cfg, clientConfig, overrides, ingestersRing, false, nil, log.NewNopLogger())
+			assert.Equal(t, testData.expectedErr, err)
+		})
+	}
+}
+
 func TestDistributor_Push_LabelNameValidation(t *testing.T) {
 	t.Parallel()
 	inputLabels := labels.Labels{
@@ -2524,6 +2610,7 @@ type prepConfig struct {
 	enableTracker                bool
 	errFail                      error
 	tokens                       [][]uint32
+	partitionsEnabled            bool
 }
 
 type prepState struct {
@@ -2602,6 +2689,7 @@ func prepare(tb testing.TB, cfg prepConfig) ([]*Distributor, []*mockIngester, []
 		},
 		HeartbeatTimeout:  60 * time.Minute,
 		ReplicationFactor: rf,
+		PartitionsEnabled: cfg.partitionsEnabled,
 	}, ingester.RingKey, ingester.RingKey, nil, nil)
 	require.NoError(tb, err)
 	require.NoError(tb, services.StartAndAwaitRunning(context.Background(), ingestersRing))
@@ -130,6 +130,8 @@ type Config struct {
 
 	// For admin contact details
 	AdminLimitMessage string `yaml:"admin_limit_message"`
+
+	BucketRateLimit bucket.RateLimitConfig `yaml:"bucket_rate_limit"`
 }
 
 // RegisterFlags adds the flags required to config this to the given FlagSet
@@ -153,6 +155,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 
 	f.StringVar(&cfg.AdminLimitMessage, "ingester.admin-limit-message", "please contact administrator to raise it", "Customize the message contained in limit errors")
 
+	cfg.BucketRateLimit.RegisterFlagsWithPrefix("ingester.bucket-rate-limit.", "ingester shipping the blocks", f)
+
 }
 
 func (cfg *Config) Validate() error {
@@ -195,6 +199,7 @@ type Ingester struct {
 	logger  log.Logger
 
 	lifecycler         *ring.Lifecycler
+	preparation        *ring.InstancePreparation
 	limits             *validation.Overrides
 	limiter            *Limiter
 	subservicesWatcher *services.FailureWatcher
@@ -629,10 +634,11 @@ func New(cfg Config, limits *validation.Overrides, registerer prometheus.Registe
 		cfg.ingesterClientFactory = client.MakeIngesterClient
 	}
 
-	bucketClient, err := bucket.NewClient(context.Background(), cfg.BlocksStorageConfig.Bucket, "ingester", logger, registerer)
+	bucketClient, err := cortex_tsdb.NewBucketClient(context.Background(), cfg.BlocksStorageConfig, "ingester", logger, registerer)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create the bucket client")
 	}
+	bucketClient = bucket.NewRateLimitedBucketClient(bucketClient, cfg.BucketRateLimit, "ingester", registerer)
 
 	i := &Ingester{
 		cfg:           cfg,
@@ -673,6 +679,14 @@ func New(cfg Config, limits *validation.Overrides, registerer prometheus.Registe
 	i.subservicesWatcher = services.NewFailureWatcher()
 	i.subservicesWatcher.WatchService(i.lifecycler)
 
+	i.preparation = ring.NewInstancePreparation(ring.PreparationHooks{
+		SetHandOffOnShutdown: i.setFlushAndUnregisterOnShutdown,
+		ChangeState:          i.lifecycler.ChangeState,
+		HandOff: func(context.Context) error {
+			return i.compactAndShipBlocks(i.logger, util.NewAllowedTenants(nil, nil))
+		},
+	}, logger)
+
 	// Init the limter and instantiate the user states which depend on it
 	i.limiter = NewLimiter(
 		limits,
@@ -681,6 +695,7 @@ func New(cfg Config, limits *validation.Overrides, registerer prometheus.Registe
 		cfg.DistributorShardByAllLabels,
 		cfg.LifecyclerConfig.RingConfig.ReplicationFactor,
 		cfg.LifecyclerConfig.RingConfig.ZoneAwarenessEnabled,
+		cfg.LifecyclerConfig.RingConfig.PartitionsEnabled,
 		cfg.AdminLimitMessage,
 	)
 
@@ -702,10 +717,11 @@ func New(cfg Config, limits *validation.Overrides, registerer prometheus.Registe
 // this is a special version of ingester used by Flusher. This ingester is not ingesting anything, its only purpose is to react
 // on Flush method and flush all opened TSDBs when called.
 func NewForFlusher(cfg Config, limits *validation.Overrides, registerer prometheus.Registerer, logger log.Logger) (*Ingester, error) {
-	bucketClient, err := bucket.NewClient(context.Background(), cfg.BlocksStorageConfig.Bucket, "ingester", logger, registerer)
+	bucketClient, err := cortex_tsdb.NewBucketClient(context.Background(), cfg.BlocksStorageConfig, "ingester", logger, registerer)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create the bucket client")
 	}
+	bucketClient = bucket.NewRateLimitedBucketClient(bucketClient, cfg.BucketRateLimit, "ingester", registerer)
 
 	i := &Ingester{
 		cfg:       cfg,
@@ -947,6 +963,27 @@ func (i *Ingester) ShutdownHandler(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// PrepareShutdownHandler prepares the ingester to flush its TSDB blocks and to leave the ring when it shuts down.
+func (i *Ingester) PrepareShutdownHandler(w http.ResponseWriter, r *http.Request) {
+	i.preparation.PrepareShutdownHandler(w, r)
+}
+
+// PrepareDownscaleHandler switches the ingester to LEAVING, so that it stops receiving writes while still serving
+// the queries, and flushes its TSDB blocks.
+func (i *Ingester) PrepareDownscaleHandler(w http.ResponseWriter, r *http.Request) {
+	i.preparation.PrepareDownscaleHandler(w, r)
+}
+
+func (i *Ingester) setFlushAndUnregisterOnShutdown(enabled bool) {
+	if enabled {
+		i.lifecycler.SetFlushOnShutdown(true)
+		i.lifecycler.SetUnregisterOnShutdown(true)
+		return
+	}
+	i.lifecycler.SetFlushOnShutdown(i.cfg.BlocksStorageConfig.TSDB.FlushBlocksOnShutdown)
+	i.lifecycler.SetUnregisterOnShutdown(i.cfg.LifecyclerConfig.UnregisterOnShutdown)
+}
+
 // check that ingester has finished starting, i.e. it is in Running or Stopping state.
 // Why Stopping? Because ingester still runs, even when it is transferring data out in Stopping state.
 // Ingester handles this state on its own (via `stopped` flag).
@@ -2735,66 +2772,73 @@ func (i *Ingester) flushHandler(w http.ResponseWriter, r *http.Request) {
 
 	allowedUsers := util.NewAllowedTenants(tenants, nil)
 	run := func() {
-		ingCtx := i.BasicService.ServiceContext()
-		if ingCtx == nil || ingCtx.Err() != nil {
-			level.Info(logutil.WithContext(r.Context(), i.logger)).Log("msg", "flushing TSDB blocks: ingester not running, ignoring flush request")
-			return
-		}
+		_ = i.compactAndShipBlocks(logutil.WithContext(r.Context(), i.logger), allowedUsers)
+	}
 
-		compactionCallbackCh := make(chan struct{})
+	if len(r.Form[waitParam]) > 0 && r.Form[waitParam][0] == "true" {
+		// Run synchronously. This simplifies and speeds up tests.
+		run()
+	} else {
+		go run()
+	}
 
-		level.Info(logutil.WithContext(r.Context(), i.logger)).Log("msg", "flushing TSDB blocks: triggering compaction")
-		select {
-		case i.TSDBState.forceCompactTrigger <- requestWithUsersAndCallback{users: allowedUsers, callback: compactionCallbackCh}:
-			// Compacting now.
-		case <-ingCtx.Done():
-			level.Warn(logutil.WithContext(r.Context(), i.logger)).Log("msg", "failed to compact TSDB blocks, ingester not running anymore")
-			return
-		}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-		// Wait until notified about compaction being finished.
-		select {
-		case <-compactionCallbackCh:
-			level.Info(logutil.WithContext(r.Context(), i.logger)).Log("msg", "finished compacting TSDB blocks")
-		case <-ingCtx.Done():
-			level.Warn(logutil.WithContext(r.Context(), i.logger)).Log("msg", "failed to compact TSDB blocks, ingester not running anymore")
-			return
-		}
+// compactAndShipBlocks force-compacts the TSDB heads of the allowed users, and ships their blocks if the
+// shipping is enabled.
+func (i *Ingester) compactAndShipBlocks(logger log.Logger, allowedUsers *util.AllowedTenants) error {
+	ingCtx := i.BasicService.ServiceContext()
+	if ingCtx == nil || ingCtx.Err() != nil {
+		level.Info(logger).Log("msg", "flushing TSDB blocks: ingester not running, ignoring flush request")
+		return errIngesterStopping
+	}
 
-		if i.cfg.BlocksStorageConfig.TSDB.IsBlocksShippingEnabled() {
-			shippingCallbackCh := make(chan struct{}) // must be new channel, as compactionCallbackCh is closed now.
+	compactionCallbackCh := make(chan struct{})
 
-			level.Info(logutil.WithContext(r.Context(), i.logger)).Log("msg", "flushing TSDB blocks: triggering shipping")
+	level.Info(logger).Log("msg", "flushing TSDB blocks: triggering compaction")
+	select {
+	case i.TSDBState.forceCompactTrigger <- requestWithUsersAndCallback{users: allowedUsers, callback: compactionCallbackCh}:
+		// Compacting now.
+	case <-ingCtx.Done():
+		level.Warn(logger).Log("msg", "failed to compact TSDB blocks, ingester not running anymore")
+		return errIngesterStopping
+	}
 
-			select {
-			case i.TSDBState.shipTrigger <- requestWithUsersAndCallback{users: allowedUsers, callback: shippingCallbackCh}:
-				// shipping now
-			case <-ingCtx.Done():
-				level.Warn(logutil.WithContext(r.Context(), i.logger)).Log("msg", "failed to ship TSDB blocks, ingester not running anymore")
-				return
-			}
+	// Wait until notified about compaction being finished.
+	select {
+	case <-compactionCallbackCh:
+		level.Info(logger).Log("msg", "finished compacting TSDB blocks")
+	case <-ingCtx.Done():
+		level.Warn(logger).Log("msg", "failed to compact TSDB blocks, ingester not running anymore")
+		return errIngesterStopping
+	}
 
-			// Wait until shipping finished.
-			select {
-			case <-shippingCallbackCh:
-				level.Info(logutil.WithContext(r.Context(), i.logger)).Log("msg", "shipping of TSDB blocks finished")
-			case <-ingCtx.Done():
-				level.Warn(logutil.WithContext(r.Context(), i.logger)).Log("msg", "failed to ship TSDB blocks, ingester not running anymore")
-				return
-			}
-		}
+	if i.cfg.BlocksStorageConfig.TSDB.IsBlocksShippingEnabled() {
+		shippingCallbackCh := make(chan struct{}) // must be new channel, as compactionCallbackCh is closed now.
 
-		level.Info(logutil.WithContext(r.Context(), i.logger)).Log("msg", "flushing TSDB blocks: finished")
-	}
+		level.Info(logger).Log("msg", "flushing TSDB blocks: triggering shipping")
 
-	if len(r.Form[waitParam]) > 0 && r.Form[waitParam][0] == "true" {
-		// Run synchronously. This simplifies and speeds up tests.
-		run()
-	} else {
-		go run()
+		select {
+		case i.TSDBState.shipTrigger <- requestWithUsersAndCallback{users: allowedUsers, callback: shippingCallbackCh}:
+			// shipping now
+		case <-ingCtx.Done():
+			level.Warn(logger).Log("msg", "failed to ship TSDB blocks, ingester not running anymore")
+			return errIngesterStopping
+		}
+
+		// Wait until shipping finished.
+		select {
+		case <-shippingCallbackCh:
+			level.Info(logger).Log("msg", "shipping of TSDB blocks finished")
+		case <-ingCtx.Done():
+			level.Warn(logger).Log("msg", "failed to ship TSDB blocks, ingester not running anymore")
+			return errIngesterStopping
+		}
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	level.Info(logger).Log("msg", "flushing TSDB blocks: finished")
+	return nil
 }
 
 // metadataQueryRange returns the best range to query for metadata queries based on the timerange in the ingester.
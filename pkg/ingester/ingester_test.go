@@ -3079,6 +3079,59 @@ func TestIngester_flushing(t *testing.T) {
 			},
 		},
 
+		"prepareShutdownHandler": {
+			setupIngester: func(cfg *Config) {
+				cfg.BlocksStorageConfig.TSDB.FlushBlocksOnShutdown = false
+				cfg.BlocksStorageConfig.TSDB.KeepUserTSDBOpenOnShutdown = true
+			},
+
+			action: func(t *testing.T, i *Ingester, reg *prometheus.Registry) {
+				pushSingleSampleWithMetadata(t, i)
+
+				rec := httptest.NewRecorder()
+				i.PrepareShutdownHandler(rec, httptest.NewRequest("POST", "/ingester/prepare-shutdown", nil))
+				require.Equal(t, http.StatusOK, rec.Code)
+				assert.Contains(t, rec.Body.String(), `"ready_for_termination":true`)
+
+				// Shutdown ingester. This triggers flushing of the block, because the shutdown has been prepared.
+				require.NoError(t, services.StopAndAwaitTerminated(context.Background(), i))
+
+				verifyCompactedHead(t, i, true)
+				require.NoError(t, testutil.GatherAndCompare(reg, bytes.NewBufferString(`
+		# HELP cortex_ingester_shipper_uploads_total Total number of uploaded TSDB blocks
+		# TYPE cortex_ingester_shipper_uploads_total counter
+		cortex_ingester_shipper_uploads_total 1
+	`), "cortex_ingester_shipper_uploads_total"))
+			},
+		},
+
+		"prepareDownscaleHandler": {
+			setupIngester: func(cfg *Config) {
+				cfg.BlocksStorageConfig.TSDB.FlushBlocksOnShutdown = false
+			},
+
+			action: func(t *testing.T, i *Ingester, reg *prometheus.Registry) {
+				pushSingleSampleWithMetadata(t, i)
+
+				rec := httptest.NewRecorder()
+				i.PrepareDownscaleHandler(rec, httptest.NewRequest("POST", "/ingester/prepare-downscale", nil))
+				require.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, ring.LEAVING, i.lifecycler.GetState())
+
+				// The ingester is ready for the termination once its blocks have been flushed.
+				test.Poll(t, 5*time.Second, true, func() interface{} {
+					return i.preparation.Status().ReadyForTermination
+				})
+
+				verifyCompactedHead(t, i, true)
+				require.NoError(t, testutil.GatherAndCompare(reg, bytes.NewBufferString(`
+		# HELP cortex_ingester_shipper_uploads_total Total number of uploaded TSDB blocks
+		# TYPE cortex_ingester_shipper_uploads_total counter
+		cortex_ingester_shipper_uploads_total 1
+	`), "cortex_ingester_shipper_uploads_total"))
+			},
+		},
+
 		"flushHandler": {
 			setupIngester: func(cfg *Config) {
 				cfg.BlocksStorageConfig.TSDB.FlushBlocksOnShutdown = false
@@ -21,6 +21,7 @@ var (
 // to count members
 type RingCount interface {
 	HealthyInstancesCount() int
+	HealthyPartitionsCount() int
 	ZonesCount() int
 }
 
@@ -33,6 +34,7 @@ type Limiter struct {
 	shuffleShardingEnabled bool
 	shardByAllLabels       bool
 	zoneAwarenessEnabled   bool
+	partitionsEnabled      bool
 	AdminLimitMessage      string
 }
 
@@ -44,6 +46,7 @@ func NewLimiter(
 	shardByAllLabels bool,
 	replicationFactor int,
 	zoneAwarenessEnabled bool,
+	partitionsEnabled bool,
 	AdminLimitMessage string,
 ) *Limiter {
 	return &Limiter{
@@ -53,6 +56,7 @@ func NewLimiter(
 		shuffleShardingEnabled: shardingStrategy == util.ShardingStrategyShuffle,
 		shardByAllLabels:       shardByAllLabels,
 		zoneAwarenessEnabled:   zoneAwarenessEnabled,
+		partitionsEnabled:      partitionsEnabled,
 		AdminLimitMessage:      AdminLimitMessage,
 	}
 }
@@ -243,6 +247,10 @@ func (l *Limiter) convertGlobalToLocalLimit(userID string, globalLimit int) int
 		return 0
 	}
 
+	if l.partitionsEnabled {
+		return l.convertGlobalToPartitionLimit(globalLimit)
+	}
+
 	// Given we don't need a super accurate count (ie. when the ingesters
 	// topology changes) and we prefer to always be in favor of the tenant,
 	// we can use a per-ingester limit equal to:
@@ -266,6 +274,23 @@ func (l *Limiter) convertGlobalToLocalLimit(userID string, globalLimit int) int
 	return int((float64(globalLimit) / float64(numIngesters)) * float64(l.replicationFactor))
 }
 
+// convertGlobalToPartitionLimit converts the global limit to the local limit of an ingester owning an ingestion
+// partition. The series of each partition are replicated to all its owners, so an ingester stores all the series
+// of its partition regardless of the number of owners per partition, and the series of a tenant are spread across
+// all the partitions, given the shuffle sharding isn't supported with the partitions:
+// (global limit / number of partitions)
+func (l *Limiter) convertGlobalToPartitionLimit(globalLimit int) int {
+	numPartitions := l.ring.HealthyPartitionsCount()
+
+	// May happen because the number of partitions is asynchronously updated.
+	// If happens, we just temporarily ignore the global limit.
+	if numPartitions == 0 {
+		return 0
+	}
+
+	return int(float64(globalLimit) / float64(numPartitions))
+}
+
 func (l *Limiter) getShardSize(userID string) int {
 	if !l.shuffleShardingEnabled {
 		return 0
@@ -223,12 +223,12 @@ func runLimiterMaxFunctionTest(
 			require.NoError(t, err)
 
 			// Assert on default sharding strategy.
-			limiter := NewLimiter(overrides, ring, util.ShardingStrategyDefault, testData.shardByAllLabels, testData.ringReplicationFactor, testData.ringZoneAwarenessEnabled, "")
+			limiter := NewLimiter(overrides, ring, util.ShardingStrategyDefault, testData.shardByAllLabels, testData.ringReplicationFactor, testData.ringZoneAwarenessEnabled, false, "")
 			actual := runMaxFn(limiter)
 			assert.Equal(t, testData.expectedDefaultSharding, actual)
 
 			// Assert on shuffle sharding strategy.
-			limiter = NewLimiter(overrides, ring, util.ShardingStrategyShuffle, testData.shardByAllLabels, testData.ringReplicationFactor, testData.ringZoneAwarenessEnabled, "")
+			limiter = NewLimiter(overrides, ring, util.ShardingStrategyShuffle, testData.shardByAllLabels, testData.ringReplicationFactor, testData.ringZoneAwarenessEnabled, false, "")
 			actual = runMaxFn(limiter)
 			assert.Equal(t, testData.expectedShuffleSharding, actual)
 		})
@@ -290,7 +290,7 @@ func TestLimiter_AssertMaxSeriesPerMetric(t *testing.T) {
 			}, nil)
 			require.NoError(t, err)
 
-			limiter := NewLimiter(limits, ring, util.ShardingStrategyDefault, testData.shardByAllLabels, testData.ringReplicationFactor, false, "")
+			limiter := NewLimiter(limits, ring, util.ShardingStrategyDefault, testData.shardByAllLabels, testData.ringReplicationFactor, false, false, "")
 			actual := limiter.AssertMaxSeriesPerMetric("test", testData.series)
 
 			assert.Equal(t, testData.expected, actual)
@@ -352,7 +352,7 @@ func TestLimiter_AssertMaxMetadataPerMetric(t *testing.T) {
 			}, nil)
 			require.NoError(t, err)
 
-			limiter := NewLimiter(limits, ring, util.ShardingStrategyDefault, testData.shardByAllLabels, testData.ringReplicationFactor, false, "")
+			limiter := NewLimiter(limits, ring, util.ShardingStrategyDefault, testData.shardByAllLabels, testData.ringReplicationFactor, false, false, "")
 			actual := limiter.AssertMaxMetadataPerMetric("test", testData.metadata)
 
 			assert.Equal(t, testData.expected, actual)
@@ -415,7 +415,7 @@ func TestLimiter_AssertMaxSeriesPerUser(t *testing.T) {
 			}, nil)
 			require.NoError(t, err)
 
-			limiter := NewLimiter(limits, ring, util.ShardingStrategyDefault, testData.shardByAllLabels, testData.ringReplicationFactor, false, "")
+			limiter := NewLimiter(limits, ring, util.ShardingStrategyDefault, testData.shardByAllLabels, testData.ringReplicationFactor, false, false, "")
 			actual := limiter.AssertMaxSeriesPerUser("test", testData.series)
 
 			assert.Equal(t, testData.expected, actual)
@@ -478,7 +478,7 @@ func TestLimiter_AssertMaxMetricsWithMetadataPerUser(t *testing.T) {
 			}, nil)
 			require.NoError(t, err)
 
-			limiter := NewLimiter(limits, ring, util.ShardingStrategyDefault, testData.shardByAllLabels, testData.ringReplicationFactor, false, "")
+			limiter := NewLimiter(limits, ring, util.ShardingStrategyDefault, testData.shardByAllLabels, testData.ringReplicationFactor, false, false, "")
 			actual := limiter.AssertMaxMetricsWithMetadataPerUser("test", testData.metadata)
 
 			assert.Equal(t, testData.expected, actual)
@@ -501,7 +501,7 @@ func TestLimiter_FormatError(t *testing.T) {
 	}, nil)
 	require.NoError(t, err)
 
-	limiter := NewLimiter(limits, ring, util.ShardingStrategyDefault, true, 3, false, "please contact administrator to raise it")
+	limiter := NewLimiter(limits, ring, util.ShardingStrategyDefault, true, 3, false, false, "please contact administrator to raise it")
 
 	actual := limiter.FormatError("user-1", errMaxSeriesPerUserLimitExceeded)
 	assert.EqualError(t, actual, "per-user series limit of 100 exceeded, please contact administrator to raise it (local limit: 0 global limit: 100 actual local limit: 100)")
@@ -520,6 +520,52 @@ func TestLimiter_FormatError(t *testing.T) {
 	assert.Equal(t, input, actual)
 }
 
+func TestLimiter_ShouldConvertGlobalLimitToPartitionLimit(t *testing.T) {
+	tests := map[string]struct {
+		healthyPartitions int
+		expected          int
+	}{
+		"no healthy partition": {
+			healthyPartitions: 0,
+			expected:          math.MaxInt32,
+		},
+		"single partition": {
+			healthyPartitions: 1,
+			expected:          900,
+		},
+		"multiple partitions": {
+			// Each partition is owned by 3 ingesters, one per zone, storing all its series.
+			healthyPartitions: 3,
+			expected:          300,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			// Mock the ring, with 3 owners per partition.
+			ring := &ringCountMock{}
+			ring.On("HealthyInstancesCount").Return(testData.healthyPartitions * 3)
+			ring.On("HealthyPartitionsCount").Return(testData.healthyPartitions)
+			ring.On("ZonesCount").Return(3)
+
+			limits, err := validation.NewOverrides(validation.Limits{
+				MaxGlobalSeriesPerUser:              900,
+				MaxGlobalSeriesPerMetric:            900,
+				MaxGlobalMetricsWithMetadataPerUser: 900,
+				MaxGlobalMetadataPerMetric:          900,
+			}, nil)
+			require.NoError(t, err)
+
+			// The replication factor is ignored with the partitions.
+			limiter := NewLimiter(limits, ring, util.ShardingStrategyDefault, true, 1, true, true, "")
+			assert.Equal(t, testData.expected, limiter.maxSeriesPerUser("test"))
+			assert.Equal(t, testData.expected, limiter.maxSeriesPerMetric("test"))
+			assert.Equal(t, testData.expected, limiter.maxMetadataPerUser("test"))
+			assert.Equal(t, testData.expected, limiter.maxMetadataPerMetric("test"))
+		})
+	}
+}
+
 func TestLimiter_minNonZero(t *testing.T) {
 	t.Parallel()
 
@@ -573,6 +619,11 @@ func (m *ringCountMock) HealthyInstancesCount() int {
 	return args.Int(0)
 }
 
+func (m *ringCountMock) HealthyPartitionsCount() int {
+	args := m.Called()
+	return args.Int(0)
+}
+
 func (m *ringCountMock) ZonesCount() int {
 	args := m.Called()
 	return args.Int(0)
@@ -77,7 +77,7 @@ func TestMetricCounter(t *testing.T) {
 
 			// We're testing code that's not dependent on sharding strategy, replication factor, etc. To simplify the test,
 			// we use local limit only.
-			limiter := NewLimiter(overrides, nil, util.ShardingStrategyDefault, true, 3, false, "")
+			limiter := NewLimiter(overrides, nil, util.ShardingStrategyDefault, true, 3, false, false, "")
 			mc := newMetricCounter(limiter, ignored)
 
 			for i := 0; i < tc.series; i++ {
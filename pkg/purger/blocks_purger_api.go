@@ -0,0 +1,210 @@
+package purger
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/thanos-io/objstore"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+)
+
+// BlocksPurgerConfigProvider exposes the per-tenant configuration used by the BlocksPurgerAPI.
+type BlocksPurgerConfigProvider interface {
+	bucket.TenantConfigProvider
+
+	// DeleteRequestCancelPeriod returns the period during which a series deletion request can be cancelled.
+	DeleteRequestCancelPeriod(userID string) time.Duration
+}
+
+// BlocksPurgerAPI exposes the series deletion API for the blocks storage. Deletion requests are
+// stored as tombstones in the bucket, and then processed by the compactor.
+type BlocksPurgerAPI struct {
+	bucketClient objstore.InstrumentedBucket
+	logger       log.Logger
+	cfgProvider  BlocksPurgerConfigProvider
+}
+
+func NewBlocksPurgerAPI(storageCfg cortex_tsdb.BlocksStorageConfig, cfgProvider BlocksPurgerConfigProvider, logger log.Logger, reg prometheus.Registerer) (*BlocksPurgerAPI, error) {
+	bucketClient, err := createBucketClient(storageCfg, "series-deletion", logger, reg)
+	if err != nil {
+		return nil, err
+	}
+
+	return newBlocksPurgerAPI(bucketClient, cfgProvider, logger), nil
+}
+
+func newBlocksPurgerAPI(bkt objstore.InstrumentedBucket, cfgProvider BlocksPurgerConfigProvider, logger log.Logger) *BlocksPurgerAPI {
+	return &BlocksPurgerAPI{
+		bucketClient: bkt,
+		cfgProvider:  cfgProvider,
+		logger:       logger,
+	}
+}
+
+// AddDeleteRequestHandler creates a series deletion request. The request parameters are the same
+// of the Prometheus delete series API.
+func (api *BlocksPurgerAPI) AddDeleteRequestHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	selectors := r.Form["match[]"]
+	if len(selectors) == 0 {
+		http.Error(w, "selectors not set", http.StatusBadRequest)
+		return
+	}
+
+	for _, selector := range selectors {
+		if _, err := parser.ParseMetricSelector(selector); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	now := time.Now()
+
+	startTime, err := util.ParseTimeParam(r, "start", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	endTime, err := util.ParseTimeParam(r, "end", now.Unix())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if endTime > now.UnixMilli() {
+		http.Error(w, "deletion end time can't be greater than current time", http.StatusBadRequest)
+		return
+	}
+
+	if startTime > endTime {
+		http.Error(w, "start time can't be greater than end time", http.StatusBadRequest)
+		return
+	}
+
+	userBucket := bucket.NewUserBucketClient(userID, api.bucketClient, api.cfgProvider)
+	tombstone := cortex_tsdb.NewTombstone(userID, now, startTime, endTime, selectors)
+
+	// The request ID is a hash of the request parameters, so we check whether the same request already exists.
+	prev, err := cortex_tsdb.GetTombstone(ctx, userBucket, tombstone.RequestID)
+	if err != nil && !errors.Is(err, cortex_tsdb.ErrTombstoneNotFound) {
+		level.Error(util_log.WithUserID(userID, api.logger)).Log("msg", "failed to read tombstone", "request_id", tombstone.RequestID, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if prev != nil && prev.State != cortex_tsdb.StateCancelled {
+		// The same request already exists, nothing to do.
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if prev != nil {
+		// The same request was previously cancelled, so we replace it.
+		if err := cortex_tsdb.DeleteTombstone(ctx, userBucket, prev.RequestID); err != nil {
+			level.Error(util_log.WithUserID(userID, api.logger)).Log("msg", "failed to delete cancelled tombstone", "request_id", prev.RequestID, "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := cortex_tsdb.WriteTombstone(ctx, userBucket, tombstone); err != nil {
+		level.Error(util_log.WithUserID(userID, api.logger)).Log("msg", "failed to write tombstone", "request_id", tombstone.RequestID, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	level.Info(util_log.WithUserID(userID, api.logger)).Log("msg", "series deletion request created", "request_id", tombstone.RequestID, "start", startTime, "end", endTime)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAllDeleteRequestsHandler returns all the series deletion requests of the tenant.
+func (api *BlocksPurgerAPI) GetAllDeleteRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	userBucket := bucket.NewUserBucketClient(userID, api.bucketClient, api.cfgProvider)
+	tombstones, err := cortex_tsdb.GetAllTombstones(ctx, userBucket)
+	if err != nil {
+		level.Error(util_log.WithUserID(userID, api.logger)).Log("msg", "failed to read tombstones", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	util.WriteJSONResponse(w, tombstones)
+}
+
+// CancelDeleteRequestHandler cancels a series deletion request, if it's still pending and
+// the cancellation period has not expired yet.
+func (api *BlocksPurgerAPI) CancelDeleteRequestHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	requestID := r.FormValue("request_id")
+	if requestID == "" {
+		http.Error(w, "request_id not set", http.StatusBadRequest)
+		return
+	}
+
+	userBucket := bucket.NewUserBucketClient(userID, api.bucketClient, api.cfgProvider)
+	tombstone, err := cortex_tsdb.GetTombstone(ctx, userBucket, requestID)
+	if errors.Is(err, cortex_tsdb.ErrTombstoneNotFound) {
+		http.Error(w, "deletion request not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		level.Error(util_log.WithUserID(userID, api.logger)).Log("msg", "failed to read tombstone", "request_id", requestID, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if tombstone.State != cortex_tsdb.StatePending {
+		http.Error(w, "deletion request can only be cancelled while pending", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	if now.After(tombstone.GetRequestCreatedAt().Add(api.cfgProvider.DeleteRequestCancelPeriod(userID))) {
+		http.Error(w, "deletion request cancellation period has expired", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := cortex_tsdb.UpdateTombstoneState(ctx, userBucket, tombstone, cortex_tsdb.StateCancelled, now); err != nil {
+		level.Error(util_log.WithUserID(userID, api.logger)).Log("msg", "failed to cancel deletion request", "request_id", requestID, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	level.Info(util_log.WithUserID(userID, api.logger)).Log("msg", "series deletion request cancelled", "request_id", requestID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
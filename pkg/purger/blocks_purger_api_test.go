@@ -0,0 +1,261 @@
+package purger
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+)
+
+func TestBlocksPurgerAPI_AddDeleteRequestHandler(t *testing.T) {
+	const userID = "user-1"
+
+	now := time.Now()
+
+	for name, tc := range map[string]struct {
+		params             url.Values
+		expectedStatusCode int
+		expectedStart      int64
+		expectedEnd        int64
+	}{
+		"missing selectors": {
+			params:             url.Values{},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"invalid selector": {
+			params:             url.Values{"match[]": []string{"{invalid"}},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"end time in the future": {
+			params:             url.Values{"match[]": []string{`{a="1"}`}, "end": []string{"9999999999"}},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"start time after end time": {
+			params:             url.Values{"match[]": []string{`{a="1"}`}, "start": []string{"20"}, "end": []string{"10"}},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"valid request with time range": {
+			params:             url.Values{"match[]": []string{`{a="1"}`}, "start": []string{"10"}, "end": []string{"20"}},
+			expectedStatusCode: http.StatusNoContent,
+			expectedStart:      10000,
+			expectedEnd:        20000,
+		},
+		"valid request without time range": {
+			params:             url.Values{"match[]": []string{`{a="1"}`}},
+			expectedStatusCode: http.StatusNoContent,
+			expectedStart:      0,
+			expectedEnd:        now.Unix() * 1000,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			bkt := objstore.NewInMemBucket()
+			api := newBlocksPurgerAPI(objstore.WithNoopInstr(bkt), &mockBlocksPurgerConfigProvider{}, log.NewNopLogger())
+
+			resp := httptest.NewRecorder()
+			api.AddDeleteRequestHandler(resp, newPurgerRequest(userID, tc.params))
+			require.Equal(t, tc.expectedStatusCode, resp.Code, resp.Body.String())
+
+			tombstones, err := cortex_tsdb.GetAllTombstones(context.Background(), bucket.NewUserBucketClient(userID, bkt, nil))
+			require.NoError(t, err)
+
+			if tc.expectedStatusCode != http.StatusNoContent {
+				assert.Empty(t, tombstones)
+				return
+			}
+
+			require.Len(t, tombstones, 1)
+			assert.Equal(t, cortex_tsdb.StatePending, tombstones[0].State)
+			assert.Equal(t, userID, tombstones[0].UserID)
+			assert.Equal(t, tc.params["match[]"], tombstones[0].Selectors)
+			assert.Equal(t, tc.expectedStart, tombstones[0].StartTime)
+			assert.InDelta(t, tc.expectedEnd, tombstones[0].EndTime, 2000)
+		})
+	}
+}
+
+func TestBlocksPurgerAPI_ShouldCreateASingleTombstoneForTheSameRequest(t *testing.T) {
+	const userID = "user-1"
+
+	bkt := objstore.NewInMemBucket()
+	userBkt := bucket.NewUserBucketClient(userID, bkt, nil)
+	api := newBlocksPurgerAPI(objstore.WithNoopInstr(bkt), &mockBlocksPurgerConfigProvider{cancelPeriod: time.Hour}, log.NewNopLogger())
+	params := url.Values{"match[]": []string{`{a="1"}`}, "start": []string{"10"}, "end": []string{"20"}}
+
+	for i := 0; i < 2; i++ {
+		resp := httptest.NewRecorder()
+		api.AddDeleteRequestHandler(resp, newPurgerRequest(userID, params))
+		require.Equal(t, http.StatusNoContent, resp.Code)
+	}
+
+	tombstones, err := cortex_tsdb.GetAllTombstones(context.Background(), userBkt)
+	require.NoError(t, err)
+	require.Len(t, tombstones, 1)
+
+	// Cancel the request and create it again.
+	resp := httptest.NewRecorder()
+	api.CancelDeleteRequestHandler(resp, newPurgerRequest(userID, url.Values{"request_id": []string{tombstones[0].RequestID}}))
+	require.Equal(t, http.StatusNoContent, resp.Code)
+
+	resp = httptest.NewRecorder()
+	api.AddDeleteRequestHandler(resp, newPurgerRequest(userID, params))
+	require.Equal(t, http.StatusNoContent, resp.Code)
+
+	tombstones, err = cortex_tsdb.GetAllTombstones(context.Background(), userBkt)
+	require.NoError(t, err)
+	require.Len(t, tombstones, 1)
+	assert.Equal(t, cortex_tsdb.StatePending, tombstones[0].State)
+}
+
+func TestBlocksPurgerAPI_GetAllDeleteRequestsHandler(t *testing.T) {
+	const userID = "user-1"
+
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+	userBkt := bucket.NewUserBucketClient(userID, bkt, nil)
+	api := newBlocksPurgerAPI(objstore.WithNoopInstr(bkt), &mockBlocksPurgerConfigProvider{}, log.NewNopLogger())
+
+	// No requests.
+	{
+		resp := httptest.NewRecorder()
+		api.GetAllDeleteRequestsHandler(resp, newPurgerRequest(userID, nil))
+		require.Equal(t, http.StatusOK, resp.Code)
+		assert.JSONEq(t, `[]`, resp.Body.String())
+	}
+
+	// Some requests.
+	{
+		t1 := cortex_tsdb.NewTombstone(userID, time.Now().Add(-time.Hour), 10, 20, []string{`{a="1"}`})
+		t2 := cortex_tsdb.NewTombstone(userID, time.Now(), 30, 40, []string{`{b="2"}`})
+		require.NoError(t, cortex_tsdb.WriteTombstone(ctx, userBkt, t1))
+		require.NoError(t, cortex_tsdb.WriteTombstone(ctx, userBkt, t2))
+
+		resp := httptest.NewRecorder()
+		api.GetAllDeleteRequestsHandler(resp, newPurgerRequest(userID, nil))
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		var actual []*cortex_tsdb.Tombstone
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &actual))
+		assert.Equal(t, []*cortex_tsdb.Tombstone{t1, t2}, actual)
+	}
+}
+
+func TestBlocksPurgerAPI_CancelDeleteRequestHandler(t *testing.T) {
+	const userID = "user-1"
+
+	now := time.Now()
+
+	for name, tc := range map[string]struct {
+		tombstone          *cortex_tsdb.Tombstone
+		requestID          string
+		expectedStatusCode int
+		expectedState      cortex_tsdb.BlockDeleteRequestState
+	}{
+		"missing request ID": {
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"request not found": {
+			requestID:          "unknown",
+			expectedStatusCode: http.StatusNotFound,
+		},
+		"pending request within the cancel period": {
+			tombstone:          cortex_tsdb.NewTombstone(userID, now.Add(-30*time.Minute), 10, 20, []string{`{a="1"}`}),
+			expectedStatusCode: http.StatusNoContent,
+			expectedState:      cortex_tsdb.StateCancelled,
+		},
+		"pending request outside the cancel period": {
+			tombstone:          cortex_tsdb.NewTombstone(userID, now.Add(-2*time.Hour), 10, 20, []string{`{a="1"}`}),
+			expectedStatusCode: http.StatusBadRequest,
+			expectedState:      cortex_tsdb.StatePending,
+		},
+		"processed request": {
+			tombstone: func() *cortex_tsdb.Tombstone {
+				t := cortex_tsdb.NewTombstone(userID, now.Add(-30*time.Minute), 10, 20, []string{`{a="1"}`})
+				t.State = cortex_tsdb.StateProcessed
+				return t
+			}(),
+			expectedStatusCode: http.StatusBadRequest,
+			expectedState:      cortex_tsdb.StateProcessed,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			bkt := objstore.NewInMemBucket()
+			userBkt := bucket.NewUserBucketClient(userID, bkt, nil)
+			api := newBlocksPurgerAPI(objstore.WithNoopInstr(bkt), &mockBlocksPurgerConfigProvider{cancelPeriod: time.Hour}, log.NewNopLogger())
+
+			requestID := tc.requestID
+			if tc.tombstone != nil {
+				require.NoError(t, cortex_tsdb.WriteTombstone(ctx, userBkt, tc.tombstone))
+				requestID = tc.tombstone.RequestID
+			}
+
+			params := url.Values{}
+			if requestID != "" {
+				params.Set("request_id", requestID)
+			}
+
+			resp := httptest.NewRecorder()
+			api.CancelDeleteRequestHandler(resp, newPurgerRequest(userID, params))
+			require.Equal(t, tc.expectedStatusCode, resp.Code, resp.Body.String())
+
+			if tc.tombstone != nil {
+				actual, err := cortex_tsdb.GetTombstone(ctx, userBkt, requestID)
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedState, actual.State)
+			}
+		})
+	}
+}
+
+func TestBlocksPurgerAPI_ShouldRequireTenantID(t *testing.T) {
+	api := newBlocksPurgerAPI(objstore.WithNoopInstr(objstore.NewInMemBucket()), &mockBlocksPurgerConfigProvider{}, log.NewNopLogger())
+
+	for _, handler := range []http.HandlerFunc{api.AddDeleteRequestHandler, api.GetAllDeleteRequestsHandler, api.CancelDeleteRequestHandler} {
+		resp := httptest.NewRecorder()
+		handler(resp, httptest.NewRequest(http.MethodPost, "/", nil))
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	}
+}
+
+func newPurgerRequest(userID string, params url.Values) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(params.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req.WithContext(user.InjectOrgID(req.Context(), userID))
+}
+
+type mockBlocksPurgerConfigProvider struct {
+	cancelPeriod time.Duration
+}
+
+func (m *mockBlocksPurgerConfigProvider) DeleteRequestCancelPeriod(_ string) time.Duration {
+	return m.cancelPeriod
+}
+
+func (m *mockBlocksPurgerConfigProvider) S3SSEType(_ string) string {
+	return ""
+}
+
+func (m *mockBlocksPurgerConfigProvider) S3SSEKMSKeyID(_ string) string {
+	return ""
+}
+
+func (m *mockBlocksPurgerConfigProvider) S3SSEKMSEncryptionContext(_ string) string {
+	return ""
+}
+
+func (m *mockBlocksPurgerConfigProvider) GCSKMSKeyName(_ string) string {
+	return ""
+}
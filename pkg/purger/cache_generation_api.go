@@ -0,0 +1,83 @@
+package purger
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/thanos-io/objstore"
+
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+// CacheGenerationAPI bumps the cache generation of the tenants, invalidating all their items of the results cache and
+// of the chunks and metadata caches, without flushing the items of the other tenants.
+type CacheGenerationAPI struct {
+	bucketClient objstore.InstrumentedBucket
+	logger       log.Logger
+}
+
+func NewCacheGenerationAPI(storageCfg cortex_tsdb.BlocksStorageConfig, logger log.Logger, reg prometheus.Registerer) (*CacheGenerationAPI, error) {
+	bucketClient, err := createBucketClient(storageCfg, "cache-generation-api", logger, reg)
+	if err != nil {
+		return nil, err
+	}
+
+	return newCacheGenerationAPI(bucketClient, logger), nil
+}
+
+func newCacheGenerationAPI(bkt objstore.InstrumentedBucket, logger log.Logger) *CacheGenerationAPI {
+	return &CacheGenerationAPI{
+		bucketClient: bkt,
+		logger:       logger,
+	}
+}
+
+type CacheGenerationResponse struct {
+	TenantID   string `json:"tenant_id"`
+	Generation int64  `json:"generation"`
+}
+
+func (api *CacheGenerationAPI) BumpCacheGeneration(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		// When Cortex is running, it uses Auth Middleware for checking X-Scope-OrgID and injecting tenant into context.
+		// Auth Middleware sends http.StatusUnauthorized if X-Scope-OrgID is missing, so we do too here, for consistency.
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	generation, err := cortex_tsdb.BumpCacheGeneration(ctx, api.bucketClient, userID, time.Now())
+	if err != nil {
+		level.Error(api.logger).Log("msg", "failed to bump the cache generation", "user", userID, "err", err)
+
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	level.Info(api.logger).Log("msg", "cache generation bumped", "user", userID, "generation", generation)
+
+	util.WriteJSONResponse(w, CacheGenerationResponse{TenantID: userID, Generation: generation})
+}
+
+func (api *CacheGenerationAPI) CacheGeneration(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	generation, err := cortex_tsdb.ReadCacheGeneration(ctx, api.bucketClient, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	util.WriteJSONResponse(w, CacheGenerationResponse{TenantID: userID, Generation: generation})
+}
@@ -0,0 +1,54 @@
+package purger
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/storage/tsdb"
+)
+
+func TestBumpCacheGeneration(t *testing.T) {
+	bkt := objstore.WithNoopInstr(objstore.NewInMemBucket())
+	api := newCacheGenerationAPI(bkt, log.NewNopLogger())
+
+	{
+		resp := httptest.NewRecorder()
+		api.BumpCacheGeneration(resp, &http.Request{})
+		require.Equal(t, http.StatusUnauthorized, resp.Code)
+	}
+
+	ctx := user.InjectOrgID(context.Background(), "fake")
+	for expected := int64(1); expected <= 2; expected++ {
+		req := &http.Request{}
+		resp := httptest.NewRecorder()
+		api.BumpCacheGeneration(resp, req.WithContext(ctx))
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		result := CacheGenerationResponse{}
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &result))
+		require.Equal(t, CacheGenerationResponse{TenantID: "fake", Generation: expected}, result)
+	}
+
+	generation, err := tsdb.ReadCacheGeneration(ctx, bkt, "fake")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), generation)
+
+	{
+		req := &http.Request{}
+		resp := httptest.NewRecorder()
+		api.CacheGeneration(resp, req.WithContext(ctx))
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		result := CacheGenerationResponse{}
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &result))
+		require.Equal(t, CacheGenerationResponse{TenantID: "fake", Generation: 2}, result)
+	}
+}
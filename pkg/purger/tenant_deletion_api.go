@@ -26,7 +26,7 @@ type TenantDeletionAPI struct {
 }
 
 func NewTenantDeletionAPI(storageCfg cortex_tsdb.BlocksStorageConfig, cfgProvider bucket.TenantConfigProvider, logger log.Logger, reg prometheus.Registerer) (*TenantDeletionAPI, error) {
-	bucketClient, err := createBucketClient(storageCfg, logger, reg)
+	bucketClient, err := createBucketClient(storageCfg, "purger", logger, reg)
 	if err != nil {
 		return nil, err
 	}
@@ -118,8 +118,8 @@ func (api *TenantDeletionAPI) isBlocksForUserDeleted(ctx context.Context, userID
 	return true, nil
 }
 
-func createBucketClient(cfg cortex_tsdb.BlocksStorageConfig, logger log.Logger, reg prometheus.Registerer) (objstore.InstrumentedBucket, error) {
-	bucketClient, err := bucket.NewClient(context.Background(), cfg.Bucket, "purger", logger, reg)
+func createBucketClient(cfg cortex_tsdb.BlocksStorageConfig, name string, logger log.Logger, reg prometheus.Registerer) (objstore.InstrumentedBucket, error) {
+	bucketClient, err := cortex_tsdb.NewBucketClient(context.Background(), cfg, name, logger, reg)
 	if err != nil {
 		return nil, errors.Wrap(err, "create bucket client")
 	}
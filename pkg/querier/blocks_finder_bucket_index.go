@@ -118,3 +118,21 @@ func (f *BucketIndexBlocksFinder) GetBlocks(ctx context.Context, userID string,
 
 	return blocks, matchingDeletionMarks, nil
 }
+
+// GetTombstones implements TombstonesLoader.
+func (f *BucketIndexBlocksFinder) GetTombstones(ctx context.Context, userID string) (bucketindex.Tombstones, error) {
+	if f.State() != services.Running {
+		return nil, errBucketIndexBlocksFinderNotRunning
+	}
+
+	idx, _, err := f.loader.GetIndex(ctx, userID)
+	if errors.Is(err, bucketindex.ErrIndexNotFound) {
+		return nil, nil
+	} else if errors.Is(err, bucket.ErrCustomerManagedKeyAccessDenied) {
+		return nil, validation.AccessDeniedError(err.Error())
+	} else if err != nil {
+		return nil, err
+	}
+
+	return idx.Tombstones, nil
+}
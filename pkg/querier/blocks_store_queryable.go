@@ -183,7 +183,7 @@ func NewBlocksStoreQueryable(
 func NewBlocksStoreQueryableFromConfig(querierCfg Config, gatewayCfg storegateway.Config, storageCfg cortex_tsdb.BlocksStorageConfig, limits BlocksStoreLimits, logger log.Logger, reg prometheus.Registerer) (*BlocksStoreQueryable, error) {
 	var stores BlocksStoreSet
 
-	bucketClient, err := bucket.NewClient(context.Background(), storageCfg.Bucket, "querier", logger, reg)
+	bucketClient, err := cortex_tsdb.NewBucketClient(context.Background(), storageCfg, "querier", logger, reg)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create bucket client")
 	}
@@ -239,7 +239,12 @@ func NewBlocksStoreQueryableFromConfig(querierCfg Config, gatewayCfg storegatewa
 			return nil, errors.Wrap(err, "failed to create store-gateway ring client")
 		}
 
-		stores, err = newBlocksStoreReplicationSet(storesRing, gatewayCfg.ShardingStrategy, randomLoadBalancing, limits, querierCfg.StoreGatewayClient, logger, reg, storesRingCfg.ZoneAwarenessEnabled, gatewayCfg.ShardingRing.ZoneStableShuffleSharding)
+		boundedLoadsEpsilon := 0.0
+		if querierCfg.StoreGatewayBoundedLoadsEnabled {
+			boundedLoadsEpsilon = querierCfg.StoreGatewayBoundedLoadsEpsilon
+		}
+
+		stores, err = newBlocksStoreReplicationSet(storesRing, gatewayCfg.ShardingStrategy, randomLoadBalancing, boundedLoadsEpsilon, limits, querierCfg.StoreGatewayClient, logger, reg, storesRingCfg.ZoneAwarenessEnabled, gatewayCfg.ShardingRing.ZoneStableShuffleSharding)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to create store set")
 		}
@@ -311,6 +316,15 @@ func (q *BlocksStoreQueryable) Querier(mint, maxt int64) (storage.Querier, error
 	}, nil
 }
 
+// GetTombstones implements TombstonesLoader. Tombstones are only supported when
+// the blocks are discovered through the bucket index.
+func (q *BlocksStoreQueryable) GetTombstones(ctx context.Context, userID string) (bucketindex.Tombstones, error) {
+	if loader, ok := q.finder.(TombstonesLoader); ok {
+		return loader.GetTombstones(ctx, userID)
+	}
+	return nil, nil
+}
+
 type blocksStoreQuerier struct {
 	minT, maxT  int64
 	finder      BlocksFinder
@@ -367,7 +381,7 @@ func (q *blocksStoreQuerier) LabelNames(ctx context.Context, matchers ...*labels
 		return queriedBlocks, nil, retryableError
 	}
 
-	if err := q.queryWithConsistencyCheck(spanCtx, spanLog, minT, maxT, userID, queryFunc); err != nil {
+	if err := q.queryWithConsistencyCheck(spanCtx, spanLog, minT, maxT, downsample.ResLevel0, userID, queryFunc); err != nil {
 		return nil, nil, err
 	}
 
@@ -406,7 +420,7 @@ func (q *blocksStoreQuerier) LabelValues(ctx context.Context, name string, match
 		return queriedBlocks, nil, retryableError
 	}
 
-	if err := q.queryWithConsistencyCheck(spanCtx, spanLog, minT, maxT, userID, queryFunc); err != nil {
+	if err := q.queryWithConsistencyCheck(spanCtx, spanLog, minT, maxT, downsample.ResLevel0, userID, queryFunc); err != nil {
 		return nil, nil, err
 	}
 
@@ -462,7 +476,7 @@ func (q *blocksStoreQuerier) selectSorted(ctx context.Context, sp *storage.Selec
 		return queriedBlocks, nil, retryableError
 	}
 
-	if err := q.queryWithConsistencyCheck(spanCtx, spanLog, minT, maxT, userID, queryFunc); err != nil {
+	if err := q.queryWithConsistencyCheck(spanCtx, spanLog, minT, maxT, maxResolutionFromHints(sp), userID, queryFunc); err != nil {
 		return storage.ErrSeriesSet(err)
 	}
 
@@ -475,7 +489,7 @@ func (q *blocksStoreQuerier) selectSorted(ctx context.Context, sp *storage.Selec
 		resWarnings)
 }
 
-func (q *blocksStoreQuerier) queryWithConsistencyCheck(ctx context.Context, logger log.Logger, minT, maxT int64, userID string,
+func (q *blocksStoreQuerier) queryWithConsistencyCheck(ctx context.Context, logger log.Logger, minT, maxT, maxResolution int64, userID string,
 	queryFunc func(clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, error, error)) error {
 	// If queryStoreAfter is enabled, we do manipulate the query maxt to query samples up until
 	// now - queryStoreAfter, because the most recent time range is covered by ingesters. This
@@ -503,6 +517,9 @@ func (q *blocksStoreQuerier) queryWithConsistencyCheck(ctx context.Context, logg
 		return err
 	}
 
+	// Only query blocks of a single resolution for each time range, like the store-gateway does.
+	knownBlocks = filterBlocksByResolution(knownBlocks, minT, maxT, maxResolution)
+
 	if len(knownBlocks) == 0 {
 		q.metrics.storesHit.Observe(0)
 		level.Debug(logger).Log("msg", "no blocks found")
@@ -617,6 +634,12 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 	}
 	convertedMatchers := convertMatchersToLabelMatcher(matchers)
 
+	maxResolution := maxResolutionFromHints(sp)
+	aggrs := defaultAggrs
+	if maxResolution > downsample.ResLevel0 {
+		aggrs = aggrsFromFunc(sp.Func)
+	}
+
 	// Concurrently fetch series from all clients.
 	for c, blockIDs := range clients {
 		// Change variables scope since it will be used in a goroutine.
@@ -634,7 +657,7 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 			seriesQueryStats := &hintspb.QueryStats{}
 			skipChunks := sp != nil && sp.Func == "series"
 
-			req, err := createSeriesRequest(minT, maxT, convertedMatchers, shardingInfo, skipChunks, blockIDs, defaultAggrs)
+			req, err := createSeriesRequest(minT, maxT, maxResolution, convertedMatchers, shardingInfo, skipChunks, blockIDs, aggrs)
 			if err != nil {
 				return errors.Wrapf(err, "failed to create series request")
 			}
@@ -801,7 +824,7 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 			// Store the result.
 			mtx.Lock()
 			// TODO: change other aggregations when downsampling is enabled.
-			seriesSets = append(seriesSets, thanosquery.NewPromSeriesSet(newStoreSeriesSet(mySeries), minT, maxT, defaultAggrs, nil))
+			seriesSets = append(seriesSets, thanosquery.NewPromSeriesSet(newStoreSeriesSet(mySeries), minT, maxT, aggrs, nil))
 			warnings.Merge(myWarnings)
 			queriedBlocks = append(queriedBlocks, myQueriedBlocks...)
 			mtx.Unlock()
@@ -1024,7 +1047,7 @@ func (q *blocksStoreQuerier) fetchLabelValuesFromStore(
 	return valueSets, warnings, queriedBlocks, nil, merr.Err()
 }
 
-func createSeriesRequest(minT, maxT int64, matchers []storepb.LabelMatcher, shardingInfo *storepb.ShardInfo, skipChunks bool, blockIDs []ulid.ULID, aggrs []storepb.Aggr) (*storepb.SeriesRequest, error) {
+func createSeriesRequest(minT, maxT, maxResolution int64, matchers []storepb.LabelMatcher, shardingInfo *storepb.ShardInfo, skipChunks bool, blockIDs []ulid.ULID, aggrs []storepb.Aggr) (*storepb.SeriesRequest, error) {
 	// Selectively query only specific blocks.
 	hints := &hintspb.SeriesRequestHints{
 		BlockMatchers: []storepb.LabelMatcher{
@@ -1051,8 +1074,7 @@ func createSeriesRequest(minT, maxT int64, matchers []storepb.LabelMatcher, shar
 		SkipChunks:              skipChunks,
 		ShardInfo:               shardingInfo,
 		Aggregates:              aggrs,
-		// TODO: support more downsample levels when downsampling is supported.
-		MaxResolutionWindow: downsample.ResLevel0,
+		MaxResolutionWindow:     maxResolution,
 	}, nil
 }
 
@@ -1187,3 +1209,101 @@ func isRetryableError(err error) bool {
 		return false
 	}
 }
+
+// maxResolutionFromHints returns the max resolution of blocks which can be used to run a query.
+// Like Thanos auto-downsampling, blocks with a resolution of up to 1/5 of the query step are used.
+func maxResolutionFromHints(sp *storage.SelectHints) int64 {
+	if sp == nil || sp.Step <= 0 {
+		return downsample.ResLevel0
+	}
+	return sp.Step / 5
+}
+
+// aggrsFromFunc infers the aggregates of downsampled chunks to fetch based on the function
+// wrapping the series selection. This is the same logic used by the Thanos querier.
+func aggrsFromFunc(f string) []storepb.Aggr {
+	if f == "min" || strings.HasPrefix(f, "min_") {
+		return []storepb.Aggr{storepb.Aggr_MIN}
+	}
+	if f == "max" || strings.HasPrefix(f, "max_") {
+		return []storepb.Aggr{storepb.Aggr_MAX}
+	}
+	if f == "count" || strings.HasPrefix(f, "count_") {
+		return []storepb.Aggr{storepb.Aggr_COUNT}
+	}
+	// f == "sum" falls through here since we want the actual samples.
+	if strings.HasPrefix(f, "sum_") {
+		return []storepb.Aggr{storepb.Aggr_SUM}
+	}
+	if f == "increase" || f == "rate" || f == "irate" || f == "resets" {
+		return []storepb.Aggr{storepb.Aggr_COUNTER}
+	}
+	// In the default case, we retrieve count and sum to compute an average.
+	return defaultAggrs
+}
+
+// blockResolutions is the list of supported block resolutions, from the lowest to the highest.
+var blockResolutions = []int64{downsample.ResLevel2, downsample.ResLevel1, downsample.ResLevel0}
+
+// filterBlocksByResolution returns the blocks to query in the given time range, given the max resolution.
+// The lowest resolution blocks are preferred, and gaps are filled with higher resolution blocks. This is
+// the same logic used by the store-gateway to select blocks, so that only blocks which are actually
+// going to be queried are expected by the consistency check.
+func filterBlocksByResolution(blocks bucketindex.Blocks, minT, maxT, maxResolution int64) bucketindex.Blocks {
+	byResolution := make(map[int64]bucketindex.Blocks, len(blockResolutions))
+	for _, b := range blocks {
+		byResolution[b.Resolution] = append(byResolution[b.Resolution], b)
+	}
+
+	// Fast path: no downsampled blocks.
+	if len(byResolution) <= 1 && len(byResolution[downsample.ResLevel0]) == len(blocks) {
+		return blocks
+	}
+
+	for _, res := range byResolution {
+		sort.Slice(res, func(i, j int) bool {
+			return res[i].MinTime < res[j].MinTime
+		})
+	}
+
+	return getBlocksForResolution(byResolution, minT, maxT, maxResolution)
+}
+
+func getBlocksForResolution(byResolution map[int64]bucketindex.Blocks, minT, maxT, maxResolution int64) (result bucketindex.Blocks) {
+	if minT > maxT {
+		return nil
+	}
+
+	// Find the first matching resolution.
+	i := 0
+	for ; i < len(blockResolutions) && blockResolutions[i] > maxResolution; i++ {
+	}
+	if i == len(blockResolutions) {
+		return nil
+	}
+
+	// Fill the time range with the blocks of the current resolution, recursively
+	// filling the gaps with higher resolution blocks.
+	start := minT
+	for _, b := range byResolution[blockResolutions[i]] {
+		if b.MaxTime <= minT {
+			continue
+		}
+		// NOTE: Block intervals are half-open: [MinTime, MaxTime).
+		if b.MinTime > maxT {
+			break
+		}
+
+		if i+1 < len(blockResolutions) {
+			result = append(result, getBlocksForResolution(byResolution, start, b.MinTime-1, blockResolutions[i+1])...)
+		}
+
+		result = append(result, b)
+		start = b.MaxTime
+	}
+
+	if i+1 < len(blockResolutions) {
+		result = append(result, getBlocksForResolution(byResolution, start, maxT, blockResolutions[i+1])...)
+	}
+	return result
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/thanos-io/promql-engine/engine"
 	"github.com/thanos-io/promql-engine/logicalplan"
+	"github.com/thanos-io/thanos/pkg/compact/downsample"
 	"github.com/thanos-io/thanos/pkg/pool"
 	"github.com/thanos-io/thanos/pkg/store/hintspb"
 	"github.com/thanos-io/thanos/pkg/store/labelpb"
@@ -1714,6 +1715,10 @@ func (m *blocksStoreLimitsMock) S3SSEKMSEncryptionContext(_ string) string {
 	return ""
 }
 
+func (m *blocksStoreLimitsMock) GCSKMSKeyName(_ string) string {
+	return ""
+}
+
 func mockSeriesResponse(lbls labels.Labels, samples ...cortexpb.Sample) *storepb.SeriesResponse {
 	res := &storepb.SeriesResponse_Series{
 		Series: &storepb.Series{
@@ -1883,3 +1888,86 @@ func TestCountSamplesAndChunks(t *testing.T) {
 		})
 	}
 }
+
+func TestFilterBlocksByResolution(t *testing.T) {
+	raw1 := &bucketindex.Block{ID: ulid.MustNew(1, nil), MinTime: 0, MaxTime: 10}
+	raw2 := &bucketindex.Block{ID: ulid.MustNew(2, nil), MinTime: 10, MaxTime: 20}
+	raw3 := &bucketindex.Block{ID: ulid.MustNew(3, nil), MinTime: 20, MaxTime: 30}
+	res5m1 := &bucketindex.Block{ID: ulid.MustNew(4, nil), MinTime: 0, MaxTime: 10, Resolution: downsample.ResLevel1}
+	res5m2 := &bucketindex.Block{ID: ulid.MustNew(5, nil), MinTime: 10, MaxTime: 20, Resolution: downsample.ResLevel1}
+	res1h1 := &bucketindex.Block{ID: ulid.MustNew(6, nil), MinTime: 0, MaxTime: 10, Resolution: downsample.ResLevel2}
+
+	tests := map[string]struct {
+		blocks        bucketindex.Blocks
+		minT, maxT    int64
+		maxResolution int64
+		expected      bucketindex.Blocks
+	}{
+		"should return input blocks if there are no downsampled blocks": {
+			blocks:        bucketindex.Blocks{raw1, raw2, raw3},
+			minT:          0,
+			maxT:          30,
+			maxResolution: downsample.ResLevel2,
+			expected:      bucketindex.Blocks{raw1, raw2, raw3},
+		},
+		"should only return raw blocks if the max resolution is raw": {
+			blocks:        bucketindex.Blocks{raw1, raw2, raw3, res5m1, res5m2, res1h1},
+			minT:          0,
+			maxT:          30,
+			maxResolution: downsample.ResLevel0,
+			expected:      bucketindex.Blocks{raw1, raw2, raw3},
+		},
+		"should prefer 5m blocks and fill the gaps with raw blocks": {
+			blocks:        bucketindex.Blocks{raw1, raw2, raw3, res5m1, res5m2, res1h1},
+			minT:          0,
+			maxT:          30,
+			maxResolution: downsample.ResLevel1,
+			expected:      bucketindex.Blocks{res5m1, res5m2, raw3},
+		},
+		"should prefer 1h blocks and fill the gaps with higher resolution blocks": {
+			blocks:        bucketindex.Blocks{raw1, raw2, raw3, res5m1, res5m2, res1h1},
+			minT:          0,
+			maxT:          30,
+			maxResolution: downsample.ResLevel2,
+			expected:      bucketindex.Blocks{res1h1, res5m2, raw3},
+		},
+		"should only return blocks within the queried time range": {
+			blocks:        bucketindex.Blocks{raw1, raw2, raw3, res5m1, res5m2, res1h1},
+			minT:          12,
+			maxT:          18,
+			maxResolution: downsample.ResLevel2,
+			expected:      bucketindex.Blocks{res5m2},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			actual := filterBlocksByResolution(testData.blocks, testData.minT, testData.maxT, testData.maxResolution)
+			assert.Equal(t, testData.expected, actual)
+		})
+	}
+}
+
+func TestMaxResolutionFromHints(t *testing.T) {
+	assert.Equal(t, downsample.ResLevel0, maxResolutionFromHints(nil))
+	assert.Equal(t, downsample.ResLevel0, maxResolutionFromHints(&storage.SelectHints{}))
+	assert.Equal(t, int64(60000), maxResolutionFromHints(&storage.SelectHints{Step: 300000}))
+}
+
+func TestAggrsFromFunc(t *testing.T) {
+	tests := map[string][]storepb.Aggr{
+		"min_over_time":   {storepb.Aggr_MIN},
+		"max":             {storepb.Aggr_MAX},
+		"count_over_time": {storepb.Aggr_COUNT},
+		"sum_over_time":   {storepb.Aggr_SUM},
+		"rate":            {storepb.Aggr_COUNTER},
+		"sum":             {storepb.Aggr_COUNT, storepb.Aggr_SUM},
+		"":                {storepb.Aggr_COUNT, storepb.Aggr_SUM},
+	}
+
+	for f, expected := range tests {
+		t.Run(f, func(t *testing.T) {
+			assert.Equal(t, expected, aggrsFromFunc(f))
+		})
+	}
+}
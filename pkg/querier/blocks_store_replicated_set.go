@@ -37,6 +37,10 @@ type blocksStoreReplicationSet struct {
 	balancingStrategy loadBalancingStrategy
 	limits            BlocksStoreLimits
 
+	// Bounds the number of blocks queried from each store-gateway to (1 + epsilon) times the
+	// average number of blocks per store-gateway of the query. 0 = disabled.
+	boundedLoadsEpsilon float64
+
 	zoneAwarenessEnabled      bool
 	zoneStableShuffleSharding bool
 
@@ -49,6 +53,7 @@ func newBlocksStoreReplicationSet(
 	storesRing *ring.Ring,
 	shardingStrategy string,
 	balancingStrategy loadBalancingStrategy,
+	boundedLoadsEpsilon float64,
 	limits BlocksStoreLimits,
 	clientConfig ClientConfig,
 	logger log.Logger,
@@ -63,6 +68,8 @@ func newBlocksStoreReplicationSet(
 		balancingStrategy: balancingStrategy,
 		limits:            limits,
 
+		boundedLoadsEpsilon: boundedLoadsEpsilon,
+
 		zoneAwarenessEnabled:      zoneAwarenessEnabled,
 		zoneStableShuffleSharding: zoneStableShuffleSharding,
 	}
@@ -115,6 +122,12 @@ func (s *blocksStoreReplicationSet) GetClientsFor(userID string, blockIDs []ulid
 		userRing = s.storesRing
 	}
 
+	// Bound the number of blocks queried from each store-gateway, if enabled.
+	var loads *ring.BoundedLoads
+	if s.boundedLoadsEpsilon > 0 {
+		loads = ring.NewBoundedLoads(len(blockIDs), userRing.InstancesCount(), s.boundedLoadsEpsilon)
+	}
+
 	// Find the replication set of each block we need to query.
 	for _, blockID := range blockIDs {
 		// Do not reuse the same buffer across multiple Get() calls because we do retain the
@@ -127,11 +140,18 @@ func (s *blocksStoreReplicationSet) GetClientsFor(userID string, blockIDs []ulid
 		}
 
 		// Pick a non excluded store-gateway instance.
-		instance := getNonExcludedInstance(set, exclude[blockID], s.balancingStrategy, s.zoneAwarenessEnabled, attemptedBlocksZones[blockID])
+		instance := getNonExcludedInstance(set, exclude[blockID], s.balancingStrategy, s.zoneAwarenessEnabled, attemptedBlocksZones[blockID], loads)
+		if instance.Addr == "" && loads != nil {
+			// All the store-gateway instances left are at capacity, so we query one of them anyway.
+			instance = getNonExcludedInstance(set, exclude[blockID], noLoadBalancing, s.zoneAwarenessEnabled, attemptedBlocksZones[blockID], nil)
+		}
 		// A valid instance should have a non-empty address.
 		if instance.Addr == "" {
 			return nil, fmt.Errorf("no store-gateway instance left after checking exclude for block %s", blockID.String())
 		}
+		if loads != nil {
+			loads.Add(instance.Addr)
+		}
 
 		shards[instance.Addr] = append(shards[instance.Addr], blockID)
 		if s.zoneAwarenessEnabled {
@@ -157,7 +177,9 @@ func (s *blocksStoreReplicationSet) GetClientsFor(userID string, blockIDs []ulid
 	return clients, nil
 }
 
-func getNonExcludedInstance(set ring.ReplicationSet, exclude []string, balancingStrategy loadBalancingStrategy, zoneAwarenessEnabled bool, attemptedZones map[string]int) ring.InstanceDesc {
+// getNonExcludedInstance returns the first instance of the set which is not excluded. If loads is not nil,
+// the instances without spare capacity are skipped too.
+func getNonExcludedInstance(set ring.ReplicationSet, exclude []string, balancingStrategy loadBalancingStrategy, zoneAwarenessEnabled bool, attemptedZones map[string]int, loads *ring.BoundedLoads) ring.InstanceDesc {
 	if balancingStrategy == randomLoadBalancing {
 		// Randomize the list of instances to not always query the same one.
 		rand.Shuffle(len(set.Instances), func(i, j int) {
@@ -182,6 +204,9 @@ func getNonExcludedInstance(set ring.ReplicationSet, exclude []string, balancing
 		if util.StringsContain(exclude, instance.Addr) {
 			continue
 		}
+		if loads != nil && !loads.HasCapacity(instance.Addr) {
+			continue
+		}
 		// If zone awareness is not enabled, pick first non-excluded instance.
 		// Otherwise, keep iterating until we find an instance in a zone where
 		// we have the least retries.
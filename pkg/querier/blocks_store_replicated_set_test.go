@@ -585,7 +585,7 @@ func TestBlocksStoreReplicationSet_GetClientsFor(t *testing.T) {
 			}
 
 			reg := prometheus.NewPedanticRegistry()
-			s, err := newBlocksStoreReplicationSet(r, testData.shardingStrategy, noLoadBalancing, limits, ClientConfig{}, log.NewNopLogger(), reg, testData.zoneAwarenessEnabled, true)
+			s, err := newBlocksStoreReplicationSet(r, testData.shardingStrategy, noLoadBalancing, 0, limits, ClientConfig{}, log.NewNopLogger(), reg, testData.zoneAwarenessEnabled, true)
 			require.NoError(t, err)
 			require.NoError(t, services.StartAndAwaitRunning(ctx, s))
 			defer services.StopAndAwaitTerminated(ctx, s) //nolint:errcheck
@@ -647,7 +647,7 @@ func TestBlocksStoreReplicationSet_GetClientsFor_ShouldSupportRandomLoadBalancin
 
 	limits := &blocksStoreLimitsMock{}
 	reg := prometheus.NewPedanticRegistry()
-	s, err := newBlocksStoreReplicationSet(r, util.ShardingStrategyDefault, randomLoadBalancing, limits, ClientConfig{}, log.NewNopLogger(), reg, false, false)
+	s, err := newBlocksStoreReplicationSet(r, util.ShardingStrategyDefault, randomLoadBalancing, 0, limits, ClientConfig{}, log.NewNopLogger(), reg, false, false)
 	require.NoError(t, err)
 	require.NoError(t, services.StartAndAwaitRunning(ctx, s))
 	defer services.StopAndAwaitTerminated(ctx, s) //nolint:errcheck
@@ -680,6 +680,80 @@ func TestBlocksStoreReplicationSet_GetClientsFor_ShouldSupportRandomLoadBalancin
 	}
 }
 
+func TestBlocksStoreReplicationSet_GetClientsFor_ShouldSupportBoundedLoads(t *testing.T) {
+	t.Parallel()
+
+	const (
+		numBlocks    = 30
+		numInstances = 3
+	)
+
+	ctx := context.Background()
+	userID := "user-A"
+	registeredAt := time.Now()
+
+	// Create a ring where all the blocks are owned by the instances 1 and 2, in this order.
+	ringStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	require.NoError(t, ringStore.CAS(ctx, "test", func(in interface{}) (interface{}, bool, error) {
+		d := ring.NewDesc()
+		d.AddIngester("instance-1", "127.0.0.1", "", []uint32{0}, ring.ACTIVE, registeredAt)
+		d.AddIngester("instance-2", "127.0.0.2", "", []uint32{1}, ring.ACTIVE, registeredAt)
+		d.AddIngester("instance-3", "127.0.0.3", "", []uint32{2}, ring.ACTIVE, registeredAt)
+		return d, true, nil
+	}))
+
+	ringCfg := ring.Config{}
+	flagext.DefaultValues(&ringCfg)
+	ringCfg.ReplicationFactor = 2
+
+	blockIDs := make([]ulid.ULID, 0, numBlocks)
+	for n := 0; n < numBlocks; n++ {
+		blockIDs = append(blockIDs, ulid.MustNew(uint64(n+3), nil))
+	}
+
+	for testName, testData := range map[string]struct {
+		boundedLoadsEpsilon float64
+		expected            map[string]int
+	}{
+		"bounded loads disabled": {
+			expected: map[string]int{"127.0.0.1": numBlocks},
+		},
+		"bounded loads enabled": {
+			boundedLoadsEpsilon: 0.5,
+			expected:            map[string]int{"127.0.0.1": numBlocks / 2, "127.0.0.2": numBlocks / 2},
+		},
+	} {
+		t.Run(testName, func(t *testing.T) {
+			r, err := ring.NewWithStoreClientAndStrategy(ringCfg, "test", "test", ringStore, ring.NewIgnoreUnhealthyInstancesReplicationStrategy(), nil, nil)
+			require.NoError(t, err)
+
+			limits := &blocksStoreLimitsMock{}
+			reg := prometheus.NewPedanticRegistry()
+			s, err := newBlocksStoreReplicationSet(r, util.ShardingStrategyDefault, noLoadBalancing, testData.boundedLoadsEpsilon, limits, ClientConfig{}, log.NewNopLogger(), reg, false, false)
+			require.NoError(t, err)
+			require.NoError(t, services.StartAndAwaitRunning(ctx, s))
+			defer services.StopAndAwaitTerminated(ctx, s) //nolint:errcheck
+
+			// Wait until the ring client has initialised the state.
+			test.Poll(t, time.Second, true, func() interface{} {
+				all, err := r.GetAllHealthy(ring.Read)
+				return err == nil && len(all.Instances) > 0
+			})
+
+			clients, err := s.GetClientsFor(userID, blockIDs, nil, nil)
+			require.NoError(t, err)
+
+			distribution := map[string]int{}
+			for addr, blocks := range getStoreGatewayClientAddrs(clients) {
+				distribution[addr] = len(blocks)
+			}
+			assert.Equal(t, testData.expected, distribution)
+		})
+	}
+}
+
 func TestBlocksStoreReplicationSet_GetClientsFor_ZoneAwareness(t *testing.T) {
 	t.Parallel()
 
@@ -716,7 +790,7 @@ func TestBlocksStoreReplicationSet_GetClientsFor_ZoneAwareness(t *testing.T) {
 
 	limits := &blocksStoreLimitsMock{}
 	reg := prometheus.NewPedanticRegistry()
-	s, err := newBlocksStoreReplicationSet(r, util.ShardingStrategyDefault, randomLoadBalancing, limits, ClientConfig{}, log.NewNopLogger(), reg, true, false)
+	s, err := newBlocksStoreReplicationSet(r, util.ShardingStrategyDefault, randomLoadBalancing, 0, limits, ClientConfig{}, log.NewNopLogger(), reg, true, false)
 	require.NoError(t, err)
 	require.NoError(t, services.StartAndAwaitRunning(ctx, s))
 	defer services.StopAndAwaitTerminated(ctx, s) //nolint:errcheck
@@ -77,6 +77,9 @@ type Config struct {
 	StoreGatewayClient            ClientConfig `yaml:"store_gateway_client"`
 	StoreGatewayQueryStatsEnabled bool         `yaml:"store_gateway_query_stats"`
 
+	StoreGatewayBoundedLoadsEnabled bool    `yaml:"store_gateway_bounded_loads_enabled"`
+	StoreGatewayBoundedLoadsEpsilon float64 `yaml:"store_gateway_bounded_loads_epsilon"`
+
 	ShuffleShardingIngestersLookbackPeriod time.Duration `yaml:"shuffle_sharding_ingesters_lookback_period"`
 
 	// Experimental. Use https://github.com/thanos-io/promql-engine rather than
@@ -91,6 +94,7 @@ var (
 	errBadLookbackConfigs                             = errors.New("bad settings, query_store_after >= query_ingesters_within which can result in queries not being sent")
 	errShuffleShardingLookbackLessThanQueryStoreAfter = errors.New("the shuffle-sharding lookback period should be greater or equal than the configured 'query store after'")
 	errEmptyTimeRange                                 = errors.New("empty time range")
+	errInvalidStoreGatewayBoundedLoadsEpsilon         = errors.New("the store-gateway bounded loads epsilon must be greater than 0")
 )
 
 // RegisterFlags adds the flags required to config this to the given FlagSet.
@@ -118,6 +122,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&cfg.ActiveQueryTrackerDir, "querier.active-query-tracker-dir", "./active-query-tracker", "Active query tracker monitors active queries, and writes them to the file in given directory. If Cortex discovers any queries in this log during startup, it will log them to the log file. Setting to empty value disables active query tracker, which also disables -querier.max-concurrent option.")
 	f.StringVar(&cfg.StoreGatewayAddresses, "querier.store-gateway-addresses", "", "Comma separated list of store-gateway addresses in DNS Service Discovery format. This option should be set when using the blocks storage and the store-gateway sharding is disabled (when enabled, the store-gateway instances form a ring and addresses are picked from the ring).")
 	f.BoolVar(&cfg.StoreGatewayQueryStatsEnabled, "querier.store-gateway-query-stats-enabled", true, "If enabled, store gateway query stats will be logged using `info` log level.")
+	f.BoolVar(&cfg.StoreGatewayBoundedLoadsEnabled, "querier.store-gateway-bounded-loads-enabled", false, "EXPERIMENTAL: True to pick the store-gateway replicas queried for the blocks of each query with bounded loads, so that no store-gateway is queried for more than (1 + epsilon) times the average number of blocks per store-gateway of the query, as long as the blocks have other replicas with spare capacity.")
+	f.Float64Var(&cfg.StoreGatewayBoundedLoadsEpsilon, "querier.store-gateway-bounded-loads-epsilon", 0.25, "EXPERIMENTAL: Maximum number of blocks queried from each store-gateway above the average, as a fraction of the average, when the store-gateway bounded loads are enabled.")
 	f.DurationVar(&cfg.LookbackDelta, "querier.lookback-delta", 5*time.Minute, "Time since the last sample after which a time series is considered stale and ignored by expression evaluations.")
 	f.DurationVar(&cfg.ShuffleShardingIngestersLookbackPeriod, "querier.shuffle-sharding-ingesters-lookback-period", 0, "When distributor's sharding strategy is shuffle-sharding and this setting is > 0, queriers fetch in-memory series from the minimum set of required ingesters, selecting only ingesters which may have received series since 'now - lookback period'. The lookback period should be greater or equal than the configured 'query store after' and 'query ingesters within'. If this setting is 0, queriers always query all ingesters (ingesters shuffle sharding on read path is disabled).")
 	f.BoolVar(&cfg.ThanosEngine, "querier.thanos-engine", false, "Experimental. Use Thanos promql engine https://github.com/thanos-io/promql-engine rather than the Prometheus promql engine.")
@@ -140,6 +146,10 @@ func (cfg *Config) Validate() error {
 		}
 	}
 
+	if cfg.StoreGatewayBoundedLoadsEnabled && cfg.StoreGatewayBoundedLoadsEpsilon <= 0 {
+		return errInvalidStoreGatewayBoundedLoadsEpsilon
+	}
+
 	return nil
 }
 
@@ -155,8 +165,9 @@ func getChunksIteratorFunction(_ Config) chunkIteratorFunc {
 	return batch.NewChunkMergeIterator
 }
 
-// New builds a queryable and promql engine.
-func New(cfg Config, limits *validation.Overrides, distributor Distributor, stores []QueryableWithFilter, reg prometheus.Registerer, logger log.Logger) (storage.SampleAndChunkQueryable, storage.ExemplarQueryable, promql.QueryEngine) {
+// New builds a queryable and promql engine. If the tombstones loader is not nil,
+// the samples deleted by series deletion requests are filtered out from query results.
+func New(cfg Config, limits *validation.Overrides, distributor Distributor, stores []QueryableWithFilter, tombstonesLoader TombstonesLoader, reg prometheus.Registerer, logger log.Logger) (storage.SampleAndChunkQueryable, storage.ExemplarQueryable, promql.QueryEngine) {
 	iteratorFunc := getChunksIteratorFunction(cfg)
 
 	distributorQueryable := newDistributorQueryable(distributor, cfg.IngesterMetadataStreaming, iteratorFunc, cfg.QueryIngestersWithin, cfg.QueryStoreForLabels)
@@ -169,6 +180,9 @@ func New(cfg Config, limits *validation.Overrides, distributor Distributor, stor
 		}
 	}
 	queryable := NewQueryable(distributorQueryable, ns, iteratorFunc, cfg, limits)
+	if tombstonesLoader != nil {
+		queryable = NewTombstonesQueryable(queryable, tombstonesLoader)
+	}
 	exemplarQueryable := newDistributorExemplarQueryable(distributor)
 
 	lazyQueryable := storage.QueryableFunc(func(mint int64, maxt int64) (storage.Querier, error) {
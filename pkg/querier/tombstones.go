@@ -0,0 +1,175 @@
+package querier
+
+import (
+	"context"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/tombstones"
+	"github.com/prometheus/prometheus/util/annotations"
+
+	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
+	"github.com/cortexproject/cortex/pkg/tenant"
+)
+
+// TombstonesLoader loads the series deletion tombstones of a tenant.
+type TombstonesLoader interface {
+	// GetTombstones returns the tombstones of the tenant which should be applied at query time.
+	GetTombstones(ctx context.Context, userID string) (bucketindex.Tombstones, error)
+}
+
+// NewTombstonesQueryable wraps the input queryable in order to filter out the samples
+// deleted by series deletion requests which have not been permanently processed yet.
+func NewTombstonesQueryable(queryable storage.Queryable, loader TombstonesLoader) storage.Queryable {
+	return storage.QueryableFunc(func(mint, maxt int64) (storage.Querier, error) {
+		q, err := queryable.Querier(mint, maxt)
+		if err != nil {
+			return nil, err
+		}
+
+		return &tombstonesQuerier{Querier: q, loader: loader, mint: mint, maxt: maxt}, nil
+	})
+}
+
+type tombstonesQuerier struct {
+	storage.Querier
+
+	loader     TombstonesLoader
+	mint, maxt int64
+}
+
+// Select implements storage.Querier.
+func (q *tombstonesQuerier) Select(ctx context.Context, sortSeries bool, sp *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return storage.ErrSeriesSet(err)
+	}
+
+	all, err := q.loader.GetTombstones(ctx, userID)
+	if err != nil {
+		return storage.ErrSeriesSet(err)
+	}
+
+	minT, maxT := q.mint, q.maxt
+	if sp != nil {
+		minT, maxT = sp.Start, sp.End
+	}
+
+	filters, err := newTombstoneFilters(all.GetTombstonesForQuery(minT, maxT))
+	if err != nil {
+		return storage.ErrSeriesSet(err)
+	}
+
+	set := q.Querier.Select(ctx, sortSeries, sp, matchers...)
+	if len(filters) == 0 {
+		return set
+	}
+
+	return &tombstonesSeriesSet{SeriesSet: set, filters: filters}
+}
+
+// tombstoneFilter holds the parsed selectors and the time range of a tombstone.
+type tombstoneFilter struct {
+	selectors [][]*labels.Matcher
+	interval  tombstones.Interval
+}
+
+func newTombstoneFilters(ts bucketindex.Tombstones) ([]tombstoneFilter, error) {
+	filters := make([]tombstoneFilter, 0, len(ts))
+	for _, t := range ts {
+		selectors, err := t.GetMatchers()
+		if err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, tombstoneFilter{
+			selectors: selectors,
+			interval:  tombstones.Interval{Mint: t.StartTime, Maxt: t.EndTime},
+		})
+	}
+	return filters, nil
+}
+
+// matches returns whether the series matches any of the tombstone selectors.
+func (f tombstoneFilter) matches(lbls labels.Labels) bool {
+	for _, selector := range f.selectors {
+		if matchesAll(selector, lbls) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAll(matchers []*labels.Matcher, lbls labels.Labels) bool {
+	for _, m := range matchers {
+		if !m.Matches(lbls.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// tombstonesSeriesSet filters out the deleted samples from the wrapped series set,
+// and skips the series which have no samples left.
+type tombstonesSeriesSet struct {
+	storage.SeriesSet
+
+	filters []tombstoneFilter
+	curr    storage.Series
+}
+
+func (s *tombstonesSeriesSet) Next() bool {
+	for s.SeriesSet.Next() {
+		series := s.SeriesSet.At()
+
+		var intervals tombstones.Intervals
+		for _, f := range s.filters {
+			if f.matches(series.Labels()) {
+				intervals = intervals.Add(f.interval)
+			}
+		}
+
+		if len(intervals) == 0 {
+			s.curr = series
+			return true
+		}
+
+		filtered := &tombstonesSeries{Series: series, intervals: intervals}
+		if filtered.Iterator(nil).Next() == chunkenc.ValNone {
+			// All samples have been deleted.
+			continue
+		}
+
+		s.curr = filtered
+		return true
+	}
+
+	return false
+}
+
+func (s *tombstonesSeriesSet) At() storage.Series {
+	return s.curr
+}
+
+func (s *tombstonesSeriesSet) Warnings() annotations.Annotations {
+	return s.SeriesSet.Warnings()
+}
+
+// tombstonesSeries wraps a series in order to filter out the deleted samples.
+type tombstonesSeries struct {
+	storage.Series
+
+	intervals tombstones.Intervals
+}
+
+func (s *tombstonesSeries) Iterator(it chunkenc.Iterator) chunkenc.Iterator {
+	if deleted, ok := it.(*tsdb.DeletedIterator); ok {
+		deleted.Iter = s.Series.Iterator(deleted.Iter)
+		deleted.Intervals = s.intervals
+		return deleted
+	}
+
+	return &tsdb.DeletedIterator{Iter: s.Series.Iterator(it), Intervals: s.intervals}
+}
@@ -0,0 +1,120 @@
+package querier
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/querier/series"
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
+)
+
+func TestTombstonesQueryable(t *testing.T) {
+	const userID = "user-1"
+
+	series1 := labels.FromStrings(labels.MetricName, "series_1")
+	series2 := labels.FromStrings(labels.MetricName, "series_2")
+
+	for name, tc := range map[string]struct {
+		tombstones bucketindex.Tombstones
+		expected   map[string][]int64
+	}{
+		"no tombstones": {
+			expected: map[string][]int64{
+				series1.String(): {10, 20, 30, 40},
+				series2.String(): {10, 20, 30, 40},
+			},
+		},
+		"tombstone deleting a time range of a series": {
+			tombstones: bucketindex.Tombstones{
+				&cortex_tsdb.Tombstone{StartTime: 15, EndTime: 30, Selectors: []string{`{__name__="series_1"}`}},
+			},
+			expected: map[string][]int64{
+				series1.String(): {10, 40},
+				series2.String(): {10, 20, 30, 40},
+			},
+		},
+		"tombstone deleting all samples of a series": {
+			tombstones: bucketindex.Tombstones{
+				&cortex_tsdb.Tombstone{StartTime: 0, EndTime: 50, Selectors: []string{`{__name__="series_2"}`}},
+			},
+			expected: map[string][]int64{
+				series1.String(): {10, 20, 30, 40},
+			},
+		},
+		"multiple tombstones matching the same series": {
+			tombstones: bucketindex.Tombstones{
+				&cortex_tsdb.Tombstone{StartTime: 0, EndTime: 10, Selectors: []string{`{__name__=~"series_.*"}`}},
+				&cortex_tsdb.Tombstone{StartTime: 35, EndTime: 50, Selectors: []string{`{__name__="series_1"}`}},
+			},
+			expected: map[string][]int64{
+				series1.String(): {20, 30},
+				series2.String(): {20, 30, 40},
+			},
+		},
+		"tombstone outside the queried time range": {
+			tombstones: bucketindex.Tombstones{
+				&cortex_tsdb.Tombstone{StartTime: 100, EndTime: 200, Selectors: []string{`{__name__="series_1"}`}},
+			},
+			expected: map[string][]int64{
+				series1.String(): {10, 20, 30, 40},
+				series2.String(): {10, 20, 30, 40},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			samples := []model.SamplePair{{Timestamp: 10, Value: 1}, {Timestamp: 20, Value: 2}, {Timestamp: 30, Value: 3}, {Timestamp: 40, Value: 4}}
+			upstream := storage.QueryableFunc(func(mint, maxt int64) (storage.Querier, error) {
+				return &mockTombstonesUpstreamQuerier{series: []storage.Series{
+					series.NewConcreteSeries(series1, samples),
+					series.NewConcreteSeries(series2, samples),
+				}}, nil
+			})
+
+			queryable := NewTombstonesQueryable(upstream, &mockTombstonesLoader{tombstones: tc.tombstones})
+			q, err := queryable.Querier(0, 50)
+			require.NoError(t, err)
+
+			ctx := user.InjectOrgID(context.Background(), userID)
+			set := q.Select(ctx, true, nil, labels.MustNewMatcher(labels.MatchRegexp, labels.MetricName, ".+"))
+
+			actual := map[string][]int64{}
+			for set.Next() {
+				s := set.At()
+				it := s.Iterator(nil)
+				for it.Next() != chunkenc.ValNone {
+					ts, _ := it.At()
+					actual[s.Labels().String()] = append(actual[s.Labels().String()], ts)
+				}
+			}
+			require.NoError(t, set.Err())
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+type mockTombstonesLoader struct {
+	tombstones bucketindex.Tombstones
+}
+
+func (m *mockTombstonesLoader) GetTombstones(_ context.Context, _ string) (bucketindex.Tombstones, error) {
+	return m.tombstones, nil
+}
+
+type mockTombstonesUpstreamQuerier struct {
+	storage.Querier
+
+	series []storage.Series
+}
+
+func (m *mockTombstonesUpstreamQuerier) Select(_ context.Context, sortSeries bool, _ *storage.SelectHints, _ ...*labels.Matcher) storage.SeriesSet {
+	return series.NewConcreteSeriesSet(sortSeries, m.series)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -29,6 +30,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/querier"
 	"github.com/cortexproject/cortex/pkg/querier/tripperware"
 	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util/compression"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
 	"github.com/cortexproject/cortex/pkg/util/spanlogger"
@@ -44,14 +46,21 @@ var (
 type ResultsCacheConfig struct {
 	CacheConfig                cache.Config `yaml:"cache"`
 	Compression                string       `yaml:"compression"`
+	CompressionLevel           int          `yaml:"compression_level"`
+	CompressionMinSizeBytes    int          `yaml:"compression_min_size_bytes"`
 	CacheQueryableSamplesStats bool         `yaml:"cache_queryable_samples_stats"`
+
+	// Injected at runtime when the cache generations are enabled.
+	CacheGenerations CacheGenerations `yaml:"-"`
 }
 
 // RegisterFlags registers flags.
 func (cfg *ResultsCacheConfig) RegisterFlags(f *flag.FlagSet) {
 	cfg.CacheConfig.RegisterFlagsWithPrefix("frontend.", "", f)
 
-	f.StringVar(&cfg.Compression, "frontend.compression", "", "Use compression in results cache. Supported values are: 'snappy' and '' (disable compression).")
+	f.StringVar(&cfg.Compression, "frontend.compression", "", "Use compression in results cache. Supported values are: 'snappy', 'zstd' and '' (disable compression).")
+	f.IntVar(&cfg.CompressionLevel, "frontend.compression-level", 0, "[Experimental] Compression level of the results cache with the zstd compression, from 1 (fastest) to 22 (smallest). 0 to use the default level.")
+	f.IntVar(&cfg.CompressionMinSizeBytes, "frontend.compression-min-size-bytes", 0, "[Experimental] Minimum size in bytes of the results cache entries to compress. The smaller entries are stored uncompressed.")
 	f.BoolVar(&cfg.CacheQueryableSamplesStats, "frontend.cache-queryable-samples-stats", false, "Cache Statistics queryable samples on results cache.")
 	//lint:ignore faillint Need to pass the global logger like this for warning on deprecated methods
 	flagext.DeprecatedFlag(f, "frontend.cache-split-interval", "Deprecated: The maximum interval expected for each request, results will be cached per single interval. This behavior is now determined by querier.split-queries-by-interval.", util_log.Logger)
@@ -59,11 +68,15 @@ func (cfg *ResultsCacheConfig) RegisterFlags(f *flag.FlagSet) {
 
 func (cfg *ResultsCacheConfig) Validate(qCfg querier.Config) error {
 	switch cfg.Compression {
-	case "snappy", "":
+	case compression.Snappy, compression.Zstd, "":
 		// valid
 	default:
 		return errors.Errorf("unsupported compression type: %s", cfg.Compression)
 	}
+	compressionCfg := cfg.compressionConfig()
+	if err := compressionCfg.Validate(); err != nil {
+		return err
+	}
 
 	if cfg.CacheQueryableSamplesStats && !qCfg.EnablePerStepStats {
 		return errors.New("frontend.cache-queryable-samples-stats may only be enabled in conjunction with querier.per-step-stats-enabled. Please set the latter")
@@ -72,6 +85,20 @@ func (cfg *ResultsCacheConfig) Validate(qCfg querier.Config) error {
 	return cfg.CacheConfig.Validate()
 }
 
+func (cfg *ResultsCacheConfig) compressionConfig() compression.Config {
+	return compression.Config{
+		Codec:        cfg.Compression,
+		Level:        cfg.CompressionLevel,
+		MinSizeBytes: cfg.CompressionMinSizeBytes,
+	}
+}
+
+// CacheGenerations returns the cache generation of a tenant, versioning the keys of its cached results so that bumping
+// the generation invalidates them.
+type CacheGenerations interface {
+	Generation(ctx context.Context, userID string) int64
+}
+
 // Extractor is used by the cache to extract a subset of a response from a cache entry.
 type Extractor interface {
 	// Extract extracts a subset of a response from the `start` and `end` timestamps in milliseconds in the `from` response.
@@ -181,8 +208,12 @@ func NewResultsCacheMiddleware(
 	if err != nil {
 		return nil, nil, err
 	}
-	if cfg.Compression == "snappy" {
-		c = cache.NewSnappy(c, logger)
+	compressor, err := compression.NewCompressor(cfg.compressionConfig())
+	if err != nil {
+		return nil, nil, err
+	}
+	if compressor != nil {
+		c = cache.NewCompressed(c, compressor, logger)
 	}
 
 	return tripperware.MiddlewareFunc(func(next tripperware.Handler) tripperware.Handler {
@@ -222,7 +253,7 @@ func (s resultsCache) Do(ctx context.Context, r tripperware.Request) (tripperwar
 	}
 
 	var (
-		key      = s.splitter.GenerateCacheKey(tenant.JoinTenantIDs(tenantIDs), r)
+		key      = s.cacheGenerationPrefix(ctx, tenantIDs) + s.splitter.GenerateCacheKey(tenant.JoinTenantIDs(tenantIDs), r)
 		extents  []Extent
 		response tripperware.Response
 	)
@@ -255,6 +286,26 @@ func (s resultsCache) Do(ctx context.Context, r tripperware.Request) (tripperwar
 	return response, err
 }
 
+// cacheGenerationPrefix returns the prefix of the cache key versioning it with the cache generations of the tenants,
+// empty when none of their generations has been bumped.
+func (s resultsCache) cacheGenerationPrefix(ctx context.Context, tenantIDs []string) string {
+	if s.cfg.CacheGenerations == nil {
+		return ""
+	}
+
+	bumped := false
+	generations := make([]string, 0, len(tenantIDs))
+	for _, tenantID := range tenantIDs {
+		generation := s.cfg.CacheGenerations.Generation(ctx, tenantID)
+		bumped = bumped || generation != 0
+		generations = append(generations, strconv.FormatInt(generation, 10))
+	}
+	if !bumped {
+		return ""
+	}
+	return "g" + strings.Join(generations, ",") + ":"
+}
+
 // shouldCacheResponse says whether the response should be cached or not.
 func (s resultsCache) shouldCacheResponse(ctx context.Context, req tripperware.Request, r tripperware.Response, maxCacheTime int64) bool {
 	headerValues := getHeaderValuesWithName(r, cacheControlHeader)
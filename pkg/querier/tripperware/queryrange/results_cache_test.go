@@ -1004,6 +1004,59 @@ func TestResultsCache(t *testing.T) {
 	require.Equal(t, 2, calls)
 }
 
+func TestResultsCache_ShouldMissTheResultsCachedWithAPreviousCacheGeneration(t *testing.T) {
+	t.Parallel()
+	calls := 0
+	generations := mockCacheGenerations{}
+	cfg := ResultsCacheConfig{
+		CacheConfig: cache.Config{
+			Cache: cache.NewMockCache(),
+		},
+		CacheGenerations: generations,
+	}
+	rcm, _, err := NewResultsCacheMiddleware(
+		log.NewNopLogger(),
+		cfg,
+		constSplitter(day),
+		mockLimits{},
+		PrometheusCodec,
+		PrometheusResponseExtractor{},
+		nil,
+		nil,
+	)
+	require.NoError(t, err)
+
+	rc := rcm.Wrap(tripperware.HandlerFunc(func(_ context.Context, req tripperware.Request) (tripperware.Response, error) {
+		calls++
+		return parsedResponse, nil
+	}))
+	ctx := user.InjectOrgID(context.Background(), "1")
+	_, err = rc.Do(ctx, parsedRequest)
+	require.NoError(t, err)
+	require.Equal(t, 1, calls)
+
+	// Bumping the generation of another tenant doesn't invalidate the cached results.
+	generations["2"] = 1
+	_, err = rc.Do(ctx, parsedRequest)
+	require.NoError(t, err)
+	require.Equal(t, 1, calls)
+
+	generations["1"] = 1
+	_, err = rc.Do(ctx, parsedRequest)
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+
+	_, err = rc.Do(ctx, parsedRequest)
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+}
+
+type mockCacheGenerations map[string]int64
+
+func (m mockCacheGenerations) Generation(_ context.Context, userID string) int64 {
+	return m[userID]
+}
+
 func TestResultsCacheRecent(t *testing.T) {
 	t.Parallel()
 	var cfg ResultsCacheConfig
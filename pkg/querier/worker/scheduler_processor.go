@@ -156,7 +156,7 @@ func (sp *schedulerProcessor) querierLoop(c schedulerpb.SchedulerForQuerier_Quer
 				ctx = spanCtx
 			}
 			logger := util_log.WithContext(ctx, sp.log)
-			sp.runRequest(ctx, logger, request.QueryID, request.FrontendAddress, request.StatsEnabled, request.HttpRequest)
+			sp.runRequest(ctx, logger, request.QueryID, request.FrontendAddress, request.StatsEnabled, request.HttpRequest, request.CoalescedRequests)
 
 			if err = ctx.Err(); err != nil {
 				return
@@ -170,7 +170,7 @@ func (sp *schedulerProcessor) querierLoop(c schedulerpb.SchedulerForQuerier_Quer
 	}
 }
 
-func (sp *schedulerProcessor) runRequest(ctx context.Context, logger log.Logger, queryID uint64, frontendAddress string, statsEnabled bool, request *httpgrpc.HTTPRequest) {
+func (sp *schedulerProcessor) runRequest(ctx context.Context, logger log.Logger, queryID uint64, frontendAddress string, statsEnabled bool, request *httpgrpc.HTTPRequest, coalesced []schedulerpb.CoalescedRequest) {
 	var stats *querier_stats.QueryStats
 	if statsEnabled {
 		stats, ctx = querier_stats.ContextWithEmptyStats(ctx)
@@ -206,6 +206,16 @@ func (sp *schedulerProcessor) runRequest(ctx context.Context, logger log.Logger,
 		}
 	}
 
+	sp.sendResultToFrontend(ctx, logger, queryID, frontendAddress, response, stats)
+
+	// The query-scheduler has coalesced identical queries into this one, so the
+	// same response is sent to each frontend waiting for it.
+	for _, r := range coalesced {
+		sp.sendResultToFrontend(ctx, logger, r.QueryID, r.FrontendAddress, response, stats)
+	}
+}
+
+func (sp *schedulerProcessor) sendResultToFrontend(ctx context.Context, logger log.Logger, queryID uint64, frontendAddress string, response *httpgrpc.HTTPResponse, stats *querier_stats.QueryStats) {
 	c, err := sp.frontendPool.GetClientFor(frontendAddress)
 	if err == nil {
 		// Response is empty and uninteresting.
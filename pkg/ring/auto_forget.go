@@ -0,0 +1,133 @@
+package ring
+
+import (
+	"flag"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	autoForgetSkippedMinHealthy   = "min-healthy-instances"
+	autoForgetSkippedMassOutage   = "max-unhealthy-ratio"
+	autoForgetMinUnhealthyPeriods = 2
+)
+
+var (
+	errAutoForgetUnhealthyPeriodTooShort = errors.New("the auto-forget unhealthy period must be at least twice the ring heartbeat timeout")
+	errAutoForgetInvalidUnhealthyRatio   = errors.New("the auto-forget max unhealthy ratio must be between 0 and 1")
+)
+
+// AutoForgetConfig configures the automatic forgetting of the instances which have been unhealthy
+// for a long time, eg. crashed instances which will never come back to unregister themselves.
+type AutoForgetConfig struct {
+	UnhealthyPeriod     time.Duration `yaml:"auto_forget_unhealthy_period"`
+	MinHealthyInstances int           `yaml:"auto_forget_min_healthy_instances"`
+	MaxUnhealthyRatio   float64       `yaml:"auto_forget_max_unhealthy_ratio"`
+}
+
+// RegisterFlagsWithPrefix adds the flags required to config this to the given FlagSet with a specified prefix.
+func (cfg *AutoForgetConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.DurationVar(&cfg.UnhealthyPeriod, prefix+"auto-forget-unhealthy-period", 0, "EXPERIMENTAL: Period after which the instances which didn't heartbeat the ring are automatically forgotten, removing them from the ring. Must be at least twice the ring heartbeat timeout. 0 = disabled.")
+	f.IntVar(&cfg.MinHealthyInstances, prefix+"auto-forget-min-healthy-instances", 1, "EXPERIMENTAL: Minimum number of healthy instances in the ring for the unhealthy instances to be automatically forgotten.")
+	f.Float64Var(&cfg.MaxUnhealthyRatio, prefix+"auto-forget-max-unhealthy-ratio", 0.5, "EXPERIMENTAL: Maximum ratio of unhealthy instances in the ring for the unhealthy instances to be automatically forgotten. Above it, the instances are assumed to be unhealthy because of an outage and they are kept in the ring. 1 = no limit.")
+}
+
+// Validate the config against the heartbeat timeout of the ring.
+func (cfg *AutoForgetConfig) Validate(heartbeatTimeout time.Duration) error {
+	if cfg.UnhealthyPeriod == 0 {
+		return nil
+	}
+	if cfg.UnhealthyPeriod < autoForgetMinUnhealthyPeriods*heartbeatTimeout {
+		return errAutoForgetUnhealthyPeriodTooShort
+	}
+	if cfg.MaxUnhealthyRatio < 0 || cfg.MaxUnhealthyRatio > 1 {
+		return errAutoForgetInvalidUnhealthyRatio
+	}
+	return nil
+}
+
+// autoForgetter removes from the ring the instances which have been unhealthy for longer than the
+// unhealthy period, unless the ring is in a state where forgetting them would be unsafe.
+type autoForgetter struct {
+	cfg              AutoForgetConfig
+	heartbeatTimeout time.Duration
+	ringName         string
+	logger           log.Logger
+
+	forgottenInstances prometheus.Counter
+	skipped            *prometheus.CounterVec
+}
+
+func newAutoForgetter(cfg AutoForgetConfig, heartbeatTimeout time.Duration, ringName string, logger log.Logger, reg prometheus.Registerer) *autoForgetter {
+	return &autoForgetter{
+		cfg:              cfg,
+		heartbeatTimeout: heartbeatTimeout,
+		ringName:         ringName,
+		logger:           logger,
+		forgottenInstances: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "ring_auto_forgotten_instances_total",
+			Help:        "The total number of unhealthy instances automatically forgotten from the ring.",
+			ConstLabels: prometheus.Labels{"name": ringName},
+		}),
+		skipped: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name:        "ring_auto_forget_skipped_total",
+			Help:        "The total number of times the unhealthy instances haven't been automatically forgotten from the ring because it would be unsafe.",
+			ConstLabels: prometheus.Labels{"name": ringName},
+		}, []string{"reason"}),
+	}
+}
+
+// forgetUnhealthyInstances removes the instances unhealthy for longer than the unhealthy period
+// from the ring, and returns their IDs.
+func (f *autoForgetter) forgetUnhealthyInstances(ringDesc *Desc, now time.Time) []string {
+	if f.cfg.UnhealthyPeriod == 0 || len(ringDesc.Ingesters) == 0 {
+		return nil
+	}
+
+	var toForget []string
+	healthy := 0
+	for id, instance := range ringDesc.Ingesters {
+		lastHeartbeat := time.Unix(instance.GetTimestamp(), 0)
+		if instance.IsHeartbeatHealthy(f.heartbeatTimeout, now) {
+			healthy++
+		} else if now.Sub(lastHeartbeat) > f.cfg.UnhealthyPeriod {
+			toForget = append(toForget, id)
+		}
+	}
+	if len(toForget) == 0 {
+		return nil
+	}
+	sort.Strings(toForget)
+
+	// Never forget instances while the ring has too few healthy instances, or while too many instances
+	// are unhealthy, because it's more likely an outage the instances will recover from.
+	unhealthyRatio := float64(len(ringDesc.Ingesters)-healthy) / float64(len(ringDesc.Ingesters))
+	if healthy < f.cfg.MinHealthyInstances {
+		f.skip(autoForgetSkippedMinHealthy, toForget, healthy, unhealthyRatio)
+		return nil
+	}
+	if unhealthyRatio > f.cfg.MaxUnhealthyRatio {
+		f.skip(autoForgetSkippedMassOutage, toForget, healthy, unhealthyRatio)
+		return nil
+	}
+
+	for _, id := range toForget {
+		instance := ringDesc.Ingesters[id]
+		lastHeartbeat := time.Unix(instance.GetTimestamp(), 0)
+		level.Warn(f.logger).Log("msg", "auto-forgetting instance from the ring because it is unhealthy for a long time", "event", "ring_instance_auto_forgotten", "ring", f.ringName, "instance", id, "addr", instance.Addr, "zone", instance.Zone, "last_heartbeat", lastHeartbeat.String(), "unhealthy_period", f.cfg.UnhealthyPeriod)
+		ringDesc.RemoveIngester(id)
+	}
+	f.forgottenInstances.Add(float64(len(toForget)))
+	return toForget
+}
+
+func (f *autoForgetter) skip(reason string, instances []string, healthy int, unhealthyRatio float64) {
+	level.Warn(f.logger).Log("msg", "not auto-forgetting unhealthy instances from the ring because it would be unsafe", "event", "ring_instance_auto_forget_skipped", "ring", f.ringName, "reason", reason, "instances", len(instances), "healthy_instances", healthy, "unhealthy_ratio", unhealthyRatio)
+	f.skipped.WithLabelValues(reason).Inc()
+}
@@ -0,0 +1,120 @@
+package ring
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoForgetConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		cfg      AutoForgetConfig
+		expected error
+	}{
+		"disabled": {
+			cfg: AutoForgetConfig{},
+		},
+		"valid": {
+			cfg: AutoForgetConfig{UnhealthyPeriod: 10 * time.Minute, MaxUnhealthyRatio: 0.5},
+		},
+		"unhealthy period shorter than twice the heartbeat timeout": {
+			cfg:      AutoForgetConfig{UnhealthyPeriod: 90 * time.Second, MaxUnhealthyRatio: 0.5},
+			expected: errAutoForgetUnhealthyPeriodTooShort,
+		},
+		"invalid max unhealthy ratio": {
+			cfg:      AutoForgetConfig{UnhealthyPeriod: 10 * time.Minute, MaxUnhealthyRatio: 1.5},
+			expected: errAutoForgetInvalidUnhealthyRatio,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, testData.cfg.Validate(time.Minute))
+		})
+	}
+}
+
+func TestAutoForgetter_ForgetUnhealthyInstances(t *testing.T) {
+	const unhealthyPeriod = 10 * time.Minute
+	now := time.Now()
+
+	// Instances are healthy if the last heartbeat is within the heartbeat timeout, unhealthy
+	// before it, and to forget before the unhealthy period.
+	healthy := now.Add(-10 * time.Second)
+	unhealthy := now.Add(-5 * time.Minute)
+	toForget := now.Add(-unhealthyPeriod - time.Minute)
+
+	tests := map[string]struct {
+		cfg               AutoForgetConfig
+		heartbeats        []time.Time
+		expectedForgotten []string
+		expectedSkipped   string
+	}{
+		"disabled": {
+			cfg:        AutoForgetConfig{},
+			heartbeats: []time.Time{healthy, healthy, toForget},
+		},
+		"no instance unhealthy for longer than the unhealthy period": {
+			cfg:        AutoForgetConfig{UnhealthyPeriod: unhealthyPeriod, MinHealthyInstances: 1, MaxUnhealthyRatio: 0.5},
+			heartbeats: []time.Time{healthy, healthy, unhealthy},
+		},
+		"instance unhealthy for longer than the unhealthy period": {
+			cfg:               AutoForgetConfig{UnhealthyPeriod: unhealthyPeriod, MinHealthyInstances: 1, MaxUnhealthyRatio: 0.5},
+			heartbeats:        []time.Time{healthy, healthy, toForget},
+			expectedForgotten: []string{"instance-3"},
+		},
+		"too few healthy instances": {
+			cfg:             AutoForgetConfig{UnhealthyPeriod: unhealthyPeriod, MinHealthyInstances: 3, MaxUnhealthyRatio: 0.5},
+			heartbeats:      []time.Time{healthy, healthy, toForget},
+			expectedSkipped: autoForgetSkippedMinHealthy,
+		},
+		"too many unhealthy instances": {
+			cfg:             AutoForgetConfig{UnhealthyPeriod: unhealthyPeriod, MinHealthyInstances: 1, MaxUnhealthyRatio: 0.5},
+			heartbeats:      []time.Time{healthy, unhealthy, toForget, toForget},
+			expectedSkipped: autoForgetSkippedMassOutage,
+		},
+		"too many unhealthy instances with no limit": {
+			cfg:               AutoForgetConfig{UnhealthyPeriod: unhealthyPeriod, MinHealthyInstances: 1, MaxUnhealthyRatio: 1},
+			heartbeats:        []time.Time{healthy, unhealthy, toForget, toForget},
+			expectedForgotten: []string{"instance-3", "instance-4"},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ringDesc := NewDesc()
+			for n, heartbeat := range testData.heartbeats {
+				id := "instance-" + string(rune('1'+n))
+				instance := ringDesc.AddIngester(id, id, "", nil, ACTIVE, now)
+				instance.Timestamp = heartbeat.Unix()
+				ringDesc.Ingesters[id] = instance
+			}
+
+			reg := prometheus.NewPedanticRegistry()
+			f := newAutoForgetter(testData.cfg, time.Minute, "test", log.NewNopLogger(), reg)
+			forgotten := f.forgetUnhealthyInstances(ringDesc, now)
+
+			assert.Equal(t, testData.expectedForgotten, forgotten)
+			assert.Len(t, ringDesc.Ingesters, len(testData.heartbeats)-len(testData.expectedForgotten))
+			for _, id := range testData.expectedForgotten {
+				assert.NotContains(t, ringDesc.Ingesters, id)
+			}
+
+			expectedSkipped := ""
+			if testData.expectedSkipped != "" {
+				expectedSkipped = `
+					# HELP ring_auto_forget_skipped_total The total number of times the unhealthy instances haven't been automatically forgotten from the ring because it would be unsafe.
+					# TYPE ring_auto_forget_skipped_total counter
+					ring_auto_forget_skipped_total{name="test",reason="` + testData.expectedSkipped + `"} 1
+				`
+			}
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expectedSkipped), "ring_auto_forget_skipped_total"))
+			assert.Equal(t, float64(len(testData.expectedForgotten)), testutil.ToFloat64(f.forgottenInstances))
+		})
+	}
+}
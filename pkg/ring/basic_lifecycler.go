@@ -5,7 +5,6 @@ import (
 	"fmt"
 	mathrand "math/rand"
 	"sort"
-	"strings"
 	"sync"
 	"time"
 
@@ -13,6 +12,7 @@ import (
 	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/atomic"
 
 	"github.com/cortexproject/cortex/pkg/ring/kv"
 	"github.com/cortexproject/cortex/pkg/util/services"
@@ -53,6 +53,11 @@ type BasicLifecyclerConfig struct {
 	TokensObservePeriod     time.Duration
 	NumTokens               int
 	TokensGeneratorStrategy string
+	TokensStaticFile        string
+
+	// If true, the heartbeats are aligned to the heartbeat period, so that the heartbeats of the
+	// lifecyclers of the same process are batched together when their CAS operations are batched.
+	AlignHeartbeats bool
 
 	// If true lifecycler doesn't unregister instance from the ring when it's stopping. Default value is false,
 	// which means unregistering.
@@ -87,13 +92,17 @@ type BasicLifecycler struct {
 	// The current instance state.
 	currState        sync.RWMutex
 	currInstanceDesc *InstanceDesc
+
+	// Whether to keep the instance in the ring when it's stopping. Initialised from the config, and
+	// changed when the shutdown of the instance is prepared.
+	keepInstanceInTheRingOnShutdown *atomic.Bool
 }
 
 // NewBasicLifecycler makes a new BasicLifecycler.
 func NewBasicLifecycler(cfg BasicLifecyclerConfig, ringName, ringKey string, store kv.Client, delegate BasicLifecyclerDelegate, logger log.Logger, reg prometheus.Registerer) (*BasicLifecycler, error) {
-	tg := NewRandomTokenGenerator()
-	if strings.EqualFold(cfg.TokensGeneratorStrategy, minimizeSpreadTokenStrategy) {
-		tg = NewMinimizeSpreadTokenGenerator()
+	tg, err := NewTokenGenerator(cfg.TokensGeneratorStrategy, cfg.TokensStaticFile)
+	if err != nil {
+		return nil, err
 	}
 
 	l := &BasicLifecycler{
@@ -106,6 +115,8 @@ func NewBasicLifecycler(cfg BasicLifecyclerConfig, ringName, ringKey string, sto
 		metrics:        NewBasicLifecyclerMetrics(ringName, reg),
 		actorChan:      make(chan func()),
 		TokenGenerator: tg,
+
+		keepInstanceInTheRingOnShutdown: atomic.NewBool(cfg.KeepInstanceInTheRingOnShutdown),
 	}
 
 	l.metrics.tokensToOwn.Set(float64(cfg.NumTokens))
@@ -201,7 +212,11 @@ func (l *BasicLifecycler) running(ctx context.Context) error {
 	if uint64(l.cfg.HeartbeatPeriod) > 0 {
 		heartbeatTicker := time.NewTicker(l.cfg.HeartbeatPeriod)
 		heartbeatTicker.Stop()
-		time.AfterFunc(time.Duration(uint64(mathrand.Int63())%uint64(l.cfg.HeartbeatPeriod)), func() {
+		delay := time.Duration(uint64(mathrand.Int63()) % uint64(l.cfg.HeartbeatPeriod))
+		if l.cfg.AlignHeartbeats {
+			delay = alignedHeartbeatDelay(time.Now(), l.cfg.HeartbeatPeriod)
+		}
+		time.AfterFunc(delay, func() {
 			l.heartbeat(ctx)
 			heartbeatTicker.Reset(l.cfg.HeartbeatPeriod)
 		})
@@ -252,7 +267,7 @@ heartbeatLoop:
 		}
 	}
 
-	if l.cfg.KeepInstanceInTheRingOnShutdown {
+	if l.ShouldKeepInstanceInTheRingOnShutdown() {
 		level.Info(l.logger).Log("msg", "keeping instance the ring", "ring", l.ringName)
 	} else {
 		// Remove the instance from the ring.
@@ -475,6 +490,16 @@ func (l *BasicLifecycler) heartbeat(ctx context.Context) {
 	l.metrics.heartbeats.Inc()
 }
 
+// ShouldKeepInstanceInTheRingOnShutdown returns whether the instance is kept in the ring when it's stopping.
+func (l *BasicLifecycler) ShouldKeepInstanceInTheRingOnShutdown() bool {
+	return l.keepInstanceInTheRingOnShutdown.Load()
+}
+
+// SetKeepInstanceInTheRingOnShutdown enables/disables keeping the instance in the ring when it's stopping.
+func (l *BasicLifecycler) SetKeepInstanceInTheRingOnShutdown(enabled bool) {
+	l.keepInstanceInTheRingOnShutdown.Store(enabled)
+}
+
 // changeState of the instance within the ring. This function is guaranteed
 // to be called within the lifecycler main goroutine.
 func (l *BasicLifecycler) changeState(ctx context.Context, state InstanceState) error {
@@ -67,6 +67,35 @@ func (i *itemTracker) getError() error {
 //
 // Not implemented as a method on Ring so we can test separately.
 func DoBatch(ctx context.Context, op Operation, r ReadRing, keys []uint32, callback func(InstanceDesc, []int) error, cleanup func()) error {
+	return doBatch(ctx, r, keys, func(key uint32, bufDescs []InstanceDesc, bufHosts []string, bufZones map[string]int) (ReplicationSet, error) {
+		return r.Get(key, op, bufDescs, bufHosts, bufZones)
+	}, callback, cleanup)
+}
+
+// DoBatchWithBoundedLoads is like DoBatch, but assigns the keys to the instances with consistent hashing
+// with bounded loads, so that no instance is sent more than (1+epsilon) times the average number of keys
+// per instance of the batch.
+func DoBatchWithBoundedLoads(ctx context.Context, op Operation, r ReadRing, keys []uint32, epsilon float64, callback func(InstanceDesc, []int) error, cleanup func()) error {
+	loads := NewBoundedLoads(len(keys)*r.ReplicationFactor(), r.InstancesCount(), epsilon)
+
+	return doBatch(ctx, r, keys, func(key uint32, bufDescs []InstanceDesc, bufHosts []string, bufZones map[string]int) (ReplicationSet, error) {
+		return r.GetWithBoundedLoads(key, op, loads, bufDescs, bufHosts, bufZones)
+	}, callback, cleanup)
+}
+
+// DoBatchWithPartitions is like DoBatch, but sends each key to the owners of its partition instead of the
+// instances owning its token range.
+func DoBatchWithPartitions(ctx context.Context, op Operation, r PartitionReadRing, keys []uint32, callback func(InstanceDesc, []int) error, cleanup func()) error {
+	return doBatch(ctx, r, keys, func(key uint32, bufDescs []InstanceDesc, _ []string, _ map[string]int) (ReplicationSet, error) {
+		partition, err := r.GetPartition(key)
+		if err != nil {
+			return ReplicationSet{}, err
+		}
+		return r.GetPartitionOwners(partition, op, bufDescs)
+	}, callback, cleanup)
+}
+
+func doBatch(ctx context.Context, r ReadRing, keys []uint32, get func(uint32, []InstanceDesc, []string, map[string]int) (ReplicationSet, error), callback func(InstanceDesc, []int) error, cleanup func()) error {
 	if r.InstancesCount() <= 0 {
 		cleanup()
 		return fmt.Errorf("DoBatch: InstancesCount <= 0")
@@ -82,7 +111,7 @@ func DoBatch(ctx context.Context, op Operation, r ReadRing, keys []uint32, callb
 		bufZones = make(map[string]int, GetZoneSize)
 	)
 	for i, key := range keys {
-		replicationSet, err := r.Get(key, op, bufDescs[:0], bufHosts[:0], bufZones)
+		replicationSet, err := get(key, bufDescs[:0], bufHosts[:0], bufZones)
 		if err != nil {
 			cleanup()
 			return err
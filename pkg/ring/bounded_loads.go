@@ -0,0 +1,44 @@
+package ring
+
+import (
+	"errors"
+	"math"
+)
+
+// errBoundedLoadsExceeded is returned when the instances with spare capacity can't form the replication set of a key.
+var errBoundedLoadsExceeded = errors.New("not enough instances with spare capacity to bound the loads")
+
+// BoundedLoads tracks the load of the instances while assigning a set of keys to them with consistent
+// hashing with bounded loads: each instance is assigned at most (1+epsilon) times the average number
+// of keys per instance, and the keys exceeding the capacity of their instances are assigned to the next
+// instances in the ring. This prevents hot-spotting when many keys hash to the same instances.
+//
+// BoundedLoads is not safe for concurrent use.
+type BoundedLoads struct {
+	capacity int
+	loads    map[string]int
+}
+
+// NewBoundedLoads makes a BoundedLoads for the given number of assignments, ie. the number of keys
+// multiplied by the replication factor, across the given number of instances.
+func NewBoundedLoads(assignments, instances int, epsilon float64) *BoundedLoads {
+	capacity := assignments
+	if instances > 0 {
+		capacity = int(math.Ceil((1 + epsilon) * float64(assignments) / float64(instances)))
+	}
+
+	return &BoundedLoads{
+		capacity: max(capacity, 1),
+		loads:    make(map[string]int, instances),
+	}
+}
+
+// HasCapacity returns true if the instance, identified by its address, can be assigned one more key.
+func (b *BoundedLoads) HasCapacity(addr string) bool {
+	return b.loads[addr] < b.capacity
+}
+
+// Add assigns one more key to the instance, identified by its address.
+func (b *BoundedLoads) Add(addr string) {
+	b.loads[addr]++
+}
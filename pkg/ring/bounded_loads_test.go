@@ -0,0 +1,150 @@
+package ring
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBoundedLoadsTestRing(numInstances, numZones, replicationFactor int, zoneAwarenessEnabled bool) *Ring {
+	g := NewMinimizeSpreadTokenGenerator()
+	desc := NewDesc()
+	for i := 0; i < numInstances; i++ {
+		name := fmt.Sprintf("instance-%d", i)
+		zone := fmt.Sprintf("zone-%d", i%numZones)
+		desc.AddIngester(name, fmt.Sprintf("127.0.0.%d", i), zone, g.GenerateTokens(desc, name, zone, 128, true), ACTIVE, time.Now())
+	}
+
+	return &Ring{
+		cfg: Config{
+			HeartbeatTimeout:     time.Hour,
+			ReplicationFactor:    replicationFactor,
+			ZoneAwarenessEnabled: zoneAwarenessEnabled,
+		},
+		ringDesc:            desc,
+		ringTokens:          desc.GetTokens(),
+		ringTokensByZone:    desc.getTokensByZone(),
+		ringInstanceByToken: desc.getTokensInfo(),
+		ringZones:           getZones(desc.getTokensByZone()),
+		strategy:            NewDefaultReplicationStrategy(),
+		KVClient:            &MockClient{},
+	}
+}
+
+func TestNewBoundedLoads(t *testing.T) {
+	assert.Equal(t, 25, NewBoundedLoads(100, 5, 0.25).capacity)
+	assert.Equal(t, 3, NewBoundedLoads(10, 4, 0.1).capacity)
+	assert.Equal(t, 1, NewBoundedLoads(0, 4, 0.25).capacity)
+	assert.Equal(t, 10, NewBoundedLoads(10, 0, 0.25).capacity)
+}
+
+func TestRing_GetWithBoundedLoads(t *testing.T) {
+	const (
+		numKeys = 100
+		epsilon = 0.25
+	)
+
+	tests := map[string]struct {
+		numInstances         int
+		numZones             int
+		replicationFactor    int
+		zoneAwarenessEnabled bool
+	}{
+		"zone-awareness disabled": {
+			numInstances:      10,
+			numZones:          1,
+			replicationFactor: 3,
+		},
+		"zone-awareness enabled": {
+			numInstances:         9,
+			numZones:             3,
+			replicationFactor:    3,
+			zoneAwarenessEnabled: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			r := newBoundedLoadsTestRing(testData.numInstances, testData.numZones, testData.replicationFactor, testData.zoneAwarenessEnabled)
+			loads := NewBoundedLoads(numKeys*testData.replicationFactor, testData.numInstances, epsilon)
+			bufDescs, bufHosts, bufZones := MakeBuffersForGet()
+
+			// All the keys are the same, so without bounded loads they would all be assigned to the same instances.
+			counts := map[string]int{}
+			for i := 0; i < numKeys; i++ {
+				set, err := r.GetWithBoundedLoads(12345, Write, loads, bufDescs, bufHosts, bufZones)
+				require.NoError(t, err)
+				require.Len(t, set.Instances, testData.replicationFactor)
+
+				zones := map[string]struct{}{}
+				for _, instance := range set.Instances {
+					counts[instance.Addr]++
+					zones[instance.Zone] = struct{}{}
+				}
+				if testData.zoneAwarenessEnabled {
+					assert.Len(t, zones, testData.numZones)
+				}
+			}
+
+			capacity := int(math.Ceil((1 + epsilon) * numKeys * float64(testData.replicationFactor) / float64(testData.numInstances)))
+			for addr, count := range counts {
+				assert.LessOrEqual(t, count, capacity, addr)
+			}
+			assert.Equal(t, counts, loads.loads)
+		})
+	}
+
+	t.Run("the first keys are assigned to the same instances than without bounded loads", func(t *testing.T) {
+		r := newBoundedLoadsTestRing(10, 1, 3, false)
+		loads := NewBoundedLoads(numKeys*3, 10, epsilon)
+
+		expected, err := r.Get(12345, Write, nil, nil, nil)
+		require.NoError(t, err)
+		actual, err := r.GetWithBoundedLoads(12345, Write, loads, nil, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	})
+}
+
+func TestDoBatchWithBoundedLoads(t *testing.T) {
+	const (
+		numKeys = 60
+		epsilon = 0.5
+	)
+
+	r := newBoundedLoadsTestRing(6, 1, 3, false)
+	keys := make([]uint32, numKeys)
+	for i := range keys {
+		keys[i] = 12345
+	}
+
+	var (
+		mtx     sync.Mutex
+		counts  = map[string]int{}
+		cleanup = make(chan struct{})
+	)
+	require.NoError(t, DoBatchWithBoundedLoads(context.Background(), Write, r, keys, epsilon, func(instance InstanceDesc, indexes []int) error {
+		mtx.Lock()
+		defer mtx.Unlock()
+		counts[instance.Addr] += len(indexes)
+		return nil
+	}, func() { close(cleanup) }))
+
+	// Wait until all the callbacks have been called.
+	<-cleanup
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	total := 0
+	for addr, count := range counts {
+		assert.LessOrEqual(t, count, 45, addr)
+		total += count
+	}
+	assert.Equal(t, numKeys*3, total)
+}
@@ -12,6 +12,8 @@ import (
 	"time"
 
 	"github.com/go-kit/log/level"
+
+	"github.com/cortexproject/cortex/pkg/util"
 )
 
 const pageContent = `
@@ -148,6 +150,19 @@ func (r *Ring) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	r.mtx.RLock()
 	defer r.mtx.RUnlock()
 
+	switch view := req.URL.Query().Get("view"); view {
+	case "":
+	case "ownership":
+		writeJSONResponse(w, r.ownership(time.Now()))
+		return
+	case "ownership-diff":
+		r.serveOwnershipDiff(w, req)
+		return
+	default:
+		http.Error(w, fmt.Sprintf("unknown view %q, supported views are ownership and ownership-diff", view), http.StatusBadRequest)
+		return
+	}
+
 	ingesterIDs := []string{}
 	for id := range r.ringDesc.Ingesters {
 		ingesterIDs = append(ingesterIDs, id)
@@ -210,6 +225,49 @@ func (r *Ring) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}, pageTemplate, req)
 }
 
+// serveOwnershipDiff responds with the ownership diff between the topologies of the ring at the "from" time and at
+// the "to" time, now by default. The ring read lock must be already taken when calling this function.
+func (r *Ring) serveOwnershipDiff(w http.ResponseWriter, req *http.Request) {
+	parseTime := func(param string, defaultValue time.Time) (time.Time, error) {
+		value := req.FormValue(param)
+		if value == "" {
+			if defaultValue.IsZero() {
+				return time.Time{}, fmt.Errorf("the %s parameter is required", param)
+			}
+			return defaultValue, nil
+		}
+		ms, err := util.ParseTime(value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s parameter: %w", param, err)
+		}
+		return util.TimeFromMillis(ms), nil
+	}
+
+	from, err := parseTime("from", time.Time{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseTime("to", time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fromSnapshot, ok := r.ownershipSnapshotAt(from)
+	if !ok {
+		http.Error(w, fmt.Sprintf("no ring topology recorded at or before %s", from), http.StatusBadRequest)
+		return
+	}
+	toSnapshot, ok := r.ownershipSnapshotAt(to)
+	if !ok {
+		http.Error(w, fmt.Sprintf("no ring topology recorded at or before %s", to), http.StatusBadRequest)
+		return
+	}
+
+	writeJSONResponse(w, diffOwnership(fromSnapshot, toSnapshot, r.cfg.ZoneAwarenessEnabled))
+}
+
 // RenderHTTPResponse either responds with json or a rendered html page using the passed in template
 // by checking the Accepts header
 func renderHTTPResponse(w http.ResponseWriter, v httpResponse, t *template.Template, r *http.Request) {
@@ -226,7 +284,7 @@ func renderHTTPResponse(w http.ResponseWriter, v httpResponse, t *template.Templ
 }
 
 // WriteJSONResponse writes some JSON as a HTTP response.
-func writeJSONResponse(w http.ResponseWriter, v httpResponse) {
+func writeJSONResponse(w http.ResponseWriter, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 
 	data, err := json.Marshal(v)
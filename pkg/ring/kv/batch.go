@@ -0,0 +1,178 @@
+package kv
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// maxCASBatchAttempts is the max number of batches a CAS operation whose callback returns an error asking to be
+// retried goes through, like the max number of retries of the CAS operations not batched.
+const maxCASBatchAttempts = 10
+
+// The casBatcher used by the clients created by NewClient() is a singleton, so that the CAS operations
+// issued by the lifecyclers of the different components started in the same process are batched.
+var processCASBatcher = newCASBatcher()
+
+// multiKeyCASClient is implemented by the stores able to CAS several keys in a single operation.
+type multiKeyCASClient interface {
+	Client
+
+	// MultiCAS is like CAS, but for several keys at once. The callback of each key is called with the
+	// current value of the key, and the keys whose callback returns a value are swapped.
+	MultiCAS(ctx context.Context, keys []string, fs []func(in interface{}) (out interface{}, retry bool, err error)) error
+}
+
+// casBatchID identifies the batches of CAS operations. Only the CAS operations of the clients with the same
+// store config, codec and batch period are batched together, so that the batch can be run by any of them.
+type casBatchID struct {
+	backend string
+	// store identifies the store of the backend, either by its full config or by its instance if it's
+	// shared by all the clients of the process.
+	store   interface{}
+	codecID string
+	period  time.Duration
+}
+
+// batchingClient batches the CAS operations issued within the batch period by all the batching clients
+// of the same store of the process, whatever their key, into a single multi-key CAS operation. The CAS
+// operations on the same key are coalesced.
+type batchingClient struct {
+	multiKeyCASClient
+
+	id      casBatchID
+	batcher *casBatcher
+}
+
+func newBatchingClient(client multiKeyCASClient, id casBatchID, batcher *casBatcher) *batchingClient {
+	return &batchingClient{
+		multiKeyCASClient: client,
+		id:                id,
+		batcher:           batcher,
+	}
+}
+
+// CAS implements Client. The callback is called with the value updated by the callbacks of the CAS
+// operations on the same key batched before it, and the callbacks returning an error don't prevent the
+// others to be applied. Like the CAS operations not batched, a callback returning an error with retry=false
+// is not called again, while a callback returning an error with retry=true is retried in the next batch.
+func (c *batchingClient) CAS(ctx context.Context, key string, f func(in interface{}) (out interface{}, retry bool, err error)) error {
+	return c.batcher.cas(ctx, c.multiKeyCASClient, c.id, key, f)
+}
+
+type casBatcher struct {
+	mtx     sync.Mutex
+	pending map[casBatchID][]*casOperation
+}
+
+type casOperation struct {
+	ctx context.Context
+	key string
+	f   func(in interface{}) (out interface{}, retry bool, err error)
+
+	// The result of the last call of the callback, and the number of batches the operation went through.
+	err      error
+	retry    bool
+	attempts int
+
+	done chan struct{}
+}
+
+func newCASBatcher() *casBatcher {
+	return &casBatcher{pending: map[casBatchID][]*casOperation{}}
+}
+
+func (b *casBatcher) cas(ctx context.Context, client multiKeyCASClient, id casBatchID, key string, f func(in interface{}) (out interface{}, retry bool, err error)) error {
+	op := &casOperation{ctx: ctx, key: key, f: f, done: make(chan struct{})}
+	b.enqueue(client, id, op)
+
+	select {
+	case <-op.done:
+		return op.err
+	case <-ctx.Done():
+		// The operation is skipped if the batch has not been flushed yet.
+		return ctx.Err()
+	}
+}
+
+func (b *casBatcher) enqueue(client multiKeyCASClient, id casBatchID, op *casOperation) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	ops, ok := b.pending[id]
+	b.pending[id] = append(ops, op)
+	if !ok {
+		// The first operation of the batch schedules it.
+		time.AfterFunc(id.period, func() {
+			b.flush(client, id)
+		})
+	}
+}
+
+func (b *casBatcher) flush(client multiKeyCASClient, id casBatchID) {
+	b.mtx.Lock()
+	ops := b.pending[id]
+	delete(b.pending, id)
+	b.mtx.Unlock()
+
+	// Group the operations by key, preserving their order.
+	var keys []string
+	opsByKey := map[string][]*casOperation{}
+	for _, op := range ops {
+		if _, ok := opsByKey[op.key]; !ok {
+			keys = append(keys, op.key)
+		}
+		opsByKey[op.key] = append(opsByKey[op.key], op)
+	}
+
+	fs := make([]func(in interface{}) (out interface{}, retry bool, err error), 0, len(keys))
+	for _, key := range keys {
+		fs = append(fs, coalesceCASOperations(opsByKey[key]))
+	}
+
+	// The batch is not bound to the context of any of its operations, the operations whose context is
+	// done are skipped instead.
+	err := client.MultiCAS(context.Background(), keys, fs)
+
+	for _, op := range ops {
+		op.attempts++
+		if err == nil && op.err != nil && op.retry && op.attempts < maxCASBatchAttempts {
+			b.enqueue(client, id, op)
+			continue
+		}
+
+		if op.err == nil {
+			op.err = err
+		}
+		close(op.done)
+	}
+}
+
+// coalesceCASOperations returns a CAS callback applying the callbacks of the operations on the same key
+// in order, each one to the value updated by the previous ones. The callback returns nil if none of the
+// operations wants to CAS anymore. The store may call it several times, if the keys changed in the
+// meanwhile: the callbacks which returned an error with retry=false are not called again.
+func coalesceCASOperations(ops []*casOperation) func(in interface{}) (out interface{}, retry bool, err error) {
+	return func(in interface{}) (out interface{}, retry bool, err error) {
+		for _, op := range ops {
+			if op.err != nil && !op.retry {
+				continue
+			}
+			if op.err = op.ctx.Err(); op.err != nil {
+				op.retry = false
+				continue
+			}
+
+			var opOut interface{}
+			opOut, op.retry, op.err = op.f(in)
+			if op.err != nil {
+				continue
+			}
+			if opOut != nil {
+				in = opOut
+				out = opOut
+			}
+		}
+		return out, false, nil
+	}
+}
@@ -0,0 +1,270 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
+	"github.com/cortexproject/cortex/pkg/ring/kv/consul"
+	"github.com/cortexproject/cortex/pkg/ring/kv/etcd"
+)
+
+// multiCASCountingClient counts the multi-key CAS operations of the wrapped client.
+type multiCASCountingClient struct {
+	*etcd.Client
+	multiCAS atomic.Int32
+}
+
+func (c *multiCASCountingClient) MultiCAS(ctx context.Context, keys []string, fs []func(in interface{}) (out interface{}, retry bool, err error)) error {
+	c.multiCAS.Inc()
+	return c.Client.MultiCAS(ctx, keys, fs)
+}
+
+func TestBatchingClient_CAS(t *testing.T) {
+	ctx := context.Background()
+	store, closer := etcd.NewInMemoryClient(codec.String{}, log.NewNopLogger())
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+	counting := &multiCASCountingClient{Client: store}
+
+	// Two clients sharing the same store and batcher, like the lifecyclers of the same process.
+	batcher := newCASBatcher()
+	id := casBatchID{backend: "etcd", store: "config", codecID: "string", period: 100 * time.Millisecond}
+	clients := []Client{
+		newBatchingClient(counting, id, batcher),
+		newBatchingClient(counting, id, batcher),
+	}
+
+	errFailed := errors.New("failed")
+	type operation struct {
+		key string
+		f   func(in interface{}) (interface{}, bool, error)
+	}
+	operations := []operation{
+		{key: "ring", f: func(in interface{}) (interface{}, bool, error) {
+			if in == nil {
+				return "a", true, nil
+			}
+			return in.(string) + "a", true, nil
+		}},
+		{key: "ring", f: func(in interface{}) (interface{}, bool, error) {
+			return nil, false, errFailed
+		}},
+		{key: "distributor", f: func(in interface{}) (interface{}, bool, error) {
+			return "c", true, nil
+		}},
+		{key: "ring", f: func(in interface{}) (interface{}, bool, error) {
+			return in.(string) + "b", true, nil
+		}},
+		{key: "ring", f: func(in interface{}) (interface{}, bool, error) {
+			return nil, true, nil
+		}},
+		{key: "compactor", f: func(in interface{}) (interface{}, bool, error) {
+			return nil, true, nil
+		}},
+	}
+
+	errs := make([]error, len(operations))
+	wg := sync.WaitGroup{}
+	for i, op := range operations {
+		wg.Add(1)
+		go func(i int, op operation) {
+			defer wg.Done()
+			errs[i] = clients[i%len(clients)].CAS(ctx, op.key, op.f)
+		}(i, op)
+
+		// Preserve the order of the operations in the batch.
+		time.Sleep(10 * time.Millisecond)
+	}
+	wg.Wait()
+
+	// The operations on all the keys are batched together.
+	assert.Equal(t, []error{nil, errFailed, nil, nil, nil, nil}, errs)
+	assert.Equal(t, int32(1), counting.multiCAS.Load())
+
+	for key, expected := range map[string]interface{}{"ring": "ab", "distributor": "c", "compactor": nil} {
+		out, err := store.Get(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, expected, out, key)
+	}
+}
+
+func TestBatchingClient_CAS_ShouldSkipTheOperationsWithDoneContext(t *testing.T) {
+	store, closer := etcd.NewInMemoryClient(codec.String{}, log.NewNopLogger())
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+	c := newBatchingClient(store, casBatchID{backend: "etcd", period: 100 * time.Millisecond}, newCASBatcher())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	called := atomic.NewBool(false)
+	err := c.CAS(ctx, "key", func(interface{}) (interface{}, bool, error) {
+		called.Store(true)
+		return "value", true, nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Wait until the batch is flushed.
+	time.Sleep(200 * time.Millisecond)
+	assert.False(t, called.Load())
+
+	out, err := store.Get(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Nil(t, out)
+}
+
+// retryingClient calls the callbacks once before the multi-key CAS operation, like if the keys changed
+// concurrently and the operation was retried.
+type retryingClient struct {
+	*multiCASCountingClient
+}
+
+func (c *retryingClient) MultiCAS(ctx context.Context, keys []string, fs []func(in interface{}) (out interface{}, retry bool, err error)) error {
+	for _, f := range fs {
+		_, _, _ = f(nil)
+	}
+	return c.multiCASCountingClient.MultiCAS(ctx, keys, fs)
+}
+
+func TestBatchingClient_CAS_ShouldPreserveTheRetrySemantics(t *testing.T) {
+	ctx := context.Background()
+	store, closer := etcd.NewInMemoryClient(codec.String{}, log.NewNopLogger())
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+	counting := &multiCASCountingClient{Client: store}
+	c := newBatchingClient(&retryingClient{counting}, casBatchID{backend: "etcd", period: 50 * time.Millisecond}, newCASBatcher())
+
+	var (
+		errFailed  = errors.New("failed")
+		errRetried = errors.New("retried")
+		failed     = atomic.NewInt32(0)
+		retried    = atomic.NewInt32(0)
+		errs       = make([]error, 2)
+		wg         = sync.WaitGroup{}
+	)
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		errs[0] = c.CAS(ctx, "ring", func(in interface{}) (interface{}, bool, error) {
+			failed.Inc()
+			return nil, false, errFailed
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		errs[1] = c.CAS(ctx, "ring", func(in interface{}) (interface{}, bool, error) {
+			if retried.Inc() <= 2 {
+				return nil, true, errRetried
+			}
+			return "b", true, nil
+		})
+	}()
+	wg.Wait()
+
+	// The callback not asking to be retried is called once, while the other one is retried in the next batch.
+	assert.Equal(t, []error{errFailed, nil}, errs)
+	assert.Equal(t, int32(1), failed.Load())
+	assert.Equal(t, int32(4), retried.Load())
+	assert.Equal(t, int32(2), counting.multiCAS.Load())
+
+	out, err := store.Get(ctx, "ring")
+	require.NoError(t, err)
+	assert.Equal(t, "b", out)
+}
+
+func TestBatchingClient_CAS_ShouldNotBatchTheClientsOfDifferentBatches(t *testing.T) {
+	ctx := context.Background()
+	store, closer := etcd.NewInMemoryClient(codec.String{}, log.NewNopLogger())
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+	counting := &multiCASCountingClient{Client: store}
+
+	batcher := newCASBatcher()
+	clients := []Client{
+		newBatchingClient(counting, casBatchID{backend: "etcd", period: 50 * time.Millisecond}, batcher),
+		newBatchingClient(counting, casBatchID{backend: "etcd", period: 100 * time.Millisecond}, batcher),
+	}
+
+	wg := sync.WaitGroup{}
+	for i, c := range clients {
+		wg.Add(1)
+		go func(key string, c Client) {
+			defer wg.Done()
+			assert.NoError(t, c.CAS(ctx, key, func(interface{}) (interface{}, bool, error) {
+				return key, true, nil
+			}))
+		}(fmt.Sprintf("key-%d", i), c)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(2), counting.multiCAS.Load())
+}
+
+func TestCreateClient_ShouldBatchTheClientsWithTheSameStoreConfig(t *testing.T) {
+	cfg := StoreConfig{CASBatchPeriod: time.Second, Consul: consul.Config{Host: "localhost:8500"}}
+
+	batchID := func(cfg StoreConfig) casBatchID {
+		client, err := createClient("consul", "", cfg, codec.String{}, Primary, nil, log.NewNopLogger())
+		require.NoError(t, err)
+		batching, ok := client.(*batchingClient)
+		require.True(t, ok)
+		return batching.id
+	}
+
+	first := batchID(cfg)
+	assert.Equal(t, first, batchID(cfg))
+
+	cfg.Consul.Host = "consul:8500"
+	assert.NotEqual(t, first, batchID(cfg))
+}
+
+func TestCreateClient_ShouldBatchOnlyTheStoresSupportingMultiKeyCAS(t *testing.T) {
+	cfg := StoreConfig{CASBatchPeriod: time.Second}
+
+	// The CAS operations of the stores not supporting the multi-key CAS are not delayed.
+	client, err := createClient("mock", "", cfg, codec.String{}, Primary, nil, log.NewNopLogger())
+	require.NoError(t, err)
+	_, ok := client.(*batchingClient)
+	assert.False(t, ok)
+}
+
+func TestEtcdClient_MultiCAS_ShouldRetryIfAnyKeyChanged(t *testing.T) {
+	ctx := context.Background()
+	store, closer := etcd.NewInMemoryClient(codec.String{}, log.NewNopLogger())
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	calls := 0
+	err := store.MultiCAS(ctx, []string{"a", "b"}, []func(in interface{}) (interface{}, bool, error){
+		func(in interface{}) (interface{}, bool, error) {
+			calls++
+			if calls == 1 {
+				// Change the other key concurrently, once.
+				require.NoError(t, store.CAS(ctx, "b", func(interface{}) (interface{}, bool, error) {
+					return "concurrent", true, nil
+				}))
+			}
+			return "a", true, nil
+		},
+		func(in interface{}) (interface{}, bool, error) {
+			if in == nil {
+				return "b", true, nil
+			}
+			return in.(string) + "-b", true, nil
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	for key, expected := range map[string]string{"a": "a", "b": "concurrent-b"} {
+		out, err := store.Get(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, expected, out)
+	}
+}
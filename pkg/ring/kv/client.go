@@ -15,6 +15,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/ring/kv/dynamodb"
 	"github.com/cortexproject/cortex/pkg/ring/kv/etcd"
 	"github.com/cortexproject/cortex/pkg/ring/kv/memberlist"
+	"github.com/cortexproject/cortex/pkg/ring/kv/s3"
 )
 
 const (
@@ -46,6 +47,10 @@ type StoreConfig struct {
 	Consul   consul.Config   `yaml:"consul"`
 	Etcd     etcd.Config     `yaml:"etcd"`
 	Multi    MultiConfig     `yaml:"multi"`
+	S3       s3.Config       `yaml:"s3"`
+
+	// Set by the ring lifecyclers from the config of their ring, since only their CAS operations are batched.
+	CASBatchPeriod time.Duration `yaml:"-"`
 
 	// Function that returns memberlist.KV store to use. By using a function, we can delay
 	// initialization of memberlist.KV until it is actually required.
@@ -76,12 +81,13 @@ func (cfg *Config) RegisterFlagsWithPrefix(flagsPrefix, defaultPrefix string, f
 	cfg.Consul.RegisterFlags(f, flagsPrefix)
 	cfg.Etcd.RegisterFlagsWithPrefix(f, flagsPrefix)
 	cfg.Multi.RegisterFlagsWithPrefix(f, flagsPrefix)
+	cfg.S3.RegisterFlags(f, flagsPrefix)
 
 	if flagsPrefix == "" {
 		flagsPrefix = "ring."
 	}
 	f.StringVar(&cfg.Prefix, flagsPrefix+"prefix", defaultPrefix, "The prefix for the keys in the store. Should end with a /.")
-	f.StringVar(&cfg.Store, flagsPrefix+"store", "consul", "Backend storage to use for the ring. Supported values are: consul, etcd, inmemory, memberlist, multi.")
+	f.StringVar(&cfg.Store, flagsPrefix+"store", "consul", "Backend storage to use for the ring. Supported values are: consul, etcd, inmemory, memberlist, multi, s3.")
 }
 
 // Client is a high-level client for key-value stores (such as Etcd and
@@ -134,15 +140,23 @@ func createClient(backend string, prefix string, cfg StoreConfig, codec codec.Co
 	var client Client
 	var err error
 
+	// The store of the backend the CAS operations of the client are batched by.
+	var casBatchStore interface{}
+
 	switch backend {
 	case "dynamodb":
 		client, err = dynamodb.NewClient(cfg.DynamoDB, codec, logger, reg)
 
 	case "consul":
 		client, err = consul.NewClient(cfg.Consul, codec, logger, reg)
+		casBatchStore = fmt.Sprintf("%#v", cfg.Consul)
 
 	case "etcd":
 		client, err = etcd.New(cfg.Etcd, codec, logger)
+		casBatchStore = fmt.Sprintf("%#v", cfg.Etcd)
+
+	case "s3":
+		client, err = s3.NewClient(cfg.S3, codec, logger)
 
 	case "inmemory":
 		// If we use the in-memory store, make sure everyone gets the same instance
@@ -151,6 +165,7 @@ func createClient(backend string, prefix string, cfg StoreConfig, codec codec.Co
 			inmemoryStore, _ = consul.NewInMemoryClient(codec, logger, reg)
 		})
 		client = inmemoryStore
+		casBatchStore = inmemoryStore
 
 	case "memberlist":
 		kv, err := cfg.MemberlistKV()
@@ -161,6 +176,7 @@ func createClient(backend string, prefix string, cfg StoreConfig, codec codec.Co
 		if err != nil {
 			return nil, err
 		}
+		casBatchStore = kv
 
 	case "multi":
 		client, err = buildMultiClient(cfg, codec, reg, logger)
@@ -177,6 +193,13 @@ func createClient(backend string, prefix string, cfg StoreConfig, codec codec.Co
 		return nil, err
 	}
 
+	// Only the stores able to CAS several keys in a single operation are batched. The multi client is
+	// not batched, the CAS operations of its primary and secondary stores are.
+	if mc, ok := client.(multiKeyCASClient); ok && cfg.CASBatchPeriod > 0 {
+		id := casBatchID{backend: backend, store: casBatchStore, codecID: codec.CodecID(), period: cfg.CASBatchPeriod}
+		client = newBatchingClient(mc, id, processCASBatcher)
+	}
+
 	if prefix != "" {
 		client = PrefixClient(client, prefix)
 	}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"math/rand"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-kit/log"
@@ -58,6 +59,7 @@ type kv interface {
 	List(path string, q *consul.QueryOptions) (consul.KVPairs, *consul.QueryMeta, error)
 	Delete(key string, q *consul.WriteOptions) (*consul.WriteMeta, error)
 	Put(p *consul.KVPair, q *consul.WriteOptions) (*consul.WriteMeta, error)
+	Txn(txn consul.KVTxnOps, q *consul.QueryOptions) (bool, *consul.KVTxnResponse, *consul.QueryMeta, error)
 }
 
 // Client is a kv.Client for Consul.
@@ -204,6 +206,108 @@ func (c *Client) cas(ctx context.Context, key string, f func(in interface{}) (ou
 	return fmt.Errorf("failed to CAS %s", key)
 }
 
+// MultiCAS is like CAS, but for several keys at once: the keys are read in a single transaction, and the
+// keys whose callback returns a value are written in a single transaction, which fails and is retried if
+// any of the keys changed in the meanwhile.
+func (c *Client) MultiCAS(ctx context.Context, keys []string, fs []func(in interface{}) (out interface{}, retry bool, err error)) error {
+	return instrument.CollectedRequest(ctx, "MultiCAS loop", c.consulMetrics.consulRequestDuration, instrument.ErrorCode, func(ctx context.Context) error {
+		return c.multiCAS(ctx, keys, fs)
+	})
+}
+
+func (c *Client) multiCAS(ctx context.Context, keys []string, fs []func(in interface{}) (out interface{}, retry bool, err error)) error {
+	retries := c.cfg.MaxCasRetries
+	if retries == 0 {
+		retries = 10
+	}
+
+	sleepBeforeRetry := time.Duration(0)
+	if c.cfg.CasRetryDelay > 0 {
+		sleepBeforeRetry = time.Duration(rand.Int63n(c.cfg.CasRetryDelay.Nanoseconds()))
+	}
+
+	gets := make(consul.KVTxnOps, 0, len(keys))
+	for _, key := range keys {
+		gets = append(gets, &consul.KVTxnOp{Verb: consul.KVGetOrEmpty, Key: key})
+	}
+
+	for i := 0; i < retries; i++ {
+		if i > 0 && sleepBeforeRetry > 0 {
+			time.Sleep(sleepBeforeRetry)
+		}
+
+		// Get with default options - don't want stale data to compare with
+		options := &consul.QueryOptions{}
+		ok, resp, _, err := c.kv.Txn(gets, options.WithContext(ctx))
+		if err != nil || !ok || len(resp.Results) != len(keys) {
+			level.Error(c.logger).Log("msg", "error getting keys", "keys", strings.Join(keys, ","), "err", err)
+			continue
+		}
+
+		var (
+			cas       = make(consul.KVTxnOps, 0, len(keys))
+			retryKeys bool
+		)
+		for j, key := range keys {
+			var intermediate interface{}
+			// If key doesn't exist, it's returned empty and index will be 0.
+			index := uint64(0)
+			if kvp := resp.Results[j]; kvp != nil && kvp.ModifyIndex > 0 {
+				out, err := c.codec.Decode(kvp.Value)
+				if err != nil {
+					level.Error(c.logger).Log("msg", "error decoding key", "key", key, "err", err)
+					retryKeys = true
+					break
+				}
+				index = kvp.ModifyIndex
+				intermediate = out
+			}
+
+			intermediate, retry, err := fs[j](intermediate)
+			if err != nil {
+				if !retry {
+					return err
+				}
+				retryKeys = true
+				break
+			}
+
+			// Treat the callback returning nil for intermediate as a decision to
+			// not actually write the key to Consul, but this is not an error.
+			if intermediate == nil {
+				continue
+			}
+
+			bytes, err := c.codec.Encode(intermediate)
+			if err != nil {
+				level.Error(c.logger).Log("msg", "error serialising value", "key", key, "err", err)
+				retryKeys = true
+				break
+			}
+			cas = append(cas, &consul.KVTxnOp{Verb: consul.KVCAS, Key: key, Value: bytes, Index: index})
+		}
+		if retryKeys {
+			continue
+		}
+		if len(cas) == 0 {
+			return nil
+		}
+
+		// The transaction is rolled back if any of the keys changed in the meanwhile.
+		ok, _, _, err = c.kv.Txn(cas, options.WithContext(ctx))
+		if err != nil {
+			level.Error(c.logger).Log("msg", "error CASing", "keys", strings.Join(keys, ","), "err", err)
+			continue
+		}
+		if !ok {
+			level.Debug(c.logger).Log("msg", "error CASing, trying again", "keys", strings.Join(keys, ","))
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("failed to CAS %s", strings.Join(keys, ","))
+}
+
 // WatchKey will watch a given key in consul for changes. When the value
 // under said key changes, the f callback is called with the deserialised
 // value. To construct the deserialised value, a factory function should be
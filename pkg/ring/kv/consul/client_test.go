@@ -148,6 +148,42 @@ func observeValueForSomeTime(t *testing.T, client *Client, key string, timeout t
 	return observed
 }
 
+func TestMultiCAS_ShouldRetryIfAnyKeyChanged(t *testing.T) {
+	ctx := context.Background()
+	c, closer := NewInMemoryClient(codec.String{}, testLogger{}, nil)
+	t.Cleanup(func() {
+		assert.NoError(t, closer.Close())
+	})
+
+	calls := 0
+	err := c.MultiCAS(ctx, []string{"a", "b"}, []func(in interface{}) (interface{}, bool, error){
+		func(in interface{}) (interface{}, bool, error) {
+			calls++
+			if calls == 1 {
+				// Change the other key concurrently, once.
+				require.NoError(t, c.CAS(ctx, "b", func(interface{}) (interface{}, bool, error) {
+					return "concurrent", true, nil
+				}))
+			}
+			return "a", true, nil
+		},
+		func(in interface{}) (interface{}, bool, error) {
+			if in == nil {
+				return "b", true, nil
+			}
+			return in.(string) + "-b", true, nil
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	for key, expected := range map[string]string{"a": "a", "b": "concurrent-b"} {
+		out, err := c.Get(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, expected, out)
+	}
+}
+
 func TestWatchKeyWithNoStartValue(t *testing.T) {
 	c, closer := NewInMemoryClient(codec.String{}, testLogger{}, prometheus.NewPedanticRegistry())
 	t.Cleanup(func() {
@@ -85,3 +85,16 @@ func (c consulInstrumentation) Put(p *consul.KVPair, options *consul.WriteOption
 	})
 	return result, err
 }
+
+func (c consulInstrumentation) Txn(txn consul.KVTxnOps, options *consul.QueryOptions) (bool, *consul.KVTxnResponse, *consul.QueryMeta, error) {
+	var ok bool
+	var resp *consul.KVTxnResponse
+	var meta *consul.QueryMeta
+	err := instrument.CollectedRequest(options.Context(), "Txn", c.consulMetrics.consulRequestDuration, instrument.ErrorCode, func(ctx context.Context) error {
+		options = options.WithContext(ctx)
+		var err error
+		ok, resp, meta, err = c.kv.Txn(txn, options)
+		return err
+	})
+	return ok, resp, meta, err
+}
@@ -233,6 +233,54 @@ func (m *mockKV) Delete(key string, q *consul.WriteOptions) (*consul.WriteMeta,
 	return nil, nil
 }
 
+func (m *mockKV) Txn(txn consul.KVTxnOps, q *consul.QueryOptions) (bool, *consul.KVTxnResponse, *consul.QueryMeta, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	// Check all the operations first, so that the transaction is applied atomically.
+	for idx, op := range txn {
+		switch op.Verb {
+		case consul.KVGetOrEmpty:
+		case consul.KVCAS:
+			if existing, ok := m.kvps[op.Key]; ok && existing.ModifyIndex != op.Index {
+				level.Debug(m.logger).Log("msg", "Txn - CAS failed", "key", op.Key, "modify_index", op.Index)
+				return false, &consul.KVTxnResponse{Errors: consul.TxnErrors{{OpIndex: idx, What: "index mismatch"}}}, nil, nil
+			}
+		default:
+			return false, nil, nil, fmt.Errorf("unsupported transaction operation: %s", op.Verb)
+		}
+	}
+
+	resp := &consul.KVTxnResponse{}
+	for _, op := range txn {
+		switch op.Verb {
+		case consul.KVGetOrEmpty:
+			if value, ok := m.kvps[op.Key]; ok {
+				resp.Results = append(resp.Results, copyKVPair(value))
+			} else {
+				resp.Results = append(resp.Results, &consul.KVPair{Key: op.Key})
+			}
+		case consul.KVCAS:
+			m.current++
+			if existing, ok := m.kvps[op.Key]; ok {
+				existing.Value = op.Value
+				existing.ModifyIndex = m.current
+			} else {
+				m.kvps[op.Key] = &consul.KVPair{
+					Key:         op.Key,
+					Value:       op.Value,
+					CreateIndex: m.current,
+					ModifyIndex: m.current,
+				}
+			}
+			resp.Results = append(resp.Results, copyKVPair(m.kvps[op.Key]))
+			m.cond.Broadcast()
+		}
+	}
+
+	return true, resp, &consul.QueryMeta{LastIndex: m.current}, nil
+}
+
 func (m *mockKV) LastUpdateTime(_ string) time.Time {
 	return time.Now().UTC()
 }
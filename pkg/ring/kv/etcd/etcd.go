@@ -5,6 +5,8 @@ import (
 	"crypto/tls"
 	"flag"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-kit/log"
@@ -29,6 +31,9 @@ type Config struct {
 
 	UserName string `yaml:"username"`
 	Password string `yaml:"password"`
+
+	LeaseHeartbeatsEnabled bool          `yaml:"lease_heartbeats_enabled"`
+	LeaseHeartbeatsTTL     time.Duration `yaml:"lease_heartbeats_ttl"`
 }
 
 // Clientv3Facade is a subset of all Etcd client operations that are required
@@ -36,6 +41,7 @@ type Config struct {
 type Clientv3Facade interface {
 	clientv3.KV
 	clientv3.Watcher
+	clientv3.Lease
 }
 
 // Client implements kv.Client for etcd.
@@ -44,6 +50,10 @@ type Client struct {
 	codec  codec.Codec
 	cli    Clientv3Facade
 	logger log.Logger
+
+	// Leases of the heartbeats kept alive by this client, by heartbeat key.
+	leasesMtx sync.Mutex
+	leases    map[string]clientv3.LeaseID
 }
 
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
@@ -59,6 +69,8 @@ func (cfg *Config) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string) {
 	f.BoolVar(&cfg.EnableTLS, prefix+"etcd.tls-enabled", false, "Enable TLS.")
 	f.StringVar(&cfg.UserName, prefix+"etcd.username", "", "Etcd username.")
 	f.StringVar(&cfg.Password, prefix+"etcd.password", "", "Etcd password.")
+	f.BoolVar(&cfg.LeaseHeartbeatsEnabled, prefix+"etcd.lease-heartbeats-enabled", false, "EXPERIMENTAL: True to keep the ring heartbeats alive with etcd leases instead of writing the whole ring at every heartbeat. The ring is only written when the instances change. All the clients of the ring must enable it.")
+	f.DurationVar(&cfg.LeaseHeartbeatsTTL, prefix+"etcd.lease-heartbeats-ttl", time.Minute, "EXPERIMENTAL: TTL of the leases of the ring heartbeats, after which an instance that stopped heartbeating is considered unhealthy. Should be equal to the ring heartbeat timeout.")
 	cfg.TLS.RegisterFlagsWithPrefix(prefix+"etcd", f)
 }
 
@@ -79,6 +91,10 @@ func (cfg *Config) GetTLS() (*tls.Config, error) {
 
 // New makes a new Client.
 func New(cfg Config, codec codec.Codec, logger log.Logger) (*Client, error) {
+	if cfg.LeaseHeartbeatsEnabled && cfg.LeaseHeartbeatsTTL < time.Second {
+		return nil, errors.New("the etcd lease heartbeats TTL must be at least 1s")
+	}
+
 	tlsConfig, err := cfg.GetTLS()
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to initialise TLS configuration for etcd")
@@ -116,6 +132,7 @@ func New(cfg Config, codec codec.Codec, logger log.Logger) (*Client, error) {
 		codec:  codec,
 		cli:    cli,
 		logger: logger,
+		leases: map[string]clientv3.LeaseID{},
 	}, nil
 }
 
@@ -136,8 +153,10 @@ func (c *Client) CAS(ctx context.Context, key string, f func(in interface{}) (ou
 		}
 
 		var intermediate interface{}
+		var stored []byte
 		if len(resp.Kvs) > 0 {
-			intermediate, err = c.codec.Decode(resp.Kvs[0].Value)
+			stored = resp.Kvs[0].Value
+			intermediate, err = c.codec.Decode(stored)
 			if err != nil {
 				level.Error(c.logger).Log("msg", "error decoding key", "key", key, "err", err)
 				lastErr = err
@@ -146,6 +165,16 @@ func (c *Client) CAS(ctx context.Context, key string, f func(in interface{}) (ou
 			revision = resp.Kvs[0].Version
 		}
 
+		var before map[string]int64
+		if hb, ok := intermediate.(Heartbeats); ok && c.cfg.LeaseHeartbeatsEnabled {
+			if err := c.applyLeaseHeartbeats(opsCtx, key, hb); err != nil {
+				level.Error(c.logger).Log("msg", "error getting heartbeats", "key", key, "err", err)
+				lastErr = err
+				continue
+			}
+			before = hb.Heartbeats()
+		}
+
 		var retry bool
 		intermediate, retry, err = f(intermediate)
 		if err != nil {
@@ -161,6 +190,18 @@ func (c *Client) CAS(ctx context.Context, key string, f func(in interface{}) (ou
 			return nil
 		}
 
+		if hb, ok := intermediate.(Heartbeats); ok && c.cfg.LeaseHeartbeatsEnabled {
+			if err := c.keepAliveHeartbeats(opsCtx, key, before, hb); err != nil {
+				level.Error(c.logger).Log("msg", "error keeping heartbeats alive", "key", key, "err", err)
+				lastErr = err
+				continue
+			}
+			// The heartbeats are kept alive by the leases, so the value is only written if something else changed.
+			if c.onlyHeartbeatsChanged(stored, hb) {
+				return nil
+			}
+		}
+
 		buf, err := c.codec.Encode(intermediate)
 		if err != nil {
 			level.Error(c.logger).Log("msg", "error serialising value", "key", key, "err", err)
@@ -183,6 +224,9 @@ func (c *Client) CAS(ctx context.Context, key string, f func(in interface{}) (ou
 			continue
 		}
 
+		if hb, ok := intermediate.(Heartbeats); ok && c.cfg.LeaseHeartbeatsEnabled {
+			c.revokeHeartbeats(opsCtx, key, hb)
+		}
 		return nil
 	}
 
@@ -192,8 +236,117 @@ func (c *Client) CAS(ctx context.Context, key string, f func(in interface{}) (ou
 	return fmt.Errorf("failed to CAS %s", key)
 }
 
+// MultiCAS is like CAS, but for several keys at once: the keys are read in a single transaction, and the
+// keys whose callback returns a value are written in a single transaction, which fails and is retried if any
+// of the keys changed in the meanwhile. If the lease heartbeats are enabled, the keys are CASed one by one
+// instead, given their heartbeats don't write them.
+func (c *Client) MultiCAS(ctx context.Context, keys []string, fs []func(in interface{}) (out interface{}, retry bool, err error)) error {
+	if c.cfg.LeaseHeartbeatsEnabled {
+		for i, key := range keys {
+			if err := c.CAS(ctx, key, fs[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var lastErr error
+
+	opsCtx, cancel := c.opsContext(ctx)
+	defer cancel()
+
+	gets := make([]clientv3.Op, 0, len(keys))
+	for _, key := range keys {
+		gets = append(gets, clientv3.OpGet(key))
+	}
+
+	for i := 0; i < c.cfg.MaxRetries; i++ {
+		resp, err := c.cli.Txn(opsCtx).Then(gets...).Commit()
+		if err != nil {
+			level.Error(c.logger).Log("msg", "error getting keys", "keys", strings.Join(keys, ","), "err", err)
+			lastErr = err
+			continue
+		}
+
+		var (
+			cmps      = make([]clientv3.Cmp, 0, len(keys))
+			puts      = make([]clientv3.Op, 0, len(keys))
+			retryKeys bool
+		)
+		for j, key := range keys {
+			var intermediate interface{}
+			var revision int64
+			if kvs := resp.Responses[j].GetResponseRange().GetKvs(); len(kvs) > 0 {
+				intermediate, err = c.codec.Decode(kvs[0].Value)
+				if err != nil {
+					level.Error(c.logger).Log("msg", "error decoding key", "key", key, "err", err)
+					lastErr = err
+					retryKeys = true
+					break
+				}
+				revision = kvs[0].Version
+			}
+
+			var retry bool
+			intermediate, retry, err = fs[j](intermediate)
+			if err != nil {
+				if !retry {
+					return err
+				}
+				lastErr = err
+				retryKeys = true
+				break
+			}
+
+			// Callback returning nil means it doesn't want to CAS the key anymore.
+			if intermediate == nil {
+				continue
+			}
+
+			buf, err := c.codec.Encode(intermediate)
+			if err != nil {
+				level.Error(c.logger).Log("msg", "error serialising value", "key", key, "err", err)
+				lastErr = err
+				retryKeys = true
+				break
+			}
+			cmps = append(cmps, clientv3.Compare(clientv3.Version(key), "=", revision))
+			puts = append(puts, clientv3.OpPut(key, string(buf)))
+		}
+		if retryKeys {
+			continue
+		}
+		if len(puts) == 0 {
+			return nil
+		}
+
+		result, err := c.cli.Txn(opsCtx).If(cmps...).Then(puts...).Commit()
+		if err != nil {
+			level.Error(c.logger).Log("msg", "error CASing", "keys", strings.Join(keys, ","), "err", err)
+			lastErr = err
+			continue
+		}
+		// result is not Succeeded if any of the comparisons was false, meaning if any of the keys changed.
+		if !result.Succeeded {
+			level.Debug(c.logger).Log("msg", "failed to CAS, version of some keys did not match in etcd", "keys", strings.Join(keys, ","))
+			continue
+		}
+		return nil
+	}
+
+	if lastErr != nil {
+		return lastErr
+	}
+	return fmt.Errorf("failed to CAS %s", strings.Join(keys, ","))
+}
+
 // WatchKey implements kv.Client.
 func (c *Client) WatchKey(ctx context.Context, key string, f func(interface{}) bool) {
+	if c.cfg.LeaseHeartbeatsEnabled {
+		c.watchKeyWithHeartbeats(ctx, key, f)
+		return
+	}
+
 	backoff := backoff.New(ctx, backoff.Config{
 		MinBackoff: 1 * time.Second,
 		MaxBackoff: 1 * time.Minute,
@@ -309,7 +462,17 @@ func (c *Client) Get(ctx context.Context, key string) (interface{}, error) {
 	} else if len(resp.Kvs) != 1 {
 		return nil, fmt.Errorf("got %d kvs, expected 1 or 0", len(resp.Kvs))
 	}
-	return c.codec.Decode(resp.Kvs[0].Value)
+
+	out, err := c.codec.Decode(resp.Kvs[0].Value)
+	if err != nil {
+		return nil, err
+	}
+	if hb, ok := out.(Heartbeats); ok && c.cfg.LeaseHeartbeatsEnabled {
+		if err := c.applyLeaseHeartbeats(opsCtx, key, hb); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
 }
 
 // Delete implements kv.Client.
@@ -0,0 +1,280 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/cortexproject/cortex/pkg/util/backoff"
+)
+
+// Heartbeats is implemented by the values whose members periodically heartbeat, like the ring.
+//
+// When the lease heartbeats are enabled, the heartbeat of each member is kept alive with an etcd lease
+// attached to a key of the member, instead of writing the whole value at every heartbeat. The value is
+// only written when something else than the heartbeats changes. When reading the value, the members
+// whose lease is alive are given a recent heartbeat.
+type Heartbeats interface {
+	// Heartbeats returns the heartbeat timestamp, in seconds, of each member.
+	Heartbeats() map[string]int64
+
+	// SetHeartbeats sets the heartbeat timestamp, in seconds, of the given members.
+	SetHeartbeats(heartbeats map[string]int64)
+
+	// Equal returns true if the value is equal to the given one.
+	Equal(that interface{}) bool
+}
+
+// heartbeatsPrefix returns the prefix of the keys holding the heartbeat leases of the members of key.
+func heartbeatsPrefix(key string) string {
+	return key + "/heartbeats/"
+}
+
+// applyLeaseHeartbeats sets the heartbeats of the members of the value whose lease is alive.
+func (c *Client) applyLeaseHeartbeats(ctx context.Context, key string, hb Heartbeats) error {
+	alive, err := c.aliveMembers(ctx, key)
+	if err != nil {
+		return err
+	}
+	setAliveHeartbeats(hb, alive)
+	return nil
+}
+
+// aliveMembers returns the members of key whose heartbeat lease is alive.
+func (c *Client) aliveMembers(ctx context.Context, key string) (map[string]struct{}, error) {
+	prefix := heartbeatsPrefix(key)
+	resp, err := c.cli.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, err
+	}
+
+	alive := make(map[string]struct{}, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		alive[strings.TrimPrefix(string(kv.Key), prefix)] = struct{}{}
+	}
+	return alive, nil
+}
+
+// setAliveHeartbeats sets the heartbeat of the alive members to one second ago, so that a heartbeat
+// set to the current time by a CAS callback is always detected as a new heartbeat.
+func setAliveHeartbeats(hb Heartbeats, alive map[string]struct{}) {
+	timestamp := time.Now().Add(-time.Second).Unix()
+	heartbeats := make(map[string]int64, len(alive))
+	for member := range alive {
+		heartbeats[member] = timestamp
+	}
+	hb.SetHeartbeats(heartbeats)
+}
+
+// keepAliveHeartbeats keeps alive the leases of the members whose heartbeat has been updated since before.
+func (c *Client) keepAliveHeartbeats(ctx context.Context, key string, before map[string]int64, hb Heartbeats) error {
+	for member, timestamp := range hb.Heartbeats() {
+		if previous, ok := before[member]; ok && previous == timestamp {
+			continue
+		}
+		if err := c.keepAliveHeartbeat(ctx, heartbeatsPrefix(key)+member); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keepAliveHeartbeat renews the lease of the heartbeat key, or creates it if it doesn't exist or has expired.
+func (c *Client) keepAliveHeartbeat(ctx context.Context, heartbeatKey string) error {
+	c.leasesMtx.Lock()
+	defer c.leasesMtx.Unlock()
+
+	if id, ok := c.leases[heartbeatKey]; ok {
+		_, err := c.cli.KeepAliveOnce(ctx, id)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, rpctypes.ErrLeaseNotFound) {
+			return err
+		}
+		delete(c.leases, heartbeatKey)
+	}
+
+	lease, err := c.cli.Grant(ctx, int64(math.Ceil(c.cfg.LeaseHeartbeatsTTL.Seconds())))
+	if err != nil {
+		return err
+	}
+	if _, err := c.cli.Put(ctx, heartbeatKey, "", clientv3.WithLease(lease.ID)); err != nil {
+		return err
+	}
+	c.leases[heartbeatKey] = lease.ID
+	return nil
+}
+
+// revokeHeartbeats revokes the leases held by this client for the members no longer in the value.
+func (c *Client) revokeHeartbeats(ctx context.Context, key string, hb Heartbeats) {
+	members := hb.Heartbeats()
+	prefix := heartbeatsPrefix(key)
+
+	c.leasesMtx.Lock()
+	defer c.leasesMtx.Unlock()
+
+	for heartbeatKey, id := range c.leases {
+		if !strings.HasPrefix(heartbeatKey, prefix) {
+			continue
+		}
+		if _, ok := members[strings.TrimPrefix(heartbeatKey, prefix)]; ok {
+			continue
+		}
+		if _, err := c.cli.Revoke(ctx, id); err != nil && !errors.Is(err, rpctypes.ErrLeaseNotFound) {
+			level.Warn(c.logger).Log("msg", "error revoking heartbeat lease", "key", heartbeatKey, "err", err)
+			continue
+		}
+		delete(c.leases, heartbeatKey)
+	}
+}
+
+// onlyHeartbeatsChanged returns true if the value only differs from the stored one by the heartbeats.
+func (c *Client) onlyHeartbeatsChanged(stored []byte, hb Heartbeats) bool {
+	if stored == nil {
+		return false
+	}
+	decoded, err := c.codec.Decode(stored)
+	if err != nil {
+		return false
+	}
+	original, ok := decoded.(Heartbeats)
+	if !ok {
+		return false
+	}
+	original.SetHeartbeats(hb.Heartbeats())
+	return original.Equal(hb)
+}
+
+// watchKeyWithHeartbeats is WatchKey when the lease heartbeats are enabled. The value is notified when it
+// changes, when a heartbeat lease is created or expires, and periodically so that the heartbeats of the
+// alive members don't get old.
+func (c *Client) watchKeyWithHeartbeats(ctx context.Context, key string, f func(interface{}) bool) {
+	backoff := backoff.New(ctx, backoff.Config{
+		MinBackoff: 1 * time.Second,
+		MaxBackoff: 1 * time.Minute,
+	})
+
+	defer level.Debug(c.logger).Log("msg", "Finished watching key", "key", key)
+	level.Debug(c.logger).Log("msg", "Watching key", "key", key)
+
+	refresh := time.NewTicker(c.cfg.LeaseHeartbeatsTTL / 4)
+	defer refresh.Stop()
+
+	for backoff.Ongoing() {
+		if !c.watchKeyWithHeartbeatsOnce(ctx, key, f, refresh.C, backoff) {
+			return
+		}
+		backoff.Wait()
+	}
+}
+
+// watchKeyWithHeartbeatsOnce watches the key and its heartbeats until an error, and returns false if f asked
+// to stop watching.
+func (c *Client) watchKeyWithHeartbeatsOnce(ctx context.Context, key string, f func(interface{}) bool, refresh <-chan time.Time, backoff *backoff.Backoff) bool {
+	// Ensure the context used by the Watch is always cancelled.
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The watches are started before reading the current state, so that no change is missed.
+	prefix := heartbeatsPrefix(key)
+	valueChan := c.cli.Watch(watchCtx, key)
+	heartbeatsChan := c.cli.Watch(watchCtx, prefix, clientv3.WithPrefix())
+
+	opsCtx, opsCancel := c.opsContext(watchCtx)
+	defer opsCancel()
+
+	resp, err := c.cli.Get(opsCtx, key)
+	if err != nil {
+		level.Error(c.logger).Log("msg", "error getting key", "key", key, "err", err)
+		return true
+	}
+	alive, err := c.aliveMembers(opsCtx, key)
+	if err != nil {
+		level.Error(c.logger).Log("msg", "error getting heartbeats", "key", key, "err", err)
+		return true
+	}
+
+	var value []byte
+	if len(resp.Kvs) > 0 {
+		value = resp.Kvs[0].Value
+	}
+
+	// The value is decoded at every notification, because f may keep it.
+	notify := func() bool {
+		if value == nil {
+			return true
+		}
+		out, err := c.codec.Decode(value)
+		if err != nil {
+			level.Error(c.logger).Log("msg", "error decoding key", "key", key, "err", err)
+			return true
+		}
+		if hb, ok := out.(Heartbeats); ok {
+			setAliveHeartbeats(hb, alive)
+		}
+		return f(out)
+	}
+
+	if !notify() {
+		return false
+	}
+	backoff.Reset()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+
+		case resp, ok := <-valueChan:
+			if !ok {
+				return true
+			}
+			if err := resp.Err(); err != nil {
+				level.Error(c.logger).Log("msg", "watch error", "key", key, "err", err)
+				return true
+			}
+			for _, event := range resp.Events {
+				if event.Type == mvccpb.DELETE {
+					continue
+				}
+				value = event.Kv.Value
+				if !notify() {
+					return false
+				}
+			}
+
+		case resp, ok := <-heartbeatsChan:
+			if !ok {
+				return true
+			}
+			if err := resp.Err(); err != nil {
+				level.Error(c.logger).Log("msg", "watch error", "key", prefix, "err", err)
+				return true
+			}
+			for _, event := range resp.Events {
+				member := strings.TrimPrefix(string(event.Kv.Key), prefix)
+				if event.Type == mvccpb.DELETE {
+					delete(alive, member)
+				} else {
+					alive[member] = struct{}{}
+				}
+			}
+			if !notify() {
+				return false
+			}
+
+		case <-refresh:
+			if !notify() {
+				return false
+			}
+		}
+	}
+}
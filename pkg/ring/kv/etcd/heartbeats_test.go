@@ -0,0 +1,214 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// testMembers is a value whose members heartbeat, used to test the lease heartbeats.
+type testMembers struct {
+	Tokens     map[string]int   `json:"tokens"`
+	Timestamps map[string]int64 `json:"timestamps"`
+}
+
+func (m *testMembers) Heartbeats() map[string]int64 {
+	result := make(map[string]int64, len(m.Timestamps))
+	for id, ts := range m.Timestamps {
+		result[id] = ts
+	}
+	return result
+}
+
+func (m *testMembers) SetHeartbeats(heartbeats map[string]int64) {
+	for id, ts := range heartbeats {
+		if _, ok := m.Timestamps[id]; ok {
+			m.Timestamps[id] = ts
+		}
+	}
+}
+
+func (m *testMembers) Equal(that interface{}) bool {
+	return reflect.DeepEqual(m, that)
+}
+
+type testMembersCodec struct{}
+
+func (testMembersCodec) Decode(b []byte) (interface{}, error) {
+	out := &testMembers{}
+	return out, json.Unmarshal(b, out)
+}
+
+func (testMembersCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (testMembersCodec) DecodeMultiKey(map[string][]byte) (interface{}, error) {
+	panic("DecodeMultiKey unimplemented")
+}
+
+func (testMembersCodec) EncodeMultiKey(interface{}) (map[string][]byte, error) {
+	panic("EncodeMultiKey unimplemented")
+}
+
+func (testMembersCodec) CodecID() string {
+	return "testMembers"
+}
+
+func newLeaseHeartbeatsClient(t *testing.T) (*Client, *mockKV) {
+	client, closer := NewInMemoryClient(testMembersCodec{}, log.NewNopLogger())
+	t.Cleanup(func() { _ = closer.Close() })
+
+	client.cfg.LeaseHeartbeatsEnabled = true
+	client.cfg.LeaseHeartbeatsTTL = time.Minute
+	return client, closer.(*mockKV)
+}
+
+// heartbeat is a CAS callback which adds the member, if missing, and updates its heartbeat.
+func heartbeat(member string, tokens int) func(in interface{}) (interface{}, bool, error) {
+	return func(in interface{}) (interface{}, bool, error) {
+		m, _ := in.(*testMembers)
+		if m == nil {
+			m = &testMembers{Tokens: map[string]int{}, Timestamps: map[string]int64{}}
+		}
+		m.Tokens[member] = tokens
+		m.Timestamps[member] = time.Now().Unix()
+		return m, true, nil
+	}
+}
+
+// storeOldHeartbeats resets the heartbeats stored in the value of key, without touching the leases.
+func storeOldHeartbeats(t *testing.T, kv *mockKV, key string) {
+	resp, err := kv.Get(context.Background(), key)
+	require.NoError(t, err)
+	require.Len(t, resp.Kvs, 1)
+
+	stored := &testMembers{}
+	require.NoError(t, json.Unmarshal(resp.Kvs[0].Value, stored))
+	for id := range stored.Timestamps {
+		stored.Timestamps[id] = 0
+	}
+	buf, err := json.Marshal(stored)
+	require.NoError(t, err)
+	_, err = kv.Put(context.Background(), key, string(buf))
+	require.NoError(t, err)
+}
+
+func TestClient_LeaseHeartbeats(t *testing.T) {
+	const key = "ring"
+	ctx := context.Background()
+
+	t.Run("heartbeats only write the value when it changes", func(t *testing.T) {
+		client, kv := newLeaseHeartbeatsClient(t)
+
+		require.NoError(t, client.CAS(ctx, key, heartbeat("a", 1)))
+		resp, err := kv.Get(ctx, key)
+		require.NoError(t, err)
+		require.Len(t, resp.Kvs, 1)
+		version := resp.Kvs[0].Version
+
+		// A second heartbeat only renews the lease.
+		require.NoError(t, client.CAS(ctx, key, heartbeat("a", 1)))
+		resp, err = kv.Get(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, version, resp.Kvs[0].Version)
+
+		// A change of the tokens writes the value.
+		require.NoError(t, client.CAS(ctx, key, heartbeat("a", 2)))
+		resp, err = kv.Get(ctx, key)
+		require.NoError(t, err)
+		assert.Greater(t, resp.Kvs[0].Version, version)
+	})
+
+	t.Run("members with an alive lease have a recent heartbeat", func(t *testing.T) {
+		client, kv := newLeaseHeartbeatsClient(t)
+
+		require.NoError(t, client.CAS(ctx, key, heartbeat("a", 1)))
+		require.NoError(t, client.CAS(ctx, key, heartbeat("b", 2)))
+
+		// Make the stored heartbeats old, as they would be after a while.
+		storeOldHeartbeats(t, kv, key)
+
+		// Expire the lease of b.
+		client.leasesMtx.Lock()
+		leaseB := client.leases[heartbeatsPrefix(key)+"b"]
+		client.leasesMtx.Unlock()
+		_, err := kv.Revoke(ctx, leaseB)
+		require.NoError(t, err)
+
+		out, err := client.Get(ctx, key)
+		require.NoError(t, err)
+		members := out.(*testMembers)
+		assert.InDelta(t, time.Now().Unix(), members.Timestamps["a"], 5)
+		assert.Equal(t, int64(0), members.Timestamps["b"])
+
+		// The next heartbeat of b creates a new lease.
+		require.NoError(t, client.CAS(ctx, key, heartbeat("b", 2)))
+		out, err = client.Get(ctx, key)
+		require.NoError(t, err)
+		assert.InDelta(t, time.Now().Unix(), out.(*testMembers).Timestamps["b"], 5)
+	})
+
+	t.Run("leases of removed members are revoked", func(t *testing.T) {
+		client, kv := newLeaseHeartbeatsClient(t)
+
+		require.NoError(t, client.CAS(ctx, key, heartbeat("a", 1)))
+		require.NoError(t, client.CAS(ctx, key, func(in interface{}) (interface{}, bool, error) {
+			m := in.(*testMembers)
+			delete(m.Tokens, "a")
+			delete(m.Timestamps, "a")
+			return m, true, nil
+		}))
+
+		resp, err := kv.Get(ctx, heartbeatsPrefix(key), clientv3.WithPrefix())
+		require.NoError(t, err)
+		assert.Empty(t, resp.Kvs)
+		assert.Empty(t, kv.leases)
+	})
+
+	t.Run("watch notifies the heartbeats expiration", func(t *testing.T) {
+		client, kv := newLeaseHeartbeatsClient(t)
+
+		require.NoError(t, client.CAS(ctx, key, heartbeat("a", 1)))
+		storeOldHeartbeats(t, kv, key)
+
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		updates := make(chan *testMembers, 10)
+		go client.WatchKey(watchCtx, key, func(in interface{}) bool {
+			updates <- in.(*testMembers)
+			return true
+		})
+
+		select {
+		case m := <-updates:
+			assert.InDelta(t, time.Now().Unix(), m.Timestamps["a"], 5)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "no update received")
+		}
+
+		// Expire the lease.
+		client.leasesMtx.Lock()
+		lease := client.leases[heartbeatsPrefix(key)+"a"]
+		client.leasesMtx.Unlock()
+		_, err := kv.Revoke(ctx, lease)
+		require.NoError(t, err)
+
+		assert.Eventually(t, func() bool {
+			select {
+			case m := <-updates:
+				return m.Timestamps["a"] == 0
+			default:
+				return false
+			}
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
@@ -5,11 +5,13 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"reflect"
 	"sync"
 
 	"github.com/go-kit/log"
 	"go.etcd.io/etcd/api/v3/etcdserverpb"
 	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
 	clientv3 "go.etcd.io/etcd/client/v3"
 
 	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
@@ -34,6 +36,7 @@ func NewInMemoryClient(codec codec.Codec, logger log.Logger) (*Client, io.Closer
 		codec:  codec,
 		cli:    kv,
 		logger: logger,
+		leases: map[string]clientv3.LeaseID{},
 	}
 
 	return client, kv
@@ -44,6 +47,7 @@ func newMockKV() *mockKV {
 	kv := &mockKV{
 		values:    make(map[string]mvccpb.KeyValue),
 		valuesMtx: sync.Mutex{},
+		leases:    make(map[clientv3.LeaseID]int64),
 		close:     make(chan struct{}),
 		events:    make(map[chan clientv3.Event]struct{}),
 		eventsMtx: sync.Mutex{},
@@ -65,6 +69,8 @@ func newMockKV() *mockKV {
 //   - Compact is not implemented and will panic
 //   - RequestProgress is not implemented and will panic
 //   - Only exact and prefix matching is supported for Get, Put, and Delete
+//   - Leases never expire, their expiration is simulated by revoking them
+//   - KeepAlive, TimeToLive and Leases are not implemented and will panic
 //   - There may be inconsistencies with how various version numbers are adjusted
 //     but none that are exposed by kv.Client unit tests
 type mockKV struct {
@@ -72,6 +78,11 @@ type mockKV struct {
 	values    map[string]mvccpb.KeyValue
 	valuesMtx sync.Mutex
 
+	// TTL of the leases created by grant calls, guarded by valuesMtx since
+	// revoking a lease deletes its keys
+	leases      map[clientv3.LeaseID]int64
+	lastLeaseID clientv3.LeaseID
+
 	// Channel for stopping all running watch goroutines and closing
 	// and cleaning up all channels used for sending events to watchers
 	close chan struct{}
@@ -224,6 +235,69 @@ func (m *mockKV) Compact(context.Context, int64, ...clientv3.CompactOption) (*cl
 	panic("Compact unimplemented")
 }
 
+// Grant implements the Clientv3Facade interface
+func (m *mockKV) Grant(_ context.Context, ttl int64) (*clientv3.LeaseGrantResponse, error) {
+	m.valuesMtx.Lock()
+	defer m.valuesMtx.Unlock()
+
+	m.lastLeaseID++
+	m.leases[m.lastLeaseID] = ttl
+	return &clientv3.LeaseGrantResponse{ID: m.lastLeaseID, TTL: ttl}, nil
+}
+
+// Revoke implements the Clientv3Facade interface
+func (m *mockKV) Revoke(_ context.Context, id clientv3.LeaseID) (*clientv3.LeaseRevokeResponse, error) {
+	m.valuesMtx.Lock()
+	defer m.valuesMtx.Unlock()
+
+	if _, ok := m.leases[id]; !ok {
+		return nil, rpctypes.ErrLeaseNotFound
+	}
+	delete(m.leases, id)
+
+	for k := range m.values {
+		kv := m.values[k]
+		if clientv3.LeaseID(kv.Lease) != id {
+			continue
+		}
+		kv.ModRevision = kv.Version
+		m.sendEvent(clientv3.Event{
+			Type: mvccpb.DELETE,
+			Kv:   &kv,
+		})
+		delete(m.values, k)
+	}
+
+	return &clientv3.LeaseRevokeResponse{}, nil
+}
+
+// KeepAliveOnce implements the Clientv3Facade interface
+func (m *mockKV) KeepAliveOnce(_ context.Context, id clientv3.LeaseID) (*clientv3.LeaseKeepAliveResponse, error) {
+	m.valuesMtx.Lock()
+	defer m.valuesMtx.Unlock()
+
+	ttl, ok := m.leases[id]
+	if !ok {
+		return nil, rpctypes.ErrLeaseNotFound
+	}
+	return &clientv3.LeaseKeepAliveResponse{ID: id, TTL: ttl}, nil
+}
+
+// KeepAlive implements the Clientv3Facade interface
+func (m *mockKV) KeepAlive(context.Context, clientv3.LeaseID) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+	panic("KeepAlive unimplemented")
+}
+
+// TimeToLive implements the Clientv3Facade interface
+func (m *mockKV) TimeToLive(context.Context, clientv3.LeaseID, ...clientv3.LeaseOption) (*clientv3.LeaseTimeToLiveResponse, error) {
+	panic("TimeToLive unimplemented")
+}
+
+// Leases implements the Clientv3Facade interface
+func (m *mockKV) Leases(context.Context) (*clientv3.LeaseLeasesResponse, error) {
+	panic("Leases unimplemented")
+}
+
 // Do implements the Clientv3Facade interface
 func (m *mockKV) Do(_ context.Context, op clientv3.Op) (clientv3.OpResponse, error) {
 	m.valuesMtx.Lock()
@@ -304,6 +378,12 @@ func (m *mockKV) doPut(op clientv3.Op) (clientv3.OpResponse, error) {
 		}
 	}
 
+	lease := leaseOf(op)
+	if _, ok := m.leases[lease]; lease != clientv3.NoLease && !ok {
+		return clientv3.OpResponse{}, rpctypes.ErrLeaseNotFound
+	}
+	newVal.Lease = int64(lease)
+
 	m.values[key] = newVal
 	m.sendEvent(clientv3.Event{
 		Type: mvccpb.PUT,
@@ -314,6 +394,11 @@ func (m *mockKV) doPut(op clientv3.Op) (clientv3.OpResponse, error) {
 	return res.OpResponse(), nil
 }
 
+// leaseOf returns the lease of the put operation. The Op doesn't expose it, so it's read by reflection.
+func leaseOf(op clientv3.Op) clientv3.LeaseID {
+	return clientv3.LeaseID(reflect.ValueOf(op).FieldByName("leaseID").Int())
+}
+
 func (m *mockKV) doTxn(op clientv3.Op) (clientv3.OpResponse, error) {
 	cmps, thens, elses := op.Txn()
 	succeeded := m.evalCmps(cmps)
@@ -327,12 +412,16 @@ func (m *mockKV) doTxn(op clientv3.Op) (clientv3.OpResponse, error) {
 
 	responses := make([]*etcdserverpb.ResponseOp, 0, len(toRun))
 	for _, o := range toRun {
-		_, err := m.doInternal(o)
+		resp, err := m.doInternal(o)
 		if err != nil {
 			panic(fmt.Sprintf("unexpected error running transaction: %s", err))
 		}
 
-		responses = append(responses, &etcdserverpb.ResponseOp{Response: nil})
+		response := &etcdserverpb.ResponseOp{}
+		if o.IsGet() {
+			response.Response = &etcdserverpb.ResponseOp_ResponseRange{ResponseRange: (*etcdserverpb.RangeResponse)(resp.Get())}
+		}
+		responses = append(responses, response)
 	}
 
 	res := clientv3.TxnResponse{
@@ -0,0 +1,124 @@
+package memberlist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-kit/log/level"
+	"github.com/hashicorp/memberlist"
+)
+
+// Reasons of the messages rejected by the gossip encryption.
+const (
+	authFailureDecryption         = "decryption_failed"
+	authFailureUnencrypted        = "unencrypted"
+	authFailureEncryptionDisabled = "encryption_disabled"
+)
+
+var authFailureReasons = []string{authFailureDecryption, authFailureUnencrypted, authFailureEncryptionDisabled}
+
+// authFailureReason returns the reason why memberlist rejected a message, if the memberlist log message reports
+// a message rejected by the gossip encryption.
+func authFailureReason(msg string) (string, bool) {
+	switch {
+	case strings.Contains(msg, "Decrypt packet failed"), strings.Contains(msg, "No installed keys could decrypt the message"):
+		return authFailureDecryption, true
+	case strings.Contains(msg, "Encryption is configured but remote state is not encrypted"):
+		return authFailureUnencrypted, true
+	case strings.Contains(msg, "Remote state is encrypted and encryption is not configured"):
+		return authFailureEncryptionDisabled, true
+	default:
+		return "", false
+	}
+}
+
+// readEncryptionKeys reads the base64 encoded keys of the file, one per line. The empty lines and the lines starting
+// with # are ignored. The first key is the primary key.
+func readEncryptionKeys(file string) ([][]byte, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the gossip encryption keys file: %w", err)
+	}
+
+	var keys [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		key, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode the gossip encryption key at line %d of %s: %w", line, file, err)
+		}
+		if err := memberlist.ValidateKey(key); err != nil {
+			return nil, fmt.Errorf("invalid gossip encryption key at line %d of %s: %w", line, file, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the gossip encryption keys file: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no gossip encryption key in %s", file)
+	}
+	return keys, nil
+}
+
+// newEncryptionKeyring returns the keyring of the keys of the gossip encryption keys file.
+func newEncryptionKeyring(file string) (*memberlist.Keyring, error) {
+	keys, err := readEncryptionKeys(file)
+	if err != nil {
+		return nil, err
+	}
+	return memberlist.NewKeyring(keys, keys[0])
+}
+
+// reloadEncryptionKeys updates the keyring with the keys of the gossip encryption keys file. The new keys are
+// installed before switching the primary key, and the keys no longer in the file are removed last, so that the
+// messages encrypted with any of the keys of the file are accepted during the rotation.
+func (m *KV) reloadEncryptionKeys() error {
+	keys, err := readEncryptionKeys(m.cfg.EncryptionKeysFile)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := m.keyring.AddKey(key); err != nil {
+			return err
+		}
+	}
+	primaryChanged := !bytes.Equal(m.keyring.GetPrimaryKey(), keys[0])
+	if err := m.keyring.UseKey(keys[0]); err != nil {
+		return err
+	}
+
+	removed := 0
+	for _, installed := range m.keyring.GetKeys() {
+		if !containsKey(keys, installed) {
+			if err := m.keyring.RemoveKey(installed); err != nil {
+				return err
+			}
+			removed++
+		}
+	}
+
+	if primaryChanged || removed > 0 {
+		level.Info(m.logger).Log("msg", "reloaded the gossip encryption keys", "keys", len(keys), "primary_changed", primaryChanged, "removed", removed)
+	}
+	return nil
+}
+
+func containsKey(keys [][]byte, key []byte) bool {
+	for _, k := range keys {
+		if bytes.Equal(k, key) {
+			return true
+		}
+	}
+	return false
+}
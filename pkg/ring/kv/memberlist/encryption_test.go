@@ -0,0 +1,166 @@
+package memberlist
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+func writeEncryptionKeys(t *testing.T, file string, keys ...[]byte) {
+	var content strings.Builder
+	content.WriteString("# Gossip encryption keys.\n\n")
+	for _, key := range keys {
+		content.WriteString(base64.StdEncoding.EncodeToString(key) + "\n")
+	}
+	require.NoError(t, os.WriteFile(file, []byte(content.String()), 0o600))
+}
+
+func TestReadEncryptionKeys(t *testing.T) {
+	dir := t.TempDir()
+	key1 := bytes.Repeat([]byte{1}, 16)
+	key2 := bytes.Repeat([]byte{2}, 32)
+
+	file := filepath.Join(dir, "keys")
+	writeEncryptionKeys(t, file, key1, key2)
+	keys, err := readEncryptionKeys(file)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{key1, key2}, keys)
+
+	for name, content := range map[string]string{
+		"empty":              "# No key.\n",
+		"invalid base64":     "not base64!\n",
+		"invalid key length": base64.StdEncoding.EncodeToString([]byte("short")) + "\n",
+	} {
+		require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+		_, err := readEncryptionKeys(file)
+		assert.Error(t, err, name)
+	}
+
+	_, err = readEncryptionKeys(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestAuthFailureReason(t *testing.T) {
+	for msg, expected := range map[string]string{
+		"Decrypt packet failed: No installed keys could decrypt the message from=127.0.0.1:7946": authFailureDecryption,
+		"failed to receive: No installed keys could decrypt the message from=127.0.0.1:7946":     authFailureDecryption,
+		"failed to receive: Encryption is configured but remote state is not encrypted":          authFailureUnencrypted,
+		"failed to receive: Remote state is encrypted and encryption is not configured":          authFailureEncryptionDisabled,
+	} {
+		reason, ok := authFailureReason(msg)
+		assert.True(t, ok, msg)
+		assert.Equal(t, expected, reason, msg)
+	}
+
+	_, ok := authFailureReason("Stream connection from=127.0.0.1:7946")
+	assert.False(t, ok)
+}
+
+func TestKV_ReloadEncryptionKeys(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "keys")
+	oldKey := bytes.Repeat([]byte{1}, 16)
+	newKey := bytes.Repeat([]byte{2}, 16)
+	writeEncryptionKeys(t, file, oldKey)
+
+	var cfg KVConfig
+	flagext.DefaultValues(&cfg)
+	cfg.EncryptionKeysFile = file
+	kv := NewKV(cfg, log.NewNopLogger(), &dnsProviderMock{}, prometheus.NewPedanticRegistry())
+	keyring, err := newEncryptionKeyring(file)
+	require.NoError(t, err)
+	kv.keyring = keyring
+
+	// Rollover: the new key is first accepted, then used, and the old key is finally removed.
+	writeEncryptionKeys(t, file, oldKey, newKey)
+	require.NoError(t, kv.reloadEncryptionKeys())
+	assert.Equal(t, oldKey, kv.keyring.GetPrimaryKey())
+	assert.Len(t, kv.keyring.GetKeys(), 2)
+
+	writeEncryptionKeys(t, file, newKey, oldKey)
+	require.NoError(t, kv.reloadEncryptionKeys())
+	assert.Equal(t, newKey, kv.keyring.GetPrimaryKey())
+	assert.Len(t, kv.keyring.GetKeys(), 2)
+
+	writeEncryptionKeys(t, file, newKey)
+	require.NoError(t, kv.reloadEncryptionKeys())
+	assert.Equal(t, [][]byte{newKey}, kv.keyring.GetKeys())
+
+	// The keys are kept if the file is invalid.
+	require.NoError(t, os.WriteFile(file, []byte("invalid"), 0o600))
+	require.Error(t, kv.reloadEncryptionKeys())
+	assert.Equal(t, [][]byte{newKey}, kv.keyring.GetKeys())
+}
+
+func TestMemberlistEncryption(t *testing.T) {
+	ports, err := getFreePorts(3)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	key1 := bytes.Repeat([]byte{1}, 16)
+	key2 := bytes.Repeat([]byte{2}, 16)
+
+	newKV := func(t *testing.T, port int, keys ...[]byte) (*KV, *prometheus.Registry) {
+		var cfg KVConfig
+		flagext.DefaultValues(&cfg)
+		cfg.TCPTransport = TCPTransportConfig{
+			BindAddrs: []string{"localhost"},
+			BindPort:  port,
+		}
+		cfg.RandomizeNodeName = true
+		cfg.Codecs = []codec.Codec{dataCodec{}}
+		cfg.AbortIfJoinFails = false
+		if port != ports[0] {
+			cfg.JoinMembers = []string{fmt.Sprintf("localhost:%d", ports[0])}
+		}
+		if len(keys) > 0 {
+			cfg.EncryptionKeysFile = filepath.Join(dir, fmt.Sprintf("keys-%d", port))
+			writeEncryptionKeys(t, cfg.EncryptionKeysFile, keys...)
+		}
+
+		reg := prometheus.NewPedanticRegistry()
+		kv := NewKV(cfg, log.NewNopLogger(), &dnsProviderMock{}, reg)
+		require.NoError(t, services.StartAndAwaitRunning(context.Background(), kv))
+		t.Cleanup(func() {
+			require.NoError(t, services.StopAndAwaitTerminated(context.Background(), kv))
+		})
+		return kv, reg
+	}
+
+	kv1, reg1 := newKV(t, ports[0], key1)
+
+	// A member encrypting with the key of the first member can join, even if it also accepts another key.
+	kv2, _ := newKV(t, ports[1], key1, key2)
+	poll(t, 5*time.Second, 2, func() interface{} {
+		return kv2.memberlist.NumMembers()
+	})
+
+	// A member without the keys can't join, and its messages are rejected.
+	kv3, _ := newKV(t, ports[2], key2)
+	assert.Equal(t, 1, kv3.memberlist.NumMembers())
+	assert.Equal(t, 2, kv1.memberlist.NumMembers())
+	poll(t, 5*time.Second, true, func() interface{} {
+		return testutil.ToFloat64(kv1.authFailures.WithLabelValues(authFailureDecryption)) > 0
+	})
+
+	assert.NoError(t, testutil.GatherAndCompare(reg1, strings.NewReader(`
+		# HELP memberlist_client_encryption_keys Number of gossip encryption keys accepted to decrypt the received messages.
+		# TYPE memberlist_client_encryption_keys gauge
+		memberlist_client_encryption_keys 1
+	`), "memberlist_client_encryption_keys"))
+}
@@ -84,6 +84,22 @@ func (c *Client) CAS(ctx context.Context, key string, f func(in interface{}) (ou
 	return c.kv.CAS(ctx, key, c.codec, f)
 }
 
+// MultiCAS is like CAS, but for several keys at once. The keys are CASed one by one in the local state,
+// and their changes are queued for broadcasting together, so that they are gossiped in the same messages.
+func (c *Client) MultiCAS(ctx context.Context, keys []string, fs []func(in interface{}) (out interface{}, retry bool, err error)) error {
+	err := c.awaitKVRunningOrStopping(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i, key := range keys {
+		if err := c.kv.CAS(ctx, key, c.codec, fs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // WatchKey is part of kv.Client interface.
 func (c *Client) WatchKey(ctx context.Context, key string, f func(interface{}) bool) {
 	err := c.awaitKVRunningOrStopping(ctx)
@@ -161,6 +177,12 @@ type KVConfig struct {
 	// How much space to use to keep received and sent messages in memory (for troubleshooting).
 	MessageHistoryBufferBytes int `yaml:"message_history_buffer_bytes"`
 
+	// Gossip encryption.
+	EncryptionKeysFile           string        `yaml:"encryption_keys_file"`
+	EncryptionKeysReloadInterval time.Duration `yaml:"encryption_keys_reload_interval"`
+	EncryptionVerifyIncoming     bool          `yaml:"encryption_verify_incoming"`
+	EncryptionVerifyOutgoing     bool          `yaml:"encryption_verify_outgoing"`
+
 	TCPTransport TCPTransportConfig `yaml:",inline"`
 
 	// Where to put custom metrics. Metrics are not registered, if this is nil.
@@ -197,6 +219,10 @@ func (cfg *KVConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string) {
 	f.BoolVar(&cfg.EnableCompression, prefix+"memberlist.compression-enabled", mlDefaults.EnableCompression, "Enable message compression. This can be used to reduce bandwidth usage at the cost of slightly more CPU utilization.")
 	f.StringVar(&cfg.AdvertiseAddr, prefix+"memberlist.advertise-addr", mlDefaults.AdvertiseAddr, "Gossip address to advertise to other members in the cluster. Used for NAT traversal.")
 	f.IntVar(&cfg.AdvertisePort, prefix+"memberlist.advertise-port", mlDefaults.AdvertisePort, "Gossip port to advertise to other members in the cluster. Used for NAT traversal.")
+	f.StringVar(&cfg.EncryptionKeysFile, prefix+"memberlist.encryption-keys-file", "", "EXPERIMENTAL: Path of the file with the gossip encryption keys, one base64 encoded key of 16, 24 or 32 bytes per line. The first key encrypts the sent messages, and all the keys decrypt the received messages. The gossip isn't encrypted if empty.")
+	f.DurationVar(&cfg.EncryptionKeysReloadInterval, prefix+"memberlist.encryption-keys-reload-interval", time.Minute, "EXPERIMENTAL: How often to reload the gossip encryption keys file, to rotate the keys without restarting. 0 to disable.")
+	f.BoolVar(&cfg.EncryptionVerifyIncoming, prefix+"memberlist.encryption-verify-incoming", true, "EXPERIMENTAL: True to reject the received messages which aren't encrypted when the gossip encryption is enabled. Disable it while enabling the gossip encryption on a running cluster.")
+	f.BoolVar(&cfg.EncryptionVerifyOutgoing, prefix+"memberlist.encryption-verify-outgoing", true, "EXPERIMENTAL: True to encrypt the sent messages when the gossip encryption is enabled. Disable it while enabling the gossip encryption on a running cluster.")
 
 	cfg.TCPTransport.RegisterFlagsWithPrefix(f, prefix)
 }
@@ -235,6 +261,9 @@ type KV struct {
 	memberlist *memberlist.Memberlist
 	broadcasts *memberlist.TransmitLimitedQueue
 
+	// Gossip encryption keys, nil if the gossip isn't encrypted.
+	keyring *memberlist.Keyring
+
 	// KV Store.
 	storeMu sync.Mutex
 	store   map[string]valueDesc
@@ -282,6 +311,10 @@ type KV struct {
 	memberlistMembersCount prometheus.GaugeFunc
 	memberlistHealthScore  prometheus.GaugeFunc
 
+	authFailures               *prometheus.CounterVec
+	encryptionKeys             prometheus.GaugeFunc
+	encryptionKeysReloadErrors prometheus.Counter
+
 	// make this configurable for tests. Default value is fine for normal usage
 	// where updates are coming from network, but when running tests with many
 	// goroutines using same KV, default can be too low.
@@ -399,9 +432,20 @@ func (m *KV) buildMemberlistConfig() (*memberlist.Config, error) {
 		level.Info(m.logger).Log("msg", "Using memberlist cluster node name", "name", mlCfg.Name)
 	}
 
-	mlCfg.LogOutput = newMemberlistLoggerAdapter(m.logger, false)
+	mlCfg.LogOutput = newMemberlistLoggerAdapter(m.logger, false, m.authFailures)
 	mlCfg.Transport = tr
 
+	if m.cfg.EncryptionKeysFile != "" {
+		keyring, err := newEncryptionKeyring(m.cfg.EncryptionKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		m.keyring = keyring
+		mlCfg.Keyring = keyring
+		mlCfg.GossipVerifyIncoming = m.cfg.EncryptionVerifyIncoming
+		mlCfg.GossipVerifyOutgoing = m.cfg.EncryptionVerifyOutgoing
+	}
+
 	// Memberlist uses UDPBufferSize to figure out how many messages it can put into single "packet".
 	// As we don't use UDP for sending packets, we can use higher value here.
 	mlCfg.UDPBufferSize = 10 * 1024 * 1024
@@ -473,6 +517,14 @@ func (m *KV) running(ctx context.Context) error {
 		}
 	}
 
+	var reloadKeysChan <-chan time.Time
+	if m.keyring != nil && m.cfg.EncryptionKeysReloadInterval > 0 {
+		t := time.NewTicker(m.cfg.EncryptionKeysReloadInterval)
+		defer t.Stop()
+
+		reloadKeysChan = t.C
+	}
+
 	var tickerChan <-chan time.Time
 	if m.cfg.RejoinInterval > 0 && len(m.cfg.JoinMembers) > 0 {
 		t := time.NewTicker(m.cfg.RejoinInterval)
@@ -491,6 +543,12 @@ func (m *KV) running(ctx context.Context) error {
 		select {
 		case <-tickerChan:
 			m.rejoinMemberlist(ctx)
+		case <-reloadKeysChan:
+			if err := m.reloadEncryptionKeys(); err != nil {
+				// Keep the current keys, so that the gossip keeps working until the file is fixed.
+				level.Error(m.logger).Log("msg", "failed to reload the gossip encryption keys", "err", err)
+				m.encryptionKeysReloadErrors.Inc()
+			}
 		case <-ctx.Done():
 			return nil
 		}
@@ -358,6 +358,27 @@ func TestCASErrorWithRetries(t *testing.T) {
 	})
 }
 
+func TestMultiCAS(t *testing.T) {
+	withFixtures(t, func(t *testing.T, kv *Client) {
+		keys := []string{"a", "b"}
+		fs := make([]func(in interface{}) (interface{}, bool, error), 0, len(keys))
+		for _, name := range []string{"Ing 1", "Ing 2"} {
+			update := updateFn(name)
+			fs = append(fs, func(in interface{}) (interface{}, bool, error) {
+				var r *data
+				if in != nil {
+					r = in.(*data)
+				}
+				return update(r)
+			})
+		}
+		require.NoError(t, kv.MultiCAS(context.Background(), keys, fs))
+
+		assert.Contains(t, getData(t, kv, "a").Members, "Ing 1")
+		assert.Contains(t, getData(t, kv, "b").Members, "Ing 2")
+	})
+}
+
 func TestCASNoChange(t *testing.T) {
 	withFixtures(t, func(t *testing.T, kv *Client) {
 		cas(t, kv, key, func(in *data) (*data, bool, error) {
@@ -7,6 +7,7 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // loggerAdapter wraps a Logger and allows it to be passed to the stdlib
@@ -16,14 +17,19 @@ import (
 type loggerAdapter struct {
 	log.Logger
 	logTimestamp bool
+
+	// Counts the messages rejected by the gossip encryption, reported by memberlist
+	// only via its logs. Optional.
+	authFailures *prometheus.CounterVec
 }
 
 // newMemberlistLoggerAdapter returns a new loggerAdapter, that can be passed
 // memberlist.Config.LogOutput field.
-func newMemberlistLoggerAdapter(logger log.Logger, logTimestamp bool) io.Writer {
+func newMemberlistLoggerAdapter(logger log.Logger, logTimestamp bool, authFailures *prometheus.CounterVec) io.Writer {
 	a := loggerAdapter{
 		Logger:       logger,
 		logTimestamp: logTimestamp,
+		authFailures: authFailures,
 	}
 	return a
 }
@@ -70,6 +76,10 @@ func (a loggerAdapter) Write(p []byte) (int, error) {
 	}
 	if msg, ok := result["msg"]; ok {
 		keyvals = append(keyvals, "msg", msg)
+
+		if reason, ok := authFailureReason(msg); ok && a.authFailures != nil {
+			a.authFailures.WithLabelValues(reason).Inc()
+		}
 	}
 	if err := a.Logger.Log(keyvals...); err != nil {
 		return 0, err
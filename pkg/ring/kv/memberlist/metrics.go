@@ -157,6 +157,36 @@ func (m *KV) createAndRegisterMetrics() {
 		return 0
 	})
 
+	m.authFailures = promauto.With(m.registerer).NewCounterVec(prometheus.CounterOpts{
+		Namespace: m.cfg.MetricsNamespace,
+		Subsystem: subsystem,
+		Name:      "messages_auth_failures_total",
+		Help:      "Number of messages rejected by the gossip encryption.",
+	}, []string{"reason"})
+	for _, reason := range authFailureReasons {
+		m.authFailures.WithLabelValues(reason)
+	}
+
+	m.encryptionKeys = promauto.With(m.registerer).NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: m.cfg.MetricsNamespace,
+		Subsystem: subsystem,
+		Name:      "encryption_keys",
+		Help:      "Number of gossip encryption keys accepted to decrypt the received messages.",
+	}, func() float64 {
+		// m.keyring is not set before Starting state
+		if (m.State() == services.Running || m.State() == services.Stopping) && m.keyring != nil {
+			return float64(len(m.keyring.GetKeys()))
+		}
+		return 0
+	})
+
+	m.encryptionKeysReloadErrors = promauto.With(m.registerer).NewCounter(prometheus.CounterOpts{
+		Namespace: m.cfg.MetricsNamespace,
+		Subsystem: subsystem,
+		Name:      "encryption_keys_reload_failures_total",
+		Help:      "Number of failed reloads of the gossip encryption keys file.",
+	})
+
 	m.watchPrefixDroppedNotifications = promauto.With(m.registerer).NewCounterVec(prometheus.CounterOpts{
 		Namespace: m.cfg.MetricsNamespace,
 		Subsystem: subsystem,
@@ -24,6 +24,9 @@ type MultiConfig struct {
 
 	// ConfigProvider returns channel with MultiRuntimeConfig updates.
 	ConfigProvider func() <-chan MultiRuntimeConfig `yaml:"-"`
+
+	// Registry tracks the MultiClients of the process, to drive their migration via the admin endpoint.
+	Registry *MultiClients `yaml:"-"`
 }
 
 // RegisterFlagsWithPrefix registers flags with prefix.
@@ -42,6 +45,10 @@ type MultiRuntimeConfig struct {
 
 	// Mirroring enabled or not. Nil = no change.
 	Mirroring *bool `yaml:"mirror_enabled"`
+
+	// Phase of the migration from the primary store to the secondary store (mirror, switch or complete). When set,
+	// it takes precedence over the primary store and the mirroring. Empty values are ignored.
+	MigrationPhase string `yaml:"migration_phase"`
 }
 
 type kvclient struct {
@@ -69,8 +76,20 @@ type MultiClient struct {
 	// The primary client used for interaction.
 	primaryID *atomic.Int32
 
+	ctx    context.Context
 	cancel context.CancelFunc
 
+	// Current migration phase, empty if no migration has been started.
+	migrationPhase *atomic.String
+
+	// Keys and prefixes used through the client, which are copied and verified when migrating.
+	keysMu   sync.Mutex
+	keys     map[string]struct{}
+	prefixes map[string]struct{}
+
+	verificationMu   sync.Mutex
+	lastVerification *MigrationVerification
+
 	inProgressMu sync.Mutex
 	// Cancel functions for ongoing operations. key is a value from inProgressCnt.
 	// What we really need is a []context.CancelFunc, but functions cannot be compared against each other using ==,
@@ -78,19 +97,25 @@ type MultiClient struct {
 	inProgress    map[int]clientInProgress
 	inProgressCnt int
 
-	primaryStoreGauge     *prometheus.GaugeVec
-	mirrorEnabledGauge    prometheus.Gauge
-	mirrorWritesCounter   prometheus.Counter
-	mirrorFailuresCounter prometheus.Counter
+	primaryStoreGauge       *prometheus.GaugeVec
+	mirrorEnabledGauge      prometheus.Gauge
+	mirrorWritesCounter     prometheus.Counter
+	mirrorFailuresCounter   prometheus.Counter
+	migrationPhaseGauge     *prometheus.GaugeVec
+	migrationMissingKeys    prometheus.Gauge
+	migrationBackfilledKeys prometheus.Counter
 }
 
 // NewMultiClient creates new MultiClient with given KV Clients.
 // First client in the slice is the primary client.
 func NewMultiClient(cfg MultiConfig, clients []kvclient, logger log.Logger, registerer prometheus.Registerer) *MultiClient {
 	c := &MultiClient{
-		clients:    clients,
-		primaryID:  atomic.NewInt32(0),
-		inProgress: map[int]clientInProgress{},
+		clients:        clients,
+		primaryID:      atomic.NewInt32(0),
+		inProgress:     map[int]clientInProgress{},
+		migrationPhase: atomic.NewString(""),
+		keys:           map[string]struct{}{},
+		prefixes:       map[string]struct{}{},
 
 		mirrorTimeout:    cfg.MirrorTimeout,
 		mirroringEnabled: atomic.NewBool(cfg.MirrorEnabled),
@@ -99,6 +124,7 @@ func NewMultiClient(cfg MultiConfig, clients []kvclient, logger log.Logger, regi
 	}
 
 	ctx, cancelFn := context.WithCancel(context.Background())
+	c.ctx = ctx
 	c.cancel = cancelFn
 
 	if cfg.ConfigProvider != nil {
@@ -108,6 +134,11 @@ func NewMultiClient(cfg MultiConfig, clients []kvclient, logger log.Logger, regi
 	c.registerMetrics(registerer)
 	c.updatePrimaryStoreGauge()
 	c.updateMirrorEnabledGauge()
+	c.updateMigrationPhaseGauge()
+
+	if cfg.Registry != nil {
+		cfg.Registry.register(c)
+	}
 	return c
 }
 
@@ -119,13 +150,15 @@ func (m *MultiClient) watchConfigChannel(ctx context.Context, configChannel <-ch
 				return
 			}
 
-			if cfg.Mirroring != nil {
-				enabled := *cfg.Mirroring
-				old := m.mirroringEnabled.Swap(enabled)
-				if old != enabled {
-					level.Info(m.logger).Log("msg", "toggled mirroring", "enabled", enabled)
+			if cfg.MigrationPhase != "" {
+				if err := m.setMigrationPhase(cfg.MigrationPhase); err != nil {
+					level.Error(m.logger).Log("msg", "failed to set KV store migration phase", "phase", cfg.MigrationPhase, "err", err)
 				}
-				m.updateMirrorEnabledGauge()
+				continue
+			}
+
+			if cfg.Mirroring != nil {
+				m.setMirroring(*cfg.Mirroring)
 			}
 
 			if cfg.PrimaryStore != "" {
@@ -144,6 +177,14 @@ func (m *MultiClient) watchConfigChannel(ctx context.Context, configChannel <-ch
 	}
 }
 
+func (m *MultiClient) setMirroring(enabled bool) {
+	old := m.mirroringEnabled.Swap(enabled)
+	if old != enabled {
+		level.Info(m.logger).Log("msg", "toggled mirroring", "enabled", enabled)
+	}
+	m.updateMirrorEnabledGauge()
+}
+
 func (m *MultiClient) getPrimaryClient() (int, kvclient) {
 	v := m.primaryID.Load()
 	return int(v), m.clients[v]
@@ -202,6 +243,21 @@ func (m *MultiClient) registerMetrics(registerer prometheus.Registerer) {
 		Name: "multikv_mirror_write_errors_total",
 		Help: "Number of failures to mirror-write to secondary store",
 	})
+
+	m.migrationPhaseGauge = promauto.With(registerer).NewGaugeVec(prometheus.GaugeOpts{
+		Name: "multikv_migration_phase",
+		Help: "Current phase of the migration between the KV stores",
+	}, []string{"phase"})
+
+	m.migrationMissingKeys = promauto.With(registerer).NewGauge(prometheus.GaugeOpts{
+		Name: "multikv_migration_missing_keys",
+		Help: "Number of keys of the primary store missing in the secondary store at the last verification",
+	})
+
+	m.migrationBackfilledKeys = promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+		Name: "multikv_migration_backfilled_keys_total",
+		Help: "Number of keys copied from the primary store to the secondary store when backfilling it",
+	})
 }
 
 func (m *MultiClient) updatePrimaryStoreGauge() {
@@ -285,12 +341,14 @@ func (m *MultiClient) runWithPrimaryClient(origCtx context.Context, fn func(newC
 
 // List is a part of the kv.Client interface.
 func (m *MultiClient) List(ctx context.Context, prefix string) ([]string, error) {
+	m.trackPrefix(prefix)
 	_, kv := m.getPrimaryClient()
 	return kv.client.List(ctx, prefix)
 }
 
 // Get is a part of kv.Client interface.
 func (m *MultiClient) Get(ctx context.Context, key string) (interface{}, error) {
+	m.trackKey(key)
 	_, kv := m.getPrimaryClient()
 	return kv.client.Get(ctx, key)
 }
@@ -303,6 +361,7 @@ func (m *MultiClient) Delete(ctx context.Context, key string) error {
 
 // CAS is a part of kv.Client interface.
 func (m *MultiClient) CAS(ctx context.Context, key string, f func(in interface{}) (out interface{}, retry bool, err error)) error {
+	m.trackKey(key)
 	_, kv := m.getPrimaryClient()
 
 	updatedValue := interface{}(nil)
@@ -321,6 +380,7 @@ func (m *MultiClient) CAS(ctx context.Context, key string, f func(in interface{}
 
 // WatchKey is a part of kv.Client interface.
 func (m *MultiClient) WatchKey(ctx context.Context, key string, f func(interface{}) bool) {
+	m.trackKey(key)
 	_ = m.runWithPrimaryClient(ctx, func(newCtx context.Context, primary kvclient) error {
 		primary.client.WatchKey(newCtx, key, f)
 		return newCtx.Err()
@@ -329,6 +389,7 @@ func (m *MultiClient) WatchKey(ctx context.Context, key string, f func(interface
 
 // WatchPrefix is a part of kv.Client interface.
 func (m *MultiClient) WatchPrefix(ctx context.Context, prefix string, f func(string, interface{}) bool) {
+	m.trackPrefix(prefix)
 	_ = m.runWithPrimaryClient(ctx, func(newCtx context.Context, primary kvclient) error {
 		primary.client.WatchPrefix(newCtx, prefix, f)
 		return newCtx.Err()
@@ -0,0 +1,326 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+)
+
+const (
+	// MigrationPhaseMirror keeps the primary store, and mirrors the writes to the secondary store. The secondary store
+	// is backfilled with the values of the primary store when entering the phase.
+	MigrationPhaseMirror = "mirror"
+
+	// MigrationPhaseSwitch switches the primary store to the secondary store, and mirrors the writes to the former
+	// primary store, so that the migration can still be rolled back to the mirror phase.
+	MigrationPhaseSwitch = "switch"
+
+	// MigrationPhaseComplete uses the secondary store as primary store, and stops mirroring the writes.
+	MigrationPhaseComplete = "complete"
+)
+
+var migrationPhases = []string{MigrationPhaseMirror, MigrationPhaseSwitch, MigrationPhaseComplete}
+
+// MigrationVerification is the result of the verification of the keys of the primary store in the secondary store.
+type MigrationVerification struct {
+	VerifiedAt  time.Time `json:"verified_at"`
+	Primary     string    `json:"primary"`
+	Keys        int       `json:"keys"`
+	MissingKeys []string  `json:"missing_keys"`
+}
+
+func (m *MultiClient) trackKey(key string) {
+	m.keysMu.Lock()
+	defer m.keysMu.Unlock()
+	m.keys[key] = struct{}{}
+}
+
+func (m *MultiClient) trackPrefix(prefix string) {
+	m.keysMu.Lock()
+	defer m.keysMu.Unlock()
+	m.prefixes[prefix] = struct{}{}
+}
+
+// migrationKeys returns the keys used through the client, and the keys of the primary store under the prefixes
+// used through the client.
+func (m *MultiClient) migrationKeys(ctx context.Context, primary kvclient) ([]string, error) {
+	m.keysMu.Lock()
+	keys := make(map[string]struct{}, len(m.keys))
+	for key := range m.keys {
+		keys[key] = struct{}{}
+	}
+	prefixes := make([]string, 0, len(m.prefixes))
+	for prefix := range m.prefixes {
+		prefixes = append(prefixes, prefix)
+	}
+	m.keysMu.Unlock()
+
+	for _, prefix := range prefixes {
+		listed, err := primary.client.List(ctx, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list keys with prefix %q in %s: %w", prefix, primary.name, err)
+		}
+		for _, key := range listed {
+			keys[key] = struct{}{}
+		}
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	return sorted, nil
+}
+
+// setMigrationPhase switches the primary store and the mirroring to the ones of the migration phase. Entering the
+// mirror phase backfills the secondary store in the background, and then verifies it.
+func (m *MultiClient) setMigrationPhase(phase string) error {
+	var (
+		primary   string
+		mirroring bool
+	)
+	switch phase {
+	case MigrationPhaseMirror:
+		primary, mirroring = m.clients[0].name, true
+	case MigrationPhaseSwitch:
+		primary, mirroring = m.clients[1].name, true
+	case MigrationPhaseComplete:
+		primary, mirroring = m.clients[1].name, false
+	default:
+		return fmt.Errorf("unknown migration phase %q, supported phases are %v", phase, migrationPhases)
+	}
+
+	// Mirror before switching the primary store, so that no write is missed by the new secondary store.
+	if mirroring {
+		m.setMirroring(true)
+	}
+	if _, err := m.setNewPrimaryClient(primary); err != nil {
+		return err
+	}
+	if !mirroring {
+		m.setMirroring(false)
+	}
+
+	prev := m.migrationPhase.Swap(phase)
+	m.updateMigrationPhaseGauge()
+	if prev == phase {
+		return nil
+	}
+	level.Info(m.logger).Log("msg", "entered KV store migration phase", "phase", phase, "primary", primary, "mirroring", mirroring)
+
+	if phase == MigrationPhaseMirror {
+		go func() {
+			if _, err := m.backfill(m.ctx); err != nil {
+				level.Warn(m.logger).Log("msg", "failed to backfill the secondary KV store", "err", err)
+				return
+			}
+			if _, err := m.verify(m.ctx); err != nil {
+				level.Warn(m.logger).Log("msg", "failed to verify the secondary KV store", "err", err)
+			}
+		}()
+	}
+	return nil
+}
+
+// backfill copies the values of the keys of the primary store to the secondary store, and returns the number of
+// keys copied. The failures to write to the secondary store are only logged, like the mirrored writes.
+func (m *MultiClient) backfill(ctx context.Context) (int, error) {
+	_, primary := m.getPrimaryClient()
+	keys, err := m.migrationKeys(ctx, primary)
+	if err != nil {
+		return 0, err
+	}
+
+	copied := 0
+	for _, key := range keys {
+		value, err := primary.client.Get(ctx, key)
+		if err != nil {
+			return copied, fmt.Errorf("failed to get key %q from %s: %w", key, primary.name, err)
+		}
+		if value == nil {
+			continue
+		}
+		m.writeToSecondary(ctx, primary, key, value)
+		m.migrationBackfilledKeys.Inc()
+		copied++
+	}
+
+	level.Info(m.logger).Log("msg", "backfilled the secondary KV store", "primary", primary.name, "keys", copied)
+	return copied, nil
+}
+
+// verify checks that all the keys of the primary store exist in the secondary store. The values aren't compared,
+// since the mirrored writes keep updating them concurrently.
+func (m *MultiClient) verify(ctx context.Context) (MigrationVerification, error) {
+	_, primary := m.getPrimaryClient()
+	keys, err := m.migrationKeys(ctx, primary)
+	if err != nil {
+		return MigrationVerification{}, err
+	}
+
+	result := MigrationVerification{Primary: primary.name, MissingKeys: []string{}}
+	for _, key := range keys {
+		value, err := primary.client.Get(ctx, key)
+		if err != nil {
+			return MigrationVerification{}, fmt.Errorf("failed to get key %q from %s: %w", key, primary.name, err)
+		}
+		if value == nil {
+			continue
+		}
+		result.Keys++
+
+		for _, kvc := range m.clients {
+			if kvc == primary {
+				continue
+			}
+			value, err := kvc.client.Get(ctx, key)
+			if err != nil {
+				return MigrationVerification{}, fmt.Errorf("failed to get key %q from %s: %w", key, kvc.name, err)
+			}
+			if value == nil {
+				result.MissingKeys = append(result.MissingKeys, key)
+			}
+		}
+	}
+	result.VerifiedAt = time.Now()
+
+	m.verificationMu.Lock()
+	m.lastVerification = &result
+	m.verificationMu.Unlock()
+	m.migrationMissingKeys.Set(float64(len(result.MissingKeys)))
+
+	level.Info(m.logger).Log("msg", "verified the secondary KV store", "primary", primary.name, "keys", result.Keys, "missing_keys", len(result.MissingKeys))
+	return result, nil
+}
+
+func (m *MultiClient) updateMigrationPhaseGauge() {
+	current := m.migrationPhase.Load()
+	for _, phase := range migrationPhases {
+		value := float64(0)
+		if phase == current {
+			value = 1
+		}
+		m.migrationPhaseGauge.WithLabelValues(phase).Set(value)
+	}
+}
+
+// MultiClientStatus is the migration status of a MultiClient.
+type MultiClientStatus struct {
+	Primary          string                 `json:"primary"`
+	Stores           []string               `json:"stores"`
+	Mirroring        bool                   `json:"mirroring"`
+	MigrationPhase   string                 `json:"migration_phase"`
+	Keys             []string               `json:"keys"`
+	Prefixes         []string               `json:"prefixes"`
+	LastVerification *MigrationVerification `json:"last_verification,omitempty"`
+}
+
+func (m *MultiClient) status() MultiClientStatus {
+	_, primary := m.getPrimaryClient()
+	status := MultiClientStatus{
+		Primary:        primary.name,
+		Mirroring:      m.mirroringEnabled.Load(),
+		MigrationPhase: m.migrationPhase.Load(),
+		Keys:           []string{},
+		Prefixes:       []string{},
+	}
+	for _, kvc := range m.clients {
+		status.Stores = append(status.Stores, kvc.name)
+	}
+
+	m.keysMu.Lock()
+	for key := range m.keys {
+		status.Keys = append(status.Keys, key)
+	}
+	for prefix := range m.prefixes {
+		status.Prefixes = append(status.Prefixes, prefix)
+	}
+	m.keysMu.Unlock()
+	sort.Strings(status.Keys)
+	sort.Strings(status.Prefixes)
+
+	m.verificationMu.Lock()
+	status.LastVerification = m.lastVerification
+	m.verificationMu.Unlock()
+	return status
+}
+
+// MultiClients tracks the MultiClients of the process, and serves the admin endpoint to drive their migration. The
+// migration phase set via the endpoint only applies to this process, until overridden by the runtime configuration.
+type MultiClients struct {
+	mtx     sync.Mutex
+	clients []*MultiClient
+}
+
+func NewMultiClients() *MultiClients {
+	return &MultiClients{}
+}
+
+func (r *MultiClients) register(c *MultiClient) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.clients = append(r.clients, c)
+}
+
+func (r *MultiClients) list() []*MultiClient {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return append([]*MultiClient(nil), r.clients...)
+}
+
+// ServeHTTP returns the migration status of the MultiClients. The POST requests first set the migration phase with
+// the "phase" parameter, and then backfill or verify the secondary stores with the "action" parameter.
+func (r *MultiClients) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodPost {
+		status, err := r.handlePost(req)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+	}
+
+	var statuses []MultiClientStatus
+	for _, c := range r.list() {
+		statuses = append(statuses, c.status())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(statuses); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (r *MultiClients) handlePost(req *http.Request) (int, error) {
+	phase := req.FormValue("phase")
+	action := req.FormValue("action")
+	if action != "" && action != "backfill" && action != "verify" {
+		return http.StatusBadRequest, fmt.Errorf("unknown action %q, supported actions are backfill and verify", action)
+	}
+
+	for _, c := range r.list() {
+		if phase != "" {
+			if err := c.setMigrationPhase(phase); err != nil {
+				return http.StatusBadRequest, err
+			}
+		}
+
+		var err error
+		switch action {
+		case "backfill":
+			_, err = c.backfill(req.Context())
+		case "verify":
+			_, err = c.verify(req.Context())
+		}
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+	return http.StatusOK, nil
+}
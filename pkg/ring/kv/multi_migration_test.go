@@ -0,0 +1,139 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
+	"github.com/cortexproject/cortex/pkg/ring/kv/consul"
+)
+
+func newTestMultiClient(t *testing.T, registry *MultiClients) (*MultiClient, Client, Client) {
+	primary, primaryCloser := consul.NewInMemoryClient(codec.String{}, log.NewNopLogger(), nil)
+	t.Cleanup(func() { _ = primaryCloser.Close() })
+	secondary, secondaryCloser := consul.NewInMemoryClient(codec.String{}, log.NewNopLogger(), nil)
+	t.Cleanup(func() { _ = secondaryCloser.Close() })
+
+	cfg := MultiConfig{MirrorTimeout: time.Second, Registry: registry}
+	clients := []kvclient{{client: primary, name: "consul"}, {client: secondary, name: "memberlist"}}
+	return NewMultiClient(cfg, clients, log.NewNopLogger(), prometheus.NewPedanticRegistry()), primary, secondary
+}
+
+func casValue(t *testing.T, c Client, key, value string) {
+	require.NoError(t, c.CAS(context.Background(), key, func(interface{}) (interface{}, bool, error) {
+		return value, false, nil
+	}))
+}
+
+func TestMultiClient_MigrationPhases(t *testing.T) {
+	ctx := context.Background()
+	m, primary, secondary := newTestMultiClient(t, nil)
+
+	// Written before the migration, so it's only in the primary store.
+	casValue(t, m, "collectors/ring", "v1")
+	v, err := secondary.Get(ctx, "collectors/ring")
+	require.NoError(t, err)
+	assert.Nil(t, v)
+
+	// The mirror phase backfills the secondary store.
+	require.NoError(t, m.setMigrationPhase(MigrationPhaseMirror))
+	assert.True(t, m.mirroringEnabled.Load())
+	assert.Eventually(t, func() bool {
+		v, err := secondary.Get(ctx, "collectors/ring")
+		return err == nil && v == "v1"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	verification, err := m.verify(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, verification.Keys)
+	assert.Empty(t, verification.MissingKeys)
+
+	// The switch phase reads from the secondary store, and still mirrors the writes to the former primary store.
+	require.NoError(t, m.setMigrationPhase(MigrationPhaseSwitch))
+	casValue(t, m, "collectors/ring", "v2")
+	v, err = m.Get(ctx, "collectors/ring")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", v)
+	v, err = primary.Get(ctx, "collectors/ring")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", v)
+
+	// The complete phase stops mirroring the writes.
+	require.NoError(t, m.setMigrationPhase(MigrationPhaseComplete))
+	assert.False(t, m.mirroringEnabled.Load())
+	casValue(t, m, "collectors/ring", "v3")
+	v, err = primary.Get(ctx, "collectors/ring")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", v)
+
+	assert.Error(t, m.setMigrationPhase("unknown"))
+	assert.Equal(t, MigrationPhaseComplete, m.migrationPhase.Load())
+}
+
+func TestMultiClient_VerifyShouldReportTheMissingKeys(t *testing.T) {
+	ctx := context.Background()
+	m, primary, _ := newTestMultiClient(t, nil)
+
+	casValue(t, primary, "collectors/ring", "v1")
+	casValue(t, primary, "collectors/compactor", "v1")
+
+	// Only the keys used through the client, or under the prefixes used through the client, are verified.
+	_, err := m.Get(ctx, "collectors/ring")
+	require.NoError(t, err)
+
+	verification, err := m.verify(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, verification.Keys)
+	assert.Equal(t, []string{"collectors/ring"}, verification.MissingKeys)
+
+	_, err = m.List(ctx, "collectors/")
+	require.NoError(t, err)
+	copied, err := m.backfill(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, copied)
+
+	verification, err = m.verify(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, verification.Keys)
+	assert.Empty(t, verification.MissingKeys)
+}
+
+func TestMultiClients_ServeHTTP(t *testing.T) {
+	registry := NewMultiClients()
+	m, _, secondary := newTestMultiClient(t, registry)
+	casValue(t, m, "collectors/ring", "v1")
+
+	req := httptest.NewRequest(http.MethodPost, "/multikv", strings.NewReader("phase=mirror&action=backfill"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	registry.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var statuses []MultiClientStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &statuses))
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "consul", statuses[0].Primary)
+	assert.Equal(t, []string{"consul", "memberlist"}, statuses[0].Stores)
+	assert.True(t, statuses[0].Mirroring)
+	assert.Equal(t, MigrationPhaseMirror, statuses[0].MigrationPhase)
+	assert.Equal(t, []string{"collectors/ring"}, statuses[0].Keys)
+
+	v, err := secondary.Get(context.Background(), "collectors/ring")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", v)
+
+	req = httptest.NewRequest(http.MethodPost, "/multikv?action=unknown", nil)
+	rec = httptest.NewRecorder()
+	registry.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,275 @@
+package s3
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
+	"github.com/cortexproject/cortex/pkg/util/backoff"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+const (
+	maxCasRetries = 10 // max retries in CAS operation
+)
+
+// Config to create a S3 KV client.
+type Config struct {
+	Endpoint        string         `yaml:"endpoint"`
+	BucketName      string         `yaml:"bucket_name"`
+	Region          string         `yaml:"region"`
+	AccessKeyID     string         `yaml:"access_key_id"`
+	SecretAccessKey flagext.Secret `yaml:"secret_access_key"`
+	Insecure        bool           `yaml:"insecure"`
+	PullerSyncTime  time.Duration  `yaml:"puller_sync_time"`
+	MaxCasRetries   int            `yaml:"max_cas_retries"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet
+// If prefix is not an empty string it should end with a period.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet, prefix string) {
+	f.StringVar(&cfg.Endpoint, prefix+"s3.endpoint", "", "EXPERIMENTAL: The S3 endpoint of the bucket storing the keys. The S3 implementation must support the conditional writes, like AWS S3 and MinIO.")
+	f.StringVar(&cfg.BucketName, prefix+"s3.bucket-name", "", "EXPERIMENTAL: The S3 bucket storing the keys.")
+	f.StringVar(&cfg.Region, prefix+"s3.region", "", "EXPERIMENTAL: The S3 region. If unset, the client will issue a S3 GetBucketLocation API call to autodetect it.")
+	f.StringVar(&cfg.AccessKeyID, prefix+"s3.access-key-id", "", "EXPERIMENTAL: The S3 access key ID. If unset, the credentials of the environment are used.")
+	f.Var(&cfg.SecretAccessKey, prefix+"s3.secret-access-key", "EXPERIMENTAL: The S3 secret access key.")
+	f.BoolVar(&cfg.Insecure, prefix+"s3.insecure", false, "EXPERIMENTAL: If enabled, use http:// for the S3 endpoint instead of https://.")
+	f.DurationVar(&cfg.PullerSyncTime, prefix+"s3.puller-sync-time", 5*time.Second, "EXPERIMENTAL: Time to refresh the watched keys with the information on S3.")
+	f.IntVar(&cfg.MaxCasRetries, prefix+"s3.max-cas-retries", maxCasRetries, "EXPERIMENTAL: Maximum number of retries for S3 KV CAS.")
+}
+
+// Client is a KV client storing each key in an object of a S3 bucket. The CAS operations are implemented with
+// conditional writes, and the watches by polling the objects.
+type Client struct {
+	store          objectStore
+	codec          codec.Codec
+	logger         log.Logger
+	pullerSyncTime time.Duration
+	backoffConfig  backoff.Config
+
+	lastUpdateLock sync.RWMutex
+	lastUpdate     map[string]time.Time
+}
+
+// NewClient makes a new S3 KV client.
+func NewClient(cfg Config, cc codec.Codec, logger log.Logger) (*Client, error) {
+	store, err := newS3ObjectStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	c := newClient(store, cc, logger, cfg.PullerSyncTime, backoff.Config{
+		MinBackoff: 100 * time.Millisecond,
+		MaxBackoff: cfg.PullerSyncTime,
+		MaxRetries: cfg.MaxCasRetries,
+	})
+	level.Info(c.logger).Log("msg", "s3 kv initialized", "bucket", cfg.BucketName)
+	return c, nil
+}
+
+func newClient(store objectStore, cc codec.Codec, logger log.Logger, pullerSyncTime time.Duration, backoffConfig backoff.Config) *Client {
+	return &Client{
+		store:          store,
+		codec:          cc,
+		logger:         log.WithPrefix(logger, "class", "S3KvClient"),
+		pullerSyncTime: pullerSyncTime,
+		backoffConfig:  backoffConfig,
+		lastUpdate:     map[string]time.Time{},
+	}
+}
+
+// List implements kv.Client.
+func (c *Client) List(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := c.store.List(ctx, prefix)
+	if err != nil {
+		level.Warn(c.logger).Log("msg", "error List", "prefix", prefix, "err", err)
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Get implements kv.Client.
+func (c *Client) Get(ctx context.Context, key string) (interface{}, error) {
+	out, _, err := c.get(ctx, key)
+	if err != nil {
+		level.Warn(c.logger).Log("msg", "error Get", "key", key, "err", err)
+		return nil, err
+	}
+	return out, nil
+}
+
+// Delete implements kv.Client.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	if err := c.store.Delete(ctx, key); err != nil {
+		level.Warn(c.logger).Log("msg", "error Delete", "key", key, "err", err)
+		return err
+	}
+
+	c.lastUpdateLock.Lock()
+	delete(c.lastUpdate, key)
+	c.lastUpdateLock.Unlock()
+	return nil
+}
+
+// CAS implements kv.Client.
+func (c *Client) CAS(ctx context.Context, key string, f func(in interface{}) (out interface{}, retry bool, err error)) error {
+	bo := backoff.New(ctx, c.backoffConfig)
+	for bo.Ongoing() {
+		in, version, err := c.get(ctx, key)
+		if err != nil {
+			level.Error(c.logger).Log("msg", "error getting key", "key", key, "err", err)
+			bo.Wait()
+			continue
+		}
+
+		out, retry, err := f(in)
+		if err != nil {
+			if !retry {
+				return err
+			}
+			bo.Wait()
+			continue
+		}
+
+		// Callback returning nil means it doesn't want to CAS anymore.
+		if out == nil {
+			return nil
+		}
+
+		data, err := c.codec.Encode(out)
+		if err != nil {
+			level.Error(c.logger).Log("msg", "error serialising value", "key", key, "err", err)
+			return err
+		}
+
+		err = c.store.Put(ctx, key, data, version)
+		if err == errConditionFailed {
+			level.Debug(c.logger).Log("msg", "conflicting CAS, retrying", "key", key)
+			bo.Wait()
+			continue
+		}
+		if err != nil {
+			level.Error(c.logger).Log("msg", "error CASing", "key", key, "err", err)
+			bo.Wait()
+			continue
+		}
+
+		c.updateLastUpdate(key)
+		return nil
+	}
+
+	level.Error(c.logger).Log("msg", "failed to CAS after retries", "key", key)
+	return fmt.Errorf("failed to CAS %s", key)
+}
+
+// WatchKey implements kv.Client. The callback is only called when the value of the key changes.
+func (c *Client) WatchKey(ctx context.Context, key string, f func(interface{}) bool) {
+	bo := backoff.New(ctx, c.backoffConfig)
+	lastVersion := ""
+
+	for bo.Ongoing() {
+		out, version, err := c.get(ctx, key)
+		if err != nil {
+			level.Error(c.logger).Log("msg", "error WatchKey", "key", key, "err", err)
+			bo.Wait()
+			continue
+		}
+
+		if version != lastVersion {
+			lastVersion = version
+			if !f(out) {
+				return
+			}
+		}
+
+		bo.Reset()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.pullerSyncTime):
+		}
+	}
+}
+
+// WatchPrefix implements kv.Client. The callback is only called for the keys whose value changes.
+func (c *Client) WatchPrefix(ctx context.Context, prefix string, f func(string, interface{}) bool) {
+	bo := backoff.New(ctx, c.backoffConfig)
+	lastVersions := map[string]string{}
+
+	for bo.Ongoing() {
+		keys, err := c.store.List(ctx, prefix)
+		if err != nil {
+			level.Error(c.logger).Log("msg", "error WatchPrefix", "prefix", prefix, "err", err)
+			bo.Wait()
+			continue
+		}
+
+		versions := make(map[string]string, len(keys))
+		for _, key := range keys {
+			out, version, err := c.get(ctx, key)
+			if err != nil {
+				level.Error(c.logger).Log("msg", "error WatchPrefix", "key", key, "err", err)
+				continue
+			}
+			// The key has been deleted since it has been listed.
+			if out == nil {
+				continue
+			}
+
+			versions[key] = version
+			if version == lastVersions[key] {
+				continue
+			}
+			if !f(key, out) {
+				return
+			}
+		}
+		lastVersions = versions
+
+		bo.Reset()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.pullerSyncTime):
+		}
+	}
+}
+
+// LastUpdateTime implements kv.Client.
+func (c *Client) LastUpdateTime(key string) time.Time {
+	c.lastUpdateLock.RLock()
+	defer c.lastUpdateLock.RUnlock()
+
+	return c.lastUpdate[key]
+}
+
+// get returns the decoded value of the key and its version, or nil if the key doesn't exist.
+func (c *Client) get(ctx context.Context, key string) (interface{}, string, error) {
+	data, version, err := c.store.Get(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+	c.updateLastUpdate(key)
+	if data == nil {
+		return nil, "", nil
+	}
+
+	out, err := c.codec.Decode(data)
+	if err != nil {
+		return nil, "", err
+	}
+	return out, version, nil
+}
+
+func (c *Client) updateLastUpdate(key string) {
+	c.lastUpdateLock.Lock()
+	defer c.lastUpdateLock.Unlock()
+
+	c.lastUpdate[key] = time.Now().UTC()
+}
@@ -0,0 +1,237 @@
+package s3
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
+	"github.com/cortexproject/cortex/pkg/util/backoff"
+)
+
+// mockObjectStore is an in-memory object store with the conditional writes semantics of S3.
+type mockObjectStore struct {
+	mtx      sync.Mutex
+	objects  map[string][]byte
+	versions map[string]string
+	next     int
+
+	// Called before each conditional write, to simulate concurrent writes.
+	beforePut func(key string)
+}
+
+func newMockObjectStore() *mockObjectStore {
+	return &mockObjectStore{objects: map[string][]byte{}, versions: map[string]string{}}
+}
+
+func (m *mockObjectStore) Get(_ context.Context, key string) ([]byte, string, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.objects[key], m.versions[key], nil
+}
+
+func (m *mockObjectStore) Put(_ context.Context, key string, data []byte, version string) error {
+	if m.beforePut != nil {
+		m.beforePut(key)
+	}
+	return m.put(key, data, version)
+}
+
+func (m *mockObjectStore) put(key string, data []byte, version string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if m.versions[key] != version {
+		return errConditionFailed
+	}
+	m.next++
+	m.objects[key] = data
+	m.versions[key] = strconv.Itoa(m.next)
+	return nil
+}
+
+func (m *mockObjectStore) Delete(_ context.Context, key string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	delete(m.objects, key)
+	delete(m.versions, key)
+	return nil
+}
+
+func (m *mockObjectStore) List(_ context.Context, prefix string) ([]string, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	var keys []string
+	for key := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func newTestClient(store objectStore) *Client {
+	return newClient(store, codec.String{}, log.NewNopLogger(), 10*time.Millisecond, backoff.Config{
+		MinBackoff: time.Millisecond,
+		MaxBackoff: time.Millisecond,
+		MaxRetries: 5,
+	})
+}
+
+func TestClient_CAS(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("creates and updates the key", func(t *testing.T) {
+		c := newTestClient(newMockObjectStore())
+
+		for _, expected := range []string{"a", "b"} {
+			require.NoError(t, c.CAS(ctx, "key", func(in interface{}) (interface{}, bool, error) {
+				return expected, true, nil
+			}))
+			out, err := c.Get(ctx, "key")
+			require.NoError(t, err)
+			assert.Equal(t, expected, out)
+		}
+		assert.False(t, c.LastUpdateTime("key").IsZero())
+	})
+
+	t.Run("retries on concurrent writes", func(t *testing.T) {
+		store := newMockObjectStore()
+		c := newTestClient(store)
+		require.NoError(t, c.CAS(ctx, "key", func(interface{}) (interface{}, bool, error) {
+			return "0", true, nil
+		}))
+
+		// Another client updates the key right before the first CAS attempt.
+		concurrentWrites := 0
+		store.beforePut = func(key string) {
+			if concurrentWrites == 0 {
+				concurrentWrites++
+				_, version, _ := store.Get(ctx, key)
+				require.NoError(t, store.put(key, []byte("concurrent"), version))
+			}
+		}
+
+		var inputs []interface{}
+		require.NoError(t, c.CAS(ctx, "key", func(in interface{}) (interface{}, bool, error) {
+			inputs = append(inputs, in)
+			return in.(string) + "+1", true, nil
+		}))
+		assert.Equal(t, []interface{}{"0", "concurrent"}, inputs)
+
+		out, err := c.Get(ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, "concurrent+1", out)
+	})
+
+	t.Run("fails after too many concurrent writes", func(t *testing.T) {
+		store := newMockObjectStore()
+		c := newTestClient(store)
+		store.beforePut = func(key string) {
+			_, version, _ := store.Get(ctx, key)
+			require.NoError(t, store.put(key, []byte("concurrent"), version))
+		}
+
+		require.Error(t, c.CAS(ctx, "key", func(interface{}) (interface{}, bool, error) {
+			return "value", true, nil
+		}))
+	})
+
+	t.Run("doesn't write if the callback returns nil", func(t *testing.T) {
+		store := newMockObjectStore()
+		c := newTestClient(store)
+		require.NoError(t, c.CAS(ctx, "key", func(interface{}) (interface{}, bool, error) {
+			return nil, true, nil
+		}))
+		assert.Empty(t, store.objects)
+	})
+}
+
+func TestClient_DeleteAndList(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(newMockObjectStore())
+
+	for _, key := range []string{"prefix/a", "prefix/b", "other"} {
+		require.NoError(t, c.CAS(ctx, key, func(interface{}) (interface{}, bool, error) {
+			return key, true, nil
+		}))
+	}
+
+	keys, err := c.List(ctx, "prefix/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prefix/a", "prefix/b"}, keys)
+
+	require.NoError(t, c.Delete(ctx, "prefix/a"))
+	require.NoError(t, c.Delete(ctx, "missing"))
+	out, err := c.Get(ctx, "prefix/a")
+	require.NoError(t, err)
+	assert.Nil(t, out)
+}
+
+func TestClient_WatchKey(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	store := newMockObjectStore()
+	c := newTestClient(store)
+	require.NoError(t, store.put("key", []byte("0"), ""))
+
+	// The callback is only called when the value changes.
+	var values []interface{}
+	c.WatchKey(ctx, "key", func(in interface{}) bool {
+		values = append(values, in)
+		if len(values) == 3 {
+			return false
+		}
+		next := strconv.Itoa(len(values))
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			_, version, _ := store.Get(ctx, "key")
+			assert.NoError(t, store.put("key", []byte(next), version))
+		}()
+		return true
+	})
+	assert.Equal(t, []interface{}{"0", "1", "2"}, values)
+}
+
+func TestClient_WatchPrefix(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	store := newMockObjectStore()
+	c := newTestClient(store)
+	require.NoError(t, store.put("prefix/a", []byte("a"), ""))
+	require.NoError(t, store.put("other", []byte("other"), ""))
+
+	var updates []string
+	c.WatchPrefix(ctx, "prefix/", func(key string, in interface{}) bool {
+		updates = append(updates, key+"="+in.(string))
+		if len(updates) == 2 {
+			return false
+		}
+		require.NoError(t, store.put("prefix/b", []byte("b"), ""))
+		return true
+	})
+	assert.Equal(t, []string{"prefix/a=a", "prefix/b=b"}, updates)
+}
+
+func TestConditionalWrite(t *testing.T) {
+	h := http.Header{}
+	(&conditionalWrite{}).Marshal(h)
+	assert.Equal(t, "*", h.Get("If-None-Match"))
+	assert.Empty(t, h.Get("If-Match"))
+
+	h = http.Header{}
+	(&conditionalWrite{version: "abc"}).Marshal(h)
+	assert.Equal(t, `"abc"`, h.Get("If-Match"))
+	assert.Empty(t, h.Get("If-None-Match"))
+}
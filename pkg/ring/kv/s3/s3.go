@@ -0,0 +1,156 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	"github.com/pkg/errors"
+)
+
+// errConditionFailed is the error returned when a conditional write fails, because the object has been
+// created, updated or deleted since it has been read.
+var errConditionFailed = errors.New("the object has been modified concurrently")
+
+// objectStore is the subset of the S3 API used by the KV client. The version of an object is its ETag.
+type objectStore interface {
+	// Get returns the content and the version of the object, or a nil content if the object doesn't exist.
+	Get(ctx context.Context, key string) ([]byte, string, error)
+
+	// Put writes the object only if its current version is the given one, or only if it doesn't exist when the
+	// version is empty. It returns errConditionFailed if the condition is not met.
+	Put(ctx context.Context, key string, data []byte, version string) error
+
+	// Delete deletes the object. No error is returned if the object doesn't exist.
+	Delete(ctx context.Context, key string) error
+
+	// List returns the keys of the objects with the given prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+type s3ObjectStore struct {
+	client     *minio.Client
+	bucketName string
+}
+
+func newS3ObjectStore(cfg Config) (*s3ObjectStore, error) {
+	if cfg.Endpoint == "" || cfg.BucketName == "" {
+		return nil, errors.New("the S3 KV store endpoint and bucket name are required")
+	}
+
+	var creds *credentials.Credentials
+	if cfg.AccessKeyID != "" {
+		creds = credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey.Value, "")
+	} else {
+		// Fallback to the credentials of the environment, eg. the IAM role of the instance.
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.FileAWSCredentials{},
+			&credentials.IAM{Client: &http.Client{Transport: http.DefaultTransport}},
+		})
+	}
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: !cfg.Insecure,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "create S3 client")
+	}
+
+	return &s3ObjectStore{client: client, bucketName: cfg.BucketName}, nil
+}
+
+func (s *s3ObjectStore) Get(ctx context.Context, key string) ([]byte, string, error) {
+	obj, err := s.client.GetObject(ctx, s.bucketName, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, "", err
+	}
+	defer obj.Close()
+
+	data, err := io.ReadAll(obj)
+	if isNotFound(err) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	// The object info has been fetched with the content, so the version matches it.
+	info, err := obj.Stat()
+	if err != nil {
+		return nil, "", err
+	}
+	return data, info.ETag, nil
+}
+
+func (s *s3ObjectStore) Put(ctx context.Context, key string, data []byte, version string) error {
+	_, err := s.client.PutObject(ctx, s.bucketName, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		// The S3 client doesn't support setting the conditional headers of the uploads, so they are set by
+		// the SSE config, whose headers are added as is to the request.
+		ServerSideEncryption: &conditionalWrite{version: version},
+		DisableMultipart:     true,
+	})
+	if isConditionFailed(err) {
+		return errConditionFailed
+	}
+	return err
+}
+
+func (s *s3ObjectStore) Delete(ctx context.Context, key string) error {
+	err := s.client.RemoveObject(ctx, s.bucketName, key, minio.RemoveObjectOptions{})
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *s3ObjectStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for obj := range s.client.ListObjects(ctx, s.bucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		keys = append(keys, obj.Key)
+	}
+	return keys, nil
+}
+
+// conditionalWrite is a SSE config adding the conditional write headers of an upload, so that the object is
+// written only if it hasn't changed since the given version was read.
+type conditionalWrite struct {
+	version string
+}
+
+// Type implements encrypt.ServerSide.
+func (c *conditionalWrite) Type() encrypt.Type {
+	return ""
+}
+
+// Marshal implements encrypt.ServerSide.
+func (c *conditionalWrite) Marshal(h http.Header) {
+	if c.version == "" {
+		h.Set("If-None-Match", "*")
+		return
+	}
+	h.Set("If-Match", "\""+c.version+"\"")
+}
+
+func isNotFound(err error) bool {
+	return err != nil && minio.ToErrorResponse(err).Code == "NoSuchKey"
+}
+
+// isConditionFailed returns whether the error is returned by S3 for a conditional write whose condition isn't
+// met, or which conflicts with a concurrent conditional write of the same object.
+func isConditionFailed(err error) bool {
+	if err == nil {
+		return false
+	}
+	status := minio.ToErrorResponse(err).StatusCode
+	return status == http.StatusPreconditionFailed || status == http.StatusConflict
+}
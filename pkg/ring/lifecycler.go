@@ -8,7 +8,6 @@ import (
 	"os"
 	"slices"
 	"sort"
-	"strings"
 	"sync"
 	"time"
 
@@ -34,7 +33,9 @@ type LifecyclerConfig struct {
 	// Config for the ingester lifecycle control
 	NumTokens                int           `yaml:"num_tokens"`
 	TokensGeneratorStrategy  string        `yaml:"tokens_generator_strategy"`
+	TokensStaticFile         string        `yaml:"tokens_static_file"`
 	HeartbeatPeriod          time.Duration `yaml:"heartbeat_period"`
+	CASBatchPeriod           time.Duration `yaml:"cas_batch_period"`
 	ObservePeriod            time.Duration `yaml:"observe_period"`
 	JoinAfter                time.Duration `yaml:"join_after"`
 	MinReadyDuration         time.Duration `yaml:"min_ready_duration"`
@@ -45,6 +46,8 @@ type LifecyclerConfig struct {
 	UnregisterOnShutdown     bool          `yaml:"unregister_on_shutdown"`
 	ReadinessCheckRingHealth bool          `yaml:"readiness_check_ring_health"`
 
+	AutoForget AutoForgetConfig `yaml:",inline"`
+
 	// For testing, you can override the address and ID of this ingester
 	Addr string `yaml:"address" doc:"hidden"`
 	Port int    `doc:"hidden"`
@@ -70,8 +73,10 @@ func (cfg *LifecyclerConfig) RegisterFlagsWithPrefix(prefix string, f *flag.Flag
 	}
 
 	f.IntVar(&cfg.NumTokens, prefix+"num-tokens", 128, "Number of tokens for each ingester.")
-	f.StringVar(&cfg.TokensGeneratorStrategy, prefix+"tokens-generator-strategy", randomTokenStrategy, fmt.Sprintf("EXPERIMENTAL: Algorithm used to generate new ring tokens. Supported Values: %s", strings.Join(supportedTokenStrategy, ",")))
+	f.StringVar(&cfg.TokensGeneratorStrategy, prefix+"tokens-generator-strategy", randomTokenStrategy, TokensGeneratorStrategyFlagDescription)
+	f.StringVar(&cfg.TokensStaticFile, prefix+"tokens-static-file", "", "EXPERIMENTAL: Path of the YAML file mapping the instance IDs to their tokens, used by the static token generator strategy.")
 	f.DurationVar(&cfg.HeartbeatPeriod, prefix+"heartbeat-period", 5*time.Second, "Period at which to heartbeat to consul. 0 = disabled.")
+	f.DurationVar(&cfg.CASBatchPeriod, prefix+"cas-batch-period", 0, "EXPERIMENTAL: Period within which the ring updates of the instance, including the heartbeats, are batched with the ring updates of the other components of the same process into a single KV store transaction. When enabled, the heartbeats are aligned to the heartbeat period, so that the heartbeats of the components with the same heartbeat period, or a multiple of it, are batched together. Supported by the consul, etcd and memberlist KV stores. 0 = disabled.")
 	f.DurationVar(&cfg.JoinAfter, prefix+"join-after", 0*time.Second, "Period to wait for a claim from another member; will join automatically after this.")
 	f.DurationVar(&cfg.ObservePeriod, prefix+"observe-period", 0*time.Second, "Observe tokens after generating to resolve collisions. Useful when using gossiping ring.")
 	f.DurationVar(&cfg.MinReadyDuration, prefix+"min-ready-duration", 15*time.Second, "Minimum duration to wait after the internal readiness checks have passed but before succeeding the readiness endpoint. This is used to slowdown deployment controllers (eg. Kubernetes) after an instance is ready and before they proceed with a rolling update, to give the rest of the cluster instances enough time to receive ring updates.")
@@ -90,15 +95,18 @@ func (cfg *LifecyclerConfig) RegisterFlagsWithPrefix(prefix string, f *flag.Flag
 	f.StringVar(&cfg.ID, prefix+"lifecycler.ID", hostname, "ID to register in the ring.")
 	f.StringVar(&cfg.Zone, prefix+"availability-zone", "", "The availability zone where this instance is running.")
 	f.BoolVar(&cfg.UnregisterOnShutdown, prefix+"unregister-on-shutdown", true, "Unregister from the ring upon clean shutdown. It can be useful to disable for rolling restarts with consistent naming in conjunction with -distributor.extend-writes=false.")
+	cfg.AutoForget.RegisterFlagsWithPrefix(prefix, f)
 	f.BoolVar(&cfg.ReadinessCheckRingHealth, prefix+"readiness-check-ring-health", true, "When enabled the readiness probe succeeds only after all instances are ACTIVE and healthy in the ring, otherwise only the instance itself is checked. This option should be disabled if in your cluster multiple instances can be rolled out simultaneously, otherwise rolling updates may be slowed down.")
 }
 
 func (cfg *LifecyclerConfig) Validate() error {
-	if cfg.TokensGeneratorStrategy != "" && !slices.Contains(supportedTokenStrategy, strings.ToLower(cfg.TokensGeneratorStrategy)) {
-		return errInvalidTokensGeneratorStrategy
+	if err := cfg.AutoForget.Validate(cfg.RingConfig.HeartbeatTimeout); err != nil {
+		return err
 	}
-
-	return nil
+	if _, ok := instanceOrdinal(cfg.ID); cfg.RingConfig.PartitionsEnabled && !ok {
+		return errPartitionsInstanceIDWithoutOrdinal
+	}
+	return ValidateTokensGenerator(cfg.TokensGeneratorStrategy, cfg.TokensStaticFile)
 }
 
 // Lifecycler is responsible for managing the lifecycle of entries in the ring.
@@ -139,12 +147,14 @@ type Lifecycler struct {
 	readySince time.Time
 
 	// Keeps stats updated at every heartbeat period
-	countersLock          sync.RWMutex
-	healthyInstancesCount int
-	zonesCount            int
-	zones                 []string
+	countersLock           sync.RWMutex
+	healthyInstancesCount  int
+	healthyPartitionsCount int
+	zonesCount             int
+	zones                  []string
 
 	lifecyclerMetrics *LifecyclerMetrics
+	autoForgetter     *autoForgetter
 	logger            log.Logger
 
 	tg TokenGenerator
@@ -165,9 +175,11 @@ func NewLifecycler(
 	}
 	port := GetInstancePort(cfg.Port, cfg.ListenPort)
 	codec := GetCodec()
+	kvCfg := cfg.RingConfig.KVStore
+	kvCfg.CASBatchPeriod = cfg.CASBatchPeriod
 	// Suffix all client names with "-lifecycler" to denote this kv client is used by the lifecycler
 	store, err := kv.NewClient(
-		cfg.RingConfig.KVStore,
+		kvCfg,
 		codec,
 		kv.RegistererWithKVName(reg, ringName+"-lifecycler"),
 		logger,
@@ -184,10 +196,9 @@ func NewLifecycler(
 		flushTransferer = NewNoopFlushTransferer()
 	}
 
-	tg := NewRandomTokenGenerator()
-
-	if strings.EqualFold(cfg.TokensGeneratorStrategy, minimizeSpreadTokenStrategy) {
-		tg = NewMinimizeSpreadTokenGenerator()
+	tg, err := NewTokenGenerator(cfg.TokensGeneratorStrategy, cfg.TokensStaticFile)
+	if err != nil {
+		return nil, err
 	}
 
 	l := &Lifecycler{
@@ -206,6 +217,7 @@ func NewLifecycler(
 		autojoinChan:         make(chan struct{}, 1),
 		state:                PENDING,
 		lifecyclerMetrics:    NewLifecyclerMetrics(ringName, reg),
+		autoForgetter:        newAutoForgetter(cfg.AutoForget, cfg.RingConfig.HeartbeatTimeout, ringName, logger, reg),
 		logger:               logger,
 		tg:                   tg,
 	}
@@ -418,6 +430,16 @@ func (i *Lifecycler) HealthyInstancesCount() int {
 	return i.healthyInstancesCount
 }
 
+// HealthyPartitionsCount returns the number of ingestion partitions owned by at least one healthy instance
+// for the Write operation in the ring, updated during the last heartbeat period. It's always 0 if the
+// ingestion partitions are disabled.
+func (i *Lifecycler) HealthyPartitionsCount() int {
+	i.countersLock.RLock()
+	defer i.countersLock.RUnlock()
+
+	return i.healthyPartitionsCount
+}
+
 // ZonesCount returns the number of zones for which there's at least 1 instance registered
 // in the ring.
 func (i *Lifecycler) ZonesCount() int {
@@ -467,7 +489,15 @@ func (i *Lifecycler) loop(ctx context.Context) error {
 		heartbeatTicker.Stop()
 		// We are jittering for at least half of the time and max the time of the heartbeat.
 		// If we jitter too soon, we can have problems of concurrency with autoJoin leaving the instance on ACTIVE without tokens
-		time.AfterFunc(time.Duration(uint64(i.cfg.HeartbeatPeriod/2)+uint64(mathrand.Int63())%uint64(i.cfg.HeartbeatPeriod/2)), func() {
+		delay := time.Duration(uint64(i.cfg.HeartbeatPeriod/2) + uint64(mathrand.Int63())%uint64(i.cfg.HeartbeatPeriod/2))
+		if i.cfg.CASBatchPeriod > 0 {
+			// The heartbeats are aligned instead, so that they are batched with the other lifecyclers' ones.
+			delay = alignedHeartbeatDelay(time.Now(), i.cfg.HeartbeatPeriod)
+			if delay < i.cfg.HeartbeatPeriod/2 {
+				delay += i.cfg.HeartbeatPeriod
+			}
+		}
+		time.AfterFunc(delay, func() {
 			i.heartbeat()
 			heartbeatTicker.Reset(i.cfg.HeartbeatPeriod)
 		})
@@ -562,10 +592,13 @@ func (i *Lifecycler) stopping(runningError error) error {
 	heartbeatTickerStop, heartbeatTickerChan := newDisableableTicker(i.cfg.HeartbeatPeriod)
 	defer heartbeatTickerStop()
 
-	// Mark ourselved as Leaving so no more samples are send to us.
-	err := i.changeState(context.Background(), LEAVING)
-	if err != nil {
-		level.Error(i.logger).Log("msg", "failed to set state to LEAVING", "ring", i.RingName, "err", err)
+	// Mark ourselved as Leaving so no more samples are send to us, unless the downscale has
+	// already been prepared.
+	if i.GetState() != LEAVING {
+		err := i.changeState(context.Background(), LEAVING)
+		if err != nil {
+			level.Error(i.logger).Log("msg", "failed to set state to LEAVING", "ring", i.RingName, "err", err)
+		}
 	}
 
 	// Do the transferring / flushing on a background goroutine so we can continue
@@ -844,6 +877,7 @@ func (i *Lifecycler) updateConsul(ctx context.Context) error {
 			ringDesc.Ingesters[i.ID] = instanceDesc
 		}
 
+		i.autoForgetter.forgetUnhealthyInstances(ringDesc, time.Now())
 		return ringDesc, true, nil
 	})
 
@@ -864,7 +898,8 @@ func (i *Lifecycler) changeState(ctx context.Context, state InstanceState) error
 		(currState == JOINING && state == PENDING) || // triggered by TransferChunks on failure
 		(currState == JOINING && state == ACTIVE) || // triggered by TransferChunks on success
 		(currState == PENDING && state == ACTIVE) || // triggered by autoJoin
-		(currState == ACTIVE && state == LEAVING)) { // triggered by shutdown
+		(currState == ACTIVE && state == LEAVING) || // triggered by shutdown or by the preparation of a downscale
+		(currState == LEAVING && state == ACTIVE)) { // triggered by the cancellation of a downscale
 		return fmt.Errorf("Changing instance state from %v -> %v is disallowed", currState, state)
 	}
 
@@ -876,16 +911,22 @@ func (i *Lifecycler) changeState(ctx context.Context, state InstanceState) error
 func (i *Lifecycler) updateCounters(ringDesc *Desc) {
 	healthyInstancesCount := 0
 	zonesMap := map[string]struct{}{}
+	partitionsMap := map[uint64]struct{}{}
 
 	if ringDesc != nil {
 		lastUpdated := i.KVStore.LastUpdateTime(i.RingKey)
 
-		for _, ingester := range ringDesc.Ingesters {
+		for id, ingester := range ringDesc.Ingesters {
 			zonesMap[ingester.Zone] = struct{}{}
 
 			// Count the number of healthy instances for Write operation.
 			if ingester.IsHealthy(Write, i.cfg.RingConfig.HeartbeatTimeout, lastUpdated) {
 				healthyInstancesCount++
+
+				// Each instance owns the partition matching its ordinal.
+				if ordinal, ok := instanceOrdinal(id); ok && i.cfg.RingConfig.PartitionsEnabled {
+					partitionsMap[ordinal] = struct{}{}
+				}
 			}
 		}
 	}
@@ -900,6 +941,7 @@ func (i *Lifecycler) updateCounters(ringDesc *Desc) {
 	// Update counters
 	i.countersLock.Lock()
 	i.healthyInstancesCount = healthyInstancesCount
+	i.healthyPartitionsCount = len(partitionsMap)
 	i.zonesCount = len(zones)
 	i.zones = zones
 	i.countersLock.Unlock()
@@ -314,6 +314,27 @@ func (d *Desc) MergeContent() []string {
 	return result
 }
 
+// Heartbeats returns the heartbeat timestamp of each instance.
+// This method is part of etcd.Heartbeats interface, used when the etcd lease heartbeats are enabled.
+func (d *Desc) Heartbeats() map[string]int64 {
+	result := make(map[string]int64, len(d.Ingesters))
+	for id, ing := range d.Ingesters {
+		result[id] = ing.Timestamp
+	}
+	return result
+}
+
+// SetHeartbeats sets the heartbeat timestamp of the given instances. Unknown instances are ignored.
+// This method is part of etcd.Heartbeats interface, used when the etcd lease heartbeats are enabled.
+func (d *Desc) SetHeartbeats(heartbeats map[string]int64) {
+	for id, timestamp := range heartbeats {
+		if ing, ok := d.Ingesters[id]; ok {
+			ing.Timestamp = timestamp
+			d.Ingesters[id] = ing
+		}
+	}
+}
+
 // normalizeIngestersMap will do the following:
 // - sorts tokens and removes duplicates (only within single ingester)
 // - modifies the input ring
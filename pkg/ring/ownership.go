@@ -0,0 +1,357 @@
+package ring
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// maxOwnershipSnapshots is the number of ring topologies kept to compute the ownership diffs. The snapshots share the
+// immutable tokens of the ring, so they only cost the memory of the topologies no longer in the ring.
+const maxOwnershipSnapshots = 128
+
+// ownershipSnapshot is the topology of the ring at a point in time.
+type ownershipSnapshot struct {
+	at              time.Time
+	tokens          []uint32
+	tokensByZone    map[string][]uint32
+	instanceByToken map[uint32]instanceInfo
+	instances       map[string]struct{}
+}
+
+// InstanceOwnership is the ownership of the ring of an instance.
+type InstanceOwnership struct {
+	ID    string `json:"id"`
+	Zone  string `json:"zone"`
+	State string `json:"state"`
+	// Number of tokens of the instance.
+	Tokens int `json:"tokens"`
+	// Percentage of the ring (of the ring of its zone if zone-awareness is enabled) owned by the instance.
+	OwnershipPercent float64 `json:"ownership_percent"`
+	// Percentage of the ring the instance would own if the ownership was balanced between the instances.
+	ExpectedOwnershipPercent float64 `json:"expected_ownership_percent"`
+}
+
+// ZoneOwnership is the ownership of the ring of the instances of a zone.
+type ZoneOwnership struct {
+	Zone      string `json:"zone"`
+	Instances int    `json:"instances"`
+	Tokens    int    `json:"tokens"`
+	// Percentage of the ring owned by the instances of the zone. Each zone owns the whole ring if zone-awareness
+	// is enabled, since each zone holds a replica of all the keys.
+	OwnershipPercent float64 `json:"ownership_percent"`
+}
+
+// RingOwnership is the ownership of the ring by instance and by zone.
+type RingOwnership struct {
+	Now                  time.Time           `json:"now"`
+	ZoneAwarenessEnabled bool                `json:"zone_awareness_enabled"`
+	Instances            []InstanceOwnership `json:"instances"`
+	Zones                []ZoneOwnership     `json:"zones"`
+	// Times of the topology changes of the ring which can be compared with the ownership diff.
+	Snapshots []time.Time `json:"snapshots"`
+}
+
+// MovedRange is a range of the ring whose owner changed, from Start (included) to End (excluded, wrapping around
+// the ring). The owners are empty if there was or is no instance in the zone.
+type MovedRange struct {
+	Zone             string  `json:"zone"`
+	Start            uint32  `json:"start"`
+	End              uint32  `json:"end"`
+	From             string  `json:"from"`
+	To               string  `json:"to"`
+	OwnershipPercent float64 `json:"ownership_percent"`
+}
+
+// InstanceOwnershipDiff is the change of the ownership of the ring of an instance.
+type InstanceOwnershipDiff struct {
+	ID                     string  `json:"id"`
+	Zone                   string  `json:"zone"`
+	TokensBefore           int     `json:"tokens_before"`
+	TokensAfter            int     `json:"tokens_after"`
+	OwnershipPercentBefore float64 `json:"ownership_percent_before"`
+	OwnershipPercentAfter  float64 `json:"ownership_percent_after"`
+}
+
+// ZoneOwnershipDiff is the percentage of the ring of a zone whose owner changed.
+type ZoneOwnershipDiff struct {
+	Zone                  string  `json:"zone"`
+	MovedOwnershipPercent float64 `json:"moved_ownership_percent"`
+}
+
+// RingOwnershipDiff is the change of the ownership of the ring between two topologies of the ring.
+type RingOwnershipDiff struct {
+	From             time.Time               `json:"from"`
+	To               time.Time               `json:"to"`
+	AddedInstances   []string                `json:"added_instances"`
+	RemovedInstances []string                `json:"removed_instances"`
+	Instances        []InstanceOwnershipDiff `json:"instances"`
+	Zones            []ZoneOwnershipDiff     `json:"zones"`
+	MovedRanges      []MovedRange            `json:"moved_ranges"`
+}
+
+// recordOwnershipSnapshot records the current topology of the ring. The ring write lock must be already taken
+// when calling this function.
+func (r *Ring) recordOwnershipSnapshot(now time.Time) {
+	instances := make(map[string]struct{}, len(r.ringDesc.Ingesters))
+	for id := range r.ringDesc.Ingesters {
+		instances[id] = struct{}{}
+	}
+
+	r.ownershipSnapshots = append(r.ownershipSnapshots, ownershipSnapshot{
+		at:              now,
+		tokens:          r.ringTokens,
+		tokensByZone:    r.ringTokensByZone,
+		instanceByToken: r.ringInstanceByToken,
+		instances:       instances,
+	})
+	if len(r.ownershipSnapshots) > maxOwnershipSnapshots {
+		r.ownershipSnapshots = r.ownershipSnapshots[len(r.ownershipSnapshots)-maxOwnershipSnapshots:]
+	}
+}
+
+// ownershipSnapshotAt returns the last snapshot recorded at or before the given time. The ring read lock must be
+// already taken when calling this function.
+func (r *Ring) ownershipSnapshotAt(at time.Time) (ownershipSnapshot, bool) {
+	i := sort.Search(len(r.ownershipSnapshots), func(i int) bool {
+		return r.ownershipSnapshots[i].at.After(at)
+	})
+	if i == 0 {
+		return ownershipSnapshot{}, false
+	}
+	return r.ownershipSnapshots[i-1], true
+}
+
+// zoneRings returns the tokens of each zone, or the tokens of the whole ring in a single fake zone if
+// zone-awareness is disabled.
+func (s ownershipSnapshot) zoneRings(zoneAwarenessEnabled bool) map[string][]uint32 {
+	if zoneAwarenessEnabled {
+		return s.tokensByZone
+	}
+	return map[string][]uint32{"": s.tokens}
+}
+
+// ownedRanges returns the number of tokens and the size of the ranges of the ring owned by each instance.
+func (s ownershipSnapshot) ownedRanges(zoneAwarenessEnabled bool) (map[string]int, map[string]int64) {
+	numTokens := map[string]int{}
+	owned := map[string]int64{}
+	for _, tokens := range s.zoneRings(zoneAwarenessEnabled) {
+		for i := 1; i <= len(tokens); i++ {
+			index := i % len(tokens)
+			info := s.instanceByToken[tokens[index]]
+			numTokens[info.InstanceID]++
+			owned[info.InstanceID] += tokenDistance(tokens[i-1], tokens[index])
+		}
+	}
+	return numTokens, owned
+}
+
+// ownership returns the ownership of the ring of the current topology. The ring read lock must be already taken
+// when calling this function.
+func (r *Ring) ownership(now time.Time) RingOwnership {
+	current := ownershipSnapshot{
+		tokens:          r.ringTokens,
+		tokensByZone:    r.ringTokensByZone,
+		instanceByToken: r.ringInstanceByToken,
+	}
+	numTokens, owned := current.ownedRanges(r.cfg.ZoneAwarenessEnabled)
+
+	instancesByZone := map[string]int{}
+	for _, instance := range r.ringDesc.Ingesters {
+		instancesByZone[instance.Zone]++
+	}
+
+	storageLastUpdate := r.KVClient.LastUpdateTime(r.key)
+	result := RingOwnership{
+		Now:                  now,
+		ZoneAwarenessEnabled: r.cfg.ZoneAwarenessEnabled,
+		Instances:            make([]InstanceOwnership, 0, len(r.ringDesc.Ingesters)),
+		Snapshots:            make([]time.Time, 0, len(r.ownershipSnapshots)),
+	}
+	zones := map[string]*ZoneOwnership{}
+	for id, instance := range r.ringDesc.Ingesters {
+		state := instance.State.String()
+		if !r.IsHealthy(&instance, Reporting, storageLastUpdate) {
+			state = unhealthy
+		}
+
+		expectedInstances := len(r.ringDesc.Ingesters)
+		if r.cfg.ZoneAwarenessEnabled {
+			expectedInstances = instancesByZone[instance.Zone]
+		}
+
+		ownership := ownershipPercent(owned[id])
+		result.Instances = append(result.Instances, InstanceOwnership{
+			ID:                       id,
+			Zone:                     instance.Zone,
+			State:                    state,
+			Tokens:                   numTokens[id],
+			OwnershipPercent:         ownership,
+			ExpectedOwnershipPercent: 100 / float64(expectedInstances),
+		})
+
+		zone, ok := zones[instance.Zone]
+		if !ok {
+			zone = &ZoneOwnership{Zone: instance.Zone}
+			zones[instance.Zone] = zone
+		}
+		zone.Instances++
+		zone.Tokens += numTokens[id]
+		zone.OwnershipPercent += ownership
+	}
+	sort.Slice(result.Instances, func(i, j int) bool {
+		return result.Instances[i].ID < result.Instances[j].ID
+	})
+
+	result.Zones = make([]ZoneOwnership, 0, len(zones))
+	for _, zone := range zones {
+		result.Zones = append(result.Zones, *zone)
+	}
+	sort.Slice(result.Zones, func(i, j int) bool {
+		return result.Zones[i].Zone < result.Zones[j].Zone
+	})
+
+	for _, snapshot := range r.ownershipSnapshots {
+		result.Snapshots = append(result.Snapshots, snapshot.at)
+	}
+	return result
+}
+
+// diffOwnership returns the change of the ownership of the ring between the two snapshots.
+func diffOwnership(from, to ownershipSnapshot, zoneAwarenessEnabled bool) RingOwnershipDiff {
+	result := RingOwnershipDiff{
+		From:             from.at,
+		To:               to.at,
+		AddedInstances:   []string{},
+		RemovedInstances: []string{},
+		Instances:        []InstanceOwnershipDiff{},
+		Zones:            []ZoneOwnershipDiff{},
+		MovedRanges:      []MovedRange{},
+	}
+
+	for id := range to.instances {
+		if _, ok := from.instances[id]; !ok {
+			result.AddedInstances = append(result.AddedInstances, id)
+		}
+	}
+	for id := range from.instances {
+		if _, ok := to.instances[id]; !ok {
+			result.RemovedInstances = append(result.RemovedInstances, id)
+		}
+	}
+	sort.Strings(result.AddedInstances)
+	sort.Strings(result.RemovedInstances)
+
+	// Instances diff.
+	tokensBefore, ownedBefore := from.ownedRanges(zoneAwarenessEnabled)
+	tokensAfter, ownedAfter := to.ownedRanges(zoneAwarenessEnabled)
+	zoneByInstance := map[string]string{}
+	for _, snapshot := range []ownershipSnapshot{from, to} {
+		for _, info := range snapshot.instanceByToken {
+			zoneByInstance[info.InstanceID] = info.Zone
+		}
+	}
+	for id, zone := range zoneByInstance {
+		if _, ok := tokensBefore[id]; !ok {
+			if _, ok := tokensAfter[id]; !ok {
+				// The instance has no token in both snapshots, eg. it's in a zone excluded from the ring.
+				continue
+			}
+		}
+		result.Instances = append(result.Instances, InstanceOwnershipDiff{
+			ID:                     id,
+			Zone:                   zone,
+			TokensBefore:           tokensBefore[id],
+			TokensAfter:            tokensAfter[id],
+			OwnershipPercentBefore: ownershipPercent(ownedBefore[id]),
+			OwnershipPercentAfter:  ownershipPercent(ownedAfter[id]),
+		})
+	}
+	sort.Slice(result.Instances, func(i, j int) bool {
+		return result.Instances[i].ID < result.Instances[j].ID
+	})
+
+	// Moved ranges, zone by zone.
+	fromRings := from.zoneRings(zoneAwarenessEnabled)
+	toRings := to.zoneRings(zoneAwarenessEnabled)
+	zones := map[string]struct{}{}
+	for zone := range fromRings {
+		zones[zone] = struct{}{}
+	}
+	for zone := range toRings {
+		zones[zone] = struct{}{}
+	}
+	sortedZones := make([]string, 0, len(zones))
+	for zone := range zones {
+		sortedZones = append(sortedZones, zone)
+	}
+	sort.Strings(sortedZones)
+
+	for _, zone := range sortedZones {
+		moved := diffZoneRanges(zone, fromRings[zone], toRings[zone], from.instanceByToken, to.instanceByToken)
+
+		var movedSize int64
+		for _, r := range moved {
+			movedSize += tokenDistance(r.Start, r.End)
+		}
+		result.Zones = append(result.Zones, ZoneOwnershipDiff{Zone: zone, MovedOwnershipPercent: ownershipPercent(movedSize)})
+		result.MovedRanges = append(result.MovedRanges, moved...)
+	}
+
+	return result
+}
+
+// diffZoneRanges returns the ranges of the ring of a zone whose owner changed between the two tokens lists. The
+// ranges are split at each token of both lists, and the adjacent ranges moved between the same owners are merged.
+func diffZoneRanges(zone string, fromTokens, toTokens []uint32, fromByToken, toByToken map[uint32]instanceInfo) []MovedRange {
+	boundaries := make([]uint32, 0, len(fromTokens)+len(toTokens))
+	boundaries = append(boundaries, fromTokens...)
+	boundaries = append(boundaries, toTokens...)
+	sort.Slice(boundaries, func(i, j int) bool { return boundaries[i] < boundaries[j] })
+	boundaries = uniqueTokens(boundaries)
+
+	owner := func(tokens []uint32, byToken map[uint32]instanceInfo, key uint32) string {
+		if len(tokens) == 0 {
+			return ""
+		}
+		return byToken[tokens[searchToken(tokens, key)]].InstanceID
+	}
+
+	var moved []MovedRange
+	for i, start := range boundaries {
+		end := boundaries[(i+1)%len(boundaries)]
+		fromOwner := owner(fromTokens, fromByToken, start)
+		toOwner := owner(toTokens, toByToken, start)
+		if fromOwner == toOwner {
+			continue
+		}
+
+		if last := len(moved) - 1; last >= 0 && moved[last].End == start && moved[last].From == fromOwner && moved[last].To == toOwner {
+			moved[last].End = end
+			continue
+		}
+		moved = append(moved, MovedRange{Zone: zone, Start: start, End: end, From: fromOwner, To: toOwner})
+	}
+
+	for i := range moved {
+		moved[i].OwnershipPercent = ownershipPercent(tokenDistance(moved[i].Start, moved[i].End))
+	}
+	return moved
+}
+
+func uniqueTokens(sorted []uint32) []uint32 {
+	if len(sorted) == 0 {
+		return sorted
+	}
+	unique := sorted[:1]
+	for _, token := range sorted[1:] {
+		if token != unique[len(unique)-1] {
+			unique = append(unique, token)
+		}
+	}
+	return unique
+}
+
+func ownershipPercent(owned int64) float64 {
+	return float64(owned) / float64(math.MaxUint32+1) * 100
+}
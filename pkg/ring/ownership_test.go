@@ -0,0 +1,159 @@
+package ring
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newOwnershipTestRing(t *testing.T, zoneAwarenessEnabled bool) *Ring {
+	cfg := Config{HeartbeatTimeout: time.Minute, ReplicationFactor: 1, ZoneAwarenessEnabled: zoneAwarenessEnabled}
+	r, err := NewWithStoreClientAndStrategy(cfg, "test", "test", &MockClient{}, NewDefaultReplicationStrategy(), prometheus.NewPedanticRegistry(), log.NewNopLogger())
+	require.NoError(t, err)
+	return r
+}
+
+func TestRing_Ownership(t *testing.T) {
+	desc := NewDesc()
+	desc.AddIngester("instance-1", "127.0.0.1", "zone-a", []uint32{0, 1 << 31}, ACTIVE, time.Now())
+	desc.AddIngester("instance-2", "127.0.0.2", "zone-a", []uint32{1 << 30, 3 << 30}, ACTIVE, time.Now())
+	desc.AddIngester("instance-3", "127.0.0.3", "zone-b", []uint32{1 << 29}, ACTIVE, time.Now())
+
+	t.Run("zone-awareness disabled", func(t *testing.T) {
+		r := newOwnershipTestRing(t, false)
+		r.updateRingState(desc.Clone().(*Desc))
+
+		ownership := r.ownership(time.Now())
+		assert.Equal(t, []InstanceOwnership{
+			{ID: "instance-1", Zone: "zone-a", State: "ACTIVE", Tokens: 2, OwnershipPercent: 50, ExpectedOwnershipPercent: 100.0 / 3},
+			{ID: "instance-2", Zone: "zone-a", State: "ACTIVE", Tokens: 2, OwnershipPercent: 37.5, ExpectedOwnershipPercent: 100.0 / 3},
+			{ID: "instance-3", Zone: "zone-b", State: "ACTIVE", Tokens: 1, OwnershipPercent: 12.5, ExpectedOwnershipPercent: 100.0 / 3},
+		}, ownership.Instances)
+		assert.Equal(t, []ZoneOwnership{
+			{Zone: "zone-a", Instances: 2, Tokens: 4, OwnershipPercent: 87.5},
+			{Zone: "zone-b", Instances: 1, Tokens: 1, OwnershipPercent: 12.5},
+		}, ownership.Zones)
+		assert.Len(t, ownership.Snapshots, 1)
+	})
+
+	t.Run("zone-awareness enabled", func(t *testing.T) {
+		r := newOwnershipTestRing(t, true)
+		r.updateRingState(desc.Clone().(*Desc))
+
+		ownership := r.ownership(time.Now())
+		assert.Equal(t, []InstanceOwnership{
+			{ID: "instance-1", Zone: "zone-a", State: "ACTIVE", Tokens: 2, OwnershipPercent: 50, ExpectedOwnershipPercent: 50},
+			{ID: "instance-2", Zone: "zone-a", State: "ACTIVE", Tokens: 2, OwnershipPercent: 50, ExpectedOwnershipPercent: 50},
+			{ID: "instance-3", Zone: "zone-b", State: "ACTIVE", Tokens: 1, OwnershipPercent: 100, ExpectedOwnershipPercent: 100},
+		}, ownership.Instances)
+		assert.Equal(t, []ZoneOwnership{
+			{Zone: "zone-a", Instances: 2, Tokens: 4, OwnershipPercent: 100},
+			{Zone: "zone-b", Instances: 1, Tokens: 1, OwnershipPercent: 100},
+		}, ownership.Zones)
+	})
+}
+
+func TestDiffOwnership(t *testing.T) {
+	before := NewDesc()
+	before.AddIngester("instance-1", "127.0.0.1", "", []uint32{0, 1 << 31}, ACTIVE, time.Now())
+	before.AddIngester("instance-2", "127.0.0.2", "", []uint32{1 << 30, 3 << 30}, ACTIVE, time.Now())
+
+	after := before.Clone().(*Desc)
+	after.AddIngester("instance-3", "127.0.0.3", "", []uint32{1 << 29}, ACTIVE, time.Now())
+
+	r := newOwnershipTestRing(t, false)
+	r.updateRingState(before)
+	r.updateRingState(after)
+	require.Len(t, r.ownershipSnapshots, 2)
+
+	diff := diffOwnership(r.ownershipSnapshots[0], r.ownershipSnapshots[1], false)
+	assert.Equal(t, []string{"instance-3"}, diff.AddedInstances)
+	assert.Empty(t, diff.RemovedInstances)
+	assert.Equal(t, []InstanceOwnershipDiff{
+		{ID: "instance-1", TokensBefore: 2, TokensAfter: 2, OwnershipPercentBefore: 50, OwnershipPercentAfter: 50},
+		{ID: "instance-2", TokensBefore: 2, TokensAfter: 2, OwnershipPercentBefore: 50, OwnershipPercentAfter: 37.5},
+		{ID: "instance-3", TokensBefore: 0, TokensAfter: 1, OwnershipPercentBefore: 0, OwnershipPercentAfter: 12.5},
+	}, diff.Instances)
+	assert.Equal(t, []ZoneOwnershipDiff{{Zone: "", MovedOwnershipPercent: 12.5}}, diff.Zones)
+	assert.Equal(t, []MovedRange{{Start: 0, End: 1 << 29, From: "instance-2", To: "instance-3", OwnershipPercent: 12.5}}, diff.MovedRanges)
+
+	// The reverse diff moves the range back.
+	diff = diffOwnership(r.ownershipSnapshots[1], r.ownershipSnapshots[0], false)
+	assert.Empty(t, diff.AddedInstances)
+	assert.Equal(t, []string{"instance-3"}, diff.RemovedInstances)
+	assert.Equal(t, []MovedRange{{Start: 0, End: 1 << 29, From: "instance-3", To: "instance-2", OwnershipPercent: 12.5}}, diff.MovedRanges)
+
+	// No range moves between the same topologies.
+	diff = diffOwnership(r.ownershipSnapshots[1], r.ownershipSnapshots[1], false)
+	assert.Empty(t, diff.MovedRanges)
+}
+
+func TestDiffZoneRanges_ShouldMergeTheAdjacentRanges(t *testing.T) {
+	fromByToken := map[uint32]instanceInfo{10: {InstanceID: "a"}, 20: {InstanceID: "a"}, 30: {InstanceID: "b"}}
+	toByToken := map[uint32]instanceInfo{0: {InstanceID: "c"}, 10: {InstanceID: "c"}, 20: {InstanceID: "c"}, 30: {InstanceID: "b"}}
+
+	assert.Equal(t, []MovedRange{
+		{Zone: "zone-a", Start: 0, End: 20, From: "a", To: "c", OwnershipPercent: ownershipPercent(20)},
+		{Zone: "zone-a", Start: 30, End: 0, From: "a", To: "c", OwnershipPercent: ownershipPercent(tokenDistance(30, 0))},
+	}, diffZoneRanges("zone-a", []uint32{10, 20, 30}, []uint32{0, 10, 20, 30}, fromByToken, toByToken))
+
+	// From an empty ring, the whole ring moves.
+	moved := diffZoneRanges("zone-a", nil, []uint32{10, 30}, nil, toByToken)
+	require.Len(t, moved, 2)
+	assert.Equal(t, MovedRange{Zone: "zone-a", Start: 10, End: 30, From: "", To: "b", OwnershipPercent: ownershipPercent(20)}, moved[0])
+	assert.Equal(t, "c", moved[1].To)
+	assert.Equal(t, float64(100), moved[0].OwnershipPercent+moved[1].OwnershipPercent)
+}
+
+func TestRing_ServeHTTP_Ownership(t *testing.T) {
+	before := NewDesc()
+	before.AddIngester("instance-1", "127.0.0.1", "", []uint32{0, 1 << 31}, ACTIVE, time.Now())
+	after := before.Clone().(*Desc)
+	after.AddIngester("instance-2", "127.0.0.2", "", []uint32{1 << 30}, ACTIVE, time.Now())
+
+	r := newOwnershipTestRing(t, false)
+	r.updateRingState(before)
+	r.updateRingState(after)
+	r.ownershipSnapshots[0].at = time.Unix(100, 0)
+	r.ownershipSnapshots[1].at = time.Unix(200, 0)
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := serve("/ring?view=ownership")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var ownership RingOwnership
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &ownership))
+	assert.Len(t, ownership.Instances, 2)
+	require.Len(t, ownership.Snapshots, 2)
+	assert.True(t, ownership.Snapshots[0].Equal(time.Unix(100, 0)))
+	assert.True(t, ownership.Snapshots[1].Equal(time.Unix(200, 0)))
+
+	rec = serve("/ring?view=ownership-diff&from=150")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var diff RingOwnershipDiff
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &diff))
+	assert.Equal(t, []string{"instance-2"}, diff.AddedInstances)
+	assert.Equal(t, []ZoneOwnershipDiff{{Zone: "", MovedOwnershipPercent: 25}}, diff.Zones)
+
+	// The diff to a time before the change is empty.
+	rec = serve("/ring?view=ownership-diff&from=150&to=199")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &diff))
+	assert.Empty(t, diff.MovedRanges)
+
+	assert.Equal(t, http.StatusBadRequest, serve("/ring?view=ownership-diff").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("/ring?view=ownership-diff&from=50").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("/ring?view=ownership-diff&from=invalid").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("/ring?view=unknown").Code)
+}
@@ -0,0 +1,161 @@
+package ring
+
+import (
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// partitionTokensCount is the number of tokens of each partition. The more tokens, the more evenly the keys are
+// spread across the partitions.
+const partitionTokensCount = 128
+
+var (
+	// ErrNoPartitions is the error returned when the keys can't be assigned to any partition, because the ring
+	// has no partition or the partitions are disabled.
+	ErrNoPartitions = errors.New("the ring has no partition")
+
+	errPartitionsInstanceIDWithoutOrdinal = errors.New("the ingestion partitions require the instance ID to end with its ordinal, eg. ingester-0")
+)
+
+// PartitionReadRing is a ReadRing whose keys can be assigned to numbered partitions, instead of the instances
+// owning the token ranges. Each partition is owned by the instances whose ID ends with the partition number,
+// typically one per zone, eg. the partition 2 is owned by ingester-zone-a-2, ingester-zone-b-2 and ingester-zone-c-2.
+type PartitionReadRing interface {
+	ReadRing
+
+	// PartitionsEnabled returns whether the keys are assigned to partitions.
+	PartitionsEnabled() bool
+
+	// PartitionsCount returns the number of partitions in the ring.
+	PartitionsCount() int
+
+	// GetPartition returns the partition of the given key. Given the same partitions, a key is always
+	// assigned to the same partition, regardless of the state of the instances owning it.
+	GetPartition(key uint32) (int32, error)
+
+	// GetPartitionOwners returns the instances owning the partition, for the given operation.
+	// bufDescs is a slice to be overwritten for the return value to avoid memory allocation.
+	GetPartitionOwners(partition int32, op Operation, bufDescs []InstanceDesc) (ReplicationSet, error)
+}
+
+// partitionsDesc maps the keys to the partitions of the ring, and the partitions to their owners.
+type partitionsDesc struct {
+	// Sorted tokens of all the partitions, and the partition of each of them.
+	tokens          []uint32
+	tokenPartitions []int32
+
+	// Sorted instance IDs of the owners of each partition.
+	owners map[int32][]string
+}
+
+// newPartitionsDesc builds the partitions of the ring from the ordinals of its instances. The tokens of each
+// partition depend only on its number, so that adding or removing a partition doesn't move the keys between the
+// other partitions. The keys are evenly spread when the number of partitions is a power of two, otherwise a
+// partition owns at most twice as many keys as another.
+func newPartitionsDesc(ringDesc *Desc) *partitionsDesc {
+	owners := map[int32][]string{}
+	for id := range ringDesc.GetIngesters() {
+		ordinal, ok := instanceOrdinal(id)
+		if !ok || ordinal > math.MaxInt32 {
+			continue
+		}
+		owners[int32(ordinal)] = append(owners[int32(ordinal)], id)
+	}
+
+	partitions := make([]int32, 0, len(owners))
+	for partition, ids := range owners {
+		sort.Strings(ids)
+		partitions = append(partitions, partition)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+
+	step := (uint64(maxTokenValue) + 1) / partitionTokensCount
+	tokenPartitions := make(map[uint32]int32, len(partitions)*partitionTokensCount)
+	for _, partition := range partitions {
+		offset := uint64(vanDerCorput(uint64(partition)) * float64(step))
+		for i := uint64(0); i < partitionTokensCount; i++ {
+			token := uint32(offset + i*step)
+			// The lowest partition keeps the token when two partitions have the same one.
+			if _, ok := tokenPartitions[token]; !ok {
+				tokenPartitions[token] = partition
+			}
+		}
+	}
+
+	desc := &partitionsDesc{
+		tokens:          make([]uint32, 0, len(tokenPartitions)),
+		tokenPartitions: make([]int32, 0, len(tokenPartitions)),
+		owners:          owners,
+	}
+	for token := range tokenPartitions {
+		desc.tokens = append(desc.tokens, token)
+	}
+	sort.Slice(desc.tokens, func(i, j int) bool { return desc.tokens[i] < desc.tokens[j] })
+	for _, token := range desc.tokens {
+		desc.tokenPartitions = append(desc.tokenPartitions, tokenPartitions[token])
+	}
+	return desc
+}
+
+func (d *partitionsDesc) getPartition(key uint32) (int32, error) {
+	if d == nil || len(d.tokens) == 0 {
+		return 0, ErrNoPartitions
+	}
+	return d.tokenPartitions[searchToken(d.tokens, key)], nil
+}
+
+// PartitionsEnabled implements PartitionReadRing.
+func (r *Ring) PartitionsEnabled() bool {
+	return r.cfg.PartitionsEnabled
+}
+
+// PartitionsCount implements PartitionReadRing.
+func (r *Ring) PartitionsCount() int {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	if r.ringPartitions == nil {
+		return 0
+	}
+	return len(r.ringPartitions.owners)
+}
+
+// GetPartition implements PartitionReadRing.
+func (r *Ring) GetPartition(key uint32) (int32, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	return r.ringPartitions.getPartition(key)
+}
+
+// GetPartitionOwners implements PartitionReadRing. The owners of a partition replicate its keys, so
+// the replication factor of the ring is ignored and the quorum is computed on the number of owners.
+func (r *Ring) GetPartitionOwners(partition int32, op Operation, bufDescs []InstanceDesc) (ReplicationSet, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	if r.ringPartitions == nil {
+		return ReplicationSet{}, ErrNoPartitions
+	}
+	ids, ok := r.ringPartitions.owners[partition]
+	if !ok {
+		return ReplicationSet{}, errors.Errorf("partition %d has no owner", partition)
+	}
+
+	instances := bufDescs[:0]
+	for _, id := range ids {
+		instances = append(instances, r.ringDesc.Ingesters[id])
+	}
+
+	healthyInstances, maxFailure, err := r.strategy.Filter(instances, op, len(instances), r.cfg.HeartbeatTimeout, r.cfg.ZoneAwarenessEnabled, r.KVClient.LastUpdateTime(r.key))
+	if err != nil {
+		return ReplicationSet{}, errors.Wrapf(err, "partition %d", partition)
+	}
+
+	return ReplicationSet{
+		Instances: healthyInstances,
+		MaxErrors: maxFailure,
+	}, nil
+}
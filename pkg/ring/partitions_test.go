@@ -0,0 +1,176 @@
+package ring
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPartitionsTestRing(t *testing.T, numPartitions int, zones []string) *Ring {
+	g := NewRandomTokenGenerator()
+	desc := NewDesc()
+	for _, zone := range zones {
+		for i := 0; i < numPartitions; i++ {
+			id := fmt.Sprintf("ingester-%s-%d", zone, i)
+			desc.AddIngester(id, id, zone, g.GenerateTokens(desc, id, zone, 128, true), ACTIVE, time.Now())
+		}
+	}
+
+	cfg := Config{HeartbeatTimeout: time.Hour, ReplicationFactor: 3, ZoneAwarenessEnabled: len(zones) > 1, PartitionsEnabled: true}
+	r, err := NewWithStoreClientAndStrategy(cfg, "test", "test", &MockClient{}, NewDefaultReplicationStrategy(), prometheus.NewPedanticRegistry(), log.NewNopLogger())
+	require.NoError(t, err)
+	r.updateRingState(desc)
+	return r
+}
+
+func TestRing_GetPartition(t *testing.T) {
+	r := newPartitionsTestRing(t, 10, []string{"zone-a"})
+	require.Equal(t, 10, r.PartitionsCount())
+
+	const numKeys = 100000
+	countKeys := func(r *Ring) map[int32]int {
+		counts := map[int32]int{}
+		for key := uint32(0); key < numKeys; key++ {
+			partition, err := r.GetPartition(key * 42949)
+			require.NoError(t, err)
+			counts[partition]++
+		}
+		return counts
+	}
+
+	// The partitions own at most twice as many keys as each other.
+	counts := countKeys(r)
+	require.Len(t, counts, 10)
+	for partition, count := range counts {
+		assert.InDelta(t, numKeys/10, count, numKeys/10*0.4, partition)
+	}
+
+	t.Run("the keys are evenly spread across a power of two of partitions", func(t *testing.T) {
+		counts := countKeys(newPartitionsTestRing(t, 8, []string{"zone-a"}))
+		require.Len(t, counts, 8)
+		for partition, count := range counts {
+			assert.InDelta(t, numKeys/8, count, numKeys/8*0.01, partition)
+		}
+	})
+
+	t.Run("adding a partition only moves keys to the new partition", func(t *testing.T) {
+		scaled := newPartitionsTestRing(t, 11, []string{"zone-a"})
+		for key := uint32(0); key < numKeys; key++ {
+			before, err := r.GetPartition(key * 42949)
+			require.NoError(t, err)
+			after, err := scaled.GetPartition(key * 42949)
+			require.NoError(t, err)
+			if before != after {
+				assert.Equal(t, int32(10), after)
+			}
+		}
+	})
+
+	t.Run("the partition doesn't depend on the state of the owners", func(t *testing.T) {
+		desc := r.ringDesc.Clone().(*Desc)
+		instance := desc.Ingesters["ingester-zone-a-3"]
+		instance.State = LEAVING
+		instance.Timestamp = 0
+		desc.Ingesters["ingester-zone-a-3"] = instance
+
+		changed := newPartitionsTestRing(t, 10, []string{"zone-a"})
+		changed.updateRingState(desc)
+		for key := uint32(0); key < numKeys; key += 97 {
+			expected, err := r.GetPartition(key * 42949)
+			require.NoError(t, err)
+			actual, err := changed.GetPartition(key * 42949)
+			require.NoError(t, err)
+			assert.Equal(t, expected, actual)
+		}
+	})
+
+	t.Run("no partitions", func(t *testing.T) {
+		empty := newPartitionsTestRing(t, 0, []string{"zone-a"})
+		_, err := empty.GetPartition(1)
+		assert.Equal(t, ErrNoPartitions, err)
+	})
+}
+
+func TestRing_GetPartitionOwners(t *testing.T) {
+	r := newPartitionsTestRing(t, 4, []string{"zone-a", "zone-b", "zone-c"})
+	require.Equal(t, 4, r.PartitionsCount())
+
+	set, err := r.GetPartitionOwners(2, Write, nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"ingester-zone-a-2", "ingester-zone-b-2", "ingester-zone-c-2"}, set.GetAddresses())
+	assert.Equal(t, 1, set.MaxErrors)
+
+	// An unhealthy owner is tolerated, but not two.
+	desc := r.ringDesc.Clone().(*Desc)
+	for _, id := range []string{"ingester-zone-a-2", "ingester-zone-b-2"} {
+		instance := desc.Ingesters[id]
+		instance.Timestamp = 0
+		desc.Ingesters[id] = instance
+		r.updateRingState(desc.Clone().(*Desc))
+
+		set, err = r.GetPartitionOwners(2, Write, nil)
+		if id == "ingester-zone-a-2" {
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"ingester-zone-b-2", "ingester-zone-c-2"}, set.GetAddresses())
+			assert.Equal(t, 0, set.MaxErrors)
+		} else {
+			require.Error(t, err)
+		}
+	}
+
+	_, err = r.GetPartitionOwners(4, Write, nil)
+	require.Error(t, err)
+}
+
+func TestDoBatchWithPartitions(t *testing.T) {
+	r := newPartitionsTestRing(t, 3, []string{"zone-a", "zone-b"})
+
+	keys := make([]uint32, 100)
+	for i := range keys {
+		keys[i] = uint32(i) * 42949673
+	}
+
+	var (
+		mtx     sync.Mutex
+		indexes = map[string][]int{}
+		cleanup = make(chan struct{})
+	)
+	require.NoError(t, DoBatchWithPartitions(context.Background(), Write, r, keys, func(instance InstanceDesc, idx []int) error {
+		mtx.Lock()
+		defer mtx.Unlock()
+		indexes[instance.Addr] = append(indexes[instance.Addr], idx...)
+		return nil
+	}, func() { close(cleanup) }))
+
+	// Wait until all the callbacks have been called.
+	<-cleanup
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	for i, key := range keys {
+		partition, err := r.GetPartition(key)
+		require.NoError(t, err)
+		for _, zone := range []string{"zone-a", "zone-b"} {
+			assert.Contains(t, indexes[fmt.Sprintf("ingester-%s-%d", zone, partition)], i)
+		}
+	}
+}
+
+func TestLifecyclerConfig_Validate_Partitions(t *testing.T) {
+	cfg := LifecyclerConfig{ID: "ingester-zone-a-1", TokensGeneratorStrategy: randomTokenStrategy}
+	cfg.RingConfig.PartitionsEnabled = true
+	assert.NoError(t, cfg.Validate())
+
+	cfg.ID = "ingester"
+	assert.Equal(t, errPartitionsInstanceIDWithoutOrdinal, cfg.Validate())
+
+	cfg.RingConfig.PartitionsEnabled = false
+	assert.NoError(t, cfg.Validate())
+}
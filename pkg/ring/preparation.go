@@ -0,0 +1,205 @@
+package ring
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// PreparationHooks are the operations of a component to prepare one of its instances for the termination.
+type PreparationHooks struct {
+	// SetHandOffOnShutdown configures whether the instance leaves the ring, and flushes or hands off its
+	// state, when it shuts down. Disabling it restores the configured behaviour.
+	SetHandOffOnShutdown func(enabled bool)
+
+	// ChangeState changes the state of the instance in the ring.
+	ChangeState func(ctx context.Context, state InstanceState) error
+
+	// HandOff flushes or hands off the state of the instance, once it has been switched to LEAVING. It's
+	// run in background, and its context is canceled if the downscale is canceled. Optional.
+	HandOff func(ctx context.Context) error
+
+	// ReadyForTermination returns an error if the instance is not ready for the termination yet, once its
+	// state has been handed off. Optional.
+	ReadyForTermination func() error
+}
+
+// PreparationStatus is the status of the preparation of an instance for the termination.
+type PreparationStatus struct {
+	ShutdownPrepared    bool       `json:"shutdown_prepared"`
+	DownscalePrepared   bool       `json:"downscale_prepared"`
+	DownscalePreparedAt *time.Time `json:"downscale_prepared_at,omitempty"`
+	ReadyForTermination bool       `json:"ready_for_termination"`
+	Reason              string     `json:"reason,omitempty"`
+}
+
+// InstancePreparation serves the prepare-shutdown and prepare-downscale endpoints of an instance, so that the
+// autoscalers can drive the scale-downs programmatically:
+//   - Preparing the shutdown configures the instance to leave the ring, and to flush or hand off its state,
+//     when it shuts down.
+//   - Preparing the downscale switches the instance to LEAVING right away, so that it stops owning data, and
+//     flushes or hands off its state in background.
+//
+// The GET requests return the status of the preparation, the POST requests prepare the instance and the DELETE
+// requests cancel the preparation.
+type InstancePreparation struct {
+	hooks  PreparationHooks
+	logger log.Logger
+
+	mtx                 sync.Mutex
+	shutdownPrepared    bool
+	downscalePreparedAt time.Time
+	handOffCancel       context.CancelFunc
+	handOffDone         bool
+	handOffErr          error
+}
+
+func NewInstancePreparation(hooks PreparationHooks, logger log.Logger) *InstancePreparation {
+	return &InstancePreparation{
+		hooks:  hooks,
+		logger: logger,
+	}
+}
+
+// PrepareShutdown configures the instance to hand off its state when it shuts down, or restores the
+// configured behaviour.
+func (p *InstancePreparation) PrepareShutdown(prepare bool) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.hooks.SetHandOffOnShutdown(prepare)
+	p.shutdownPrepared = prepare
+	level.Info(p.logger).Log("msg", "instance shutdown preparation changed", "prepared", prepare)
+}
+
+// PrepareDownscale switches the instance to LEAVING and starts handing off its state, or switches it back
+// to ACTIVE.
+func (p *InstancePreparation) PrepareDownscale(ctx context.Context, prepare bool) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	prepared := !p.downscalePreparedAt.IsZero()
+	if prepare == prepared {
+		return nil
+	}
+
+	if !prepare {
+		p.handOffCancel()
+		if err := p.hooks.ChangeState(ctx, ACTIVE); err != nil {
+			return err
+		}
+		p.downscalePreparedAt = time.Time{}
+		level.Info(p.logger).Log("msg", "instance downscale preparation canceled")
+		return nil
+	}
+
+	if err := p.hooks.ChangeState(ctx, LEAVING); err != nil {
+		return err
+	}
+	p.downscalePreparedAt = time.Now()
+	p.handOffDone, p.handOffErr = false, nil
+
+	handOffCtx, cancel := context.WithCancel(context.Background())
+	p.handOffCancel = cancel
+	go p.handOff(handOffCtx)
+
+	level.Info(p.logger).Log("msg", "instance downscale prepared, handing off its state")
+	return nil
+}
+
+func (p *InstancePreparation) handOff(ctx context.Context) {
+	var err error
+	if p.hooks.HandOff != nil {
+		err = p.hooks.HandOff(ctx)
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	// The downscale has been canceled in the meanwhile. The context is canceled with the lock held.
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		level.Warn(p.logger).Log("msg", "failed to hand off the state of the instance", "err", err)
+	} else {
+		level.Info(p.logger).Log("msg", "handed off the state of the instance")
+	}
+	p.handOffDone, p.handOffErr = true, err
+}
+
+// Status returns the status of the preparation of the instance. The instance is ready for the termination
+// once its shutdown is prepared, or once its downscale is prepared and its state has been handed off.
+func (p *InstancePreparation) Status() PreparationStatus {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	status := PreparationStatus{
+		ShutdownPrepared:  p.shutdownPrepared,
+		DownscalePrepared: !p.downscalePreparedAt.IsZero(),
+	}
+
+	switch {
+	case status.DownscalePrepared:
+		preparedAt := p.downscalePreparedAt
+		status.DownscalePreparedAt = &preparedAt
+
+		if !p.handOffDone {
+			status.Reason = "the state of the instance is being handed off"
+		} else if p.handOffErr != nil {
+			status.Reason = "failed to hand off the state of the instance: " + p.handOffErr.Error()
+		} else if p.hooks.ReadyForTermination != nil {
+			if err := p.hooks.ReadyForTermination(); err != nil {
+				status.Reason = err.Error()
+			}
+		}
+		status.ReadyForTermination = status.Reason == ""
+
+	case status.ShutdownPrepared:
+		status.ReadyForTermination = true
+
+	default:
+		status.Reason = "the instance is not prepared for the termination"
+	}
+	return status
+}
+
+// PrepareShutdownHandler serves the prepare-shutdown endpoint.
+func (p *InstancePreparation) PrepareShutdownHandler(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		p.PrepareShutdown(true)
+	case http.MethodDelete:
+		p.PrepareShutdown(false)
+	}
+	p.writeStatus(w)
+}
+
+// PrepareDownscaleHandler serves the prepare-downscale endpoint.
+func (p *InstancePreparation) PrepareDownscaleHandler(w http.ResponseWriter, req *http.Request) {
+	var err error
+	switch req.Method {
+	case http.MethodPost:
+		err = p.PrepareDownscale(req.Context(), true)
+	case http.MethodDelete:
+		err = p.PrepareDownscale(req.Context(), false)
+	}
+	if err != nil {
+		level.Error(p.logger).Log("msg", "failed to change the downscale preparation of the instance", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p.writeStatus(w)
+}
+
+func (p *InstancePreparation) writeStatus(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(p.Status()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
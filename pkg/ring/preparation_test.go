@@ -0,0 +1,152 @@
+package ring
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/cortexproject/cortex/pkg/util/test"
+)
+
+type preparationTestInstance struct {
+	mtx               sync.Mutex
+	state             InstanceState
+	handOffOnShutdown bool
+
+	handOffRelease chan struct{}
+	handOffs       atomic.Int32
+	readyErr       error
+}
+
+func newPreparationTestInstance() *preparationTestInstance {
+	return &preparationTestInstance{state: ACTIVE, handOffRelease: make(chan struct{})}
+}
+
+func (i *preparationTestInstance) hooks() PreparationHooks {
+	return PreparationHooks{
+		SetHandOffOnShutdown: func(enabled bool) {
+			i.mtx.Lock()
+			defer i.mtx.Unlock()
+			i.handOffOnShutdown = enabled
+		},
+		ChangeState: func(_ context.Context, state InstanceState) error {
+			i.mtx.Lock()
+			defer i.mtx.Unlock()
+			i.state = state
+			return nil
+		},
+		HandOff: func(ctx context.Context) error {
+			i.handOffs.Inc()
+			select {
+			case <-i.handOffRelease:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+		ReadyForTermination: func() error {
+			i.mtx.Lock()
+			defer i.mtx.Unlock()
+			return i.readyErr
+		},
+	}
+}
+
+func (i *preparationTestInstance) getState() (InstanceState, bool) {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+	return i.state, i.handOffOnShutdown
+}
+
+func servePreparation(t *testing.T, handler http.HandlerFunc, method string) PreparationStatus {
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(method, "/prepare", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	status := PreparationStatus{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	return status
+}
+
+func TestInstancePreparation_PrepareShutdown(t *testing.T) {
+	instance := newPreparationTestInstance()
+	p := NewInstancePreparation(instance.hooks(), log.NewNopLogger())
+
+	status := servePreparation(t, p.PrepareShutdownHandler, http.MethodGet)
+	assert.False(t, status.ShutdownPrepared)
+	assert.False(t, status.ReadyForTermination)
+
+	status = servePreparation(t, p.PrepareShutdownHandler, http.MethodPost)
+	assert.True(t, status.ShutdownPrepared)
+	assert.True(t, status.ReadyForTermination)
+	state, handOffOnShutdown := instance.getState()
+	assert.Equal(t, ACTIVE, state)
+	assert.True(t, handOffOnShutdown)
+
+	status = servePreparation(t, p.PrepareShutdownHandler, http.MethodDelete)
+	assert.False(t, status.ShutdownPrepared)
+	_, handOffOnShutdown = instance.getState()
+	assert.False(t, handOffOnShutdown)
+}
+
+func TestInstancePreparation_PrepareDownscale(t *testing.T) {
+	instance := newPreparationTestInstance()
+	p := NewInstancePreparation(instance.hooks(), log.NewNopLogger())
+
+	status := servePreparation(t, p.PrepareDownscaleHandler, http.MethodPost)
+	assert.True(t, status.DownscalePrepared)
+	assert.NotNil(t, status.DownscalePreparedAt)
+	assert.False(t, status.ReadyForTermination)
+	state, _ := instance.getState()
+	assert.Equal(t, LEAVING, state)
+
+	// Preparing again is a no-op.
+	servePreparation(t, p.PrepareDownscaleHandler, http.MethodPost)
+	test.Poll(t, time.Second, int32(1), func() interface{} { return instance.handOffs.Load() })
+
+	// The instance is ready once its state has been handed off, and it's ready for the termination.
+	instance.mtx.Lock()
+	instance.readyErr = errors.New("still running")
+	instance.mtx.Unlock()
+	close(instance.handOffRelease)
+	test.Poll(t, time.Second, "still running", func() interface{} { return p.Status().Reason })
+
+	instance.mtx.Lock()
+	instance.readyErr = nil
+	instance.mtx.Unlock()
+	status = servePreparation(t, p.PrepareDownscaleHandler, http.MethodGet)
+	assert.True(t, status.ReadyForTermination)
+	assert.Empty(t, status.Reason)
+
+	// Cancel the downscale.
+	status = servePreparation(t, p.PrepareDownscaleHandler, http.MethodDelete)
+	assert.False(t, status.DownscalePrepared)
+	assert.False(t, status.ReadyForTermination)
+	state, _ = instance.getState()
+	assert.Equal(t, ACTIVE, state)
+}
+
+func TestInstancePreparation_CancelDownscaleWhileHandingOff(t *testing.T) {
+	instance := newPreparationTestInstance()
+	p := NewInstancePreparation(instance.hooks(), log.NewNopLogger())
+
+	require.NoError(t, p.PrepareDownscale(context.Background(), true))
+	test.Poll(t, time.Second, int32(1), func() interface{} { return instance.handOffs.Load() })
+	require.NoError(t, p.PrepareDownscale(context.Background(), false))
+
+	// The canceled hand off doesn't make the instance ready once prepared again.
+	require.NoError(t, p.PrepareDownscale(context.Background(), true))
+	test.Poll(t, time.Second, int32(2), func() interface{} { return instance.handOffs.Load() })
+	assert.False(t, p.Status().ReadyForTermination)
+	assert.Equal(t, "the state of the instance is being handed off", p.Status().Reason)
+}
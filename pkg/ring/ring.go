@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -44,6 +46,10 @@ type ReadRing interface {
 	// to avoid memory allocation; can be nil, or created with ring.MakeBuffersForGet().
 	Get(key uint32, op Operation, bufDescs []InstanceDesc, bufHosts []string, bufZones map[string]int) (ReplicationSet, error)
 
+	// GetWithBoundedLoads is like Get, but skips the instances which have no spare capacity left in loads, so that
+	// no instance is assigned more than its share of the keys, and adds the returned instances to loads.
+	GetWithBoundedLoads(key uint32, op Operation, loads *BoundedLoads, bufDescs []InstanceDesc, bufHosts []string, bufZones map[string]int) (ReplicationSet, error)
+
 	// GetAllHealthy returns all healthy instances in the ring, for the given operation.
 	// This function doesn't check if the quorum is honored, so doesn't fail if the number
 	// of unhealthy instances is greater than the tolerated max unavailable.
@@ -141,6 +147,9 @@ type Config struct {
 	ExcludedZones          flagext.StringSliceCSV `yaml:"excluded_zones"`
 	DetailedMetricsEnabled bool                   `yaml:"detailed_metrics_enabled"`
 
+	ShuffleShardingZoneFailoverEnabled bool `yaml:"shuffle_sharding_zone_failover_enabled"`
+	PartitionsEnabled                  bool `yaml:"ingestion_partitions_enabled"`
+
 	// Whether the shuffle-sharding subring cache is disabled. This option is set
 	// internally and never exposed to the user.
 	SubringCacheDisabled bool `yaml:"-"`
@@ -160,6 +169,8 @@ func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	f.IntVar(&cfg.ReplicationFactor, prefix+"distributor.replication-factor", 3, "The number of ingesters to write to and read from.")
 	f.BoolVar(&cfg.ZoneAwarenessEnabled, prefix+"distributor.zone-awareness-enabled", false, "True to enable the zone-awareness and replicate ingested samples across different availability zones.")
 	f.Var(&cfg.ExcludedZones, prefix+"distributor.excluded-zones", "Comma-separated list of zones to exclude from the ring. Instances in excluded zones will be filtered out from the ring.")
+	f.BoolVar(&cfg.ShuffleShardingZoneFailoverEnabled, prefix+"distributor.shuffle-sharding-zone-failover-enabled", false, "EXPERIMENTAL: True to replace the instances of the shuffle shards in a failed zone, whose instances are all unhealthy, with instances of the healthy zones. Requires zone-awareness.")
+	f.BoolVar(&cfg.PartitionsEnabled, prefix+"distributor.ingestion-partitions-enabled", false, "EXPERIMENTAL: True to assign the series to numbered partitions instead of the token ranges of the ingesters. Each partition is owned by the ingesters whose ID ends with the partition number, one per zone, which replicate its series, and the replication factor is ignored. Requires the ingester IDs to end with their ordinal, eg. ingester-zone-a-0, and isn't supported with the shuffle-sharding strategy.")
 }
 
 type instanceInfo struct {
@@ -193,6 +204,12 @@ type Ring struct {
 	// to be sorted alphabetically.
 	ringZones []string
 
+	// Partitions of the ring, only set if the partitions are enabled.
+	ringPartitions *partitionsDesc
+
+	// Topologies of the ring over time, used to compute the ownership diffs. Not set in subrings.
+	ownershipSnapshots []ownershipSnapshot
+
 	// Cache of shuffle-sharded subrings per identifier. Invalidated when topology changes.
 	// If set to nil, no caching is done (used by tests, and subrings).
 	shuffledSubringCache map[subringCacheKey]*Ring
@@ -204,6 +221,15 @@ type Ring struct {
 	oldestTimestampGaugeVec *prometheus.GaugeVec
 	reportedOwners          map[string]struct{}
 
+	// Zones whose instances are all unhealthy, only computed if the zone failover of the shuffle
+	// sharding is enabled. Updated whenever the ring changes, and guaranteed to be sorted alphabetically.
+	failedZones []string
+
+	// Only set if the zone failover of the shuffle sharding is enabled. The counters are shared
+	// with the subrings, since they can be shuffle sharded too.
+	failedZonesGauge       prometheus.Gauge
+	degradedShardsCounters *prometheus.CounterVec
+
 	logger log.Logger
 }
 
@@ -212,6 +238,9 @@ type subringCacheKey struct {
 	shardSize  int
 
 	zoneStableSharding bool
+
+	// Sorted and comma-separated zones whose instances have been replaced.
+	failedZones string
 }
 
 // New creates a new Ring. Being a service, Ring needs to be started to do anything.
@@ -270,6 +299,18 @@ func NewWithStoreClientAndStrategy(cfg Config, name, key string, store kv.Client
 		logger: logger,
 	}
 
+	if cfg.ShuffleShardingZoneFailoverEnabled {
+		r.failedZonesGauge = promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name:        "ring_failed_zones",
+			Help:        "Number of zones whose instances are all unhealthy, last detected when the ring changed with zone failover enabled.",
+			ConstLabels: map[string]string{"name": name}})
+		r.degradedShardsCounters = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name:        "ring_degraded_shuffle_shards_total",
+			Help:        "Total number of shuffle shards cached with the instances of a failed zone replaced by instances of the healthy zones.",
+			ConstLabels: map[string]string{"name": name}},
+			[]string{"zone"})
+	}
+
 	r.Service = services.NewBasicService(r.starting, r.loop, nil).WithName(fmt.Sprintf("%s ring client", name))
 	return r, nil
 }
@@ -329,6 +370,7 @@ func (r *Ring) updateRingState(ringDesc *Desc) {
 		// when watching the ring for updates).
 		r.mtx.Lock()
 		r.ringDesc = ringDesc
+		r.updateFailedZones()
 		r.updateRingMetrics(rc)
 		r.mtx.Unlock()
 		return
@@ -340,6 +382,11 @@ func (r *Ring) updateRingState(ringDesc *Desc) {
 	ringInstanceByToken := ringDesc.getTokensInfo()
 	ringZones := getZones(ringTokensByZone)
 
+	var ringPartitions *partitionsDesc
+	if r.cfg.PartitionsEnabled {
+		ringPartitions = newPartitionsDesc(ringDesc)
+	}
+
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 	r.ringDesc = ringDesc
@@ -347,11 +394,14 @@ func (r *Ring) updateRingState(ringDesc *Desc) {
 	r.ringTokensByZone = ringTokensByZone
 	r.ringInstanceByToken = ringInstanceByToken
 	r.ringZones = ringZones
+	r.ringPartitions = ringPartitions
 	r.lastTopologyChange = now
+	r.recordOwnershipSnapshot(now)
 	if r.shuffledSubringCache != nil {
 		// Invalidate all cached subrings.
 		r.shuffledSubringCache = make(map[subringCacheKey]*Ring)
 	}
+	r.updateFailedZones()
 	r.updateRingMetrics(rc)
 }
 
@@ -366,6 +416,36 @@ func (r *Ring) Get(key uint32, op Operation, bufDescs []InstanceDesc, bufHosts [
 		return ReplicationSet{}, ErrEmptyRing
 	}
 
+	return r.get(key, op, nil, bufDescs, bufHosts, bufZones)
+}
+
+// GetWithBoundedLoads implements ReadRing.
+func (r *Ring) GetWithBoundedLoads(key uint32, op Operation, loads *BoundedLoads, bufDescs []InstanceDesc, bufHosts []string, bufZones map[string]int) (ReplicationSet, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	if r.ringDesc == nil || len(r.ringTokens) == 0 {
+		return ReplicationSet{}, ErrEmptyRing
+	}
+
+	set, err := r.get(key, op, loads, bufDescs, bufHosts, bufZones)
+	if errors.Is(err, errBoundedLoadsExceeded) {
+		// The instances with spare capacity can't form a replication set honoring the zone-awareness,
+		// so the key is assigned to its instances regardless of their load.
+		set, err = r.get(key, op, nil, bufDescs, bufHosts, bufZones)
+	}
+	if err != nil {
+		return ReplicationSet{}, err
+	}
+
+	for _, instance := range set.Instances {
+		loads.Add(instance.Addr)
+	}
+	return set, nil
+}
+
+// get returns the replication set of the key, skipping the instances without spare capacity if loads is not nil.
+// The caller must hold the read lock.
+func (r *Ring) get(key uint32, op Operation, loads *BoundedLoads, bufDescs []InstanceDesc, bufHosts []string, bufZones map[string]int) (ReplicationSet, error) {
 	var (
 		replicationFactor      = r.cfg.ReplicationFactor
 		instances              = bufDescs[:0]
@@ -397,6 +477,11 @@ func (r *Ring) Get(key uint32, op Operation, bufDescs []InstanceDesc, bufHosts [
 			continue
 		}
 
+		// Skip the instances without spare capacity, when the loads are bounded.
+		if loads != nil && !loads.HasCapacity(r.ringDesc.Ingesters[info.InstanceID].Addr) {
+			continue
+		}
+
 		// Ignore if the instances don't have a zone set.
 		if r.cfg.ZoneAwarenessEnabled && info.Zone != "" {
 			maxNumOfInstance := maxInstancePerZone
@@ -434,6 +519,10 @@ func (r *Ring) Get(key uint32, op Operation, bufDescs []InstanceDesc, bufHosts [
 		instances = append(instances, instance)
 	}
 
+	if loads != nil && len(distinctHosts) < replicationFactor {
+		return ReplicationSet{}, errBoundedLoadsExceeded
+	}
+
 	healthyInstances, maxFailure, err := r.strategy.Filter(instances, op, r.cfg.ReplicationFactor, r.cfg.HeartbeatTimeout, r.cfg.ZoneAwarenessEnabled, r.KVClient.LastUpdateTime(r.key))
 	if err != nil {
 		return ReplicationSet{}, err
@@ -740,7 +829,7 @@ func (r *Ring) ShuffleShardWithLookback(identifier string, size int, lookbackPer
 		return r
 	}
 
-	return r.shuffleShard(identifier, size, lookbackPeriod, now, false)
+	return r.shuffleShard(identifier, size, lookbackPeriod, now, false, r.getFailedZones())
 }
 
 func (r *Ring) shuffleShardWithCache(identifier string, size int, zoneStableSharding bool) ReadRing {
@@ -749,17 +838,60 @@ func (r *Ring) shuffleShardWithCache(identifier string, size int, zoneStableShar
 		return r
 	}
 
-	if cached := r.getCachedShuffledSubring(identifier, size, zoneStableSharding); cached != nil {
+	failedZones := r.getFailedZones()
+	if cached := r.getCachedShuffledSubring(identifier, size, zoneStableSharding, failedZones); cached != nil {
 		return cached
 	}
 
-	result := r.shuffleShard(identifier, size, 0, time.Now(), zoneStableSharding)
+	result := r.shuffleShard(identifier, size, 0, time.Now(), zoneStableSharding, failedZones)
 
-	r.setCachedShuffledSubring(identifier, size, zoneStableSharding, result)
+	// The degraded shards are counted once, when cached, given they're built again only when
+	// the ring changes.
+	if r.setCachedShuffledSubring(identifier, size, zoneStableSharding, failedZones, result) && r.degradedShardsCounters != nil {
+		for _, zone := range failedZones {
+			r.degradedShardsCounters.WithLabelValues(zone).Inc()
+		}
+	}
 	return result
 }
 
-func (r *Ring) shuffleShard(identifier string, size int, lookbackPeriod time.Duration, now time.Time, zoneStableSharding bool) *Ring {
+// getFailedZones returns the sorted zones whose instances are all unhealthy, as of the last ring change.
+func (r *Ring) getFailedZones() []string {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	return r.failedZones
+}
+
+// updateFailedZones updates the zones whose instances are all unhealthy, if the zone failover of the shuffle
+// sharding is enabled. No zone is considered failed if all the zones are. It's called whenever the ring changes,
+// including the heartbeats of the instances, so that the shuffle sharding doesn't have to iterate the instances.
+// The lock must be held.
+func (r *Ring) updateFailedZones() {
+	r.failedZones = nil
+	if r.cfg.ShuffleShardingZoneFailoverEnabled && r.cfg.ZoneAwarenessEnabled && len(r.ringZones) >= 2 {
+		storageLastUpdate := r.KVClient.LastUpdateTime(r.key)
+		healthyZones := make(map[string]struct{}, len(r.ringZones))
+		for _, instance := range r.ringDesc.Ingesters {
+			if r.IsHealthy(&instance, Reporting, storageLastUpdate) {
+				healthyZones[instance.Zone] = struct{}{}
+			}
+		}
+
+		if len(healthyZones) > 0 {
+			for _, zone := range r.ringZones {
+				if _, ok := healthyZones[zone]; !ok {
+					r.failedZones = append(r.failedZones, zone)
+				}
+			}
+		}
+	}
+
+	if r.failedZonesGauge != nil {
+		r.failedZonesGauge.Set(float64(len(r.failedZones)))
+	}
+}
+
+func (r *Ring) shuffleShard(identifier string, size int, lookbackPeriod time.Duration, now time.Time, zoneStableSharding bool, failedZones []string) *Ring {
 	lookbackUntil := now.Add(-lookbackPeriod).Unix()
 
 	r.mtx.RLock()
@@ -786,33 +918,9 @@ func (r *Ring) shuffleShard(identifier string, size int, lookbackPeriod time.Dur
 
 	shard := make(map[string]InstanceDesc, size)
 
-	// We need to iterate zones always in the same order to guarantee stability.
-	for _, zone := range actualZones {
-		var tokens []uint32
-
-		if r.cfg.ZoneAwarenessEnabled {
-			tokens = r.ringTokensByZone[zone]
-		} else {
-			// When zone-awareness is disabled, we just iterate over 1 single fake zone
-			// and use all tokens in the ring.
-			tokens = r.ringTokens
-		}
-
-		// Initialise the random generator used to select instances in the ring.
-		// Since we consider each zone like an independent ring, we have to use dedicated
-		// pseudo-random generator for each zone, in order to guarantee the "consistency"
-		// property when the shard size changes or a new zone is added.
-		random := rand.New(rand.NewSource(shardUtil.ShuffleShardSeed(identifier, zone)))
-
-		// To select one more instance while guaranteeing the "consistency" property,
-		// we do pick a random value from the generator and resolve uniqueness collisions
-		// (if any) continuing walking the ring.
-		finalInstancesPerZone := numInstancesPerZone
-		if zonesWithExtraInstance > 0 {
-			zonesWithExtraInstance--
-			finalInstancesPerZone++
-		}
-		for i := 0; i < finalInstancesPerZone; i++ {
+	// selectInstances adds count unique instances to the shard, walking the tokens from random positions.
+	selectInstances := func(tokens []uint32, random *rand.Rand, count int) {
+		for i := 0; i < count; i++ {
 			start := searchToken(tokens, random.Uint32())
 			iterations := 0
 			found := false
@@ -849,19 +957,74 @@ func (r *Ring) shuffleShard(identifier string, size int, lookbackPeriod time.Dur
 			}
 
 			// If one more instance has not been found, we can stop looking for
-			// more instances in this zone, because it means the zone has no more
-			// instances which haven't been already selected.
+			// more instances, because it means there are no more instances which
+			// haven't been already selected.
 			if !found {
 				break
 			}
 		}
 	}
 
+	// Number of instances of the shard to select in the healthy zones, per failed zone.
+	replacedInstancesByZone := make(map[string]int, len(failedZones))
+
+	// We need to iterate zones always in the same order to guarantee stability.
+	for _, zone := range actualZones {
+		var tokens []uint32
+
+		if r.cfg.ZoneAwarenessEnabled {
+			tokens = r.ringTokensByZone[zone]
+		} else {
+			// When zone-awareness is disabled, we just iterate over 1 single fake zone
+			// and use all tokens in the ring.
+			tokens = r.ringTokens
+		}
+
+		// To select one more instance while guaranteeing the "consistency" property,
+		// we do pick a random value from the generator and resolve uniqueness collisions
+		// (if any) continuing walking the ring.
+		finalInstancesPerZone := numInstancesPerZone
+		if zonesWithExtraInstance > 0 {
+			zonesWithExtraInstance--
+			finalInstancesPerZone++
+		}
+
+		// The instances of the failed zones are replaced once the healthy zones are done, so that
+		// the instances selected in the healthy zones don't depend on the failed zones.
+		if slices.Contains(failedZones, zone) {
+			replacedInstancesByZone[zone] = finalInstancesPerZone
+			continue
+		}
+
+		// Initialise the random generator used to select instances in the ring.
+		// Since we consider each zone like an independent ring, we have to use dedicated
+		// pseudo-random generator for each zone, in order to guarantee the "consistency"
+		// property when the shard size changes or a new zone is added.
+		random := rand.New(rand.NewSource(shardUtil.ShuffleShardSeed(identifier, zone)))
+		selectInstances(tokens, random, finalInstancesPerZone)
+	}
+
+	if len(replacedInstancesByZone) > 0 {
+		healthyTokens := make([]uint32, 0, len(r.ringTokens))
+		for _, token := range r.ringTokens {
+			if !slices.Contains(failedZones, r.ringInstanceByToken[token].Zone) {
+				healthyTokens = append(healthyTokens, token)
+			}
+		}
+
+		for _, zone := range failedZones {
+			// The replacements are selected with the generator of the failed zone, so that the shards of
+			// the identifiers having the same instances in the failed zone don't have the same replacements.
+			random := rand.New(rand.NewSource(shardUtil.ShuffleShardSeed(identifier, zone)))
+			selectInstances(healthyTokens, random, replacedInstancesByZone[zone])
+		}
+	}
+
 	// Build a read-only ring for the shard.
 	shardDesc := &Desc{Ingesters: shard}
 	shardTokensByZone := shardDesc.getTokensByZone()
 
-	return &Ring{
+	subring := &Ring{
 		cfg:              r.cfg,
 		strategy:         r.strategy,
 		ringDesc:         shardDesc,
@@ -878,6 +1041,10 @@ func (r *Ring) shuffleShard(identifier string, size int, lookbackPeriod time.Dur
 		// For caching to work, remember these values.
 		lastTopologyChange: r.lastTopologyChange,
 	}
+
+	// The subrings are not updated, so their failed zones are computed once.
+	subring.updateFailedZones()
+	return subring
 }
 
 // GetInstanceState returns the current state of an instance or an error if the
@@ -905,7 +1072,7 @@ func (r *Ring) HasInstance(instanceID string) bool {
 	return ok
 }
 
-func (r *Ring) getCachedShuffledSubring(identifier string, size int, zoneStableSharding bool) *Ring {
+func (r *Ring) getCachedShuffledSubring(identifier string, size int, zoneStableSharding bool, failedZones []string) *Ring {
 	if r.cfg.SubringCacheDisabled {
 		return nil
 	}
@@ -914,7 +1081,7 @@ func (r *Ring) getCachedShuffledSubring(identifier string, size int, zoneStableS
 	defer r.mtx.RUnlock()
 
 	// if shuffledSubringCache map is nil, reading it returns default value (nil pointer).
-	cached := r.shuffledSubringCache[subringCacheKey{identifier: identifier, shardSize: size, zoneStableSharding: zoneStableSharding}]
+	cached := r.shuffledSubringCache[subringCacheKey{identifier: identifier, shardSize: size, zoneStableSharding: zoneStableSharding, failedZones: strings.Join(failedZones, ",")}]
 	if cached == nil {
 		return nil
 	}
@@ -933,9 +1100,10 @@ func (r *Ring) getCachedShuffledSubring(identifier string, size int, zoneStableS
 	return cached
 }
 
-func (r *Ring) setCachedShuffledSubring(identifier string, size int, zoneStableSharding bool, subring *Ring) {
+// setCachedShuffledSubring caches the subring, returning whether it has been cached.
+func (r *Ring) setCachedShuffledSubring(identifier string, size int, zoneStableSharding bool, failedZones []string, subring *Ring) bool {
 	if subring == nil || r.cfg.SubringCacheDisabled {
-		return
+		return false
 	}
 
 	r.mtx.Lock()
@@ -945,8 +1113,10 @@ func (r *Ring) setCachedShuffledSubring(identifier string, size int, zoneStableS
 	// (which can happen between releasing the read lock and getting read-write lock).
 	// Note that shuffledSubringCache can be only nil when set by test.
 	if r.shuffledSubringCache != nil && r.lastTopologyChange.Equal(subring.lastTopologyChange) {
-		r.shuffledSubringCache[subringCacheKey{identifier: identifier, shardSize: size, zoneStableSharding: zoneStableSharding}] = subring
+		r.shuffledSubringCache[subringCacheKey{identifier: identifier, shardSize: size, zoneStableSharding: zoneStableSharding, failedZones: strings.Join(failedZones, ",")}] = subring
+		return true
 	}
+	return false
 }
 
 func (r *Ring) CleanupShuffleShardCache(identifier string) {
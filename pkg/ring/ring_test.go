@@ -2187,6 +2187,127 @@ func TestRing_ShuffleShardWithZoneStability_ConsistencyOnZonesChanged(t *testing
 	}
 }
 
+func TestRing_ShuffleShard_ZoneFailover(t *testing.T) {
+	const (
+		userID    = "user-1"
+		shardSize = 6
+	)
+
+	g := NewRandomTokenGenerator()
+	tokens := map[string][]uint32{}
+
+	newRing := func(t *testing.T, failoverEnabled bool, failedZones ...string) (*Ring, *prometheus.Registry) {
+		reg := prometheus.NewPedanticRegistry()
+		cfg := Config{
+			HeartbeatTimeout:                   time.Minute,
+			ReplicationFactor:                  3,
+			ZoneAwarenessEnabled:               true,
+			ShuffleShardingZoneFailoverEnabled: failoverEnabled,
+		}
+		r, err := NewWithStoreClientAndStrategy(cfg, "test", "test", &MockClient{}, NewDefaultReplicationStrategy(), reg, log.NewNopLogger())
+		require.NoError(t, err)
+
+		desc := NewDesc()
+		for _, zone := range []string{"zone-a", "zone-b", "zone-c"} {
+			for i := 0; i < 6; i++ {
+				id := fmt.Sprintf("%s-instance-%d", zone, i)
+				// The instances have the same tokens in all the rings.
+				if _, ok := tokens[id]; !ok {
+					tokens[id] = g.GenerateTokens(desc, id, zone, 128, true)
+				}
+				instance := desc.AddIngester(id, id, zone, tokens[id], ACTIVE, time.Now())
+				if util.StringsContain(failedZones, zone) {
+					instance.Timestamp = time.Now().Add(-time.Hour).Unix()
+					desc.Ingesters[id] = instance
+				}
+			}
+		}
+		r.updateRingState(desc)
+		return r, reg
+	}
+
+	shardInstances := func(t *testing.T, r ReadRing) []string {
+		healthy, unhealthy, err := r.GetAllInstanceDescs(Reporting)
+		require.NoError(t, err)
+
+		var ids []string
+		for _, instance := range append(healthy, unhealthy...) {
+			ids = append(ids, instance.Addr)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+	zonesOf := func(ids []string) map[string]int {
+		zones := map[string]int{}
+		for _, id := range ids {
+			zones[id[:len("zone-a")]]++
+		}
+		return zones
+	}
+
+	healthyRing, _ := newRing(t, true)
+	expected := shardInstances(t, healthyRing.ShuffleShard(userID, shardSize))
+	require.Equal(t, map[string]int{"zone-a": 2, "zone-b": 2, "zone-c": 2}, zonesOf(expected))
+
+	t.Run("failover disabled", func(t *testing.T) {
+		r, _ := newRing(t, false, "zone-c")
+		assert.Equal(t, expected, shardInstances(t, r.ShuffleShard(userID, shardSize)))
+	})
+
+	t.Run("failover enabled", func(t *testing.T) {
+		r, reg := newRing(t, true, "zone-c")
+		actual := shardInstances(t, r.ShuffleShard(userID, shardSize))
+		require.Len(t, actual, shardSize)
+		assert.Equal(t, 0, zonesOf(actual)["zone-c"])
+
+		// The instances selected in the healthy zones are kept.
+		for _, id := range expected {
+			if !strings.HasPrefix(id, "zone-c") {
+				assert.Contains(t, actual, id)
+			}
+		}
+
+		// The shard is stable, and cached.
+		assert.Equal(t, actual, shardInstances(t, r.ShuffleShard(userID, shardSize)))
+		assert.Equal(t, actual, shardInstances(t, r.ShuffleShardWithZoneStability(userID, shardSize)))
+
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP ring_degraded_shuffle_shards_total Total number of shuffle shards cached with the instances of a failed zone replaced by instances of the healthy zones.
+			# TYPE ring_degraded_shuffle_shards_total counter
+			ring_degraded_shuffle_shards_total{name="test",zone="zone-c"} 2
+			# HELP ring_failed_zones Number of zones whose instances are all unhealthy, last detected when the ring changed with zone failover enabled.
+			# TYPE ring_failed_zones gauge
+			ring_failed_zones{name="test"} 1
+		`), "ring_degraded_shuffle_shards_total", "ring_failed_zones"))
+
+		// The lookback shard fails over too, but it's not cached so it's not counted.
+		assert.Equal(t, actual, shardInstances(t, r.ShuffleShardWithLookback(userID, shardSize, time.Hour, time.Now().Add(2*time.Hour))))
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP ring_degraded_shuffle_shards_total Total number of shuffle shards cached with the instances of a failed zone replaced by instances of the healthy zones.
+			# TYPE ring_degraded_shuffle_shards_total counter
+			ring_degraded_shuffle_shards_total{name="test",zone="zone-c"} 2
+		`), "ring_degraded_shuffle_shards_total"))
+	})
+
+	t.Run("failover enabled, zone recovered", func(t *testing.T) {
+		r, _ := newRing(t, true, "zone-c")
+		require.Len(t, shardInstances(t, r.ShuffleShard(userID, shardSize)), shardSize)
+
+		desc := r.ringDesc.Clone().(*Desc)
+		for id, instance := range desc.Ingesters {
+			instance.Timestamp = time.Now().Unix()
+			desc.Ingesters[id] = instance
+		}
+		r.updateRingState(desc)
+		assert.Equal(t, expected, shardInstances(t, r.ShuffleShard(userID, shardSize)))
+	})
+
+	t.Run("failover enabled, all zones failed", func(t *testing.T) {
+		r, _ := newRing(t, true, "zone-a", "zone-b", "zone-c")
+		assert.Equal(t, expected, shardInstances(t, r.ShuffleShard(userID, shardSize)))
+	})
+}
+
 func TestRing_ShuffleShardWithLookback(t *testing.T) {
 	type eventType int
 
@@ -2470,7 +2591,7 @@ func TestRing_ShuffleShardWithLookback_CorrectnessWithFuzzy(t *testing.T) {
 					currTime := time.Now().Add(lookbackPeriod).Add(time.Minute)
 
 					// Add the initial shard to the history.
-					rs, err := ring.shuffleShard(userID, shardSize, 0, time.Now(), enableStableSharding).GetReplicationSetForOperation(Read)
+					rs, err := ring.shuffleShard(userID, shardSize, 0, time.Now(), enableStableSharding, nil).GetReplicationSetForOperation(Read)
 					require.NoError(t, err)
 
 					history := map[time.Time]ReplicationSet{
@@ -2536,7 +2657,7 @@ func TestRing_ShuffleShardWithLookback_CorrectnessWithFuzzy(t *testing.T) {
 						}
 
 						// Add the current shard to the history.
-						rs, err = ring.shuffleShard(userID, shardSize, 0, time.Now(), enableStableSharding).GetReplicationSetForOperation(Read)
+						rs, err = ring.shuffleShard(userID, shardSize, 0, time.Now(), enableStableSharding, nil).GetReplicationSetForOperation(Read)
 						require.NoError(t, err)
 						history[currTime] = rs
 
@@ -0,0 +1,196 @@
+package ring
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+
+	"github.com/cortexproject/cortex/pkg/ring/kv"
+)
+
+var (
+	errRingNotEmpty        = errors.New("the ring is not empty, set force=true to overwrite it")
+	errUnknownSnapshotRing = errors.New("unknown ring in the snapshot")
+)
+
+// SnapshotTarget is a ring included in the snapshots, stored under the key of the KV store.
+type SnapshotTarget struct {
+	Name     string
+	Key      string
+	KVConfig kv.Config
+}
+
+// Snapshot is the state of the rings, dumped to restore them into a fresh KV store for disaster recovery.
+type Snapshot struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Rings     []RingSnapshot `json:"rings"`
+}
+
+// RingSnapshot is the state of a ring.
+type RingSnapshot struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	Desc *Desc  `json:"desc"`
+}
+
+// SnapshotHandler serves the admin endpoint to dump the state of the rings of all the components to a snapshot,
+// and to restore a snapshot. The KV clients of the rings are created on the first use, so that the rings of the
+// components not running in this process can be dumped and restored too.
+type SnapshotHandler struct {
+	targets []SnapshotTarget
+	logger  log.Logger
+
+	clientsMtx sync.Mutex
+	clients    map[string]kv.Client
+
+	// Used by tests to override the KV clients.
+	newClient func(SnapshotTarget) (kv.Client, error)
+}
+
+func NewSnapshotHandler(targets []SnapshotTarget, logger log.Logger) *SnapshotHandler {
+	return &SnapshotHandler{
+		targets: targets,
+		logger:  logger,
+		clients: map[string]kv.Client{},
+		newClient: func(target SnapshotTarget) (kv.Client, error) {
+			return kv.NewClient(target.KVConfig, GetCodec(), nil, logger)
+		},
+	}
+}
+
+func (h *SnapshotHandler) client(target SnapshotTarget) (kv.Client, error) {
+	h.clientsMtx.Lock()
+	defer h.clientsMtx.Unlock()
+
+	if c, ok := h.clients[target.Name]; ok {
+		return c, nil
+	}
+	c, err := h.newClient(target)
+	if err != nil {
+		return nil, errors.Wrapf(err, "create KV client of the %s ring", target.Name)
+	}
+	h.clients[target.Name] = c
+	return c, nil
+}
+
+// Dump returns the snapshot of the rings. The empty rings are not included.
+func (h *SnapshotHandler) Dump(ctx context.Context) (*Snapshot, error) {
+	snapshot := &Snapshot{Timestamp: time.Now(), Rings: []RingSnapshot{}}
+	for _, target := range h.targets {
+		c, err := h.client(target)
+		if err != nil {
+			return nil, err
+		}
+		val, err := c.Get(ctx, target.Key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get the %s ring", target.Name)
+		}
+		desc := GetOrCreateRingDesc(val)
+		if len(desc.Ingesters) == 0 {
+			continue
+		}
+		snapshot.Rings = append(snapshot.Rings, RingSnapshot{Name: target.Name, Key: target.Key, Desc: desc})
+	}
+	return snapshot, nil
+}
+
+// Restore writes the rings of the snapshot to their KV store. The rings which are not empty are only
+// overwritten if force is true. The instances keep the heartbeat timestamps of the snapshot, so they're
+// unhealthy until they heartbeat the restored ring, but they keep their tokens when they restart.
+func (h *SnapshotHandler) Restore(ctx context.Context, snapshot *Snapshot, force bool) ([]string, error) {
+	targets := make(map[string]SnapshotTarget, len(h.targets))
+	for _, target := range h.targets {
+		targets[target.Name] = target
+	}
+	for _, ring := range snapshot.Rings {
+		if _, ok := targets[ring.Name]; !ok {
+			return nil, errors.Wrap(errUnknownSnapshotRing, ring.Name)
+		}
+	}
+
+	restored := []string{}
+	for _, ring := range snapshot.Rings {
+		target := targets[ring.Name]
+		c, err := h.client(target)
+		if err != nil {
+			return restored, err
+		}
+
+		err = c.CAS(ctx, target.Key, func(in interface{}) (out interface{}, retry bool, err error) {
+			if !force && len(GetOrCreateRingDesc(in).Ingesters) > 0 {
+				return nil, false, errRingNotEmpty
+			}
+			return ring.Desc, true, nil
+		})
+		if err != nil {
+			return restored, errors.Wrapf(err, "restore the %s ring", ring.Name)
+		}
+
+		level.Info(h.logger).Log("msg", "restored ring from snapshot", "ring", ring.Name, "instances", len(ring.Desc.GetIngesters()), "snapshot_timestamp", snapshot.Timestamp)
+		restored = append(restored, ring.Name)
+	}
+	return restored, nil
+}
+
+// ServeHTTP returns the snapshot of the rings. The POST requests restore the snapshot of the request body instead,
+// overwriting the rings which are not empty if the "force" parameter is true.
+func (h *SnapshotHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodPost {
+		h.handleRestore(w, req)
+		return
+	}
+
+	snapshot, err := h.Dump(req.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=ring-snapshot-%d.json", snapshot.Timestamp.Unix()))
+	if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *SnapshotHandler) handleRestore(w http.ResponseWriter, req *http.Request) {
+	force := false
+	if v := req.URL.Query().Get("force"); v != "" {
+		var err error
+		if force, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, fmt.Sprintf("invalid force parameter: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	snapshot := &Snapshot{}
+	if err := json.NewDecoder(req.Body).Decode(snapshot); err != nil {
+		http.Error(w, fmt.Sprintf("invalid snapshot: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	restored, err := h.Restore(req.Context(), snapshot, force)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errUnknownSnapshotRing) {
+			status = http.StatusBadRequest
+		} else if errors.Is(err, errRingNotEmpty) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string][]string{"restored": restored}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
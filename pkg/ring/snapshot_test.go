@@ -0,0 +1,103 @@
+package ring
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/ring/kv"
+	"github.com/cortexproject/cortex/pkg/ring/kv/consul"
+)
+
+func newSnapshotTestHandler(t *testing.T) (*SnapshotHandler, map[string]kv.Client) {
+	stores := map[string]kv.Client{}
+	for _, name := range []string{"ingester", "store-gateway"} {
+		store, closer := consul.NewInMemoryClient(GetCodec(), log.NewNopLogger(), nil)
+		t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+		stores[name] = store
+	}
+
+	h := NewSnapshotHandler([]SnapshotTarget{
+		{Name: "ingester", Key: "ring"},
+		{Name: "store-gateway", Key: "store-gateway"},
+	}, log.NewNopLogger())
+	h.newClient = func(target SnapshotTarget) (kv.Client, error) {
+		return stores[target.Name], nil
+	}
+	return h, stores
+}
+
+func TestSnapshotHandler_DumpAndRestore(t *testing.T) {
+	ctx := context.Background()
+	source, sourceStores := newSnapshotTestHandler(t)
+	target, targetStores := newSnapshotTestHandler(t)
+
+	desc := NewDesc()
+	desc.AddIngester("ingester-1", "127.0.0.1", "zone-a", []uint32{1, 2}, ACTIVE, time.Now())
+	desc.AddIngester("ingester-2", "127.0.0.2", "zone-b", []uint32{3, 4}, LEAVING, time.Now())
+	require.NoError(t, sourceStores["ingester"].CAS(ctx, "ring", func(interface{}) (interface{}, bool, error) {
+		return desc, true, nil
+	}))
+
+	// Dump the snapshot via the admin endpoint. The empty rings are not included.
+	rec := httptest.NewRecorder()
+	source.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ring/snapshot", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), "attachment")
+
+	snapshot := &Snapshot{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), snapshot))
+	require.Len(t, snapshot.Rings, 1)
+	assert.Equal(t, "ingester", snapshot.Rings[0].Name)
+	assert.Equal(t, "ring", snapshot.Rings[0].Key)
+
+	// Restore it into the fresh KV store.
+	rec = httptest.NewRecorder()
+	target.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ring/snapshot", bytes.NewReader(mustMarshalJSON(t, snapshot))))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"restored":["ingester"]}`, rec.Body.String())
+
+	restored, err := targetStores["ingester"].Get(ctx, "ring")
+	require.NoError(t, err)
+	assert.Equal(t, desc.Ingesters, restored.(*Desc).Ingesters)
+
+	// The restored rings are not overwritten, unless forced.
+	rec = httptest.NewRecorder()
+	target.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ring/snapshot", bytes.NewReader(mustMarshalJSON(t, snapshot))))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = httptest.NewRecorder()
+	target.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ring/snapshot?force=true", bytes.NewReader(mustMarshalJSON(t, snapshot))))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestSnapshotHandler_RestoreUnknownRing(t *testing.T) {
+	h, stores := newSnapshotTestHandler(t)
+
+	snapshot := &Snapshot{Rings: []RingSnapshot{
+		{Name: "ingester", Key: "ring", Desc: NewDesc()},
+		{Name: "unknown", Key: "unknown", Desc: NewDesc()},
+	}}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ring/snapshot", bytes.NewReader(mustMarshalJSON(t, snapshot))))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Nothing has been restored.
+	val, err := stores["ingester"].Get(context.Background(), "ring")
+	require.NoError(t, err)
+	assert.Nil(t, val)
+}
+
+func mustMarshalJSON(t *testing.T, v interface{}) []byte {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return data
+}
@@ -2,11 +2,18 @@ package ring
 
 import (
 	"container/heap"
+	"fmt"
 	"math"
 	"math/rand"
+	"os"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
 )
 
 const (
@@ -14,12 +21,50 @@ const (
 
 	minimizeSpreadTokenStrategy = "minimize-spread"
 	randomTokenStrategy         = "random"
+	evenlySpacedTokenStrategy   = "evenly-spaced"
+	staticTokenStrategy         = "static"
 )
 
 var (
-	supportedTokenStrategy = []string{strings.ToLower(randomTokenStrategy), strings.ToLower(minimizeSpreadTokenStrategy)}
+	supportedTokenStrategy = []string{strings.ToLower(randomTokenStrategy), strings.ToLower(minimizeSpreadTokenStrategy), evenlySpacedTokenStrategy, staticTokenStrategy}
+
+	errTokensGeneratorStaticFileRequired = errors.New("the static tokens file is required by the static token generator strategy")
 )
 
+// TokensGeneratorStrategyFlagDescription is the description of the flags selecting the token generator strategy.
+var TokensGeneratorStrategyFlagDescription = fmt.Sprintf("EXPERIMENTAL: Algorithm used to generate new ring tokens. "+
+	"The evenly-spaced strategy places the tokens of each instance at regular intervals, shifted according to the ordinal at the end of the instance ID. "+
+	"The static strategy reads the tokens of each instance from the static tokens file. Supported Values: %s", strings.Join(supportedTokenStrategy, ","))
+
+// ValidateTokensGenerator validates the token generator strategy and its static tokens file.
+func ValidateTokensGenerator(strategy, staticFile string) error {
+	if strategy != "" && !slices.Contains(supportedTokenStrategy, strings.ToLower(strategy)) {
+		return errInvalidTokensGeneratorStrategy
+	}
+	if strings.EqualFold(strategy, staticTokenStrategy) && staticFile == "" {
+		return errTokensGeneratorStaticFileRequired
+	}
+	return nil
+}
+
+// NewTokenGenerator makes the token generator of the strategy, the random one if the strategy is empty.
+func NewTokenGenerator(strategy, staticFile string) (TokenGenerator, error) {
+	if err := ValidateTokensGenerator(strategy, staticFile); err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(strategy) {
+	case minimizeSpreadTokenStrategy:
+		return NewMinimizeSpreadTokenGenerator(), nil
+	case evenlySpacedTokenStrategy:
+		return NewEvenlySpacedTokenGenerator(), nil
+	case staticTokenStrategy:
+		return NewStaticTokenGenerator(staticFile)
+	default:
+		return NewRandomTokenGenerator(), nil
+	}
+}
+
 type TokenGenerator interface {
 	// GenerateTokens make numTokens unique random tokens, none of which clash
 	// with takenTokens. Generated tokens are sorted.
@@ -278,3 +323,138 @@ func findFirst(n int, f func(int) bool) int {
 	}
 	return n
 }
+
+// EvenlySpacedTokenGenerator places the tokens of each instance at regular intervals on the ring, shifted by an offset
+// derived from the ordinal at the end of the instance ID (eg. 3 for ingester-3). The offsets follow the van der Corput
+// sequence, so that the first N instances of a zone split the ring evenly whatever N is, and adding or removing an
+// instance never moves the tokens of the other instances.
+type EvenlySpacedTokenGenerator struct {
+	fallbackGenerator TokenGenerator
+}
+
+func NewEvenlySpacedTokenGenerator() TokenGenerator {
+	return &EvenlySpacedTokenGenerator{
+		fallbackGenerator: NewRandomTokenGenerator(),
+	}
+}
+
+// GenerateTokens generates the evenly spaced tokens of the instance, or random tokens if the instance ID doesn't end
+// with an ordinal.
+func (g *EvenlySpacedTokenGenerator) GenerateTokens(ring *Desc, id, zone string, numTokens int, force bool) []uint32 {
+	if numTokens <= 0 {
+		return []uint32{}
+	}
+
+	ordinal, ok := instanceOrdinal(id)
+	if !ok {
+		return g.fallbackGenerator.GenerateTokens(ring, id, zone, numTokens, force)
+	}
+
+	used := make(map[uint32]bool)
+	for _, v := range ring.GetTokens() {
+		used[v] = true
+	}
+
+	step := (uint64(maxTokenValue) + 1) / uint64(numTokens)
+	offset := uint64(vanDerCorput(ordinal) * float64(step))
+
+	tokens := make([]uint32, 0, numTokens)
+	for i := 0; i < numTokens; i++ {
+		token := uint32(offset + uint64(i)*step)
+		// Another instance with the same ordinal, eg. in another zone, owns the token.
+		for used[token] {
+			token++
+		}
+		used[token] = true
+		tokens = append(tokens, token)
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i] < tokens[j]
+	})
+	return tokens
+}
+
+// instanceOrdinal returns the number at the end of the instance ID, and whether there is one.
+func instanceOrdinal(id string) (uint64, bool) {
+	start := len(id)
+	for start > 0 && id[start-1] >= '0' && id[start-1] <= '9' {
+		start--
+	}
+	if start == len(id) {
+		return 0, false
+	}
+	ordinal, err := strconv.ParseUint(id[start:], 10, 64)
+	return ordinal, err == nil
+}
+
+// vanDerCorput returns the n-th element of the base 2 van der Corput sequence, which is n with its bits reversed
+// after the binary point: 0, 0.5, 0.25, 0.75, 0.125...
+func vanDerCorput(n uint64) float64 {
+	result, fraction := 0.0, 0.5
+	for ; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			result += fraction
+		}
+		fraction /= 2
+	}
+	return result
+}
+
+// StaticTokenGenerator assigns the tokens of each instance read from a static tokens file, mapping the instance IDs
+// to their tokens in YAML. The missing tokens of the instances, and the tokens of the instances not in the file, are
+// generated randomly.
+type StaticTokenGenerator struct {
+	tokens            map[string][]uint32
+	fallbackGenerator TokenGenerator
+}
+
+func NewStaticTokenGenerator(staticFile string) (TokenGenerator, error) {
+	content, err := os.ReadFile(staticFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "read static tokens file")
+	}
+
+	tokens := map[string][]uint32{}
+	if err := yaml.UnmarshalStrict(content, &tokens); err != nil {
+		return nil, errors.Wrapf(err, "parse static tokens file %s", staticFile)
+	}
+
+	return &StaticTokenGenerator{
+		tokens:            tokens,
+		fallbackGenerator: NewRandomTokenGenerator(),
+	}, nil
+}
+
+// GenerateTokens returns the tokens of the instance from the static tokens file which aren't in the ring yet.
+func (g *StaticTokenGenerator) GenerateTokens(ring *Desc, id, zone string, numTokens int, force bool) []uint32 {
+	if numTokens <= 0 {
+		return []uint32{}
+	}
+
+	used := make(map[uint32]bool)
+	for _, v := range ring.GetTokens() {
+		used[v] = true
+	}
+
+	tokens := make([]uint32, 0, numTokens)
+	for _, token := range g.tokens[id] {
+		if len(tokens) == numTokens {
+			break
+		}
+		if used[token] {
+			continue
+		}
+		used[token] = true
+		tokens = append(tokens, token)
+	}
+
+	if len(tokens) < numTokens {
+		tokens = append(tokens, g.fallbackGenerator.GenerateTokens(ring, id, zone, numTokens-len(tokens), true)...)
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i] < tokens[j]
+	})
+	return tokens
+}
@@ -3,11 +3,14 @@ package ring
 import (
 	"fmt"
 	"math"
+	"os"
+	"path/filepath"
 	"slices"
 	"strconv"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -21,6 +24,9 @@ func TestGenerateTokens(t *testing.T) {
 		"minimizeSpread": {
 			tg: NewMinimizeSpreadTokenGenerator(),
 		},
+		"evenlySpaced": {
+			tg: NewEvenlySpacedTokenGenerator(),
+		},
 	}
 
 	for name, tc := range testCase {
@@ -50,6 +56,9 @@ func TestGenerateTokens_IgnoresOldTokens(t *testing.T) {
 		"minimizeSpread": {
 			tg: NewMinimizeSpreadTokenGenerator(),
 		},
+		"evenlySpaced": {
+			tg: NewEvenlySpacedTokenGenerator(),
+		},
 	}
 
 	for name, c := range testCase {
@@ -184,3 +193,107 @@ func assertDistancePerIngester(t testing.TB, d *Desc, tolerance float64) {
 		}, "[%v] expected and real distance error is greater than %v -> %v[%v/%v]", s, tolerance, 1-math.Abs(expectedDistance/realDistance), expectedDistance, realDistance)
 	}
 }
+
+func TestEvenlySpacedTokenGenerator(t *testing.T) {
+	tg := NewEvenlySpacedTokenGenerator()
+	d := NewDesc()
+
+	// The tokens of the first instance are evenly spaced from 0.
+	tokens := tg.GenerateTokens(d, "ingester-0", "", 4, true)
+	require.Equal(t, []uint32{0, 1 << 30, 2 << 30, 3 << 30}, tokens)
+	d.AddIngester("ingester-0", "ingester-0", "", tokens, ACTIVE, time.Now())
+
+	// The tokens of the next instances split the intervals between the existing tokens in halves.
+	tokens = tg.GenerateTokens(d, "ingester-1", "", 4, true)
+	require.Equal(t, []uint32{1 << 29, 3 << 29, 5 << 29, 7 << 29}, tokens)
+	d.AddIngester("ingester-1", "ingester-1", "", tokens, ACTIVE, time.Now())
+
+	// The tokens don't depend on the other instances, unless they are already taken.
+	require.Equal(t, tg.GenerateTokens(NewDesc(), "ingester-2", "", 4, true), tg.GenerateTokens(d, "ingester-2", "", 4, true))
+	tokens = tg.GenerateTokens(d, "zone-b-ingester-0", "", 4, true)
+	require.Equal(t, []uint32{1, 1<<30 + 1, 2<<30 + 1, 3<<30 + 1}, tokens)
+
+	// The instances without ordinal get random tokens.
+	tokens = tg.GenerateTokens(d, "ingester", "", 4, true)
+	require.Len(t, tokens, 4)
+	require.True(t, slices.IsSorted(tokens))
+
+	require.Empty(t, tg.GenerateTokens(d, "ingester-3", "", 0, true))
+}
+
+func TestInstanceOrdinal(t *testing.T) {
+	for id, expected := range map[string]uint64{"ingester-0": 0, "ingester-12": 12, "ingester-zone-a-7": 7, "42": 42} {
+		ordinal, ok := instanceOrdinal(id)
+		assert.True(t, ok, id)
+		assert.Equal(t, expected, ordinal, id)
+	}
+
+	for _, id := range []string{"", "ingester", "ingester-1a"} {
+		_, ok := instanceOrdinal(id)
+		assert.False(t, ok, id)
+	}
+}
+
+func TestVanDerCorput(t *testing.T) {
+	expected := []float64{0, 0.5, 0.25, 0.75, 0.125, 0.625, 0.375, 0.875}
+	for n, v := range expected {
+		assert.Equal(t, v, vanDerCorput(uint64(n)))
+	}
+}
+
+func TestStaticTokenGenerator(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tokens.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("ingester-0: [30, 10, 20]\ningester-1: [40, 50]\n"), 0o666))
+
+	tg, err := NewStaticTokenGenerator(file)
+	require.NoError(t, err)
+	d := NewDesc()
+
+	tokens := tg.GenerateTokens(d, "ingester-0", "", 3, true)
+	require.Equal(t, []uint32{10, 20, 30}, tokens)
+	d.AddIngester("ingester-0", "ingester-0", "", tokens, ACTIVE, time.Now())
+
+	// The missing tokens are generated randomly.
+	tokens = tg.GenerateTokens(d, "ingester-1", "", 3, true)
+	require.Len(t, tokens, 3)
+	require.Subset(t, tokens, []uint32{40, 50})
+	require.True(t, slices.IsSorted(tokens))
+
+	// The tokens already in the ring are skipped.
+	d.AddIngester("ingester-2", "ingester-2", "", []uint32{40}, ACTIVE, time.Now())
+	tokens = tg.GenerateTokens(d, "ingester-1", "", 3, true)
+	require.Len(t, tokens, 3)
+	require.Contains(t, tokens, uint32(50))
+	require.NotContains(t, tokens, uint32(40))
+
+	require.Len(t, tg.GenerateTokens(d, "ingester-3", "", 5, true), 5)
+
+	require.NoError(t, os.WriteFile(file, []byte("ingester-0: [-1]\n"), 0o666))
+	_, err = NewStaticTokenGenerator(file)
+	require.Error(t, err)
+
+	_, err = NewStaticTokenGenerator(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+}
+
+func TestNewTokenGenerator(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tokens.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("ingester-0: [10]\n"), 0o666))
+
+	for strategy, expected := range map[string]TokenGenerator{
+		"":                &RandomTokenGenerator{},
+		"random":          &RandomTokenGenerator{},
+		"Minimize-Spread": &MinimizeSpreadTokenGenerator{},
+		"evenly-spaced":   &EvenlySpacedTokenGenerator{},
+		"static":          &StaticTokenGenerator{},
+	} {
+		tg, err := NewTokenGenerator(strategy, file)
+		require.NoError(t, err, strategy)
+		assert.IsType(t, expected, tg, strategy)
+	}
+
+	_, err := NewTokenGenerator("unknown", "")
+	assert.ErrorIs(t, err, errInvalidTokensGeneratorStrategy)
+	_, err = NewTokenGenerator("static", "")
+	assert.ErrorIs(t, err, errTokensGeneratorStaticFileRequired)
+}
@@ -3,6 +3,7 @@ package ring
 import (
 	"context"
 	"fmt"
+	mathrand "math/rand"
 	"net"
 	"sort"
 	"strings"
@@ -14,6 +15,17 @@ import (
 	"github.com/cortexproject/cortex/pkg/util/backoff"
 )
 
+// heartbeatsOffset is the offset of the aligned heartbeats of the lifecyclers of the process. It's random,
+// so that the heartbeats of the different processes are still spread over time.
+var heartbeatsOffset = time.Duration(mathrand.Int63n(int64(time.Hour)))
+
+// alignedHeartbeatDelay returns the delay until the next heartbeat aligned to the heartbeat period. The
+// lifecyclers of the process with the same heartbeat period, or a multiple of it, heartbeat at the same
+// time, so that their ring updates are batched together.
+func alignedHeartbeatDelay(now time.Time, period time.Duration) time.Duration {
+	return period - time.Duration((now.UnixNano()+int64(heartbeatsOffset))%int64(period))
+}
+
 // GetInstanceAddr returns the address to use to register the instance
 // in the ring.
 func GetInstanceAddr(configAddr string, netInterfaces []string, logger log.Logger) (string, error) {
@@ -26,6 +26,11 @@ func (r *RingMock) Get(key uint32, op Operation, bufDescs []InstanceDesc, bufHos
 	return args.Get(0).(ReplicationSet), args.Error(1)
 }
 
+func (r *RingMock) GetWithBoundedLoads(key uint32, op Operation, loads *BoundedLoads, bufDescs []InstanceDesc, bufHosts []string, bufZones map[string]int) (ReplicationSet, error) {
+	args := r.Called(key, op, loads, bufDescs, bufHosts, bufZones)
+	return args.Get(0).(ReplicationSet), args.Error(1)
+}
+
 func (r *RingMock) GetAllHealthy(op Operation) (ReplicationSet, error) {
 	args := r.Called(op)
 	return args.Get(0).(ReplicationSet), args.Error(1)
@@ -401,6 +406,23 @@ func TestWaitInstanceState_ExitsAfterActualStateEqualsState(t *testing.T) {
 	ring.AssertNumberOfCalls(t, "GetInstanceState", 1)
 }
 
+func TestAlignedHeartbeatDelay(t *testing.T) {
+	for _, now := range []time.Time{time.Now(), time.Now().Add(3 * time.Second), time.Now().Add(time.Hour + 7*time.Second)} {
+		aligned := map[time.Duration]time.Time{}
+		for _, period := range []time.Duration{5 * time.Second, 15 * time.Second} {
+			delay := alignedHeartbeatDelay(now, period)
+			assert.Greater(t, delay, time.Duration(0))
+			assert.LessOrEqual(t, delay, period)
+			aligned[period] = now.Add(delay)
+		}
+
+		// The heartbeats with a period multiple of another one are aligned with it.
+		assert.Zero(t, aligned[15*time.Second].Sub(aligned[5*time.Second])%(5*time.Second))
+		// The heartbeats are aligned whenever the delay is computed.
+		assert.Equal(t, aligned[5*time.Second], now.Add(time.Second).Add(alignedHeartbeatDelay(now.Add(time.Second), 5*time.Second)))
+	}
+}
+
 func TestResetZoneMap(t *testing.T) {
 	zoneMap := map[string]int{
 		"zone-1": 2,
@@ -0,0 +1,226 @@
+package ruler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/notifier"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/weaveworks/common/user"
+	"golang.org/x/net/context/ctxhttp"
+
+	"github.com/cortexproject/cortex/pkg/ruler/rulespb"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+const (
+	errAlertmanagerTenantNotAllowed = "the tenant %s is not allowed to receive the alerts of the rule groups (allowed alertmanager tenants: %v)"
+	errAlertmanagerURLNotAllowed    = "the alertmanager URL %s is not allowed to receive the alerts of the rule groups (allowed alertmanager URLs: %v)"
+	errInvalidAlertmanagerURL       = "invalid alertmanager URL %s of the rule group"
+)
+
+// checkAlertmanagerTenants returns an error if any of the alertmanager tenants of a rule group is not
+// a valid tenant ID or is not allowed for the tenant owning the rule group.
+func checkAlertmanagerTenants(alertmanagerTenants, allowed []string) error {
+	for _, alertmanagerTenant := range alertmanagerTenants {
+		if err := tenant.ValidTenantID(alertmanagerTenant); err != nil {
+			return err
+		}
+		if !util.StringsContain(allowed, alertmanagerTenant) {
+			return fmt.Errorf(errAlertmanagerTenantNotAllowed, alertmanagerTenant, allowed)
+		}
+	}
+	return nil
+}
+
+// checkAlertmanagerURLs returns an error if any of the alertmanager URLs of a rule group is not a valid
+// HTTP URL or is not allowed for the tenant owning the rule group. The URLs must match the allowed ones.
+func checkAlertmanagerURLs(alertmanagerURLs, allowed []string) error {
+	for _, alertmanagerURL := range alertmanagerURLs {
+		u, err := url.Parse(alertmanagerURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf(errInvalidAlertmanagerURL, alertmanagerURL)
+		}
+		if !util.StringsContain(allowed, alertmanagerURL) {
+			return fmt.Errorf(errAlertmanagerURLNotAllowed, alertmanagerURL, allowed)
+		}
+	}
+	return nil
+}
+
+// alertmanagerRoute is an additional alertmanager receiving the alerts of a rule group: either the
+// alertmanager of another tenant, or an alertmanager URL.
+type alertmanagerRoute struct {
+	tenant string
+	url    string
+}
+
+// alertmanagerRoutesFor returns the additional alertmanagers of the alerts of the rule group.
+func alertmanagerRoutesFor(g federatedRuleGroup) []alertmanagerRoute {
+	routes := make([]alertmanagerRoute, 0, len(g.alertmanagerTenants)+len(g.alertmanagerURLs))
+	for _, t := range g.alertmanagerTenants {
+		routes = append(routes, alertmanagerRoute{tenant: t})
+	}
+	for _, u := range g.alertmanagerURLs {
+		routes = append(routes, alertmanagerRoute{url: u})
+	}
+	return routes
+}
+
+// alertmanagerNotifiers returns the notifier sending the alerts of a tenant to an additional alertmanager.
+type alertmanagerNotifiers func(route alertmanagerRoute) (sender, error)
+
+// alertmanagerRouter sends the alerts of the rule groups of a tenant to their additional alertmanagers,
+// besides the alertmanager of the tenant.
+type alertmanagerRouter struct {
+	groups    *federatedRuleGroups
+	overrides RulesLimits
+	userID    string
+	logger    log.Logger
+
+	mtx       sync.RWMutex
+	notifiers alertmanagerNotifiers
+}
+
+func (r *alertmanagerRouter) setNotifiers(notifiers alertmanagerNotifiers) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.notifiers = notifiers
+}
+
+func (r *alertmanagerRouter) getNotifiers() alertmanagerNotifiers {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	return r.notifiers
+}
+
+// notifyFunc returns a notify function sending the alerts to the alertmanager of the tenant with the
+// given notify function, and to the additional alertmanagers of the rule group evaluated in the context.
+func (r *alertmanagerRouter) notifyFunc(notify promRules.NotifyFunc, externalURL string) promRules.NotifyFunc {
+	return func(ctx context.Context, expr string, alerts ...*promRules.Alert) {
+		notify(ctx, expr, alerts...)
+
+		notifiers := r.getNotifiers()
+		ref, ok := ruleGroupRefFromContext(ctx)
+		if !ok || notifiers == nil || len(alerts) == 0 {
+			return
+		}
+
+		g := r.groups.get(ref)
+		// The allowed alertmanagers are checked again, given they can change after the rule group has been stored.
+		if err := checkAlertmanagerTenants(g.alertmanagerTenants, r.overrides.RulerAllowedAlertmanagerTenants(r.userID)); err != nil {
+			level.Warn(r.logger).Log("msg", "not sending the alerts of the rule group to its alertmanager tenants", "file", ref.file, "group", ref.name, "err", err)
+			g.alertmanagerTenants = nil
+		}
+		if err := checkAlertmanagerURLs(g.alertmanagerURLs, r.overrides.RulerAllowedAlertmanagerURLs(r.userID)); err != nil {
+			level.Warn(r.logger).Log("msg", "not sending the alerts of the rule group to its alertmanager URLs", "file", ref.file, "group", ref.name, "err", err)
+			g.alertmanagerURLs = nil
+		}
+
+		for _, route := range alertmanagerRoutesFor(g) {
+			n, err := notifiers(route)
+			if err != nil {
+				level.Error(r.logger).Log("msg", "unable to create the notifier of the alertmanager of the rule group", "file", ref.file, "group", ref.name, "tenant", route.tenant, "url", route.url, "err", err)
+				continue
+			}
+			SendAlerts(n, externalURL)(ctx, expr, alerts...)
+		}
+	}
+}
+
+// alertmanagerRoutingRulesManager is implemented by the rules managers sending the alerts of the rule
+// groups to their additional alertmanagers.
+type alertmanagerRoutingRulesManager interface {
+	SetAlertmanagerNotifiers(notifiers alertmanagerNotifiers)
+}
+
+// getOrCreateRoutedNotifier returns the notifier sending the alerts of the user to an additional alertmanager.
+// The notifiers of the alertmanager tenants send the alerts to the configured alertmanager on behalf of the
+// alertmanager tenant, while the notifiers of the alertmanager URLs send them on behalf of the user.
+func (r *DefaultMultiTenantManager) getOrCreateRoutedNotifier(userID string, route alertmanagerRoute) (*notifier.Manager, error) {
+	r.notifiersMtx.Lock()
+	defer r.notifiersMtx.Unlock()
+
+	if n, ok := r.routedNotifiers[userID][route]; ok {
+		return n.notifier, nil
+	}
+
+	cfg := r.notifierCfg
+	orgID := userID
+	if route.tenant != "" {
+		orgID = route.tenant
+	} else {
+		rulerCfg := r.cfg
+		rulerCfg.AlertmanagerURL = route.url
+		rulerCfg.AlertmanagerDiscovery = false
+
+		var err error
+		if cfg, err = buildNotifierConfig(&rulerCfg); err != nil {
+			return nil, err
+		}
+	}
+
+	// The metrics of the notifiers of the additional alertmanagers aren't exported, not to clash with the
+	// metrics of the notifier of the user.
+	reg := prometheus.NewRegistry()
+	n := newRulerNotifier(&notifier.Options{
+		QueueCapacity: r.cfg.NotificationQueueCapacity,
+		Registerer:    reg,
+		Do: func(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
+			ctx = user.InjectOrgID(ctx, orgID)
+			if err := user.InjectOrgIDIntoHTTPRequest(ctx, req); err != nil {
+				return nil, err
+			}
+			sp := ot.GlobalTracer().StartSpan("notify", ot.Tag{Key: "organization", Value: userID})
+			defer sp.Finish()
+			ctx = ot.ContextWithSpan(ctx, sp)
+			_ = ot.GlobalTracer().Inject(sp.Context(), ot.HTTPHeaders, ot.HTTPHeadersCarrier(req.Header))
+			return ctxhttp.Do(ctx, client, req)
+		},
+	}, log.With(r.logger, "user", userID, "alertmanager_tenant", route.tenant, "alertmanager_url", route.url), reg, r.notifiersDiscoveryMetrics)
+
+	n.run()
+
+	if err := n.applyConfig(cfg); err != nil {
+		n.stop()
+		return nil, err
+	}
+
+	if r.routedNotifiers[userID] == nil {
+		r.routedNotifiers[userID] = map[alertmanagerRoute]*rulerNotifier{}
+	}
+	r.routedNotifiers[userID][route] = n
+	return n.notifier, nil
+}
+
+// removeUnusedRoutedNotifiers stops the notifiers of the additional alertmanagers no rule group of the
+// user sends its alerts to anymore.
+func (r *DefaultMultiTenantManager) removeUnusedRoutedNotifiers(userID string, groups rulespb.RuleGroupList) {
+	used := map[alertmanagerRoute]struct{}{}
+	for _, g := range groups {
+		for _, route := range alertmanagerRoutesFor(federatedRuleGroup{alertmanagerTenants: g.AlertmanagerTenants, alertmanagerURLs: g.AlertmanagerURLs}) {
+			used[route] = struct{}{}
+		}
+	}
+
+	r.notifiersMtx.Lock()
+	defer r.notifiersMtx.Unlock()
+
+	for route, n := range r.routedNotifiers[userID] {
+		if _, ok := used[route]; !ok {
+			n.stop()
+			delete(r.routedNotifiers[userID], route)
+		}
+	}
+	if len(r.routedNotifiers[userID]) == 0 {
+		delete(r.routedNotifiers, userID)
+	}
+}
@@ -0,0 +1,114 @@
+package ruler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/notifier"
+	"github.com/prometheus/prometheus/promql"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+)
+
+func TestCheckAlertmanagers(t *testing.T) {
+	assert.NoError(t, checkAlertmanagerTenants(nil, nil))
+	assert.NoError(t, checkAlertmanagerTenants([]string{"ops"}, []string{"ops", "sre"}))
+	assert.EqualError(t, checkAlertmanagerTenants([]string{"dev"}, []string{"ops"}), "the tenant dev is not allowed to receive the alerts of the rule groups (allowed alertmanager tenants: [ops])")
+	assert.Error(t, checkAlertmanagerTenants([]string{"ops/dev"}, []string{"ops/dev"}))
+
+	allowed := []string{"https://alertmanager.example.com/api/prom"}
+	assert.NoError(t, checkAlertmanagerURLs(nil, nil))
+	assert.NoError(t, checkAlertmanagerURLs([]string{"https://alertmanager.example.com/api/prom"}, allowed))
+	assert.EqualError(t, checkAlertmanagerURLs([]string{"https://alertmanager.example.com/"}, allowed), "the alertmanager URL https://alertmanager.example.com/ is not allowed to receive the alerts of the rule groups (allowed alertmanager URLs: [https://alertmanager.example.com/api/prom])")
+	assert.EqualError(t, checkAlertmanagerURLs([]string{"alertmanager:9093"}, []string{"alertmanager:9093"}), "invalid alertmanager URL alertmanager:9093 of the rule group")
+}
+
+func TestAlertmanagerRouter_NotifyFunc(t *testing.T) {
+	ref := ruleGroupRef{file: "ns", name: "group"}
+	groups := &federatedRuleGroups{}
+	groups.set(map[ruleGroupRef]federatedRuleGroup{
+		ref: {alertmanagerTenants: []string{"ops", "sre"}, alertmanagerURLs: []string{"https://alertmanager.example.com"}},
+	})
+	limits := ruleLimits{allowedAMTenants: []string{"ops"}, allowedAMURLs: []string{"https://alertmanager.example.com"}}
+	router := &alertmanagerRouter{groups: groups, overrides: limits, userID: "user-1", logger: log.NewNopLogger()}
+
+	var owner int
+	sent := map[alertmanagerRoute]int{}
+	notify := router.notifyFunc(func(_ context.Context, _ string, alerts ...*promRules.Alert) { owner += len(alerts) }, "http://localhost:9090")
+	router.setNotifiers(func(route alertmanagerRoute) (sender, error) {
+		return senderFunc(func(alerts ...*notifier.Alert) { sent[route] += len(alerts) }), nil
+	})
+
+	alert := &promRules.Alert{Labels: labels.FromStrings(labels.AlertName, "test"), FiredAt: time.Now()}
+	groupCtx := promql.NewOriginContext(context.Background(), map[string]interface{}{
+		"ruleGroup": map[string]string{"file": ref.file, "name": ref.name},
+	})
+
+	// The alerts are sent to the alertmanager of the tenant and to the allowed additional alertmanagers.
+	notify(groupCtx, "up", alert)
+	assert.Equal(t, 1, owner)
+	assert.Equal(t, map[alertmanagerRoute]int{{url: "https://alertmanager.example.com"}: 1}, sent)
+
+	// The alerts of the other rule groups are only sent to the alertmanager of the tenant.
+	notify(context.Background(), "up", alert)
+	assert.Equal(t, 2, owner)
+	assert.Equal(t, map[alertmanagerRoute]int{{url: "https://alertmanager.example.com"}: 1}, sent)
+
+	router.overrides = ruleLimits{allowedAMTenants: []string{"ops", "sre"}}
+	notify(groupCtx, "up", alert)
+	assert.Equal(t, 3, owner)
+	assert.Equal(t, map[alertmanagerRoute]int{{url: "https://alertmanager.example.com"}: 1, {tenant: "ops"}: 1, {tenant: "sre"}: 1}, sent)
+}
+
+func TestDefaultMultiTenantManager_RoutedNotifiers(t *testing.T) {
+	received := make(chan string, 10)
+	newAlertmanager := func() *httptest.Server {
+		ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			userID, _, err := tenant.ExtractTenantIDFromHTTPRequest(r)
+			assert.NoError(t, err)
+			received <- userID
+		}))
+		t.Cleanup(ts.Close)
+		return ts
+	}
+	tenantAlertmanager := newAlertmanager()
+	urlAlertmanager := newAlertmanager()
+
+	cfg := defaultRulerConfig(t)
+	cfg.AlertmanagerURL = tenantAlertmanager.URL
+	cfg.AlertmanagerDiscovery = false
+
+	manager := newManager(t, cfg)
+	defer manager.Stop()
+
+	send := func(route alertmanagerRoute) {
+		n, err := manager.getOrCreateRoutedNotifier("user-1", route)
+		require.NoError(t, err)
+		// Loop until notifier discovery syncs up
+		for len(n.Alertmanagers()) == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		n.Send(&notifier.Alert{Labels: labels.FromStrings(labels.AlertName, "test")})
+	}
+
+	// The alerts sent to an alertmanager tenant are sent on its behalf to the configured alertmanager.
+	send(alertmanagerRoute{tenant: "ops"})
+	assert.Equal(t, "ops", <-received)
+
+	// The alerts sent to an alertmanager URL are sent on behalf of the tenant owning the rule group.
+	send(alertmanagerRoute{url: urlAlertmanager.URL})
+	assert.Equal(t, "user-1", <-received)
+	assert.Len(t, manager.routedNotifiers["user-1"], 2)
+
+	// The notifiers not used by the rule groups anymore are stopped.
+	manager.removeUnusedRoutedNotifiers("user-1", nil)
+	assert.Empty(t, manager.routedNotifiers)
+}
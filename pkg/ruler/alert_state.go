@@ -0,0 +1,472 @@
+package ruler
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/thanos-io/objstore"
+
+	"github.com/cortexproject/cortex/pkg/querier/series"
+	"github.com/cortexproject/cortex/pkg/ruler/rulespb"
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	"github.com/cortexproject/cortex/pkg/util/concurrency"
+)
+
+const (
+	// The bucket prefix under which the alerts state of the rule groups is stored.
+	alertStatePrefix = "ruler-alerts-state"
+
+	// How many snapshots to load or persist concurrently.
+	alertStateConcurrency = 10
+
+	alertStatePersistTimeout = 30 * time.Second
+
+	// The metric name of the series written to restore the "for" state of the alerts.
+	alertForStateMetricName = "ALERTS_FOR_STATE"
+)
+
+// alertStateSnapshot is the state of the active alerts of a rule group at a given time.
+type alertStateSnapshot struct {
+	Timestamp time.Time                 `json:"timestamp"`
+	Alerts    []alertStateSnapshotAlert `json:"alerts"`
+}
+
+type alertStateSnapshotAlert struct {
+	Labels   labels.Labels `json:"labels"`
+	ActiveAt time.Time     `json:"activeAt"`
+}
+
+// newAlertStateSnapshot returns the snapshot of the active alerts of the rule group. It returns false if
+// the rule group has no alerting rules, or if the state of its alerts has not been restored yet, given
+// their "for" duration would have been reset.
+func newAlertStateSnapshot(g *promRules.Group, now time.Time) (*alertStateSnapshot, bool) {
+	alertingRules := g.AlertingRules()
+	if len(alertingRules) == 0 {
+		return nil, false
+	}
+
+	snapshot := &alertStateSnapshot{Timestamp: now}
+	for _, rule := range alertingRules {
+		if !rule.Restored() {
+			return nil, false
+		}
+
+		rule.ForEachActiveAlert(func(a *promRules.Alert) {
+			// The resolved alerts are kept to be sent to the Alertmanager, but they're not active anymore.
+			if a.State == promRules.StateInactive {
+				return
+			}
+			snapshot.Alerts = append(snapshot.Alerts, alertStateSnapshotAlert{Labels: a.Labels, ActiveAt: a.ActiveAt})
+		})
+	}
+	return snapshot, true
+}
+
+// series returns the ALERTS_FOR_STATE series matching the matchers, like the ones written by the rule
+// group when the snapshot was taken, if it was taken between mint and maxt.
+func (s *alertStateSnapshot) series(mint, maxt int64, matchers []*labels.Matcher) []storage.Series {
+	ts := s.Timestamp.UnixMilli()
+	if ts < mint || ts > maxt {
+		return nil
+	}
+
+	var result []storage.Series
+	for _, a := range s.Alerts {
+		lbls := labels.NewBuilder(a.Labels).Set(labels.MetricName, alertForStateMetricName).Labels()
+		if !matchesAll(lbls, matchers) {
+			continue
+		}
+
+		result = append(result, series.NewConcreteSeries(lbls, []model.SamplePair{{
+			Timestamp: model.Time(ts),
+			Value:     model.SampleValue(a.ActiveAt.Unix()),
+		}}))
+	}
+	return result
+}
+
+func matchesAll(lbls labels.Labels, matchers []*labels.Matcher) bool {
+	for _, m := range matchers {
+		if !m.Matches(lbls.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// alertStateSnapshots holds the snapshots of the rule groups of a rules manager, used to restore the state
+// of their alerts.
+type alertStateSnapshots struct {
+	mtx       sync.RWMutex
+	snapshots map[ruleGroupRef]*alertStateSnapshot
+}
+
+// update adds the loaded snapshots, and drops the snapshots of the rule groups not loaded anymore.
+func (s *alertStateSnapshots) update(loaded map[ruleGroupRef]*alertStateSnapshot, groups map[ruleGroupRef]struct{}) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	snapshots := make(map[ruleGroupRef]*alertStateSnapshot, len(groups))
+	for ref, snapshot := range s.snapshots {
+		if _, ok := groups[ref]; ok {
+			snapshots[ref] = snapshot
+		}
+	}
+	for ref, snapshot := range loaded {
+		snapshots[ref] = snapshot
+	}
+	s.snapshots = snapshots
+}
+
+func (s *alertStateSnapshots) series(mint, maxt int64, matchers []*labels.Matcher) []storage.Series {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var result []storage.Series
+	for _, snapshot := range s.snapshots {
+		result = append(result, snapshot.series(mint, maxt, matchers)...)
+	}
+	return result
+}
+
+// alertStateQueryable is a queryable returning the ALERTS_FOR_STATE series from the snapshots of the
+// rule groups, when they have any, so that the rules manager restores the state of the alerts from them.
+type alertStateQueryable struct {
+	storage.Queryable
+
+	snapshots *alertStateSnapshots
+}
+
+func (q alertStateQueryable) Querier(mint, maxt int64) (storage.Querier, error) {
+	querier, err := q.Queryable.Querier(mint, maxt)
+	if err != nil {
+		return nil, err
+	}
+	return &alertStateQuerier{Querier: querier, snapshots: q.snapshots, mint: mint, maxt: maxt}, nil
+}
+
+type alertStateQuerier struct {
+	storage.Querier
+
+	snapshots  *alertStateSnapshots
+	mint, maxt int64
+}
+
+func (q *alertStateQuerier) Select(ctx context.Context, sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	for _, m := range matchers {
+		if m.Name == labels.MetricName && m.Type == labels.MatchEqual && m.Value == alertForStateMetricName {
+			if result := q.snapshots.series(q.mint, q.maxt, matchers); len(result) > 0 {
+				return series.NewConcreteSeriesSet(sortSeries, result)
+			}
+		}
+	}
+	return q.Querier.Select(ctx, sortSeries, hints, matchers...)
+}
+
+// AlertStateStore stores the snapshots of the alerts state of the rule groups in object storage.
+type AlertStateStore struct {
+	bucket      objstore.Bucket
+	cfgProvider bucket.TenantConfigProvider
+	logger      log.Logger
+}
+
+func newAlertStateStore(bkt objstore.Bucket, cfgProvider bucket.TenantConfigProvider, logger log.Logger) *AlertStateStore {
+	return &AlertStateStore{
+		bucket:      bucket.NewPrefixedBucketClient(bkt, alertStatePrefix),
+		cfgProvider: cfgProvider,
+		logger:      logger,
+	}
+}
+
+func getAlertStateObjectKey(namespace, group string) string {
+	return base64.URLEncoding.EncodeToString([]byte(namespace)) + objstore.DirDelim + base64.URLEncoding.EncodeToString([]byte(group))
+}
+
+// getSnapshot returns the snapshot of the rule group, or nil if the rule group has no snapshot.
+func (s *AlertStateStore) getSnapshot(ctx context.Context, userID, namespace, group string) (*alertStateSnapshot, error) {
+	userBucket := bucket.NewUserBucketClient(userID, s.bucket, s.cfgProvider)
+	objectKey := getAlertStateObjectKey(namespace, group)
+
+	reader, err := userBucket.Get(ctx, objectKey)
+	if userBucket.IsObjNotFoundErr(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get alert state %s", objectKey)
+	}
+	defer func() { _ = reader.Close() }()
+
+	buf, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read alert state %s", objectKey)
+	}
+
+	snapshot := &alertStateSnapshot{}
+	if err := json.Unmarshal(buf, snapshot); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal alert state %s", objectKey)
+	}
+	return snapshot, nil
+}
+
+func (s *AlertStateStore) setSnapshot(ctx context.Context, userID, namespace, group string, snapshot *alertStateSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	userBucket := bucket.NewUserBucketClient(userID, s.bucket, s.cfgProvider)
+	return userBucket.Upload(ctx, getAlertStateObjectKey(namespace, group), bytes.NewReader(data))
+}
+
+// namespaceFromRuleFile returns the namespace of the rule groups written by the mapper to the rule file.
+func namespaceFromRuleFile(file string) string {
+	namespace, err := url.PathUnescape(filepath.Base(file))
+	if err != nil {
+		return filepath.Base(file)
+	}
+	return namespace
+}
+
+// alertStatePersister persists the state of the alerts of the rule groups to the ruler storage, and
+// restores it when the rule groups are loaded.
+type alertStatePersister struct {
+	store  *AlertStateStore
+	logger log.Logger
+
+	mtx sync.Mutex
+	// The rule groups added to a running rules manager, whose state is restored after their first evaluation.
+	pendingRestores map[string]map[ruleGroupRef]struct{}
+	// The rule groups whose last persisted snapshot has no alerts, not persisted again until they have any.
+	persistedEmpty map[string]map[ruleGroupRef]struct{}
+
+	persistTotal  prometheus.Counter
+	persistFailed prometheus.Counter
+}
+
+func newAlertStatePersister(store *AlertStateStore, logger log.Logger, reg prometheus.Registerer) *alertStatePersister {
+	return &alertStatePersister{
+		store:           store,
+		logger:          logger,
+		pendingRestores: map[string]map[ruleGroupRef]struct{}{},
+		persistedEmpty:  map[string]map[ruleGroupRef]struct{}{},
+		persistTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Namespace: "cortex",
+			Name:      "ruler_alert_state_persist_total",
+			Help:      "Total number of rule group alert states persisted to the ruler storage.",
+		}),
+		persistFailed: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Namespace: "cortex",
+			Name:      "ruler_alert_state_persist_failed_total",
+			Help:      "Total number of rule group alert states failed to be persisted to the ruler storage.",
+		}),
+	}
+}
+
+// load returns the snapshots of the rule groups. The rule groups whose snapshot can't be loaded are
+// restored from the ALERTS_FOR_STATE series, like when the persistence is disabled.
+func (p *alertStatePersister) load(ctx context.Context, userID string, groups map[ruleGroupRef]*rulespb.RuleGroupDesc) map[ruleGroupRef]*alertStateSnapshot {
+	jobs := make([]interface{}, 0, len(groups))
+	for ref := range groups {
+		jobs = append(jobs, ref)
+	}
+
+	var (
+		mtx       sync.Mutex
+		snapshots = map[ruleGroupRef]*alertStateSnapshot{}
+	)
+	_ = concurrency.ForEach(ctx, jobs, alertStateConcurrency, func(ctx context.Context, job interface{}) error {
+		ref := job.(ruleGroupRef)
+		g := groups[ref]
+
+		snapshot, err := p.store.getSnapshot(ctx, userID, g.Namespace, g.Name)
+		if err != nil {
+			level.Warn(p.logger).Log("msg", "failed to load alert state", "user", userID, "namespace", g.Namespace, "group", g.Name, "err", err)
+			return nil
+		}
+		if snapshot != nil {
+			mtx.Lock()
+			snapshots[ref] = snapshot
+			mtx.Unlock()
+		}
+		return nil
+	})
+	return snapshots
+}
+
+// addPendingRestores marks the rule groups added to a running rules manager to be restored, given the
+// rules manager only restores the state of the alerts of the rule groups loaded by its first update.
+func (p *alertStatePersister) addPendingRestores(userID string, refs []ruleGroupRef) {
+	if len(refs) == 0 {
+		return
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.pendingRestores[userID] == nil {
+		p.pendingRestores[userID] = map[ruleGroupRef]struct{}{}
+	}
+	for _, ref := range refs {
+		p.pendingRestores[userID][ref] = struct{}{}
+	}
+}
+
+func (p *alertStatePersister) isPendingRestore(userID string, ref ruleGroupRef) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	_, ok := p.pendingRestores[userID][ref]
+	return ok
+}
+
+func (p *alertStatePersister) popPendingRestore(userID string, ref ruleGroupRef) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if _, ok := p.pendingRestores[userID][ref]; !ok {
+		return false
+	}
+	delete(p.pendingRestores[userID], ref)
+	return true
+}
+
+// iterationFunc returns an iteration function restoring the state of the alerts of the rule groups
+// pending restore, after their first evaluation.
+func (p *alertStatePersister) iterationFunc(userID string, next promRules.GroupEvalIterationFunc) promRules.GroupEvalIterationFunc {
+	return func(ctx context.Context, g *promRules.Group, evalTimestamp time.Time) {
+		next(ctx, g, evalTimestamp)
+
+		if p.popPendingRestore(userID, ruleGroupRef{file: g.File(), name: g.Name()}) {
+			g.RestoreForState(time.Now())
+		}
+	}
+}
+
+// persist persists the state of the alerts of the rule groups.
+func (p *alertStatePersister) persist(ctx context.Context, userID string, groups []*promRules.Group) {
+	now := time.Now()
+	for _, g := range groups {
+		ref := ruleGroupRef{file: g.File(), name: g.Name()}
+		if p.isPendingRestore(userID, ref) {
+			continue
+		}
+
+		snapshot, ok := newAlertStateSnapshot(g, now)
+		if !ok || (len(snapshot.Alerts) == 0 && p.isPersistedEmpty(userID, ref)) {
+			continue
+		}
+
+		p.persistTotal.Inc()
+		if err := p.store.setSnapshot(ctx, userID, namespaceFromRuleFile(g.File()), g.Name(), snapshot); err != nil {
+			p.persistFailed.Inc()
+			level.Warn(p.logger).Log("msg", "failed to persist alert state", "user", userID, "file", g.File(), "group", g.Name(), "err", err)
+			continue
+		}
+		p.setPersistedEmpty(userID, ref, len(snapshot.Alerts) == 0)
+	}
+}
+
+func (p *alertStatePersister) isPersistedEmpty(userID string, ref ruleGroupRef) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	_, ok := p.persistedEmpty[userID][ref]
+	return ok
+}
+
+func (p *alertStatePersister) setPersistedEmpty(userID string, ref ruleGroupRef, empty bool) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if !empty {
+		delete(p.persistedEmpty[userID], ref)
+		return
+	}
+	if p.persistedEmpty[userID] == nil {
+		p.persistedEmpty[userID] = map[ruleGroupRef]struct{}{}
+	}
+	p.persistedEmpty[userID][ref] = struct{}{}
+}
+
+func (p *alertStatePersister) removeUser(userID string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	delete(p.pendingRestores, userID)
+	delete(p.persistedEmpty, userID)
+}
+
+// prepareAlertStateRestore persists the state of the alerts of the rule groups loaded by the rules manager,
+// which may be about to be removed, and loads the snapshots of the rule groups about to be added. It returns
+// the iteration function restoring the state of the alerts of the added rule groups.
+func (r *DefaultMultiTenantManager) prepareAlertStateRestore(ctx context.Context, userID string, manager RulesManager, existing bool, groups rulespb.RuleGroupList) promRules.GroupEvalIterationFunc {
+	loaded := map[ruleGroupRef]struct{}{}
+	if existing {
+		current := manager.RuleGroups()
+		r.alertState.persist(ctx, userID, current)
+		for _, g := range current {
+			loaded[ruleGroupRef{file: g.File(), name: g.Name()}] = struct{}{}
+		}
+	}
+
+	all := make(map[ruleGroupRef]struct{}, len(groups))
+	added := map[ruleGroupRef]*rulespb.RuleGroupDesc{}
+	for _, g := range groups {
+		ref := ruleGroupRef{file: r.mapper.ruleFile(userID, g.Namespace), name: g.Name}
+		all[ref] = struct{}{}
+		if _, ok := loaded[ref]; !ok {
+			added[ref] = g
+		}
+	}
+
+	if m, ok := manager.(alertStateRulesManager); ok {
+		m.UpdateAlertStateSnapshots(r.alertState.load(ctx, userID, added), all)
+	}
+
+	// The rules manager restores the state of the alerts of the rule groups loaded by its first update.
+	if existing {
+		refs := make([]ruleGroupRef, 0, len(added))
+		for ref := range added {
+			refs = append(refs, ref)
+		}
+		r.alertState.addPendingRestores(userID, refs)
+	}
+	return r.alertState.iterationFunc(userID, ruleGroupIterationFunc)
+}
+
+// PersistAlertState persists the state of the alerts of the rule groups of all the users to the ruler storage.
+func (r *DefaultMultiTenantManager) PersistAlertState(ctx context.Context) {
+	if r.alertState == nil {
+		return
+	}
+
+	r.userManagerMtx.RLock()
+	managers := make(map[string]RulesManager, len(r.userManagers))
+	users := make([]string, 0, len(r.userManagers))
+	for userID, manager := range r.userManagers {
+		managers[userID] = manager
+		users = append(users, userID)
+	}
+	r.userManagerMtx.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, alertStatePersistTimeout)
+	defer cancel()
+
+	_ = concurrency.ForEachUser(ctx, users, alertStateConcurrency, func(ctx context.Context, userID string) error {
+		r.alertState.persist(ctx, userID, managers[userID].RuleGroups())
+		return nil
+	})
+}
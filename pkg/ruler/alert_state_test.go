@@ -0,0 +1,147 @@
+package ruler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/cortexpb"
+	"github.com/cortexproject/cortex/pkg/ruler/rulespb"
+)
+
+func newAlertStateTestGroup(t *testing.T, queryable storage.Queryable, restored bool) *promRules.Group {
+	expr, err := parser.ParseExpr("errors > 0")
+	require.NoError(t, err)
+
+	rule := promRules.NewAlertingRule("HighErrors", expr, 10*time.Minute, 0, labels.EmptyLabels(), labels.EmptyLabels(), labels.EmptyLabels(), "", restored, log.NewNopLogger())
+	return promRules.NewGroup(promRules.GroupOptions{
+		Name:     "group",
+		File:     "/rules/user-1/ns",
+		Interval: time.Minute,
+		Rules:    []promRules.Rule{rule},
+		Opts: &promRules.ManagerOptions{
+			QueryFunc: func(_ context.Context, _ string, ts time.Time) (promql.Vector, error) {
+				return promql.Vector{{T: ts.UnixMilli(), F: 1, Metric: labels.FromStrings("job", "api")}}, nil
+			},
+			Appendable:      NewPusherAppendable(&fakePusher{response: &cortexpb.WriteResponse{}}, "user-1", ruleLimits{}, prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{})),
+			Queryable:       queryable,
+			NotifyFunc:      func(context.Context, string, ...*promRules.Alert) {},
+			Context:         user.InjectOrgID(context.Background(), "user-1"),
+			Logger:          log.NewNopLogger(),
+			OutageTolerance: time.Hour,
+		},
+	})
+}
+
+func TestAlertStateSnapshot_ShouldRestoreTheForState(t *testing.T) {
+	start := time.Unix(1000, 0)
+
+	// The alert becomes active on the first evaluation.
+	group := newAlertStateTestGroup(t, storage.QueryableFunc(func(_, _ int64) (storage.Querier, error) { return storage.NoopQuerier(), nil }), true)
+	group.Eval(context.Background(), start)
+
+	snapshot, ok := newAlertStateSnapshot(group, start.Add(5*time.Minute))
+	require.True(t, ok)
+	require.Len(t, snapshot.Alerts, 1)
+	assert.Equal(t, labels.FromStrings(labels.AlertName, "HighErrors", "job", "api"), snapshot.Alerts[0].Labels)
+	assert.True(t, start.Equal(snapshot.Alerts[0].ActiveAt))
+
+	snapshots := &alertStateSnapshots{}
+	snapshots.update(map[ruleGroupRef]*alertStateSnapshot{{file: "/rules/user-1/ns", name: "group"}: snapshot}, map[ruleGroupRef]struct{}{{file: "/rules/user-1/ns", name: "group"}: {}})
+
+	restart := start.Add(6 * time.Minute)
+	restoredGroup := newAlertStateTestGroup(t, alertStateQueryable{Queryable: storage.QueryableFunc(func(_, _ int64) (storage.Querier, error) { return storage.NoopQuerier(), nil }), snapshots: snapshots}, false)
+	restoredGroup.Eval(context.Background(), restart)
+
+	// The state of the alerts of a rule group not restored yet is not snapshotted.
+	_, ok = newAlertStateSnapshot(restoredGroup, restart)
+	require.False(t, ok)
+
+	// The alert was pending for 5 minutes when the snapshot was taken, so it's restored as pending
+	// for 5 minutes, shifted by the downtime.
+	restoredGroup.RestoreForState(restart)
+	snapshot, ok = newAlertStateSnapshot(restoredGroup, restart)
+	require.True(t, ok)
+	require.Len(t, snapshot.Alerts, 1)
+	assert.True(t, start.Add(time.Minute).Equal(snapshot.Alerts[0].ActiveAt), snapshot.Alerts[0].ActiveAt)
+}
+
+func TestAlertStateQuerier_ShouldFallbackToTheStorage(t *testing.T) {
+	snapshot := &alertStateSnapshot{
+		Timestamp: time.Unix(1000, 0),
+		Alerts:    []alertStateSnapshotAlert{{Labels: labels.FromStrings(labels.AlertName, "HighErrors"), ActiveAt: time.Unix(900, 0)}},
+	}
+	snapshots := &alertStateSnapshots{}
+	snapshots.update(map[ruleGroupRef]*alertStateSnapshot{{file: "file", name: "group"}: snapshot}, map[ruleGroupRef]struct{}{{file: "file", name: "group"}: {}})
+
+	queryable := alertStateQueryable{
+		Queryable: storage.QueryableFunc(func(_, _ int64) (storage.Querier, error) { return storage.NoopQuerier(), nil }),
+		snapshots: snapshots,
+	}
+	countSeries := func(mint, maxt int64, matchers ...*labels.Matcher) int {
+		q, err := queryable.Querier(mint, maxt)
+		require.NoError(t, err)
+		set := q.Select(context.Background(), false, nil, matchers...)
+		count := 0
+		for set.Next() {
+			count++
+		}
+		return count
+	}
+
+	forState := labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, alertForStateMetricName)
+	assert.Equal(t, 1, countSeries(0, 2000000, forState, labels.MustNewMatcher(labels.MatchEqual, labels.AlertName, "HighErrors")))
+	assert.Equal(t, 0, countSeries(0, 2000000, forState, labels.MustNewMatcher(labels.MatchEqual, labels.AlertName, "Other")))
+
+	// The snapshots taken before the outage tolerance are ignored.
+	assert.Equal(t, 0, countSeries(1500000, 2000000, forState))
+	// The other series are queried from the storage.
+	assert.Equal(t, 0, countSeries(0, 2000000, labels.MustNewMatcher(labels.MatchEqual, labels.AlertName, "HighErrors")))
+}
+
+func TestAlertStatePersister(t *testing.T) {
+	store := newAlertStateStore(objstore.NewInMemBucket(), nil, log.NewNopLogger())
+	reg := prometheus.NewPedanticRegistry()
+	persister := newAlertStatePersister(store, log.NewNopLogger(), reg)
+
+	ref := ruleGroupRef{file: "/rules/user-1/ns", name: "group"}
+	group := newAlertStateTestGroup(t, nil, true)
+
+	// The rule groups pending restore are not persisted.
+	persister.addPendingRestores("user-1", []ruleGroupRef{ref})
+	persister.persist(context.Background(), "user-1", []*promRules.Group{group})
+	assert.Equal(t, 0, int(testutil.ToFloat64(persister.persistTotal)))
+	assert.True(t, persister.popPendingRestore("user-1", ref))
+
+	// The snapshots without alerts are only persisted once.
+	persister.persist(context.Background(), "user-1", []*promRules.Group{group})
+	persister.persist(context.Background(), "user-1", []*promRules.Group{group})
+	assert.Equal(t, 1, int(testutil.ToFloat64(persister.persistTotal)))
+
+	group.Eval(context.Background(), time.Unix(1000, 0))
+	persister.persist(context.Background(), "user-1", []*promRules.Group{group})
+	persister.persist(context.Background(), "user-1", []*promRules.Group{group})
+	assert.Equal(t, 3, int(testutil.ToFloat64(persister.persistTotal)))
+	assert.Equal(t, 0, int(testutil.ToFloat64(persister.persistFailed)))
+
+	loaded := persister.load(context.Background(), "user-1", map[ruleGroupRef]*rulespb.RuleGroupDesc{
+		ref: {Namespace: "ns", Name: "group"},
+		{file: "/rules/user-1/ns", name: "other"}: {Namespace: "ns", Name: "other"},
+	})
+	require.Len(t, loaded, 1)
+	require.Len(t, loaded[ref].Alerts, 1)
+	assert.True(t, time.Unix(1000, 0).Equal(loaded[ref].Alerts[0].ActiveAt))
+}
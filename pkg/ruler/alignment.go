@@ -0,0 +1,93 @@
+package ruler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	promRules "github.com/prometheus/prometheus/rules"
+
+	"github.com/cortexproject/cortex/pkg/ruler/rulespb"
+)
+
+const (
+	errEvaluationOffsetWithoutAlignment = "the evaluation offset of a rule group can only be set if its evaluations are aligned"
+	errInvalidEvaluationOffset          = "the evaluation offset of a rule group must be greater than or equal to 0 and less than its interval (interval: %s)"
+)
+
+// checkEvaluationAlignment returns an error if the evaluation offset of a rule group is set without
+// aligning its evaluations, or is not within its interval.
+func checkEvaluationAlignment(align bool, offset, interval time.Duration) error {
+	if offset != 0 && !align {
+		return errors.New(errEvaluationOffsetWithoutAlignment)
+	}
+	if offset < 0 || offset >= interval {
+		return fmt.Errorf(errInvalidEvaluationOffset, interval)
+	}
+	return nil
+}
+
+// alignedEvalTimestamp returns the latest timestamp not after the evaluation timestamp which is aligned
+// to the interval, plus the offset.
+func alignedEvalTimestamp(evalTimestamp time.Time, interval, offset time.Duration) time.Time {
+	adjusted := evalTimestamp.UnixNano() - int64(offset)
+	delta := adjusted % int64(interval)
+	if delta < 0 {
+		delta += int64(interval)
+	}
+	return time.Unix(0, adjusted-delta+int64(offset)).UTC()
+}
+
+// alignedRuleGroupsFor returns the offset of the rule groups with aligned evaluations, by the rule file
+// the mapper writes them to and their name.
+func alignedRuleGroupsFor(m *mapper, userID string, groups rulespb.RuleGroupList) map[ruleGroupRef]time.Duration {
+	aligned := map[ruleGroupRef]time.Duration{}
+	for _, g := range groups {
+		if g.AlignEvaluations {
+			aligned[ruleGroupRef{file: m.ruleFile(userID, g.Namespace), name: g.Name}] = g.EvaluationOffset
+		}
+	}
+	return aligned
+}
+
+// ruleGroupAlignments tracks the rule groups of a rules manager with aligned evaluations.
+type ruleGroupAlignments struct {
+	mtx     sync.RWMutex
+	offsets map[ruleGroupRef]time.Duration
+}
+
+func (a *ruleGroupAlignments) set(offsets map[ruleGroupRef]time.Duration) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.offsets = offsets
+}
+
+func (a *ruleGroupAlignments) get(ref ruleGroupRef) (time.Duration, bool) {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	offset, ok := a.offsets[ref]
+	return offset, ok
+}
+
+// iterationFunc returns an iteration function evaluating the rule groups with aligned evaluations with
+// the aligned function, at the latest aligned timestamp before their scheduled evaluation, and the
+// other rule groups with the next function. The rule groups keep being evaluated when scheduled, so
+// the evaluations of the rule groups with the same aligned timestamps are still spread over the interval.
+func (a *ruleGroupAlignments) iterationFunc(aligned, next promRules.GroupEvalIterationFunc) promRules.GroupEvalIterationFunc {
+	return func(ctx context.Context, g *promRules.Group, evalTimestamp time.Time) {
+		offset, ok := a.get(ruleGroupRef{file: g.File(), name: g.Name()})
+		if !ok || offset >= g.Interval() {
+			next(ctx, g, evalTimestamp)
+			return
+		}
+
+		ts := alignedEvalTimestamp(evalTimestamp, g.Interval(), offset)
+		if !ts.After(g.GetLastEvalTimestamp()) {
+			// The rule group has already been evaluated at the aligned timestamp, after its alignment changed.
+			ts = evalTimestamp
+		}
+		aligned(ctx, g, ts)
+	}
+}
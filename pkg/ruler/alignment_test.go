@@ -0,0 +1,88 @@
+package ruler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cortexproject/cortex/pkg/ruler/rulespb"
+)
+
+func TestCheckEvaluationAlignment(t *testing.T) {
+	assert.NoError(t, checkEvaluationAlignment(false, 0, time.Minute))
+	assert.NoError(t, checkEvaluationAlignment(true, 0, time.Minute))
+	assert.NoError(t, checkEvaluationAlignment(true, 30*time.Second, time.Minute))
+	assert.EqualError(t, checkEvaluationAlignment(false, 30*time.Second, time.Minute), errEvaluationOffsetWithoutAlignment)
+	assert.EqualError(t, checkEvaluationAlignment(true, time.Minute, time.Minute), "the evaluation offset of a rule group must be greater than or equal to 0 and less than its interval (interval: 1m0s)")
+	assert.EqualError(t, checkEvaluationAlignment(true, -time.Second, time.Minute), "the evaluation offset of a rule group must be greater than or equal to 0 and less than its interval (interval: 1m0s)")
+}
+
+func TestAlignedEvalTimestamp(t *testing.T) {
+	tests := map[string]struct {
+		evalTimestamp time.Time
+		interval      time.Duration
+		offset        time.Duration
+		expected      time.Time
+	}{
+		"should align to the interval": {
+			evalTimestamp: time.Unix(1037, 0),
+			interval:      time.Minute,
+			expected:      time.Unix(1020, 0),
+		},
+		"should keep an aligned timestamp": {
+			evalTimestamp: time.Unix(1020, 0),
+			interval:      time.Minute,
+			expected:      time.Unix(1020, 0),
+		},
+		"should align to the interval plus the offset": {
+			evalTimestamp: time.Unix(1037, 0),
+			interval:      time.Minute,
+			offset:        15 * time.Second,
+			expected:      time.Unix(1035, 0),
+		},
+		"should align to the previous interval plus the offset": {
+			evalTimestamp: time.Unix(1030, 0),
+			interval:      time.Minute,
+			offset:        15 * time.Second,
+			expected:      time.Unix(975, 0),
+		},
+		"should align to the hour": {
+			evalTimestamp: time.Unix(7300, 0),
+			interval:      time.Hour,
+			expected:      time.Unix(7200, 0),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected.UTC(), alignedEvalTimestamp(tc.evalTimestamp, tc.interval, tc.offset))
+		})
+	}
+}
+
+func TestRuleGroupAlignments_IterationFunc(t *testing.T) {
+	m := newMapper("/rules", log.NewNopLogger())
+	alignments := &ruleGroupAlignments{}
+	alignments.set(alignedRuleGroupsFor(m, "user-1", rulespb.RuleGroupList{
+		{Name: "a", Namespace: "ns", AlignEvaluations: true, EvaluationOffset: 10 * time.Second},
+		{Name: "b", Namespace: "ns"},
+	}))
+
+	var alignedCalls, nextCalls []time.Time
+	iterationFunc := alignments.iterationFunc(
+		func(_ context.Context, _ *promRules.Group, ts time.Time) { alignedCalls = append(alignedCalls, ts) },
+		func(_ context.Context, _ *promRules.Group, ts time.Time) { nextCalls = append(nextCalls, ts) },
+	)
+
+	aligned := newDependencyTestGroup(t, "a", "a", "up")
+	other := newDependencyTestGroup(t, "b", "b", "up")
+	iterationFunc(context.Background(), aligned, time.Unix(1037, 0))
+	iterationFunc(context.Background(), other, time.Unix(1037, 0))
+
+	assert.Equal(t, []time.Time{time.Unix(1030, 0).UTC()}, alignedCalls)
+	assert.Equal(t, []time.Time{time.Unix(1037, 0)}, nextCalls)
+}
@@ -65,6 +65,39 @@ type RuleGroup struct {
 	LastEvaluation time.Time `json:"lastEvaluation"`
 	EvaluationTime float64   `json:"evaluationTime"`
 	Limit          int64     `json:"limit"`
+	// The query statistics of the rule group, only exposed when the rule group stats are enabled.
+	LastEvaluationStats *RuleGroupStats `json:"lastEvaluationStats,omitempty"`
+	TotalStats          *RuleGroupStats `json:"totalStats,omitempty"`
+}
+
+// RuleGroupStats are the query statistics of the evaluations of a rule group.
+type RuleGroupStats struct {
+	QueryWallTime  float64           `json:"queryWallTime"`
+	FetchedSeries  uint64            `json:"fetchedSeries"`
+	FetchedChunks  uint64            `json:"fetchedChunks"`
+	FetchedSamples uint64            `json:"fetchedSamples"`
+	FetchedBytes   uint64            `json:"fetchedBytes"`
+	SeriesWritten  uint64            `json:"seriesWritten"`
+	Failures       map[string]uint64 `json:"failures"`
+}
+
+func ruleGroupStatsFromDesc(desc *RuleGroupStatsDesc) *RuleGroupStats {
+	if desc == nil {
+		return nil
+	}
+	failures := desc.Failures
+	if failures == nil {
+		failures = map[string]uint64{}
+	}
+	return &RuleGroupStats{
+		QueryWallTime:  desc.QueryWallTime.Seconds(),
+		FetchedSeries:  desc.FetchedSeries,
+		FetchedChunks:  desc.FetchedChunks,
+		FetchedSamples: desc.FetchedSamples,
+		FetchedBytes:   desc.FetchedBytes,
+		SeriesWritten:  desc.SeriesWritten,
+		Failures:       failures,
+	}
 }
 
 type rule interface{}
@@ -161,6 +194,9 @@ func (a *API) PrometheusRules(w http.ResponseWriter, req *http.Request) {
 			LastEvaluation: g.GetEvaluationTimestamp(),
 			EvaluationTime: g.GetEvaluationDuration().Seconds(),
 			Limit:          g.Group.Limit,
+
+			LastEvaluationStats: ruleGroupStatsFromDesc(g.LastEvaluationStats),
+			TotalStats:          ruleGroupStatsFromDesc(g.TotalStats),
 		}
 
 		for i, rl := range g.ActiveRules {
@@ -426,7 +462,7 @@ func (a *API) ListRules(w http.ResponseWriter, req *http.Request) {
 
 	level.Debug(logger).Log("msg", "retrieved rule groups from rule store", "userID", userID, "num_namespaces", len(rgs))
 
-	formatted := rgs.Formatted()
+	formatted := rgs.FormattedWithTenants()
 	marshalAndSend(formatted, w, logger)
 }
 
@@ -448,7 +484,7 @@ func (a *API) GetRuleGroup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	formatted := rulespb.FromProto(rg)
+	formatted := rulespb.FromProtoWithTenants(rg)
 	marshalAndSend(formatted, w, logger)
 }
 
@@ -469,7 +505,7 @@ func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
 
 	level.Debug(logger).Log("msg", "attempting to unmarshal rulegroup", "userID", userID, "group", string(payload))
 
-	rg := rulefmt.RuleGroup{}
+	rg := rulespb.RuleGroup{}
 	err = yaml.Unmarshal(payload, &rg)
 	if err != nil {
 		level.Error(logger).Log("msg", "unable to unmarshal rule group payload", "err", err.Error())
@@ -477,7 +513,7 @@ func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	errs := a.ruler.manager.ValidateRuleGroup(rg)
+	errs := a.ruler.manager.ValidateRuleGroup(rg.RuleGroup)
 	if len(errs) > 0 {
 		e := []string{}
 		for _, err := range errs {
@@ -495,6 +531,36 @@ func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if err := a.ruler.AssertSourceTenantsAllowed(userID, rg.SourceTenants); err != nil {
+		level.Error(logger).Log("msg", "source tenants validation failure", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.ruler.AssertDestinationTenantAllowed(userID, rg.DestinationTenant, rg.Rules); err != nil {
+		level.Error(logger).Log("msg", "destination tenant validation failure", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.ruler.AssertAlertmanagersAllowed(userID, rg); err != nil {
+		level.Error(logger).Log("msg", "alertmanagers validation failure", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.ruler.AssertEvaluationAlignmentValid(rg); err != nil {
+		level.Error(logger).Log("msg", "evaluation alignment validation failure", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.ruler.AssertExternalLabelsValid(rg); err != nil {
+		level.Error(logger).Log("msg", "external labels validation failure", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	if a.ruler.HasMaxRuleGroupsLimit(userID) {
 		rgs, err := a.store.ListRuleGroupsForUserAndNamespace(req.Context(), userID, "")
 		if err != nil {
@@ -510,7 +576,7 @@ func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
-	rgProto := rulespb.ToProto(userID, namespace, rg)
+	rgProto := rulespb.ToProtoWithTenants(userID, namespace, rg)
 	loadedRg := rulespb.FromProto(rgProto)
 	rgYaml, err := yaml.Marshal(loadedRg)
 	if err == nil {
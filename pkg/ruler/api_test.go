@@ -12,6 +12,7 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/user"
 
@@ -310,6 +311,416 @@ rules:
 	}
 }
 
+func TestRuler_CreateWithSourceTenants(t *testing.T) {
+	store := newMockRuleStore(make(map[string]rulespb.RuleGroupList), nil)
+	cfg := defaultRulerConfig(t)
+
+	r := newTestRuler(t, cfg, store, nil)
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	r.limits = ruleLimits{allowedSourceTenants: []string{"team-a", "team-b"}}
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+
+	tc := []struct {
+		name   string
+		input  string
+		output string
+		status int
+	}{
+		{
+			name:   "with allowed source tenants",
+			status: 202,
+			input: `
+name: test
+source_tenants: [team-a, team-b]
+rules:
+- record: up_rule
+  expr: up{}
+`,
+			output: "name: test\nrules:\n    - record: up_rule\n      expr: up{}\nsource_tenants:\n    - team-a\n    - team-b\n",
+		},
+		{
+			name:   "with a source tenant not allowed",
+			status: 400,
+			input: `
+name: test
+source_tenants: [team-a, team-c]
+rules:
+- record: up_rule
+  expr: up{}
+`,
+			output: "the tenant team-c is not allowed to be a source tenant of the federated rule groups (allowed source tenants: [team-a team-b])\n",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			router.Path("/api/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+			router.Path("/api/v1/rules/{namespace}/{groupName}").Methods("GET").HandlerFunc(a.GetRuleGroup)
+			// POST
+			req := requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/namespace", strings.NewReader(tt.input), "user1")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+			require.Equal(t, tt.status, w.Code)
+
+			if tt.status == 202 {
+				// GET
+				req = requestFor(t, http.MethodGet, "https://localhost:8080/api/v1/rules/namespace/test", nil, "user1")
+				w = httptest.NewRecorder()
+
+				router.ServeHTTP(w, req)
+				require.Equal(t, 200, w.Code)
+			}
+			require.Equal(t, tt.output, w.Body.String())
+		})
+	}
+}
+
+func TestRuler_CreateWithEvaluationAlignment(t *testing.T) {
+	store := newMockRuleStore(make(map[string]rulespb.RuleGroupList), nil)
+	cfg := defaultRulerConfig(t)
+
+	r := newTestRuler(t, cfg, store, nil)
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+
+	tc := []struct {
+		name   string
+		input  string
+		output string
+		status int
+	}{
+		{
+			name:   "with aligned evaluations and an offset",
+			status: 202,
+			input: `
+name: test
+interval: 1m
+align_evaluations: true
+evaluation_offset: 15s
+rules:
+- record: up_rule
+  expr: up{}
+`,
+			output: "name: test\ninterval: 1m\nrules:\n    - record: up_rule\n      expr: up{}\nalign_evaluations: true\nevaluation_offset: 15s\n",
+		},
+		{
+			name:   "with an offset without aligned evaluations",
+			status: 400,
+			input: `
+name: test
+evaluation_offset: 15s
+rules:
+- record: up_rule
+  expr: up{}
+`,
+			output: errEvaluationOffsetWithoutAlignment + "\n",
+		},
+		{
+			name:   "with an offset greater than the interval",
+			status: 400,
+			input: `
+name: test
+interval: 1m
+align_evaluations: true
+evaluation_offset: 2m
+rules:
+- record: up_rule
+  expr: up{}
+`,
+			output: "the evaluation offset of a rule group must be greater than or equal to 0 and less than its interval (interval: 1m0s)\n",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			router.Path("/api/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+			router.Path("/api/v1/rules/{namespace}/{groupName}").Methods("GET").HandlerFunc(a.GetRuleGroup)
+			// POST
+			req := requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/namespace", strings.NewReader(tt.input), "user1")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+			require.Equal(t, tt.status, w.Code)
+
+			if tt.status == 202 {
+				// GET
+				req = requestFor(t, http.MethodGet, "https://localhost:8080/api/v1/rules/namespace/test", nil, "user1")
+				w = httptest.NewRecorder()
+
+				router.ServeHTTP(w, req)
+				require.Equal(t, 200, w.Code)
+			}
+			require.Equal(t, tt.output, w.Body.String())
+		})
+	}
+}
+
+func TestRuler_CreateWithExternalLabels(t *testing.T) {
+	store := newMockRuleStore(make(map[string]rulespb.RuleGroupList), nil)
+	cfg := defaultRulerConfig(t)
+	cfg.ExternalLabels = labels.FromStrings("cluster", "eu-1")
+
+	r := newTestRuler(t, cfg, store, nil)
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+
+	tc := []struct {
+		name   string
+		input  string
+		output string
+		status int
+	}{
+		{
+			name:   "with external labels",
+			status: 202,
+			input: `
+name: test
+external_labels:
+  env: prod
+rules:
+- record: up_rule
+  expr: up{}
+`,
+			output: "name: test\nrules:\n    - record: up_rule\n      expr: up{}\nexternal_labels:\n    env: prod\n",
+		},
+		{
+			name:   "with a reserved external label",
+			status: 400,
+			input: `
+name: test
+external_labels:
+  __tenant__: other
+rules:
+- record: up_rule
+  expr: up{}
+`,
+			output: "the external label \"__tenant__\" of the rule group is reserved\n",
+		},
+		{
+			name:   "with an external label of the ruler",
+			status: 400,
+			input: `
+name: test
+external_labels:
+  cluster: us-1
+rules:
+- record: up_rule
+  expr: up{}
+`,
+			output: "the external label \"cluster\" of the rule group is reserved\n",
+		},
+		{
+			name:   "with an invalid external label",
+			status: 400,
+			input: `
+name: test
+external_labels:
+  invalid-name: value
+rules:
+- record: up_rule
+  expr: up{}
+`,
+			output: "invalid external label \"invalid-name\"=\"value\" of the rule group\n",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			router.Path("/api/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+			router.Path("/api/v1/rules/{namespace}/{groupName}").Methods("GET").HandlerFunc(a.GetRuleGroup)
+			// POST
+			req := requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/namespace", strings.NewReader(tt.input), "user1")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+			require.Equal(t, tt.status, w.Code)
+
+			if tt.status == 202 {
+				// GET
+				req = requestFor(t, http.MethodGet, "https://localhost:8080/api/v1/rules/namespace/test", nil, "user1")
+				w = httptest.NewRecorder()
+
+				router.ServeHTTP(w, req)
+				require.Equal(t, 200, w.Code)
+			}
+			require.Equal(t, tt.output, w.Body.String())
+		})
+	}
+}
+
+func TestRuler_CreateWithDestinationTenant(t *testing.T) {
+	store := newMockRuleStore(make(map[string]rulespb.RuleGroupList), nil)
+	cfg := defaultRulerConfig(t)
+
+	r := newTestRuler(t, cfg, store, nil)
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	r.limits = ruleLimits{allowedDestTenants: []string{"ops"}}
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+
+	tc := []struct {
+		name   string
+		input  string
+		output string
+		status int
+	}{
+		{
+			name:   "with an allowed destination tenant",
+			status: 202,
+			input: `
+name: test
+destination_tenant: ops
+rules:
+- record: up_rule
+  expr: up{}
+`,
+			output: "name: test\nrules:\n    - record: up_rule\n      expr: up{}\ndestination_tenant: ops\n",
+		},
+		{
+			name:   "with a destination tenant not allowed",
+			status: 400,
+			input: `
+name: test
+destination_tenant: other
+rules:
+- record: up_rule
+  expr: up{}
+`,
+			output: "the tenant other is not allowed to be the destination tenant of the rule groups (allowed destination tenants: [ops])\n",
+		},
+		{
+			name:   "with alerting rules",
+			status: 400,
+			input: `
+name: test
+destination_tenant: ops
+rules:
+- alert: up_alert
+  expr: up{} == 0
+`,
+			output: "the rule groups with a destination tenant can only contain recording rules\n",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			router.Path("/api/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+			router.Path("/api/v1/rules/{namespace}/{groupName}").Methods("GET").HandlerFunc(a.GetRuleGroup)
+			// POST
+			req := requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/namespace", strings.NewReader(tt.input), "user1")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+			require.Equal(t, tt.status, w.Code)
+
+			if tt.status == 202 {
+				// GET
+				req = requestFor(t, http.MethodGet, "https://localhost:8080/api/v1/rules/namespace/test", nil, "user1")
+				w = httptest.NewRecorder()
+
+				router.ServeHTTP(w, req)
+				require.Equal(t, 200, w.Code)
+			}
+			require.Equal(t, tt.output, w.Body.String())
+		})
+	}
+}
+
+func TestRuler_CreateWithAlertmanagers(t *testing.T) {
+	store := newMockRuleStore(make(map[string]rulespb.RuleGroupList), nil)
+	cfg := defaultRulerConfig(t)
+
+	r := newTestRuler(t, cfg, store, nil)
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	r.limits = ruleLimits{allowedAMTenants: []string{"ops"}, allowedAMURLs: []string{"https://alertmanager.example.com"}}
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+
+	tc := []struct {
+		name   string
+		input  string
+		output string
+		status int
+	}{
+		{
+			name:   "with allowed alertmanagers",
+			status: 202,
+			input: `
+name: test
+alertmanager_tenants:
+- ops
+alertmanager_urls:
+- https://alertmanager.example.com
+rules:
+- alert: up_alert
+  expr: up{} == 0
+`,
+			output: "name: test\nrules:\n    - alert: up_alert\n      expr: up{} == 0\nalertmanager_tenants:\n    - ops\nalertmanager_urls:\n    - https://alertmanager.example.com\n",
+		},
+		{
+			name:   "with an alertmanager tenant not allowed",
+			status: 400,
+			input: `
+name: test
+alertmanager_tenants:
+- other
+rules:
+- alert: up_alert
+  expr: up{} == 0
+`,
+			output: "the tenant other is not allowed to receive the alerts of the rule groups (allowed alertmanager tenants: [ops])\n",
+		},
+		{
+			name:   "with an alertmanager URL not allowed",
+			status: 400,
+			input: `
+name: test
+alertmanager_urls:
+- https://other.example.com
+rules:
+- alert: up_alert
+  expr: up{} == 0
+`,
+			output: "the alertmanager URL https://other.example.com is not allowed to receive the alerts of the rule groups (allowed alertmanager URLs: [https://alertmanager.example.com])\n",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			router.Path("/api/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+			router.Path("/api/v1/rules/{namespace}/{groupName}").Methods("GET").HandlerFunc(a.GetRuleGroup)
+			// POST
+			req := requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/namespace", strings.NewReader(tt.input), "user1")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+			require.Equal(t, tt.status, w.Code)
+
+			if tt.status == 202 {
+				// GET
+				req = requestFor(t, http.MethodGet, "https://localhost:8080/api/v1/rules/namespace/test", nil, "user1")
+				w = httptest.NewRecorder()
+
+				router.ServeHTTP(w, req)
+				require.Equal(t, 200, w.Code)
+			}
+			require.Equal(t, tt.output, w.Body.String())
+		})
+	}
+}
+
 func TestRuler_DeleteNamespace(t *testing.T) {
 	store := newMockRuleStore(mockRulesNamespaces, nil)
 	cfg := defaultRulerConfig(t)
@@ -0,0 +1,254 @@
+package ruler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+	"gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+	util_api "github.com/cortexproject/cortex/pkg/util/api"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+)
+
+// maxBacktestSteps is the max number of evaluations of a backtest, like the max resolution of the range queries.
+const maxBacktestSteps = 11000
+
+// BacktestAlert is an alert the backtested alerting rule would have generated.
+type BacktestAlert struct {
+	Labels      labels.Labels `json:"labels"`
+	Annotations labels.Labels `json:"annotations"`
+	// State of the alert at the end of the backtest: "pending", "firing" or "inactive" if resolved.
+	State      string     `json:"state"`
+	ActiveAt   time.Time  `json:"activeAt"`
+	FiredAt    *time.Time `json:"firedAt,omitempty"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+}
+
+// BacktestResult is the result of a backtest: the alerts generated by an alerting rule, or the series
+// written by a recording rule.
+type BacktestResult struct {
+	Alerts []*BacktestAlert `json:"alerts,omitempty"`
+	Series promql.Matrix    `json:"series,omitempty"`
+}
+
+// Backtester evaluates rules over historical data, to validate them before deploying them.
+type Backtester struct {
+	queryable          storage.Queryable
+	engine             promql.QueryEngine
+	limits             RulesLimits
+	evaluationInterval time.Duration
+	logger             log.Logger
+}
+
+// NewBacktester creates a Backtester evaluating the rules with the given queryable and engine. The rule
+// unit tests are evaluated within the rule query limits of the tenants.
+func NewBacktester(cfg Config, queryable storage.Queryable, engine promql.QueryEngine, limits RulesLimits, logger log.Logger) *Backtester {
+	return &Backtester{
+		queryable:          queryable,
+		engine:             engine,
+		limits:             limits,
+		evaluationInterval: cfg.EvaluationInterval,
+		logger:             logger,
+	}
+}
+
+// BacktestRule evaluates the rule in the "rule" parameter, in the YAML format of the rules in a rule
+// group, at each step between the "start" and "end" parameters, and returns the alerts it would have
+// generated or the series it would have written. The step defaults to the ruler evaluation interval.
+func (b *Backtester) BacktestRule(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), b.logger)
+	userID, err := tenant.TenantID(req.Context())
+	if err != nil || userID == "" {
+		level.Error(logger).Log("msg", "error extracting org id from context", "err", err)
+		util_api.RespondError(logger, w, v1.ErrBadData, "no valid org id found", http.StatusBadRequest)
+		return
+	}
+
+	rule, start, end, step, err := parseBacktestRequest(req, b.evaluationInterval)
+	if err != nil {
+		util_api.RespondError(logger, w, v1.ErrBadData, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := backtestRule(req.Context(), rule, start, end, step, promRules.EngineQueryFunc(b.engine, b.queryable), logger)
+	if err != nil {
+		util_api.RespondError(logger, w, v1.ErrExec, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	data, err := json.Marshal(&util_api.Response{
+		Status: "success",
+		Data:   result,
+	})
+	if err != nil {
+		level.Error(logger).Log("msg", "error marshaling json response", "err", err)
+		util_api.RespondError(logger, w, v1.ErrServer, "unable to marshal the requested data", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if n, err := w.Write(data); err != nil {
+		level.Error(logger).Log("msg", "error writing response", "bytesWritten", n, "err", err)
+	}
+}
+
+func parseBacktestRequest(req *http.Request, defaultStep time.Duration) (rule rulefmt.RuleNode, start, end time.Time, step time.Duration, err error) {
+	if err = yaml.Unmarshal([]byte(req.FormValue("rule")), &rule); err != nil {
+		return rule, start, end, step, errors.Wrap(err, ErrBadRuleGroup.Error())
+	}
+	if errs := rule.Validate(); len(errs) > 0 {
+		return rule, start, end, step, &errs[0]
+	}
+
+	startMs, err := util.ParseTime(req.FormValue("start"))
+	if err != nil {
+		return rule, start, end, step, errors.Wrap(err, "invalid start")
+	}
+	endMs, err := util.ParseTime(req.FormValue("end"))
+	if err != nil {
+		return rule, start, end, step, errors.Wrap(err, "invalid end")
+	}
+	start, end = util.TimeFromMillis(startMs), util.TimeFromMillis(endMs)
+	if end.Before(start) {
+		return rule, start, end, step, errors.New("end timestamp must not be before start time")
+	}
+
+	step = defaultStep
+	if s := req.FormValue("step"); s != "" {
+		if step, err = parseBacktestStep(s); err != nil {
+			return rule, start, end, step, err
+		}
+	}
+	if step <= 0 {
+		return rule, start, end, step, errors.New("zero or negative step is not accepted, try a positive integer")
+	}
+	if end.Sub(start)/step >= maxBacktestSteps {
+		return rule, start, end, step, fmt.Errorf("exceeded maximum number of evaluations of %d, try decreasing the backtest range or increasing the step", maxBacktestSteps)
+	}
+
+	return rule, start, end, step, nil
+}
+
+// parseBacktestStep parses the step, as seconds or as a duration.
+func parseBacktestStep(s string) (time.Duration, error) {
+	if d, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(d * float64(time.Second)), nil
+	}
+	if d, err := model.ParseDuration(s); err == nil {
+		return time.Duration(d), nil
+	}
+	return 0, fmt.Errorf("cannot parse %q to a valid step", s)
+}
+
+// backtestRule evaluates the rule at each step between start and end, keeping the state of the
+// alerts between the evaluations like the rules manager does.
+func backtestRule(ctx context.Context, rule rulefmt.RuleNode, start, end time.Time, step time.Duration, query promRules.QueryFunc, logger log.Logger) (*BacktestResult, error) {
+	expr, err := parser.ParseExpr(rule.Expr.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	if rule.Record.Value != "" {
+		recording := promRules.NewRecordingRule(rule.Record.Value, expr, labels.FromMap(rule.Labels))
+
+		series := map[uint64]*promql.Series{}
+		for ts := start; !ts.After(end); ts = ts.Add(step) {
+			vector, err := recording.Eval(ctx, ts, query, nil, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, s := range vector {
+				h := s.Metric.Hash()
+				if _, ok := series[h]; !ok {
+					series[h] = &promql.Series{Metric: s.Metric}
+				}
+				series[h].Floats = append(series[h].Floats, promql.FPoint{T: s.T, F: s.F})
+			}
+		}
+
+		matrix := make(promql.Matrix, 0, len(series))
+		for _, s := range series {
+			matrix = append(matrix, *s)
+		}
+		sort.Sort(matrix)
+		return &BacktestResult{Series: matrix}, nil
+	}
+
+	alerting := promRules.NewAlertingRule(
+		rule.Alert.Value, expr, time.Duration(rule.For), time.Duration(rule.KeepFiringFor),
+		labels.FromMap(rule.Labels), labels.FromMap(rule.Annotations), labels.EmptyLabels(), "", true, logger,
+	)
+
+	// The alerts are tracked by their labels and the time they became active, so that each
+	// time an alert becomes active again it's returned as a different alert.
+	type alertKey struct {
+		hash     uint64
+		activeAt time.Time
+	}
+	alerts := map[alertKey]*BacktestAlert{}
+
+	for ts := start; !ts.After(end); ts = ts.Add(step) {
+		if _, err := alerting.Eval(ctx, ts, query, nil, 0); err != nil {
+			return nil, err
+		}
+
+		seen := map[alertKey]struct{}{}
+		alerting.ForEachActiveAlert(func(a *promRules.Alert) {
+			key := alertKey{hash: a.Labels.Hash(), activeAt: a.ActiveAt}
+			seen[key] = struct{}{}
+			alert, ok := alerts[key]
+			if !ok {
+				alert = &BacktestAlert{Labels: a.Labels, Annotations: a.Annotations, ActiveAt: a.ActiveAt}
+				alerts[key] = alert
+			}
+
+			alert.State = a.State.String()
+			if !a.FiredAt.IsZero() {
+				firedAt := a.FiredAt
+				alert.FiredAt = &firedAt
+			}
+			if !a.ResolvedAt.IsZero() {
+				resolvedAt := a.ResolvedAt
+				alert.ResolvedAt = &resolvedAt
+			}
+		})
+
+		// The pending alerts are dropped by the rule as soon as they're not active anymore.
+		for key, alert := range alerts {
+			if _, ok := seen[key]; !ok && alert.State == promRules.StatePending.String() {
+				alert.State = promRules.StateInactive.String()
+			}
+		}
+	}
+
+	result := &BacktestResult{Alerts: make([]*BacktestAlert, 0, len(alerts))}
+	for _, alert := range alerts {
+		result.Alerts = append(result.Alerts, alert)
+	}
+	sort.Slice(result.Alerts, func(i, j int) bool {
+		if !result.Alerts[i].ActiveAt.Equal(result.Alerts[j].ActiveAt) {
+			return result.Alerts[i].ActiveAt.Before(result.Alerts[j].ActiveAt)
+		}
+		return labels.Compare(result.Alerts[i].Labels, result.Alerts[j].Labels) < 0
+	})
+	return result, nil
+}
@@ -0,0 +1,115 @@
+package ruler
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBacktestRule(t *testing.T) {
+	start := time.Unix(0, 0)
+	end := start.Add(10 * time.Minute)
+	series := labels.FromStrings(labels.MetricName, "errors", "job", "api")
+
+	// The query returns a result between the 2nd and 5th minutes, and at the 9th minute.
+	query := func(_ context.Context, _ string, ts time.Time) (promql.Vector, error) {
+		if m := ts.Sub(start) / time.Minute; (m >= 2 && m <= 5) || m == 9 {
+			return promql.Vector{{T: ts.UnixMilli(), F: float64(m), Metric: series}}, nil
+		}
+		return nil, nil
+	}
+
+	t.Run("alerting rule", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(url.Values{
+			"rule":  []string{"alert: HighErrors\nexpr: errors > 0\nfor: 2m\nlabels:\n  severity: page\n"},
+			"start": []string{"0"},
+			"end":   []string{"600"},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rule, reqStart, reqEnd, step, err := parseBacktestRequest(req, time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, start, reqStart)
+		assert.Equal(t, end, reqEnd)
+		assert.Equal(t, time.Minute, step)
+
+		result, err := backtestRule(context.Background(), rule, reqStart, reqEnd, step, query, log.NewNopLogger())
+		require.NoError(t, err)
+		require.Len(t, result.Alerts, 2)
+
+		expectedLabels := labels.FromStrings(labels.AlertName, "HighErrors", "job", "api", "severity", "page")
+
+		// The first alert fires after being active for 2 minutes, and is resolved when the result disappears.
+		first := result.Alerts[0]
+		assert.Equal(t, expectedLabels, first.Labels)
+		assert.Equal(t, "inactive", first.State)
+		assert.Equal(t, start.Add(2*time.Minute), first.ActiveAt)
+		require.NotNil(t, first.FiredAt)
+		assert.Equal(t, start.Add(4*time.Minute), *first.FiredAt)
+		require.NotNil(t, first.ResolvedAt)
+		assert.Equal(t, start.Add(6*time.Minute), *first.ResolvedAt)
+
+		// The second alert is active for a single evaluation, so it never fires.
+		second := result.Alerts[1]
+		assert.Equal(t, expectedLabels, second.Labels)
+		assert.Equal(t, "inactive", second.State)
+		assert.Equal(t, start.Add(9*time.Minute), second.ActiveAt)
+		assert.Nil(t, second.FiredAt)
+	})
+
+	t.Run("recording rule", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(url.Values{
+			"rule":  []string{"record: job:errors\nexpr: errors\n"},
+			"start": []string{"0"},
+			"end":   []string{"600"},
+			"step":  []string{"2m"},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rule, reqStart, reqEnd, step, err := parseBacktestRequest(req, time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, 2*time.Minute, step)
+
+		result, err := backtestRule(context.Background(), rule, reqStart, reqEnd, step, query, log.NewNopLogger())
+		require.NoError(t, err)
+		assert.Equal(t, promql.Matrix{{
+			Metric: labels.FromStrings(labels.MetricName, "job:errors", "job", "api"),
+			Floats: []promql.FPoint{{T: 120000, F: 2}, {T: 240000, F: 4}},
+		}}, result.Series)
+	})
+}
+
+func TestParseBacktestRequest_ShouldRejectInvalidRequests(t *testing.T) {
+	for name, tc := range map[string]struct {
+		params      url.Values
+		expectedErr string
+	}{
+		"invalid rule": {
+			params:      url.Values{"rule": []string{"expr: up\n"}, "start": []string{"0"}, "end": []string{"60"}},
+			expectedErr: "one of 'record' or 'alert' must be set",
+		},
+		"end before start": {
+			params:      url.Values{"rule": []string{"record: r\nexpr: up\n"}, "start": []string{"60"}, "end": []string{"0"}},
+			expectedErr: "end timestamp must not be before start time",
+		},
+		"too many evaluations": {
+			params:      url.Values{"rule": []string{"record: r\nexpr: up\n"}, "start": []string{"0"}, "end": []string{"11000"}, "step": []string{"1"}},
+			expectedErr: "exceeded maximum number of evaluations of 11000, try decreasing the backtest range or increasing the step",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(tc.params.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			_, _, _, _, err := parseBacktestRequest(req, time.Minute)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expectedErr)
+		})
+	}
+}
@@ -45,6 +45,12 @@ type PusherAppender struct {
 	samples         []cortexpb.Sample
 	userID          string
 	evaluationDelay time.Duration
+
+	// err is returned on commit, when the series can't be written to the destination tenant.
+	err error
+
+	// groupStats record the series written by the rule groups, if enabled.
+	groupStats *ruleGroupsStats
 }
 
 func (a *PusherAppender) AppendHistogram(storage.SeriesRef, labels.Labels, int64, *histogram.Histogram, *histogram.FloatHistogram) (storage.SeriesRef, error) {
@@ -85,6 +91,14 @@ func (a *PusherAppender) AppendExemplar(_ storage.SeriesRef, _ labels.Labels, _
 func (a *PusherAppender) Commit() error {
 	a.totalWrites.Inc()
 
+	if a.err != nil {
+		a.failedWrites.Inc()
+		a.recordWrite(a.err)
+		a.labels = nil
+		a.samples = nil
+		return a.err
+	}
+
 	// Since a.pusher is distributor, client.ReuseSlice will be called in a.pusher.Push.
 	// We shouldn't call client.ReuseSlice here.
 	_, err := a.pusher.Push(user.InjectOrgID(a.ctx, a.userID), cortexpb.ToWriteRequest(a.labels, a.samples, nil, nil, cortexpb.RULE))
@@ -95,12 +109,24 @@ func (a *PusherAppender) Commit() error {
 			a.failedWrites.Inc()
 		}
 	}
+	a.recordWrite(err)
 
 	a.labels = nil
 	a.samples = nil
 	return err
 }
 
+func (a *PusherAppender) recordWrite(err error) {
+	if a.groupStats == nil {
+		return
+	}
+	if err != nil {
+		a.groupStats.record(a.ctx, RuleGroupStatsDesc{}, failureReasonWrite)
+		return
+	}
+	a.groupStats.record(a.ctx, RuleGroupStatsDesc{SeriesWritten: uint64(len(a.labels))}, "")
+}
+
 func (a *PusherAppender) UpdateMetadata(_ storage.SeriesRef, _ labels.Labels, _ metadata.Metadata) (storage.SeriesRef, error) {
 	return 0, errors.New("update metadata unsupported")
 }
@@ -119,6 +145,11 @@ type PusherAppendable struct {
 
 	totalWrites  prometheus.Counter
 	failedWrites prometheus.Counter
+
+	// federatedGroups are used to write the series of the rule groups to their destination tenant.
+	federatedGroups *federatedRuleGroups
+	// groupStats record the series written by the rule groups, if enabled.
+	groupStats *ruleGroupsStats
 }
 
 func NewPusherAppendable(pusher Pusher, userID string, limits RulesLimits, totalWrites, failedWrites prometheus.Counter) *PusherAppendable {
@@ -133,14 +164,22 @@ func NewPusherAppendable(pusher Pusher, userID string, limits RulesLimits, total
 
 // Appender returns a storage.Appender
 func (t *PusherAppendable) Appender(ctx context.Context) storage.Appender {
+	userID := t.userID
+	var err error
+	if t.federatedGroups != nil {
+		userID, err = destinationTenant(ctx, t.federatedGroups, t.rulesLimits, t.userID)
+	}
+
 	return &PusherAppender{
 		failedWrites: t.failedWrites,
 		totalWrites:  t.totalWrites,
 
 		ctx:             ctx,
 		pusher:          t.pusher,
-		userID:          t.userID,
+		userID:          userID,
 		evaluationDelay: t.rulesLimits.EvaluationDelay(t.userID),
+		err:             err,
+		groupStats:      t.groupStats,
 	}
 }
 
@@ -152,6 +191,16 @@ type RulesLimits interface {
 	RulerMaxRuleGroupsPerTenant(userID string) int
 	RulerMaxRulesPerRuleGroup(userID string) int
 	DisabledRuleGroups(userID string) validation.DisabledRuleGroups
+	RulerAllowedSourceTenants(userID string) []string
+	RulerAllowedDestinationTenants(userID string) []string
+	RulerAllowedAlertmanagerTenants(userID string) []string
+	RulerAllowedAlertmanagerURLs(userID string) []string
+	RulerMaxConcurrentEvaluations(userID string) int
+	RulerEvaluationQueryTimeout(userID string) time.Duration
+	RulerMaxQueryBytesPerMinute(userID string) int64
+	RulerMaxSeriesPerRule(userID string) int
+	RulerMaxAlertsPerRule(userID string) int
+	RulerMaxSeriesPerRuleGroup(userID string) int
 }
 
 // EngineQueryFunc returns a new engine query function by passing an altered timestamp.
@@ -295,6 +344,107 @@ type RulesManager interface {
 	RuleGroups() []*rules.Group
 }
 
+// tenantRulesManager is the rules manager of a tenant, running the queries of the federated rule groups
+// across their source tenants, sending the alerts to the additional alertmanagers of the rule groups,
+// restoring the state of the alerts from their snapshots, catching up the missed evaluations of the
+// recording rules, and recording the query statistics of the rule groups.
+type tenantRulesManager struct {
+	*rules.Manager
+
+	federatedGroups    *federatedRuleGroups
+	alertState         *alertStateSnapshots
+	alertmanagerRouter *alertmanagerRouter
+	groupStats         *ruleGroupsStats
+
+	queryFunc  rules.QueryFunc
+	appendable storage.Appendable
+}
+
+// SetFederatedRuleGroups sets the federated rule groups, by rule file and group name.
+func (m *tenantRulesManager) SetFederatedRuleGroups(groups map[ruleGroupRef]federatedRuleGroup) {
+	m.federatedGroups.set(groups)
+}
+
+// SetAlertmanagerNotifiers sets the notifiers sending the alerts of the rule groups to their additional alertmanagers.
+func (m *tenantRulesManager) SetAlertmanagerNotifiers(notifiers alertmanagerNotifiers) {
+	m.alertmanagerRouter.setNotifiers(notifiers)
+}
+
+// RetainRuleGroupStats drops the query statistics of the rule groups not loaded anymore.
+func (m *tenantRulesManager) RetainRuleGroupStats(groups map[ruleGroupRef]struct{}) {
+	if m.groupStats != nil {
+		m.groupStats.retain(groups)
+	}
+}
+
+// RuleGroupStats returns the query statistics of the last evaluation, and of all the evaluations, of the
+// rule group. They're nil if the rule group stats are disabled or the rule group hasn't been evaluated yet.
+func (m *tenantRulesManager) RuleGroupStats(ref ruleGroupRef) (last, total *RuleGroupStatsDesc) {
+	if m.groupStats == nil {
+		return nil, nil
+	}
+	return m.groupStats.get(ref)
+}
+
+// UpdateAlertStateSnapshots adds the loaded snapshots of the rule groups, and drops the ones of the
+// rule groups not loaded anymore.
+func (m *tenantRulesManager) UpdateAlertStateSnapshots(loaded map[ruleGroupRef]*alertStateSnapshot, groups map[ruleGroupRef]struct{}) {
+	m.alertState.update(loaded, groups)
+}
+
+// EvalRecordingRules evaluates the recording rules of the rule group at the timestamp, and writes their
+// series. The alerting rules and the state of the rule group are left untouched. It returns the first
+// error, after evaluating all the recording rules.
+func (m *tenantRulesManager) EvalRecordingRules(ctx context.Context, g *rules.Group, ts time.Time) error {
+	ctx = contextWithRuleGroupOutput(ctx)
+
+	var firstErr error
+	for _, rule := range g.Rules() {
+		recordingRule, ok := rule.(*rules.RecordingRule)
+		if !ok {
+			continue
+		}
+
+		if err := m.evalRecordingRule(ctx, recordingRule, g.Limit(), ts); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("recording rule %s: %w", recordingRule.Name(), err)
+		}
+	}
+	return firstErr
+}
+
+func (m *tenantRulesManager) evalRecordingRule(ctx context.Context, rule *rules.RecordingRule, limit int, ts time.Time) error {
+	vector, err := rule.Eval(ctx, ts, m.queryFunc, nil, limit)
+	if err != nil {
+		return err
+	}
+
+	app := m.appendable.Appender(ctx)
+	for _, s := range vector {
+		if _, err := app.Append(0, s.Metric, s.T, s.F); err != nil {
+			_ = app.Rollback()
+			return err
+		}
+	}
+	return app.Commit()
+}
+
+// federatedRulesManagerSetter is implemented by the rules managers supporting federated rule groups.
+type federatedRulesManagerSetter interface {
+	SetFederatedRuleGroups(groups map[ruleGroupRef]federatedRuleGroup)
+}
+
+// alertStateRulesManager is implemented by the rules managers restoring the state of the alerts from
+// their snapshots.
+type alertStateRulesManager interface {
+	UpdateAlertStateSnapshots(loaded map[ruleGroupRef]*alertStateSnapshot, groups map[ruleGroupRef]struct{})
+}
+
+// recordingRulesEvaluator is implemented by the rules managers evaluating the recording rules of a rule
+// group at any timestamp, to catch up their missed evaluations.
+type recordingRulesEvaluator interface {
+	EvalRecordingRules(ctx context.Context, g *rules.Group, ts time.Time) error
+}
+
 // ManagerFactory is a function that creates new RulesManager for given user and notifier.Manager.
 type ManagerFactory func(ctx context.Context, userID string, notifier *notifier.Manager, logger log.Logger, reg prometheus.Registerer) RulesManager
 
@@ -304,6 +454,22 @@ func DefaultTenantManagerFactory(cfg Config, p Pusher, q storage.Queryable, engi
 	// Errors from PromQL are always "user" errors.
 	q = querier.NewErrorTranslateQueryableWithFn(q, WrapQueryableErrors)
 
+	return newTenantManagerFactory(cfg, p, q, func(userID string) rules.QueryFunc {
+		return EngineQueryFunc(engine, q, overrides, userID, cfg.LookbackDelta)
+	}, overrides, evalMetrics)
+}
+
+// FrontendTenantManagerFactory returns a ManagerFactory evaluating the rule queries through the query-frontend.
+// The queryable is only used to restore the "for" state of the alerts.
+func FrontendTenantManagerFactory(cfg Config, p Pusher, q storage.Queryable, client *FrontendClient, overrides RulesLimits, evalMetrics *RuleEvalMetrics) ManagerFactory {
+	q = querier.NewErrorTranslateQueryableWithFn(q, WrapQueryableErrors)
+
+	return newTenantManagerFactory(cfg, p, q, func(userID string) rules.QueryFunc {
+		return FrontendQueryFunc(client, overrides, userID)
+	}, overrides, evalMetrics)
+}
+
+func newTenantManagerFactory(cfg Config, p Pusher, q storage.Queryable, queryFunc func(userID string) rules.QueryFunc, overrides RulesLimits, evalMetrics *RuleEvalMetrics) ManagerFactory {
 	return func(ctx context.Context, userID string, notifier *notifier.Manager, logger log.Logger, reg prometheus.Registerer) RulesManager {
 		var queryTime prometheus.Counter
 		if evalMetrics.RulerQuerySeconds != nil {
@@ -315,16 +481,30 @@ func DefaultTenantManagerFactory(cfg Config, p Pusher, q storage.Queryable, engi
 		totalWrites := evalMetrics.TotalWritesVec.WithLabelValues(userID)
 		failedWrites := evalMetrics.FailedWritesVec.WithLabelValues(userID)
 
-		engineQueryFunc := EngineQueryFunc(engine, q, overrides, userID, cfg.LookbackDelta)
-		metricsQueryFunc := MetricsQueryFunc(engineQueryFunc, totalQueries, failedQueries)
+		var groupStats *ruleGroupsStats
+		if cfg.RuleGroupStatsEnabled {
+			groupStats = newRuleGroupsStats(userID, evalMetrics)
+		}
+
+		federatedGroups := &federatedRuleGroups{}
+		limitedQueryFunc := LimitedQueryFunc(SourceTenantsQueryFunc(queryFunc(userID), federatedGroups, overrides, userID), overrides, userID)
+		metricsQueryFunc := MetricsQueryFunc(RuleGroupStatsQueryFunc(limitedQueryFunc, groupStats), totalQueries, failedQueries)
+
+		appendable := NewPusherAppendable(p, userID, overrides, totalWrites, failedWrites)
+		appendable.federatedGroups = federatedGroups
+		appendable.groupStats = groupStats
+		alertState := &alertStateSnapshots{}
+		alertmanagerRouter := &alertmanagerRouter{groups: federatedGroups, overrides: overrides, userID: userID, logger: log.With(logger, "user", userID)}
+
+		ruleQueryFunc := RecordAndReportRuleQueryMetrics(metricsQueryFunc, queryTime, logger)
 
-		return rules.NewManager(&rules.ManagerOptions{
-			Appendable:             NewPusherAppendable(p, userID, overrides, totalWrites, failedWrites),
-			Queryable:              q,
-			QueryFunc:              RecordAndReportRuleQueryMetrics(metricsQueryFunc, queryTime, logger),
+		manager := rules.NewManager(&rules.ManagerOptions{
+			Appendable:             appendable,
+			Queryable:              alertStateQueryable{Queryable: q, snapshots: alertState},
+			QueryFunc:              ruleQueryFunc,
 			Context:                user.InjectOrgID(ctx, userID),
 			ExternalURL:            cfg.ExternalURL.URL,
-			NotifyFunc:             SendAlerts(notifier, cfg.ExternalURL.URL.String()),
+			NotifyFunc:             alertmanagerRouter.notifyFunc(SendAlerts(notifier, cfg.ExternalURL.URL.String()), cfg.ExternalURL.URL.String()),
 			Logger:                 log.With(logger, "user", userID),
 			Registerer:             reg,
 			OutageTolerance:        cfg.OutageTolerance,
@@ -333,6 +513,7 @@ func DefaultTenantManagerFactory(cfg Config, p Pusher, q storage.Queryable, engi
 			ConcurrentEvalsEnabled: cfg.ConcurrentEvalsEnabled,
 			MaxConcurrentEvals:     cfg.MaxConcurrentEvals,
 		})
+		return &tenantRulesManager{Manager: manager, federatedGroups: federatedGroups, alertState: alertState, alertmanagerRouter: alertmanagerRouter, groupStats: groupStats, queryFunc: ruleQueryFunc, appendable: appendable}
 	}
 }
 
@@ -18,6 +18,7 @@ import (
 	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
 
 	"github.com/cortexproject/cortex/pkg/cortexpb"
 	"github.com/cortexproject/cortex/pkg/util/validation"
@@ -27,10 +28,12 @@ type fakePusher struct {
 	request  *cortexpb.WriteRequest
 	response *cortexpb.WriteResponse
 	err      error
+	orgID    string
 }
 
 func (p *fakePusher) Push(ctx context.Context, r *cortexpb.WriteRequest) (*cortexpb.WriteResponse, error) {
 	p.request = r
+	p.orgID, _ = user.ExtractOrgID(ctx)
 	return p.response, p.err
 }
 
@@ -0,0 +1,186 @@
+package ruler
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/ruler/rulespb"
+	"github.com/cortexproject/cortex/pkg/util/concurrency"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+// groupCostKey identifies a rule group in the cost-based assignment.
+type groupCostKey struct {
+	user      string
+	namespace string
+	group     string
+}
+
+func groupCostKeyFor(g *rulespb.RuleGroupDesc) groupCostKey {
+	return groupCostKey{user: g.User, namespace: g.Namespace, group: g.Name}
+}
+
+// costBasedAssignment is the assignment of the rule groups to the rulers computed from
+// the rule groups evaluation cost.
+type costBasedAssignment struct {
+	mtx    sync.RWMutex
+	owners map[groupCostKey]string
+}
+
+func (a *costBasedAssignment) set(owners map[groupCostKey]string) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.owners = owners
+}
+
+func (a *costBasedAssignment) owner(g *rulespb.RuleGroupDesc) (string, bool) {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	addr, ok := a.owners[groupCostKeyFor(g)]
+	return addr, ok
+}
+
+// ruleGroupCost returns the fraction of time spent evaluating the rule group, computed from the
+// duration of its last evaluation and its evaluation interval. The cost is rounded up to a power
+// of two, so that the small differences between the evaluations don't change the assignment.
+func ruleGroupCost(g *GroupStateDesc, defaultInterval time.Duration) float64 {
+	interval := g.Group.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	if g.EvaluationDuration <= 0 || interval <= 0 {
+		return 0
+	}
+
+	cost := g.EvaluationDuration.Seconds() / interval.Seconds()
+	return math.Exp2(math.Ceil(math.Log2(cost)))
+}
+
+// assignRuleGroupsByCost assigns each rule group to one of the instances, balancing the cost
+// of the rule groups assigned to each instance. The rule groups are assigned from the most
+// expensive one to the instance with the lowest assigned cost so far. The assignment only
+// depends on the input, so that all rulers compute the same assignment from the same costs.
+func assignRuleGroupsByCost(costs map[groupCostKey]float64, instances []string) map[groupCostKey]string {
+	if len(instances) == 0 {
+		return nil
+	}
+
+	keys := make([]groupCostKey, 0, len(costs))
+	for key := range costs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if costs[keys[i]] != costs[keys[j]] {
+			return costs[keys[i]] > costs[keys[j]]
+		}
+		if keys[i].user != keys[j].user {
+			return keys[i].user < keys[j].user
+		}
+		if keys[i].namespace != keys[j].namespace {
+			return keys[i].namespace < keys[j].namespace
+		}
+		return keys[i].group < keys[j].group
+	})
+
+	sorted := append([]string(nil), instances...)
+	sort.Strings(sorted)
+	loads := make([]float64, len(sorted))
+
+	owners := make(map[groupCostKey]string, len(keys))
+	for _, key := range keys {
+		lowest := 0
+		for i := range loads {
+			if loads[i] < loads[lowest] {
+				lowest = i
+			}
+		}
+
+		owners[key] = sorted[lowest]
+		loads[lowest] += costs[key]
+	}
+	return owners
+}
+
+// nextRuleGroupsRebalance returns the next time the rule groups are rebalanced, aligned to
+// the rebalance interval, so that all rulers rebalance at the same time.
+func nextRuleGroupsRebalance(now time.Time, interval time.Duration) time.Time {
+	return now.Truncate(interval).Add(interval)
+}
+
+// rebalanceRuleGroupsByCost fetches the evaluation cost of the rule groups from all rulers and
+// updates the cost-based assignment. If the cost of any tenant can't be fetched, the previous
+// assignment is kept, given a partial view would lead to a different assignment than the one
+// computed by the other rulers.
+func (r *Ruler) rebalanceRuleGroupsByCost(ctx context.Context) {
+	healthy, err := r.ring.GetAllHealthy(RingOp)
+	if err != nil {
+		level.Warn(r.logger).Log("msg", "unable to rebalance rule groups by cost, failed to get the healthy rulers", "err", err)
+		return
+	}
+
+	users, err := r.store.ListAllUsers(ctx)
+	if err != nil {
+		level.Warn(r.logger).Log("msg", "unable to rebalance rule groups by cost, failed to list users", "err", err)
+		return
+	}
+
+	var (
+		mtx   sync.Mutex
+		costs = map[groupCostKey]float64{}
+	)
+	err = concurrency.ForEachUser(ctx, users, loadRulesConcurrency, func(ctx context.Context, userID string) error {
+		groups, err := r.getShardedRules(user.InjectOrgID(ctx, userID), userID, RulesRequest{})
+		if err != nil {
+			return errors.Wrapf(err, "failed to fetch rule groups for user %s", userID)
+		}
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		for _, g := range groups {
+			// The backup replicas report the rule groups too, so we keep the highest cost.
+			key := groupCostKeyFor(g.Group)
+			if cost := ruleGroupCost(g, r.cfg.EvaluationInterval); cost > costs[key] {
+				costs[key] = cost
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		level.Warn(r.logger).Log("msg", "unable to rebalance rule groups by cost, keeping the previous assignment", "err", err)
+		return
+	}
+
+	// The rule groups not evaluated yet are sharded by hash until the next rebalance.
+	for key, cost := range costs {
+		if cost == 0 {
+			delete(costs, key)
+		}
+	}
+
+	r.costAssignment.set(assignRuleGroupsByCost(costs, healthy.GetAddresses()))
+	level.Info(r.logger).Log("msg", "rebalanced rule groups by cost", "groups", len(costs), "rulers", len(healthy.Instances))
+}
+
+// filterRuleGroupsByCost splits the rule groups between the ones assigned to a healthy ruler by the
+// cost-based assignment, returning the ones assigned to this instance, and the unassigned ones.
+func filterRuleGroupsByCost(ruleGroups []*rulespb.RuleGroupDesc, disabledRuleGroups validation.DisabledRuleGroups, assignment *costBasedAssignment, healthy map[string]struct{}, instanceAddr string) (owned, unassigned []*rulespb.RuleGroupDesc) {
+	for _, g := range ruleGroups {
+		addr, ok := assignment.owner(g)
+		if _, isHealthy := healthy[addr]; !ok || !isHealthy {
+			unassigned = append(unassigned, g)
+			continue
+		}
+
+		if addr == instanceAddr && !ruleGroupDisabled(g, disabledRuleGroups) {
+			owned = append(owned, g)
+		}
+	}
+	return owned, unassigned
+}
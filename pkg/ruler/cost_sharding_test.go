@@ -0,0 +1,84 @@
+package ruler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cortexproject/cortex/pkg/ruler/rulespb"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+func TestRuleGroupCost(t *testing.T) {
+	group := func(interval, duration time.Duration) *GroupStateDesc {
+		return &GroupStateDesc{
+			Group:              &rulespb.RuleGroupDesc{Interval: interval},
+			EvaluationDuration: duration,
+		}
+	}
+
+	// The cost is rounded up to a power of two.
+	assert.Equal(t, 0.125, ruleGroupCost(group(time.Minute, 6*time.Second), time.Minute))
+	assert.Equal(t, 0.125, ruleGroupCost(group(time.Minute, 7*time.Second), time.Minute))
+	assert.Equal(t, 0.5, ruleGroupCost(group(10*time.Second, 4*time.Second), time.Minute))
+
+	// The default evaluation interval is used when the rule group doesn't set one.
+	assert.Equal(t, 0.5, ruleGroupCost(group(0, 30*time.Second), time.Minute))
+
+	// The rule groups not evaluated yet have no cost.
+	assert.Equal(t, float64(0), ruleGroupCost(group(time.Minute, 0), time.Minute))
+}
+
+func TestAssignRuleGroupsByCost(t *testing.T) {
+	costs := map[groupCostKey]float64{
+		{user: "user-1", namespace: "ns", group: "expensive-1"}: 1,
+		{user: "user-1", namespace: "ns", group: "expensive-2"}: 1,
+		{user: "user-1", namespace: "ns", group: "medium"}:      0.5,
+		{user: "user-2", namespace: "ns", group: "cheap-1"}:     0.25,
+		{user: "user-2", namespace: "ns", group: "cheap-2"}:     0.25,
+	}
+
+	owners := assignRuleGroupsByCost(costs, []string{"ruler-2", "ruler-1"})
+	assert.Equal(t, map[groupCostKey]string{
+		{user: "user-1", namespace: "ns", group: "expensive-1"}: "ruler-1",
+		{user: "user-1", namespace: "ns", group: "expensive-2"}: "ruler-2",
+		{user: "user-1", namespace: "ns", group: "medium"}:      "ruler-1",
+		{user: "user-2", namespace: "ns", group: "cheap-1"}:     "ruler-2",
+		{user: "user-2", namespace: "ns", group: "cheap-2"}:     "ruler-2",
+	}, owners)
+
+	// The assignment doesn't depend on the order of the instances.
+	assert.Equal(t, owners, assignRuleGroupsByCost(costs, []string{"ruler-1", "ruler-2"}))
+
+	assert.Nil(t, assignRuleGroupsByCost(costs, nil))
+}
+
+func TestFilterRuleGroupsByCost(t *testing.T) {
+	assigned := &rulespb.RuleGroupDesc{User: "user-1", Namespace: "ns", Name: "assigned"}
+	assignedToOther := &rulespb.RuleGroupDesc{User: "user-1", Namespace: "ns", Name: "assigned-to-other"}
+	assignedToUnhealthy := &rulespb.RuleGroupDesc{User: "user-1", Namespace: "ns", Name: "assigned-to-unhealthy"}
+	disabled := &rulespb.RuleGroupDesc{User: "user-1", Namespace: "ns", Name: "disabled"}
+	unassigned := &rulespb.RuleGroupDesc{User: "user-1", Namespace: "ns", Name: "unassigned"}
+
+	assignment := &costBasedAssignment{}
+	assignment.set(map[groupCostKey]string{
+		groupCostKeyFor(assigned):            "ruler-1",
+		groupCostKeyFor(assignedToOther):     "ruler-2",
+		groupCostKeyFor(assignedToUnhealthy): "ruler-3",
+		groupCostKeyFor(disabled):            "ruler-1",
+	})
+	healthy := map[string]struct{}{"ruler-1": {}, "ruler-2": {}}
+	disabledRuleGroups := validation.DisabledRuleGroups{{User: "user-1", Namespace: "ns", Name: "disabled"}}
+
+	owned, notAssigned := filterRuleGroupsByCost(
+		[]*rulespb.RuleGroupDesc{assigned, assignedToOther, assignedToUnhealthy, disabled, unassigned},
+		disabledRuleGroups, assignment, healthy, "ruler-1")
+	assert.Equal(t, []*rulespb.RuleGroupDesc{assigned}, owned)
+	assert.Equal(t, []*rulespb.RuleGroupDesc{assignedToUnhealthy, unassigned}, notAssigned)
+}
+
+func TestNextRuleGroupsRebalance(t *testing.T) {
+	assert.Equal(t, time.Unix(1200, 0), nextRuleGroupsRebalance(time.Unix(1000, 0), 10*time.Minute))
+	assert.Equal(t, time.Unix(1800, 0), nextRuleGroupsRebalance(time.Unix(1200, 0), 10*time.Minute))
+}
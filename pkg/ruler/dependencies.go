@@ -0,0 +1,255 @@
+package ruler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql/parser"
+	promRules "github.com/prometheus/prometheus/rules"
+
+	"github.com/cortexproject/cortex/pkg/ruler/rulespb"
+)
+
+// exprMetricNames returns the names of the metrics selected by the expression.
+func exprMetricNames(expr string) (map[string]struct{}, error) {
+	e, err := parser.ParseExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]struct{}{}
+	parser.Inspect(e, func(node parser.Node, _ []parser.Node) error {
+		vs, ok := node.(*parser.VectorSelector)
+		if !ok {
+			return nil
+		}
+		if vs.Name != "" {
+			names[vs.Name] = struct{}{}
+			return nil
+		}
+		for _, m := range vs.LabelMatchers {
+			if m.Name == labels.MetricName && m.Type == labels.MatchEqual {
+				names[m.Value] = struct{}{}
+			}
+		}
+		return nil
+	})
+	return names, nil
+}
+
+// orderRulesByDependencies returns the rules ordered so that the recording rules are evaluated before
+// the rules using the series they record, keeping the original order otherwise. The rules depending
+// on each other, or whose expression can't be parsed, are kept in their original order.
+func orderRulesByDependencies(rules []rulefmt.RuleNode) []rulefmt.RuleNode {
+	outputs := map[string][]int{}
+	for i, r := range rules {
+		if r.Record.Value != "" {
+			outputs[r.Record.Value] = append(outputs[r.Record.Value], i)
+		}
+	}
+	if len(outputs) == 0 {
+		return rules
+	}
+
+	// The number of dependencies of each rule not ordered yet, and the rules depending on each rule.
+	pending := make([]int, len(rules))
+	dependents := make([][]int, len(rules))
+	for i, r := range rules {
+		inputs, err := exprMetricNames(r.Expr.Value)
+		if err != nil {
+			return rules
+		}
+		for name := range inputs {
+			for _, j := range outputs[name] {
+				if j != i {
+					pending[i]++
+					dependents[j] = append(dependents[j], i)
+				}
+			}
+		}
+	}
+
+	ordered := make([]rulefmt.RuleNode, 0, len(rules))
+	done := make([]bool, len(rules))
+	for len(ordered) < len(rules) {
+		next := -1
+		for i := range rules {
+			if !done[i] && pending[i] == 0 {
+				next = i
+				break
+			}
+		}
+
+		if next == -1 {
+			// The remaining rules depend on each other.
+			for i := range rules {
+				if !done[i] {
+					ordered = append(ordered, rules[i])
+				}
+			}
+			break
+		}
+
+		done[next] = true
+		ordered = append(ordered, rules[next])
+		for _, d := range dependents[next] {
+			pending[d]--
+		}
+	}
+	return ordered
+}
+
+// orderRuleGroupsByDependencies orders the rules of each rule group by their dependencies.
+func orderRuleGroupsByDependencies(groups map[string][]rulefmt.RuleGroup) {
+	for _, namespaceGroups := range groups {
+		for i := range namespaceGroups {
+			namespaceGroups[i].Rules = orderRulesByDependencies(namespaceGroups[i].Rules)
+		}
+	}
+}
+
+// ruleGroupDependenciesFor returns the rule groups each rule group depends on, because it uses the
+// series recorded by them, by the rule file the mapper writes them to and their name. The federated
+// rule groups and the rule groups depending on each other have no dependencies.
+func ruleGroupDependenciesFor(m *mapper, userID string, groups rulespb.RuleGroupList) map[ruleGroupRef][]ruleGroupRef {
+	refs := make([]ruleGroupRef, len(groups))
+	outputs := map[string][]int{}
+	for i, g := range groups {
+		refs[i] = ruleGroupRef{file: m.ruleFile(userID, g.Namespace), name: g.Name}
+
+		// The series recorded by the rule groups with a destination tenant are written to another tenant.
+		if g.DestinationTenant != "" {
+			continue
+		}
+		for _, r := range g.Rules {
+			if r.Record != "" {
+				outputs[r.Record] = append(outputs[r.Record], i)
+			}
+		}
+	}
+
+	edges := make([][]int, len(groups))
+	for i, g := range groups {
+		// The federated rule groups query other tenants.
+		if len(g.SourceTenants) > 0 {
+			continue
+		}
+
+		seen := map[int]struct{}{}
+		for _, r := range g.Rules {
+			inputs, err := exprMetricNames(r.Expr)
+			if err != nil {
+				continue
+			}
+			for name := range inputs {
+				for _, j := range outputs[name] {
+					if _, ok := seen[j]; ok || j == i {
+						continue
+					}
+					seen[j] = struct{}{}
+					edges[i] = append(edges[i], j)
+				}
+			}
+		}
+	}
+
+	dependencies := map[ruleGroupRef][]ruleGroupRef{}
+	for i := range groups {
+		for _, j := range edges[i] {
+			if reachable(edges, j, i) {
+				continue
+			}
+			dependencies[refs[i]] = append(dependencies[refs[i]], refs[j])
+		}
+	}
+	return dependencies
+}
+
+// reachable returns whether the node to can be reached from the node from.
+func reachable(edges [][]int, from, to int) bool {
+	visited := make([]bool, len(edges))
+	stack := []int{from}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if n == to {
+			return true
+		}
+		if visited[n] {
+			continue
+		}
+		visited[n] = true
+		stack = append(stack, edges[n]...)
+	}
+	return false
+}
+
+// ruleGroupDependencies tracks the dependencies between the rule groups of a rules manager.
+type ruleGroupDependencies struct {
+	mtx          sync.RWMutex
+	dependencies map[ruleGroupRef][]ruleGroupRef
+	groups       map[ruleGroupRef]*promRules.Group
+}
+
+func (d *ruleGroupDependencies) setDependencies(dependencies map[ruleGroupRef][]ruleGroupRef) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.dependencies = dependencies
+}
+
+func (d *ruleGroupDependencies) setGroups(groups []*promRules.Group) {
+	byRef := make(map[ruleGroupRef]*promRules.Group, len(groups))
+	for _, g := range groups {
+		byRef[ruleGroupRef{file: g.File(), name: g.Name()}] = g
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.groups = byRef
+}
+
+// dependenciesOf returns the loaded rule groups the rule group depends on.
+func (d *ruleGroupDependencies) dependenciesOf(ref ruleGroupRef) []*promRules.Group {
+	d.mtx.RLock()
+	defer d.mtx.RUnlock()
+
+	var groups []*promRules.Group
+	for _, dep := range d.dependencies[ref] {
+		if g, ok := d.groups[dep]; ok {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// evalTimestamp returns the timestamp to evaluate the rule group at. The rule groups depending on other
+// rule groups are evaluated at the timestamp of the latest evaluation of their dependencies, so that they
+// use the series recorded by it instead of the ones recorded an evaluation interval earlier. They're
+// evaluated at their own timestamp if their dependencies haven't been evaluated in the last interval.
+func (d *ruleGroupDependencies) evalTimestamp(g *promRules.Group, evalTimestamp time.Time) time.Time {
+	var latest time.Time
+	for _, dep := range d.dependenciesOf(ruleGroupRef{file: g.File(), name: g.Name()}) {
+		last := dep.GetLastEvalTimestamp()
+		if last.After(evalTimestamp) {
+			continue
+		}
+		if last.After(latest) {
+			latest = last
+		}
+	}
+
+	if !latest.After(evalTimestamp.Add(-g.Interval())) || !latest.After(g.GetLastEvalTimestamp()) {
+		return evalTimestamp
+	}
+	return latest
+}
+
+// iterationFunc returns an iteration function evaluating the rule groups after the rule groups they depend on.
+func (d *ruleGroupDependencies) iterationFunc(next promRules.GroupEvalIterationFunc) promRules.GroupEvalIterationFunc {
+	return func(ctx context.Context, g *promRules.Group, evalTimestamp time.Time) {
+		next(ctx, g, d.evalTimestamp(g, evalTimestamp))
+	}
+}
@@ -0,0 +1,160 @@
+package ruler
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex/pkg/cortexpb"
+	"github.com/cortexproject/cortex/pkg/ruler/rulespb"
+)
+
+func TestOrderRulesByDependencies(t *testing.T) {
+	record := func(name, expr string) rulefmt.RuleNode {
+		return rulefmt.RuleNode{Record: yaml.Node{Value: name}, Expr: yaml.Node{Value: expr}}
+	}
+	alert := func(name, expr string) rulefmt.RuleNode {
+		return rulefmt.RuleNode{Alert: yaml.Node{Value: name}, Expr: yaml.Node{Value: expr}}
+	}
+	names := func(rules []rulefmt.RuleNode) []string {
+		var result []string
+		for _, r := range rules {
+			result = append(result, r.Record.Value+r.Alert.Value)
+		}
+		return result
+	}
+
+	tests := map[string]struct {
+		rules    []rulefmt.RuleNode
+		expected []string
+	}{
+		"should keep the order of the independent rules": {
+			rules:    []rulefmt.RuleNode{record("b", "up"), record("a", "up"), alert("Down", "up == 0")},
+			expected: []string{"b", "a", "Down"},
+		},
+		"should evaluate the chained recording rules after their dependencies": {
+			rules:    []rulefmt.RuleNode{alert("High", "job:c > 1"), record("job:c", "sum(job:b)"), record("job:b", "rate(job:a[5m])"), record("job:a", "up")},
+			expected: []string{"job:a", "job:b", "job:c", "High"},
+		},
+		"should match the metric name in the label matchers": {
+			rules:    []rulefmt.RuleNode{record("b", `{__name__="a"}`), record("a", "up")},
+			expected: []string{"a", "b"},
+		},
+		"should keep the order of the rules depending on each other": {
+			rules:    []rulefmt.RuleNode{record("c", "b"), record("a", "b"), record("b", "a"), record("d", "up")},
+			expected: []string{"d", "c", "a", "b"},
+		},
+		"should keep the order of the rules if an expression can't be parsed": {
+			rules:    []rulefmt.RuleNode{record("b", "a"), record("a", "up{")},
+			expected: []string{"b", "a"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, names(orderRulesByDependencies(tc.rules)))
+		})
+	}
+}
+
+func TestRuleGroupDependenciesFor(t *testing.T) {
+	m := newMapper("/rules", log.NewNopLogger())
+	group := func(name string, rules ...*rulespb.RuleDesc) *rulespb.RuleGroupDesc {
+		return &rulespb.RuleGroupDesc{Name: name, Namespace: "ns", User: "user-1", Rules: rules}
+	}
+	ref := func(name string) ruleGroupRef {
+		return ruleGroupRef{file: m.ruleFile("user-1", "ns"), name: name}
+	}
+
+	groups := rulespb.RuleGroupList{
+		group("a", &rulespb.RuleDesc{Record: "a", Expr: "up"}),
+		group("b", &rulespb.RuleDesc{Record: "b", Expr: "a"}, &rulespb.RuleDesc{Alert: "B", Expr: "a > 0"}),
+		group("c", &rulespb.RuleDesc{Record: "c", Expr: "a + b"}),
+		// The rule groups depending on each other.
+		group("d", &rulespb.RuleDesc{Record: "d", Expr: "e"}),
+		group("e", &rulespb.RuleDesc{Record: "e", Expr: "d"}),
+		// The federated rule groups.
+		{Name: "f", Namespace: "ns", User: "user-1", SourceTenants: []string{"team-a"}, Rules: []*rulespb.RuleDesc{{Record: "f", Expr: "a"}}},
+		{Name: "g", Namespace: "ns", User: "user-1", DestinationTenant: "team-a", Rules: []*rulespb.RuleDesc{{Record: "g", Expr: "a"}}},
+		group("h", &rulespb.RuleDesc{Record: "h", Expr: "g"}),
+	}
+
+	assert.Equal(t, map[ruleGroupRef][]ruleGroupRef{
+		ref("b"): {ref("a")},
+		ref("c"): {ref("a"), ref("b")},
+		ref("g"): {ref("a")},
+	}, sortedRuleGroupDependencies(ruleGroupDependenciesFor(m, "user-1", groups)))
+}
+
+func sortedRuleGroupDependencies(dependencies map[ruleGroupRef][]ruleGroupRef) map[ruleGroupRef][]ruleGroupRef {
+	for _, refs := range dependencies {
+		sort.Slice(refs, func(i, j int) bool { return refs[i].name < refs[j].name })
+	}
+	return dependencies
+}
+
+func newDependencyTestGroup(t *testing.T, name, record, expr string) *promRules.Group {
+	e, err := parser.ParseExpr(expr)
+	require.NoError(t, err)
+
+	return promRules.NewGroup(promRules.GroupOptions{
+		Name:     name,
+		File:     "/rules/user-1/ns",
+		Interval: time.Minute,
+		Rules:    []promRules.Rule{promRules.NewRecordingRule(record, e, labels.EmptyLabels())},
+		Opts: &promRules.ManagerOptions{
+			QueryFunc: func(_ context.Context, _ string, ts time.Time) (promql.Vector, error) {
+				return promql.Vector{{T: ts.UnixMilli(), F: 1, Metric: labels.FromStrings("job", "api")}}, nil
+			},
+			Appendable: NewPusherAppendable(&fakePusher{response: &cortexpb.WriteResponse{}}, "user-1", ruleLimits{}, prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{})),
+			Context:    user.InjectOrgID(context.Background(), "user-1"),
+			Logger:     log.NewNopLogger(),
+		},
+	})
+}
+
+func TestRuleGroupDependencies_EvalTimestamp(t *testing.T) {
+	ctx := context.Background()
+	dependency := newDependencyTestGroup(t, "a", "a", "up")
+	dependent := newDependencyTestGroup(t, "b", "b", "a")
+
+	dependencies := &ruleGroupDependencies{}
+	dependencies.setDependencies(map[ruleGroupRef][]ruleGroupRef{
+		{file: "/rules/user-1/ns", name: "b"}: {{file: "/rules/user-1/ns", name: "a"}},
+	})
+	dependencies.setGroups([]*promRules.Group{dependency, dependent})
+
+	start := time.Unix(1000, 0)
+
+	// The dependency has never been evaluated.
+	assert.Equal(t, start, dependencies.evalTimestamp(dependent, start))
+
+	// The dependent rule group is evaluated at the timestamp of the latest evaluation of its dependency.
+	promRules.DefaultEvalIterationFunc(ctx, dependency, start.Add(-20*time.Second))
+	assert.Equal(t, start.Add(-20*time.Second), dependencies.evalTimestamp(dependent, start))
+
+	dependencies.iterationFunc(promRules.DefaultEvalIterationFunc)(ctx, dependent, start)
+	assert.Equal(t, start.Add(-20*time.Second), dependent.GetLastEvalTimestamp())
+
+	// The dependent rule group is not evaluated twice at the same timestamp.
+	assert.Equal(t, start.Add(10*time.Second), dependencies.evalTimestamp(dependent, start.Add(10*time.Second)))
+
+	promRules.DefaultEvalIterationFunc(ctx, dependency, start.Add(40*time.Second))
+	assert.Equal(t, start.Add(40*time.Second), dependencies.evalTimestamp(dependent, start.Add(time.Minute)))
+
+	// The dependent rule group is evaluated at its own timestamp if its dependency hasn't been evaluated in the last interval.
+	assert.Equal(t, start.Add(3*time.Minute), dependencies.evalTimestamp(dependent, start.Add(3*time.Minute)))
+}
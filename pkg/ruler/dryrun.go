@@ -0,0 +1,159 @@
+package ruler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	promRules "github.com/prometheus/prometheus/rules"
+	"gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+	util_api "github.com/cortexproject/cortex/pkg/util/api"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+)
+
+// DryRunRuleResult is the result of the evaluation of a rule of a dry-run rule group: the samples
+// of a recording rule, or the active alerts of an alerting rule.
+type DryRunRuleResult struct {
+	Name    string           `json:"name"`
+	Type    string           `json:"type"`
+	Samples promql.Vector    `json:"samples,omitempty"`
+	Alerts  []*BacktestAlert `json:"alerts,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// DryRunResult is the result of a dry-run evaluation of a rule group.
+type DryRunResult struct {
+	Timestamp time.Time           `json:"timestamp"`
+	Rules     []*DryRunRuleResult `json:"rules"`
+}
+
+// DryRunRuleGroup evaluates the rule group in the request body, in the same YAML format used to
+// create rule groups, once at the "time" parameter, or now if not provided. It returns the samples
+// of the recording rules and the alerts of the alerting rules, without writing the samples or
+// sending the alerts.
+func (b *Backtester) DryRunRuleGroup(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), b.logger)
+	userID, err := tenant.TenantID(req.Context())
+	if err != nil || userID == "" {
+		level.Error(logger).Log("msg", "error extracting org id from context", "err", err)
+		util_api.RespondError(logger, w, v1.ErrBadData, "no valid org id found", http.StatusBadRequest)
+		return
+	}
+
+	rg, ts, err := parseDryRunRequest(req)
+	if err != nil {
+		util_api.RespondError(logger, w, v1.ErrBadData, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result := dryRunRuleGroup(req.Context(), rg, ts, promRules.EngineQueryFunc(b.engine, b.queryable), logger)
+
+	data, err := json.Marshal(&util_api.Response{
+		Status: "success",
+		Data:   result,
+	})
+	if err != nil {
+		level.Error(logger).Log("msg", "error marshaling json response", "err", err)
+		util_api.RespondError(logger, w, v1.ErrServer, "unable to marshal the requested data", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if n, err := w.Write(data); err != nil {
+		level.Error(logger).Log("msg", "error writing response", "bytesWritten", n, "err", err)
+	}
+}
+
+func parseDryRunRequest(req *http.Request) (rulefmt.RuleGroup, time.Time, error) {
+	rg := rulefmt.RuleGroup{}
+
+	payload, err := io.ReadAll(req.Body)
+	if err != nil {
+		return rg, time.Time{}, err
+	}
+	if err := yaml.Unmarshal(payload, &rg); err != nil {
+		return rg, time.Time{}, ErrBadRuleGroup
+	}
+	if rg.Name == "" {
+		return rg, time.Time{}, errors.New("rule group name must not be empty")
+	}
+	if len(rg.Rules) == 0 {
+		return rg, time.Time{}, errors.New("rule group must contain at least one rule")
+	}
+	for _, rule := range rg.Rules {
+		if errs := rule.Validate(); len(errs) > 0 {
+			return rg, time.Time{}, &errs[0]
+		}
+	}
+
+	ts := time.Now()
+	if t := req.URL.Query().Get("time"); t != "" {
+		ms, err := util.ParseTime(t)
+		if err != nil {
+			return rg, time.Time{}, err
+		}
+		ts = util.TimeFromMillis(ms)
+	}
+	return rg, ts, nil
+}
+
+// dryRunRuleGroup evaluates each rule of the rule group once at the timestamp. Given the samples of
+// the recording rules are not written, the following rules of the rule group can't query them.
+func dryRunRuleGroup(ctx context.Context, rg rulefmt.RuleGroup, ts time.Time, query promRules.QueryFunc, logger log.Logger) *DryRunResult {
+	result := &DryRunResult{Timestamp: ts, Rules: make([]*DryRunRuleResult, 0, len(rg.Rules))}
+
+	for _, rule := range rg.Rules {
+		ruleResult := &DryRunRuleResult{Name: rule.Record.Value, Type: "recording"}
+		if rule.Alert.Value != "" {
+			ruleResult = &DryRunRuleResult{Name: rule.Alert.Value, Type: "alerting"}
+		}
+		result.Rules = append(result.Rules, ruleResult)
+
+		expr, err := parser.ParseExpr(rule.Expr.Value)
+		if err != nil {
+			ruleResult.Error = err.Error()
+			continue
+		}
+
+		if rule.Record.Value != "" {
+			recording := promRules.NewRecordingRule(rule.Record.Value, expr, labels.FromMap(rule.Labels))
+			if ruleResult.Samples, err = recording.Eval(ctx, ts, query, nil, rg.Limit); err != nil {
+				ruleResult.Error = err.Error()
+			}
+			continue
+		}
+
+		alerting := promRules.NewAlertingRule(
+			rule.Alert.Value, expr, time.Duration(rule.For), time.Duration(rule.KeepFiringFor),
+			labels.FromMap(rule.Labels), labels.FromMap(rule.Annotations), labels.EmptyLabels(), "", true, logger,
+		)
+		if _, err := alerting.Eval(ctx, ts, query, nil, rg.Limit); err != nil {
+			ruleResult.Error = err.Error()
+			continue
+		}
+
+		ruleResult.Alerts = []*BacktestAlert{}
+		alerting.ForEachActiveAlert(func(a *promRules.Alert) {
+			alert := &BacktestAlert{Labels: a.Labels, Annotations: a.Annotations, State: a.State.String(), ActiveAt: a.ActiveAt}
+			if !a.FiredAt.IsZero() {
+				firedAt := a.FiredAt
+				alert.FiredAt = &firedAt
+			}
+			ruleResult.Alerts = append(ruleResult.Alerts, alert)
+		})
+	}
+	return result
+}
@@ -0,0 +1,107 @@
+package ruler
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRunRuleGroup(t *testing.T) {
+	ts := time.Unix(600, 0)
+	series := labels.FromStrings(labels.MetricName, "errors", "job", "api")
+
+	query := func(_ context.Context, q string, ts time.Time) (promql.Vector, error) {
+		if q == "broken" {
+			return nil, errors.New("query failed")
+		}
+		return promql.Vector{{T: ts.UnixMilli(), F: 3, Metric: series}}, nil
+	}
+
+	req := httptest.NewRequest("POST", "/?time=600", strings.NewReader(`
+name: group
+rules:
+  - record: job:errors
+    expr: errors
+  - alert: HighErrors
+    expr: errors > 0
+    labels:
+      severity: page
+  - record: job:broken
+    expr: broken
+`))
+	rg, reqTime, err := parseDryRunRequest(req)
+	require.NoError(t, err)
+	assert.Equal(t, ts, reqTime)
+
+	result := dryRunRuleGroup(context.Background(), rg, reqTime, query, log.NewNopLogger())
+	assert.Equal(t, ts, result.Timestamp)
+	require.Len(t, result.Rules, 3)
+
+	recording := result.Rules[0]
+	assert.Equal(t, "job:errors", recording.Name)
+	assert.Equal(t, "recording", recording.Type)
+	assert.Equal(t, promql.Vector{{T: 600000, F: 3, Metric: labels.FromStrings(labels.MetricName, "job:errors", "job", "api")}}, recording.Samples)
+	assert.Empty(t, recording.Error)
+
+	alerting := result.Rules[1]
+	assert.Equal(t, "HighErrors", alerting.Name)
+	assert.Equal(t, "alerting", alerting.Type)
+	require.Len(t, alerting.Alerts, 1)
+	assert.Equal(t, labels.FromStrings(labels.AlertName, "HighErrors", "job", "api", "severity", "page"), alerting.Alerts[0].Labels)
+	assert.Equal(t, "firing", alerting.Alerts[0].State)
+	assert.Equal(t, ts, alerting.Alerts[0].ActiveAt)
+
+	broken := result.Rules[2]
+	assert.Equal(t, "job:broken", broken.Name)
+	assert.Empty(t, broken.Samples)
+	assert.Contains(t, broken.Error, "query failed")
+}
+
+func TestParseDryRunRequest_ShouldRejectInvalidRequests(t *testing.T) {
+	for name, tc := range map[string]struct {
+		url         string
+		body        string
+		expectedErr string
+	}{
+		"invalid yaml": {
+			url:         "/",
+			body:        "name: [",
+			expectedErr: ErrBadRuleGroup.Error(),
+		},
+		"missing name": {
+			url:         "/",
+			body:        "rules:\n  - record: r\n    expr: up\n",
+			expectedErr: "rule group name must not be empty",
+		},
+		"no rules": {
+			url:         "/",
+			body:        "name: group\n",
+			expectedErr: "rule group must contain at least one rule",
+		},
+		"invalid rule": {
+			url:         "/",
+			body:        "name: group\nrules:\n  - expr: up\n",
+			expectedErr: "one of 'record' or 'alert' must be set",
+		},
+		"invalid time": {
+			url:         "/?time=yesterday",
+			body:        "name: group\nrules:\n  - record: r\n    expr: up\n",
+			expectedErr: "cannot parse \"yesterday\" to a valid timestamp",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := parseDryRunRequest(httptest.NewRequest("POST", tc.url, strings.NewReader(tc.body)))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,353 @@
+package ruler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/thanos-io/objstore"
+
+	"github.com/cortexproject/cortex/pkg/ruler/rulespb"
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	"github.com/cortexproject/cortex/pkg/util/concurrency"
+)
+
+const (
+	// The bucket prefix under which the last evaluation of the rule groups is stored.
+	lastEvaluationPrefix = "ruler-last-evaluations"
+
+	// How many last evaluations to load or persist concurrently.
+	lastEvaluationConcurrency = 10
+
+	lastEvaluationPersistTimeout = 30 * time.Second
+)
+
+// lastEvaluation is the last successful evaluation of a rule group.
+type lastEvaluation struct {
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// LastEvaluationStore stores the last successful evaluation of the rule groups in object storage.
+type LastEvaluationStore struct {
+	bucket      objstore.Bucket
+	cfgProvider bucket.TenantConfigProvider
+	logger      log.Logger
+}
+
+func newLastEvaluationStore(bkt objstore.Bucket, cfgProvider bucket.TenantConfigProvider, logger log.Logger) *LastEvaluationStore {
+	return &LastEvaluationStore{
+		bucket:      bucket.NewPrefixedBucketClient(bkt, lastEvaluationPrefix),
+		cfgProvider: cfgProvider,
+		logger:      logger,
+	}
+}
+
+// getLastEvaluation returns the timestamp of the last evaluation of the rule group, or the zero time if
+// the rule group has no last evaluation.
+func (s *LastEvaluationStore) getLastEvaluation(ctx context.Context, userID, namespace, group string) (time.Time, error) {
+	userBucket := bucket.NewUserBucketClient(userID, s.bucket, s.cfgProvider)
+	objectKey := getAlertStateObjectKey(namespace, group)
+
+	reader, err := userBucket.Get(ctx, objectKey)
+	if userBucket.IsObjNotFoundErr(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "failed to get last evaluation %s", objectKey)
+	}
+	defer func() { _ = reader.Close() }()
+
+	buf, err := io.ReadAll(reader)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "failed to read last evaluation %s", objectKey)
+	}
+
+	evaluation := lastEvaluation{}
+	if err := json.Unmarshal(buf, &evaluation); err != nil {
+		return time.Time{}, errors.Wrapf(err, "failed to unmarshal last evaluation %s", objectKey)
+	}
+	return evaluation.Timestamp, nil
+}
+
+func (s *LastEvaluationStore) setLastEvaluation(ctx context.Context, userID, namespace, group string, ts time.Time) error {
+	data, err := json.Marshal(lastEvaluation{Timestamp: ts})
+	if err != nil {
+		return err
+	}
+
+	userBucket := bucket.NewUserBucketClient(userID, s.bucket, s.cfgProvider)
+	return userBucket.Upload(ctx, getAlertStateObjectKey(namespace, group), bytes.NewReader(data))
+}
+
+// evaluationCatchUp tracks the last successful evaluation of the rule groups, persisting it to the ruler
+// storage, and catches up the evaluations of the recording rules missed since then when the rule groups
+// are loaded.
+type evaluationCatchUp struct {
+	store       *LastEvaluationStore
+	maxLookback time.Duration
+	logger      log.Logger
+
+	mtx sync.Mutex
+	// The last evaluation of the rule groups added to a rules manager, whose missed evaluations are
+	// caught up before their first evaluation.
+	pending map[string]map[ruleGroupRef]time.Time
+	// The last successful evaluation of the rule groups, and the last one persisted.
+	evaluated map[string]map[ruleGroupRef]time.Time
+	persisted map[string]map[ruleGroupRef]time.Time
+
+	catchUpTotal  prometheus.Counter
+	catchUpFailed prometheus.Counter
+	persistTotal  prometheus.Counter
+	persistFailed prometheus.Counter
+}
+
+func newEvaluationCatchUp(store *LastEvaluationStore, maxLookback time.Duration, logger log.Logger, reg prometheus.Registerer) *evaluationCatchUp {
+	return &evaluationCatchUp{
+		store:       store,
+		maxLookback: maxLookback,
+		logger:      logger,
+		pending:     map[string]map[ruleGroupRef]time.Time{},
+		evaluated:   map[string]map[ruleGroupRef]time.Time{},
+		persisted:   map[string]map[ruleGroupRef]time.Time{},
+		catchUpTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Namespace: "cortex",
+			Name:      "ruler_catch_up_evaluations_total",
+			Help:      "Total number of missed rule group evaluations caught up.",
+		}),
+		catchUpFailed: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Namespace: "cortex",
+			Name:      "ruler_catch_up_evaluations_failed_total",
+			Help:      "Total number of missed rule group evaluations failed to be caught up.",
+		}),
+		persistTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Namespace: "cortex",
+			Name:      "ruler_last_evaluation_persist_total",
+			Help:      "Total number of rule group last evaluations persisted to the ruler storage.",
+		}),
+		persistFailed: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Namespace: "cortex",
+			Name:      "ruler_last_evaluation_persist_failed_total",
+			Help:      "Total number of rule group last evaluations failed to be persisted to the ruler storage.",
+		}),
+	}
+}
+
+// load loads the last evaluation of the added rule groups, to catch up their missed evaluations, and drops
+// the tracked evaluations of the rule groups not loaded anymore. The rule groups whose last evaluation
+// can't be loaded are not caught up.
+func (c *evaluationCatchUp) load(ctx context.Context, userID string, added map[ruleGroupRef]*rulespb.RuleGroupDesc, groups map[ruleGroupRef]struct{}) {
+	jobs := make([]interface{}, 0, len(added))
+	for ref := range added {
+		jobs = append(jobs, ref)
+	}
+
+	var (
+		mtx    sync.Mutex
+		loaded = map[ruleGroupRef]time.Time{}
+	)
+	_ = concurrency.ForEach(ctx, jobs, lastEvaluationConcurrency, func(ctx context.Context, job interface{}) error {
+		ref := job.(ruleGroupRef)
+		g := added[ref]
+
+		ts, err := c.store.getLastEvaluation(ctx, userID, g.Namespace, g.Name)
+		if err != nil {
+			level.Warn(c.logger).Log("msg", "failed to load last evaluation", "user", userID, "namespace", g.Namespace, "group", g.Name, "err", err)
+			return nil
+		}
+		if !ts.IsZero() {
+			mtx.Lock()
+			loaded[ref] = ts
+			mtx.Unlock()
+		}
+		return nil
+	})
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, tracked := range []map[string]map[ruleGroupRef]time.Time{c.pending, c.evaluated, c.persisted} {
+		for ref := range tracked[userID] {
+			if _, ok := groups[ref]; !ok {
+				delete(tracked[userID], ref)
+			}
+		}
+	}
+	if len(loaded) > 0 && c.pending[userID] == nil {
+		c.pending[userID] = map[ruleGroupRef]time.Time{}
+	}
+	for ref, ts := range loaded {
+		c.pending[userID][ref] = ts
+	}
+}
+
+func (c *evaluationCatchUp) popPending(userID string, ref ruleGroupRef) (time.Time, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	ts, ok := c.pending[userID][ref]
+	if ok {
+		delete(c.pending[userID], ref)
+	}
+	return ts, ok
+}
+
+func (c *evaluationCatchUp) setEvaluated(userID string, ref ruleGroupRef, ts time.Time) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.evaluated[userID] == nil {
+		c.evaluated[userID] = map[ruleGroupRef]time.Time{}
+	}
+	c.evaluated[userID][ref] = ts
+}
+
+// missedEvaluations returns the timestamps of the evaluations of a rule group missed between its last
+// evaluation and the next one, no older than the max lookback.
+func (c *evaluationCatchUp) missedEvaluations(last, next time.Time, interval time.Duration) []time.Time {
+	if interval <= 0 {
+		return nil
+	}
+
+	start := last.Add(interval)
+	if minTs := next.Add(-c.maxLookback); start.Before(minTs) {
+		skipped := (minTs.Sub(start) + interval - 1) / interval
+		start = start.Add(skipped * interval)
+	}
+
+	var missed []time.Time
+	for ts := start; ts.Before(next); ts = ts.Add(interval) {
+		missed = append(missed, ts)
+	}
+	return missed
+}
+
+// iterationFunc returns an iteration function catching up the missed evaluations of the recording rules
+// of the rule groups pending catch-up, before their first evaluation, and tracking the last successful
+// evaluation of the rule groups.
+func (c *evaluationCatchUp) iterationFunc(userID string, manager RulesManager, next promRules.GroupEvalIterationFunc) promRules.GroupEvalIterationFunc {
+	evaluator, _ := manager.(recordingRulesEvaluator)
+
+	return func(ctx context.Context, g *promRules.Group, evalTimestamp time.Time) {
+		ref := ruleGroupRef{file: g.File(), name: g.Name()}
+		if last, ok := c.popPending(userID, ref); ok && evaluator != nil {
+			// The missed evaluations are caught up in order, before the next one, so that their samples are not out of order.
+			for _, ts := range c.missedEvaluations(last, evalTimestamp, g.Interval()) {
+				c.catchUpTotal.Inc()
+				if err := evaluator.EvalRecordingRules(ctx, g, ts); err != nil {
+					c.catchUpFailed.Inc()
+					level.Warn(c.logger).Log("msg", "failed to catch up missed rule group evaluation", "user", userID, "file", g.File(), "group", g.Name(), "timestamp", ts, "err", err)
+				}
+			}
+		}
+
+		next(ctx, g, evalTimestamp)
+
+		// The evaluation is only successful if all the recording rules succeeded, otherwise the next
+		// catch-up starts from the previous successful evaluation.
+		for _, rule := range g.Rules() {
+			if _, ok := rule.(*promRules.RecordingRule); ok && rule.Health() == promRules.HealthBad {
+				return
+			}
+		}
+		c.setEvaluated(userID, ref, evalTimestamp)
+	}
+}
+
+// persist persists the last successful evaluation of the rule groups, if it changed since last persisted.
+func (c *evaluationCatchUp) persist(ctx context.Context, userID string) {
+	c.mtx.Lock()
+	changed := map[ruleGroupRef]time.Time{}
+	for ref, ts := range c.evaluated[userID] {
+		if !c.persisted[userID][ref].Equal(ts) {
+			changed[ref] = ts
+		}
+	}
+	c.mtx.Unlock()
+
+	for ref, ts := range changed {
+		c.persistTotal.Inc()
+		if err := c.store.setLastEvaluation(ctx, userID, namespaceFromRuleFile(ref.file), ref.name, ts); err != nil {
+			c.persistFailed.Inc()
+			level.Warn(c.logger).Log("msg", "failed to persist last evaluation", "user", userID, "file", ref.file, "group", ref.name, "err", err)
+			continue
+		}
+
+		c.mtx.Lock()
+		if c.persisted[userID] == nil {
+			c.persisted[userID] = map[ruleGroupRef]time.Time{}
+		}
+		c.persisted[userID][ref] = ts
+		c.mtx.Unlock()
+	}
+}
+
+func (c *evaluationCatchUp) removeUser(userID string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	delete(c.pending, userID)
+	delete(c.evaluated, userID)
+	delete(c.persisted, userID)
+}
+
+// users returns the users whose last evaluations are tracked.
+func (c *evaluationCatchUp) users() []string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	users := make([]string, 0, len(c.evaluated))
+	for userID := range c.evaluated {
+		users = append(users, userID)
+	}
+	return users
+}
+
+// prepareEvaluationCatchUp persists the last evaluation of the rule groups loaded by the rules manager, which
+// may be about to be removed, and loads the last evaluation of the rule groups about to be added. It returns
+// the iteration function catching up the missed evaluations of the added rule groups.
+func (r *DefaultMultiTenantManager) prepareEvaluationCatchUp(ctx context.Context, userID string, manager RulesManager, existing bool, groups rulespb.RuleGroupList, next promRules.GroupEvalIterationFunc) promRules.GroupEvalIterationFunc {
+	loaded := map[ruleGroupRef]struct{}{}
+	if existing {
+		r.evaluationCatchUp.persist(ctx, userID)
+		for _, g := range manager.RuleGroups() {
+			loaded[ruleGroupRef{file: g.File(), name: g.Name()}] = struct{}{}
+		}
+	}
+
+	all := make(map[ruleGroupRef]struct{}, len(groups))
+	added := map[ruleGroupRef]*rulespb.RuleGroupDesc{}
+	for _, g := range groups {
+		ref := ruleGroupRef{file: r.mapper.ruleFile(userID, g.Namespace), name: g.Name}
+		all[ref] = struct{}{}
+		if _, ok := loaded[ref]; !ok {
+			added[ref] = g
+		}
+	}
+
+	r.evaluationCatchUp.load(ctx, userID, added, all)
+	return r.evaluationCatchUp.iterationFunc(userID, manager, next)
+}
+
+// PersistLastEvaluations persists the last successful evaluation of the rule groups of all the users to the
+// ruler storage.
+func (r *DefaultMultiTenantManager) PersistLastEvaluations(ctx context.Context) {
+	if r.evaluationCatchUp == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, lastEvaluationPersistTimeout)
+	defer cancel()
+
+	_ = concurrency.ForEachUser(ctx, r.evaluationCatchUp.users(), lastEvaluationConcurrency, func(ctx context.Context, userID string) error {
+		r.evaluationCatchUp.persist(ctx, userID)
+		return nil
+	})
+}
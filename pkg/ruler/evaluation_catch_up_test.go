@@ -0,0 +1,138 @@
+package ruler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/cortexproject/cortex/pkg/cortexpb"
+	"github.com/cortexproject/cortex/pkg/ruler/rulespb"
+)
+
+type catchUpTestPusher struct {
+	mtx        sync.Mutex
+	timestamps []int64
+}
+
+func (p *catchUpTestPusher) Push(_ context.Context, r *cortexpb.WriteRequest) (*cortexpb.WriteResponse, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for _, ts := range r.Timeseries {
+		for _, s := range ts.Samples {
+			p.timestamps = append(p.timestamps, s.TimestampMs)
+		}
+	}
+	return &cortexpb.WriteResponse{}, nil
+}
+
+func TestEvaluationCatchUp_MissedEvaluations(t *testing.T) {
+	catchUp := newEvaluationCatchUp(nil, 10*time.Minute, log.NewNopLogger(), nil)
+	last := time.Unix(6000, 0)
+
+	tests := map[string]struct {
+		next     time.Time
+		expected []time.Time
+	}{
+		"no missed evaluation": {
+			next: last.Add(time.Minute),
+		},
+		"missed evaluations": {
+			next:     last.Add(3*time.Minute + 10*time.Second),
+			expected: []time.Time{last.Add(time.Minute), last.Add(2 * time.Minute), last.Add(3 * time.Minute)},
+		},
+		"missed evaluations older than the max lookback": {
+			next:     last.Add(time.Hour),
+			expected: []time.Time{last.Add(50 * time.Minute), last.Add(51 * time.Minute), last.Add(52 * time.Minute), last.Add(53 * time.Minute), last.Add(54 * time.Minute), last.Add(55 * time.Minute), last.Add(56 * time.Minute), last.Add(57 * time.Minute), last.Add(58 * time.Minute), last.Add(59 * time.Minute)},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, catchUp.missedEvaluations(last, tc.next, time.Minute))
+		})
+	}
+}
+
+func TestEvaluationCatchUp(t *testing.T) {
+	ctx := context.Background()
+	store := newLastEvaluationStore(objstore.NewInMemBucket(), nil, log.NewNopLogger())
+	catchUp := newEvaluationCatchUp(store, time.Hour, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+
+	ref := ruleGroupRef{file: "/rules/user-1/ns", name: "group"}
+	last := time.Unix(6000, 0)
+
+	// The last evaluation is only persisted when it changes.
+	catchUp.setEvaluated("user-1", ref, last)
+	catchUp.persist(ctx, "user-1")
+	catchUp.persist(ctx, "user-1")
+	assert.Equal(t, 1, int(testutil.ToFloat64(catchUp.persistTotal)))
+	assert.Equal(t, 0, int(testutil.ToFloat64(catchUp.persistFailed)))
+
+	// The rule group is loaded by another ruler.
+	catchUp = newEvaluationCatchUp(store, time.Hour, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	catchUp.load(ctx, "user-1", map[ruleGroupRef]*rulespb.RuleGroupDesc{
+		ref: {Namespace: "ns", Name: "group"},
+		{file: "/rules/user-1/ns", name: "other"}: {Namespace: "ns", Name: "other"},
+	}, map[ruleGroupRef]struct{}{ref: {}, {file: "/rules/user-1/ns", name: "other"}: {}})
+
+	expr, err := parser.ParseExpr("sum(up)")
+	require.NoError(t, err)
+	queryFunc := func(_ context.Context, _ string, ts time.Time) (promql.Vector, error) {
+		return promql.Vector{{T: ts.UnixMilli(), F: 1, Metric: labels.FromStrings("job", "api")}}, nil
+	}
+	pusher := &catchUpTestPusher{}
+	manager := &tenantRulesManager{
+		queryFunc:  queryFunc,
+		appendable: NewPusherAppendable(pusher, "user-1", ruleLimits{}, prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{})),
+	}
+	group := promRules.NewGroup(promRules.GroupOptions{
+		Name:     "group",
+		File:     "/rules/user-1/ns",
+		Interval: time.Minute,
+		Rules: []promRules.Rule{
+			promRules.NewRecordingRule("job:up:sum", expr, labels.EmptyLabels()),
+			promRules.NewAlertingRule("Down", expr, 0, 0, labels.EmptyLabels(), labels.EmptyLabels(), labels.EmptyLabels(), "", true, log.NewNopLogger()),
+		},
+		Opts: &promRules.ManagerOptions{QueryFunc: queryFunc},
+	})
+
+	var evaluated []time.Time
+	iterationFunc := catchUp.iterationFunc("user-1", manager, func(_ context.Context, _ *promRules.Group, ts time.Time) {
+		evaluated = append(evaluated, ts)
+	})
+
+	// The missed evaluations of the recording rules are caught up before the first evaluation.
+	next := last.Add(4 * time.Minute)
+	iterationFunc(ctx, group, next)
+	assert.Equal(t, []int64{last.Add(time.Minute).UnixMilli(), last.Add(2 * time.Minute).UnixMilli(), last.Add(3 * time.Minute).UnixMilli()}, pusher.timestamps)
+	assert.Equal(t, 3, int(testutil.ToFloat64(catchUp.catchUpTotal)))
+	assert.Equal(t, 0, int(testutil.ToFloat64(catchUp.catchUpFailed)))
+
+	// The next evaluations are not caught up.
+	iterationFunc(ctx, group, next.Add(5*time.Minute))
+	assert.Len(t, pusher.timestamps, 3)
+	assert.Equal(t, []time.Time{next, next.Add(5 * time.Minute)}, evaluated)
+
+	// The last successful evaluation is persisted.
+	catchUp.persist(ctx, "user-1")
+	ts, err := store.getLastEvaluation(ctx, "user-1", "ns", "group")
+	require.NoError(t, err)
+	assert.True(t, next.Add(5*time.Minute).Equal(ts))
+
+	ts, err = store.getLastEvaluation(ctx, "user-1", "ns", "other")
+	require.NoError(t, err)
+	assert.True(t, ts.IsZero())
+}
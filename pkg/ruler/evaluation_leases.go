@@ -0,0 +1,115 @@
+package ruler
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	promRules "github.com/prometheus/prometheus/rules"
+
+	"github.com/cortexproject/cortex/pkg/ha"
+	"github.com/cortexproject/cortex/pkg/ring/kv"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+// EvaluationLeasesConfig configures the leases of the rule groups evaluations, which are tracked
+// with the HA tracker: the rule groups are the replica groups and the rulers their replicas.
+type EvaluationLeasesConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// The lease of a rule group is only renewed if it has been held for more than this duration.
+	RenewInterval          time.Duration `yaml:"renew_interval"`
+	RenewIntervalJitterMax time.Duration `yaml:"renew_interval_jitter_max"`
+	// Another ruler can only take over the lease of a rule group if it hasn't been renewed for more than this duration.
+	Timeout time.Duration `yaml:"timeout"`
+
+	KVStore kv.Config `yaml:"kvstore" doc:"description=Backend storage to use for the leases. Please be aware that memberlist is not supported since gossip propagation is too slow for the leases."`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet.
+func (cfg *EvaluationLeasesConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "ruler.evaluation-leases.enabled", false, "[Experimental] Enable the leases of the rule groups evaluations, so that a rule group is only evaluated by the ruler holding its lease, even while the rule groups are handed over between the rulers.")
+	f.DurationVar(&cfg.RenewInterval, "ruler.evaluation-leases.renew-interval", time.Minute, "Renew the lease of a rule group in the KV store, when it's evaluated, only after this amount of time has passed since it was last renewed.")
+	f.DurationVar(&cfg.RenewIntervalJitterMax, "ruler.evaluation-leases.renew-interval-jitter-max", 5*time.Second, "Maximum jitter applied to the renew interval, in order to spread the renewals over time.")
+	f.DurationVar(&cfg.Timeout, "ruler.evaluation-leases.timeout", 3*time.Minute, "If the lease of a rule group hasn't been renewed in this amount of time, the next ruler evaluating the rule group takes it over. This value must be greater than the renew interval, and than the evaluation interval of the rule groups.")
+
+	// The leases can use a different KV store than the ring, and use a different prefix
+	// so that they don't clash with the ring key if they share the same KV store.
+	cfg.KVStore.RegisterFlagsWithPrefix("ruler.evaluation-leases.", "ruler-leases/", f)
+}
+
+// Validate config and returns error on failure.
+func (cfg *EvaluationLeasesConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	haCfg := cfg.toHATrackerConfig()
+	return haCfg.Validate()
+}
+
+func (cfg *EvaluationLeasesConfig) toHATrackerConfig() ha.HATrackerConfig {
+	haCfg := ha.HATrackerConfig{}
+	flagext.DefaultValues(&haCfg)
+
+	haCfg.EnableHATracker = cfg.Enabled
+	haCfg.UpdateTimeout = cfg.RenewInterval
+	haCfg.UpdateTimeoutJitterMax = cfg.RenewIntervalJitterMax
+	haCfg.FailoverTimeout = cfg.Timeout
+	haCfg.KVStore = cfg.KVStore
+
+	return haCfg
+}
+
+// evaluationLeases grants the leases of the rule groups evaluations to the ruler.
+type evaluationLeases struct {
+	tracker *ha.HATracker
+	// The ID of the ruler in the ring.
+	instanceID string
+	logger     log.Logger
+}
+
+func newEvaluationLeases(cfg Config, reg prometheus.Registerer, logger log.Logger) (*evaluationLeases, error) {
+	tracker, err := ha.NewHATracker(cfg.EvaluationLeases.toHATrackerConfig(), nil, ha.HATrackerStatusConfig{
+		Title:             "Cortex Ruler Evaluation Leases",
+		ReplicaGroupLabel: "Rule group",
+	}, prometheus.WrapRegistererWithPrefix("cortex_ruler_", reg), "ruler-evaluation-leases", logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &evaluationLeases{tracker: tracker, instanceID: cfg.Ring.InstanceID, logger: logger}, nil
+}
+
+// acquire returns whether the ruler holds the lease of the rule group, acquiring or renewing it if needed.
+// The rule group is evaluated if the lease can't be checked, not to miss its evaluations if the KV store
+// is unavailable.
+func (l *evaluationLeases) acquire(ctx context.Context, userID string, g *promRules.Group, now time.Time) bool {
+	// The leases are keyed by the rule file, which includes the escaped namespace, and the rule group name.
+	group := filepath.Base(g.File()) + "/" + url.PathEscape(g.Name())
+
+	err := l.tracker.CheckReplica(ctx, userID, group, l.instanceID, now)
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, ha.ReplicasNotMatchError{}) {
+		level.Debug(l.logger).Log("msg", "skipping the evaluation of the rule group, the lease is held by another ruler", "user", userID, "group", group, "err", err)
+		return false
+	}
+	level.Warn(l.logger).Log("msg", "unable to check the lease of the rule group, evaluating it", "user", userID, "group", group, "err", err)
+	return true
+}
+
+// iterationFunc returns an iteration function only evaluating the rule groups whose lease is held by the ruler.
+func (l *evaluationLeases) iterationFunc(userID string, next promRules.GroupEvalIterationFunc) promRules.GroupEvalIterationFunc {
+	return func(ctx context.Context, g *promRules.Group, evalTimestamp time.Time) {
+		if !l.acquire(ctx, userID, g, time.Now()) {
+			return
+		}
+		next(ctx, g, evalTimestamp)
+	}
+}
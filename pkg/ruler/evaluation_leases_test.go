@@ -0,0 +1,79 @@
+package ruler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/ha"
+	"github.com/cortexproject/cortex/pkg/ring/kv"
+	"github.com/cortexproject/cortex/pkg/ring/kv/consul"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+func TestEvaluationLeasesConfig_Validate(t *testing.T) {
+	cfg := EvaluationLeasesConfig{RenewInterval: time.Minute, RenewIntervalJitterMax: 5 * time.Second, Timeout: 3 * time.Minute, KVStore: kv.Config{Store: "memberlist"}}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Enabled = true
+	assert.EqualError(t, cfg.Validate(), "invalid HATracker KV store type: memberlist")
+
+	cfg.KVStore.Store = "consul"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Timeout = time.Minute
+	assert.Error(t, cfg.Validate())
+}
+
+func TestEvaluationLeases(t *testing.T) {
+	kvStore, closer := consul.NewInMemoryClient(ha.GetReplicaDescCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	newLeases := func(instanceID string) *evaluationLeases {
+		cfg := Config{}
+		cfg.Ring.InstanceID = instanceID
+		cfg.EvaluationLeases = EvaluationLeasesConfig{
+			Enabled:       true,
+			RenewInterval: time.Minute,
+			Timeout:       3 * time.Minute,
+			KVStore:       kv.Config{Mock: kvStore},
+		}
+
+		leases, err := newEvaluationLeases(cfg, nil, log.NewNopLogger())
+		require.NoError(t, err)
+		require.NoError(t, services.StartAndAwaitRunning(context.Background(), leases.tracker))
+		t.Cleanup(func() {
+			assert.NoError(t, services.StopAndAwaitTerminated(context.Background(), leases.tracker))
+		})
+		return leases
+	}
+
+	ctx := context.Background()
+	first := newLeases("ruler-1")
+	second := newLeases("ruler-2")
+	group := newDependencyTestGroup(t, "a", "a", "up")
+	other := newDependencyTestGroup(t, "b", "b", "up")
+	now := time.Now()
+
+	// The first ruler evaluating a rule group acquires its lease.
+	assert.True(t, first.acquire(ctx, "user-1", group, now))
+	assert.False(t, second.acquire(ctx, "user-1", group, now.Add(time.Second)))
+	assert.True(t, second.acquire(ctx, "user-1", other, now.Add(time.Second)))
+	assert.True(t, first.acquire(ctx, "user-1", group, now.Add(2*time.Minute)))
+
+	// The rule groups are only evaluated by the ruler holding their lease.
+	var evaluated []string
+	iterationFunc := func(_ context.Context, g *promRules.Group, _ time.Time) { evaluated = append(evaluated, g.Name()) }
+	second.iterationFunc("user-1", iterationFunc)(ctx, group, now)
+	second.iterationFunc("user-1", iterationFunc)(ctx, other, now)
+	assert.Equal(t, []string{"b"}, evaluated)
+
+	// The lease is taken over once it hasn't been renewed for the timeout.
+	assert.True(t, second.acquire(ctx, "user-1", group, now.Add(6*time.Minute)))
+	assert.False(t, first.acquire(ctx, "user-1", group, now.Add(6*time.Minute+time.Second)))
+}
@@ -0,0 +1,58 @@
+package ruler
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+
+	"github.com/cortexproject/cortex/pkg/cortexpb"
+	"github.com/cortexproject/cortex/pkg/ruler/rulespb"
+)
+
+const (
+	errInvalidExternalLabel  = "invalid external label %q=%q of the rule group"
+	errReservedExternalLabel = "the external label %q of the rule group is reserved"
+)
+
+// checkExternalLabels returns an error if an external label of a rule group is invalid, or reserved: the
+// labels starting with "__", the alert name label and the external labels of the ruler.
+func checkExternalLabels(externalLabels map[string]string, rulerExternalLabels labels.Labels) error {
+	for name, value := range externalLabels {
+		if !model.LabelName(name).IsValid() || !utf8.ValidString(value) {
+			return fmt.Errorf(errInvalidExternalLabel, name, value)
+		}
+		if strings.HasPrefix(name, model.ReservedLabelPrefix) || name == labels.AlertName || rulerExternalLabels.Has(name) {
+			return fmt.Errorf(errReservedExternalLabel, name)
+		}
+	}
+	return nil
+}
+
+// formattedWithExternalLabels returns the rule groups as formatted rule groups mapped by namespace, like
+// rulespb.RuleGroupList.Formatted, adding the external labels of each rule group to the labels of its
+// rules. The labels of the rules take precedence over the external labels.
+func formattedWithExternalLabels(groups rulespb.RuleGroupList) map[string][]rulefmt.RuleGroup {
+	formatted := map[string][]rulefmt.RuleGroup{}
+	for _, g := range groups {
+		rg := rulespb.FromProto(g)
+		if len(g.ExternalLabels) > 0 {
+			externalLabels := cortexpb.FromLabelAdaptersToLabels(g.ExternalLabels)
+			for i := range rg.Rules {
+				if rg.Rules[i].Labels == nil {
+					rg.Rules[i].Labels = make(map[string]string, externalLabels.Len())
+				}
+				externalLabels.Range(func(l labels.Label) {
+					if _, ok := rg.Rules[i].Labels[l.Name]; !ok {
+						rg.Rules[i].Labels[l.Name] = l.Value
+					}
+				})
+			}
+		}
+		formatted[g.Namespace] = append(formatted[g.Namespace], rg)
+	}
+	return formatted
+}
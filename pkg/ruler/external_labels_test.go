@@ -0,0 +1,56 @@
+package ruler
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex/pkg/ruler/rulespb"
+)
+
+func TestCheckExternalLabels(t *testing.T) {
+	rulerExternalLabels := labels.FromStrings("cluster", "eu-1")
+
+	assert.NoError(t, checkExternalLabels(nil, rulerExternalLabels))
+	assert.NoError(t, checkExternalLabels(map[string]string{"env": "prod"}, rulerExternalLabels))
+	assert.EqualError(t, checkExternalLabels(map[string]string{"__name__": "up"}, rulerExternalLabels), `the external label "__name__" of the rule group is reserved`)
+	assert.EqualError(t, checkExternalLabels(map[string]string{"alertname": "test"}, rulerExternalLabels), `the external label "alertname" of the rule group is reserved`)
+	assert.EqualError(t, checkExternalLabels(map[string]string{"cluster": "us-1"}, rulerExternalLabels), `the external label "cluster" of the rule group is reserved`)
+	assert.EqualError(t, checkExternalLabels(map[string]string{"1env": "prod"}, rulerExternalLabels), `invalid external label "1env"="prod" of the rule group`)
+}
+
+func TestFormattedWithExternalLabels(t *testing.T) {
+	node := func(value string) yaml.Node { return yaml.Node{Kind: yaml.ScalarNode, Value: value} }
+
+	groups := rulespb.RuleGroupList{
+		rulespb.ToProtoWithTenants("user", "ns", rulespb.RuleGroup{
+			RuleGroup: rulefmt.RuleGroup{
+				Name: "with-labels",
+				Rules: []rulefmt.RuleNode{
+					{Record: node("up:sum"), Expr: node("sum(up)")},
+					{Alert: node("Down"), Expr: node("up == 0"), Labels: map[string]string{"env": "dev", "severity": "page"}},
+				},
+			},
+			ExternalLabels: map[string]string{"env": "prod", "team": "a"},
+		}),
+		rulespb.ToProto("user", "ns", rulefmt.RuleGroup{
+			Name:  "without-labels",
+			Rules: []rulefmt.RuleNode{{Record: node("up:count"), Expr: node("count(up)")}},
+		}),
+	}
+
+	formatted := formattedWithExternalLabels(groups)
+	require.Len(t, formatted["ns"], 2)
+
+	// The labels of the rules take precedence over the external labels.
+	withLabels := formatted["ns"][0]
+	assert.Equal(t, map[string]string{"env": "prod", "team": "a"}, withLabels.Rules[0].Labels)
+	assert.Equal(t, map[string]string{"env": "dev", "severity": "page", "team": "a"}, withLabels.Rules[1].Labels)
+
+	withoutLabels := formatted["ns"][1]
+	assert.Empty(t, withoutLabels.Rules[0].Labels)
+}
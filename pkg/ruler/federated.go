@@ -0,0 +1,155 @@
+package ruler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/ruler/rulespb"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+const (
+	errSourceTenantNotAllowed      = "the tenant %s is not allowed to be a source tenant of the federated rule groups (allowed source tenants: %v)"
+	errDestinationTenantNotAllowed = "the tenant %s is not allowed to be the destination tenant of the rule groups (allowed destination tenants: %v)"
+	errDestinationTenantAlerts     = "the rule groups with a destination tenant can only contain recording rules"
+)
+
+// checkSourceTenants returns an error if any of the source tenants of a federated rule group
+// is not a valid tenant ID or is not allowed for the tenant owning the rule group.
+func checkSourceTenants(sourceTenants, allowed []string) error {
+	for _, sourceTenant := range sourceTenants {
+		if err := tenant.ValidTenantID(sourceTenant); err != nil {
+			return err
+		}
+		if !util.StringsContain(allowed, sourceTenant) {
+			return fmt.Errorf(errSourceTenantNotAllowed, sourceTenant, allowed)
+		}
+	}
+	return nil
+}
+
+// checkDestinationTenant returns an error if the destination tenant of a rule group is not a valid
+// tenant ID or is not allowed for the tenant owning the rule group.
+func checkDestinationTenant(destinationTenant string, allowed []string) error {
+	if err := tenant.ValidTenantID(destinationTenant); err != nil {
+		return err
+	}
+	if !util.StringsContain(allowed, destinationTenant) {
+		return fmt.Errorf(errDestinationTenantNotAllowed, destinationTenant, allowed)
+	}
+	return nil
+}
+
+// ruleGroupRef identifies a rule group loaded in a rules manager.
+type ruleGroupRef struct {
+	file string
+	name string
+}
+
+// ruleGroupRefFromContext returns the rule group evaluating the query in the context.
+func ruleGroupRefFromContext(ctx context.Context) (ruleGroupRef, bool) {
+	origin, ok := ctx.Value(promql.QueryOrigin{}).(map[string]interface{})
+	if !ok {
+		return ruleGroupRef{}, false
+	}
+	group, ok := origin["ruleGroup"].(map[string]string)
+	if !ok {
+		return ruleGroupRef{}, false
+	}
+	return ruleGroupRef{file: group["file"], name: group["name"]}, true
+}
+
+// federatedRuleGroup holds the tenants a rule group queries and writes to, when they're not
+// the tenant owning it, and the additional alertmanagers receiving its alerts.
+type federatedRuleGroup struct {
+	sourceTenants       []string
+	destinationTenant   string
+	alertmanagerTenants []string
+	alertmanagerURLs    []string
+}
+
+// federatedRuleGroups tracks the federated rule groups of a rules manager.
+type federatedRuleGroups struct {
+	mtx    sync.RWMutex
+	groups map[ruleGroupRef]federatedRuleGroup
+}
+
+func (s *federatedRuleGroups) set(groups map[ruleGroupRef]federatedRuleGroup) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.groups = groups
+}
+
+func (s *federatedRuleGroups) get(ref ruleGroupRef) federatedRuleGroup {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.groups[ref]
+}
+
+// SourceTenantsQueryFunc returns a query function running the queries of the federated rule groups
+// across their source tenants, and the queries of the other rule groups on the tenant owning them.
+func SourceTenantsQueryFunc(qf rules.QueryFunc, groups *federatedRuleGroups, overrides RulesLimits, userID string) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		ref, ok := ruleGroupRefFromContext(ctx)
+		if !ok {
+			return qf(ctx, qs, t)
+		}
+
+		tenants := groups.get(ref).sourceTenants
+		if len(tenants) == 0 {
+			return qf(ctx, qs, t)
+		}
+
+		// The allowed source tenants are checked again, given they can change after the rule group has been stored.
+		if err := checkSourceTenants(tenants, overrides.RulerAllowedSourceTenants(userID)); err != nil {
+			return nil, err
+		}
+		return qf(user.InjectOrgID(ctx, tenant.JoinTenantIDs(tenants)), qs, t)
+	}
+}
+
+// destinationTenant returns the tenant the series produced by the rule group evaluated in the
+// context are written to, and an error if the destination tenant is not allowed anymore.
+func destinationTenant(ctx context.Context, groups *federatedRuleGroups, overrides RulesLimits, userID string) (string, error) {
+	ref, ok := ruleGroupRefFromContext(ctx)
+	if !ok {
+		return userID, nil
+	}
+
+	destination := groups.get(ref).destinationTenant
+	if destination == "" {
+		return userID, nil
+	}
+
+	// The allowed destination tenants are checked again, given they can change after the rule group has been stored.
+	if err := checkDestinationTenant(destination, overrides.RulerAllowedDestinationTenants(userID)); err != nil {
+		return "", err
+	}
+	return destination, nil
+}
+
+// federatedRuleGroupsFor returns the rule groups having source tenants, a destination tenant or additional
+// alertmanagers, by the rule file the mapper writes them to and their name.
+func federatedRuleGroupsFor(m *mapper, userID string, groups rulespb.RuleGroupList) map[ruleGroupRef]federatedRuleGroup {
+	federated := map[ruleGroupRef]federatedRuleGroup{}
+	for _, g := range groups {
+		if len(g.SourceTenants) == 0 && g.DestinationTenant == "" && len(g.AlertmanagerTenants) == 0 && len(g.AlertmanagerURLs) == 0 {
+			continue
+		}
+
+		federated[ruleGroupRef{file: m.ruleFile(userID, g.Namespace), name: g.Name}] = federatedRuleGroup{
+			sourceTenants:       tenant.NormalizeTenantIDs(g.SourceTenants),
+			destinationTenant:   g.DestinationTenant,
+			alertmanagerTenants: g.AlertmanagerTenants,
+			alertmanagerURLs:    g.AlertmanagerURLs,
+		}
+	}
+	return federated
+}
@@ -0,0 +1,105 @@
+package ruler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/cortexpb"
+)
+
+func ruleGroupContext(file, name string) context.Context {
+	ctx := user.InjectOrgID(context.Background(), "user-1")
+	return promql.NewOriginContext(ctx, map[string]interface{}{
+		"ruleGroup": map[string]string{"file": file, "name": name},
+	})
+}
+
+func TestSourceTenantsQueryFunc(t *testing.T) {
+	var queriedOrgID string
+	query := func(ctx context.Context, _ string, _ time.Time) (promql.Vector, error) {
+		queriedOrgID, _ = user.ExtractOrgID(ctx)
+		return nil, nil
+	}
+
+	groups := &federatedRuleGroups{}
+	groups.set(map[ruleGroupRef]federatedRuleGroup{
+		{file: "/rules/user-1/ns", name: "federated"}: {sourceTenants: []string{"team-a", "team-b"}},
+	})
+
+	limits := ruleLimits{allowedSourceTenants: []string{"team-a", "team-b"}}
+	qf := SourceTenantsQueryFunc(query, groups, limits, "user-1")
+
+	// The federated rule groups query their source tenants.
+	_, err := qf(ruleGroupContext("/rules/user-1/ns", "federated"), "up", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "team-a|team-b", queriedOrgID)
+
+	// The other rule groups query the tenant owning them.
+	_, err = qf(ruleGroupContext("/rules/user-1/ns", "other"), "up", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", queriedOrgID)
+
+	// The federated rule groups fail if a source tenant is not allowed anymore.
+	qf = SourceTenantsQueryFunc(query, groups, ruleLimits{allowedSourceTenants: []string{"team-a"}}, "user-1")
+	_, err = qf(ruleGroupContext("/rules/user-1/ns", "federated"), "up", time.Now())
+	require.EqualError(t, err, "the tenant team-b is not allowed to be a source tenant of the federated rule groups (allowed source tenants: [team-a])")
+}
+
+func TestPusherAppendable_DestinationTenant(t *testing.T) {
+	groups := &federatedRuleGroups{}
+	groups.set(map[ruleGroupRef]federatedRuleGroup{
+		{file: "/rules/user-1/ns", name: "aggregated"}: {destinationTenant: "ops"},
+	})
+
+	for name, tc := range map[string]struct {
+		group            string
+		allowed          []string
+		expectedOrgID    string
+		expectedErr      string
+		expectedFailures int
+	}{
+		"rule group with a destination tenant": {
+			group:         "aggregated",
+			allowed:       []string{"ops"},
+			expectedOrgID: "ops",
+		},
+		"rule group without a destination tenant": {
+			group:         "other",
+			expectedOrgID: "user-1",
+		},
+		"destination tenant not allowed anymore": {
+			group:            "aggregated",
+			expectedErr:      "the tenant ops is not allowed to be the destination tenant of the rule groups (allowed destination tenants: [])",
+			expectedFailures: 1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			pusher := &fakePusher{response: &cortexpb.WriteResponse{}}
+			failures := prometheus.NewCounter(prometheus.CounterOpts{})
+			pa := NewPusherAppendable(pusher, "user-1", ruleLimits{allowedDestTenants: tc.allowed}, prometheus.NewCounter(prometheus.CounterOpts{}), failures)
+			pa.federatedGroups = groups
+
+			a := pa.Appender(ruleGroupContext("/rules/user-1/ns", tc.group))
+			_, err := a.Append(0, labels.FromStrings(labels.MetricName, "job:up"), 0, 1)
+			require.NoError(t, err)
+
+			if tc.expectedErr != "" {
+				require.EqualError(t, a.Commit(), tc.expectedErr)
+				assert.Nil(t, pusher.request)
+			} else {
+				require.NoError(t, a.Commit())
+				assert.Equal(t, tc.expectedOrgID, pusher.orgID)
+			}
+			assert.Equal(t, tc.expectedFailures, int(testutil.ToFloat64(failures)))
+		})
+	}
+}
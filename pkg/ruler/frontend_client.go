@@ -0,0 +1,129 @@
+package ruler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/weaveworks/common/user"
+)
+
+// FrontendClient runs the rule queries through the Prometheus HTTP API exposed by the
+// query-frontend (or the querier), so that they go through the same query sharding,
+// caching and limits of the user queries.
+type FrontendClient struct {
+	api     v1.API
+	timeout time.Duration
+}
+
+// NewFrontendClient creates a FrontendClient for the Prometheus HTTP API at the address,
+// including the API prefix (eg. http://query-frontend:8080/prometheus).
+func NewFrontendClient(address string, timeout time.Duration) (*FrontendClient, error) {
+	client, err := api.NewClient(api.Config{
+		Address:      address,
+		RoundTripper: &orgIDRoundTripper{next: api.DefaultRoundTripper},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &FrontendClient{
+		api:     v1.NewAPI(client),
+		timeout: timeout,
+	}, nil
+}
+
+// InstantQuery runs the instant query at the given time, on behalf of the tenant in the context.
+func (c *FrontendClient) InstantQuery(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	res, _, err := c.api.Query(ctx, qs, t)
+	if err != nil {
+		return nil, translateFrontendError(err)
+	}
+
+	switch v := res.(type) {
+	case model.Vector:
+		out := make(promql.Vector, 0, len(v))
+		for _, s := range v {
+			if s.Histogram != nil {
+				return nil, errors.New("native histograms are not supported by the rules evaluated through the query-frontend")
+			}
+
+			b := labels.NewScratchBuilder(len(s.Metric))
+			for name, value := range s.Metric {
+				b.Add(string(name), string(value))
+			}
+			b.Sort()
+
+			out = append(out, promql.Sample{
+				T:      int64(s.Timestamp),
+				F:      float64(s.Value),
+				Metric: b.Labels(),
+			})
+		}
+		return out, nil
+	case *model.Scalar:
+		return promql.Vector{promql.Sample{
+			T:      int64(v.Timestamp),
+			F:      float64(v.Value),
+			Metric: labels.Labels{},
+		}}, nil
+	default:
+		return nil, errors.New("rule result is not a vector or scalar")
+	}
+}
+
+// translateFrontendError wraps the errors which are not caused by the query into a QueryableError
+// of storage type, so that they're tracked as failed queries like the errors of the embedded querier.
+func translateFrontendError(err error) error {
+	var apiErr *v1.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Type {
+		case v1.ErrBadData, v1.ErrExec, v1.ErrCanceled, v1.ErrClient:
+			return err
+		}
+	}
+
+	return WrapQueryableErrors(promql.ErrStorage{Err: err})
+}
+
+// FrontendQueryFunc returns a query function running the rule queries through the query-frontend.
+func FrontendQueryFunc(client *FrontendClient, overrides RulesLimits, userID string) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		evaluationDelay := overrides.EvaluationDelay(userID)
+		// The federated rule groups query their source tenants, already set in the context.
+		orgID, err := user.ExtractOrgID(ctx)
+		if err != nil {
+			orgID = userID
+		}
+		return client.InstantQuery(user.InjectOrgID(ctx, orgID), qs, t.Add(-evaluationDelay))
+	}
+}
+
+// orgIDRoundTripper injects the tenant ID from the request context into the request headers.
+type orgIDRoundTripper struct {
+	next http.RoundTripper
+}
+
+func (rt *orgIDRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The round tripper must not modify the request.
+	req = req.Clone(req.Context())
+	if err := user.InjectOrgIDIntoHTTPRequest(req.Context(), req); err != nil {
+		return nil, fmt.Errorf("inject tenant ID: %w", err)
+	}
+
+	return rt.next.RoundTrip(req)
+}
@@ -0,0 +1,83 @@
+package ruler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrontendQueryFunc(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	for name, tc := range map[string]struct {
+		status          int
+		body            string
+		expected        promql.Vector
+		expectedErr     bool
+		expectedStorage bool
+	}{
+		"vector": {
+			status: http.StatusOK,
+			body:   `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"__name__":"up","job":"a"},"value":[940,"1"]}]}}`,
+			expected: promql.Vector{
+				{T: 940000, F: 1, Metric: labels.FromStrings(labels.MetricName, "up", "job", "a")},
+			},
+		},
+		"scalar": {
+			status:   http.StatusOK,
+			body:     `{"status":"success","data":{"resultType":"scalar","result":[940,"2"]}}`,
+			expected: promql.Vector{{T: 940000, F: 2, Metric: labels.Labels{}}},
+		},
+		"execution error is a user error": {
+			status:      http.StatusUnprocessableEntity,
+			body:        `{"status":"error","errorType":"execution","error":"too many samples"}`,
+			expectedErr: true,
+		},
+		"server error is a storage error": {
+			status:          http.StatusInternalServerError,
+			body:            `internal error`,
+			expectedErr:     true,
+			expectedStorage: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/prometheus/api/v1/query", r.URL.Path)
+				assert.Equal(t, "user-1", r.Header.Get("X-Scope-OrgID"))
+				require.NoError(t, r.ParseForm())
+				assert.Equal(t, "up", r.Form.Get("query"))
+				// The evaluation delay is applied to the evaluation time.
+				assert.Equal(t, "940", r.Form.Get("time"))
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer server.Close()
+
+			client, err := NewFrontendClient(server.URL+"/prometheus", time.Minute)
+			require.NoError(t, err)
+
+			queryFunc := FrontendQueryFunc(client, ruleLimits{evalDelay: time.Minute}, "user-1")
+			res, err := queryFunc(context.Background(), "up", now)
+
+			if !tc.expectedErr {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, res)
+				return
+			}
+
+			require.Error(t, err)
+			assert.Equal(t, tc.expectedStorage, errors.As(err, &QueryableError{}))
+			assert.Equal(t, tc.expectedStorage, errors.As(err, &promql.ErrStorage{}))
+		})
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"golang.org/x/net/context/ctxhttp"
 
 	"github.com/cortexproject/cortex/pkg/ruler/rulespb"
+	"github.com/cortexproject/cortex/pkg/util/services"
 )
 
 type DefaultMultiTenantManager struct {
@@ -43,10 +44,27 @@ type DefaultMultiTenantManager struct {
 	notifiersMtx              sync.Mutex
 	notifiers                 map[string]*rulerNotifier
 	notifiersDiscoveryMetrics map[string]discovery.DiscovererMetrics
+	// Per-user notifiers of the additional alertmanagers of the rule groups.
+	routedNotifiers map[string]map[alertmanagerRoute]*rulerNotifier
 
 	// rules backup
 	rulesBackupManager *rulesBackupManager
 
+	// Persists and restores the alerts state, nil if disabled.
+	alertState *alertStatePersister
+
+	// Persists the last evaluation of the rule groups and catches up their missed evaluations, nil if disabled.
+	evaluationCatchUp *evaluationCatchUp
+
+	// Per-user dependencies between the rule groups, only tracked if the rule dependency ordering is enabled.
+	ruleGroupDependencies map[string]*ruleGroupDependencies
+
+	// Per-user rule groups with aligned evaluations.
+	ruleGroupAlignments map[string]*ruleGroupAlignments
+
+	// Leases of the rule groups evaluations, nil if disabled.
+	evaluationLeases *evaluationLeases
+
 	managersTotal                 prometheus.Gauge
 	lastReloadSuccessful          *prometheus.GaugeVec
 	lastReloadSuccessfulTimestamp *prometheus.GaugeVec
@@ -59,7 +77,7 @@ type DefaultMultiTenantManager struct {
 	syncRuleMtx  sync.Mutex
 }
 
-func NewDefaultMultiTenantManager(cfg Config, managerFactory ManagerFactory, evalMetrics *RuleEvalMetrics, reg prometheus.Registerer, logger log.Logger) (*DefaultMultiTenantManager, error) {
+func NewDefaultMultiTenantManager(cfg Config, managerFactory ManagerFactory, alertStateStore *AlertStateStore, lastEvaluationStore *LastEvaluationStore, evalMetrics *RuleEvalMetrics, reg prometheus.Registerer, logger log.Logger) (*DefaultMultiTenantManager, error) {
 	ncfg, err := buildNotifierConfig(&cfg)
 	if err != nil {
 		return nil, err
@@ -89,10 +107,13 @@ func NewDefaultMultiTenantManager(cfg Config, managerFactory ManagerFactory, eva
 		ruleEvalMetrics:           evalMetrics,
 		notifiers:                 map[string]*rulerNotifier{},
 		notifiersDiscoveryMetrics: notifiersDiscoveryMetrics,
+		routedNotifiers:           map[string]map[alertmanagerRoute]*rulerNotifier{},
 		mapper:                    newMapper(cfg.RulePath, logger),
 		userManagers:              map[string]RulesManager{},
 		userManagerMetrics:        userManagerMetrics,
 		ruleCache:                 map[string][]*promRules.Group{},
+		ruleGroupDependencies:     map[string]*ruleGroupDependencies{},
+		ruleGroupAlignments:       map[string]*ruleGroupAlignments{},
 		managersTotal: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
 			Namespace: "cortex",
 			Name:      "ruler_managers_total",
@@ -119,6 +140,20 @@ func NewDefaultMultiTenantManager(cfg Config, managerFactory ManagerFactory, eva
 	if cfg.RulesBackupEnabled() {
 		m.rulesBackupManager = newRulesBackupManager(cfg, logger, reg)
 	}
+	if alertStateStore != nil {
+		m.alertState = newAlertStatePersister(alertStateStore, logger, reg)
+	}
+	if lastEvaluationStore != nil {
+		m.evaluationCatchUp = newEvaluationCatchUp(lastEvaluationStore, cfg.EvaluationCatchUpMaxLookback, logger, reg)
+	}
+	if cfg.EvaluationLeases.Enabled {
+		if m.evaluationLeases, err = newEvaluationLeases(cfg, reg, logger); err != nil {
+			return nil, err
+		}
+		if err := services.StartAndAwaitRunning(context.Background(), m.evaluationLeases.tracker); err != nil {
+			return nil, err
+		}
+	}
 	return m, nil
 }
 
@@ -137,8 +172,19 @@ func (r *DefaultMultiTenantManager) SyncRuleGroups(ctx context.Context, ruleGrou
 	// Check for deleted users and remove them
 	for userID, mngr := range r.userManagers {
 		if _, exists := ruleGroups[userID]; !exists {
+			if r.alertState != nil {
+				// The rule groups may have been resharded, so their latest state is persisted for the new owner.
+				r.alertState.persist(ctx, userID, mngr.RuleGroups())
+				r.alertState.removeUser(userID)
+			}
+			if r.evaluationCatchUp != nil {
+				r.evaluationCatchUp.persist(ctx, userID)
+				r.evaluationCatchUp.removeUser(userID)
+			}
 			go mngr.Stop()
 			delete(r.userManagers, userID)
+			delete(r.ruleGroupDependencies, userID)
+			delete(r.ruleGroupAlignments, userID)
 
 			r.removeNotifier(userID)
 			r.mapper.cleanupUser(userID)
@@ -178,8 +224,13 @@ func (r *DefaultMultiTenantManager) BackUpRuleGroups(ctx context.Context, ruleGr
 // users Prometheus Rules Manager.
 func (r *DefaultMultiTenantManager) syncRulesToManager(ctx context.Context, user string, groups rulespb.RuleGroupList) {
 	// Map the files to disk and return the file names to be passed to the users manager if they
-	// have been updated
-	update, files, err := r.mapper.MapRules(user, groups.Formatted())
+	// have been updated. The external labels of the rule groups are part of the rule files.
+	formatted := formattedWithExternalLabels(groups)
+	if r.cfg.RuleDependencyOrderingEnabled {
+		orderRuleGroupsByDependencies(formatted)
+	}
+
+	update, files, err := r.mapper.MapRules(user, formatted)
 	if err != nil {
 		r.lastReloadSuccessful.WithLabelValues(user).Set(0)
 		level.Error(r.logger).Log("msg", "unable to map rule files", "user", user, "err", err)
@@ -197,25 +248,87 @@ func (r *DefaultMultiTenantManager) syncRulesToManager(ctx context.Context, user
 		return
 	}
 
+	// The federated rule groups are set before updating the rule groups, so that they're
+	// never evaluated on the tenant owning them.
+	if m, ok := manager.(federatedRulesManagerSetter); ok {
+		m.SetFederatedRuleGroups(federatedRuleGroupsFor(r.mapper, user, groups))
+	}
+	r.removeUnusedRoutedNotifiers(user, groups)
+	if m, ok := manager.(ruleGroupStatsRulesManager); ok {
+		refs := make(map[ruleGroupRef]struct{}, len(groups))
+		for _, g := range groups {
+			refs[ruleGroupRef{file: r.mapper.ruleFile(user, g.Namespace), name: g.Name}] = struct{}{}
+		}
+		m.RetainRuleGroupStats(refs)
+	}
+
+	// The alignment of the rule groups isn't part of the rule files, so it's set even if they didn't change.
+	alignments := r.getOrCreateRuleGroupAlignments(user)
+	alignments.set(alignedRuleGroupsFor(r.mapper, user, groups))
+
 	if !existing || update {
 		level.Debug(r.logger).Log("msg", "updating rules", "user", user)
 		r.configUpdatesTotal.WithLabelValues(user).Inc()
 		if update && existing {
 			r.updateRuleCache(user, manager.RuleGroups())
 		}
-		err = manager.Update(r.cfg.EvaluationInterval, files, r.cfg.ExternalLabels, r.cfg.ExternalURL.String(), ruleGroupIterationFunc)
+		iterationFunc := ruleGroupIterationFunc
+		if r.alertState != nil {
+			iterationFunc = r.prepareAlertStateRestore(ctx, user, manager, existing, groups)
+		}
+		if r.evaluationCatchUp != nil {
+			iterationFunc = r.prepareEvaluationCatchUp(ctx, user, manager, existing, groups, iterationFunc)
+		}
+		// The rule groups with aligned evaluations are evaluated at their aligned timestamp, regardless of their dependencies.
+		alignedIterationFunc := iterationFunc
+		var dependencies *ruleGroupDependencies
+		if r.cfg.RuleDependencyOrderingEnabled {
+			dependencies = r.getOrCreateRuleGroupDependencies(user)
+			dependencies.setDependencies(ruleGroupDependenciesFor(r.mapper, user, groups))
+			iterationFunc = dependencies.iterationFunc(iterationFunc)
+		}
+		iterationFunc = alignments.iterationFunc(alignedIterationFunc, iterationFunc)
+		if r.evaluationLeases != nil {
+			iterationFunc = r.evaluationLeases.iterationFunc(user, iterationFunc)
+		}
+		err = manager.Update(r.cfg.EvaluationInterval, files, r.cfg.ExternalLabels, r.cfg.ExternalURL.String(), iterationFunc)
 		r.deleteRuleCache(user)
 		if err != nil {
 			r.lastReloadSuccessful.WithLabelValues(user).Set(0)
 			level.Error(r.logger).Log("msg", "unable to update rule manager", "user", user, "err", err)
 			return
 		}
+		if dependencies != nil {
+			dependencies.setGroups(manager.RuleGroups())
+		}
 
 		r.lastReloadSuccessful.WithLabelValues(user).Set(1)
 		r.lastReloadSuccessfulTimestamp.WithLabelValues(user).SetToCurrentTime()
 	}
 }
 
+// getOrCreateRuleGroupDependencies returns the dependencies between the rule groups of the user. It must
+// be called while syncing the rule groups.
+func (r *DefaultMultiTenantManager) getOrCreateRuleGroupDependencies(user string) *ruleGroupDependencies {
+	dependencies, ok := r.ruleGroupDependencies[user]
+	if !ok {
+		dependencies = &ruleGroupDependencies{}
+		r.ruleGroupDependencies[user] = dependencies
+	}
+	return dependencies
+}
+
+// getOrCreateRuleGroupAlignments returns the rule groups of the user with aligned evaluations. It must
+// be called while syncing the rule groups.
+func (r *DefaultMultiTenantManager) getOrCreateRuleGroupAlignments(user string) *ruleGroupAlignments {
+	alignments, ok := r.ruleGroupAlignments[user]
+	if !ok {
+		alignments = &ruleGroupAlignments{}
+		r.ruleGroupAlignments[user] = alignments
+	}
+	return alignments
+}
+
 func (r *DefaultMultiTenantManager) getRulesManager(user string, ctx context.Context) RulesManager {
 	r.userManagerMtx.RLock()
 	defer r.userManagerMtx.RUnlock()
@@ -252,6 +365,7 @@ func ruleGroupIterationFunc(ctx context.Context, g *promRules.Group, evalTimesta
 	}
 
 	level.Info(g.Logger()).Log(logMessage...)
+	ctx = contextWithRuleGroupEvaluation(contextWithRuleGroupOutput(ctx), evalTimestamp)
 	promRules.DefaultEvalIterationFunc(ctx, g, evalTimestamp)
 }
 
@@ -268,7 +382,13 @@ func (r *DefaultMultiTenantManager) newManager(ctx context.Context, userID strin
 		return nil, err
 	}
 
-	return r.managerFactory(ctx, userID, notifier, r.logger, reg), nil
+	manager := r.managerFactory(ctx, userID, notifier, r.logger, reg)
+	if m, ok := manager.(alertmanagerRoutingRulesManager); ok {
+		m.SetAlertmanagerNotifiers(func(route alertmanagerRoute) (sender, error) {
+			return r.getOrCreateRoutedNotifier(userID, route)
+		})
+	}
+	return manager, nil
 }
 
 func (r *DefaultMultiTenantManager) removeNotifier(userID string) {
@@ -278,8 +398,12 @@ func (r *DefaultMultiTenantManager) removeNotifier(userID string) {
 	if n, ok := r.notifiers[userID]; ok {
 		n.stop()
 	}
+	for _, n := range r.routedNotifiers[userID] {
+		n.stop()
+	}
 
 	delete(r.notifiers, userID)
+	delete(r.routedNotifiers, userID)
 }
 
 func (r *DefaultMultiTenantManager) getOrCreateNotifier(userID string, userManagerRegistry prometheus.Registerer) (*notifier.Manager, error) {
@@ -346,6 +470,18 @@ func (r *DefaultMultiTenantManager) GetRules(userID string) []*promRules.Group {
 	return groups
 }
 
+// GetRuleGroupStats returns the query statistics of the last evaluation, and of all the evaluations, of the
+// rule group of the user, if recorded.
+func (r *DefaultMultiTenantManager) GetRuleGroupStats(userID, file, name string) (last, total *RuleGroupStatsDesc) {
+	r.userManagerMtx.RLock()
+	mngr, exists := r.userManagers[userID]
+	r.userManagerMtx.RUnlock()
+	if m, ok := mngr.(ruleGroupStatsRulesManager); exists && ok {
+		return m.RuleGroupStats(ruleGroupRef{file: file, name: name})
+	}
+	return nil, nil
+}
+
 func (r *DefaultMultiTenantManager) GetBackupRules(userID string) rulespb.RuleGroupList {
 	if r.rulesBackupManager != nil {
 		return r.rulesBackupManager.getRuleGroups(userID)
@@ -354,10 +490,19 @@ func (r *DefaultMultiTenantManager) GetBackupRules(userID string) rulespb.RuleGr
 }
 
 func (r *DefaultMultiTenantManager) Stop() {
+	// The state of the alerts is persisted before stopping the user managers, to be restored on restart.
+	r.PersistAlertState(context.Background())
+	r.PersistLastEvaluations(context.Background())
+
 	r.notifiersMtx.Lock()
 	for _, n := range r.notifiers {
 		n.stop()
 	}
+	for _, notifiers := range r.routedNotifiers {
+		for _, n := range notifiers {
+			n.stop()
+		}
+	}
 	r.notifiersMtx.Unlock()
 
 	level.Info(r.logger).Log("msg", "stopping user managers")
@@ -376,6 +521,10 @@ func (r *DefaultMultiTenantManager) Stop() {
 	r.userManagerMtx.Unlock()
 	level.Info(r.logger).Log("msg", "all user managers stopped")
 
+	if r.evaluationLeases != nil {
+		_ = services.StopAndAwaitTerminated(context.Background(), r.evaluationLeases.tracker)
+	}
+
 	// cleanup user rules directories
 	r.mapper.cleanup()
 }
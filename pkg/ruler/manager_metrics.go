@@ -230,6 +230,14 @@ type RuleEvalMetrics struct {
 	TotalQueriesVec   *prometheus.CounterVec
 	FailedQueriesVec  *prometheus.CounterVec
 	RulerQuerySeconds *prometheus.CounterVec
+
+	// Per rule group metrics, only created when the rule group stats are enabled.
+	RuleGroupQuerySeconds       *prometheus.CounterVec
+	RuleGroupFetchedSeries      *prometheus.CounterVec
+	RuleGroupFetchedSamples     *prometheus.CounterVec
+	RuleGroupFetchedBytes       *prometheus.CounterVec
+	RuleGroupSeriesWritten      *prometheus.CounterVec
+	RuleGroupEvaluationFailures *prometheus.CounterVec
 }
 
 func NewRuleEvalMetrics(cfg Config, reg prometheus.Registerer) *RuleEvalMetrics {
@@ -257,6 +265,32 @@ func NewRuleEvalMetrics(cfg Config, reg prometheus.Registerer) *RuleEvalMetrics
 			Help: "Total amount of wall clock time spent processing queries by the ruler.",
 		}, []string{"user"})
 	}
+	if cfg.RuleGroupStatsEnabled {
+		m.RuleGroupQuerySeconds = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_rule_group_query_seconds_total",
+			Help: "Total amount of wall clock time spent processing the queries of the rule group.",
+		}, []string{"user", "rule_group"})
+		m.RuleGroupFetchedSeries = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_rule_group_fetched_series_total",
+			Help: "Total number of series fetched by the queries of the rule group.",
+		}, []string{"user", "rule_group"})
+		m.RuleGroupFetchedSamples = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_rule_group_fetched_samples_total",
+			Help: "Total number of samples fetched by the queries of the rule group.",
+		}, []string{"user", "rule_group"})
+		m.RuleGroupFetchedBytes = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_rule_group_fetched_bytes_total",
+			Help: "Total size of the data fetched by the queries of the rule group, in bytes.",
+		}, []string{"user", "rule_group"})
+		m.RuleGroupSeriesWritten = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_rule_group_series_written_total",
+			Help: "Total number of series written by the rules of the rule group.",
+		}, []string{"user", "rule_group"})
+		m.RuleGroupEvaluationFailures = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_rule_group_evaluation_failures_total",
+			Help: "Total number of failed rule evaluations of the rule group, by reason.",
+		}, []string{"user", "rule_group", "reason"})
+	}
 
 	return m
 }
@@ -270,4 +304,14 @@ func (m *RuleEvalMetrics) deletePerUserMetrics(userID string) {
 	if m.RulerQuerySeconds != nil {
 		m.RulerQuerySeconds.DeleteLabelValues(userID)
 	}
+	m.deletePerRuleGroupMetrics(prometheus.Labels{"user": userID})
+}
+
+// deletePerRuleGroupMetrics deletes the per rule group metrics matching the labels.
+func (m *RuleEvalMetrics) deletePerRuleGroupMetrics(matching prometheus.Labels) {
+	for _, vec := range []*prometheus.CounterVec{m.RuleGroupQuerySeconds, m.RuleGroupFetchedSeries, m.RuleGroupFetchedSamples, m.RuleGroupFetchedBytes, m.RuleGroupSeriesWritten, m.RuleGroupEvaluationFailures} {
+		if vec != nil {
+			vec.DeletePartialMatch(matching)
+		}
+	}
 }
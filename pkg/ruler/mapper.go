@@ -82,9 +82,7 @@ func (m *mapper) MapRules(user string, ruleConfigs map[string][]rulefmt.RuleGrou
 
 	// write all rule configs to disk
 	for filename, groups := range ruleConfigs {
-		// Store the encoded file name to better handle `/` characters
-		encodedFileName := url.PathEscape(filename)
-		fullFileName := filepath.Join(path, encodedFileName)
+		fullFileName := m.ruleFile(user, filename)
 
 		fileUpdated, err := m.writeRuleGroupsIfNewer(groups, fullFileName)
 		if err != nil {
@@ -124,6 +122,12 @@ func (m *mapper) MapRules(user string, ruleConfigs map[string][]rulefmt.RuleGrou
 	return anyUpdated, filenames, nil
 }
 
+// ruleFile returns the path of the file the rule groups of the namespace are written to.
+func (m *mapper) ruleFile(user, namespace string) string {
+	// Store the encoded file name to better handle `/` characters
+	return filepath.Join(m.Path, user, url.PathEscape(namespace))
+}
+
 func (m *mapper) writeRuleGroupsIfNewer(groups []rulefmt.RuleGroup, filename string) (bool, error) {
 	sort.Slice(groups, func(i, j int) bool {
 		return groups[i].Name > groups[j].Name
@@ -0,0 +1,188 @@
+package ruler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"go.uber.org/atomic"
+
+	"github.com/cortexproject/cortex/pkg/querier/stats"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+const (
+	errMaxQueryBytesPerMinuteLimitExceeded = "the rule queries of the tenant exceeded the fetched data bytes limit (limit: %d bytes per minute)"
+	errMaxSeriesPerRuleLimitExceeded       = "the recording rule exceeded the series per rule limit (limit: %d series, actual: %d series)"
+	errMaxAlertsPerRuleLimitExceeded       = "the alerting rule exceeded the alerts per rule limit (limit: %d alerts, actual: %d alerts)"
+	errMaxSeriesPerRuleGroupLimitExceeded  = "the rule group exceeded the series per rule group limit (limit: %d series, actual: %d series)"
+)
+
+type ruleGroupOutputContextKey struct{}
+
+// contextWithRuleGroupOutput returns a context counting the series and alerts of the rules of a rule group
+// evaluation, to enforce the maximum number of series per rule group.
+func contextWithRuleGroupOutput(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ruleGroupOutputContextKey{}, atomic.NewInt64(0))
+}
+
+func ruleGroupOutputFromContext(ctx context.Context) *atomic.Int64 {
+	output, _ := ctx.Value(ruleGroupOutputContextKey{}).(*atomic.Int64)
+	return output
+}
+
+// ruleQueryLimiter enforces the limits of the rule queries of a tenant: the maximum number of rule queries
+// evaluated concurrently, and the maximum size of the data they fetch each minute.
+type ruleQueryLimiter struct {
+	overrides RulesLimits
+	userID    string
+	now       func() time.Time
+
+	mtx      sync.Mutex
+	inflight int
+	// Closed, and replaced, each time a rule query completes.
+	released chan struct{}
+	// The start of the current minute and the size of the data fetched in it.
+	window      time.Time
+	windowBytes int64
+}
+
+func newRuleQueryLimiter(overrides RulesLimits, userID string) *ruleQueryLimiter {
+	return &ruleQueryLimiter{
+		overrides: overrides,
+		userID:    userID,
+		now:       time.Now,
+		released:  make(chan struct{}),
+	}
+}
+
+// acquire waits until the rule query can be evaluated without exceeding the maximum number of concurrent
+// rule queries, or the context is done.
+func (l *ruleQueryLimiter) acquire(ctx context.Context) error {
+	for {
+		l.mtx.Lock()
+		limit := l.overrides.RulerMaxConcurrentEvaluations(l.userID)
+		if limit <= 0 || l.inflight < limit {
+			l.inflight++
+			l.mtx.Unlock()
+			return nil
+		}
+		released := l.released
+		l.mtx.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-released:
+		}
+	}
+}
+
+// release records the completion of a rule query which fetched the given size of data.
+func (l *ruleQueryLimiter) release(fetchedBytes int64) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.inflight--
+	close(l.released)
+	l.released = make(chan struct{})
+
+	l.rotateWindow()
+	l.windowBytes += fetchedBytes
+}
+
+// checkFetchedBytes returns an error if the rule queries have exceeded the maximum size of the data
+// fetched in the current minute.
+func (l *ruleQueryLimiter) checkFetchedBytes() error {
+	limit := l.overrides.RulerMaxQueryBytesPerMinute(l.userID)
+	if limit <= 0 {
+		return nil
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.rotateWindow()
+	if l.windowBytes >= limit {
+		return validation.LimitError(fmt.Sprintf(errMaxQueryBytesPerMinuteLimitExceeded, limit))
+	}
+	return nil
+}
+
+// rotateWindow resets the size of the fetched data at the start of each minute. Must be called with the lock held.
+func (l *ruleQueryLimiter) rotateWindow() {
+	if window := l.now().Truncate(time.Minute); window.After(l.window) {
+		l.window = window
+		l.windowBytes = 0
+	}
+}
+
+// checkRuleOutput returns an error if the series of a rule query exceed the maximum number of series of
+// a recording rule, or of alerts of an alerting rule, or the maximum number of series of the rule group.
+func checkRuleOutput(ctx context.Context, overrides RulesLimits, userID string, vector promql.Vector) error {
+	switch rules.FromOriginContext(ctx).Kind {
+	case rules.KindRecording:
+		if limit := overrides.RulerMaxSeriesPerRule(userID); limit > 0 && len(vector) > limit {
+			return validation.LimitError(fmt.Sprintf(errMaxSeriesPerRuleLimitExceeded, limit, len(vector)))
+		}
+	case rules.KindAlerting:
+		if limit := overrides.RulerMaxAlertsPerRule(userID); limit > 0 && len(vector) > limit {
+			return validation.LimitError(fmt.Sprintf(errMaxAlertsPerRuleLimitExceeded, limit, len(vector)))
+		}
+	default:
+		return nil
+	}
+
+	if output := ruleGroupOutputFromContext(ctx); output != nil {
+		total := output.Add(int64(len(vector)))
+		if limit := overrides.RulerMaxSeriesPerRuleGroup(userID); limit > 0 && total > int64(limit) {
+			return validation.LimitError(fmt.Sprintf(errMaxSeriesPerRuleGroupLimitExceeded, limit, total))
+		}
+	}
+	return nil
+}
+
+// LimitedQueryFunc returns a query function enforcing the per-tenant limits of the rule queries: the
+// maximum number of concurrent rule queries, the timeout of each rule query and the maximum size of
+// the data fetched by the rule queries each minute. It also enforces the maximum number of series and
+// alerts of the rules, failing the rules exceeding them.
+func LimitedQueryFunc(qf rules.QueryFunc, overrides RulesLimits, userID string) rules.QueryFunc {
+	limiter := newRuleQueryLimiter(overrides, userID)
+
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		if err := limiter.checkFetchedBytes(); err != nil {
+			return nil, err
+		}
+		if err := limiter.acquire(ctx); err != nil {
+			return nil, err
+		}
+
+		// The size of the data fetched by the query is tracked by the query stats.
+		queryStats := stats.FromContext(ctx)
+		if queryStats == nil {
+			queryStats, ctx = stats.ContextWithEmptyStats(ctx)
+		}
+		fetchedBytes := queryStats.LoadFetchedDataBytes()
+		defer func() {
+			limiter.release(int64(queryStats.LoadFetchedDataBytes() - fetchedBytes))
+		}()
+
+		if timeout := overrides.RulerEvaluationQueryTimeout(userID); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		vector, err := qf(ctx, qs, t)
+		if err != nil {
+			return vector, err
+		}
+		if err := checkRuleOutput(ctx, overrides, userID, vector); err != nil {
+			return nil, err
+		}
+		return vector, nil
+	}
+}
@@ -0,0 +1,151 @@
+package ruler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/cortexproject/cortex/pkg/querier/stats"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+func TestLimitedQueryFunc_MaxConcurrentEvaluations(t *testing.T) {
+	var (
+		running    atomic.Int32
+		maxRunning atomic.Int32
+		unblock    = make(chan struct{})
+	)
+	qf := LimitedQueryFunc(func(_ context.Context, _ string, _ time.Time) (promql.Vector, error) {
+		if n := running.Inc(); n > maxRunning.Load() {
+			maxRunning.Store(n)
+		}
+		<-unblock
+		running.Dec()
+		return nil, nil
+	}, ruleLimits{maxConcurrentEvals: 2}, "user-1")
+
+	done := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		go func() {
+			_, err := qf(context.Background(), "up", time.Now())
+			done <- err
+		}()
+	}
+
+	// Only 2 queries run until they're unblocked.
+	require.Eventually(t, func() bool { return running.Load() == 2 }, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(2), running.Load())
+
+	close(unblock)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, <-done)
+	}
+	assert.Equal(t, int32(2), maxRunning.Load())
+
+	// The queries waiting for a slot fail once their context is done.
+	limiter := newRuleQueryLimiter(ruleLimits{maxConcurrentEvals: 1}, "user-1")
+	require.NoError(t, limiter.acquire(context.Background()))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, limiter.acquire(ctx), context.DeadlineExceeded)
+}
+
+func TestLimitedQueryFunc_EvaluationQueryTimeout(t *testing.T) {
+	qf := LimitedQueryFunc(func(ctx context.Context, _ string, _ time.Time) (promql.Vector, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, ruleLimits{queryTimeout: 50 * time.Millisecond}, "user-1")
+
+	_, err := qf(context.Background(), "up", time.Now())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestLimitedQueryFunc_MaxQueryBytesPerMinute(t *testing.T) {
+	now := time.Unix(1000, 0)
+	limiter := newRuleQueryLimiter(ruleLimits{maxQueryBytes: 100}, "user-1")
+	limiter.now = func() time.Time { return now }
+
+	fetch := func(bytes uint64) error {
+		if err := limiter.checkFetchedBytes(); err != nil {
+			return err
+		}
+		require.NoError(t, limiter.acquire(context.Background()))
+		limiter.release(int64(bytes))
+		return nil
+	}
+
+	require.NoError(t, fetch(60))
+	require.NoError(t, fetch(60))
+	assert.EqualError(t, fetch(10), "the rule queries of the tenant exceeded the fetched data bytes limit (limit: 100 bytes per minute)")
+
+	// The limit is reset the next minute.
+	now = now.Add(time.Minute)
+	require.NoError(t, fetch(10))
+
+	// The size of the fetched data is tracked by the query stats.
+	qf := LimitedQueryFunc(func(ctx context.Context, _ string, _ time.Time) (promql.Vector, error) {
+		stats.FromContext(ctx).AddFetchedDataBytes(100)
+		return nil, nil
+	}, ruleLimits{maxQueryBytes: 100}, "user-1")
+	_, err := qf(context.Background(), "up", time.Now())
+	require.NoError(t, err)
+	_, err = qf(context.Background(), "up", time.Now())
+	assert.Error(t, err)
+}
+
+func TestLimitedQueryFunc_MaxSeriesAndAlertsPerRule(t *testing.T) {
+	expr, err := parser.ParseExpr("up")
+	require.NoError(t, err)
+	recordingRule := promRules.NewRecordingRule("job:up", expr, labels.EmptyLabels())
+	alertingRule := promRules.NewAlertingRule("UpAlert", expr, 0, 0, labels.EmptyLabels(), labels.EmptyLabels(), labels.EmptyLabels(), "", true, nil)
+
+	now := time.Now()
+	qf := LimitedQueryFunc(func(_ context.Context, qs string, _ time.Time) (promql.Vector, error) {
+		vector := promql.Vector{}
+		for i := 0; i < len(qs); i++ {
+			vector = append(vector, promql.Sample{Metric: labels.FromStrings("instance", string(rune('a'+i))), T: now.UnixMilli(), F: 1})
+		}
+		return vector, nil
+	}, ruleLimits{maxSeriesPerRule: 2, maxAlertsPerRule: 3, maxSeriesPerGroup: 5}, "user-1")
+
+	recordingCtx := promRules.NewOriginContext(context.Background(), promRules.NewRuleDetail(recordingRule))
+	alertingCtx := promRules.NewOriginContext(context.Background(), promRules.NewRuleDetail(alertingRule))
+
+	// The rules can return up to their limit of series.
+	_, err = qf(recordingCtx, "up", now)
+	require.NoError(t, err)
+	_, err = qf(alertingCtx, "up", now)
+	require.NoError(t, err)
+
+	// The rules exceeding their limit fail, whichever the kind of the rule.
+	_, err = qf(recordingCtx, "upup", now)
+	assert.EqualError(t, err, "the recording rule exceeded the series per rule limit (limit: 2 series, actual: 4 series)")
+	assert.ErrorAs(t, err, new(validation.LimitError))
+	_, err = qf(alertingCtx, "upup", now)
+	assert.EqualError(t, err, "the alerting rule exceeded the alerts per rule limit (limit: 3 alerts, actual: 4 alerts)")
+
+	// The queries not evaluated by a rule aren't limited.
+	vector, err := qf(context.Background(), "upupup", now)
+	require.NoError(t, err)
+	assert.Len(t, vector, 6)
+
+	// The rules of a rule group evaluation fail once the rule group exceeds its limit.
+	groupCtx := contextWithRuleGroupOutput(context.Background())
+	_, err = qf(promRules.NewOriginContext(groupCtx, promRules.NewRuleDetail(recordingRule)), "up", now)
+	require.NoError(t, err)
+	_, err = qf(promRules.NewOriginContext(groupCtx, promRules.NewRuleDetail(alertingRule)), "up", now)
+	require.NoError(t, err)
+	_, err = qf(promRules.NewOriginContext(groupCtx, promRules.NewRuleDetail(recordingRule)), "up", now)
+	assert.EqualError(t, err, "the rule group exceeded the series per rule group limit (limit: 5 series, actual: 6 series)")
+	_, err = qf(promRules.NewOriginContext(groupCtx, promRules.NewRuleDetail(recordingRule)), "u", now)
+	assert.Error(t, err)
+}
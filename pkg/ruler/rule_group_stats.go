@@ -0,0 +1,212 @@
+package ruler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/promql"
+	promRules "github.com/prometheus/prometheus/rules"
+
+	"github.com/cortexproject/cortex/pkg/querier/stats"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+// The reasons of the failed rule evaluations.
+const (
+	failureReasonLimit    = "limit"
+	failureReasonTimeout  = "timeout"
+	failureReasonCanceled = "canceled"
+	failureReasonStorage  = "storage"
+	failureReasonQuery    = "query"
+	failureReasonWrite    = "write"
+)
+
+// ruleQueryFailureReason returns the reason of the failure of a rule query.
+func ruleQueryFailureReason(err error) string {
+	var (
+		limitErr     validation.LimitError
+		timeoutErr   promql.ErrQueryTimeout
+		canceledErr  promql.ErrQueryCanceled
+		queryableErr QueryableError
+	)
+
+	switch {
+	case errors.As(err, &limitErr):
+		return failureReasonLimit
+	case errors.Is(err, context.DeadlineExceeded) || errors.As(err, &timeoutErr):
+		return failureReasonTimeout
+	case errors.Is(err, context.Canceled) || errors.As(err, &canceledErr):
+		return failureReasonCanceled
+	case errors.As(err, &queryableErr):
+		return failureReasonStorage
+	default:
+		return failureReasonQuery
+	}
+}
+
+type ruleGroupEvaluationContextKey struct{}
+
+// contextWithRuleGroupEvaluation returns a context holding the timestamp of a scheduled rule group
+// evaluation, to tell the statistics of its last evaluation apart.
+func contextWithRuleGroupEvaluation(ctx context.Context, ts time.Time) context.Context {
+	return context.WithValue(ctx, ruleGroupEvaluationContextKey{}, ts)
+}
+
+func ruleGroupEvaluationFromContext(ctx context.Context) (time.Time, bool) {
+	ts, ok := ctx.Value(ruleGroupEvaluationContextKey{}).(time.Time)
+	return ts, ok
+}
+
+type ruleGroupStatsEntry struct {
+	lastEvaluation time.Time
+	last           RuleGroupStatsDesc
+	total          RuleGroupStatsDesc
+}
+
+// ruleGroupsStats holds the query statistics of the rule groups of a tenant: the ones of their last
+// scheduled evaluation, and the ones of all their evaluations since they've been loaded.
+type ruleGroupsStats struct {
+	userID  string
+	metrics *RuleEvalMetrics
+
+	mtx    sync.Mutex
+	groups map[ruleGroupRef]*ruleGroupStatsEntry
+}
+
+func newRuleGroupsStats(userID string, metrics *RuleEvalMetrics) *ruleGroupsStats {
+	return &ruleGroupsStats{
+		userID:  userID,
+		metrics: metrics,
+		groups:  map[ruleGroupRef]*ruleGroupStatsEntry{},
+	}
+}
+
+// record adds the statistics of a rule evaluation, and the reason of its failure if any, to the
+// statistics of the rule group evaluated in the context.
+func (s *ruleGroupsStats) record(ctx context.Context, delta RuleGroupStatsDesc, failureReason string) {
+	ref, ok := ruleGroupRefFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	s.mtx.Lock()
+	entry, ok := s.groups[ref]
+	if !ok {
+		entry = &ruleGroupStatsEntry{}
+		s.groups[ref] = entry
+	}
+	// The evaluations catching up the missed ones only count towards the total statistics.
+	if ts, ok := ruleGroupEvaluationFromContext(ctx); ok {
+		if !ts.Equal(entry.lastEvaluation) {
+			entry.lastEvaluation = ts
+			entry.last = RuleGroupStatsDesc{}
+		}
+		addRuleGroupStats(&entry.last, delta, failureReason)
+	}
+	addRuleGroupStats(&entry.total, delta, failureReason)
+	s.mtx.Unlock()
+
+	group := promRules.GroupKey(ref.file, ref.name)
+	s.metrics.RuleGroupQuerySeconds.WithLabelValues(s.userID, group).Add(delta.QueryWallTime.Seconds())
+	s.metrics.RuleGroupFetchedSeries.WithLabelValues(s.userID, group).Add(float64(delta.FetchedSeries))
+	s.metrics.RuleGroupFetchedSamples.WithLabelValues(s.userID, group).Add(float64(delta.FetchedSamples))
+	s.metrics.RuleGroupFetchedBytes.WithLabelValues(s.userID, group).Add(float64(delta.FetchedBytes))
+	s.metrics.RuleGroupSeriesWritten.WithLabelValues(s.userID, group).Add(float64(delta.SeriesWritten))
+	if failureReason != "" {
+		s.metrics.RuleGroupEvaluationFailures.WithLabelValues(s.userID, group, failureReason).Inc()
+	}
+}
+
+func addRuleGroupStats(groupStats *RuleGroupStatsDesc, delta RuleGroupStatsDesc, failureReason string) {
+	groupStats.QueryWallTime += delta.QueryWallTime
+	groupStats.FetchedSeries += delta.FetchedSeries
+	groupStats.FetchedChunks += delta.FetchedChunks
+	groupStats.FetchedSamples += delta.FetchedSamples
+	groupStats.FetchedBytes += delta.FetchedBytes
+	groupStats.SeriesWritten += delta.SeriesWritten
+	if failureReason != "" {
+		if groupStats.Failures == nil {
+			groupStats.Failures = map[string]uint64{}
+		}
+		groupStats.Failures[failureReason]++
+	}
+}
+
+// get returns a copy of the statistics of the last evaluation, and of all the evaluations, of the rule group.
+func (s *ruleGroupsStats) get(ref ruleGroupRef) (last, total *RuleGroupStatsDesc) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	entry, ok := s.groups[ref]
+	if !ok {
+		return nil, nil
+	}
+	return copyRuleGroupStats(entry.last), copyRuleGroupStats(entry.total)
+}
+
+func copyRuleGroupStats(groupStats RuleGroupStatsDesc) *RuleGroupStatsDesc {
+	failures := make(map[string]uint64, len(groupStats.Failures))
+	for reason, count := range groupStats.Failures {
+		failures[reason] = count
+	}
+	groupStats.Failures = failures
+	return &groupStats
+}
+
+// retain drops the statistics, and the metrics, of the rule groups not loaded anymore.
+func (s *ruleGroupsStats) retain(groups map[ruleGroupRef]struct{}) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for ref := range s.groups {
+		if _, ok := groups[ref]; !ok {
+			delete(s.groups, ref)
+			s.metrics.deletePerRuleGroupMetrics(prometheus.Labels{"user": s.userID, "rule_group": promRules.GroupKey(ref.file, ref.name)})
+		}
+	}
+}
+
+// ruleGroupStatsRulesManager is implemented by the rules managers recording the query statistics of the
+// rule groups.
+type ruleGroupStatsRulesManager interface {
+	RetainRuleGroupStats(groups map[ruleGroupRef]struct{})
+	RuleGroupStats(ref ruleGroupRef) (last, total *RuleGroupStatsDesc)
+}
+
+// RuleGroupStatsQueryFunc returns a query function recording the query statistics of the rule groups:
+// the wall time of their queries, the size of the data they fetch, and the reasons of their failures.
+func RuleGroupStatsQueryFunc(qf promRules.QueryFunc, groupStats *ruleGroupsStats) promRules.QueryFunc {
+	if groupStats == nil {
+		return qf
+	}
+
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		// The data fetched by the query is tracked by the query stats, which may be shared with the other queries.
+		queryStats := stats.FromContext(ctx)
+		if queryStats == nil {
+			queryStats, ctx = stats.ContextWithEmptyStats(ctx)
+		}
+		fetchedSeries, fetchedChunks := queryStats.LoadFetchedSeries(), queryStats.LoadFetchedChunks()
+		fetchedSamples, fetchedBytes := queryStats.LoadFetchedSamples(), queryStats.LoadFetchedDataBytes()
+
+		start := time.Now()
+		vector, err := qf(ctx, qs, t)
+
+		var failureReason string
+		if err != nil {
+			failureReason = ruleQueryFailureReason(err)
+		}
+		groupStats.record(ctx, RuleGroupStatsDesc{
+			QueryWallTime:  time.Since(start),
+			FetchedSeries:  queryStats.LoadFetchedSeries() - fetchedSeries,
+			FetchedChunks:  queryStats.LoadFetchedChunks() - fetchedChunks,
+			FetchedSamples: queryStats.LoadFetchedSamples() - fetchedSamples,
+			FetchedBytes:   queryStats.LoadFetchedDataBytes() - fetchedBytes,
+		}, failureReason)
+
+		return vector, err
+	}
+}
@@ -0,0 +1,108 @@
+package ruler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/cortexpb"
+	"github.com/cortexproject/cortex/pkg/querier/stats"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+func TestRuleGroupStatsQueryFunc(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	groupStats := newRuleGroupsStats("user-1", NewRuleEvalMetrics(Config{RuleGroupStatsEnabled: true}, reg))
+
+	var queryErr error
+	qf := RuleGroupStatsQueryFunc(func(ctx context.Context, _ string, _ time.Time) (promql.Vector, error) {
+		queryStats := stats.FromContext(ctx)
+		queryStats.AddFetchedSeries(2)
+		queryStats.AddFetchedSamples(10)
+		queryStats.AddFetchedDataBytes(100)
+		return promql.Vector{{Metric: labels.FromStrings("foo", "bar")}}, queryErr
+	}, groupStats)
+
+	ref := ruleGroupRef{file: "ns", name: "group"}
+	groupCtx := promql.NewOriginContext(context.Background(), map[string]interface{}{
+		"ruleGroup": map[string]string{"file": ref.file, "name": ref.name},
+	})
+	evaluate := func(ctx context.Context, err error) {
+		queryErr = err
+		_, _ = qf(ctx, "up", time.Now())
+	}
+
+	// The statistics of the rule queries are recorded for the scheduled evaluation.
+	eval1 := contextWithRuleGroupEvaluation(groupCtx, time.Unix(60, 0))
+	evaluate(eval1, nil)
+	evaluate(eval1, validation.LimitError("limit exceeded"))
+
+	last, total := groupStats.get(ref)
+	assert.Equal(t, uint64(4), last.FetchedSeries)
+	assert.Equal(t, uint64(20), last.FetchedSamples)
+	assert.Equal(t, uint64(200), last.FetchedBytes)
+	assert.Equal(t, map[string]uint64{failureReasonLimit: 1}, last.Failures)
+	assert.Equal(t, last, total)
+
+	// The statistics of the last evaluation are reset by the next scheduled evaluation.
+	evaluate(contextWithRuleGroupEvaluation(groupCtx, time.Unix(120, 0)), QueryableError{err: errors.New("storage unavailable")})
+	last, total = groupStats.get(ref)
+	assert.Equal(t, uint64(10), last.FetchedSamples)
+	assert.Equal(t, map[string]uint64{failureReasonStorage: 1}, last.Failures)
+	assert.Equal(t, uint64(30), total.FetchedSamples)
+	assert.Equal(t, map[string]uint64{failureReasonLimit: 1, failureReasonStorage: 1}, total.Failures)
+
+	// The evaluations catching up the missed ones only count towards the total statistics, and the
+	// queries of the rules outside of the rule groups aren't recorded.
+	evaluate(groupCtx, context.DeadlineExceeded)
+	evaluate(context.Background(), nil)
+	last, total = groupStats.get(ref)
+	assert.Equal(t, uint64(10), last.FetchedSamples)
+	assert.Equal(t, uint64(40), total.FetchedSamples)
+	assert.Equal(t, map[string]uint64{failureReasonLimit: 1, failureReasonStorage: 1, failureReasonTimeout: 1}, total.Failures)
+
+	// The series written by the rules are recorded by the appender.
+	pusher := &fakePusher{response: &cortexpb.WriteResponse{}}
+	appendable := NewPusherAppendable(pusher, "user-1", ruleLimits{}, prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{}))
+	appendable.groupStats = groupStats
+	app := appendable.Appender(contextWithRuleGroupEvaluation(groupCtx, time.Unix(120, 0)))
+	for i := 0; i < 3; i++ {
+		_, err := app.Append(0, labels.FromStrings("series", fmt.Sprint(i)), 0, 1)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+	last, total = groupStats.get(ref)
+	assert.Equal(t, uint64(3), last.SeriesWritten)
+	assert.Equal(t, uint64(3), total.SeriesWritten)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_ruler_rule_group_evaluation_failures_total Total number of failed rule evaluations of the rule group, by reason.
+		# TYPE cortex_ruler_rule_group_evaluation_failures_total counter
+		cortex_ruler_rule_group_evaluation_failures_total{reason="limit",rule_group="ns;group",user="user-1"} 1
+		cortex_ruler_rule_group_evaluation_failures_total{reason="storage",rule_group="ns;group",user="user-1"} 1
+		cortex_ruler_rule_group_evaluation_failures_total{reason="timeout",rule_group="ns;group",user="user-1"} 1
+		# HELP cortex_ruler_rule_group_fetched_samples_total Total number of samples fetched by the queries of the rule group.
+		# TYPE cortex_ruler_rule_group_fetched_samples_total counter
+		cortex_ruler_rule_group_fetched_samples_total{rule_group="ns;group",user="user-1"} 40
+		# HELP cortex_ruler_rule_group_series_written_total Total number of series written by the rules of the rule group.
+		# TYPE cortex_ruler_rule_group_series_written_total counter
+		cortex_ruler_rule_group_series_written_total{rule_group="ns;group",user="user-1"} 3
+	`), "cortex_ruler_rule_group_evaluation_failures_total", "cortex_ruler_rule_group_fetched_samples_total", "cortex_ruler_rule_group_series_written_total"))
+
+	// The statistics and the metrics of the rule groups not loaded anymore are dropped.
+	groupStats.retain(map[ruleGroupRef]struct{}{})
+	last, total = groupStats.get(ref)
+	assert.Nil(t, last)
+	assert.Nil(t, total)
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(""), "cortex_ruler_rule_group_evaluation_failures_total", "cortex_ruler_rule_group_fetched_samples_total", "cortex_ruler_rule_group_series_written_total"))
+}
@@ -0,0 +1,570 @@
+package ruler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	promRules "github.com/prometheus/prometheus/rules"
+	"gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+	util_api "github.com/cortexproject/cortex/pkg/util/api"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+)
+
+const (
+	// maxRuleTestRequestBytes is the max size of the test files and rule files of a rule unit tests request.
+	maxRuleTestRequestBytes = 10 << 20
+
+	ruleTestFileFormField = "test_file"
+	ruleFileFormField     = "rule_file"
+)
+
+// unitTestFile is a promtool rule unit tests file.
+type unitTestFile struct {
+	RuleFiles          []string        `yaml:"rule_files"`
+	EvaluationInterval model.Duration  `yaml:"evaluation_interval,omitempty"`
+	GroupEvalOrder     []string        `yaml:"group_eval_order"`
+	Tests              []unitTestGroup `yaml:"tests"`
+}
+
+// unitTestGroup is a group of tests of a promtool rule unit tests file, sharing the same input series.
+type unitTestGroup struct {
+	Interval        model.Duration       `yaml:"interval"`
+	InputSeries     []unitTestSeries     `yaml:"input_series"`
+	AlertRuleTests  []unitTestAlertCase  `yaml:"alert_rule_test,omitempty"`
+	PromqlExprTests []unitTestPromQLCase `yaml:"promql_expr_test,omitempty"`
+	ExternalLabels  labels.Labels        `yaml:"external_labels,omitempty"`
+	ExternalURL     string               `yaml:"external_url,omitempty"`
+	TestGroupName   string               `yaml:"name,omitempty"`
+}
+
+type unitTestSeries struct {
+	Series string `yaml:"series"`
+	Values string `yaml:"values"`
+}
+
+type unitTestAlertCase struct {
+	EvalTime  model.Duration  `yaml:"eval_time"`
+	Alertname string          `yaml:"alertname"`
+	ExpAlerts []unitTestAlert `yaml:"exp_alerts"`
+}
+
+type unitTestAlert struct {
+	ExpLabels      map[string]string `yaml:"exp_labels"`
+	ExpAnnotations map[string]string `yaml:"exp_annotations"`
+}
+
+type unitTestPromQLCase struct {
+	Expr       string           `yaml:"expr"`
+	EvalTime   model.Duration   `yaml:"eval_time"`
+	ExpSamples []unitTestSample `yaml:"exp_samples"`
+}
+
+type unitTestSample struct {
+	Labels    string  `yaml:"labels"`
+	Value     float64 `yaml:"value"`
+	Histogram string  `yaml:"histogram"`
+}
+
+// RuleTestGroupResult is the result of a group of tests of a rule unit tests file.
+type RuleTestGroupResult struct {
+	Name   string   `json:"name"`
+	Passed bool     `json:"passed"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// RuleTestFileResult is the result of the tests of a rule unit tests file. The errors are the ones
+// preventing the tests from running.
+type RuleTestFileResult struct {
+	File   string                 `json:"file"`
+	Passed bool                   `json:"passed"`
+	Errors []string               `json:"errors,omitempty"`
+	Tests  []*RuleTestGroupResult `json:"tests,omitempty"`
+}
+
+// RuleTestResult is the result of the rule unit tests.
+type RuleTestResult struct {
+	Passed bool                  `json:"passed"`
+	Files  []*RuleTestFileResult `json:"files"`
+}
+
+// TestRules runs the rule unit tests of the promtool "test rules" files in the "test_file" fields of the
+// multipart request body, against the rule files in its "rule_file" fields. The rule files listed by the
+// test files are matched against the names of the uploaded rule files, ignoring their directory. The
+// rules and the PromQL expressions of the tests are evaluated by the ruler query engine, within the rule
+// query limits of the tenant.
+func (b *Backtester) TestRules(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), b.logger)
+	userID, err := tenant.TenantID(req.Context())
+	if err != nil || userID == "" {
+		level.Error(logger).Log("msg", "error extracting org id from context", "err", err)
+		util_api.RespondError(logger, w, v1.ErrBadData, "no valid org id found", http.StatusBadRequest)
+		return
+	}
+
+	req.Body = http.MaxBytesReader(w, req.Body, maxRuleTestRequestBytes)
+	if err := req.ParseMultipartForm(maxRuleTestRequestBytes); err != nil {
+		util_api.RespondError(logger, w, v1.ErrBadData, fmt.Sprintf("invalid multipart request: %s", err), http.StatusBadRequest)
+		return
+	}
+	defer req.MultipartForm.RemoveAll() //nolint:errcheck
+
+	testFiles, err := readRuleTestFormFiles(req.MultipartForm.File[ruleTestFileFormField])
+	if err == nil && len(testFiles) == 0 {
+		err = errors.New("no rule unit tests file in the request")
+	}
+	if err != nil {
+		util_api.RespondError(logger, w, v1.ErrBadData, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ruleFiles, err := readRuleTestFormFiles(req.MultipartForm.File[ruleFileFormField])
+	if err != nil {
+		util_api.RespondError(logger, w, v1.ErrBadData, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dir, err := os.MkdirTemp("", "ruler-unit-tests-")
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to create the rule files directory", "err", err)
+		util_api.RespondError(logger, w, v1.ErrServer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	for name, content := range ruleFiles {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0600); err != nil {
+			level.Error(logger).Log("msg", "unable to write the rule file", "file", name, "err", err)
+			util_api.RespondError(logger, w, v1.ErrServer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	result := &RuleTestResult{Passed: true, Files: make([]*RuleTestFileResult, 0, len(testFiles))}
+	names := make([]string, 0, len(testFiles))
+	for name := range testFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fileResult := b.runRuleTestFile(req.Context(), userID, name, testFiles[name], dir)
+		result.Passed = result.Passed && fileResult.Passed
+		result.Files = append(result.Files, fileResult)
+	}
+
+	data, err := json.Marshal(&util_api.Response{
+		Status: "success",
+		Data:   result,
+	})
+	if err != nil {
+		level.Error(logger).Log("msg", "error marshaling json response", "err", err)
+		util_api.RespondError(logger, w, v1.ErrServer, "unable to marshal the requested data", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if n, err := w.Write(data); err != nil {
+		level.Error(logger).Log("msg", "error writing response", "bytesWritten", n, "err", err)
+	}
+}
+
+// readRuleTestFormFiles reads the uploaded files, by their name without their directory.
+func readRuleTestFormFiles(headers []*multipart.FileHeader) (map[string][]byte, error) {
+	files := make(map[string][]byte, len(headers))
+	for _, h := range headers {
+		name := filepath.Base(h.Filename)
+		if name == "." || name == ".." || name == string(filepath.Separator) {
+			return nil, fmt.Errorf("invalid file name %q", h.Filename)
+		}
+		if _, ok := files[name]; ok {
+			return nil, fmt.Errorf("duplicate file name %q", name)
+		}
+
+		f, err := h.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, err
+		}
+		files[name] = content
+	}
+	return files, nil
+}
+
+// runRuleTestFile runs the tests of a rule unit tests file, with the rule files written to the directory.
+func (b *Backtester) runRuleTestFile(ctx context.Context, userID, name string, content []byte, dir string) *RuleTestFileResult {
+	result := &RuleTestFileResult{File: name}
+	fail := func(err error) *RuleTestFileResult {
+		// The rule files are referred to by their name, regardless of the directory they're written to.
+		result.Errors = append(result.Errors, strings.ReplaceAll(err.Error(), dir+string(filepath.Separator), ""))
+		return result
+	}
+
+	file := unitTestFile{}
+	decoder := yaml.NewDecoder(strings.NewReader(string(content)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && err != io.EOF {
+		return fail(err)
+	}
+	if file.EvaluationInterval == 0 {
+		file.EvaluationInterval = model.Duration(time.Minute)
+	}
+
+	ruleFiles, err := resolveRuleTestRuleFiles(file.RuleFiles, dir)
+	if err != nil {
+		return fail(err)
+	}
+
+	groupOrder := make(map[string]int, len(file.GroupEvalOrder))
+	for i, g := range file.GroupEvalOrder {
+		if _, ok := groupOrder[g]; ok {
+			return fail(fmt.Errorf("group name repeated in evaluation order: %s", g))
+		}
+		groupOrder[g] = i
+	}
+
+	result.Passed = true
+	for i, tg := range file.Tests {
+		if tg.Interval == 0 {
+			tg.Interval = file.EvaluationInterval
+		}
+		groupResult := &RuleTestGroupResult{Name: tg.TestGroupName}
+		if groupResult.Name == "" {
+			groupResult.Name = fmt.Sprintf("test group #%d", i+1)
+		}
+
+		for _, err := range b.runRuleTestGroup(ctx, userID, tg, time.Duration(file.EvaluationInterval), groupOrder, ruleFiles) {
+			groupResult.Errors = append(groupResult.Errors, strings.ReplaceAll(err.Error(), dir+string(filepath.Separator), ""))
+		}
+		groupResult.Passed = len(groupResult.Errors) == 0
+		result.Passed = result.Passed && groupResult.Passed
+		result.Tests = append(result.Tests, groupResult)
+	}
+	return result
+}
+
+// resolveRuleTestRuleFiles returns the paths of the rule files written to the directory matching the rule
+// files of a rule unit tests file, which can be glob patterns.
+func resolveRuleTestRuleFiles(patterns []string, dir string) ([]string, error) {
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(dir, filepath.Base(pattern)))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("the rule file %q is missing from the request", pattern)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// runRuleTestGroup runs a group of tests of a rule unit tests file, like promtool: the input series are
+// loaded, and the rule groups evaluated in their evaluation order, at each evaluation interval until the
+// last evaluation time of the tests. The alerts are checked at their evaluation time, and the PromQL
+// expressions once all the rule groups have been evaluated.
+func (b *Backtester) runRuleTestGroup(ctx context.Context, userID string, tg unitTestGroup, evalInterval time.Duration, groupOrder map[string]int, ruleFiles []string) []error {
+	mint := time.Unix(0, 0).UTC()
+	maxt := mint.Add(tg.maxEvalTime())
+	if maxt.Sub(mint)/evalInterval >= maxBacktestSteps {
+		return []error{fmt.Errorf("exceeded maximum number of evaluations of %d, try decreasing the evaluation time of the tests or increasing the evaluation interval", maxBacktestSteps)}
+	}
+
+	loadCmd := fmt.Sprintf("load %v\n", time.Duration(tg.Interval))
+	for _, s := range tg.InputSeries {
+		loadCmd += fmt.Sprintf("  %v %v\n", s.Series, s.Values)
+	}
+	suite, err := promql.NewLazyLoader(loadCmd, promql.LazyLoaderOpts{EnableAtModifier: true, EnableNegativeOffset: true})
+	if err != nil {
+		return []error{err}
+	}
+	defer suite.Close() //nolint:errcheck
+
+	// The rules are evaluated by the ruler query engine, over the input series of the test group.
+	query := LimitedQueryFunc(promRules.EngineQueryFunc(b.engine, suite.Storage()), b.limits, userID)
+	manager := promRules.NewManager(&promRules.ManagerOptions{
+		QueryFunc:  query,
+		Appendable: suite.Storage(),
+		Queryable:  suite.Storage(),
+		Context:    ctx,
+		NotifyFunc: func(context.Context, string, ...*promRules.Alert) {},
+		Logger:     log.NewNopLogger(),
+	})
+	groupsMap, errs := manager.LoadGroups(time.Duration(tg.Interval), tg.ExternalLabels, tg.ExternalURL, nil, ruleFiles...)
+	if len(errs) > 0 {
+		return errs
+	}
+	groups := orderedRuleTestGroups(groupsMap, groupOrder)
+
+	// The alerting rules are marked as restored, so that the ALERTS series are written when they run.
+	for _, g := range groups {
+		for _, r := range g.Rules() {
+			if alertingRule, ok := r.(*promRules.AlertingRule); ok {
+				alertingRule.SetRestored(true)
+			}
+		}
+	}
+
+	// The alert tests by evaluation time, and the alert names tested at each evaluation time.
+	alertTests := map[model.Duration][]unitTestAlertCase{}
+	alertNames := map[model.Duration]map[string]struct{}{}
+	for _, alert := range tg.AlertRuleTests {
+		if alertNames[alert.EvalTime] == nil {
+			alertNames[alert.EvalTime] = map[string]struct{}{}
+		}
+		alertNames[alert.EvalTime][alert.Alertname] = struct{}{}
+		alertTests[alert.EvalTime] = append(alertTests[alert.EvalTime], alert)
+	}
+	alertEvalTimes := make([]model.Duration, 0, len(alertTests))
+	for t := range alertTests {
+		alertEvalTimes = append(alertEvalTimes, t)
+	}
+	sort.Slice(alertEvalTimes, func(i, j int) bool { return alertEvalTimes[i] < alertEvalTimes[j] })
+
+	curr := 0
+	errs = nil
+	for ts := mint; !ts.After(maxt); ts = ts.Add(evalInterval) {
+		var evalErrs []error
+		suite.WithSamplesTill(ts, func(err error) {
+			if err != nil {
+				evalErrs = append(evalErrs, err)
+				return
+			}
+			for _, g := range groups {
+				g.Eval(contextWithRuleGroupOutput(ctx), ts)
+				for _, r := range g.Rules() {
+					if r.LastError() != nil {
+						evalErrs = append(evalErrs, fmt.Errorf("rule: %s, time: %s, err: %v", r.Name(), ts.Sub(mint), r.LastError()))
+					}
+				}
+			}
+		})
+		// The tests stop at the first evaluation failure, rather than at the first test failure.
+		if len(evalErrs) > 0 {
+			return append(errs, evalErrs...)
+		}
+
+		// The alerts tested at the evaluation times in [ts, ts+evalInterval) are checked against this evaluation.
+		for ; curr < len(alertEvalTimes) && time.Duration(alertEvalTimes[curr]) < ts.Add(evalInterval).Sub(mint); curr++ {
+			t := alertEvalTimes[curr]
+			got := firingRuleTestAlerts(groups, alertNames[t])
+			for _, testCase := range alertTests[t] {
+				exp := make([]unitTestAlertLabels, 0, len(testCase.ExpAlerts))
+				for _, a := range testCase.ExpAlerts {
+					// The alert name label is added to the alerts by their alerting rule.
+					lbls := labels.NewBuilder(labels.FromMap(a.ExpLabels)).Set(labels.AlertName, testCase.Alertname).Labels()
+					exp = append(exp, unitTestAlertLabels{labels: lbls, annotations: labels.FromMap(a.ExpAnnotations)})
+				}
+				if !equalRuleTestAlerts(exp, got[testCase.Alertname]) {
+					errs = append(errs, fmt.Errorf("alertname: %s, time: %s, \n    exp: %v, \n    got: %v", testCase.Alertname, testCase.EvalTime, ruleTestAlertsString(exp), ruleTestAlertsString(got[testCase.Alertname])))
+				}
+			}
+		}
+	}
+
+	for _, testCase := range tg.PromqlExprTests {
+		if err := b.checkRuleTestPromQLCase(ctx, suite, testCase, mint); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// maxEvalTime returns the last evaluation time of the tests of the test group.
+func (tg *unitTestGroup) maxEvalTime() time.Duration {
+	var maxd model.Duration
+	for _, alert := range tg.AlertRuleTests {
+		if alert.EvalTime > maxd {
+			maxd = alert.EvalTime
+		}
+	}
+	for _, expr := range tg.PromqlExprTests {
+		if expr.EvalTime > maxd {
+			maxd = expr.EvalTime
+		}
+	}
+	return time.Duration(maxd)
+}
+
+// orderedRuleTestGroups returns the rule groups in their evaluation order. The rule groups missing from the
+// evaluation order are evaluated first, by file and name.
+func orderedRuleTestGroups(groupsMap map[string]*promRules.Group, groupOrder map[string]int) []*promRules.Group {
+	groups := make([]*promRules.Group, 0, len(groupsMap))
+	for _, g := range groupsMap {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].File() != groups[j].File() {
+			return groups[i].File() < groups[j].File()
+		}
+		return groups[i].Name() < groups[j].Name()
+	})
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groupOrder[groups[i].Name()] < groupOrder[groups[j].Name()]
+	})
+	return groups
+}
+
+type unitTestAlertLabels struct {
+	labels      labels.Labels
+	annotations labels.Labels
+}
+
+// firingRuleTestAlerts returns the firing alerts of the alerting rules with the given names, by alert name.
+// The same alert name can be used by several alerting rules.
+func firingRuleTestAlerts(groups []*promRules.Group, names map[string]struct{}) map[string][]unitTestAlertLabels {
+	got := map[string][]unitTestAlertLabels{}
+	for _, g := range groups {
+		for _, r := range g.AlertingRules() {
+			if _, ok := names[r.Name()]; !ok {
+				continue
+			}
+			for _, a := range r.ActiveAlerts() {
+				if a.State == promRules.StateFiring {
+					got[r.Name()] = append(got[r.Name()], unitTestAlertLabels{labels: a.Labels.Copy(), annotations: a.Annotations.Copy()})
+				}
+			}
+		}
+	}
+	return got
+}
+
+// equalRuleTestAlerts returns whether the expected and the firing alerts are the same, regardless of their order.
+func equalRuleTestAlerts(exp, got []unitTestAlertLabels) bool {
+	if len(exp) != len(got) {
+		return false
+	}
+	sortRuleTestAlerts(exp)
+	sortRuleTestAlerts(got)
+	for i := range exp {
+		if !labels.Equal(exp[i].labels, got[i].labels) || !labels.Equal(exp[i].annotations, got[i].annotations) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortRuleTestAlerts(alerts []unitTestAlertLabels) {
+	sort.Slice(alerts, func(i, j int) bool {
+		if c := labels.Compare(alerts[i].labels, alerts[j].labels); c != 0 {
+			return c < 0
+		}
+		return labels.Compare(alerts[i].annotations, alerts[j].annotations) < 0
+	})
+}
+
+func ruleTestAlertsString(alerts []unitTestAlertLabels) string {
+	s := make([]string, 0, len(alerts))
+	for i, a := range alerts {
+		s = append(s, fmt.Sprintf("%d:\n      Labels:%s\n      Annotations:%s\n", i, a.labels, a.annotations))
+	}
+	return "[\n" + strings.Join(s, "") + "    ]"
+}
+
+type unitTestParsedSample struct {
+	labels    labels.Labels
+	value     float64
+	histogram string
+}
+
+func (s unitTestParsedSample) String() string {
+	if s.histogram != "" {
+		return s.labels.String() + " " + s.histogram
+	}
+	return s.labels.String() + " " + fmt.Sprint(s.value)
+}
+
+// checkRuleTestPromQLCase returns an error if the result of the PromQL expression of the test case isn't
+// the expected one.
+func (b *Backtester) checkRuleTestPromQLCase(ctx context.Context, suite *promql.LazyLoader, testCase unitTestPromQLCase, mint time.Time) error {
+	fail := func(err error) error {
+		return fmt.Errorf("expr: %q, time: %s, err: %w", testCase.Expr, testCase.EvalTime, err)
+	}
+
+	q, err := b.engine.NewInstantQuery(ctx, suite.Queryable(), nil, testCase.Expr, mint.Add(time.Duration(testCase.EvalTime)))
+	if err != nil {
+		return fail(err)
+	}
+	defer q.Close()
+	res := q.Exec(ctx)
+	if res.Err != nil {
+		return fail(res.Err)
+	}
+
+	var got []unitTestParsedSample
+	switch v := res.Value.(type) {
+	case promql.Vector:
+		for _, s := range v {
+			sample := unitTestParsedSample{labels: s.Metric.Copy(), value: s.F}
+			if s.H != nil {
+				sample = unitTestParsedSample{labels: s.Metric.Copy(), histogram: promql.HistogramTestExpression(s.H)}
+			}
+			got = append(got, sample)
+		}
+	case promql.Scalar:
+		got = append(got, unitTestParsedSample{labels: labels.EmptyLabels(), value: v.V})
+	default:
+		return fail(errors.New("rule result is not a vector or scalar"))
+	}
+
+	exp := make([]unitTestParsedSample, 0, len(testCase.ExpSamples))
+	for _, s := range testCase.ExpSamples {
+		lbls, err := parser.ParseMetric(s.Labels)
+		if err != nil {
+			return fail(fmt.Errorf("labels %q: %w", s.Labels, err))
+		}
+		sample := unitTestParsedSample{labels: lbls, value: s.Value}
+		if s.Histogram != "" {
+			_, values, err := parser.ParseSeriesDesc("{} " + s.Histogram)
+			if err != nil || len(values) != 1 || values[0].Histogram == nil {
+				return fail(fmt.Errorf("histogram %q: invalid histogram", s.Histogram))
+			}
+			sample = unitTestParsedSample{labels: lbls, histogram: promql.HistogramTestExpression(values[0].Histogram)}
+		}
+		exp = append(exp, sample)
+	}
+
+	sort.Slice(exp, func(i, j int) bool { return labels.Compare(exp[i].labels, exp[j].labels) < 0 })
+	sort.Slice(got, func(i, j int) bool { return labels.Compare(got[i].labels, got[j].labels) < 0 })
+	if !equalRuleTestSamples(exp, got) {
+		return fmt.Errorf("expr: %q, time: %s,\n    exp: %v\n    got: %v", testCase.Expr, testCase.EvalTime, exp, got)
+	}
+	return nil
+}
+
+func equalRuleTestSamples(exp, got []unitTestParsedSample) bool {
+	if len(exp) != len(got) {
+		return false
+	}
+	for i := range exp {
+		if !labels.Equal(exp[i].labels, got[i].labels) || exp[i].histogram != got[i].histogram {
+			return false
+		}
+		if exp[i].value != got[i].value && !(math.IsNaN(exp[i].value) && math.IsNaN(got[i].value)) {
+			return false
+		}
+	}
+	return true
+}
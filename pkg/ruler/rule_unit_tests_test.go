@@ -0,0 +1,151 @@
+package ruler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+)
+
+const ruleUnitTestsRuleFile = `
+groups:
+- name: example
+  rules:
+  - record: job:http_requests:rate5m
+    expr: sum by (job) (rate(http_requests_total[5m]))
+  - alert: InstanceDown
+    expr: up == 0
+    for: 5m
+    labels:
+      severity: page
+    annotations:
+      summary: "Instance {{ $labels.instance }} down"
+`
+
+const ruleUnitTestsFile = `
+rule_files:
+  - rules/alerts.yml
+evaluation_interval: 1m
+tests:
+  - name: passing
+    interval: 1m
+    input_series:
+      - series: 'up{job="prometheus", instance="localhost:9090"}'
+        values: '0 0 0 0 0 0 0 0 0 0 0 0 0 0 0'
+      - series: 'http_requests_total{job="api"}'
+        values: '0+60x15'
+    alert_rule_test:
+      - eval_time: 2m
+        alertname: InstanceDown
+        exp_alerts: []
+      - eval_time: 10m
+        alertname: InstanceDown
+        exp_alerts:
+          - exp_labels:
+              severity: page
+              instance: localhost:9090
+              job: prometheus
+            exp_annotations:
+              summary: "Instance localhost:9090 down"
+    promql_expr_test:
+      - expr: job:http_requests:rate5m
+        eval_time: 10m
+        exp_samples:
+          - labels: 'job:http_requests:rate5m{job="api"}'
+            value: 1
+  - name: failing
+    input_series:
+      - series: 'up{job="prometheus", instance="localhost:9090"}'
+        values: '1x15'
+    alert_rule_test:
+      - eval_time: 10m
+        alertname: InstanceDown
+        exp_alerts:
+          - exp_labels:
+              severity: page
+              instance: localhost:9090
+              job: prometheus
+`
+
+func TestBacktester_TestRules(t *testing.T) {
+	engine := promql.NewEngine(promql.EngineOpts{MaxSamples: 1e6, Timeout: time.Minute})
+	b := NewBacktester(Config{EvaluationInterval: time.Minute}, nil, engine, ruleLimits{}, log.NewNopLogger())
+
+	newRequest := func(t *testing.T, files map[string]map[string]string) *http.Request {
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		for field, contents := range files {
+			for name, content := range contents {
+				fw, err := mw.CreateFormFile(field, name)
+				require.NoError(t, err)
+				_, err = fw.Write([]byte(content))
+				require.NoError(t, err)
+			}
+		}
+		require.NoError(t, mw.Close())
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/rules/test", body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		return req.WithContext(user.InjectOrgID(context.Background(), "user-1"))
+	}
+
+	t.Run("runs the tests of the test files", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		b.TestRules(w, newRequest(t, map[string]map[string]string{
+			ruleTestFileFormField: {"tests.yml": ruleUnitTestsFile},
+			ruleFileFormField:     {"alerts.yml": ruleUnitTestsRuleFile},
+		}))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := struct {
+			Data RuleTestResult `json:"data"`
+		}{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.False(t, resp.Data.Passed)
+		require.Len(t, resp.Data.Files, 1)
+
+		file := resp.Data.Files[0]
+		assert.Equal(t, "tests.yml", file.File)
+		assert.Empty(t, file.Errors)
+		require.Len(t, file.Tests, 2)
+		assert.Equal(t, &RuleTestGroupResult{Name: "passing", Passed: true}, file.Tests[0])
+		assert.Equal(t, "failing", file.Tests[1].Name)
+		assert.False(t, file.Tests[1].Passed)
+		require.Len(t, file.Tests[1].Errors, 1)
+		assert.Contains(t, file.Tests[1].Errors[0], "alertname: InstanceDown, time: 10m")
+	})
+
+	t.Run("reports the rule files missing from the request", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		b.TestRules(w, newRequest(t, map[string]map[string]string{
+			ruleTestFileFormField: {"tests.yml": ruleUnitTestsFile},
+		}))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := struct {
+			Data RuleTestResult `json:"data"`
+		}{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.False(t, resp.Data.Passed)
+		require.Len(t, resp.Data.Files, 1)
+		assert.Equal(t, []string{`the rule file "rules/alerts.yml" is missing from the request`}, resp.Data.Files[0].Errors)
+	})
+
+	t.Run("rejects the requests without test files", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		b.TestRules(w, newRequest(t, map[string]map[string]string{
+			ruleFileFormField: {"alerts.yml": ruleUnitTestsRuleFile},
+		}))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -25,6 +25,7 @@ import (
 	promRules "github.com/prometheus/prometheus/rules"
 	"github.com/prometheus/prometheus/util/strutil"
 	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/cortexproject/cortex/pkg/cortexpb"
@@ -50,19 +51,26 @@ var (
 	errInvalidShardingStrategy   = errors.New("invalid sharding strategy")
 	errInvalidTenantShardSize    = errors.New("invalid tenant shard size, the value must be greater than 0")
 	errInvalidMaxConcurrentEvals = errors.New("invalid max concurrent evals, the value must be greater than 0")
+	errInvalidFrontendAddress    = errors.New("invalid ruler frontend address, the value must be an HTTP URL")
+	errInvalidCostBasedSharding  = errors.New("cost-based sharding is only supported by the default sharding strategy, with a rebalance interval greater than 0")
+	errInvalidAlertStateInterval = errors.New("invalid alert state persist interval, the value must be greater than 0")
+	errInvalidMaxRulesVersions   = errors.New("invalid max rules versions, the value must be greater than or equal to 0")
+	errInvalidEvaluationCatchUp  = errors.New("invalid evaluation catch-up config, the max lookback and the persist interval must be greater than 0")
 )
 
 const (
-	// ringKey is the key under which we store the rulers ring in the KVStore.
-	ringKey = "ring"
+	// RingKey is the key under which we store the rulers ring in the KVStore.
+	RingKey = "ring"
 
 	// Number of concurrent group list and group loads operations.
 	loadRulesConcurrency  = 10
 	fetchRulesConcurrency = 16
 
-	rulerSyncReasonInitial    = "initial"
-	rulerSyncReasonPeriodic   = "periodic"
-	rulerSyncReasonRingChange = "ring-change"
+	rulerSyncReasonInitial      = "initial"
+	rulerSyncReasonPeriodic     = "periodic"
+	rulerSyncReasonRingChange   = "ring-change"
+	rulerSyncReasonRebalance    = "rebalance"
+	rulerSyncReasonNotification = "notification"
 
 	// Limit errors
 	errMaxRuleGroupsPerUserLimitExceeded        = "per-user rule groups limit (limit: %d actual: %d) exceeded"
@@ -95,6 +103,8 @@ type Config struct {
 	EvaluationInterval time.Duration `yaml:"evaluation_interval"`
 	// How frequently to poll for updated rules.
 	PollInterval time.Duration `yaml:"poll_interval"`
+	// Whether to sync the rules on the object storage notifications.
+	SyncNotificationsEnabled bool `yaml:"sync_notifications_enabled"`
 	// Path to store rule files for prom manager.
 	RulePath string `yaml:"rule_path"`
 
@@ -130,8 +140,14 @@ type Config struct {
 	Ring             RingConfig    `yaml:"ring"`
 	FlushCheckPeriod time.Duration `yaml:"flush_period"`
 
+	// Leases of the rule groups evaluations.
+	EvaluationLeases EvaluationLeasesConfig `yaml:"evaluation_leases"`
+
 	EnableAPI           bool `yaml:"enable_api"`
 	APIDeduplicateRules bool `yaml:"api_deduplicate_rules"`
+	EnableBacktestAPI   bool `yaml:"enable_backtest_api"`
+	EnableDryRunAPI     bool `yaml:"enable_dry_run_api"`
+	EnableRuleTestAPI   bool `yaml:"enable_rule_test_api"`
 
 	EnabledTenants  flagext.StringSliceCSV `yaml:"enabled_tenants"`
 	DisabledTenants flagext.StringSliceCSV `yaml:"disabled_tenants"`
@@ -143,6 +159,31 @@ type Config struct {
 
 	EnableQueryStats      bool `yaml:"query_stats_enabled"`
 	DisableRuleGroupLabel bool `yaml:"disable_rule_group_label"`
+	RuleGroupStatsEnabled bool `yaml:"rule_group_stats_enabled"`
+
+	// Evaluate the rule queries through the query-frontend.
+	FrontendAddress string        `yaml:"frontend_address"`
+	FrontendTimeout time.Duration `yaml:"frontend_timeout"`
+
+	// Shard the rule groups by their evaluation cost.
+	CostBasedShardingEnabled           bool          `yaml:"cost_based_sharding_enabled"`
+	CostBasedShardingRebalanceInterval time.Duration `yaml:"cost_based_sharding_rebalance_interval"`
+
+	// Persist the state of the alerts to the ruler storage.
+	AlertStatePersistenceEnabled bool          `yaml:"alert_state_persistence_enabled"`
+	AlertStatePersistInterval    time.Duration `yaml:"alert_state_persist_interval"`
+
+	// Catch up the evaluations of the recording rules missed while the rule groups weren't loaded.
+	EvaluationCatchUpEnabled         bool          `yaml:"evaluation_catch_up_enabled"`
+	EvaluationCatchUpMaxLookback     time.Duration `yaml:"evaluation_catch_up_max_lookback"`
+	EvaluationCatchUpPersistInterval time.Duration `yaml:"evaluation_catch_up_persist_interval"`
+
+	// Keep the history of the rule namespaces changed through the ruler API.
+	RulesVersioningEnabled bool `yaml:"rules_versioning_enabled"`
+	MaxRulesVersions       int  `yaml:"max_rules_versions"`
+
+	// Evaluate the rules depending on the series recorded by other rules after them.
+	RuleDependencyOrderingEnabled bool `yaml:"rule_dependency_ordering_enabled"`
 }
 
 // Validate config and returns error on failure
@@ -162,6 +203,32 @@ func (cfg *Config) Validate(limits validation.Limits, log log.Logger) error {
 	if cfg.ConcurrentEvalsEnabled && cfg.MaxConcurrentEvals <= 0 {
 		return errInvalidMaxConcurrentEvals
 	}
+
+	if cfg.FrontendAddress != "" {
+		if u, err := url.Parse(cfg.FrontendAddress); err != nil || u.Scheme == "" || u.Host == "" {
+			return errInvalidFrontendAddress
+		}
+	}
+
+	if cfg.CostBasedShardingEnabled && (cfg.ShardingStrategy != util.ShardingStrategyDefault || cfg.CostBasedShardingRebalanceInterval <= 0) {
+		return errInvalidCostBasedSharding
+	}
+
+	if cfg.AlertStatePersistenceEnabled && cfg.AlertStatePersistInterval <= 0 {
+		return errInvalidAlertStateInterval
+	}
+
+	if cfg.EvaluationCatchUpEnabled && (cfg.EvaluationCatchUpMaxLookback <= 0 || cfg.EvaluationCatchUpPersistInterval <= 0) {
+		return errInvalidEvaluationCatchUp
+	}
+
+	if cfg.RulesVersioningEnabled && cfg.MaxRulesVersions < 0 {
+		return errInvalidMaxRulesVersions
+	}
+
+	if err := cfg.EvaluationLeases.Validate(); err != nil {
+		return errors.Wrap(err, "invalid ruler evaluation leases config")
+	}
 	return nil
 }
 
@@ -169,6 +236,7 @@ func (cfg *Config) Validate(limits validation.Limits, log log.Logger) error {
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.ClientTLSConfig.RegisterFlagsWithPrefix("ruler.client", f)
 	cfg.Ring.RegisterFlags(f)
+	cfg.EvaluationLeases.RegisterFlags(f)
 	cfg.Notifier.RegisterFlags(f)
 
 	// Deprecated Flags that will be maintained to avoid user disruption
@@ -186,6 +254,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.Var(&cfg.ExternalURL, "ruler.external.url", "URL of alerts return path.")
 	f.DurationVar(&cfg.EvaluationInterval, "ruler.evaluation-interval", 1*time.Minute, "How frequently to evaluate rules")
 	f.DurationVar(&cfg.PollInterval, "ruler.poll-interval", 1*time.Minute, "How frequently to poll for rule changes")
+	f.BoolVar(&cfg.SyncNotificationsEnabled, "ruler.sync-notifications-enabled", false, "[Experimental] Enable the endpoint receiving the notifications of the changes to the rules object storage bucket, from Amazon SNS, Google Cloud Pub/Sub or a webhook, to sync the rules when they change in addition to polling them.")
 
 	f.StringVar(&cfg.AlertmanagerURL, "ruler.alertmanager-url", "", "Comma-separated list of URL(s) of the Alertmanager(s) to send notifications to. Each Alertmanager URL is treated as a separate group in the configuration. Multiple Alertmanagers in HA per group can be supported by using DNS resolution via -ruler.alertmanager-discovery.")
 	f.BoolVar(&cfg.AlertmanagerDiscovery, "ruler.alertmanager-discovery", false, "Use DNS SRV records to discover Alertmanager hosts.")
@@ -200,6 +269,9 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&cfg.RulePath, "ruler.rule-path", "/rules", "file path to store temporary rule files for the prometheus rule managers")
 	f.BoolVar(&cfg.EnableAPI, "experimental.ruler.enable-api", false, "Enable the ruler api")
 	f.BoolVar(&cfg.APIDeduplicateRules, "experimental.ruler.api-deduplicate-rules", false, "EXPERIMENTAL: Remove duplicate rules in the prometheus rules and alerts API response. If there are duplicate rules the rule with the latest evaluation timestamp will be kept.")
+	f.BoolVar(&cfg.EnableBacktestAPI, "ruler.enable-backtest-api", false, "[Experimental] Enable the API to backtest a rule over historical data, returning the alerts or the series it would have generated. Requires the ruler API to be enabled.")
+	f.BoolVar(&cfg.EnableDryRunAPI, "ruler.enable-dry-run-api", false, "[Experimental] Enable the API to evaluate a rule group once and return the resulting samples and alerts, without writing the samples or sending the alerts. Requires the ruler API to be enabled.")
+	f.BoolVar(&cfg.EnableRuleTestAPI, "ruler.enable-rule-test-api", false, "[Experimental] Enable the API to run the promtool rule unit tests against rule files, with the ruler query engine and the rule query limits of the tenant. Requires the ruler API to be enabled.")
 	f.DurationVar(&cfg.OutageTolerance, "ruler.for-outage-tolerance", time.Hour, `Max time to tolerate outage for restoring "for" state of alert.`)
 	f.DurationVar(&cfg.ForGracePeriod, "ruler.for-grace-period", 10*time.Minute, `Minimum duration between alert and restored "for" state. This is maintained only for alerts with configured "for" time greater than grace period.`)
 	f.DurationVar(&cfg.ResendDelay, "ruler.resend-delay", time.Minute, `Minimum amount of time to wait before resending an alert to Alertmanager.`)
@@ -210,11 +282,34 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.Var(&cfg.DisabledTenants, "ruler.disabled-tenants", "Comma separated list of tenants whose rules this ruler cannot evaluate. If specified, a ruler that would normally pick the specified tenant(s) for processing will ignore them instead. Subject to sharding.")
 
 	f.BoolVar(&cfg.EnableQueryStats, "ruler.query-stats-enabled", false, "Report the wall time for ruler queries to complete as a per user metric and as an info level log message.")
+	f.BoolVar(&cfg.RuleGroupStatsEnabled, "ruler.rule-group-stats-enabled", false, "[Experimental] Record the query statistics of the rule groups: the wall time of their queries, the series and samples they fetch, the series they write and the reasons of their failed evaluations. They're exposed by the rules API and as per rule group metrics.")
 	f.BoolVar(&cfg.DisableRuleGroupLabel, "ruler.disable-rule-group-label", false, "Disable the rule_group label on exported metrics")
 
+	f.StringVar(&cfg.FrontendAddress, "ruler.frontend-address", "", "[Experimental] HTTP URL of the Prometheus API of the query-frontend, including the API prefix (eg. http://query-frontend:8080/prometheus). If set, the rule queries are evaluated through the query-frontend, applying the same query sharding, caching and limits of the user queries, instead of the querier embedded in the ruler. The embedded querier is still used to restore the \"for\" state of the alerts.")
+	f.DurationVar(&cfg.FrontendTimeout, "ruler.frontend-timeout", 2*time.Minute, "[Experimental] Timeout of the rule queries evaluated through the query-frontend. 0 to disable.")
+
+	f.BoolVar(&cfg.CostBasedShardingEnabled, "ruler.cost-based-sharding-enabled", false, "[Experimental] Shard the rule groups across the rulers by their evaluation cost, measured from the duration of their last evaluation, instead of hashing them. The rule groups are periodically rebalanced to balance the evaluation cost of each ruler. Only supported by the default sharding strategy.")
+	f.DurationVar(&cfg.CostBasedShardingRebalanceInterval, "ruler.cost-based-sharding-rebalance-interval", 10*time.Minute, "[Experimental] How frequently the rule groups are rebalanced across the rulers by their evaluation cost, when cost-based sharding is enabled.")
+
+	f.BoolVar(&cfg.AlertStatePersistenceEnabled, "ruler.alert-state-persistence-enabled", false, "[Experimental] Periodically persist the state of the alerts to the ruler storage, and restore it when a ruler loads the rule groups, so that the \"for\" duration of the pending alerts is not reset when the rulers restart or the rule groups are resharded. Requires an object storage backend for the ruler storage.")
+	f.DurationVar(&cfg.AlertStatePersistInterval, "ruler.alert-state-persist-interval", time.Minute, "[Experimental] The interval between persisting the state of the alerts to the ruler storage, when the alert state persistence is enabled.")
+
+	f.BoolVar(&cfg.EvaluationCatchUpEnabled, "ruler.evaluation-catch-up-enabled", false, "[Experimental] Periodically persist the timestamp of the last successful evaluation of the rule groups to the ruler storage, and when a ruler loads a rule group, evaluate its recording rules at the timestamps of the evaluations missed since then, so that the recorded series don't have gaps when the rulers restart or the rule groups are resharded. Requires an object storage backend for the ruler storage.")
+	f.DurationVar(&cfg.EvaluationCatchUpMaxLookback, "ruler.evaluation-catch-up-max-lookback", time.Hour, "[Experimental] The maximum age of the missed evaluations caught up, when the evaluation catch-up is enabled. The older missed evaluations are not caught up.")
+	f.DurationVar(&cfg.EvaluationCatchUpPersistInterval, "ruler.evaluation-catch-up-persist-interval", time.Minute, "[Experimental] The interval between persisting the timestamp of the last evaluation of the rule groups to the ruler storage, when the evaluation catch-up is enabled.")
+
+	f.BoolVar(&cfg.RulesVersioningEnabled, "ruler.rules-versioning-enabled", false, "[Experimental] Keep the history of the rule namespaces changed through the ruler API in the ruler storage, and expose the API to list, diff and roll back their versions. Requires the ruler API to be enabled and an object storage backend for the ruler storage.")
+	f.IntVar(&cfg.MaxRulesVersions, "ruler.max-rules-versions", 20, "[Experimental] The maximum number of versions kept for each rule namespace, when the rules versioning is enabled. The oldest versions are deleted first. 0 to keep all the versions.")
+
+	f.BoolVar(&cfg.RuleDependencyOrderingEnabled, "ruler.rule-dependency-ordering-enabled", false, "[Experimental] Evaluate the rules using the series recorded by other rules after them, instead of one evaluation interval later. The rules of a rule group are evaluated in the order of their dependencies, and the rule groups depending on other rule groups of the same tenant are evaluated at the timestamp of their latest evaluation.")
+
 	cfg.RingCheckPeriod = 5 * time.Second
 }
 
+func (r *Ruler) costBasedShardingEnabled() bool {
+	return r.cfg.EnableSharding && r.cfg.ShardingStrategy == util.ShardingStrategyDefault && r.cfg.CostBasedShardingEnabled
+}
+
 func (cfg *Config) RulesBackupEnabled() bool {
 	// If the replication factor is greater the  1, only the first replica is responsible for evaluating the rule,
 	// the rest of the replica will store the rule groups as backup only for API HA.
@@ -230,12 +325,18 @@ type MultiTenantManager interface {
 	BackUpRuleGroups(ctx context.Context, ruleGroups map[string]rulespb.RuleGroupList)
 	// GetRules fetches rules for a particular tenant (userID).
 	GetRules(userID string) []*promRules.Group
+	// GetRuleGroupStats fetches the query statistics of the last evaluation, and of all the evaluations, of a rule group.
+	GetRuleGroupStats(userID, file, name string) (last, total *RuleGroupStatsDesc)
 	// GetBackupRules fetches rules for a particular tenant (userID) that the ruler stores for backup purposes
 	GetBackupRules(userID string) rulespb.RuleGroupList
 	// Stop stops all Manager components.
 	Stop()
 	// ValidateRuleGroup validates a rulegroup
 	ValidateRuleGroup(rulefmt.RuleGroup) []error
+	// PersistAlertState persists the state of the alerts to the ruler storage, if enabled.
+	PersistAlertState(ctx context.Context)
+	// PersistLastEvaluations persists the timestamp of the last evaluation of the rule groups to the ruler storage, if enabled.
+	PersistLastEvaluations(ctx context.Context)
 }
 
 // Ruler evaluates rules.
@@ -268,12 +369,13 @@ type MultiTenantManager interface {
 type Ruler struct {
 	services.Service
 
-	cfg        Config
-	lifecycler *ring.BasicLifecycler
-	ring       *ring.Ring
-	store      rulestore.RuleStore
-	manager    MultiTenantManager
-	limits     RulesLimits
+	cfg         Config
+	lifecycler  *ring.BasicLifecycler
+	ring        *ring.Ring
+	preparation *ring.InstancePreparation
+	store       rulestore.RuleStore
+	manager     MultiTenantManager
+	limits      RulesLimits
 
 	subservices        *services.Manager
 	subservicesWatcher *services.FailureWatcher
@@ -289,6 +391,17 @@ type Ruler struct {
 
 	allowedTenants *util.AllowedTenants
 
+	// Assignment of the rule groups to the rulers, when cost-based sharding is enabled.
+	costAssignment costBasedAssignment
+
+	// Signals the rules have changed in the storage, when sync notifications are enabled.
+	syncNotifications chan struct{}
+
+	// Number of rule groups owned by the ruler at the last sync.
+	ownedRuleGroups atomic.Int64
+	// Whether to persist the alerts state and the last evaluations when stopping, set when the shutdown is prepared.
+	persistStateOnShutdown atomic.Bool
+
 	registry prometheus.Registerer
 	logger   log.Logger
 }
@@ -309,6 +422,8 @@ func newRuler(cfg Config, manager MultiTenantManager, reg prometheus.Registerer,
 		clientsPool:    clientPool,
 		allowedTenants: util.NewAllowedTenants(cfg.EnabledTenants, cfg.DisabledTenants),
 
+		syncNotifications: make(chan struct{}, 1),
+
 		ringCheckErrors: promauto.With(reg).NewCounter(prometheus.CounterOpts{
 			Name: "cortex_ruler_ring_check_errors_total",
 			Help: "Number of errors that have occurred when checking the ring for ownership",
@@ -343,8 +458,10 @@ func newRuler(cfg Config, manager MultiTenantManager, reg prometheus.Registerer,
 	}
 
 	if cfg.EnableSharding {
+		kvCfg := cfg.Ring.KVStore
+		kvCfg.CASBatchPeriod = cfg.Ring.CASBatchPeriod
 		ringStore, err := kv.NewClient(
-			cfg.Ring.KVStore,
+			kvCfg,
 			ring.GetCodec(),
 			kv.RegistererWithKVName(prometheus.WrapRegistererWithPrefix("cortex_", reg), "ruler"),
 			logger,
@@ -375,12 +492,19 @@ func enableSharding(r *Ruler, ringStore kv.Client) error {
 	delegate = ring.NewAutoForgetDelegate(r.cfg.Ring.HeartbeatTimeout*ringAutoForgetUnhealthyPeriods, delegate, r.logger)
 
 	rulerRingName := "ruler"
-	r.lifecycler, err = ring.NewBasicLifecycler(lifecyclerCfg, rulerRingName, ringKey, ringStore, delegate, r.logger, prometheus.WrapRegistererWithPrefix("cortex_", r.registry))
+	r.lifecycler, err = ring.NewBasicLifecycler(lifecyclerCfg, rulerRingName, RingKey, ringStore, delegate, r.logger, prometheus.WrapRegistererWithPrefix("cortex_", r.registry))
 	if err != nil {
 		return errors.Wrap(err, "failed to initialize ruler's lifecycler")
 	}
 
-	r.ring, err = ring.NewWithStoreClientAndStrategy(r.cfg.Ring.ToRingConfig(), rulerRingName, ringKey, ringStore, ring.NewIgnoreUnhealthyInstancesReplicationStrategy(), prometheus.WrapRegistererWithPrefix("cortex_", r.registry), r.logger)
+	r.preparation = ring.NewInstancePreparation(ring.PreparationHooks{
+		SetHandOffOnShutdown: r.persistStateOnShutdown.Store,
+		ChangeState:          r.lifecycler.ChangeState,
+		HandOff:              r.handOffRuleGroups,
+		ReadyForTermination:  r.checkNoOwnedRuleGroups,
+	}, r.logger)
+
+	r.ring, err = ring.NewWithStoreClientAndStrategy(r.cfg.Ring.ToRingConfig(), rulerRingName, RingKey, ringStore, ring.NewIgnoreUnhealthyInstancesReplicationStrategy(), prometheus.WrapRegistererWithPrefix("cortex_", r.registry), r.logger)
 	if err != nil {
 		return errors.Wrap(err, "failed to initialize ruler's ring")
 	}
@@ -412,6 +536,10 @@ func (r *Ruler) starting(ctx context.Context) error {
 // Stop stops the Ruler.
 // Each function of the ruler is terminated before leaving the ring
 func (r *Ruler) stopping(_ error) error {
+	if r.persistStateOnShutdown.Load() {
+		r.manager.PersistAlertState(context.Background())
+		r.manager.PersistLastEvaluations(context.Background())
+	}
 	r.manager.Stop()
 
 	if r.subservices != nil {
@@ -529,6 +657,34 @@ func (r *Ruler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// PrepareShutdownHandler prepares the ruler to persist the alerts state and the last evaluations of its rule
+// groups when it shuts down.
+func (r *Ruler) PrepareShutdownHandler(w http.ResponseWriter, req *http.Request) {
+	if r.checkPreparationAvailable(w) {
+		r.preparation.PrepareShutdownHandler(w, req)
+	}
+}
+
+// PrepareDownscaleHandler switches the ruler to LEAVING, so that its rule groups are taken over by other rulers,
+// persists their state and reports when the ruler doesn't evaluate them anymore.
+func (r *Ruler) PrepareDownscaleHandler(w http.ResponseWriter, req *http.Request) {
+	if r.checkPreparationAvailable(w) {
+		r.preparation.PrepareDownscaleHandler(w, req)
+	}
+}
+
+func (r *Ruler) checkPreparationAvailable(w http.ResponseWriter) bool {
+	if !r.cfg.EnableSharding {
+		http.Error(w, "Ruler has no ring because sharding is disabled.", http.StatusNotFound)
+		return false
+	}
+	if r.State() != services.Running {
+		http.Error(w, "Ruler is not running yet.", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
 func (r *Ruler) run(ctx context.Context) error {
 	level.Info(r.logger).Log("msg", "ruler up and running")
 
@@ -545,6 +701,29 @@ func (r *Ruler) run(ctx context.Context) error {
 		ringTickerChan = ringTicker.C
 	}
 
+	var rebalanceTimer *time.Timer
+	var rebalanceChan <-chan time.Time
+
+	if r.costBasedShardingEnabled() {
+		rebalanceTimer = time.NewTimer(time.Until(nextRuleGroupsRebalance(time.Now(), r.cfg.CostBasedShardingRebalanceInterval)))
+		defer rebalanceTimer.Stop()
+		rebalanceChan = rebalanceTimer.C
+	}
+
+	var alertStateChan <-chan time.Time
+	if r.cfg.AlertStatePersistenceEnabled {
+		alertStateTicker := time.NewTicker(r.cfg.AlertStatePersistInterval)
+		defer alertStateTicker.Stop()
+		alertStateChan = alertStateTicker.C
+	}
+
+	var lastEvaluationsChan <-chan time.Time
+	if r.cfg.EvaluationCatchUpEnabled {
+		lastEvaluationsTicker := time.NewTicker(r.cfg.EvaluationCatchUpPersistInterval)
+		defer lastEvaluationsTicker.Stop()
+		lastEvaluationsChan = lastEvaluationsTicker.C
+	}
+
 	r.syncRules(ctx, rulerSyncReasonInitial)
 	for {
 		select {
@@ -561,6 +740,16 @@ func (r *Ruler) run(ctx context.Context) error {
 				ringLastState = currRingState
 				r.syncRules(ctx, rulerSyncReasonRingChange)
 			}
+		case <-rebalanceChan:
+			r.rebalanceRuleGroupsByCost(ctx)
+			r.syncRules(ctx, rulerSyncReasonRebalance)
+			rebalanceTimer.Reset(time.Until(nextRuleGroupsRebalance(time.Now(), r.cfg.CostBasedShardingRebalanceInterval)))
+		case <-alertStateChan:
+			r.manager.PersistAlertState(ctx)
+		case <-lastEvaluationsChan:
+			r.manager.PersistLastEvaluations(ctx)
+		case <-r.syncNotifications:
+			r.syncRules(ctx, rulerSyncReasonNotification)
 		case err := <-r.subservicesWatcher.Chan():
 			return errors.Wrap(err, "ruler subservice failed")
 		}
@@ -585,11 +774,44 @@ func (r *Ruler) syncRules(ctx context.Context, reason string) {
 	// This will also delete local group files for users that are no longer in 'configs' map.
 	r.manager.SyncRuleGroups(ctx, loadedConfigs)
 
+	owned := 0
+	for _, groups := range loadedConfigs {
+		owned += len(groups)
+	}
+	r.ownedRuleGroups.Store(int64(owned))
+
 	if r.cfg.RulesBackupEnabled() {
 		r.manager.BackUpRuleGroups(ctx, backupConfigs)
 	}
 }
 
+// handOffRuleGroups waits until the ruler is LEAVING in the ring, persists the alerts state and the last
+// evaluations of its rule groups, so that the rulers taking over the rule groups can restore them, and triggers
+// a sync of the rules to stop evaluating them.
+func (r *Ruler) handOffRuleGroups(ctx context.Context) error {
+	if err := ring.WaitInstanceState(ctx, r.ring, r.lifecycler.GetInstanceID(), ring.LEAVING); err != nil {
+		return errors.Wrap(err, "wait until the ruler is LEAVING in the ring")
+	}
+
+	r.manager.PersistAlertState(ctx)
+	r.manager.PersistLastEvaluations(ctx)
+
+	select {
+	case r.syncNotifications <- struct{}{}:
+	default:
+		// A sync is already pending.
+	}
+	return nil
+}
+
+// checkNoOwnedRuleGroups returns an error if the ruler still owned some rule groups at the last sync.
+func (r *Ruler) checkNoOwnedRuleGroups() error {
+	if owned := r.ownedRuleGroups.Load(); owned > 0 {
+		return fmt.Errorf("the ruler still evaluates %d rule groups", owned)
+	}
+	return nil
+}
+
 func (r *Ruler) loadRuleGroups(ctx context.Context) (map[string]rulespb.RuleGroupList, map[string]rulespb.RuleGroupList, error) {
 	timer := prometheus.NewTimer(nil)
 
@@ -682,10 +904,27 @@ func (r *Ruler) listRulesShardingDefault(ctx context.Context) (map[string]rulesp
 		return nil, nil, err
 	}
 
+	var healthy map[string]struct{}
+	if r.cfg.CostBasedShardingEnabled {
+		healthy = map[string]struct{}{}
+		// In case of error, all the rule groups are sharded by hash.
+		rs, _ := r.ring.GetAllHealthy(RingOp)
+		for _, addr := range rs.GetAddresses() {
+			healthy[addr] = struct{}{}
+		}
+	}
+
 	ownedConfigs := make(map[string]rulespb.RuleGroupList)
 	backedUpConfigs := make(map[string]rulespb.RuleGroupList)
 	for userID, groups := range configs {
-		owned := filterRuleGroups(userID, groups, r.limits.DisabledRuleGroups(userID), r.ring, r.lifecycler.GetInstanceAddr(), r.logger, r.ringCheckErrors)
+		var owned []*rulespb.RuleGroupDesc
+		unassigned := groups
+		if r.cfg.CostBasedShardingEnabled {
+			// The rule groups assigned to a healthy ruler by cost are evaluated by it, while the
+			// other rule groups are sharded by hash.
+			owned, unassigned = filterRuleGroupsByCost(groups, r.limits.DisabledRuleGroups(userID), &r.costAssignment, healthy, r.lifecycler.GetInstanceAddr())
+		}
+		owned = append(owned, filterRuleGroups(userID, unassigned, r.limits.DisabledRuleGroups(userID), r.ring, r.lifecycler.GetInstanceAddr(), r.logger, r.ringCheckErrors)...)
 		if len(owned) > 0 {
 			ownedConfigs[userID] = owned
 		}
@@ -908,6 +1147,7 @@ func (r *Ruler) getLocalRules(userID string, rulesRequest RulesRequest, includeB
 			EvaluationTimestamp: group.GetLastEvaluation(),
 			EvaluationDuration:  group.GetEvaluationTime(),
 		}
+		groupDesc.LastEvaluationStats, groupDesc.TotalStats = r.manager.GetRuleGroupStats(userID, group.File(), group.Name())
 		for _, r := range group.Rules() {
 			if len(ruleNameSet) > 0 {
 				if _, OK := ruleNameSet[r.Name()]; !OK {
@@ -1232,6 +1472,79 @@ func (r *Ruler) AssertMaxRulesPerRuleGroup(userID string, rules int) error {
 	return fmt.Errorf(errMaxRulesPerRuleGroupPerUserLimitExceeded, limit, rules)
 }
 
+// AssertSourceTenantsAllowed returns an error if any of the source tenants of a federated rule group
+// is not allowed for the user.
+func (r *Ruler) AssertSourceTenantsAllowed(userID string, sourceTenants []string) error {
+	return checkSourceTenants(sourceTenants, r.limits.RulerAllowedSourceTenants(userID))
+}
+
+// AssertDestinationTenantAllowed returns an error if the destination tenant of a rule group is not
+// allowed for the user, or if the rule group contains alerting rules.
+func (r *Ruler) AssertDestinationTenantAllowed(userID, destinationTenant string, rules []rulefmt.RuleNode) error {
+	if destinationTenant == "" {
+		return nil
+	}
+
+	for _, rule := range rules {
+		if rule.Alert.Value != "" {
+			return errors.New(errDestinationTenantAlerts)
+		}
+	}
+	return checkDestinationTenant(destinationTenant, r.limits.RulerAllowedDestinationTenants(userID))
+}
+
+// AssertAlertmanagersAllowed returns an error if any of the additional alertmanager tenants or URLs of
+// a rule group is not allowed for the user.
+func (r *Ruler) AssertAlertmanagersAllowed(userID string, rg rulespb.RuleGroup) error {
+	if err := checkAlertmanagerTenants(rg.AlertmanagerTenants, r.limits.RulerAllowedAlertmanagerTenants(userID)); err != nil {
+		return err
+	}
+	return checkAlertmanagerURLs(rg.AlertmanagerURLs, r.limits.RulerAllowedAlertmanagerURLs(userID))
+}
+
+// AssertEvaluationAlignmentValid returns an error if the evaluation offset of a rule group is set
+// without aligning its evaluations, or is not within its interval.
+func (r *Ruler) AssertEvaluationAlignmentValid(rg rulespb.RuleGroup) error {
+	interval := time.Duration(rg.Interval)
+	if interval == 0 {
+		interval = r.cfg.EvaluationInterval
+	}
+	return checkEvaluationAlignment(rg.AlignEvaluations, time.Duration(rg.EvaluationOffset), interval)
+}
+
+// AssertExternalLabelsValid returns an error if an external label of a rule group is invalid or reserved.
+func (r *Ruler) AssertExternalLabelsValid(rg rulespb.RuleGroup) error {
+	return checkExternalLabels(rg.ExternalLabels, r.cfg.ExternalLabels)
+}
+
+// AssertRuleGroupValid returns an error if a rule group is invalid, or exceeds the limits of the rule groups.
+// It doesn't check the maximum number of rule groups, which depends on the other rule groups of the tenant.
+func (r *Ruler) AssertRuleGroupValid(userID string, rg rulespb.RuleGroup) error {
+	if errs := r.manager.ValidateRuleGroup(rg.RuleGroup); len(errs) > 0 {
+		e := make([]string, 0, len(errs))
+		for _, err := range errs {
+			e = append(e, err.Error())
+		}
+		return errors.New(strings.Join(e, ", "))
+	}
+	if err := r.AssertMaxRulesPerRuleGroup(userID, len(rg.Rules)); err != nil {
+		return err
+	}
+	if err := r.AssertSourceTenantsAllowed(userID, rg.SourceTenants); err != nil {
+		return err
+	}
+	if err := r.AssertDestinationTenantAllowed(userID, rg.DestinationTenant, rg.Rules); err != nil {
+		return err
+	}
+	if err := r.AssertAlertmanagersAllowed(userID, rg); err != nil {
+		return err
+	}
+	if err := r.AssertEvaluationAlignmentValid(rg); err != nil {
+		return err
+	}
+	return r.AssertExternalLabelsValid(rg)
+}
+
 func (r *Ruler) DeleteTenantConfiguration(w http.ResponseWriter, req *http.Request) {
 	logger := util_log.WithContext(req.Context(), r.logger)
 
@@ -12,6 +12,7 @@ import (
 	rulespb "github.com/cortexproject/cortex/pkg/ruler/rulespb"
 	_ "github.com/gogo/protobuf/gogoproto"
 	proto "github.com/gogo/protobuf/proto"
+	github_com_gogo_protobuf_sortkeys "github.com/gogo/protobuf/sortkeys"
 	github_com_gogo_protobuf_types "github.com/gogo/protobuf/types"
 	_ "github.com/golang/protobuf/ptypes/duration"
 	_ "github.com/golang/protobuf/ptypes/timestamp"
@@ -148,18 +149,99 @@ func (m *RulesResponse) GetGroups() []*GroupStateDesc {
 	return nil
 }
 
+type SyncRulesRequest struct {
+	// The users whose rules have changed.
+	Users []string `protobuf:"bytes,1,rep,name=users,proto3" json:"users,omitempty"`
+}
+
+func (m *SyncRulesRequest) Reset()      { *m = SyncRulesRequest{} }
+func (*SyncRulesRequest) ProtoMessage() {}
+func (*SyncRulesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9ecbec0a4cfddea6, []int{2}
+}
+func (m *SyncRulesRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *SyncRulesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_SyncRulesRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *SyncRulesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SyncRulesRequest.Merge(m, src)
+}
+func (m *SyncRulesRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *SyncRulesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SyncRulesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SyncRulesRequest proto.InternalMessageInfo
+
+func (m *SyncRulesRequest) GetUsers() []string {
+	if m != nil {
+		return m.Users
+	}
+	return nil
+}
+
+type SyncRulesResponse struct {
+}
+
+func (m *SyncRulesResponse) Reset()      { *m = SyncRulesResponse{} }
+func (*SyncRulesResponse) ProtoMessage() {}
+func (*SyncRulesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9ecbec0a4cfddea6, []int{3}
+}
+func (m *SyncRulesResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *SyncRulesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_SyncRulesResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *SyncRulesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SyncRulesResponse.Merge(m, src)
+}
+func (m *SyncRulesResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *SyncRulesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SyncRulesResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SyncRulesResponse proto.InternalMessageInfo
+
 // GroupStateDesc is a proto representation of a cortex rule group
 type GroupStateDesc struct {
 	Group               *rulespb.RuleGroupDesc `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
 	ActiveRules         []*RuleStateDesc       `protobuf:"bytes,2,rep,name=active_rules,json=activeRules,proto3" json:"active_rules,omitempty"`
 	EvaluationTimestamp time.Time              `protobuf:"bytes,3,opt,name=evaluationTimestamp,proto3,stdtime" json:"evaluationTimestamp"`
 	EvaluationDuration  time.Duration          `protobuf:"bytes,4,opt,name=evaluationDuration,proto3,stdduration" json:"evaluationDuration"`
+	LastEvaluationStats *RuleGroupStatsDesc    `protobuf:"bytes,5,opt,name=lastEvaluationStats,proto3" json:"lastEvaluationStats,omitempty"`
+	TotalStats          *RuleGroupStatsDesc    `protobuf:"bytes,6,opt,name=totalStats,proto3" json:"totalStats,omitempty"`
 }
 
 func (m *GroupStateDesc) Reset()      { *m = GroupStateDesc{} }
 func (*GroupStateDesc) ProtoMessage() {}
 func (*GroupStateDesc) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9ecbec0a4cfddea6, []int{2}
+	return fileDescriptor_9ecbec0a4cfddea6, []int{4}
 }
 func (m *GroupStateDesc) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -216,6 +298,113 @@ func (m *GroupStateDesc) GetEvaluationDuration() time.Duration {
 	return 0
 }
 
+func (m *GroupStateDesc) GetLastEvaluationStats() *RuleGroupStatsDesc {
+	if m != nil {
+		return m.LastEvaluationStats
+	}
+	return nil
+}
+
+func (m *GroupStateDesc) GetTotalStats() *RuleGroupStatsDesc {
+	if m != nil {
+		return m.TotalStats
+	}
+	return nil
+}
+
+// RuleGroupStatsDesc is a proto representation of the query statistics of the evaluations of a rule group
+type RuleGroupStatsDesc struct {
+	QueryWallTime  time.Duration `protobuf:"bytes,1,opt,name=queryWallTime,proto3,stdduration" json:"queryWallTime"`
+	FetchedSeries  uint64        `protobuf:"varint,2,opt,name=fetchedSeries,proto3" json:"fetchedSeries,omitempty"`
+	FetchedChunks  uint64        `protobuf:"varint,3,opt,name=fetchedChunks,proto3" json:"fetchedChunks,omitempty"`
+	FetchedSamples uint64        `protobuf:"varint,4,opt,name=fetchedSamples,proto3" json:"fetchedSamples,omitempty"`
+	FetchedBytes   uint64        `protobuf:"varint,5,opt,name=fetchedBytes,proto3" json:"fetchedBytes,omitempty"`
+	SeriesWritten  uint64        `protobuf:"varint,6,opt,name=seriesWritten,proto3" json:"seriesWritten,omitempty"`
+	// The number of failed rule evaluations, by reason.
+	Failures map[string]uint64 `protobuf:"bytes,7,rep,name=failures,proto3" json:"failures,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+}
+
+func (m *RuleGroupStatsDesc) Reset()      { *m = RuleGroupStatsDesc{} }
+func (*RuleGroupStatsDesc) ProtoMessage() {}
+func (*RuleGroupStatsDesc) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9ecbec0a4cfddea6, []int{5}
+}
+func (m *RuleGroupStatsDesc) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *RuleGroupStatsDesc) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_RuleGroupStatsDesc.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *RuleGroupStatsDesc) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RuleGroupStatsDesc.Merge(m, src)
+}
+func (m *RuleGroupStatsDesc) XXX_Size() int {
+	return m.Size()
+}
+func (m *RuleGroupStatsDesc) XXX_DiscardUnknown() {
+	xxx_messageInfo_RuleGroupStatsDesc.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RuleGroupStatsDesc proto.InternalMessageInfo
+
+func (m *RuleGroupStatsDesc) GetQueryWallTime() time.Duration {
+	if m != nil {
+		return m.QueryWallTime
+	}
+	return 0
+}
+
+func (m *RuleGroupStatsDesc) GetFetchedSeries() uint64 {
+	if m != nil {
+		return m.FetchedSeries
+	}
+	return 0
+}
+
+func (m *RuleGroupStatsDesc) GetFetchedChunks() uint64 {
+	if m != nil {
+		return m.FetchedChunks
+	}
+	return 0
+}
+
+func (m *RuleGroupStatsDesc) GetFetchedSamples() uint64 {
+	if m != nil {
+		return m.FetchedSamples
+	}
+	return 0
+}
+
+func (m *RuleGroupStatsDesc) GetFetchedBytes() uint64 {
+	if m != nil {
+		return m.FetchedBytes
+	}
+	return 0
+}
+
+func (m *RuleGroupStatsDesc) GetSeriesWritten() uint64 {
+	if m != nil {
+		return m.SeriesWritten
+	}
+	return 0
+}
+
+func (m *RuleGroupStatsDesc) GetFailures() map[string]uint64 {
+	if m != nil {
+		return m.Failures
+	}
+	return nil
+}
+
 // RuleStateDesc is a proto representation of a Prometheus Rule
 type RuleStateDesc struct {
 	Rule                *rulespb.RuleDesc `protobuf:"bytes,1,opt,name=rule,proto3" json:"rule,omitempty"`
@@ -230,7 +419,7 @@ type RuleStateDesc struct {
 func (m *RuleStateDesc) Reset()      { *m = RuleStateDesc{} }
 func (*RuleStateDesc) ProtoMessage() {}
 func (*RuleStateDesc) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9ecbec0a4cfddea6, []int{3}
+	return fileDescriptor_9ecbec0a4cfddea6, []int{6}
 }
 func (m *RuleStateDesc) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -324,7 +513,7 @@ type AlertStateDesc struct {
 func (m *AlertStateDesc) Reset()      { *m = AlertStateDesc{} }
 func (*AlertStateDesc) ProtoMessage() {}
 func (*AlertStateDesc) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9ecbec0a4cfddea6, []int{4}
+	return fileDescriptor_9ecbec0a4cfddea6, []int{7}
 }
 func (m *AlertStateDesc) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -412,7 +601,11 @@ func (m *AlertStateDesc) GetKeepFiringSince() time.Time {
 func init() {
 	proto.RegisterType((*RulesRequest)(nil), "ruler.RulesRequest")
 	proto.RegisterType((*RulesResponse)(nil), "ruler.RulesResponse")
+	proto.RegisterType((*SyncRulesRequest)(nil), "ruler.SyncRulesRequest")
+	proto.RegisterType((*SyncRulesResponse)(nil), "ruler.SyncRulesResponse")
 	proto.RegisterType((*GroupStateDesc)(nil), "ruler.GroupStateDesc")
+	proto.RegisterType((*RuleGroupStatsDesc)(nil), "ruler.RuleGroupStatsDesc")
+	proto.RegisterMapType((map[string]uint64)(nil), "ruler.RuleGroupStatsDesc.FailuresEntry")
 	proto.RegisterType((*RuleStateDesc)(nil), "ruler.RuleStateDesc")
 	proto.RegisterType((*AlertStateDesc)(nil), "ruler.AlertStateDesc")
 }
@@ -420,54 +613,69 @@ func init() {
 func init() { proto.RegisterFile("ruler.proto", fileDescriptor_9ecbec0a4cfddea6) }
 
 var fileDescriptor_9ecbec0a4cfddea6 = []byte{
-	// 750 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x55, 0x4f, 0x6b, 0x13, 0x4f,
-	0x18, 0xde, 0x49, 0x9b, 0x34, 0x99, 0xf4, 0xd7, 0xf2, 0x9b, 0x46, 0x59, 0x83, 0x4c, 0x4a, 0x04,
-	0x29, 0x82, 0x1b, 0x88, 0x05, 0xf1, 0x50, 0x25, 0xa5, 0xad, 0x17, 0x91, 0xb2, 0x51, 0xaf, 0x61,
-	0x92, 0x4c, 0xb6, 0x6b, 0x37, 0x3b, 0xeb, 0xcc, 0x6c, 0xa8, 0x17, 0xf1, 0x23, 0xf4, 0xe8, 0xd9,
-	0x93, 0x1f, 0xa5, 0xc7, 0xe2, 0xa9, 0x88, 0x54, 0x9b, 0x5e, 0x3c, 0xf6, 0x23, 0xc8, 0xcc, 0xec,
-	0x9a, 0xa4, 0x56, 0x70, 0x91, 0x5e, 0x92, 0x79, 0xff, 0x3c, 0xcf, 0x3b, 0xef, 0xfb, 0xcc, 0xcc,
-	0xc2, 0x32, 0x8f, 0x03, 0xca, 0x9d, 0x88, 0x33, 0xc9, 0x50, 0x5e, 0x1b, 0xd5, 0x8a, 0xc7, 0x3c,
-	0xa6, 0x3d, 0x0d, 0xb5, 0x32, 0xc1, 0x2a, 0xf6, 0x18, 0xf3, 0x02, 0xda, 0xd0, 0x56, 0x37, 0x1e,
-	0x34, 0xfa, 0x31, 0x27, 0xd2, 0x67, 0x61, 0x12, 0xaf, 0x5d, 0x8e, 0x4b, 0x7f, 0x48, 0x85, 0x24,
-	0xc3, 0x28, 0x49, 0x78, 0xe4, 0xf9, 0x72, 0x2f, 0xee, 0x3a, 0x3d, 0x36, 0x6c, 0xf4, 0x18, 0x97,
-	0xf4, 0x20, 0xe2, 0xec, 0x35, 0xed, 0xc9, 0xc4, 0x6a, 0x44, 0xfb, 0x5e, 0x1a, 0xe8, 0x26, 0x8b,
-	0x04, 0xba, 0xf1, 0x37, 0x50, 0xbd, 0x79, 0xfd, 0x2b, 0xa2, 0xae, 0xf9, 0x37, 0xf0, 0xfa, 0x3b,
-	0xb8, 0xe8, 0x2a, 0xd3, 0xa5, 0x6f, 0x62, 0x2a, 0x24, 0xba, 0x0d, 0x4b, 0x2a, 0xfc, 0x9c, 0x0c,
-	0xa9, 0xb0, 0xc1, 0xea, 0xdc, 0x5a, 0xc9, 0x9d, 0x38, 0xd0, 0x5d, 0xb8, 0xa4, 0x8c, 0xa7, 0x9c,
-	0xc5, 0x91, 0x49, 0xc9, 0xe9, 0x94, 0x4b, 0x5e, 0x54, 0x81, 0xf9, 0x81, 0x1f, 0x50, 0x61, 0xcf,
-	0xe9, 0xb0, 0x31, 0x10, 0x82, 0xf3, 0xf2, 0x6d, 0x44, 0xed, 0xf9, 0x55, 0xb0, 0x56, 0x72, 0xf5,
-	0xba, 0xfe, 0x18, 0xfe, 0x97, 0xd4, 0x17, 0x11, 0x0b, 0x05, 0x45, 0xf7, 0x61, 0xc1, 0x53, 0x44,
-	0xa6, 0x7a, 0xb9, 0x79, 0xc3, 0x31, 0x32, 0x68, 0xf6, 0xb6, 0x24, 0x92, 0x6e, 0x51, 0xd1, 0x73,
-	0x93, 0xa4, 0xfa, 0xc7, 0x1c, 0x5c, 0x9a, 0x0d, 0xa1, 0x7b, 0x30, 0xaf, 0x83, 0x36, 0x58, 0x05,
-	0x6b, 0xe5, 0x66, 0xc5, 0x31, 0xfd, 0xba, 0xe9, 0x16, 0x35, 0xde, 0xa4, 0xa0, 0x87, 0x70, 0x91,
-	0xf4, 0xa4, 0x3f, 0xa2, 0x1d, 0x9d, 0xa4, 0xdb, 0x49, 0x21, 0x5c, 0x43, 0x26, 0x25, 0xcb, 0x26,
-	0x53, 0x6f, 0x17, 0xbd, 0x82, 0x2b, 0x74, 0x44, 0x82, 0x58, 0xcb, 0xfc, 0x22, 0x95, 0xd3, 0x9e,
-	0xd3, 0x25, 0xab, 0x8e, 0x11, 0xdc, 0x49, 0x05, 0x77, 0x7e, 0x65, 0x6c, 0x16, 0x8f, 0x4e, 0x6b,
-	0xd6, 0xe1, 0xb7, 0x1a, 0x70, 0xaf, 0x22, 0x40, 0x6d, 0x88, 0x26, 0xee, 0xad, 0xe4, 0x18, 0xe9,
-	0x89, 0x95, 0x9b, 0xb7, 0x7e, 0xa3, 0x4d, 0x13, 0x0c, 0xeb, 0x07, 0xc5, 0x7a, 0x05, 0xbc, 0xfe,
-	0x35, 0x67, 0xa6, 0x3c, 0x99, 0xd1, 0x1d, 0x38, 0xaf, 0x5a, 0x4c, 0x46, 0xb4, 0x3c, 0x35, 0x22,
-	0xdd, 0xaa, 0x0e, 0x2a, 0x15, 0x85, 0x42, 0xd8, 0x39, 0x2d, 0x98, 0x31, 0xd0, 0x4d, 0x58, 0xd8,
-	0xa3, 0x24, 0x90, 0x7b, 0xba, 0xd9, 0x92, 0x9b, 0x58, 0xea, 0xe4, 0x04, 0x44, 0xc8, 0x6d, 0xce,
-	0x19, 0x4f, 0x24, 0x9e, 0x38, 0x94, 0xac, 0x24, 0xa0, 0x5c, 0x0a, 0x3b, 0x3f, 0x23, 0x6b, 0x4b,
-	0x39, 0xa7, 0x64, 0x35, 0x49, 0x7f, 0x1a, 0x6f, 0xe1, 0x7a, 0xc6, 0xbb, 0xf0, 0x6f, 0xe3, 0xfd,
-	0x9c, 0x87, 0x4b, 0xb3, 0x7d, 0x4c, 0x46, 0x07, 0xa6, 0x47, 0x17, 0xc2, 0x42, 0x40, 0xba, 0x34,
-	0x48, 0xcf, 0xd9, 0x8a, 0x93, 0xde, 0x69, 0xe7, 0x99, 0xf2, 0xef, 0x12, 0x9f, 0x6f, 0xb6, 0x54,
-	0xad, 0x2f, 0xa7, 0xb5, 0x4c, 0x6f, 0x82, 0xc1, 0xb7, 0xfa, 0x24, 0x92, 0x94, 0xbb, 0x49, 0x15,
-	0x74, 0x00, 0xcb, 0x24, 0x0c, 0x99, 0xd4, 0xdb, 0x34, 0x97, 0xf1, 0xfa, 0x8a, 0x4e, 0x97, 0x52,
-	0xfd, 0xab, 0x39, 0x99, 0xbb, 0x0e, 0x5c, 0x63, 0xa0, 0x16, 0x2c, 0x25, 0xb7, 0x8d, 0x48, 0x3b,
-	0x9f, 0x41, 0xcb, 0xa2, 0x81, 0xb5, 0x24, 0x7a, 0x02, 0x8b, 0x03, 0x9f, 0xd3, 0xbe, 0x62, 0xc8,
-	0x72, 0x1a, 0x16, 0x34, 0xaa, 0x25, 0xd1, 0x36, 0x2c, 0x73, 0x2a, 0x58, 0x30, 0x32, 0x1c, 0x0b,
-	0x19, 0x38, 0x60, 0x0a, 0x6c, 0x49, 0xb4, 0x03, 0x17, 0xd5, 0xe1, 0xee, 0x08, 0x1a, 0x4a, 0xc5,
-	0x53, 0xcc, 0xc2, 0xa3, 0x90, 0x6d, 0x1a, 0x4a, 0xb3, 0x9d, 0x11, 0x09, 0xfc, 0x7e, 0x27, 0x0e,
-	0xa5, 0x1f, 0xd8, 0xa5, 0x2c, 0x34, 0x1a, 0xf8, 0x52, 0xe1, 0xd0, 0x2e, 0xfc, 0x7f, 0x9f, 0xd2,
-	0xa8, 0x33, 0xf0, 0xb9, 0x1f, 0x7a, 0x1d, 0xe1, 0x87, 0x3d, 0x6a, 0xc3, 0x0c, 0x64, 0xcb, 0x0a,
-	0xbe, 0xa3, 0xd1, 0x6d, 0x05, 0x6e, 0x6e, 0xc0, 0xbc, 0x7a, 0x0e, 0x38, 0x5a, 0x37, 0x0b, 0x81,
-	0x56, 0xa6, 0x5e, 0xc5, 0xf4, 0x7b, 0x51, 0xad, 0xcc, 0x3a, 0xcd, 0x23, 0x5e, 0xb7, 0x36, 0xd7,
-	0x8f, 0xcf, 0xb0, 0x75, 0x72, 0x86, 0xad, 0x8b, 0x33, 0x0c, 0xde, 0x8f, 0x31, 0xf8, 0x34, 0xc6,
-	0xe0, 0x68, 0x8c, 0xc1, 0xf1, 0x18, 0x83, 0xef, 0x63, 0x0c, 0x7e, 0x8c, 0xb1, 0x75, 0x31, 0xc6,
-	0xe0, 0xf0, 0x1c, 0x5b, 0xc7, 0xe7, 0xd8, 0x3a, 0x39, 0xc7, 0x56, 0xb7, 0xa0, 0xf7, 0xf8, 0xe0,
-	0x67, 0x00, 0x00, 0x00, 0xff, 0xff, 0x77, 0xf3, 0x36, 0x0c, 0x7b, 0x07, 0x00, 0x00,
+	// 987 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x56, 0x4f, 0x6f, 0x1b, 0x45,
+	0x14, 0xf7, 0xc6, 0x7f, 0x62, 0x3f, 0x27, 0x69, 0x3b, 0x36, 0xb0, 0x58, 0x68, 0x13, 0x19, 0x04,
+	0x16, 0x12, 0x6b, 0x29, 0x54, 0x82, 0x82, 0xf8, 0xe3, 0xb4, 0x09, 0x42, 0x20, 0x54, 0xad, 0x81,
+	0x1e, 0xad, 0xb1, 0x3d, 0xb6, 0x97, 0xac, 0x77, 0xb7, 0x33, 0xb3, 0x51, 0x7d, 0x01, 0x3e, 0x42,
+	0x8f, 0xdc, 0xb8, 0x22, 0x3e, 0x49, 0x8f, 0x11, 0xa7, 0x0a, 0xa1, 0x42, 0x9c, 0x0b, 0xc7, 0x7e,
+	0x04, 0x34, 0x6f, 0x66, 0x6d, 0x6f, 0x92, 0x8a, 0x5a, 0x55, 0x2f, 0xc9, 0xbe, 0xf7, 0x7e, 0xbf,
+	0xdf, 0x9b, 0x79, 0xf3, 0xe6, 0x8d, 0xa1, 0xca, 0x93, 0x80, 0x71, 0x37, 0xe6, 0x91, 0x8c, 0x48,
+	0x11, 0x8d, 0x46, 0x7d, 0x1c, 0x8d, 0x23, 0xf4, 0xb4, 0xd5, 0x97, 0x0e, 0x36, 0x9c, 0x71, 0x14,
+	0x8d, 0x03, 0xd6, 0x46, 0xab, 0x9f, 0x8c, 0xda, 0xc3, 0x84, 0x53, 0xe9, 0x47, 0xa1, 0x89, 0xef,
+	0x5e, 0x8c, 0x4b, 0x7f, 0xca, 0x84, 0xa4, 0xd3, 0xd8, 0x00, 0x6e, 0x8d, 0x7d, 0x39, 0x49, 0xfa,
+	0xee, 0x20, 0x9a, 0xb6, 0x07, 0x11, 0x97, 0xec, 0x41, 0xcc, 0xa3, 0x1f, 0xd8, 0x40, 0x1a, 0xab,
+	0x1d, 0x1f, 0x8f, 0xd3, 0x40, 0xdf, 0x7c, 0x18, 0xea, 0x27, 0xcf, 0x43, 0xc5, 0xc5, 0xe3, 0x5f,
+	0x11, 0xf7, 0xf5, 0x7f, 0x4d, 0x6f, 0xfe, 0x08, 0x5b, 0x9e, 0x32, 0x3d, 0x76, 0x3f, 0x61, 0x42,
+	0x92, 0x37, 0xa0, 0xa2, 0xc2, 0xdf, 0xd0, 0x29, 0x13, 0xb6, 0xb5, 0x97, 0x6f, 0x55, 0xbc, 0xa5,
+	0x83, 0xbc, 0x0d, 0x3b, 0xca, 0xf8, 0x82, 0x47, 0x49, 0xac, 0x21, 0x1b, 0x08, 0xb9, 0xe0, 0x25,
+	0x75, 0x28, 0x8e, 0xfc, 0x80, 0x09, 0x3b, 0x8f, 0x61, 0x6d, 0x10, 0x02, 0x05, 0x39, 0x8b, 0x99,
+	0x5d, 0xd8, 0xb3, 0x5a, 0x15, 0x0f, 0xbf, 0x9b, 0x9f, 0xc2, 0xb6, 0xc9, 0x2f, 0xe2, 0x28, 0x14,
+	0x8c, 0xbc, 0x07, 0xa5, 0xb1, 0x12, 0xd2, 0xd9, 0xab, 0xfb, 0xaf, 0xb8, 0xfa, 0x18, 0x50, 0xbd,
+	0x2b, 0xa9, 0x64, 0x77, 0x98, 0x18, 0x78, 0x06, 0xd4, 0x6c, 0xc1, 0xf5, 0xee, 0x2c, 0x1c, 0x64,
+	0xf6, 0x50, 0x87, 0x62, 0x22, 0x18, 0x4f, 0xd7, 0xaf, 0x8d, 0x66, 0x0d, 0x6e, 0xac, 0x20, 0x75,
+	0xb6, 0xe6, 0xef, 0x79, 0xd8, 0xc9, 0x2a, 0x93, 0x77, 0xa1, 0x88, 0xda, 0xb6, 0xb5, 0x67, 0xb5,
+	0xaa, 0xfb, 0x75, 0x57, 0x97, 0xcb, 0x4b, 0x77, 0x88, 0xe9, 0x35, 0x84, 0x7c, 0x00, 0x5b, 0x74,
+	0x20, 0xfd, 0x13, 0xd6, 0x43, 0x10, 0x56, 0x23, 0xa5, 0x70, 0xa4, 0x2c, 0x57, 0x5c, 0xd5, 0x48,
+	0xcc, 0x4f, 0xbe, 0x87, 0x1a, 0x3b, 0xa1, 0x41, 0x82, 0x5d, 0xf2, 0x6d, 0xda, 0x0d, 0x76, 0x1e,
+	0x53, 0x36, 0x5c, 0xdd, 0x2f, 0x6e, 0xda, 0x2f, 0xee, 0x02, 0x71, 0x50, 0x7e, 0xf4, 0x64, 0x37,
+	0xf7, 0xf0, 0xef, 0x5d, 0xcb, 0xbb, 0x4a, 0x80, 0x74, 0x81, 0x2c, 0xdd, 0x77, 0x4c, 0x17, 0x62,
+	0xc1, 0xab, 0xfb, 0xaf, 0x5f, 0x92, 0x4d, 0x01, 0x5a, 0xf5, 0x17, 0xa5, 0x7a, 0x05, 0x9d, 0x7c,
+	0x05, 0xb5, 0x80, 0x0a, 0x79, 0xb8, 0x88, 0xa8, 0x4d, 0x09, 0xbb, 0x68, 0x54, 0x97, 0x9b, 0x5d,
+	0x54, 0x52, 0xe0, 0x8e, 0xaf, 0x62, 0x91, 0x5b, 0x00, 0x32, 0x92, 0x34, 0xd0, 0x1a, 0xa5, 0xff,
+	0xd3, 0x58, 0x01, 0x37, 0x7f, 0xcd, 0x03, 0xb9, 0x0c, 0x21, 0x5f, 0xc2, 0xf6, 0xfd, 0x84, 0xf1,
+	0xd9, 0x3d, 0x1a, 0x04, 0xaa, 0x12, 0xb6, 0xf5, 0xfc, 0xdb, 0xcd, 0x32, 0xc9, 0x5b, 0xb0, 0x3d,
+	0x62, 0x72, 0x30, 0x61, 0xc3, 0x2e, 0xe3, 0x3e, 0x1e, 0xa8, 0xd5, 0x2a, 0x78, 0x59, 0xe7, 0x0a,
+	0xea, 0xf6, 0x24, 0x09, 0x8f, 0x85, 0x9d, 0xcf, 0xa0, 0xb4, 0x53, 0xdd, 0x95, 0x94, 0x46, 0xa7,
+	0xb1, 0xea, 0x8e, 0x02, 0xc2, 0x2e, 0x78, 0x49, 0x13, 0xb6, 0x8c, 0xe7, 0x60, 0x26, 0x99, 0x2e,
+	0x6b, 0xc1, 0xcb, 0xf8, 0x54, 0x46, 0x81, 0xb9, 0xef, 0x71, 0x5f, 0x4a, 0x16, 0x62, 0xdd, 0x0a,
+	0x5e, 0xd6, 0x49, 0x6e, 0x43, 0x79, 0x44, 0xfd, 0x20, 0xe1, 0x4c, 0xd8, 0x9b, 0xd8, 0x89, 0xef,
+	0x3c, 0xb3, 0xb0, 0xee, 0x91, 0x41, 0x1e, 0x86, 0x92, 0xcf, 0xbc, 0x05, 0xb1, 0xf1, 0x31, 0x6c,
+	0x67, 0x42, 0xe4, 0x3a, 0xe4, 0x8f, 0xd9, 0x0c, 0x8b, 0x5a, 0xf1, 0xd4, 0xa7, 0xba, 0x5f, 0xea,
+	0x50, 0x99, 0xa9, 0x8e, 0x36, 0x3e, 0xda, 0xf8, 0xd0, 0x6a, 0xfe, 0xb5, 0xa1, 0xaf, 0xf3, 0xf2,
+	0x36, 0xbd, 0x09, 0x05, 0xb5, 0x04, 0x73, 0x26, 0xd7, 0x56, 0x2e, 0x13, 0x1e, 0x2f, 0x06, 0x95,
+	0xa0, 0x50, 0x0c, 0x14, 0xac, 0x78, 0xda, 0x20, 0xaf, 0x42, 0x69, 0xc2, 0x68, 0x20, 0x27, 0x58,
+	0xdf, 0x8a, 0x67, 0x2c, 0x35, 0xa2, 0xb0, 0xb1, 0x38, 0x8f, 0xb8, 0x99, 0x25, 0x4b, 0x87, 0x9a,
+	0x1f, 0x34, 0x60, 0x1c, 0xfb, 0x73, 0x75, 0x7e, 0x74, 0x94, 0x73, 0x65, 0x7e, 0x68, 0xd0, 0xb3,
+	0x2e, 0x62, 0xe9, 0xe5, 0x5c, 0xc4, 0xcd, 0x17, 0xba, 0x88, 0xcd, 0x3f, 0x8a, 0xb0, 0x93, 0xdd,
+	0xc7, 0xb2, 0x74, 0xd6, 0x6a, 0xe9, 0x42, 0x28, 0x05, 0xb4, 0xcf, 0x82, 0x74, 0x22, 0xd5, 0xdc,
+	0xf4, 0xf1, 0x70, 0xbf, 0x56, 0xfe, 0xbb, 0xd4, 0xe7, 0x07, 0x1d, 0x95, 0xeb, 0xcf, 0x27, 0xbb,
+	0x6b, 0x3d, 0x3e, 0x9a, 0xdf, 0x19, 0xd2, 0x58, 0x32, 0xee, 0x99, 0x2c, 0xe4, 0x01, 0x54, 0x69,
+	0x18, 0x46, 0x12, 0x97, 0xa9, 0xa7, 0xfe, 0xcb, 0x4b, 0xba, 0x9a, 0x6a, 0xd9, 0x8b, 0xaa, 0x11,
+	0x2c, 0xd3, 0x8b, 0xa4, 0x03, 0x15, 0x33, 0x97, 0xa9, 0xb4, 0x8b, 0x6b, 0x9c, 0x65, 0x59, 0xd3,
+	0x3a, 0x92, 0x7c, 0x06, 0xe5, 0x91, 0xcf, 0xd9, 0x50, 0x29, 0xac, 0xd3, 0x0d, 0x9b, 0xc8, 0xea,
+	0x48, 0x72, 0x08, 0x55, 0xce, 0x44, 0x14, 0x9c, 0x68, 0x8d, 0xcd, 0x35, 0x34, 0x20, 0x25, 0x76,
+	0x24, 0x39, 0x82, 0x2d, 0xd5, 0xdc, 0x3d, 0xc1, 0x42, 0xa9, 0x74, 0xca, 0xeb, 0xe8, 0x28, 0x66,
+	0x97, 0x85, 0x52, 0x2f, 0xe7, 0x84, 0x06, 0xfe, 0xb0, 0x97, 0x84, 0xd2, 0x0f, 0xec, 0xca, 0x3a,
+	0x32, 0x48, 0xfc, 0x4e, 0xf1, 0xc8, 0x5d, 0xb8, 0x71, 0xcc, 0x58, 0xdc, 0x1b, 0xf9, 0xdc, 0x0f,
+	0xc7, 0x3d, 0xe1, 0x87, 0x03, 0x66, 0xc3, 0x1a, 0x62, 0xd7, 0x14, 0xfd, 0x08, 0xd9, 0x5d, 0x45,
+	0xde, 0xff, 0x09, 0x8a, 0x6a, 0x1c, 0x70, 0x72, 0x53, 0x7f, 0x08, 0x52, 0x5b, 0x99, 0x5a, 0xe9,
+	0xa3, 0xde, 0xa8, 0x67, 0x9d, 0xe6, 0xfd, 0xce, 0x91, 0xcf, 0xa1, 0xb2, 0x78, 0xd6, 0xc9, 0x6b,
+	0x06, 0x74, 0xf1, 0x27, 0x41, 0xc3, 0xbe, 0x1c, 0x48, 0x15, 0x0e, 0x6e, 0x9e, 0x9e, 0x39, 0xb9,
+	0xc7, 0x67, 0x4e, 0xee, 0xe9, 0x99, 0x63, 0xfd, 0x3c, 0x77, 0xac, 0xdf, 0xe6, 0x8e, 0xf5, 0x68,
+	0xee, 0x58, 0xa7, 0x73, 0xc7, 0xfa, 0x67, 0xee, 0x58, 0xff, 0xce, 0x9d, 0xdc, 0xd3, 0xb9, 0x63,
+	0x3d, 0x3c, 0x77, 0x72, 0xa7, 0xe7, 0x4e, 0xee, 0xf1, 0xb9, 0x93, 0xeb, 0x97, 0x70, 0x97, 0xef,
+	0xff, 0x37, 0x00, 0x0b, 0xc1, 0xf5, 0xed, 0x26, 0x0a, 0x00, 0x00,
 }
 
 func (this *RulesRequest) Equal(that interface{}) bool {
@@ -547,6 +755,56 @@ func (this *RulesResponse) Equal(that interface{}) bool {
 	}
 	return true
 }
+func (this *SyncRulesRequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*SyncRulesRequest)
+	if !ok {
+		that2, ok := that.(SyncRulesRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Users) != len(that1.Users) {
+		return false
+	}
+	for i := range this.Users {
+		if this.Users[i] != that1.Users[i] {
+			return false
+		}
+	}
+	return true
+}
+func (this *SyncRulesResponse) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*SyncRulesResponse)
+	if !ok {
+		that2, ok := that.(SyncRulesResponse)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	return true
+}
 func (this *GroupStateDesc) Equal(that interface{}) bool {
 	if that == nil {
 		return this == nil
@@ -583,6 +841,59 @@ func (this *GroupStateDesc) Equal(that interface{}) bool {
 	if this.EvaluationDuration != that1.EvaluationDuration {
 		return false
 	}
+	if !this.LastEvaluationStats.Equal(that1.LastEvaluationStats) {
+		return false
+	}
+	if !this.TotalStats.Equal(that1.TotalStats) {
+		return false
+	}
+	return true
+}
+func (this *RuleGroupStatsDesc) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*RuleGroupStatsDesc)
+	if !ok {
+		that2, ok := that.(RuleGroupStatsDesc)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.QueryWallTime != that1.QueryWallTime {
+		return false
+	}
+	if this.FetchedSeries != that1.FetchedSeries {
+		return false
+	}
+	if this.FetchedChunks != that1.FetchedChunks {
+		return false
+	}
+	if this.FetchedSamples != that1.FetchedSamples {
+		return false
+	}
+	if this.FetchedBytes != that1.FetchedBytes {
+		return false
+	}
+	if this.SeriesWritten != that1.SeriesWritten {
+		return false
+	}
+	if len(this.Failures) != len(that1.Failures) {
+		return false
+	}
+	for i := range this.Failures {
+		if this.Failures[i] != that1.Failures[i] {
+			return false
+		}
+	}
 	return true
 }
 func (this *RuleStateDesc) Equal(that interface{}) bool {
@@ -718,11 +1029,30 @@ func (this *RulesResponse) GoString() string {
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
+func (this *SyncRulesRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&ruler.SyncRulesRequest{")
+	s = append(s, "Users: "+fmt.Sprintf("%#v", this.Users)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *SyncRulesResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 4)
+	s = append(s, "&ruler.SyncRulesResponse{")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
 func (this *GroupStateDesc) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 8)
+	s := make([]string, 0, 10)
 	s = append(s, "&ruler.GroupStateDesc{")
 	if this.Group != nil {
 		s = append(s, "Group: "+fmt.Sprintf("%#v", this.Group)+",\n")
@@ -732,6 +1062,40 @@ func (this *GroupStateDesc) GoString() string {
 	}
 	s = append(s, "EvaluationTimestamp: "+fmt.Sprintf("%#v", this.EvaluationTimestamp)+",\n")
 	s = append(s, "EvaluationDuration: "+fmt.Sprintf("%#v", this.EvaluationDuration)+",\n")
+	if this.LastEvaluationStats != nil {
+		s = append(s, "LastEvaluationStats: "+fmt.Sprintf("%#v", this.LastEvaluationStats)+",\n")
+	}
+	if this.TotalStats != nil {
+		s = append(s, "TotalStats: "+fmt.Sprintf("%#v", this.TotalStats)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *RuleGroupStatsDesc) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 11)
+	s = append(s, "&ruler.RuleGroupStatsDesc{")
+	s = append(s, "QueryWallTime: "+fmt.Sprintf("%#v", this.QueryWallTime)+",\n")
+	s = append(s, "FetchedSeries: "+fmt.Sprintf("%#v", this.FetchedSeries)+",\n")
+	s = append(s, "FetchedChunks: "+fmt.Sprintf("%#v", this.FetchedChunks)+",\n")
+	s = append(s, "FetchedSamples: "+fmt.Sprintf("%#v", this.FetchedSamples)+",\n")
+	s = append(s, "FetchedBytes: "+fmt.Sprintf("%#v", this.FetchedBytes)+",\n")
+	s = append(s, "SeriesWritten: "+fmt.Sprintf("%#v", this.SeriesWritten)+",\n")
+	keysForFailures := make([]string, 0, len(this.Failures))
+	for k, _ := range this.Failures {
+		keysForFailures = append(keysForFailures, k)
+	}
+	github_com_gogo_protobuf_sortkeys.Strings(keysForFailures)
+	mapStringForFailures := "map[string]uint64{"
+	for _, k := range keysForFailures {
+		mapStringForFailures += fmt.Sprintf("%#v: %#v,", k, this.Failures[k])
+	}
+	mapStringForFailures += "}"
+	if this.Failures != nil {
+		s = append(s, "Failures: "+mapStringForFailures+",\n")
+	}
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -796,6 +1160,7 @@ const _ = grpc.SupportPackageIsVersion4
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type RulerClient interface {
 	Rules(ctx context.Context, in *RulesRequest, opts ...grpc.CallOption) (*RulesResponse, error)
+	SyncRules(ctx context.Context, in *SyncRulesRequest, opts ...grpc.CallOption) (*SyncRulesResponse, error)
 }
 
 type rulerClient struct {
@@ -815,9 +1180,19 @@ func (c *rulerClient) Rules(ctx context.Context, in *RulesRequest, opts ...grpc.
 	return out, nil
 }
 
+func (c *rulerClient) SyncRules(ctx context.Context, in *SyncRulesRequest, opts ...grpc.CallOption) (*SyncRulesResponse, error) {
+	out := new(SyncRulesResponse)
+	err := c.cc.Invoke(ctx, "/ruler.Ruler/SyncRules", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // RulerServer is the server API for Ruler service.
 type RulerServer interface {
 	Rules(context.Context, *RulesRequest) (*RulesResponse, error)
+	SyncRules(context.Context, *SyncRulesRequest) (*SyncRulesResponse, error)
 }
 
 // UnimplementedRulerServer can be embedded to have forward compatible implementations.
@@ -827,6 +1202,9 @@ type UnimplementedRulerServer struct {
 func (*UnimplementedRulerServer) Rules(ctx context.Context, req *RulesRequest) (*RulesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Rules not implemented")
 }
+func (*UnimplementedRulerServer) SyncRules(ctx context.Context, req *SyncRulesRequest) (*SyncRulesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SyncRules not implemented")
+}
 
 func RegisterRulerServer(s *grpc.Server, srv RulerServer) {
 	s.RegisterService(&_Ruler_serviceDesc, srv)
@@ -850,6 +1228,24 @@ func _Ruler_Rules_Handler(srv interface{}, ctx context.Context, dec func(interfa
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Ruler_SyncRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncRulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulerServer).SyncRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ruler.Ruler/SyncRules",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulerServer).SyncRules(ctx, req.(*SyncRulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Ruler_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "ruler.Ruler",
 	HandlerType: (*RulerServer)(nil),
@@ -858,6 +1254,10 @@ var _Ruler_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Rules",
 			Handler:    _Ruler_Rules_Handler,
 		},
+		{
+			MethodName: "SyncRules",
+			Handler:    _Ruler_SyncRules_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "ruler.proto",
@@ -957,7 +1357,7 @@ func (m *RulesResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
-func (m *GroupStateDesc) Marshal() (dAtA []byte, err error) {
+func (m *SyncRulesRequest) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalToSizedBuffer(dAtA[:size])
@@ -967,32 +1367,111 @@ func (m *GroupStateDesc) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *GroupStateDesc) MarshalTo(dAtA []byte) (int, error) {
+func (m *SyncRulesRequest) MarshalTo(dAtA []byte) (int, error) {
 	size := m.Size()
 	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *GroupStateDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+func (m *SyncRulesRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	i := len(dAtA)
 	_ = i
 	var l int
 	_ = l
-	n1, err1 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.EvaluationDuration, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationDuration):])
-	if err1 != nil {
-		return 0, err1
-	}
-	i -= n1
-	i = encodeVarintRuler(dAtA, i, uint64(n1))
-	i--
-	dAtA[i] = 0x22
-	n2, err2 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.EvaluationTimestamp, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.EvaluationTimestamp):])
-	if err2 != nil {
-		return 0, err2
+	if len(m.Users) > 0 {
+		for iNdEx := len(m.Users) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Users[iNdEx])
+			copy(dAtA[i:], m.Users[iNdEx])
+			i = encodeVarintRuler(dAtA, i, uint64(len(m.Users[iNdEx])))
+			i--
+			dAtA[i] = 0xa
+		}
 	}
-	i -= n2
-	i = encodeVarintRuler(dAtA, i, uint64(n2))
-	i--
-	dAtA[i] = 0x1a
+	return len(dAtA) - i, nil
+}
+
+func (m *SyncRulesResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SyncRulesResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SyncRulesResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	return len(dAtA) - i, nil
+}
+
+func (m *GroupStateDesc) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *GroupStateDesc) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *GroupStateDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.TotalStats != nil {
+		{
+			size, err := m.TotalStats.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintRuler(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x32
+	}
+	if m.LastEvaluationStats != nil {
+		{
+			size, err := m.LastEvaluationStats.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintRuler(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x2a
+	}
+	n3, err3 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.EvaluationDuration, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationDuration):])
+	if err3 != nil {
+		return 0, err3
+	}
+	i -= n3
+	i = encodeVarintRuler(dAtA, i, uint64(n3))
+	i--
+	dAtA[i] = 0x22
+	n4, err4 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.EvaluationTimestamp, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.EvaluationTimestamp):])
+	if err4 != nil {
+		return 0, err4
+	}
+	i -= n4
+	i = encodeVarintRuler(dAtA, i, uint64(n4))
+	i--
+	dAtA[i] = 0x1a
 	if len(m.ActiveRules) > 0 {
 		for iNdEx := len(m.ActiveRules) - 1; iNdEx >= 0; iNdEx-- {
 			{
@@ -1022,6 +1501,79 @@ func (m *GroupStateDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *RuleGroupStatsDesc) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *RuleGroupStatsDesc) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *RuleGroupStatsDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Failures) > 0 {
+		for k := range m.Failures {
+			v := m.Failures[k]
+			baseI := i
+			i = encodeVarintRuler(dAtA, i, uint64(v))
+			i--
+			dAtA[i] = 0x10
+			i -= len(k)
+			copy(dAtA[i:], k)
+			i = encodeVarintRuler(dAtA, i, uint64(len(k)))
+			i--
+			dAtA[i] = 0xa
+			i = encodeVarintRuler(dAtA, i, uint64(baseI-i))
+			i--
+			dAtA[i] = 0x3a
+		}
+	}
+	if m.SeriesWritten != 0 {
+		i = encodeVarintRuler(dAtA, i, uint64(m.SeriesWritten))
+		i--
+		dAtA[i] = 0x30
+	}
+	if m.FetchedBytes != 0 {
+		i = encodeVarintRuler(dAtA, i, uint64(m.FetchedBytes))
+		i--
+		dAtA[i] = 0x28
+	}
+	if m.FetchedSamples != 0 {
+		i = encodeVarintRuler(dAtA, i, uint64(m.FetchedSamples))
+		i--
+		dAtA[i] = 0x20
+	}
+	if m.FetchedChunks != 0 {
+		i = encodeVarintRuler(dAtA, i, uint64(m.FetchedChunks))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.FetchedSeries != 0 {
+		i = encodeVarintRuler(dAtA, i, uint64(m.FetchedSeries))
+		i--
+		dAtA[i] = 0x10
+	}
+	n6, err6 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.QueryWallTime, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.QueryWallTime):])
+	if err6 != nil {
+		return 0, err6
+	}
+	i -= n6
+	i = encodeVarintRuler(dAtA, i, uint64(n6))
+	i--
+	dAtA[i] = 0xa
+	return len(dAtA) - i, nil
+}
+
 func (m *RuleStateDesc) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
@@ -1042,20 +1594,20 @@ func (m *RuleStateDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
-	n4, err4 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.EvaluationDuration, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationDuration):])
-	if err4 != nil {
-		return 0, err4
+	n7, err7 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.EvaluationDuration, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationDuration):])
+	if err7 != nil {
+		return 0, err7
 	}
-	i -= n4
-	i = encodeVarintRuler(dAtA, i, uint64(n4))
+	i -= n7
+	i = encodeVarintRuler(dAtA, i, uint64(n7))
 	i--
 	dAtA[i] = 0x3a
-	n5, err5 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.EvaluationTimestamp, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.EvaluationTimestamp):])
-	if err5 != nil {
-		return 0, err5
+	n8, err8 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.EvaluationTimestamp, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.EvaluationTimestamp):])
+	if err8 != nil {
+		return 0, err8
 	}
-	i -= n5
-	i = encodeVarintRuler(dAtA, i, uint64(n5))
+	i -= n8
+	i = encodeVarintRuler(dAtA, i, uint64(n8))
 	i--
 	dAtA[i] = 0x32
 	if len(m.Alerts) > 0 {
@@ -1128,53 +1680,53 @@ func (m *AlertStateDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
-	n7, err7 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.KeepFiringSince, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.KeepFiringSince):])
-	if err7 != nil {
-		return 0, err7
-	}
-	i -= n7
-	i = encodeVarintRuler(dAtA, i, uint64(n7))
-	i--
-	dAtA[i] = 0x52
-	n8, err8 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.ValidUntil, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.ValidUntil):])
-	if err8 != nil {
-		return 0, err8
-	}
-	i -= n8
-	i = encodeVarintRuler(dAtA, i, uint64(n8))
-	i--
-	dAtA[i] = 0x4a
-	n9, err9 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.LastSentAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.LastSentAt):])
-	if err9 != nil {
-		return 0, err9
-	}
-	i -= n9
-	i = encodeVarintRuler(dAtA, i, uint64(n9))
-	i--
-	dAtA[i] = 0x42
-	n10, err10 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.ResolvedAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.ResolvedAt):])
+	n10, err10 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.KeepFiringSince, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.KeepFiringSince):])
 	if err10 != nil {
 		return 0, err10
 	}
 	i -= n10
 	i = encodeVarintRuler(dAtA, i, uint64(n10))
 	i--
-	dAtA[i] = 0x3a
-	n11, err11 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.FiredAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.FiredAt):])
+	dAtA[i] = 0x52
+	n11, err11 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.ValidUntil, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.ValidUntil):])
 	if err11 != nil {
 		return 0, err11
 	}
 	i -= n11
 	i = encodeVarintRuler(dAtA, i, uint64(n11))
 	i--
-	dAtA[i] = 0x32
-	n12, err12 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.ActiveAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.ActiveAt):])
+	dAtA[i] = 0x4a
+	n12, err12 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.LastSentAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.LastSentAt):])
 	if err12 != nil {
 		return 0, err12
 	}
 	i -= n12
 	i = encodeVarintRuler(dAtA, i, uint64(n12))
 	i--
+	dAtA[i] = 0x42
+	n13, err13 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.ResolvedAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.ResolvedAt):])
+	if err13 != nil {
+		return 0, err13
+	}
+	i -= n13
+	i = encodeVarintRuler(dAtA, i, uint64(n13))
+	i--
+	dAtA[i] = 0x3a
+	n14, err14 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.FiredAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.FiredAt):])
+	if err14 != nil {
+		return 0, err14
+	}
+	i -= n14
+	i = encodeVarintRuler(dAtA, i, uint64(n14))
+	i--
+	dAtA[i] = 0x32
+	n15, err15 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.ActiveAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.ActiveAt):])
+	if err15 != nil {
+		return 0, err15
+	}
+	i -= n15
+	i = encodeVarintRuler(dAtA, i, uint64(n15))
+	i--
 	dAtA[i] = 0x2a
 	if m.Value != 0 {
 		i -= 8
@@ -1277,6 +1829,30 @@ func (m *RulesResponse) Size() (n int) {
 	return n
 }
 
+func (m *SyncRulesRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Users) > 0 {
+		for _, s := range m.Users {
+			l = len(s)
+			n += 1 + l + sovRuler(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *SyncRulesResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
 func (m *GroupStateDesc) Size() (n int) {
 	if m == nil {
 		return 0
@@ -1297,6 +1873,48 @@ func (m *GroupStateDesc) Size() (n int) {
 	n += 1 + l + sovRuler(uint64(l))
 	l = github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationDuration)
 	n += 1 + l + sovRuler(uint64(l))
+	if m.LastEvaluationStats != nil {
+		l = m.LastEvaluationStats.Size()
+		n += 1 + l + sovRuler(uint64(l))
+	}
+	if m.TotalStats != nil {
+		l = m.TotalStats.Size()
+		n += 1 + l + sovRuler(uint64(l))
+	}
+	return n
+}
+
+func (m *RuleGroupStatsDesc) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = github_com_gogo_protobuf_types.SizeOfStdDuration(m.QueryWallTime)
+	n += 1 + l + sovRuler(uint64(l))
+	if m.FetchedSeries != 0 {
+		n += 1 + sovRuler(uint64(m.FetchedSeries))
+	}
+	if m.FetchedChunks != 0 {
+		n += 1 + sovRuler(uint64(m.FetchedChunks))
+	}
+	if m.FetchedSamples != 0 {
+		n += 1 + sovRuler(uint64(m.FetchedSamples))
+	}
+	if m.FetchedBytes != 0 {
+		n += 1 + sovRuler(uint64(m.FetchedBytes))
+	}
+	if m.SeriesWritten != 0 {
+		n += 1 + sovRuler(uint64(m.SeriesWritten))
+	}
+	if len(m.Failures) > 0 {
+		for k, v := range m.Failures {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovRuler(uint64(len(k))) + 1 + sovRuler(uint64(v))
+			n += mapEntrySize + 1 + sovRuler(uint64(mapEntrySize))
+		}
+	}
 	return n
 }
 
@@ -1409,6 +2027,25 @@ func (this *RulesResponse) String() string {
 	}, "")
 	return s
 }
+func (this *SyncRulesRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&SyncRulesRequest{`,
+		`Users:` + fmt.Sprintf("%v", this.Users) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *SyncRulesResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&SyncRulesResponse{`,
+		`}`,
+	}, "")
+	return s
+}
 func (this *GroupStateDesc) String() string {
 	if this == nil {
 		return "nil"
@@ -1423,6 +2060,34 @@ func (this *GroupStateDesc) String() string {
 		`ActiveRules:` + repeatedStringForActiveRules + `,`,
 		`EvaluationTimestamp:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.EvaluationTimestamp), "Timestamp", "timestamp.Timestamp", 1), `&`, ``, 1) + `,`,
 		`EvaluationDuration:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.EvaluationDuration), "Duration", "duration.Duration", 1), `&`, ``, 1) + `,`,
+		`LastEvaluationStats:` + strings.Replace(this.LastEvaluationStats.String(), "RuleGroupStatsDesc", "RuleGroupStatsDesc", 1) + `,`,
+		`TotalStats:` + strings.Replace(this.TotalStats.String(), "RuleGroupStatsDesc", "RuleGroupStatsDesc", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *RuleGroupStatsDesc) String() string {
+	if this == nil {
+		return "nil"
+	}
+	keysForFailures := make([]string, 0, len(this.Failures))
+	for k, _ := range this.Failures {
+		keysForFailures = append(keysForFailures, k)
+	}
+	github_com_gogo_protobuf_sortkeys.Strings(keysForFailures)
+	mapStringForFailures := "map[string]uint64{"
+	for _, k := range keysForFailures {
+		mapStringForFailures += fmt.Sprintf("%v: %v,", k, this.Failures[k])
+	}
+	mapStringForFailures += "}"
+	s := strings.Join([]string{`&RuleGroupStatsDesc{`,
+		`QueryWallTime:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.QueryWallTime), "Duration", "duration.Duration", 1), `&`, ``, 1) + `,`,
+		`FetchedSeries:` + fmt.Sprintf("%v", this.FetchedSeries) + `,`,
+		`FetchedChunks:` + fmt.Sprintf("%v", this.FetchedChunks) + `,`,
+		`FetchedSamples:` + fmt.Sprintf("%v", this.FetchedSamples) + `,`,
+		`FetchedBytes:` + fmt.Sprintf("%v", this.FetchedBytes) + `,`,
+		`SeriesWritten:` + fmt.Sprintf("%v", this.SeriesWritten) + `,`,
+		`Failures:` + mapStringForFailures + `,`,
 		`}`,
 	}, "")
 	return s
@@ -1527,20 +2192,452 @@ func (m *RulesRequest) Unmarshal(dAtA []byte) error {
 			if intStringLen < 0 {
 				return ErrInvalidLengthRuler
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRuler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.RuleNames = append(m.RuleNames, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RuleGroupNames", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRuler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRuler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.RuleGroupNames = append(m.RuleGroupNames, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Files", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRuler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRuler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Files = append(m.Files, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Type", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRuler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRuler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Type = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRuler(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthRuler
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *RulesResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRuler
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: RulesResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: RulesResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Groups", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRuler
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRuler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Groups = append(m.Groups, &GroupStateDesc{})
+			if err := m.Groups[len(m.Groups)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRuler(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthRuler
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SyncRulesRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRuler
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SyncRulesRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SyncRulesRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Users", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRuler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRuler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Users = append(m.Users, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRuler(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthRuler
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SyncRulesResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRuler
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SyncRulesResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SyncRulesResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRuler(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthRuler
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *GroupStateDesc) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRuler
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: GroupStateDesc: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: GroupStateDesc: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Group", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRuler
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRuler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Group == nil {
+				m.Group = &rulespb.RuleGroupDesc{}
+			}
+			if err := m.Group.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ActiveRules", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRuler
+			}
+			postIndex := iNdEx + msglen
 			if postIndex < 0 {
 				return ErrInvalidLengthRuler
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.RuleNames = append(m.RuleNames, string(dAtA[iNdEx:postIndex]))
+			m.ActiveRules = append(m.ActiveRules, &RuleStateDesc{})
+			if err := m.ActiveRules[len(m.ActiveRules)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
 			iNdEx = postIndex
-		case 2:
+		case 3:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field RuleGroupNames", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field EvaluationTimestamp", wireType)
 			}
-			var stringLen uint64
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRuler
@@ -1550,29 +2647,30 @@ func (m *RulesRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				msglen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthRuler
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + msglen
 			if postIndex < 0 {
 				return ErrInvalidLengthRuler
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.RuleGroupNames = append(m.RuleGroupNames, string(dAtA[iNdEx:postIndex]))
+			if err := github_com_gogo_protobuf_types.StdTimeUnmarshal(&m.EvaluationTimestamp, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
 			iNdEx = postIndex
-		case 3:
+		case 4:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Files", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field EvaluationDuration", wireType)
 			}
-			var stringLen uint64
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRuler
@@ -1582,29 +2680,30 @@ func (m *RulesRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				msglen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthRuler
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + msglen
 			if postIndex < 0 {
 				return ErrInvalidLengthRuler
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Files = append(m.Files, string(dAtA[iNdEx:postIndex]))
+			if err := github_com_gogo_protobuf_types.StdDurationUnmarshal(&m.EvaluationDuration, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
 			iNdEx = postIndex
-		case 4:
+		case 5:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Type", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field LastEvaluationStats", wireType)
 			}
-			var stringLen uint64
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRuler
@@ -1614,80 +2713,31 @@ func (m *RulesRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				msglen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthRuler
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + msglen
 			if postIndex < 0 {
 				return ErrInvalidLengthRuler
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Type = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRuler(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRuler
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthRuler
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *RulesResponse) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRuler
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
+			if m.LastEvaluationStats == nil {
+				m.LastEvaluationStats = &RuleGroupStatsDesc{}
 			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
+			if err := m.LastEvaluationStats.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
 			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: RulesResponse: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: RulesResponse: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
+			iNdEx = postIndex
+		case 6:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Groups", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field TotalStats", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -1714,8 +2764,10 @@ func (m *RulesResponse) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Groups = append(m.Groups, &GroupStateDesc{})
-			if err := m.Groups[len(m.Groups)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			if m.TotalStats == nil {
+				m.TotalStats = &RuleGroupStatsDesc{}
+			}
+			if err := m.TotalStats.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
@@ -1725,10 +2777,7 @@ func (m *RulesResponse) Unmarshal(dAtA []byte) error {
 			if err != nil {
 				return err
 			}
-			if skippy < 0 {
-				return ErrInvalidLengthRuler
-			}
-			if (iNdEx + skippy) < 0 {
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthRuler
 			}
 			if (iNdEx + skippy) > l {
@@ -1743,7 +2792,7 @@ func (m *RulesResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *GroupStateDesc) Unmarshal(dAtA []byte) error {
+func (m *RuleGroupStatsDesc) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1766,15 +2815,15 @@ func (m *GroupStateDesc) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: GroupStateDesc: wiretype end group for non-group")
+			return fmt.Errorf("proto: RuleGroupStatsDesc: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: GroupStateDesc: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: RuleGroupStatsDesc: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Group", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field QueryWallTime", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -1801,18 +2850,15 @@ func (m *GroupStateDesc) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Group == nil {
-				m.Group = &rulespb.RuleGroupDesc{}
-			}
-			if err := m.Group.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			if err := github_com_gogo_protobuf_types.StdDurationUnmarshal(&m.QueryWallTime, dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
 		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ActiveRules", wireType)
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FetchedSeries", wireType)
 			}
-			var msglen int
+			m.FetchedSeries = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRuler
@@ -1822,31 +2868,35 @@ func (m *GroupStateDesc) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				m.FetchedSeries |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
-				return ErrInvalidLengthRuler
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRuler
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FetchedChunks", wireType)
 			}
-			m.ActiveRules = append(m.ActiveRules, &RuleStateDesc{})
-			if err := m.ActiveRules[len(m.ActiveRules)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			m.FetchedChunks = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.FetchedChunks |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			iNdEx = postIndex
-		case 3:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field EvaluationTimestamp", wireType)
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FetchedSamples", wireType)
 			}
-			var msglen int
+			m.FetchedSamples = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRuler
@@ -1856,28 +2906,52 @@ func (m *GroupStateDesc) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				m.FetchedSamples |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
-				return ErrInvalidLengthRuler
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FetchedBytes", wireType)
 			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthRuler
+			m.FetchedBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.FetchedBytes |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SeriesWritten", wireType)
 			}
-			if err := github_com_gogo_protobuf_types.StdTimeUnmarshal(&m.EvaluationTimestamp, dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			m.SeriesWritten = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SeriesWritten |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			iNdEx = postIndex
-		case 4:
+		case 7:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field EvaluationDuration", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Failures", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -1904,9 +2978,89 @@ func (m *GroupStateDesc) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if err := github_com_gogo_protobuf_types.StdDurationUnmarshal(&m.EvaluationDuration, dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			if m.Failures == nil {
+				m.Failures = make(map[string]uint64)
+			}
+			var mapkey string
+			var mapvalue uint64
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowRuler
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowRuler
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthRuler
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey < 0 {
+						return ErrInvalidLengthRuler
+					}
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowRuler
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						mapvalue |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipRuler(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if (skippy < 0) || (iNdEx+skippy) < 0 {
+						return ErrInvalidLengthRuler
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
 			}
+			m.Failures[mapkey] = mapvalue
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -1914,10 +3068,7 @@ func (m *GroupStateDesc) Unmarshal(dAtA []byte) error {
 			if err != nil {
 				return err
 			}
-			if skippy < 0 {
-				return ErrInvalidLengthRuler
-			}
-			if (iNdEx + skippy) < 0 {
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthRuler
 			}
 			if (iNdEx + skippy) > l {
@@ -2199,10 +3350,7 @@ func (m *RuleStateDesc) Unmarshal(dAtA []byte) error {
 			if err != nil {
 				return err
 			}
-			if skippy < 0 {
-				return ErrInvalidLengthRuler
-			}
-			if (iNdEx + skippy) < 0 {
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthRuler
 			}
 			if (iNdEx + skippy) > l {
@@ -2561,10 +3709,7 @@ func (m *AlertStateDesc) Unmarshal(dAtA []byte) error {
 			if err != nil {
 				return err
 			}
-			if skippy < 0 {
-				return ErrInvalidLengthRuler
-			}
-			if (iNdEx + skippy) < 0 {
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthRuler
 			}
 			if (iNdEx + skippy) > l {
@@ -2582,6 +3727,7 @@ func (m *AlertStateDesc) Unmarshal(dAtA []byte) error {
 func skipRuler(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
+	depth := 0
 	for iNdEx < l {
 		var wire uint64
 		for shift := uint(0); ; shift += 7 {
@@ -2613,10 +3759,8 @@ func skipRuler(dAtA []byte) (n int, err error) {
 					break
 				}
 			}
-			return iNdEx, nil
 		case 1:
 			iNdEx += 8
-			return iNdEx, nil
 		case 2:
 			var length int
 			for shift := uint(0); ; shift += 7 {
@@ -2637,55 +3781,30 @@ func skipRuler(dAtA []byte) (n int, err error) {
 				return 0, ErrInvalidLengthRuler
 			}
 			iNdEx += length
-			if iNdEx < 0 {
-				return 0, ErrInvalidLengthRuler
-			}
-			return iNdEx, nil
 		case 3:
-			for {
-				var innerWire uint64
-				var start int = iNdEx
-				for shift := uint(0); ; shift += 7 {
-					if shift >= 64 {
-						return 0, ErrIntOverflowRuler
-					}
-					if iNdEx >= l {
-						return 0, io.ErrUnexpectedEOF
-					}
-					b := dAtA[iNdEx]
-					iNdEx++
-					innerWire |= (uint64(b) & 0x7F) << shift
-					if b < 0x80 {
-						break
-					}
-				}
-				innerWireType := int(innerWire & 0x7)
-				if innerWireType == 4 {
-					break
-				}
-				next, err := skipRuler(dAtA[start:])
-				if err != nil {
-					return 0, err
-				}
-				iNdEx = start + next
-				if iNdEx < 0 {
-					return 0, ErrInvalidLengthRuler
-				}
-			}
-			return iNdEx, nil
+			depth++
 		case 4:
-			return iNdEx, nil
+			if depth == 0 {
+				return 0, ErrUnexpectedEndOfGroupRuler
+			}
+			depth--
 		case 5:
 			iNdEx += 4
-			return iNdEx, nil
 		default:
 			return 0, fmt.Errorf("proto: illegal wireType %d", wireType)
 		}
+		if iNdEx < 0 {
+			return 0, ErrInvalidLengthRuler
+		}
+		if depth == 0 {
+			return iNdEx, nil
+		}
 	}
-	panic("unreachable")
+	return 0, io.ErrUnexpectedEOF
 }
 
 var (
-	ErrInvalidLengthRuler = fmt.Errorf("proto: negative length found during unmarshaling")
-	ErrIntOverflowRuler   = fmt.Errorf("proto: integer overflow")
+	ErrInvalidLengthRuler        = fmt.Errorf("proto: negative length found during unmarshaling")
+	ErrIntOverflowRuler          = fmt.Errorf("proto: integer overflow")
+	ErrUnexpectedEndOfGroupRuler = fmt.Errorf("proto: unexpected end of group")
 )
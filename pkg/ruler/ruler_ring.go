@@ -40,6 +40,7 @@ var ListRuleRingOp = ring.NewOp([]ring.InstanceState{ring.ACTIVE, ring.LEAVING},
 type RingConfig struct {
 	KVStore              kv.Config     `yaml:"kvstore"`
 	HeartbeatPeriod      time.Duration `yaml:"heartbeat_period"`
+	CASBatchPeriod       time.Duration `yaml:"cas_batch_period"`
 	HeartbeatTimeout     time.Duration `yaml:"heartbeat_timeout"`
 	ReplicationFactor    int           `yaml:"replication_factor"`
 	ZoneAwarenessEnabled bool          `yaml:"zone_awareness_enabled"`
@@ -52,6 +53,9 @@ type RingConfig struct {
 	InstanceZone           string   `yaml:"instance_availability_zone" doc:"hidden"`
 	NumTokens              int      `yaml:"num_tokens"`
 
+	TokensGeneratorStrategy string `yaml:"tokens_generator_strategy"`
+	TokensStaticFile        string `yaml:"tokens_static_file"`
+
 	FinalSleep time.Duration `yaml:"final_sleep"`
 
 	// Injected internally
@@ -71,6 +75,7 @@ func (cfg *RingConfig) RegisterFlags(f *flag.FlagSet) {
 	// Ring flags
 	cfg.KVStore.RegisterFlagsWithPrefix("ruler.ring.", "rulers/", f)
 	f.DurationVar(&cfg.HeartbeatPeriod, "ruler.ring.heartbeat-period", 5*time.Second, "Period at which to heartbeat to the ring. 0 = disabled.")
+	f.DurationVar(&cfg.CASBatchPeriod, "ruler.ring.cas-batch-period", 0, "EXPERIMENTAL: Period within which the ring updates of the ruler, including the heartbeats, are batched with the ring updates of the other components of the same process into a single KV store transaction. When enabled, the heartbeats are aligned to the heartbeat period, so that the heartbeats of the components with the same heartbeat period, or a multiple of it, are batched together. Supported by the consul, etcd and memberlist KV stores. 0 = disabled.")
 	f.DurationVar(&cfg.HeartbeatTimeout, "ruler.ring.heartbeat-timeout", time.Minute, "The heartbeat timeout after which rulers are considered unhealthy within the ring. 0 = never (timeout disabled).")
 	f.DurationVar(&cfg.FinalSleep, "ruler.ring.final-sleep", 0*time.Second, "The sleep seconds when ruler is shutting down. Need to be close to or larger than KV Store information propagation delay")
 	f.IntVar(&cfg.ReplicationFactor, "ruler.ring.replication-factor", 1, "EXPERIMENTAL: The replication factor to use when loading rule groups for API HA.")
@@ -84,6 +89,8 @@ func (cfg *RingConfig) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&cfg.InstanceID, "ruler.ring.instance-id", hostname, "Instance ID to register in the ring.")
 	f.StringVar(&cfg.InstanceZone, "ruler.ring.instance-availability-zone", "", "The availability zone where this instance is running. Required if zone-awareness is enabled.")
 	f.IntVar(&cfg.NumTokens, "ruler.ring.num-tokens", 128, "Number of tokens for each ruler.")
+	f.StringVar(&cfg.TokensGeneratorStrategy, "ruler.ring.tokens-generator-strategy", "random", ring.TokensGeneratorStrategyFlagDescription)
+	f.StringVar(&cfg.TokensStaticFile, "ruler.ring.tokens-static-file", "", "EXPERIMENTAL: Path of the YAML file mapping the instance IDs to their tokens, used by the static token generator strategy.")
 }
 
 // ToLifecyclerConfig returns a LifecyclerConfig based on the ruler
@@ -97,13 +104,16 @@ func (cfg *RingConfig) ToLifecyclerConfig(logger log.Logger) (ring.BasicLifecycl
 	instancePort := ring.GetInstancePort(cfg.InstancePort, cfg.ListenPort)
 
 	return ring.BasicLifecyclerConfig{
-		ID:                  cfg.InstanceID,
-		Addr:                fmt.Sprintf("%s:%d", instanceAddr, instancePort),
-		Zone:                cfg.InstanceZone,
-		HeartbeatPeriod:     cfg.HeartbeatPeriod,
-		TokensObservePeriod: 0,
-		NumTokens:           cfg.NumTokens,
-		FinalSleep:          cfg.FinalSleep,
+		ID:                      cfg.InstanceID,
+		Addr:                    fmt.Sprintf("%s:%d", instanceAddr, instancePort),
+		Zone:                    cfg.InstanceZone,
+		HeartbeatPeriod:         cfg.HeartbeatPeriod,
+		AlignHeartbeats:         cfg.CASBatchPeriod > 0,
+		TokensObservePeriod:     0,
+		NumTokens:               cfg.NumTokens,
+		TokensGeneratorStrategy: cfg.TokensGeneratorStrategy,
+		TokensStaticFile:        cfg.TokensStaticFile,
+		FinalSleep:              cfg.FinalSleep,
 	}, nil
 }
 
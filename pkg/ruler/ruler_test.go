@@ -88,6 +88,16 @@ type ruleLimits struct {
 	maxRuleGroups        int
 	disabledRuleGroups   validation.DisabledRuleGroups
 	maxQueryLength       time.Duration
+	allowedSourceTenants []string
+	allowedDestTenants   []string
+	maxConcurrentEvals   int
+	queryTimeout         time.Duration
+	maxQueryBytes        int64
+	maxSeriesPerRule     int
+	maxAlertsPerRule     int
+	maxSeriesPerGroup    int
+	allowedAMTenants     []string
+	allowedAMURLs        []string
 }
 
 func (r ruleLimits) EvaluationDelay(_ string) time.Duration {
@@ -112,6 +122,26 @@ func (r ruleLimits) DisabledRuleGroups(userID string) validation.DisabledRuleGro
 
 func (r ruleLimits) MaxQueryLength(_ string) time.Duration { return r.maxQueryLength }
 
+func (r ruleLimits) RulerAllowedSourceTenants(_ string) []string { return r.allowedSourceTenants }
+
+func (r ruleLimits) RulerAllowedDestinationTenants(_ string) []string { return r.allowedDestTenants }
+
+func (r ruleLimits) RulerAllowedAlertmanagerTenants(_ string) []string { return r.allowedAMTenants }
+
+func (r ruleLimits) RulerAllowedAlertmanagerURLs(_ string) []string { return r.allowedAMURLs }
+
+func (r ruleLimits) RulerMaxConcurrentEvaluations(_ string) int { return r.maxConcurrentEvals }
+
+func (r ruleLimits) RulerEvaluationQueryTimeout(_ string) time.Duration { return r.queryTimeout }
+
+func (r ruleLimits) RulerMaxQueryBytesPerMinute(_ string) int64 { return r.maxQueryBytes }
+
+func (r ruleLimits) RulerMaxSeriesPerRule(_ string) int { return r.maxSeriesPerRule }
+
+func (r ruleLimits) RulerMaxAlertsPerRule(_ string) int { return r.maxAlertsPerRule }
+
+func (r ruleLimits) RulerMaxSeriesPerRuleGroup(_ string) int { return r.maxSeriesPerGroup }
+
 func newEmptyQueryable() storage.Queryable {
 	return storage.QueryableFunc(func(mint, maxt int64) (storage.Querier, error) {
 		return emptyQuerier{}, nil
@@ -143,7 +173,7 @@ func testQueryableFunc(querierTestConfig *querier.TestConfig, reg prometheus.Reg
 		querierTestConfig.Cfg.ActiveQueryTrackerDir = ""
 
 		overrides, _ := validation.NewOverrides(querier.DefaultLimitsConfig(), nil)
-		q, _, _ := querier.New(querierTestConfig.Cfg, overrides, querierTestConfig.Distributor, querierTestConfig.Stores, reg, logger)
+		q, _, _ := querier.New(querierTestConfig.Cfg, overrides, querierTestConfig.Distributor, querierTestConfig.Stores, nil, reg, logger)
 		return func(mint, maxt int64) (storage.Querier, error) {
 			return q.Querier(mint, maxt)
 		}
@@ -180,7 +210,7 @@ func newManager(t *testing.T, cfg Config) *DefaultMultiTenantManager {
 	engine, queryable, pusher, logger, overrides, reg := testSetup(t, nil)
 	metrics := NewRuleEvalMetrics(cfg, nil)
 	managerFactory := DefaultTenantManagerFactory(cfg, pusher, queryable, engine, overrides, metrics, nil)
-	manager, err := NewDefaultMultiTenantManager(cfg, managerFactory, metrics, reg, logger)
+	manager, err := NewDefaultMultiTenantManager(cfg, managerFactory, nil, nil, metrics, reg, logger)
 	require.NoError(t, err)
 
 	return manager
@@ -203,6 +233,11 @@ func (c *mockRulerClient) Rules(ctx context.Context, in *RulesRequest, _ ...grpc
 	return c.ruler.Rules(ctx, in)
 }
 
+func (c *mockRulerClient) SyncRules(ctx context.Context, in *SyncRulesRequest, _ ...grpc.CallOption) (*SyncRulesResponse, error) {
+	c.numberOfCalls.Inc()
+	return c.ruler.SyncRules(ctx, in)
+}
+
 func (p *mockRulerClientsPool) GetClientFor(addr string) (RulerClient, error) {
 	for _, r := range p.rulerAddrMap {
 		if r.lifecycler.GetInstanceAddr() == addr {
@@ -228,7 +263,7 @@ func buildRuler(t *testing.T, rulerConfig Config, querierTestConfig *querier.Tes
 	engine, queryable, pusher, logger, overrides, reg := testSetup(t, querierTestConfig)
 	metrics := NewRuleEvalMetrics(rulerConfig, reg)
 	managerFactory := DefaultTenantManagerFactory(rulerConfig, pusher, queryable, engine, overrides, metrics, reg)
-	manager, err := NewDefaultMultiTenantManager(rulerConfig, managerFactory, metrics, reg, log.NewNopLogger())
+	manager, err := NewDefaultMultiTenantManager(rulerConfig, managerFactory, nil, nil, metrics, reg, log.NewNopLogger())
 	require.NoError(t, err)
 
 	ruler, err := newRuler(
@@ -801,7 +836,7 @@ func TestGetRules(t *testing.T) {
 			}
 
 			if tc.sharding {
-				err := kvStore.CAS(context.Background(), ringKey, func(in interface{}) (out interface{}, retry bool, err error) {
+				err := kvStore.CAS(context.Background(), RingKey, func(in interface{}) (out interface{}, retry bool, err error) {
 					d, _ := in.(*ring.Desc)
 					if d == nil {
 						d = ring.NewDesc()
@@ -829,7 +864,7 @@ func TestGetRules(t *testing.T) {
 
 			if tc.sharding {
 				// update the State of the rulers in the ring based on tc.rulerStateMap
-				err := kvStore.CAS(context.Background(), ringKey, func(in interface{}) (out interface{}, retry bool, err error) {
+				err := kvStore.CAS(context.Background(), RingKey, func(in interface{}) (out interface{}, retry bool, err error) {
 					d, _ := in.(*ring.Desc)
 					if d == nil {
 						d = ring.NewDesc()
@@ -1036,7 +1071,7 @@ func TestGetRulesFromBackup(t *testing.T) {
 		}
 	}
 
-	err := kvStore.CAS(context.Background(), ringKey, func(in interface{}) (out interface{}, retry bool, err error) {
+	err := kvStore.CAS(context.Background(), RingKey, func(in interface{}) (out interface{}, retry bool, err error) {
 		d, _ := in.(*ring.Desc)
 		if d == nil {
 			d = ring.NewDesc()
@@ -1062,7 +1097,7 @@ func TestGetRulesFromBackup(t *testing.T) {
 	})
 
 	// update the State of the rulers in the ring based on tc.rulerStateMap
-	err = kvStore.CAS(context.Background(), ringKey, func(in interface{}) (out interface{}, retry bool, err error) {
+	err = kvStore.CAS(context.Background(), RingKey, func(in interface{}) (out interface{}, retry bool, err error) {
 		d, _ := in.(*ring.Desc)
 		if d == nil {
 			d = ring.NewDesc()
@@ -1629,7 +1664,7 @@ func TestSharding(t *testing.T) {
 			}
 
 			if tc.setupRing != nil {
-				err := kvStore.CAS(context.Background(), ringKey, func(in interface{}) (out interface{}, retry bool, err error) {
+				err := kvStore.CAS(context.Background(), RingKey, func(in interface{}) (out interface{}, retry bool, err error) {
 					d, _ := in.(*ring.Desc)
 					if d == nil {
 						d = ring.NewDesc()
@@ -1749,6 +1784,7 @@ func Test_LoadPartialGroups(t *testing.T) {
 			ReplicationFactor: 1,
 		},
 		FlushCheckPeriod: 0,
+		RulePath:         t.TempDir(),
 	}
 
 	r1, manager := buildRuler(t, cfg, nil, store, nil)
@@ -1757,7 +1793,7 @@ func Test_LoadPartialGroups(t *testing.T) {
 	require.NoError(t, services.StartAndAwaitRunning(context.Background(), r1))
 	t.Cleanup(r1.StopAsync)
 
-	err := kvStore.CAS(context.Background(), ringKey, func(in interface{}) (out interface{}, retry bool, err error) {
+	err := kvStore.CAS(context.Background(), RingKey, func(in interface{}) (out interface{}, retry bool, err error) {
 		d, _ := in.(*ring.Desc)
 		if d == nil {
 			d = ring.NewDesc()
@@ -2302,7 +2338,7 @@ func TestRulerDisablesRuleGroups(t *testing.T) {
 			}
 
 			if tc.setupRing != nil {
-				err := kvStore.CAS(context.Background(), ringKey, func(in interface{}) (out interface{}, retry bool, err error) {
+				err := kvStore.CAS(context.Background(), RingKey, func(in interface{}) (out interface{}, retry bool, err error) {
 					d, _ := in.(*ring.Desc)
 					if d == nil {
 						d = ring.NewDesc()
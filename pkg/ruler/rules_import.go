@@ -0,0 +1,213 @@
+package ruler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex/pkg/ruler/rulespb"
+	util_api "github.com/cortexproject/cortex/pkg/util/api"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+)
+
+const (
+	// The Thanos partial response strategies of the rule groups.
+	partialResponseStrategyAbort = "abort"
+	partialResponseStrategyWarn  = "warn"
+
+	errImportNoRuleGroups               = "the rule file has no rule groups"
+	errImportDuplicateRuleGroup         = "rule group %q: duplicate rule group name"
+	errImportInvalidRuleGroup           = "rule group %q: %s"
+	errImportInvalidPartialResponse     = "rule group %q: invalid partial response strategy %q"
+	warnImportPartialResponseWarn       = "rule group %q: the partial response strategy %q is not supported, the rules fail on partial query responses"
+	warnImportIntervalExceedsLease      = "rule group %q: the interval %s is not less than the evaluation leases timeout %s, the rule group can be evaluated by several rulers"
+	warnImportForShorterThanTheInterval = "rule group %q: the for duration %s of the alerting rule %q is shorter than the interval %s, the alert fires at its second evaluation"
+)
+
+// importRuleGroup is a rule group of a Prometheus rule file, which can also have the fields of the
+// Cortex rule groups, and the partial response strategy of the Thanos rule groups.
+type importRuleGroup struct {
+	rulespb.RuleGroup `yaml:",inline"`
+	// PartialResponseStrategy is the Thanos partial response strategy of the rule group, ignored since
+	// the rules always fail on partial query responses, like the "abort" strategy.
+	PartialResponseStrategy string `yaml:"partial_response_strategy,omitempty"`
+}
+
+// importRuleFile is a Prometheus or Thanos rule file.
+type importRuleFile struct {
+	Groups []importRuleGroup `yaml:"groups"`
+}
+
+// RulesImportResult is the result of the import of a rule file in a rule namespace.
+type RulesImportResult struct {
+	Namespace string   `json:"namespace"`
+	Groups    []string `json:"groups"`
+	DryRun    bool     `json:"dryRun"`
+}
+
+// ImportRules replaces the rule groups of a rule namespace with the ones of the Prometheus or Thanos
+// rule file in the request body, once they're validated and linted. The rule groups of the namespace
+// missing from the rule file are deleted. With the "dry_run" parameter, the rule file is only
+// validated and linted.
+func (a *API) ImportRules(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+	userID, namespace, _, err := parseRequest(req, true, false)
+	if err != nil {
+		util_api.RespondError(logger, w, v1.ErrBadData, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dryRun := false
+	if v := req.FormValue("dry_run"); v != "" {
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			util_api.RespondError(logger, w, v1.ErrBadData, fmt.Sprintf("invalid dry_run parameter: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	payload, err := io.ReadAll(req.Body)
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to read rule file payload", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	file, err := parseImportRuleFile(payload)
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to unmarshal rule file payload", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	errs, warnings := a.ruler.lintImportRuleFile(userID, file)
+	if len(errs) > 0 {
+		level.Error(logger).Log("msg", "rule file validation failure", "err", strings.Join(errs, ", "), "user", userID)
+		http.Error(w, strings.Join(errs, "\n"), http.StatusBadRequest)
+		return
+	}
+
+	result := RulesImportResult{Namespace: namespace, Groups: make([]string, 0, len(file.Groups)), DryRun: dryRun}
+	groups := make([]*rulespb.RuleGroupDesc, 0, len(file.Groups))
+	for _, g := range file.Groups {
+		groups = append(groups, rulespb.ToProtoWithTenants(userID, namespace, g.RuleGroup))
+		result.Groups = append(result.Groups, g.Name)
+	}
+
+	if a.ruler.HasMaxRuleGroupsLimit(userID) {
+		all, err := a.store.ListRuleGroupsForUserAndNamespace(req.Context(), userID, "")
+		if err != nil {
+			level.Error(logger).Log("msg", "unable to fetch current rule groups for validation", "err", err.Error(), "user", userID)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		others := 0
+		for _, g := range all {
+			if g.Namespace != namespace {
+				others++
+			}
+		}
+		if err := a.ruler.AssertMaxRuleGroups(userID, others+len(groups)); err != nil {
+			level.Error(logger).Log("msg", "limit validation failure", "err", err.Error(), "user", userID)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	status := http.StatusOK
+	if !dryRun {
+		// The versioned rule store records a single version of the namespace for the whole import.
+		if versioned, ok := a.store.(*VersionedRuleStore); ok {
+			err = versioned.Rollback(req.Context(), userID, namespace, groups)
+		} else {
+			err = replaceNamespaceRuleGroups(req.Context(), a.store, userID, namespace, groups)
+		}
+		if err != nil {
+			level.Error(logger).Log("msg", "unable to store imported rule groups", "err", err.Error(), "user", userID)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		status = http.StatusAccepted
+	}
+
+	data, err := json.Marshal(&util_api.Response{
+		Status:   "success",
+		Data:     result,
+		Warnings: warnings,
+	})
+	if err != nil {
+		level.Error(logger).Log("msg", "error marshaling json response", "err", err)
+		util_api.RespondError(logger, w, v1.ErrServer, "unable to marshal the requested data", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if n, err := w.Write(data); err != nil {
+		level.Error(logger).Log("msg", "error writing response", "bytesWritten", n, "err", err)
+	}
+}
+
+// parseImportRuleFile parses a Prometheus or Thanos rule file, failing on the unknown fields.
+func parseImportRuleFile(payload []byte) (importRuleFile, error) {
+	file := importRuleFile{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(payload))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && err != io.EOF {
+		return importRuleFile{}, err
+	}
+	if len(file.Groups) == 0 {
+		return importRuleFile{}, errors.New(errImportNoRuleGroups)
+	}
+	return file, nil
+}
+
+// lintImportRuleFile returns the errors of the rule groups of the rule file, which can't be imported,
+// and the warnings about the rule groups which are imported but may not behave as expected.
+func (r *Ruler) lintImportRuleFile(userID string, file importRuleFile) (errs []string, warnings []string) {
+	names := make(map[string]struct{}, len(file.Groups))
+	for _, g := range file.Groups {
+		if _, ok := names[g.Name]; ok {
+			errs = append(errs, fmt.Sprintf(errImportDuplicateRuleGroup, g.Name))
+			continue
+		}
+		names[g.Name] = struct{}{}
+
+		switch strings.ToLower(g.PartialResponseStrategy) {
+		case "", partialResponseStrategyAbort:
+		case partialResponseStrategyWarn:
+			warnings = append(warnings, fmt.Sprintf(warnImportPartialResponseWarn, g.Name, g.PartialResponseStrategy))
+		default:
+			errs = append(errs, fmt.Sprintf(errImportInvalidPartialResponse, g.Name, g.PartialResponseStrategy))
+		}
+
+		if err := r.AssertRuleGroupValid(userID, g.RuleGroup); err != nil {
+			errs = append(errs, fmt.Sprintf(errImportInvalidRuleGroup, g.Name, err))
+			continue
+		}
+
+		interval := time.Duration(g.Interval)
+		if interval == 0 {
+			interval = r.cfg.EvaluationInterval
+		}
+		if r.cfg.EvaluationLeases.Enabled && interval >= r.cfg.EvaluationLeases.Timeout {
+			warnings = append(warnings, fmt.Sprintf(warnImportIntervalExceedsLease, g.Name, interval, r.cfg.EvaluationLeases.Timeout))
+		}
+		for _, rule := range g.Rules {
+			if rule.Alert.Value != "" && rule.For > 0 && time.Duration(rule.For) < interval {
+				warnings = append(warnings, fmt.Sprintf(warnImportForShorterThanTheInterval, g.Name, rule.For, rule.Alert.Value, interval))
+			}
+		}
+	}
+	return errs, warnings
+}
@@ -0,0 +1,176 @@
+package ruler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/ruler/rulespb"
+	util_api "github.com/cortexproject/cortex/pkg/util/api"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+func TestRuler_ImportRules(t *testing.T) {
+	tc := []struct {
+		name     string
+		input    string
+		query    string
+		status   int
+		error    string
+		warnings []string
+		groups   []string
+	}{
+		{
+			name:   "Prometheus rule file",
+			status: http.StatusAccepted,
+			input: `
+groups:
+- name: recording
+  interval: 30s
+  rules:
+  - record: up:sum
+    expr: sum(up)
+- name: alerting
+  rules:
+  - alert: Down
+    expr: up == 0
+    for: 5m
+`,
+			groups: []string{"recording", "alerting"},
+		},
+		{
+			name:   "Thanos rule file",
+			status: http.StatusAccepted,
+			input: `
+groups:
+- name: abort
+  partial_response_strategy: abort
+  rules:
+  - record: up:sum
+    expr: sum(up)
+- name: warn
+  partial_response_strategy: warn
+  rules:
+  - alert: Down
+    expr: up == 0
+    for: 30s
+`,
+			warnings: []string{
+				`rule group "warn": the partial response strategy "warn" is not supported, the rules fail on partial query responses`,
+				`rule group "warn": the for duration 30s of the alerting rule "Down" is shorter than the interval 1m0s, the alert fires at its second evaluation`,
+			},
+			groups: []string{"abort", "warn"},
+		},
+		{
+			name:   "dry run",
+			query:  "?dry_run=true",
+			status: http.StatusOK,
+			input: `
+groups:
+- name: recording
+  rules:
+  - record: up:sum
+    expr: sum(up)
+`,
+			groups: []string{"existing"},
+		},
+		{
+			name:   "unknown field",
+			status: http.StatusBadRequest,
+			input: `
+groups:
+- name: recording
+  unknown: true
+  rules:
+  - record: up:sum
+    expr: sum(up)
+`,
+			error: "field unknown not found",
+		},
+		{
+			name:   "invalid rules",
+			status: http.StatusBadRequest,
+			input: `
+groups:
+- name: recording
+  rules:
+  - record: up:sum
+    expr: sum(up
+- name: recording
+  rules:
+  - record: up:sum
+    expr: sum(up)
+- name: strategy
+  partial_response_strategy: invalid
+  rules:
+  - record: up:sum
+    expr: sum(up)
+`,
+			error: `rule group "recording": 6:11: group "recording", rule 0, "up:sum": could not parse expression: 1:7: parse error: unclosed left parenthesis
+rule group "recording": duplicate rule group name
+rule group "strategy": invalid partial response strategy "invalid"
+`,
+		},
+		{
+			name:   "no rule groups",
+			status: http.StatusBadRequest,
+			input:  "groups: []\n",
+			error:  "the rule file has no rule groups",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMockRuleStore(map[string]rulespb.RuleGroupList{
+				"user1": {
+					{User: "user1", Namespace: "namespace", Name: "existing", Interval: interval},
+					{User: "user1", Namespace: "other", Name: "other", Interval: interval},
+				},
+			}, nil)
+			cfg := defaultRulerConfig(t)
+
+			r := newTestRuler(t, cfg, store, nil)
+			defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+			a := NewAPI(r, r.store, log.NewNopLogger())
+			router := mux.NewRouter()
+			router.Path("/api/v1/rules_import/{namespace}").Methods("POST").HandlerFunc(a.ImportRules)
+
+			req := requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules_import/namespace"+tt.query, strings.NewReader(tt.input), "user1")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+
+			if tt.error != "" {
+				assert.Contains(t, w.Body.String(), tt.error)
+				return
+			}
+
+			resp := util_api.Response{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, "success", resp.Status)
+			assert.Equal(t, tt.warnings, resp.Warnings)
+
+			// The rule groups of the namespace are replaced, the ones of the other namespaces are kept.
+			groups, err := store.ListRuleGroupsForUserAndNamespace(context.Background(), "user1", "namespace")
+			require.NoError(t, err)
+			var names []string
+			for _, g := range groups {
+				names = append(names, g.Name)
+			}
+			assert.ElementsMatch(t, tt.groups, names)
+
+			others, err := store.ListRuleGroupsForUserAndNamespace(context.Background(), "user1", "other")
+			require.NoError(t, err)
+			assert.Len(t, others, 1)
+		})
+	}
+}
@@ -0,0 +1,508 @@
+package ruler
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/thanos-io/objstore"
+	"gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex/pkg/ruler/rulespb"
+	"github.com/cortexproject/cortex/pkg/ruler/rulestore"
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	util_api "github.com/cortexproject/cortex/pkg/util/api"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+)
+
+const (
+	rulesVersionsPrefix = "ruler-rules-versions"
+)
+
+var (
+	// ErrNoRulesVersion is returned if the version of the rules is missing from the request.
+	ErrNoRulesVersion = errors.New("a rules version must be provided in the url")
+	// ErrInvalidRulesVersion is returned if the version of the rules is not valid.
+	ErrInvalidRulesVersion = errors.New("invalid rules version")
+	// ErrRulesVersionNotFound is returned if the version of the rules does not exist.
+	ErrRulesVersionNotFound = errors.New("rules version does not exist")
+)
+
+// rulesVersionFile is the content of a version of a rule namespace, in the format of a rule file.
+type rulesVersionFile struct {
+	Groups []rulespb.RuleGroup `yaml:"groups"`
+}
+
+// RulesVersion is a version of a rule namespace.
+type RulesVersion struct {
+	Version   string    `yaml:"version"`
+	Timestamp time.Time `yaml:"timestamp"`
+}
+
+// VersionedRuleStore is a rule store recording a version of each rule namespace changed through it
+// in object storage, so that the rule namespaces can be rolled back to any of their versions.
+type VersionedRuleStore struct {
+	rulestore.RuleStore
+
+	bucket      objstore.Bucket
+	cfgProvider bucket.TenantConfigProvider
+	maxVersions int
+	logger      log.Logger
+
+	now func() time.Time
+}
+
+func newVersionedRuleStore(store rulestore.RuleStore, bkt objstore.Bucket, maxVersions int, cfgProvider bucket.TenantConfigProvider, logger log.Logger) *VersionedRuleStore {
+	return &VersionedRuleStore{
+		RuleStore:   store,
+		bucket:      bucket.NewPrefixedBucketClient(bkt, rulesVersionsPrefix),
+		cfgProvider: cfgProvider,
+		maxVersions: maxVersions,
+		logger:      logger,
+		now:         time.Now,
+	}
+}
+
+// SetRuleGroup implements rulestore.RuleStore.
+func (s *VersionedRuleStore) SetRuleGroup(ctx context.Context, userID, namespace string, group *rulespb.RuleGroupDesc) error {
+	return s.withVersion(ctx, userID, namespace, func() error {
+		return s.RuleStore.SetRuleGroup(ctx, userID, namespace, group)
+	})
+}
+
+// DeleteRuleGroup implements rulestore.RuleStore.
+func (s *VersionedRuleStore) DeleteRuleGroup(ctx context.Context, userID, namespace string, group string) error {
+	return s.withVersion(ctx, userID, namespace, func() error {
+		return s.RuleStore.DeleteRuleGroup(ctx, userID, namespace, group)
+	})
+}
+
+// DeleteNamespace implements rulestore.RuleStore. The deletion of all the rule groups of a user,
+// with an empty namespace, is not versioned.
+func (s *VersionedRuleStore) DeleteNamespace(ctx context.Context, userID, namespace string) error {
+	if namespace == "" {
+		return s.RuleStore.DeleteNamespace(ctx, userID, namespace)
+	}
+	return s.withVersion(ctx, userID, namespace, func() error {
+		return s.RuleStore.DeleteNamespace(ctx, userID, namespace)
+	})
+}
+
+// Rollback replaces the rule groups of the namespace with the provided ones.
+func (s *VersionedRuleStore) Rollback(ctx context.Context, userID, namespace string, groups []*rulespb.RuleGroupDesc) error {
+	return s.withVersion(ctx, userID, namespace, func() error {
+		return replaceNamespaceRuleGroups(ctx, s.RuleStore, userID, namespace, groups)
+	})
+}
+
+// replaceNamespaceRuleGroups replaces the rule groups of the namespace with the provided ones, deleting
+// the rule groups of the namespace which are not provided.
+func replaceNamespaceRuleGroups(ctx context.Context, store rulestore.RuleStore, userID, namespace string, groups []*rulespb.RuleGroupDesc) error {
+	current, err := store.ListRuleGroupsForUserAndNamespace(ctx, userID, namespace)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]struct{}, len(groups))
+	for _, g := range groups {
+		if err := store.SetRuleGroup(ctx, userID, namespace, g); err != nil {
+			return err
+		}
+		names[g.Name] = struct{}{}
+	}
+
+	for _, g := range current {
+		if _, ok := names[g.Name]; ok {
+			continue
+		}
+		if err := store.DeleteRuleGroup(ctx, userID, namespace, g.Name); err != nil && !errors.Is(err, rulestore.ErrGroupNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// withVersion applies the change to the rule namespace, recording its version before and after
+// the change. The change is not applied if the rule namespace can't be recorded before it, so
+// that its current rules can always be recovered.
+func (s *VersionedRuleStore) withVersion(ctx context.Context, userID, namespace string, change func() error) error {
+	if err := s.recordVersion(ctx, userID, namespace); err != nil {
+		return errors.Wrap(err, "failed to record the rules version")
+	}
+
+	if err := change(); err != nil {
+		return err
+	}
+
+	if err := s.recordVersion(ctx, userID, namespace); err != nil {
+		level.Warn(util_log.WithContext(ctx, s.logger)).Log("msg", "failed to record the rules version", "user", userID, "namespace", namespace, "err", err)
+	}
+	return nil
+}
+
+// recordVersion records the current rule groups of the namespace as a new version, unless they
+// didn't change since the latest version, and deletes the versions exceeding the max versions.
+func (s *VersionedRuleStore) recordVersion(ctx context.Context, userID, namespace string) error {
+	current, empty, err := s.CurrentRules(ctx, userID, namespace)
+	if err != nil {
+		return err
+	}
+
+	versions, err := s.ListVersions(ctx, userID, namespace)
+	if err != nil {
+		return err
+	}
+
+	var latest string
+	if len(versions) == 0 {
+		// The namespace doesn't need to be recorded until it has rule groups.
+		if empty {
+			return nil
+		}
+	} else {
+		latest = versions[len(versions)-1]
+		content, err := s.GetVersion(ctx, userID, namespace, latest)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(content, current) {
+			return nil
+		}
+	}
+
+	// The versions must be sorted by the time they've been recorded.
+	version := fmt.Sprintf("%020d", s.now().UnixNano())
+	if version <= latest {
+		latestNanos, _ := strconv.ParseInt(latest, 10, 64)
+		version = fmt.Sprintf("%020d", latestNanos+1)
+	}
+
+	userBucket := bucket.NewUserBucketClient(userID, s.bucket, s.cfgProvider)
+	if err := userBucket.Upload(ctx, getRulesVersionObjectKey(namespace, version), bytes.NewReader(current)); err != nil {
+		return err
+	}
+	versions = append(versions, version)
+
+	if s.maxVersions <= 0 || len(versions) <= s.maxVersions {
+		return nil
+	}
+	for _, v := range versions[:len(versions)-s.maxVersions] {
+		if err := userBucket.Delete(ctx, getRulesVersionObjectKey(namespace, v)); err != nil && !userBucket.IsObjNotFoundErr(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// CurrentRules returns the current rule groups of the namespace, in the format of the versions,
+// and whether the namespace has no rule groups.
+func (s *VersionedRuleStore) CurrentRules(ctx context.Context, userID, namespace string) ([]byte, bool, error) {
+	rgs, err := s.RuleStore.ListRuleGroupsForUserAndNamespace(ctx, userID, namespace)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(rgs) > 0 {
+		if _, err := s.RuleStore.LoadRuleGroups(ctx, map[string]rulespb.RuleGroupList{userID: rgs}); err != nil {
+			return nil, false, err
+		}
+	}
+
+	content, err := yaml.Marshal(rulesVersionFile{Groups: rgs.FormattedWithTenants()[namespace]})
+	if err != nil {
+		return nil, false, err
+	}
+	return content, len(rgs) == 0, nil
+}
+
+// ListVersions returns the versions of the namespace, from the oldest to the latest.
+func (s *VersionedRuleStore) ListVersions(ctx context.Context, userID, namespace string) ([]string, error) {
+	userBucket := bucket.NewUserBucketClient(userID, s.bucket, s.cfgProvider)
+	dir := getRulesVersionsDir(namespace)
+
+	var versions []string
+	err := userBucket.Iter(ctx, dir, func(key string) error {
+		versions = append(versions, strings.TrimPrefix(key, dir))
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list rules versions of namespace %s", namespace)
+	}
+
+	sort.Strings(versions)
+	return versions, nil
+}
+
+// GetVersion returns the content of the version of the namespace, or ErrRulesVersionNotFound if
+// it doesn't exist.
+func (s *VersionedRuleStore) GetVersion(ctx context.Context, userID, namespace, version string) ([]byte, error) {
+	userBucket := bucket.NewUserBucketClient(userID, s.bucket, s.cfgProvider)
+	objectKey := getRulesVersionObjectKey(namespace, version)
+
+	reader, err := userBucket.Get(ctx, objectKey)
+	if userBucket.IsObjNotFoundErr(err) {
+		return nil, ErrRulesVersionNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get rules version %s", objectKey)
+	}
+	defer func() { _ = reader.Close() }()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read rules version %s", objectKey)
+	}
+	return content, nil
+}
+
+func getRulesVersionsDir(namespace string) string {
+	return base64.URLEncoding.EncodeToString([]byte(namespace)) + objstore.DirDelim
+}
+
+func getRulesVersionObjectKey(namespace, version string) string {
+	return getRulesVersionsDir(namespace) + version
+}
+
+// rulesVersionTimestamp returns the time the version has been recorded at.
+func rulesVersionTimestamp(version string) (time.Time, error) {
+	nanos, err := strconv.ParseInt(version, 10, 64)
+	if err != nil || nanos < 0 {
+		return time.Time{}, ErrInvalidRulesVersion
+	}
+	return time.Unix(0, nanos).UTC(), nil
+}
+
+// RulesVersionsAPI is the API to list, diff and roll back the versions of the rule namespaces.
+type RulesVersionsAPI struct {
+	ruler  *Ruler
+	store  *VersionedRuleStore
+	logger log.Logger
+}
+
+// NewRulesVersionsAPI returns a new RulesVersionsAPI.
+func NewRulesVersionsAPI(r *Ruler, s *VersionedRuleStore, logger log.Logger) *RulesVersionsAPI {
+	return &RulesVersionsAPI{
+		ruler:  r,
+		store:  s,
+		logger: logger,
+	}
+}
+
+// ListVersions lists the versions of a rule namespace.
+func (a *RulesVersionsAPI) ListVersions(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+	userID, namespace, _, err := parseRequest(req, true, false)
+	if err != nil {
+		util_api.RespondError(logger, w, v1.ErrBadData, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	versions, err := a.store.ListVersions(req.Context(), userID, namespace)
+	if err != nil {
+		util_api.RespondError(logger, w, v1.ErrServer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(versions) == 0 {
+		http.Error(w, ErrRulesVersionNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	output := struct {
+		Versions []RulesVersion `yaml:"versions"`
+	}{}
+	for _, v := range versions {
+		ts, err := rulesVersionTimestamp(v)
+		if err != nil {
+			level.Warn(logger).Log("msg", "skipping invalid rules version", "user", userID, "namespace", namespace, "version", v)
+			continue
+		}
+		output.Versions = append(output.Versions, RulesVersion{Version: v, Timestamp: ts})
+	}
+	marshalAndSend(output, w, logger)
+}
+
+// GetVersion returns the rule groups of a version of a rule namespace.
+func (a *RulesVersionsAPI) GetVersion(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+	userID, namespace, version, err := parseVersionRequest(req)
+	if err != nil {
+		util_api.RespondError(logger, w, v1.ErrBadData, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	content, ok := a.getVersion(w, req, userID, namespace, version)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	if _, err := w.Write(content); err != nil {
+		level.Error(logger).Log("msg", "error writing yaml response", "err", err)
+	}
+}
+
+// DiffVersion returns the unified diff between a version of a rule namespace and the version in the
+// "to" parameter, or the current rule groups of the namespace if not provided.
+func (a *RulesVersionsAPI) DiffVersion(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+	userID, namespace, version, err := parseVersionRequest(req)
+	if err != nil {
+		util_api.RespondError(logger, w, v1.ErrBadData, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from, ok := a.getVersion(w, req, userID, namespace, version)
+	if !ok {
+		return
+	}
+
+	var to []byte
+	toName := "current"
+	if toVersion := req.URL.Query().Get("to"); toVersion != "" {
+		if _, err := rulesVersionTimestamp(toVersion); err != nil {
+			util_api.RespondError(logger, w, v1.ErrBadData, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if to, ok = a.getVersion(w, req, userID, namespace, toVersion); !ok {
+			return
+		}
+		toName = toVersion
+	} else if to, _, err = a.store.CurrentRules(req.Context(), userID, namespace); err != nil {
+		util_api.RespondError(logger, w, v1.ErrServer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(strings.TrimSuffix(string(from), "\n")),
+		B:        difflib.SplitLines(strings.TrimSuffix(string(to), "\n")),
+		FromFile: version,
+		ToFile:   toName,
+		Context:  3,
+	})
+	if err != nil {
+		util_api.RespondError(logger, w, v1.ErrServer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err := w.Write([]byte(diff)); err != nil {
+		level.Error(logger).Log("msg", "error writing diff response", "err", err)
+	}
+}
+
+// RollbackVersion replaces the rule groups of a rule namespace with the ones of one of its versions.
+func (a *RulesVersionsAPI) RollbackVersion(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+	userID, namespace, version, err := parseVersionRequest(req)
+	if err != nil {
+		util_api.RespondError(logger, w, v1.ErrBadData, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	content, ok := a.getVersion(w, req, userID, namespace, version)
+	if !ok {
+		return
+	}
+
+	file := rulesVersionFile{}
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		level.Error(logger).Log("msg", "unable to unmarshal rules version", "err", err.Error(), "user", userID, "version", version)
+		util_api.RespondError(logger, w, v1.ErrServer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The rule groups are validated again, given the limits can change after they've been stored.
+	groups := make([]*rulespb.RuleGroupDesc, 0, len(file.Groups))
+	for _, rg := range file.Groups {
+		if err := a.ruler.AssertRuleGroupValid(userID, rg); err != nil {
+			level.Error(logger).Log("msg", "rules version validation failure", "err", err.Error(), "user", userID, "version", version)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rgProto := rulespb.ToProtoWithTenants(userID, namespace, rg)
+		groups = append(groups, rgProto)
+	}
+
+	if a.ruler.HasMaxRuleGroupsLimit(userID) {
+		all, err := a.store.ListRuleGroupsForUserAndNamespace(req.Context(), userID, "")
+		if err != nil {
+			level.Error(logger).Log("msg", "unable to fetch current rule groups for validation", "err", err.Error(), "user", userID)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		others := 0
+		for _, g := range all {
+			if g.Namespace != namespace {
+				others++
+			}
+		}
+		if err := a.ruler.AssertMaxRuleGroups(userID, others+len(groups)); err != nil {
+			level.Error(logger).Log("msg", "limit validation failure", "err", err.Error(), "user", userID)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := a.store.Rollback(req.Context(), userID, namespace, groups); err != nil {
+		level.Error(logger).Log("msg", "unable to roll back rules version", "err", err.Error(), "user", userID, "version", version)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondAccepted(w, logger)
+}
+
+// getVersion returns the content of the version, writing the error response if it can't be read.
+func (a *RulesVersionsAPI) getVersion(w http.ResponseWriter, req *http.Request, userID, namespace, version string) ([]byte, bool) {
+	content, err := a.store.GetVersion(req.Context(), userID, namespace, version)
+	if errors.Is(err, ErrRulesVersionNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		util_api.RespondError(util_log.WithContext(req.Context(), a.logger), w, v1.ErrServer, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return content, true
+}
+
+// parseVersionRequest parses the incoming request to parse out the userID, rules namespace and
+// rules version, and returns them in that order.
+func parseVersionRequest(req *http.Request) (string, string, string, error) {
+	userID, namespace, _, err := parseRequest(req, true, false)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	version, exists := mux.Vars(req)["version"]
+	if !exists {
+		return "", "", "", ErrNoRulesVersion
+	}
+
+	version, err = url.PathUnescape(version)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	if _, err := rulesVersionTimestamp(version); err != nil {
+		return "", "", "", err
+	}
+	return userID, namespace, version, nil
+}
@@ -0,0 +1,171 @@
+package ruler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex/pkg/ruler/rulespb"
+	"github.com/cortexproject/cortex/pkg/ruler/rulestore/bucketclient"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+func newTestVersionedRuleStore(maxVersions int) *VersionedRuleStore {
+	bkt := objstore.NewInMemBucket()
+	store := newVersionedRuleStore(bucketclient.NewBucketRuleStore(bkt, nil, log.NewNopLogger()), bkt, maxVersions, nil, log.NewNopLogger())
+
+	now := time.Unix(1000, 0)
+	store.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	return store
+}
+
+func newTestVersionedRuleGroup(name, expr string) *rulespb.RuleGroupDesc {
+	return rulespb.ToProto("user-1", "ns", rulefmt.RuleGroup{
+		Name:  name,
+		Rules: []rulefmt.RuleNode{{Record: yaml.Node{Kind: yaml.ScalarNode, Value: "rule"}, Expr: yaml.Node{Kind: yaml.ScalarNode, Value: expr}}},
+	})
+}
+
+func TestVersionedRuleStore_ShouldRecordTheChangedNamespaces(t *testing.T) {
+	ctx := context.Background()
+	store := newTestVersionedRuleStore(2)
+
+	require.NoError(t, store.SetRuleGroup(ctx, "user-1", "ns", newTestVersionedRuleGroup("a", "up")))
+	// The rule groups not changed are not recorded again.
+	require.NoError(t, store.SetRuleGroup(ctx, "user-1", "ns", newTestVersionedRuleGroup("a", "up")))
+
+	versions, err := store.ListVersions(ctx, "user-1", "ns")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+
+	require.NoError(t, store.SetRuleGroup(ctx, "user-1", "ns", newTestVersionedRuleGroup("b", "up")))
+	require.NoError(t, store.DeleteRuleGroup(ctx, "user-1", "ns", "a"))
+
+	// Only the latest versions are kept.
+	versions, err = store.ListVersions(ctx, "user-1", "ns")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+
+	content, err := store.GetVersion(ctx, "user-1", "ns", versions[0])
+	require.NoError(t, err)
+	assert.Equal(t, "groups:\n    - name: a\n      rules:\n        - record: rule\n          expr: up\n    - name: b\n      rules:\n        - record: rule\n          expr: up\n", string(content))
+
+	content, err = store.GetVersion(ctx, "user-1", "ns", versions[1])
+	require.NoError(t, err)
+	assert.Equal(t, "groups:\n    - name: b\n      rules:\n        - record: rule\n          expr: up\n", string(content))
+
+	_, err = store.GetVersion(ctx, "user-1", "ns", "00000000000000000001")
+	assert.ErrorIs(t, err, ErrRulesVersionNotFound)
+
+	// The rule groups changed outside of the store are recorded before the next change.
+	require.NoError(t, store.RuleStore.SetRuleGroup(ctx, "user-1", "ns", newTestVersionedRuleGroup("b", "down")))
+	require.NoError(t, store.DeleteNamespace(ctx, "user-1", "ns"))
+
+	versions, err = store.ListVersions(ctx, "user-1", "ns")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+
+	content, err = store.GetVersion(ctx, "user-1", "ns", versions[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "expr: down")
+
+	content, err = store.GetVersion(ctx, "user-1", "ns", versions[1])
+	require.NoError(t, err)
+	assert.Equal(t, "groups: []\n", string(content))
+}
+
+func TestRulesVersionsAPI(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+	r := newTestRuler(t, cfg, newMockRuleStore(make(map[string]rulespb.RuleGroupList), nil), nil)
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	store := newTestVersionedRuleStore(0)
+	a := NewAPI(r, store, log.NewNopLogger())
+	v := NewRulesVersionsAPI(r, store, log.NewNopLogger())
+
+	router := mux.NewRouter()
+	router.Path("/api/v1/rules/{namespace}").Methods("GET").HandlerFunc(a.ListRules)
+	router.Path("/api/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+	router.Path("/api/v1/rules/{namespace}").Methods("DELETE").HandlerFunc(a.DeleteNamespace)
+	router.Path("/api/v1/rules_versions/{namespace}").Methods("GET").HandlerFunc(v.ListVersions)
+	router.Path("/api/v1/rules_versions/{namespace}/{version}").Methods("GET").HandlerFunc(v.GetVersion)
+	router.Path("/api/v1/rules_versions/{namespace}/{version}/diff").Methods("GET").HandlerFunc(v.DiffVersion)
+	router.Path("/api/v1/rules_versions/{namespace}/{version}/rollback").Methods("POST").HandlerFunc(v.RollbackVersion)
+
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, requestFor(t, method, url, strings.NewReader(body), "user-1"))
+		return w
+	}
+
+	w := serve(http.MethodGet, "https://localhost:8080/api/v1/rules_versions/ns", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	require.Equal(t, http.StatusAccepted, serve(http.MethodPost, "https://localhost:8080/api/v1/rules/ns", "name: a\nrules:\n- record: rule\n  expr: up\n").Code)
+	require.Equal(t, http.StatusAccepted, serve(http.MethodPost, "https://localhost:8080/api/v1/rules/ns", "name: b\nrules:\n- record: rule\n  expr: up\n").Code)
+	require.Equal(t, http.StatusAccepted, serve(http.MethodDelete, "https://localhost:8080/api/v1/rules/ns", "").Code)
+	require.Equal(t, http.StatusNotFound, serve(http.MethodGet, "https://localhost:8080/api/v1/rules/ns", "").Code)
+
+	w = serve(http.MethodGet, "https://localhost:8080/api/v1/rules_versions/ns", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `versions:
+    - version: "00000001001000000000"
+      timestamp: 1970-01-01T00:16:41Z
+    - version: "00000001002000000000"
+      timestamp: 1970-01-01T00:16:42Z
+    - version: "00000001003000000000"
+      timestamp: 1970-01-01T00:16:43Z
+`, w.Body.String())
+
+	w = serve(http.MethodGet, "https://localhost:8080/api/v1/rules_versions/ns/00000001002000000000", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "groups:\n    - name: a\n      rules:\n        - record: rule\n          expr: up\n    - name: b\n      rules:\n        - record: rule\n          expr: up\n", w.Body.String())
+
+	w = serve(http.MethodGet, "https://localhost:8080/api/v1/rules_versions/ns/00000001001000000000/diff?to=00000001002000000000", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `--- 00000001001000000000
++++ 00000001002000000000
+@@ -3,3 +3,7 @@
+       rules:
+         - record: rule
+           expr: up
++    - name: b
++      rules:
++        - record: rule
++          expr: up
+`, w.Body.String())
+
+	w = serve(http.MethodGet, "https://localhost:8080/api/v1/rules_versions/ns/00000001002000000000/diff", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "+++ current\n")
+	assert.Contains(t, w.Body.String(), "+groups: []\n")
+
+	require.Equal(t, http.StatusNotFound, serve(http.MethodGet, "https://localhost:8080/api/v1/rules_versions/ns/00000000000000000001", "").Code)
+	require.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "https://localhost:8080/api/v1/rules_versions/ns/latest", "").Code)
+
+	// Roll back the deletion of the namespace.
+	require.Equal(t, http.StatusAccepted, serve(http.MethodPost, "https://localhost:8080/api/v1/rules_versions/ns/00000001002000000000/rollback", "").Code)
+
+	w = serve(http.MethodGet, "https://localhost:8080/api/v1/rules/ns", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ns:\n    - name: a\n      rules:\n        - record: rule\n          expr: up\n    - name: b\n      rules:\n        - record: rule\n          expr: up\n", w.Body.String())
+
+	// The rollback is recorded as a new version.
+	versions, err := store.ListVersions(context.Background(), "user-1", "ns")
+	require.NoError(t, err)
+	require.Len(t, versions, 4)
+}
@@ -11,6 +11,26 @@ import (
 	"github.com/cortexproject/cortex/pkg/cortexpb" //lint:ignore faillint allowed to import other protobuf
 )
 
+// RuleGroup is a formatted prometheus rulegroup including the fields specific to Cortex.
+type RuleGroup struct {
+	rulefmt.RuleGroup `yaml:",inline"`
+
+	// SourceTenants are the tenants whose data is queried by the rules of a federated rule group.
+	SourceTenants []string `yaml:"source_tenants,omitempty"`
+	// DestinationTenant is the tenant the series produced by the rule group are written to.
+	DestinationTenant string `yaml:"destination_tenant,omitempty"`
+	// AlignEvaluations aligns the evaluation timestamps of the rule group to its interval.
+	AlignEvaluations bool `yaml:"align_evaluations,omitempty"`
+	// EvaluationOffset is the offset of the aligned evaluation timestamps within the interval.
+	EvaluationOffset model.Duration `yaml:"evaluation_offset,omitempty"`
+	// ExternalLabels are added to the series and alerts produced by the rules of the rule group.
+	ExternalLabels map[string]string `yaml:"external_labels,omitempty"`
+	// AlertmanagerTenants are the tenants whose alertmanager also receives the alerts of the rule group.
+	AlertmanagerTenants []string `yaml:"alertmanager_tenants,omitempty"`
+	// AlertmanagerURLs are the URLs of the alertmanagers also receiving the alerts of the rule group.
+	AlertmanagerURLs []string `yaml:"alertmanager_urls,omitempty"`
+}
+
 // ToProto transforms a formatted prometheus rulegroup to a rule group protobuf
 func ToProto(user string, namespace string, rl rulefmt.RuleGroup) *RuleGroupDesc {
 	rg := RuleGroupDesc{
@@ -24,6 +44,23 @@ func ToProto(user string, namespace string, rl rulefmt.RuleGroup) *RuleGroupDesc
 	return &rg
 }
 
+// ToProtoWithTenants transforms a RuleGroup to a rule group protobuf, including the source tenants of
+// federated rule groups, the destination tenant, the evaluation alignment, the external labels and the
+// additional alertmanagers of the alerts.
+func ToProtoWithTenants(user string, namespace string, rl RuleGroup) *RuleGroupDesc {
+	rg := ToProto(user, namespace, rl.RuleGroup)
+	rg.SourceTenants = rl.SourceTenants
+	rg.DestinationTenant = rl.DestinationTenant
+	rg.AlignEvaluations = rl.AlignEvaluations
+	rg.EvaluationOffset = time.Duration(rl.EvaluationOffset)
+	if len(rl.ExternalLabels) > 0 {
+		rg.ExternalLabels = cortexpb.FromLabelsToLabelAdapters(labels.FromMap(rl.ExternalLabels))
+	}
+	rg.AlertmanagerTenants = rl.AlertmanagerTenants
+	rg.AlertmanagerURLs = rl.AlertmanagerURLs
+	return rg
+}
+
 func formattedRuleToProto(rls []rulefmt.RuleNode) []*RuleDesc {
 	rules := make([]*RuleDesc, len(rls))
 	for i := range rls {
@@ -77,3 +114,22 @@ func FromProto(rg *RuleGroupDesc) rulefmt.RuleGroup {
 
 	return formattedRuleGroup
 }
+
+// FromProtoWithTenants generates a RuleGroup, including the source tenants of federated rule groups,
+// the destination tenant, the evaluation alignment, the external labels and the additional alertmanagers
+// of the alerts.
+func FromProtoWithTenants(rg *RuleGroupDesc) RuleGroup {
+	formatted := RuleGroup{
+		RuleGroup:           FromProto(rg),
+		SourceTenants:       rg.GetSourceTenants(),
+		DestinationTenant:   rg.GetDestinationTenant(),
+		AlignEvaluations:    rg.GetAlignEvaluations(),
+		EvaluationOffset:    model.Duration(rg.GetEvaluationOffset()),
+		AlertmanagerTenants: rg.GetAlertmanagerTenants(),
+		AlertmanagerURLs:    rg.GetAlertmanagerURLs(),
+	}
+	if len(rg.ExternalLabels) > 0 {
+		formatted.ExternalLabels = cortexpb.FromLabelAdaptersToLabels(rg.ExternalLabels).Map()
+	}
+	return formatted
+}
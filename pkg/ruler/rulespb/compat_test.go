@@ -48,3 +48,108 @@ func TestProto(t *testing.T) {
 	formatted := FromProto(desc)
 	assert.Equal(t, rg, formatted)
 }
+
+func TestRuleGroup_SourceTenants(t *testing.T) {
+	payload := `
+name: group1
+source_tenants:
+  - tenant-a
+  - tenant-b
+rules:
+  - record: test_rule
+    expr: test_expr
+`
+	rg := RuleGroup{}
+	assert.NoError(t, yaml.Unmarshal([]byte(payload), &rg))
+	assert.Equal(t, "group1", rg.Name)
+	assert.Equal(t, []string{"tenant-a", "tenant-b"}, rg.SourceTenants)
+
+	desc := ToProto("test", "namespace", rg.RuleGroup)
+	desc.SourceTenants = rg.SourceTenants
+
+	formatted := FromProtoWithTenants(desc)
+	assert.Equal(t, []string{"tenant-a", "tenant-b"}, formatted.SourceTenants)
+
+	out, err := yaml.Marshal(formatted)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "source_tenants:\n    - tenant-a\n    - tenant-b\n")
+
+	// The source tenants are not part of the Prometheus rule group.
+	out, err = yaml.Marshal(FromProto(desc))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(out), "source_tenants")
+}
+
+func TestRuleGroup_DestinationTenant(t *testing.T) {
+	payload := `
+name: group1
+destination_tenant: ops
+rules:
+  - record: test_rule
+    expr: test_expr
+`
+	rg := RuleGroup{}
+	assert.NoError(t, yaml.Unmarshal([]byte(payload), &rg))
+	assert.Equal(t, "ops", rg.DestinationTenant)
+
+	desc := ToProto("test", "namespace", rg.RuleGroup)
+	desc.DestinationTenant = rg.DestinationTenant
+
+	out, err := yaml.Marshal(FromProtoWithTenants(desc))
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "destination_tenant: ops\n")
+}
+
+func TestRuleGroup_ExternalLabels(t *testing.T) {
+	payload := `
+name: group1
+external_labels:
+  env: prod
+  region: eu
+rules:
+  - record: test_rule
+    expr: test_expr
+`
+	rg := RuleGroup{}
+	assert.NoError(t, yaml.Unmarshal([]byte(payload), &rg))
+	assert.Equal(t, map[string]string{"env": "prod", "region": "eu"}, rg.ExternalLabels)
+
+	desc := ToProtoWithTenants("test", "namespace", rg)
+	formatted := FromProtoWithTenants(desc)
+	assert.Equal(t, rg.ExternalLabels, formatted.ExternalLabels)
+
+	out, err := yaml.Marshal(formatted)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "external_labels:\n    env: prod\n    region: eu\n")
+
+	// The external labels are not part of the Prometheus rule group.
+	out, err = yaml.Marshal(FromProto(desc))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(out), "external_labels")
+}
+
+func TestRuleGroup_Alertmanagers(t *testing.T) {
+	payload := `
+name: group1
+alertmanager_tenants:
+  - ops
+alertmanager_urls:
+  - https://alertmanager.example.com/
+rules:
+  - alert: test_rule
+    expr: test_expr
+`
+	rg := RuleGroup{}
+	assert.NoError(t, yaml.Unmarshal([]byte(payload), &rg))
+	assert.Equal(t, []string{"ops"}, rg.AlertmanagerTenants)
+	assert.Equal(t, []string{"https://alertmanager.example.com/"}, rg.AlertmanagerURLs)
+
+	desc := ToProtoWithTenants("test", "namespace", rg)
+	formatted := FromProtoWithTenants(desc)
+	assert.Equal(t, rg.AlertmanagerTenants, formatted.AlertmanagerTenants)
+	assert.Equal(t, rg.AlertmanagerURLs, formatted.AlertmanagerURLs)
+
+	out, err := yaml.Marshal(formatted)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "alertmanager_tenants:\n    - ops\nalertmanager_urls:\n    - https://alertmanager.example.com/\n")
+}
@@ -19,3 +19,13 @@ func (l RuleGroupList) Formatted() map[string][]rulefmt.RuleGroup {
 	}
 	return ruleMap
 }
+
+// FormattedWithTenants returns the rule group list as a set of formatted rule groups, including
+// the source tenants of federated rule groups and the destination tenant, mapped by namespace
+func (l RuleGroupList) FormattedWithTenants() map[string][]RuleGroup {
+	ruleMap := map[string][]RuleGroup{}
+	for _, g := range l {
+		ruleMap[g.Namespace] = append(ruleMap[g.Namespace], FromProtoWithTenants(g))
+	}
+	return ruleMap
+}
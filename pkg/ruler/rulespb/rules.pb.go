@@ -45,6 +45,20 @@ type RuleGroupDesc struct {
 	// to the Prometheus Manager.
 	Options []*types.Any `protobuf:"bytes,9,rep,name=options,proto3" json:"options,omitempty"`
 	Limit   int64        `protobuf:"varint,10,opt,name=limit,proto3" json:"limit,omitempty"`
+	// The tenants whose data is queried by the rules of a federated rule group.
+	SourceTenants []string `protobuf:"bytes,11,rep,name=sourceTenants,proto3" json:"sourceTenants,omitempty"`
+	// The tenant the series produced by the rule group are written to, instead of the tenant owning it.
+	DestinationTenant string `protobuf:"bytes,12,opt,name=destinationTenant,proto3" json:"destinationTenant,omitempty"`
+	// Whether the evaluation timestamps of the rule group are aligned to its interval.
+	AlignEvaluations bool `protobuf:"varint,13,opt,name=alignEvaluations,proto3" json:"alignEvaluations,omitempty"`
+	// The offset of the aligned evaluation timestamps of the rule group within its interval.
+	EvaluationOffset time.Duration `protobuf:"bytes,14,opt,name=evaluationOffset,proto3,stdduration" json:"evaluationOffset"`
+	// The labels added to the series and alerts produced by the rules of the rule group.
+	ExternalLabels []github_com_cortexproject_cortex_pkg_cortexpb.LabelAdapter `protobuf:"bytes,15,rep,name=externalLabels,proto3,customtype=github.com/cortexproject/cortex/pkg/cortexpb.LabelAdapter" json:"externalLabels"`
+	// The tenants whose alertmanager also receives the alerts of the rule group.
+	AlertmanagerTenants []string `protobuf:"bytes,16,rep,name=alertmanagerTenants,proto3" json:"alertmanagerTenants,omitempty"`
+	// The URLs of the alertmanagers also receiving the alerts of the rule group.
+	AlertmanagerURLs []string `protobuf:"bytes,17,rep,name=alertmanagerURLs,proto3" json:"alertmanagerURLs,omitempty"`
 }
 
 func (m *RuleGroupDesc) Reset()      { *m = RuleGroupDesc{} }
@@ -128,6 +142,48 @@ func (m *RuleGroupDesc) GetLimit() int64 {
 	return 0
 }
 
+func (m *RuleGroupDesc) GetSourceTenants() []string {
+	if m != nil {
+		return m.SourceTenants
+	}
+	return nil
+}
+
+func (m *RuleGroupDesc) GetDestinationTenant() string {
+	if m != nil {
+		return m.DestinationTenant
+	}
+	return ""
+}
+
+func (m *RuleGroupDesc) GetAlignEvaluations() bool {
+	if m != nil {
+		return m.AlignEvaluations
+	}
+	return false
+}
+
+func (m *RuleGroupDesc) GetEvaluationOffset() time.Duration {
+	if m != nil {
+		return m.EvaluationOffset
+	}
+	return 0
+}
+
+func (m *RuleGroupDesc) GetAlertmanagerTenants() []string {
+	if m != nil {
+		return m.AlertmanagerTenants
+	}
+	return nil
+}
+
+func (m *RuleGroupDesc) GetAlertmanagerURLs() []string {
+	if m != nil {
+		return m.AlertmanagerURLs
+	}
+	return nil
+}
+
 // RuleDesc is a proto representation of a Prometheus Rule
 type RuleDesc struct {
 	Expr          string                                                      `protobuf:"bytes,1,opt,name=expr,proto3" json:"expr,omitempty"`
@@ -214,40 +270,48 @@ func init() {
 func init() { proto.RegisterFile("rules.proto", fileDescriptor_8e722d3e922f0937) }
 
 var fileDescriptor_8e722d3e922f0937 = []byte{
-	// 524 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x52, 0x4f, 0x6f, 0xd3, 0x30,
-	0x1c, 0x8d, 0xd7, 0x34, 0x4b, 0x5d, 0x55, 0xab, 0x4c, 0x85, 0xbc, 0x81, 0xdc, 0x6a, 0x12, 0x52,
-	0x4f, 0x89, 0x34, 0xc4, 0x81, 0x03, 0x42, 0xad, 0xa6, 0x21, 0x55, 0x1c, 0x50, 0x8e, 0x08, 0x69,
-	0x72, 0x52, 0x37, 0x84, 0xa5, 0x71, 0xe4, 0x38, 0x68, 0xbb, 0xf1, 0x11, 0xb8, 0x20, 0xf1, 0x11,
-	0xf8, 0x28, 0x3b, 0x96, 0xdb, 0xc4, 0xa1, 0xd0, 0xf4, 0x82, 0x38, 0xed, 0x23, 0x20, 0xdb, 0x09,
-	0x7f, 0x0f, 0xc0, 0x81, 0x53, 0x7e, 0xcf, 0x2f, 0xcf, 0xef, 0xf9, 0xd9, 0xb0, 0x2b, 0xca, 0x94,
-	0x15, 0x5e, 0x2e, 0xb8, 0xe4, 0xa8, 0xad, 0xc1, 0xc1, 0x20, 0xe6, 0x31, 0xd7, 0x2b, 0xbe, 0x9a,
-	0x0c, 0x79, 0x40, 0x62, 0xce, 0xe3, 0x94, 0xf9, 0x1a, 0x85, 0xe5, 0xc2, 0x9f, 0x97, 0x82, 0xca,
-	0x84, 0x67, 0x35, 0xbf, 0xff, 0x2b, 0x4f, 0xb3, 0x8b, 0x9a, 0xba, 0x1f, 0x27, 0xf2, 0x79, 0x19,
-	0x7a, 0x11, 0x5f, 0xfa, 0x11, 0x17, 0x92, 0x9d, 0xe7, 0x82, 0xbf, 0x60, 0x91, 0xac, 0x91, 0x9f,
-	0x9f, 0xc5, 0x0d, 0x11, 0xd6, 0x83, 0x91, 0x1e, 0xbe, 0xd9, 0x81, 0xbd, 0xa0, 0x4c, 0xd9, 0x23,
-	0xc1, 0xcb, 0xfc, 0x98, 0x15, 0x11, 0x42, 0xd0, 0xce, 0xe8, 0x92, 0x61, 0x30, 0x02, 0xe3, 0x4e,
-	0xa0, 0x67, 0x74, 0x1b, 0x76, 0xd4, 0xb7, 0xc8, 0x69, 0xc4, 0xf0, 0x8e, 0x26, 0xbe, 0x2f, 0xa0,
-	0x87, 0xd0, 0x4d, 0x32, 0xc9, 0xc4, 0x4b, 0x9a, 0xe2, 0xd6, 0x08, 0x8c, 0xbb, 0x47, 0xfb, 0x9e,
-	0x09, 0xeb, 0x35, 0x61, 0xbd, 0xe3, 0xfa, 0x30, 0x53, 0xf7, 0x72, 0x3d, 0xb4, 0xde, 0x7e, 0x1c,
-	0x82, 0xe0, 0x9b, 0x08, 0xdd, 0x81, 0xa6, 0x19, 0x6c, 0x8f, 0x5a, 0xe3, 0xee, 0xd1, 0x9e, 0x67,
-	0x4a, 0x53, 0xb9, 0x54, 0xa4, 0xc0, 0xb0, 0x2a, 0x59, 0x59, 0x30, 0x81, 0x1d, 0x93, 0x4c, 0xcd,
-	0xc8, 0x83, 0xbb, 0x3c, 0x57, 0x1b, 0x17, 0xb8, 0xa3, 0xc5, 0x83, 0xdf, 0xac, 0x27, 0xd9, 0x45,
-	0xd0, 0xfc, 0x84, 0x06, 0xb0, 0x9d, 0x26, 0xcb, 0x44, 0x62, 0x38, 0x02, 0xe3, 0x56, 0x60, 0xc0,
-	0xcc, 0x76, 0xdb, 0x7d, 0x67, 0x66, 0xbb, 0xbb, 0x7d, 0x77, 0x66, 0xbb, 0x6e, 0xbf, 0x73, 0xf8,
-	0xbe, 0x05, 0xdd, 0xc6, 0x5f, 0x19, 0xab, 0x4a, 0x9b, 0x4a, 0xd4, 0x8c, 0x6e, 0x42, 0x47, 0xb0,
-	0x88, 0x8b, 0x79, 0xdd, 0x47, 0x8d, 0x94, 0x01, 0x4d, 0x99, 0x90, 0xba, 0x89, 0x4e, 0x60, 0x00,
-	0xba, 0x07, 0x5b, 0x0b, 0x2e, 0xb0, 0xfd, 0xf7, 0xed, 0xa8, 0xff, 0x51, 0x06, 0x9d, 0x94, 0x86,
-	0x2c, 0x2d, 0x70, 0x5b, 0x1f, 0xee, 0x86, 0xd7, 0xdc, 0xa2, 0xf7, 0x58, 0xad, 0x3f, 0xa1, 0x89,
-	0x98, 0x4e, 0x94, 0xe6, 0xc3, 0x7a, 0xf8, 0x4f, 0xaf, 0xc0, 0xe8, 0x27, 0x73, 0x9a, 0x4b, 0x26,
-	0x82, 0xda, 0x05, 0x9d, 0xc3, 0x2e, 0xcd, 0x32, 0x2e, 0xa9, 0x69, 0xd4, 0xf9, 0xaf, 0xa6, 0x3f,
-	0x5a, 0xa1, 0x67, 0xb0, 0x77, 0xc6, 0x58, 0x7e, 0x92, 0x88, 0x24, 0x8b, 0x4f, 0xb8, 0xc0, 0xbd,
-	0x3f, 0x55, 0x75, 0x4b, 0x25, 0xf8, 0xb2, 0x1e, 0xee, 0x29, 0xdd, 0xe9, 0x42, 0x0b, 0x4f, 0x17,
-	0x5c, 0xe8, 0xf6, 0x7e, 0xde, 0x4c, 0xdf, 0x6c, 0x6f, 0xfa, 0x60, 0xb5, 0x21, 0xd6, 0xd5, 0x86,
-	0x58, 0xd7, 0x1b, 0x02, 0x5e, 0x55, 0x04, 0xbc, 0xab, 0x08, 0xb8, 0xac, 0x08, 0x58, 0x55, 0x04,
-	0x7c, 0xaa, 0x08, 0xf8, 0x5c, 0x11, 0xeb, 0xba, 0x22, 0xe0, 0xf5, 0x96, 0x58, 0xab, 0x2d, 0xb1,
-	0xae, 0xb6, 0xc4, 0x7a, 0xba, 0xab, 0x1f, 0x5f, 0x1e, 0x86, 0x8e, 0xce, 0x70, 0xf7, 0x6b, 0x00,
-	0x00, 0x00, 0xff, 0xff, 0x77, 0x8c, 0xa0, 0x4e, 0xd3, 0x03, 0x00, 0x00,
+	// 655 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x54, 0xcd, 0x4e, 0x14, 0x4b,
+	0x18, 0xed, 0xba, 0xf3, 0x43, 0x4f, 0xcd, 0x1d, 0x18, 0x0a, 0x72, 0x53, 0x70, 0x6f, 0x6a, 0x26,
+	0xe4, 0x9a, 0x4c, 0x8c, 0xe9, 0x31, 0x18, 0x17, 0x2e, 0x8c, 0x81, 0x20, 0x26, 0x84, 0x04, 0xd3,
+	0xd1, 0x8d, 0x31, 0x21, 0x35, 0x3d, 0x35, 0x6d, 0x4b, 0x4f, 0x55, 0xa7, 0xba, 0x9a, 0xc0, 0xc6,
+	0xf8, 0x08, 0x2e, 0x7d, 0x04, 0x1f, 0xc4, 0x05, 0x4b, 0xdc, 0x11, 0x17, 0x28, 0xcd, 0xc6, 0xb8,
+	0xe2, 0x11, 0x4c, 0x55, 0x75, 0x83, 0x30, 0x26, 0xe2, 0x82, 0xd5, 0x7c, 0xdf, 0x39, 0xf5, 0xd5,
+	0x77, 0xfa, 0xd4, 0xc9, 0xc0, 0xa6, 0xcc, 0x62, 0x96, 0x7a, 0x89, 0x14, 0x4a, 0xa0, 0x9a, 0x69,
+	0x16, 0xe7, 0x43, 0x11, 0x0a, 0x83, 0xf4, 0x75, 0x65, 0xc9, 0x45, 0x12, 0x0a, 0x11, 0xc6, 0xac,
+	0x6f, 0xba, 0x41, 0x36, 0xea, 0x0f, 0x33, 0x49, 0x55, 0x24, 0x78, 0xc1, 0x2f, 0x5c, 0xe5, 0x29,
+	0xdf, 0x2f, 0xa8, 0x07, 0x61, 0xa4, 0x5e, 0x65, 0x03, 0x2f, 0x10, 0xe3, 0x7e, 0x20, 0xa4, 0x62,
+	0x7b, 0x89, 0x14, 0xaf, 0x59, 0xa0, 0x8a, 0xae, 0x9f, 0xec, 0x84, 0x25, 0x31, 0x28, 0x0a, 0x3b,
+	0xba, 0xf4, 0xb1, 0x06, 0x5b, 0x7e, 0x16, 0xb3, 0x27, 0x52, 0x64, 0xc9, 0x1a, 0x4b, 0x03, 0x84,
+	0x60, 0x95, 0xd3, 0x31, 0xc3, 0xa0, 0x0b, 0x7a, 0x0d, 0xdf, 0xd4, 0xe8, 0x3f, 0xd8, 0xd0, 0xbf,
+	0x69, 0x42, 0x03, 0x86, 0xff, 0x32, 0xc4, 0x05, 0x80, 0x1e, 0x41, 0x37, 0xe2, 0x8a, 0xc9, 0x5d,
+	0x1a, 0xe3, 0x4a, 0x17, 0xf4, 0x9a, 0xcb, 0x0b, 0x9e, 0x15, 0xeb, 0x95, 0x62, 0xbd, 0xb5, 0xe2,
+	0x63, 0x56, 0xdd, 0x83, 0xe3, 0x8e, 0xf3, 0xfe, 0x4b, 0x07, 0xf8, 0xe7, 0x43, 0xe8, 0x16, 0xb4,
+	0xce, 0xe0, 0x6a, 0xb7, 0xd2, 0x6b, 0x2e, 0xcf, 0x78, 0xa6, 0xf3, 0xb4, 0x2e, 0x2d, 0xc9, 0xb7,
+	0xac, 0x56, 0x96, 0xa5, 0x4c, 0xe2, 0xba, 0x55, 0xa6, 0x6b, 0xe4, 0xc1, 0x29, 0x91, 0xe8, 0x8b,
+	0x53, 0xdc, 0x30, 0xc3, 0xf3, 0x13, 0xab, 0x57, 0xf8, 0xbe, 0x5f, 0x1e, 0x42, 0xf3, 0xb0, 0x16,
+	0x47, 0xe3, 0x48, 0x61, 0xd8, 0x05, 0xbd, 0x8a, 0x6f, 0x1b, 0xf4, 0x3f, 0x6c, 0xa5, 0x22, 0x93,
+	0x01, 0x7b, 0xc6, 0x38, 0xe5, 0x2a, 0xc5, 0xcd, 0x6e, 0xa5, 0xd7, 0xf0, 0x2f, 0x83, 0xe8, 0x0e,
+	0x9c, 0x1d, 0xb2, 0x54, 0x45, 0xdc, 0x7c, 0x89, 0x45, 0xf1, 0xdf, 0x46, 0xcc, 0x24, 0x81, 0x6e,
+	0xc3, 0x36, 0x8d, 0xa3, 0x90, 0x3f, 0xde, 0xa5, 0x71, 0x46, 0xad, 0xc4, 0x56, 0x17, 0xf4, 0x5c,
+	0x7f, 0x02, 0x47, 0x5b, 0xb0, 0xcd, 0xce, 0xdb, 0xad, 0xd1, 0x28, 0x65, 0x0a, 0x4f, 0x5f, 0xdf,
+	0xc9, 0x89, 0x61, 0xf4, 0x06, 0x4e, 0xb3, 0x3d, 0xc5, 0x24, 0xa7, 0xf1, 0x26, 0x1d, 0xb0, 0x38,
+	0xc5, 0x33, 0xc6, 0x9d, 0x39, 0xaf, 0x8c, 0x81, 0x67, 0xf0, 0xa7, 0x34, 0x92, 0xab, 0x2b, 0xfa,
+	0xa2, 0xcf, 0xc7, 0x9d, 0x3f, 0x8a, 0x91, 0x9d, 0x5f, 0x19, 0xd2, 0x44, 0x31, 0xe9, 0x5f, 0xd9,
+	0x86, 0xee, 0xc2, 0x39, 0x1a, 0x33, 0xa9, 0xc6, 0x94, 0xd3, 0x90, 0xc9, 0xd2, 0xd6, 0xb6, 0xb1,
+	0xf5, 0x57, 0x94, 0xb5, 0xeb, 0x02, 0x7e, 0xee, 0x6f, 0xa6, 0x78, 0xd6, 0x1c, 0x9f, 0xc0, 0x37,
+	0xaa, 0x6e, 0xad, 0x5d, 0xdf, 0xa8, 0xba, 0x53, 0x6d, 0x77, 0xa3, 0xea, 0xba, 0xed, 0xc6, 0xd2,
+	0xa7, 0x0a, 0x74, 0xcb, 0xb8, 0xe8, 0x9c, 0x68, 0xe9, 0x65, 0x82, 0x75, 0x8d, 0xfe, 0x81, 0x75,
+	0xc9, 0x02, 0x21, 0x87, 0x45, 0x7c, 0x8b, 0x4e, 0xe7, 0xc1, 0x5c, 0x6f, 0x82, 0xdb, 0xf0, 0x6d,
+	0x83, 0xee, 0xc3, 0xca, 0x48, 0x48, 0x5c, 0xbd, 0xfe, 0x13, 0xe8, 0xf3, 0x88, 0xc3, 0x7a, 0x6c,
+	0xdd, 0xae, 0xdd, 0xa8, 0xdb, 0xc5, 0x16, 0xb4, 0x07, 0x9b, 0x94, 0x73, 0xa1, 0x8a, 0x74, 0xd5,
+	0x6f, 0x74, 0xe9, 0xcf, 0xab, 0xd0, 0x4b, 0xd8, 0xda, 0x61, 0x2c, 0x59, 0x8f, 0x64, 0xc4, 0xc3,
+	0x75, 0x21, 0x71, 0xeb, 0x77, 0x56, 0xfd, 0xab, 0x15, 0x7c, 0x3f, 0xee, 0xcc, 0xe8, 0xb9, 0xed,
+	0x91, 0x19, 0xdc, 0x1e, 0x09, 0x69, 0xdc, 0xbb, 0x7c, 0x99, 0x79, 0xd9, 0xd6, 0xea, 0xc3, 0xc3,
+	0x13, 0xe2, 0x1c, 0x9d, 0x10, 0xe7, 0xec, 0x84, 0x80, 0xb7, 0x39, 0x01, 0x1f, 0x72, 0x02, 0x0e,
+	0x72, 0x02, 0x0e, 0x73, 0x02, 0xbe, 0xe6, 0x04, 0x7c, 0xcb, 0x89, 0x73, 0x96, 0x13, 0xf0, 0xee,
+	0x94, 0x38, 0x87, 0xa7, 0xc4, 0x39, 0x3a, 0x25, 0xce, 0x8b, 0x29, 0xf3, 0x5f, 0x91, 0x0c, 0x06,
+	0x75, 0xa3, 0xe1, 0xde, 0x8f, 0x01, 0x00, 0x5d, 0x37, 0x0f, 0x57, 0x82, 0x05, 0x00, 0x00,
 }
 
 func (this *RuleGroupDesc) Equal(that interface{}) bool {
@@ -300,6 +364,47 @@ func (this *RuleGroupDesc) Equal(that interface{}) bool {
 	if this.Limit != that1.Limit {
 		return false
 	}
+	if len(this.SourceTenants) != len(that1.SourceTenants) {
+		return false
+	}
+	for i := range this.SourceTenants {
+		if this.SourceTenants[i] != that1.SourceTenants[i] {
+			return false
+		}
+	}
+	if this.DestinationTenant != that1.DestinationTenant {
+		return false
+	}
+	if this.AlignEvaluations != that1.AlignEvaluations {
+		return false
+	}
+	if this.EvaluationOffset != that1.EvaluationOffset {
+		return false
+	}
+	if len(this.ExternalLabels) != len(that1.ExternalLabels) {
+		return false
+	}
+	for i := range this.ExternalLabels {
+		if !this.ExternalLabels[i].Equal(that1.ExternalLabels[i]) {
+			return false
+		}
+	}
+	if len(this.AlertmanagerTenants) != len(that1.AlertmanagerTenants) {
+		return false
+	}
+	for i := range this.AlertmanagerTenants {
+		if this.AlertmanagerTenants[i] != that1.AlertmanagerTenants[i] {
+			return false
+		}
+	}
+	if len(this.AlertmanagerURLs) != len(that1.AlertmanagerURLs) {
+		return false
+	}
+	for i := range this.AlertmanagerURLs {
+		if this.AlertmanagerURLs[i] != that1.AlertmanagerURLs[i] {
+			return false
+		}
+	}
 	return true
 }
 func (this *RuleDesc) Equal(that interface{}) bool {
@@ -358,7 +463,7 @@ func (this *RuleGroupDesc) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 11)
+	s := make([]string, 0, 18)
 	s = append(s, "&rulespb.RuleGroupDesc{")
 	s = append(s, "Name: "+fmt.Sprintf("%#v", this.Name)+",\n")
 	s = append(s, "Namespace: "+fmt.Sprintf("%#v", this.Namespace)+",\n")
@@ -371,6 +476,13 @@ func (this *RuleGroupDesc) GoString() string {
 		s = append(s, "Options: "+fmt.Sprintf("%#v", this.Options)+",\n")
 	}
 	s = append(s, "Limit: "+fmt.Sprintf("%#v", this.Limit)+",\n")
+	s = append(s, "SourceTenants: "+fmt.Sprintf("%#v", this.SourceTenants)+",\n")
+	s = append(s, "DestinationTenant: "+fmt.Sprintf("%#v", this.DestinationTenant)+",\n")
+	s = append(s, "AlignEvaluations: "+fmt.Sprintf("%#v", this.AlignEvaluations)+",\n")
+	s = append(s, "EvaluationOffset: "+fmt.Sprintf("%#v", this.EvaluationOffset)+",\n")
+	s = append(s, "ExternalLabels: "+fmt.Sprintf("%#v", this.ExternalLabels)+",\n")
+	s = append(s, "AlertmanagerTenants: "+fmt.Sprintf("%#v", this.AlertmanagerTenants)+",\n")
+	s = append(s, "AlertmanagerURLs: "+fmt.Sprintf("%#v", this.AlertmanagerURLs)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -418,6 +530,76 @@ func (m *RuleGroupDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.AlertmanagerURLs) > 0 {
+		for iNdEx := len(m.AlertmanagerURLs) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.AlertmanagerURLs[iNdEx])
+			copy(dAtA[i:], m.AlertmanagerURLs[iNdEx])
+			i = encodeVarintRules(dAtA, i, uint64(len(m.AlertmanagerURLs[iNdEx])))
+			i--
+			dAtA[i] = 0x1
+			i--
+			dAtA[i] = 0x8a
+		}
+	}
+	if len(m.AlertmanagerTenants) > 0 {
+		for iNdEx := len(m.AlertmanagerTenants) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.AlertmanagerTenants[iNdEx])
+			copy(dAtA[i:], m.AlertmanagerTenants[iNdEx])
+			i = encodeVarintRules(dAtA, i, uint64(len(m.AlertmanagerTenants[iNdEx])))
+			i--
+			dAtA[i] = 0x1
+			i--
+			dAtA[i] = 0x82
+		}
+	}
+	if len(m.ExternalLabels) > 0 {
+		for iNdEx := len(m.ExternalLabels) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size := m.ExternalLabels[iNdEx].Size()
+				i -= size
+				if _, err := m.ExternalLabels[iNdEx].MarshalTo(dAtA[i:]); err != nil {
+					return 0, err
+				}
+				i = encodeVarintRules(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x7a
+		}
+	}
+	n1, err1 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.EvaluationOffset, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationOffset):])
+	if err1 != nil {
+		return 0, err1
+	}
+	i -= n1
+	i = encodeVarintRules(dAtA, i, uint64(n1))
+	i--
+	dAtA[i] = 0x72
+	if m.AlignEvaluations {
+		i--
+		if m.AlignEvaluations {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x68
+	}
+	if len(m.DestinationTenant) > 0 {
+		i -= len(m.DestinationTenant)
+		copy(dAtA[i:], m.DestinationTenant)
+		i = encodeVarintRules(dAtA, i, uint64(len(m.DestinationTenant)))
+		i--
+		dAtA[i] = 0x62
+	}
+	if len(m.SourceTenants) > 0 {
+		for iNdEx := len(m.SourceTenants) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.SourceTenants[iNdEx])
+			copy(dAtA[i:], m.SourceTenants[iNdEx])
+			i = encodeVarintRules(dAtA, i, uint64(len(m.SourceTenants[iNdEx])))
+			i--
+			dAtA[i] = 0x5a
+		}
+	}
 	if m.Limit != 0 {
 		i = encodeVarintRules(dAtA, i, uint64(m.Limit))
 		i--
@@ -458,12 +640,12 @@ func (m *RuleGroupDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 			dAtA[i] = 0x22
 		}
 	}
-	n1, err1 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.Interval, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.Interval):])
-	if err1 != nil {
-		return 0, err1
+	n2, err2 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.Interval, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.Interval):])
+	if err2 != nil {
+		return 0, err2
 	}
-	i -= n1
-	i = encodeVarintRules(dAtA, i, uint64(n1))
+	i -= n2
+	i = encodeVarintRules(dAtA, i, uint64(n2))
 	i--
 	dAtA[i] = 0x1a
 	if len(m.Namespace) > 0 {
@@ -503,12 +685,12 @@ func (m *RuleDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
-	n2, err2 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.KeepFiringFor, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.KeepFiringFor):])
-	if err2 != nil {
-		return 0, err2
+	n3, err3 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.KeepFiringFor, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.KeepFiringFor):])
+	if err3 != nil {
+		return 0, err3
 	}
-	i -= n2
-	i = encodeVarintRules(dAtA, i, uint64(n2))
+	i -= n3
+	i = encodeVarintRules(dAtA, i, uint64(n3))
 	i--
 	dAtA[i] = 0x6a
 	if len(m.Annotations) > 0 {
@@ -539,12 +721,12 @@ func (m *RuleDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 			dAtA[i] = 0x2a
 		}
 	}
-	n3, err3 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.For, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.For):])
-	if err3 != nil {
-		return 0, err3
+	n4, err4 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.For, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.For):])
+	if err4 != nil {
+		return 0, err4
 	}
-	i -= n3
-	i = encodeVarintRules(dAtA, i, uint64(n3))
+	i -= n4
+	i = encodeVarintRules(dAtA, i, uint64(n4))
 	i--
 	dAtA[i] = 0x22
 	if len(m.Alert) > 0 {
@@ -617,6 +799,39 @@ func (m *RuleGroupDesc) Size() (n int) {
 	if m.Limit != 0 {
 		n += 1 + sovRules(uint64(m.Limit))
 	}
+	if len(m.SourceTenants) > 0 {
+		for _, s := range m.SourceTenants {
+			l = len(s)
+			n += 1 + l + sovRules(uint64(l))
+		}
+	}
+	l = len(m.DestinationTenant)
+	if l > 0 {
+		n += 1 + l + sovRules(uint64(l))
+	}
+	if m.AlignEvaluations {
+		n += 2
+	}
+	l = github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationOffset)
+	n += 1 + l + sovRules(uint64(l))
+	if len(m.ExternalLabels) > 0 {
+		for _, e := range m.ExternalLabels {
+			l = e.Size()
+			n += 1 + l + sovRules(uint64(l))
+		}
+	}
+	if len(m.AlertmanagerTenants) > 0 {
+		for _, s := range m.AlertmanagerTenants {
+			l = len(s)
+			n += 2 + l + sovRules(uint64(l))
+		}
+	}
+	if len(m.AlertmanagerURLs) > 0 {
+		for _, s := range m.AlertmanagerURLs {
+			l = len(s)
+			n += 2 + l + sovRules(uint64(l))
+		}
+	}
 	return n
 }
 
@@ -685,6 +900,13 @@ func (this *RuleGroupDesc) String() string {
 		`User:` + fmt.Sprintf("%v", this.User) + `,`,
 		`Options:` + repeatedStringForOptions + `,`,
 		`Limit:` + fmt.Sprintf("%v", this.Limit) + `,`,
+		`SourceTenants:` + fmt.Sprintf("%v", this.SourceTenants) + `,`,
+		`DestinationTenant:` + fmt.Sprintf("%v", this.DestinationTenant) + `,`,
+		`AlignEvaluations:` + fmt.Sprintf("%v", this.AlignEvaluations) + `,`,
+		`EvaluationOffset:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.EvaluationOffset), "Duration", "duration.Duration", 1), `&`, ``, 1) + `,`,
+		`ExternalLabels:` + fmt.Sprintf("%v", this.ExternalLabels) + `,`,
+		`AlertmanagerTenants:` + fmt.Sprintf("%v", this.AlertmanagerTenants) + `,`,
+		`AlertmanagerURLs:` + fmt.Sprintf("%v", this.AlertmanagerURLs) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -958,16 +1180,228 @@ func (m *RuleGroupDesc) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 11:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SourceTenants", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SourceTenants = append(m.SourceTenants, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 12:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DestinationTenant", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.DestinationTenant = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 13:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AlignEvaluations", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.AlignEvaluations = bool(v != 0)
+		case 14:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EvaluationOffset", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := github_com_gogo_protobuf_types.StdDurationUnmarshal(&m.EvaluationOffset, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 15:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ExternalLabels", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ExternalLabels = append(m.ExternalLabels, github_com_cortexproject_cortex_pkg_cortexpb.LabelAdapter{})
+			if err := m.ExternalLabels[len(m.ExternalLabels)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 16:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AlertmanagerTenants", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.AlertmanagerTenants = append(m.AlertmanagerTenants, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 17:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AlertmanagerURLs", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.AlertmanagerURLs = append(m.AlertmanagerURLs, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRules(dAtA[iNdEx:])
 			if err != nil {
 				return err
 			}
-			if skippy < 0 {
-				return ErrInvalidLengthRules
-			}
-			if (iNdEx + skippy) < 0 {
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthRules
 			}
 			if (iNdEx + skippy) > l {
@@ -1247,10 +1681,7 @@ func (m *RuleDesc) Unmarshal(dAtA []byte) error {
 			if err != nil {
 				return err
 			}
-			if skippy < 0 {
-				return ErrInvalidLengthRules
-			}
-			if (iNdEx + skippy) < 0 {
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthRules
 			}
 			if (iNdEx + skippy) > l {
@@ -1268,6 +1699,7 @@ func (m *RuleDesc) Unmarshal(dAtA []byte) error {
 func skipRules(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
+	depth := 0
 	for iNdEx < l {
 		var wire uint64
 		for shift := uint(0); ; shift += 7 {
@@ -1299,10 +1731,8 @@ func skipRules(dAtA []byte) (n int, err error) {
 					break
 				}
 			}
-			return iNdEx, nil
 		case 1:
 			iNdEx += 8
-			return iNdEx, nil
 		case 2:
 			var length int
 			for shift := uint(0); ; shift += 7 {
@@ -1323,55 +1753,30 @@ func skipRules(dAtA []byte) (n int, err error) {
 				return 0, ErrInvalidLengthRules
 			}
 			iNdEx += length
-			if iNdEx < 0 {
-				return 0, ErrInvalidLengthRules
-			}
-			return iNdEx, nil
 		case 3:
-			for {
-				var innerWire uint64
-				var start int = iNdEx
-				for shift := uint(0); ; shift += 7 {
-					if shift >= 64 {
-						return 0, ErrIntOverflowRules
-					}
-					if iNdEx >= l {
-						return 0, io.ErrUnexpectedEOF
-					}
-					b := dAtA[iNdEx]
-					iNdEx++
-					innerWire |= (uint64(b) & 0x7F) << shift
-					if b < 0x80 {
-						break
-					}
-				}
-				innerWireType := int(innerWire & 0x7)
-				if innerWireType == 4 {
-					break
-				}
-				next, err := skipRules(dAtA[start:])
-				if err != nil {
-					return 0, err
-				}
-				iNdEx = start + next
-				if iNdEx < 0 {
-					return 0, ErrInvalidLengthRules
-				}
-			}
-			return iNdEx, nil
+			depth++
 		case 4:
-			return iNdEx, nil
+			if depth == 0 {
+				return 0, ErrUnexpectedEndOfGroupRules
+			}
+			depth--
 		case 5:
 			iNdEx += 4
-			return iNdEx, nil
 		default:
 			return 0, fmt.Errorf("proto: illegal wireType %d", wireType)
 		}
+		if iNdEx < 0 {
+			return 0, ErrInvalidLengthRules
+		}
+		if depth == 0 {
+			return iNdEx, nil
+		}
 	}
-	panic("unreachable")
+	return 0, io.ErrUnexpectedEOF
 }
 
 var (
-	ErrInvalidLengthRules = fmt.Errorf("proto: negative length found during unmarshaling")
-	ErrIntOverflowRules   = fmt.Errorf("proto: integer overflow")
+	ErrInvalidLengthRules        = fmt.Errorf("proto: negative length found during unmarshaling")
+	ErrIntOverflowRules          = fmt.Errorf("proto: integer overflow")
+	ErrUnexpectedEndOfGroupRules = fmt.Errorf("proto: unexpected end of group")
 )
@@ -2,6 +2,7 @@ package ruler
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/go-kit/log"
 	"github.com/prometheus/client_golang/prometheus"
@@ -43,3 +44,44 @@ func NewRuleStore(ctx context.Context, cfg rulestore.Config, cfgProvider bucket.
 
 	return store, nil
 }
+
+// NewAlertStateStore returns the store of the alerts state, persisted in the object storage of the ruler storage.
+func NewAlertStateStore(ctx context.Context, cfg rulestore.Config, cfgProvider bucket.TenantConfigProvider, logger log.Logger, reg prometheus.Registerer) (*AlertStateStore, error) {
+	if cfg.Backend == configdb.Name || cfg.Backend == local.Name {
+		return nil, fmt.Errorf("the alert state persistence is not supported by the %s ruler storage backend", cfg.Backend)
+	}
+
+	bucketClient, err := bucket.NewClient(ctx, cfg.Config, "ruler-alert-state", logger, reg)
+	if err != nil {
+		return nil, err
+	}
+	return newAlertStateStore(bucketClient, cfgProvider, logger), nil
+}
+
+// NewLastEvaluationStore returns the store of the last evaluation of the rule groups, persisted in the object
+// storage of the ruler storage.
+func NewLastEvaluationStore(ctx context.Context, cfg rulestore.Config, cfgProvider bucket.TenantConfigProvider, logger log.Logger, reg prometheus.Registerer) (*LastEvaluationStore, error) {
+	if cfg.Backend == configdb.Name || cfg.Backend == local.Name {
+		return nil, fmt.Errorf("the evaluation catch-up is not supported by the %s ruler storage backend", cfg.Backend)
+	}
+
+	bucketClient, err := bucket.NewClient(ctx, cfg.Config, "ruler-last-evaluations", logger, reg)
+	if err != nil {
+		return nil, err
+	}
+	return newLastEvaluationStore(bucketClient, cfgProvider, logger), nil
+}
+
+// NewVersionedRuleStore returns a rule store keeping the history of the rule namespaces changed
+// through it, in the object storage of the ruler storage.
+func NewVersionedRuleStore(ctx context.Context, cfg rulestore.Config, store rulestore.RuleStore, maxVersions int, cfgProvider bucket.TenantConfigProvider, logger log.Logger, reg prometheus.Registerer) (*VersionedRuleStore, error) {
+	if cfg.Backend == configdb.Name || cfg.Backend == local.Name {
+		return nil, fmt.Errorf("the rules versioning is not supported by the %s ruler storage backend", cfg.Backend)
+	}
+
+	bucketClient, err := bucket.NewClient(ctx, cfg.Config, "ruler-rules-versions", logger, reg)
+	if err != nil {
+		return nil, err
+	}
+	return newVersionedRuleStore(store, bucketClient, maxVersions, cfgProvider, logger), nil
+}
@@ -288,7 +288,7 @@ func (m *mockRuleStore) DeleteRuleGroup(ctx context.Context, userID string, name
 
 	for i, rg := range userRules {
 		if rg.Namespace == namespace && rg.Name == group {
-			m.rules[userID] = append(userRules[:i], userRules[:i+1]...)
+			m.rules[userID] = append(userRules[:i], userRules[i+1:]...)
 			return nil
 		}
 	}
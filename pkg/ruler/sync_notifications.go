@@ -0,0 +1,232 @@
+package ruler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/util/concurrency"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+)
+
+const (
+	// maxSyncNotificationSize is the maximum size of the body of an object storage notification.
+	maxSyncNotificationSize = 1 << 20
+
+	snsTypeNotification             = "Notification"
+	snsTypeSubscriptionConfirmation = "SubscriptionConfirmation"
+)
+
+// bucketNotification is a notification of changes to the objects of the rules bucket. It can be an
+// Amazon SNS notification, wrapping an Amazon S3 event notification, an Amazon S3 event notification,
+// a Google Cloud Pub/Sub push message of a Google Cloud Storage notification, or a webhook notification
+// listing the keys of the changed objects.
+type bucketNotification struct {
+	// Amazon SNS notification.
+	Type         string `json:"Type"`
+	SubscribeURL string `json:"SubscribeURL"`
+
+	// The Amazon SNS message, or the Google Cloud Pub/Sub message.
+	Message json.RawMessage `json:"Message"`
+
+	// Amazon S3 event notification.
+	Records []struct {
+		S3 struct {
+			Object struct {
+				Key string `json:"key"`
+			} `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+
+	// Webhook notification.
+	Keys []string `json:"keys"`
+}
+
+type pubSubMessage struct {
+	Attributes struct {
+		ObjectID string `json:"objectId"`
+	} `json:"attributes"`
+}
+
+func parseBucketNotification(body []byte) (bucketNotification, error) {
+	n := bucketNotification{}
+	if err := json.Unmarshal(body, &n); err != nil {
+		return n, errors.Wrap(err, "unable to decode the notification")
+	}
+	return n, nil
+}
+
+// objectKeys returns the keys of the objects changed in the notification.
+func (n bucketNotification) objectKeys() ([]string, error) {
+	keys := append([]string(nil), n.Keys...)
+
+	for _, r := range n.Records {
+		// The keys of the Amazon S3 event notifications are URL encoded.
+		key, err := url.QueryUnescape(r.S3.Object.Key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to decode the object key %q", r.S3.Object.Key)
+		}
+		keys = append(keys, key)
+	}
+
+	if len(n.Message) == 0 {
+		return keys, nil
+	}
+
+	if n.Type == snsTypeNotification {
+		var message string
+		if err := json.Unmarshal(n.Message, &message); err != nil {
+			return nil, errors.Wrap(err, "unable to decode the Amazon SNS message")
+		}
+		inner, err := parseBucketNotification([]byte(message))
+		if err != nil {
+			return nil, err
+		}
+		innerKeys, err := inner.objectKeys()
+		if err != nil {
+			return nil, err
+		}
+		return append(keys, innerKeys...), nil
+	}
+
+	message := pubSubMessage{}
+	if err := json.Unmarshal(n.Message, &message); err != nil {
+		return nil, errors.Wrap(err, "unable to decode the Google Cloud Pub/Sub message")
+	}
+	if message.Attributes.ObjectID != "" {
+		keys = append(keys, message.Attributes.ObjectID)
+	}
+	return keys, nil
+}
+
+// ruleGroupObjectKeysUsers returns the sorted users of the rule groups objects among the object keys.
+// The rule groups objects are stored as "rules/<user>/<namespace>/<group>", optionally under a prefix.
+func ruleGroupObjectKeysUsers(keys []string) []string {
+	seen := map[string]struct{}{}
+	for _, key := range keys {
+		parts := strings.Split(strings.Trim(key, "/"), "/")
+		if len(parts) < 4 {
+			continue
+		}
+		parts = parts[len(parts)-4:]
+		if parts[0] != "rules" || parts[1] == "" || parts[2] == "" || parts[3] == "" {
+			continue
+		}
+		seen[parts[1]] = struct{}{}
+	}
+
+	users := make([]string, 0, len(seen))
+	for u := range seen {
+		users = append(users, u)
+	}
+	sort.Strings(users)
+	return users
+}
+
+// SyncNotification receives the notifications of changes to the objects of the rules bucket, and syncs
+// the rules of all the rulers if the rule groups of any of their users have changed.
+func (r *Ruler) SyncNotification(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), r.logger)
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxSyncNotificationSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	n, err := parseBucketNotification(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if n.Type == snsTypeSubscriptionConfirmation {
+		level.Info(logger).Log("msg", "received an Amazon SNS subscription confirmation, visit the subscribe URL to confirm the subscription", "url", n.SubscribeURL)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	keys, err := n.objectKeys()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	users := ruleGroupObjectKeysUsers(keys)
+	if len(users) == 0 {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	level.Debug(logger).Log("msg", "received a notification of changes to the rule groups", "users", strings.Join(users, ","))
+	r.notifySync(users)
+	if r.cfg.EnableSharding {
+		if err := r.notifyRulersSync(req.Context(), users); err != nil {
+			level.Warn(logger).Log("msg", "unable to notify the rulers of changes to the rule groups", "err", err)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// SyncRules implements the Ruler service, syncing the rules if the rule groups of any of the users
+// handled by this ruler have changed.
+func (r *Ruler) SyncRules(_ context.Context, req *SyncRulesRequest) (*SyncRulesResponse, error) {
+	r.notifySync(req.Users)
+	return &SyncRulesResponse{}, nil
+}
+
+// notifySync requests a sync of the rules if any of the users is allowed on this ruler. The requests
+// received while a sync is pending are coalesced into it.
+func (r *Ruler) notifySync(users []string) {
+	for _, u := range users {
+		if !r.allowedTenants.IsAllowed(u) {
+			continue
+		}
+		select {
+		case r.syncNotifications <- struct{}{}:
+		default:
+		}
+		return
+	}
+}
+
+// notifyRulersSync requests a sync of the rules to the other healthy rulers.
+func (r *Ruler) notifyRulersSync(ctx context.Context, users []string) error {
+	rulers, err := r.ring.GetAllHealthy(RingOp)
+	if err != nil {
+		return err
+	}
+
+	var addrs []interface{}
+	for _, addr := range rulers.GetAddresses() {
+		if addr != r.lifecycler.GetInstanceAddr() {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil
+	}
+
+	ctx = user.InjectOrgID(ctx, users[0]) // fake: the ruler server insists on having an org ID
+	return concurrency.ForEach(ctx, addrs, len(addrs), func(ctx context.Context, job interface{}) error {
+		addr := job.(string)
+
+		rulerClient, err := r.clientsPool.GetClientFor(addr)
+		if err == nil {
+			_, err = rulerClient.SyncRules(ctx, &SyncRulesRequest{Users: users})
+		}
+		if err != nil {
+			// The ruler syncs the rules on the next poll anyway, so we keep notifying the other rulers.
+			level.Warn(r.logger).Log("msg", "unable to notify the ruler of changes to the rule groups", "ruler", addr, "err", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,112 @@
+package ruler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+func TestBucketNotification_ObjectKeys(t *testing.T) {
+	s3Event := `{"Records":[{"eventName":"ObjectCreated:Put","s3":{"object":{"key":"rules/user-1/bnM%3D/Z3JvdXA%3D"}}}]}`
+	snsMessage, err := json.Marshal(s3Event)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		body     string
+		expected []string
+	}{
+		"Amazon S3 event notification": {
+			body:     s3Event,
+			expected: []string{"rules/user-1/bnM=/Z3JvdXA="},
+		},
+		"Amazon SNS notification": {
+			body:     `{"Type":"Notification","MessageId":"1","Message":` + string(snsMessage) + `}`,
+			expected: []string{"rules/user-1/bnM=/Z3JvdXA="},
+		},
+		"Google Cloud Pub/Sub push message": {
+			body:     `{"message":{"attributes":{"eventType":"OBJECT_FINALIZE","objectId":"rules/user-2/bnM=/Z3JvdXA="},"data":"e30="},"subscription":"projects/p/subscriptions/s"}`,
+			expected: []string{"rules/user-2/bnM=/Z3JvdXA="},
+		},
+		"webhook notification": {
+			body:     `{"keys":["rules/user-1/bnM=/Z3JvdXA=","rules/user-2/bnM=/Z3JvdXA="]}`,
+			expected: []string{"rules/user-1/bnM=/Z3JvdXA=", "rules/user-2/bnM=/Z3JvdXA="},
+		},
+		"unknown notification": {
+			body: `{"foo":"bar"}`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			n, err := parseBucketNotification([]byte(tc.body))
+			require.NoError(t, err)
+			keys, err := n.objectKeys()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, keys)
+		})
+	}
+}
+
+func TestRuleGroupObjectKeysUsers(t *testing.T) {
+	assert.Equal(t, []string{"user-1", "user-2"}, ruleGroupObjectKeysUsers([]string{
+		"rules/user-2/bnM=/Z3JvdXA=",
+		"rules/user-1/bnM=/Z3JvdXA=",
+		"prefix/rules/user-1/bnM=/b3RoZXI=",
+		// The objects other than the rule groups.
+		"alerts/user-3",
+		"ruler-alert-state/user-4/bnM=/Z3JvdXA=",
+		"rules/user-5/bnM=",
+	}))
+}
+
+func TestRuler_SyncNotification(t *testing.T) {
+	r := &Ruler{
+		cfg:               Config{},
+		allowedTenants:    util.NewAllowedTenants(nil, []string{"user-2"}),
+		syncNotifications: make(chan struct{}, 1),
+		logger:            log.NewNopLogger(),
+	}
+
+	notify := func(body string) int {
+		w := httptest.NewRecorder()
+		r.SyncNotification(w, httptest.NewRequest(http.MethodPost, "/ruler/sync_notification", strings.NewReader(body)))
+		return w.Code
+	}
+	synced := func() bool {
+		select {
+		case <-r.syncNotifications:
+			return true
+		default:
+			return false
+		}
+	}
+
+	// The rule groups of an allowed user have changed.
+	assert.Equal(t, http.StatusOK, notify(`{"keys":["rules/user-1/bnM=/Z3JvdXA="]}`))
+	assert.True(t, synced())
+
+	// The notifications are coalesced while a sync is pending.
+	assert.Equal(t, http.StatusOK, notify(`{"keys":["rules/user-1/bnM=/Z3JvdXA="]}`))
+	assert.Equal(t, http.StatusOK, notify(`{"keys":["rules/user-1/bnM=/b3RoZXI="]}`))
+	assert.True(t, synced())
+	assert.False(t, synced())
+
+	// The rule groups of a disabled user, or other objects, have changed.
+	assert.Equal(t, http.StatusOK, notify(`{"keys":["rules/user-2/bnM=/Z3JvdXA="]}`))
+	assert.Equal(t, http.StatusOK, notify(`{"keys":["alerts/user-1"]}`))
+	assert.False(t, synced())
+
+	// The Amazon SNS subscription confirmations are accepted.
+	assert.Equal(t, http.StatusOK, notify(`{"Type":"SubscriptionConfirmation","SubscribeURL":"https://sns.example.com"}`))
+	assert.False(t, synced())
+
+	assert.Equal(t, http.StatusBadRequest, notify(`not json`))
+}
@@ -239,6 +239,33 @@ func (q *RequestQueue) NotifyQuerierShutdown(querierID string) {
 	q.queues.notifyQuerierShutdown(querierID)
 }
 
+// DrainQuerier marks the querier as draining: it will not receive any new request, while requests
+// it's already processing are not affected. Returns false if the querier is not connected.
+func (q *RequestQueue) DrainQuerier(querierID string) bool {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if !q.queues.drainQuerier(querierID) {
+		return false
+	}
+
+	// Other queriers may now be part of the resharded users' queriers.
+	q.cond.Broadcast()
+	return true
+}
+
+// IsQuerierDraining returns whether the querier has been marked as draining, and whether it is registered at all.
+func (q *RequestQueue) IsQuerierDraining(querierID string) (draining bool, registered bool) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	info := q.queues.queriers[querierID]
+	if info == nil {
+		return false, false
+	}
+	return info.draining, true
+}
+
 // When querier is waiting for next request, this unblocks the method.
 func (q *RequestQueue) QuerierDisconnecting() {
 	q.cond.Broadcast()
@@ -11,6 +11,10 @@ import (
 	"github.com/cortexproject/cortex/pkg/util/validation"
 )
 
+// maxReservedQueriersRatio is the maximum ratio of the queriers which can be reserved by all the users
+// together, so that the users not reserving queriers are never starved.
+const maxReservedQueriersRatio = 0.5
+
 // Limits needed for the Query Scheduler - interface used for decoupling.
 type Limits interface {
 	// MaxOutstandingPerTenant returns the limit to the maximum number
@@ -20,6 +24,10 @@ type Limits interface {
 	// QueryPriority returns query priority config for the tenant, including priority level,
 	// their attributes, and how many reserved queriers each priority has.
 	QueryPriority(user string) validation.QueryPriority
+
+	// ReservedQueriersPerTenant returns the number of queriers reserved for the tenant,
+	// or 0 if no querier is reserved.
+	ReservedQueriersPerTenant(user string) float64
 }
 
 // querier holds information about a querier registered in the queue.
@@ -30,6 +38,10 @@ type querier struct {
 	// True if the querier notified it's gracefully shutting down.
 	shuttingDown bool
 
+	// True if the querier has been marked as draining. A draining querier doesn't get
+	// new requests and is not part of any user's shard.
+	draining bool
+
 	// When the last connection has been unregistered.
 	disconnectedAt time.Time
 }
@@ -53,9 +65,13 @@ type queues struct {
 	// Tracks queriers registered to the queue.
 	queriers map[string]*querier
 
-	// Sorted list of querier names, used when creating per-user shard.
+	// Sorted list of querier names, used when creating per-user shard. Draining queriers are excluded.
 	sortedQueriers []string
 
+	// Users which reserved the querier, for each querier reserved by users with pending requests.
+	// Recomputed when the reserved queriers of any user change.
+	querierReservations map[string]map[string]struct{}
+
 	limits Limits
 
 	queueLength *prometheus.GaugeVec // Per user, type and priority.
@@ -71,11 +87,16 @@ type userQueue struct {
 	// Contains assigned priority for querier ID
 	reservedQueriers map[string]int64
 
+	// If not nil, these queriers only handle requests from users which reserved them,
+	// as long as this user has pending requests.
+	tenantReservedQueriers map[string]struct{}
+
 	// Stores last limit config for the user. When changed, re-populate queriers and reservedQueriers
-	maxQueriers     int
-	maxOutstanding  int
-	priorityList    []int64
-	priorityEnabled bool
+	maxQueriers          int
+	reservedQuerierCount int
+	maxOutstanding       int
+	priorityList         []int64
+	priorityEnabled      bool
 
 	// Seed for shuffle sharding of queriers. This seed is based on userID only and is therefore consistent
 	// between different frontends.
@@ -115,6 +136,11 @@ func (q *queues) deleteQueue(userID string) {
 	for ix := len(q.users) - 1; ix >= 0 && q.users[ix] == ""; ix-- {
 		q.users = q.users[:ix]
 	}
+
+	// The queriers reserved by the user are released.
+	if len(uq.tenantReservedQueriers) > 0 {
+		q.recomputeQuerierReservations()
+	}
 }
 
 // Returns existing or new queue for user.
@@ -136,6 +162,7 @@ func (q *queues) getOrAddQueue(userID string, maxQueriers int) userRequestQueue
 	priorityEnabled := q.limits.QueryPriority(userID).Enabled
 	maxOutstanding := q.limits.MaxOutstandingPerTenant(userID)
 	priorityList := getPriorityList(q.limits.QueryPriority(userID), maxQueriers)
+	reservedQuerierCount := getReservedQuerierCount(q.limits.ReservedQueriersPerTenant(userID), maxQueriers, len(q.sortedQueriers))
 
 	if uq == nil {
 		uq = &userQueue{
@@ -179,6 +206,12 @@ func (q *queues) getOrAddQueue(userID string, maxQueriers int) userRequestQueue
 		uq.queriers = shuffleQueriersForUser(uq.seed, maxQueriers, q.sortedQueriers, nil)
 	}
 
+	if uq.reservedQuerierCount != reservedQuerierCount {
+		uq.reservedQuerierCount = reservedQuerierCount
+		uq.tenantReservedQueriers = shuffleQueriersForUser(uq.seed, reservedQuerierCount, q.sortedQueriers, nil)
+		q.recomputeQuerierReservations()
+	}
+
 	if priorityEnabled && hasPriorityListChanged(uq.priorityList, priorityList) {
 		reservedQueriers := make(map[string]int64)
 
@@ -220,9 +253,16 @@ func (q *queues) createUserRequestQueue(userID string) userRequestQueue {
 // Finds next queue for the querier. To support fair scheduling between users, client is expected
 // to pass last user index returned by this function as argument. Is there was no previous
 // last user index, use -1.
+// If the querier is reserved by any user with pending requests, only those users are considered.
 func (q *queues) getNextQueueForQuerier(lastUserIndex int, querierID string) (userRequestQueue, string, int) {
 	uid := lastUserIndex
 
+	if info := q.queriers[querierID]; info != nil && info.draining {
+		return nil, "", uid
+	}
+
+	reservedBy := q.querierReservations[querierID]
+
 	for iters := 0; iters < len(q.users); iters++ {
 		uid = uid + 1
 
@@ -239,6 +279,13 @@ func (q *queues) getNextQueueForQuerier(lastUserIndex int, querierID string) (us
 
 		uq := q.userQueues[u]
 
+		if reservedBy != nil {
+			if _, ok := reservedBy[u]; !ok {
+				// This querier is reserved by other users.
+				continue
+			}
+		}
+
 		if uq.queriers != nil {
 			if _, ok := uq.queriers[querierID]; !ok {
 				// This querier is not handling the user.
@@ -297,8 +344,14 @@ func (q *queues) removeQuerierConnection(querierID string, now time.Time) {
 }
 
 func (q *queues) removeQuerier(querierID string) {
+	info := q.queriers[querierID]
 	delete(q.queriers, querierID)
 
+	// Draining queriers have already been removed from the sorted queriers.
+	if info != nil && info.draining {
+		return
+	}
+
 	ix := sort.SearchStrings(q.sortedQueriers, querierID)
 	if ix >= len(q.sortedQueriers) || q.sortedQueriers[ix] != querierID {
 		panic("incorrect state of sorted queriers")
@@ -328,6 +381,30 @@ func (q *queues) notifyQuerierShutdown(querierID string) {
 	info.shuttingDown = true
 }
 
+// drainQuerier marks the querier as draining, so that it doesn't get any new request, and
+// reshards users to the remaining queriers. Returns false if the querier is not registered.
+func (q *queues) drainQuerier(querierID string) bool {
+	info := q.queriers[querierID]
+	if info == nil {
+		return false
+	}
+
+	if info.draining {
+		return true
+	}
+
+	ix := sort.SearchStrings(q.sortedQueriers, querierID)
+	if ix >= len(q.sortedQueriers) || q.sortedQueriers[ix] != querierID {
+		panic("incorrect state of sorted queriers")
+	}
+
+	info.draining = true
+	q.sortedQueriers = append(q.sortedQueriers[:ix], q.sortedQueriers[ix+1:]...)
+
+	q.recomputeUserQueriers()
+	return true
+}
+
 // forgetDisconnectedQueriers removes all disconnected queriers that have gone since at least
 // the forget delay. Returns the number of forgotten queriers.
 func (q *queues) forgetDisconnectedQueriers(now time.Time) int {
@@ -353,8 +430,56 @@ func (q *queues) forgetDisconnectedQueriers(now time.Time) int {
 func (q *queues) recomputeUserQueriers() {
 	scratchpad := make([]string, 0, len(q.sortedQueriers))
 
-	for _, uq := range q.userQueues {
+	for userID, uq := range q.userQueues {
 		uq.queriers = shuffleQueriersForUser(uq.seed, uq.maxQueriers, q.sortedQueriers, scratchpad)
+
+		// The number of reserved queriers depends on the number of available queriers.
+		uq.reservedQuerierCount = getReservedQuerierCount(q.limits.ReservedQueriersPerTenant(userID), uq.maxQueriers, len(q.sortedQueriers))
+		uq.tenantReservedQueriers = shuffleQueriersForUser(uq.seed, uq.reservedQuerierCount, q.sortedQueriers, scratchpad)
+	}
+
+	q.recomputeQuerierReservations()
+}
+
+// recomputeQuerierReservations recomputes the users which reserved each querier. The users reserve their
+// queriers in the order of the users list, until maxReservedQueriersRatio of the queriers are reserved.
+// Then, the users can only share the queriers already reserved by other users.
+func (q *queues) recomputeQuerierReservations() {
+	q.querierReservations = nil
+	maxReserved := int(float64(len(q.sortedQueriers)) * maxReservedQueriersRatio)
+
+	var reserved []string
+	for _, userID := range q.users {
+		if userID == "" {
+			continue
+		}
+
+		uq := q.userQueues[userID]
+		if len(uq.tenantReservedQueriers) == 0 {
+			continue
+		}
+
+		// Sort the reserved queriers, so that the same ones are kept when the cap is reached.
+		reserved = reserved[:0]
+		for querierID := range uq.tenantReservedQueriers {
+			reserved = append(reserved, querierID)
+		}
+		sort.Strings(reserved)
+
+		for _, querierID := range reserved {
+			users, ok := q.querierReservations[querierID]
+			if !ok {
+				if len(q.querierReservations) >= maxReserved {
+					continue
+				}
+				if q.querierReservations == nil {
+					q.querierReservations = map[string]map[string]struct{}{}
+				}
+				users = map[string]struct{}{}
+				q.querierReservations[querierID] = users
+			}
+			users[userID] = struct{}{}
+		}
 	}
 }
 
@@ -405,6 +530,23 @@ func getPriorityList(queryPriority validation.QueryPriority, totalQuerierCount i
 	return priorityList
 }
 
+// getReservedQuerierCount returns the number of queriers reserved for a user. Reserved queriers are
+// selected with the same seed used for shuffle sharding, so they are always part of the user's shard.
+func getReservedQuerierCount(reservedQueriers float64, maxQueriers int, totalQuerierCount int) int {
+	count := util.DynamicShardSize(reservedQueriers, totalQuerierCount)
+
+	if maxQueriers > 0 && count > maxQueriers {
+		count = maxQueriers
+	}
+
+	// Reserving all queriers would starve every other user.
+	if count >= totalQuerierCount {
+		return 0
+	}
+
+	return count
+}
+
 func hasPriorityListChanged(old, new []int64) bool {
 	if len(old) != len(new) {
 		return true
@@ -422,6 +564,7 @@ type MockLimits struct {
 	MaxOutstanding        int
 	MaxQueriersPerUserVal float64
 	QueryPriorityVal      validation.QueryPriority
+	ReservedQueriersVal   float64
 }
 
 func (l MockLimits) MaxQueriersPerUser(_ string) float64 {
@@ -435,3 +578,7 @@ func (l MockLimits) MaxOutstandingPerTenant(_ string) int {
 func (l MockLimits) QueryPriority(_ string) validation.QueryPriority {
 	return l.QueryPriorityVal
 }
+
+func (l MockLimits) ReservedQueriersPerTenant(_ string) float64 {
+	return l.ReservedQueriersVal
+}
@@ -484,7 +484,13 @@ func confirmOrderForQuerier(t *testing.T, uq *queues, querier string, lastUserIn
 }
 
 func isConsistent(uq *queues) error {
-	if len(uq.sortedQueriers) != len(uq.queriers) {
+	activeQueriers := 0
+	for _, info := range uq.queriers {
+		if !info.draining {
+			activeQueriers++
+		}
+	}
+	if len(uq.sortedQueriers) != activeQueriers {
 		return fmt.Errorf("inconsistent number of sorted queriers and querier connections")
 	}
 
@@ -518,6 +524,28 @@ func isConsistent(uq *queues) error {
 		if q.maxQueriers > 0 && len(uq.sortedQueriers) > q.maxQueriers && len(q.queriers) != q.maxQueriers {
 			return fmt.Errorf("user %s has incorrect number of queriers, expected=%d, got=%d", u, len(q.queriers), q.maxQueriers)
 		}
+
+		for querierID := range q.tenantReservedQueriers {
+			if _, ok := q.queriers[querierID]; q.queriers != nil && !ok {
+				return fmt.Errorf("user %s has reserved querier %s outside of its queriers", u, querierID)
+			}
+		}
+	}
+
+	if maxReserved := int(float64(len(uq.sortedQueriers)) * maxReservedQueriersRatio); len(uq.querierReservations) > maxReserved {
+		return fmt.Errorf("too many reserved queriers, expected at most %d, got %d", maxReserved, len(uq.querierReservations))
+	}
+
+	for querierID, users := range uq.querierReservations {
+		for u := range users {
+			q := uq.userQueues[u]
+			if q == nil {
+				return fmt.Errorf("querier %s is reserved by user %s without queue", querierID, u)
+			}
+			if _, ok := q.tenantReservedQueriers[querierID]; !ok {
+				return fmt.Errorf("querier %s is reserved by user %s but is not one of its reserved queriers", querierID, u)
+			}
+		}
 	}
 
 	if uc != len(uq.userQueues) {
@@ -564,6 +592,43 @@ func getKeys(x interface{}) []string {
 	return keys
 }
 
+func TestQueues_DrainQuerier(t *testing.T) {
+	uq := newUserQueues(0, 0, MockLimits{}, nil)
+
+	for ix := 0; ix < 3; ix++ {
+		uq.addQuerierConnection(fmt.Sprintf("querier-%d", ix))
+	}
+
+	q := getOrAdd(t, uq, "user-1", 2)
+	require.Len(t, uq.userQueues["user-1"].queriers, 2)
+
+	require.False(t, uq.drainQuerier("unknown"))
+
+	// Drain a querier of the user's shard.
+	var drained string
+	for qid := range uq.userQueues["user-1"].queriers {
+		drained = qid
+		break
+	}
+	require.True(t, uq.drainQuerier(drained))
+	require.True(t, uq.drainQuerier(drained))
+	require.NoError(t, isConsistent(uq))
+
+	// The draining querier doesn't get any queue, and the user has been resharded to the remaining queriers.
+	confirmOrderForQuerier(t, uq, drained, -1, nil, nil)
+	require.NotContains(t, uq.userQueues["user-1"].queriers, drained)
+	for ix := 0; ix < 3; ix++ {
+		if qid := fmt.Sprintf("querier-%d", ix); qid != drained {
+			confirmOrderForQuerier(t, uq, qid, -1, q, q)
+		}
+	}
+
+	// The draining querier is removed once it disconnects.
+	uq.removeQuerierConnection(drained, time.Now())
+	require.NotContains(t, uq.queriers, drained)
+	require.NoError(t, isConsistent(uq))
+}
+
 func TestShuffleQueriers(t *testing.T) {
 	allQueriers := []string{"a", "b", "c", "d", "e"}
 
@@ -622,6 +687,135 @@ func TestHasPriorityListChanged(t *testing.T) {
 	require.False(t, hasPriorityListChanged([]int64{}, []int64{}))
 }
 
+func TestQueuesWithTenantReservedQueriers(t *testing.T) {
+	uq := newUserQueues(0, 0, MockLimits{ReservedQueriersVal: 1}, nil)
+
+	for ix := 0; ix < 3; ix++ {
+		uq.addQuerierConnection(fmt.Sprintf("querier-%d", ix))
+	}
+
+	// Only user-a reserves a querier.
+	qa := getOrAdd(t, uq, "user-a", 0)
+	uq.limits = MockLimits{}
+	qb := getOrAdd(t, uq, "user-b", 0)
+
+	require.Len(t, uq.userQueues["user-a"].tenantReservedQueriers, 1)
+	require.Nil(t, uq.userQueues["user-b"].tenantReservedQueriers)
+
+	for ix := 0; ix < 3; ix++ {
+		qid := fmt.Sprintf("querier-%d", ix)
+		if _, ok := uq.userQueues["user-a"].tenantReservedQueriers[qid]; ok {
+			// The reserved querier only handles user-a while it has pending requests.
+			confirmOrderForQuerier(t, uq, qid, -1, qa, qa, qa)
+		} else {
+			confirmOrderForQuerier(t, uq, qid, -1, qa, qb, qa, qb)
+		}
+	}
+
+	// Once user-a has no pending requests, its reserved querier is shared again.
+	uq.deleteQueue("user-a")
+	for ix := 0; ix < 3; ix++ {
+		confirmOrderForQuerier(t, uq, fmt.Sprintf("querier-%d", ix), -1, qb, qb)
+	}
+}
+
+func TestQueuesTenantReservedQueriersShouldBeWithinShard(t *testing.T) {
+	uq := newUserQueues(0, 0, MockLimits{ReservedQueriersVal: 2}, nil)
+
+	for ix := 0; ix < 10; ix++ {
+		uq.addQuerierConnection(fmt.Sprintf("querier-%d", ix))
+	}
+
+	for u := 0; u < 100; u++ {
+		uid := fmt.Sprintf("user-%d", u)
+		getOrAdd(t, uq, uid, 3)
+		assert.Len(t, uq.userQueues[uid].tenantReservedQueriers, 2)
+	}
+
+	// Removing queriers reshuffles both the shard and the reserved queriers.
+	for ix := 0; ix < 5; ix++ {
+		uq.removeQuerier(fmt.Sprintf("querier-%d", ix))
+		assert.NoError(t, isConsistent(uq))
+	}
+}
+
+func TestQueuesTenantReservedQueriersShouldBeCappedGlobally(t *testing.T) {
+	uq := newUserQueues(0, 0, MockLimits{ReservedQueriersVal: 1}, nil)
+
+	for ix := 0; ix < 4; ix++ {
+		uq.addQuerierConnection(fmt.Sprintf("querier-%d", ix))
+	}
+
+	queues := map[string]userRequestQueue{}
+	for u := 0; u < 20; u++ {
+		uid := fmt.Sprintf("user-%d", u)
+		queues[uid] = getOrAdd(t, uq, uid, 0)
+		require.NoError(t, isConsistent(uq))
+	}
+
+	// Every user reserves a querier, but at most half of the queriers are reserved.
+	require.Len(t, uq.querierReservations, 2)
+
+	// The queriers not reserved handle the requests of all the users.
+	for ix := 0; ix < 4; ix++ {
+		qid := fmt.Sprintf("querier-%d", ix)
+		if _, ok := uq.querierReservations[qid]; ok {
+			continue
+		}
+
+		lastUserIndex := -1
+		seen := map[string]struct{}{}
+		for i := 0; i < 20; i++ {
+			var u string
+			_, u, lastUserIndex = uq.getNextQueueForQuerier(lastUserIndex, qid)
+			seen[u] = struct{}{}
+		}
+		assert.Len(t, seen, 20)
+	}
+
+	// Releasing the reservations of the users without pending requests makes room for the others.
+	for u := 0; u < 20; u++ {
+		uid := fmt.Sprintf("user-%d", u)
+		if len(uq.querierReservations) == 0 {
+			break
+		}
+		uq.deleteQueue(uid)
+		require.NoError(t, isConsistent(uq))
+	}
+}
+
+func TestQueuesTenantReservedQueriersShouldBeRecomputedWhenQueriersChange(t *testing.T) {
+	uq := newUserQueues(0, 0, MockLimits{ReservedQueriersVal: 0.5}, nil)
+
+	uq.addQuerierConnection("querier-0")
+	uq.addQuerierConnection("querier-1")
+	getOrAdd(t, uq, "user", 0)
+	require.Len(t, uq.userQueues["user"].tenantReservedQueriers, 1)
+
+	// The number of reserved queriers follows the number of connected queriers.
+	uq.addQuerierConnection("querier-2")
+	uq.addQuerierConnection("querier-3")
+	assert.Equal(t, 2, uq.userQueues["user"].reservedQuerierCount)
+	assert.Len(t, uq.userQueues["user"].tenantReservedQueriers, 2)
+	assert.Len(t, uq.querierReservations, 2)
+	require.NoError(t, isConsistent(uq))
+
+	uq.removeQuerier("querier-3")
+	uq.removeQuerier("querier-2")
+	assert.Equal(t, 1, uq.userQueues["user"].reservedQuerierCount)
+	assert.Len(t, uq.querierReservations, 1)
+	require.NoError(t, isConsistent(uq))
+}
+
+func TestGetReservedQuerierCount(t *testing.T) {
+	assert.Equal(t, 0, getReservedQuerierCount(0, 0, 10))
+	assert.Equal(t, 2, getReservedQuerierCount(2, 0, 10))
+	assert.Equal(t, 3, getReservedQuerierCount(0.3, 0, 10))
+	assert.Equal(t, 4, getReservedQuerierCount(5, 4, 10))
+	assert.Equal(t, 0, getReservedQuerierCount(10, 0, 10))
+	assert.Equal(t, 0, getReservedQuerierCount(1, 0, 1))
+}
+
 func TestGetPriorityList(t *testing.T) {
 	queryPriority := validation.QueryPriority{
 		Enabled: true,
@@ -2,9 +2,12 @@ package scheduler
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"flag"
 	"io"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
@@ -55,6 +58,14 @@ type Scheduler struct {
 	pendingRequestsMu sync.Mutex
 	pendingRequests   map[requestKey]*schedulerRequest // Request is kept in this map even after being dispatched to querier. It can still be canceled at that time.
 
+	// Requests sitting in the queue, indexed by their coalescing key. Only used when query coalescing is enabled.
+	queuedRequestsMu sync.Mutex
+	queuedRequests   map[string]*schedulerRequest
+
+	// Number of requests currently being processed by each querier.
+	inflightRequestsMu sync.Mutex
+	inflightRequests   map[string]int
+
 	// Subservices manager.
 	subservices        *services.Manager
 	subservicesWatcher *services.FailureWatcher
@@ -65,6 +76,7 @@ type Scheduler struct {
 	connectedQuerierClients  prometheus.GaugeFunc
 	connectedFrontendClients prometheus.GaugeFunc
 	queueDuration            prometheus.Histogram
+	coalescedRequests        *prometheus.CounterVec
 }
 
 type requestKey struct {
@@ -85,12 +97,14 @@ type Config struct {
 	MaxOutstandingPerTenant int               `yaml:"max_outstanding_requests_per_tenant"`
 	QuerierForgetDelay      time.Duration     `yaml:"querier_forget_delay"`
 	GRPCClientConfig        grpcclient.Config `yaml:"grpc_client_config" doc:"description=This configures the gRPC client used to report errors back to the query-frontend."`
+	QueryCoalescingEnabled  bool              `yaml:"query_coalescing_enabled"`
 }
 
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.MaxOutstandingPerTenant, "query-scheduler.max-outstanding-requests-per-tenant", 0, "Deprecated (use frontend.max-outstanding-requests-per-tenant instead) and will be removed in v1.17.0: Maximum number of outstanding requests per tenant per query-scheduler. In-flight requests above this limit will fail with HTTP response status code 429.")
 	f.DurationVar(&cfg.QuerierForgetDelay, "query-scheduler.querier-forget-delay", 0, "If a querier disconnects without sending notification about graceful shutdown, the query-scheduler will keep the querier in the tenant's shard until the forget delay has passed. This feature is useful to reduce the blast radius when shuffle-sharding is enabled.")
 	cfg.GRPCClientConfig.RegisterFlagsWithPrefix("query-scheduler.grpc-client-config", f)
+	f.BoolVar(&cfg.QueryCoalescingEnabled, "query-scheduler.query-coalescing-enabled", false, "[Experimental] If enabled, identical queries from the same tenant which are waiting in the queue are coalesced: the query is executed once by a querier and the result is sent to all the query-frontends waiting for it.")
 }
 
 // NewScheduler creates a new Scheduler.
@@ -101,6 +115,8 @@ func NewScheduler(cfg Config, limits Limits, log log.Logger, registerer promethe
 		limits: limits,
 
 		pendingRequests:    map[requestKey]*schedulerRequest{},
+		queuedRequests:     map[string]*schedulerRequest{},
+		inflightRequests:   map[string]int{},
 		connectedFrontends: map[string]*connectedFrontend{},
 	}
 
@@ -114,6 +130,11 @@ func NewScheduler(cfg Config, limits Limits, log log.Logger, registerer promethe
 		Help: "Total number of query requests discarded.",
 	}, []string{"user", "priority"})
 
+	s.coalescedRequests = promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_query_scheduler_coalesced_requests_total",
+		Help: "Total number of query requests coalesced with an identical request already in the queue.",
+	}, []string{"user"})
+
 	s.requestQueue = queue.NewRequestQueue(cfg.MaxOutstandingPerTenant, cfg.QuerierForgetDelay, s.queueLength, s.discardedRequests, s.limits, registerer)
 
 	s.queueDuration = promauto.With(registerer).NewHistogram(prometheus.HistogramOpts{
@@ -163,6 +184,11 @@ type schedulerRequest struct {
 	ctxCancel context.CancelFunc
 	queueSpan opentracing.Span
 
+	// Key used to coalesce identical requests, and requests coalesced with this one
+	// while it was waiting in the queue. Protected by Scheduler.queuedRequestsMu.
+	coalescingKey string
+	coalesced     []*schedulerRequest
+
 	// This is only used for testing.
 	parentSpanContext opentracing.SpanContext
 }
@@ -321,15 +347,99 @@ func (s *Scheduler) enqueueRequest(frontendContext context.Context, frontendAddr
 	maxQueriers := validation.SmallestPositiveNonZeroFloat64PerTenant(tenantIDs, s.limits.MaxQueriersPerUser)
 
 	s.activeUsers.UpdateUserTimestamp(userID, now)
+
+	if s.cfg.QueryCoalescingEnabled {
+		req.coalescingKey = coalescingKey(userID, msg.HttpRequest, msg.StatsEnabled)
+		if s.coalesceRequest(req) {
+			shouldCancel = false
+			s.coalescedRequests.WithLabelValues(userID).Inc()
+			s.addPendingRequest(req)
+			return nil
+		}
+	}
+
 	return s.requestQueue.EnqueueRequest(userID, req, maxQueriers, func() {
 		shouldCancel = false
+		s.addPendingRequest(req)
 
-		s.pendingRequestsMu.Lock()
-		defer s.pendingRequestsMu.Unlock()
-		s.pendingRequests[requestKey{frontendAddr: frontendAddr, queryID: msg.QueryID}] = req
+		if req.coalescingKey != "" {
+			s.queuedRequestsMu.Lock()
+			defer s.queuedRequestsMu.Unlock()
+			s.queuedRequests[req.coalescingKey] = req
+		}
 	})
 }
 
+func (s *Scheduler) addPendingRequest(req *schedulerRequest) {
+	s.pendingRequestsMu.Lock()
+	defer s.pendingRequestsMu.Unlock()
+	s.pendingRequests[requestKey{frontendAddr: req.frontendAddress, queryID: req.queryID}] = req
+}
+
+// coalescingKey returns the key identifying identical requests. Requests are considered identical
+// if they're issued by the same tenant with the same method, URL (which includes the query expression,
+// time range and step), body and accepted response format.
+func coalescingKey(userID string, req *httpgrpc.HTTPRequest, statsEnabled bool) string {
+	h := sha256.New()
+	for _, v := range []string{userID, req.Method, req.Url, strconv.FormatBool(statsEnabled)} {
+		_, _ = h.Write([]byte(v))
+		_, _ = h.Write([]byte{0})
+	}
+	for _, header := range req.Headers {
+		if http.CanonicalHeaderKey(header.Key) != "Accept" {
+			continue
+		}
+		for _, v := range header.Values {
+			_, _ = h.Write([]byte(v))
+			_, _ = h.Write([]byte{0})
+		}
+	}
+	_, _ = h.Write(req.Body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// coalesceRequest attaches the request to an identical request waiting in the queue, if any.
+// Returns true if the request has been coalesced and must not be enqueued.
+func (s *Scheduler) coalesceRequest(req *schedulerRequest) bool {
+	s.queuedRequestsMu.Lock()
+	defer s.queuedRequestsMu.Unlock()
+
+	queued := s.queuedRequests[req.coalescingKey]
+	if queued == nil {
+		return false
+	}
+
+	queued.coalesced = append(queued.coalesced, req)
+	return true
+}
+
+// takeCoalescedRequests removes the request from the set of queued requests, and returns
+// the requests coalesced with it which haven't been canceled in the meantime.
+func (s *Scheduler) takeCoalescedRequests(req *schedulerRequest) []*schedulerRequest {
+	if req.coalescingKey == "" {
+		return nil
+	}
+
+	s.queuedRequestsMu.Lock()
+	defer s.queuedRequestsMu.Unlock()
+
+	if s.queuedRequests[req.coalescingKey] == req {
+		delete(s.queuedRequests, req.coalescingKey)
+	}
+
+	coalesced := make([]*schedulerRequest, 0, len(req.coalesced))
+	for _, r := range req.coalesced {
+		if r.ctx.Err() != nil {
+			s.cancelRequestAndRemoveFromPending(r.frontendAddress, r.queryID)
+			continue
+		}
+		coalesced = append(coalesced, r)
+	}
+	req.coalesced = nil
+
+	return coalesced
+}
+
 // This method doesn't do removal from the queue.
 func (s *Scheduler) cancelRequestAndRemoveFromPending(frontendAddr string, queryID uint64) {
 	s.pendingRequestsMu.Lock()
@@ -374,9 +484,13 @@ func (s *Scheduler) QuerierLoop(querier schedulerpb.SchedulerForQuerier_QuerierL
 		lastUserIndex = idx
 
 		r := req.(*schedulerRequest)
+		coalesced := s.takeCoalescedRequests(r)
 
 		s.queueDuration.Observe(time.Since(r.enqueueTime).Seconds())
 		r.queueSpan.Finish()
+		for _, c := range coalesced {
+			c.queueSpan.Finish()
+		}
 
 		/*
 		  We want to dequeue the next unexpired request from the chosen tenant queue.
@@ -394,11 +508,18 @@ func (s *Scheduler) QuerierLoop(querier schedulerpb.SchedulerForQuerier_QuerierL
 			// Remove from pending requests.
 			s.cancelRequestAndRemoveFromPending(r.frontendAddress, r.queryID)
 
-			lastUserIndex = lastUserIndex.ReuseLastUser()
-			continue
+			// The request has been canceled, but requests coalesced with it are still waiting for the result.
+			if len(coalesced) == 0 {
+				lastUserIndex = lastUserIndex.ReuseLastUser()
+				continue
+			}
+			r, coalesced = coalesced[0], coalesced[1:]
 		}
 
-		if err := s.forwardRequestToQuerier(querier, r); err != nil {
+		s.updateInflightRequests(querierID, 1)
+		err = s.forwardRequestToQuerier(querier, r, coalesced)
+		s.updateInflightRequests(querierID, -1)
+		if err != nil {
 			return err
 		}
 	}
@@ -413,21 +534,87 @@ func (s *Scheduler) NotifyQuerierShutdown(_ context.Context, req *schedulerpb.No
 	return &schedulerpb.NotifyQuerierShutdownResponse{}, nil
 }
 
-func (s *Scheduler) forwardRequestToQuerier(querier schedulerpb.SchedulerForQuerier_QuerierLoopServer, req *schedulerRequest) error {
+func (s *Scheduler) updateInflightRequests(querierID string, delta int) {
+	s.inflightRequestsMu.Lock()
+	defer s.inflightRequestsMu.Unlock()
+
+	s.inflightRequests[querierID] += delta
+	if s.inflightRequests[querierID] <= 0 {
+		delete(s.inflightRequests, querierID)
+	}
+}
+
+// QuerierDrainStatus is the response of the querier drain endpoint.
+type QuerierDrainStatus struct {
+	QuerierID        string `json:"querier_id"`
+	Registered       bool   `json:"registered"`
+	Draining         bool   `json:"draining"`
+	InflightRequests int    `json:"inflight_requests"`
+	SafeToTerminate  bool   `json:"safe_to_terminate"`
+}
+
+// DrainQuerierHandler marks a querier as draining (POST) and reports whether it is safe to terminate it (GET and POST).
+// A draining querier doesn't get any new request, and it's safe to terminate once it has no more in-flight requests.
+func (s *Scheduler) DrainQuerierHandler(w http.ResponseWriter, r *http.Request) {
+	querierID := r.FormValue("querier_id")
+	if querierID == "" {
+		http.Error(w, "querier_id parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if !s.requestQueue.DrainQuerier(querierID) {
+			http.Error(w, "querier is not connected", http.StatusNotFound)
+			return
+		}
+		level.Info(s.log).Log("msg", "querier marked as draining", "querier", querierID)
+	}
+
+	status := QuerierDrainStatus{QuerierID: querierID}
+	status.Draining, status.Registered = s.requestQueue.IsQuerierDraining(querierID)
+
+	s.inflightRequestsMu.Lock()
+	status.InflightRequests = s.inflightRequests[querierID]
+	s.inflightRequestsMu.Unlock()
+
+	// A querier which is not registered anymore can't be processing requests from this query-scheduler.
+	status.SafeToTerminate = !status.Registered || (status.Draining && status.InflightRequests == 0)
+
+	util.WriteJSONResponse(w, status)
+}
+
+func (s *Scheduler) forwardRequestToQuerier(querier schedulerpb.SchedulerForQuerier_QuerierLoopServer, req *schedulerRequest, coalesced []*schedulerRequest) error {
 	// Make sure to cancel request at the end to cleanup resources.
 	defer s.cancelRequestAndRemoveFromPending(req.frontendAddress, req.queryID)
+	for _, c := range coalesced {
+		defer s.cancelRequestAndRemoveFromPending(c.frontendAddress, c.queryID)
+	}
+
+	// When requests have been coalesced, the query must keep running until all of them are canceled.
+	ctx := req.ctx
+	if len(coalesced) > 0 {
+		ctx = coalescedContext(append([]*schedulerRequest{req}, coalesced...))
+	}
+
+	msg := &schedulerpb.SchedulerToQuerier{
+		UserID:          req.userID,
+		QueryID:         req.queryID,
+		FrontendAddress: req.frontendAddress,
+		HttpRequest:     req.request,
+		StatsEnabled:    req.statsEnabled,
+	}
+	for _, c := range coalesced {
+		msg.CoalescedRequests = append(msg.CoalescedRequests, schedulerpb.CoalescedRequest{
+			QueryID:         c.queryID,
+			FrontendAddress: c.frontendAddress,
+		})
+	}
 
 	// Handle the stream sending & receiving on a goroutine so we can
 	// monitoring the contexts in a select and cancel things appropriately.
 	errCh := make(chan error, 1)
 	go func() {
-		err := querier.Send(&schedulerpb.SchedulerToQuerier{
-			UserID:          req.userID,
-			QueryID:         req.queryID,
-			FrontendAddress: req.frontendAddress,
-			HttpRequest:     req.request,
-			StatsEnabled:    req.statsEnabled,
-		})
+		err := querier.Send(msg)
 		if err != nil {
 			errCh <- err
 			return
@@ -438,11 +625,11 @@ func (s *Scheduler) forwardRequestToQuerier(querier schedulerpb.SchedulerForQuer
 	}()
 
 	select {
-	case <-req.ctx.Done():
+	case <-ctx.Done():
 		// If the upstream request is cancelled (eg. frontend issued CANCEL or closed connection),
 		// we need to cancel the downstream req. Only way we can do that is to close the stream (by returning error here).
 		// Querier is expecting this semantics.
-		return req.ctx.Err()
+		return ctx.Err()
 
 	case err := <-errCh:
 		// Is there was an error handling this request due to network IO,
@@ -450,11 +637,26 @@ func (s *Scheduler) forwardRequestToQuerier(querier schedulerpb.SchedulerForQuer
 
 		if err != nil {
 			s.forwardErrorToFrontend(req.ctx, req, err)
+			for _, c := range coalesced {
+				s.forwardErrorToFrontend(c.ctx, c, err)
+			}
 		}
 		return err
 	}
 }
 
+// coalescedContext returns a context which is canceled once all the input requests have been canceled.
+func coalescedContext(reqs []*schedulerRequest) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for _, r := range reqs {
+			<-r.ctx.Done()
+		}
+		cancel()
+	}()
+	return ctx
+}
+
 func (s *Scheduler) forwardErrorToFrontend(ctx context.Context, req *schedulerRequest, requestErr error) {
 	opts, err := s.cfg.GRPCClientConfig.DialOption([]grpc.UnaryClientInterceptor{
 		otgrpc.OpenTracingClientInterceptor(opentracing.GlobalTracer()),
@@ -531,6 +733,7 @@ func (s *Scheduler) cleanupMetricsForInactiveUser(user string) {
 	s.discardedRequests.DeletePartialMatch(prometheus.Labels{
 		"user": user,
 	})
+	s.coalescedRequests.DeleteLabelValues(user)
 }
 
 func (s *Scheduler) getConnectedFrontendClientsMetric() float64 {
@@ -2,9 +2,11 @@ package scheduler
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"sync"
 	"testing"
@@ -37,6 +39,10 @@ func setupScheduler(t *testing.T, reg prometheus.Registerer) (*Scheduler, schedu
 	flagext.DefaultValues(&cfg)
 	cfg.MaxOutstandingPerTenant = testMaxOutstandingPerTenant
 
+	return setupSchedulerWithConfig(t, reg, cfg)
+}
+
+func setupSchedulerWithConfig(t *testing.T, reg prometheus.Registerer, cfg Config) (*Scheduler, schedulerpb.SchedulerForFrontendClient, schedulerpb.SchedulerForQuerierClient) {
 	s, err := NewScheduler(cfg, frontendv1.MockLimits{Queriers: 2, MockLimits: queue.MockLimits{MaxOutstanding: testMaxOutstandingPerTenant}}, log.NewNopLogger(), reg)
 	require.NoError(t, err)
 
@@ -199,6 +205,145 @@ func TestSchedulerEnqueueWithFrontendDisconnect(t *testing.T) {
 	verifyNoPendingRequestsLeft(t, scheduler)
 }
 
+func TestSchedulerQueryCoalescing(t *testing.T) {
+	cfg := Config{}
+	flagext.DefaultValues(&cfg)
+	cfg.MaxOutstandingPerTenant = testMaxOutstandingPerTenant
+	cfg.QueryCoalescingEnabled = true
+
+	reg := prometheus.NewPedanticRegistry()
+	scheduler, frontendClient, querierClient := setupSchedulerWithConfig(t, reg, cfg)
+
+	frontendLoop1 := initFrontendLoop(t, frontendClient, "frontend-1")
+	frontendLoop2 := initFrontendLoop(t, frontendClient, "frontend-2")
+
+	query := &httpgrpc.HTTPRequest{Method: "GET", Url: "/api/v1/query_range?query=up&start=0&end=3600&step=60"}
+	frontendToScheduler(t, frontendLoop1, &schedulerpb.FrontendToScheduler{Type: schedulerpb.ENQUEUE, QueryID: 1, UserID: "test", HttpRequest: query})
+	frontendToScheduler(t, frontendLoop2, &schedulerpb.FrontendToScheduler{Type: schedulerpb.ENQUEUE, QueryID: 2, UserID: "test", HttpRequest: query})
+	frontendToScheduler(t, frontendLoop2, &schedulerpb.FrontendToScheduler{Type: schedulerpb.ENQUEUE, QueryID: 3, UserID: "another", HttpRequest: query})
+	frontendToScheduler(t, frontendLoop2, &schedulerpb.FrontendToScheduler{Type: schedulerpb.ENQUEUE, QueryID: 4, UserID: "test", HttpRequest: &httpgrpc.HTTPRequest{Method: "GET", Url: "/hello"}})
+
+	querierLoop := initQuerierLoop(t, querierClient, "querier-1")
+
+	received := map[uint64]*schedulerpb.SchedulerToQuerier{}
+	for i := 0; i < 3; i++ {
+		msg, err := querierLoop.Recv()
+		require.NoError(t, err)
+		received[msg.QueryID] = msg
+		require.NoError(t, querierLoop.Send(&schedulerpb.QuerierToScheduler{}))
+	}
+
+	// Identical queries from the same tenant are executed once.
+	require.Contains(t, received, uint64(1))
+	require.Equal(t, []schedulerpb.CoalescedRequest{{QueryID: 2, FrontendAddress: "frontend-2"}}, received[1].CoalescedRequests)
+
+	// Same query from a different tenant, or a different query, are not coalesced.
+	require.Contains(t, received, uint64(3))
+	require.Empty(t, received[3].CoalescedRequests)
+	require.Contains(t, received, uint64(4))
+	require.Empty(t, received[4].CoalescedRequests)
+
+	verifyQuerierDoesntReceiveRequest(t, querierLoop, 500*time.Millisecond)
+	verifyNoPendingRequestsLeft(t, scheduler)
+
+	require.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_query_scheduler_coalesced_requests_total Total number of query requests coalesced with an identical request already in the queue.
+		# TYPE cortex_query_scheduler_coalesced_requests_total counter
+		cortex_query_scheduler_coalesced_requests_total{user="test"} 1
+	`), "cortex_query_scheduler_coalesced_requests_total"))
+}
+
+func TestSchedulerQueryCoalescingWithCanceledRequest(t *testing.T) {
+	cfg := Config{}
+	flagext.DefaultValues(&cfg)
+	cfg.MaxOutstandingPerTenant = testMaxOutstandingPerTenant
+	cfg.QueryCoalescingEnabled = true
+
+	scheduler, frontendClient, querierClient := setupSchedulerWithConfig(t, nil, cfg)
+
+	frontendLoop1 := initFrontendLoop(t, frontendClient, "frontend-1")
+	frontendLoop2 := initFrontendLoop(t, frontendClient, "frontend-2")
+
+	query := &httpgrpc.HTTPRequest{Method: "GET", Url: "/hello"}
+	frontendToScheduler(t, frontendLoop1, &schedulerpb.FrontendToScheduler{Type: schedulerpb.ENQUEUE, QueryID: 1, UserID: "test", HttpRequest: query})
+	frontendToScheduler(t, frontendLoop2, &schedulerpb.FrontendToScheduler{Type: schedulerpb.ENQUEUE, QueryID: 2, UserID: "test", HttpRequest: query})
+
+	// Cancel the query which was enqueued first. The coalesced one should still be executed.
+	frontendToScheduler(t, frontendLoop1, &schedulerpb.FrontendToScheduler{Type: schedulerpb.CANCEL, QueryID: 1})
+
+	querierLoop := initQuerierLoop(t, querierClient, "querier-1")
+
+	msg, err := querierLoop.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), msg.QueryID)
+	require.Equal(t, "frontend-2", msg.FrontendAddress)
+	require.Empty(t, msg.CoalescedRequests)
+	require.NoError(t, querierLoop.Send(&schedulerpb.QuerierToScheduler{}))
+
+	verifyQuerierDoesntReceiveRequest(t, querierLoop, 500*time.Millisecond)
+	verifyNoPendingRequestsLeft(t, scheduler)
+}
+
+func TestSchedulerDrainQuerier(t *testing.T) {
+	scheduler, frontendClient, querierClient := setupScheduler(t, nil)
+
+	frontendLoop := initFrontendLoop(t, frontendClient, "frontend-12345")
+	frontendToScheduler(t, frontendLoop, &schedulerpb.FrontendToScheduler{
+		Type:        schedulerpb.ENQUEUE,
+		QueryID:     1,
+		UserID:      "test",
+		HttpRequest: &httpgrpc.HTTPRequest{Method: "GET", Url: "/hello"},
+	})
+
+	querierLoop := initQuerierLoop(t, querierClient, "querier-1")
+	msg, err := querierLoop.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), msg.QueryID)
+
+	// Draining the querier while it's still processing the request.
+	status := drainQuerier(t, scheduler, http.MethodPost, "querier-1", http.StatusOK)
+	require.Equal(t, QuerierDrainStatus{QuerierID: "querier-1", Registered: true, Draining: true, InflightRequests: 1}, status)
+
+	frontendToScheduler(t, frontendLoop, &schedulerpb.FrontendToScheduler{
+		Type:        schedulerpb.ENQUEUE,
+		QueryID:     2,
+		UserID:      "test",
+		HttpRequest: &httpgrpc.HTTPRequest{Method: "GET", Url: "/hello"},
+	})
+
+	// Once the in-flight request is done, the draining querier is safe to terminate and gets no new request.
+	require.NoError(t, querierLoop.Send(&schedulerpb.QuerierToScheduler{}))
+	test.Poll(t, time.Second, true, func() interface{} {
+		return drainQuerier(t, scheduler, http.MethodGet, "querier-1", http.StatusOK).SafeToTerminate
+	})
+	verifyQuerierDoesntReceiveRequest(t, querierLoop, 500*time.Millisecond)
+
+	// The pending request is handled by another querier.
+	otherQuerierLoop := initQuerierLoop(t, querierClient, "querier-2")
+	msg, err = otherQuerierLoop.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), msg.QueryID)
+	require.NoError(t, otherQuerierLoop.Send(&schedulerpb.QuerierToScheduler{}))
+
+	// Unknown queriers can't be drained, but are safe to terminate.
+	drainQuerier(t, scheduler, http.MethodPost, "querier-3", http.StatusNotFound)
+	require.Equal(t, QuerierDrainStatus{QuerierID: "querier-3", SafeToTerminate: true}, drainQuerier(t, scheduler, http.MethodGet, "querier-3", http.StatusOK))
+	drainQuerier(t, scheduler, http.MethodGet, "", http.StatusBadRequest)
+}
+
+func drainQuerier(t *testing.T, scheduler *Scheduler, method, querierID string, expectedStatusCode int) QuerierDrainStatus {
+	req := httptest.NewRequest(method, "/scheduler/drain_querier?querier_id="+querierID, nil)
+	rec := httptest.NewRecorder()
+	scheduler.DrainQuerierHandler(rec, req)
+	require.Equal(t, expectedStatusCode, rec.Code)
+
+	status := QuerierDrainStatus{}
+	if rec.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	}
+	return status
+}
+
 func TestCancelRequestInProgress(t *testing.T) {
 	scheduler, frontendClient, querierClient := setupScheduler(t, nil)
 
@@ -139,6 +139,9 @@ type SchedulerToQuerier struct {
 	// Whether query statistics tracking should be enabled. The response will include
 	// statistics only when this option is enabled.
 	StatsEnabled bool `protobuf:"varint,5,opt,name=statsEnabled,proto3" json:"statsEnabled,omitempty"`
+	// Identical queries from other frontends which have been coalesced with this one by
+	// the query-scheduler. Querier sends the same HTTP response to each of them too.
+	CoalescedRequests []CoalescedRequest `protobuf:"bytes,6,rep,name=coalescedRequests,proto3" json:"coalescedRequests"`
 }
 
 func (m *SchedulerToQuerier) Reset()      { *m = SchedulerToQuerier{} }
@@ -208,6 +211,66 @@ func (m *SchedulerToQuerier) GetStatsEnabled() bool {
 	return false
 }
 
+func (m *SchedulerToQuerier) GetCoalescedRequests() []CoalescedRequest {
+	if m != nil {
+		return m.CoalescedRequests
+	}
+	return nil
+}
+
+type CoalescedRequest struct {
+	// Query ID as reported by frontend.
+	QueryID uint64 `protobuf:"varint,1,opt,name=queryID,proto3" json:"queryID,omitempty"`
+	// Where should querier send HTTP Response to (using FrontendForQuerier interface).
+	FrontendAddress string `protobuf:"bytes,2,opt,name=frontendAddress,proto3" json:"frontendAddress,omitempty"`
+}
+
+func (m *CoalescedRequest) Reset()      { *m = CoalescedRequest{} }
+func (*CoalescedRequest) ProtoMessage() {}
+func (*CoalescedRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2b3fc28395a6d9c5, []int{2}
+}
+func (m *CoalescedRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *CoalescedRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_CoalescedRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *CoalescedRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CoalescedRequest.Merge(m, src)
+}
+func (m *CoalescedRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *CoalescedRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CoalescedRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CoalescedRequest proto.InternalMessageInfo
+
+func (m *CoalescedRequest) GetQueryID() uint64 {
+	if m != nil {
+		return m.QueryID
+	}
+	return 0
+}
+
+func (m *CoalescedRequest) GetFrontendAddress() string {
+	if m != nil {
+		return m.FrontendAddress
+	}
+	return ""
+}
+
 type FrontendToScheduler struct {
 	Type FrontendToSchedulerType `protobuf:"varint,1,opt,name=type,proto3,enum=schedulerpb.FrontendToSchedulerType" json:"type,omitempty"`
 	// Used by INIT message. Will be put into all requests passed to querier.
@@ -224,7 +287,7 @@ type FrontendToScheduler struct {
 func (m *FrontendToScheduler) Reset()      { *m = FrontendToScheduler{} }
 func (*FrontendToScheduler) ProtoMessage() {}
 func (*FrontendToScheduler) Descriptor() ([]byte, []int) {
-	return fileDescriptor_2b3fc28395a6d9c5, []int{2}
+	return fileDescriptor_2b3fc28395a6d9c5, []int{3}
 }
 func (m *FrontendToScheduler) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -303,7 +366,7 @@ type SchedulerToFrontend struct {
 func (m *SchedulerToFrontend) Reset()      { *m = SchedulerToFrontend{} }
 func (*SchedulerToFrontend) ProtoMessage() {}
 func (*SchedulerToFrontend) Descriptor() ([]byte, []int) {
-	return fileDescriptor_2b3fc28395a6d9c5, []int{3}
+	return fileDescriptor_2b3fc28395a6d9c5, []int{4}
 }
 func (m *SchedulerToFrontend) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -353,7 +416,7 @@ type NotifyQuerierShutdownRequest struct {
 func (m *NotifyQuerierShutdownRequest) Reset()      { *m = NotifyQuerierShutdownRequest{} }
 func (*NotifyQuerierShutdownRequest) ProtoMessage() {}
 func (*NotifyQuerierShutdownRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_2b3fc28395a6d9c5, []int{4}
+	return fileDescriptor_2b3fc28395a6d9c5, []int{5}
 }
 func (m *NotifyQuerierShutdownRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -395,7 +458,7 @@ type NotifyQuerierShutdownResponse struct {
 func (m *NotifyQuerierShutdownResponse) Reset()      { *m = NotifyQuerierShutdownResponse{} }
 func (*NotifyQuerierShutdownResponse) ProtoMessage() {}
 func (*NotifyQuerierShutdownResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_2b3fc28395a6d9c5, []int{5}
+	return fileDescriptor_2b3fc28395a6d9c5, []int{6}
 }
 func (m *NotifyQuerierShutdownResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -429,6 +492,7 @@ func init() {
 	proto.RegisterEnum("schedulerpb.SchedulerToFrontendStatus", SchedulerToFrontendStatus_name, SchedulerToFrontendStatus_value)
 	proto.RegisterType((*QuerierToScheduler)(nil), "schedulerpb.QuerierToScheduler")
 	proto.RegisterType((*SchedulerToQuerier)(nil), "schedulerpb.SchedulerToQuerier")
+	proto.RegisterType((*CoalescedRequest)(nil), "schedulerpb.CoalescedRequest")
 	proto.RegisterType((*FrontendToScheduler)(nil), "schedulerpb.FrontendToScheduler")
 	proto.RegisterType((*SchedulerToFrontend)(nil), "schedulerpb.SchedulerToFrontend")
 	proto.RegisterType((*NotifyQuerierShutdownRequest)(nil), "schedulerpb.NotifyQuerierShutdownRequest")
@@ -438,48 +502,50 @@ func init() {
 func init() { proto.RegisterFile("scheduler.proto", fileDescriptor_2b3fc28395a6d9c5) }
 
 var fileDescriptor_2b3fc28395a6d9c5 = []byte{
-	// 644 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0x4f, 0x4f, 0xdb, 0x4e,
-	0x10, 0xf5, 0x86, 0x24, 0xc0, 0x84, 0xdf, 0x0f, 0x77, 0x81, 0x36, 0x8d, 0xe8, 0x12, 0x45, 0x55,
-	0x95, 0x72, 0x48, 0xaa, 0xb4, 0x52, 0x7b, 0x40, 0x95, 0x52, 0x30, 0x25, 0x2a, 0x75, 0x60, 0xb3,
-	0x51, 0xff, 0x5c, 0x22, 0x92, 0x2c, 0x09, 0x02, 0xbc, 0x66, 0x6d, 0x17, 0xe5, 0xd6, 0x63, 0x8f,
-	0xfd, 0x18, 0xfd, 0x28, 0xbd, 0x54, 0xe2, 0xc8, 0xa1, 0x87, 0x62, 0x2e, 0x3d, 0xf2, 0x11, 0xaa,
-	0x38, 0x76, 0xea, 0xa4, 0x0e, 0x70, 0x9b, 0x1d, 0xbf, 0xe7, 0x9d, 0xf7, 0x66, 0x66, 0x61, 0xde,
-	0x6a, 0x75, 0x79, 0xdb, 0x39, 0xe2, 0xb2, 0x60, 0x4a, 0x61, 0x0b, 0x9c, 0x1a, 0x26, 0xcc, 0x66,
-	0x66, 0xb1, 0x23, 0x3a, 0xc2, 0xcb, 0x17, 0xfb, 0xd1, 0x00, 0x92, 0x79, 0xd6, 0x39, 0xb0, 0xbb,
-	0x4e, 0xb3, 0xd0, 0x12, 0xc7, 0xc5, 0x53, 0xbe, 0xf7, 0x89, 0x9f, 0x0a, 0x79, 0x68, 0x15, 0x5b,
-	0xe2, 0xf8, 0x58, 0x18, 0xc5, 0xae, 0x6d, 0x9b, 0x1d, 0x69, 0xb6, 0x86, 0xc1, 0x80, 0x95, 0x2b,
-	0x01, 0xde, 0x75, 0xb8, 0x3c, 0xe0, 0x92, 0x89, 0x5a, 0x70, 0x07, 0x5e, 0x86, 0xd9, 0x93, 0x41,
-	0xb6, 0xb2, 0x91, 0x46, 0x59, 0x94, 0x9f, 0xa5, 0x7f, 0x13, 0xb9, 0x1f, 0x08, 0xf0, 0x10, 0xcb,
-	0x84, 0xcf, 0xc7, 0x69, 0x98, 0xee, 0x63, 0x7a, 0x3e, 0x25, 0x4e, 0x83, 0x23, 0x7e, 0x0e, 0xa9,
-	0xfe, 0xb5, 0x94, 0x9f, 0x38, 0xdc, 0xb2, 0xd3, 0xb1, 0x2c, 0xca, 0xa7, 0x4a, 0x4b, 0x85, 0x61,
-	0x29, 0x5b, 0x8c, 0xed, 0xf8, 0x1f, 0x69, 0x18, 0x89, 0xf3, 0x30, 0xbf, 0x2f, 0x85, 0x61, 0x73,
-	0xa3, 0x5d, 0x6e, 0xb7, 0x25, 0xb7, 0xac, 0xf4, 0x94, 0x57, 0xcd, 0x78, 0x1a, 0xdf, 0x85, 0xa4,
-	0x63, 0x79, 0xe5, 0xc6, 0x3d, 0x80, 0x7f, 0xc2, 0x39, 0x98, 0xb3, 0xec, 0x3d, 0xdb, 0xd2, 0x8c,
-	0xbd, 0xe6, 0x11, 0x6f, 0xa7, 0x13, 0x59, 0x94, 0x9f, 0xa1, 0x23, 0xb9, 0xdc, 0x97, 0x18, 0x2c,
-	0x6c, 0xfa, 0xff, 0x0b, 0xbb, 0xf0, 0x02, 0xe2, 0x76, 0xcf, 0xe4, 0x9e, 0x9a, 0xff, 0x4b, 0x0f,
-	0x0b, 0xa1, 0x1e, 0x14, 0x22, 0xf0, 0xac, 0x67, 0x72, 0xea, 0x31, 0xa2, 0xea, 0x8e, 0x45, 0xd7,
-	0x1d, 0x32, 0x6d, 0x6a, 0xd4, 0xb4, 0x49, 0x8a, 0xc6, 0xcc, 0x4c, 0xdc, 0xda, 0xcc, 0x71, 0x2b,
-	0x92, 0x11, 0x56, 0x1c, 0xc2, 0x42, 0xa8, 0xb3, 0x81, 0x48, 0xfc, 0x12, 0x92, 0x7d, 0x98, 0x63,
-	0xf9, 0x5e, 0x3c, 0x1a, 0xf1, 0x22, 0x82, 0x51, 0xf3, 0xd0, 0xd4, 0x67, 0xe1, 0x45, 0x48, 0x70,
-	0x29, 0x85, 0xf4, 0x5d, 0x18, 0x1c, 0x72, 0x6b, 0xb0, 0xac, 0x0b, 0xfb, 0x60, 0xbf, 0xe7, 0x4f,
-	0x50, 0xad, 0xeb, 0xd8, 0x6d, 0x71, 0x6a, 0x04, 0x05, 0x5f, 0x3f, 0x85, 0x2b, 0xf0, 0x60, 0x02,
-	0xdb, 0x32, 0x85, 0x61, 0xf1, 0xd5, 0x35, 0xb8, 0x37, 0xa1, 0x4b, 0x78, 0x06, 0xe2, 0x15, 0xbd,
-	0xc2, 0x54, 0x05, 0xa7, 0x60, 0x5a, 0xd3, 0x77, 0xeb, 0x5a, 0x5d, 0x53, 0x11, 0x06, 0x48, 0xae,
-	0x97, 0xf5, 0x75, 0x6d, 0x5b, 0x8d, 0xad, 0xb6, 0xe0, 0xfe, 0x44, 0x5d, 0x38, 0x09, 0xb1, 0xea,
-	0x1b, 0x55, 0xc1, 0x59, 0x58, 0x66, 0xd5, 0x6a, 0xe3, 0x6d, 0x59, 0xff, 0xd0, 0xa0, 0xda, 0x6e,
-	0x5d, 0xab, 0xb1, 0x5a, 0x63, 0x47, 0xa3, 0x0d, 0xa6, 0xe9, 0x65, 0x9d, 0xa9, 0x08, 0xcf, 0x42,
-	0x42, 0xa3, 0xb4, 0x4a, 0xd5, 0x18, 0xbe, 0x03, 0xff, 0xd5, 0xb6, 0xea, 0x8c, 0x55, 0xf4, 0xd7,
-	0x8d, 0x8d, 0xea, 0x3b, 0x5d, 0x9d, 0x2a, 0xfd, 0x44, 0x21, 0xbf, 0x37, 0x85, 0x0c, 0x56, 0xa9,
-	0x0e, 0x29, 0x3f, 0xdc, 0x16, 0xc2, 0xc4, 0x2b, 0x23, 0x76, 0xff, 0xbb, 0xaf, 0x99, 0x95, 0x49,
-	0xfd, 0xf0, 0xb1, 0x39, 0x25, 0x8f, 0x9e, 0x20, 0x6c, 0xc0, 0x52, 0xa4, 0x65, 0xf8, 0xf1, 0x08,
-	0xff, 0xba, 0xa6, 0x64, 0x56, 0x6f, 0x03, 0x1d, 0x74, 0xa0, 0x64, 0xc2, 0x62, 0x58, 0xdd, 0x70,
-	0x9c, 0xde, 0xc3, 0x5c, 0x10, 0x7b, 0xfa, 0xb2, 0x37, 0xad, 0x56, 0x26, 0x7b, 0xd3, 0xc0, 0x0d,
-	0x14, 0xbe, 0x2a, 0x9f, 0x5d, 0x10, 0xe5, 0xfc, 0x82, 0x28, 0x57, 0x17, 0x04, 0x7d, 0x76, 0x09,
-	0xfa, 0xe6, 0x12, 0xf4, 0xdd, 0x25, 0xe8, 0xcc, 0x25, 0xe8, 0x97, 0x4b, 0xd0, 0x6f, 0x97, 0x28,
-	0x57, 0x2e, 0x41, 0x5f, 0x2f, 0x89, 0x72, 0x76, 0x49, 0x94, 0xf3, 0x4b, 0xa2, 0x7c, 0x0c, 0xbf,
-	0xae, 0xcd, 0xa4, 0xf7, 0x30, 0x3e, 0xfd, 0x13, 0x00, 0x00, 0xff, 0xff, 0x88, 0x0c, 0xfe, 0x56,
-	0x84, 0x05, 0x00, 0x00,
+	// 685 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x55, 0x4d, 0x53, 0xda, 0x50,
+	0x14, 0xcd, 0x0b, 0x10, 0xf5, 0x62, 0x6b, 0x7c, 0x6a, 0x4b, 0x19, 0x0d, 0x99, 0x4c, 0xa7, 0x43,
+	0x5d, 0x40, 0x87, 0x76, 0xa6, 0x5d, 0x38, 0x9d, 0x41, 0x8d, 0x95, 0xa9, 0x0d, 0x12, 0x42, 0xbf,
+	0x36, 0x0c, 0x24, 0x4f, 0x70, 0xd4, 0xbc, 0x98, 0x8f, 0x3a, 0xec, 0xba, 0xec, 0xb2, 0x3f, 0xa1,
+	0xcb, 0xfe, 0x14, 0x97, 0x2e, 0x5d, 0x74, 0x3a, 0x35, 0x6e, 0xba, 0xf4, 0x27, 0x74, 0x08, 0x81,
+	0x06, 0x04, 0x75, 0x77, 0xdf, 0xe5, 0x1c, 0xee, 0xbd, 0xe7, 0xdc, 0xf7, 0x02, 0x73, 0x8e, 0xde,
+	0x26, 0x86, 0x77, 0x48, 0xec, 0x9c, 0x65, 0x53, 0x97, 0xe2, 0xe4, 0x20, 0x61, 0x35, 0xd3, 0x8b,
+	0x2d, 0xda, 0xa2, 0x41, 0x3e, 0xdf, 0x8d, 0x7a, 0x90, 0xf4, 0x8b, 0xd6, 0xbe, 0xdb, 0xf6, 0x9a,
+	0x39, 0x9d, 0x1e, 0xe5, 0x4f, 0x48, 0xe3, 0x0b, 0x39, 0xa1, 0xf6, 0x81, 0x93, 0xd7, 0xe9, 0xd1,
+	0x11, 0x35, 0xf3, 0x6d, 0xd7, 0xb5, 0x5a, 0xb6, 0xa5, 0x0f, 0x82, 0x1e, 0x4b, 0x2a, 0x00, 0xae,
+	0x78, 0xc4, 0xde, 0x27, 0xb6, 0x46, 0xab, 0xfd, 0x1a, 0x78, 0x19, 0x66, 0x8e, 0x7b, 0xd9, 0xd2,
+	0x66, 0x0a, 0x89, 0x28, 0x3b, 0xa3, 0xfe, 0x4f, 0x48, 0x3f, 0x58, 0xc0, 0x03, 0xac, 0x46, 0x43,
+	0x3e, 0x4e, 0xc1, 0x54, 0x17, 0xd3, 0x09, 0x29, 0x71, 0xb5, 0x7f, 0xc4, 0x2f, 0x21, 0xd9, 0x2d,
+	0xab, 0x92, 0x63, 0x8f, 0x38, 0x6e, 0x8a, 0x15, 0x51, 0x36, 0x59, 0x58, 0xca, 0x0d, 0x5a, 0xd9,
+	0xd6, 0xb4, 0xdd, 0xf0, 0x47, 0x35, 0x8a, 0xc4, 0x59, 0x98, 0xdb, 0xb3, 0xa9, 0xe9, 0x12, 0xd3,
+	0x28, 0x1a, 0x86, 0x4d, 0x1c, 0x27, 0x15, 0x0b, 0xba, 0x19, 0x4d, 0xe3, 0x07, 0xc0, 0x79, 0x4e,
+	0xd0, 0x6e, 0x3c, 0x00, 0x84, 0x27, 0x2c, 0xc1, 0xac, 0xe3, 0x36, 0x5c, 0x47, 0x36, 0x1b, 0xcd,
+	0x43, 0x62, 0xa4, 0x12, 0x22, 0xca, 0x4e, 0xab, 0x43, 0x39, 0x5c, 0x81, 0x79, 0x9d, 0x36, 0x0e,
+	0x89, 0xa3, 0x13, 0x23, 0xac, 0xec, 0xa4, 0x38, 0x31, 0x96, 0x4d, 0x16, 0x56, 0x72, 0x11, 0xe1,
+	0x73, 0x1b, 0x23, 0xa8, 0xf5, 0xf8, 0xe9, 0xef, 0x0c, 0xa3, 0x5e, 0x67, 0x4b, 0xef, 0x81, 0x1f,
+	0x05, 0xdf, 0xa0, 0xcf, 0x98, 0x31, 0xd9, 0xb1, 0x63, 0x4a, 0xdf, 0x58, 0x58, 0xd8, 0x0a, 0x73,
+	0x51, 0xc3, 0x5e, 0x41, 0xdc, 0xed, 0x58, 0x24, 0xf8, 0xe3, 0xfb, 0x85, 0xc7, 0x43, 0x5d, 0x8f,
+	0xc1, 0x6b, 0x1d, 0x8b, 0xa8, 0x01, 0xe3, 0xee, 0xb5, 0xa3, 0xfd, 0xc7, 0x86, 0xfb, 0x9f, 0x24,
+	0xfe, 0x88, 0xef, 0x89, 0x3b, 0xfb, 0x3e, 0xea, 0x1a, 0x77, 0xdd, 0x35, 0xe9, 0x00, 0x16, 0x22,
+	0x4b, 0xd8, 0x1f, 0x12, 0xbf, 0x06, 0xae, 0x0b, 0xf3, 0x9c, 0x50, 0x8b, 0x27, 0x43, 0x5a, 0x8c,
+	0x61, 0x54, 0x03, 0xb4, 0x1a, 0xb2, 0xf0, 0x22, 0x24, 0x88, 0x6d, 0x53, 0x3b, 0x54, 0xa1, 0x77,
+	0x90, 0xd6, 0x60, 0x59, 0xa1, 0xee, 0xfe, 0x5e, 0x27, 0x5c, 0xf6, 0x6a, 0xdb, 0x73, 0x0d, 0x7a,
+	0x62, 0xf6, 0x1b, 0xbe, 0xf9, 0xc2, 0x64, 0x60, 0x65, 0x02, 0xdb, 0xb1, 0xa8, 0xe9, 0x90, 0xd5,
+	0x35, 0x78, 0x38, 0xc1, 0x25, 0x3c, 0x0d, 0xf1, 0x92, 0x52, 0xd2, 0x78, 0x06, 0x27, 0x61, 0x4a,
+	0x56, 0x2a, 0x35, 0xb9, 0x26, 0xf3, 0x08, 0x03, 0x70, 0x1b, 0x45, 0x65, 0x43, 0xde, 0xe1, 0xd9,
+	0x55, 0x1d, 0x1e, 0x4d, 0x9c, 0x0b, 0x73, 0xc0, 0x96, 0xdf, 0xf2, 0x0c, 0x16, 0x61, 0x59, 0x2b,
+	0x97, 0xeb, 0xef, 0x8a, 0xca, 0xa7, 0xba, 0x2a, 0x57, 0x6a, 0x72, 0x55, 0xab, 0xd6, 0x77, 0x65,
+	0xb5, 0xae, 0xc9, 0x4a, 0x51, 0xd1, 0x78, 0x84, 0x67, 0x20, 0x21, 0xab, 0x6a, 0x59, 0xe5, 0x59,
+	0x3c, 0x0f, 0xf7, 0xaa, 0xdb, 0x35, 0x4d, 0x2b, 0x29, 0x6f, 0xea, 0x9b, 0xe5, 0x0f, 0x0a, 0x1f,
+	0x2b, 0xfc, 0x42, 0x11, 0xbd, 0xb7, 0xa8, 0xdd, 0xbf, 0xf5, 0x35, 0x48, 0x86, 0xe1, 0x0e, 0xa5,
+	0x16, 0xce, 0x0c, 0xc9, 0x7d, 0xfd, 0x69, 0x49, 0x67, 0x26, 0xf9, 0x11, 0x62, 0x25, 0x26, 0x8b,
+	0x9e, 0x21, 0x6c, 0xc2, 0xd2, 0x58, 0xc9, 0xf0, 0xd3, 0x21, 0xfe, 0x4d, 0xa6, 0xa4, 0x57, 0xef,
+	0x02, 0xed, 0x39, 0x50, 0xb0, 0x60, 0x31, 0x3a, 0xdd, 0x60, 0x9d, 0x3e, 0xc2, 0x6c, 0x3f, 0x0e,
+	0xe6, 0x13, 0x6f, 0xbb, 0x5a, 0x69, 0xf1, 0xb6, 0x85, 0xeb, 0x4d, 0xb8, 0x5e, 0x3c, 0xbb, 0x10,
+	0x98, 0xf3, 0x0b, 0x81, 0xb9, 0xba, 0x10, 0xd0, 0x57, 0x5f, 0x40, 0x3f, 0x7d, 0x01, 0x9d, 0xfa,
+	0x02, 0x3a, 0xf3, 0x05, 0xf4, 0xc7, 0x17, 0xd0, 0x5f, 0x5f, 0x60, 0xae, 0x7c, 0x01, 0x7d, 0xbf,
+	0x14, 0x98, 0xb3, 0x4b, 0x81, 0x39, 0xbf, 0x14, 0x98, 0xcf, 0xd1, 0x0f, 0x41, 0x93, 0x0b, 0xde,
+	0xf0, 0xe7, 0xff, 0x06, 0x00, 0x93, 0xd4, 0xa5, 0x78, 0x2f, 0x06, 0x00, 0x00,
 }
 
 func (x FrontendToSchedulerType) String() string {
@@ -554,6 +620,41 @@ func (this *SchedulerToQuerier) Equal(that interface{}) bool {
 	if this.StatsEnabled != that1.StatsEnabled {
 		return false
 	}
+	if len(this.CoalescedRequests) != len(that1.CoalescedRequests) {
+		return false
+	}
+	for i := range this.CoalescedRequests {
+		if !this.CoalescedRequests[i].Equal(&that1.CoalescedRequests[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *CoalescedRequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*CoalescedRequest)
+	if !ok {
+		that2, ok := that.(CoalescedRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.QueryID != that1.QueryID {
+		return false
+	}
+	if this.FrontendAddress != that1.FrontendAddress {
+		return false
+	}
 	return true
 }
 func (this *FrontendToScheduler) Equal(that interface{}) bool {
@@ -681,7 +782,7 @@ func (this *SchedulerToQuerier) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 9)
+	s := make([]string, 0, 10)
 	s = append(s, "&schedulerpb.SchedulerToQuerier{")
 	s = append(s, "QueryID: "+fmt.Sprintf("%#v", this.QueryID)+",\n")
 	if this.HttpRequest != nil {
@@ -690,6 +791,24 @@ func (this *SchedulerToQuerier) GoString() string {
 	s = append(s, "FrontendAddress: "+fmt.Sprintf("%#v", this.FrontendAddress)+",\n")
 	s = append(s, "UserID: "+fmt.Sprintf("%#v", this.UserID)+",\n")
 	s = append(s, "StatsEnabled: "+fmt.Sprintf("%#v", this.StatsEnabled)+",\n")
+	if this.CoalescedRequests != nil {
+		vs := make([]*CoalescedRequest, len(this.CoalescedRequests))
+		for i := range vs {
+			vs[i] = &this.CoalescedRequests[i]
+		}
+		s = append(s, "CoalescedRequests: "+fmt.Sprintf("%#v", vs)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *CoalescedRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&schedulerpb.CoalescedRequest{")
+	s = append(s, "QueryID: "+fmt.Sprintf("%#v", this.QueryID)+",\n")
+	s = append(s, "FrontendAddress: "+fmt.Sprintf("%#v", this.FrontendAddress)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -1078,6 +1197,20 @@ func (m *SchedulerToQuerier) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.CoalescedRequests) > 0 {
+		for iNdEx := len(m.CoalescedRequests) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.CoalescedRequests[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintScheduler(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x32
+		}
+	}
 	if m.StatsEnabled {
 		i--
 		if m.StatsEnabled {
@@ -1122,6 +1255,41 @@ func (m *SchedulerToQuerier) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *CoalescedRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *CoalescedRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *CoalescedRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.FrontendAddress) > 0 {
+		i -= len(m.FrontendAddress)
+		copy(dAtA[i:], m.FrontendAddress)
+		i = encodeVarintScheduler(dAtA, i, uint64(len(m.FrontendAddress)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.QueryID != 0 {
+		i = encodeVarintScheduler(dAtA, i, uint64(m.QueryID))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
 func (m *FrontendToScheduler) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
@@ -1327,6 +1495,28 @@ func (m *SchedulerToQuerier) Size() (n int) {
 	if m.StatsEnabled {
 		n += 2
 	}
+	if len(m.CoalescedRequests) > 0 {
+		for _, e := range m.CoalescedRequests {
+			l = e.Size()
+			n += 1 + l + sovScheduler(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *CoalescedRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.QueryID != 0 {
+		n += 1 + sovScheduler(uint64(m.QueryID))
+	}
+	l = len(m.FrontendAddress)
+	if l > 0 {
+		n += 1 + l + sovScheduler(uint64(l))
+	}
 	return n
 }
 
@@ -1418,12 +1608,29 @@ func (this *SchedulerToQuerier) String() string {
 	if this == nil {
 		return "nil"
 	}
+	repeatedStringForCoalescedRequests := "[]CoalescedRequest{"
+	for _, f := range this.CoalescedRequests {
+		repeatedStringForCoalescedRequests += strings.Replace(strings.Replace(f.String(), "CoalescedRequest", "CoalescedRequest", 1), `&`, ``, 1) + ","
+	}
+	repeatedStringForCoalescedRequests += "}"
 	s := strings.Join([]string{`&SchedulerToQuerier{`,
 		`QueryID:` + fmt.Sprintf("%v", this.QueryID) + `,`,
 		`HttpRequest:` + strings.Replace(fmt.Sprintf("%v", this.HttpRequest), "HTTPRequest", "httpgrpc.HTTPRequest", 1) + `,`,
 		`FrontendAddress:` + fmt.Sprintf("%v", this.FrontendAddress) + `,`,
 		`UserID:` + fmt.Sprintf("%v", this.UserID) + `,`,
 		`StatsEnabled:` + fmt.Sprintf("%v", this.StatsEnabled) + `,`,
+		`CoalescedRequests:` + repeatedStringForCoalescedRequests + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *CoalescedRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&CoalescedRequest{`,
+		`QueryID:` + fmt.Sprintf("%v", this.QueryID) + `,`,
+		`FrontendAddress:` + fmt.Sprintf("%v", this.FrontendAddress) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -1734,6 +1941,144 @@ func (m *SchedulerToQuerier) Unmarshal(dAtA []byte) error {
 				}
 			}
 			m.StatsEnabled = bool(v != 0)
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CoalescedRequests", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.CoalescedRequests = append(m.CoalescedRequests, CoalescedRequest{})
+			if err := m.CoalescedRequests[len(m.CoalescedRequests)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipScheduler(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *CoalescedRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowScheduler
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: CoalescedRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: CoalescedRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QueryID", wireType)
+			}
+			m.QueryID = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.QueryID |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FrontendAddress", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.FrontendAddress = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipScheduler(dAtA[iNdEx:])
@@ -0,0 +1,227 @@
+package bucket
+
+import (
+	"context"
+	"flag"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/objstore"
+)
+
+// The classes of the bucket operations, grouping the operations billed alike by the storage providers.
+const (
+	operationClassRead     = "read"
+	operationClassMetadata = "metadata"
+	operationClassList     = "list"
+	operationClassWrite    = "write"
+	operationClassDelete   = "delete"
+)
+
+// AuditLogConfig configures the audit log of the bucket operations.
+type AuditLogConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// RegisterFlagsWithPrefix registers the flags for the audit log with the provided prefix.
+func (cfg *AuditLogConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+"audit-log.enabled", false, "[Experimental] If enabled, every operation on the objects is logged with its tenant, component, operation class and number of bytes transferred.")
+}
+
+type tenantContextKey struct{}
+
+// contextWithTenant returns a context carrying the tenant on behalf of which the bucket operations are made.
+func contextWithTenant(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, userID)
+}
+
+// tenantFromContext returns the tenant on behalf of which the bucket operation is made, or an empty string if the
+// operation isn't made on behalf of a tenant.
+func tenantFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(tenantContextKey{}).(string)
+	return userID
+}
+
+// auditBucketClient is a bucket client tracking the operations, and the bytes transferred, per tenant and operation
+// class, to attribute the cost of the storage requests and egress. It wraps the backend client, so that each
+// request sent to the storage is tracked, including the hedged and retried requests.
+type auditBucketClient struct {
+	objstore.Bucket
+
+	component string
+
+	// The logger of the audit log, nil if the audit log is disabled.
+	logger log.Logger
+
+	operations *prometheus.CounterVec
+	bytes      *prometheus.CounterVec
+}
+
+func newAuditBucketClient(bkt objstore.Bucket, cfg AuditLogConfig, component string, logger log.Logger, reg prometheus.Registerer) *auditBucketClient {
+	b := &auditBucketClient{
+		Bucket:    bkt,
+		component: component,
+		operations: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_bucket_tenant_operations_total",
+			Help: "Total number of operations on the objects, per tenant and operation class.",
+		}, []string{"user", "class"}),
+		bytes: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_bucket_tenant_transferred_bytes_total",
+			Help: "Total number of bytes read from and written to the objects, per tenant and operation class.",
+		}, []string{"user", "class"}),
+	}
+	if cfg.Enabled {
+		b.logger = logger
+	}
+	return b
+}
+
+// Upload implements objstore.Bucket.
+func (b *auditBucketClient) Upload(ctx context.Context, name string, r io.Reader) error {
+	start := time.Now()
+
+	// The reader is passed as is when its size is known, because the backends upload the readers differently
+	// depending on their type.
+	size, sizeErr := objstore.TryToGetSize(r)
+	if sizeErr == nil {
+		err := b.Bucket.Upload(ctx, name, r)
+		b.track(ctx, objstore.OpUpload, operationClassWrite, name, size, start, err)
+		return err
+	}
+
+	cr := &countingReader{r: r}
+	err := b.Bucket.Upload(ctx, name, cr)
+	b.track(ctx, objstore.OpUpload, operationClassWrite, name, cr.n, start, err)
+	return err
+}
+
+// Delete implements objstore.Bucket.
+func (b *auditBucketClient) Delete(ctx context.Context, name string) error {
+	start := time.Now()
+	err := b.Bucket.Delete(ctx, name)
+	b.track(ctx, objstore.OpDelete, operationClassDelete, name, 0, start, err)
+	return err
+}
+
+// Iter implements objstore.Bucket.
+func (b *auditBucketClient) Iter(ctx context.Context, dir string, f func(string) error, options ...objstore.IterOption) error {
+	start := time.Now()
+	err := b.Bucket.Iter(ctx, dir, f, options...)
+	b.track(ctx, objstore.OpIter, operationClassList, dir, 0, start, err)
+	return err
+}
+
+// Get implements objstore.Bucket.
+func (b *auditBucketClient) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	start := time.Now()
+	r, err := b.Bucket.Get(ctx, name)
+	return b.trackReader(ctx, objstore.OpGet, name, r, start, err)
+}
+
+// GetRange implements objstore.Bucket.
+func (b *auditBucketClient) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	start := time.Now()
+	r, err := b.Bucket.GetRange(ctx, name, off, length)
+	return b.trackReader(ctx, objstore.OpGetRange, name, r, start, err)
+}
+
+// Exists implements objstore.Bucket.
+func (b *auditBucketClient) Exists(ctx context.Context, name string) (bool, error) {
+	start := time.Now()
+	exists, err := b.Bucket.Exists(ctx, name)
+	b.track(ctx, objstore.OpExists, operationClassMetadata, name, 0, start, err)
+	return exists, err
+}
+
+// Attributes implements objstore.Bucket.
+func (b *auditBucketClient) Attributes(ctx context.Context, name string) (objstore.ObjectAttributes, error) {
+	start := time.Now()
+	attrs, err := b.Bucket.Attributes(ctx, name)
+	b.track(ctx, objstore.OpAttributes, operationClassMetadata, name, 0, start, err)
+	return attrs, err
+}
+
+// trackReader tracks the read operation once the reader is closed, when the number of bytes read is known.
+func (b *auditBucketClient) trackReader(ctx context.Context, op, name string, r io.ReadCloser, start time.Time, err error) (io.ReadCloser, error) {
+	if err != nil {
+		b.track(ctx, op, operationClassRead, name, 0, start, err)
+		return nil, err
+	}
+
+	return &trackedReadCloser{
+		ReadCloser: r,
+		onClose: func(n int64) {
+			b.track(ctx, op, operationClassRead, name, n, start, nil)
+		},
+	}, nil
+}
+
+func (b *auditBucketClient) track(ctx context.Context, op, class, name string, n int64, start time.Time, err error) {
+	userID := tenantFromContext(ctx)
+
+	b.operations.WithLabelValues(userID, class).Inc()
+	if n > 0 {
+		b.bytes.WithLabelValues(userID, class).Add(float64(n))
+	}
+
+	if b.logger == nil {
+		return
+	}
+	keyvals := []interface{}{
+		"msg", "bucket operation",
+		"component", b.component,
+		"user", userID,
+		"operation", op,
+		"class", class,
+		"object", name,
+		"bytes", n,
+		"duration", time.Since(start),
+	}
+	if err != nil {
+		keyvals = append(keyvals, "err", err)
+	}
+	level.Info(b.logger).Log(keyvals...)
+}
+
+// countingReader counts the bytes read from the wrapped reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// trackedReadCloser counts the bytes read from the wrapped reader, and reports them once closed.
+type trackedReadCloser struct {
+	io.ReadCloser
+
+	onClose func(n int64)
+	n       int64
+	once    sync.Once
+}
+
+func (r *trackedReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// ObjectSize implements objstore.ObjectSizer.
+func (r *trackedReadCloser) ObjectSize() (int64, error) {
+	return objstore.TryToGetSize(r.ReadCloser)
+}
+
+func (r *trackedReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(func() { r.onClose(r.n) })
+	return err
+}
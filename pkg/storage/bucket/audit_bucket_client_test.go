@@ -0,0 +1,72 @@
+package bucket
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+)
+
+func TestAuditBucketClient(t *testing.T) {
+	ctx := context.Background()
+	reg := prometheus.NewPedanticRegistry()
+	logs := &bytes.Buffer{}
+
+	client, err := instrumentClient(objstore.NewInMemBucket(), Config{AuditLog: AuditLogConfig{Enabled: true}}, "test", log.NewLogfmtLogger(logs), reg)
+	require.NoError(t, err)
+
+	// The operations made on behalf of a tenant are attributed to the tenant.
+	userBkt := NewUserBucketClient("user-1", client, nil)
+	require.NoError(t, userBkt.Upload(ctx, "object", strings.NewReader("content")))
+
+	r, err := userBkt.GetRange(ctx, "object", 0, 4)
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	_, err = userBkt.Exists(ctx, "object")
+	require.NoError(t, err)
+	require.NoError(t, userBkt.Delete(ctx, "object"))
+
+	// The operations not made on behalf of a tenant are attributed to no tenant.
+	require.NoError(t, client.Iter(ctx, "", func(string) error { return nil }))
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_bucket_tenant_operations_total Total number of operations on the objects, per tenant and operation class.
+		# TYPE cortex_bucket_tenant_operations_total counter
+		cortex_bucket_tenant_operations_total{class="delete",component="test",user="user-1"} 1
+		cortex_bucket_tenant_operations_total{class="list",component="test",user=""} 1
+		cortex_bucket_tenant_operations_total{class="metadata",component="test",user="user-1"} 1
+		cortex_bucket_tenant_operations_total{class="read",component="test",user="user-1"} 1
+		cortex_bucket_tenant_operations_total{class="write",component="test",user="user-1"} 1
+
+		# HELP cortex_bucket_tenant_transferred_bytes_total Total number of bytes read from and written to the objects, per tenant and operation class.
+		# TYPE cortex_bucket_tenant_transferred_bytes_total counter
+		cortex_bucket_tenant_transferred_bytes_total{class="read",component="test",user="user-1"} 4
+		cortex_bucket_tenant_transferred_bytes_total{class="write",component="test",user="user-1"} 7
+	`), "cortex_bucket_tenant_operations_total", "cortex_bucket_tenant_transferred_bytes_total"))
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	require.Len(t, lines, 5)
+	assert.Contains(t, lines[0], `msg="bucket operation" component=test user=user-1 operation=upload class=write object=user-1/object bytes=7`)
+	assert.Contains(t, lines[1], `msg="bucket operation" component=test user=user-1 operation=get_range class=read object=user-1/object bytes=4`)
+}
+
+func TestAuditBucketClient_ShouldCountTheBytesOfTheReadersOfUnknownSize(t *testing.T) {
+	ctx := context.Background()
+	reg := prometheus.NewPedanticRegistry()
+
+	client := newAuditBucketClient(objstore.NewInMemBucket(), AuditLogConfig{}, "test", log.NewNopLogger(), reg)
+	require.NoError(t, client.Upload(ctx, "object", io.MultiReader(strings.NewReader("con"), strings.NewReader("tent"))))
+
+	assert.Equal(t, float64(7), testutil.ToFloat64(client.bytes.WithLabelValues("", operationClassWrite)))
+}
@@ -1,6 +1,9 @@
 package azure
 
 import (
+	"os"
+	"sync"
+
 	"github.com/go-kit/log"
 	"github.com/prometheus/common/model"
 	"github.com/thanos-io/objstore"
@@ -37,5 +40,50 @@ func NewBucketClient(cfg Config, name string, logger log.Logger) (objstore.Bucke
 		return nil, err
 	}
 
-	return azure.NewBucket(logger, serialized, name)
+	if !cfg.workloadIdentityEnabled() {
+		return azure.NewBucket(logger, serialized, name)
+	}
+
+	// The Azure Workload Identity is authenticated by the default Azure credential, which is only configured
+	// through the environment when it's created by the bucket.
+	var bucket objstore.Bucket
+	err = withEnv(map[string]string{
+		"AZURE_FEDERATED_TOKEN_FILE": cfg.FederatedTokenFile,
+		"AZURE_CLIENT_ID":            cfg.ClientID,
+		"AZURE_TENANT_ID":            cfg.TenantID,
+	}, func() error {
+		var err error
+		bucket, err = azure.NewBucket(logger, serialized, name)
+		return err
+	})
+	return bucket, err
+}
+
+// envMtx serializes the changes of the environment made by withEnv.
+var envMtx sync.Mutex
+
+// withEnv calls f with the non-empty environment variables of env set, and restores them afterwards.
+func withEnv(env map[string]string, f func() error) error {
+	envMtx.Lock()
+	defer envMtx.Unlock()
+
+	for name, value := range env {
+		if value == "" {
+			continue
+		}
+
+		prev, ok := os.LookupEnv(name)
+		if err := os.Setenv(name, value); err != nil {
+			return err
+		}
+		defer func(name string) {
+			if ok {
+				_ = os.Setenv(name, prev)
+			} else {
+				_ = os.Unsetenv(name)
+			}
+		}(name)
+	}
+
+	return f()
 }
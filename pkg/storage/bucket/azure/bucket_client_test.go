@@ -0,0 +1,33 @@
+package azure
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithEnv(t *testing.T) {
+	t.Setenv("AZURE_CLIENT_ID", "env-client-id")
+	t.Setenv("AZURE_TENANT_ID", "")
+	require.NoError(t, os.Unsetenv("AZURE_TENANT_ID"))
+
+	err := withEnv(map[string]string{
+		"AZURE_CLIENT_ID":            "client-id",
+		"AZURE_TENANT_ID":            "tenant-id",
+		"AZURE_FEDERATED_TOKEN_FILE": "",
+	}, func() error {
+		assert.Equal(t, "client-id", os.Getenv("AZURE_CLIENT_ID"))
+		assert.Equal(t, "tenant-id", os.Getenv("AZURE_TENANT_ID"))
+		_, ok := os.LookupEnv("AZURE_FEDERATED_TOKEN_FILE")
+		assert.False(t, ok)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// The environment is restored.
+	assert.Equal(t, "env-client-id", os.Getenv("AZURE_CLIENT_ID"))
+	_, ok := os.LookupEnv("AZURE_TENANT_ID")
+	assert.False(t, ok)
+}
@@ -3,6 +3,8 @@ package azure
 import (
 	"flag"
 
+	"github.com/pkg/errors"
+
 	"github.com/cortexproject/cortex/pkg/storage/bucket/http"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 )
@@ -19,9 +21,16 @@ type Config struct {
 	MSIResource    string `yaml:"msi_resource"`
 	UserAssignedID string `yaml:"user_assigned_id"`
 
+	// Azure Workload Identity, defaulting to the environment variables set by the workload identity webhook.
+	FederatedTokenFile string `yaml:"federated_token_file"`
+	ClientID           string `yaml:"client_id"`
+	TenantID           string `yaml:"tenant_id"`
+
 	http.Config `yaml:"http"`
 }
 
+var errWorkloadIdentityWithOtherAuth = errors.New("the Azure Workload Identity options cannot be set along with the account key, the connection string or the user assigned ID")
+
 // RegisterFlags registers the flags for Azure storage
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.RegisterFlagsWithPrefix("", f)
@@ -37,5 +46,21 @@ func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	f.IntVar(&cfg.MaxRetries, prefix+"azure.max-retries", 20, "Number of retries for recoverable errors")
 	f.StringVar(&cfg.MSIResource, prefix+"azure.msi-resource", "", "Deprecated: Azure storage MSI resource. It will be set automatically by Azure SDK.")
 	f.StringVar(&cfg.UserAssignedID, prefix+"azure.user-assigned-id", "", "Azure storage MSI resource managed identity client Id. If not supplied default Azure credential will be used. Set it to empty if you need to authenticate via Azure Workload Identity.")
+	f.StringVar(&cfg.FederatedTokenFile, prefix+"azure.federated-token-file", "", "Path of the federated token file used to authenticate via Azure Workload Identity. If not supplied, the AZURE_FEDERATED_TOKEN_FILE environment variable set by the workload identity webhook is used.")
+	f.StringVar(&cfg.ClientID, prefix+"azure.client-id", "", "Client ID of the application or user-assigned managed identity used to authenticate via Azure Workload Identity. If not supplied, the AZURE_CLIENT_ID environment variable set by the workload identity webhook is used.")
+	f.StringVar(&cfg.TenantID, prefix+"azure.tenant-id", "", "Azure Active Directory tenant ID used to authenticate via Azure Workload Identity. If not supplied, the AZURE_TENANT_ID environment variable set by the workload identity webhook is used.")
 	cfg.Config.RegisterFlagsWithPrefix(prefix+"azure.", f)
 }
+
+// Validate config and returns error on failure
+func (cfg *Config) Validate() error {
+	if cfg.workloadIdentityEnabled() && (cfg.StorageAccountKey.Value != "" || cfg.StorageConnectionString.Value != "" || cfg.UserAssignedID != "") {
+		return errWorkloadIdentityWithOtherAuth
+	}
+	return nil
+}
+
+// workloadIdentityEnabled returns whether any of the Azure Workload Identity options is set.
+func (cfg *Config) workloadIdentityEnabled() bool {
+	return cfg.FederatedTokenFile != "" || cfg.ClientID != "" || cfg.TenantID != ""
+}
@@ -77,6 +77,25 @@ http:
 			},
 			expectedErr: nil,
 		},
+		"workload identity config": {
+			config: `
+account_name: test-account-name
+container_name: test-container-name
+federated_token_file: /var/run/secrets/azure/tokens/azure-identity-token
+client_id: test-client-id
+tenant_id: test-tenant-id
+`,
+			expectedConfig: func() Config {
+				cfg := defaultConfig
+				cfg.StorageAccountName = "test-account-name"
+				cfg.ContainerName = "test-container-name"
+				cfg.FederatedTokenFile = "/var/run/secrets/azure/tokens/azure-identity-token"
+				cfg.ClientID = "test-client-id"
+				cfg.TenantID = "test-tenant-id"
+				return cfg
+			}(),
+			expectedErr: nil,
+		},
 		"invalid type": {
 			config:         `max_retries: foo`,
 			expectedConfig: defaultConfig,
@@ -97,3 +116,39 @@ http:
 		})
 	}
 }
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config      Config
+		expectedErr error
+	}{
+		"account key": {
+			config: Config{StorageAccountKey: flagext.Secret{Value: "key"}},
+		},
+		"workload identity": {
+			config: Config{FederatedTokenFile: "/token", ClientID: "client-id", TenantID: "tenant-id"},
+		},
+		"workload identity with account key": {
+			config:      Config{ClientID: "client-id", StorageAccountKey: flagext.Secret{Value: "key"}},
+			expectedErr: errWorkloadIdentityWithOtherAuth,
+		},
+		"workload identity with connection string": {
+			config:      Config{TenantID: "tenant-id", StorageConnectionString: flagext.Secret{Value: "connection-string"}},
+			expectedErr: errWorkloadIdentityWithOtherAuth,
+		},
+		"workload identity with user assigned ID": {
+			config:      Config{FederatedTokenFile: "/token", UserAssignedID: "user-assigned-id"},
+			expectedErr: errWorkloadIdentityWithOtherAuth,
+		},
+	}
+
+	for testName, testData := range tests {
+		testData := testData
+
+		t.Run(testName, func(t *testing.T) {
+			require.Equal(t, testData.expectedErr, testData.config.Validate())
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package bucket
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/objstore"
+	"golang.org/x/time/rate"
+)
+
+const bandwidthScheduleTimeLayout = "15:04"
+
+var errEmptyBandwidthSchedulePeriod = errors.New("the start time and the end time of a bandwidth schedule period must be different")
+
+// BandwidthGovernorConfig configures the bandwidth governor of the background traffic.
+type BandwidthGovernorConfig struct {
+	MaxBytesPerSecond uint64                    `yaml:"max_bytes_per_second"`
+	Timezone          string                    `yaml:"timezone"`
+	Schedule          []BandwidthSchedulePeriod `yaml:"schedule" doc:"nocli|description=Periods of the week during which the background traffic is capped to the maximum bytes per second of the period, instead of the maximum bytes per second above. The first period matching the current time applies."`
+}
+
+// BandwidthSchedulePeriod is a period of the week with its own cap of the background traffic.
+type BandwidthSchedulePeriod struct {
+	Weekdays          []string `yaml:"weekdays" doc:"nocli|description=Days of the week the period starts on, eg. monday. If empty, the period starts every day."`
+	StartTime         string   `yaml:"start_time" doc:"nocli|description=Time of the day the period starts at, in the HH:MM format."`
+	EndTime           string   `yaml:"end_time" doc:"nocli|description=Time of the day the period ends at, in the HH:MM format. If earlier than the start time, the period ends the next day."`
+	MaxBytesPerSecond uint64   `yaml:"max_bytes_per_second" doc:"nocli|description=Maximum number of bytes per second of the background traffic during the period. 0 to not cap the background traffic during the period.|default=0"`
+}
+
+// RegisterFlagsWithPrefix registers the flags for the bandwidth governor with the provided prefix.
+func (cfg *BandwidthGovernorConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.Uint64Var(&cfg.MaxBytesPerSecond, prefix+"max-bytes-per-second", 0, "[Experimental] Maximum number of bytes per second read from and written to the objects by the background jobs of the compactor and the blocks synchronization of the store-gateway, shared by the compactor and the store-gateway running in the same process. 0 to not cap the background traffic, except during the periods of the schedule.")
+	f.StringVar(&cfg.Timezone, prefix+"timezone", "UTC", "[Experimental] Timezone of the times of the periods of the background bandwidth schedule, eg. Europe/Paris.")
+}
+
+// Validate the config.
+func (cfg *BandwidthGovernorConfig) Validate() error {
+	if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+		return errors.Wrap(err, "invalid background bandwidth timezone")
+	}
+	for _, period := range cfg.Schedule {
+		if _, err := parseBandwidthSchedulePeriod(period); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *BandwidthGovernorConfig) enabled() bool {
+	return cfg.MaxBytesPerSecond > 0 || len(cfg.Schedule) > 0
+}
+
+// bandwidthSchedulePeriod is a parsed BandwidthSchedulePeriod.
+type bandwidthSchedulePeriod struct {
+	// The days the period starts on, nil if the period starts every day.
+	weekdays map[time.Weekday]bool
+
+	// The start and end of the period, in minutes since the beginning of the day.
+	start, end int
+
+	maxBytesPerSecond uint64
+}
+
+func parseBandwidthSchedulePeriod(cfg BandwidthSchedulePeriod) (bandwidthSchedulePeriod, error) {
+	p := bandwidthSchedulePeriod{maxBytesPerSecond: cfg.MaxBytesPerSecond}
+
+	for _, day := range cfg.Weekdays {
+		weekday, ok := parseWeekday(day)
+		if !ok {
+			return p, fmt.Errorf("invalid weekday %q of a bandwidth schedule period", day)
+		}
+		if p.weekdays == nil {
+			p.weekdays = map[time.Weekday]bool{}
+		}
+		p.weekdays[weekday] = true
+	}
+
+	for _, t := range []struct {
+		value string
+		dst   *int
+	}{{cfg.StartTime, &p.start}, {cfg.EndTime, &p.end}} {
+		parsed, err := time.Parse(bandwidthScheduleTimeLayout, t.value)
+		if err != nil {
+			return p, errors.Wrapf(err, "invalid time %q of a bandwidth schedule period", t.value)
+		}
+		*t.dst = parsed.Hour()*60 + parsed.Minute()
+	}
+	if p.start == p.end {
+		return p, errEmptyBandwidthSchedulePeriod
+	}
+
+	return p, nil
+}
+
+func parseWeekday(day string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(d.String(), day) {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// contains returns whether the period contains the time, in the timezone of the schedule.
+func (p bandwidthSchedulePeriod) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+
+	switch {
+	case p.start < p.end:
+		if minute < p.start || minute >= p.end {
+			return false
+		}
+	case minute >= p.start:
+		// The period started today, and ends tomorrow.
+	case minute < p.end:
+		// The period started yesterday.
+		day = (day + 6) % 7
+	default:
+		return false
+	}
+
+	return p.weekdays == nil || p.weekdays[day]
+}
+
+type backgroundTrafficContextKey struct{}
+
+// ContextWithBackgroundTraffic returns a context marking the bucket operations made with it as background traffic,
+// capped by the bandwidth governor.
+func ContextWithBackgroundTraffic(ctx context.Context) context.Context {
+	return context.WithValue(ctx, backgroundTrafficContextKey{}, true)
+}
+
+func isBackgroundTraffic(ctx context.Context) bool {
+	background, _ := ctx.Value(backgroundTrafficContextKey{}).(bool)
+	return background
+}
+
+// BandwidthGovernor caps the bytes per second of the background traffic of the bucket clients sharing it, to a
+// limit changing according to a weekly schedule, so that the background jobs don't saturate the network during the
+// peak query traffic.
+type BandwidthGovernor struct {
+	defaultLimit uint64
+	location     *time.Location
+	schedule     []bandwidthSchedulePeriod
+	limiter      *rate.Limiter
+
+	mtx          sync.Mutex
+	currentLimit uint64
+
+	limit        prometheus.Gauge
+	waitDuration prometheus.Counter
+}
+
+// NewBandwidthGovernor returns a new bandwidth governor, or nil if the background traffic isn't capped.
+func NewBandwidthGovernor(cfg BandwidthGovernorConfig, reg prometheus.Registerer) (*BandwidthGovernor, error) {
+	if !cfg.enabled() {
+		return nil, nil
+	}
+
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid background bandwidth timezone")
+	}
+
+	g := &BandwidthGovernor{
+		defaultLimit: cfg.MaxBytesPerSecond,
+		location:     location,
+		limiter:      rate.NewLimiter(rate.Inf, math.MaxInt32),
+		limit: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_bucket_background_bandwidth_limit_bytes_per_second",
+			Help: "Current maximum number of bytes per second of the background traffic, 0 if the background traffic isn't capped.",
+		}),
+		waitDuration: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_bucket_background_bandwidth_wait_seconds_total",
+			Help: "Total time spent by the background traffic waiting for the background bandwidth cap.",
+		}),
+	}
+	for _, period := range cfg.Schedule {
+		p, err := parseBandwidthSchedulePeriod(period)
+		if err != nil {
+			return nil, err
+		}
+		g.schedule = append(g.schedule, p)
+	}
+
+	g.update(time.Now())
+	return g, nil
+}
+
+// update applies the limit of the schedule at the given time, and returns it.
+func (g *BandwidthGovernor) update(now time.Time) uint64 {
+	limit := g.defaultLimit
+	local := now.In(g.location)
+	for _, p := range g.schedule {
+		if p.contains(local) {
+			limit = p.maxBytesPerSecond
+			break
+		}
+	}
+
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	if limit != g.currentLimit {
+		if limit == 0 {
+			g.limiter.SetLimitAt(now, rate.Inf)
+		} else {
+			// The burst is the bytes transferred in a second, as for the rate limits of the components.
+			g.limiter.SetLimitAt(now, rate.Limit(limit))
+			g.limiter.SetBurstAt(now, int(min(limit, math.MaxInt32)))
+		}
+		g.currentLimit = limit
+		g.limit.Set(float64(limit))
+	}
+	return limit
+}
+
+func (g *BandwidthGovernor) maxBytesPerWait() int {
+	return g.limiter.Burst()
+}
+
+func (g *BandwidthGovernor) waitBytes(ctx context.Context, n int) error {
+	if g.update(time.Now()) == 0 {
+		return nil
+	}
+
+	// The burst may have been lowered by the schedule since the bytes were read.
+	for n > 0 {
+		chunk := min(n, g.limiter.Burst())
+		waited, err := waitRateLimit(ctx, g.limiter, chunk, "bucket_background_bandwidth_wait", opentracing.Tags{"events": chunk})
+		g.waitDuration.Add(waited.Seconds())
+		if err != nil {
+			return err
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+// bandwidthGovernedBucketClient is a bucket client capping the bytes read and written by the background traffic
+// with the bandwidth governor.
+type bandwidthGovernedBucketClient struct {
+	objstore.Bucket
+
+	governor *BandwidthGovernor
+
+	// Whether only the operations marked as background traffic are capped.
+	backgroundOnly bool
+}
+
+// NewBandwidthGovernedBucketClient returns the bucket client capping the bytes read and written with the bandwidth
+// governor, or the bucket client itself if the governor is nil. If backgroundOnly is true, only the operations
+// whose context is marked with ContextWithBackgroundTraffic are capped.
+func NewBandwidthGovernedBucketClient(bkt objstore.InstrumentedBucket, governor *BandwidthGovernor, backgroundOnly bool) objstore.InstrumentedBucket {
+	if governor == nil {
+		return bkt
+	}
+	return &bandwidthGovernedBucketClient{Bucket: bkt, governor: governor, backgroundOnly: backgroundOnly}
+}
+
+func (b *bandwidthGovernedBucketClient) governed(ctx context.Context) bool {
+	return !b.backgroundOnly || isBackgroundTraffic(ctx)
+}
+
+// Upload implements objstore.Bucket.
+func (b *bandwidthGovernedBucketClient) Upload(ctx context.Context, name string, r io.Reader) error {
+	if b.governed(ctx) {
+		r = &rateLimitedReader{Reader: r, ctx: ctx, limiter: b.governor}
+	}
+	return b.Bucket.Upload(ctx, name, r)
+}
+
+// Get implements objstore.Bucket.
+func (b *bandwidthGovernedBucketClient) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	r, err := b.Bucket.Get(ctx, name)
+	return b.wrapReader(ctx, r, err)
+}
+
+// GetRange implements objstore.Bucket.
+func (b *bandwidthGovernedBucketClient) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	r, err := b.Bucket.GetRange(ctx, name, off, length)
+	return b.wrapReader(ctx, r, err)
+}
+
+func (b *bandwidthGovernedBucketClient) wrapReader(ctx context.Context, r io.ReadCloser, err error) (io.ReadCloser, error) {
+	if err != nil || !b.governed(ctx) {
+		return r, err
+	}
+	return &rateLimitedReadCloser{
+		rateLimitedReader: rateLimitedReader{Reader: r, ctx: ctx, limiter: b.governor},
+		closer:            r,
+	}, nil
+}
+
+// ReaderWithExpectedErrs implements objstore.InstrumentedBucket.
+func (b *bandwidthGovernedBucketClient) ReaderWithExpectedErrs(fn objstore.IsOpFailureExpectedFunc) objstore.BucketReader {
+	return b.WithExpectedErrs(fn)
+}
+
+// WithExpectedErrs implements objstore.InstrumentedBucket.
+func (b *bandwidthGovernedBucketClient) WithExpectedErrs(fn objstore.IsOpFailureExpectedFunc) objstore.Bucket {
+	if ib, ok := b.Bucket.(objstore.InstrumentedBucket); ok {
+		return &bandwidthGovernedBucketClient{Bucket: ib.WithExpectedErrs(fn), governor: b.governor, backgroundOnly: b.backgroundOnly}
+	}
+	return b
+}
@@ -0,0 +1,157 @@
+package bucket
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+)
+
+func TestBandwidthGovernorConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		cfg      BandwidthGovernorConfig
+		expected string
+	}{
+		"valid schedule": {
+			cfg: BandwidthGovernorConfig{Timezone: "Europe/Paris", Schedule: []BandwidthSchedulePeriod{
+				{Weekdays: []string{"Monday", "friday"}, StartTime: "09:00", EndTime: "18:00", MaxBytesPerSecond: 1000},
+			}},
+		},
+		"invalid timezone": {
+			cfg:      BandwidthGovernorConfig{Timezone: "Mars/Olympus"},
+			expected: "invalid background bandwidth timezone",
+		},
+		"invalid weekday": {
+			cfg: BandwidthGovernorConfig{Timezone: "UTC", Schedule: []BandwidthSchedulePeriod{
+				{Weekdays: []string{"mon"}, StartTime: "09:00", EndTime: "18:00"},
+			}},
+			expected: `invalid weekday "mon"`,
+		},
+		"invalid time": {
+			cfg: BandwidthGovernorConfig{Timezone: "UTC", Schedule: []BandwidthSchedulePeriod{
+				{StartTime: "9am", EndTime: "18:00"},
+			}},
+			expected: `invalid time "9am"`,
+		},
+		"empty period": {
+			cfg: BandwidthGovernorConfig{Timezone: "UTC", Schedule: []BandwidthSchedulePeriod{
+				{StartTime: "09:00", EndTime: "09:00"},
+			}},
+			expected: errEmptyBandwidthSchedulePeriod.Error(),
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			err := testData.cfg.Validate()
+			if testData.expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, testData.expected)
+			}
+		})
+	}
+}
+
+func TestBandwidthGovernor_ShouldApplyTheLimitOfTheSchedule(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	governor, err := NewBandwidthGovernor(BandwidthGovernorConfig{
+		MaxBytesPerSecond: 10000,
+		Timezone:          "America/New_York",
+		Schedule: []BandwidthSchedulePeriod{
+			{Weekdays: []string{"monday", "tuesday", "wednesday", "thursday", "friday"}, StartTime: "09:00", EndTime: "18:00", MaxBytesPerSecond: 1000},
+			{Weekdays: []string{"friday"}, StartTime: "22:00", EndTime: "06:00"},
+		},
+	}, reg)
+	require.NoError(t, err)
+
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		time     time.Time
+		expected uint64
+	}{
+		"business hours": {
+			time:     time.Date(2024, time.March, 13, 10, 30, 0, 0, newYork), // Wednesday.
+			expected: 1000,
+		},
+		"business hours in another timezone": {
+			time:     time.Date(2024, time.March, 13, 14, 30, 0, 0, time.UTC), // Wednesday, 10:30 in New York.
+			expected: 1000,
+		},
+		"end of the business hours": {
+			time:     time.Date(2024, time.March, 13, 18, 0, 0, 0, newYork),
+			expected: 10000,
+		},
+		"business hours during the weekend": {
+			time:     time.Date(2024, time.March, 16, 10, 30, 0, 0, newYork), // Saturday.
+			expected: 10000,
+		},
+		"overnight period on the day it starts": {
+			time:     time.Date(2024, time.March, 15, 23, 0, 0, 0, newYork), // Friday.
+			expected: 0,
+		},
+		"overnight period on the day after it starts": {
+			time:     time.Date(2024, time.March, 16, 5, 59, 0, 0, newYork), // Saturday.
+			expected: 0,
+		},
+		"overnight period on another day": {
+			time:     time.Date(2024, time.March, 14, 5, 0, 0, 0, newYork), // Thursday.
+			expected: 10000,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, governor.update(testData.time))
+			assert.Equal(t, float64(testData.expected), testutil.ToFloat64(governor.limit))
+		})
+	}
+}
+
+func TestNewBandwidthGovernor_ShouldReturnNilIfDisabled(t *testing.T) {
+	governor, err := NewBandwidthGovernor(BandwidthGovernorConfig{Timezone: "UTC"}, nil)
+	require.NoError(t, err)
+	assert.Nil(t, governor)
+
+	bkt := objstore.WithNoopInstr(objstore.NewInMemBucket())
+	assert.Equal(t, bkt, NewBandwidthGovernedBucketClient(bkt, governor, false))
+}
+
+func TestBandwidthGovernedBucketClient_ShouldOnlyCapTheBackgroundTraffic(t *testing.T) {
+	ctx := context.Background()
+	content := strings.Repeat("x", 1500)
+
+	governor, err := NewBandwidthGovernor(BandwidthGovernorConfig{MaxBytesPerSecond: 1000, Timezone: "UTC"}, prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+	client := NewBandwidthGovernedBucketClient(objstore.WithNoopInstr(objstore.NewInMemBucket()), governor, true)
+
+	// The foreground traffic isn't capped.
+	start := time.Now()
+	require.NoError(t, client.Upload(ctx, "object", strings.NewReader(content)))
+	readObject(ctx, t, client, content)
+	assert.Less(t, time.Since(start), 400*time.Millisecond)
+
+	// The background traffic exceeding the burst is capped.
+	start = time.Now()
+	readObject(ContextWithBackgroundTraffic(ctx), t, client, content)
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+	assert.Greater(t, testutil.ToFloat64(governor.waitDuration), 0.0)
+}
+
+func readObject(ctx context.Context, t *testing.T, bkt objstore.Bucket, expected string) {
+	r, err := bkt.Get(ctx, "object")
+	require.NoError(t, err)
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, expected, string(b))
+}
@@ -0,0 +1,27 @@
+package bos
+
+import (
+	"github.com/go-kit/log"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/objstore/providers/bos"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// NewBucketClient creates a new Baidu Cloud BOS bucket client
+func NewBucketClient(cfg Config, name string, logger log.Logger) (objstore.Bucket, error) {
+	bucketConfig := bos.Config{
+		Endpoint:  cfg.Endpoint,
+		Bucket:    cfg.BucketName,
+		AccessKey: cfg.AccessKey,
+		SecretKey: cfg.SecretKey.Value,
+	}
+
+	// Thanos currently doesn't support passing the config as is, but expects a YAML,
+	// so we're going to serialize it.
+	serialized, err := yaml.Marshal(bucketConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return bos.NewBucket(logger, serialized, name)
+}
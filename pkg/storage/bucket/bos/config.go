@@ -0,0 +1,46 @@
+package bos
+
+import (
+	"flag"
+
+	"github.com/pkg/errors"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+var (
+	errMissingEndpointOrBucket = errors.New("the Baidu Cloud BOS endpoint and bucket name must be set")
+	errMissingAccessKey        = errors.New("the Baidu Cloud BOS access key and secret key must be set")
+)
+
+// Config holds the config options for a Baidu Cloud BOS backend
+type Config struct {
+	Endpoint   string         `yaml:"endpoint"`
+	BucketName string         `yaml:"bucket_name"`
+	AccessKey  string         `yaml:"access_key"`
+	SecretKey  flagext.Secret `yaml:"secret_key"`
+}
+
+// RegisterFlags registers the flags for Baidu Cloud BOS storage
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	cfg.RegisterFlagsWithPrefix("", f)
+}
+
+// RegisterFlagsWithPrefix registers the flags for Baidu Cloud BOS storage with the provided prefix
+func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Endpoint, prefix+"bos.endpoint", "", "Baidu Cloud BOS endpoint to connect to, for example bj.bcebos.com.")
+	f.StringVar(&cfg.BucketName, prefix+"bos.bucket-name", "", "Baidu Cloud BOS bucket name.")
+	f.StringVar(&cfg.AccessKey, prefix+"bos.access-key", "", "Baidu Cloud BOS access key.")
+	f.Var(&cfg.SecretKey, prefix+"bos.secret-key", "Baidu Cloud BOS secret key.")
+}
+
+// Validate config and returns error on failure
+func (cfg *Config) Validate() error {
+	if cfg.Endpoint == "" || cfg.BucketName == "" {
+		return errMissingEndpointOrBucket
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey.Value == "" {
+		return errMissingAccessKey
+	}
+	return nil
+}
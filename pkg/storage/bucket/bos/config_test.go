@@ -0,0 +1,34 @@
+package bos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		cfg         Config
+		expectedErr error
+	}{
+		"valid config": {
+			cfg: Config{Endpoint: "bj.bcebos.com", BucketName: "test", AccessKey: "xxx", SecretKey: flagext.Secret{Value: "yyy"}},
+		},
+		"missing bucket name": {
+			cfg:         Config{Endpoint: "bj.bcebos.com", AccessKey: "xxx", SecretKey: flagext.Secret{Value: "yyy"}},
+			expectedErr: errMissingEndpointOrBucket,
+		},
+		"missing secret key": {
+			cfg:         Config{Endpoint: "bj.bcebos.com", BucketName: "test", AccessKey: "xxx"},
+			expectedErr: errMissingAccessKey,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expectedErr, testData.cfg.Validate())
+		})
+	}
+}
@@ -10,11 +10,13 @@ import (
 	"github.com/go-kit/log"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/thanos-io/objstore"
-	"github.com/thanos-io/objstore/tracing/opentracing"
 
 	"github.com/cortexproject/cortex/pkg/storage/bucket/azure"
+	"github.com/cortexproject/cortex/pkg/storage/bucket/bos"
+	"github.com/cortexproject/cortex/pkg/storage/bucket/cos"
 	"github.com/cortexproject/cortex/pkg/storage/bucket/filesystem"
 	"github.com/cortexproject/cortex/pkg/storage/bucket/gcs"
+	"github.com/cortexproject/cortex/pkg/storage/bucket/oss"
 	"github.com/cortexproject/cortex/pkg/storage/bucket/s3"
 	"github.com/cortexproject/cortex/pkg/storage/bucket/swift"
 	"github.com/cortexproject/cortex/pkg/util"
@@ -35,10 +37,19 @@ const (
 
 	// Filesystem is the value for the filesystem storage backend.
 	Filesystem = "filesystem"
+
+	// OSS is the value for the Alibaba Cloud OSS storage backend.
+	OSS = "oss"
+
+	// COS is the value for the Tencent Cloud COS storage backend.
+	COS = "cos"
+
+	// BOS is the value for the Baidu Cloud BOS storage backend.
+	BOS = "bos"
 )
 
 var (
-	SupportedBackends = []string{S3, GCS, Azure, Swift, Filesystem}
+	SupportedBackends = []string{S3, GCS, Azure, Swift, Filesystem, OSS, COS, BOS}
 
 	ErrUnsupportedStorageBackend = errors.New("unsupported storage backend")
 
@@ -54,6 +65,14 @@ type Config struct {
 	Azure      azure.Config      `yaml:"azure"`
 	Swift      swift.Config      `yaml:"swift"`
 	Filesystem filesystem.Config `yaml:"filesystem"`
+	OSS        oss.Config        `yaml:"oss"`
+	COS        cos.Config        `yaml:"cos"`
+	BOS        bos.Config        `yaml:"bos"`
+
+	Hedging HedgingConfig `yaml:"hedging"`
+	Retries RetriesConfig `yaml:"retries"`
+
+	AuditLog AuditLogConfig `yaml:"audit_log"`
 
 	// Not used internally, meant to allow callers to wrap Buckets
 	// created using this config
@@ -84,6 +103,12 @@ func (cfg *Config) RegisterFlagsWithPrefixAndBackend(prefix string, f *flag.Flag
 	cfg.Azure.RegisterFlagsWithPrefix(prefix, f)
 	cfg.Swift.RegisterFlagsWithPrefix(prefix, f)
 	cfg.Filesystem.RegisterFlagsWithPrefix(prefix, f)
+	cfg.OSS.RegisterFlagsWithPrefix(prefix, f)
+	cfg.COS.RegisterFlagsWithPrefix(prefix, f)
+	cfg.BOS.RegisterFlagsWithPrefix(prefix, f)
+	cfg.Hedging.RegisterFlagsWithPrefix(prefix, f)
+	cfg.Retries.RegisterFlagsWithPrefix(prefix, f)
+	cfg.AuditLog.RegisterFlagsWithPrefix(prefix, f)
 
 	f.StringVar(&cfg.Backend, prefix+"backend", defaultBackend, fmt.Sprintf("Backend storage to use. Supported backends are: %s.", strings.Join(cfg.supportedBackends(), ", ")))
 }
@@ -98,13 +123,53 @@ func (cfg *Config) Validate() error {
 			return err
 		}
 	}
+	if cfg.Backend == GCS {
+		if err := cfg.GCS.Validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Backend == Azure {
+		if err := cfg.Azure.Validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Backend == OSS {
+		if err := cfg.OSS.Validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Backend == COS {
+		if err := cfg.COS.Validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Backend == BOS {
+		if err := cfg.BOS.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := cfg.Hedging.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.Retries.Validate(); err != nil {
+		return err
+	}
 
 	return nil
 }
 
 // NewClient creates a new bucket client based on the configured backend
 func NewClient(ctx context.Context, cfg Config, name string, logger log.Logger, reg prometheus.Registerer) (bucket objstore.InstrumentedBucket, err error) {
-	var client objstore.Bucket
+	client, err := newBackendClient(ctx, cfg, name, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return instrumentClient(client, cfg, name, logger, reg)
+}
+
+// newBackendClient creates a new bucket client, without instrumentation, based on the configured backend.
+func newBackendClient(ctx context.Context, cfg Config, name string, logger log.Logger) (client objstore.Bucket, err error) {
 	switch cfg.Backend {
 	case S3:
 		client, err = s3.NewBucketClient(cfg.S3, name, logger)
@@ -116,15 +181,42 @@ func NewClient(ctx context.Context, cfg Config, name string, logger log.Logger,
 		client, err = swift.NewBucketClient(cfg.Swift, name, logger)
 	case Filesystem:
 		client, err = filesystem.NewBucketClient(cfg.Filesystem)
+	case OSS:
+		client, err = oss.NewBucketClient(cfg.OSS, name, logger)
+	case COS:
+		client, err = cos.NewBucketClient(cfg.COS, name, logger)
+	case BOS:
+		client, err = bos.NewBucketClient(cfg.BOS, name, logger)
 	default:
 		return nil, ErrUnsupportedStorageBackend
 	}
 
-	if err != nil {
-		return nil, err
+	return client, err
+}
+
+// instrumentClient wraps the bucket client with the audit, hedging, retries, metrics, tracing and the middlewares of the
+// config.
+func instrumentClient(client objstore.Bucket, cfg Config, name string, logger log.Logger, reg prometheus.Registerer) (objstore.InstrumentedBucket, error) {
+	var err error
+
+	var componentReg prometheus.Registerer
+	if reg != nil {
+		componentReg = prometheus.WrapRegistererWith(prometheus.Labels{"component": name}, reg)
+	}
+
+	// Each request sent to the storage is audited, while the hedged and retried requests are tracked as a single
+	// operation by the bucket metrics.
+	if componentReg != nil || cfg.AuditLog.Enabled {
+		client = newAuditBucketClient(client, cfg.AuditLog, name, logger, componentReg)
+	}
+	if cfg.Hedging.Enabled {
+		client = newHedgedBucketClient(client, cfg.Hedging, componentReg)
+	}
+	if cfg.Retries.MaxRetries > 0 {
+		client = newRetryingBucketClient(client, cfg.Retries, componentReg)
 	}
 
-	iClient := opentracing.WrapWithTraces(bucketWithMetrics(client, name, reg))
+	var iClient objstore.InstrumentedBucket = newTracingBucketClient(bucketWithMetrics(client, name, reg), name)
 
 	// Wrap the client with any provided middleware
 	for _, wrap := range cfg.Middlewares {
@@ -49,6 +49,34 @@ gcs:
     }
 `
 
+	configWithOSSBackend = `
+backend: oss
+oss:
+  endpoint:          http://localhost
+  bucket_name:       test
+  access_key_id:     xxx
+  access_key_secret: yyy
+`
+
+	configWithCOSBackend = `
+backend: cos
+cos:
+  bucket_name: test
+  app_id:      "1250000000"
+  region:      ap-singapore
+  secret_id:   xxx
+  secret_key:  yyy
+`
+
+	configWithBOSBackend = `
+backend: bos
+bos:
+  endpoint:    localhost
+  bucket_name: test
+  access_key:  xxx
+  secret_key:  yyy
+`
+
 	configWithUnknownBackend = `
 backend: unknown
 `
@@ -69,6 +97,18 @@ func TestNewClient(t *testing.T) {
 			config:      configWithGCSBackend,
 			expectedErr: nil,
 		},
+		"should create an OSS bucket": {
+			config:      configWithOSSBackend,
+			expectedErr: nil,
+		},
+		"should create a COS bucket": {
+			config:      configWithCOSBackend,
+			expectedErr: nil,
+		},
+		"should create a BOS bucket": {
+			config:      configWithBOSBackend,
+			expectedErr: nil,
+		},
 		"should return error on unknown backend": {
 			config:      configWithUnknownBackend,
 			expectedErr: ErrUnsupportedStorageBackend,
@@ -0,0 +1,41 @@
+package cos
+
+import (
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/objstore/exthttp"
+	"github.com/thanos-io/objstore/providers/cos"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// NewBucketClient creates a new Tencent Cloud COS bucket client
+func NewBucketClient(cfg Config, name string, logger log.Logger) (objstore.Bucket, error) {
+	bucketConfig := cos.Config{
+		Bucket:    cfg.BucketName,
+		AppId:     cfg.AppID,
+		Region:    cfg.Region,
+		Endpoint:  cfg.Endpoint,
+		SecretId:  cfg.SecretID,
+		SecretKey: cfg.SecretKey.Value,
+		HTTPConfig: exthttp.HTTPConfig{
+			IdleConnTimeout:       model.Duration(cfg.IdleConnTimeout),
+			ResponseHeaderTimeout: model.Duration(cfg.ResponseHeaderTimeout),
+			InsecureSkipVerify:    cfg.InsecureSkipVerify,
+			TLSHandshakeTimeout:   model.Duration(cfg.TLSHandshakeTimeout),
+			ExpectContinueTimeout: model.Duration(cfg.ExpectContinueTimeout),
+			MaxIdleConns:          cfg.MaxIdleConns,
+			MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+			MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		},
+	}
+
+	// Thanos currently doesn't support passing the config as is, but expects a YAML,
+	// so we're going to serialize it.
+	serialized, err := yaml.Marshal(bucketConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return cos.NewBucket(logger, serialized, name)
+}
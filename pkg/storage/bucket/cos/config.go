@@ -0,0 +1,59 @@
+package cos
+
+import (
+	"flag"
+	"net/url"
+
+	"github.com/pkg/errors"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket/http"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+var (
+	errMissingBucket    = errors.New("the Tencent Cloud COS bucket name, app ID and region must be set when the endpoint isn't set")
+	errMissingSecretKey = errors.New("the Tencent Cloud COS secret ID and secret key must be set")
+)
+
+// Config holds the config options for a Tencent Cloud COS backend
+type Config struct {
+	BucketName string         `yaml:"bucket_name"`
+	AppID      string         `yaml:"app_id"`
+	Region     string         `yaml:"region"`
+	Endpoint   string         `yaml:"endpoint"`
+	SecretID   string         `yaml:"secret_id"`
+	SecretKey  flagext.Secret `yaml:"secret_key"`
+
+	http.Config `yaml:"http"`
+}
+
+// RegisterFlags registers the flags for Tencent Cloud COS storage
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	cfg.RegisterFlagsWithPrefix("", f)
+}
+
+// RegisterFlagsWithPrefix registers the flags for Tencent Cloud COS storage with the provided prefix
+func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.BucketName, prefix+"cos.bucket-name", "", "Tencent Cloud COS bucket name, without the app ID suffix.")
+	f.StringVar(&cfg.AppID, prefix+"cos.app-id", "", "Tencent Cloud COS app ID, suffixed to the bucket name.")
+	f.StringVar(&cfg.Region, prefix+"cos.region", "", "Tencent Cloud COS region, for example ap-singapore.")
+	f.StringVar(&cfg.Endpoint, prefix+"cos.endpoint", "", "Tencent Cloud COS bucket endpoint, including the schema. If set, the bucket name, app ID and region are ignored.")
+	f.StringVar(&cfg.SecretID, prefix+"cos.secret-id", "", "Tencent Cloud COS secret ID.")
+	f.Var(&cfg.SecretKey, prefix+"cos.secret-key", "Tencent Cloud COS secret key.")
+	cfg.Config.RegisterFlagsWithPrefix(prefix+"cos.", f)
+}
+
+// Validate config and returns error on failure
+func (cfg *Config) Validate() error {
+	if cfg.Endpoint != "" {
+		if _, err := url.Parse(cfg.Endpoint); err != nil {
+			return errors.Wrap(err, "invalid Tencent Cloud COS endpoint")
+		}
+	} else if cfg.BucketName == "" || cfg.AppID == "" || cfg.Region == "" {
+		return errMissingBucket
+	}
+	if cfg.SecretID == "" || cfg.SecretKey.Value == "" {
+		return errMissingSecretKey
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package cos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		cfg         Config
+		expectedErr error
+	}{
+		"valid config with bucket, app ID and region": {
+			cfg: Config{BucketName: "test", AppID: "1250000000", Region: "ap-singapore", SecretID: "xxx", SecretKey: flagext.Secret{Value: "yyy"}},
+		},
+		"valid config with endpoint": {
+			cfg: Config{Endpoint: "https://test-1250000000.cos.ap-singapore.myqcloud.com", SecretID: "xxx", SecretKey: flagext.Secret{Value: "yyy"}},
+		},
+		"missing app ID": {
+			cfg:         Config{BucketName: "test", Region: "ap-singapore", SecretID: "xxx", SecretKey: flagext.Secret{Value: "yyy"}},
+			expectedErr: errMissingBucket,
+		},
+		"missing secret key": {
+			cfg:         Config{Endpoint: "https://test-1250000000.cos.ap-singapore.myqcloud.com", SecretID: "xxx"},
+			expectedErr: errMissingSecretKey,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expectedErr, testData.cfg.Validate())
+		})
+	}
+}
@@ -1,11 +1,236 @@
 package filesystem
 
 import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/tsdb/fileutil"
 	"github.com/thanos-io/objstore"
 	"github.com/thanos-io/objstore/providers/filesystem"
 )
 
+const (
+	// uploadsDirName is the directory, in the root directory, the objects are written to before being renamed
+	// to their final location.
+	uploadsDirName = ".uploads"
+
+	// lockFileName is the file, in the root directory, locked while the objects are renamed or deleted.
+	lockFileName = ".lock"
+
+	// The uploads older than staleUploadAge are the leftovers of a crashed process, and are removed when the
+	// bucket client is created.
+	staleUploadAge = time.Hour
+
+	// lockTimeout is how long to wait for the lock held by another process.
+	lockTimeout = 30 * time.Second
+)
+
+var (
+	// rootLocks serializes the locking of the root directories within the process.
+	rootLocksMtx sync.Mutex
+	rootLocks    = map[string]*sync.Mutex{}
+)
+
 // NewBucketClient creates a new filesystem bucket client
 func NewBucketClient(cfg Config) (objstore.Bucket, error) {
-	return filesystem.NewBucket(cfg.Directory)
+	bkt, err := filesystem.NewBucket(cfg.Directory)
+	if err != nil {
+		return nil, err
+	}
+
+	rootDir, err := filepath.Abs(cfg.Directory)
+	if err != nil {
+		return nil, err
+	}
+	uploadsDir := filepath.Join(rootDir, uploadsDirName)
+	if err := os.MkdirAll(uploadsDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	if err := removeStaleUploads(uploadsDir); err != nil {
+		return nil, err
+	}
+
+	rootLocksMtx.Lock()
+	rootLock, ok := rootLocks[rootDir]
+	if !ok {
+		rootLock = &sync.Mutex{}
+		rootLocks[rootDir] = rootLock
+	}
+	rootLocksMtx.Unlock()
+
+	return &bucketClient{
+		Bucket:     bkt,
+		rootDir:    rootDir,
+		uploadsDir: uploadsDir,
+		rootLock:   rootLock,
+	}, nil
+}
+
+// bucketClient is a filesystem bucket client uploading the objects atomically and durably: an object is written
+// and synced to a temporary file, which is renamed to the object once complete, so that a crash never leaves a
+// partially written object. The renames and the deletions, which remove the emptied directories, are serialized
+// with an advisory lock of the root directory, shared by the processes using the same directory.
+type bucketClient struct {
+	*filesystem.Bucket
+
+	rootDir    string
+	uploadsDir string
+	rootLock   *sync.Mutex
+}
+
+// Upload implements objstore.Bucket.
+func (b *bucketClient) Upload(ctx context.Context, name string, r io.Reader) (err error) {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	tmp, err := os.CreateTemp(b.uploadsDir, "upload-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		_ = tmp.Close()
+		return errors.Wrapf(err, "copy to %s", tmp.Name())
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return errors.Wrapf(err, "sync %s", tmp.Name())
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	release, err := b.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	file := filepath.Join(b.rootDir, name)
+	if err := mkdirAllSynced(filepath.Dir(file)); err != nil {
+		return err
+	}
+	return fileutil.Rename(tmp.Name(), file)
+}
+
+// Delete implements objstore.Bucket.
+func (b *bucketClient) Delete(ctx context.Context, name string) error {
+	release, err := b.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return b.Bucket.Delete(ctx, name)
+}
+
+// Iter implements objstore.Bucket.
+func (b *bucketClient) Iter(ctx context.Context, dir string, f func(string) error, options ...objstore.IterOption) error {
+	return b.Bucket.Iter(ctx, dir, func(name string) error {
+		// Hide the internal files of the bucket client.
+		if name == lockFileName || strings.HasPrefix(name, uploadsDirName+"/") {
+			return nil
+		}
+		return f(name)
+	}, options...)
+}
+
+// lock locks the root directory, within the process and across the processes using the same directory, and
+// returns the function releasing the lock.
+func (b *bucketClient) lock(ctx context.Context) (func(), error) {
+	b.rootLock.Lock()
+
+	// The file lock doesn't wait for the lock to be released by another process, so it's retried.
+	deadline := time.Now().Add(lockTimeout)
+	backoff := time.Millisecond
+	for {
+		releaser, _, err := fileutil.Flock(filepath.Join(b.rootDir, lockFileName))
+		if err == nil {
+			return func() {
+				_ = releaser.Release()
+				b.rootLock.Unlock()
+			}, nil
+		}
+
+		if time.Now().After(deadline) {
+			b.rootLock.Unlock()
+			return nil, errors.Wrapf(err, "lock %s", b.rootDir)
+		}
+		select {
+		case <-ctx.Done():
+			b.rootLock.Unlock()
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff < 100*time.Millisecond {
+			backoff *= 2
+		}
+	}
+}
+
+// mkdirAllSynced creates the directory and its missing parents, syncing the parent of each created directory
+// so that the directories survive a crash.
+func mkdirAllSynced(dir string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := mkdirAllSynced(filepath.Dir(dir)); err != nil {
+		return err
+	}
+	if err := os.Mkdir(dir, os.ModePerm); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return syncDir(filepath.Dir(dir))
+}
+
+func syncDir(dir string) error {
+	d, err := fileutil.OpenDir(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		_ = d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+// removeStaleUploads removes the uploads left over by a crashed process.
+func removeStaleUploads(uploadsDir string) error {
+	entries, err := os.ReadDir(uploadsDir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if time.Since(info.ModTime()) < staleUploadAge {
+			continue
+		}
+		if err := os.Remove(filepath.Join(uploadsDir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,108 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+)
+
+func TestBucketClient_Upload(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	bkt, err := NewBucketClient(Config{Directory: dir})
+	require.NoError(t, err)
+
+	require.NoError(t, bkt.Upload(ctx, "user-1/block/meta.json", strings.NewReader("content")))
+
+	content, err := os.ReadFile(filepath.Join(dir, "user-1/block/meta.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+
+	// A failed upload doesn't leave a partial object, nor a temporary file.
+	err = bkt.Upload(ctx, "user-1/block/index", io.MultiReader(strings.NewReader("partial"), &failingReader{}))
+	require.Error(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "user-1/block/index"))
+
+	uploads, err := os.ReadDir(filepath.Join(dir, uploadsDirName))
+	require.NoError(t, err)
+	assert.Empty(t, uploads)
+
+	// The internal files aren't listed.
+	var entries []string
+	require.NoError(t, bkt.Iter(ctx, "", func(name string) error {
+		entries = append(entries, name)
+		return nil
+	}))
+	assert.Equal(t, []string{"user-1/"}, entries)
+
+	entries = nil
+	require.NoError(t, bkt.Iter(ctx, "", func(name string) error {
+		entries = append(entries, name)
+		return nil
+	}, objstore.WithRecursiveIter))
+	assert.Equal(t, []string{"user-1/block/meta.json"}, entries)
+}
+
+func TestBucketClient_ShouldRemoveStaleUploads(t *testing.T) {
+	dir := t.TempDir()
+	uploadsDir := filepath.Join(dir, uploadsDirName)
+	require.NoError(t, os.MkdirAll(uploadsDir, os.ModePerm))
+
+	stale, recent := filepath.Join(uploadsDir, "upload-stale"), filepath.Join(uploadsDir, "upload-recent")
+	require.NoError(t, os.WriteFile(stale, []byte("stale"), 0644))
+	require.NoError(t, os.WriteFile(recent, []byte("recent"), 0644))
+	require.NoError(t, os.Chtimes(stale, time.Now().Add(-2*staleUploadAge), time.Now().Add(-2*staleUploadAge)))
+
+	_, err := NewBucketClient(Config{Directory: dir})
+	require.NoError(t, err)
+
+	assert.NoFileExists(t, stale)
+	assert.FileExists(t, recent)
+}
+
+func TestBucketClient_ConcurrentUploadsAndDeletes(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// The bucket clients sharing the directory don't interfere, even though a deletion removes the directories
+	// emptied by it.
+	bkt1, err := NewBucketClient(Config{Directory: dir})
+	require.NoError(t, err)
+	bkt2, err := NewBucketClient(Config{Directory: dir})
+	require.NoError(t, err)
+
+	wg := sync.WaitGroup{}
+	for i, bkt := range []objstore.Bucket{bkt1, bkt2} {
+		wg.Add(1)
+		go func(i int, bkt objstore.Bucket) {
+			defer wg.Done()
+
+			for j := 0; j < 50; j++ {
+				name := fmt.Sprintf("user-1/block/object-%d-%d", i, j)
+				assert.NoError(t, bkt.Upload(ctx, name, strings.NewReader(name)))
+				assert.NoError(t, bkt.Delete(ctx, name))
+			}
+		}(i, bkt)
+	}
+	wg.Wait()
+
+	assert.NoDirExists(t, filepath.Join(dir, "user-1"))
+}
+
+type failingReader struct{}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read failed")
+}
@@ -2,13 +2,23 @@ package gcs
 
 import (
 	"context"
+	"io"
 
+	"cloud.google.com/go/storage"
 	"github.com/go-kit/log"
 	"github.com/thanos-io/objstore"
 	"github.com/thanos-io/objstore/providers/gcs"
 	yaml "gopkg.in/yaml.v2"
 )
 
+type kmsKeyNameContextKey struct{}
+
+// ContextWithKMSKeyName returns a context overriding the KMS key name used to encrypt the objects uploaded
+// with it, ignored by the other storage backends.
+func ContextWithKMSKeyName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, kmsKeyNameContextKey{}, name)
+}
+
 // NewBucketClient creates a new GCS bucket client
 func NewBucketClient(ctx context.Context, cfg Config, name string, logger log.Logger) (objstore.Bucket, error) {
 	bucketConfig := gcs.Config{
@@ -23,5 +33,42 @@ func NewBucketClient(ctx context.Context, cfg Config, name string, logger log.Lo
 		return nil, err
 	}
 
-	return gcs.NewBucket(ctx, logger, serialized, name)
+	bkt, err := gcs.NewBucket(ctx, logger, serialized, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bucketClient{
+		Bucket:     bkt,
+		handle:     bkt.Handle(),
+		kmsKeyName: cfg.KMSKeyName,
+	}, nil
+}
+
+// bucketClient is a GCS bucket client encrypting the uploaded objects with a customer-managed encryption key,
+// which the Thanos GCS client doesn't support.
+type bucketClient struct {
+	objstore.Bucket
+
+	handle     *storage.BucketHandle
+	kmsKeyName string
+}
+
+// Upload implements objstore.Bucket.
+func (b *bucketClient) Upload(ctx context.Context, name string, r io.Reader) error {
+	kmsKeyName := b.kmsKeyName
+	if override, ok := ctx.Value(kmsKeyNameContextKey{}).(string); ok && override != "" {
+		kmsKeyName = override
+	}
+	if kmsKeyName == "" {
+		return b.Bucket.Upload(ctx, name, r)
+	}
+
+	w := b.handle.Object(name).NewWriter(ctx)
+	w.KMSKeyName = kmsKeyName
+
+	if _, err := io.Copy(w, r); err != nil {
+		return err
+	}
+	return w.Close()
 }
@@ -0,0 +1,93 @@
+package gcs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"google.golang.org/api/option"
+)
+
+func TestBucketClient_Upload_ShouldEncryptWithKMSKey(t *testing.T) {
+	const (
+		defaultKeyName  = "projects/test/locations/global/keyRings/cortex/cryptoKeys/default"
+		overrideKeyName = "projects/test/locations/global/keyRings/cortex/cryptoKeys/tenant"
+	)
+
+	tests := map[string]struct {
+		defaultKeyName   string
+		overrideKeyName  string
+		expectedKeyName  string
+		expectedUploaded bool
+	}{
+		"no KMS key": {
+			expectedUploaded: false,
+		},
+		"default KMS key": {
+			defaultKeyName:   defaultKeyName,
+			expectedKeyName:  defaultKeyName,
+			expectedUploaded: true,
+		},
+		"KMS key overridden by the context": {
+			defaultKeyName:   defaultKeyName,
+			overrideKeyName:  overrideKeyName,
+			expectedKeyName:  overrideKeyName,
+			expectedUploaded: true,
+		},
+		"KMS key set by the context only": {
+			overrideKeyName:  overrideKeyName,
+			expectedKeyName:  overrideKeyName,
+			expectedUploaded: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var req *http.Request
+
+			// Start a fake HTTP server which simulate GCS.
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Keep track of the received request.
+				req = r
+
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = io.WriteString(w, `{"bucket":"test-bucket","name":"test"}`)
+			}))
+			defer srv.Close()
+
+			client, err := storage.NewClient(context.Background(), option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+			require.NoError(t, err)
+			defer client.Close()
+
+			fallback := objstore.NewInMemBucket()
+			bkt := &bucketClient{
+				Bucket:     fallback,
+				handle:     client.Bucket("test-bucket"),
+				kmsKeyName: testData.defaultKeyName,
+			}
+
+			ctx := context.Background()
+			if testData.overrideKeyName != "" {
+				ctx = ContextWithKMSKeyName(ctx, testData.overrideKeyName)
+			}
+			require.NoError(t, bkt.Upload(ctx, "test", strings.NewReader("test")))
+
+			if !testData.expectedUploaded {
+				assert.Nil(t, req)
+				assert.Contains(t, fallback.Objects(), "test")
+				return
+			}
+
+			require.NotNil(t, req)
+			assert.Equal(t, testData.expectedKeyName, req.URL.Query().Get("kmsKeyName"))
+			assert.Empty(t, fallback.Objects())
+		})
+	}
+}
@@ -2,14 +2,24 @@ package gcs
 
 import (
 	"flag"
+	"regexp"
+
+	"github.com/pkg/errors"
 
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 )
 
+var (
+	kmsKeyNameRegexp = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/keyRings/[^/]+/cryptoKeys/[^/]+$`)
+
+	errInvalidKMSKeyName = errors.New("invalid GCS KMS key name, expected format: projects/<project>/locations/<location>/keyRings/<key-ring>/cryptoKeys/<key>")
+)
+
 // Config holds the config options for GCS backend
 type Config struct {
 	BucketName     string         `yaml:"bucket_name"`
 	ServiceAccount flagext.Secret `yaml:"service_account"`
+	KMSKeyName     string         `yaml:"kms_key_name"`
 }
 
 // RegisterFlags registers the flags for GCS storage
@@ -21,4 +31,18 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	f.StringVar(&cfg.BucketName, prefix+"gcs.bucket-name", "", "GCS bucket name")
 	f.Var(&cfg.ServiceAccount, prefix+"gcs.service-account", "JSON representing either a Google Developers Console client_credentials.json file or a Google Developers service account key file. If empty, fallback to Google default logic.")
+	f.StringVar(&cfg.KMSKeyName, prefix+"gcs.kms-key-name", "", "Name of the Cloud KMS key used to encrypt the objects written to the bucket (customer-managed encryption key), in the format projects/<project>/locations/<location>/keyRings/<key-ring>/cryptoKeys/<key>. If empty, the objects are encrypted with the default key of the bucket.")
+}
+
+// Validate config and returns error on failure
+func (cfg *Config) Validate() error {
+	return ValidateKMSKeyName(cfg.KMSKeyName)
+}
+
+// ValidateKMSKeyName returns an error if the KMS key name is set and not a valid Cloud KMS key name.
+func ValidateKMSKeyName(name string) error {
+	if name != "" && !kmsKeyNameRegexp.MatchString(name) {
+		return errInvalidKMSKeyName
+	}
+	return nil
 }
@@ -0,0 +1,34 @@
+package gcs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		kmsKeyName  string
+		expectedErr error
+	}{
+		"no KMS key name": {},
+		"valid KMS key name": {
+			kmsKeyName: "projects/test/locations/europe-west1/keyRings/cortex/cryptoKeys/blocks",
+		},
+		"KMS key name missing the key ring": {
+			kmsKeyName:  "projects/test/locations/europe-west1/cryptoKeys/blocks",
+			expectedErr: errInvalidKMSKeyName,
+		},
+		"KMS key version instead of KMS key name": {
+			kmsKeyName:  "projects/test/locations/europe-west1/keyRings/cortex/cryptoKeys/blocks/cryptoKeyVersions/1",
+			expectedErr: errInvalidKMSKeyName,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := Config{KMSKeyName: testData.kmsKeyName}
+			assert.Equal(t, testData.expectedErr, cfg.Validate())
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package bucket
+
+import (
+	"context"
+	"flag"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/objstore"
+)
+
+const (
+	// The number of latencies of an operation the hedging delay is computed from.
+	hedgingLatencyWindow = 1000
+
+	// The number of latencies observed between two computations of the hedging delay. The requests aren't
+	// hedged until the hedging delay has been computed once.
+	hedgingDelayComputeInterval = 100
+)
+
+var errInvalidHedgingQuantile = errors.New("invalid hedging quantile, it must be greater than 0 and less than 1")
+
+// HedgingConfig configures the hedging of the requests reading the objects.
+type HedgingConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Quantile float64       `yaml:"quantile"`
+	MinDelay time.Duration `yaml:"min_delay"`
+}
+
+// RegisterFlagsWithPrefix registers the flags for hedging with the provided prefix.
+func (cfg *HedgingConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+"hedging.enabled", false, "[Experimental] If enabled, a second request is sent to read an object if the first request hasn't responded after the hedging delay, and the first response is used. The hedging delay is the latency quantile of the recent requests reading the objects.")
+	f.Float64Var(&cfg.Quantile, prefix+"hedging.quantile", 0.9, "[Experimental] Quantile of the latency of the recent requests reading the objects used as hedging delay.")
+	f.DurationVar(&cfg.MinDelay, prefix+"hedging.min-delay", 50*time.Millisecond, "[Experimental] Minimum hedging delay.")
+}
+
+// Validate the config.
+func (cfg *HedgingConfig) Validate() error {
+	if cfg.Enabled && (cfg.Quantile <= 0 || cfg.Quantile >= 1) {
+		return errInvalidHedgingQuantile
+	}
+	return nil
+}
+
+// hedgedBucketClient is a bucket client hedging the requests reading the objects, to cut the tail latency
+// caused by the slow replicas of the storage.
+type hedgedBucketClient struct {
+	objstore.Bucket
+
+	cfg       HedgingConfig
+	latencies map[string]*latencyTracker
+
+	hedged    *prometheus.CounterVec
+	hedgedWon *prometheus.CounterVec
+}
+
+func newHedgedBucketClient(bkt objstore.Bucket, cfg HedgingConfig, reg prometheus.Registerer) *hedgedBucketClient {
+	return &hedgedBucketClient{
+		Bucket: bkt,
+		cfg:    cfg,
+		latencies: map[string]*latencyTracker{
+			objstore.OpGet:      newLatencyTracker(cfg.Quantile),
+			objstore.OpGetRange: newLatencyTracker(cfg.Quantile),
+		},
+		hedged: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_bucket_hedged_operations_total",
+			Help: "Total number of operations for which a hedged request has been sent.",
+		}, []string{"operation"}),
+		hedgedWon: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_bucket_hedged_operations_won_total",
+			Help: "Total number of operations for which the hedged request has responded first.",
+		}, []string{"operation"}),
+	}
+}
+
+// Get implements objstore.Bucket.
+func (b *hedgedBucketClient) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	return b.hedge(ctx, objstore.OpGet, func(ctx context.Context) (io.ReadCloser, error) {
+		return b.Bucket.Get(ctx, name)
+	})
+}
+
+// GetRange implements objstore.Bucket.
+func (b *hedgedBucketClient) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	return b.hedge(ctx, objstore.OpGetRange, func(ctx context.Context) (io.ReadCloser, error) {
+		return b.Bucket.GetRange(ctx, name, off, length)
+	})
+}
+
+type hedgedResult struct {
+	attempt int
+	r       io.ReadCloser
+	err     error
+}
+
+// hedge runs the request, and runs it again if it hasn't responded after the hedging delay of the operation. The
+// first successful response is returned, and the other request is canceled.
+func (b *hedgedBucketClient) hedge(ctx context.Context, op string, f func(ctx context.Context) (io.ReadCloser, error)) (io.ReadCloser, error) {
+	latencies := b.latencies[op]
+	delay, ok := latencies.delay()
+	if !ok {
+		start := time.Now()
+		r, err := f(ctx)
+		if err == nil {
+			latencies.observe(time.Since(start))
+		}
+		return r, err
+	}
+	if delay < b.cfg.MinDelay {
+		delay = b.cfg.MinDelay
+	}
+
+	results := make(chan hedgedResult, 2)
+	var cancels []context.CancelFunc
+	run := func() {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		attempt := len(cancels)
+		cancels = append(cancels, cancel)
+
+		go func() {
+			start := time.Now()
+			r, err := f(attemptCtx)
+			if err == nil {
+				latencies.observe(time.Since(start))
+			}
+			results <- hedgedResult{attempt: attempt, r: r, err: err}
+		}()
+	}
+
+	run()
+	pending := 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	timerC := timer.C
+
+	for {
+		select {
+		case <-timerC:
+			timerC = nil
+			b.hedged.WithLabelValues(op).Inc()
+			setSpanTag(ctx, "hedged", true)
+			run()
+			pending++
+
+		case res := <-results:
+			pending--
+			if res.err != nil && pending > 0 {
+				// Wait for the response of the other request.
+				cancels[res.attempt]()
+				continue
+			}
+			if res.err != nil {
+				for _, cancel := range cancels {
+					cancel()
+				}
+				return nil, res.err
+			}
+
+			// Cancel the other request, and release its response if it has already succeeded.
+			for attempt, cancel := range cancels {
+				if attempt != res.attempt {
+					cancel()
+				}
+			}
+			go func(pending int) {
+				for ; pending > 0; pending-- {
+					if other := <-results; other.err == nil {
+						_ = other.r.Close()
+					}
+				}
+			}(pending)
+
+			if res.attempt > 0 {
+				b.hedgedWon.WithLabelValues(op).Inc()
+				setSpanTag(ctx, "hedged_won", true)
+			}
+			return &cancelOnCloseReader{ReadCloser: res.r, cancel: cancels[res.attempt]}, nil
+		}
+	}
+}
+
+// cancelOnCloseReader cancels the context of the request reading the object once closed.
+type cancelOnCloseReader struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r *cancelOnCloseReader) Close() error {
+	defer r.cancel()
+	return r.ReadCloser.Close()
+}
+
+// latencyTracker tracks the recent latencies of an operation, and periodically computes their quantile.
+type latencyTracker struct {
+	quantile float64
+
+	mtx       sync.Mutex
+	latencies []time.Duration
+	next      int
+	observed  int
+	current   time.Duration
+}
+
+func newLatencyTracker(quantile float64) *latencyTracker {
+	return &latencyTracker{
+		quantile:  quantile,
+		latencies: make([]time.Duration, 0, hedgingLatencyWindow),
+	}
+}
+
+func (t *latencyTracker) observe(latency time.Duration) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if len(t.latencies) < hedgingLatencyWindow {
+		t.latencies = append(t.latencies, latency)
+	} else {
+		t.latencies[t.next] = latency
+	}
+	t.next = (t.next + 1) % hedgingLatencyWindow
+
+	t.observed++
+	if t.observed%hedgingDelayComputeInterval != 0 {
+		return
+	}
+
+	sorted := make([]time.Duration, len(t.latencies))
+	copy(sorted, t.latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	t.current = sorted[int(t.quantile*float64(len(sorted)-1))]
+}
+
+// delay returns the latency quantile, or false if it hasn't been computed yet.
+func (t *latencyTracker) delay() (time.Duration, bool) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	return t.current, t.observed >= hedgingDelayComputeInterval
+}
@@ -0,0 +1,131 @@
+package bucket
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+)
+
+func TestHedgingConfig_Validate(t *testing.T) {
+	assert.NoError(t, (&HedgingConfig{Enabled: false, Quantile: 0}).Validate())
+	assert.NoError(t, (&HedgingConfig{Enabled: true, Quantile: 0.9}).Validate())
+	assert.ErrorIs(t, (&HedgingConfig{Enabled: true, Quantile: 0}).Validate(), errInvalidHedgingQuantile)
+	assert.ErrorIs(t, (&HedgingConfig{Enabled: true, Quantile: 1}).Validate(), errInvalidHedgingQuantile)
+}
+
+func TestHedgedBucketClient(t *testing.T) {
+	ctx := context.Background()
+	inMem := objstore.NewInMemBucket()
+	require.NoError(t, inMem.Upload(ctx, "object", strings.NewReader("content")))
+
+	bkt := &slowBucket{Bucket: inMem}
+	reg := prometheus.NewPedanticRegistry()
+	client := newHedgedBucketClient(bkt, HedgingConfig{Enabled: true, Quantile: 0.9, MinDelay: 10 * time.Millisecond}, reg)
+
+	read := func() string {
+		r, err := client.Get(ctx, "object")
+		require.NoError(t, err)
+		defer r.Close()
+		b, err := io.ReadAll(r)
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	// The requests aren't hedged until the hedging delay has been computed.
+	for i := 0; i < hedgingDelayComputeInterval; i++ {
+		assert.Equal(t, "content", read())
+	}
+	assert.Equal(t, hedgingDelayComputeInterval, bkt.calls())
+
+	// A slow request is hedged, and the response of the hedged request is used.
+	bkt.setDelays(time.Minute)
+	assert.Equal(t, "content", read())
+	assert.Equal(t, hedgingDelayComputeInterval+2, bkt.calls())
+
+	// A fast request isn't hedged.
+	assert.Equal(t, "content", read())
+	assert.Equal(t, hedgingDelayComputeInterval+3, bkt.calls())
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_bucket_hedged_operations_total Total number of operations for which a hedged request has been sent.
+		# TYPE cortex_bucket_hedged_operations_total counter
+		cortex_bucket_hedged_operations_total{operation="get"} 1
+		# HELP cortex_bucket_hedged_operations_won_total Total number of operations for which the hedged request has responded first.
+		# TYPE cortex_bucket_hedged_operations_won_total counter
+		cortex_bucket_hedged_operations_won_total{operation="get"} 1
+	`), "cortex_bucket_hedged_operations_total", "cortex_bucket_hedged_operations_won_total"))
+
+	// The not found errors are returned.
+	_, err := client.Get(ctx, "missing")
+	assert.True(t, client.IsObjNotFoundErr(err))
+}
+
+func TestLatencyTracker(t *testing.T) {
+	tracker := newLatencyTracker(0.9)
+
+	for i := 1; i < hedgingDelayComputeInterval; i++ {
+		tracker.observe(time.Duration(i) * time.Millisecond)
+	}
+	_, ok := tracker.delay()
+	assert.False(t, ok)
+
+	tracker.observe(hedgingDelayComputeInterval * time.Millisecond)
+	delay, ok := tracker.delay()
+	assert.True(t, ok)
+	assert.Equal(t, 90*time.Millisecond, delay)
+
+	// The oldest latencies are evicted from the window.
+	for i := 0; i < hedgingLatencyWindow; i++ {
+		tracker.observe(time.Second)
+	}
+	delay, ok = tracker.delay()
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, delay)
+}
+
+// slowBucket is a bucket delaying the requests reading the objects by the next configured delays.
+type slowBucket struct {
+	objstore.Bucket
+
+	mtx     sync.Mutex
+	delays  []time.Duration
+	ncalled int
+}
+
+func (b *slowBucket) setDelays(delays ...time.Duration) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.delays = delays
+}
+
+func (b *slowBucket) calls() int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.ncalled
+}
+
+func (b *slowBucket) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	b.mtx.Lock()
+	b.ncalled++
+	var delay time.Duration
+	if len(b.delays) > 0 {
+		delay, b.delays = b.delays[0], b.delays[1:]
+	}
+	b.mtx.Unlock()
+
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return b.Bucket.Get(ctx, name)
+}
@@ -0,0 +1,27 @@
+package oss
+
+import (
+	"github.com/go-kit/log"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/objstore/providers/oss"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// NewBucketClient creates a new Alibaba Cloud OSS bucket client
+func NewBucketClient(cfg Config, name string, logger log.Logger) (objstore.Bucket, error) {
+	bucketConfig := oss.Config{
+		Endpoint:        cfg.Endpoint,
+		Bucket:          cfg.BucketName,
+		AccessKeyID:     cfg.AccessKeyID,
+		AccessKeySecret: cfg.AccessKeySecret.Value,
+	}
+
+	// Thanos currently doesn't support passing the config as is, but expects a YAML,
+	// so we're going to serialize it.
+	serialized, err := yaml.Marshal(bucketConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return oss.NewBucket(logger, serialized, name)
+}
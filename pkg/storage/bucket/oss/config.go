@@ -0,0 +1,46 @@
+package oss
+
+import (
+	"flag"
+
+	"github.com/pkg/errors"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+var (
+	errMissingEndpointOrBucket = errors.New("the Alibaba Cloud OSS endpoint and bucket name must be set")
+	errMissingAccessKey        = errors.New("the Alibaba Cloud OSS access key ID and secret must be set")
+)
+
+// Config holds the config options for an Alibaba Cloud OSS backend
+type Config struct {
+	Endpoint        string         `yaml:"endpoint"`
+	BucketName      string         `yaml:"bucket_name"`
+	AccessKeyID     string         `yaml:"access_key_id"`
+	AccessKeySecret flagext.Secret `yaml:"access_key_secret"`
+}
+
+// RegisterFlags registers the flags for Alibaba Cloud OSS storage
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	cfg.RegisterFlagsWithPrefix("", f)
+}
+
+// RegisterFlagsWithPrefix registers the flags for Alibaba Cloud OSS storage with the provided prefix
+func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Endpoint, prefix+"oss.endpoint", "", "Alibaba Cloud OSS endpoint to connect to, including the schema.")
+	f.StringVar(&cfg.BucketName, prefix+"oss.bucket-name", "", "Alibaba Cloud OSS bucket name.")
+	f.StringVar(&cfg.AccessKeyID, prefix+"oss.access-key-id", "", "Alibaba Cloud OSS access key ID.")
+	f.Var(&cfg.AccessKeySecret, prefix+"oss.access-key-secret", "Alibaba Cloud OSS access key secret.")
+}
+
+// Validate config and returns error on failure
+func (cfg *Config) Validate() error {
+	if cfg.Endpoint == "" || cfg.BucketName == "" {
+		return errMissingEndpointOrBucket
+	}
+	if cfg.AccessKeyID == "" || cfg.AccessKeySecret.Value == "" {
+		return errMissingAccessKey
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package oss
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		cfg         Config
+		expectedErr error
+	}{
+		"valid config": {
+			cfg: Config{Endpoint: "http://localhost", BucketName: "test", AccessKeyID: "xxx", AccessKeySecret: flagext.Secret{Value: "yyy"}},
+		},
+		"missing endpoint": {
+			cfg:         Config{BucketName: "test", AccessKeyID: "xxx", AccessKeySecret: flagext.Secret{Value: "yyy"}},
+			expectedErr: errMissingEndpointOrBucket,
+		},
+		"missing access key secret": {
+			cfg:         Config{Endpoint: "http://localhost", BucketName: "test", AccessKeyID: "xxx"},
+			expectedErr: errMissingAccessKey,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expectedErr, testData.cfg.Validate())
+		})
+	}
+}
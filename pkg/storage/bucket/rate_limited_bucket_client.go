@@ -0,0 +1,286 @@
+package bucket
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/objstore"
+	"golang.org/x/time/rate"
+)
+
+// RateLimitConfig configures the rate limits of the bucket operations of a component.
+type RateLimitConfig struct {
+	MaxOperationsPerSecond float64 `yaml:"max_operations_per_second"`
+	MaxBytesPerSecond      uint64  `yaml:"max_bytes_per_second"`
+}
+
+// RegisterFlagsWithPrefix registers the flags for the rate limits with the provided prefix.
+func (cfg *RateLimitConfig) RegisterFlagsWithPrefix(prefix, component string, f *flag.FlagSet) {
+	f.Float64Var(&cfg.MaxOperationsPerSecond, prefix+"max-operations-per-second", 0, "[Experimental] Maximum number of operations per second on the objects by the "+component+". The operations exceeding the limit wait, instead of failing. 0 to disable.")
+	f.Uint64Var(&cfg.MaxBytesPerSecond, prefix+"max-bytes-per-second", 0, "[Experimental] Maximum number of bytes per second read from and written to the objects by the "+component+". The reads and writes exceeding the limit wait, instead of failing. 0 to disable.")
+}
+
+func (cfg *RateLimitConfig) enabled() bool {
+	return cfg.MaxOperationsPerSecond > 0 || cfg.MaxBytesPerSecond > 0
+}
+
+// bucketRateLimiter limits the rate of the operations, and of the bytes transferred, of a bucket client.
+type bucketRateLimiter struct {
+	component string
+
+	// The limiters, nil if the limit is disabled.
+	operations *rate.Limiter
+	bytes      *rate.Limiter
+
+	waitDuration *prometheus.CounterVec
+}
+
+func (l *bucketRateLimiter) waitOperation(ctx context.Context) error {
+	if l.operations == nil {
+		return nil
+	}
+	return l.wait(ctx, l.operations, "operations", 1)
+}
+
+func (l *bucketRateLimiter) waitBytes(ctx context.Context, n int) error {
+	if l.bytes == nil || n <= 0 {
+		return nil
+	}
+	return l.wait(ctx, l.bytes, "bytes", n)
+}
+
+func (l *bucketRateLimiter) maxBytesPerWait() int {
+	return l.bytes.Burst()
+}
+
+func (l *bucketRateLimiter) wait(ctx context.Context, limiter *rate.Limiter, limit string, n int) error {
+	waited, err := waitRateLimit(ctx, limiter, n, "bucket_rate_limit_wait", opentracing.Tags{
+		"component": l.component,
+		"limit":     limit,
+		"events":    n,
+	})
+	l.waitDuration.WithLabelValues(limit).Add(waited.Seconds())
+	return err
+}
+
+// waitRateLimit waits until the limiter allows n events, and returns the time waited. The waits are traced in a
+// span with the given name and tags, to find the operations throttled by the rate limits in the traces of the
+// queries and jobs.
+func waitRateLimit(ctx context.Context, limiter *rate.Limiter, n int, spanName string, tags opentracing.Tags) (time.Duration, error) {
+	reservation := limiter.ReserveN(time.Now(), n)
+	if !reservation.OK() {
+		return 0, fmt.Errorf("%d events exceed the burst of the rate limit", n)
+	}
+	delay := reservation.Delay()
+	if delay == 0 {
+		return 0, nil
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, spanName, tags)
+	defer span.Finish()
+
+	start := time.Now()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return time.Since(start), nil
+	case <-ctx.Done():
+		reservation.Cancel()
+		return time.Since(start), ctx.Err()
+	}
+}
+
+// RateLimitedBucketClient is a bucket client limiting the rate of the operations, and of the bytes read and written,
+// so that the background jobs of a component don't exceed the request rate limits of the storage provider. The
+// operations exceeding the limits wait until they're allowed.
+type RateLimitedBucketClient struct {
+	bucket  objstore.Bucket
+	limiter *bucketRateLimiter
+}
+
+// NewRateLimitedBucketClient returns the bucket client wrapped with the rate limits of the config, or the bucket
+// client itself if the rate limits are disabled.
+func NewRateLimitedBucketClient(bkt objstore.InstrumentedBucket, cfg RateLimitConfig, name string, reg prometheus.Registerer) objstore.InstrumentedBucket {
+	if !cfg.enabled() {
+		return bkt
+	}
+
+	if reg != nil {
+		reg = prometheus.WrapRegistererWith(prometheus.Labels{"component": name}, reg)
+	}
+	limiter := &bucketRateLimiter{
+		component: name,
+		waitDuration: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_bucket_rate_limit_wait_seconds_total",
+			Help: "Total time spent waiting for the rate limits of the operations on the objects.",
+		}, []string{"limit"}),
+	}
+	if cfg.MaxOperationsPerSecond > 0 {
+		limiter.operations = rate.NewLimiter(rate.Limit(cfg.MaxOperationsPerSecond), int(math.Max(1, math.Ceil(cfg.MaxOperationsPerSecond))))
+	}
+	if cfg.MaxBytesPerSecond > 0 {
+		// The burst is the bytes transferred in a second, so that the reads and writes are at most a second long.
+		limiter.bytes = rate.NewLimiter(rate.Limit(cfg.MaxBytesPerSecond), int(min(cfg.MaxBytesPerSecond, math.MaxInt32)))
+	}
+
+	return &RateLimitedBucketClient{bucket: bkt, limiter: limiter}
+}
+
+// Close implements io.Closer.
+func (b *RateLimitedBucketClient) Close() error {
+	return b.bucket.Close()
+}
+
+// Name implements objstore.Bucket.
+func (b *RateLimitedBucketClient) Name() string {
+	return b.bucket.Name()
+}
+
+// Upload implements objstore.Bucket.
+func (b *RateLimitedBucketClient) Upload(ctx context.Context, name string, r io.Reader) error {
+	if err := b.limiter.waitOperation(ctx); err != nil {
+		return err
+	}
+	if b.limiter.bytes != nil {
+		r = &rateLimitedReader{Reader: r, ctx: ctx, limiter: b.limiter}
+	}
+	return b.bucket.Upload(ctx, name, r)
+}
+
+// Delete implements objstore.Bucket.
+func (b *RateLimitedBucketClient) Delete(ctx context.Context, name string) error {
+	if err := b.limiter.waitOperation(ctx); err != nil {
+		return err
+	}
+	return b.bucket.Delete(ctx, name)
+}
+
+// Iter implements objstore.Bucket.
+func (b *RateLimitedBucketClient) Iter(ctx context.Context, dir string, f func(string) error, options ...objstore.IterOption) error {
+	if err := b.limiter.waitOperation(ctx); err != nil {
+		return err
+	}
+	return b.bucket.Iter(ctx, dir, f, options...)
+}
+
+// Get implements objstore.Bucket.
+func (b *RateLimitedBucketClient) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	if err := b.limiter.waitOperation(ctx); err != nil {
+		return nil, err
+	}
+	r, err := b.bucket.Get(ctx, name)
+	return b.wrapReader(ctx, r, err)
+}
+
+// GetRange implements objstore.Bucket.
+func (b *RateLimitedBucketClient) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	if err := b.limiter.waitOperation(ctx); err != nil {
+		return nil, err
+	}
+	r, err := b.bucket.GetRange(ctx, name, off, length)
+	return b.wrapReader(ctx, r, err)
+}
+
+func (b *RateLimitedBucketClient) wrapReader(ctx context.Context, r io.ReadCloser, err error) (io.ReadCloser, error) {
+	if err != nil || b.limiter.bytes == nil {
+		return r, err
+	}
+	return &rateLimitedReadCloser{
+		rateLimitedReader: rateLimitedReader{Reader: r, ctx: ctx, limiter: b.limiter},
+		closer:            r,
+	}, nil
+}
+
+// Exists implements objstore.Bucket.
+func (b *RateLimitedBucketClient) Exists(ctx context.Context, name string) (bool, error) {
+	if err := b.limiter.waitOperation(ctx); err != nil {
+		return false, err
+	}
+	return b.bucket.Exists(ctx, name)
+}
+
+// Attributes implements objstore.Bucket.
+func (b *RateLimitedBucketClient) Attributes(ctx context.Context, name string) (objstore.ObjectAttributes, error) {
+	if err := b.limiter.waitOperation(ctx); err != nil {
+		return objstore.ObjectAttributes{}, err
+	}
+	return b.bucket.Attributes(ctx, name)
+}
+
+// IsObjNotFoundErr implements objstore.Bucket.
+func (b *RateLimitedBucketClient) IsObjNotFoundErr(err error) bool {
+	return b.bucket.IsObjNotFoundErr(err)
+}
+
+// IsAccessDeniedErr implements objstore.Bucket.
+func (b *RateLimitedBucketClient) IsAccessDeniedErr(err error) bool {
+	return b.bucket.IsAccessDeniedErr(err)
+}
+
+// ReaderWithExpectedErrs implements objstore.InstrumentedBucket.
+func (b *RateLimitedBucketClient) ReaderWithExpectedErrs(fn objstore.IsOpFailureExpectedFunc) objstore.BucketReader {
+	return b.WithExpectedErrs(fn)
+}
+
+// WithExpectedErrs implements objstore.InstrumentedBucket.
+func (b *RateLimitedBucketClient) WithExpectedErrs(fn objstore.IsOpFailureExpectedFunc) objstore.Bucket {
+	if ib, ok := b.bucket.(objstore.InstrumentedBucket); ok {
+		return &RateLimitedBucketClient{
+			bucket:  ib.WithExpectedErrs(fn),
+			limiter: b.limiter,
+		}
+	}
+	return b
+}
+
+// bytesLimiter limits the rate of the bytes read and written.
+type bytesLimiter interface {
+	// maxBytesPerWait returns the maximum number of bytes waitBytes can wait for at once.
+	maxBytesPerWait() int
+
+	// waitBytes waits until n bytes are allowed.
+	waitBytes(ctx context.Context, n int) error
+}
+
+// rateLimitedReader limits the rate of the bytes read from the wrapped reader.
+type rateLimitedReader struct {
+	io.Reader
+
+	ctx     context.Context
+	limiter bytesLimiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if maxBytes := r.limiter.maxBytesPerWait(); len(p) > maxBytes {
+		p = p[:maxBytes]
+	}
+
+	n, err := r.Reader.Read(p)
+	if waitErr := r.limiter.waitBytes(r.ctx, n); waitErr != nil && err == nil {
+		err = waitErr
+	}
+	return n, err
+}
+
+// ObjectSize implements objstore.ObjectSizer.
+func (r *rateLimitedReader) ObjectSize() (int64, error) {
+	return objstore.TryToGetSize(r.Reader)
+}
+
+type rateLimitedReadCloser struct {
+	rateLimitedReader
+	closer io.Closer
+}
+
+func (r *rateLimitedReadCloser) Close() error {
+	return r.closer.Close()
+}
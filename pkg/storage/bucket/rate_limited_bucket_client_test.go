@@ -0,0 +1,64 @@
+package bucket
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+)
+
+func TestNewRateLimitedBucketClient_ShouldNotWrapTheBucketIfDisabled(t *testing.T) {
+	bkt := objstore.WithNoopInstr(objstore.NewInMemBucket())
+	assert.Equal(t, bkt, NewRateLimitedBucketClient(bkt, RateLimitConfig{}, "test", nil))
+}
+
+func TestRateLimitedBucketClient_ShouldLimitTheOperations(t *testing.T) {
+	ctx := context.Background()
+	client := NewRateLimitedBucketClient(objstore.WithNoopInstr(objstore.NewInMemBucket()), RateLimitConfig{MaxOperationsPerSecond: 20}, "test", prometheus.NewPedanticRegistry())
+
+	// The burst is allowed right away, and the following operations are limited.
+	start := time.Now()
+	for i := 0; i < 30; i++ {
+		_, err := client.Exists(ctx, "object")
+		require.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+
+	// The operations waiting for the limit fail once the context is canceled.
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err := client.Exists(canceledCtx, "object")
+	assert.Error(t, err)
+}
+
+func TestRateLimitedBucketClient_ShouldLimitTheBytes(t *testing.T) {
+	ctx := context.Background()
+	content := strings.Repeat("x", 1500)
+	client := NewRateLimitedBucketClient(objstore.WithNoopInstr(objstore.NewInMemBucket()), RateLimitConfig{MaxBytesPerSecond: 1000}, "test", prometheus.NewPedanticRegistry())
+
+	// The upload exceeding the burst is limited.
+	start := time.Now()
+	require.NoError(t, client.Upload(ctx, "object", strings.NewReader(content)))
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+
+	// The read exceeding the remaining bytes is limited, and returns the whole object.
+	start = time.Now()
+	r, err := client.Get(ctx, "object")
+	require.NoError(t, err)
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, content, string(b))
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+
+	// The size of the uploaded readers is still known.
+	size, err := objstore.TryToGetSize(&rateLimitedReader{Reader: strings.NewReader(content)})
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), size)
+}
@@ -0,0 +1,178 @@
+package bucket
+
+import (
+	"context"
+	"flag"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/objstore"
+
+	"github.com/cortexproject/cortex/pkg/util/backoff"
+)
+
+// The maximum, and initial, number of retries of the retry budget, allowing to retry the requests after a
+// period without requests.
+const retryBudgetMaxTokens = 10
+
+var errInvalidRetryBudgetRatio = errors.New("invalid retry budget ratio, it must be greater than 0")
+
+// RetriesConfig configures the retries of the requests reading the objects.
+type RetriesConfig struct {
+	MaxRetries  int           `yaml:"max_retries"`
+	BudgetRatio float64       `yaml:"budget_ratio"`
+	MinBackoff  time.Duration `yaml:"min_backoff"`
+	MaxBackoff  time.Duration `yaml:"max_backoff"`
+}
+
+// RegisterFlagsWithPrefix registers the flags for retries with the provided prefix.
+func (cfg *RetriesConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.IntVar(&cfg.MaxRetries, prefix+"retries.max-retries", 0, "[Experimental] Maximum number of retries of the failed requests reading the objects, 0 to disable the retries. The requests failing because the object doesn't exist or the access is denied aren't retried.")
+	f.Float64Var(&cfg.BudgetRatio, prefix+"retries.budget-ratio", 0.1, "[Experimental] Maximum ratio of the retries to the requests reading the objects. The retries beyond the budget aren't attempted, so that the retries don't overload a degraded storage.")
+	f.DurationVar(&cfg.MinBackoff, prefix+"retries.min-backoff", 100*time.Millisecond, "[Experimental] Minimum backoff between the retries.")
+	f.DurationVar(&cfg.MaxBackoff, prefix+"retries.max-backoff", time.Second, "[Experimental] Maximum backoff between the retries.")
+}
+
+// Validate the config.
+func (cfg *RetriesConfig) Validate() error {
+	if cfg.MaxRetries > 0 && cfg.BudgetRatio <= 0 {
+		return errInvalidRetryBudgetRatio
+	}
+	return nil
+}
+
+// retryingBucketClient is a bucket client retrying the failed requests reading the objects, within a retry
+// budget adapting the retries to the number of requests.
+type retryingBucketClient struct {
+	objstore.Bucket
+
+	cfg    RetriesConfig
+	budget *retryBudget
+
+	retries         *prometheus.CounterVec
+	budgetExhausted *prometheus.CounterVec
+}
+
+func newRetryingBucketClient(bkt objstore.Bucket, cfg RetriesConfig, reg prometheus.Registerer) *retryingBucketClient {
+	return &retryingBucketClient{
+		Bucket: bkt,
+		cfg:    cfg,
+		budget: &retryBudget{ratio: cfg.BudgetRatio, tokens: retryBudgetMaxTokens},
+		retries: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_bucket_operation_retries_total",
+			Help: "Total number of retries of the failed operations.",
+		}, []string{"operation"}),
+		budgetExhausted: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_bucket_operation_retries_budget_exhausted_total",
+			Help: "Total number of retries of the failed operations not attempted because the retry budget was exhausted.",
+		}, []string{"operation"}),
+	}
+}
+
+// Get implements objstore.Bucket.
+func (b *retryingBucketClient) Get(ctx context.Context, name string) (r io.ReadCloser, err error) {
+	err = b.retry(ctx, objstore.OpGet, func() error {
+		r, err = b.Bucket.Get(ctx, name)
+		return err
+	})
+	return r, err
+}
+
+// GetRange implements objstore.Bucket.
+func (b *retryingBucketClient) GetRange(ctx context.Context, name string, off, length int64) (r io.ReadCloser, err error) {
+	err = b.retry(ctx, objstore.OpGetRange, func() error {
+		r, err = b.Bucket.GetRange(ctx, name, off, length)
+		return err
+	})
+	return r, err
+}
+
+// Exists implements objstore.Bucket.
+func (b *retryingBucketClient) Exists(ctx context.Context, name string) (exists bool, err error) {
+	err = b.retry(ctx, objstore.OpExists, func() error {
+		exists, err = b.Bucket.Exists(ctx, name)
+		return err
+	})
+	return exists, err
+}
+
+// Attributes implements objstore.Bucket.
+func (b *retryingBucketClient) Attributes(ctx context.Context, name string) (attrs objstore.ObjectAttributes, err error) {
+	err = b.retry(ctx, objstore.OpAttributes, func() error {
+		attrs, err = b.Bucket.Attributes(ctx, name)
+		return err
+	})
+	return attrs, err
+}
+
+// retry runs the request, and retries it while it fails, up to the max retries and within the retry budget. The
+// attempts are tagged on the span of the operation.
+func (b *retryingBucketClient) retry(ctx context.Context, op string, f func() error) error {
+	b.budget.deposit()
+
+	retries := backoff.New(ctx, backoff.Config{
+		MinBackoff: b.cfg.MinBackoff,
+		MaxBackoff: b.cfg.MaxBackoff,
+		MaxRetries: b.cfg.MaxRetries,
+	})
+	for attempt := 1; ; attempt++ {
+		setSpanTag(ctx, "attempts", attempt)
+
+		err := f()
+		if err == nil || !b.isRetryable(ctx, err) {
+			return err
+		}
+
+		if !retries.Ongoing() {
+			return err
+		}
+		if !b.budget.withdraw() {
+			b.budgetExhausted.WithLabelValues(op).Inc()
+			return err
+		}
+
+		retries.Wait()
+		if ctx.Err() != nil {
+			return err
+		}
+		b.retries.WithLabelValues(op).Inc()
+	}
+}
+
+func (b *retryingBucketClient) isRetryable(ctx context.Context, err error) bool {
+	return ctx.Err() == nil && !b.IsObjNotFoundErr(err) && !b.IsAccessDeniedErr(err)
+}
+
+// retryBudget is a token bucket, to which each request deposits the retry budget ratio, and from which each
+// retry withdraws a token.
+type retryBudget struct {
+	ratio float64
+
+	mtx    sync.Mutex
+	tokens float64
+}
+
+func (b *retryBudget) deposit() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.tokens += b.ratio
+	if b.tokens > retryBudgetMaxTokens {
+		b.tokens = retryBudgetMaxTokens
+	}
+}
+
+func (b *retryBudget) withdraw() bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,124 @@
+package bucket
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+)
+
+func TestRetriesConfig_Validate(t *testing.T) {
+	assert.NoError(t, (&RetriesConfig{MaxRetries: 0, BudgetRatio: 0}).Validate())
+	assert.NoError(t, (&RetriesConfig{MaxRetries: 3, BudgetRatio: 0.1}).Validate())
+	assert.ErrorIs(t, (&RetriesConfig{MaxRetries: 3, BudgetRatio: 0}).Validate(), errInvalidRetryBudgetRatio)
+}
+
+func TestRetryingBucketClient(t *testing.T) {
+	ctx := context.Background()
+	inMem := objstore.NewInMemBucket()
+	require.NoError(t, inMem.Upload(ctx, "object", strings.NewReader("content")))
+
+	bkt := &failingBucket{Bucket: inMem}
+	reg := prometheus.NewPedanticRegistry()
+	client := newRetryingBucketClient(bkt, RetriesConfig{MaxRetries: 2, BudgetRatio: 0.1, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}, reg)
+
+	// A request failing less than the max retries succeeds.
+	bkt.failures = 2
+	r, err := client.Get(ctx, "object")
+	require.NoError(t, err)
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, "content", string(b))
+	assert.Equal(t, 3, bkt.calls)
+
+	// A request failing more than the max retries fails.
+	bkt.failures, bkt.calls = 3, 0
+	_, err = client.Exists(ctx, "object")
+	assert.ErrorIs(t, err, errTransient)
+	assert.Equal(t, 3, bkt.calls)
+
+	// The not found errors aren't retried.
+	bkt.failures, bkt.calls = 0, 0
+	_, err = client.Get(ctx, "missing")
+	assert.True(t, client.IsObjNotFoundErr(err))
+	assert.Equal(t, 1, bkt.calls)
+
+	// The retries aren't attempted once the retry budget is exhausted.
+	bkt.failures, bkt.calls = 100, 0
+	client.budget.tokens = 1
+	_, err = client.Attributes(ctx, "object")
+	assert.ErrorIs(t, err, errTransient)
+	assert.Equal(t, 2, bkt.calls)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_bucket_operation_retries_budget_exhausted_total Total number of retries of the failed operations not attempted because the retry budget was exhausted.
+		# TYPE cortex_bucket_operation_retries_budget_exhausted_total counter
+		cortex_bucket_operation_retries_budget_exhausted_total{operation="attributes"} 1
+		# HELP cortex_bucket_operation_retries_total Total number of retries of the failed operations.
+		# TYPE cortex_bucket_operation_retries_total counter
+		cortex_bucket_operation_retries_total{operation="attributes"} 1
+		cortex_bucket_operation_retries_total{operation="exists"} 2
+		cortex_bucket_operation_retries_total{operation="get"} 2
+	`), "cortex_bucket_operation_retries_total", "cortex_bucket_operation_retries_budget_exhausted_total"))
+}
+
+func TestRetryingBucketClient_ShouldNotRetryOnCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	bkt := &failingBucket{Bucket: objstore.NewInMemBucket(), failures: 100}
+	client := newRetryingBucketClient(bkt, RetriesConfig{MaxRetries: 2, BudgetRatio: 0.1, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}, nil)
+
+	_, err := client.Get(ctx, "object")
+	assert.ErrorIs(t, err, errTransient)
+	assert.Equal(t, 1, bkt.calls)
+}
+
+var errTransient = errors.New("transient error")
+
+// failingBucket is a bucket failing the next configured number of requests reading the objects.
+type failingBucket struct {
+	objstore.Bucket
+
+	failures int
+	calls    int
+}
+
+func (b *failingBucket) fail() error {
+	b.calls++
+	if b.failures > 0 {
+		b.failures--
+		return errTransient
+	}
+	return nil
+}
+
+func (b *failingBucket) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	if err := b.fail(); err != nil {
+		return nil, err
+	}
+	return b.Bucket.Get(ctx, name)
+}
+
+func (b *failingBucket) Exists(ctx context.Context, name string) (bool, error) {
+	if err := b.fail(); err != nil {
+		return false, err
+	}
+	return b.Bucket.Exists(ctx, name)
+}
+
+func (b *failingBucket) Attributes(ctx context.Context, name string) (objstore.ObjectAttributes, error) {
+	if err := b.fail(); err != nil {
+		return objstore.ObjectAttributes{}, err
+	}
+	return b.Bucket.Attributes(ctx, name)
+}
@@ -0,0 +1,186 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/objstore/exthttp"
+	"github.com/thanos-io/objstore/providers/s3"
+)
+
+// The credentials of the assumed role are refreshed assumeRoleExpiryWindow before they expire, so that the
+// requests in flight don't fail.
+const assumeRoleExpiryWindow = 5 * time.Minute
+
+// assumeRoleBucket is a bucket client authenticated with the temporary credentials of an assumed IAM role. The
+// bucket client is recreated each time the credentials are refreshed.
+type assumeRoleBucket struct {
+	logger  log.Logger
+	name    string
+	roleARN string
+	cfg     s3.Config
+	creds   aws.CredentialsProvider
+
+	mtx         sync.Mutex
+	bucket      *s3.Bucket
+	bucketCreds aws.Credentials
+}
+
+func newAssumeRoleBucket(cfg Config, s3Cfg s3.Config, name string, logger log.Logger) (*assumeRoleBucket, error) {
+	// The role is assumed with the configured credentials, or else the default AWS credentials.
+	opts := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(cfg.Region)}
+	if cfg.AccessKeyID != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey.Value, "")))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "load AWS SDK config")
+	}
+
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(awsCfg), cfg.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = "cortex-" + name
+		if cfg.RoleExternalID != "" {
+			o.ExternalID = aws.String(cfg.RoleExternalID)
+		}
+	})
+
+	// The transport is shared by the recreated bucket clients, to keep the connections.
+	if s3Cfg.HTTPConfig.Transport == nil {
+		s3Cfg.HTTPConfig.Transport, err = exthttp.DefaultTransport(s3Cfg.HTTPConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	s3Cfg.AWSSDKAuth = false
+
+	return &assumeRoleBucket{
+		logger:  logger,
+		name:    name,
+		roleARN: cfg.RoleARN,
+		cfg:     s3Cfg,
+		creds: aws.NewCredentialsCache(provider, func(o *aws.CredentialsCacheOptions) {
+			o.ExpiryWindow = assumeRoleExpiryWindow
+		}),
+	}, nil
+}
+
+// current returns the bucket client authenticated with the current credentials of the assumed role.
+func (b *assumeRoleBucket) current(ctx context.Context) (*s3.Bucket, error) {
+	creds, err := b.creds.Retrieve(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "assume role %s", b.roleARN)
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.bucket != nil && creds.AccessKeyID == b.bucketCreds.AccessKeyID && creds.SessionToken == b.bucketCreds.SessionToken {
+		return b.bucket, nil
+	}
+
+	cfg := b.cfg
+	cfg.AccessKey = creds.AccessKeyID
+	cfg.SecretKey = creds.SecretAccessKey
+	cfg.SessionToken = creds.SessionToken
+
+	bucket, err := s3.NewBucketWithConfig(b.logger, cfg, b.name)
+	if err != nil {
+		return nil, err
+	}
+	b.bucket, b.bucketCreds = bucket, creds
+	return bucket, nil
+}
+
+// Name implements objstore.Bucket.
+func (b *assumeRoleBucket) Name() string {
+	return b.cfg.Bucket
+}
+
+// Iter implements objstore.Bucket.
+func (b *assumeRoleBucket) Iter(ctx context.Context, dir string, f func(string) error, options ...objstore.IterOption) error {
+	bucket, err := b.current(ctx)
+	if err != nil {
+		return err
+	}
+	return bucket.Iter(ctx, dir, f, options...)
+}
+
+// Get implements objstore.Bucket.
+func (b *assumeRoleBucket) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	bucket, err := b.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return bucket.Get(ctx, name)
+}
+
+// GetRange implements objstore.Bucket.
+func (b *assumeRoleBucket) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	bucket, err := b.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return bucket.GetRange(ctx, name, off, length)
+}
+
+// Exists implements objstore.Bucket.
+func (b *assumeRoleBucket) Exists(ctx context.Context, name string) (bool, error) {
+	bucket, err := b.current(ctx)
+	if err != nil {
+		return false, err
+	}
+	return bucket.Exists(ctx, name)
+}
+
+// Upload implements objstore.Bucket.
+func (b *assumeRoleBucket) Upload(ctx context.Context, name string, r io.Reader) error {
+	bucket, err := b.current(ctx)
+	if err != nil {
+		return err
+	}
+	return bucket.Upload(ctx, name, r)
+}
+
+// Attributes implements objstore.Bucket.
+func (b *assumeRoleBucket) Attributes(ctx context.Context, name string) (objstore.ObjectAttributes, error) {
+	bucket, err := b.current(ctx)
+	if err != nil {
+		return objstore.ObjectAttributes{}, err
+	}
+	return bucket.Attributes(ctx, name)
+}
+
+// Delete implements objstore.Bucket.
+func (b *assumeRoleBucket) Delete(ctx context.Context, name string) error {
+	bucket, err := b.current(ctx)
+	if err != nil {
+		return err
+	}
+	return bucket.Delete(ctx, name)
+}
+
+// IsObjNotFoundErr implements objstore.Bucket. The S3 bucket client doesn't depend on its credentials to classify
+// the errors.
+func (b *assumeRoleBucket) IsObjNotFoundErr(err error) bool {
+	return (&s3.Bucket{}).IsObjNotFoundErr(err)
+}
+
+// IsAccessDeniedErr implements objstore.Bucket.
+func (b *assumeRoleBucket) IsAccessDeniedErr(err error) bool {
+	return (&s3.Bucket{}).IsAccessDeniedErr(err)
+}
+
+// Close implements objstore.Bucket.
+func (b *assumeRoleBucket) Close() error {
+	return nil
+}
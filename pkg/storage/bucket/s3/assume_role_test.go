@@ -0,0 +1,83 @@
+package s3
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+func TestAssumeRoleBucket_ShouldUseTheRefreshedCredentials(t *testing.T) {
+	var (
+		mtx    sync.Mutex
+		tokens []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		tokens = append(tokens, r.Header.Get("X-Amz-Security-Token"))
+		mtx.Unlock()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := Config{}
+	flagext.DefaultValues(&cfg)
+	cfg.Endpoint = strings.TrimPrefix(srv.URL, "http://")
+	cfg.Insecure = true
+	cfg.BucketName = "test"
+	cfg.Region = "us-east-1"
+	cfg.RoleARN = "arn:aws:iam::123456789012:role/tenant-a"
+
+	s3Cfg, err := newS3Config(cfg)
+	require.NoError(t, err)
+	bkt, err := newAssumeRoleBucket(cfg, s3Cfg, "test", log.NewNopLogger())
+	require.NoError(t, err)
+
+	creds := &mockCredentialsProvider{creds: aws.Credentials{AccessKeyID: "key-1", SecretAccessKey: "secret-1", SessionToken: "token-1"}}
+	bkt.creds = creds
+
+	for i := 0; i < 2; i++ {
+		_, err = bkt.Exists(context.Background(), "object")
+		require.NoError(t, err)
+	}
+
+	// The bucket client is recreated once the credentials are refreshed.
+	first, err := bkt.current(context.Background())
+	require.NoError(t, err)
+	creds.set(aws.Credentials{AccessKeyID: "key-2", SecretAccessKey: "secret-2", SessionToken: "token-2"})
+	_, err = bkt.Exists(context.Background(), "object")
+	require.NoError(t, err)
+	second, err := bkt.current(context.Background())
+	require.NoError(t, err)
+	assert.NotSame(t, first, second)
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	assert.Equal(t, []string{"token-1", "token-1", "token-2"}, tokens)
+}
+
+type mockCredentialsProvider struct {
+	mtx   sync.Mutex
+	creds aws.Credentials
+}
+
+func (p *mockCredentialsProvider) set(creds aws.Credentials) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.creds = creds
+}
+
+func (p *mockCredentialsProvider) Retrieve(context.Context) (aws.Credentials, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.creds, nil
+}
@@ -26,7 +26,7 @@ func NewBucketClient(cfg Config, name string, logger log.Logger) (objstore.Bucke
 		return nil, err
 	}
 
-	bucket, err := s3.NewBucketWithConfig(logger, s3Cfg, name)
+	bucket, err := newBucket(cfg, s3Cfg, name, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -46,7 +46,7 @@ func NewBucketReaderClient(cfg Config, name string, logger log.Logger) (objstore
 		return nil, err
 	}
 
-	bucket, err := s3.NewBucketWithConfig(logger, s3Cfg, name)
+	bucket, err := newBucket(cfg, s3Cfg, name, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -70,6 +70,24 @@ func NewBucketWithRetries(bucket objstore.Bucket, operationRetries int, retryMin
 	}, nil
 }
 
+// newBucket creates the S3 bucket, authenticated with the assumed role if configured, and uploading the blocks with
+// the object lock retention if configured.
+func newBucket(cfg Config, s3Cfg s3.Config, name string, logger log.Logger) (objstore.Bucket, error) {
+	var (
+		bucket objstore.Bucket
+		err    error
+	)
+	if cfg.RoleARN != "" {
+		bucket, err = newAssumeRoleBucket(cfg, s3Cfg, name, logger)
+	} else {
+		bucket, err = s3.NewBucketWithConfig(logger, s3Cfg, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newObjectLockBucket(bucket, cfg)
+}
+
 func newS3Config(cfg Config) (s3.Config, error) {
 	sseCfg, err := cfg.SSE.BuildThanosConfig()
 	if err != nil {
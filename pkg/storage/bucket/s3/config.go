@@ -41,6 +41,8 @@ var (
 	errUnsupportedSSEType          = errors.New("unsupported S3 SSE type")
 	errInvalidSSEContext           = errors.New("invalid S3 SSE encryption context")
 	errInvalidBucketLookupType     = errors.New("invalid bucket lookup type")
+	errInvalidRoleARN              = errors.New("invalid S3 role ARN")
+	errRoleWithSignatureV2         = errors.New("the S3 role can't be assumed with the signature version v2")
 )
 
 // HTTPConfig stores the http.Transport configuration for the s3 minio client.
@@ -67,9 +69,13 @@ type Config struct {
 	SignatureVersion string         `yaml:"signature_version"`
 	BucketLookupType string         `yaml:"bucket_lookup_type"`
 	SendContentMd5   bool           `yaml:"send_content_md5"`
+	RoleARN          string         `yaml:"role_arn"`
+	RoleExternalID   string         `yaml:"role_external_id"`
 
 	SSE  SSEConfig  `yaml:"sse"`
 	HTTP HTTPConfig `yaml:"http"`
+
+	ObjectLock ObjectLockConfig `yaml:"object_lock"`
 }
 
 // RegisterFlags registers the flags for s3 storage with the provided prefix
@@ -88,8 +94,11 @@ func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	f.StringVar(&cfg.SignatureVersion, prefix+"s3.signature-version", SignatureVersionV4, fmt.Sprintf("The signature version to use for authenticating against S3. Supported values are: %s.", strings.Join(supportedSignatureVersions, ", ")))
 	f.StringVar(&cfg.BucketLookupType, prefix+"s3.bucket-lookup-type", BucketAutoLookup, fmt.Sprintf("The s3 bucket lookup style. Supported values are: %s.", strings.Join(supportedBucketLookupTypes, ", ")))
 	f.BoolVar(&cfg.SendContentMd5, prefix+"s3.send-content-md5", true, "If true, attach MD5 checksum when upload objects and S3 uses MD5 checksum algorithm to verify the provided digest. If false, use CRC32C algorithm instead.")
+	f.StringVar(&cfg.RoleARN, prefix+"s3.role-arn", "", "ARN of the IAM role to assume with AWS STS to access the bucket. The role is assumed with the configured access key, or else the default AWS credentials, and its temporary credentials are refreshed before they expire.")
+	f.StringVar(&cfg.RoleExternalID, prefix+"s3.role-external-id", "", "External ID required by the trust policy of the IAM role to assume.")
 	cfg.SSE.RegisterFlagsWithPrefix(prefix+"s3.sse.", f)
 	cfg.HTTP.RegisterFlagsWithPrefix(prefix, f)
+	cfg.ObjectLock.RegisterFlagsWithPrefix(prefix+"s3.object-lock.", f)
 }
 
 // Validate config and returns error on failure
@@ -101,10 +110,23 @@ func (cfg *Config) Validate() error {
 		return errInvalidBucketLookupType
 	}
 
+	if cfg.RoleARN != "" {
+		if !strings.HasPrefix(cfg.RoleARN, "arn:") {
+			return errInvalidRoleARN
+		}
+		if cfg.SignatureVersion == SignatureVersionV2 {
+			return errRoleWithSignatureV2
+		}
+	}
+
 	if err := cfg.SSE.Validate(); err != nil {
 		return err
 	}
 
+	if err := cfg.ObjectLock.Validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -152,6 +174,21 @@ func (cfg *SSEConfig) Validate() error {
 	return nil
 }
 
+// ValidateSSEConfigOverride validates the per-tenant SSE config override, which is ignored if the
+// SSE type override is not set.
+func ValidateSSEConfigOverride(sseType, kmsKeyID, kmsEncryptionContext string) error {
+	if sseType == "" {
+		return nil
+	}
+
+	cfg := SSEConfig{
+		Type:                 sseType,
+		KMSKeyID:             kmsKeyID,
+		KMSEncryptionContext: kmsEncryptionContext,
+	}
+	return cfg.Validate()
+}
+
 // BuildThanosConfig builds the SSE config expected by the Thanos client.
 func (cfg *SSEConfig) BuildThanosConfig() (s3.SSEConfig, error) {
 	switch cfg.Type {
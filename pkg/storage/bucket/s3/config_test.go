@@ -56,6 +56,8 @@ access_key_id: test-access-key-id
 insecure: true
 signature_version: test-signature-version
 bucket_lookup_type: virtual-hosted
+role_arn: test-role-arn
+role_external_id: test-role-external-id
 sse:
   type: test-type
   kms_key_id: test-kms-key-id
@@ -69,6 +71,9 @@ http:
   max_idle_connections: 6
   max_idle_connections_per_host: 7
   max_connections_per_host: 8
+object_lock:
+  mode: COMPLIANCE
+  retention_period: 720h
 `,
 			expectedConfig: Config{
 				Endpoint:         "test-endpoint",
@@ -80,6 +85,8 @@ http:
 				SignatureVersion: "test-signature-version",
 				BucketLookupType: BucketVirtualHostLookup,
 				SendContentMd5:   true,
+				RoleARN:          "test-role-arn",
+				RoleExternalID:   "test-role-external-id",
 				SSE: SSEConfig{
 					Type:                 "test-type",
 					KMSKeyID:             "test-kms-key-id",
@@ -97,6 +104,10 @@ http:
 						MaxConnsPerHost:       8,
 					},
 				},
+				ObjectLock: ObjectLockConfig{
+					Mode:            "COMPLIANCE",
+					RetentionPeriod: 720 * time.Hour,
+				},
 			},
 			expectedErr: nil,
 		},
@@ -121,6 +132,22 @@ http:
 	}
 }
 
+func TestConfig_Validate_RoleARN(t *testing.T) {
+	cfg := Config{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.Validate())
+
+	cfg.RoleARN = "arn:aws:iam::123456789012:role/tenant-a"
+	require.NoError(t, cfg.Validate())
+
+	cfg.RoleARN = "tenant-a"
+	require.Equal(t, errInvalidRoleARN, cfg.Validate())
+
+	cfg.RoleARN = "arn:aws:iam::123456789012:role/tenant-a"
+	cfg.SignatureVersion = SignatureVersionV2
+	require.Equal(t, errRoleWithSignatureV2, cfg.Validate())
+}
+
 func TestSSEConfig_Validate(t *testing.T) {
 	tests := map[string]struct {
 		setup    func() *SSEConfig
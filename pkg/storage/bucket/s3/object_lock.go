@@ -0,0 +1,157 @@
+package s3
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/objstore/providers/s3"
+)
+
+var (
+	supportedObjectLockModes            = []string{string(minio.Governance), string(minio.Compliance)}
+	errUnsupportedObjectLockMode        = errors.New("unsupported S3 object lock mode")
+	errInvalidObjectLockRetentionPeriod = errors.New("the S3 object lock retention period must be greater than 0 when the object lock mode is set")
+)
+
+// ObjectLockConfig configures the S3 Object Lock retention of the uploaded blocks.
+type ObjectLockConfig struct {
+	Mode            string        `yaml:"mode"`
+	RetentionPeriod time.Duration `yaml:"retention_period"`
+}
+
+// RegisterFlagsWithPrefix registers the flags for the S3 object lock with the provided prefix.
+func (cfg *ObjectLockConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Mode, prefix+"mode", "", fmt.Sprintf("[Experimental] S3 Object Lock retention mode of the objects of the uploaded blocks, for the buckets created with Object Lock enabled. Supported values: %s. Empty to upload the objects without retention, or with the default retention of the bucket.", strings.Join(supportedObjectLockModes, ", ")))
+	f.DurationVar(&cfg.RetentionPeriod, prefix+"retention-period", 0, "[Experimental] S3 Object Lock retention period of the objects of the uploaded blocks, since their upload. The objects can't be deleted, nor overwritten, until the retention period has elapsed.")
+}
+
+// Validate the config.
+func (cfg *ObjectLockConfig) Validate() error {
+	if cfg.Mode == "" {
+		return nil
+	}
+	if !minio.RetentionMode(cfg.Mode).IsValid() {
+		return errUnsupportedObjectLockMode
+	}
+	if cfg.RetentionPeriod <= 0 {
+		return errInvalidObjectLockRetentionPeriod
+	}
+	return nil
+}
+
+type sseConfigContextKey struct{}
+
+// ContextWithSSEConfig returns a context overriding the SSE config of the uploads, like s3.ContextWithSSEConfig,
+// keeping track of the override so that the object lock of the uploads preserves it.
+func ContextWithSSEConfig(ctx context.Context, sse encrypt.ServerSide) context.Context {
+	ctx = context.WithValue(ctx, sseConfigContextKey{}, sse)
+	return s3.ContextWithSSEConfig(ctx, sse)
+}
+
+// objectLockBucket is a bucket client uploading the objects of the blocks with the S3 Object Lock retention.
+type objectLockBucket struct {
+	objstore.Bucket
+
+	mode       minio.RetentionMode
+	retention  time.Duration
+	defaultSSE encrypt.ServerSide
+}
+
+func newObjectLockBucket(bkt objstore.Bucket, cfg Config) (objstore.Bucket, error) {
+	if cfg.ObjectLock.Mode == "" {
+		return bkt, nil
+	}
+
+	defaultSSE, err := cfg.SSE.BuildMinioConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return &objectLockBucket{
+		Bucket:     bkt,
+		mode:       minio.RetentionMode(cfg.ObjectLock.Mode),
+		retention:  cfg.ObjectLock.RetentionPeriod,
+		defaultSSE: defaultSSE,
+	}, nil
+}
+
+// Upload implements objstore.Bucket.
+func (b *objectLockBucket) Upload(ctx context.Context, name string, r io.Reader) error {
+	// The markers and the bucket index are overwritten and deleted by the compactor, so only the objects of the
+	// blocks are locked.
+	if !isBlockObject(name) {
+		return b.Bucket.Upload(ctx, name, r)
+	}
+
+	// The S3 client doesn't support setting the object lock of the uploads, so the object lock headers are set by
+	// the SSE config, which is the only option of the uploads overridable per request.
+	sse, ok := ctx.Value(sseConfigContextKey{}).(encrypt.ServerSide)
+	if !ok {
+		sse = b.defaultSSE
+	}
+	ctx = s3.ContextWithSSEConfig(ctx, &objectLockSSE{
+		sse:         sse,
+		mode:        b.mode,
+		retainUntil: time.Now().Add(b.retention),
+	})
+
+	return b.Bucket.Upload(ctx, name, r)
+}
+
+// objectLockSSE is a SSE config adding the object lock headers to the headers of the SSE config it wraps, if any.
+type objectLockSSE struct {
+	sse         encrypt.ServerSide
+	mode        minio.RetentionMode
+	retainUntil time.Time
+}
+
+// Type implements encrypt.ServerSide.
+func (s *objectLockSSE) Type() encrypt.Type {
+	if s.sse == nil {
+		return ""
+	}
+	return s.sse.Type()
+}
+
+// Marshal implements encrypt.ServerSide.
+func (s *objectLockSSE) Marshal(h http.Header) {
+	if s.sse != nil {
+		s.sse.Marshal(h)
+	}
+	h.Set("X-Amz-Object-Lock-Mode", s.mode.String())
+	h.Set("X-Amz-Object-Lock-Retain-Until-Date", s.retainUntil.UTC().Format(time.RFC3339))
+}
+
+// isBlockObject returns whether the object is in the directory of a block, eg. <tenant>/<block>/chunks/000001.
+func isBlockObject(name string) bool {
+	parts := strings.Split(name, objstore.DirDelim)
+	for _, dir := range parts[:len(parts)-1] {
+		if _, err := ulid.Parse(dir); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// IsObjectLockedErr returns whether the error is returned by S3 for an operation denied by the object lock
+// retention of the object.
+func IsObjectLockedErr(err error) bool {
+	var errResp minio.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Code != "AccessDenied" {
+		return false
+	}
+
+	// AWS S3 and MinIO don't return a specific error code for the locked objects.
+	msg := strings.ToLower(errResp.Message)
+	return strings.Contains(msg, "object lock") || strings.Contains(msg, "worm protected")
+}
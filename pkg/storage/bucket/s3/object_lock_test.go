@@ -0,0 +1,139 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+func TestObjectLockConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		cfg      ObjectLockConfig
+		expected error
+	}{
+		"disabled": {
+			cfg: ObjectLockConfig{},
+		},
+		"compliance mode": {
+			cfg: ObjectLockConfig{Mode: "COMPLIANCE", RetentionPeriod: time.Hour},
+		},
+		"unsupported mode": {
+			cfg:      ObjectLockConfig{Mode: "compliance", RetentionPeriod: time.Hour},
+			expected: errUnsupportedObjectLockMode,
+		},
+		"missing retention period": {
+			cfg:      ObjectLockConfig{Mode: "GOVERNANCE"},
+			expected: errInvalidObjectLockRetentionPeriod,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, testData.cfg.Validate())
+		})
+	}
+}
+
+func TestObjectLockBucket_ShouldLockTheObjectsOfTheBlocks(t *testing.T) {
+	var (
+		mtx     sync.Mutex
+		headers = map[string]http.Header{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		headers[strings.TrimPrefix(r.URL.Path, "/test/")] = r.Header.Clone()
+		mtx.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := Config{}
+	flagext.DefaultValues(&cfg)
+	cfg.Endpoint = strings.TrimPrefix(srv.URL, "http://")
+	cfg.Insecure = true
+	cfg.BucketName = "test"
+	cfg.Region = "us-east-1"
+	cfg.AccessKeyID = "key"
+	cfg.SecretAccessKey = flagext.Secret{Value: "secret"}
+	cfg.BucketLookupType = BucketPathLookup
+	cfg.SSE = SSEConfig{Type: SSES3}
+	cfg.ObjectLock = ObjectLockConfig{Mode: "GOVERNANCE", RetentionPeriod: 24 * time.Hour}
+
+	s3Cfg, err := newS3Config(cfg)
+	require.NoError(t, err)
+	bkt, err := newBucket(cfg, s3Cfg, "test", log.NewNopLogger())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, bkt.Upload(ctx, "user-1/01HQ8W7N9Z0Y4VJ6F5ZJ9KXW3B/chunks/000001", bytes.NewReader([]byte("chunks"))))
+	require.NoError(t, bkt.Upload(ctx, "user-1/markers/01HQ8W7N9Z0Y4VJ6F5ZJ9KXW3B-deletion-mark.json", bytes.NewReader([]byte("{}"))))
+
+	sseKMS, err := (&SSEConfig{Type: SSEKMS, KMSKeyID: "tenant-key"}).BuildMinioConfig()
+	require.NoError(t, err)
+	require.NoError(t, bkt.Upload(ContextWithSSEConfig(ctx, sseKMS), "user-2/01HQ8W7N9Z0Y4VJ6F5ZJ9KXW3B/meta.json", bytes.NewReader([]byte("{}"))))
+
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	// The objects of the blocks are locked, keeping the SSE config.
+	chunks := headers["user-1/01HQ8W7N9Z0Y4VJ6F5ZJ9KXW3B/chunks/000001"]
+	assert.Equal(t, "GOVERNANCE", chunks.Get("X-Amz-Object-Lock-Mode"))
+	retainUntil, err := time.Parse(time.RFC3339, chunks.Get("X-Amz-Object-Lock-Retain-Until-Date"))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), retainUntil, time.Minute)
+	assert.Equal(t, "AES256", chunks.Get("X-Amz-Server-Side-Encryption"))
+
+	// The SSE config of the tenant is preserved.
+	meta := headers["user-2/01HQ8W7N9Z0Y4VJ6F5ZJ9KXW3B/meta.json"]
+	assert.Equal(t, "GOVERNANCE", meta.Get("X-Amz-Object-Lock-Mode"))
+	assert.Equal(t, "aws:kms", meta.Get("X-Amz-Server-Side-Encryption"))
+	assert.Equal(t, "tenant-key", meta.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+
+	// The markers aren't locked.
+	marker := headers["user-1/markers/01HQ8W7N9Z0Y4VJ6F5ZJ9KXW3B-deletion-mark.json"]
+	require.NotNil(t, marker)
+	assert.Empty(t, marker.Get("X-Amz-Object-Lock-Mode"))
+	assert.Equal(t, "AES256", marker.Get("X-Amz-Server-Side-Encryption"))
+}
+
+func TestIsObjectLockedErr(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"AWS S3 locked object": {
+			err:      minio.ErrorResponse{Code: "AccessDenied", Message: "Access Denied because object protected by object lock."},
+			expected: true,
+		},
+		"MinIO locked object": {
+			err:      errors.Wrap(minio.ErrorResponse{Code: "AccessDenied", Message: "Object is WORM protected and cannot be overwritten"}, "delete block"),
+			expected: true,
+		},
+		"access denied": {
+			err:      minio.ErrorResponse{Code: "AccessDenied", Message: "Access Denied"},
+			expected: false,
+		},
+		"other error": {
+			err:      errors.New("object lock"),
+			expected: false,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, IsObjectLockedErr(testData.err))
+		})
+	}
+}
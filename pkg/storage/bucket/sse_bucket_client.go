@@ -8,11 +8,11 @@ import (
 	"github.com/minio/minio-go/v7/pkg/encrypt"
 	"github.com/pkg/errors"
 	"github.com/thanos-io/objstore"
-	"github.com/thanos-io/objstore/providers/s3"
 	"google.golang.org/grpc/codes"
 
 	cortex_errors "github.com/cortexproject/cortex/pkg/util/errors"
 
+	"github.com/cortexproject/cortex/pkg/storage/bucket/gcs"
 	cortex_s3 "github.com/cortexproject/cortex/pkg/storage/bucket/s3"
 )
 
@@ -26,10 +26,14 @@ type TenantConfigProvider interface {
 
 	// S3SSEKMSEncryptionContext returns the per-tenant S3 KMS-SSE key id or an empty string if not set.
 	S3SSEKMSEncryptionContext(userID string) string
+
+	// GCSKMSKeyName returns the per-tenant GCS KMS key name or an empty string if not set.
+	GCSKMSKeyName(userID string) string
 }
 
 // SSEBucketClient is a wrapper around a objstore.BucketReader that configures the object
-// storage server-side encryption (SSE) for a given user.
+// storage server-side encryption (SSE) for a given user. The operations are tagged with the
+// user, to attribute them to the user in the bucket metrics and audit log.
 type SSEBucketClient struct {
 	userID      string
 	bucket      objstore.Bucket
@@ -52,12 +56,19 @@ func (b *SSEBucketClient) Close() error {
 
 // Upload the contents of the reader as an object into the bucket.
 func (b *SSEBucketClient) Upload(ctx context.Context, name string, r io.Reader) error {
+	ctx = contextWithTenant(ctx, b.userID)
+
 	if sse, err := b.getCustomS3SSEConfig(); err != nil {
 		return err
 	} else if sse != nil {
 		// If the underlying bucket client is not S3 and a custom S3 SSE config has been
 		// provided, the config option will be ignored.
-		ctx = s3.ContextWithSSEConfig(ctx, sse)
+		ctx = cortex_s3.ContextWithSSEConfig(ctx, sse)
+	}
+
+	if kmsKeyName := b.getCustomGCSKMSKeyName(); kmsKeyName != "" {
+		// If the underlying bucket client is not GCS, the config option will be ignored.
+		ctx = gcs.ContextWithKMSKeyName(ctx, kmsKeyName)
 	}
 
 	return b.bucket.Upload(ctx, name, r)
@@ -65,6 +76,7 @@ func (b *SSEBucketClient) Upload(ctx context.Context, name string, r io.Reader)
 
 // Delete implements objstore.Bucket.
 func (b *SSEBucketClient) Delete(ctx context.Context, name string) error {
+	ctx = contextWithTenant(ctx, b.userID)
 	return b.bucket.Delete(ctx, name)
 }
 
@@ -98,13 +110,23 @@ func (b *SSEBucketClient) getCustomS3SSEConfig() (encrypt.ServerSide, error) {
 	return sse, nil
 }
 
+func (b *SSEBucketClient) getCustomGCSKMSKeyName() string {
+	if b.cfgProvider == nil {
+		return ""
+	}
+
+	return b.cfgProvider.GCSKMSKeyName(b.userID)
+}
+
 // Iter implements objstore.Bucket.
 func (b *SSEBucketClient) Iter(ctx context.Context, dir string, f func(string) error, options ...objstore.IterOption) error {
+	ctx = contextWithTenant(ctx, b.userID)
 	return b.bucket.Iter(ctx, dir, f, options...)
 }
 
 // Get implements objstore.Bucket.
 func (b *SSEBucketClient) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	ctx = contextWithTenant(ctx, b.userID)
 	r, err := b.bucket.Get(ctx, name)
 
 	if err != nil && b.IsAccessDeniedErr(err) {
@@ -117,6 +139,7 @@ func (b *SSEBucketClient) Get(ctx context.Context, name string) (io.ReadCloser,
 
 // GetRange implements objstore.Bucket.
 func (b *SSEBucketClient) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	ctx = contextWithTenant(ctx, b.userID)
 	r, err := b.bucket.GetRange(ctx, name, off, length)
 	if err != nil && b.IsAccessDeniedErr(err) {
 		return nil, cortex_errors.WithCause(err, status.Error(codes.PermissionDenied, err.Error()))
@@ -127,6 +150,7 @@ func (b *SSEBucketClient) GetRange(ctx context.Context, name string, off, length
 
 // Exists implements objstore.Bucket.
 func (b *SSEBucketClient) Exists(ctx context.Context, name string) (bool, error) {
+	ctx = contextWithTenant(ctx, b.userID)
 	return b.bucket.Exists(ctx, name)
 }
 
@@ -146,6 +170,7 @@ func (b *SSEBucketClient) IsAccessDeniedErr(err error) bool {
 
 // Attributes implements objstore.Bucket.
 func (b *SSEBucketClient) Attributes(ctx context.Context, name string) (objstore.ObjectAttributes, error) {
+	ctx = contextWithTenant(ctx, b.userID)
 	return b.bucket.Attributes(ctx, name)
 }
 
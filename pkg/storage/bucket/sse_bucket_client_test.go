@@ -123,6 +123,7 @@ type mockTenantConfigProvider struct {
 	s3SseType              string
 	s3KmsKeyID             string
 	s3KmsEncryptionContext string
+	gcsKmsKeyName          string
 }
 
 func (m *mockTenantConfigProvider) S3SSEType(_ string) string {
@@ -136,3 +137,7 @@ func (m *mockTenantConfigProvider) S3SSEKMSKeyID(_ string) string {
 func (m *mockTenantConfigProvider) S3SSEKMSEncryptionContext(_ string) string {
 	return m.s3KmsEncryptionContext
 }
+
+func (m *mockTenantConfigProvider) GCSKMSKeyName(_ string) string {
+	return m.gcsKmsKeyName
+}
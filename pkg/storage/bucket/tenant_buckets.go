@@ -0,0 +1,273 @@
+package bucket
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/thanos-io/objstore"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/cortexproject/cortex/pkg/util/multierror"
+)
+
+// TenantBucketsConfig configures the buckets storing the objects of the tenants in addition to the default bucket,
+// and maps the tenants to their bucket. The tenants are mapped to a bucket by their tenant ID, or else by the first
+// rule matching their tenant ID. The tenants which aren't mapped to any bucket are stored in the default bucket.
+type TenantBucketsConfig struct {
+	Buckets []TenantBucketConfig `yaml:"buckets"`
+	Tenants map[string]string    `yaml:"tenants"`
+	Rules   []TenantBucketRule   `yaml:"rules"`
+}
+
+// TenantBucketConfig configures a bucket storing the objects of the tenants mapped to it, under the optional prefix.
+type TenantBucketConfig struct {
+	Name   string `yaml:"name"`
+	Prefix string `yaml:"prefix"`
+	Config `yaml:",inline"`
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (c *TenantBucketConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = TenantBucketConfig{}
+	flagext.DefaultValues(&c.Config)
+
+	type plain TenantBucketConfig
+	return unmarshal((*plain)(c))
+}
+
+// TenantBucketRule maps the tenants whose tenant ID matches its regex to its bucket.
+type TenantBucketRule struct {
+	TenantsRegex string `yaml:"tenants_regex"`
+	Bucket       string `yaml:"bucket"`
+
+	regex *regexp.Regexp
+}
+
+// LoadTenantBucketsConfigFile reads, parses and validates the tenant buckets config file.
+func LoadTenantBucketsConfigFile(filename string) (*TenantBucketsConfig, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return LoadTenantBucketsConfig(content)
+}
+
+// LoadTenantBucketsConfig parses and validates the tenant buckets config.
+func LoadTenantBucketsConfig(content []byte) (*TenantBucketsConfig, error) {
+	cfg := &TenantBucketsConfig{}
+	if err := yaml.UnmarshalStrict(content, cfg); err != nil {
+		return nil, err
+	}
+
+	names := map[string]struct{}{}
+	for _, b := range cfg.Buckets {
+		if b.Name == "" {
+			return nil, errors.New("missing name in tenant bucket")
+		}
+		if _, ok := names[b.Name]; ok {
+			return nil, fmt.Errorf("duplicate tenant bucket %q", b.Name)
+		}
+		names[b.Name] = struct{}{}
+
+		if err := b.Config.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid tenant bucket %q", b.Name)
+		}
+		if slices.Contains(strings.Split(b.Prefix, objstore.DirDelim), "..") {
+			return nil, fmt.Errorf("invalid prefix %q of tenant bucket %q", b.Prefix, b.Name)
+		}
+	}
+
+	for userID, name := range cfg.Tenants {
+		if _, ok := names[name]; !ok {
+			return nil, fmt.Errorf("tenant %q mapped to unknown bucket %q", userID, name)
+		}
+	}
+
+	for i := range cfg.Rules {
+		r := &cfg.Rules[i]
+		if _, ok := names[r.Bucket]; !ok {
+			return nil, fmt.Errorf("tenant bucket rule %q mapped to unknown bucket %q", r.TenantsRegex, r.Bucket)
+		}
+
+		regex, err := regexp.Compile("^(?:" + r.TenantsRegex + ")$")
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid tenants regex %q in tenant bucket rule", r.TenantsRegex)
+		}
+		r.regex = regex
+	}
+
+	return cfg, nil
+}
+
+// BucketName returns the name of the bucket the tenant is mapped to, or an empty string if the tenant is stored in
+// the default bucket.
+func (c *TenantBucketsConfig) BucketName(userID string) string {
+	if name, ok := c.Tenants[userID]; ok {
+		return name
+	}
+
+	for _, r := range c.Rules {
+		if r.regex.MatchString(userID) {
+			return r.Bucket
+		}
+	}
+	return ""
+}
+
+// NewClientWithTenantBuckets creates a new bucket client storing the objects of the tenants in their bucket, according
+// to the tenant buckets config, and the objects of the other tenants in the bucket of the config. The tenant of an object
+// is the first component of its name, as laid out by the blocks storage.
+func NewClientWithTenantBuckets(ctx context.Context, cfg Config, tenantBuckets *TenantBucketsConfig, name string, logger log.Logger, reg prometheus.Registerer) (objstore.InstrumentedBucket, error) {
+	defaultBucket, err := newBackendClient(ctx, cfg, name, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &tenantBucketsClient{
+		cfg:           tenantBuckets,
+		defaultBucket: defaultBucket,
+		buckets:       make(map[string]objstore.Bucket, len(tenantBuckets.Buckets)),
+	}
+
+	for _, b := range tenantBuckets.Buckets {
+		bkt, err := newBackendClient(ctx, b.Config, name, logger)
+		if err != nil {
+			_ = client.Close()
+			return nil, errors.Wrapf(err, "create tenant bucket %q", b.Name)
+		}
+		if b.Prefix != "" {
+			bkt = objstore.NewPrefixedBucket(bkt, b.Prefix)
+		}
+		client.buckets[b.Name] = bkt
+	}
+
+	return instrumentClient(client, cfg, name, logger, reg)
+}
+
+// tenantBucketsClient is a bucket client routing the operations on the objects of the tenants to their bucket.
+type tenantBucketsClient struct {
+	cfg           *TenantBucketsConfig
+	defaultBucket objstore.Bucket
+	buckets       map[string]objstore.Bucket
+}
+
+// bucketName returns the name of the bucket storing the object, or the directory, with the given name.
+func (b *tenantBucketsClient) bucketName(name string) string {
+	userID, _, _ := strings.Cut(strings.TrimPrefix(name, objstore.DirDelim), objstore.DirDelim)
+	return b.cfg.BucketName(userID)
+}
+
+// bucket returns the bucket storing the object, or the directory, with the given name.
+func (b *tenantBucketsClient) bucket(name string) objstore.Bucket {
+	if bkt, ok := b.buckets[b.bucketName(name)]; ok {
+		return bkt
+	}
+	return b.defaultBucket
+}
+
+// Close implements objstore.Bucket.
+func (b *tenantBucketsClient) Close() error {
+	errs := multierror.New()
+	errs.Add(b.defaultBucket.Close())
+	for _, bkt := range b.buckets {
+		errs.Add(bkt.Close())
+	}
+	return errs.Err()
+}
+
+// Upload implements objstore.Bucket.
+func (b *tenantBucketsClient) Upload(ctx context.Context, name string, r io.Reader) error {
+	return b.bucket(name).Upload(ctx, name, r)
+}
+
+// Delete implements objstore.Bucket.
+func (b *tenantBucketsClient) Delete(ctx context.Context, name string) error {
+	return b.bucket(name).Delete(ctx, name)
+}
+
+// Name implements objstore.Bucket.
+func (b *tenantBucketsClient) Name() string {
+	return b.defaultBucket.Name()
+}
+
+// Iter implements objstore.Bucket. Iterating the root directory iterates the root directory of every bucket, skipping
+// the entries of the tenants mapped to another bucket.
+func (b *tenantBucketsClient) Iter(ctx context.Context, dir string, f func(string) error, options ...objstore.IterOption) error {
+	if strings.Trim(dir, objstore.DirDelim) != "" {
+		return b.bucket(dir).Iter(ctx, dir, f, options...)
+	}
+
+	iter := func(bucketName string, bkt objstore.Bucket) error {
+		return bkt.Iter(ctx, dir, func(entry string) error {
+			if b.bucketName(entry) != bucketName {
+				return nil
+			}
+			return f(entry)
+		}, options...)
+	}
+
+	if err := iter("", b.defaultBucket); err != nil {
+		return err
+	}
+	for _, cfg := range b.cfg.Buckets {
+		if err := iter(cfg.Name, b.buckets[cfg.Name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get implements objstore.Bucket.
+func (b *tenantBucketsClient) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	return b.bucket(name).Get(ctx, name)
+}
+
+// GetRange implements objstore.Bucket.
+func (b *tenantBucketsClient) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	return b.bucket(name).GetRange(ctx, name, off, length)
+}
+
+// Exists implements objstore.Bucket.
+func (b *tenantBucketsClient) Exists(ctx context.Context, name string) (bool, error) {
+	return b.bucket(name).Exists(ctx, name)
+}
+
+// IsObjNotFoundErr implements objstore.Bucket.
+func (b *tenantBucketsClient) IsObjNotFoundErr(err error) bool {
+	if b.defaultBucket.IsObjNotFoundErr(err) {
+		return true
+	}
+	for _, bkt := range b.buckets {
+		if bkt.IsObjNotFoundErr(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAccessDeniedErr implements objstore.Bucket.
+func (b *tenantBucketsClient) IsAccessDeniedErr(err error) bool {
+	if b.defaultBucket.IsAccessDeniedErr(err) {
+		return true
+	}
+	for _, bkt := range b.buckets {
+		if bkt.IsAccessDeniedErr(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// Attributes implements objstore.Bucket.
+func (b *tenantBucketsClient) Attributes(ctx context.Context, name string) (objstore.ObjectAttributes, error) {
+	return b.bucket(name).Attributes(ctx, name)
+}
@@ -0,0 +1,246 @@
+package bucket
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket/filesystem"
+)
+
+func TestLoadTenantBucketsConfig(t *testing.T) {
+	tests := map[string]struct {
+		config      string
+		expectedErr string
+	}{
+		"valid config": {
+			config: `
+buckets:
+- name: eu
+  backend: filesystem
+  filesystem:
+    dir: /data/eu
+tenants:
+  tenant-a: eu
+rules:
+- tenants_regex: 'eu-.*'
+  bucket: eu
+`,
+		},
+		"missing bucket name": {
+			config: `
+buckets:
+- backend: filesystem
+`,
+			expectedErr: "missing name in tenant bucket",
+		},
+		"duplicate bucket name": {
+			config: `
+buckets:
+- name: eu
+  backend: filesystem
+- name: eu
+  backend: filesystem
+`,
+			expectedErr: `duplicate tenant bucket "eu"`,
+		},
+		"invalid bucket config": {
+			config: `
+buckets:
+- name: eu
+  backend: unknown
+`,
+			expectedErr: `invalid tenant bucket "eu": unsupported storage backend`,
+		},
+		"tenant mapped to an unknown bucket": {
+			config: `
+tenants:
+  tenant-a: eu
+`,
+			expectedErr: `tenant "tenant-a" mapped to unknown bucket "eu"`,
+		},
+		"rule mapped to an unknown bucket": {
+			config: `
+rules:
+- tenants_regex: 'eu-.*'
+  bucket: eu
+`,
+			expectedErr: `tenant bucket rule "eu-.*" mapped to unknown bucket "eu"`,
+		},
+		"invalid bucket prefix": {
+			config: `
+buckets:
+- name: eu
+  prefix: ../eu
+  backend: filesystem
+`,
+			expectedErr: `invalid prefix "../eu" of tenant bucket "eu"`,
+		},
+		"invalid rule regex": {
+			config: `
+buckets:
+- name: eu
+  backend: filesystem
+rules:
+- tenants_regex: 'eu-('
+  bucket: eu
+`,
+			expectedErr: `invalid tenants regex "eu-(" in tenant bucket rule`,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			_, err := LoadTenantBucketsConfig([]byte(testData.config))
+			if testData.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testData.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestTenantBucketsConfig_BucketName(t *testing.T) {
+	cfg, err := LoadTenantBucketsConfig([]byte(`
+buckets:
+- name: eu
+  backend: filesystem
+- name: us
+  backend: filesystem
+tenants:
+  eu-tenant-us: us
+rules:
+- tenants_regex: 'eu-.*'
+  bucket: eu
+- tenants_regex: '.*-(eu|de)'
+  bucket: eu
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, "eu", cfg.BucketName("eu-tenant"))
+	assert.Equal(t, "eu", cfg.BucketName("tenant-de"))
+	assert.Equal(t, "us", cfg.BucketName("eu-tenant-us"))
+	assert.Equal(t, "", cfg.BucketName("tenant"))
+	assert.Equal(t, "", cfg.BucketName("tenant-eu-1"))
+}
+
+func TestNewClientWithTenantBuckets(t *testing.T) {
+	ctx := context.Background()
+	defaultDir, euDir := t.TempDir(), t.TempDir()
+
+	tenantBuckets, err := LoadTenantBucketsConfig([]byte(fmt.Sprintf(`
+buckets:
+- name: eu
+  backend: filesystem
+  filesystem:
+    dir: %s
+rules:
+- tenants_regex: 'eu-.*'
+  bucket: eu
+`, euDir)))
+	require.NoError(t, err)
+
+	cfg := Config{Backend: Filesystem, Filesystem: filesystem.Config{Directory: defaultDir}}
+	bkt, err := NewClientWithTenantBuckets(ctx, cfg, tenantBuckets, "test", log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, bkt.Close()) })
+
+	for _, name := range []string{"tenant-1/block/meta.json", "eu-tenant-1/block/meta.json", "__markers__/eu-tenant-1/tenant-deletion-mark.json"} {
+		require.NoError(t, bkt.Upload(ctx, name, strings.NewReader(name)))
+	}
+
+	// The objects of the tenants are stored in their bucket.
+	assert.FileExists(t, filepath.Join(defaultDir, "tenant-1/block/meta.json"))
+	assert.FileExists(t, filepath.Join(defaultDir, "__markers__/eu-tenant-1/tenant-deletion-mark.json"))
+	assert.FileExists(t, filepath.Join(euDir, "eu-tenant-1/block/meta.json"))
+	assert.NoFileExists(t, filepath.Join(defaultDir, "eu-tenant-1/block/meta.json"))
+
+	exists, err := bkt.Exists(ctx, "eu-tenant-1/block/meta.json")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	_, err = bkt.Get(ctx, "eu-tenant-1/block/missing.json")
+	assert.True(t, bkt.IsObjNotFoundErr(err))
+
+	// The root directory lists the tenants of every bucket, skipping the leftovers of the tenants mapped to another bucket.
+	require.NoError(t, os.MkdirAll(filepath.Join(defaultDir, "eu-tenant-2/block"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(defaultDir, "eu-tenant-2/block/meta.json"), []byte("leftover"), 0644))
+
+	var entries []string
+	require.NoError(t, bkt.Iter(ctx, "", func(entry string) error {
+		entries = append(entries, entry)
+		return nil
+	}))
+	assert.ElementsMatch(t, []string{"__markers__/", "tenant-1/", "eu-tenant-1/"}, entries)
+
+	entries = nil
+	require.NoError(t, bkt.Iter(ctx, "eu-tenant-1/", func(entry string) error {
+		entries = append(entries, entry)
+		return nil
+	}, objstore.WithRecursiveIter))
+	assert.Equal(t, []string{"eu-tenant-1/block/meta.json"}, entries)
+
+	require.NoError(t, bkt.Delete(ctx, "eu-tenant-1/block/meta.json"))
+	assert.NoFileExists(t, filepath.Join(euDir, "eu-tenant-1/block/meta.json"))
+}
+
+func TestNewClientWithTenantBuckets_ShouldStoreTheTenantsUnderTheBucketPrefix(t *testing.T) {
+	ctx := context.Background()
+	defaultDir, sharedDir := t.TempDir(), t.TempDir()
+
+	// The tenants share the same storage, each one under its own prefix.
+	tenantBuckets, err := LoadTenantBucketsConfig([]byte(fmt.Sprintf(`
+buckets:
+- name: tenant-a
+  prefix: isolated/tenant-a
+  backend: filesystem
+  filesystem:
+    dir: %s
+- name: tenant-b
+  prefix: isolated/tenant-b
+  backend: filesystem
+  filesystem:
+    dir: %s
+tenants:
+  tenant-a: tenant-a
+  tenant-b: tenant-b
+`, sharedDir, sharedDir)))
+	require.NoError(t, err)
+
+	cfg := Config{Backend: Filesystem, Filesystem: filesystem.Config{Directory: defaultDir}}
+	bkt, err := NewClientWithTenantBuckets(ctx, cfg, tenantBuckets, "test", log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, bkt.Close()) })
+
+	require.NoError(t, bkt.Upload(ctx, "tenant-a/block/meta.json", strings.NewReader("a")))
+	require.NoError(t, bkt.Upload(ctx, "tenant-b/block/meta.json", strings.NewReader("b")))
+
+	assert.FileExists(t, filepath.Join(sharedDir, "isolated/tenant-a/tenant-a/block/meta.json"))
+	assert.FileExists(t, filepath.Join(sharedDir, "isolated/tenant-b/tenant-b/block/meta.json"))
+
+	// Each tenant is only listed once, from its own prefix.
+	var entries []string
+	require.NoError(t, bkt.Iter(ctx, "", func(entry string) error {
+		entries = append(entries, entry)
+		return nil
+	}))
+	assert.ElementsMatch(t, []string{"tenant-a/", "tenant-b/"}, entries)
+
+	r, err := bkt.Get(ctx, "tenant-b/block/meta.json")
+	require.NoError(t, err)
+	content, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, "b", string(content))
+}
@@ -0,0 +1,184 @@
+package bucket
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/thanos-io/objstore"
+)
+
+// ulidRegexp matches the ULIDs, identifying the blocks, in the object names.
+var ulidRegexp = regexp.MustCompile(`[0-9A-HJKMNP-TV-Z]{26}`)
+
+// tracingBucketClient is a bucket client tracing each operation in a span, child of the span of the query or job
+// running it. The span is tagged with the operation, the object, its key pattern and the bytes transferred, while
+// the bucket clients it wraps tag it with the attempts sent to the storage.
+type tracingBucketClient struct {
+	bucket    objstore.Bucket
+	component string
+}
+
+func newTracingBucketClient(bkt objstore.Bucket, component string) *tracingBucketClient {
+	return &tracingBucketClient{bucket: bkt, component: component}
+}
+
+// Upload implements objstore.Bucket.
+func (b *tracingBucketClient) Upload(ctx context.Context, name string, r io.Reader) error {
+	span, ctx := b.startSpan(ctx, objstore.OpUpload, name)
+	if size, err := objstore.TryToGetSize(r); err == nil {
+		span.SetTag("bytes", size)
+	}
+
+	err := b.bucket.Upload(ctx, name, r)
+	finishSpan(span, err)
+	return err
+}
+
+// Delete implements objstore.Bucket.
+func (b *tracingBucketClient) Delete(ctx context.Context, name string) error {
+	span, ctx := b.startSpan(ctx, objstore.OpDelete, name)
+	err := b.bucket.Delete(ctx, name)
+	finishSpan(span, err)
+	return err
+}
+
+// Iter implements objstore.Bucket.
+func (b *tracingBucketClient) Iter(ctx context.Context, dir string, f func(string) error, options ...objstore.IterOption) error {
+	span, ctx := b.startSpan(ctx, objstore.OpIter, dir)
+	err := b.bucket.Iter(ctx, dir, f, options...)
+	finishSpan(span, err)
+	return err
+}
+
+// Get implements objstore.Bucket.
+func (b *tracingBucketClient) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	span, ctx := b.startSpan(ctx, objstore.OpGet, name)
+	r, err := b.bucket.Get(ctx, name)
+	return tracedReader(span, r, err)
+}
+
+// GetRange implements objstore.Bucket.
+func (b *tracingBucketClient) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	span, ctx := b.startSpan(ctx, objstore.OpGetRange, name)
+	span.SetTag("offset", off)
+	span.SetTag("length", length)
+
+	r, err := b.bucket.GetRange(ctx, name, off, length)
+	return tracedReader(span, r, err)
+}
+
+// Exists implements objstore.Bucket.
+func (b *tracingBucketClient) Exists(ctx context.Context, name string) (bool, error) {
+	span, ctx := b.startSpan(ctx, objstore.OpExists, name)
+	exists, err := b.bucket.Exists(ctx, name)
+	finishSpan(span, err)
+	return exists, err
+}
+
+// Attributes implements objstore.Bucket.
+func (b *tracingBucketClient) Attributes(ctx context.Context, name string) (objstore.ObjectAttributes, error) {
+	span, ctx := b.startSpan(ctx, objstore.OpAttributes, name)
+	attrs, err := b.bucket.Attributes(ctx, name)
+	finishSpan(span, err)
+	return attrs, err
+}
+
+// Name implements objstore.Bucket.
+func (b *tracingBucketClient) Name() string {
+	return b.bucket.Name()
+}
+
+// Close implements objstore.Bucket.
+func (b *tracingBucketClient) Close() error {
+	return b.bucket.Close()
+}
+
+// IsObjNotFoundErr implements objstore.Bucket.
+func (b *tracingBucketClient) IsObjNotFoundErr(err error) bool {
+	return b.bucket.IsObjNotFoundErr(err)
+}
+
+// IsAccessDeniedErr implements objstore.Bucket.
+func (b *tracingBucketClient) IsAccessDeniedErr(err error) bool {
+	return b.bucket.IsAccessDeniedErr(err)
+}
+
+// ReaderWithExpectedErrs implements objstore.InstrumentedBucket.
+func (b *tracingBucketClient) ReaderWithExpectedErrs(fn objstore.IsOpFailureExpectedFunc) objstore.BucketReader {
+	return b.WithExpectedErrs(fn)
+}
+
+// WithExpectedErrs implements objstore.InstrumentedBucket.
+func (b *tracingBucketClient) WithExpectedErrs(fn objstore.IsOpFailureExpectedFunc) objstore.Bucket {
+	if ib, ok := b.bucket.(objstore.InstrumentedBucket); ok {
+		return &tracingBucketClient{bucket: ib.WithExpectedErrs(fn), component: b.component}
+	}
+	return b
+}
+
+func (b *tracingBucketClient) startSpan(ctx context.Context, op, name string) (opentracing.Span, context.Context) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "bucket_"+op)
+	userID := tenantFromContext(ctx)
+
+	span.SetTag("component", b.component)
+	span.SetTag("operation", op)
+	span.SetTag("user", userID)
+	span.SetTag("object", name)
+	span.SetTag("object_pattern", objectPattern(userID, name))
+	// The retrying and hedged bucket clients override the attempts.
+	span.SetTag("attempts", 1)
+	return span, ctx
+}
+
+func finishSpan(span opentracing.Span, err error) {
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogKV("err", err)
+	}
+	span.Finish()
+}
+
+// tracedReader finishes the span of the read operation once the reader is closed, tagging it with the bytes read.
+func tracedReader(span opentracing.Span, r io.ReadCloser, err error) (io.ReadCloser, error) {
+	if err != nil {
+		finishSpan(span, err)
+		return nil, err
+	}
+
+	return &trackedReadCloser{
+		ReadCloser: r,
+		onClose: func(n int64) {
+			span.SetTag("bytes", n)
+			span.Finish()
+		},
+	}, nil
+}
+
+// objectPattern returns the pattern of the key of the object, replacing the tenant, the block IDs and the chunks
+// segment numbers with placeholders, to group the operations on the objects of the same kind.
+func objectPattern(userID, name string) string {
+	parts := strings.Split(name, objstore.DirDelim)
+	for i, part := range parts {
+		switch {
+		case part == "":
+		case userID != "" && part == userID:
+			parts[i] = "<tenant>"
+		case strings.Trim(part, "0123456789") == "":
+			parts[i] = "<segment>"
+		default:
+			parts[i] = ulidRegexp.ReplaceAllString(part, "<block>")
+		}
+	}
+	return strings.Join(parts, objstore.DirDelim)
+}
+
+// setSpanTag tags the span of the bucket operation running in the context, if any.
+func setSpanTag(ctx context.Context, key string, value interface{}) {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag(key, value)
+	}
+}
@@ -0,0 +1,121 @@
+package bucket
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+)
+
+func TestTracingBucketClient(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	t.Cleanup(func() { opentracing.SetGlobalTracer(opentracing.NoopTracer{}) })
+
+	ctx := context.Background()
+	inMem := objstore.NewInMemBucket()
+	require.NoError(t, inMem.Upload(ctx, "user-1/01HQ8W7N9Z0Y4VJ6F5ZJ9KXW3B/chunks/000001", strings.NewReader("content")))
+
+	bkt := &failingBucket{Bucket: inMem, failures: 1}
+	cfg := Config{Retries: RetriesConfig{MaxRetries: 2, BudgetRatio: 0.1, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}}
+	client, err := instrumentClient(bkt, cfg, "test", log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	parent, parentCtx := opentracing.StartSpanFromContext(ctx, "query")
+	userBkt := NewUserBucketClient("user-1", client, nil)
+
+	r, err := userBkt.Get(parentCtx, "01HQ8W7N9Z0Y4VJ6F5ZJ9KXW3B/chunks/000001")
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	_, err = userBkt.Attributes(parentCtx, "missing")
+	require.Error(t, err)
+	parent.Finish()
+
+	spans := tracer.FinishedSpans()
+	require.Len(t, spans, 3)
+
+	// The span of the operation is a child of the span running it, tagged with the retried attempts.
+	get := spans[0]
+	assert.Equal(t, "bucket_get", get.OperationName)
+	assert.Equal(t, parent.Context().(mocktracer.MockSpanContext).SpanID, get.ParentID)
+	assert.Equal(t, map[string]interface{}{
+		"component":      "test",
+		"operation":      objstore.OpGet,
+		"user":           "user-1",
+		"object":         "user-1/01HQ8W7N9Z0Y4VJ6F5ZJ9KXW3B/chunks/000001",
+		"object_pattern": "<tenant>/<block>/chunks/<segment>",
+		"attempts":       2,
+		"bytes":          int64(7),
+	}, get.Tags())
+
+	// The failed operations are tagged as errors.
+	attrs := spans[1]
+	assert.Equal(t, "bucket_attributes", attrs.OperationName)
+	assert.Equal(t, true, attrs.Tag("error"))
+	assert.Equal(t, 1, attrs.Tag("attempts"))
+}
+
+func TestRateLimitedBucketClient_ShouldTraceTheWaits(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	t.Cleanup(func() { opentracing.SetGlobalTracer(opentracing.NoopTracer{}) })
+
+	ctx := context.Background()
+	client := NewRateLimitedBucketClient(objstore.WithNoopInstr(objstore.NewInMemBucket()), RateLimitConfig{MaxOperationsPerSecond: 20}, "test", nil)
+
+	// Only the operations waiting for the rate limit are traced.
+	for i := 0; i < 21; i++ {
+		_, err := client.Exists(ctx, "object")
+		require.NoError(t, err)
+	}
+
+	spans := tracer.FinishedSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "bucket_rate_limit_wait", spans[0].OperationName)
+	assert.Equal(t, map[string]interface{}{"component": "test", "limit": "operations", "events": 1}, spans[0].Tags())
+}
+
+func TestObjectPattern(t *testing.T) {
+	tests := map[string]struct {
+		userID   string
+		name     string
+		expected string
+	}{
+		"chunks segment": {
+			userID:   "user-1",
+			name:     "user-1/01HQ8W7N9Z0Y4VJ6F5ZJ9KXW3B/chunks/000001",
+			expected: "<tenant>/<block>/chunks/<segment>",
+		},
+		"block marker": {
+			userID:   "user-1",
+			name:     "user-1/markers/01HQ8W7N9Z0Y4VJ6F5ZJ9KXW3B-deletion-mark.json",
+			expected: "<tenant>/markers/<block>-deletion-mark.json",
+		},
+		"global tenant marker": {
+			userID:   "user-1",
+			name:     "__markers__/user-1/tenant-deletion-mark.json",
+			expected: "__markers__/<tenant>/tenant-deletion-mark.json",
+		},
+		"directory without tenant": {
+			name:     "user-1/",
+			expected: "user-1/",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, objectPattern(testData.userID, testData.name))
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package tsdb
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/thanos/pkg/cache"
+	"github.com/thanos-io/thanos/pkg/cacheutil"
+)
+
+// asyncBucketCache is a cache of the caching bucket storing the items asynchronously, through a bounded queue, so
+// that a slow cache never adds latency to the requests. The items are dropped when the queue is full.
+type asyncBucketCache struct {
+	cache.Cache
+
+	processor    *cacheutil.AsyncOperationProcessor
+	droppedItems prometheus.Counter
+}
+
+func newAsyncBucketCache(name string, maxAsyncConcurrency, maxAsyncBufferSize int, c cache.Cache, reg prometheus.Registerer) cache.Cache {
+	return &asyncBucketCache{
+		Cache:     c,
+		processor: cacheutil.NewAsyncOperationProcessor(maxAsyncBufferSize, maxAsyncConcurrency),
+		droppedItems: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "cortex_bucket_cache_async_dropped_items_total",
+			Help:        "Total number of items dropped because the async buffer of the bucket cache was full when storing them.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}),
+	}
+}
+
+// Store implements cache.Cache.
+func (c *asyncBucketCache) Store(data map[string][]byte, ttl time.Duration) {
+	if len(data) == 0 {
+		return
+	}
+	if err := c.processor.EnqueueAsync(func() {
+		c.Cache.Store(data, ttl)
+	}); errors.Is(err, cacheutil.ErrAsyncBufferFull) {
+		c.droppedItems.Add(float64(len(data)))
+	}
+}
+
+// droppedItemsCountingClient is a remote cache client counting the items dropped because its async buffer is full,
+// instead of failing to store each of them.
+type droppedItemsCountingClient struct {
+	cacheutil.RemoteCacheClient
+
+	droppedItems prometheus.Counter
+}
+
+func newDroppedItemsCountingClient(name string, client cacheutil.RemoteCacheClient, reg prometheus.Registerer) cacheutil.RemoteCacheClient {
+	return &droppedItemsCountingClient{
+		RemoteCacheClient: client,
+		droppedItems: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "cortex_cache_client_async_dropped_items_total",
+			Help:        "Total number of items dropped because the async buffer of the cache client was full when storing them.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}),
+	}
+}
+
+// SetAsync implements cacheutil.RemoteCacheClient.
+func (c *droppedItemsCountingClient) SetAsync(key string, value []byte, ttl time.Duration) error {
+	err := c.RemoteCacheClient.SetAsync(key, value, ttl)
+	if errors.Is(err, cacheutil.ErrAsyncBufferFull) {
+		c.droppedItems.Inc()
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package tsdb
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsyncBucketCache_ShouldDropTheItemsWhenTheBufferIsFull(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	blocking := &blockingBucketCache{mockBucketCache: newMockBucketCache(), storing: make(chan struct{}, 1), unblock: make(chan struct{})}
+	c := newAsyncBucketCache("chunks-cache", 1, 1, blocking, reg)
+
+	// The first items are stored by the only worker, which blocks, and the second ones wait in the buffer.
+	c.Store(map[string][]byte{"a": []byte("1")}, time.Minute)
+	<-blocking.storing
+	c.Store(map[string][]byte{"b": []byte("2")}, time.Minute)
+
+	// The buffer is full, so the items are dropped without waiting for the cache.
+	c.Store(map[string][]byte{"c": []byte("3"), "d": []byte("4")}, time.Minute)
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_bucket_cache_async_dropped_items_total Total number of items dropped because the async buffer of the bucket cache was full when storing them.
+		# TYPE cortex_bucket_cache_async_dropped_items_total counter
+		cortex_bucket_cache_async_dropped_items_total{name="chunks-cache"} 2
+	`)))
+
+	close(blocking.unblock)
+	assert.Eventually(t, func() bool {
+		return len(blocking.get()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, map[string][]byte{"a": []byte("1"), "b": []byte("2")}, blocking.get())
+}
+
+// blockingBucketCache is a cache whose stores block until unblocked.
+type blockingBucketCache struct {
+	*mockBucketCache
+
+	storing, unblock chan struct{}
+}
+
+func (c *blockingBucketCache) Store(data map[string][]byte, ttl time.Duration) {
+	select {
+	case c.storing <- struct{}{}:
+	default:
+	}
+	<-c.unblock
+	c.mockBucketCache.Store(data, ttl)
+}
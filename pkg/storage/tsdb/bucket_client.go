@@ -0,0 +1,26 @@
+package tsdb
+
+import (
+	"context"
+
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/thanos-io/objstore"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+)
+
+// NewBucketClient creates a new bucket client for the blocks storage, storing the blocks of the tenants in their bucket
+// if the tenant buckets config file is set.
+func NewBucketClient(ctx context.Context, cfg BlocksStorageConfig, name string, logger log.Logger, reg prometheus.Registerer) (objstore.InstrumentedBucket, error) {
+	if cfg.TenantBucketsConfigFile == "" {
+		return bucket.NewClient(ctx, cfg.Bucket, name, logger, reg)
+	}
+
+	tenantBuckets, err := bucket.LoadTenantBucketsConfigFile(cfg.TenantBucketsConfigFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to load tenant buckets config %q", cfg.TenantBucketsConfigFile)
+	}
+	return bucket.NewClientWithTenantBuckets(ctx, cfg.Bucket, tenantBuckets, name, logger, reg)
+}
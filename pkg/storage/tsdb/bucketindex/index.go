@@ -20,6 +20,9 @@ const (
 	IndexCompressedFilename = IndexFilename + ".gz"
 	IndexVersion1           = 1
 
+	// IndexVersion2 adds the query planning statistics of the blocks.
+	IndexVersion2 = 2
+
 	SegmentsFormatUnknown = ""
 
 	// SegmentsFormat1Based6Digits defined segments numbered with 6 digits numbers in a sequence starting from number 1
@@ -38,6 +41,10 @@ type Index struct {
 	// List of block deletion marks.
 	BlockDeletionMarks BlockDeletionMarks `json:"block_deletion_marks"`
 
+	// List of series deletion tombstones which should be applied at query time
+	// (pending or processed). Cancelled tombstones are excluded.
+	Tombstones Tombstones `json:"tombstones,omitempty"`
+
 	// UpdatedAt is a unix timestamp (seconds precision) of when the index has been updated
 	// (written in the storage) the last time.
 	UpdatedAt int64 `json:"updated_at"`
@@ -84,9 +91,41 @@ type Block struct {
 	SeriesMaxSize int64 `json:"series_max_size,omitempty"`
 	ChunkMaxSize  int64 `json:"chunk_max_size,omitempty"`
 
+	// Resolution of the downsampled block in milliseconds, or 0 for raw blocks.
+	Resolution int64 `json:"resolution,omitempty"`
+
+	// Rollup is true if the block contains the series generated by the compactor rollup rules.
+	Rollup bool `json:"rollup,omitempty"`
+
+	// Size in bytes of the block files, or 0 if unknown.
+	Size int64 `json:"size,omitempty"`
+
 	// UploadedAt is a unix timestamp (seconds precision) of when the block has been completed to be uploaded
 	// to the storage.
 	UploadedAt int64 `json:"uploaded_at"`
+
+	// Stats are the query planning statistics of the block, or nil if unknown. Available
+	// since index version 2, only if the block stats collection is enabled.
+	Stats *BlockStats `json:"stats,omitempty"`
+}
+
+// BlockStats holds the statistics of a block which can be used to select the blocks to query
+// and estimate the cost of a query, without opening the block index-header.
+type BlockStats struct {
+	// Number of series in the block.
+	NumSeries uint64 `json:"num_series"`
+
+	// Number of distinct values of each label name in the block.
+	LabelNames map[string]int `json:"label_names"`
+
+	// Range of the values of the key labels in the block. Key labels not in the block are missing.
+	KeyLabels map[string]LabelValuesRange `json:"key_labels,omitempty"`
+}
+
+// LabelValuesRange holds the lexicographically smallest and largest value of a label.
+type LabelValuesRange struct {
+	Min string `json:"min"`
+	Max string `json:"max"`
 }
 
 // Within returns whether the block contains samples within the provided range.
@@ -104,17 +143,31 @@ func (m *Block) GetUploadedAt() time.Time {
 // The returned meta doesn't include all original meta.json data but only a subset
 // of it.
 func (m *Block) ThanosMeta(userID string) *metadata.Meta {
+	labels := map[string]string{
+		cortex_tsdb.TenantIDExternalLabel: userID,
+	}
+	if m.Rollup {
+		labels[cortex_tsdb.RollupExternalLabel] = "true"
+	}
+
+	var stats tsdb.BlockStats
+	if m.Stats != nil {
+		stats.NumSeries = m.Stats.NumSeries
+	}
+
 	return &metadata.Meta{
 		BlockMeta: tsdb.BlockMeta{
 			ULID:    m.ID,
 			MinTime: m.MinTime,
 			MaxTime: m.MaxTime,
 			Version: metadata.TSDBVersion1,
+			Stats:   stats,
 		},
 		Thanos: metadata.Thanos{
 			Version: metadata.ThanosVersion1,
-			Labels: map[string]string{
-				cortex_tsdb.TenantIDExternalLabel: userID,
+			Labels:  labels,
+			Downsample: metadata.ThanosDownsample{
+				Resolution: m.Resolution,
 			},
 			SegmentFiles: m.thanosMetaSegmentFiles(),
 			IndexStats: metadata.IndexStats{
@@ -153,7 +206,18 @@ func BlockFromThanosMeta(meta metadata.Meta) *Block {
 		SegmentsNum:    segmentsNum,
 		SeriesMaxSize:  meta.Thanos.IndexStats.SeriesMaxSize,
 		ChunkMaxSize:   meta.Thanos.IndexStats.ChunkMaxSize,
+		Resolution:     meta.Thanos.Downsample.Resolution,
+		Rollup:         meta.Thanos.Labels[cortex_tsdb.RollupExternalLabel] != "",
+		Size:           blockSize(meta),
+	}
+}
+
+func blockSize(meta metadata.Meta) int64 {
+	size := int64(0)
+	for _, f := range meta.Thanos.Files {
+		size += f.SizeBytes
 	}
+	return size
 }
 
 func detectBlockSegmentsFormat(meta metadata.Meta) (string, int) {
@@ -268,3 +332,18 @@ func (s Blocks) String() string {
 
 	return b.String()
 }
+
+// Tombstones is a list of series deletion tombstones.
+type Tombstones []*cortex_tsdb.Tombstone
+
+// GetTombstonesForQuery returns the tombstones overlapping the input time range
+// (millis precision, both included).
+func (s Tombstones) GetTombstonesForQuery(minT, maxT int64) Tombstones {
+	var result Tombstones
+	for _, t := range s {
+		if t.IsOverlappingInterval(minT, maxT) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
@@ -2,11 +2,14 @@ package bucketindex
 
 import (
 	"testing"
+	"time"
 
 	"github.com/oklog/ulid"
 	"github.com/prometheus/prometheus/tsdb"
 	"github.com/stretchr/testify/assert"
 	"github.com/thanos-io/thanos/pkg/block/metadata"
+
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
 )
 
 func TestIndex_RemoveBlock(t *testing.T) {
@@ -232,6 +235,74 @@ func TestBlockFromThanosMeta(t *testing.T) {
 				ChunkMaxSize:   1000,
 			},
 		},
+		"meta.json of a downsampled block": {
+			meta: metadata.Meta{
+				BlockMeta: tsdb.BlockMeta{
+					ULID:    blockID,
+					MinTime: 10,
+					MaxTime: 20,
+				},
+				Thanos: metadata.Thanos{
+					Downsample: metadata.ThanosDownsample{
+						Resolution: 300000,
+					},
+				},
+			},
+			expected: Block{
+				ID:             blockID,
+				MinTime:        10,
+				MaxTime:        20,
+				SegmentsFormat: SegmentsFormatUnknown,
+				SegmentsNum:    0,
+				Resolution:     300000,
+			},
+		},
+		"meta.json of a rollup block": {
+			meta: metadata.Meta{
+				BlockMeta: tsdb.BlockMeta{
+					ULID:    blockID,
+					MinTime: 10,
+					MaxTime: 20,
+				},
+				Thanos: metadata.Thanos{
+					Labels: map[string]string{
+						"__rollup__": "true",
+					},
+				},
+			},
+			expected: Block{
+				ID:             blockID,
+				MinTime:        10,
+				MaxTime:        20,
+				SegmentsFormat: SegmentsFormatUnknown,
+				SegmentsNum:    0,
+				Rollup:         true,
+			},
+		},
+		"meta.json with Files size": {
+			meta: metadata.Meta{
+				BlockMeta: tsdb.BlockMeta{
+					ULID:    blockID,
+					MinTime: 10,
+					MaxTime: 20,
+				},
+				Thanos: metadata.Thanos{
+					Files: []metadata.File{
+						{RelPath: "index", SizeBytes: 100},
+						{RelPath: "chunks/000001", SizeBytes: 1000},
+						{RelPath: "meta.json"},
+					},
+				},
+			},
+			expected: Block{
+				ID:             blockID,
+				MinTime:        10,
+				MaxTime:        20,
+				SegmentsFormat: SegmentsFormat1Based6Digits,
+				SegmentsNum:    1,
+				Size:           1100,
+			},
+		},
 	}
 
 	for testName, testData := range tests {
@@ -374,6 +445,54 @@ func TestBlock_ThanosMeta(t *testing.T) {
 				},
 			},
 		},
+		"downsampled block": {
+			block: Block{
+				ID:         blockID,
+				MinTime:    10,
+				MaxTime:    20,
+				Resolution: 3600000,
+			},
+			expected: &metadata.Meta{
+				BlockMeta: tsdb.BlockMeta{
+					ULID:    blockID,
+					MinTime: 10,
+					MaxTime: 20,
+					Version: metadata.TSDBVersion1,
+				},
+				Thanos: metadata.Thanos{
+					Version: metadata.ThanosVersion1,
+					Labels: map[string]string{
+						"__org_id__": userID,
+					},
+					Downsample: metadata.ThanosDownsample{
+						Resolution: 3600000,
+					},
+				},
+			},
+		},
+		"rollup block": {
+			block: Block{
+				ID:      blockID,
+				MinTime: 10,
+				MaxTime: 20,
+				Rollup:  true,
+			},
+			expected: &metadata.Meta{
+				BlockMeta: tsdb.BlockMeta{
+					ULID:    blockID,
+					MinTime: 10,
+					MaxTime: 20,
+					Version: metadata.TSDBVersion1,
+				},
+				Thanos: metadata.Thanos{
+					Version: metadata.ThanosVersion1,
+					Labels: map[string]string{
+						"__org_id__": userID,
+						"__rollup__": "true",
+					},
+				},
+			},
+		},
 	}
 
 	for testName, testData := range tests {
@@ -407,3 +526,14 @@ func TestBlockDeletionMarks_Clone(t *testing.T) {
 	orig[0].DeletionTime = -1
 	assert.Equal(t, int64(1), clone[0].DeletionTime)
 }
+
+func TestTombstones_GetTombstonesForQuery(t *testing.T) {
+	t1 := cortex_tsdb.NewTombstone("user-1", time.Now(), 10, 20, []string{`{a="1"}`})
+	t2 := cortex_tsdb.NewTombstone("user-1", time.Now(), 30, 40, []string{`{a="1"}`})
+	tombstones := Tombstones{t1, t2}
+
+	assert.Equal(t, Tombstones{t1}, tombstones.GetTombstonesForQuery(0, 10))
+	assert.Equal(t, Tombstones{t1, t2}, tombstones.GetTombstonesForQuery(15, 35))
+	assert.Equal(t, Tombstones{t2}, tombstones.GetTombstonesForQuery(40, 50))
+	assert.Empty(t, tombstones.GetTombstonesForQuery(21, 29))
+}
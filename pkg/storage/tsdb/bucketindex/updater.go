@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/go-kit/log"
@@ -13,6 +14,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/thanos-io/objstore"
 	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/indexheader"
 	"github.com/thanos-io/thanos/pkg/block/metadata"
 
 	"github.com/cortexproject/cortex/pkg/storage/tsdb"
@@ -31,10 +33,19 @@ var (
 	errBlockMetaKeyAccessDeniedErr = errors.New("block meta file key access denied error")
 )
 
+// The block stats are computed iterating over all the label values, so there's no benefit
+// in keeping more postings offsets in memory than the store-gateway default.
+const blockStatsPostingOffsetsInMemSampling = 32
+
 // Updater is responsible to generate an update in-memory bucket index.
 type Updater struct {
 	bkt    objstore.InstrumentedBucket
 	logger log.Logger
+
+	// Whether the query planning statistics of the blocks are collected, and the labels
+	// whose values range is tracked.
+	blockStatsEnabled   bool
+	blockStatsKeyLabels []string
 }
 
 func NewUpdater(bkt objstore.Bucket, userID string, cfgProvider bucket.TenantConfigProvider, logger log.Logger) *Updater {
@@ -44,6 +55,15 @@ func NewUpdater(bkt objstore.Bucket, userID string, cfgProvider bucket.TenantCon
 	}
 }
 
+// EnableBlockStats configures the updater to collect the query planning statistics of the
+// blocks, reading the index-header of the blocks not having them in the old index yet.
+// The index is generated with version 2.
+func (w *Updater) EnableBlockStats(keyLabels []string) *Updater {
+	w.blockStatsEnabled = true
+	w.blockStatsKeyLabels = keyLabels
+	return w
+}
+
 // UpdateIndex generates the bucket index and returns it, without storing it to the storage.
 // If the old index is not passed in input, then the bucket index will be generated from scratch.
 func (w *Updater) UpdateIndex(ctx context.Context, old *Index) (*Index, map[ulid.ULID]error, int64, error) {
@@ -66,10 +86,21 @@ func (w *Updater) UpdateIndex(ctx context.Context, old *Index) (*Index, map[ulid
 		return nil, nil, 0, err
 	}
 
+	tombstones, err := w.updateTombstones(ctx)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	version := IndexVersion1
+	if w.blockStatsEnabled {
+		version = IndexVersion2
+	}
+
 	return &Index{
-		Version:            IndexVersion1,
+		Version:            version,
 		Blocks:             blocks,
 		BlockDeletionMarks: blockDeletionMarks,
+		Tombstones:         tombstones,
 		UpdatedAt:          time.Now().Unix(),
 	}, partials, totalBlocksBlocksMarkedForNoCompaction, nil
 }
@@ -98,6 +129,9 @@ func (w *Updater) updateBlocks(ctx context.Context, old []*Block, deletedBlocks
 				level.Warn(w.logger).Log("msg", "skipped block with missing global deletion marker", "block", b.ID.String())
 				continue
 			}
+			if w.blockStatsEnabled && b.Stats == nil {
+				b = w.backfillBlockStats(ctx, b)
+			}
 			blocks = append(blocks, b)
 		}
 	}
@@ -177,9 +211,85 @@ func (w *Updater) updateBlockIndexEntry(ctx context.Context, id ulid.ULID) (*Blo
 	// the block has completed to be uploaded.
 	block.UploadedAt = attrs.LastModified.Unix()
 
+	if w.blockStatsEnabled {
+		block.Stats = w.collectBlockStats(ctx, id, m.Stats.NumSeries)
+	}
+
 	return block, nil
 }
 
+// backfillBlockStats returns a copy of the block with the query planning statistics, for the blocks
+// added to the index before the block stats collection has been enabled.
+func (w *Updater) backfillBlockStats(ctx context.Context, b *Block) *Block {
+	m, err := block.DownloadMeta(ctx, w.logger, w.bkt, b.ID)
+	if err != nil {
+		level.Warn(w.logger).Log("msg", "failed to read block meta to collect block stats", "block", b.ID.String(), "err", err)
+		return b
+	}
+
+	out := *b
+	out.Stats = w.collectBlockStats(ctx, b.ID, m.Stats.NumSeries)
+	return &out
+}
+
+// collectBlockStats returns the query planning statistics of the block, read from its index-header.
+// Blocks are immutable, so the statistics are collected only once for each block, unless they can't
+// be read: in this case nil is returned and they're collected again at the next update.
+func (w *Updater) collectBlockStats(ctx context.Context, id ulid.ULID, numSeries uint64) *BlockStats {
+	stats, err := w.readBlockStats(ctx, id)
+	if err != nil {
+		level.Warn(w.logger).Log("msg", "failed to read block index-header to collect block stats", "block", id.String(), "err", err)
+		return nil
+	}
+
+	stats.NumSeries = numSeries
+	return stats
+}
+
+func (w *Updater) readBlockStats(ctx context.Context, id ulid.ULID) (*BlockStats, error) {
+	// The index-header is built in memory and not cached on disk, given it's read only once.
+	r, err := indexheader.NewBinaryReader(ctx, w.logger, w.bkt, "", id, blockStatsPostingOffsetsInMemSampling, indexheader.NewBinaryReaderMetrics(nil))
+	if err != nil {
+		return nil, err
+	}
+	defer runutil.CloseWithLogOnErr(w.logger, r, "close block index-header reader")
+
+	names, err := r.LabelNames()
+	if err != nil {
+		return nil, errors.Wrap(err, "read label names")
+	}
+
+	stats := &BlockStats{LabelNames: make(map[string]int, len(names))}
+	for _, name := range names {
+		values, err := r.LabelValues(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read label %s values", name)
+		}
+		stats.LabelNames[name] = len(values)
+	}
+
+	for _, name := range w.blockStatsKeyLabels {
+		// Label values are sorted in the index.
+		values, err := r.LabelValues(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read label %s values", name)
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if stats.KeyLabels == nil {
+			stats.KeyLabels = map[string]LabelValuesRange{}
+		}
+		// The values reference the index-header buffer, so they're copied.
+		stats.KeyLabels[name] = LabelValuesRange{
+			Min: strings.Clone(values[0]),
+			Max: strings.Clone(values[len(values)-1]),
+		}
+	}
+
+	return stats, nil
+}
+
 func (w *Updater) updateBlockMarks(ctx context.Context, old []*BlockDeletionMark) ([]*BlockDeletionMark, map[ulid.ULID]struct{}, int64, error) {
 	out := make([]*BlockDeletionMark, 0, len(old))
 	deletedBlocks := map[ulid.ULID]struct{}{}
@@ -249,3 +359,26 @@ func (w *Updater) updateBlockDeletionMarkIndexEntry(ctx context.Context, id ulid
 
 	return BlockDeletionMarkFromThanosMarker(&m), nil
 }
+
+// updateTombstones returns the series deletion tombstones which should be applied at query time.
+func (w *Updater) updateTombstones(ctx context.Context) (Tombstones, error) {
+	all, err := tsdb.GetAllTombstones(ctx, w.bkt)
+	if err != nil {
+		return nil, err
+	}
+
+	return FilterQueryableTombstones(all), nil
+}
+
+// FilterQueryableTombstones returns the tombstones which should be applied at query time,
+// excluding the cancelled ones.
+func FilterQueryableTombstones(all []*tsdb.Tombstone) Tombstones {
+	var tombstones Tombstones
+	for _, t := range all {
+		if t.State == tsdb.StateCancelled {
+			continue
+		}
+		tombstones = append(tombstones, t)
+	}
+	return tombstones
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"path"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -13,6 +14,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/tsdb"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -21,6 +23,7 @@ import (
 	"github.com/thanos-io/thanos/pkg/block/metadata"
 
 	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
 	"github.com/cortexproject/cortex/pkg/storage/tsdb/testutil"
 )
 
@@ -301,6 +304,88 @@ func TestUpdater_UpdateIndex_NoTenantInTheBucket(t *testing.T) {
 	}
 }
 
+func TestUpdater_UpdateIndex_ShouldIncludeQueryableTombstones(t *testing.T) {
+	const userID = "user-1"
+
+	ctx := context.Background()
+	bkt, _ := testutil.PrepareFilesystemBucket(t)
+	userBkt := bucket.NewUserBucketClient(userID, bkt, nil)
+
+	now := time.Now()
+	pending := cortex_tsdb.NewTombstone(userID, now.Add(-2*time.Hour), 10, 20, []string{`{a="1"}`})
+	processed := cortex_tsdb.NewTombstone(userID, now.Add(-time.Hour), 20, 30, []string{`{b="2"}`})
+	processed.State = cortex_tsdb.StateProcessed
+	cancelled := cortex_tsdb.NewTombstone(userID, now, 30, 40, []string{`{c="3"}`})
+	cancelled.State = cortex_tsdb.StateCancelled
+
+	for _, tombstone := range []*cortex_tsdb.Tombstone{pending, processed, cancelled} {
+		require.NoError(t, cortex_tsdb.WriteTombstone(ctx, userBkt, tombstone))
+	}
+
+	w := NewUpdater(bkt, userID, nil, log.NewNopLogger())
+	idx, _, _, err := w.UpdateIndex(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, Tombstones{pending, processed}, idx.Tombstones)
+}
+
+func TestUpdater_UpdateIndex_ShouldCollectBlockStats(t *testing.T) {
+	const userID = "user-1"
+
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+	bkt, _ := testutil.PrepareFilesystemBucket(t)
+
+	// Write a block with a real index, and a block without index.
+	blocksDir := t.TempDir()
+	writer, err := tsdb.NewBlockWriter(logger, blocksDir, 2*time.Hour.Milliseconds())
+	require.NoError(t, err)
+	app := writer.Appender(ctx)
+	for _, lbls := range []labels.Labels{
+		labels.FromStrings(labels.MetricName, "up", "job", "a", "zone", "z1"),
+		labels.FromStrings(labels.MetricName, "up", "job", "b"),
+		labels.FromStrings(labels.MetricName, "cpu", "job", "c", "zone", "z2"),
+	} {
+		_, err := app.Append(0, lbls, 10, 1)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+	block1, err := writer.Flush(ctx)
+	require.NoError(t, err)
+	require.NoError(t, objstore.UploadDir(ctx, logger, bkt, filepath.Join(blocksDir, block1.String()), path.Join(userID, block1.String())))
+	require.NoError(t, writer.Close())
+
+	block2 := testutil.MockStorageBlock(t, bkt, userID, 20, 30)
+
+	// The stats are not collected by default.
+	idx, _, _, err := NewUpdater(bkt, userID, nil, logger).UpdateIndex(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, IndexVersion1, idx.Version)
+	require.Len(t, idx.Blocks, 2)
+	for _, b := range idx.Blocks {
+		assert.Nil(t, b.Stats)
+	}
+
+	// The stats of the blocks already in the index are backfilled, while the block without
+	// index is kept in the index without stats.
+	idx, _, _, err = NewUpdater(bkt, userID, nil, logger).EnableBlockStats([]string{"zone", "missing"}).UpdateIndex(ctx, idx)
+	require.NoError(t, err)
+	assert.Equal(t, IndexVersion2, idx.Version)
+	require.Len(t, idx.Blocks, 2)
+
+	for _, b := range idx.Blocks {
+		if b.ID == block2.ULID {
+			assert.Nil(t, b.Stats)
+			continue
+		}
+		assert.Equal(t, &BlockStats{
+			NumSeries:  3,
+			LabelNames: map[string]int{labels.MetricName: 2, "job": 3, "zone": 2},
+			KeyLabels:  map[string]LabelValuesRange{"zone": {Min: "z1", Max: "z2"}},
+		}, b.Stats)
+		assert.Equal(t, uint64(3), b.ThanosMeta(userID).Stats.NumSeries)
+	}
+}
+
 func getBlockUploadedAt(t testing.TB, bkt objstore.Bucket, userID string, blockID ulid.ULID) int64 {
 	metaFile := path.Join(userID, blockID.String(), block.MetaFilename)
 
@@ -0,0 +1,276 @@
+package tsdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/cache"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+)
+
+const CacheGenerationFile = "cache-generation.json"
+
+var errInvalidCacheGenerationRefreshInterval = errors.New("the cache generation refresh interval must be greater than 0")
+
+// CacheGenerationConfig configures the versioning of the cache keys by tenant.
+type CacheGenerationConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+}
+
+func (cfg *CacheGenerationConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+"enabled", false, "[Experimental] True to version the keys of the results cache and the chunks and metadata caches with the cache generation of the tenant, so that bumping the generation with the cache generation API invalidates all the cached items of the tenant.")
+	f.DurationVar(&cfg.RefreshInterval, prefix+"refresh-interval", time.Minute, "[Experimental] How frequently the cache generations of the tenants are reloaded from the bucket. The cached items of a tenant are invalidated within this interval after bumping its generation.")
+}
+
+// Validate the config.
+func (cfg *CacheGenerationConfig) Validate() error {
+	if cfg.Enabled && cfg.RefreshInterval <= 0 {
+		return errInvalidCacheGenerationRefreshInterval
+	}
+	return nil
+}
+
+// CacheGeneration is the generation of the cached items of a tenant. The items cached with a previous generation are
+// never read anymore.
+type CacheGeneration struct {
+	Generation int64 `json:"generation"`
+
+	// Unix timestamp when the generation was bumped.
+	UpdatedAt int64 `json:"updated_at"`
+}
+
+func GetCacheGenerationPath(userID string) string {
+	return path.Join(util.GlobalMarkersDir, userID, CacheGenerationFile)
+}
+
+// ReadCacheGeneration returns the cache generation of the tenant, 0 if it has never been bumped.
+func ReadCacheGeneration(ctx context.Context, bkt objstore.InstrumentedBucketReader, userID string) (int64, error) {
+	generationFile := GetCacheGenerationPath(userID)
+	r, err := bkt.ReaderWithExpectedErrs(bkt.IsObjNotFoundErr).Get(ctx, generationFile)
+	if err != nil {
+		if bkt.IsObjNotFoundErr(err) {
+			return 0, nil
+		}
+		return 0, errors.Wrapf(err, "failed to read cache generation object: %s", generationFile)
+	}
+
+	generation := CacheGeneration{}
+	err = json.NewDecoder(r).Decode(&generation)
+
+	// Close reader before dealing with decode error.
+	if closeErr := r.Close(); closeErr != nil {
+		level.Warn(util_log.Logger).Log("msg", "failed to close bucket reader", "err", closeErr)
+	}
+
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to decode cache generation object: %s", generationFile)
+	}
+	return generation.Generation, nil
+}
+
+// BumpCacheGeneration increments the cache generation of the tenant, and returns the new generation. The concurrent
+// bumps of the generation of a tenant may be merged into one.
+func BumpCacheGeneration(ctx context.Context, bkt objstore.InstrumentedBucket, userID string, now time.Time) (int64, error) {
+	generation, err := ReadCacheGeneration(ctx, bkt, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := json.Marshal(CacheGeneration{Generation: generation + 1, UpdatedAt: now.Unix()})
+	if err != nil {
+		return 0, errors.Wrap(err, "serialize cache generation")
+	}
+	if err := bkt.Upload(ctx, GetCacheGenerationPath(userID), bytes.NewReader(data)); err != nil {
+		return 0, errors.Wrap(err, "upload cache generation")
+	}
+	return generation + 1, nil
+}
+
+// CacheGenerationLoader loads and caches the cache generations of the tenants. The first lookup of a tenant reads its
+// generation from the bucket, then the generation is reloaded in the background every refresh interval, while the
+// previous one keeps being returned.
+type CacheGenerationLoader struct {
+	bkt             objstore.InstrumentedBucketReader
+	refreshInterval time.Duration
+	logger          log.Logger
+
+	mtx         sync.Mutex
+	generations map[string]*cachedGeneration
+
+	loadFailures prometheus.Counter
+}
+
+type cachedGeneration struct {
+	generation int64
+	loadedAt   time.Time
+	refreshing bool
+}
+
+func NewCacheGenerationLoader(bkt objstore.InstrumentedBucketReader, refreshInterval time.Duration, logger log.Logger, reg prometheus.Registerer) *CacheGenerationLoader {
+	return &CacheGenerationLoader{
+		bkt:             bkt,
+		refreshInterval: refreshInterval,
+		logger:          logger,
+		generations:     map[string]*cachedGeneration{},
+		loadFailures: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_cache_generation_load_failures_total",
+			Help: "Total number of failures loading the cache generation of a tenant.",
+		}),
+	}
+}
+
+// Generation returns the cache generation of the tenant. The generation is 0 when it has never been bumped, or when
+// it couldn't be loaded.
+func (l *CacheGenerationLoader) Generation(ctx context.Context, userID string) int64 {
+	l.mtx.Lock()
+	cached, ok := l.generations[userID]
+	if ok {
+		generation := cached.generation
+		if !cached.refreshing && time.Since(cached.loadedAt) >= l.refreshInterval {
+			cached.refreshing = true
+			go l.refresh(userID)
+		}
+		l.mtx.Unlock()
+		return generation
+	}
+	l.mtx.Unlock()
+
+	// The failed loads are cached too, so that they're retried after the refresh interval instead of on each lookup.
+	generation, _ := l.load(ctx, userID)
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if cached, ok := l.generations[userID]; ok {
+		// Loaded concurrently.
+		return cached.generation
+	}
+	l.generations[userID] = &cachedGeneration{generation: generation, loadedAt: time.Now()}
+	return generation
+}
+
+func (l *CacheGenerationLoader) refresh(userID string) {
+	generation, err := l.load(context.Background(), userID)
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	cached := l.generations[userID]
+	cached.refreshing = false
+	cached.loadedAt = time.Now()
+	if err == nil {
+		cached.generation = generation
+	}
+}
+
+func (l *CacheGenerationLoader) load(ctx context.Context, userID string) (int64, error) {
+	generation, err := ReadCacheGeneration(ctx, l.bkt, userID)
+	if err != nil {
+		l.loadFailures.Inc()
+		level.Warn(l.logger).Log("msg", "failed to load the cache generation", "user", userID, "err", err)
+	}
+	return generation, err
+}
+
+// CacheGenerationKeyPrefix returns the prefix of the cache keys of the items cached with the generation, empty for
+// the generation 0 so that enabling the versioning doesn't invalidate the cached items.
+func CacheGenerationKeyPrefix(generation int64) string {
+	if generation == 0 {
+		return ""
+	}
+	return fmt.Sprintf("g%d:", generation)
+}
+
+// trimCacheGenerationKeyPrefix returns the bucket cache key without its cache generation prefix, if any.
+func trimCacheGenerationKeyPrefix(key string) string {
+	prefix, rest, ok := strings.Cut(key, ":")
+	if !ok || len(prefix) < 2 || prefix[0] != 'g' {
+		return key
+	}
+	if _, err := strconv.ParseInt(prefix[1:], 10, 64); err != nil {
+		return key
+	}
+	return rest
+}
+
+// tenantOfBucketCacheKey returns the tenant whose object is cached with the bucket cache key, empty if the object
+// doesn't belong to a tenant.
+func tenantOfBucketCacheKey(key string) string {
+	_, name, ok := strings.Cut(key, ":")
+	if !ok {
+		return ""
+	}
+	userID, rest, ok := strings.Cut(name, "/")
+	if !ok {
+		return ""
+	}
+	if userID == util.GlobalMarkersDir {
+		userID, _, ok = strings.Cut(rest, "/")
+		if !ok {
+			return ""
+		}
+	}
+	return userID
+}
+
+// generationBucketCache is a cache of the caching bucket prefixing the keys of the items of each tenant with its
+// cache generation.
+type generationBucketCache struct {
+	cache.Cache
+
+	generations *CacheGenerationLoader
+}
+
+func newGenerationBucketCache(c cache.Cache, generations *CacheGenerationLoader) cache.Cache {
+	return &generationBucketCache{Cache: c, generations: generations}
+}
+
+// Store implements cache.Cache.
+func (c *generationBucketCache) Store(data map[string][]byte, ttl time.Duration) {
+	versioned := make(map[string][]byte, len(data))
+	for key, value := range data {
+		versioned[c.versionedKey(context.Background(), key)] = value
+	}
+	c.Cache.Store(versioned, ttl)
+}
+
+// Fetch implements cache.Cache.
+func (c *generationBucketCache) Fetch(ctx context.Context, keys []string) map[string][]byte {
+	versionedKeys := make([]string, 0, len(keys))
+	originalKeys := make(map[string]string, len(keys))
+	for _, key := range keys {
+		versionedKey := c.versionedKey(ctx, key)
+		versionedKeys = append(versionedKeys, versionedKey)
+		originalKeys[versionedKey] = key
+	}
+
+	hits := c.Cache.Fetch(ctx, versionedKeys)
+	result := make(map[string][]byte, len(hits))
+	for versionedKey, value := range hits {
+		result[originalKeys[versionedKey]] = value
+	}
+	return result
+}
+
+func (c *generationBucketCache) versionedKey(ctx context.Context, key string) string {
+	userID := tenantOfBucketCacheKey(key)
+	if userID == "" {
+		return key
+	}
+	return CacheGenerationKeyPrefix(c.generations.Generation(ctx, userID)) + key
+}
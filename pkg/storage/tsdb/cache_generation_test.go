@@ -0,0 +1,101 @@
+package tsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+)
+
+func TestBumpCacheGeneration(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.WithNoopInstr(objstore.NewInMemBucket())
+
+	generation, err := ReadCacheGeneration(ctx, bkt, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), generation)
+
+	for expected := int64(1); expected <= 3; expected++ {
+		generation, err = BumpCacheGeneration(ctx, bkt, "user-1", time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, expected, generation)
+	}
+
+	generation, err = ReadCacheGeneration(ctx, bkt, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), generation)
+
+	generation, err = ReadCacheGeneration(ctx, bkt, "user-2")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), generation)
+}
+
+func TestCacheGenerationLoader_ShouldReloadTheGenerationsInTheBackground(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.WithNoopInstr(objstore.NewInMemBucket())
+	loader := NewCacheGenerationLoader(bkt, 100*time.Millisecond, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+
+	assert.Equal(t, int64(0), loader.Generation(ctx, "user-1"))
+
+	_, err := BumpCacheGeneration(ctx, bkt, "user-1", time.Now())
+	require.NoError(t, err)
+
+	// The previous generation is returned until it's reloaded.
+	assert.Equal(t, int64(0), loader.Generation(ctx, "user-1"))
+	assert.Eventually(t, func() bool {
+		return loader.Generation(ctx, "user-1") == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestTenantOfBucketCacheKey(t *testing.T) {
+	for key, expected := range map[string]string{
+		"content:user-1/01EQK4QKFHVSZYVJ908Y7HH9E0/meta.json":              "user-1",
+		"subrange:user-1/01EQK4QKFHVSZYVJ908Y7HH9E0/chunks/000001:0:16000": "user-1",
+		"iter:user-1/": "user-1",
+		"iter:":        "",
+		"exists:__markers__/user-1/cache-generation": "user-1",
+		"iter:__markers__/":                          "",
+	} {
+		assert.Equal(t, expected, tenantOfBucketCacheKey(key), key)
+	}
+}
+
+func TestTrimCacheGenerationKeyPrefix(t *testing.T) {
+	assert.Equal(t, "content:user-1/meta.json", trimCacheGenerationKeyPrefix("g12:content:user-1/meta.json"))
+	assert.Equal(t, "content:user-1/meta.json", trimCacheGenerationKeyPrefix("content:user-1/meta.json"))
+}
+
+func TestGenerationBucketCache_ShouldInvalidateTheItemsOfTheTenantWhenBumpingItsGeneration(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.WithNoopInstr(objstore.NewInMemBucket())
+	loader := NewCacheGenerationLoader(bkt, 0, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	backend := newMockBucketCache()
+	c := newGenerationBucketCache(backend, loader)
+
+	items := map[string][]byte{
+		"content:user-1/meta.json": []byte("1"),
+		"content:user-2/meta.json": []byte("2"),
+		"iter:":                    []byte("3"),
+	}
+	keys := []string{"content:user-1/meta.json", "content:user-2/meta.json", "iter:"}
+	c.Store(items, time.Minute)
+	assert.Equal(t, items, c.Fetch(ctx, keys))
+
+	_, err := BumpCacheGeneration(ctx, bkt, "user-1", time.Now())
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return loader.Generation(ctx, "user-1") == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Only the items of the tenant are invalidated.
+	assert.Equal(t, map[string][]byte{"content:user-2/meta.json": []byte("2"), "iter:": []byte("3")}, c.Fetch(ctx, keys))
+
+	c.Store(map[string][]byte{"content:user-1/meta.json": []byte("4")}, time.Minute)
+	assert.Equal(t, []byte("4"), c.Fetch(ctx, keys)["content:user-1/meta.json"])
+	assert.Contains(t, backend.get(), "g1:content:user-1/meta.json")
+}
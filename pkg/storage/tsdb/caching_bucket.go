@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/alecthomas/units"
 	"github.com/go-kit/log"
 	"github.com/golang/snappy"
 	"github.com/oklog/ulid"
@@ -18,30 +19,111 @@ import (
 	"github.com/thanos-io/thanos/pkg/block/metadata"
 	"github.com/thanos-io/thanos/pkg/cache"
 	"github.com/thanos-io/thanos/pkg/cacheutil"
+	"github.com/thanos-io/thanos/pkg/model"
 	storecache "github.com/thanos-io/thanos/pkg/store/cache"
+	"golang.org/x/exp/slices"
+
+	chunkcache "github.com/cortexproject/cortex/pkg/chunk/cache"
+	"github.com/cortexproject/cortex/pkg/util/compression"
 )
 
 const (
+	CacheBackendInMemory  = "inmemory"
 	CacheBackendMemcached = "memcached"
 	CacheBackendRedis     = "redis"
+	// CacheBackendGroupcache is the embedded distributed cache, shared by the instances with groupcache peers.
+	CacheBackendGroupcache = "groupcache"
+	// CacheBackendDisk is the cache on a local disk of each instance.
+	CacheBackendDisk = "disk"
+)
+
+var (
+	supportedBucketCacheBackends = []string{CacheBackendInMemory, CacheBackendMemcached, CacheBackendRedis, CacheBackendGroupcache, CacheBackendDisk}
+
+	errDuplicatedBucketCacheBackend = errors.New("duplicated bucket cache backend")
 )
 
 type CacheBackend struct {
-	Backend   string                `yaml:"backend"`
-	Memcached MemcachedClientConfig `yaml:"memcached"`
-	Redis     RedisClientConfig     `yaml:"redis"`
+	Backend    string                      `yaml:"backend"`
+	InMemory   InMemoryBucketCacheConfig   `yaml:"inmemory"`
+	Memcached  MemcachedClientConfig       `yaml:"memcached"`
+	Redis      RedisClientConfig           `yaml:"redis"`
+	Groupcache GroupcacheBucketCacheConfig `yaml:"groupcache"`
+	Disk       DiskCacheConfig             `yaml:"disk"`
+	MultiLevel MultiLevelBucketCacheConfig `yaml:"multilevel"`
+
+	// Injected at runtime when the cache generations are enabled.
+	CacheGenerations *CacheGenerationLoader `yaml:"-"`
 }
 
 // Validate the config.
 func (cfg *CacheBackend) Validate() error {
-	switch cfg.Backend {
-	case CacheBackendMemcached:
-		return cfg.Memcached.Validate()
-	case CacheBackendRedis:
-		return cfg.Redis.Validate()
-	case "":
-	default:
-		return fmt.Errorf("unsupported cache backend: %s", cfg.Backend)
+	if cfg.Backend == "" {
+		return nil
+	}
+
+	splitBackends := strings.Split(cfg.Backend, ",")
+	configuredBackends := map[string]struct{}{}
+
+	if len(splitBackends) > 1 {
+		if err := cfg.MultiLevel.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for i, backend := range splitBackends {
+		if _, ok := configuredBackends[backend]; ok {
+			return errors.WithMessagef(errDuplicatedBucketCacheBackend, "duplicated backend: %v", backend)
+		}
+		configuredBackends[backend] = struct{}{}
+
+		var err error
+		switch backend {
+		case CacheBackendInMemory:
+			err = cfg.InMemory.Validate()
+		case CacheBackendMemcached:
+			err = cfg.Memcached.Validate()
+		case CacheBackendRedis:
+			err = cfg.Redis.Validate()
+		case CacheBackendGroupcache:
+			if i != len(splitBackends)-1 {
+				return errGroupcacheNotLastLevel
+			}
+			err = cfg.Groupcache.Validate()
+		case CacheBackendDisk:
+			err = cfg.Disk.Validate()
+		default:
+			err = fmt.Errorf("unsupported cache backend: %s", backend)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UsesBackend returns whether the backend is one of the levels of the cache.
+func (cfg *CacheBackend) UsesBackend(backend string) bool {
+	return cfg.Backend != "" && slices.Contains(strings.Split(cfg.Backend, ","), backend)
+}
+
+type InMemoryBucketCacheConfig struct {
+	MaxSizeBytes     uint64 `yaml:"max_size_bytes"`
+	MaxItemSizeBytes uint64 `yaml:"max_item_size_bytes"`
+}
+
+func (cfg *InMemoryBucketCacheConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string, defaultMaxSizeBytes uint64) {
+	f.Uint64Var(&cfg.MaxSizeBytes, prefix+"max-size-bytes", defaultMaxSizeBytes, "Maximum size in bytes of the in-memory cache (shared between all tenants).")
+	f.Uint64Var(&cfg.MaxItemSizeBytes, prefix+"max-item-size-bytes", 0, "Maximum size in bytes of an item stored in the in-memory cache. The larger items aren't stored, so that they don't evict many smaller items, but can still be stored in the next levels of a multi-level cache. 0 to use the max size of the cache.")
+}
+
+// Validate the config.
+func (cfg *InMemoryBucketCacheConfig) Validate() error {
+	if cfg.MaxSizeBytes == 0 {
+		return errors.New("the in-memory cache max size must be greater than 0")
+	}
+	if cfg.MaxItemSizeBytes > cfg.MaxSizeBytes {
+		return errors.New("the in-memory cache max item size must not be greater than the max size")
 	}
 	return nil
 }
@@ -53,21 +135,36 @@ type ChunksCacheConfig struct {
 	MaxGetRangeRequests int           `yaml:"max_get_range_requests"`
 	AttributesTTL       time.Duration `yaml:"attributes_ttl"`
 	SubrangeTTL         time.Duration `yaml:"subrange_ttl"`
+
+	Compression compression.Config `yaml:"compression"`
 }
 
 func (cfg *ChunksCacheConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string) {
-	f.StringVar(&cfg.Backend, prefix+"backend", "", fmt.Sprintf("Backend for chunks cache, if not empty. Supported values: %s.", CacheBackendMemcached))
+	f.StringVar(&cfg.Backend, prefix+"backend", "", fmt.Sprintf("Backend for chunks cache, if not empty. "+
+		"Multiple cache backend can be provided as a comma-separated ordered list to enable the implementation of a cache hierarchy. "+
+		"Supported values: %s.", strings.Join(supportedBucketCacheBackends, ", ")))
 
+	cfg.InMemory.RegisterFlagsWithPrefix(f, prefix+"inmemory.", uint64(units.Gibibyte))
 	cfg.Memcached.RegisterFlagsWithPrefix(f, prefix+"memcached.")
 	cfg.Redis.RegisterFlagsWithPrefix(f, prefix+"redis.")
+	cfg.Groupcache.RegisterFlagsWithPrefix(f, prefix+"groupcache.", uint64(units.Gibibyte))
+	cfg.Disk.RegisterFlagsWithPrefix(f, prefix+"disk.")
+	cfg.MultiLevel.RegisterFlagsWithPrefix(f, prefix+"multilevel.")
 
 	f.Int64Var(&cfg.SubrangeSize, prefix+"subrange-size", 16000, "Size of each subrange that bucket object is split into for better caching.")
 	f.IntVar(&cfg.MaxGetRangeRequests, prefix+"max-get-range-requests", 3, "Maximum number of sub-GetRange requests that a single GetRange request can be split into when fetching chunks. Zero or negative value = unlimited number of sub-requests.")
 	f.DurationVar(&cfg.AttributesTTL, prefix+"attributes-ttl", 168*time.Hour, "TTL for caching object attributes for chunks.")
 	f.DurationVar(&cfg.SubrangeTTL, prefix+"subrange-ttl", 24*time.Hour, "TTL for caching individual chunks subranges.")
+	cfg.Compression.RegisterFlagsWithPrefix(f, prefix+"compression.", "chunks subranges")
 }
 
 func (cfg *ChunksCacheConfig) Validate() error {
+	if err := cfg.Compression.Validate(); err != nil {
+		return errors.Wrap(err, "chunks cache compression")
+	}
+	if cfg.Compression.Enabled() && cfg.UsesBackend(CacheBackendGroupcache) {
+		return errGroupcacheCompression
+	}
 	return cfg.CacheBackend.Validate()
 }
 
@@ -85,13 +182,21 @@ type MetadataCacheConfig struct {
 	BlockIndexAttributesTTL time.Duration `yaml:"block_index_attributes_ttl"`
 	BucketIndexContentTTL   time.Duration `yaml:"bucket_index_content_ttl"`
 	BucketIndexMaxSize      int           `yaml:"bucket_index_max_size_bytes"`
+
+	TTLPolicy chunkcache.TTLPolicyConfig `yaml:",inline"`
 }
 
 func (cfg *MetadataCacheConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string) {
-	f.StringVar(&cfg.Backend, prefix+"backend", "", fmt.Sprintf("Backend for metadata cache, if not empty. Supported values: %s.", CacheBackendMemcached))
+	f.StringVar(&cfg.Backend, prefix+"backend", "", fmt.Sprintf("Backend for metadata cache, if not empty. "+
+		"Multiple cache backend can be provided as a comma-separated ordered list to enable the implementation of a cache hierarchy. "+
+		"Supported values: %s.", strings.Join(supportedBucketCacheBackends, ", ")))
 
+	cfg.InMemory.RegisterFlagsWithPrefix(f, prefix+"inmemory.", uint64(256*units.Mebibyte))
 	cfg.Memcached.RegisterFlagsWithPrefix(f, prefix+"memcached.")
 	cfg.Redis.RegisterFlagsWithPrefix(f, prefix+"redis.")
+	cfg.Groupcache.RegisterFlagsWithPrefix(f, prefix+"groupcache.", uint64(256*units.Mebibyte))
+	cfg.Disk.RegisterFlagsWithPrefix(f, prefix+"disk.")
+	cfg.MultiLevel.RegisterFlagsWithPrefix(f, prefix+"multilevel.")
 
 	f.DurationVar(&cfg.TenantsListTTL, prefix+"tenants-list-ttl", 15*time.Minute, "How long to cache list of tenants in the bucket.")
 	f.DurationVar(&cfg.TenantBlocksListTTL, prefix+"tenant-blocks-list-ttl", 5*time.Minute, "How long to cache list of blocks for each tenant.")
@@ -104,9 +209,16 @@ func (cfg *MetadataCacheConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix
 	f.DurationVar(&cfg.BlockIndexAttributesTTL, prefix+"block-index-attributes-ttl", 168*time.Hour, "How long to cache attributes of the block index.")
 	f.DurationVar(&cfg.BucketIndexContentTTL, prefix+"bucket-index-content-ttl", 5*time.Minute, "How long to cache content of the bucket index.")
 	f.IntVar(&cfg.BucketIndexMaxSize, prefix+"bucket-index-max-size-bytes", 1*1024*1024, "Maximum size of bucket index content to cache in bytes. Caching will be skipped if the content exceeds this size. This is useful to avoid network round trip for large content if the configured caching backend has an hard limit on cached items size (in this case, you should set this limit to the same limit in the caching backend).")
+	cfg.TTLPolicy.RegisterFlagsWithPrefix(prefix, "", f)
 }
 
 func (cfg *MetadataCacheConfig) Validate() error {
+	if err := cfg.TTLPolicy.Validate(); err != nil {
+		return errors.Wrap(err, "metadata cache")
+	}
+	if cfg.TTLPolicy.Enabled() && cfg.UsesBackend(CacheBackendGroupcache) {
+		return errGroupcacheTTLPolicy
+	}
 	return cfg.CacheBackend.Validate()
 }
 
@@ -114,22 +226,35 @@ func CreateCachingBucket(chunksConfig ChunksCacheConfig, metadataConfig Metadata
 	cfg := cache.NewCachingBucketConfig()
 	cachingConfigured := false
 
-	chunksCache, err := createCache("chunks-cache", &chunksConfig.CacheBackend, logger, reg)
+	chunksCache, err := createCache("chunks-cache", &chunksConfig.CacheBackend, bkt, cfg, logger, reg)
 	if err != nil {
 		return nil, errors.Wrapf(err, "chunks-cache")
 	}
 	if chunksCache != nil {
 		cachingConfigured = true
+		chunksCache, err = newCompressedBucketCache(chunksCache, chunksConfig.Compression, logger)
+		if err != nil {
+			return nil, errors.Wrapf(err, "chunks-cache")
+		}
+		if chunksConfig.CacheGenerations != nil {
+			chunksCache = newGenerationBucketCache(chunksCache, chunksConfig.CacheGenerations)
+		}
 		chunksCache = cache.NewTracingCache(chunksCache)
 		cfg.CacheGetRange("chunks", chunksCache, matchers.GetChunksMatcher(), chunksConfig.SubrangeSize, chunksConfig.AttributesTTL, chunksConfig.SubrangeTTL, chunksConfig.MaxGetRangeRequests)
 	}
 
-	metadataCache, err := createCache("metadata-cache", &metadataConfig.CacheBackend, logger, reg)
+	metadataCache, err := createCache("metadata-cache", &metadataConfig.CacheBackend, bkt, cfg, logger, reg)
 	if err != nil {
 		return nil, errors.Wrapf(err, "metadata-cache")
 	}
 	if metadataCache != nil {
 		cachingConfigured = true
+		if metadataConfig.TTLPolicy.Enabled() {
+			metadataCache = newTTLPolicyBucketCache(metadataCache, chunkcache.NewTTLPolicy("metadata-cache", metadataConfig.TTLPolicy, reg))
+		}
+		if metadataConfig.CacheGenerations != nil {
+			metadataCache = newGenerationBucketCache(metadataCache, metadataConfig.CacheGenerations)
+		}
 		metadataCache = cache.NewTracingCache(metadataCache)
 
 		cfg.CacheExists("metafile", metadataCache, matchers.GetMetafileMatcher(), metadataConfig.MetafileExistsTTL, metadataConfig.MetafileDoesntExistTTL)
@@ -152,11 +277,39 @@ func CreateCachingBucket(chunksConfig ChunksCacheConfig, metadataConfig Metadata
 	return storecache.NewCachingBucket(bkt, cfg, logger, reg)
 }
 
-func createCache(cacheName string, cacheBackend *CacheBackend, logger log.Logger, reg prometheus.Registerer) (cache.Cache, error) {
-	switch cacheBackend.Backend {
-	case "":
+func createCache(cacheName string, cacheBackend *CacheBackend, bkt objstore.Bucket, cachingCfg *cache.CachingBucketConfig, logger log.Logger, reg prometheus.Registerer) (cache.Cache, error) {
+	if cacheBackend.Backend == "" {
 		// No caching.
 		return nil, nil
+	}
+
+	var caches []cache.Cache
+	for _, backend := range strings.Split(cacheBackend.Backend, ",") {
+		c, err := createCacheBackend(cacheName, backend, cacheBackend, bkt, cachingCfg, logger, reg)
+		if err != nil {
+			return nil, err
+		}
+		caches = append(caches, c)
+	}
+
+	return newMultiLevelBucketCache(cacheName, cacheBackend.MultiLevel, reg, caches...), nil
+}
+
+func createCacheBackend(cacheName, backend string, cacheBackend *CacheBackend, bkt objstore.Bucket, cachingCfg *cache.CachingBucketConfig, logger log.Logger, reg prometheus.Registerer) (cache.Cache, error) {
+	switch backend {
+	case CacheBackendInMemory:
+		maxItemSize := cacheBackend.InMemory.MaxItemSizeBytes
+		if maxItemSize == 0 {
+			maxItemSize = cacheBackend.InMemory.MaxSizeBytes
+		}
+		inMemoryCache, err := cache.NewInMemoryCacheWithConfig(cacheName, logger, reg, cache.InMemoryCacheConfig{
+			MaxSize:     model.Bytes(cacheBackend.InMemory.MaxSizeBytes),
+			MaxItemSize: model.Bytes(maxItemSize),
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create in-memory cache")
+		}
+		return inMemoryCache, nil
 
 	case CacheBackendMemcached:
 		var client cacheutil.MemcachedClient
@@ -171,10 +324,25 @@ func createCache(cacheName string, cacheBackend *CacheBackend, logger log.Logger
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to create redis client")
 		}
-		return cache.NewRedisCache(cacheName, logger, redisCache, reg), nil
+		// The redis cache stores the items synchronously, so they're stored through the async buffer of the cache.
+		return newAsyncBucketCache(cacheName, cacheBackend.Redis.MaxAsyncConcurrency, cacheBackend.Redis.MaxAsyncBufferSize, cache.NewRedisCache(cacheName, logger, redisCache, reg), reg), nil
+
+	case CacheBackendGroupcache:
+		groupcache, err := newGroupcacheBucketCache(cacheName, cacheBackend.Groupcache, bkt, cachingCfg, logger, reg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create groupcache")
+		}
+		return groupcache, nil
+
+	case CacheBackendDisk:
+		diskCache, err := newDiskCache(cacheName, cacheBackend.Disk, logger, reg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create disk cache")
+		}
+		return diskCache, nil
 
 	default:
-		return nil, errors.Errorf("unsupported cache type for cache %s: %s", cacheName, cacheBackend.Backend)
+		return nil, errors.Errorf("unsupported cache type for cache %s: %s", cacheName, backend)
 	}
 }
 
@@ -1,11 +1,23 @@
 package tsdb
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/go-kit/log"
 	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
 )
 
 func TestIsTenantDir(t *testing.T) {
@@ -35,3 +47,76 @@ func TestIsBlockIndexFile(t *testing.T) {
 	assert.True(t, isBlockIndexFile(fmt.Sprintf("%s/index", blockID.String())))
 	assert.True(t, isBlockIndexFile(fmt.Sprintf("/%s/index", blockID.String())))
 }
+
+func TestCreateCachingBucket_InMemoryMetadataCache(t *testing.T) {
+	ctx := context.Background()
+	blockID := ulid.MustNew(1, nil)
+	metaFile := path.Join("user-1", blockID.String(), metadata.MetaFilename)
+
+	inMem := objstore.NewInMemBucket()
+	require.NoError(t, inMem.Upload(ctx, metaFile, strings.NewReader("{}")))
+	bkt := &countingBucket{Bucket: inMem, calls: map[string]int{}}
+
+	storageCfg := BlocksStorageConfig{}
+	flagext.DefaultValues(&storageCfg)
+	metadataConfig := storageCfg.BucketStore.MetadataCache
+	metadataConfig.Backend = CacheBackendInMemory
+	require.NoError(t, metadataConfig.Validate())
+
+	cachingBucket, err := CreateCachingBucket(ChunksCacheConfig{}, metadataConfig, NewMatchers(), objstore.WithNoopInstr(bkt), log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		exists, err := cachingBucket.Exists(ctx, metaFile)
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		var tenants []string
+		require.NoError(t, cachingBucket.Iter(ctx, "", func(name string) error {
+			tenants = append(tenants, name)
+			return nil
+		}))
+		assert.Equal(t, []string{"user-1/"}, tenants)
+	}
+
+	// The metadata calls are served from the cache after the first one.
+	assert.Equal(t, 1, bkt.calls[objstore.OpExists])
+	assert.Equal(t, 1, bkt.calls[objstore.OpIter])
+}
+
+func TestInMemoryBucketCacheConfig_Validate(t *testing.T) {
+	cfg := CacheBackend{Backend: CacheBackendInMemory}
+	assert.Error(t, cfg.Validate())
+
+	cfg.InMemory.MaxSizeBytes = 1024
+	assert.NoError(t, cfg.Validate())
+}
+
+// countingBucket is a bucket counting the calls of the metadata and read operations.
+type countingBucket struct {
+	objstore.Bucket
+
+	mtx   sync.Mutex
+	calls map[string]int
+}
+
+func (b *countingBucket) count(op string) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.calls[op]++
+}
+
+func (b *countingBucket) Exists(ctx context.Context, name string) (bool, error) {
+	b.count(objstore.OpExists)
+	return b.Bucket.Exists(ctx, name)
+}
+
+func (b *countingBucket) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	b.count(objstore.OpGet)
+	return b.Bucket.Get(ctx, name)
+}
+
+func (b *countingBucket) Iter(ctx context.Context, dir string, f func(string) error, options ...objstore.IterOption) error {
+	b.count(objstore.OpIter)
+	return b.Bucket.Iter(ctx, dir, f, options...)
+}
@@ -0,0 +1,132 @@
+package tsdb
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/thanos-io/thanos/pkg/cache"
+	storecache "github.com/thanos-io/thanos/pkg/store/cache"
+
+	"github.com/cortexproject/cortex/pkg/util/compression"
+)
+
+// compressedIndexCache is an index cache compressing the postings, expanded postings and series, with the compressor
+// of each type of item. The items whose compressor is nil are stored uncompressed.
+type compressedIndexCache struct {
+	storecache.IndexCache
+
+	postings, series *compression.Compressor
+	logger           log.Logger
+}
+
+func newCompressedIndexCache(c storecache.IndexCache, postingsCfg, seriesCfg compression.Config, logger log.Logger) (storecache.IndexCache, error) {
+	postings, err := compression.NewCompressor(postingsCfg)
+	if err != nil {
+		return nil, err
+	}
+	series, err := compression.NewCompressor(seriesCfg)
+	if err != nil {
+		return nil, err
+	}
+	if postings == nil && series == nil {
+		return c, nil
+	}
+
+	return &compressedIndexCache{IndexCache: c, postings: postings, series: series, logger: logger}, nil
+}
+
+func (c *compressedIndexCache) StorePostings(blockID ulid.ULID, l labels.Label, v []byte, tenant string) {
+	c.IndexCache.StorePostings(blockID, l, compress(c.postings, v), tenant)
+}
+
+func (c *compressedIndexCache) FetchMultiPostings(ctx context.Context, blockID ulid.ULID, keys []labels.Label, tenant string) (map[labels.Label][]byte, []labels.Label) {
+	hits, misses := c.IndexCache.FetchMultiPostings(ctx, blockID, keys, tenant)
+	return decompressHits(c.postings, hits, misses, c.logger)
+}
+
+func (c *compressedIndexCache) StoreExpandedPostings(blockID ulid.ULID, matchers []*labels.Matcher, v []byte, tenant string) {
+	c.IndexCache.StoreExpandedPostings(blockID, matchers, compress(c.postings, v), tenant)
+}
+
+func (c *compressedIndexCache) FetchExpandedPostings(ctx context.Context, blockID ulid.ULID, matchers []*labels.Matcher, tenant string) ([]byte, bool) {
+	v, ok := c.IndexCache.FetchExpandedPostings(ctx, blockID, matchers, tenant)
+	if !ok || c.postings == nil {
+		return v, ok
+	}
+	d, err := compression.Decompress(v)
+	if err != nil {
+		level.Warn(c.logger).Log("msg", "failed to decompress index cache item", "err", err)
+		return nil, false
+	}
+	return d, true
+}
+
+func (c *compressedIndexCache) StoreSeries(blockID ulid.ULID, id storage.SeriesRef, v []byte, tenant string) {
+	c.IndexCache.StoreSeries(blockID, id, compress(c.series, v), tenant)
+}
+
+func (c *compressedIndexCache) FetchMultiSeries(ctx context.Context, blockID ulid.ULID, ids []storage.SeriesRef, tenant string) (map[storage.SeriesRef][]byte, []storage.SeriesRef) {
+	hits, misses := c.IndexCache.FetchMultiSeries(ctx, blockID, ids, tenant)
+	return decompressHits(c.series, hits, misses, c.logger)
+}
+
+// compressedBucketCache is a cache of the caching bucket compressing the cached items.
+type compressedBucketCache struct {
+	cache.Cache
+
+	compressor *compression.Compressor
+	logger     log.Logger
+}
+
+func newCompressedBucketCache(c cache.Cache, cfg compression.Config, logger log.Logger) (cache.Cache, error) {
+	compressor, err := compression.NewCompressor(cfg)
+	if err != nil || compressor == nil {
+		return c, err
+	}
+	return &compressedBucketCache{Cache: c, compressor: compressor, logger: logger}, nil
+}
+
+// Store implements cache.Cache.
+func (c *compressedBucketCache) Store(data map[string][]byte, ttl time.Duration) {
+	compressed := make(map[string][]byte, len(data))
+	for key, value := range data {
+		compressed[key] = c.compressor.Compress(value)
+	}
+	c.Cache.Store(compressed, ttl)
+}
+
+// Fetch implements cache.Cache.
+func (c *compressedBucketCache) Fetch(ctx context.Context, keys []string) map[string][]byte {
+	hits, _ := decompressHits(c.compressor, c.Cache.Fetch(ctx, keys), nil, c.logger)
+	return hits
+}
+
+func compress(compressor *compression.Compressor, v []byte) []byte {
+	if compressor == nil {
+		return v
+	}
+	return compressor.Compress(v)
+}
+
+// decompressHits decompresses the hits, and returns the hits failing to be decompressed as misses.
+func decompressHits[K comparable](compressor *compression.Compressor, hits map[K][]byte, misses []K, logger log.Logger) (map[K][]byte, []K) {
+	if compressor == nil {
+		return hits, misses
+	}
+	for key, value := range hits {
+		d, err := compression.Decompress(value)
+		if err != nil {
+			level.Warn(logger).Log("msg", "failed to decompress cache item", "err", err)
+			delete(hits, key)
+			misses = append(misses, key)
+			continue
+		}
+		hits[key] = d
+	}
+	return hits, misses
+}
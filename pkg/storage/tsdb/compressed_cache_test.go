@@ -0,0 +1,81 @@
+package tsdb
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/util/compression"
+)
+
+func TestCompressedIndexCache(t *testing.T) {
+	ctx := context.Background()
+	blockID := ulid.MustNew(1, nil)
+	postings := bytes.Repeat([]byte("postings"), 100)
+	series := bytes.Repeat([]byte("series"), 100)
+
+	cfg := IndexCacheConfig{
+		Backend:             IndexCacheBackendInMemory,
+		InMemory:            InMemoryIndexCacheConfig{MaxSizeBytes: 1024 * 1024},
+		PostingsCompression: compression.Config{Codec: compression.Zstd, Level: 3},
+		SeriesCompression:   compression.Config{Codec: compression.Snappy, MinSizeBytes: 1024},
+	}
+	require.NoError(t, cfg.Validate())
+
+	c, err := NewIndexCache(cfg, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+	require.IsType(t, &compressedIndexCache{}, c)
+	underlying := c.(*compressedIndexCache).IndexCache
+
+	c.StorePostings(blockID, labels.Label{Name: "a", Value: "1"}, postings, "user-1")
+	c.StoreSeries(blockID, storage.SeriesRef(1), series, "user-1")
+
+	hits, misses := c.FetchMultiPostings(ctx, blockID, []labels.Label{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}}, "user-1")
+	assert.Equal(t, map[labels.Label][]byte{{Name: "a", Value: "1"}: postings}, hits)
+	assert.Equal(t, []labels.Label{{Name: "b", Value: "2"}}, misses)
+
+	seriesHits, seriesMisses := c.FetchMultiSeries(ctx, blockID, []storage.SeriesRef{1}, "user-1")
+	assert.Equal(t, map[storage.SeriesRef][]byte{1: series}, seriesHits)
+	assert.Empty(t, seriesMisses)
+
+	// The postings are compressed, while the series smaller than the min size aren't.
+	hits, _ = underlying.FetchMultiPostings(ctx, blockID, []labels.Label{{Name: "a", Value: "1"}}, "user-1")
+	assert.Less(t, len(hits[labels.Label{Name: "a", Value: "1"}]), len(postings))
+	seriesHits, _ = underlying.FetchMultiSeries(ctx, blockID, []storage.SeriesRef{1}, "user-1")
+	assert.Equal(t, len(series)+2, len(seriesHits[1]))
+
+	// The items which can't be decompressed are misses.
+	underlying.StoreSeries(blockID, storage.SeriesRef(2), series, "user-1")
+	seriesHits, seriesMisses = c.FetchMultiSeries(ctx, blockID, []storage.SeriesRef{2}, "user-1")
+	assert.Empty(t, seriesHits)
+	assert.Equal(t, []storage.SeriesRef{2}, seriesMisses)
+}
+
+func TestNewIndexCache_ShouldNotCompressByDefault(t *testing.T) {
+	cfg := IndexCacheConfig{Backend: IndexCacheBackendInMemory, InMemory: InMemoryIndexCacheConfig{MaxSizeBytes: 1024}}
+	c, err := NewIndexCache(cfg, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+	assert.IsType(t, &InMemoryIndexCache{}, c)
+}
+
+func TestCompressedBucketCache(t *testing.T) {
+	ctx := context.Background()
+	subrange := bytes.Repeat([]byte("chunk"), 100)
+
+	underlying := newMockBucketCache()
+	c, err := newCompressedBucketCache(underlying, compression.Config{Codec: compression.Snappy}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	c.Store(map[string][]byte{"subrange": subrange}, time.Hour)
+	assert.Less(t, len(underlying.get()["subrange"]), len(subrange))
+	assert.Equal(t, map[string][]byte{"subrange": subrange}, c.Fetch(ctx, []string{"subrange", "missing"}))
+}
@@ -26,6 +26,10 @@ const (
 	// set when shipping blocks to the storage.
 	IngesterIDExternalLabel = "__ingester_id__"
 
+	// RollupExternalLabel is the external label set by the compactor on the blocks
+	// containing the series generated by the rollup rules.
+	RollupExternalLabel = "__rollup__"
+
 	// How often are open TSDBs checked for being idle and closed.
 	DefaultCloseIdleTSDBInterval = 5 * time.Minute
 
@@ -61,6 +65,15 @@ type BlocksStorageConfig struct {
 	Bucket      bucket.Config     `yaml:",inline"`
 	BucketStore BucketStoreConfig `yaml:"bucket_store" doc:"description=This configures how the querier and store-gateway discover and synchronize blocks stored in the bucket."`
 	TSDB        TSDBConfig        `yaml:"tsdb"`
+
+	TenantBucketsConfigFile string `yaml:"tenant_buckets_config_file"`
+
+	BackgroundBandwidth bucket.BandwidthGovernorConfig `yaml:"background_bandwidth"`
+
+	CacheGeneration CacheGenerationConfig `yaml:"cache_generation"`
+
+	// Injected at runtime, shared by the compactor and the store-gateway running in the same process.
+	BandwidthGovernor *bucket.BandwidthGovernor `yaml:"-"`
 }
 
 // DurationList is the block ranges for a tsdb
@@ -105,6 +118,10 @@ func (cfg *BlocksStorageConfig) RegisterFlags(f *flag.FlagSet) {
 	cfg.Bucket.RegisterFlagsWithPrefix("blocks-storage.", f)
 	cfg.BucketStore.RegisterFlags(f)
 	cfg.TSDB.RegisterFlags(f)
+
+	f.StringVar(&cfg.TenantBucketsConfigFile, "blocks-storage.tenant-buckets-config-file", "", "[Experimental] Filename of the tenant buckets config, defining the buckets storing the blocks of the tenants in addition to the bucket configured above, and mapping the tenants to their bucket by tenant ID or by regex. The file is read at startup, and the tenants which aren't mapped to any bucket are stored in the bucket configured above.")
+	cfg.BackgroundBandwidth.RegisterFlagsWithPrefix("blocks-storage.background-bandwidth.", f)
+	cfg.CacheGeneration.RegisterFlagsWithPrefix("blocks-storage.cache-generation.", f)
 }
 
 // Validate the config.
@@ -117,6 +134,14 @@ func (cfg *BlocksStorageConfig) Validate() error {
 		return err
 	}
 
+	if err := cfg.BackgroundBandwidth.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.CacheGeneration.Validate(); err != nil {
+		return err
+	}
+
 	return cfg.BucketStore.Validate()
 }
 
@@ -253,6 +278,7 @@ type BucketStoreConfig struct {
 	IndexCache               IndexCacheConfig    `yaml:"index_cache"`
 	ChunksCache              ChunksCacheConfig   `yaml:"chunks_cache"`
 	MetadataCache            MetadataCacheConfig `yaml:"metadata_cache"`
+	Groupcache               GroupcacheConfig    `yaml:"groupcache"`
 	IgnoreDeletionMarksDelay time.Duration       `yaml:"ignore_deletion_mark_delay"`
 	IgnoreBlocksWithin       time.Duration       `yaml:"ignore_blocks_within"`
 	BucketIndex              BucketIndexConfig   `yaml:"bucket_index"`
@@ -295,6 +321,7 @@ func (cfg *BucketStoreConfig) RegisterFlags(f *flag.FlagSet) {
 	cfg.IndexCache.RegisterFlagsWithPrefix(f, "blocks-storage.bucket-store.index-cache.")
 	cfg.ChunksCache.RegisterFlagsWithPrefix(f, "blocks-storage.bucket-store.chunks-cache.")
 	cfg.MetadataCache.RegisterFlagsWithPrefix(f, "blocks-storage.bucket-store.metadata-cache.")
+	cfg.Groupcache.RegisterFlagsWithPrefix(f, "blocks-storage.bucket-store.groupcache.")
 	cfg.BucketIndex.RegisterFlagsWithPrefix(f, "blocks-storage.bucket-store.bucket-index.")
 
 	f.StringVar(&cfg.SyncDir, "blocks-storage.bucket-store.sync-dir", "tsdb-sync", "Directory to store synchronized TSDB index headers.")
@@ -337,12 +364,22 @@ func (cfg *BucketStoreConfig) Validate() error {
 	if err != nil {
 		return errors.Wrap(err, "metadata-cache configuration")
 	}
+	if cfg.GroupcacheEnabled() {
+		if err := cfg.Groupcache.Validate(); err != nil {
+			return errors.Wrap(err, "groupcache configuration")
+		}
+	}
 	if !util.StringsContain(supportedBlockDiscoveryStrategies, cfg.BlockDiscoveryStrategy) {
 		return ErrInvalidBucketIndexBlockDiscoveryStrategy
 	}
 	return nil
 }
 
+// GroupcacheEnabled returns whether the chunks or metadata cache uses the groupcache backend.
+func (cfg *BucketStoreConfig) GroupcacheEnabled() bool {
+	return cfg.ChunksCache.UsesBackend(CacheBackendGroupcache) || cfg.MetadataCache.UsesBackend(CacheBackendGroupcache)
+}
+
 type BucketIndexConfig struct {
 	Enabled               bool          `yaml:"enabled"`
 	UpdateOnErrorInterval time.Duration `yaml:"update_on_error_interval"`
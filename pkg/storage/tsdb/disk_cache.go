@@ -0,0 +1,546 @@
+package tsdb
+
+import (
+	"context"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/units"
+	"github.com/cespare/xxhash/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/tsdb/fileutil"
+	"github.com/thanos-io/thanos/pkg/cacheutil"
+)
+
+const (
+	diskCacheSegmentPrefix  = "segment-"
+	diskCacheSegmentPattern = diskCacheSegmentPrefix + "%08d"
+	diskCacheLockFile       = "lock"
+
+	// Each record is made of a header, the key and the value. The header holds the CRC32 of the rest of the header,
+	// the CRC32 of the key and value, the expiration in Unix milliseconds (0 never expires), the key length and the
+	// value length.
+	diskCacheHeaderSize = 4 + 4 + 8 + 4 + 4
+)
+
+var (
+	errDiskCacheNoDir              = errors.New("the disk cache directory must be set")
+	errInvalidDiskCacheMaxSize     = errors.New("the disk cache max size must be greater than 0")
+	errInvalidDiskCacheSegmentSize = errors.New("the disk cache segment size must be greater than 0, not greater than the max size and lower than 4GiB")
+	errInvalidDiskCacheAsyncBuffer = errors.New("the disk cache max async buffer size must be greater than 0")
+	errDiskCacheCorruptedRecord    = errors.New("corrupted disk cache record")
+
+	diskCacheCastagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// DiskCacheConfig configures a cache on a local disk, between the in-memory caches and the object storage.
+type DiskCacheConfig struct {
+	Dir                string `yaml:"dir"`
+	MaxSizeBytes       uint64 `yaml:"max_size_bytes"`
+	SegmentSizeBytes   uint64 `yaml:"segment_size_bytes"`
+	MaxAsyncBufferSize int    `yaml:"max_async_buffer_size"`
+}
+
+func (cfg *DiskCacheConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string) {
+	f.StringVar(&cfg.Dir, prefix+"dir", "", "[Experimental] Directory of the disk cache, preferably on a local NVMe disk. The directory is locked by the cache, so it can't be shared with another cache, even of another Cortex component running in the same process.")
+	f.Uint64Var(&cfg.MaxSizeBytes, prefix+"max-size-bytes", uint64(100*units.Gibibyte), "[Experimental] Maximum size in bytes of the disk cache. The oldest segments of the cache are deleted when it's full.")
+	f.Uint64Var(&cfg.SegmentSizeBytes, prefix+"segment-size-bytes", uint64(256*units.Mebibyte), "[Experimental] Size in bytes of the segment files the items of the disk cache are appended to. The items are evicted by whole segment, and the items larger than a segment aren't stored.")
+	f.IntVar(&cfg.MaxAsyncBufferSize, prefix+"max-async-buffer-size", 10000, "[Experimental] The maximum number of enqueued writes to the disk cache. The items are dropped when the buffer is full.")
+}
+
+// Validate the config.
+func (cfg *DiskCacheConfig) Validate() error {
+	if cfg.Dir == "" {
+		return errDiskCacheNoDir
+	}
+	if cfg.MaxSizeBytes == 0 {
+		return errInvalidDiskCacheMaxSize
+	}
+	if cfg.SegmentSizeBytes == 0 || cfg.SegmentSizeBytes > cfg.MaxSizeBytes || cfg.SegmentSizeBytes >= math.MaxUint32 {
+		return errInvalidDiskCacheSegmentSize
+	}
+	if cfg.MaxAsyncBufferSize <= 0 {
+		return errInvalidDiskCacheAsyncBuffer
+	}
+	return nil
+}
+
+// diskCache is a log-structured cache on a local disk. The items are appended to fixed size segment files, and
+// found through an in-memory index of the hashes of their keys. When the cache is full, its oldest segment is
+// deleted with all its items, so the cache evicts the items in insertion order. The index is rebuilt from the
+// segments on startup, so the cache survives the restarts.
+//
+// The cache implements both cache.Cache, for the caching bucket, and cacheutil.RemoteCacheClient, for the index
+// cache. The items are written asynchronously, one at a time, and dropped when the async buffer is full.
+type diskCache struct {
+	name      string
+	cfg       DiskCacheConfig
+	logger    log.Logger
+	lock      fileutil.Releaser
+	processor *cacheutil.AsyncOperationProcessor
+
+	// Serializes the writes to the active segment, which is the newest one.
+	writeMtx sync.Mutex
+
+	mtx        sync.RWMutex
+	index      map[uint64]diskCacheLocation
+	segments   map[uint32]*diskCacheSegment
+	segmentIDs []uint32 // Ordered from the oldest to the newest.
+	sizeBytes  uint64
+
+	requests        prometheus.Counter
+	hits            prometheus.Counter
+	evictedSegments prometheus.Counter
+	corruptedItems  prometheus.Counter
+	failures        *prometheus.CounterVec
+	droppedItems    prometheus.Counter
+	skippedItems    prometheus.Counter
+}
+
+type diskCacheSegment struct {
+	id   uint32
+	file *os.File
+	size uint32
+
+	// The hashes of the keys of the items appended to the segment, to remove them from the index when the segment is
+	// evicted. The items overwritten in a newer segment are left in the index.
+	hashes []uint64
+}
+
+type diskCacheLocation struct {
+	segment   uint32
+	offset    uint32
+	length    uint32
+	expiresAt int64
+}
+
+func newDiskCache(name string, cfg DiskCacheConfig, logger log.Logger, reg prometheus.Registerer) (*diskCache, error) {
+	if err := os.MkdirAll(cfg.Dir, os.ModePerm); err != nil {
+		return nil, errors.Wrap(err, "create disk cache directory")
+	}
+	lock, _, err := fileutil.Flock(filepath.Join(cfg.Dir, diskCacheLockFile))
+	if err != nil {
+		return nil, errors.Wrapf(err, "lock disk cache directory %s", cfg.Dir)
+	}
+
+	c := &diskCache{
+		name:      name,
+		cfg:       cfg,
+		logger:    log.With(logger, "name", name),
+		lock:      lock,
+		processor: cacheutil.NewAsyncOperationProcessor(cfg.MaxAsyncBufferSize, 1),
+		index:     map[uint64]diskCacheLocation{},
+		segments:  map[uint32]*diskCacheSegment{},
+
+		requests: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "cortex_disk_cache_requests_total",
+			Help:        "Total number of items requested to the disk cache.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}),
+		hits: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "cortex_disk_cache_hits_total",
+			Help:        "Total number of items requested to the disk cache that were found.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}),
+		evictedSegments: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "cortex_disk_cache_evicted_segments_total",
+			Help:        "Total number of segments deleted from the disk cache because it was full.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}),
+		corruptedItems: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "cortex_disk_cache_corrupted_items_total",
+			Help:        "Total number of items of the disk cache which failed their checksum when read.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}),
+		failures: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name:        "cortex_disk_cache_operation_failures_total",
+			Help:        "Total number of disk cache operations which failed.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}, []string{"operation"}),
+		droppedItems: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "cortex_disk_cache_async_dropped_items_total",
+			Help:        "Total number of items dropped because the async buffer of the disk cache was full when storing them.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}),
+		skippedItems: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "cortex_disk_cache_skipped_items_total",
+			Help:        "Total number of items not stored in the disk cache because they were larger than a segment.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}),
+	}
+	promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "cortex_disk_cache_size_bytes",
+		Help:        "Size in bytes of the segments of the disk cache.",
+		ConstLabels: prometheus.Labels{"name": name},
+	}, func() float64 {
+		c.mtx.RLock()
+		defer c.mtx.RUnlock()
+		return float64(c.sizeBytes)
+	})
+	promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "cortex_disk_cache_items",
+		Help:        "Number of items indexed by the disk cache.",
+		ConstLabels: prometheus.Labels{"name": name},
+	}, func() float64 {
+		c.mtx.RLock()
+		defer c.mtx.RUnlock()
+		return float64(len(c.index))
+	})
+
+	if err := c.load(); err != nil {
+		c.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// load rebuilds the index from the segments found in the directory, truncating the records partially written or
+// corrupted at the end of each segment.
+func (c *diskCache) load() error {
+	entries, err := os.ReadDir(c.cfg.Dir)
+	if err != nil {
+		return errors.Wrap(err, "read disk cache directory")
+	}
+
+	var ids []uint32
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), diskCacheSegmentPrefix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimPrefix(entry.Name(), diskCacheSegmentPrefix), 10, 32)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, uint32(id))
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	nowMillis := time.Now().UnixMilli()
+	for _, id := range ids {
+		segment, err := c.loadSegment(id, nowMillis)
+		if err != nil {
+			return err
+		}
+		c.segments[id] = segment
+		c.segmentIDs = append(c.segmentIDs, id)
+		c.sizeBytes += uint64(segment.size)
+	}
+
+	// The max size may have been lowered since the segments were written.
+	for c.sizeBytes > c.cfg.MaxSizeBytes && len(c.segmentIDs) > 1 {
+		c.evictOldestSegment()
+	}
+
+	level.Info(c.logger).Log("msg", "disk cache loaded", "dir", c.cfg.Dir, "segments", len(c.segmentIDs), "items", len(c.index), "size_bytes", c.sizeBytes)
+	return nil
+}
+
+func (c *diskCache) loadSegment(id uint32, nowMillis int64) (*diskCacheSegment, error) {
+	file, err := os.OpenFile(c.segmentPath(id), os.O_RDWR, 0o666)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open disk cache segment %d", id)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, errors.Wrapf(err, "stat disk cache segment %d", id)
+	}
+
+	segment := &diskCacheSegment{id: id, file: file}
+	header := make([]byte, diskCacheHeaderSize)
+	offset := int64(0)
+	for {
+		if _, err := file.ReadAt(header, offset); err != nil {
+			break
+		}
+		expiresAt, keyLen, valueLen, ok := decodeDiskCacheHeader(header)
+		length := int64(diskCacheHeaderSize) + int64(keyLen) + int64(valueLen)
+		if !ok || offset+length > info.Size() || offset+length > int64(c.cfg.SegmentSizeBytes) {
+			break
+		}
+
+		key := make([]byte, keyLen)
+		if _, err := file.ReadAt(key, offset+diskCacheHeaderSize); err != nil {
+			break
+		}
+		hash := xxhash.Sum64(key)
+		segment.hashes = append(segment.hashes, hash)
+		if expiresAt == 0 || nowMillis < expiresAt {
+			c.index[hash] = diskCacheLocation{segment: id, offset: uint32(offset), length: uint32(length), expiresAt: expiresAt}
+		}
+		offset += length
+	}
+
+	if offset < info.Size() {
+		level.Warn(c.logger).Log("msg", "truncating the partially written or corrupted records at the end of the disk cache segment", "segment", id, "size", info.Size(), "truncated_size", offset)
+		if err := file.Truncate(offset); err != nil {
+			_ = file.Close()
+			return nil, errors.Wrapf(err, "truncate disk cache segment %d", id)
+		}
+	}
+	segment.size = uint32(offset)
+	return segment, nil
+}
+
+func (c *diskCache) segmentPath(id uint32) string {
+	return filepath.Join(c.cfg.Dir, fmt.Sprintf(diskCacheSegmentPattern, id))
+}
+
+// Name implements cache.Cache.
+func (c *diskCache) Name() string {
+	return c.name
+}
+
+// Store implements cache.Cache.
+func (c *diskCache) Store(data map[string][]byte, ttl time.Duration) {
+	if len(data) == 0 {
+		return
+	}
+	if err := c.processor.EnqueueAsync(func() {
+		for key, value := range data {
+			c.storeItem(key, value, ttl)
+		}
+	}); errors.Is(err, cacheutil.ErrAsyncBufferFull) {
+		c.droppedItems.Add(float64(len(data)))
+	}
+}
+
+// Fetch implements cache.Cache.
+func (c *diskCache) Fetch(ctx context.Context, keys []string) map[string][]byte {
+	return c.GetMulti(ctx, keys)
+}
+
+// SetAsync implements cacheutil.RemoteCacheClient.
+func (c *diskCache) SetAsync(key string, value []byte, ttl time.Duration) error {
+	if err := c.processor.EnqueueAsync(func() {
+		c.storeItem(key, value, ttl)
+	}); errors.Is(err, cacheutil.ErrAsyncBufferFull) {
+		c.droppedItems.Inc()
+	}
+	return nil
+}
+
+// GetMulti implements cacheutil.RemoteCacheClient.
+func (c *diskCache) GetMulti(ctx context.Context, keys []string) map[string][]byte {
+	c.requests.Add(float64(len(keys)))
+
+	hits := map[string][]byte{}
+	nowMillis := time.Now().UnixMilli()
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			break
+		}
+		if value, ok := c.get(key, nowMillis); ok {
+			hits[key] = value
+		}
+	}
+	c.hits.Add(float64(len(hits)))
+	return hits
+}
+
+// Stop implements cacheutil.RemoteCacheClient. The items still enqueued are dropped.
+func (c *diskCache) Stop() {
+	c.processor.Stop()
+	c.close()
+}
+
+func (c *diskCache) close() {
+	c.writeMtx.Lock()
+	defer c.writeMtx.Unlock()
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, segment := range c.segments {
+		if err := segment.file.Close(); err != nil {
+			level.Warn(c.logger).Log("msg", "failed to close disk cache segment", "segment", segment.id, "err", err)
+		}
+	}
+	c.segments = map[uint32]*diskCacheSegment{}
+	c.segmentIDs = nil
+	c.index = map[uint64]diskCacheLocation{}
+
+	if err := c.lock.Release(); err != nil {
+		level.Warn(c.logger).Log("msg", "failed to release disk cache directory lock", "err", err)
+	}
+}
+
+func (c *diskCache) get(key string, nowMillis int64) ([]byte, bool) {
+	hash := xxhash.Sum64String(key)
+
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	loc, ok := c.index[hash]
+	if !ok || (loc.expiresAt != 0 && nowMillis >= loc.expiresAt) {
+		return nil, false
+	}
+	segment, ok := c.segments[loc.segment]
+	if !ok {
+		return nil, false
+	}
+
+	record := make([]byte, loc.length)
+	if _, err := segment.file.ReadAt(record, int64(loc.offset)); err != nil {
+		c.failures.WithLabelValues("read").Inc()
+		level.Warn(c.logger).Log("msg", "failed to read disk cache item", "segment", loc.segment, "err", err)
+		return nil, false
+	}
+	recordKey, value, err := decodeDiskCacheRecord(record)
+	if err != nil {
+		c.corruptedItems.Inc()
+		return nil, false
+	}
+	// Another key with the same hash.
+	if recordKey != key {
+		return nil, false
+	}
+	return value, true
+}
+
+func (c *diskCache) storeItem(key string, value []byte, ttl time.Duration) {
+	if err := c.set(key, value, ttl, time.Now()); err != nil {
+		c.failures.WithLabelValues("write").Inc()
+		level.Warn(c.logger).Log("msg", "failed to store disk cache item", "err", err)
+	}
+}
+
+func (c *diskCache) set(key string, value []byte, ttl time.Duration, now time.Time) error {
+	length := uint64(diskCacheHeaderSize + len(key) + len(value))
+	if length > c.cfg.SegmentSizeBytes {
+		c.skippedItems.Inc()
+		return nil
+	}
+	expiresAt := int64(0)
+	if ttl > 0 {
+		expiresAt = now.Add(ttl).UnixMilli()
+	}
+	record := encodeDiskCacheRecord(key, value, expiresAt)
+
+	c.writeMtx.Lock()
+	defer c.writeMtx.Unlock()
+
+	segment, err := c.activeSegment(length)
+	if err != nil {
+		return err
+	}
+	offset := segment.size
+	if _, err := segment.file.WriteAt(record, int64(offset)); err != nil {
+		return errors.Wrapf(err, "write disk cache segment %d", segment.id)
+	}
+
+	hash := xxhash.Sum64String(key)
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	segment.size += uint32(length)
+	segment.hashes = append(segment.hashes, hash)
+	c.sizeBytes += length
+	c.index[hash] = diskCacheLocation{segment: segment.id, offset: offset, length: uint32(length), expiresAt: expiresAt}
+	return nil
+}
+
+// activeSegment returns the segment to append a record of the given length to, creating a new segment when the
+// newest one is full, and evicting the oldest segments to make room for it. Must be called with the write mutex held.
+func (c *diskCache) activeSegment(length uint64) (*diskCacheSegment, error) {
+	var newest *diskCacheSegment
+	if n := len(c.segmentIDs); n > 0 {
+		newest = c.segments[c.segmentIDs[n-1]]
+		if uint64(newest.size)+length <= c.cfg.SegmentSizeBytes {
+			return newest, nil
+		}
+	}
+
+	id := uint32(0)
+	if newest != nil {
+		id = newest.id + 1
+	}
+	file, err := os.OpenFile(c.segmentPath(id), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+	if err != nil {
+		return nil, errors.Wrapf(err, "create disk cache segment %d", id)
+	}
+	segment := &diskCacheSegment{id: id, file: file}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for len(c.segmentIDs) > 0 && c.sizeBytes+c.cfg.SegmentSizeBytes > c.cfg.MaxSizeBytes {
+		c.evictOldestSegment()
+	}
+	c.segments[id] = segment
+	c.segmentIDs = append(c.segmentIDs, id)
+	return segment, nil
+}
+
+// evictOldestSegment deletes the oldest segment and removes its items from the index. Must be called with the mutex
+// held.
+func (c *diskCache) evictOldestSegment() {
+	id := c.segmentIDs[0]
+	segment := c.segments[id]
+	c.segmentIDs = c.segmentIDs[1:]
+	delete(c.segments, id)
+	c.sizeBytes -= uint64(segment.size)
+
+	for _, hash := range segment.hashes {
+		if loc, ok := c.index[hash]; ok && loc.segment == id {
+			delete(c.index, hash)
+		}
+	}
+
+	if err := segment.file.Close(); err != nil {
+		level.Warn(c.logger).Log("msg", "failed to close disk cache segment", "segment", id, "err", err)
+	}
+	if err := os.Remove(c.segmentPath(id)); err != nil {
+		c.failures.WithLabelValues("evict").Inc()
+		level.Warn(c.logger).Log("msg", "failed to delete disk cache segment", "segment", id, "err", err)
+	}
+	c.evictedSegments.Inc()
+}
+
+func encodeDiskCacheRecord(key string, value []byte, expiresAt int64) []byte {
+	record := make([]byte, diskCacheHeaderSize+len(key)+len(value))
+	copy(record[diskCacheHeaderSize:], key)
+	copy(record[diskCacheHeaderSize+len(key):], value)
+
+	binary.BigEndian.PutUint32(record[4:], crc32.Checksum(record[diskCacheHeaderSize:], diskCacheCastagnoliTable))
+	binary.BigEndian.PutUint64(record[8:], uint64(expiresAt))
+	binary.BigEndian.PutUint32(record[16:], uint32(len(key)))
+	binary.BigEndian.PutUint32(record[20:], uint32(len(value)))
+	binary.BigEndian.PutUint32(record[0:], crc32.Checksum(record[4:diskCacheHeaderSize], diskCacheCastagnoliTable))
+	return record
+}
+
+// decodeDiskCacheHeader returns the expiration, key length and value length of the record, and whether the header
+// is valid.
+func decodeDiskCacheHeader(header []byte) (int64, uint32, uint32, bool) {
+	if binary.BigEndian.Uint32(header[0:]) != crc32.Checksum(header[4:diskCacheHeaderSize], diskCacheCastagnoliTable) {
+		return 0, 0, 0, false
+	}
+	return int64(binary.BigEndian.Uint64(header[8:])), binary.BigEndian.Uint32(header[16:]), binary.BigEndian.Uint32(header[20:]), true
+}
+
+func decodeDiskCacheRecord(record []byte) (string, []byte, error) {
+	if len(record) < diskCacheHeaderSize {
+		return "", nil, io.ErrUnexpectedEOF
+	}
+	_, keyLen, valueLen, ok := decodeDiskCacheHeader(record)
+	if !ok || uint64(len(record)) != uint64(diskCacheHeaderSize)+uint64(keyLen)+uint64(valueLen) {
+		return "", nil, errDiskCacheCorruptedRecord
+	}
+	if binary.BigEndian.Uint32(record[4:]) != crc32.Checksum(record[diskCacheHeaderSize:], diskCacheCastagnoliTable) {
+		return "", nil, errDiskCacheCorruptedRecord
+	}
+	return string(record[diskCacheHeaderSize : diskCacheHeaderSize+keyLen]), record[diskCacheHeaderSize+keyLen:], nil
+}
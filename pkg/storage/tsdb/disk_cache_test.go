@@ -0,0 +1,162 @@
+package tsdb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskCacheConfig_Validate(t *testing.T) {
+	valid := DiskCacheConfig{Dir: "/tmp/cache", MaxSizeBytes: 1000, SegmentSizeBytes: 100, MaxAsyncBufferSize: 10}
+	assert.NoError(t, valid.Validate())
+
+	cfg := valid
+	cfg.Dir = ""
+	assert.ErrorIs(t, cfg.Validate(), errDiskCacheNoDir)
+
+	cfg = valid
+	cfg.MaxSizeBytes = 0
+	assert.ErrorIs(t, cfg.Validate(), errInvalidDiskCacheMaxSize)
+
+	cfg = valid
+	cfg.SegmentSizeBytes = 2000
+	assert.ErrorIs(t, cfg.Validate(), errInvalidDiskCacheSegmentSize)
+
+	cfg = valid
+	cfg.MaxAsyncBufferSize = 0
+	assert.ErrorIs(t, cfg.Validate(), errInvalidDiskCacheAsyncBuffer)
+}
+
+func newTestDiskCache(t *testing.T, cfg DiskCacheConfig) *diskCache {
+	c, err := newDiskCache("test", cfg, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+	return c
+}
+
+func TestDiskCache_ShouldStoreAndFetchTheItems(t *testing.T) {
+	c := newTestDiskCache(t, DiskCacheConfig{Dir: t.TempDir(), MaxSizeBytes: 1000, SegmentSizeBytes: 200, MaxAsyncBufferSize: 10})
+	defer c.Stop()
+
+	now := time.Now()
+	require.NoError(t, c.set("key-1", []byte("value-1"), time.Hour, now))
+	require.NoError(t, c.set("key-2", []byte("value-2"), 0, now))
+	require.NoError(t, c.set("key-3", []byte("value-3"), time.Millisecond, now.Add(-time.Second)))
+
+	assert.Equal(t, map[string][]byte{
+		"key-1": []byte("value-1"),
+		"key-2": []byte("value-2"),
+	}, c.Fetch(context.Background(), []string{"key-1", "key-2", "key-3", "key-4"}))
+
+	// The items overwritten are read from their last write.
+	require.NoError(t, c.set("key-1", []byte("value-1b"), time.Hour, now))
+	assert.Equal(t, []byte("value-1b"), c.Fetch(context.Background(), []string{"key-1"})["key-1"])
+
+	// The items larger than a segment aren't stored.
+	require.NoError(t, c.set("key-5", make([]byte, 200), time.Hour, now))
+	assert.Empty(t, c.Fetch(context.Background(), []string{"key-5"}))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.skippedItems))
+}
+
+func TestDiskCache_ShouldStoreTheItemsAsynchronously(t *testing.T) {
+	c := newTestDiskCache(t, DiskCacheConfig{Dir: t.TempDir(), MaxSizeBytes: 1000, SegmentSizeBytes: 200, MaxAsyncBufferSize: 10})
+	defer c.Stop()
+
+	c.Store(map[string][]byte{"key-1": []byte("value-1")}, time.Hour)
+	require.NoError(t, c.SetAsync("key-2", []byte("value-2"), time.Hour))
+
+	assert.Eventually(t, func() bool {
+		return len(c.GetMulti(context.Background(), []string{"key-1", "key-2"})) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestDiskCache_ShouldEvictTheOldestSegmentsWhenFull(t *testing.T) {
+	c := newTestDiskCache(t, DiskCacheConfig{Dir: t.TempDir(), MaxSizeBytes: 300, SegmentSizeBytes: 100, MaxAsyncBufferSize: 10})
+	defer c.Stop()
+
+	// Each record is 24 bytes of header, 5 bytes of key and 41 bytes of value, so each segment holds one record.
+	keys := []string{"key-1", "key-2", "key-3", "key-4"}
+	for _, key := range keys {
+		require.NoError(t, c.set(key, make([]byte, 41), time.Hour, time.Now()))
+	}
+
+	found := c.Fetch(context.Background(), keys)
+	assert.NotContains(t, found, "key-1")
+	assert.Contains(t, found, "key-2")
+	assert.Contains(t, found, "key-3")
+	assert.Contains(t, found, "key-4")
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.evictedSegments))
+	assert.Len(t, c.index, 3)
+	assert.LessOrEqual(t, c.sizeBytes, uint64(300))
+
+	_, err := os.Stat(c.segmentPath(0))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDiskCache_ShouldReloadTheItemsOnRestart(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DiskCacheConfig{Dir: dir, MaxSizeBytes: 1000, SegmentSizeBytes: 100, MaxAsyncBufferSize: 10}
+
+	c := newTestDiskCache(t, cfg)
+	require.NoError(t, c.set("key-1", []byte("value-1"), time.Hour, time.Now()))
+	require.NoError(t, c.set("key-2", []byte("value-2"), time.Hour, time.Now()))
+	require.NoError(t, c.set("key-3", []byte("value-3"), time.Hour, time.Now()))
+	require.NoError(t, c.set("key-4", []byte("value-4"), time.Millisecond, time.Now().Add(-time.Second)))
+	lastSegment := c.segmentPath(c.segmentIDs[len(c.segmentIDs)-1])
+	c.Stop()
+
+	// A partially written record at the end of the last segment.
+	f, err := os.OpenFile(lastSegment, os.O_APPEND|os.O_WRONLY, 0o666)
+	require.NoError(t, err)
+	_, err = f.Write(encodeDiskCacheRecord("key-5", []byte("value-5"), 0)[:30])
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	c = newTestDiskCache(t, cfg)
+	defer c.Stop()
+	assert.Equal(t, map[string][]byte{
+		"key-1": []byte("value-1"),
+		"key-2": []byte("value-2"),
+		"key-3": []byte("value-3"),
+	}, c.Fetch(context.Background(), []string{"key-1", "key-2", "key-3", "key-4", "key-5"}))
+
+	// The items are appended after the last valid record.
+	require.NoError(t, c.set("key-5", []byte("value-5"), time.Hour, time.Now()))
+	assert.Equal(t, []byte("value-5"), c.Fetch(context.Background(), []string{"key-5"})["key-5"])
+}
+
+func TestDiskCache_ShouldMissTheCorruptedItems(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestDiskCache(t, DiskCacheConfig{Dir: dir, MaxSizeBytes: 1000, SegmentSizeBytes: 100, MaxAsyncBufferSize: 10})
+	defer c.Stop()
+
+	require.NoError(t, c.set("key-1", []byte("value-1"), time.Hour, time.Now()))
+	_, err := c.segments[0].file.WriteAt([]byte("x"), int64(diskCacheHeaderSize+len("key-1")))
+	require.NoError(t, err)
+
+	assert.Empty(t, c.Fetch(context.Background(), []string{"key-1"}))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.corruptedItems))
+}
+
+func TestDiskCache_ShouldLockTheDirectory(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DiskCacheConfig{Dir: dir, MaxSizeBytes: 1000, SegmentSizeBytes: 100, MaxAsyncBufferSize: 10}
+
+	c := newTestDiskCache(t, cfg)
+	_, err := newDiskCache("test", cfg, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	assert.Error(t, err)
+
+	c.Stop()
+	c = newTestDiskCache(t, cfg)
+	c.Stop()
+
+	_, err = os.Stat(filepath.Join(dir, diskCacheLockFile))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,354 @@
+package tsdb
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/cache"
+	"github.com/thanos-io/thanos/pkg/discovery/dns"
+	"github.com/thanos-io/thanos/pkg/model"
+	"github.com/thanos-io/thanos/pkg/runutil"
+	"github.com/thanos-io/thanos/pkg/store/cache/cachekey"
+	"github.com/vimeo/galaxycache"
+	galaxyhttp "github.com/vimeo/galaxycache/http"
+
+	"github.com/cortexproject/cortex/pkg/util/concurrency"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+const (
+	// GroupcacheBasePath is the HTTP path prefix of the requests between the groupcache peers.
+	GroupcacheBasePath = "/_galaxycache/"
+)
+
+var (
+	errGroupcacheSelfURLRequired    = errors.New("the groupcache self URL is required when a cache uses the groupcache backend")
+	errGroupcacheTrailingSlash      = errors.New("the groupcache peers and self URL must not have a trailing slash")
+	errGroupcacheNotLastLevel       = errors.New("the groupcache backend must be the last level of a multi-level cache, since it fills itself from the bucket")
+	errGroupcacheMaxSize            = errors.New("the groupcache max size must be greater than 0")
+	errGroupcacheInvalidConcurrency = errors.New("the groupcache fetch concurrency must be greater than 0")
+	errGroupcacheNotInitialized     = errors.New("the groupcache peers haven't been initialized")
+	errGroupcacheUnconfiguredPath   = errors.New("the caching bucket doesn't cache the object")
+	errGroupcacheCompression        = errors.New("the chunks cache compression isn't supported with the groupcache backend")
+	errGroupcacheTTLPolicy          = errors.New("the metadata cache TTL jitter and stale-while-revalidate aren't supported with the groupcache backend")
+)
+
+// GroupcacheConfig configures the peers of the groupcache, shared by the chunks and metadata caches of the process.
+type GroupcacheConfig struct {
+	Peers            flagext.StringSliceCSV `yaml:"peers"`
+	SelfURL          string                 `yaml:"self_url"`
+	DNSInterval      time.Duration          `yaml:"dns_interval"`
+	Timeout          time.Duration          `yaml:"timeout"`
+	FetchConcurrency int                    `yaml:"fetch_concurrency"`
+}
+
+func (cfg *GroupcacheConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string) {
+	f.Var(&cfg.Peers, prefix+"peers", "[Experimental] Comma-separated list of the HTTP addresses of the groupcache peers, in the host:port format, including the address of this instance. The addresses may be prefixed with dns+ or dnssrv+ to discover the peers with DNS lookups. Empty to use this instance only.")
+	f.StringVar(&cfg.SelfURL, prefix+"self-url", "", "[Experimental] HTTP address of this instance in the groupcache peers, in the host:port format. It must match the address of this instance in the peers, as resolved by the DNS lookups.")
+	f.DurationVar(&cfg.DNSInterval, prefix+"dns-interval", time.Minute, "[Experimental] How frequently the groupcache peers are resolved.")
+	f.DurationVar(&cfg.Timeout, prefix+"timeout", 2*time.Second, "[Experimental] Timeout of the fetch of the items from the groupcache, including the load of the missing items from the peers or the storage. 0 to disable the timeout.")
+	f.IntVar(&cfg.FetchConcurrency, prefix+"fetch-concurrency", 16, "[Experimental] Maximum number of concurrent fetches of the items of a single request from the groupcache.")
+}
+
+// Validate the config.
+func (cfg *GroupcacheConfig) Validate() error {
+	if cfg.SelfURL == "" {
+		return errGroupcacheSelfURLRequired
+	}
+	for _, addr := range append([]string{cfg.SelfURL}, cfg.Peers...) {
+		if strings.HasSuffix(addr, "/") {
+			return errors.WithMessagef(errGroupcacheTrailingSlash, "address: %s", addr)
+		}
+	}
+	if cfg.FetchConcurrency <= 0 {
+		return errGroupcacheInvalidConcurrency
+	}
+	return nil
+}
+
+// GroupcacheBucketCacheConfig configures the groupcache backend of a cache.
+type GroupcacheBucketCacheConfig struct {
+	MaxSizeBytes uint64 `yaml:"max_size_bytes"`
+
+	// Injected internally.
+	Universe *GroupcacheUniverse `yaml:"-"`
+}
+
+func (cfg *GroupcacheBucketCacheConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string, defaultMaxSizeBytes uint64) {
+	f.Uint64Var(&cfg.MaxSizeBytes, prefix+"max-size-bytes", defaultMaxSizeBytes, "[Experimental] Maximum size in bytes of the items of the cache held by this instance in the groupcache.")
+}
+
+// Validate the config.
+func (cfg *GroupcacheBucketCacheConfig) Validate() error {
+	if cfg.MaxSizeBytes == 0 {
+		return errGroupcacheMaxSize
+	}
+	return nil
+}
+
+// GroupcacheUniverse is an embedded distributed cache formed by the queriers, store-gateways and compactors among
+// themselves. Each item is owned by a single peer, chosen by consistent hashing, which loads it from the storage once
+// for all the concurrent requests of all the peers, and keeps it in memory.
+type GroupcacheUniverse struct {
+	services.Service
+
+	cfg      GroupcacheConfig
+	universe *galaxycache.Universe
+	handler  http.Handler
+	provider *dns.Provider
+	logger   log.Logger
+
+	galaxiesMtx sync.Mutex
+}
+
+func NewGroupcacheUniverse(cfg GroupcacheConfig, logger log.Logger, reg prometheus.Registerer) *GroupcacheUniverse {
+	opts := &galaxyhttp.HTTPOptions{BasePath: GroupcacheBasePath}
+	universe := galaxycache.NewUniverse(galaxyhttp.NewHTTPFetchProtocol(opts), cfg.SelfURL)
+
+	mux := http.NewServeMux()
+	galaxyhttp.RegisterHTTPHandler(universe, opts, mux)
+
+	u := &GroupcacheUniverse{
+		cfg:      cfg,
+		universe: universe,
+		handler:  mux,
+		provider: dns.NewProvider(logger, prometheus.WrapRegistererWithPrefix("cortex_groupcache_", reg), dns.GolangResolverType),
+		logger:   logger,
+	}
+	u.Service = services.NewTimerService(cfg.DNSInterval, u.starting, u.resolvePeers, u.stopping)
+	return u
+}
+
+// Handler returns the HTTP handler of the requests of the peers, to serve under GroupcacheBasePath.
+func (u *GroupcacheUniverse) Handler() http.Handler {
+	return u.handler
+}
+
+func (u *GroupcacheUniverse) starting(ctx context.Context) error {
+	// The peers are resolved before running. The caches load all the items from the storage until then.
+	return u.resolvePeers(ctx)
+}
+
+// resolvePeers updates the peers of the groupcache. The failures are logged but don't fail the service, since the
+// caches keep working with the previous peers.
+func (u *GroupcacheUniverse) resolvePeers(ctx context.Context) error {
+	peers := []string{u.cfg.SelfURL}
+	if len(u.cfg.Peers) > 0 {
+		if err := u.provider.Resolve(ctx, u.cfg.Peers); err != nil {
+			level.Warn(u.logger).Log("msg", "failed to resolve the groupcache peers", "err", err)
+			return nil
+		}
+		peers = u.provider.Addresses()
+	}
+
+	if err := u.universe.Set(peers...); err != nil {
+		level.Warn(u.logger).Log("msg", "failed to set the groupcache peers", "err", err)
+	}
+	return nil
+}
+
+func (u *GroupcacheUniverse) stopping(_ error) error {
+	return u.universe.Shutdown()
+}
+
+// galaxy returns the galaxy of the cache, creating it on the first call. The galaxy is shared by all the caching
+// buckets of the cache in the process, eg. the querier and store-gateway in single binary mode.
+func (u *GroupcacheUniverse) galaxy(name string, maxSize int64, getter galaxycache.BackendGetter) (*galaxycache.Galaxy, bool) {
+	u.galaxiesMtx.Lock()
+	defer u.galaxiesMtx.Unlock()
+
+	if g := u.universe.GetGalaxy(name); g != nil {
+		return g, false
+	}
+	return u.universe.NewGalaxy(name, maxSize, getter), true
+}
+
+// groupcacheBucketCache is a cache of the caching bucket backed by a galaxy of the groupcache. The missing items are
+// loaded from the bucket by the galaxy itself, so the items stored by the caching bucket are ignored.
+type groupcacheBucketCache struct {
+	galaxy      *galaxycache.Galaxy
+	timeout     time.Duration
+	concurrency int
+	logger      log.Logger
+}
+
+func newGroupcacheBucketCache(name string, cfg GroupcacheBucketCacheConfig, bkt objstore.Bucket, cachingCfg *cache.CachingBucketConfig, logger log.Logger, reg prometheus.Registerer) (cache.Cache, error) {
+	if cfg.Universe == nil {
+		return nil, errGroupcacheNotInitialized
+	}
+
+	getter := &groupcacheGetter{bkt: bkt, cfg: cachingCfg, logger: logger}
+	galaxy, created := cfg.Universe.galaxy(name, int64(cfg.MaxSizeBytes), galaxycache.GetterFunc(getter.Get))
+	if !created {
+		level.Info(logger).Log("msg", "reusing the groupcache of another caching bucket", "name", name)
+	}
+
+	cache.RegisterCacheStatsCollector(galaxy, &cache.GroupcacheConfig{MaxSize: model.Bytes(cfg.MaxSizeBytes)}, prometheus.WrapRegistererWith(prometheus.Labels{"name": name}, reg))
+
+	return &groupcacheBucketCache{
+		galaxy:      galaxy,
+		timeout:     cfg.Universe.cfg.Timeout,
+		concurrency: cfg.Universe.cfg.FetchConcurrency,
+		logger:      logger,
+	}, nil
+}
+
+// Store implements cache.Cache.
+func (c *groupcacheBucketCache) Store(_ map[string][]byte, _ time.Duration) {
+	// The items are stored by the galaxy when they're fetched.
+}
+
+// Fetch implements cache.Cache.
+func (c *groupcacheBucketCache) Fetch(ctx context.Context, keys []string) map[string][]byte {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	var (
+		hitsMtx sync.Mutex
+		hits    = make(map[string][]byte, len(keys))
+	)
+	_ = concurrency.ForEach(ctx, concurrency.CreateJobsFromStrings(keys), c.concurrency, func(ctx context.Context, job interface{}) error {
+		key := job.(string)
+		value := galaxycache.ByteCodec{}
+		if err := c.galaxy.Get(ctx, key, &value); err != nil {
+			level.Debug(c.logger).Log("msg", "failed to fetch the item from the groupcache", "key", key, "err", err)
+			return nil
+		}
+		b, _, err := value.MarshalBinary()
+		if err != nil {
+			return nil
+		}
+
+		hitsMtx.Lock()
+		hits[key] = b
+		hitsMtx.Unlock()
+		return nil
+	})
+	return hits
+}
+
+// Name implements cache.Cache.
+func (c *groupcacheBucketCache) Name() string {
+	return c.galaxy.Name()
+}
+
+// groupcacheGetter loads the items missing from the groupcache from the bucket, as the caching bucket would.
+type groupcacheGetter struct {
+	bkt    objstore.Bucket
+	cfg    *cache.CachingBucketConfig
+	logger log.Logger
+}
+
+func (g *groupcacheGetter) Get(ctx context.Context, key string, dest galaxycache.Codec) error {
+	k, err := cachekey.ParseBucketCacheKey(trimCacheGenerationKeyPrefix(key))
+	if err != nil {
+		return err
+	}
+
+	switch k.Verb {
+	case cachekey.ExistsVerb:
+		_, existsCfg := g.cfg.FindExistConfig(k.Name)
+		if existsCfg == nil {
+			return errGroupcacheUnconfiguredPath
+		}
+		exists, err := g.bkt.Exists(ctx, k.Name)
+		if err != nil {
+			return err
+		}
+		ttl := existsCfg.DoesntExistTTL
+		if exists {
+			ttl = existsCfg.ExistsTTL
+		}
+		return dest.UnmarshalBinary([]byte(strconv.FormatBool(exists)), time.Now().Add(ttl))
+
+	case cachekey.ContentVerb:
+		_, getCfg := g.cfg.FindGetConfig(k.Name)
+		if getCfg == nil {
+			return errGroupcacheUnconfiguredPath
+		}
+		rc, err := g.bkt.Get(ctx, k.Name)
+		if err != nil {
+			return err
+		}
+		defer runutil.CloseWithLogOnErr(g.logger, rc, "close the object reader")
+
+		b, err := io.ReadAll(rc)
+		if err != nil {
+			return err
+		}
+		return dest.UnmarshalBinary(b, time.Now().Add(getCfg.ContentTTL))
+
+	case cachekey.SubrangeVerb:
+		_, rangeCfg := g.cfg.FindGetRangeConfig(k.Name)
+		if rangeCfg == nil {
+			return errGroupcacheUnconfiguredPath
+		}
+		rc, err := g.bkt.GetRange(ctx, k.Name, k.Start, k.End-k.Start)
+		if err != nil {
+			return err
+		}
+		defer runutil.CloseWithLogOnErr(g.logger, rc, "close the object range reader")
+
+		b, err := io.ReadAll(rc)
+		if err != nil {
+			return err
+		}
+		return dest.UnmarshalBinary(b, time.Now().Add(rangeCfg.SubrangeTTL))
+
+	case cachekey.AttributesVerb:
+		_, attrsCfg := g.cfg.FindAttributesConfig(k.Name)
+		if attrsCfg == nil {
+			return errGroupcacheUnconfiguredPath
+		}
+		attrs, err := g.bkt.Attributes(ctx, k.Name)
+		if err != nil {
+			return err
+		}
+		b, err := json.Marshal(attrs)
+		if err != nil {
+			return err
+		}
+		return dest.UnmarshalBinary(b, time.Now().Add(attrsCfg.TTL))
+
+	case cachekey.IterVerb, cachekey.IterRecursiveVerb:
+		_, iterCfg := g.cfg.FindIterConfig(k.Name)
+		if iterCfg == nil {
+			return errGroupcacheUnconfiguredPath
+		}
+		var opts []objstore.IterOption
+		if k.Verb == cachekey.IterRecursiveVerb {
+			opts = append(opts, objstore.WithRecursiveIter)
+		}
+		var files []string
+		if err := g.bkt.Iter(ctx, k.Name, func(file string) error {
+			files = append(files, file)
+			return nil
+		}, opts...); err != nil {
+			return err
+		}
+		b, err := iterCfg.Codec.Encode(files)
+		if err != nil {
+			return err
+		}
+		return dest.UnmarshalBinary(b, time.Now().Add(iterCfg.TTL))
+
+	default:
+		return errors.Errorf("unsupported bucket cache key verb: %s", k.Verb)
+	}
+}
@@ -0,0 +1,148 @@
+package tsdb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/cortexproject/cortex/pkg/util/compression"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+func TestCacheBackend_GroupcacheValidate(t *testing.T) {
+	tests := map[string]struct {
+		cfg      ChunksCacheConfig
+		expected error
+	}{
+		"groupcache": {
+			cfg: ChunksCacheConfig{CacheBackend: CacheBackend{Backend: CacheBackendGroupcache, Groupcache: GroupcacheBucketCacheConfig{MaxSizeBytes: 1024}}},
+		},
+		"groupcache without max size": {
+			cfg:      ChunksCacheConfig{CacheBackend: CacheBackend{Backend: CacheBackendGroupcache}},
+			expected: errGroupcacheMaxSize,
+		},
+		"groupcache not in the last level": {
+			cfg: ChunksCacheConfig{CacheBackend: CacheBackend{
+				Backend:    "groupcache,inmemory",
+				InMemory:   InMemoryBucketCacheConfig{MaxSizeBytes: 1024},
+				Groupcache: GroupcacheBucketCacheConfig{MaxSizeBytes: 1024},
+				MultiLevel: MultiLevelBucketCacheConfig{MaxAsyncConcurrency: 1, MaxAsyncBufferSize: 1, MaxBackfillItems: 1, BackfillTTL: 1},
+			}},
+			expected: errGroupcacheNotLastLevel,
+		},
+		"groupcache with compression": {
+			cfg: ChunksCacheConfig{
+				CacheBackend: CacheBackend{Backend: CacheBackendGroupcache, Groupcache: GroupcacheBucketCacheConfig{MaxSizeBytes: 1024}},
+				Compression:  compression.Config{Codec: compression.Snappy},
+			},
+			expected: errGroupcacheCompression,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.ErrorIs(t, testData.cfg.Validate(), testData.expected)
+		})
+	}
+}
+
+func TestGroupcacheConfig_Validate(t *testing.T) {
+	cfg := GroupcacheConfig{FetchConcurrency: 1}
+	assert.ErrorIs(t, cfg.Validate(), errGroupcacheSelfURLRequired)
+
+	cfg.SelfURL = "10.0.0.1:80"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Peers = []string{"dns+cortex-store-gateway:80/"}
+	assert.ErrorIs(t, cfg.Validate(), errGroupcacheTrailingSlash)
+}
+
+func TestGroupcacheBucketCache_ShouldLoadTheMissingItemsOnce(t *testing.T) {
+	ctx := context.Background()
+	bkt := &countingBucket{Bucket: objstore.NewInMemBucket(), calls: map[string]int{}}
+	require.NoError(t, bkt.Upload(ctx, "user-1/block-1/meta.json", strings.NewReader("content")))
+
+	universe := NewGroupcacheUniverse(GroupcacheConfig{SelfURL: "localhost:80", FetchConcurrency: 1}, log.NewNopLogger(), nil)
+	cachingBkt := newGroupcacheCachingBucket(t, universe, bkt)
+
+	for i := 0; i < 3; i++ {
+		assertObjectContent(t, cachingBkt, "user-1/block-1/meta.json", "content")
+	}
+	assert.Equal(t, 1, bkt.calls[objstore.OpGet])
+	assert.Equal(t, 1, bkt.calls[objstore.OpExists])
+}
+
+func TestGroupcacheBucketCache_ShouldLoadTheItemsFromTheOwnerPeer(t *testing.T) {
+	const numObjects = 20
+
+	ctx := context.Background()
+	bkt := &countingBucket{Bucket: objstore.NewInMemBucket(), calls: map[string]int{}}
+	for i := 0; i < numObjects; i++ {
+		require.NoError(t, bkt.Upload(ctx, fmt.Sprintf("user-1/block-%d/meta.json", i), strings.NewReader(fmt.Sprintf("content-%d", i))))
+	}
+
+	// The listeners are created first, since each peer needs the addresses of all the peers.
+	var universes []*GroupcacheUniverse
+	var servers []*httptest.Server
+	for i := 0; i < 2; i++ {
+		server := httptest.NewUnstartedServer(nil)
+		servers = append(servers, server)
+		defer server.Close()
+	}
+	var peers []string
+	for _, server := range servers {
+		peers = append(peers, server.Listener.Addr().String())
+	}
+	for i, server := range servers {
+		universe := NewGroupcacheUniverse(GroupcacheConfig{Peers: peers, SelfURL: peers[i], FetchConcurrency: 1}, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+		require.NoError(t, universe.resolvePeers(ctx))
+		server.Config.Handler = universe.Handler()
+		server.Start()
+		universes = append(universes, universe)
+	}
+
+	var cachingBkts []objstore.Bucket
+	for _, universe := range universes {
+		cachingBkts = append(cachingBkts, newGroupcacheCachingBucket(t, universe, bkt))
+	}
+
+	// Each object is loaded from the bucket once, by the peer owning it, whatever the peer it's read from.
+	for i := 0; i < numObjects; i++ {
+		for _, cachingBkt := range cachingBkts {
+			assertObjectContent(t, cachingBkt, fmt.Sprintf("user-1/block-%d/meta.json", i), fmt.Sprintf("content-%d", i))
+		}
+	}
+	assert.Equal(t, numObjects, bkt.calls[objstore.OpGet])
+}
+
+func newGroupcacheCachingBucket(t *testing.T, universe *GroupcacheUniverse, bkt objstore.Bucket) objstore.Bucket {
+	storageCfg := BlocksStorageConfig{}
+	flagext.DefaultValues(&storageCfg)
+	cfg := storageCfg.BucketStore.MetadataCache
+	cfg.Backend = CacheBackendGroupcache
+	cfg.Groupcache.Universe = universe
+	require.NoError(t, cfg.Validate())
+
+	cachingBkt, err := CreateCachingBucket(ChunksCacheConfig{}, cfg, NewMatchers(), objstore.WithNoopInstr(bkt), log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+	return cachingBkt
+}
+
+func assertObjectContent(t *testing.T, bkt objstore.Bucket, name, expected string) {
+	rc, err := bkt.Get(context.Background(), name)
+	require.NoError(t, err)
+	defer rc.Close()
+
+	content, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(content))
+}
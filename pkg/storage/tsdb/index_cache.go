@@ -15,6 +15,7 @@ import (
 	storecache "github.com/thanos-io/thanos/pkg/store/cache"
 
 	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/compression"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 )
 
@@ -28,6 +29,9 @@ const (
 	// IndexCacheBackendRedis is the value for the redis index cache backend.
 	IndexCacheBackendRedis = "redis"
 
+	// IndexCacheBackendDisk is the value for the disk index cache backend.
+	IndexCacheBackendDisk = "disk"
+
 	// IndexCacheBackendDefault is the value for the default index cache backend.
 	IndexCacheBackendDefault = IndexCacheBackendInMemory
 
@@ -37,7 +41,7 @@ const (
 )
 
 var (
-	supportedIndexCacheBackends = []string{IndexCacheBackendInMemory, IndexCacheBackendMemcached, IndexCacheBackendRedis}
+	supportedIndexCacheBackends = []string{IndexCacheBackendInMemory, IndexCacheBackendMemcached, IndexCacheBackendRedis, IndexCacheBackendDisk}
 
 	errUnsupportedIndexCacheBackend = errors.New("unsupported index cache backend")
 	errDuplicatedIndexCacheBackend  = errors.New("duplicated index cache backend")
@@ -52,7 +56,11 @@ type IndexCacheConfig struct {
 	InMemory   InMemoryIndexCacheConfig   `yaml:"inmemory"`
 	Memcached  MemcachedIndexCacheConfig  `yaml:"memcached"`
 	Redis      RedisIndexCacheConfig      `yaml:"redis"`
+	Disk       DiskIndexCacheConfig       `yaml:"disk"`
 	MultiLevel MultiLevelIndexCacheConfig `yaml:"multilevel"`
+
+	PostingsCompression compression.Config `yaml:"postings_compression"`
+	SeriesCompression   compression.Config `yaml:"series_compression"`
 }
 
 func (cfg *IndexCacheConfig) RegisterFlags(f *flag.FlagSet) {
@@ -68,7 +76,10 @@ func (cfg *IndexCacheConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix str
 	cfg.InMemory.RegisterFlagsWithPrefix(f, prefix+"inmemory.")
 	cfg.Memcached.RegisterFlagsWithPrefix(f, prefix+"memcached.")
 	cfg.Redis.RegisterFlagsWithPrefix(f, prefix+"redis.")
+	cfg.Disk.RegisterFlagsWithPrefix(f, prefix+"disk.")
 	cfg.MultiLevel.RegisterFlagsWithPrefix(f, prefix+"multilevel.")
+	cfg.PostingsCompression.RegisterFlagsWithPrefix(f, prefix+"postings-compression.", "postings and expanded postings")
+	cfg.SeriesCompression.RegisterFlagsWithPrefix(f, prefix+"series-compression.", "series")
 }
 
 // Validate the config.
@@ -100,6 +111,10 @@ func (cfg *IndexCacheConfig) Validate() error {
 			if err := cfg.Redis.Validate(); err != nil {
 				return err
 			}
+		} else if backend == IndexCacheBackendDisk {
+			if err := cfg.Disk.Validate(); err != nil {
+				return err
+			}
 		} else {
 			if err := cfg.InMemory.Validate(); err != nil {
 				return err
@@ -109,6 +124,12 @@ func (cfg *IndexCacheConfig) Validate() error {
 		configuredBackends[backend] = struct{}{}
 	}
 
+	if err := cfg.PostingsCompression.Validate(); err != nil {
+		return errors.Wrap(err, "postings compression")
+	}
+	if err := cfg.SeriesCompression.Validate(); err != nil {
+		return errors.Wrap(err, "series compression")
+	}
 	return nil
 }
 
@@ -188,6 +209,23 @@ func (cfg *RedisIndexCacheConfig) Validate() error {
 	return storecache.ValidateEnabledItems(cfg.EnabledItems)
 }
 
+type DiskIndexCacheConfig struct {
+	DiskCacheConfig `yaml:",inline"`
+	EnabledItems    []string `yaml:"enabled_items"`
+}
+
+func (cfg *DiskIndexCacheConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string) {
+	cfg.DiskCacheConfig.RegisterFlagsWithPrefix(f, prefix)
+	f.Var((*flagext.StringSlice)(&cfg.EnabledItems), prefix+"enabled-items", "Selectively cache index item types. Supported values are Postings, ExpandedPostings and Series")
+}
+
+func (cfg *DiskIndexCacheConfig) Validate() error {
+	if err := cfg.DiskCacheConfig.Validate(); err != nil {
+		return err
+	}
+	return storecache.ValidateEnabledItems(cfg.EnabledItems)
+}
+
 // NewIndexCache creates a new index cache based on the input configuration.
 func NewIndexCache(cfg IndexCacheConfig, logger log.Logger, registerer prometheus.Registerer) (storecache.IndexCache, error) {
 	splitBackends := strings.Split(cfg.Backend, ",")
@@ -236,12 +274,24 @@ func NewIndexCache(cfg IndexCacheConfig, logger log.Logger, registerer prometheu
 			}
 			caches = append(caches, cache)
 			enabledItems = append(enabledItems, cfg.Redis.EnabledItems)
+		case IndexCacheBackendDisk:
+			c, err := newDiskCache("index-cache", cfg.Disk.DiskCacheConfig, logger, registerer)
+			if err != nil {
+				return nil, errors.Wrapf(err, "create index cache disk cache")
+			}
+			cache, err := storecache.NewRemoteIndexCache(logger, c, nil, iReg, defaultTTL)
+			if err != nil {
+				return nil, err
+			}
+			caches = append(caches, cache)
+			enabledItems = append(enabledItems, cfg.Disk.EnabledItems)
 		default:
 			return nil, errUnsupportedIndexCacheBackend
 		}
 	}
 
-	return newMultiLevelCache(registerer, cfg.MultiLevel, enabledItems, caches...), nil
+	c := newMultiLevelCache(registerer, cfg.MultiLevel, enabledItems, caches...)
+	return newCompressedIndexCache(c, cfg.PostingsCompression, cfg.SeriesCompression, logger)
 }
 
 func newInMemoryIndexCache(cfg InMemoryIndexCacheConfig, logger log.Logger, registerer prometheus.Registerer) (storecache.IndexCache, error) {
@@ -274,5 +324,5 @@ func newRedisIndexCacheClient(cfg RedisClientConfig, logger log.Logger, register
 		return nil, errors.Wrapf(err, "create index cache redis client")
 	}
 
-	return client, err
+	return newDroppedItemsCountingClient("index-cache", client, registerer), nil
 }
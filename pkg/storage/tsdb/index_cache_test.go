@@ -76,6 +76,24 @@ func TestIndexCacheConfig_Validate(t *testing.T) {
 			},
 			expected: fmt.Errorf("unsupported item type foo"),
 		},
+		"no disk cache directory should fail": {
+			cfg: func() IndexCacheConfig {
+				cfg := IndexCacheConfig{}
+				flagext.DefaultValues(&cfg)
+				cfg.Backend = "inmemory,disk"
+				return cfg
+			}(),
+			expected: errDiskCacheNoDir,
+		},
+		"disk cache with a directory should pass": {
+			cfg: func() IndexCacheConfig {
+				cfg := IndexCacheConfig{}
+				flagext.DefaultValues(&cfg)
+				cfg.Backend = "inmemory,disk"
+				cfg.Disk.Dir = "/tmp/index-cache"
+				return cfg
+			}(),
+		},
 	}
 
 	for testName, testData := range tests {
@@ -0,0 +1,183 @@
+package tsdb
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/thanos/pkg/cache"
+	"github.com/thanos-io/thanos/pkg/cacheutil"
+)
+
+var errInvalidBackfillTTL = errors.New("invalid backfill_ttl, must greater than 0")
+
+type MultiLevelBucketCacheConfig struct {
+	MaxAsyncConcurrency int           `yaml:"max_async_concurrency"`
+	MaxAsyncBufferSize  int           `yaml:"max_async_buffer_size"`
+	MaxBackfillItems    int           `yaml:"max_backfill_items"`
+	BackfillTTL         time.Duration `yaml:"backfill_ttl"`
+}
+
+func (cfg *MultiLevelBucketCacheConfig) Validate() error {
+	if cfg.MaxAsyncBufferSize <= 0 {
+		return errInvalidMaxAsyncBufferSize
+	}
+	if cfg.MaxAsyncConcurrency <= 0 {
+		return errInvalidMaxAsyncConcurrency
+	}
+	if cfg.MaxBackfillItems <= 0 {
+		return errInvalidMaxBackfillItems
+	}
+	if cfg.BackfillTTL <= 0 {
+		return errInvalidBackfillTTL
+	}
+	return nil
+}
+
+func (cfg *MultiLevelBucketCacheConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string) {
+	f.IntVar(&cfg.MaxAsyncConcurrency, prefix+"max-async-concurrency", 50, "The maximum number of concurrent asynchronous operations can occur when backfilling cache items.")
+	f.IntVar(&cfg.MaxAsyncBufferSize, prefix+"max-async-buffer-size", 10000, "The maximum number of enqueued asynchronous operations allowed when backfilling cache items.")
+	f.IntVar(&cfg.MaxBackfillItems, prefix+"max-backfill-items", 10000, "The maximum number of items to backfill per asynchronous operation.")
+	f.DurationVar(&cfg.BackfillTTL, prefix+"backfill-ttl", 5*time.Minute, "TTL of the items backfilled in the upper levels of the cache, from the lower levels of the cache. The TTL isn't applied by the in-memory cache, which evicts the least recently used items instead.")
+}
+
+// multiLevelBucketCache is a cache made of an ordered list of caches, eg. an in-memory cache in front of a remote
+// cache. The items are fetched from the first levels first, and the items found in a level are backfilled
+// asynchronously into the previous levels.
+type multiLevelBucketCache struct {
+	name   string
+	caches []cache.Cache
+
+	fetchLatency         prometheus.Histogram
+	backFillLatency      prometheus.Histogram
+	backfillProcessor    *cacheutil.AsyncOperationProcessor
+	backfillDroppedItems prometheus.Counter
+
+	maxBackfillItems int
+	backfillTTL      time.Duration
+}
+
+func newMultiLevelBucketCache(name string, cfg MultiLevelBucketCacheConfig, reg prometheus.Registerer, c ...cache.Cache) cache.Cache {
+	if len(c) == 1 {
+		return c[0]
+	}
+
+	reg = prometheus.WrapRegistererWith(prometheus.Labels{"name": name}, reg)
+	return &multiLevelBucketCache{
+		name:              name,
+		caches:            c,
+		backfillProcessor: cacheutil.NewAsyncOperationProcessor(cfg.MaxAsyncBufferSize, cfg.MaxAsyncConcurrency),
+		fetchLatency: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+			Name:    "cortex_bucket_cache_multilevel_fetch_duration_seconds",
+			Help:    "Histogram to track latency to fetch items from multi level bucket cache",
+			Buckets: []float64{0.01, 0.1, 0.3, 0.6, 1, 3, 6, 10, 15, 20, 25, 30, 40, 50, 60, 90},
+		}),
+		backFillLatency: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+			Name:    "cortex_bucket_cache_multilevel_backfill_duration_seconds",
+			Help:    "Histogram to track latency to backfill items from multi level bucket cache",
+			Buckets: []float64{0.01, 0.1, 0.3, 0.6, 1, 3, 6, 10, 15, 20, 25, 30, 40, 50, 60, 90},
+		}),
+		backfillDroppedItems: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_bucket_cache_multilevel_backfill_dropped_items_total",
+			Help: "Total number of items dropped due to async buffer full when backfilling multilevel bucket cache",
+		}),
+		maxBackfillItems: cfg.MaxBackfillItems,
+		backfillTTL:      cfg.BackfillTTL,
+	}
+}
+
+// Store implements cache.Cache.
+func (m *multiLevelBucketCache) Store(data map[string][]byte, ttl time.Duration) {
+	wg := sync.WaitGroup{}
+	wg.Add(len(m.caches))
+	for _, c := range m.caches {
+		cache := c
+		go func() {
+			defer wg.Done()
+			cache.Store(data, ttl)
+		}()
+	}
+	wg.Wait()
+}
+
+// Fetch implements cache.Cache.
+func (m *multiLevelBucketCache) Fetch(ctx context.Context, keys []string) map[string][]byte {
+	timer := prometheus.NewTimer(m.fetchLatency)
+	defer timer.ObserveDuration()
+
+	hits := map[string][]byte{}
+	misses := keys
+	backfillItems := make([]map[string][]byte, len(m.caches)-1)
+	for i, c := range m.caches {
+		if ctx.Err() != nil {
+			return hits
+		}
+
+		h := c.Fetch(ctx, misses)
+		if len(h) > 0 {
+			misses = missingKeys(misses, h)
+			for key, value := range h {
+				hits[key] = value
+			}
+			if i > 0 {
+				backfillItems[i-1] = h
+			}
+		}
+
+		if len(misses) == 0 {
+			break
+		}
+	}
+
+	// The items found in a level are backfilled into all the previous levels, which missed them.
+	for i, items := range backfillItems {
+		if len(items) == 0 {
+			continue
+		}
+		upperCaches, backfill := m.caches[:i+1], items
+		if err := m.backfillProcessor.EnqueueAsync(func() {
+			m.backfill(upperCaches, backfill)
+		}); errors.Is(err, cacheutil.ErrAsyncBufferFull) {
+			m.backfillDroppedItems.Add(float64(len(backfill)))
+		}
+	}
+
+	return hits
+}
+
+func (m *multiLevelBucketCache) backfill(caches []cache.Cache, items map[string][]byte) {
+	timer := prometheus.NewTimer(m.backFillLatency)
+	defer timer.ObserveDuration()
+
+	batch := make(map[string][]byte, min(len(items), m.maxBackfillItems))
+	for key, value := range items {
+		if len(batch) >= m.maxBackfillItems {
+			m.backfillDroppedItems.Add(float64(len(items) - len(batch)))
+			break
+		}
+		batch[key] = value
+	}
+
+	for _, c := range caches {
+		c.Store(batch, m.backfillTTL)
+	}
+}
+
+// Name implements cache.Cache.
+func (m *multiLevelBucketCache) Name() string {
+	return m.name
+}
+
+func missingKeys(keys []string, hits map[string][]byte) []string {
+	misses := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := hits[key]; !ok {
+			misses = append(misses, key)
+		}
+	}
+	return misses
+}
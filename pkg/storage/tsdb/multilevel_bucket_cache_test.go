@@ -0,0 +1,228 @@
+package tsdb
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/cache"
+)
+
+func TestCacheBackend_MultiLevelValidate(t *testing.T) {
+	multiLevelCfg := MultiLevelBucketCacheConfig{
+		MaxAsyncBufferSize:  1,
+		MaxAsyncConcurrency: 1,
+		MaxBackfillItems:    1,
+		BackfillTTL:         time.Minute,
+	}
+
+	tests := map[string]struct {
+		cfg      CacheBackend
+		expected error
+	}{
+		"multiple backends": {
+			cfg: CacheBackend{
+				Backend:    "inmemory,redis",
+				InMemory:   InMemoryBucketCacheConfig{MaxSizeBytes: 1024},
+				Redis:      RedisClientConfig{Addresses: "localhost:6379"},
+				MultiLevel: multiLevelCfg,
+			},
+		},
+		"duplicated backends": {
+			cfg: CacheBackend{
+				Backend:    "inmemory,inmemory",
+				InMemory:   InMemoryBucketCacheConfig{MaxSizeBytes: 1024},
+				MultiLevel: multiLevelCfg,
+			},
+			expected: errDuplicatedBucketCacheBackend,
+		},
+		"invalid backfill TTL": {
+			cfg: CacheBackend{
+				Backend:  "inmemory,redis",
+				InMemory: InMemoryBucketCacheConfig{MaxSizeBytes: 1024},
+				Redis:    RedisClientConfig{Addresses: "localhost:6379"},
+				MultiLevel: MultiLevelBucketCacheConfig{
+					MaxAsyncBufferSize:  1,
+					MaxAsyncConcurrency: 1,
+					MaxBackfillItems:    1,
+				},
+			},
+			expected: errInvalidBackfillTTL,
+		},
+		"invalid max backfill items": {
+			cfg: CacheBackend{
+				Backend:  "inmemory,redis",
+				InMemory: InMemoryBucketCacheConfig{MaxSizeBytes: 1024},
+				Redis:    RedisClientConfig{Addresses: "localhost:6379"},
+				MultiLevel: MultiLevelBucketCacheConfig{
+					MaxAsyncBufferSize:  1,
+					MaxAsyncConcurrency: 1,
+					BackfillTTL:         time.Minute,
+				},
+			},
+			expected: errInvalidMaxBackfillItems,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			if testData.expected == nil {
+				assert.NoError(t, testData.cfg.Validate())
+			} else {
+				assert.ErrorIs(t, testData.cfg.Validate(), testData.expected)
+			}
+		})
+	}
+
+	cfg := CacheBackend{Backend: "inmemory,unknown", InMemory: InMemoryBucketCacheConfig{MaxSizeBytes: 1024}, MultiLevel: multiLevelCfg}
+	assert.ErrorContains(t, cfg.Validate(), "unsupported cache backend: unknown")
+}
+
+func TestCreateCache_MultiLevel(t *testing.T) {
+	s, err := miniredis.Run()
+	require.NoError(t, err)
+	defer s.Close()
+
+	reg := prometheus.NewPedanticRegistry()
+	cfg := CacheBackend{
+		Backend:  "inmemory,redis",
+		InMemory: InMemoryBucketCacheConfig{MaxSizeBytes: 1024},
+		Redis: RedisClientConfig{
+			Addresses:              s.Addr(),
+			MaxGetMultiConcurrency: 1,
+			GetMultiBatchSize:      100,
+			MaxSetMultiConcurrency: 1,
+			SetMultiBatchSize:      100,
+		},
+		MultiLevel: MultiLevelBucketCacheConfig{
+			MaxAsyncBufferSize:  1,
+			MaxAsyncConcurrency: 1,
+			MaxBackfillItems:    1,
+			BackfillTTL:         time.Minute,
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	// The chunks and metadata caches can both be multi-level caches.
+	for _, name := range []string{"chunks-cache", "metadata-cache"} {
+		c, err := createCache(name, &cfg, nil, nil, log.NewNopLogger(), reg)
+		require.NoError(t, err)
+		require.IsType(t, &multiLevelBucketCache{}, c)
+		assert.Equal(t, name, c.Name())
+	}
+}
+
+func TestMultiLevelBucketCache(t *testing.T) {
+	ctx := context.Background()
+	cfg := MultiLevelBucketCacheConfig{
+		MaxAsyncConcurrency: 10,
+		MaxAsyncBufferSize:  100000,
+		MaxBackfillItems:    10000,
+		BackfillTTL:         time.Minute,
+	}
+
+	t.Run("should return the single cache", func(t *testing.T) {
+		l1 := newMockBucketCache()
+		assert.Equal(t, l1, newMultiLevelBucketCache("test", cfg, nil, l1))
+	})
+
+	t.Run("should store the items in all the levels", func(t *testing.T) {
+		l1, l2 := newMockBucketCache(), newMockBucketCache()
+		c := newMultiLevelBucketCache("test", cfg, nil, l1, l2)
+
+		c.Store(map[string][]byte{"a": []byte("1")}, time.Hour)
+		assert.Equal(t, map[string][]byte{"a": []byte("1")}, l1.items)
+		assert.Equal(t, map[string][]byte{"a": []byte("1")}, l2.items)
+	})
+
+	t.Run("should fetch the misses from the next levels and backfill the previous levels", func(t *testing.T) {
+		l1, l2, l3 := newMockBucketCache(), newMockBucketCache(), newMockBucketCache()
+		l1.items["a"] = []byte("1")
+		l2.items["b"] = []byte("2")
+		l3.items["c"] = []byte("3")
+		c := newMultiLevelBucketCache("test", cfg, nil, l1, l2, l3)
+
+		hits := c.Fetch(ctx, []string{"a", "b", "c", "d"})
+		assert.Equal(t, map[string][]byte{"a": []byte("1"), "b": []byte("2"), "c": []byte("3")}, hits)
+		assert.Equal(t, []string{"a", "b", "c", "d"}, l1.fetched)
+		assert.Equal(t, []string{"b", "c", "d"}, l2.fetched)
+		assert.Equal(t, []string{"c", "d"}, l3.fetched)
+
+		require.Eventually(t, func() bool {
+			return len(l1.get()) == 3 && len(l2.get()) == 2
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, map[string][]byte{"a": []byte("1"), "b": []byte("2"), "c": []byte("3")}, l1.get())
+		assert.Equal(t, map[string][]byte{"b": []byte("2"), "c": []byte("3")}, l2.get())
+		assert.Equal(t, time.Minute, l1.lastTTL())
+	})
+
+	t.Run("should not fetch the next levels if all the items are found", func(t *testing.T) {
+		l1, l2 := newMockBucketCache(), newMockBucketCache()
+		l1.items["a"] = []byte("1")
+		c := newMultiLevelBucketCache("test", cfg, nil, l1, l2)
+
+		assert.Equal(t, map[string][]byte{"a": []byte("1")}, c.Fetch(ctx, []string{"a"}))
+		assert.Nil(t, l2.fetched)
+	})
+}
+
+type mockBucketCache struct {
+	mtx     sync.Mutex
+	items   map[string][]byte
+	ttl     time.Duration
+	fetched []string
+}
+
+func newMockBucketCache() *mockBucketCache {
+	return &mockBucketCache{items: map[string][]byte{}}
+}
+
+func (m *mockBucketCache) Store(data map[string][]byte, ttl time.Duration) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for key, value := range data {
+		m.items[key] = value
+	}
+	m.ttl = ttl
+}
+
+func (m *mockBucketCache) Fetch(_ context.Context, keys []string) map[string][]byte {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.fetched = append(m.fetched, keys...)
+	hits := map[string][]byte{}
+	for _, key := range keys {
+		if value, ok := m.items[key]; ok {
+			hits[key] = value
+		}
+	}
+	return hits
+}
+
+func (m *mockBucketCache) Name() string {
+	return "mock"
+}
+
+func (m *mockBucketCache) get() map[string][]byte {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	items := make(map[string][]byte, len(m.items))
+	for key, value := range m.items {
+		items[key] = value
+	}
+	return items
+}
+
+func (m *mockBucketCache) lastTTL() time.Duration {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.ttl
+}
+
+var _ cache.Cache = &mockBucketCache{}
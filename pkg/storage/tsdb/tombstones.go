@@ -0,0 +1,307 @@
+package tsdb
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/thanos-io/objstore"
+
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+)
+
+const (
+	// TombstonesPath is the path, relative to the tenant location in the bucket,
+	// where the series deletion tombstones are stored.
+	TombstonesPath = "tombstones"
+
+	tombstoneFileExtension = ".json"
+)
+
+// BlockDeleteRequestState is the state of a series deletion request.
+type BlockDeleteRequestState string
+
+const (
+	// StatePending is the state of a deletion request which has not been applied to the blocks yet.
+	// The deleted series are filtered out at query time.
+	StatePending BlockDeleteRequestState = "pending"
+
+	// StateProcessed is the state of a deletion request which has been applied to all the blocks.
+	// The deleted series are still filtered out at query time until the rewritten blocks are deleted.
+	StateProcessed BlockDeleteRequestState = "processed"
+
+	// StateCancelled is the state of a deletion request which has been cancelled.
+	StateCancelled BlockDeleteRequestState = "deleted"
+)
+
+var (
+	ErrTombstoneNotFound = errors.New("tombstone not found")
+
+	// statesOrder is used to pick the most recent state when the same deletion request
+	// is found in the bucket with multiple states, because the previous state file
+	// failed to be deleted. A request can be both processed and cancelled if it has been
+	// cancelled while the compactor was applying it: the processed state wins, given the
+	// deleted samples can't be restored.
+	statesOrder = map[BlockDeleteRequestState]int{
+		StatePending:   0,
+		StateCancelled: 1,
+		StateProcessed: 2,
+	}
+)
+
+// Tombstone holds a series deletion request. The tombstone is stored in the bucket at
+// <tenant>/tombstones/<request id>.json.<state>, and the state is moved forward writing
+// a new file with the new extension and then deleting the previous one.
+type Tombstone struct {
+	RequestID string `json:"request_id"`
+	UserID    string `json:"user_id"`
+
+	// StartTime and EndTime specify the time range of the samples to delete (millis precision, both included).
+	StartTime int64 `json:"start_time"`
+	EndTime   int64 `json:"end_time"`
+
+	// Selectors are the series selectors of the samples to delete. A series is deleted if it matches any selector.
+	Selectors []string `json:"selectors"`
+
+	// RequestCreatedAt and StateCreatedAt are unix timestamps (millis precision) of when
+	// the request and its current state have been created.
+	RequestCreatedAt int64 `json:"request_created_at"`
+	StateCreatedAt   int64 `json:"state_created_at"`
+
+	// State of the request. When reading the tombstone from the bucket, the state is
+	// always taken from the filename extension.
+	State BlockDeleteRequestState `json:"state"`
+}
+
+// NewTombstone makes a new pending tombstone.
+func NewTombstone(userID string, now time.Time, startTime, endTime int64, selectors []string) *Tombstone {
+	return &Tombstone{
+		RequestID:        GetTombstoneRequestID(startTime, endTime, selectors),
+		UserID:           userID,
+		StartTime:        startTime,
+		EndTime:          endTime,
+		Selectors:        selectors,
+		RequestCreatedAt: now.UnixMilli(),
+		StateCreatedAt:   now.UnixMilli(),
+		State:            StatePending,
+	}
+}
+
+// GetTombstoneRequestID returns the deletion request ID, which is a hash of the request
+// parameters so that the same request issued twice results in a single tombstone.
+func GetTombstoneRequestID(startTime, endTime int64, selectors []string) string {
+	sorted := append([]string(nil), selectors...)
+	sort.Strings(sorted)
+
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%d,%d,%s", startTime, endTime, strings.Join(sorted, ","))))
+	return hex.EncodeToString(hash[:])
+}
+
+// GetFilename returns the name of the tombstone file, including its state extension.
+func (t *Tombstone) GetFilename() string {
+	return getTombstoneFilename(t.RequestID, t.State)
+}
+
+// GetRequestCreatedAt returns the time when the deletion request has been created.
+func (t *Tombstone) GetRequestCreatedAt() time.Time {
+	return time.UnixMilli(t.RequestCreatedAt)
+}
+
+// GetStateCreatedAt returns the time when the current state has been created.
+func (t *Tombstone) GetStateCreatedAt() time.Time {
+	return time.UnixMilli(t.StateCreatedAt)
+}
+
+// IsOverlappingInterval returns whether the tombstone time range overlaps the input one
+// (millis precision, both included).
+func (t *Tombstone) IsOverlappingInterval(minT, maxT int64) bool {
+	return t.StartTime <= maxT && minT <= t.EndTime
+}
+
+// GetMatchers parses the tombstone selectors and returns a list of matchers for each selector.
+func (t *Tombstone) GetMatchers() ([][]*labels.Matcher, error) {
+	matchers := make([][]*labels.Matcher, 0, len(t.Selectors))
+	for _, selector := range t.Selectors {
+		m, err := parser.ParseMetricSelector(selector)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse selector %s of tombstone %s", selector, t.RequestID)
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+func getTombstoneFilename(requestID string, state BlockDeleteRequestState) string {
+	return requestID + tombstoneFileExtension + "." + string(state)
+}
+
+func getTombstonePath(requestID string, state BlockDeleteRequestState) string {
+	return path.Join(TombstonesPath, getTombstoneFilename(requestID, state))
+}
+
+// parseTombstoneFilename returns the request ID and state from a tombstone filename.
+func parseTombstoneFilename(filename string) (string, BlockDeleteRequestState, bool) {
+	idx := strings.LastIndex(filename, tombstoneFileExtension+".")
+	if idx <= 0 {
+		return "", "", false
+	}
+
+	state := BlockDeleteRequestState(filename[idx+len(tombstoneFileExtension)+1:])
+	if _, ok := statesOrder[state]; !ok {
+		return "", "", false
+	}
+
+	return filename[:idx], state, true
+}
+
+// WriteTombstone uploads the tombstone to the storage. The input bucket must be
+// scoped to the tenant location.
+func WriteTombstone(ctx context.Context, userBkt objstore.Bucket, t *Tombstone) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return errors.Wrap(err, "serialize tombstone")
+	}
+
+	return errors.Wrap(userBkt.Upload(ctx, getTombstonePath(t.RequestID, t.State), bytes.NewReader(data)), "upload tombstone")
+}
+
+// ReadTombstone reads the tombstone with the given request ID and state from the storage.
+// The input bucket must be scoped to the tenant location.
+func ReadTombstone(ctx context.Context, userBkt objstore.InstrumentedBucketReader, requestID string, state BlockDeleteRequestState) (*Tombstone, error) {
+	tombstonePath := getTombstonePath(requestID, state)
+
+	r, err := userBkt.ReaderWithExpectedErrs(userBkt.IsObjNotFoundErr).Get(ctx, tombstonePath)
+	if err != nil {
+		if userBkt.IsObjNotFoundErr(err) {
+			return nil, ErrTombstoneNotFound
+		}
+
+		return nil, errors.Wrapf(err, "failed to read tombstone object: %s", tombstonePath)
+	}
+
+	t := &Tombstone{}
+	err = json.NewDecoder(r).Decode(t)
+
+	// Close reader before dealing with decode error.
+	if closeErr := r.Close(); closeErr != nil {
+		level.Warn(util_log.Logger).Log("msg", "failed to close bucket reader", "err", closeErr)
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode tombstone object: %s", tombstonePath)
+	}
+
+	// The state is always taken from the filename.
+	t.State = state
+	return t, nil
+}
+
+// GetTombstone returns the tombstone with the given request ID, in its most recent state.
+// The input bucket must be scoped to the tenant location.
+func GetTombstone(ctx context.Context, userBkt objstore.InstrumentedBucketReader, requestID string) (*Tombstone, error) {
+	var result *Tombstone
+
+	for state, order := range statesOrder {
+		t, err := ReadTombstone(ctx, userBkt, requestID, state)
+		if errors.Is(err, ErrTombstoneNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if result == nil || statesOrder[result.State] < order {
+			result = t
+		}
+	}
+
+	if result == nil {
+		return nil, ErrTombstoneNotFound
+	}
+	return result, nil
+}
+
+// GetAllTombstones returns all the tombstones stored in the bucket, each one in its most recent
+// state, sorted by request creation time. The input bucket must be scoped to the tenant location.
+func GetAllTombstones(ctx context.Context, userBkt objstore.InstrumentedBucketReader) ([]*Tombstone, error) {
+	// Find the most recent state of each request.
+	states := map[string]BlockDeleteRequestState{}
+
+	err := userBkt.Iter(ctx, TombstonesPath+"/", func(name string) error {
+		requestID, state, ok := parseTombstoneFilename(path.Base(name))
+		if !ok {
+			return nil
+		}
+
+		if prev, exists := states[requestID]; !exists || statesOrder[prev] < statesOrder[state] {
+			states[requestID] = state
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list tombstones")
+	}
+
+	tombstones := make([]*Tombstone, 0, len(states))
+	for requestID, state := range states {
+		t, err := ReadTombstone(ctx, userBkt, requestID, state)
+		if errors.Is(err, ErrTombstoneNotFound) {
+			// The tombstone has been moved to a new state or deleted in the meanwhile.
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		tombstones = append(tombstones, t)
+	}
+
+	sort.Slice(tombstones, func(i, j int) bool {
+		if tombstones[i].RequestCreatedAt != tombstones[j].RequestCreatedAt {
+			return tombstones[i].RequestCreatedAt < tombstones[j].RequestCreatedAt
+		}
+		return tombstones[i].RequestID < tombstones[j].RequestID
+	})
+
+	return tombstones, nil
+}
+
+// UpdateTombstoneState moves the tombstone to the new state, writing the new state file
+// and then deleting the previous one. The input bucket must be scoped to the tenant location.
+func UpdateTombstoneState(ctx context.Context, userBkt objstore.Bucket, t *Tombstone, newState BlockDeleteRequestState, now time.Time) (*Tombstone, error) {
+	updated := *t
+	updated.State = newState
+	updated.StateCreatedAt = now.UnixMilli()
+
+	if err := WriteTombstone(ctx, userBkt, &updated); err != nil {
+		return nil, err
+	}
+
+	// If the previous state file fails to be deleted, the new state is picked up anyway
+	// when reading the tombstones, so we can safely return the updated tombstone.
+	if err := userBkt.Delete(ctx, getTombstonePath(t.RequestID, t.State)); err != nil && !userBkt.IsObjNotFoundErr(err) {
+		return &updated, errors.Wrap(err, "failed to delete tombstone previous state")
+	}
+
+	return &updated, nil
+}
+
+// DeleteTombstone deletes the tombstone with the given request ID, in any state.
+// The input bucket must be scoped to the tenant location.
+func DeleteTombstone(ctx context.Context, userBkt objstore.Bucket, requestID string) error {
+	for state := range statesOrder {
+		if err := userBkt.Delete(ctx, getTombstonePath(requestID, state)); err != nil && !userBkt.IsObjNotFoundErr(err) {
+			return errors.Wrapf(err, "failed to delete tombstone %s", requestID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package tsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+)
+
+func TestGetTombstoneRequestID(t *testing.T) {
+	id := GetTombstoneRequestID(10, 20, []string{`{a="1"}`, `{b="2"}`})
+
+	// The order of the selectors doesn't matter.
+	assert.Equal(t, id, GetTombstoneRequestID(10, 20, []string{`{b="2"}`, `{a="1"}`}))
+
+	// Different parameters result in a different request ID.
+	assert.NotEqual(t, id, GetTombstoneRequestID(10, 21, []string{`{a="1"}`, `{b="2"}`}))
+	assert.NotEqual(t, id, GetTombstoneRequestID(10, 20, []string{`{a="1"}`}))
+}
+
+func TestParseTombstoneFilename(t *testing.T) {
+	for filename, expected := range map[string]struct {
+		requestID string
+		state     BlockDeleteRequestState
+		ok        bool
+	}{
+		"abc.json.pending":   {requestID: "abc", state: StatePending, ok: true},
+		"abc.json.processed": {requestID: "abc", state: StateProcessed, ok: true},
+		"abc.json.deleted":   {requestID: "abc", state: StateCancelled, ok: true},
+		"abc.json.unknown":   {ok: false},
+		"abc.json":           {ok: false},
+		".json.pending":      {ok: false},
+	} {
+		t.Run(filename, func(t *testing.T) {
+			requestID, state, ok := parseTombstoneFilename(filename)
+			assert.Equal(t, expected.ok, ok)
+			assert.Equal(t, expected.requestID, requestID)
+			assert.Equal(t, expected.state, state)
+		})
+	}
+}
+
+func TestTombstone_IsOverlappingInterval(t *testing.T) {
+	tombstone := NewTombstone("user", time.Now(), 10, 20, []string{`{a="1"}`})
+
+	assert.True(t, tombstone.IsOverlappingInterval(0, 10))
+	assert.True(t, tombstone.IsOverlappingInterval(15, 16))
+	assert.True(t, tombstone.IsOverlappingInterval(20, 30))
+	assert.True(t, tombstone.IsOverlappingInterval(0, 30))
+	assert.False(t, tombstone.IsOverlappingInterval(0, 9))
+	assert.False(t, tombstone.IsOverlappingInterval(21, 30))
+}
+
+func TestTombstone_GetMatchers(t *testing.T) {
+	tombstone := NewTombstone("user", time.Now(), 10, 20, []string{`{a="1"}`, `up{b=~"2.*"}`})
+
+	matchers, err := tombstone.GetMatchers()
+	require.NoError(t, err)
+	require.Len(t, matchers, 2)
+	require.Len(t, matchers[0], 1)
+	assert.Equal(t, `a="1"`, matchers[0][0].String())
+	require.Len(t, matchers[1], 2)
+	assert.Equal(t, `b=~"2.*"`, matchers[1][0].String())
+	assert.Equal(t, `__name__="up"`, matchers[1][1].String())
+
+	tombstone.Selectors = []string{"{invalid"}
+	_, err = tombstone.GetMatchers()
+	require.Error(t, err)
+}
+
+func TestTombstones_WriteReadUpdateDelete(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.WithNoopInstr(objstore.NewInMemBucket())
+	now := time.Now()
+
+	t1 := NewTombstone("user", now.Add(-time.Hour), 10, 20, []string{`{a="1"}`})
+	t2 := NewTombstone("user", now, 30, 40, []string{`{b="2"}`})
+	require.NoError(t, WriteTombstone(ctx, bkt, t1))
+	require.NoError(t, WriteTombstone(ctx, bkt, t2))
+
+	// Read a single tombstone.
+	actual, err := GetTombstone(ctx, bkt, t1.RequestID)
+	require.NoError(t, err)
+	assert.Equal(t, t1, actual)
+
+	_, err = GetTombstone(ctx, bkt, "unknown")
+	assert.ErrorIs(t, err, ErrTombstoneNotFound)
+
+	// Read all tombstones, sorted by request creation time.
+	all, err := GetAllTombstones(ctx, bkt)
+	require.NoError(t, err)
+	assert.Equal(t, []*Tombstone{t1, t2}, all)
+
+	// Update the state of a tombstone.
+	updated, err := UpdateTombstoneState(ctx, bkt, t1, StateProcessed, now)
+	require.NoError(t, err)
+	assert.Equal(t, StateProcessed, updated.State)
+	assert.Equal(t, now.UnixMilli(), updated.StateCreatedAt)
+	assert.Equal(t, t1.RequestCreatedAt, updated.RequestCreatedAt)
+
+	exists, err := bkt.Exists(ctx, getTombstonePath(t1.RequestID, StatePending))
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	all, err = GetAllTombstones(ctx, bkt)
+	require.NoError(t, err)
+	assert.Equal(t, []*Tombstone{updated, t2}, all)
+
+	// Delete a tombstone.
+	require.NoError(t, DeleteTombstone(ctx, bkt, t2.RequestID))
+
+	all, err = GetAllTombstones(ctx, bkt)
+	require.NoError(t, err)
+	assert.Equal(t, []*Tombstone{updated}, all)
+}
+
+func TestGetAllTombstones_ShouldPickTheMostRecentStateOnDuplicates(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.WithNoopInstr(objstore.NewInMemBucket())
+
+	// Simulate a failure while deleting the previous state file.
+	pending := NewTombstone("user", time.Now(), 10, 20, []string{`{a="1"}`})
+	processed := *pending
+	processed.State = StateProcessed
+	require.NoError(t, WriteTombstone(ctx, bkt, pending))
+	require.NoError(t, WriteTombstone(ctx, bkt, &processed))
+
+	all, err := GetAllTombstones(ctx, bkt)
+	require.NoError(t, err)
+	assert.Equal(t, []*Tombstone{&processed}, all)
+
+	actual, err := GetTombstone(ctx, bkt, pending.RequestID)
+	require.NoError(t, err)
+	assert.Equal(t, &processed, actual)
+
+	// Deleting the tombstone removes all the states.
+	require.NoError(t, DeleteTombstone(ctx, bkt, pending.RequestID))
+
+	all, err = GetAllTombstones(ctx, bkt)
+	require.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func TestGetAllTombstones_ShouldPickTheProcessedStateIfAlsoCancelled(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.WithNoopInstr(objstore.NewInMemBucket())
+
+	// Simulate a request cancelled while it was being processed.
+	pending := NewTombstone("user", time.Now(), 10, 20, []string{`{a="1"}`})
+	cancelled := *pending
+	cancelled.State = StateCancelled
+	processed := *pending
+	processed.State = StateProcessed
+	require.NoError(t, WriteTombstone(ctx, bkt, &processed))
+	require.NoError(t, WriteTombstone(ctx, bkt, &cancelled))
+
+	// The result doesn't depend on the order the states are read.
+	for i := 0; i < 10; i++ {
+		all, err := GetAllTombstones(ctx, bkt)
+		require.NoError(t, err)
+		assert.Equal(t, []*Tombstone{&processed}, all)
+
+		actual, err := GetTombstone(ctx, bkt, pending.RequestID)
+		require.NoError(t, err)
+		assert.Equal(t, &processed, actual)
+	}
+}
@@ -0,0 +1,52 @@
+package tsdb
+
+import (
+	"context"
+	"time"
+
+	"github.com/thanos-io/thanos/pkg/cache"
+
+	chunkcache "github.com/cortexproject/cortex/pkg/chunk/cache"
+)
+
+// ttlPolicyBucketCache is a cache of the caching bucket expiring the items with a TTL policy, jittering their TTL and
+// serving them stale while they're revalidated.
+type ttlPolicyBucketCache struct {
+	cache.Cache
+
+	policy *chunkcache.TTLPolicy
+}
+
+func newTTLPolicyBucketCache(c cache.Cache, policy *chunkcache.TTLPolicy) cache.Cache {
+	return &ttlPolicyBucketCache{Cache: c, policy: policy}
+}
+
+// Store implements cache.Cache.
+func (c *ttlPolicyBucketCache) Store(data map[string][]byte, ttl time.Duration) {
+	now := time.Now()
+	wrapped := make(map[string][]byte, len(data))
+	var storeTTL time.Duration
+	for key, value := range data {
+		wrapped[chunkcache.TTLPolicyKeyPrefix+key], storeTTL = c.policy.Wrap(key, value, ttl, now)
+	}
+	c.Cache.Store(wrapped, storeTTL)
+}
+
+// Fetch implements cache.Cache.
+func (c *ttlPolicyBucketCache) Fetch(ctx context.Context, keys []string) map[string][]byte {
+	prefixedKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		prefixedKeys = append(prefixedKeys, chunkcache.TTLPolicyKeyPrefix+key)
+	}
+
+	now := time.Now()
+	hits := c.Cache.Fetch(ctx, prefixedKeys)
+	result := make(map[string][]byte, len(hits))
+	for prefixedKey, wrapped := range hits {
+		key := prefixedKey[len(chunkcache.TTLPolicyKeyPrefix):]
+		if value, ok := c.policy.Unwrap(key, wrapped, now); ok {
+			result[key] = value
+		}
+	}
+	return result
+}
@@ -0,0 +1,39 @@
+package tsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	chunkcache "github.com/cortexproject/cortex/pkg/chunk/cache"
+)
+
+func TestMetadataCacheConfig_ValidateTTLPolicy(t *testing.T) {
+	cfg := MetadataCacheConfig{
+		CacheBackend: CacheBackend{Backend: CacheBackendInMemory, InMemory: InMemoryBucketCacheConfig{MaxSizeBytes: 1024}},
+		TTLPolicy:    chunkcache.TTLPolicyConfig{TTLJitter: 0.1, StaleWhileRevalidate: time.Minute},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.TTLPolicy.TTLJitter = 2
+	assert.Error(t, cfg.Validate())
+
+	cfg.TTLPolicy.TTLJitter = 0.1
+	cfg.CacheBackend = CacheBackend{Backend: CacheBackendGroupcache, Groupcache: GroupcacheBucketCacheConfig{MaxSizeBytes: 1024}}
+	assert.ErrorIs(t, cfg.Validate(), errGroupcacheTTLPolicy)
+}
+
+func TestTTLPolicyBucketCache(t *testing.T) {
+	backend := newMockBucketCache()
+	c := newTTLPolicyBucketCache(backend, chunkcache.NewTTLPolicy("metadata-cache", chunkcache.TTLPolicyConfig{TTLJitter: 0.5, StaleWhileRevalidate: time.Minute}, prometheus.NewPedanticRegistry()))
+
+	c.Store(map[string][]byte{"content:user-1/meta.json": []byte("1"), "content:user-2/meta.json": []byte("2")}, time.Hour)
+	assert.Equal(t, map[string][]byte{"content:user-1/meta.json": []byte("1")}, c.Fetch(context.Background(), []string{"content:user-1/meta.json", "content:user-3/meta.json"}))
+
+	// The items are kept by the cache for their TTL and the stale-while-revalidate period.
+	assert.Equal(t, time.Hour+time.Minute, backend.ttl)
+	assert.Contains(t, backend.get(), chunkcache.TTLPolicyKeyPrefix+"content:user-1/meta.json")
+}
@@ -53,7 +53,11 @@ func (s *UsersScanner) ScanUsers(ctx context.Context) (users, markedForDeletion
 
 	// Scan users from the __markers__ directory.
 	err = s.bucketClient.Iter(ctx, util.GlobalMarkersDir, func(entry string) error {
-		// entry will be of the form __markers__/<user>/
+		// entry will be of the form __markers__/<user>/, the global markers not owned by
+		// any user are skipped.
+		if !strings.HasSuffix(entry, objstore.DirDelim) {
+			return nil
+		}
 		parts := strings.Split(entry, objstore.DirDelim)
 		userID := parts[1]
 		scannedUsers[userID] = struct{}{}
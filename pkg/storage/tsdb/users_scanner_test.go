@@ -16,7 +16,7 @@ import (
 func TestUsersScanner_ScanUsers_ShouldReturnedOwnedUsersOnly(t *testing.T) {
 	bucketClient := &bucket.ClientMock{}
 	bucketClient.MockIter("", []string{"user-1/", "user-2/", "user-3/", "user-4/"}, nil)
-	bucketClient.MockIter("__markers__", []string{"__markers__/user-5/", "__markers__/user-6/", "__markers__/user-7/"}, nil)
+	bucketClient.MockIter("__markers__", []string{"__markers__/user-5/", "__markers__/user-6/", "__markers__/user-7/", "__markers__/global-marker.json"}, nil)
 	bucketClient.MockExists(GetGlobalDeletionMarkPath("user-1"), false, nil)
 	bucketClient.MockExists(GetLocalDeletionMarkPath("user-1"), false, nil)
 	bucketClient.MockExists(GetGlobalDeletionMarkPath("user-3"), true, nil)
@@ -25,6 +25,8 @@ func TestUsersScanner_ScanUsers_ShouldReturnedOwnedUsersOnly(t *testing.T) {
 	bucketClient.MockExists(GetLocalDeletionMarkPath("user-7"), true, nil)
 
 	isOwned := func(userID string) (bool, error) {
+		// The global markers should not be considered users.
+		assert.NotEqual(t, "global-marker.json", userID)
 		return userID == "user-1" || userID == "user-3" || userID == "user-7", nil
 	}
 
@@ -174,6 +174,9 @@ func (u *BucketStores) SyncBlocks(ctx context.Context) error {
 }
 
 func (u *BucketStores) syncUsersBlocksWithRetries(ctx context.Context, f func(context.Context, *store.BucketStore) error) error {
+	// The blocks synchronization is capped by the background bandwidth governor, if any.
+	ctx = bucket.ContextWithBackgroundTraffic(ctx)
+
 	retries := backoff.New(ctx, backoff.Config{
 		MinBackoff: 1 * time.Second,
 		MaxBackoff: 10 * time.Second,
@@ -524,6 +527,7 @@ func (u *BucketStores) getOrCreateStore(userID string) (*store.BucketStore, erro
 		NewReplicaLabelRemover(userLogger, []string{
 			tsdb.TenantIDExternalLabel,
 			tsdb.IngesterIDExternalLabel,
+			tsdb.RollupExternalLabel,
 		}),
 		// Remove Cortex external labels so that they're not injected when querying blocks.
 	}...)
@@ -62,6 +62,8 @@ type Config struct {
 
 	EnabledTenants  flagext.StringSliceCSV `yaml:"enabled_tenants"`
 	DisabledTenants flagext.StringSliceCSV `yaml:"disabled_tenants"`
+
+	BucketRateLimit bucket.RateLimitConfig `yaml:"bucket_rate_limit"`
 }
 
 // RegisterFlags registers the Config flags.
@@ -72,6 +74,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&cfg.ShardingStrategy, "store-gateway.sharding-strategy", util.ShardingStrategyDefault, fmt.Sprintf("The sharding strategy to use. Supported values are: %s.", strings.Join(supportedShardingStrategies, ", ")))
 	f.Var(&cfg.EnabledTenants, "store-gateway.enabled-tenants", "Comma separated list of tenants whose store metrics this storegateway can process. If specified, only these tenants will be handled by storegateway, otherwise this storegateway will be enabled for all the tenants in the store-gateway cluster.")
 	f.Var(&cfg.DisabledTenants, "store-gateway.disabled-tenants", "Comma separated list of tenants whose store metrics this storegateway cannot process. If specified, a storegateway that would normally pick the specified tenant(s) for processing will ignore them instead.")
+	cfg.BucketRateLimit.RegisterFlagsWithPrefix("store-gateway.bucket-rate-limit.", "store-gateway", f)
 }
 
 // Validate the Config.
@@ -103,6 +106,7 @@ type StoreGateway struct {
 	// Ring used for sharding blocks.
 	ringLifecycler *ring.BasicLifecycler
 	ring           *ring.Ring
+	preparation    *ring.InstancePreparation
 
 	// Subservices manager (ring, lifecycler)
 	subservices        *services.Manager
@@ -114,14 +118,16 @@ type StoreGateway struct {
 func NewStoreGateway(gatewayCfg Config, storageCfg cortex_tsdb.BlocksStorageConfig, limits *validation.Overrides, logLevel logging.Level, logger log.Logger, reg prometheus.Registerer) (*StoreGateway, error) {
 	var ringStore kv.Client
 
-	bucketClient, err := createBucketClient(storageCfg, logger, reg)
+	bucketClient, err := createBucketClient(gatewayCfg, storageCfg, logger, reg)
 	if err != nil {
 		return nil, err
 	}
 
 	if gatewayCfg.ShardingEnabled {
+		kvCfg := gatewayCfg.ShardingRing.KVStore
+		kvCfg.CASBatchPeriod = gatewayCfg.ShardingRing.CASBatchPeriod
 		ringStore, err = kv.NewClient(
-			gatewayCfg.ShardingRing.KVStore,
+			kvCfg,
 			ring.GetCodec(),
 			kv.RegistererWithKVName(prometheus.WrapRegistererWithPrefix("cortex_", reg), "store-gateway"),
 			logger,
@@ -196,6 +202,14 @@ func newStoreGateway(gatewayCfg Config, storageCfg cortex_tsdb.BlocksStorageConf
 			return nil, errors.Wrap(err, "create ring lifecycler")
 		}
 
+		g.preparation = ring.NewInstancePreparation(ring.PreparationHooks{
+			SetHandOffOnShutdown: func(enabled bool) {
+				g.ringLifecycler.SetKeepInstanceInTheRingOnShutdown(!enabled && gatewayCfg.ShardingRing.KeepInstanceInTheRingOnShutdown)
+			},
+			ChangeState: g.ringLifecycler.ChangeState,
+			HandOff:     g.waitBlocksHandedOff,
+		}, logger)
+
 		ringCfg := gatewayCfg.ShardingRing.ToRingConfig()
 		g.ring, err = ring.NewWithStoreClientAndStrategy(ringCfg, RingNameForServer, RingKey, ringStore, ring.NewIgnoreUnhealthyInstancesReplicationStrategy(), prometheus.WrapRegistererWithPrefix("cortex_", reg), logger)
 		if err != nil {
@@ -313,6 +327,25 @@ func (g *StoreGateway) starting(ctx context.Context) (err error) {
 	return nil
 }
 
+// waitBlocksHandedOff waits until the other store-gateways had the time to load the blocks of this
+// instance, once it has been switched to LEAVING.
+func (g *StoreGateway) waitBlocksHandedOff(ctx context.Context) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, g.gatewayCfg.ShardingRing.WaitInstanceStateTimeout)
+	defer cancel()
+	if err := ring.WaitInstanceState(ctxWithTimeout, g.ring, g.ringLifecycler.GetInstanceID(), ring.LEAVING); err != nil {
+		return errors.Wrap(err, "wait until the store-gateway is LEAVING in the ring")
+	}
+
+	// The blocks of a LEAVING instance are loaded by additional store-gateways when they detect the ring
+	// topology change, so wait as long as the ring stability is waited at startup.
+	select {
+	case <-time.After(g.gatewayCfg.ShardingRing.WaitStabilityMinDuration):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (g *StoreGateway) running(ctx context.Context) error {
 	var ringTickerChan <-chan time.Time
 	var lastInstanceDescs map[string]ring.InstanceDesc
@@ -407,11 +440,13 @@ func (g *StoreGateway) OnRingInstanceStopping(_ *ring.BasicLifecycler)
 func (g *StoreGateway) OnRingInstanceHeartbeat(_ *ring.BasicLifecycler, _ *ring.Desc, _ *ring.InstanceDesc) {
 }
 
-func createBucketClient(cfg cortex_tsdb.BlocksStorageConfig, logger log.Logger, reg prometheus.Registerer) (objstore.InstrumentedBucket, error) {
-	bucketClient, err := bucket.NewClient(context.Background(), cfg.Bucket, "store-gateway", logger, reg)
+func createBucketClient(gatewayCfg Config, cfg cortex_tsdb.BlocksStorageConfig, logger log.Logger, reg prometheus.Registerer) (objstore.InstrumentedBucket, error) {
+	bucketClient, err := cortex_tsdb.NewBucketClient(context.Background(), cfg, "store-gateway", logger, reg)
 	if err != nil {
 		return nil, errors.Wrap(err, "create bucket client")
 	}
 
-	return bucketClient, nil
+	// Only the blocks synchronization is background traffic, the queries aren't capped by the bandwidth governor.
+	bucketClient = bucket.NewBandwidthGovernedBucketClient(bucketClient, cfg.BandwidthGovernor, true)
+	return bucket.NewRateLimitedBucketClient(bucketClient, gatewayCfg.BucketRateLimit, "store-gateway", reg), nil
 }
@@ -51,3 +51,30 @@ func (c *StoreGateway) RingHandler(w http.ResponseWriter, req *http.Request) {
 
 	c.ring.ServeHTTP(w, req)
 }
+
+// PrepareShutdownHandler prepares the store-gateway to leave the ring when it shuts down.
+func (c *StoreGateway) PrepareShutdownHandler(w http.ResponseWriter, req *http.Request) {
+	if c.checkPreparationAvailable(w) {
+		c.preparation.PrepareShutdownHandler(w, req)
+	}
+}
+
+// PrepareDownscaleHandler switches the store-gateway to LEAVING, so that its blocks are loaded by other
+// store-gateways, and reports when they had the time to load them.
+func (c *StoreGateway) PrepareDownscaleHandler(w http.ResponseWriter, req *http.Request) {
+	if c.checkPreparationAvailable(w) {
+		c.preparation.PrepareDownscaleHandler(w, req)
+	}
+}
+
+func (c *StoreGateway) checkPreparationAvailable(w http.ResponseWriter) bool {
+	if !c.gatewayCfg.ShardingEnabled {
+		http.Error(w, "Store gateway has no ring because sharding is disabled.", http.StatusNotFound)
+		return false
+	}
+	if c.State() != services.Running {
+		http.Error(w, "Store gateway is not running yet.", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
@@ -62,12 +62,16 @@ var (
 type RingConfig struct {
 	KVStore                         kv.Config     `yaml:"kvstore" doc:"description=The key-value store used to share the hash ring across multiple instances. This option needs be set both on the store-gateway and querier when running in microservices mode."`
 	HeartbeatPeriod                 time.Duration `yaml:"heartbeat_period"`
+	CASBatchPeriod                  time.Duration `yaml:"cas_batch_period"`
 	HeartbeatTimeout                time.Duration `yaml:"heartbeat_timeout"`
 	ReplicationFactor               int           `yaml:"replication_factor"`
 	TokensFilePath                  string        `yaml:"tokens_file_path"`
+	TokensGeneratorStrategy         string        `yaml:"tokens_generator_strategy"`
+	TokensStaticFile                string        `yaml:"tokens_static_file"`
 	ZoneAwarenessEnabled            bool          `yaml:"zone_awareness_enabled"`
 	KeepInstanceInTheRingOnShutdown bool          `yaml:"keep_instance_in_the_ring_on_shutdown"`
 	ZoneStableShuffleSharding       bool          `yaml:"zone_stable_shuffle_sharding" doc:"hidden"`
+	ZoneFailoverShuffleSharding     bool          `yaml:"zone_failover_shuffle_sharding"`
 
 	// Wait ring stability.
 	WaitStabilityMinDuration time.Duration `yaml:"wait_stability_min_duration"`
@@ -101,12 +105,16 @@ func (cfg *RingConfig) RegisterFlags(f *flag.FlagSet) {
 	// Ring flags
 	cfg.KVStore.RegisterFlagsWithPrefix(ringFlagsPrefix, "collectors/", f)
 	f.DurationVar(&cfg.HeartbeatPeriod, ringFlagsPrefix+"heartbeat-period", 15*time.Second, "Period at which to heartbeat to the ring. 0 = disabled.")
+	f.DurationVar(&cfg.CASBatchPeriod, ringFlagsPrefix+"cas-batch-period", 0, "EXPERIMENTAL: Period within which the ring updates of the store-gateway, including the heartbeats, are batched with the ring updates of the other components of the same process into a single KV store transaction. When enabled, the heartbeats are aligned to the heartbeat period, so that the heartbeats of the components with the same heartbeat period, or a multiple of it, are batched together. Supported by the consul, etcd and memberlist KV stores. 0 = disabled.")
 	f.DurationVar(&cfg.HeartbeatTimeout, ringFlagsPrefix+"heartbeat-timeout", time.Minute, "The heartbeat timeout after which store gateways are considered unhealthy within the ring. 0 = never (timeout disabled)."+sharedOptionWithQuerier)
 	f.IntVar(&cfg.ReplicationFactor, ringFlagsPrefix+"replication-factor", 3, "The replication factor to use when sharding blocks."+sharedOptionWithQuerier)
 	f.StringVar(&cfg.TokensFilePath, ringFlagsPrefix+"tokens-file-path", "", "File path where tokens are stored. If empty, tokens are not stored at shutdown and restored at startup.")
+	f.StringVar(&cfg.TokensGeneratorStrategy, ringFlagsPrefix+"tokens-generator-strategy", "random", ring.TokensGeneratorStrategyFlagDescription)
+	f.StringVar(&cfg.TokensStaticFile, ringFlagsPrefix+"tokens-static-file", "", "EXPERIMENTAL: Path of the YAML file mapping the instance IDs to their tokens, used by the static token generator strategy.")
 	f.BoolVar(&cfg.ZoneAwarenessEnabled, ringFlagsPrefix+"zone-awareness-enabled", false, "True to enable zone-awareness and replicate blocks across different availability zones.")
 	f.BoolVar(&cfg.KeepInstanceInTheRingOnShutdown, ringFlagsPrefix+"keep-instance-in-the-ring-on-shutdown", false, "True to keep the store gateway instance in the ring when it shuts down. The instance will then be auto-forgotten from the ring after 10*heartbeat_timeout.")
 	f.BoolVar(&cfg.ZoneStableShuffleSharding, ringFlagsPrefix+"zone-stable-shuffle-sharding", false, "If true, use zone stable shuffle sharding algorithm. Otherwise, use the default shuffle sharding algorithm.")
+	f.BoolVar(&cfg.ZoneFailoverShuffleSharding, ringFlagsPrefix+"zone-failover-shuffle-sharding", false, "EXPERIMENTAL: True to replace the store gateways of the shuffle shards in a failed zone, whose store gateways are all unhealthy, with store gateways of the healthy zones. Requires zone-awareness."+sharedOptionWithQuerier)
 
 	// Wait stability flags.
 	f.DurationVar(&cfg.WaitStabilityMinDuration, ringFlagsPrefix+"wait-stability-min-duration", time.Minute, "Minimum time to wait for ring stability at startup. 0 to disable.")
@@ -137,6 +145,7 @@ func (cfg *RingConfig) ToRingConfig() ring.Config {
 	rc.HeartbeatTimeout = cfg.HeartbeatTimeout
 	rc.ReplicationFactor = cfg.ReplicationFactor
 	rc.ZoneAwarenessEnabled = cfg.ZoneAwarenessEnabled
+	rc.ShuffleShardingZoneFailoverEnabled = cfg.ZoneFailoverShuffleSharding
 	rc.SubringCacheDisabled = true
 
 	return rc
@@ -155,8 +164,11 @@ func (cfg *RingConfig) ToLifecyclerConfig(logger log.Logger) (ring.BasicLifecycl
 		Addr:                            fmt.Sprintf("%s:%d", instanceAddr, instancePort),
 		Zone:                            cfg.InstanceZone,
 		HeartbeatPeriod:                 cfg.HeartbeatPeriod,
+		AlignHeartbeats:                 cfg.CASBatchPeriod > 0,
 		TokensObservePeriod:             0,
 		NumTokens:                       RingNumTokens,
+		TokensGeneratorStrategy:         cfg.TokensGeneratorStrategy,
+		TokensStaticFile:                cfg.TokensStaticFile,
 		KeepInstanceInTheRingOnShutdown: cfg.KeepInstanceInTheRingOnShutdown,
 		FinalSleep:                      cfg.FinalSleep,
 	}, nil
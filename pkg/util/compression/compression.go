@@ -0,0 +1,141 @@
+package compression
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+const (
+	// None stores the entries uncompressed.
+	None = "none"
+	// Snappy compresses the entries with snappy, which is fast but compresses less.
+	Snappy = "snappy"
+	// Zstd compresses the entries with zstd, which compresses more at the given level but is slower.
+	Zstd = "zstd"
+
+	// magic is the first byte of the compressed entries. It can't be the first byte of a protobuf message, since the
+	// wire type 7 is invalid.
+	magic byte = 0xff
+)
+
+var (
+	supportedCodecs = []string{None, Snappy, Zstd}
+	codecIDs        = map[string]byte{None: 0, Snappy: 1, Zstd: 2}
+
+	errUnsupportedCodec  = errors.New("unsupported compression codec")
+	errInvalidLevel      = errors.New("invalid compression level, the zstd level must be between 0 and 22")
+	errInvalidMinSize    = errors.New("invalid compression min size, must be greater than or equal to 0")
+	errNotCompressed     = errors.New("the entry isn't compressed by the cache compression")
+	errUnsupportedFormat = errors.New("the entry is compressed with an unsupported codec")
+
+	// The decoder is safe for the concurrent use of DecodeAll.
+	decoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+)
+
+// Config configures the compression of the entries of a cache.
+type Config struct {
+	Codec        string `yaml:"codec"`
+	Level        int    `yaml:"level"`
+	MinSizeBytes int    `yaml:"min_size_bytes"`
+}
+
+// RegisterFlagsWithPrefix registers the flags for the compression of the entries of the named cache.
+func (cfg *Config) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix, entries string) {
+	f.StringVar(&cfg.Codec, prefix+"codec", None, fmt.Sprintf("[Experimental] Compression codec of the %s stored in the cache. Supported values: %s.", entries, strings.Join(supportedCodecs, ", ")))
+	f.IntVar(&cfg.Level, prefix+"level", 0, fmt.Sprintf("[Experimental] Compression level of the %s with the zstd codec, from 1 (fastest) to 22 (smallest). 0 to use the default level.", entries))
+	f.IntVar(&cfg.MinSizeBytes, prefix+"min-size-bytes", 0, fmt.Sprintf("[Experimental] Minimum size in bytes of the %s to compress. The smaller ones are stored uncompressed, since compressing them saves little space for the CPU it costs.", entries))
+}
+
+// Validate the config.
+func (cfg *Config) Validate() error {
+	if _, ok := codecIDs[cfg.Codec]; !ok && cfg.Codec != "" {
+		return errors.WithMessagef(errUnsupportedCodec, "codec: %s", cfg.Codec)
+	}
+	if cfg.Level < 0 || cfg.Level > 22 {
+		return errInvalidLevel
+	}
+	if cfg.MinSizeBytes < 0 {
+		return errInvalidMinSize
+	}
+	return nil
+}
+
+// Enabled returns whether the entries are compressed.
+func (cfg *Config) Enabled() bool {
+	return cfg.Codec != "" && cfg.Codec != None
+}
+
+// Compressor compresses the entries of a cache. The compressed entries start with a header telling their codec, so
+// that they can be decompressed after the codec is changed.
+type Compressor struct {
+	codec   byte
+	minSize int
+	encoder *zstd.Encoder
+}
+
+// NewCompressor returns a compressor for the config, or nil if the compression is disabled.
+func NewCompressor(cfg Config) (*Compressor, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	c := &Compressor{codec: codecIDs[cfg.Codec], minSize: cfg.MinSizeBytes}
+	if cfg.Codec == Zstd {
+		level := zstd.SpeedDefault
+		if cfg.Level > 0 {
+			level = zstd.EncoderLevelFromZstd(cfg.Level)
+		}
+		encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(level))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create the zstd encoder")
+		}
+		c.encoder = encoder
+	}
+	return c, nil
+}
+
+// Compress returns the compressed entry, or the entry with the header of the uncompressed entries if it's smaller
+// than the min size.
+func (c *Compressor) Compress(entry []byte) []byte {
+	if len(entry) < c.minSize {
+		return append([]byte{magic, codecIDs[None]}, entry...)
+	}
+
+	header := []byte{magic, c.codec}
+	switch c.codec {
+	case codecIDs[Snappy]:
+		compressed := make([]byte, len(header)+snappy.MaxEncodedLen(len(entry)))
+		copy(compressed, header)
+		return compressed[:len(header)+len(snappy.Encode(compressed[len(header):], entry))]
+	case codecIDs[Zstd]:
+		return c.encoder.EncodeAll(entry, header)
+	default:
+		return append(header, entry...)
+	}
+}
+
+// Decompress returns the decompressed entry compressed by a Compressor, with any codec.
+func Decompress(entry []byte) ([]byte, error) {
+	if len(entry) < 2 || entry[0] != magic {
+		return nil, errNotCompressed
+	}
+
+	switch entry[1] {
+	case codecIDs[None]:
+		return entry[2:], nil
+	case codecIDs[Snappy]:
+		return snappy.Decode(nil, entry[2:])
+	case codecIDs[Zstd]:
+		return decoder.DecodeAll(entry[2:], nil)
+	default:
+		return nil, errUnsupportedFormat
+	}
+}
@@ -0,0 +1,70 @@
+package compression
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		cfg      Config
+		expected error
+	}{
+		"disabled":           {cfg: Config{}},
+		"zstd with level":    {cfg: Config{Codec: Zstd, Level: 19, MinSizeBytes: 1024}},
+		"unsupported codec":  {cfg: Config{Codec: "gzip"}, expected: errUnsupportedCodec},
+		"invalid level":      {cfg: Config{Codec: Zstd, Level: 23}, expected: errInvalidLevel},
+		"invalid min size":   {cfg: Config{Codec: Snappy, MinSizeBytes: -1}, expected: errInvalidMinSize},
+		"none with min size": {cfg: Config{Codec: None, MinSizeBytes: 1024}},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.ErrorIs(t, testData.cfg.Validate(), testData.expected)
+		})
+	}
+}
+
+func TestCompressor(t *testing.T) {
+	small := []byte("small entry")
+	large := bytes.Repeat([]byte("large entry "), 1000)
+
+	for _, codec := range []string{Snappy, Zstd} {
+		t.Run(codec, func(t *testing.T) {
+			c, err := NewCompressor(Config{Codec: codec, Level: 3, MinSizeBytes: 100})
+			require.NoError(t, err)
+
+			// The entries smaller than the min size aren't compressed.
+			compressed := c.Compress(small)
+			assert.Equal(t, append([]byte{magic, codecIDs[None]}, small...), compressed)
+			decompressed, err := Decompress(compressed)
+			require.NoError(t, err)
+			assert.Equal(t, small, decompressed)
+
+			compressed = c.Compress(large)
+			assert.Less(t, len(compressed), len(large)/10)
+			decompressed, err = Decompress(compressed)
+			require.NoError(t, err)
+			assert.Equal(t, large, decompressed)
+		})
+	}
+}
+
+func TestNewCompressor_ShouldReturnNilIfDisabled(t *testing.T) {
+	for _, codec := range []string{"", None} {
+		c, err := NewCompressor(Config{Codec: codec})
+		require.NoError(t, err)
+		assert.Nil(t, c)
+	}
+}
+
+func TestDecompress_ShouldFailOnTheEntriesNotCompressed(t *testing.T) {
+	_, err := Decompress([]byte("uncompressed"))
+	assert.ErrorIs(t, err, errNotCompressed)
+
+	_, err = Decompress([]byte{magic, 42, 1})
+	assert.ErrorIs(t, err, errUnsupportedFormat)
+}
@@ -0,0 +1,208 @@
+package flagext
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const minutesInDay = 24 * 60
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// TimeWindow is a daily time window in UTC, optionally restricted to a range of weekdays.
+// Its format is "[<weekday>[-<weekday>] ]<HH:MM>-<HH:MM>", for example "22:00-06:00" or
+// "Mon-Fri 20:00-24:00". A window ending before its start spans midnight, and the weekdays
+// refer to the day the window starts.
+type TimeWindow struct {
+	// Weekdays range, both included. Ignored if allDays is true.
+	firstDay, lastDay time.Weekday
+	allDays           bool
+
+	// Start (included) and end (excluded) minutes of the day.
+	start, end int
+
+	raw string
+}
+
+// ParseTimeWindow parses a TimeWindow from its string representation.
+func ParseTimeWindow(s string) (TimeWindow, error) {
+	w := TimeWindow{raw: s, allDays: true}
+
+	fields := strings.Fields(s)
+	switch len(fields) {
+	case 1:
+	case 2:
+		first, last, err := parseWeekdays(fields[0])
+		if err != nil {
+			return TimeWindow{}, fmt.Errorf("invalid time window %q: %w", s, err)
+		}
+		w.firstDay, w.lastDay, w.allDays = first, last, false
+	default:
+		return TimeWindow{}, fmt.Errorf("invalid time window %q", s)
+	}
+
+	start, end, found := strings.Cut(fields[len(fields)-1], "-")
+	if !found {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q: missing end time", s)
+	}
+
+	var err error
+	if w.start, err = parseMinuteOfDay(start); err != nil {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q: %w", s, err)
+	}
+	if w.end, err = parseMinuteOfDay(end); err != nil {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q: %w", s, err)
+	}
+	if w.start == w.end || w.start == minutesInDay {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q: empty window", s)
+	}
+
+	return w, nil
+}
+
+// Contains returns whether the input time is within the window.
+func (w TimeWindow) Contains(t time.Time) bool {
+	t = t.UTC()
+	minute := t.Hour()*60 + t.Minute()
+
+	if w.start < w.end {
+		return minute >= w.start && minute < w.end && w.containsDay(t.Weekday())
+	}
+
+	// The window spans midnight.
+	if minute >= w.start {
+		return w.containsDay(t.Weekday())
+	}
+	return minute < w.end && w.containsDay((t.Weekday()+6)%7)
+}
+
+func (w TimeWindow) containsDay(day time.Weekday) bool {
+	if w.allDays {
+		return true
+	}
+	if w.firstDay <= w.lastDay {
+		return day >= w.firstDay && day <= w.lastDay
+	}
+	// The weekdays range wraps around the end of the week.
+	return day >= w.firstDay || day <= w.lastDay
+}
+
+// String returns the window string representation.
+func (w TimeWindow) String() string {
+	return w.raw
+}
+
+func parseWeekdays(s string) (time.Weekday, time.Weekday, error) {
+	first, last, found := strings.Cut(s, "-")
+	if !found {
+		last = first
+	}
+
+	firstDay, ok := weekdays[strings.ToLower(first)]
+	if !ok {
+		return 0, 0, fmt.Errorf("unknown weekday %q", first)
+	}
+	lastDay, ok := weekdays[strings.ToLower(last)]
+	if !ok {
+		return 0, 0, fmt.Errorf("unknown weekday %q", last)
+	}
+	return firstDay, lastDay, nil
+}
+
+func parseMinuteOfDay(s string) (int, error) {
+	var hours, minutes int
+	if n, err := fmt.Sscanf(s, "%d:%d", &hours, &minutes); err != nil || n != 2 || len(s) != 5 {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	if hours < 0 || minutes < 0 || minutes > 59 || hours*60+minutes > minutesInDay {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return hours*60 + minutes, nil
+}
+
+// TimeWindows is a list of TimeWindow parsed from a comma-separated string.
+// It implements flag.Value, yaml and json Marshalers.
+type TimeWindows []TimeWindow
+
+// Contains returns whether the input time is within any of the windows.
+// An empty list of windows contains any time.
+func (v TimeWindows) Contains(t time.Time) bool {
+	if len(v) == 0 {
+		return true
+	}
+	for _, w := range v {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// String implements flag.Value
+func (v TimeWindows) String() string {
+	raw := make([]string, 0, len(v))
+	for _, w := range v {
+		raw = append(raw, w.String())
+	}
+	return strings.Join(raw, ",")
+}
+
+// Set implements flag.Value
+func (v *TimeWindows) Set(s string) error {
+	var windows TimeWindows
+	for _, raw := range strings.Split(s, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+
+		w, err := ParseTimeWindow(raw)
+		if err != nil {
+			return err
+		}
+		windows = append(windows, w)
+	}
+
+	*v = windows
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (v *TimeWindows) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	return v.Set(s)
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (v TimeWindows) MarshalYAML() (interface{}, error) {
+	return v.String(), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (v *TimeWindows) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	return v.Set(s)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (v TimeWindows) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.String())
+}
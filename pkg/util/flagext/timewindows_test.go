@@ -0,0 +1,136 @@
+package flagext
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestTimeWindows_Contains(t *testing.T) {
+	// 2024-01-01 is a Monday.
+	at := func(day int, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	for name, tc := range map[string]struct {
+		windows  string
+		expected map[time.Time]bool
+	}{
+		"no windows": {
+			windows:  "",
+			expected: map[time.Time]bool{at(1, 0, 0): true, at(3, 12, 30): true},
+		},
+		"daily window": {
+			windows: "02:00-06:00",
+			expected: map[time.Time]bool{
+				at(1, 1, 59): false,
+				at(1, 2, 0):  true,
+				at(2, 5, 59): true,
+				at(3, 6, 0):  false,
+			},
+		},
+		"daily window spanning midnight": {
+			windows: "22:00-02:00",
+			expected: map[time.Time]bool{
+				at(1, 21, 59): false,
+				at(1, 22, 0):  true,
+				at(2, 1, 59):  true,
+				at(2, 2, 0):   false,
+			},
+		},
+		"window ending at midnight": {
+			windows: "Sat 20:00-24:00",
+			expected: map[time.Time]bool{
+				at(6, 19, 59): false,
+				at(6, 23, 59): true,
+				at(7, 0, 0):   false,
+			},
+		},
+		"weekdays window": {
+			windows: "Mon-Fri 22:00-06:00",
+			expected: map[time.Time]bool{
+				at(1, 22, 0): true,  // Monday night.
+				at(6, 5, 0):  true,  // Saturday morning, window started on Friday.
+				at(6, 22, 0): false, // Saturday night.
+				at(8, 5, 0):  false, // Monday morning, window started on Sunday.
+			},
+		},
+		"weekdays range wrapping around the week": {
+			windows: "Sat-Sun 00:00-24:00",
+			expected: map[time.Time]bool{
+				at(5, 12, 0): false,
+				at(6, 12, 0): true,
+				at(7, 12, 0): true,
+				at(8, 12, 0): false,
+			},
+		},
+		"multiple windows": {
+			windows: "01:00-02:00, Wed 12:00-13:00",
+			expected: map[time.Time]bool{
+				at(1, 1, 30):  true,
+				at(1, 12, 30): false,
+				at(3, 12, 30): true,
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var windows TimeWindows
+			require.NoError(t, windows.Set(tc.windows))
+
+			for ts, expected := range tc.expected {
+				assert.Equal(t, expected, windows.Contains(ts), ts.String())
+			}
+		})
+	}
+}
+
+func TestTimeWindows_Set_ShouldReturnErrorOnInvalidWindows(t *testing.T) {
+	for _, input := range []string{
+		"02:00",
+		"2:00-06:00",
+		"02:00-25:00",
+		"02:60-06:00",
+		"02:00-02:00",
+		"24:00-02:00",
+		"Foo 02:00-06:00",
+		"Mon-Foo 02:00-06:00",
+		"Mon Tue 02:00-06:00",
+	} {
+		var windows TimeWindows
+		assert.Error(t, windows.Set(input), input)
+	}
+}
+
+func TestTimeWindows_Marshal(t *testing.T) {
+	type TestStruct struct {
+		Windows TimeWindows `yaml:"windows" json:"windows"`
+	}
+
+	var testStruct TestStruct
+	require.NoError(t, testStruct.Windows.Set("Mon-Fri 22:00-06:00,12:00-13:00"))
+
+	// YAML.
+	expected := []byte(`windows: Mon-Fri 22:00-06:00,12:00-13:00
+`)
+
+	actual, err := yaml.Marshal(testStruct)
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	var fromYAML TestStruct
+	require.NoError(t, yaml.Unmarshal(expected, &fromYAML))
+	assert.Equal(t, testStruct, fromYAML)
+
+	// JSON.
+	actual, err = json.Marshal(testStruct)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"windows":"Mon-Fri 22:00-06:00,12:00-13:00"}`, string(actual))
+
+	var fromJSON TestStruct
+	require.NoError(t, json.Unmarshal(actual, &fromJSON))
+	assert.Equal(t, testStruct, fromJSON)
+}
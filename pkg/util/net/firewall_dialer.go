@@ -3,6 +3,7 @@ package net
 import (
 	"context"
 	"net"
+	"strings"
 	"syscall"
 
 	"github.com/pkg/errors"
@@ -12,10 +13,17 @@ import (
 
 var errBlockedAddress = errors.New("blocked address")
 var errInvalidAddress = errors.New("invalid address")
+var errBlockedHost = errors.New("blocked host")
 
 type FirewallDialerConfigProvider interface {
 	BlockCIDRNetworks() []flagext.CIDR
 	BlockPrivateAddresses() bool
+
+	// AllowedHosts returns the hosts allowed to be dialed, all hosts being allowed if empty.
+	AllowedHosts() []string
+
+	// BlockedHosts returns the hosts blocked from being dialed.
+	BlockedHosts() []string
 }
 
 // FirewallDialer is a net dialer which integrates a firewall to block specific addresses.
@@ -31,9 +39,50 @@ func NewFirewallDialer(cfgProvider FirewallDialerConfigProvider) *FirewallDialer
 }
 
 func (d *FirewallDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	// The hosts are checked before the DNS resolution, on the address requested to be dialed.
+	if allowed, blocked := d.cfgProvider.AllowedHosts(), d.cfgProvider.BlockedHosts(); len(allowed) > 0 || len(blocked) > 0 {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, errInvalidAddress
+		}
+		if !IsHostAllowed(host, allowed, blocked) {
+			return nil, errBlockedHost
+		}
+	}
+
 	return d.parent.DialContext(ctx, network, address)
 }
 
+// IsHostAllowed returns whether the host is allowed by the allowed and the blocked hosts. A host matches an
+// allowed or blocked host if it's equal to it or one of its subdomains, and is allowed if it matches one of
+// the allowed hosts, if any, and none of the blocked hosts.
+func IsHostAllowed(host string, allowed, blocked []string) bool {
+	for _, b := range blocked {
+		if hostMatches(host, b) {
+			return false
+		}
+	}
+
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if hostMatches(host, a) {
+			return true
+		}
+	}
+	return false
+}
+
+func hostMatches(host, pattern string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	pattern = strings.TrimSuffix(strings.ToLower(pattern), ".")
+	if pattern == "" {
+		return false
+	}
+	return host == pattern || strings.HasSuffix(host, "."+pattern)
+}
+
 func (d *FirewallDialer) control(_, address string, _ syscall.RawConn) error {
 	blockPrivateAddresses := d.cfgProvider.BlockPrivateAddresses()
 	blockCIDRNetworks := d.cfgProvider.BlockCIDRNetworks()
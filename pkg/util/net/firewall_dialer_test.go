@@ -2,6 +2,7 @@ package net
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"strings"
@@ -134,9 +135,76 @@ func TestIsPrivate(t *testing.T) {
 	}
 }
 
+func TestFirewallDialer_Hosts(t *testing.T) {
+	d := NewFirewallDialer(firewallCfgProvider{
+		allowedHosts: []string{"example.com", "127.0.0.1"},
+		blockedHosts: []string{"internal.example.com"},
+	})
+
+	for address, expectBlocked := range map[string]bool{
+		"example.com:80":             false,
+		"hooks.example.com:80":       false,
+		"internal.example.com:80":    true,
+		"db.internal.example.com:80": true,
+		"notexample.com:80":          true,
+		"localhost:80":               true,
+		"127.0.0.1:80":               false,
+	} {
+		t.Run(fmt.Sprintf("address: %s", address), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			conn, err := d.DialContext(ctx, "tcp", address)
+			if conn != nil {
+				require.NoError(t, conn.Close())
+			}
+
+			if expectBlocked {
+				assert.ErrorIs(t, err, errBlockedHost)
+			} else {
+				// We're fine either if succeeded or triggered a different error (eg. connection refused).
+				assert.False(t, errors.Is(err, errBlockedHost))
+			}
+		})
+	}
+}
+
+func TestIsHostAllowed(t *testing.T) {
+	tests := map[string]struct {
+		host     string
+		allowed  []string
+		blocked  []string
+		expected bool
+	}{
+		"no allowed nor blocked hosts":        {host: "example.com", expected: true},
+		"allowed host":                        {host: "example.com", allowed: []string{"example.com"}, expected: true},
+		"subdomain of an allowed host":        {host: "a.b.example.com", allowed: []string{"example.com"}, expected: true},
+		"host not allowed":                    {host: "example.org", allowed: []string{"example.com"}, expected: false},
+		"host with the suffix of an allowed":  {host: "badexample.com", allowed: []string{"example.com"}, expected: false},
+		"blocked host":                        {host: "Example.COM.", blocked: []string{"example.com"}, expected: false},
+		"blocked subdomain of an allowed one": {host: "internal.example.com", allowed: []string{"example.com"}, blocked: []string{"internal.example.com"}, expected: false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, IsHostAllowed(test.host, test.allowed, test.blocked))
+		})
+	}
+}
+
 type firewallCfgProvider struct {
 	blockCIDRNetworks     []flagext.CIDR
 	blockPrivateAddresses bool
+	allowedHosts          []string
+	blockedHosts          []string
+}
+
+func (p firewallCfgProvider) AllowedHosts() []string {
+	return p.allowedHosts
+}
+
+func (p firewallCfgProvider) BlockedHosts() []string {
+	return p.blockedHosts
 }
 
 func (p firewallCfgProvider) BlockCIDRNetworks() []flagext.CIDR {
@@ -5,14 +5,17 @@ import (
 	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
 	"math"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/cespare/xxhash/v2"
+	commoncfg "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/prometheus/prometheus/promql/parser"
 	"golang.org/x/time/rate"
 
 	"github.com/cortexproject/cortex/pkg/util/flagext"
@@ -21,11 +24,15 @@ import (
 var errMaxGlobalSeriesPerUserValidation = errors.New("The ingester.max-global-series-per-user limit is unsupported if distributor.shard-by-all-labels is disabled")
 var errDuplicateQueryPriorities = errors.New("duplicate entry of priorities found. Make sure they are all unique, including the default priority")
 var errCompilingQueryPriorityRegex = errors.New("error compiling query priority regex")
+var errInvalidCompactorDeduplicationFunc = fmt.Errorf("invalid compactor deduplication func, supported values are: %s, %s", CompactorDeduplicationFuncOneToOne, CompactorDeduplicationFuncPenalty)
 
 // Supported values for enum limits
 const (
 	LocalIngestionRateStrategy  = "local"
 	GlobalIngestionRateStrategy = "global"
+
+	CompactorDeduplicationFuncOneToOne = "one-to-one"
+	CompactorDeduplicationFuncPenalty  = "penalty"
 )
 
 // AccessDeniedError are errors that do not comply with the limits specified.
@@ -73,6 +80,43 @@ type TimeWindow struct {
 	End   model.Duration `yaml:"end" json:"end" doc:"nocli|description=End of the data select time window (including range selectors, modifiers and lookback delta) that the query should be within. If set to 0, it won't be checked.|default=0"`
 }
 
+// RollupRule is a recording-rule-like expression evaluated by the compactor over the
+// historical blocks of a tenant.
+type RollupRule struct {
+	Record                  string         `yaml:"record" json:"record" doc:"nocli|description=Name of the series generated by the rule."`
+	Expr                    string         `yaml:"expr" json:"expr" doc:"nocli|description=PromQL expression to evaluate over each fully compacted block. The series returned by the expression are stored with the record name."`
+	Interval                model.Duration `yaml:"interval" json:"interval" doc:"nocli|description=Interval between the samples generated by the rule. Must be greater than 0.|default=0s"`
+	DeleteSourceSeriesAfter model.Duration `yaml:"delete_source_series_after" json:"delete_source_series_after" doc:"nocli|description=Delete the raw series selected by the expression from the blocks older than this period, once the rule has been evaluated over them. 0 to keep the raw series.|default=0s"`
+}
+
+// Validate returns an error if the rollup rule is invalid.
+func (r *RollupRule) Validate() error {
+	if !model.IsValidMetricName(model.LabelValue(r.Record)) {
+		return fmt.Errorf("invalid rollup rule record name %q", r.Record)
+	}
+	if _, err := parser.ParseExpr(r.Expr); err != nil {
+		return fmt.Errorf("invalid rollup rule %s expression: %w", r.Record, err)
+	}
+	if r.Interval <= 0 {
+		return fmt.Errorf("invalid rollup rule %s interval, must be greater than 0", r.Record)
+	}
+	return nil
+}
+
+// AlertmanagerReceiverTLSConfig is the TLS config of the Alertmanager receivers sending notifications to some hosts.
+type AlertmanagerReceiverTLSConfig struct {
+	Hosts     []string            `yaml:"hosts" json:"hosts" doc:"nocli|description=Hosts the TLS config applies to. A host also matches its subdomains."`
+	TLSConfig commoncfg.TLSConfig `yaml:"tls_config" json:"tls_config" doc:"nocli|description=TLS config of the receivers, with the CA bundle verifying the certificates of the hosts and the client certificate presented to them."`
+}
+
+// Validate returns an error if the receiver TLS config is invalid.
+func (c *AlertmanagerReceiverTLSConfig) Validate() error {
+	if len(c.Hosts) == 0 {
+		return errors.New("the alertmanager receiver TLS config requires at least one host")
+	}
+	return c.TLSConfig.Validate()
+}
+
 // Limits describe all the limits for users; can be used to describe global default
 // limits via flags, or per-user limits via yaml config.
 type Limits struct {
@@ -124,7 +168,9 @@ type Limits struct {
 	MaxQueryLength               model.Duration `yaml:"max_query_length" json:"max_query_length"`
 	MaxQueryParallelism          int            `yaml:"max_query_parallelism" json:"max_query_parallelism"`
 	MaxCacheFreshness            model.Duration `yaml:"max_cache_freshness" json:"max_cache_freshness"`
+	MaxCacheSizeBytes            int64          `yaml:"max_cache_size_bytes" json:"max_cache_size_bytes"`
 	MaxQueriersPerTenant         float64        `yaml:"max_queriers_per_tenant" json:"max_queriers_per_tenant"`
+	ReservedQueriersPerTenant    float64        `yaml:"reserved_queriers_per_tenant" json:"reserved_queriers_per_tenant"`
 	QueryVerticalShardSize       int            `yaml:"query_vertical_shard_size" json:"query_vertical_shard_size" doc:"hidden"`
 
 	// Query Frontend / Scheduler enforced limits.
@@ -134,28 +180,55 @@ type Limits struct {
 	queryPriorityCompiledRegex map[string]*regexp.Regexp
 
 	// Ruler defaults and limits.
-	RulerEvaluationDelay        model.Duration `yaml:"ruler_evaluation_delay_duration" json:"ruler_evaluation_delay_duration"`
-	RulerTenantShardSize        int            `yaml:"ruler_tenant_shard_size" json:"ruler_tenant_shard_size"`
-	RulerMaxRulesPerRuleGroup   int            `yaml:"ruler_max_rules_per_rule_group" json:"ruler_max_rules_per_rule_group"`
-	RulerMaxRuleGroupsPerTenant int            `yaml:"ruler_max_rule_groups_per_tenant" json:"ruler_max_rule_groups_per_tenant"`
+	RulerEvaluationDelay            model.Duration         `yaml:"ruler_evaluation_delay_duration" json:"ruler_evaluation_delay_duration"`
+	RulerTenantShardSize            int                    `yaml:"ruler_tenant_shard_size" json:"ruler_tenant_shard_size"`
+	RulerMaxRulesPerRuleGroup       int                    `yaml:"ruler_max_rules_per_rule_group" json:"ruler_max_rules_per_rule_group"`
+	RulerMaxRuleGroupsPerTenant     int                    `yaml:"ruler_max_rule_groups_per_tenant" json:"ruler_max_rule_groups_per_tenant"`
+	RulerAllowedSourceTenants       flagext.StringSliceCSV `yaml:"ruler_allowed_source_tenants" json:"ruler_allowed_source_tenants"`
+	RulerAllowedDestinationTenants  flagext.StringSliceCSV `yaml:"ruler_allowed_destination_tenants" json:"ruler_allowed_destination_tenants"`
+	RulerAllowedAlertmanagerTenants flagext.StringSliceCSV `yaml:"ruler_allowed_alertmanager_tenants" json:"ruler_allowed_alertmanager_tenants"`
+	RulerAllowedAlertmanagerURLs    flagext.StringSliceCSV `yaml:"ruler_allowed_alertmanager_urls" json:"ruler_allowed_alertmanager_urls"`
+	RulerMaxConcurrentEvaluations   int                    `yaml:"ruler_max_concurrent_evaluations" json:"ruler_max_concurrent_evaluations"`
+	RulerEvaluationQueryTimeout     model.Duration         `yaml:"ruler_evaluation_query_timeout" json:"ruler_evaluation_query_timeout"`
+	RulerMaxQueryBytesPerMinute     int64                  `yaml:"ruler_max_query_bytes_per_minute" json:"ruler_max_query_bytes_per_minute"`
+	RulerMaxSeriesPerRule           int                    `yaml:"ruler_max_series_per_rule" json:"ruler_max_series_per_rule"`
+	RulerMaxAlertsPerRule           int                    `yaml:"ruler_max_alerts_per_rule" json:"ruler_max_alerts_per_rule"`
+	RulerMaxSeriesPerRuleGroup      int                    `yaml:"ruler_max_series_per_rule_group" json:"ruler_max_series_per_rule_group"`
 
 	// Store-gateway.
 	StoreGatewayTenantShardSize  float64 `yaml:"store_gateway_tenant_shard_size" json:"store_gateway_tenant_shard_size"`
 	MaxDownloadedBytesPerRequest int     `yaml:"max_downloaded_bytes_per_request" json:"max_downloaded_bytes_per_request"`
 
 	// Compactor.
-	CompactorBlocksRetentionPeriod model.Duration `yaml:"compactor_blocks_retention_period" json:"compactor_blocks_retention_period"`
-	CompactorTenantShardSize       int            `yaml:"compactor_tenant_shard_size" json:"compactor_tenant_shard_size"`
+	CompactorBlocksRetentionPeriod      model.Duration         `yaml:"compactor_blocks_retention_period" json:"compactor_blocks_retention_period"`
+	CompactorBlocksRetentionPeriod5m    model.Duration         `yaml:"compactor_blocks_retention_period_5m" json:"compactor_blocks_retention_period_5m"`
+	CompactorBlocksRetentionPeriod1h    model.Duration         `yaml:"compactor_blocks_retention_period_1h" json:"compactor_blocks_retention_period_1h"`
+	CompactorTenantShardSize            int                    `yaml:"compactor_tenant_shard_size" json:"compactor_tenant_shard_size"`
+	CompactorDownsamplingEnabled        bool                   `yaml:"compactor_downsampling_enabled" json:"compactor_downsampling_enabled"`
+	CompactorTenantCompactionWindows    flagext.TimeWindows    `yaml:"compactor_tenant_compaction_windows" json:"compactor_tenant_compaction_windows"`
+	CompactorTenantPriority             int                    `yaml:"compactor_tenant_priority" json:"compactor_tenant_priority"`
+	CompactorDeduplicationReplicaLabels flagext.StringSliceCSV `yaml:"compactor_deduplication_replica_labels" json:"compactor_deduplication_replica_labels"`
+	CompactorDeduplicationFunc          string                 `yaml:"compactor_deduplication_func" json:"compactor_deduplication_func"`
+	CompactorRollupRules                []RollupRule           `yaml:"compactor_rollup_rules,omitempty" json:"compactor_rollup_rules,omitempty" doc:"nocli|description=[Experimental] List of rollup rules evaluated by the compactor over the fully compacted blocks. The generated series are stored in separate rollup blocks, and the raw series used by a rule can optionally be deleted after a grace period."`
+	CompactorRelabelConfigs             []*relabel.Config      `yaml:"compactor_relabel_configs,omitempty" json:"compactor_relabel_configs,omitempty" doc:"nocli|description=[Experimental] List of relabel configurations applied by the compactor to the series of the fully compacted blocks, which are rewritten accordingly. Series ending up with the same labels are merged, summing the samples with the same timestamp. It allows to retroactively drop or rewrite labels of historical data."`
+
+	// Purger.
+	DeleteRequestCancelPeriod model.Duration `yaml:"delete_request_cancel_period" json:"delete_request_cancel_period"`
 
 	// This config doesn't have a CLI flag registered here because they're registered in
 	// their own original config struct.
 	S3SSEType                 string `yaml:"s3_sse_type" json:"s3_sse_type" doc:"nocli|description=S3 server-side encryption type. Required to enable server-side encryption overrides for a specific tenant. If not set, the default S3 client settings are used."`
 	S3SSEKMSKeyID             string `yaml:"s3_sse_kms_key_id" json:"s3_sse_kms_key_id" doc:"nocli|description=S3 server-side encryption KMS Key ID. Ignored if the SSE type override is not set."`
 	S3SSEKMSEncryptionContext string `yaml:"s3_sse_kms_encryption_context" json:"s3_sse_kms_encryption_context" doc:"nocli|description=S3 server-side encryption KMS encryption context. If unset and the key ID override is set, the encryption context will not be provided to S3. Ignored if the SSE type override is not set."`
+	GCSKMSKeyName             string `yaml:"gcs_kms_key_name" json:"gcs_kms_key_name" doc:"nocli|description=Name of the Cloud KMS key used to encrypt the objects of the tenant written to GCS. If not set, the default GCS client settings are used."`
 
 	// Alertmanager.
-	AlertmanagerReceiversBlockCIDRNetworks     flagext.CIDRSliceCSV `yaml:"alertmanager_receivers_firewall_block_cidr_networks" json:"alertmanager_receivers_firewall_block_cidr_networks"`
-	AlertmanagerReceiversBlockPrivateAddresses bool                 `yaml:"alertmanager_receivers_firewall_block_private_addresses" json:"alertmanager_receivers_firewall_block_private_addresses"`
+	AlertmanagerReceiversBlockCIDRNetworks     flagext.CIDRSliceCSV   `yaml:"alertmanager_receivers_firewall_block_cidr_networks" json:"alertmanager_receivers_firewall_block_cidr_networks"`
+	AlertmanagerReceiversBlockPrivateAddresses bool                   `yaml:"alertmanager_receivers_firewall_block_private_addresses" json:"alertmanager_receivers_firewall_block_private_addresses"`
+	AlertmanagerReceiversAllowedHosts          flagext.StringSliceCSV `yaml:"alertmanager_receivers_firewall_allowed_hosts" json:"alertmanager_receivers_firewall_allowed_hosts"`
+	AlertmanagerReceiversBlockedHosts          flagext.StringSliceCSV `yaml:"alertmanager_receivers_firewall_blocked_hosts" json:"alertmanager_receivers_firewall_blocked_hosts"`
+
+	AlertmanagerReceiversTLSConfigs []AlertmanagerReceiverTLSConfig `yaml:"alertmanager_receivers_tls_configs,omitempty" json:"alertmanager_receivers_tls_configs,omitempty" doc:"nocli|description=[Experimental] List of TLS configs of the HTTP receivers sending notifications to the given hosts, for example to present a client certificate and verify the certificates with a custom CA bundle. A TLS config is applied to the receivers without their own CA or client certificate, and can reference files, unlike the Alertmanager configs of the tenants."`
 
 	NotificationRateLimit               float64                  `yaml:"alertmanager_notification_rate_limit" json:"alertmanager_notification_rate_limit"`
 	NotificationRateLimitPerIntegration NotificationRateLimitMap `yaml:"alertmanager_notification_rate_limit_per_integration" json:"alertmanager_notification_rate_limit_per_integration"`
@@ -166,6 +239,12 @@ type Limits struct {
 	AlertmanagerMaxDispatcherAggregationGroups int                `yaml:"alertmanager_max_dispatcher_aggregation_groups" json:"alertmanager_max_dispatcher_aggregation_groups"`
 	AlertmanagerMaxAlertsCount                 int                `yaml:"alertmanager_max_alerts_count" json:"alertmanager_max_alerts_count"`
 	AlertmanagerMaxAlertsSizeBytes             int                `yaml:"alertmanager_max_alerts_size_bytes" json:"alertmanager_max_alerts_size_bytes"`
+	AlertmanagerEvictResolvedAlerts            bool               `yaml:"alertmanager_evict_resolved_alerts" json:"alertmanager_evict_resolved_alerts"`
+	AlertmanagerMaxSilencesCount               int                `yaml:"alertmanager_max_silences_count" json:"alertmanager_max_silences_count"`
+	AlertmanagerMaxTotalSilences               int                `yaml:"alertmanager_max_total_silences" json:"alertmanager_max_total_silences"`
+	AlertmanagerExpiredSilencesMaxAge          model.Duration     `yaml:"alertmanager_expired_silences_max_age" json:"alertmanager_expired_silences_max_age"`
+	AlertmanagerNotificationLogMaxSizeBytes    int                `yaml:"alertmanager_notification_log_max_size_bytes" json:"alertmanager_notification_log_max_size_bytes"`
+	AlertmanagerFallbackConfigName             string             `yaml:"alertmanager_fallback_config_name" json:"alertmanager_fallback_config_name"`
 	DisabledRuleGroups                         DisabledRuleGroups `yaml:"disabled_rule_groups" json:"disabled_rule_groups" doc:"nocli|description=list of rule groups to disable"`
 }
 
@@ -214,7 +293,9 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.MaxQueryParallelism, "querier.max-query-parallelism", 14, "Maximum number of split queries will be scheduled in parallel by the frontend.")
 	_ = l.MaxCacheFreshness.Set("1m")
 	f.Var(&l.MaxCacheFreshness, "frontend.max-cache-freshness", "Most recent allowed cacheable result per-tenant, to prevent caching very recent results that might still be in flux.")
+	f.Int64Var(&l.MaxCacheSizeBytes, "frontend.max-cache-size-bytes", 0, "[Experimental] Maximum size in bytes of the entries of the tenant in the in-memory results cache. When exceeded, the least recently used entries of the tenant are evicted, instead of the entries of the other tenants. 0 to disable.")
 	f.Float64Var(&l.MaxQueriersPerTenant, "frontend.max-queriers-per-tenant", 0, "Maximum number of queriers that can handle requests for a single tenant. If set to 0 or value higher than number of available queriers, *all* queriers will handle requests for the tenant. If the value is < 1, it will be treated as a percentage and the gets a percentage of the total queriers. Each frontend (or query-scheduler, if used) will select the same set of queriers for the same tenant (given that all queriers are connected to all frontends / query-schedulers). This option only works with queriers connecting to the query-frontend / query-scheduler, not when using downstream URL.")
+	f.Float64Var(&l.ReservedQueriersPerTenant, "frontend.reserved-queriers-per-tenant", 0, "[Experimental] Number of queriers reserved for a single tenant while it has pending requests. Reserved queriers are picked from the tenant's queriers and, once they finish their current query, only handle requests from tenants which reserved them. If the value is < 1, it will be treated as a percentage of the total queriers. The reservation is ignored if it would include all available queriers, and at most half of the queriers are reserved by all the tenants together. 0 to disable.")
 	f.IntVar(&l.QueryVerticalShardSize, "frontend.query-vertical-shard-size", 0, "[Experimental] Number of shards to use when distributing shardable PromQL queries.")
 	f.BoolVar(&l.QueryPriority.Enabled, "frontend.query-priority.enabled", false, "Whether queries are assigned with priorities.")
 	f.Int64Var(&l.QueryPriority.DefaultPriority, "frontend.query-priority.default-priority", 0, "Priority assigned to all queries by default. Must be a unique value. Use this as a baseline to make certain queries higher/lower priority.")
@@ -225,10 +306,31 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.RulerTenantShardSize, "ruler.tenant-shard-size", 0, "The default tenant's shard size when the shuffle-sharding strategy is used by ruler. When this setting is specified in the per-tenant overrides, a value of 0 disables shuffle sharding for the tenant.")
 	f.IntVar(&l.RulerMaxRulesPerRuleGroup, "ruler.max-rules-per-rule-group", 0, "Maximum number of rules per rule group per-tenant. 0 to disable.")
 	f.IntVar(&l.RulerMaxRuleGroupsPerTenant, "ruler.max-rule-groups-per-tenant", 0, "Maximum number of rule groups per-tenant. 0 to disable.")
+	f.Var(&l.RulerAllowedSourceTenants, "ruler.allowed-source-tenants", "[Experimental] Comma separated list of tenants whose data can be queried by the federated rule groups of the tenant, listed in the source_tenants of the rule groups. The federated rule groups require the tenant federation to be enabled. If empty, the tenant can't have federated rule groups.")
+	f.Var(&l.RulerAllowedDestinationTenants, "ruler.allowed-destination-tenants", "[Experimental] Comma separated list of tenants the rule groups of the tenant can write their series to, set in the destination_tenant of the rule groups. If empty, the rule groups of the tenant can only write to the tenant itself.")
+	f.Var(&l.RulerAllowedAlertmanagerTenants, "ruler.allowed-alertmanager-tenants", "[Experimental] Comma separated list of tenants whose alertmanager can also receive the alerts of the rule groups of the tenant, listed in the alertmanager_tenants of the rule groups. If empty, the alerts of the tenant are only sent to its own alertmanager.")
+	f.Var(&l.RulerAllowedAlertmanagerURLs, "ruler.allowed-alertmanager-urls", "[Experimental] Comma separated list of alertmanager URLs which can also receive the alerts of the rule groups of the tenant, listed in the alertmanager_urls of the rule groups. If empty, the alerts of the tenant are only sent to its own alertmanager.")
+	f.IntVar(&l.RulerMaxConcurrentEvaluations, "ruler.max-concurrent-evaluations-per-tenant", 0, "[Experimental] Maximum number of rule queries of the tenant evaluated concurrently by each ruler. The rule queries exceeding the limit wait for the running ones to complete. 0 to disable.")
+	f.Var(&l.RulerEvaluationQueryTimeout, "ruler.evaluation-query-timeout", "[Experimental] Timeout of each rule query of the tenant. 0 to disable.")
+	f.Int64Var(&l.RulerMaxQueryBytesPerMinute, "ruler.max-query-bytes-per-minute", 0, "[Experimental] Maximum combined size of the data fetched by the rule queries of the tenant each minute, in each ruler. Once the limit is reached, the rule queries of the tenant fail until the next minute. Only enforced when the rule queries are evaluated by the querier embedded in the ruler. 0 to disable.")
+	f.IntVar(&l.RulerMaxSeriesPerRule, "ruler.max-series-per-rule", 0, "[Experimental] Maximum number of series a recording rule of the tenant can write at each evaluation. The recording rules exceeding the limit fail, without writing any series. 0 to disable.")
+	f.IntVar(&l.RulerMaxAlertsPerRule, "ruler.max-alerts-per-rule", 0, "[Experimental] Maximum number of alerts an alerting rule of the tenant can have at each evaluation, counting the series returned by its expression. The alerting rules exceeding the limit fail, keeping their alerts unchanged. 0 to disable.")
+	f.IntVar(&l.RulerMaxSeriesPerRuleGroup, "ruler.max-series-per-rule-group", 0, "[Experimental] Maximum combined number of series written and alerts of the rules of a rule group of the tenant at each evaluation. The rule exceeding the limit, and the next rules of the rule group, fail in the evaluation. 0 to disable.")
 
 	f.Var(&l.CompactorBlocksRetentionPeriod, "compactor.blocks-retention-period", "Delete blocks containing samples older than the specified retention period. 0 to disable.")
+	f.Var(&l.CompactorBlocksRetentionPeriod5m, "compactor.blocks-retention-period-5m", "[Experimental] Delete 5m downsampled blocks containing samples older than the specified retention period. 0 to use -compactor.blocks-retention-period.")
+	f.Var(&l.CompactorBlocksRetentionPeriod1h, "compactor.blocks-retention-period-1h", "[Experimental] Delete 1h downsampled blocks containing samples older than the specified retention period. 0 to use -compactor.blocks-retention-period.")
+	f.BoolVar(&l.CompactorDownsamplingEnabled, "compactor.downsampling-enabled", false, "[Experimental] If enabled, the compactor downsamples blocks spanning at least 40h to 5m resolution, and 5m blocks spanning at least 10d to 1h resolution. Native histograms are downsampled to the average histogram of each resolution window. Range queries with a step of at least 5 times the resolution are served from downsampled blocks.")
+	f.Var(&l.CompactorTenantCompactionWindows, "compactor.tenant-compaction-windows", "[Experimental] Comma-separated list of time windows, in UTC, during which the compactor is allowed to start compacting the tenant's blocks. Each window has the format '[<weekday>[-<weekday>] ]<HH:MM>-<HH:MM>', e.g. 'Mon-Fri 22:00-06:00,Sat-Sun 00:00-24:00'. A window ending before its start spans midnight. Empty to allow compaction at any time.")
+	f.IntVar(&l.CompactorTenantPriority, "compactor.tenant-priority", 0, "[Experimental] Priority of the tenant's compaction. On each compaction run, tenants with a higher priority are compacted before tenants with a lower priority.")
+	f.Var(&l.CompactorDeduplicationReplicaLabels, "compactor.deduplication-replica-labels", "[Experimental] Comma-separated list of external labels identifying the replica which uploaded a block, eg. the replica label of HA Prometheus pairs. The compactor removes these labels from the blocks metadata, so that the blocks uploaded by different replicas for the same time range are vertically compacted together and their samples deduplicated.")
+	f.StringVar(&l.CompactorDeduplicationFunc, "compactor.deduplication-func", CompactorDeduplicationFuncOneToOne, fmt.Sprintf("[Experimental] Function used to deduplicate the samples of overlapping blocks during vertical compaction. Supported values are: %s, %s. The %s deduplication only merges the samples with the same timestamp. The %s deduplication picks the samples of one replica at a time, switching to another replica when there is a gap in the samples, and should be used with -compactor.deduplication-replica-labels for blocks uploaded by HA Prometheus pairs, whose samples have different timestamps.", CompactorDeduplicationFuncOneToOne, CompactorDeduplicationFuncPenalty, CompactorDeduplicationFuncOneToOne, CompactorDeduplicationFuncPenalty))
 	f.IntVar(&l.CompactorTenantShardSize, "compactor.tenant-shard-size", 0, "The default tenant's shard size when the shuffle-sharding strategy is used by the compactor. When this setting is specified in the per-tenant overrides, a value of 0 disables shuffle sharding for the tenant.")
 
+	// Purger.
+	_ = l.DeleteRequestCancelPeriod.Set("24h")
+	f.Var(&l.DeleteRequestCancelPeriod, "purger.delete-request-cancel-period", "[Experimental] Period during which a series deletion request can be cancelled. Once the period is over, the compactor permanently deletes the requested series from the blocks.")
+
 	// Store-gateway.
 	f.Float64Var(&l.StoreGatewayTenantShardSize, "store-gateway.tenant-shard-size", 0, "The default tenant's shard size when the shuffle-sharding strategy is used. Must be set when the store-gateway sharding is enabled with the shuffle-sharding strategy. When this setting is specified in the per-tenant overrides, a value of 0 disables shuffle sharding for the tenant. If the value is < 1 the shard size will be a percentage of the total store-gateways.")
 	f.IntVar(&l.MaxDownloadedBytesPerRequest, "store-gateway.max-downloaded-bytes-per-request", 0, "The maximum number of data bytes to download per gRPC request in Store Gateway, including Series/LabelNames/LabelValues requests. 0 to disable.")
@@ -236,6 +338,8 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	// Alertmanager.
 	f.Var(&l.AlertmanagerReceiversBlockCIDRNetworks, "alertmanager.receivers-firewall-block-cidr-networks", "Comma-separated list of network CIDRs to block in Alertmanager receiver integrations.")
 	f.BoolVar(&l.AlertmanagerReceiversBlockPrivateAddresses, "alertmanager.receivers-firewall-block-private-addresses", false, "True to block private and local addresses in Alertmanager receiver integrations. It blocks private addresses defined by  RFC 1918 (IPv4 addresses) and RFC 4193 (IPv6 addresses), as well as loopback, local unicast and local multicast addresses.")
+	f.Var(&l.AlertmanagerReceiversAllowedHosts, "alertmanager.receivers-firewall-allowed-hosts", "Comma-separated list of hosts allowed as destination of the Alertmanager receiver integrations. A host also allows its subdomains. If empty, all hosts are allowed. The hosts are checked when validating the Alertmanager configs and when sending the notifications.")
+	f.Var(&l.AlertmanagerReceiversBlockedHosts, "alertmanager.receivers-firewall-blocked-hosts", "Comma-separated list of hosts blocked as destination of the Alertmanager receiver integrations. A host also blocks its subdomains. The hosts are checked when validating the Alertmanager configs and when sending the notifications.")
 
 	f.Float64Var(&l.NotificationRateLimit, "alertmanager.notification-rate-limit", 0, "Per-user rate limit for sending notifications from Alertmanager in notifications/sec. 0 = rate limit disabled. Negative value = no notifications are allowed.")
 
@@ -249,6 +353,12 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.AlertmanagerMaxDispatcherAggregationGroups, "alertmanager.max-dispatcher-aggregation-groups", 0, "Maximum number of aggregation groups in Alertmanager's dispatcher that a tenant can have. Each active aggregation group uses single goroutine. When the limit is reached, dispatcher will not dispatch alerts that belong to additional aggregation groups, but existing groups will keep working properly. 0 = no limit.")
 	f.IntVar(&l.AlertmanagerMaxAlertsCount, "alertmanager.max-alerts-count", 0, "Maximum number of alerts that a single user can have. Inserting more alerts will fail with a log message and metric increment. 0 = no limit.")
 	f.IntVar(&l.AlertmanagerMaxAlertsSizeBytes, "alertmanager.max-alerts-size-bytes", 0, "Maximum total size of alerts that a single user can have, alert size is the sum of the bytes of its labels, annotations and generatorURL. Inserting more alerts will fail with a log message and metric increment. 0 = no limit.")
+	f.BoolVar(&l.AlertmanagerEvictResolvedAlerts, "alertmanager.evict-resolved-alerts", false, "[Experimental] Evict the resolved alerts of a tenant, oldest first, to make room for the new alerts when the -alertmanager.max-alerts-count or -alertmanager.max-alerts-size-bytes limit is reached. If disabled, the new alerts are rejected until the resolved alerts are garbage collected.")
+	f.IntVar(&l.AlertmanagerMaxSilencesCount, "alertmanager.max-silences-count", 0, "[Experimental] Maximum number of active and pending silences that a tenant can have. Creating more silences will fail. 0 = no limit.")
+	f.IntVar(&l.AlertmanagerMaxTotalSilences, "alertmanager.max-total-silences", 0, "[Experimental] Maximum number of silences, including the expired silences, that a tenant can have. The oldest expired silences are garbage collected when it's exceeded. 0 = no limit.")
+	f.Var(&l.AlertmanagerExpiredSilencesMaxAge, "alertmanager.expired-silences-max-age", "[Experimental] Maximum time the expired silences of a tenant are kept before being garbage collected, if less than -alertmanager.storage.retention. 0 = no limit.")
+	f.IntVar(&l.AlertmanagerNotificationLogMaxSizeBytes, "alertmanager.notification-log-max-size-bytes", 0, "[Experimental] Maximum size of the notification log of a tenant. Its oldest entries are evicted when it's exceeded, every -alertmanager.notification-log.compaction-interval, and their aggregation groups are notified again at their next flush. 0 = no limit.")
+	f.StringVar(&l.AlertmanagerFallbackConfigName, "alertmanager.fallback-config-name", "", "[Experimental] Name of the fallback config of the -alertmanager.configs.fallback-directory used by the Alertmanager of a tenant without its own configuration, for example the default receivers of its organization. If empty or not found, the -alertmanager.configs.fallback config is used.")
 }
 
 // Validate the limits config and returns an error if the validation
@@ -260,6 +370,10 @@ func (l *Limits) Validate(shardByAllLabels bool) error {
 		return errMaxGlobalSeriesPerUserValidation
 	}
 
+	if err := l.validateCompactorDeduplicationFunc(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -284,6 +398,18 @@ func (l *Limits) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return err
 	}
 
+	if err := l.validateCompactorRollupRules(); err != nil {
+		return err
+	}
+
+	if err := l.validateAlertmanagerReceiversTLSConfigs(); err != nil {
+		return err
+	}
+
+	if err := l.validateCompactorDeduplicationFunc(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -310,6 +436,52 @@ func (l *Limits) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
+	if err := l.validateCompactorRollupRules(); err != nil {
+		return err
+	}
+
+	if err := l.validateAlertmanagerReceiversTLSConfigs(); err != nil {
+		return err
+	}
+
+	if err := l.validateCompactorDeduplicationFunc(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (l *Limits) validateCompactorDeduplicationFunc() error {
+	switch l.CompactorDeduplicationFunc {
+	case "", CompactorDeduplicationFuncOneToOne, CompactorDeduplicationFuncPenalty:
+		return nil
+	default:
+		return errInvalidCompactorDeduplicationFunc
+	}
+}
+
+func (l *Limits) validateCompactorRollupRules() error {
+	records := map[string]struct{}{}
+	for i := range l.CompactorRollupRules {
+		if err := l.CompactorRollupRules[i].Validate(); err != nil {
+			return err
+		}
+
+		// Rules with the same record could generate series with the same labels.
+		if _, ok := records[l.CompactorRollupRules[i].Record]; ok {
+			return fmt.Errorf("duplicate rollup rule record name %q", l.CompactorRollupRules[i].Record)
+		}
+		records[l.CompactorRollupRules[i].Record] = struct{}{}
+	}
+	return nil
+}
+
+func (l *Limits) validateAlertmanagerReceiversTLSConfigs() error {
+	for i := range l.AlertmanagerReceiversTLSConfigs {
+		if err := l.AlertmanagerReceiversTLSConfigs[i].Validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -573,11 +745,21 @@ func (o *Overrides) MaxCacheFreshness(userID string) time.Duration {
 	return time.Duration(o.GetOverridesForUser(userID).MaxCacheFreshness)
 }
 
+// MaxCacheSizeBytes returns the maximum size in bytes of the entries of the tenant in the in-memory results cache.
+func (o *Overrides) MaxCacheSizeBytes(userID string) int64 {
+	return o.GetOverridesForUser(userID).MaxCacheSizeBytes
+}
+
 // MaxQueriersPerUser returns the maximum number of queriers that can handle requests for this user.
 func (o *Overrides) MaxQueriersPerUser(userID string) float64 {
 	return o.GetOverridesForUser(userID).MaxQueriersPerTenant
 }
 
+// ReservedQueriersPerTenant returns the number of queriers reserved for the tenant, or 0 if disabled.
+func (o *Overrides) ReservedQueriersPerTenant(userID string) float64 {
+	return o.GetOverridesForUser(userID).ReservedQueriersPerTenant
+}
+
 // QueryVerticalShardSize returns the number of shards to use when distributing shardable PromQL queries.
 func (o *Overrides) QueryVerticalShardSize(userID string) int {
 	return o.GetOverridesForUser(userID).QueryVerticalShardSize
@@ -645,6 +827,56 @@ func (o *Overrides) CompactorBlocksRetentionPeriod(userID string) time.Duration
 	return time.Duration(o.GetOverridesForUser(userID).CompactorBlocksRetentionPeriod)
 }
 
+// CompactorBlocksRetentionPeriod5m returns the retention period of 5m downsampled blocks for a given user.
+func (o *Overrides) CompactorBlocksRetentionPeriod5m(userID string) time.Duration {
+	return time.Duration(o.GetOverridesForUser(userID).CompactorBlocksRetentionPeriod5m)
+}
+
+// CompactorBlocksRetentionPeriod1h returns the retention period of 1h downsampled blocks for a given user.
+func (o *Overrides) CompactorBlocksRetentionPeriod1h(userID string) time.Duration {
+	return time.Duration(o.GetOverridesForUser(userID).CompactorBlocksRetentionPeriod1h)
+}
+
+// CompactorDownsamplingEnabled returns whether blocks of a given user should be downsampled.
+func (o *Overrides) CompactorDownsamplingEnabled(userID string) bool {
+	return o.GetOverridesForUser(userID).CompactorDownsamplingEnabled
+}
+
+// DeleteRequestCancelPeriod returns the period during which a series deletion request of a given user can be cancelled.
+func (o *Overrides) DeleteRequestCancelPeriod(userID string) time.Duration {
+	return time.Duration(o.GetOverridesForUser(userID).DeleteRequestCancelPeriod)
+}
+
+// CompactorTenantCompactionWindows returns the time windows during which the blocks of a given user can be compacted.
+func (o *Overrides) CompactorTenantCompactionWindows(userID string) flagext.TimeWindows {
+	return o.GetOverridesForUser(userID).CompactorTenantCompactionWindows
+}
+
+// CompactorTenantPriority returns the compaction priority of a given user.
+func (o *Overrides) CompactorTenantPriority(userID string) int {
+	return o.GetOverridesForUser(userID).CompactorTenantPriority
+}
+
+// CompactorDeduplicationReplicaLabels returns the external labels identifying the replica which uploaded a block of a given user.
+func (o *Overrides) CompactorDeduplicationReplicaLabels(userID string) []string {
+	return o.GetOverridesForUser(userID).CompactorDeduplicationReplicaLabels
+}
+
+// CompactorDeduplicationFunc returns the function used to deduplicate the samples of overlapping blocks of a given user.
+func (o *Overrides) CompactorDeduplicationFunc(userID string) string {
+	return o.GetOverridesForUser(userID).CompactorDeduplicationFunc
+}
+
+// CompactorRollupRules returns the rollup rules evaluated by the compactor over the blocks of a given user.
+func (o *Overrides) CompactorRollupRules(userID string) []RollupRule {
+	return o.GetOverridesForUser(userID).CompactorRollupRules
+}
+
+// CompactorRelabelConfigs returns the relabel configs applied by the compactor to the blocks of a given user.
+func (o *Overrides) CompactorRelabelConfigs(userID string) []*relabel.Config {
+	return o.GetOverridesForUser(userID).CompactorRelabelConfigs
+}
+
 // CompactorTenantShardSize returns shard size (number of rulers) used by this tenant when using shuffle-sharding strategy.
 func (o *Overrides) CompactorTenantShardSize(userID string) int {
 	return o.GetOverridesForUser(userID).CompactorTenantShardSize
@@ -670,6 +902,56 @@ func (o *Overrides) RulerMaxRuleGroupsPerTenant(userID string) int {
 	return o.GetOverridesForUser(userID).RulerMaxRuleGroupsPerTenant
 }
 
+// RulerAllowedSourceTenants returns the tenants whose data can be queried by the federated rule groups of a given user.
+func (o *Overrides) RulerAllowedSourceTenants(userID string) []string {
+	return o.GetOverridesForUser(userID).RulerAllowedSourceTenants
+}
+
+// RulerAllowedDestinationTenants returns the tenants the rule groups of a given user can write their series to.
+func (o *Overrides) RulerAllowedDestinationTenants(userID string) []string {
+	return o.GetOverridesForUser(userID).RulerAllowedDestinationTenants
+}
+
+// RulerAllowedAlertmanagerTenants returns the tenants whose alertmanager can also receive the alerts of the rule groups of a given user.
+func (o *Overrides) RulerAllowedAlertmanagerTenants(userID string) []string {
+	return o.GetOverridesForUser(userID).RulerAllowedAlertmanagerTenants
+}
+
+// RulerAllowedAlertmanagerURLs returns the alertmanager URLs which can also receive the alerts of the rule groups of a given user.
+func (o *Overrides) RulerAllowedAlertmanagerURLs(userID string) []string {
+	return o.GetOverridesForUser(userID).RulerAllowedAlertmanagerURLs
+}
+
+// RulerMaxConcurrentEvaluations returns the maximum number of rule queries of a given user evaluated concurrently by each ruler.
+func (o *Overrides) RulerMaxConcurrentEvaluations(userID string) int {
+	return o.GetOverridesForUser(userID).RulerMaxConcurrentEvaluations
+}
+
+// RulerEvaluationQueryTimeout returns the timeout of each rule query of a given user.
+func (o *Overrides) RulerEvaluationQueryTimeout(userID string) time.Duration {
+	return time.Duration(o.GetOverridesForUser(userID).RulerEvaluationQueryTimeout)
+}
+
+// RulerMaxQueryBytesPerMinute returns the maximum combined size of the data fetched by the rule queries of a given user each minute.
+func (o *Overrides) RulerMaxQueryBytesPerMinute(userID string) int64 {
+	return o.GetOverridesForUser(userID).RulerMaxQueryBytesPerMinute
+}
+
+// RulerMaxSeriesPerRule returns the maximum number of series a recording rule of a given user can write at each evaluation.
+func (o *Overrides) RulerMaxSeriesPerRule(userID string) int {
+	return o.GetOverridesForUser(userID).RulerMaxSeriesPerRule
+}
+
+// RulerMaxAlertsPerRule returns the maximum number of alerts an alerting rule of a given user can have at each evaluation.
+func (o *Overrides) RulerMaxAlertsPerRule(userID string) int {
+	return o.GetOverridesForUser(userID).RulerMaxAlertsPerRule
+}
+
+// RulerMaxSeriesPerRuleGroup returns the maximum number of series and alerts of the rules of a rule group of a given user at each evaluation.
+func (o *Overrides) RulerMaxSeriesPerRuleGroup(userID string) int {
+	return o.GetOverridesForUser(userID).RulerMaxSeriesPerRuleGroup
+}
+
 // StoreGatewayTenantShardSize returns the store-gateway shard size for a given user.
 func (o *Overrides) StoreGatewayTenantShardSize(userID string) float64 {
 	return o.GetOverridesForUser(userID).StoreGatewayTenantShardSize
@@ -695,6 +977,11 @@ func (o *Overrides) S3SSEKMSEncryptionContext(user string) string {
 	return o.GetOverridesForUser(user).S3SSEKMSEncryptionContext
 }
 
+// GCSKMSKeyName returns the per-tenant GCS KMS key name.
+func (o *Overrides) GCSKMSKeyName(user string) string {
+	return o.GetOverridesForUser(user).GCSKMSKeyName
+}
+
 // AlertmanagerReceiversBlockCIDRNetworks returns the list of network CIDRs that should be blocked
 // in the Alertmanager receivers for the given user.
 func (o *Overrides) AlertmanagerReceiversBlockCIDRNetworks(user string) []flagext.CIDR {
@@ -707,6 +994,23 @@ func (o *Overrides) AlertmanagerReceiversBlockPrivateAddresses(user string) bool
 	return o.GetOverridesForUser(user).AlertmanagerReceiversBlockPrivateAddresses
 }
 
+// AlertmanagerReceiversAllowedHosts returns the list of hosts allowed as destination of the
+// Alertmanager receivers for the given user. If empty, all hosts are allowed.
+func (o *Overrides) AlertmanagerReceiversAllowedHosts(user string) []string {
+	return o.GetOverridesForUser(user).AlertmanagerReceiversAllowedHosts
+}
+
+// AlertmanagerReceiversBlockedHosts returns the list of hosts blocked as destination of the
+// Alertmanager receivers for the given user.
+func (o *Overrides) AlertmanagerReceiversBlockedHosts(user string) []string {
+	return o.GetOverridesForUser(user).AlertmanagerReceiversBlockedHosts
+}
+
+// AlertmanagerReceiversTLSConfigs returns the TLS configs of the Alertmanager receivers for the given user.
+func (o *Overrides) AlertmanagerReceiversTLSConfigs(user string) []AlertmanagerReceiverTLSConfig {
+	return o.GetOverridesForUser(user).AlertmanagerReceiversTLSConfigs
+}
+
 // MaxExemplars gets the maximum number of exemplars that will be stored per user. 0 or less means disabled.
 func (o *Overrides) MaxExemplars(userID string) int {
 	return o.GetOverridesForUser(userID).MaxExemplars
@@ -784,6 +1088,36 @@ func (o *Overrides) AlertmanagerMaxAlertsSizeBytes(userID string) int {
 	return o.GetOverridesForUser(userID).AlertmanagerMaxAlertsSizeBytes
 }
 
+// AlertmanagerEvictResolvedAlerts returns whether the resolved alerts of a given user are evicted when its alerts limits are reached.
+func (o *Overrides) AlertmanagerEvictResolvedAlerts(userID string) bool {
+	return o.GetOverridesForUser(userID).AlertmanagerEvictResolvedAlerts
+}
+
+// AlertmanagerMaxSilencesCount returns the max number of active and pending silences of a given user.
+func (o *Overrides) AlertmanagerMaxSilencesCount(userID string) int {
+	return o.GetOverridesForUser(userID).AlertmanagerMaxSilencesCount
+}
+
+// AlertmanagerMaxTotalSilences returns the max number of silences, including the expired ones, of a given user.
+func (o *Overrides) AlertmanagerMaxTotalSilences(userID string) int {
+	return o.GetOverridesForUser(userID).AlertmanagerMaxTotalSilences
+}
+
+// AlertmanagerExpiredSilencesMaxAge returns the max time the expired silences of a given user are kept.
+func (o *Overrides) AlertmanagerExpiredSilencesMaxAge(userID string) time.Duration {
+	return time.Duration(o.GetOverridesForUser(userID).AlertmanagerExpiredSilencesMaxAge)
+}
+
+// AlertmanagerNotificationLogMaxSizeBytes returns the max size of the notification log of a given user.
+func (o *Overrides) AlertmanagerNotificationLogMaxSizeBytes(userID string) int {
+	return o.GetOverridesForUser(userID).AlertmanagerNotificationLogMaxSizeBytes
+}
+
+// AlertmanagerFallbackConfigName returns the name of the fallback config used by the alertmanager of a given user without its own configuration.
+func (o *Overrides) AlertmanagerFallbackConfigName(userID string) string {
+	return o.GetOverridesForUser(userID).AlertmanagerFallbackConfigName
+}
+
 func (o *Overrides) DisabledRuleGroups(userID string) DisabledRuleGroups {
 	if o.tenantLimits != nil {
 		l := o.tenantLimits.ByUserID(userID)
@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	commoncfg "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/relabel"
 	"github.com/stretchr/testify/assert"
@@ -231,6 +232,155 @@ metric_relabel_configs:
 	assert.Equal(t, []*relabel.Config{&exp}, l.MetricRelabelConfigs)
 }
 
+func TestCompactorRollupRulesLoadingFromYaml(t *testing.T) {
+	SetDefaultLimitsForYAMLUnmarshalling(Limits{})
+
+	for name, tc := range map[string]struct {
+		input       string
+		expected    []RollupRule
+		expectedErr string
+	}{
+		"valid rules": {
+			input: `
+compactor_rollup_rules:
+- record: job:http_requests:rate5m
+  expr: sum by (job) (rate(http_requests_total[5m]))
+  interval: 5m
+  delete_source_series_after: 30d
+`,
+			expected: []RollupRule{{
+				Record:                  "job:http_requests:rate5m",
+				Expr:                    "sum by (job) (rate(http_requests_total[5m]))",
+				Interval:                model.Duration(5 * time.Minute),
+				DeleteSourceSeriesAfter: model.Duration(30 * 24 * time.Hour),
+			}},
+		},
+		"invalid record": {
+			input: `
+compactor_rollup_rules:
+- record: "job-requests"
+  expr: sum(http_requests_total)
+  interval: 5m
+`,
+			expectedErr: `invalid rollup rule record name "job-requests"`,
+		},
+		"invalid expression": {
+			input: `
+compactor_rollup_rules:
+- record: job:http_requests:sum
+  expr: sum(
+  interval: 5m
+`,
+			expectedErr: "invalid rollup rule job:http_requests:sum expression",
+		},
+		"missing interval": {
+			input: `
+compactor_rollup_rules:
+- record: job:http_requests:sum
+  expr: sum(http_requests_total)
+`,
+			expectedErr: "invalid rollup rule job:http_requests:sum interval, must be greater than 0",
+		},
+		"duplicate record": {
+			input: `
+compactor_rollup_rules:
+- record: job:http_requests:sum
+  expr: sum(http_requests_total)
+  interval: 5m
+- record: job:http_requests:sum
+  expr: sum(http_requests_total)
+  interval: 1h
+`,
+			expectedErr: `duplicate rollup rule record name "job:http_requests:sum"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			l := Limits{}
+			err := yaml.UnmarshalStrict([]byte(tc.input), &l)
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, l.CompactorRollupRules)
+		})
+	}
+}
+
+func TestAlertmanagerReceiversTLSConfigsLoadingFromYaml(t *testing.T) {
+	SetDefaultLimitsForYAMLUnmarshalling(Limits{})
+
+	for name, tc := range map[string]struct {
+		input       string
+		expected    []AlertmanagerReceiverTLSConfig
+		expectedErr string
+	}{
+		"valid configs": {
+			input: `
+alertmanager_receivers_tls_configs:
+- hosts: [internal.example.com]
+  tls_config:
+    ca_file: /certs/ca.crt
+    cert_file: /certs/client.crt
+    key_file: /certs/client.key
+`,
+			expected: []AlertmanagerReceiverTLSConfig{{
+				Hosts:     []string{"internal.example.com"},
+				TLSConfig: commoncfg.TLSConfig{CAFile: "/certs/ca.crt", CertFile: "/certs/client.crt", KeyFile: "/certs/client.key"},
+			}},
+		},
+		"missing hosts": {
+			input: `
+alertmanager_receivers_tls_configs:
+- tls_config:
+    ca_file: /certs/ca.crt
+`,
+			expectedErr: "the alertmanager receiver TLS config requires at least one host",
+		},
+		"invalid TLS config": {
+			input: `
+alertmanager_receivers_tls_configs:
+- hosts: [internal.example.com]
+  tls_config:
+    ca: ca
+    ca_file: /certs/ca.crt
+`,
+			expectedErr: "at most one of ca and ca_file must be configured",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			l := Limits{}
+			err := yaml.UnmarshalStrict([]byte(tc.input), &l)
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, l.AlertmanagerReceiversTLSConfigs)
+		})
+	}
+}
+
+func TestCompactorDeduplicationLoadingFromYaml(t *testing.T) {
+	SetDefaultLimitsForYAMLUnmarshalling(Limits{})
+
+	l := Limits{}
+	require.NoError(t, yaml.UnmarshalStrict([]byte(`
+compactor_deduplication_replica_labels: replica,prometheus_replica
+compactor_deduplication_func: penalty
+`), &l))
+	assert.Equal(t, []string{"replica", "prometheus_replica"}, []string(l.CompactorDeduplicationReplicaLabels))
+	assert.Equal(t, CompactorDeduplicationFuncPenalty, l.CompactorDeduplicationFunc)
+
+	l = Limits{}
+	err := yaml.UnmarshalStrict([]byte(`compactor_deduplication_func: unknown`), &l)
+	assert.Equal(t, errInvalidCompactorDeduplicationFunc, err)
+}
+
 func TestSmallestPositiveIntPerTenant(t *testing.T) {
 	tenantLimits := map[string]*Limits{
 		"tenant-a": {
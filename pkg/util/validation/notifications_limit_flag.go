@@ -11,7 +11,7 @@ import (
 
 var allowedIntegrationNames = []string{
 	"webhook", "email", "pagerduty", "opsgenie", "wechat", "slack", "victorops", "pushover", "sns", "telegram", "discord", "webex",
-	"msteams",
+	"msteams", "http",
 }
 
 type NotificationRateLimitMap map[string]float64
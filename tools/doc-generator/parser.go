@@ -9,6 +9,7 @@ import (
 	"unicode"
 
 	"github.com/pkg/errors"
+	commoncfg "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
 	"github.com/weaveworks/common/logging"
 
@@ -276,7 +277,7 @@ func getFieldType(t reflect.Type) (string, error) {
 	switch t.String() {
 	case "*url.URL":
 		return "url", nil
-	case "time.Duration":
+	case "time.Duration", "model.Duration":
 		return "duration", nil
 	case "cortex.moduleName":
 		return "string", nil
@@ -284,6 +285,8 @@ func getFieldType(t reflect.Type) (string, error) {
 		return "string", nil
 	case "flagext.CIDRSliceCSV":
 		return "string", nil
+	case "flagext.TimeWindows":
+		return "string", nil
 	case "[]*relabel.Config":
 		return "relabel_config...", nil
 	}
@@ -387,6 +390,15 @@ func getCustomFieldEntry(parent reflect.Type, field reflect.StructField, fieldVa
 			fieldDefault: fieldFlag.DefValue,
 		}, nil
 	}
+	if field.Type == reflect.TypeOf(commoncfg.TLSConfig{}) {
+		return &configEntry{
+			kind:      "field",
+			name:      getFieldName(field),
+			required:  isFieldRequired(field),
+			fieldDesc: getFieldDescription(field, ""),
+			fieldType: "tls_config",
+		}, nil
+	}
 	if field.Type == reflect.TypeOf(flagext.Secret{}) {
 		fieldFlag, err := getFieldFlag(parent, field, fieldValue, flags)
 		if err != nil {
@@ -0,0 +1,485 @@
+package storagemigrate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/runutil"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+var (
+	// The prefixes under which the ruler storage stores the objects of the tenants, following the pattern
+	// <prefix>/<user-id>/<object>.
+	rulerPrefixes = []string{"rules", "ruler-alerts-state", "ruler-last-evaluations", "ruler-rules-versions"}
+
+	// The prefixes under which the alertmanager storage stores the objects of the tenants, following the
+	// pattern <prefix>/<user-id> or <prefix>/<user-id>/<object>.
+	alertmanagerPrefixes = []string{"alerts", "alertmanager-templates", "alertmanager"}
+)
+
+// Config holds the source and destination buckets of the blocks storage and, optionally, of the ruler and
+// alertmanager storages.
+type Config struct {
+	Blocks       BucketsConfig  `yaml:"blocks"`
+	Ruler        *BucketsConfig `yaml:"ruler"`
+	Alertmanager *BucketsConfig `yaml:"alertmanager"`
+}
+
+// BucketsConfig holds the source and destination buckets of a storage.
+type BucketsConfig struct {
+	Source      bucket.Config `yaml:"source"`
+	Destination bucket.Config `yaml:"destination"`
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (c *BucketsConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = BucketsConfig{}
+	flagext.DefaultValues(&c.Source)
+	flagext.DefaultValues(&c.Destination)
+
+	type plain BucketsConfig
+	return unmarshal((*plain)(c))
+}
+
+// Validate the config.
+func (c *Config) Validate() error {
+	for name, cfg := range map[string]*BucketsConfig{"blocks": &c.Blocks, "ruler": c.Ruler, "alertmanager": c.Alertmanager} {
+		if cfg == nil {
+			continue
+		}
+		if err := cfg.Source.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid %s source bucket config", name)
+		}
+		if err := cfg.Destination.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid %s destination bucket config", name)
+		}
+	}
+	return nil
+}
+
+// Migrator copies the blocks, the ruler objects and the alertmanager objects of tenants from a storage backend to
+// another, verifying the checksum of every copied object. The blocks already copied are skipped, so that the
+// migration can be run repeatedly while Cortex keeps using the source storage, and resumed after a failure.
+type Migrator struct {
+	logger log.Logger
+	dryRun bool
+
+	blocks, ruler, alertmanager *buckets
+}
+
+type buckets struct {
+	source, destination objstore.InstrumentedBucket
+}
+
+type Results struct {
+	CopiedBlocks, SkippedBlocks, FailedBlocks []string
+
+	// The blocks marked for deletion in the destination storage at cutover.
+	MarkedForDeletionBlocks []string
+
+	// The ruler and alertmanager objects copied and failed to be copied.
+	CopiedObjects, FailedObjects []string
+}
+
+func (r *Results) add(other Results) {
+	r.CopiedBlocks = append(r.CopiedBlocks, other.CopiedBlocks...)
+	r.SkippedBlocks = append(r.SkippedBlocks, other.SkippedBlocks...)
+	r.FailedBlocks = append(r.FailedBlocks, other.FailedBlocks...)
+	r.MarkedForDeletionBlocks = append(r.MarkedForDeletionBlocks, other.MarkedForDeletionBlocks...)
+	r.CopiedObjects = append(r.CopiedObjects, other.CopiedObjects...)
+	r.FailedObjects = append(r.FailedObjects, other.FailedObjects...)
+}
+
+// NewMigrator creates a Migrator.
+func NewMigrator(ctx context.Context, cfg Config, dryRun bool, logger log.Logger) (*Migrator, error) {
+	newBuckets := func(cfg *BucketsConfig, name string) (*buckets, error) {
+		if cfg == nil {
+			return nil, nil
+		}
+
+		source, err := bucket.NewClient(ctx, cfg.Source, "storagemigrate-"+name+"-source", logger, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "create %s source bucket client", name)
+		}
+		destination, err := bucket.NewClient(ctx, cfg.Destination, "storagemigrate-"+name+"-destination", logger, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "create %s destination bucket client", name)
+		}
+		return &buckets{source: source, destination: destination}, nil
+	}
+
+	blocks, err := newBuckets(&cfg.Blocks, "blocks")
+	if err != nil {
+		return nil, err
+	}
+	ruler, err := newBuckets(cfg.Ruler, "ruler")
+	if err != nil {
+		return nil, err
+	}
+	alertmanager, err := newBuckets(cfg.Alertmanager, "alertmanager")
+	if err != nil {
+		return nil, err
+	}
+
+	return newMigrator(blocks, ruler, alertmanager, dryRun, logger), nil
+}
+
+func newMigrator(blocks, ruler, alertmanager *buckets, dryRun bool, logger log.Logger) *Migrator {
+	// The markers are kept in the global markers location too, as done by Cortex.
+	blocks = &buckets{
+		source:      bucketindex.BucketWithGlobalMarkers(blocks.source),
+		destination: bucketindex.BucketWithGlobalMarkers(blocks.destination),
+	}
+
+	return &Migrator{
+		logger:       logger,
+		dryRun:       dryRun,
+		blocks:       blocks,
+		ruler:        ruler,
+		alertmanager: alertmanager,
+	}
+}
+
+// Tenants returns the tenants of the source storages: the tenants with blocks not marked for deletion, and the
+// tenants with ruler or alertmanager configs.
+func (m *Migrator) Tenants(ctx context.Context) ([]string, error) {
+	users, _, err := cortex_tsdb.NewUsersScanner(m.blocks.source, cortex_tsdb.AllUsers, m.logger).ScanUsers(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "list tenants of the blocks storage")
+	}
+
+	tenants := map[string]struct{}{}
+	for _, userID := range users {
+		tenants[userID] = struct{}{}
+	}
+
+	for _, b := range []struct {
+		buckets *buckets
+		prefix  string
+	}{{m.ruler, "rules"}, {m.alertmanager, "alerts"}} {
+		if b.buckets == nil {
+			continue
+		}
+		if err := b.buckets.source.Iter(ctx, b.prefix, func(name string) error {
+			tenants[strings.TrimSuffix(strings.TrimPrefix(name, b.prefix+objstore.DirDelim), objstore.DirDelim)] = struct{}{}
+			return nil
+		}); err != nil {
+			return nil, errors.Wrapf(err, "list tenants under %s", b.prefix)
+		}
+	}
+
+	result := make([]string, 0, len(tenants))
+	for userID := range tenants {
+		result = append(result, userID)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// Run migrates the tenant. The blocks marked for deletion and the partial blocks are not copied, while the blocks
+// already existing in the destination storage are skipped. The ruler and alertmanager objects are copied if they
+// differ in the destination storage.
+//
+// The cutover is run once Cortex has been switched to the destination storage: it copies the blocks uploaded to the
+// source storage in the meantime, marks for deletion the copied blocks which have been marked for deletion in the
+// source storage, for example because they've been compacted, and regenerates the bucket index of the tenant in the
+// destination storage. The ruler and alertmanager objects are copied at cutover only if missing in the destination
+// storage, so that the changes made since the switch aren't overwritten.
+func (m *Migrator) Run(ctx context.Context, userID string, cutover bool) (Results, error) {
+	results := Results{}
+
+	if userID == "" {
+		return results, errors.New("tenant must be set")
+	}
+	if userID == "." || userID == ".." {
+		return results, fmt.Errorf("invalid tenant ID %q", userID)
+	}
+	if err := tenant.ValidTenantID(userID); err != nil {
+		return results, errors.Wrapf(err, "invalid tenant ID %q", userID)
+	}
+
+	logger := log.With(m.logger, "user", userID)
+
+	blocksResults, err := m.migrateBlocks(ctx, logger, userID, cutover)
+	results.add(blocksResults)
+	if err != nil {
+		return results, err
+	}
+
+	for _, s := range []struct {
+		buckets  *buckets
+		prefixes []string
+	}{{m.ruler, rulerPrefixes}, {m.alertmanager, alertmanagerPrefixes}} {
+		if s.buckets == nil {
+			continue
+		}
+		objectsResults, err := m.migrateObjects(ctx, logger, s.buckets, s.prefixes, userID, cutover)
+		results.add(objectsResults)
+		if err != nil {
+			return results, err
+		}
+	}
+
+	return results, nil
+}
+
+func (m *Migrator) migrateBlocks(ctx context.Context, logger log.Logger, userID string, cutover bool) (Results, error) {
+	results := Results{}
+
+	// No per-tenant config provider because the storagemigrate tool doesn't support it.
+	sourceBkt := bucket.NewUserBucketClient(userID, m.blocks.source, nil)
+	destinationBkt := bucket.NewUserBucketClient(userID, m.blocks.destination, nil)
+
+	var blockIDs []ulid.ULID
+	if err := sourceBkt.Iter(ctx, "", func(name string) error {
+		if id, ok := block.IsBlockDir(name); ok {
+			blockIDs = append(blockIDs, id)
+		}
+		return nil
+	}); err != nil {
+		return results, errors.Wrap(err, "list blocks of the source storage")
+	}
+
+	var deletedBlockIDs []ulid.ULID
+	for _, id := range blockIDs {
+		logger := log.With(logger, "block", id.String())
+
+		copied, deleted, err := m.migrateBlock(ctx, logger, sourceBkt, destinationBkt, id)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to copy block", "err", err)
+			results.FailedBlocks = append(results.FailedBlocks, id.String())
+		} else if copied {
+			results.CopiedBlocks = append(results.CopiedBlocks, id.String())
+		} else {
+			results.SkippedBlocks = append(results.SkippedBlocks, id.String())
+		}
+		if deleted {
+			deletedBlockIDs = append(deletedBlockIDs, id)
+		}
+	}
+
+	if !cutover || m.dryRun {
+		return results, nil
+	}
+
+	markedForDeletion := prometheus.NewCounter(prometheus.CounterOpts{})
+	for _, id := range deletedBlockIDs {
+		if exists, err := destinationBkt.Exists(ctx, path.Join(id.String(), block.MetaFilename)); err != nil {
+			return results, err
+		} else if !exists {
+			continue
+		}
+		if exists, err := destinationBkt.Exists(ctx, path.Join(id.String(), metadata.DeletionMarkFilename)); err != nil {
+			return results, err
+		} else if exists {
+			continue
+		}
+
+		if err := block.MarkForDeletion(ctx, logger, destinationBkt, id, "block marked for deletion in the source storage", markedForDeletion); err != nil {
+			return results, errors.Wrapf(err, "mark block %s for deletion", id)
+		}
+		results.MarkedForDeletionBlocks = append(results.MarkedForDeletionBlocks, id.String())
+	}
+
+	if err := m.updateBucketIndex(ctx, userID); err != nil {
+		return results, errors.Wrap(err, "update bucket index of the destination storage")
+	}
+	level.Info(logger).Log("msg", "updated bucket index of the destination storage")
+
+	return results, nil
+}
+
+// migrateBlock copies the block to the destination storage, writing the meta.json last so that the block is
+// complete once visible. It returns false if the block has been skipped, and whether the block is marked for
+// deletion in the source storage.
+func (m *Migrator) migrateBlock(ctx context.Context, logger log.Logger, sourceBkt, destinationBkt objstore.Bucket, id ulid.ULID) (bool, bool, error) {
+	if exists, err := sourceBkt.Exists(ctx, path.Join(id.String(), metadata.DeletionMarkFilename)); err != nil {
+		return false, false, err
+	} else if exists {
+		level.Debug(logger).Log("msg", "skipped block marked for deletion")
+		return false, true, nil
+	}
+
+	if exists, err := sourceBkt.Exists(ctx, path.Join(id.String(), block.MetaFilename)); err != nil {
+		return false, false, err
+	} else if !exists {
+		level.Info(logger).Log("msg", "skipped partial block")
+		return false, false, nil
+	}
+
+	if exists, err := destinationBkt.Exists(ctx, path.Join(id.String(), block.MetaFilename)); err != nil {
+		return false, false, err
+	} else if exists {
+		level.Debug(logger).Log("msg", "skipped block already existing in the destination storage")
+		return false, false, nil
+	}
+
+	if m.dryRun {
+		level.Info(logger).Log("msg", "block would be copied (dry-run)")
+		return true, false, nil
+	}
+
+	var names []string
+	if err := sourceBkt.Iter(ctx, id.String(), func(name string) error {
+		if name != path.Join(id.String(), block.MetaFilename) {
+			names = append(names, name)
+		}
+		return nil
+	}, objstore.WithRecursiveIter); err != nil {
+		return false, false, errors.Wrap(err, "list block objects")
+	}
+
+	for _, name := range append(names, path.Join(id.String(), block.MetaFilename)) {
+		if err := copyObject(ctx, logger, sourceBkt, destinationBkt, name); err != nil {
+			return false, false, errors.Wrapf(err, "copy %s", name)
+		}
+	}
+
+	level.Info(logger).Log("msg", "copied block")
+	return true, false, nil
+}
+
+// migrateObjects copies the objects of the tenant stored under the prefixes.
+func (m *Migrator) migrateObjects(ctx context.Context, logger log.Logger, b *buckets, prefixes []string, userID string, cutover bool) (Results, error) {
+	results := Results{}
+
+	var names []string
+	for _, prefix := range prefixes {
+		name := path.Join(prefix, userID)
+		if exists, err := b.source.Exists(ctx, name); err != nil {
+			return results, err
+		} else if exists {
+			names = append(names, name)
+		}
+
+		if err := b.source.Iter(ctx, name+objstore.DirDelim, func(name string) error {
+			names = append(names, name)
+			return nil
+		}, objstore.WithRecursiveIter); err != nil {
+			return results, errors.Wrapf(err, "list objects under %s", name)
+		}
+	}
+
+	for _, name := range names {
+		logger := log.With(logger, "object", name)
+
+		copied, err := m.migrateObject(ctx, logger, b, name, cutover)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to copy object", "err", err)
+			results.FailedObjects = append(results.FailedObjects, name)
+		} else if copied {
+			results.CopiedObjects = append(results.CopiedObjects, name)
+		}
+	}
+	return results, nil
+}
+
+// migrateObject copies the object if it's missing in the destination storage, or if it differs and the migration
+// isn't at cutover. It returns false if the object has been skipped.
+func (m *Migrator) migrateObject(ctx context.Context, logger log.Logger, b *buckets, name string, cutover bool) (bool, error) {
+	if exists, err := b.destination.Exists(ctx, name); err != nil {
+		return false, err
+	} else if exists {
+		if cutover {
+			return false, nil
+		}
+
+		sourceChecksum, err := checksum(ctx, logger, b.source, name)
+		if err != nil {
+			return false, err
+		}
+		destinationChecksum, err := checksum(ctx, logger, b.destination, name)
+		if err != nil {
+			return false, err
+		}
+		if bytes.Equal(sourceChecksum, destinationChecksum) {
+			return false, nil
+		}
+	}
+
+	if m.dryRun {
+		level.Info(logger).Log("msg", "object would be copied (dry-run)")
+		return true, nil
+	}
+
+	if err := copyObject(ctx, logger, b.source, b.destination, name); err != nil {
+		return false, err
+	}
+	level.Info(logger).Log("msg", "copied object")
+	return true, nil
+}
+
+func (m *Migrator) updateBucketIndex(ctx context.Context, userID string) error {
+	// The index is regenerated from scratch if missing or corrupted.
+	old, err := bucketindex.ReadIndex(ctx, m.blocks.destination, userID, nil, m.logger)
+	if errors.Is(err, bucketindex.ErrIndexNotFound) || errors.Is(err, bucketindex.ErrIndexCorrupted) {
+		old = nil
+	} else if err != nil {
+		return err
+	}
+
+	idx, _, _, err := bucketindex.NewUpdater(m.blocks.destination, userID, nil, m.logger).UpdateIndex(ctx, old)
+	if err != nil {
+		return err
+	}
+
+	return bucketindex.WriteIndex(ctx, m.blocks.destination, userID, nil, idx)
+}
+
+// copyObject copies the object, and verifies the checksum of the copy.
+func copyObject(ctx context.Context, logger log.Logger, src, dst objstore.Bucket, name string) error {
+	r, err := src.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer runutil.CloseWithLogOnErr(logger, r, "close storagemigrate object reader")
+
+	h := sha256.New()
+	if err := dst.Upload(ctx, name, io.TeeReader(r, h)); err != nil {
+		return err
+	}
+
+	copied, err := checksum(ctx, logger, dst, name)
+	if err != nil {
+		return errors.Wrap(err, "compute checksum of the copy")
+	}
+	if expected := h.Sum(nil); !bytes.Equal(expected, copied) {
+		return fmt.Errorf("checksum mismatch, expected: %x, got: %x", expected, copied)
+	}
+	return nil
+}
+
+// checksum returns the SHA-256 checksum of the object.
+func checksum(ctx context.Context, logger log.Logger, bkt objstore.Bucket, name string) ([]byte, error) {
+	r, err := bkt.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer runutil.CloseWithLogOnErr(logger, r, "close storagemigrate object reader")
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
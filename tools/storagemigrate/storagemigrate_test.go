@@ -0,0 +1,212 @@
+package storagemigrate
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"gopkg.in/yaml.v2"
+
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
+	cortex_testutil "github.com/cortexproject/cortex/pkg/storage/tsdb/testutil"
+)
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+
+	blocksSource, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	blocksDestination, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	rulerSource, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	rulerDestination, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	amSource, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	amDestination, _ := cortex_testutil.PrepareFilesystemBucket(t)
+
+	newTestMigrator := func(dryRun bool) *Migrator {
+		return newMigrator(
+			&buckets{source: blocksSource, destination: blocksDestination},
+			&buckets{source: rulerSource, destination: rulerDestination},
+			&buckets{source: amSource, destination: amDestination},
+			dryRun, logger)
+	}
+
+	uploadBlock := func(userID string, id ulid.ULID) {
+		meta := metadata.Meta{
+			BlockMeta: tsdb.BlockMeta{ULID: id, MinTime: 10, MaxTime: 20, Version: metadata.TSDBVersion1},
+			Thanos:    metadata.Thanos{Labels: map[string]string{cortex_tsdb.TenantIDExternalLabel: userID}},
+		}
+		var body bytes.Buffer
+		require.NoError(t, meta.Write(&body))
+		require.NoError(t, blocksSource.Upload(ctx, path.Join(userID, id.String(), block.IndexFilename), strings.NewReader("index")))
+		require.NoError(t, blocksSource.Upload(ctx, path.Join(userID, id.String(), block.ChunksDirname, "000001"), strings.NewReader("chunks")))
+		require.NoError(t, blocksSource.Upload(ctx, path.Join(userID, id.String(), block.MetaFilename), &body))
+	}
+	exists := func(bkt objstore.Bucket, name string) bool {
+		ok, err := bkt.Exists(ctx, name)
+		require.NoError(t, err)
+		return ok
+	}
+	content := func(bkt objstore.Bucket, name string) string {
+		r, err := bkt.Get(ctx, name)
+		require.NoError(t, err)
+		defer r.Close()
+		b, err := io.ReadAll(r)
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	block1 := ulid.MustNew(1, nil)
+	block2 := ulid.MustNew(2, nil)
+	block3 := ulid.MustNew(3, nil)
+	block4 := ulid.MustNew(4, nil)
+
+	// The source tenant has a block marked for no compaction, a block marked for deletion and a partial block.
+	uploadBlock("user-1", block1)
+	uploadBlock("user-1", block2)
+	require.NoError(t, blocksSource.Upload(ctx, path.Join("user-1", block3.String(), block.IndexFilename), strings.NewReader("index")))
+	globalMarkersBkt := bucketindex.BucketWithGlobalMarkers(blocksSource)
+	require.NoError(t, block.MarkForNoCompact(ctx, logger, objstore.NewPrefixedBucket(globalMarkersBkt, "user-1"), block1, metadata.ManualNoCompactReason, "", prometheus.NewCounter(prometheus.CounterOpts{})))
+	require.NoError(t, block.MarkForDeletion(ctx, logger, objstore.NewPrefixedBucket(globalMarkersBkt, "user-1"), block2, "", prometheus.NewCounter(prometheus.CounterOpts{})))
+
+	require.NoError(t, rulerSource.Upload(ctx, "rules/user-1/namespace/group", strings.NewReader("group")))
+	require.NoError(t, amSource.Upload(ctx, "alerts/user-1", strings.NewReader("config")))
+	require.NoError(t, amSource.Upload(ctx, "alertmanager/user-1/fullstate", strings.NewReader("state")))
+	require.NoError(t, amSource.Upload(ctx, "alerts/user-2", strings.NewReader("config")))
+
+	tenants, err := newTestMigrator(false).Tenants(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user-1", "user-2"}, tenants)
+
+	// Dry-run doesn't change anything.
+	results, err := newTestMigrator(true).Run(ctx, "user-1", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{block1.String()}, results.CopiedBlocks)
+	assert.Equal(t, []string{block2.String(), block3.String()}, results.SkippedBlocks)
+	assert.ElementsMatch(t, []string{"rules/user-1/namespace/group", "alerts/user-1", "alertmanager/user-1/fullstate"}, results.CopiedObjects)
+	assert.False(t, exists(blocksDestination, path.Join("user-1", block1.String(), block.MetaFilename)))
+	assert.False(t, exists(amDestination, "alerts/user-1"))
+
+	// Copy the blocks and the configs of the tenant.
+	results, err = newTestMigrator(false).Run(ctx, "user-1", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{block1.String()}, results.CopiedBlocks)
+	assert.Equal(t, []string{block2.String(), block3.String()}, results.SkippedBlocks)
+	assert.Empty(t, results.FailedBlocks)
+	assert.ElementsMatch(t, []string{"rules/user-1/namespace/group", "alerts/user-1", "alertmanager/user-1/fullstate"}, results.CopiedObjects)
+	assert.Empty(t, results.FailedObjects)
+
+	for _, name := range []string{
+		path.Join("user-1", block1.String(), block.MetaFilename),
+		path.Join("user-1", block1.String(), block.IndexFilename),
+		path.Join("user-1", block1.String(), block.ChunksDirname, "000001"),
+		path.Join("user-1", bucketindex.NoCompactMarkFilenameMarkFilepath(block1)),
+	} {
+		assert.True(t, exists(blocksDestination, name), name)
+	}
+	assert.False(t, exists(blocksDestination, path.Join("user-1", block2.String(), block.MetaFilename)))
+	assert.False(t, exists(blocksDestination, path.Join("user-1", block3.String(), block.IndexFilename)))
+	assert.Equal(t, "group", content(rulerDestination, "rules/user-1/namespace/group"))
+	assert.Equal(t, "state", content(amDestination, "alertmanager/user-1/fullstate"))
+	assert.False(t, exists(amDestination, "alerts/user-2"))
+
+	// Running the migration again only copies the new blocks and the changed configs.
+	uploadBlock("user-1", block4)
+	require.NoError(t, amSource.Upload(ctx, "alerts/user-1", strings.NewReader("updated config")))
+
+	results, err = newTestMigrator(false).Run(ctx, "user-1", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{block4.String()}, results.CopiedBlocks)
+	assert.Equal(t, []string{"alerts/user-1"}, results.CopiedObjects)
+	assert.Equal(t, "updated config", content(amDestination, "alerts/user-1"))
+
+	// At cutover, the changes made in the destination storage are kept, the blocks compacted in the source storage
+	// are marked for deletion, and the bucket index is regenerated.
+	require.NoError(t, amDestination.Upload(ctx, "alerts/user-1", strings.NewReader("config changed after the switch")))
+	require.NoError(t, block.MarkForDeletion(ctx, logger, objstore.NewPrefixedBucket(globalMarkersBkt, "user-1"), block4, "", prometheus.NewCounter(prometheus.CounterOpts{})))
+
+	results, err = newTestMigrator(false).Run(ctx, "user-1", true)
+	require.NoError(t, err)
+	assert.Empty(t, results.CopiedBlocks)
+	assert.Equal(t, []string{block4.String()}, results.MarkedForDeletionBlocks)
+	assert.Empty(t, results.CopiedObjects)
+	assert.Equal(t, "config changed after the switch", content(amDestination, "alerts/user-1"))
+
+	idx, err := bucketindex.ReadIndex(ctx, blocksDestination, "user-1", nil, logger)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []ulid.ULID{block1, block4}, idx.Blocks.GetULIDs())
+	assert.ElementsMatch(t, []ulid.ULID{block4}, idx.BlockDeletionMarks.GetULIDs())
+}
+
+func TestMigrator_ShouldFailOnChecksumMismatch(t *testing.T) {
+	ctx := context.Background()
+
+	source, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	require.NoError(t, source.Upload(ctx, "object", strings.NewReader("content")))
+
+	destination := &corruptingBucket{InstrumentedBucket: objstore.WithNoopInstr(objstore.NewInMemBucket())}
+	err := copyObject(ctx, log.NewNopLogger(), source, destination, "object")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "checksum mismatch")
+}
+
+func TestMigrator_ShouldRejectInvalidTenants(t *testing.T) {
+	bkt, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	migrator := newMigrator(&buckets{source: bkt, destination: bkt}, nil, nil, false, log.NewNopLogger())
+
+	_, err := migrator.Run(context.Background(), "", false)
+	require.EqualError(t, err, "tenant must be set")
+
+	_, err = migrator.Run(context.Background(), "..", false)
+	require.EqualError(t, err, `invalid tenant ID ".."`)
+
+	_, err = migrator.Run(context.Background(), "user/1", false)
+	require.Error(t, err)
+}
+
+func TestConfig_UnmarshalYAML(t *testing.T) {
+	cfg := Config{}
+	require.NoError(t, yaml.UnmarshalStrict([]byte(`
+blocks:
+  source:
+    backend: filesystem
+    filesystem:
+      dir: /data/source
+  destination:
+    backend: gcs
+    gcs:
+      bucket_name: destination
+`), &cfg))
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, "/data/source", cfg.Blocks.Source.Filesystem.Directory)
+	assert.Equal(t, "destination", cfg.Blocks.Destination.GCS.BucketName)
+	// The defaults of the bucket configs are applied.
+	assert.Equal(t, "v4", cfg.Blocks.Destination.S3.SignatureVersion)
+	assert.Nil(t, cfg.Ruler)
+	assert.Nil(t, cfg.Alertmanager)
+}
+
+// corruptingBucket is a bucket corrupting the uploaded objects.
+type corruptingBucket struct {
+	objstore.InstrumentedBucket
+}
+
+func (b *corruptingBucket) Upload(ctx context.Context, name string, r io.Reader) error {
+	if _, err := io.ReadAll(r); err != nil {
+		return err
+	}
+	return b.InstrumentedBucket.Upload(ctx, name, strings.NewReader("corrupted"))
+}
@@ -0,0 +1,235 @@
+package tenantrename
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/runutil"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
+	"github.com/cortexproject/cortex/pkg/tenant"
+)
+
+// TenantRenamer copies the blocks of a tenant to another tenant, rewriting the tenant ID in their
+// meta.json, and regenerates the bucket index of the target tenant. If the target tenant already
+// has blocks, the blocks of the two tenants are merged.
+type TenantRenamer struct {
+	logger log.Logger
+	bkt    objstore.InstrumentedBucket
+	dryRun bool
+
+	// Whether the source blocks are marked for deletion once all of them have been copied.
+	markSourceBlocksForDeletion bool
+}
+
+type Results struct {
+	RenamedBlocks, SkippedBlocks, FailedBlocks []string
+}
+
+// NewTenantRenamer creates a TenantRenamer.
+func NewTenantRenamer(ctx context.Context, cfg bucket.Config, dryRun, markSourceBlocksForDeletion bool, logger log.Logger) (*TenantRenamer, error) {
+	bkt, err := bucket.NewClient(ctx, cfg, "tenantrename", logger, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTenantRenamer(bkt, dryRun, markSourceBlocksForDeletion, logger), nil
+}
+
+func newTenantRenamer(bkt objstore.InstrumentedBucket, dryRun, markSourceBlocksForDeletion bool, logger log.Logger) *TenantRenamer {
+	return &TenantRenamer{
+		// The markers are kept in the global markers location too, as done by Cortex.
+		bkt:                         bucketindex.BucketWithGlobalMarkers(bkt),
+		logger:                      logger,
+		dryRun:                      dryRun,
+		markSourceBlocksForDeletion: markSourceBlocksForDeletion,
+	}
+}
+
+// Run copies the blocks of the source tenant to the target tenant. The blocks marked for deletion and the
+// partial blocks are not copied, while the blocks already existing in the target tenant are skipped, so
+// that the tool can be safely run again after a failure.
+func (r *TenantRenamer) Run(ctx context.Context, source, target string) (Results, error) {
+	results := Results{}
+
+	if source == "" || target == "" {
+		return results, errors.New("source and target tenants must be set")
+	}
+	for _, userID := range []string{source, target} {
+		if userID == "." || userID == ".." {
+			return results, fmt.Errorf("invalid tenant ID %q", userID)
+		}
+		if err := tenant.ValidTenantID(userID); err != nil {
+			return results, errors.Wrapf(err, "invalid tenant ID %q", userID)
+		}
+	}
+	if source == target {
+		return results, errors.New("source and target tenants must be different")
+	}
+
+	// No per-tenant config provider because the tenantrename tool doesn't support it.
+	sourceBkt := bucket.NewUserBucketClient(source, r.bkt, nil)
+	targetBkt := bucket.NewUserBucketClient(target, r.bkt, nil)
+
+	var blockIDs []ulid.ULID
+	if err := sourceBkt.Iter(ctx, "", func(name string) error {
+		if id, ok := block.IsBlockDir(name); ok {
+			blockIDs = append(blockIDs, id)
+		}
+		return nil
+	}); err != nil {
+		return results, errors.Wrapf(err, "list blocks of tenant %s", source)
+	}
+
+	for _, id := range blockIDs {
+		logger := log.With(r.logger, "block", id.String())
+
+		renamed, err := r.renameBlock(ctx, logger, sourceBkt, targetBkt, id, target)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to rename block", "err", err)
+			results.FailedBlocks = append(results.FailedBlocks, id.String())
+		} else if renamed {
+			results.RenamedBlocks = append(results.RenamedBlocks, id.String())
+		} else {
+			results.SkippedBlocks = append(results.SkippedBlocks, id.String())
+		}
+	}
+
+	if r.dryRun {
+		return results, nil
+	}
+
+	if err := r.updateBucketIndex(ctx, target); err != nil {
+		return results, errors.Wrapf(err, "update bucket index of tenant %s", target)
+	}
+	level.Info(r.logger).Log("msg", "updated bucket index", "user", target)
+
+	if !r.markSourceBlocksForDeletion {
+		return results, nil
+	}
+	if len(results.FailedBlocks) > 0 {
+		level.Warn(r.logger).Log("msg", "source blocks not marked for deletion because some blocks failed to be renamed", "user", source)
+		return results, nil
+	}
+
+	markedForDeletion := prometheus.NewCounter(prometheus.CounterOpts{})
+	for _, id := range append(results.RenamedBlocks, results.SkippedBlocks...) {
+		blockID := ulid.MustParse(id)
+		if exists, err := targetBkt.Exists(ctx, path.Join(id, block.MetaFilename)); err != nil || !exists {
+			// Partial blocks and blocks marked for deletion are not copied.
+			continue
+		}
+		if err := block.MarkForDeletion(ctx, r.logger, sourceBkt, blockID, fmt.Sprintf("block renamed to tenant %s", target), markedForDeletion); err != nil {
+			return results, errors.Wrapf(err, "mark block %s of tenant %s for deletion", id, source)
+		}
+	}
+
+	if err := r.updateBucketIndex(ctx, source); err != nil {
+		return results, errors.Wrapf(err, "update bucket index of tenant %s", source)
+	}
+	level.Info(r.logger).Log("msg", "marked source blocks for deletion and updated bucket index", "user", source)
+
+	return results, nil
+}
+
+// renameBlock copies the block to the target tenant, writing the meta.json last so that the block is
+// complete once visible. It returns false if the block has been skipped.
+func (r *TenantRenamer) renameBlock(ctx context.Context, logger log.Logger, sourceBkt, targetBkt objstore.Bucket, id ulid.ULID, target string) (bool, error) {
+	meta, err := block.DownloadMeta(ctx, logger, sourceBkt, id)
+	if sourceBkt.IsObjNotFoundErr(errors.Cause(err)) {
+		level.Info(logger).Log("msg", "skipped partial block")
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if exists, err := sourceBkt.Exists(ctx, path.Join(id.String(), metadata.DeletionMarkFilename)); err != nil {
+		return false, err
+	} else if exists {
+		level.Info(logger).Log("msg", "skipped block marked for deletion")
+		return false, nil
+	}
+
+	if exists, err := targetBkt.Exists(ctx, path.Join(id.String(), block.MetaFilename)); err != nil {
+		return false, err
+	} else if exists {
+		level.Info(logger).Log("msg", "skipped block already existing in the target tenant")
+		return false, nil
+	}
+
+	if r.dryRun {
+		level.Info(logger).Log("msg", "block would be renamed (dry-run)")
+		return true, nil
+	}
+
+	var names []string
+	if err := sourceBkt.Iter(ctx, id.String(), func(name string) error {
+		if name != path.Join(id.String(), block.MetaFilename) {
+			names = append(names, name)
+		}
+		return nil
+	}, objstore.WithRecursiveIter); err != nil {
+		return false, errors.Wrap(err, "list block objects")
+	}
+
+	for _, name := range names {
+		if err := copyObject(ctx, logger, sourceBkt, targetBkt, name); err != nil {
+			return false, errors.Wrapf(err, "copy %s", name)
+		}
+	}
+
+	if meta.Thanos.Labels == nil {
+		meta.Thanos.Labels = map[string]string{}
+	}
+	meta.Thanos.Labels[cortex_tsdb.TenantIDExternalLabel] = target
+
+	var body bytes.Buffer
+	if err := meta.Write(&body); err != nil {
+		return false, errors.Wrap(err, "encode meta.json")
+	}
+	if err := targetBkt.Upload(ctx, path.Join(id.String(), block.MetaFilename), &body); err != nil {
+		return false, errors.Wrap(err, "upload meta.json")
+	}
+
+	level.Info(logger).Log("msg", "renamed block")
+	return true, nil
+}
+
+func (r *TenantRenamer) updateBucketIndex(ctx context.Context, userID string) error {
+	// The index is regenerated from scratch if missing or corrupted.
+	old, err := bucketindex.ReadIndex(ctx, r.bkt, userID, nil, r.logger)
+	if errors.Is(err, bucketindex.ErrIndexNotFound) || errors.Is(err, bucketindex.ErrIndexCorrupted) {
+		old = nil
+	} else if err != nil {
+		return err
+	}
+
+	idx, _, _, err := bucketindex.NewUpdater(r.bkt, userID, nil, r.logger).UpdateIndex(ctx, old)
+	if err != nil {
+		return err
+	}
+
+	return bucketindex.WriteIndex(ctx, r.bkt, userID, nil, idx)
+}
+
+func copyObject(ctx context.Context, logger log.Logger, src, dst objstore.Bucket, name string) error {
+	r, err := src.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer runutil.CloseWithLogOnErr(logger, r, "close tenantrename object reader")
+
+	return dst.Upload(ctx, name, r)
+}
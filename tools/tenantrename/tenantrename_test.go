@@ -0,0 +1,123 @@
+package tenantrename
+
+import (
+	"bytes"
+	"context"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
+	cortex_testutil "github.com/cortexproject/cortex/pkg/storage/tsdb/testutil"
+)
+
+func TestTenantRenamer(t *testing.T) {
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+	bkt, _ := cortex_testutil.PrepareFilesystemBucket(t)
+
+	uploadBlock := func(userID string, id ulid.ULID) {
+		meta := metadata.Meta{
+			BlockMeta: tsdb.BlockMeta{ULID: id, MinTime: 10, MaxTime: 20, Version: metadata.TSDBVersion1},
+			Thanos:    metadata.Thanos{Labels: map[string]string{cortex_tsdb.TenantIDExternalLabel: userID, "zone": "a"}},
+		}
+		var body bytes.Buffer
+		require.NoError(t, meta.Write(&body))
+		require.NoError(t, bkt.Upload(ctx, path.Join(userID, id.String(), block.IndexFilename), strings.NewReader("index")))
+		require.NoError(t, bkt.Upload(ctx, path.Join(userID, id.String(), block.ChunksDirname, "000001"), strings.NewReader("chunks")))
+		require.NoError(t, bkt.Upload(ctx, path.Join(userID, id.String(), block.MetaFilename), &body))
+	}
+
+	block1 := ulid.MustNew(1, nil)
+	block2 := ulid.MustNew(2, nil)
+	block3 := ulid.MustNew(3, nil)
+	block4 := ulid.MustNew(4, nil)
+
+	// The source tenant has a block marked for no compaction, a block marked for deletion and a partial block.
+	uploadBlock("user-1", block1)
+	uploadBlock("user-1", block2)
+	require.NoError(t, bkt.Upload(ctx, path.Join("user-1", block3.String(), block.IndexFilename), strings.NewReader("index")))
+	globalMarkersBkt := bucketindex.BucketWithGlobalMarkers(bkt)
+	require.NoError(t, block.MarkForNoCompact(ctx, logger, objstore.NewPrefixedBucket(globalMarkersBkt, "user-1"), block1, metadata.ManualNoCompactReason, "", prometheus.NewCounter(prometheus.CounterOpts{})))
+	require.NoError(t, block.MarkForDeletion(ctx, logger, objstore.NewPrefixedBucket(globalMarkersBkt, "user-1"), block2, "", prometheus.NewCounter(prometheus.CounterOpts{})))
+
+	// The target tenant already has blocks.
+	uploadBlock("user-2", block4)
+
+	// Dry-run doesn't change anything.
+	results, err := newTenantRenamer(bkt, true, true, logger).Run(ctx, "user-1", "user-2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{block1.String()}, results.RenamedBlocks)
+	assert.Equal(t, []string{block2.String(), block3.String()}, results.SkippedBlocks)
+	assert.Empty(t, results.FailedBlocks)
+
+	exists, err := bkt.Exists(ctx, path.Join("user-2", block1.String(), block.MetaFilename))
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// Rename the blocks, marking the source blocks for deletion.
+	results, err = newTenantRenamer(bkt, false, true, logger).Run(ctx, "user-1", "user-2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{block1.String()}, results.RenamedBlocks)
+	assert.Equal(t, []string{block2.String(), block3.String()}, results.SkippedBlocks)
+	assert.Empty(t, results.FailedBlocks)
+
+	meta, err := block.DownloadMeta(ctx, logger, objstore.NewPrefixedBucket(bkt, "user-2"), block1)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-2", "zone": "a"}, meta.Thanos.Labels)
+
+	for _, name := range []string{
+		path.Join("user-2", block1.String(), block.IndexFilename),
+		path.Join("user-2", block1.String(), block.ChunksDirname, "000001"),
+		path.Join("user-2", bucketindex.NoCompactMarkFilenameMarkFilepath(block1)),
+		path.Join("user-1", block1.String(), metadata.DeletionMarkFilename),
+	} {
+		exists, err := bkt.Exists(ctx, name)
+		require.NoError(t, err)
+		assert.True(t, exists, name)
+	}
+
+	idx, err := bucketindex.ReadIndex(ctx, bkt, "user-2", nil, logger)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []ulid.ULID{block1, block4}, idx.Blocks.GetULIDs())
+	assert.Empty(t, idx.BlockDeletionMarks)
+
+	idx, err = bucketindex.ReadIndex(ctx, bkt, "user-1", nil, logger)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []ulid.ULID{block1, block2}, idx.Blocks.GetULIDs())
+	assert.ElementsMatch(t, []ulid.ULID{block1, block2}, idx.BlockDeletionMarks.GetULIDs())
+
+	// Running the tool again skips the blocks already renamed.
+	results, err = newTenantRenamer(bkt, false, false, logger).Run(ctx, "user-1", "user-2")
+	require.NoError(t, err)
+	assert.Empty(t, results.RenamedBlocks)
+	assert.Equal(t, []string{block1.String(), block2.String(), block3.String()}, results.SkippedBlocks)
+}
+
+func TestTenantRenamer_ShouldRejectInvalidTenants(t *testing.T) {
+	bkt, _ := cortex_testutil.PrepareFilesystemBucket(t)
+	renamer := newTenantRenamer(bkt, false, false, log.NewNopLogger())
+
+	_, err := renamer.Run(context.Background(), "user-1", "user-1")
+	require.EqualError(t, err, "source and target tenants must be different")
+
+	_, err = renamer.Run(context.Background(), "user-1", "..")
+	require.EqualError(t, err, `invalid tenant ID ".."`)
+
+	_, err = renamer.Run(context.Background(), "user-1", "user/2")
+	require.Error(t, err)
+
+	_, err = renamer.Run(context.Background(), "", "user-2")
+	require.EqualError(t, err, "source and target tenants must be set")
+}